@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+type AccountStore interface {
+	pkg.RoleGetter
+	pkg.SoleAdminStore
+	pkg.AccountEraser
+}
+
+// accountConfirmation is the text the user types to confirm that the account should be deleted
+func accountConfirmation(user *pkg.UserInfo) string {
+	if user.Email != "" {
+		return user.Email
+	}
+	return user.Name
+}
+
+// AccountHandler renders the form for deleting the account of the signed in user
+func AccountHandler(store AccountStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		userId := MustGetUserId(MustGetSession(r))
+		user, err := store.GetUserInfo(ctx, userId)
+		if err != nil {
+			http.Error(w, "Could not fetch user", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch user", "error", err, "userId", userId)
+			return
+		}
+		orgs, err := pkg.SoleAdminOrganizations(ctx, store, user)
+		if err != nil {
+			http.Error(w, "Could not fetch organizations", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch organizations", "error", err, "userId", userId)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		web.Account(w, pkg.LanguageFromReq(r), web.AccountData{Confirmation: accountConfirmation(user), SoleAdminOrgs: orgs})
+	}
+}
+
+// DeleteAccountHandler erases the signed in user and signs out. The form field "confirmation" must hold the email
+// address of the user, or the name of users without one. Users that are the only admin of an organization are
+// refused
+func DeleteAccountHandler(store AccountStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 1024)
+		code, err := parseForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		userId := MustGetUserId(MustGetSession(r))
+		user, err := store.GetUserInfo(ctx, userId)
+		if err != nil {
+			http.Error(w, "Could not fetch user", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch user", "error", err, "userId", userId)
+			return
+		}
+
+		confirmation := strings.TrimSpace(r.FormValue("confirmation"))
+		if expected := accountConfirmation(user); expected == "" || !strings.EqualFold(confirmation, expected) {
+			http.Error(w, "The confirmation does not match", http.StatusBadRequest)
+			return
+		}
+
+		orgs, err := pkg.SoleAdminOrganizations(ctx, store, user)
+		if err != nil {
+			http.Error(w, "Could not fetch organizations", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch organizations", "error", err, "userId", userId)
+			return
+		}
+		if len(orgs) > 0 {
+			names := make([]string, len(orgs))
+			for i, org := range orgs {
+				names[i] = org.Name
+			}
+			http.Error(w, "You are the only admin of "+strings.Join(names, ", "), http.StatusConflict)
+			return
+		}
+
+		if err := store.EraseUser(ctx, userId); err != nil {
+			http.Error(w, "Could not delete account", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not delete account", "error", err, "userId", userId)
+			return
+		}
+		slog.InfoContext(ctx, "Deleted account", "userId", userId)
+
+		w.Header().Set("HX-Redirect", "/")
+		SignOut(w, r)
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func accountStore(t *testing.T, otherRole pkg.RoleKind) *pkg.MultiOrgInMemoryStore {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &pkg.Organization{Id: "org1", Name: "Brass band"}))
+	for _, user := range []*pkg.UserInfo{
+		{Id: "0000-0000", Email: "john@example.com", Roles: map[string]pkg.RoleKind{"org1": pkg.RoleAdmin}},
+		{Id: "1111-1111", Email: "susan@example.com", Roles: map[string]pkg.RoleKind{"org1": otherRole}},
+	} {
+		testutils.AssertNil(t, store.RegisterUser(ctx, user))
+	}
+	return store
+}
+
+func deleteAccount(store AccountStore, confirmation string) *httptest.ResponseRecorder {
+	form := url.Values{"confirmation": {confirmation}}
+	req := httptest.NewRequest("DELETE", RouteAccount+"?"+form.Encode(), nil)
+	rec := httptest.NewRecorder()
+	DeleteAccountHandler(store, time.Second)(rec, withSignedInSession(req, "org1"))
+	return rec
+}
+
+func TestAccountHandler(t *testing.T) {
+	for _, test := range []struct {
+		otherRole pkg.RoleKind
+		want      string
+	}{
+		{pkg.RoleAdmin, `hx-delete="/account"`},
+		{pkg.RoleViewer, "Brass band"},
+	} {
+		rec := httptest.NewRecorder()
+		AccountHandler(accountStore(t, test.otherRole), time.Second)(rec, withSignedInSession(httptest.NewRequest("GET", RouteAccount, nil), "org1"))
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+		testutils.AssertContains(t, rec.Body.String(), test.want)
+	}
+}
+
+func TestDeleteAccountHandler(t *testing.T) {
+	t.Run("wrong confirmation", func(t *testing.T) {
+		store := accountStore(t, pkg.RoleAdmin)
+		testutils.AssertEqual(t, deleteAccount(store, "susan@example.com").Code, http.StatusBadRequest)
+		testutils.AssertEqual(t, len(store.Users), 2)
+	})
+
+	t.Run("last admin", func(t *testing.T) {
+		store := accountStore(t, pkg.RoleEditor)
+		rec := deleteAccount(store, "john@example.com")
+		testutils.AssertEqual(t, rec.Code, http.StatusConflict)
+		testutils.AssertContains(t, rec.Body.String(), "Brass band")
+		testutils.AssertEqual(t, len(store.Users), 2)
+	})
+
+	t.Run("deletes account", func(t *testing.T) {
+		store := accountStore(t, pkg.RoleAdmin)
+		rec := deleteAccount(store, " John@Example.com ")
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+		testutils.AssertEqual(t, rec.Header().Get("HX-Redirect"), "/")
+		testutils.AssertEqual(t, len(rec.Result().Cookies()), 1)
+		testutils.AssertEqual(t, rec.Result().Cookies()[0].MaxAge < 0, true)
+
+		_, err := store.GetUserInfo(context.Background(), "0000-0000")
+		testutils.AssertEqual(t, errors.Is(err, pkg.ErrUserNotFound), true)
+		testutils.AssertEqual(t, deleteAccount(store, "john@example.com").Code, http.StatusNotFound)
+	})
+}
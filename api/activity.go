@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+const activityPageSize = 20
+
+// ActivityTarget extracts the project and the pieces an activity concerns from a request
+type ActivityTarget func(r *http.Request) (projectId string, resourceIds []string)
+
+func submittedPieces(r *http.Request) (string, []string) {
+	project := pkg.Project{Name: r.FormValue("projectQuery")}
+	return project.Id(), r.Form["pieceIds"]
+}
+
+func removedPiece(r *http.Request) (string, []string) {
+	return r.PathValue("projectId"), []string{r.PathValue("resourceId")}
+}
+
+func downloadedPieces(r *http.Request) (string, []string) {
+	return r.FormValue("projectId"), r.Form["resourceId"]
+}
+
+// RecordProjectActivity adds an entry to the activity feed of the project when the wrapped handler succeeds.
+// Requests that do not concern a project are not recorded
+func RecordProjectActivity(recorder pkg.ActivityRecorder, kind pkg.ActivityKind, target ActivityTarget) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			if rec.status >= http.StatusBadRequest {
+				return
+			}
+
+			projectId, resourceIds := target(r)
+			if projectId == "" {
+				return
+			}
+
+			orgId, _ := r.Context().Value(pkg.OrgIdKey).(string)
+			userId, _ := r.Context().Value(pkg.UserIdKey).(string)
+			activity := pkg.NewActivity(projectId, kind, userId, resourceIds)
+			if err := recorder.RecordActivity(r.Context(), orgId, activity); err != nil {
+				slog.ErrorContext(r.Context(), "Could not record project activity", "projectId", projectId, "kind", kind, "error", err)
+			}
+		})
+	}
+}
+
+type ActivityFeedStore interface {
+	pkg.ActivityGetter
+	pkg.UserInOrgGetter
+	pkg.MetaByIdGetter
+}
+
+func ProjectActivityHandler(store ActivityFeedStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId := r.PathValue("id")
+		page := 0
+		if value := r.URL.Query().Get("page"); value != "" {
+			var err error
+			page, err = strconv.Atoi(value)
+			if err != nil || page < 0 {
+				http.Error(w, "page must be a non-negative integer", http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		activities, err := store.ProjectActivity(ctx, orgId, projectId)
+		if err != nil {
+			http.Error(w, "Failed to fetch project activity", http.StatusInternalServerError)
+			slog.ErrorContext(ctx, "Failed to fetch project activity", "projectId", projectId, "error", err)
+			return
+		}
+		activities, hasMore := pkg.Page(activities, page, activityPageSize)
+
+		users, err := store.GetUsersInOrg(ctx, orgId)
+		if err != nil {
+			http.Error(w, "Failed to fetch users", http.StatusInternalServerError)
+			slog.ErrorContext(ctx, "Failed to fetch users", "error", err)
+			return
+		}
+		userNames := make(map[string]string, len(users))
+		for _, user := range users {
+			userNames[user.Id] = user.Name
+		}
+
+		language := pkg.LanguageFromReq(r)
+		titles := make(map[string]string)
+		entries := make([]web.ActivityEntry, len(activities))
+		for i, activity := range activities {
+			name, ok := userNames[activity.UserId]
+			if !ok || name == "" {
+				name = web.FlashMessage(language, "activity.unknown-user", nil)
+			}
+			entries[i] = web.ActivityEntry{Activity: activity, User: name, Pieces: make([]string, len(activity.ResourceIds))}
+			for j, resourceId := range activity.ResourceIds {
+				entries[i].Pieces[j] = pieceTitle(ctx, store, orgId, resourceId, titles)
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		web.ProjectActivity(w, language, projectId, entries, page, hasMore)
+	}
+}
+
+// pieceTitle returns the title of the resource. Pieces that no longer exist are shown by their id
+func pieceTitle(ctx context.Context, store pkg.MetaByIdGetter, orgId, resourceId string, cache map[string]string) string {
+	if title, ok := cache[resourceId]; ok {
+		return title
+	}
+	title := resourceId
+	if meta, err := store.MetaById(ctx, orgId, resourceId); err == nil && meta.Title != "" {
+		title = meta.Title
+	}
+	cache[resourceId] = title
+	return title
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestRecordProjectActivity(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	success := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Write([]byte("ok"))
+	})
+	failure := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad", http.StatusBadRequest)
+	})
+
+	newRequest := func(form url.Values) *http.Request {
+		ctx := context.WithValue(context.Background(), pkg.OrgIdKey, "org1")
+		ctx = context.WithValue(ctx, pkg.UserIdKey, "user1")
+		req := httptest.NewRequest("POST", "/projects", strings.NewReader(form.Encode())).WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	middleware := RecordProjectActivity(store, pkg.ActivityPieceAdded, submittedPieces)
+	form := url.Values{"projectQuery": {"My Project"}, "pieceIds": {"a", "b"}}
+	middleware(success).ServeHTTP(httptest.NewRecorder(), newRequest(form))
+	middleware(failure).ServeHTTP(httptest.NewRecorder(), newRequest(form))
+	middleware(success).ServeHTTP(httptest.NewRecorder(), newRequest(url.Values{"pieceIds": {"a"}}))
+
+	activities, err := store.ProjectActivity(context.Background(), "org1", "myproject")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(activities), 1)
+	testutils.AssertEqual(t, activities[0].Kind, pkg.ActivityPieceAdded)
+	testutils.AssertEqual(t, activities[0].UserId, "user1")
+	testutils.AssertEqual(t, strings.Join(activities[0].ResourceIds, ","), "a,b")
+}
+
+func TestActivityTargets(t *testing.T) {
+	req := httptest.NewRequest("DELETE", "/projects/p/r", nil)
+	req.SetPathValue("projectId", "p")
+	req.SetPathValue("resourceId", "r")
+	projectId, resourceIds := removedPiece(req)
+	testutils.AssertEqual(t, projectId, "p")
+	testutils.AssertEqual(t, strings.Join(resourceIds, ","), "r")
+
+	req = httptest.NewRequest("POST", "/resources/parts?projectId=p&resourceId=a&resourceId=b", nil)
+	req.ParseForm()
+	projectId, resourceIds = downloadedPieces(req)
+	testutils.AssertEqual(t, projectId, "p")
+	testutils.AssertEqual(t, strings.Join(resourceIds, ","), "a,b")
+}
+
+type failingActivityStore struct {
+	*pkg.MultiOrgInMemoryStore
+	errActivity error
+	errUsers    error
+}
+
+func (f *failingActivityStore) ProjectActivity(ctx context.Context, orgId, projectId string) ([]pkg.Activity, error) {
+	if f.errActivity != nil {
+		return nil, f.errActivity
+	}
+	return f.MultiOrgInMemoryStore.ProjectActivity(ctx, orgId, projectId)
+}
+
+func (f *failingActivityStore) GetUsersInOrg(ctx context.Context, orgId string) ([]pkg.UserInfo, error) {
+	if f.errUsers != nil {
+		return nil, f.errUsers
+	}
+	return f.MultiOrgInMemoryStore.GetUsersInOrg(ctx, orgId)
+}
+
+func TestProjectActivityHandler(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	users, err := store.GetUsersInOrg(context.Background(), orgId)
+	testutils.AssertNil(t, err)
+	resourceId := demoResourceIds(t, store)[0]
+	meta, err := store.MetaById(context.Background(), orgId, resourceId)
+	testutils.AssertNil(t, err)
+
+	start := time.Now()
+	for i := range activityPageSize + 1 {
+		activity := pkg.NewActivity("project", pkg.ActivityDownload, users[0].Id, []string{resourceId, "deleted"})
+		activity.Time = start.Add(time.Duration(i) * time.Minute)
+		testutils.AssertNil(t, store.RecordActivity(context.Background(), orgId, activity))
+	}
+	removed := pkg.NewActivity("project", pkg.ActivityPieceRemoved, "unknown", []string{"deleted"})
+	removed.Time = start.Add(time.Hour)
+	testutils.AssertNil(t, store.RecordActivity(context.Background(), orgId, removed))
+
+	request := func(query string) *http.Request {
+		req := withAuthSession(httptest.NewRequest("GET", "/projects/project/activity"+query, nil), orgId)
+		req.SetPathValue("id", "project")
+		return req
+	}
+
+	t.Run("first page", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		ProjectActivityHandler(store, time.Second)(rec, request(""))
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+		body := rec.Body.String()
+		testutils.AssertEqual(t, strings.Count(body, "<li"), activityPageSize)
+		testutils.AssertContains(t, body, "Activity", users[0].Name, meta.Title, "deleted", "Unknown user", "/projects/project/activity?page=1")
+	})
+
+	t.Run("last page", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		ProjectActivityHandler(store, time.Second)(rec, request("?page=1"))
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+		testutils.AssertEqual(t, strings.Count(rec.Body.String(), "<li"), 2)
+		testutils.AssertNotContains(t, rec.Body.String(), "page=2", "<h3")
+	})
+
+	t.Run("invalid page", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		ProjectActivityHandler(store, time.Second)(rec, request("?page=-1"))
+		testutils.AssertEqual(t, rec.Code, http.StatusBadRequest)
+	})
+
+	for _, failing := range []*failingActivityStore{
+		{MultiOrgInMemoryStore: store, errActivity: errors.New("activity")},
+		{MultiOrgInMemoryStore: store, errUsers: errors.New("users")},
+	} {
+		rec := httptest.NewRecorder()
+		ProjectActivityHandler(failing, time.Second)(rec, request(""))
+		testutils.AssertEqual(t, rec.Code, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+const announcementDateFormat = "2006-01-02"
+
+// sessionMember returns the active organization and the user of the session, and whether the user is a
+// member of the organization
+func sessionMember(r *http.Request) (string, *pkg.UserInfo, bool) {
+	session := MustGetSession(r)
+	orgId, ok := session.Values["orgId"].(string)
+	if !ok || orgId == "" {
+		return "", nil, false
+	}
+	data, ok := session.Values["role"].([]byte)
+	if !ok {
+		return "", nil, false
+	}
+	var user pkg.UserInfo
+	if err := json.Unmarshal(data, &user); err != nil {
+		return "", nil, false
+	}
+	_, isMember := user.Roles[orgId]
+	return orgId, &user, isMember
+}
+
+// AnnouncementsHandler lists the active announcements of the organization. Visitors that are not signed in
+// get an empty response, such that the list can be loaded on public pages. Admins also get the form for
+// posting new announcements and see how many members have read each announcement
+func AnnouncementsHandler(store pkg.AnnouncementStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		orgId, user, ok := sessionMember(r)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		announcements, err := store.Announcements(ctx, orgId)
+		if err != nil {
+			http.Error(w, "Could not fetch announcements", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch announcements", "error", err, "orgId", orgId)
+			return
+		}
+		web.Announcements(w, pkg.LanguageFromReq(r), pkg.ActiveAnnouncements(announcements, time.Now()), user.Id, user.Roles[orgId].AtLeast(pkg.RoleAdmin))
+	}
+}
+
+type AnnouncementPublisher interface {
+	pkg.AnnouncementStore
+	pkg.UserInOrgGetter
+	pkg.OrganizationGetter
+	pkg.FeatureCounter
+	pkg.ActivityRecorder
+}
+
+func CreateAnnouncementHandler(store AnnouncementPublisher, config *pkg.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 32768)
+		code, err := parseForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		var expiresAt time.Time
+		if expires := r.FormValue("expires"); expires != "" {
+			date, err := time.Parse(announcementDateFormat, expires)
+			if err != nil {
+				http.Error(w, "Expiry date must be on the form YYYY-MM-DD", http.StatusBadRequest)
+				return
+			}
+
+			// The announcement is shown for the whole day it expires
+			expiresAt = date.AddDate(0, 0, 1)
+		}
+
+		userId, _ := r.Context().Value(pkg.UserIdKey).(string)
+		announcement := pkg.NewAnnouncement(r.FormValue("title"), r.FormValue("body"), userId, expiresAt)
+
+		ctx, cancel := context.WithTimeout(r.Context(), config.Timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		if err := store.SubmitAnnouncement(ctx, orgId, announcement); err != nil {
+			http.Error(w, err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not store announcement", "error", err, "orgId", orgId)
+			return
+		}
+		slog.InfoContext(ctx, "Posted announcement", "orgId", orgId, "announcementId", announcement.Id)
+
+		if r.FormValue("email") != "" {
+			num, err := emailAnnouncement(ctx, store, config, orgId, announcement)
+			if err != nil {
+				slog.ErrorContext(ctx, "Could not email announcement", "error", err, "orgId", orgId, "numSent", num)
+				HxFlash(w, r, FlashWarning, "flash.announcement-email-failed", map[string]any{"Count": num})
+			} else {
+				HxFlash(w, r, FlashSuccess, "flash.announcement-emailed", map[string]any{"Count": num})
+			}
+		} else {
+			HxFlash(w, r, FlashSuccess, "flash.announcement-posted", nil)
+		}
+		HxTrigger(w, EventAnnouncementsUpdated, nil)
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// emailAnnouncement sends the announcement to all members with an email address. Each member gets a
+// separate email such that addresses are not shared. It returns the number of emails sent
+func emailAnnouncement(ctx context.Context, store AnnouncementPublisher, config *pkg.Config, orgId string, announcement *pkg.Announcement) (int, error) {
+	users, err := store.GetUsersInOrg(ctx, orgId)
+	if err != nil {
+		return 0, err
+	}
+
+	var branding *pkg.Branding
+	if org, err := store.GetOrganization(ctx, orgId); err == nil {
+		branding = &org.Branding
+	}
+
+	noAttachments := func(yield func(string, io.Reader) bool) {}
+	numSent := 0
+	var errs []error
+	for _, user := range users {
+		if user.Email == "" {
+			continue
+		}
+		email := pkg.Email{
+			Sender:    config.EmailSender,
+			SmtpHost:  config.SmtpConfig.Host,
+			SmtpPort:  config.SmtpConfig.Port,
+			SmtpAuth:  config.SmtpConfig.Auth,
+			Recipents: []string{user.Email},
+			SendFn:    config.SmtpConfig.SendFn,
+			Branding:  branding,
+		}
+		content, err := email.Build(announcement.Title, announcement.Body, noAttachments)
+		if err == nil {
+			err = email.Send(ctx, content.Bytes())
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		numSent++
+		if err := store.RecordActivity(ctx, orgId, pkg.NewActivity("", pkg.ActivityEmailSent, user.Id, nil)); err != nil {
+			slog.ErrorContext(ctx, "Could not record email", "error", err, "userId", user.Id)
+		}
+		if err := store.CountFeature(ctx, orgId, pkg.FeatureEmailSent, time.Now()); err != nil {
+			slog.ErrorContext(ctx, "Could not count feature usage", "feature", pkg.FeatureEmailSent, "error", err)
+		}
+	}
+	return numSent, errors.Join(errs...)
+}
+
+func MarkAnnouncementReadHandler(store pkg.AnnouncementStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		userId, _ := r.Context().Value(pkg.UserIdKey).(string)
+		id := r.PathValue("id")
+		if err := store.MarkAnnouncementRead(ctx, orgId, id, userId); err != nil {
+			http.Error(w, "Could not mark announcement as read", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not mark announcement as read", "error", err, "announcementId", id)
+			return
+		}
+		HxTrigger(w, EventAnnouncementsUpdated, nil)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func ExpireAnnouncementHandler(store pkg.AnnouncementStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		id := r.PathValue("id")
+		if err := store.ExpireAnnouncement(ctx, orgId, id, time.Now()); err != nil {
+			http.Error(w, "Could not expire announcement", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not expire announcement", "error", err, "announcementId", id)
+			return
+		}
+		slog.InfoContext(ctx, "Expired announcement", "orgId", orgId, "announcementId", id)
+		HxTrigger(w, EventAnnouncementsUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.announcement-expired", nil)
+		w.WriteHeader(http.StatusOK)
+	}
+}
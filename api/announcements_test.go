@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func announcementRequest(orgId string, form url.Values) *http.Request {
+	req := httptest.NewRequest("POST", "/announcements", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = withAuthSession(req, orgId)
+	return req.WithContext(context.WithValue(req.Context(), pkg.UserIdKey, "0000-0000"))
+}
+
+func TestAnnouncementsHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	read := pkg.NewAnnouncement("Concert", "Remember **black** clothes", "admin", time.Time{})
+	read.ReadBy = []string{"0000-0000"}
+	unread := pkg.NewAnnouncement("Rehearsal", "", "admin", time.Time{})
+	expired := pkg.NewAnnouncement("Old news", "", "admin", time.Now().Add(-time.Hour))
+	for _, a := range []*pkg.Announcement{read, unread, expired} {
+		testutils.AssertNil(t, store.SubmitAnnouncement(ctx, "org", a))
+	}
+	handler := AnnouncementsHandler(store, time.Second)
+
+	t.Run("admin", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, withAuthSession(httptest.NewRequest("GET", "/announcements", nil), "org"))
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		body := recorder.Body.String()
+		testutils.AssertContains(t, body, "Concert", "<strong>black</strong>", "/announcements/"+unread.Id+"/read", "announcement-form")
+		testutils.AssertNotContains(t, body, "Old news", "/announcements/"+read.Id+"/read")
+	})
+
+	t.Run("member", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		req := withAuthSession(httptest.NewRequest("GET", "/announcements", nil), "org")
+		MustGetSession(req).Values["role"], _ = json.Marshal(pkg.UserInfo{Id: "1111", Roles: map[string]pkg.RoleKind{"org": pkg.RoleViewer}})
+		handler(recorder, req)
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		body := recorder.Body.String()
+		testutils.AssertContains(t, body, "/announcements/"+read.Id+"/read")
+		testutils.AssertNotContains(t, body, "announcement-form", "/expire")
+	})
+
+	t.Run("not signed in", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, withEmptySession(httptest.NewRequest("GET", "/announcements", nil)))
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertEqual(t, recorder.Body.Len(), 0)
+	})
+}
+
+func TestCreateAnnouncementHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterUser(ctx, &pkg.UserInfo{Id: "a", Email: "a@example.com", Roles: map[string]pkg.RoleKind{"org": pkg.RoleViewer}}))
+	testutils.AssertNil(t, store.RegisterUser(ctx, &pkg.UserInfo{Id: "b", Email: "b@example.com", Roles: map[string]pkg.RoleKind{"org": pkg.RoleAdmin}}))
+	testutils.AssertNil(t, store.RegisterUser(ctx, &pkg.UserInfo{Id: "c", Roles: map[string]pkg.RoleKind{"org": pkg.RoleViewer}}))
+	testutils.AssertNil(t, store.RegisterUser(ctx, &pkg.UserInfo{Id: "d", Email: "d@example.com", Roles: map[string]pkg.RoleKind{"other": pkg.RoleViewer}}))
+
+	var recipents []string
+	config := pkg.NewDefaultConfig()
+	config.SmtpConfig.SendFn = func(addr string, auth smtp.Auth, sender string, to []string, m []byte) error {
+		recipents = append(recipents, to...)
+		return nil
+	}
+	handler := CreateAnnouncementHandler(store, config)
+
+	t.Run("post and email", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, announcementRequest("org", url.Values{"title": {"Concert"}, "body": {"Saturday"}, "expires": {"2099-01-31"}, "email": {"true"}}))
+		testutils.AssertEqual(t, recorder.Code, http.StatusCreated)
+		testutils.AssertContains(t, recorder.Header().Get("HX-Trigger"), string(EventAnnouncementsUpdated))
+
+		announcements, err := store.Announcements(ctx, "org")
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, len(announcements), 1)
+		testutils.AssertEqual(t, announcements[0].AuthorId, "0000-0000")
+		testutils.AssertEqual(t, announcements[0].ExpiresAt, time.Date(2099, 2, 1, 0, 0, 0, 0, time.UTC))
+
+		// One email per member with an address
+		testutils.AssertEqual(t, strings.Join(recipents, ","), "a@example.com,b@example.com")
+		counts, err := store.FeatureCounts(ctx, time.Now().Add(-time.Hour))
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, counts[0].Count, 2)
+
+		sent, err := store.OrganizationActivity(ctx, "org", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, len(sent), 2)
+		testutils.AssertEqual(t, sent[0].Kind, pkg.ActivityEmailSent)
+	})
+
+	t.Run("email fails", func(t *testing.T) {
+		config.SmtpConfig.SendFn = func(addr string, auth smtp.Auth, sender string, to []string, m []byte) error {
+			return errors.New("smtp is down")
+		}
+		recorder := httptest.NewRecorder()
+		handler(recorder, announcementRequest("org", url.Values{"title": {"Concert"}, "email": {"true"}}))
+		testutils.AssertEqual(t, recorder.Code, http.StatusCreated)
+		testutils.AssertContains(t, recorder.Header().Get("HX-Trigger"), string(FlashWarning))
+	})
+
+	for _, test := range []struct {
+		desc string
+		form url.Values
+	}{
+		{"missing title", url.Values{"body": {"Saturday"}}},
+		{"invalid expiry", url.Values{"title": {"Concert"}, "expires": {"tomorrow"}}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler(recorder, announcementRequest("org", test.form))
+			testutils.AssertEqual(t, recorder.Code, http.StatusBadRequest)
+		})
+	}
+}
+
+func TestMarkReadAndExpireAnnouncement(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	announcement := pkg.NewAnnouncement("Concert", "", "admin", time.Time{})
+	testutils.AssertNil(t, store.SubmitAnnouncement(ctx, "org", announcement))
+
+	mux := http.NewServeMux()
+	mux.Handle("POST "+RouteAnnouncementsIdRead, MarkAnnouncementReadHandler(store, time.Second))
+	mux.Handle("POST "+RouteAnnouncementsIdExpire, ExpireAnnouncementHandler(store, time.Second))
+
+	for _, test := range []struct {
+		path string
+		code int
+	}{
+		{"/announcements/" + announcement.Id + "/read", http.StatusOK},
+		{"/announcements/" + announcement.Id + "/expire", http.StatusOK},
+		{"/announcements/unknown/read", http.StatusNotFound},
+		{"/announcements/unknown/expire", http.StatusNotFound},
+	} {
+		req := httptest.NewRequest("POST", test.path, nil)
+		req = withAuthSession(req, "org")
+		req = req.WithContext(context.WithValue(req.Context(), pkg.UserIdKey, "0000-0000"))
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+		testutils.AssertEqual(t, recorder.Code, test.code)
+	}
+
+	announcements, err := store.Announcements(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, announcements[0].IsReadBy("0000-0000"), true)
+	testutils.AssertEqual(t, announcements[0].Active(time.Now()), false)
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+// The session was signed in with the API token with this id
+const sessionApiTokenKey = "apiToken"
+
+// Number of days a new API token is valid for. Zero means that the token never expires
+var apiTokenValidityDays = []int{30, 90, 365, 0}
+
+func writeApiTokens(ctx context.Context, w http.ResponseWriter, r *http.Request, store pkg.ApiTokenStore, secret string, status int) {
+	session := MustGetSession(r)
+	userId := MustGetUserId(session)
+	orgId := MustGetOrgId(session)
+	tokens, err := store.ApiTokens(ctx, userId)
+	if err != nil {
+		http.Error(w, "Could not fetch API tokens", StoreErrorCode(err))
+		slog.ErrorContext(ctx, "Could not fetch API tokens", "error", err, "userId", userId)
+		return
+	}
+
+	// Tokens only give access to a single organization, hence only the tokens of the active organization are shown
+	tokens = slices.DeleteFunc(tokens, func(t pkg.ApiToken) bool { return t.OrgId != orgId })
+	role := MustGetUserInfo(session).Roles[orgId]
+	data := web.ApiTokensData{
+		Tokens:       tokens,
+		Secret:       secret,
+		CanWrite:     role.AtLeast(pkg.RoleEditor),
+		CanProvision: role.AtLeast(pkg.RoleAdmin),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	web.ApiTokens(w, pkg.LanguageFromReq(r), data)
+}
+
+// ApiTokensHandler lists the API tokens the signed in user has for the active organization
+func ApiTokensHandler(store pkg.ApiTokenStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		writeApiTokens(ctx, w, r, store, "", http.StatusOK)
+	}
+}
+
+// CreateApiTokenHandler issues an API token for the active organization. The token is only shown in the response,
+// since only the hash of it is stored
+func CreateApiTokenHandler(store pkg.ApiTokenStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 1024)
+		code, err := parseForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		session := MustGetSession(r)
+		if _, isApiToken := session.Values[sessionApiTokenKey]; isApiToken {
+			http.Error(w, "API tokens can not create other API tokens", http.StatusForbidden)
+			return
+		}
+
+		days, err := strconv.Atoi(r.FormValue("days"))
+		if err != nil || !slices.Contains(apiTokenValidityDays, days) {
+			http.Error(w, fmt.Sprintf("days must be one of %v", apiTokenValidityDays), http.StatusBadRequest)
+			return
+		}
+		var expiresAt time.Time
+		if days > 0 {
+			expiresAt = time.Now().AddDate(0, 0, days)
+		}
+
+		userId := MustGetUserId(session)
+		orgId := MustGetOrgId(session)
+		scope := pkg.ApiTokenScope(r.FormValue("scope"))
+		if !MustGetUserInfo(session).Roles[orgId].AtLeast(scope.Role()) {
+			http.Error(w, "Your role does not allow tokens with this scope", http.StatusForbidden)
+			return
+		}
+
+		token, secret, err := pkg.NewApiToken(userId, orgId, r.FormValue("name"), scope, expiresAt)
+		if err != nil {
+			http.Error(w, err.Error(), StoreErrorCode(err))
+			return
+		}
+		token.Passkey, _ = session.Values[sessionPasskeyKey].(bool)
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		if err := store.SaveApiToken(ctx, token); err != nil {
+			http.Error(w, "Could not store API token", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not store API token", "error", err, "userId", userId)
+			return
+		}
+
+		slog.InfoContext(ctx, "Created API token", "userId", userId, "orgId", orgId, "tokenId", token.Id, "scope", token.Scope)
+		HxFlash(w, r, FlashSuccess, "flash.api-token-created", nil)
+		writeApiTokens(ctx, w, r, store, secret, http.StatusCreated)
+	}
+}
+
+func RevokeApiTokenHandler(store pkg.ApiTokenStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		userId := MustGetUserId(MustGetSession(r))
+		id := r.PathValue("id")
+		if err := store.RevokeApiToken(ctx, userId, id); err != nil {
+			http.Error(w, "Could not revoke API token", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not revoke API token", "error", err, "userId", userId, "tokenId", id)
+			return
+		}
+
+		slog.InfoContext(ctx, "Revoked API token", "userId", userId, "tokenId", id)
+		HxTrigger(w, EventApiTokensUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.api-token-revoked", nil)
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/gorilla/sessions"
+)
+
+var apiTokenSecretRegex = regexp.MustCompile(pkg.ApiTokenPrefix + `[A-Za-z0-9_-]+`)
+
+func apiTokenTestStore(t *testing.T, role pkg.RoleKind) *pkg.MultiOrgInMemoryStore {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterUser(ctx, &pkg.UserInfo{Id: "0000-0000", Email: "kari@example.com"}))
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &pkg.Organization{Id: "org1", Name: "Band"}))
+	testutils.AssertNil(t, store.RegisterRole(ctx, "0000-0000", "org1", role))
+	testutils.AssertNil(t, store.RegisterGroup(ctx, "0000-0000", "org1", "Horn"))
+	return store
+}
+
+func createApiToken(store pkg.ApiTokenStore, role pkg.RoleKind, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", RouteTokens, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = withAuthSession(req, "org1")
+	session := MustGetSession(req)
+	session.Values["userId"] = "0000-0000"
+	session.Values["role"], _ = json.Marshal(pkg.UserInfo{Id: "0000-0000", Roles: map[string]pkg.RoleKind{"org1": role}})
+	recorder := httptest.NewRecorder()
+	CreateApiTokenHandler(store, time.Second)(recorder, req)
+	return recorder
+}
+
+func mustCreateApiToken(t *testing.T, store pkg.ApiTokenStore, scope pkg.ApiTokenScope, days string) string {
+	recorder := createApiToken(store, pkg.RoleAdmin, url.Values{"name": {"Script"}, "scope": {string(scope)}, "days": {days}})
+	testutils.AssertEqual(t, recorder.Code, http.StatusCreated)
+	secret := apiTokenSecretRegex.FindString(recorder.Body.String())
+	testutils.AssertEqual(t, secret != "", true)
+	return secret
+}
+
+func TestCreateApiTokenHandler(t *testing.T) {
+	store := apiTokenTestStore(t, pkg.RoleAdmin)
+	recorder := createApiToken(store, pkg.RoleAdmin, url.Values{"name": {"Nightly upload"}, "scope": {"write"}, "days": {"30"}})
+	testutils.AssertEqual(t, recorder.Code, http.StatusCreated)
+	testutils.AssertContains(t, recorder.Body.String(), "Nightly upload", "Copy the token now")
+
+	secret := apiTokenSecretRegex.FindString(recorder.Body.String())
+	token, err := store.ApiTokenByHash(context.Background(), pkg.HashApiToken(secret))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, token.OrgId, "org1")
+	testutils.AssertEqual(t, token.Scope, pkg.ApiTokenWrite)
+	testutils.AssertEqual(t, token.ExpiresAt.After(time.Now().AddDate(0, 0, 29)), true)
+
+	for _, test := range []struct {
+		desc string
+		role pkg.RoleKind
+		form url.Values
+		code int
+	}{
+		{"unknown scope", pkg.RoleAdmin, url.Values{"name": {"Script"}, "scope": {"admin"}, "days": {"30"}}, http.StatusBadRequest},
+		{"missing name", pkg.RoleAdmin, url.Values{"scope": {"read"}, "days": {"30"}}, http.StatusBadRequest},
+		{"unsupported validity", pkg.RoleAdmin, url.Values{"name": {"Script"}, "scope": {"read"}, "days": {"7"}}, http.StatusBadRequest},
+		{"viewer can not write", pkg.RoleViewer, url.Values{"name": {"Script"}, "scope": {"write"}, "days": {"30"}}, http.StatusForbidden},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			testutils.AssertEqual(t, createApiToken(store, test.role, test.form).Code, test.code)
+		})
+	}
+}
+
+func TestApiTokensHandlerListsActiveOrganization(t *testing.T) {
+	store := apiTokenTestStore(t, pkg.RoleAdmin)
+	mustCreateApiToken(t, store, pkg.ApiTokenRead, "0")
+	other, _, err := pkg.NewApiToken("0000-0000", "org2", "Other organization", pkg.ApiTokenRead, time.Time{})
+	testutils.AssertNil(t, err)
+	testutils.AssertNil(t, store.SaveApiToken(context.Background(), other))
+
+	req := withAuthSession(httptest.NewRequest("GET", RouteTokens, nil), "org1")
+	MustGetSession(req).Values["userId"] = "0000-0000"
+	recorder := httptest.NewRecorder()
+	ApiTokensHandler(store, time.Second)(recorder, req)
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+	testutils.AssertContains(t, recorder.Body.String(), "Script", "Never")
+	testutils.AssertNotContains(t, recorder.Body.String(), "Other organization", pkg.ApiTokenPrefix)
+}
+
+func TestRevokeApiTokenHandler(t *testing.T) {
+	store := apiTokenTestStore(t, pkg.RoleAdmin)
+	secret := mustCreateApiToken(t, store, pkg.ApiTokenRead, "30")
+	token, err := store.ApiTokenByHash(context.Background(), pkg.HashApiToken(secret))
+	testutils.AssertNil(t, err)
+
+	revoke := func(userId string) *httptest.ResponseRecorder {
+		req := withAuthSession(httptest.NewRequest("DELETE", "/tokens/"+token.Id, nil), "org1")
+		MustGetSession(req).Values["userId"] = userId
+		req.SetPathValue("id", token.Id)
+		recorder := httptest.NewRecorder()
+		RevokeApiTokenHandler(store, time.Second)(recorder, req)
+		return recorder
+	}
+
+	testutils.AssertEqual(t, revoke("other-user").Code, http.StatusNotFound)
+	recorder := revoke("0000-0000")
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+	testutils.AssertContains(t, recorder.Header().Get("HX-Trigger"), string(EventApiTokensUpdated))
+
+	_, err = store.ApiTokenByHash(context.Background(), token.Hash)
+	testutils.AssertEqual(t, err != nil, true)
+}
+
+func TestApiTokenMiddleware(t *testing.T) {
+	store := apiTokenTestStore(t, pkg.RoleAdmin)
+	config := pkg.NewDefaultConfig()
+	config.RequireSubscription = false
+	cookie := sessions.NewCookieStore([]byte("key"))
+	opts := &sessions.Options{}
+
+	var seen *sessions.Session
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = MustGetSession(r)
+		trySaveSession(seen, r, w)
+	})
+
+	serve := func(middleware func(http.Handler) http.Handler, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/resources", nil)
+		req.Header.Set("Authorization", authorization)
+		recorder := httptest.NewRecorder()
+		middleware(handler).ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	readRoute := RequireRead(store, config, cookie, opts)
+	writeRoute := RequireWrite(store, config, cookie, opts)
+	readSecret := mustCreateApiToken(t, store, pkg.ApiTokenRead, "30")
+	writeSecret := mustCreateApiToken(t, store, pkg.ApiTokenWrite, "0")
+
+	t.Run("read token can read", func(t *testing.T) {
+		recorder := serve(readRoute, "Bearer "+readSecret)
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertEqual(t, MustGetUserId(seen), "0000-0000")
+		testutils.AssertEqual(t, MustGetOrgId(seen), "org1")
+		testutils.AssertEqual(t, MustGetUserInfo(seen).Groups["org1"][0], "Horn")
+
+		// The session of a token is never handed out as a cookie
+		testutils.AssertEqual(t, len(recorder.Result().Cookies()), 0)
+	})
+
+	t.Run("read token can not write", func(t *testing.T) {
+		testutils.AssertEqual(t, serve(writeRoute, "Bearer "+readSecret).Code, http.StatusUnauthorized)
+	})
+
+	t.Run("write token can write", func(t *testing.T) {
+		testutils.AssertEqual(t, serve(writeRoute, "Bearer "+writeSecret).Code, http.StatusOK)
+		testutils.AssertEqual(t, MustGetUserInfo(seen).Roles["org1"], pkg.RoleEditor)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		recorder := serve(readRoute, "Bearer "+pkg.ApiTokenPrefix+"unknown")
+		testutils.AssertEqual(t, recorder.Code, http.StatusUnauthorized)
+		testutils.AssertContains(t, recorder.Header().Get("WWW-Authenticate"), "Bearer")
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		token, err := store.ApiTokenByHash(context.Background(), pkg.HashApiToken(readSecret))
+		testutils.AssertNil(t, err)
+		token.ExpiresAt = time.Now().Add(-time.Minute)
+		testutils.AssertNil(t, store.SaveApiToken(context.Background(), &token))
+		testutils.AssertEqual(t, serve(readRoute, "Bearer "+readSecret).Code, http.StatusUnauthorized)
+	})
+
+	t.Run("removed from organization", func(t *testing.T) {
+		testutils.AssertNil(t, store.DeleteRole(context.Background(), "0000-0000", "org1"))
+		testutils.AssertEqual(t, serve(writeRoute, "Bearer "+writeSecret).Code, http.StatusUnauthorized)
+	})
+
+	t.Run("tokens can not create tokens", func(t *testing.T) {
+		testutils.AssertNil(t, store.RegisterRole(context.Background(), "0000-0000", "org1", pkg.RoleAdmin))
+		req := httptest.NewRequest("POST", RouteTokens, strings.NewReader(url.Values{"name": {"Copy"}, "scope": {"read"}, "days": {"30"}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer "+writeSecret)
+		recorder := httptest.NewRecorder()
+		readRoute(CreateApiTokenHandler(store, time.Second)).ServeHTTP(recorder, req)
+		testutils.AssertEqual(t, recorder.Code, http.StatusForbidden)
+	})
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"golang.org/x/oauth2"
+)
+
+// HandleAppleLogin redirects to Sign in with Apple. Apple only shares the name and email when the response is
+// posted to the callback
+func HandleAppleLogin(apple *pkg.AppleConfig) http.HandlerFunc {
+	return handleOAuthLogin(apple.OAuthConfig(), oauth2.SetAuthURLParam("response_mode", "form_post"))
+}
+
+// HandleAppleFormPost receives the response Apple posts to the callback. Browsers do not send the session
+// cookie with a post from another site, so the response is passed on to the callback as a normal redirect
+func HandleAppleFormPost(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 16*1024)
+	code, err := parseForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		slog.ErrorContext(r.Context(), "Failed to parse form", "error", err)
+		return
+	}
+
+	query := url.Values{}
+	for _, key := range []string{"code", "state", "user", "error"} {
+		if value := r.PostFormValue(key); value != "" {
+			query.Set(key, value)
+		}
+	}
+	http.Redirect(w, r, r.URL.Path+"?"+query.Encode(), http.StatusSeeOther)
+}
+
+// HandleAppleCallback signs in users with an Apple ID. The user is read from the id token, and the name from the
+// user field that Apple only sends the first time a user signs in
+func HandleAppleCallback(roleStore LoginStore, apple *pkg.AppleConfig, timeout time.Duration, signSecret string, transport http.RoundTripper) http.HandlerFunc {
+	fetchUser := func(r *http.Request, client *http.Client, token *oauth2.Token) (pkg.UserInfo, int, error) {
+		idToken, ok := token.Extra("id_token").(string)
+		if !ok {
+			return pkg.UserInfo{}, http.StatusBadGateway, errors.New("Apple did not return an id token")
+		}
+		claims, err := pkg.ParseAppleIdToken(idToken, apple.ClientId, time.Now())
+		if err != nil {
+			return pkg.UserInfo{}, http.StatusBadGateway, errors.New("Invalid id token: " + err.Error())
+		}
+
+		var user *pkg.AppleUser
+		if userField := r.FormValue("user"); userField != "" {
+			user = &pkg.AppleUser{}
+			if err := json.Unmarshal([]byte(userField), user); err != nil {
+				return pkg.UserInfo{}, http.StatusBadRequest, errors.New("Failed decoding user: " + err.Error())
+			}
+		}
+		return pkg.AppleUserInfo(claims, user), http.StatusOK, nil
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if reason := r.FormValue("error"); reason != "" {
+			slog.InfoContext(r.Context(), "Sign in with Apple was not completed", "reason", reason)
+			http.Redirect(w, r, RouteLogin, http.StatusSeeOther)
+			return
+		}
+
+		clientSecret, err := apple.ClientSecret(time.Now())
+		if err != nil {
+			http.Error(w, "Could not sign in with Apple", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Could not create Apple client secret", "error", err)
+			return
+		}
+		oauthConfig := apple.OAuthConfig()
+		oauthConfig.ClientSecret = clientSecret
+		handleOAuthTokenCallback(roleStore, oauthConfig, timeout, signSecret, transport, fetchUser)(w, r)
+	}
+}
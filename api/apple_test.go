@@ -0,0 +1,165 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/sessions"
+)
+
+func testAppleConfig(t *testing.T) *pkg.AppleConfig {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testutils.AssertNil(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	testutils.AssertNil(t, err)
+
+	return &pkg.AppleConfig{
+		ClientId:    "no.example.caesura",
+		TeamId:      "TEAM123",
+		KeyId:       "KEY123",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		RedirectURL: "http://localhost:8080/auth/apple/callback",
+	}
+}
+
+type appleTransport struct {
+	idToken      string
+	clientSecret string
+}
+
+func (a *appleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.String() != pkg.AppleEndpoint.TokenURL {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(bytes.NewBufferString(""))}, nil
+	}
+	req.ParseForm()
+	a.clientSecret = req.PostForm.Get("client_secret")
+
+	body := map[string]any{"access_token": "test-access-token", "expires_in": 3600, "token_type": "Bearer"}
+	if a.idToken != "" {
+		body["id_token"] = a.idToken
+	}
+	content, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewBuffer(content)),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+	}, nil
+}
+
+func appleIdToken(t *testing.T, subject string) string {
+	claims := jwt.MapClaims{
+		"iss":            pkg.AppleIssuer,
+		"aud":            "no.example.caesura",
+		"sub":            subject,
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"email":          "Kari@Example.com",
+		"email_verified": "true",
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("key"))
+	testutils.AssertNil(t, err)
+	return token
+}
+
+func prepareAppleCallbackRequest(query url.Values) *http.Request {
+	query.Set("state", "oauth-state-string")
+	query.Set("code", "some-code")
+	request := httptest.NewRequest("GET", RouteAuthAppleCallback+"?"+query.Encode(), nil)
+	session := sessions.NewSession(sessions.NewCookieStore([]byte("some-random-key")), AuthSession)
+	session.Values[OAuthState] = "oauth-state-string"
+	return request.WithContext(context.WithValue(request.Context(), sessionKey, session))
+}
+
+func TestHandleAppleLogin(t *testing.T) {
+	cookie := sessions.NewCookieStore([]byte("some-random-key"))
+	handler := RequireSession(cookie, AuthSession, &sessions.Options{})(HandleAppleLogin(testAppleConfig(t)))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", RouteLoginApple, nil))
+	testutils.AssertEqual(t, recorder.Code, http.StatusTemporaryRedirect)
+	testutils.AssertContains(t, recorder.Header().Get("Location"), pkg.AppleEndpoint.AuthURL, "response_mode=form_post", "scope=name+email")
+}
+
+func TestHandleAppleFormPost(t *testing.T) {
+	form := url.Values{"code": {"some-code"}, "state": {"some-state"}, "user": {`{"name": {"firstName": "Kari"}}`}, "other": {"ignored"}}
+	request := httptest.NewRequest("POST", RouteAuthAppleCallback, strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	HandleAppleFormPost(recorder, request)
+
+	testutils.AssertEqual(t, recorder.Code, http.StatusSeeOther)
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, location.Path, RouteAuthAppleCallback)
+	testutils.AssertEqual(t, location.Query().Get("code"), "some-code")
+	testutils.AssertEqual(t, location.Query().Get("user"), `{"name": {"firstName": "Kari"}}`)
+	testutils.AssertEqual(t, location.Query().Has("other"), false)
+}
+
+func TestHandleAppleCallback(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	transport := appleTransport{idToken: appleIdToken(t, "001234.abcd")}
+	handler := HandleAppleCallback(store, testAppleConfig(t), time.Second, "signKey", &transport)
+
+	req := prepareAppleCallbackRequest(url.Values{"user": {`{"name": {"firstName": "Kari", "lastName": "Nordmann"}}`}})
+	recorder := httptest.NewRecorder()
+	handler(recorder, req)
+	testutils.AssertEqual(t, recorder.Code, http.StatusSeeOther)
+	testutils.AssertEqual(t, transport.clientSecret != "", true)
+
+	userId := pkg.ExternalUserId(pkg.AppleIssuer, "001234.abcd")
+	testutils.AssertEqual(t, MustGetSession(req).Values["userId"], any(userId))
+	user, err := store.GetUserInfo(context.Background(), userId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, user.Email, "kari@example.com")
+	testutils.AssertEqual(t, user.Name, "Kari Nordmann")
+}
+
+func TestHandleAppleCallbackErrors(t *testing.T) {
+	for _, test := range []struct {
+		desc    string
+		idToken string
+		query   url.Values
+		code    int
+	}{
+		{desc: "no id token", query: url.Values{}, code: http.StatusBadGateway},
+		{desc: "invalid id token", idToken: "not-a-token", query: url.Values{}, code: http.StatusBadGateway},
+		{desc: "invalid user", idToken: appleIdToken(t, "001234.abcd"), query: url.Values{"user": {"{"}}, code: http.StatusBadRequest},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			transport := appleTransport{idToken: test.idToken}
+			handler := HandleAppleCallback(pkg.NewMultiOrgInMemoryStore(), testAppleConfig(t), time.Second, "signKey", &transport)
+
+			recorder := httptest.NewRecorder()
+			handler(recorder, prepareAppleCallbackRequest(test.query))
+			testutils.AssertEqual(t, recorder.Code, test.code)
+		})
+	}
+}
+
+func TestHandleAppleCallbackCancelled(t *testing.T) {
+	handler := HandleAppleCallback(pkg.NewMultiOrgInMemoryStore(), testAppleConfig(t), time.Second, "signKey", &appleTransport{})
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("GET", RouteAuthAppleCallback+"?error=user_cancelled_authorize", nil))
+	testutils.AssertEqual(t, recorder.Code, http.StatusSeeOther)
+	testutils.AssertEqual(t, recorder.Header().Get("Location"), RouteLogin)
+}
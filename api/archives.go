@@ -0,0 +1,204 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Generating the archive of a large project takes far longer than a regular request
+const archiveJobTimeout = time.Hour
+
+const archiveTokenAudience = "caesura-archive"
+
+// ArchiveClaim grants access to a generated archive without a session, such that download managers can resume
+// the download. The claim expires together with the archive
+type ArchiveClaim struct {
+	OrgId     string `json:"org_id"`
+	ArchiveId string `json:"archive_id"`
+	jwt.RegisteredClaims
+}
+
+func SignedArchiveToken(orgId, archiveId, signSecret string, expires time.Time) (string, error) {
+	currentTime := time.Now()
+	claims := ArchiveClaim{
+		OrgId:     orgId,
+		ArchiveId: archiveId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{archiveTokenAudience},
+			ExpiresAt: jwt.NewNumericDate(expires),
+			IssuedAt:  jwt.NewNumericDate(currentTime),
+			NotBefore: jwt.NewNumericDate(currentTime),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(signSecret))
+}
+
+func parseArchiveToken(token, signSecret string) (*ArchiveClaim, error) {
+	var claims ArchiveClaim
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		return []byte(signSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired(), jwt.WithAudience(archiveTokenAudience))
+	if err != nil {
+		return nil, err
+	}
+	if claims.OrgId == "" || claims.ArchiveId == "" {
+		return nil, fmt.Errorf("token does not contain organization and archive")
+	}
+	return &claims, nil
+}
+
+// PartsArchiveResponse is the progress of an archive of parts. URL is set once the archive is ready
+type PartsArchiveResponse struct {
+	Id      string            `json:"id"`
+	Status  pkg.ArchiveStatus `json:"status"`
+	Size    int64             `json:"size"`
+	Expires time.Time         `json:"expires"`
+	URL     string            `json:"url,omitempty"`
+}
+
+func writeArchiveStatus(ctx context.Context, w http.ResponseWriter, code int, status PartsArchiveResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		slog.ErrorContext(ctx, "Failed to encode archive status", "error", err)
+	}
+}
+
+type PartsArchiveStore interface {
+	UserPartsStore
+	pkg.ArchiveStore
+	pkg.TempArtifactStore
+}
+
+// CreatePartsArchiveHandler takes the same form as DownloadUserParts, but writes the zip archive in the
+// background and keeps it in the store. The progress is polled at the URL in Location
+func CreatePartsArchiveHandler(store PartsArchiveStore, archives *pkg.Archives, config *pkg.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 32768)
+		code, err := parseForm(r)
+		if err != nil {
+			http.Error(w, "Failed to parse form: "+err.Error(), code)
+			return
+		}
+		session := MustGetSession(r)
+		orgId := MustGetOrgId(session)
+
+		// The archive outlives the request
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), archiveJobTimeout)
+		parts, code, err := collectUserParts(ctx, store, session, r.Form)
+		if err != nil {
+			cancel()
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		// The archive is tracked before anything is written, such that the cleanup also removes archives of
+		// jobs that never finished
+		archive := pkg.NewArchive(orgId, partsZipFilename(), archives.Expiry)
+		artifact := pkg.TempArtifact{Kind: pkg.ArtifactArchive, OrgId: orgId, Id: archive.Id, ExpiresAt: archive.ExpiresAt}
+		err = pkg.ReturnOnFirstError(
+			func() error { return store.TrackArtifact(ctx, &artifact) },
+			func() error { return store.SaveArchive(ctx, archive) },
+		)
+		if err != nil {
+			cancel()
+			http.Error(w, "Could not create archive", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not save archive", "error", err)
+			return
+		}
+
+		// The job updates the archive, so the response is made before it starts
+		status := PartsArchiveResponse{Id: archive.Id, Status: archive.Status, Expires: archive.ExpiresAt}
+		archives.Go(func() {
+			defer cancel()
+			numFiles := 0
+			err := pkg.WriteArchive(ctx, store, archive, func(w io.Writer) error {
+				var err error
+				numFiles, err = parts.writeZip(w)
+				return err
+			})
+			if err != nil {
+				slog.ErrorContext(ctx, "Could not generate archive", "error", err, "archiveId", archive.Id)
+				return
+			}
+			recordPartsAccess(ctx, store, orgId, parts.ids)
+			slog.InfoContext(ctx, "Generated archive", "archiveId", archive.Id, "numPieces", len(parts.ids), "numFilesInZipArchive", numFiles, "size", archive.Size)
+		})
+
+		slog.InfoContext(r.Context(), "Started archive", "archiveId", status.Id, "numPieces", len(parts.ids))
+		w.Header().Set("Location", RouteResourcesPartsArchives+"/"+status.Id)
+		writeArchiveStatus(r.Context(), w, http.StatusAccepted, status)
+	}
+}
+
+// ArchiveStatusHandler reports whether an archive of the organization is ready. Ready archives come with a link
+// that works without a session until the archive expires
+func ArchiveStatusHandler(store pkg.ArchiveStore, config *pkg.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), config.Timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		archive, err := store.Archive(ctx, orgId, r.PathValue("id"))
+		if err == nil && archive.Expired(time.Now()) {
+			err = pkg.ErrArchiveNotFound
+		}
+		if err != nil {
+			http.Error(w, "The archive does not exist or has expired", StoreErrorCode(err))
+			return
+		}
+
+		status := PartsArchiveResponse{Id: archive.Id, Status: archive.Status, Size: archive.Size, Expires: archive.ExpiresAt}
+		if archive.Status == pkg.ArchiveReady {
+			token, err := SignedArchiveToken(orgId, archive.Id, config.CookieSecretSignKey, archive.ExpiresAt)
+			if err != nil {
+				http.Error(w, "Failed to sign link", http.StatusInternalServerError)
+				slog.ErrorContext(ctx, "Failed to sign archive token", "error", err)
+				return
+			}
+			status.URL = config.BaseURL + RouteSharedArchive + "?token=" + url.QueryEscape(token)
+		}
+		writeArchiveStatus(ctx, w, http.StatusOK, status)
+	}
+}
+
+// SharedArchiveHandler serves a ready archive to anyone holding a valid token created by ArchiveStatusHandler.
+// Range requests are supported, such that an interrupted download continues where it stopped
+func SharedArchiveHandler(store pkg.ArchiveStore, signSecret string, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, err := parseArchiveToken(r.URL.Query().Get("token"), signSecret)
+		if err != nil {
+			http.Error(w, "The link is invalid or has expired", http.StatusUnauthorized)
+			slog.InfoContext(r.Context(), "Invalid archive token", "error", err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		archive, err := store.Archive(ctx, claims.OrgId, claims.ArchiveId)
+		cancel()
+		if err == nil && (archive.Status != pkg.ArchiveReady || archive.Expired(time.Now())) {
+			err = pkg.ErrArchiveNotFound
+		}
+		if err != nil {
+			http.Error(w, "The archive does not exist or has expired", StoreErrorCode(err))
+			return
+		}
+
+		// The chunks are fetched while the archive is sent, so the download is only limited by the connection
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+archive.Filename+"\"")
+		w.Header().Set("Cache-Control", "private, no-store")
+		w.Header().Set("ETag", `"`+archive.Id+`"`)
+		http.ServeContent(w, r, archive.Filename, archive.CreatedAt, pkg.NewArchiveReader(r.Context(), store, archive))
+	}
+}
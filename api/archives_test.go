@@ -0,0 +1,154 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func archiveMux(store *pkg.MultiOrgInMemoryStore, archives *pkg.Archives, config *pkg.Config) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("POST "+RouteResourcesPartsArchives, CreatePartsArchiveHandler(store, archives, config))
+	mux.Handle("GET "+RouteResourcesPartsArchivesId, ArchiveStatusHandler(store, config))
+	mux.Handle("GET "+RouteSharedArchive, SharedArchiveHandler(store, config.CookieSecretSignKey, time.Second))
+	return mux
+}
+
+func archiveStatusOf(t *testing.T, mux http.Handler, location string) PartsArchiveResponse {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, withAuthSession(httptest.NewRequest("GET", location, nil), "org"))
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+
+	var status PartsArchiveResponse
+	testutils.AssertNil(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	return status
+}
+
+func TestPartsArchiveRoundTrip(t *testing.T) {
+	store, resourceId := manifestStore(t)
+	config := pkg.NewDefaultConfig()
+	config.CookieSecretSignKey = "secret"
+	archives := pkg.NewArchives(time.Hour)
+	mux := archiveMux(store, archives, config)
+
+	form := url.Values{"resourceId": {resourceId}}
+	req := httptest.NewRequest("POST", RouteResourcesPartsArchives, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, withAuthSession(req, "org"))
+	testutils.AssertEqual(t, rec.Code, http.StatusAccepted)
+	location := rec.Header().Get("Location")
+	testutils.AssertContains(t, location, RouteResourcesPartsArchives+"/")
+	testutils.AssertEqual(t, len(store.TempArtifacts), 1)
+	archives.Wait()
+
+	status := archiveStatusOf(t, mux, location)
+	testutils.AssertEqual(t, status.Status, pkg.ArchiveReady)
+	testutils.AssertContains(t, status.URL, config.BaseURL+RouteSharedArchive+"?token=")
+
+	// The link works without a session
+	link, err := url.Parse(status.URL)
+	testutils.AssertNil(t, err)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", link.RequestURI(), nil))
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, rec.Header().Get("Content-Type"), "application/zip")
+	testutils.AssertEqual(t, rec.Header().Get("Accept-Ranges"), "bytes")
+	testutils.AssertEqual(t, int64(rec.Body.Len()), status.Size)
+
+	archive := rec.Body.Bytes()
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(reader.File), 2)
+
+	t.Run("resumes from an offset", func(t *testing.T) {
+		req := httptest.NewRequest("GET", link.RequestURI(), nil)
+		req.Header.Set("Range", "bytes=10-")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		testutils.AssertEqual(t, rec.Code, http.StatusPartialContent)
+		testutils.AssertEqual(t, rec.Body.String(), string(archive[10:]))
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", RouteSharedArchive+"?token=invalid", nil))
+		testutils.AssertEqual(t, rec.Code, http.StatusUnauthorized)
+	})
+
+	t.Run("other organization", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, withAuthSession(httptest.NewRequest("GET", location, nil), "other-org"))
+		testutils.AssertEqual(t, rec.Code, http.StatusNotFound)
+	})
+}
+
+func TestCreatePartsArchiveUnknownPiece(t *testing.T) {
+	store, _ := manifestStore(t)
+	archives := pkg.NewArchives(time.Hour)
+	mux := archiveMux(store, archives, pkg.NewDefaultConfig())
+
+	form := url.Values{"resourceId": {"unknown"}}
+	req := httptest.NewRequest("POST", RouteResourcesPartsArchives, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, withAuthSession(req, "org"))
+	testutils.AssertEqual(t, rec.Code, http.StatusNotFound)
+	testutils.AssertEqual(t, len(store.Archives), 0)
+}
+
+func TestExpiredArchivesAreNotServed(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	config := pkg.NewDefaultConfig()
+	config.CookieSecretSignKey = "secret"
+	mux := archiveMux(store, pkg.NewArchives(time.Hour), config)
+
+	archive := pkg.NewArchive("org", "parts.zip", -time.Minute)
+	archive.Status = pkg.ArchiveReady
+	testutils.AssertNil(t, store.SaveArchive(context.Background(), archive))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, withAuthSession(httptest.NewRequest("GET", RouteResourcesPartsArchives+"/"+archive.Id, nil), "org"))
+	testutils.AssertEqual(t, rec.Code, http.StatusNotFound)
+
+	// A link signed before the archive expired is rejected as well
+	token, err := SignedArchiveToken("org", archive.Id, "secret", time.Now().Add(time.Hour))
+	testutils.AssertNil(t, err)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", RouteSharedArchive+"?token="+url.QueryEscape(token), nil))
+	testutils.AssertEqual(t, rec.Code, http.StatusNotFound)
+}
+
+func TestPendingArchiveHasNoLink(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	mux := archiveMux(store, pkg.NewArchives(time.Hour), pkg.NewDefaultConfig())
+	archive := pkg.NewArchive("org", "parts.zip", time.Hour)
+	testutils.AssertNil(t, store.SaveArchive(context.Background(), archive))
+
+	status := archiveStatusOf(t, mux, RouteResourcesPartsArchives+"/"+archive.Id)
+	testutils.AssertEqual(t, status.Status, pkg.ArchivePending)
+	testutils.AssertEqual(t, status.URL, "")
+}
+
+func TestArchiveTokenRejectsOtherTokens(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	sharedPart, err := SignedSharedPartToken("org", "archive", "Horn.pdf", "secret", expires)
+	testutils.AssertNil(t, err)
+	_, err = parseArchiveToken(sharedPart, "secret")
+	testutils.AssertEqual(t, err != nil, true)
+
+	archive, err := SignedArchiveToken("org", "archive", "secret", expires)
+	testutils.AssertNil(t, err)
+	_, err = parseSharedPartToken(archive, "secret")
+	testutils.AssertEqual(t, err != nil, true)
+}
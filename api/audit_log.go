@@ -0,0 +1,178 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+const (
+	auditLogPageSize = 50
+
+	// auditLogWindow is how far back the audit log is listed when the request has no start date
+	auditLogWindow = 30 * 24 * time.Hour
+)
+
+// AuditTarget extracts what an audited request concerns, and optionally a detail such as the new role of a member
+type AuditTarget func(r *http.Request) (targetId, detail string)
+
+func auditPathId(r *http.Request) (string, string) {
+	return r.PathValue("id"), ""
+}
+
+func auditOrganization(r *http.Request) (string, string) {
+	orgId, _ := r.Context().Value(pkg.OrgIdKey).(string)
+	return orgId, ""
+}
+
+func auditAssignedRole(r *http.Request) (string, string) {
+	return r.PathValue("id"), "role=" + r.FormValue("role")
+}
+
+func auditInvitedEmail(r *http.Request) (string, string) {
+	return r.FormValue("email"), "role=" + r.FormValue("role")
+}
+
+func auditGuestEmail(r *http.Request) (string, string) {
+	if projectId := r.FormValue("projectId"); projectId != "" {
+		return r.FormValue("email"), "project=" + projectId
+	}
+	return r.FormValue("email"), "resources=" + strings.Join(r.Form["resourceId"], ",")
+}
+
+func auditRenamedPart(r *http.Request) (string, string) {
+	newName, _ := pkg.PartName(r.FormValue("name"))
+	return r.PathValue("id"), "from=" + r.PathValue("name") + " to=" + newName
+}
+
+func auditRenamedParts(r *http.Request) (string, string) {
+	newName, _ := pkg.PartName(r.FormValue("newName"))
+	return strings.Join(r.Form["resourceId"], ","), "from=" + r.FormValue("name") + " to=" + newName
+}
+
+func auditWebhookUrl(r *http.Request) (string, string) {
+	detail := "url=" + strings.TrimSpace(r.FormValue("url"))
+	if r.FormValue("rotate") != "" {
+		detail += " secret rotated"
+	}
+	return "", detail
+}
+
+func auditSubscriptionPlan(r *http.Request) (string, string) {
+	return "", "plan=" + r.FormValue("subscription-plan")
+}
+
+// AuditRoute appends an entry to the audit log of the active organization when the wrapped handler succeeds. The
+// actor is the signed in user of the request
+func AuditRoute(logger pkg.AuditLogger, action pkg.AuditAction, target AuditTarget) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			if rec.status >= http.StatusBadRequest {
+				return
+			}
+
+			orgId, _ := r.Context().Value(pkg.OrgIdKey).(string)
+			userId, _ := r.Context().Value(pkg.UserIdKey).(string)
+			targetId, detail := target(r)
+			appendAudit(r.Context(), logger, orgId, pkg.NewAuditEntry(action, userId, targetId, detail, getIp(r)))
+		})
+	}
+}
+
+// AuditLogin appends a login entry to the audit log of every organization the user is a member of when the
+// wrapped handler signs in the user
+func AuditLogin(logger pkg.AuditLogger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			if rec.status >= http.StatusBadRequest {
+				return
+			}
+
+			session := MustGetSession(r)
+			userId, ok := session.Values["userId"].(string)
+			if !ok || userId == "" {
+				return
+			}
+			for _, orgId := range organizationIds(session) {
+				appendAudit(r.Context(), logger, orgId, pkg.NewAuditEntry(pkg.AuditLogin, userId, userId, "", getIp(r)))
+			}
+		})
+	}
+}
+
+func appendAudit(ctx context.Context, logger pkg.AuditLogger, orgId string, entry *pkg.AuditEntry) {
+	if orgId == "" {
+		return
+	}
+	if err := logger.Audit(ctx, orgId, entry); err != nil {
+		slog.ErrorContext(ctx, "Could not append to audit log", "action", entry.Action, "orgId", orgId, "error", err)
+	}
+}
+
+// AuditLogHandler lists the audit log of the active organization. The optional query parameters from and to are
+// dates, where to is inclusive. The last 30 days are listed by default
+func AuditLogHandler(store pkg.AuditLogReader, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		to := time.Now()
+		if value := query.Get("to"); value != "" {
+			day, err := time.Parse(time.DateOnly, value)
+			if err != nil {
+				http.Error(w, "to must be a date", http.StatusBadRequest)
+				return
+			}
+			to = day.AddDate(0, 0, 1)
+		}
+		from := to.Add(-auditLogWindow)
+		if value := query.Get("from"); value != "" {
+			var err error
+			from, err = time.Parse(time.DateOnly, value)
+			if err != nil {
+				http.Error(w, "from must be a date", http.StatusBadRequest)
+				return
+			}
+		}
+
+		page := 0
+		if value := query.Get("page"); value != "" {
+			var err error
+			page, err = strconv.Atoi(value)
+			if err != nil || page < 0 {
+				http.Error(w, "page must be a non-negative integer", http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		entries, err := store.AuditLog(ctx, orgId, from, to)
+		if err != nil {
+			http.Error(w, "Could not fetch audit log", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch audit log", "error", err)
+			return
+		}
+		entries, hasMore := pkg.Page(entries, page, auditLogPageSize)
+
+		result := struct {
+			Entries []pkg.AuditEntry `json:"entries"`
+			HasMore bool             `json:"hasMore"`
+		}{Entries: entries, HasMore: hasMore}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			slog.ErrorContext(ctx, "Failed to encode audit log", "error", err)
+		}
+	}
+}
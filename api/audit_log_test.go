@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestAuditRouteRecordsOnSuccess(t *testing.T) {
+	for _, test := range []struct {
+		desc   string
+		status int
+		want   int
+	}{
+		{"success", http.StatusOK, 1},
+		{"forbidden", http.StatusForbidden, 0},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			store := pkg.NewMultiOrgInMemoryStore()
+			handler := AuditRoute(store, pkg.AuditMemberRemoved, auditPathId)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+			}))
+
+			mux := http.NewServeMux()
+			mux.Handle("DELETE "+RouteOrganizationsUsersId, handler)
+			req := httptest.NewRequest("DELETE", "/organizations/users/member", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			ctx := context.WithValue(req.Context(), pkg.OrgIdKey, "org")
+			req = req.WithContext(context.WithValue(ctx, pkg.UserIdKey, "admin"))
+			mux.ServeHTTP(httptest.NewRecorder(), req)
+
+			testutils.AssertEqual(t, len(store.AuditLogs["org"]), test.want)
+			if test.want > 0 {
+				entry := store.AuditLogs["org"][0]
+				testutils.AssertEqual(t, entry.Action, pkg.AuditMemberRemoved)
+				testutils.AssertEqual(t, entry.ActorId, "admin")
+				testutils.AssertEqual(t, entry.TargetId, "member")
+				testutils.AssertEqual(t, entry.IP, "10.0.0.1")
+			}
+		})
+	}
+}
+
+func TestAuditLoginRecordsAllOrganizations(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	handler := AuditLogin(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := pkg.UserInfo{Id: "user", Roles: map[string]pkg.RoleKind{"org1": pkg.RoleViewer, "org2": pkg.RoleAdmin}}
+		session := MustGetSession(r)
+		session.Values["userId"] = user.Id
+		pkg.PopulateSessionWithRoles(session, &user)
+		w.WriteHeader(http.StatusSeeOther)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), withEmptySession(httptest.NewRequest("GET", RouteAuthCallback, nil)))
+	for _, orgId := range []string{"org1", "org2"} {
+		testutils.AssertEqual(t, len(store.AuditLogs[orgId]), 1)
+		testutils.AssertEqual(t, store.AuditLogs[orgId][0].Action, pkg.AuditLogin)
+		testutils.AssertEqual(t, store.AuditLogs[orgId][0].ActorId, "user")
+	}
+
+	t.Run("failed login is not recorded", func(t *testing.T) {
+		store := pkg.NewMultiOrgInMemoryStore()
+		handler := AuditLogin(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "wrong password", http.StatusUnauthorized)
+		}))
+		req := withSignedInSession(httptest.NewRequest("POST", RouteLoginBasic, nil), "org1")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		testutils.AssertEqual(t, len(store.AuditLogs), 0)
+	})
+}
+
+func TestAuditLogHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	now := time.Now()
+	for i := range auditLogPageSize + 1 {
+		entry := pkg.NewAuditEntry(pkg.AuditLogin, "user", "user", "", "")
+		entry.Time = now.Add(-time.Duration(i) * time.Minute)
+		testutils.AssertNil(t, store.Audit(context.Background(), "org", entry))
+	}
+	old := pkg.NewAuditEntry(pkg.AuditRoleChanged, "admin", "user", "role=2", "")
+	old.Time = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	testutils.AssertNil(t, store.Audit(context.Background(), "org", old))
+
+	type auditLog struct {
+		Entries []pkg.AuditEntry `json:"entries"`
+		HasMore bool             `json:"hasMore"`
+	}
+	get := func(query string) (int, auditLog) {
+		rec := httptest.NewRecorder()
+		AuditLogHandler(store, time.Second)(rec, withAuthSession(httptest.NewRequest("GET", RouteOrganizationsAudit+query, nil), "org"))
+		var result auditLog
+		if rec.Code == http.StatusOK {
+			testutils.AssertNil(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		}
+		return rec.Code, result
+	}
+
+	code, result := get("")
+	testutils.AssertEqual(t, code, http.StatusOK)
+	testutils.AssertEqual(t, len(result.Entries), auditLogPageSize)
+	testutils.AssertEqual(t, result.HasMore, true)
+
+	_, result = get("?page=1")
+	testutils.AssertEqual(t, len(result.Entries), 1)
+	testutils.AssertEqual(t, result.HasMore, false)
+
+	_, result = get("?from=2025-01-01&to=2025-01-01")
+	testutils.AssertEqual(t, len(result.Entries), 1)
+	testutils.AssertEqual(t, result.Entries[0].Detail, "role=2")
+
+	for _, query := range []string{"?page=-1", "?from=yesterday", "?to=today"} {
+		code, _ := get(query)
+		testutils.AssertEqual(t, code, http.StatusBadRequest)
+	}
+}
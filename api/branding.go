@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+// sessionBranding returns the branding of the active organization in the session. If there is no
+// active organization, the default branding is returned
+func sessionBranding(r *http.Request, getter pkg.OrganizationGetter, timeout time.Duration) pkg.Branding {
+	orgId, ok := MustGetSession(r).Values["orgId"].(string)
+	if !ok || orgId == "" {
+		return pkg.Branding{}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	org, err := getter.GetOrganization(ctx, orgId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not get organization", "error", err, "orgId", orgId)
+		return pkg.Branding{}
+	}
+	return org.Branding
+}
+
+func BrandingCSSHandler(getter pkg.OrganizationGetter, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		web.BrandingCSS(w, sessionBranding(r, getter, timeout))
+	}
+}
+
+func BrandingLogoHandler(getter pkg.OrganizationGetter, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		web.BrandingLogo(w, sessionBranding(r, getter, timeout))
+	}
+}
+
+// BrandingFormHandler renders the form for editing branding. Only admins see the form
+func BrandingFormHandler(getter pkg.OrganizationGetter, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := MustGetSession(r)
+		orgId := MustGetOrgId(session)
+		if !MustGetUserInfo(session).Roles[orgId].AtLeast(pkg.RoleAdmin) {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		org, err := getter.GetOrganization(ctx, orgId)
+		if err != nil {
+			http.Error(w, "Could not get organization", http.StatusInternalServerError)
+			slog.ErrorContext(ctx, "Could not get organization", "error", err, "orgId", orgId)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		web.BrandingForm(w, pkg.LanguageFromReq(r), org.Branding)
+	}
+}
+
+func UpdateBrandingHandler(updater pkg.BrandingUpdater, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 4096)
+		code, err := parseForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			slog.ErrorContext(r.Context(), "Failed to parse form", "error", err)
+			return
+		}
+
+		branding := pkg.Branding{
+			PrimaryColor: r.FormValue("primaryColor"),
+			LogoURL:      r.FormValue("logoUrl"),
+		}
+		if err := branding.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		if err := updater.UpdateBranding(ctx, orgId, branding); errors.Is(err, pkg.ErrOrganizationNotFound) {
+			http.Error(w, "Organization not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Could not update branding", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not update branding", "error", err, "orgId", orgId)
+			return
+		}
+
+		slog.InfoContext(ctx, "Updated branding", "orgId", orgId)
+		HxTrigger(w, EventBrandingUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.branding-updated", nil)
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/gorilla/sessions"
+)
+
+func withEmptySession(r *http.Request) *http.Request {
+	session, err := sessions.NewCookieStore([]byte("whatever-key")).Get(r, AuthSession)
+	if err != nil {
+		panic(err)
+	}
+	return r.WithContext(context.WithValue(r.Context(), sessionKey, session))
+}
+
+func brandedStore(t *testing.T) (*pkg.MultiOrgInMemoryStore, string) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	err := store.UpdateBranding(context.Background(), orgId, pkg.Branding{PrimaryColor: "#112233", LogoURL: "https://example.com/logo.png"})
+	testutils.AssertNil(t, err)
+	return store, orgId
+}
+
+func TestBrandingCSSHandler(t *testing.T) {
+	store, orgId := brandedStore(t)
+	handler := BrandingCSSHandler(store, time.Second)
+
+	t.Run("branded organization", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, withAuthSession(httptest.NewRequest("GET", "/session/branding.css", nil), orgId))
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertContains(t, recorder.Header().Get("Content-Type"), "text/css")
+		testutils.AssertContains(t, recorder.Body.String(), "--color-primary-600: #112233")
+	})
+
+	t.Run("no active organization", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, withEmptySession(httptest.NewRequest("GET", "/session/branding.css", nil)))
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertEqual(t, recorder.Body.Len(), 0)
+	})
+
+	t.Run("organization can not be fetched", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		failing := &pkg.MockIAMStore{ErrGetOrganization: errors.New("what")}
+		BrandingCSSHandler(failing, time.Second)(recorder, withAuthSession(httptest.NewRequest("GET", "/session/branding.css", nil), orgId))
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertEqual(t, recorder.Body.Len(), 0)
+	})
+}
+
+func TestBrandingLogoHandler(t *testing.T) {
+	store, orgId := brandedStore(t)
+	recorder := httptest.NewRecorder()
+	BrandingLogoHandler(store, time.Second)(recorder, withAuthSession(httptest.NewRequest("GET", "/session/branding/logo", nil), orgId))
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+	testutils.AssertContains(t, recorder.Body.String(), `src="https://example.com/logo.png"`)
+}
+
+func TestBrandingFormHandler(t *testing.T) {
+	store, orgId := brandedStore(t)
+	handler := BrandingFormHandler(store, time.Second)
+
+	t.Run("admin sees form", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, withAuthSession(httptest.NewRequest("GET", "/organizations/branding", nil), orgId))
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertContains(t, recorder.Body.String(), `id="branding-form"`, `value="#112233"`)
+	})
+
+	t.Run("non-admin sees nothing", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		req := withAuthSession(httptest.NewRequest("GET", "/organizations/branding", nil), orgId)
+		session := MustGetSession(req)
+		session.Values["role"], _ = json.Marshal(pkg.UserInfo{Roles: map[string]pkg.RoleKind{orgId: pkg.RoleViewer}})
+		handler(recorder, req)
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertNotContains(t, recorder.Body.String(), "branding-form")
+	})
+
+	t.Run("organization can not be fetched", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		failing := &pkg.MockIAMStore{ErrGetOrganization: errors.New("what")}
+		BrandingFormHandler(failing, time.Second)(recorder, withAuthSession(httptest.NewRequest("GET", "/organizations/branding", nil), orgId))
+		testutils.AssertEqual(t, recorder.Code, http.StatusInternalServerError)
+	})
+}
+
+func brandingRequest(orgId string, form url.Values) *http.Request {
+	req := httptest.NewRequest("PUT", "/organizations/branding", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return withAuthSession(req, orgId)
+}
+
+func TestUpdateBrandingHandler(t *testing.T) {
+	store, orgId := brandedStore(t)
+	handler := UpdateBrandingHandler(store, time.Second)
+
+	t.Run("success", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, brandingRequest(orgId, url.Values{"primaryColor": {"#aabbcc"}, "logoUrl": {""}}))
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+
+		var events map[string]any
+		testutils.AssertNil(t, json.Unmarshal([]byte(recorder.Header().Get("HX-Trigger")), &events))
+		_, ok := events[string(EventBrandingUpdated)]
+		testutils.AssertEqual(t, ok, true)
+		_, ok = events[string(EventFlash)]
+		testutils.AssertEqual(t, ok, true)
+
+		org, err := store.GetOrganization(context.Background(), orgId)
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, org.Branding, pkg.Branding{PrimaryColor: "#aabbcc"})
+	})
+
+	t.Run("invalid color", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, brandingRequest(orgId, url.Values{"primaryColor": {"red"}}))
+		testutils.AssertEqual(t, recorder.Code, http.StatusBadRequest)
+	})
+
+	t.Run("insecure logo", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, brandingRequest(orgId, url.Values{"logoUrl": {"http://example.com/logo.png"}}))
+		testutils.AssertEqual(t, recorder.Code, http.StatusBadRequest)
+	})
+
+	t.Run("unknown organization", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, brandingRequest("unknown-org", url.Values{"primaryColor": {"#aabbcc"}}))
+		testutils.AssertEqual(t, recorder.Code, http.StatusNotFound)
+	})
+
+	t.Run("store failure", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		failing := &pkg.MockIAMStore{ErrUpdateBranding: errors.New("what")}
+		UpdateBrandingHandler(failing, time.Second)(recorder, brandingRequest(orgId, url.Values{"primaryColor": {"#aabbcc"}}))
+		testutils.AssertEqual(t, recorder.Code, http.StatusInternalServerError)
+	})
+
+	t.Run("body too large", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, brandingRequest(orgId, url.Values{"logoUrl": {strings.Repeat("a", 5000)}}))
+		testutils.AssertEqual(t, recorder.Code, http.StatusRequestEntityTooLarge)
+	})
+}
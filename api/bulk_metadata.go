@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+type BulkMetaDataResponse struct {
+	Results []pkg.MetaDataPatchResult `json:"results"`
+}
+
+func BulkEditPageHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	web.BulkEditPage(w, pkg.LanguageFromReq(r))
+}
+
+// BulkEditRowsHandler renders the editable rows of the resources matching the filter
+func BulkEditRowsHandler(fetcher pkg.MetaByPatternFetcher, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filterValue := r.URL.Query().Get("resource-filter")
+		pattern := &pkg.MetaData{
+			Title:    filterValue,
+			Composer: filterValue,
+			Arranger: filterValue,
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		meta, err := fetcher.MetaByPattern(ctx, orgId, pattern)
+		if err != nil {
+			http.Error(w, "Failed to fetch metadata", http.StatusInternalServerError)
+			slog.ErrorContext(ctx, "Failed to fetch metadata", "error", err)
+			return
+		}
+		meta = slices.DeleteFunc(meta, func(m pkg.MetaData) bool { return m.Deleted })
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		web.BulkEditRows(w, meta)
+	}
+}
+
+// BulkMetaDataHandler applies a list of metadata patches. Each patch is applied separately and the
+// outcome of each is reported in the response. The request is rejected when a patch has fields that can not be
+// edited in bulk, such as the fields making up the resource id
+func BulkMetaDataHandler(store pkg.Transactor, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+		var patches []pkg.MetaDataPatch
+		if err := json.NewDecoder(r.Body).Decode(&patches); err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Could not decode patches: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if len(patches) == 0 || len(patches) > pkg.MaxMetaDataPatches {
+			http.Error(w, fmt.Sprintf("Number of patches must be between 1 and %d", pkg.MaxMetaDataPatches), http.StatusBadRequest)
+			return
+		}
+
+		if unsupported := pkg.UnsupportedPatchFields(patches); len(unsupported) > 0 {
+			msg := "Fields can not be edited in bulk: " + strings.Join(unsupported, ", ")
+			if slices.ContainsFunc(unsupported, pkg.IsIdentityField) {
+				msg += ". Title, composer and arranger make up the resource id and can not be changed"
+			}
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		results := pkg.BulkPatchMetaData(ctx, store, orgId, patches)
+
+		numUpdated := 0
+		for _, result := range results {
+			if result.Ok {
+				numUpdated++
+			}
+		}
+		slog.InfoContext(ctx, "Applied metadata patches", "num", len(patches), "num-updated", numUpdated)
+
+		level := FlashSuccess
+		if numUpdated < len(results) {
+			level = FlashWarning
+		}
+		HxTrigger(w, EventMetadataUpdated, nil)
+		HxFlash(w, r, level, "flash.metadata-updated", struct{ Updated, Total int }{numUpdated, len(results)})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(BulkMetaDataResponse{Results: results})
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func demoResourceIds(t *testing.T, store *pkg.MultiOrgInMemoryStore) []string {
+	meta, err := store.MetaByPattern(context.Background(), store.FirstOrganizationId(), &pkg.MetaData{})
+	testutils.AssertNil(t, err)
+	ids := make([]string, len(meta))
+	for i := range meta {
+		ids[i] = meta[i].ResourceId()
+	}
+	return ids
+}
+
+func TestBulkEditPageHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	BulkEditPageHandler(rec, httptest.NewRequest("GET", "/overview/bulk-edit", nil))
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Body.String(), `id="bulk-edit-rows"`, "/js/bulk-edit.js")
+}
+
+func TestBulkEditRowsHandler(t *testing.T) {
+	store := pkg.NewDemoStore()
+	ids := demoResourceIds(t, store)
+
+	rec := httptest.NewRecorder()
+	req := withAuthSession(httptest.NewRequest("GET", "/resources/metadata/table", nil), store.FirstOrganizationId())
+	BulkEditRowsHandler(store, time.Second)(rec, req)
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, strings.Count(rec.Body.String(), "data-resource-id="), len(ids))
+
+	rec = httptest.NewRecorder()
+	failing := &failingFetcher{err: errors.New("what")}
+	BulkEditRowsHandler(failing, time.Second)(rec, req)
+	testutils.AssertEqual(t, rec.Code, http.StatusInternalServerError)
+}
+
+func bulkPatchRequest(orgId string, body []byte) *http.Request {
+	req := httptest.NewRequest("PATCH", "/resources/metadata", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return withAuthSession(req, orgId)
+}
+
+func TestBulkMetaDataHandler(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	ids := demoResourceIds(t, store)
+	handler := BulkMetaDataHandler(store, time.Second)
+
+	t.Run("partial success", func(t *testing.T) {
+		patches := []pkg.MetaDataPatch{
+			{ResourceId: ids[0], Fields: map[string]string{"genre": "Marsj"}},
+			{ResourceId: "unknown", Fields: map[string]string{"genre": "Marsj"}},
+		}
+		body, err := json.Marshal(patches)
+		testutils.AssertNil(t, err)
+
+		rec := httptest.NewRecorder()
+		handler(rec, bulkPatchRequest(orgId, body))
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+
+		var resp BulkMetaDataResponse
+		testutils.AssertNil(t, json.NewDecoder(rec.Body).Decode(&resp))
+		testutils.AssertEqual(t, len(resp.Results), 2)
+		testutils.AssertEqual(t, resp.Results[0].Ok, true)
+		testutils.AssertEqual(t, resp.Results[1].Ok, false)
+
+		testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), string(EventMetadataUpdated), "Updated 1 of 2 pieces", `"warning"`)
+
+		meta, err := store.MetaById(context.Background(), orgId, ids[0])
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, meta.Genre, "Marsj")
+	})
+
+	t.Run("identity fields", func(t *testing.T) {
+		patches := []pkg.MetaDataPatch{
+			{ResourceId: ids[0], Fields: map[string]string{"genre": "Pop"}},
+			{ResourceId: ids[0], Fields: map[string]string{"arranger": "Someone", "color": "red"}},
+		}
+		body, err := json.Marshal(patches)
+		testutils.AssertNil(t, err)
+
+		rec := httptest.NewRecorder()
+		handler(rec, bulkPatchRequest(orgId, body))
+		testutils.AssertEqual(t, rec.Code, http.StatusBadRequest)
+		testutils.AssertContains(t, rec.Body.String(), "arranger, color", "resource id")
+
+		meta, err := store.MetaById(context.Background(), orgId, ids[0])
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, meta.Genre, "Marsj")
+	})
+
+	for _, test := range []struct {
+		desc string
+		body []byte
+		want int
+	}{
+		{"invalid json", []byte("not json"), http.StatusBadRequest},
+		{"empty list", []byte("[]"), http.StatusBadRequest},
+		{"too many", []byte("[" + strings.Repeat(`{"resourceId": "a"},`, pkg.MaxMetaDataPatches) + `{"resourceId": "a"}]`), http.StatusBadRequest},
+		{"too large", []byte(`[{"resourceId": "` + strings.Repeat("a", 1<<20) + `"}]`), http.StatusRequestEntityTooLarge},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler(rec, bulkPatchRequest(orgId, test.body))
+			testutils.AssertEqual(t, rec.Code, test.want)
+		})
+	}
+}
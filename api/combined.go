@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+// SubmitCombinedHandler stores a PDF holding several pieces as one resource per piece. The form has the PDF in
+// document and the JSON encoded []pkg.CombinedPiece in pieces. Either all pieces are stored or none
+func SubmitCombinedHandler(store pkg.CombinedSubmitStore, timeout time.Duration, maxSize int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		file, ok := documentFromForm(w, r, maxSize)
+		if !ok {
+			return
+		}
+		defer file.Close()
+
+		var pieces []pkg.CombinedPiece
+		if err := json.Unmarshal([]byte(r.FormValue("pieces")), &pieces); err != nil {
+			http.Error(w, "Failed to parse pieces", http.StatusBadRequest)
+			slog.ErrorContext(r.Context(), "Failed to parse pieces", "error", err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		resourceIds, err := pkg.SubmitCombined(ctx, store, orgId, file, pieces)
+		if err != nil {
+			code := StoreErrorCode(err)
+			if code == http.StatusBadRequest || code == http.StatusConflict {
+				http.Error(w, err.Error(), code)
+				slog.InfoContext(ctx, "Rejected combined submit", "error", err)
+				return
+			}
+			http.Error(w, "Failed to store pieces", code)
+			slog.ErrorContext(ctx, "Failed to store pieces", "error", err)
+			return
+		}
+
+		for _, resourceId := range resourceIds {
+			recordEvent(ctx, newRequestEvent(r, pkg.EventResourceUploaded, orgId, resourceId))
+		}
+		slog.InfoContext(ctx, "Combined pdf stored successfully", "resourceIds", resourceIds)
+		HxTrigger(w, EventResourceUploaded, map[string][]string{"resourceIds": resourceIds})
+		HxFlash(w, r, FlashSuccess, "flash.pieces-uploaded", map[string]any{"Count": len(resourceIds)})
+		w.WriteHeader(http.StatusOK)
+	}
+}
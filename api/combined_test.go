@@ -0,0 +1,63 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func combinedForm(t *testing.T, pieces string) (*bytes.Buffer, string) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	document, err := writer.CreateFormFile("document", "combined.pdf")
+	testutils.AssertNil(t, err)
+	testutils.AssertNil(t, pkg.CreateNPagePdf(document, 4))
+	testutils.AssertNil(t, writer.WriteField("pieces", pieces))
+	testutils.AssertNil(t, writer.Close())
+	return &buf, writer.FormDataContentType()
+}
+
+func TestSubmitCombinedHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.RegisterOrganization(context.Background(), &pkg.Organization{Id: "org1"}))
+	handler := SubmitCombinedHandler(store, time.Second, 10)
+
+	submit := func(pieces string) *httptest.ResponseRecorder {
+		body, contentType := combinedForm(t, pieces)
+		req := withAuthSession(httptest.NewRequest("POST", RouteResourcesCombined, body), "org1")
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	pieces := `[
+		{"metadata": {"title": "Movement I"}, "assignments": [{"id": "Score", "from": 1, "to": 2}]},
+		{"metadata": {"title": "Movement II"}, "assignments": [{"id": "Score", "from": 3, "to": 4}]}
+	]`
+	rec := submit(pieces)
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), string(EventResourceUploaded))
+	testutils.AssertEqual(t, len(store.Data["org1"].Metadata), 2)
+
+	t.Run("existing pieces", func(t *testing.T) {
+		testutils.AssertEqual(t, submit(pieces).Code, http.StatusConflict)
+	})
+
+	t.Run("invalid pieces", func(t *testing.T) {
+		rec := submit(`[{"metadata": {"title": "Movement III"}, "assignments": [{"id": "Score", "from": 3, "to": 5}]}]`)
+		testutils.AssertEqual(t, rec.Code, http.StatusBadRequest)
+		testutils.AssertContains(t, rec.Body.String(), "not within the 4 pages")
+	})
+
+	t.Run("malformed pieces", func(t *testing.T) {
+		testutils.AssertEqual(t, submit("not json").Code, http.StatusBadRequest)
+	})
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+type CorrectionProposer interface {
+	pkg.MetaByIdGetter
+	pkg.CorrectionStore
+}
+
+// ProposeCorrectionHandler files a correction of a field of the metadata of a resource. Any member can propose
+// corrections, and the member is stored with the correction
+func ProposeCorrectionHandler(store CorrectionProposer, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 8192)
+		code, err := parseForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		session := MustGetSession(r)
+		orgId := MustGetOrgId(session)
+		resourceId := r.PathValue("id")
+		meta, err := store.MetaById(ctx, orgId, resourceId)
+		if err == nil && meta.Deleted {
+			err = pkg.ErrResourceMetadataNotFound
+		}
+		if err != nil {
+			http.Error(w, "Could not find the piece", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch metadata of corrected piece", "error", err, "orgId", orgId, "resourceId", resourceId)
+			return
+		}
+
+		correction, err := pkg.NewMetaDataCorrection(meta, r.FormValue("field"), r.FormValue("value"), r.FormValue("comment"), MustGetUserInfo(session))
+		if err == nil {
+			err = store.SubmitCorrection(ctx, orgId, correction)
+		}
+		if err != nil {
+			http.Error(w, "Could not propose correction: "+err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not store correction", "error", err, "orgId", orgId, "resourceId", resourceId)
+			return
+		}
+
+		slog.InfoContext(ctx, "Correction proposed", "orgId", orgId, "resourceId", resourceId, "correctionId", correction.Id, "field", correction.Field)
+		HxTrigger(w, EventCorrectionsUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.correction-proposed", nil)
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+type ProfileCorrectionProposer interface {
+	pkg.RoleGetter
+	pkg.CorrectionStore
+}
+
+// ProposeProfileCorrectionHandler files a correction of the name of the signed in member
+func ProposeProfileCorrectionHandler(store ProfileCorrectionProposer, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 8192)
+		code, err := parseForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		session := MustGetSession(r)
+		orgId := MustGetOrgId(session)
+		userId := MustGetUserId(session)
+		user, err := store.GetUserInfo(ctx, userId)
+		if err != nil {
+			http.Error(w, "Could not fetch user", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch user", "error", err, "userId", userId)
+			return
+		}
+
+		correction, err := pkg.NewProfileCorrection(r.FormValue("value"), r.FormValue("comment"), user)
+		if err == nil {
+			err = store.SubmitCorrection(ctx, orgId, correction)
+		}
+		if err != nil {
+			http.Error(w, "Could not propose correction: "+err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not store correction", "error", err, "orgId", orgId, "userId", userId)
+			return
+		}
+
+		slog.InfoContext(ctx, "Correction proposed", "orgId", orgId, "userId", userId, "correctionId", correction.Id, "field", correction.Field)
+		HxTrigger(w, EventCorrectionsUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.correction-proposed", nil)
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// canDecideCorrection returns true when the role is allowed to approve or reject the correction. Corrections of
+// resources are handled by librarians, while corrections of members are handled by admins
+func canDecideCorrection(role pkg.RoleKind, correction *pkg.Correction) bool {
+	if correction.Kind == pkg.CorrectionProfile {
+		return role.AtLeast(pkg.RoleAdmin)
+	}
+	return role.AtLeast(pkg.RoleLibrarian)
+}
+
+// CorrectionsHandler lists the corrections the member can decide on. Other members only see the corrections they
+// have proposed
+func CorrectionsHandler(store ProfileCorrectionProposer, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		session := MustGetSession(r)
+		orgId := MustGetOrgId(session)
+		userId := MustGetUserId(session)
+		role := MustGetUserInfo(session).Roles[orgId]
+
+		user, err := store.GetUserInfo(ctx, userId)
+		if err != nil {
+			http.Error(w, "Could not fetch user", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch user", "error", err, "userId", userId)
+			return
+		}
+
+		corrections, err := store.Corrections(ctx, orgId)
+		if err != nil {
+			http.Error(w, "Could not fetch corrections", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch corrections", "error", err, "orgId", orgId)
+			return
+		}
+
+		canDecide := role.AtLeast(pkg.RoleLibrarian)
+		corrections = slices.DeleteFunc(corrections, func(c pkg.Correction) bool {
+			if canDecide {
+				return !canDecideCorrection(role, &c)
+			}
+			return c.ProposedBy != userId
+		})
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		web.Corrections(w, pkg.LanguageFromReq(r), &web.CorrectionsData{Corrections: corrections, CanDecide: canDecide, Name: user.Name})
+	}
+}
+
+type CorrectionDecider interface {
+	pkg.CorrectionStore
+	pkg.Transactor
+}
+
+// CorrectionDecisionHandler approves or rejects a correction. The form field "decision" is either "approve" or
+// "reject". Approved corrections are applied in the same transaction as the decision is stored
+func CorrectionDecisionHandler(store CorrectionDecider, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 1024)
+		code, err := parseForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		decision := r.FormValue("decision")
+		if decision != "approve" && decision != "reject" {
+			http.Error(w, "Decision must be approve or reject", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		session := MustGetSession(r)
+		orgId := MustGetOrgId(session)
+		user := MustGetUserInfo(session)
+		id := r.PathValue("id")
+
+		correction, err := store.Correction(ctx, orgId, id)
+		if err != nil {
+			http.Error(w, "Could not find correction", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch correction", "error", err, "orgId", orgId, "correctionId", id)
+			return
+		}
+		if !canDecideCorrection(user.Roles[orgId], correction) {
+			http.Error(w, "Only admins can decide on corrections of members", http.StatusForbidden)
+			return
+		}
+
+		decided, err := pkg.DecideCorrection(ctx, store, orgId, id, decision == "approve", user.Id, time.Now())
+		if err != nil {
+			http.Error(w, "Could not decide on correction: "+err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not decide on correction", "error", err, "orgId", orgId, "correctionId", id)
+			return
+		}
+
+		slog.InfoContext(ctx, "Decided on correction", "orgId", orgId, "correctionId", id, "status", decided.Status)
+		HxTrigger(w, EventCorrectionsUpdated, nil)
+		if decided.Kind == pkg.CorrectionMetaData && decided.Status == pkg.CorrectionApproved {
+			HxTrigger(w, EventMetadataUpdated, nil)
+		}
+		HxFlash(w, r, FlashSuccess, "flash.correction-decided", nil)
+		w.WriteHeader(http.StatusOK)
+	}
+}
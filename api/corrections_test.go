@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func correctionMux(store *pkg.MultiOrgInMemoryStore) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("POST "+RouteResourcesIdCorrections, ProposeCorrectionHandler(store, time.Second))
+	mux.Handle("POST "+RouteOrganizationsCorrectionsProfile, ProposeProfileCorrectionHandler(store, time.Second))
+	mux.Handle("GET "+RouteOrganizationsCorrections, CorrectionsHandler(store, time.Second))
+	mux.Handle("PUT "+RouteOrganizationsCorrectionsId, CorrectionDecisionHandler(store, time.Second))
+	return mux
+}
+
+func correctionStore(t *testing.T) (*pkg.MultiOrgInMemoryStore, string) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	user := pkg.UserInfo{Id: "0000-0000", Name: "Anna", Roles: map[string]pkg.RoleKind{orgId: pkg.RoleAdmin}, Groups: map[string][]string{}}
+	testutils.AssertNil(t, store.RegisterUser(context.Background(), &user))
+	return store, orgId
+}
+
+// withCorrectionRole returns a signed in request of user 0000-0000 with the role in the organization
+func withCorrectionRole(r *http.Request, orgId string, role pkg.RoleKind) *http.Request {
+	r = withSignedInSession(r, orgId)
+	MustGetSession(r).Values["role"], _ = json.Marshal(pkg.UserInfo{Id: "0000-0000", Name: "Anna", Roles: map[string]pkg.RoleKind{orgId: role}})
+	return r
+}
+
+func correctionRequest(method, target string, form url.Values) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestMetaDataCorrectionRoundTrip(t *testing.T) {
+	store, orgId := correctionStore(t)
+	mux := correctionMux(store)
+	meta := store.Data[orgId].Metadata[0]
+	target := strings.Replace(RouteResourcesIdCorrections, "{id}", meta.ResourceId(), 1)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, withCorrectionRole(correctionRequest("POST", target, url.Values{"field": {"genre"}, "value": {"Polka"}, "comment": {"Misspelled"}}), orgId, pkg.RoleViewer))
+	testutils.AssertEqual(t, rec.Code, http.StatusCreated)
+	testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), string(EventCorrectionsUpdated))
+
+	corrections, err := store.Corrections(context.Background(), orgId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(corrections), 1)
+	testutils.AssertEqual(t, corrections[0].ProposerName, "Anna")
+
+	t.Run("librarian sees the queue", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, withCorrectionRole(httptest.NewRequest("GET", RouteOrganizationsCorrections, nil), orgId, pkg.RoleLibrarian))
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+		testutils.AssertContains(t, rec.Body.String(), meta.Title, "Misspelled", `hx-put="/organizations/corrections/`+corrections[0].Id+`"`)
+	})
+
+	decide := func(decision string, role pkg.RoleKind) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		target := strings.Replace(RouteOrganizationsCorrectionsId, "{id}", corrections[0].Id, 1)
+		mux.ServeHTTP(rec, withCorrectionRole(correctionRequest("PUT", target, url.Values{"decision": {decision}}), orgId, role))
+		return rec
+	}
+
+	testutils.AssertEqual(t, decide("maybe", pkg.RoleLibrarian).Code, http.StatusBadRequest)
+	rec = decide("approve", pkg.RoleLibrarian)
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), string(EventMetadataUpdated))
+
+	updated, err := store.MetaById(context.Background(), orgId, meta.ResourceId())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, updated.Genre, "Polka")
+
+	testutils.AssertEqual(t, decide("reject", pkg.RoleLibrarian).Code, http.StatusConflict)
+}
+
+func TestProposeMetaDataCorrectionInvalidInput(t *testing.T) {
+	store, orgId := correctionStore(t)
+	mux := correctionMux(store)
+	resourceId := store.Data[orgId].Metadata[0].ResourceId()
+
+	for _, test := range []struct {
+		desc       string
+		resourceId string
+		form       url.Values
+		code       int
+	}{
+		{"title is part of the id", resourceId, url.Values{"field": {"title"}, "value": {"Polka"}}, http.StatusBadRequest},
+		{"invalid duration", resourceId, url.Values{"field": {"duration"}, "value": {"long"}}, http.StatusBadRequest},
+		{"unknown resource", "unknown", url.Values{"field": {"genre"}, "value": {"Polka"}}, http.StatusNotFound},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			target := strings.Replace(RouteResourcesIdCorrections, "{id}", test.resourceId, 1)
+			mux.ServeHTTP(rec, withCorrectionRole(correctionRequest("POST", target, test.form), orgId, pkg.RoleViewer))
+			testutils.AssertEqual(t, rec.Code, test.code)
+		})
+	}
+}
+
+func TestProfileCorrectionRequiresAdmin(t *testing.T) {
+	store, orgId := correctionStore(t)
+	mux := correctionMux(store)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, withCorrectionRole(correctionRequest("POST", RouteOrganizationsCorrectionsProfile, url.Values{"value": {"Anna Hansen"}}), orgId, pkg.RoleViewer))
+	testutils.AssertEqual(t, rec.Code, http.StatusCreated)
+
+	corrections, err := store.Corrections(context.Background(), orgId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(corrections), 1)
+	testutils.AssertEqual(t, corrections[0].Current, "Anna")
+
+	listFor := func(role pkg.RoleKind) string {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, withCorrectionRole(httptest.NewRequest("GET", RouteOrganizationsCorrections, nil), orgId, role))
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+		return rec.Body.String()
+	}
+	testutils.AssertContains(t, listFor(pkg.RoleViewer), "Anna Hansen", "Pending")
+	testutils.AssertNotContains(t, listFor(pkg.RoleLibrarian), "Anna Hansen")
+	testutils.AssertContains(t, listFor(pkg.RoleAdmin), "Anna Hansen", "Approve")
+
+	decide := func(role pkg.RoleKind) int {
+		rec := httptest.NewRecorder()
+		target := strings.Replace(RouteOrganizationsCorrectionsId, "{id}", corrections[0].Id, 1)
+		mux.ServeHTTP(rec, withCorrectionRole(correctionRequest("PUT", target, url.Values{"decision": {"approve"}}), orgId, role))
+		return rec.Code
+	}
+	testutils.AssertEqual(t, decide(pkg.RoleLibrarian), http.StatusForbidden)
+	testutils.AssertEqual(t, decide(pkg.RoleAdmin), http.StatusOK)
+
+	user, err := store.GetUserInfo(context.Background(), "0000-0000")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, user.Name, "Anna Hansen")
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+// DashboardHandler renders the start page of the signed in user in the active organization. Visitors and users
+// that have not signed in with a passkey in an organization that requires one get an empty response, such that
+// the public landing page is shown instead
+func DashboardHandler(store pkg.DashboardSource, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		orgId, user, ok := sessionMember(r)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		dashboard, err := pkg.NewDashboard(ctx, store, orgId, user.Id)
+		if err != nil {
+			http.Error(w, "Could not fetch dashboard", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch dashboard", "error", err, "orgId", orgId, "userId", user.Id)
+			return
+		}
+
+		signedInWithPasskey, _ := MustGetSession(r).Values[sessionPasskeyKey].(bool)
+		if dashboard.Organization.RequirePasskey && !signedInWithPasskey {
+			return
+		}
+		web.Dashboard(w, pkg.LanguageFromReq(r), dashboard)
+	}
+}
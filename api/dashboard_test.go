@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestDashboardHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &pkg.Organization{Id: "org1", Name: "Brass band"}))
+	testutils.AssertNil(t, store.RegisterUser(ctx, &pkg.UserInfo{Id: "0000-0000", Email: "kari@example.com"}))
+	testutils.AssertNil(t, store.RegisterRole(ctx, "0000-0000", "org1", pkg.RoleAdmin))
+	testutils.AssertNil(t, store.SubmitProject(ctx, "org1", &pkg.Project{Name: "Spring concert", UpdatedAt: time.Now()}))
+	testutils.AssertNil(t, store.Submit(ctx, "org1", &pkg.MetaData{Title: "Festive overture", Composer: "Shostakovich"}, func(yield func(string, []byte) bool) {}))
+	handler := DashboardHandler(store, time.Second)
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler(recorder, req)
+		return recorder
+	}
+
+	t.Run("member", func(t *testing.T) {
+		recorder := serve(withAuthSession(httptest.NewRequest("GET", RouteDashboard, nil), "org1"))
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertContains(t, recorder.Body.String(), "Brass band", "Spring concert", "Festive overture", "not verified", "not in any group")
+	})
+
+	t.Run("not signed in", func(t *testing.T) {
+		recorder := serve(withEmptySession(httptest.NewRequest("GET", RouteDashboard, nil)))
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertEqual(t, recorder.Body.Len(), 0)
+	})
+
+	t.Run("passkey required", func(t *testing.T) {
+		testutils.AssertNil(t, store.RequirePasskey(ctx, "org1", true))
+		req := withAuthSession(httptest.NewRequest("GET", RouteDashboard, nil), "org1")
+		testutils.AssertEqual(t, serve(req).Body.Len(), 0)
+
+		req = withAuthSession(httptest.NewRequest("GET", RouteDashboard, nil), "org1")
+		MustGetSession(req).Values[sessionPasskeyKey] = true
+		testutils.AssertContains(t, serve(req).Body.String(), "Brass band")
+	})
+
+	t.Run("unknown organization", func(t *testing.T) {
+		recorder := serve(withAuthSession(httptest.NewRequest("GET", RouteDashboard, nil), "unknown"))
+		testutils.AssertEqual(t, recorder.Code, http.StatusNotFound)
+	})
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+// devToolsGate holds back requests while a dev tool replaces the content of the in-memory store, since the
+// store is not synchronized. Requests share the gate, while the dev tools take it alone
+type devToolsGate struct {
+	mu sync.RWMutex
+}
+
+func (g *devToolsGate) Shared(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.mu.RLock()
+		defer g.mu.RUnlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (g *devToolsGate) Exclusive(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+type DevToolsResponse struct {
+	Scenario      string   `json:"scenario"`
+	Organizations []string `json:"organizations"`
+}
+
+func writeDevToolsResponse(w http.ResponseWriter, scenario string, store *pkg.MultiOrgInMemoryStore) {
+	resp := DevToolsResponse{Scenario: scenario, Organizations: []string{}}
+	for _, org := range store.Organizations {
+		resp.Organizations = append(resp.Organizations, org.Id)
+	}
+	slices.Sort(resp.Organizations)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// DevToolsSeedHandler replaces the content of the store with the fixture given by the scenario query parameter
+func DevToolsSeedHandler(store *pkg.MultiOrgInMemoryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scenario := r.URL.Query().Get("scenario")
+		fixture, err := pkg.NewFixtureStore(scenario)
+		if err != nil {
+			http.Error(w, "Unknown scenario. Available scenarios: "+strings.Join(pkg.FixtureNames(), ", "), http.StatusBadRequest)
+			return
+		}
+		store.ReplaceWith(fixture)
+		slog.InfoContext(r.Context(), "Seeded store", "scenario", scenario)
+		writeDevToolsResponse(w, scenario, store)
+	}
+}
+
+// DevToolsResetHandler restores the store to the passed initial state
+func DevToolsResetHandler(store, initial *pkg.MultiOrgInMemoryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store.ReplaceWith(initial)
+		slog.InfoContext(r.Context(), "Reset store to initial state")
+		writeDevToolsResponse(w, "initial", store)
+	}
+}
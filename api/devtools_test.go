@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/gorilla/sessions"
+)
+
+func TestDevToolsSeedHandler(t *testing.T) {
+	store := pkg.NewDemoStore()
+	handler := DevToolsSeedHandler(store)
+
+	t.Run("known scenario", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/devtools/seed?scenario=empty", nil))
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+		testutils.AssertEqual(t, len(store.Organizations), 0)
+
+		var resp DevToolsResponse
+		testutils.AssertNil(t, json.NewDecoder(rec.Body).Decode(&resp))
+		testutils.AssertEqual(t, resp.Scenario, "empty")
+		testutils.AssertEqual(t, len(resp.Organizations), 0)
+	})
+
+	t.Run("unknown scenario", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/devtools/seed?scenario=unknown", nil))
+		testutils.AssertEqual(t, rec.Code, http.StatusBadRequest)
+		testutils.AssertContains(t, rec.Body.String(), "expired-subscription")
+	})
+}
+
+func TestDevToolsResetHandler(t *testing.T) {
+	store := pkg.NewDemoStore()
+	initial := store.Clone()
+	store.ReplaceWith(pkg.NewMultiOrgInMemoryStore())
+
+	rec := httptest.NewRecorder()
+	DevToolsResetHandler(store, initial).ServeHTTP(rec, httptest.NewRequest("POST", "/devtools/reset", nil))
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, len(store.Organizations), len(initial.Organizations))
+}
+
+func TestDevToolsGateHoldsBackRequests(t *testing.T) {
+	var gate devToolsGate
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	reset := gate.Exclusive(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	}))
+	served := make(chan struct{})
+	request := gate.Shared(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(served)
+	}))
+
+	go reset.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/devtools/reset", nil))
+	<-entered
+	go request.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	select {
+	case <-served:
+		t.Fatal("Request was served while the store was replaced")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-served
+}
+
+func TestDevToolsOnlyRegisteredWhenEnabled(t *testing.T) {
+	config := pkg.NewDefaultConfig()
+	for _, enabled := range []bool{false, true} {
+		config.DevTools = enabled
+		store := pkg.NewDemoStore()
+		mux := Setup(store, config, sessions.NewCookieStore([]byte("secret")))
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/devtools/seed?scenario=empty", nil))
+
+		wasSeeded := len(store.Organizations) == 0
+		testutils.AssertEqual(t, wasSeeded, enabled)
+	}
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/gorilla/sessions"
+	"golang.org/x/oauth2"
+)
+
+const domainOrgKey ctxKey = "domainOrg"
+
+// ResolveDomainOrganization looks up the organization that has registered the host of the request as its
+// own domain. Requests to the main domain and to unknown hosts are passed on unchanged. Hosts that can not be
+// registered, such as IP addresses, are never looked up, and the lookups of other hosts are cached
+func ResolveDomainOrganization(store pkg.OrganizationByDomainGetter, config *pkg.Config) func(http.Handler) http.Handler {
+	mainHost := pkg.HostOf(config.BaseURL)
+	cached := pkg.NewCachedOrganizationByDomain(store, config.DomainCacheTTL)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := pkg.NormalizeDomain(r.Host)
+			if host == "" || host == mainHost || pkg.ValidateDomain(host) != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), config.Timeout)
+			org, err := cached.OrganizationByDomain(ctx, host)
+			cancel()
+			if errors.Is(err, pkg.ErrOrganizationNotFound) {
+				next.ServeHTTP(w, r)
+				return
+			} else if err != nil {
+				http.Error(w, "Could not resolve domain", http.StatusInternalServerError)
+				slog.ErrorContext(r.Context(), "Could not resolve domain", "error", err, "host", host)
+				return
+			}
+
+			ctx = context.WithValue(r.Context(), domainOrgKey, org)
+			ctx = context.WithValue(ctx, pkg.OrgIdKey, org.Id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// DomainOrganization returns the organization served on the host of the request
+func DomainOrganization(r *http.Request) (pkg.Organization, bool) {
+	org, ok := r.Context().Value(domainOrgKey).(pkg.Organization)
+	return org, ok
+}
+
+// applyDomainSession restricts the session to the organization owning the domain. The cookie is bound
+// to the exact host since the cookie domain of the main site is not valid on other domains
+func applyDomainSession(r *http.Request, session *sessions.Session, opts *sessions.Options) {
+	org, ok := DomainOrganization(r)
+	if !ok {
+		session.Options = opts
+		return
+	}
+
+	hostOnly := *opts
+	hostOnly.Domain = ""
+	session.Options = &hostOnly
+	session.Values["orgId"] = org.Id
+}
+
+// oauthConfigForRequest returns a config that redirects back to the domain the user signed in from.
+// Note that all redirect URIs must be registered with the identity provider
+func oauthConfigForRequest(r *http.Request, config *oauth2.Config) *oauth2.Config {
+	if _, ok := DomainOrganization(r); !ok {
+		return config
+	}
+
+	redirect, err := url.Parse(config.RedirectURL)
+	if err != nil || redirect.Host == "" {
+		return config
+	}
+	redirect.Host = r.Host
+
+	domainConfig := *config
+	domainConfig.RedirectURL = redirect.String()
+	return &domainConfig
+}
+
+// UpdateDomainHandler sets the domain an organization is served on. An empty domain removes it
+func UpdateDomainHandler(updater pkg.DomainUpdater, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 1024)
+		code, err := parseForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			slog.ErrorContext(r.Context(), "Failed to parse form", "error", err)
+			return
+		}
+
+		domain := pkg.NormalizeDomain(r.FormValue("domain"))
+		if err := pkg.ValidateDomain(domain); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := r.PathValue("id")
+		err = updater.UpdateDomain(ctx, orgId, domain)
+		switch {
+		case errors.Is(err, pkg.ErrOrganizationNotFound):
+			http.Error(w, "Organization not found", http.StatusNotFound)
+		case errors.Is(err, pkg.ErrDomainInUse):
+			http.Error(w, "Domain is already in use", http.StatusConflict)
+		case err != nil:
+			http.Error(w, "Could not update domain", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not update domain", "error", err, "orgId", orgId)
+		default:
+			slog.InfoContext(ctx, "Updated domain", "orgId", orgId, "domain", domain)
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+}
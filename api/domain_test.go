@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/gorilla/sessions"
+	"golang.org/x/oauth2"
+)
+
+func domainStore(t *testing.T) *pkg.MultiOrgInMemoryStore {
+	store := pkg.NewMultiOrgInMemoryStore()
+	store.Organizations = []pkg.Organization{{Id: "org1"}, {Id: "org2"}}
+	testutils.AssertNil(t, store.UpdateDomain(context.Background(), "org1", "music.example.com"))
+	return store
+}
+
+func TestResolveDomainOrganization(t *testing.T) {
+	config := pkg.NewDefaultConfig()
+	config.BaseURL = "https://caesura.no"
+
+	var (
+		resolved pkg.Organization
+		found    bool
+	)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resolved, found = DomainOrganization(r)
+	})
+	handler := ResolveDomainOrganization(domainStore(t), config)(next)
+
+	for _, test := range []struct {
+		host      string
+		wantFound bool
+	}{
+		{"caesura.no", false},
+		{"music.example.com", true},
+		{"Music.Example.com:443", true},
+		{"unknown.example.com", false},
+		{"10.0.0.1:8080", false},
+		{"localhost", false},
+	} {
+		t.Run(test.host, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Host = test.host
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			testutils.AssertEqual(t, rec.Code, http.StatusOK)
+			testutils.AssertEqual(t, found, test.wantFound)
+			if test.wantFound {
+				testutils.AssertEqual(t, resolved.Id, "org1")
+			}
+		})
+	}
+
+	t.Run("ip addresses are not looked up", func(t *testing.T) {
+		failing := &pkg.MockIAMStore{ErrOrganizationByDomain: errors.New("what")}
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = "10.0.0.1"
+		rec := httptest.NewRecorder()
+		ResolveDomainOrganization(failing, config)(next).ServeHTTP(rec, req)
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	})
+
+	t.Run("store failure", func(t *testing.T) {
+		failing := &pkg.MockIAMStore{ErrOrganizationByDomain: errors.New("what")}
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = "music.example.com"
+		rec := httptest.NewRecorder()
+		ResolveDomainOrganization(failing, config)(next).ServeHTTP(rec, req)
+		testutils.AssertEqual(t, rec.Code, http.StatusInternalServerError)
+	})
+}
+
+func TestSessionPinnedToDomainOrganization(t *testing.T) {
+	config := pkg.NewDefaultConfig()
+	config.CookieDomain = "caesura.no"
+	cookieStore := sessions.NewCookieStore([]byte("top-secret"))
+
+	var (
+		orgId  any
+		domain string
+	)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := MustGetSession(r)
+		orgId = session.Values["orgId"]
+		domain = session.Options.Domain
+	})
+	handler := ResolveDomainOrganization(domainStore(t), config)(RequireSession(cookieStore, AuthSession, config.SessionOpts())(next))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "music.example.com"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	testutils.AssertEqual(t, orgId, any("org1"))
+	testutils.AssertEqual(t, domain, "")
+
+	req = httptest.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	testutils.AssertEqual(t, orgId, nil)
+	testutils.AssertEqual(t, domain, "caesura.no")
+}
+
+func TestOAuthConfigForRequest(t *testing.T) {
+	config := &oauth2.Config{RedirectURL: "https://caesura.no/auth/callback"}
+
+	req := httptest.NewRequest("GET", "/login/google", nil)
+	testutils.AssertEqual(t, oauthConfigForRequest(req, config), config)
+
+	req.Host = "music.example.com"
+	req = req.WithContext(context.WithValue(req.Context(), domainOrgKey, pkg.Organization{Id: "org1"}))
+	domainConfig := oauthConfigForRequest(req, config)
+	testutils.AssertEqual(t, domainConfig.RedirectURL, "https://music.example.com/auth/callback")
+	testutils.AssertEqual(t, config.RedirectURL, "https://caesura.no/auth/callback")
+	testutils.AssertContains(t, domainConfig.AuthCodeURL("state"), url.QueryEscape("https://music.example.com/auth/callback"))
+}
+
+func domainRequest(orgId, domain string) *http.Request {
+	form := url.Values{"domain": {domain}}
+	req := httptest.NewRequest("PUT", "/admin/organizations/"+orgId+"/domain", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("id", orgId)
+	return req
+}
+
+func TestUpdateDomainHandler(t *testing.T) {
+	store := domainStore(t)
+	handler := UpdateDomainHandler(store, time.Second)
+
+	for _, test := range []struct {
+		desc   string
+		orgId  string
+		domain string
+		want   int
+	}{
+		{"success", "org2", "Choir.Example.com", http.StatusOK},
+		{"remove domain", "org2", "", http.StatusOK},
+		{"invalid domain", "org2", "not a domain", http.StatusBadRequest},
+		{"in use", "org2", "music.example.com", http.StatusConflict},
+		{"unknown organization", "unknown", "new.example.com", http.StatusNotFound},
+		{"too large", "org2", strings.Repeat("a", 2000), http.StatusRequestEntityTooLarge},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler(rec, domainRequest(test.orgId, test.domain))
+			testutils.AssertEqual(t, rec.Code, test.want)
+		})
+	}
+
+	t.Run("domain is normalized", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler(rec, domainRequest("org2", "Choir.Example.com"))
+		org, err := store.OrganizationByDomain(context.Background(), "choir.example.com")
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, org.Id, "org2")
+	})
+
+	t.Run("store failure", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		failing := &pkg.MockIAMStore{ErrUpdateDomain: errors.New("what")}
+		UpdateDomainHandler(failing, time.Second)(rec, domainRequest("org2", "choir.example.com"))
+		testutils.AssertEqual(t, rec.Code, http.StatusInternalServerError)
+	})
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+const (
+	// Verification links are signed like password reset links, with a subject telling them apart
+	emailVerificationSubject = "verify-email"
+	emailVerificationExpiry  = 72 * time.Hour
+
+	// The user of the session has not verified the email address
+	sessionUnverifiedEmailKey = "unverifiedEmail"
+)
+
+// sendVerificationEmail sends a signed link the user opens to confirm that the email address belongs to them
+func sendVerificationEmail(ctx context.Context, store pkg.ResetEmailStore, config *pkg.Config, emailAddr string) error {
+	branding := pkg.BrandingForUser(ctx, store, emailAddr)
+	email := pkg.Email{
+		Sender:    config.EmailSender,
+		SmtpHost:  config.SmtpConfig.Host,
+		SmtpPort:  config.SmtpConfig.Port,
+		SmtpAuth:  config.SmtpConfig.Auth,
+		Recipents: []string{emailAddr},
+		SendFn:    config.SmtpConfig.SendFn,
+		Branding:  &branding,
+	}
+
+	var (
+		signedToken  string
+		emailContent *bytes.Buffer
+	)
+	err := pkg.ReturnOnFirstError(
+		func() error {
+			var err error
+			signedToken, err = signedEmailToken(emailAddr, emailVerificationSubject, config.CookieSecretSignKey, emailVerificationExpiry)
+			return err
+		},
+		func() error {
+			var err error
+			link := config.BaseURL + RouteEmailVerify + "?token=" + url.QueryEscape(signedToken)
+			emailContent, err = email.Build("Caesura: verify your email address", "Verification link: "+link, func(yield func(string, io.Reader) bool) {})
+			return err
+		},
+		func() error {
+			return email.Send(ctx, emailContent.Bytes())
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := store.CountFeature(ctx, "", pkg.FeatureEmailSent, time.Now()); err != nil {
+		slog.ErrorContext(ctx, "Could not count feature usage", "feature", pkg.FeatureEmailSent, "error", err)
+	}
+	return nil
+}
+
+type VerificationEmailStore interface {
+	pkg.ResetEmailStore
+	pkg.RoleGetter
+}
+
+// ResendVerificationHandler sends a new verification link to the signed in user
+func ResendVerificationHandler(store VerificationEmailStore, config *pkg.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), config.Timeout)
+		defer cancel()
+
+		userId := MustGetUserId(MustGetSession(r))
+		user, err := store.GetUserInfo(ctx, userId)
+		if err != nil {
+			http.Error(w, "Could not fetch user", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch user", "error", err, "userId", userId)
+			return
+		}
+
+		switch {
+		case user.Email == "":
+			http.Error(w, "The user has no email address", http.StatusBadRequest)
+			return
+		case user.VerifiedEmail:
+			HxFlash(w, r, FlashInfo, "flash.email-already-verified", nil)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if err := sendVerificationEmail(ctx, store, config, user.Email); err != nil {
+			http.Error(w, "Could not send verification email", http.StatusInternalServerError)
+			slog.ErrorContext(ctx, "Could not send verification email", "error", err, "userId", userId)
+			return
+		}
+		HxFlash(w, r, FlashSuccess, "flash.verification-sent", nil)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+type EmailVerificationStore interface {
+	pkg.UserByEmailGetter
+	pkg.EmailVerifier
+}
+
+// VerifyEmailHandler marks the email address in a verification link as verified and continues to the start page
+func VerifyEmailHandler(store EmailVerificationStore, signSecret string, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		email, err := emailFromJwt(r.URL.Query().Get("token"), emailVerificationSubject, signSecret)
+		if err != nil {
+			http.Error(w, "Invalid or expired verification link", http.StatusBadRequest)
+			slog.InfoContext(r.Context(), "Invalid verification token", "error", err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		user, err := store.UserByEmail(ctx, email)
+		if err == nil {
+			err = store.MarkEmailVerified(ctx, user.Id)
+		}
+		if err != nil {
+			http.Error(w, "Could not verify email address", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not verify email address", "error", err)
+			return
+		}
+		slog.InfoContext(ctx, "Verified email address", "userId", user.Id)
+
+		session := MustGetSession(r)
+		if userId, _ := session.Values["userId"].(string); userId == user.Id {
+			delete(session.Values, sessionUnverifiedEmailKey)
+			trySaveSession(session, r, w)
+		}
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
+
+// RequireVerifiedEmail rejects users that have not verified their email address when the configuration requires
+// it. The store is only consulted for sessions signed in before the address was verified
+func RequireVerifiedEmail(store pkg.RoleGetter, config *pkg.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session := MustGetSession(r)
+			unverified, _ := session.Values[sessionUnverifiedEmailKey].(bool)
+			if !config.RequireVerifiedEmail || !unverified {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			userId, _ := session.Values["userId"].(string)
+			ctx, cancel := context.WithTimeout(r.Context(), config.Timeout)
+			user, err := store.GetUserInfo(ctx, userId)
+			cancel()
+			switch {
+			case err != nil && !errors.Is(err, pkg.ErrUserNotFound):
+				http.Error(w, "Could not check if the email address is verified", StoreErrorCode(err))
+				slog.ErrorContext(r.Context(), "Could not fetch user", "error", err, "userId", userId)
+				return
+			case err != nil || !user.VerifiedEmail:
+				http.Error(w, "Verify your email address to continue", http.StatusForbidden)
+				return
+			}
+
+			delete(session.Values, sessionUnverifiedEmailKey)
+			trySaveSession(session, r, w)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestVerificationTokenIsNotResetToken(t *testing.T) {
+	verification, err := signedEmailToken("john@example.com", emailVerificationSubject, "secret", time.Minute)
+	testutils.AssertNil(t, err)
+	reset, err := SignedResetToken("john@example.com", "secret", time.Minute)
+	testutils.AssertNil(t, err)
+
+	_, err = emailFromResetPasswordJwt(verification, "secret")
+	testutils.AssertEqual(t, err != nil, true)
+	_, err = emailFromJwt(reset, emailVerificationSubject, "secret")
+	testutils.AssertEqual(t, err != nil, true)
+
+	email, err := emailFromJwt(verification, emailVerificationSubject, "secret")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, email, "john@example.com")
+}
+
+func TestSignUpSendsVerificationEmail(t *testing.T) {
+	var msg []byte
+	config := passwordLoginConfig()
+	config.SmtpConfig.SendFn = func(addr string, auth smtp.Auth, sender string, recipents []string, m []byte) error {
+		msg = m
+		return nil
+	}
+	store := pkg.NewMultiOrgInMemoryStore()
+
+	form := url.Values{}
+	form.Add("email", "john@example.com")
+	form.Add("password", "johns-password")
+	form.Add("retyped", "johns-password")
+	req := withEmptySession(httptest.NewRequest("POST", "/login", bytes.NewBufferString(form.Encode())))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rec := httptest.NewRecorder()
+	LoginByPassword(store, config, pkg.NewLoginThrottler(&config.LoginThrottle))(rec, req)
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, string(msg), "/email/verify?token=")
+	testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), "verify your email address")
+	testutils.AssertEqual(t, MustGetSession(req).Values[sessionUnverifiedEmailKey], any(true))
+}
+
+func TestVerifyEmailHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterUser(ctx, &pkg.UserInfo{Id: "0000-0000", Email: "john@example.com", Password: "hash"}))
+	handler := VerifyEmailHandler(store, "secret", time.Second)
+
+	verify := func(token string) (*httptest.ResponseRecorder, *http.Request) {
+		req := withSignedInSession(httptest.NewRequest("GET", RouteEmailVerify+"?token="+url.QueryEscape(token), nil), "org1")
+		MustGetSession(req).Values[sessionUnverifiedEmailKey] = true
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec, req
+	}
+
+	t.Run("invalid token", func(t *testing.T) {
+		reset, err := SignedResetToken("john@example.com", "secret", time.Minute)
+		testutils.AssertNil(t, err)
+		rec, _ := verify(reset)
+		testutils.AssertEqual(t, rec.Code, http.StatusBadRequest)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		token, err := signedEmailToken("unknown@example.com", emailVerificationSubject, "secret", time.Minute)
+		testutils.AssertNil(t, err)
+		rec, _ := verify(token)
+		testutils.AssertEqual(t, rec.Code, http.StatusNotFound)
+	})
+
+	t.Run("verifies user", func(t *testing.T) {
+		token, err := signedEmailToken("john@example.com", emailVerificationSubject, "secret", time.Minute)
+		testutils.AssertNil(t, err)
+		rec, req := verify(token)
+		testutils.AssertEqual(t, rec.Code, http.StatusSeeOther)
+		_, unverified := MustGetSession(req).Values[sessionUnverifiedEmailKey]
+		testutils.AssertEqual(t, unverified, false)
+
+		user, err := store.GetUserInfo(ctx, "0000-0000")
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, user.VerifiedEmail, true)
+	})
+}
+
+func TestResendVerificationHandler(t *testing.T) {
+	sent := 0
+	config := passwordLoginConfig()
+	config.SmtpConfig.SendFn = func(addr string, auth smtp.Auth, sender string, recipents []string, m []byte) error {
+		sent++
+		return nil
+	}
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterUser(ctx, &pkg.UserInfo{Id: "0000-0000", Email: "john@example.com", Password: "hash"}))
+	handler := ResendVerificationHandler(store, config)
+
+	resend := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler(rec, withSignedInSession(httptest.NewRequest("POST", RouteEmailVerification, nil), "org1"))
+		return rec
+	}
+
+	rec := resend()
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, sent, 1)
+	testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), "verify your email address")
+
+	testutils.AssertNil(t, store.MarkEmailVerified(ctx, "0000-0000"))
+	rec = resend()
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, sent, 1)
+	testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), "already verified")
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterUser(ctx, &pkg.UserInfo{Id: "0000-0000", Email: "john@example.com", Password: "hash"}))
+	config := pkg.NewDefaultConfig()
+	handler := RequireVerifiedEmail(store, config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(unverified bool) int {
+		req := withSignedInSession(httptest.NewRequest("GET", "/", nil), "org1")
+		if unverified {
+			MustGetSession(req).Values[sessionUnverifiedEmailKey] = true
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Unverified users are only rejected when the configuration requires it
+	testutils.AssertEqual(t, serve(true), http.StatusOK)
+
+	config.RequireVerifiedEmail = true
+	testutils.AssertEqual(t, serve(false), http.StatusOK)
+	testutils.AssertEqual(t, serve(true), http.StatusForbidden)
+
+	// Sessions signed in before the address was verified are accepted once it is
+	testutils.AssertNil(t, store.MarkEmailVerified(ctx, "0000-0000"))
+	testutils.AssertEqual(t, serve(true), http.StatusOK)
+}
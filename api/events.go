@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+const eventsKey ctxKey = "events"
+
+// requestEvents holds the events recorded while handling a request
+type requestEvents struct {
+	mu     sync.Mutex
+	events []pkg.Event
+}
+
+// recordEvent adds the event to the events of the request. The events are published by EmitEvents when the
+// request succeeds. Nothing is done for requests that are not wrapped by EmitEvents
+func recordEvent(ctx context.Context, event pkg.Event) {
+	recorded, ok := ctx.Value(eventsKey).(*requestEvents)
+	if !ok {
+		return
+	}
+	recorded.mu.Lock()
+	defer recorded.mu.Unlock()
+	recorded.events = append(recorded.events, event)
+}
+
+// newRequestEvent returns an event caused by the signed in user of the request
+func newRequestEvent(r *http.Request, kind pkg.EventKind, orgId, targetId string) pkg.Event {
+	userId, _ := r.Context().Value(pkg.UserIdKey).(string)
+	return pkg.NewEvent(kind, orgId, userId, targetId)
+}
+
+// EmitEvents publishes the events recorded by the wrapped handler when it succeeds. Handlers only record what
+// happened, and the reactions are subscribed to the publisher
+func EmitEvents(publisher pkg.EventPublisher) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorded := &requestEvents{}
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), eventsKey, recorded)))
+			if rec.status >= http.StatusBadRequest {
+				return
+			}
+
+			recorded.mu.Lock()
+			defer recorded.mu.Unlock()
+			for _, event := range recorded.events {
+				publisher.Publish(r.Context(), event)
+			}
+		})
+	}
+}
+
+// SubscribeReactions registers the side effects of the events on the bus
+func SubscribeReactions(bus *pkg.EventBus, permissions *pkg.CachedPermissionsVersions, textIndex *pkg.TextIndex, webhooks *pkg.WebhookDispatcher) {
+	bus.Subscribe(pkg.LogEvent)
+	bus.Subscribe(webhooks.Deliver)
+	bus.Subscribe(func(ctx context.Context, event pkg.Event) {
+		permissions.Clear(event.UserId)
+	}, pkg.EventUserJoined)
+	bus.Subscribe(func(ctx context.Context, event pkg.Event) {
+		textIndex.Invalidate(event.OrgId)
+	}, pkg.EventResourceUploaded)
+}
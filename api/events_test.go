@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+type eventCollector struct {
+	events []pkg.Event
+}
+
+func (e *eventCollector) Publish(ctx context.Context, event pkg.Event) {
+	e.events = append(e.events, event)
+}
+
+func TestEmitEventsPublishesOnSuccess(t *testing.T) {
+	for _, test := range []struct {
+		desc   string
+		status int
+		want   int
+	}{
+		{"success", http.StatusOK, 1},
+		{"client error", http.StatusBadRequest, 0},
+		{"server error", http.StatusInternalServerError, 0},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			collector := &eventCollector{}
+			handler := EmitEvents(collector)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				recordEvent(r.Context(), newRequestEvent(r, pkg.EventProjectUpdated, "org", "project"))
+				w.WriteHeader(test.status)
+			}))
+
+			req := httptest.NewRequest("PUT", "/", nil)
+			req = req.WithContext(context.WithValue(req.Context(), pkg.UserIdKey, "0000-0000"))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			testutils.AssertEqual(t, len(collector.events), test.want)
+			if test.want > 0 {
+				testutils.AssertEqual(t, collector.events[0].UserId, "0000-0000")
+				testutils.AssertEqual(t, collector.events[0].TargetId, "project")
+			}
+		})
+	}
+}
+
+func TestRecordEventWithoutEmitter(t *testing.T) {
+	recordEvent(context.Background(), pkg.NewEvent(pkg.EventUserJoined, "org", "user", "user"))
+}
+
+func TestSubmitRecordsResourceUploaded(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	store.RegisterOrganization(context.Background(), &pkg.Organization{Id: "orgId"})
+	collector := &eventCollector{}
+
+	multipartBuffer, contentType := validMultipartForm()
+	req := httptest.NewRequest("POST", "/resources", multipartBuffer)
+	req.Header.Set("Content-Type", contentType)
+	req = withAuthSession(req, "orgId")
+
+	rec := httptest.NewRecorder()
+	EmitEvents(collector)(SubmitHandler(store, nil, time.Second, 10)).ServeHTTP(rec, req)
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, len(collector.events), 1)
+	testutils.AssertEqual(t, collector.events[0].Kind, pkg.EventResourceUploaded)
+	testutils.AssertEqual(t, collector.events[0].OrgId, "orgId")
+}
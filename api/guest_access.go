@@ -0,0 +1,455 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const guestTokenAudience = "caesura-guest"
+
+// GuestClaim identifies a guest grant. The grant itself is looked up on every request, such that a revoked grant
+// stops the link from working before the token expires
+type GuestClaim struct {
+	OrgId   string `json:"org_id"`
+	GrantId string `json:"grant_id"`
+	jwt.RegisteredClaims
+}
+
+func SignedGuestToken(orgId, grantId, signSecret string, expires time.Time) (string, error) {
+	currentTime := time.Now()
+	claims := GuestClaim{
+		OrgId:   orgId,
+		GrantId: grantId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{guestTokenAudience},
+			ExpiresAt: jwt.NewNumericDate(expires),
+			IssuedAt:  jwt.NewNumericDate(currentTime),
+			NotBefore: jwt.NewNumericDate(currentTime),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(signSecret))
+}
+
+func parseGuestToken(token, signSecret string) (*GuestClaim, error) {
+	var claims GuestClaim
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		return []byte(signSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired(), jwt.WithAudience(guestTokenAudience))
+	if err != nil {
+		return nil, err
+	}
+	if claims.OrgId == "" || claims.GrantId == "" {
+		return nil, fmt.Errorf("token does not contain organization and guest access")
+	}
+	return &claims, nil
+}
+
+func guestURL(baseURL, signSecret string, grant *pkg.GuestGrant) (string, error) {
+	token, err := SignedGuestToken(grant.OrgId, grant.Id, signSecret, grant.ExpiresAt)
+	if err != nil {
+		return "", err
+	}
+	return baseURL + RouteGuest + "?token=" + url.QueryEscape(token), nil
+}
+
+type guestGrantStatus struct {
+	Id          string               `json:"id"`
+	Email       string               `json:"email"`
+	ResourceIds []string             `json:"resourceIds"`
+	ProjectId   string               `json:"projectId"`
+	Watermark   string               `json:"watermark"`
+	CreatedBy   string               `json:"createdBy"`
+	Status      pkg.GuestGrantStatus `json:"status"`
+	Expires     time.Time            `json:"expires"`
+}
+
+func newGuestGrantStatus(grant *pkg.GuestGrant, now time.Time) guestGrantStatus {
+	resourceIds := grant.ResourceIds
+	if resourceIds == nil {
+		resourceIds = []string{}
+	}
+	return guestGrantStatus{
+		Id:          grant.Id,
+		Email:       grant.Email,
+		ResourceIds: resourceIds,
+		ProjectId:   grant.ProjectId,
+		Watermark:   grant.Watermark,
+		CreatedBy:   grant.CreatedBy,
+		Status:      grant.StatusAt(now),
+		Expires:     grant.ExpiresAt,
+	}
+}
+
+type GuestGrantSender interface {
+	pkg.GuestGrantStore
+	pkg.ProjectByIdGetter
+	pkg.MetaByIdsGetter
+	pkg.OrganizationGetter
+	pkg.FeatureCounter
+}
+
+// requireGuestResources checks that the project or the resources of the grant exist, such that the guest is not
+// sent a link to an empty page
+func requireGuestResources(ctx context.Context, store GuestGrantSender, grant *pkg.GuestGrant) error {
+	if grant.ProjectId != "" {
+		_, err := store.ProjectById(ctx, grant.OrgId, grant.ProjectId)
+		return err
+	}
+	for i, lookup := range store.MetaByIds(ctx, grant.OrgId, grant.ResourceIds) {
+		if lookup.Err != nil {
+			return lookup.Err
+		}
+		if lookup.Meta.Deleted {
+			return errors.Join(pkg.ErrResourceMetadataNotFound, fmt.Errorf("resource %s is in the trash", grant.ResourceIds[i]))
+		}
+	}
+	return nil
+}
+
+// guestSetlist lists the pieces of the project shared with the guest in the order of the project. The email is
+// useful without the list, so errors are only logged
+func guestSetlist(ctx context.Context, store GuestGrantSender, grant *pkg.GuestGrant) string {
+	if grant.ProjectId == "" {
+		return ""
+	}
+	project, err := store.ProjectById(ctx, grant.OrgId, grant.ProjectId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to fetch the setlist", "error", err, "projectId", grant.ProjectId)
+		return ""
+	}
+	metaData := make([]pkg.MetaData, 0, len(project.ResourceIds))
+	for _, lookup := range store.MetaByIds(ctx, grant.OrgId, project.ResourceIds) {
+		if lookup.Err == nil && !lookup.Meta.Deleted {
+			metaData = append(metaData, *lookup.Meta)
+		}
+	}
+	return pkg.Setlist(metaData)
+}
+
+func sendGuestEmail(ctx context.Context, store GuestGrantSender, config *pkg.Config, grant *pkg.GuestGrant) error {
+	org, err := store.GetOrganization(ctx, grant.OrgId)
+	if err != nil {
+		return err
+	}
+
+	email := pkg.Email{
+		Sender:    config.EmailSender,
+		SmtpHost:  config.SmtpConfig.Host,
+		SmtpPort:  config.SmtpConfig.Port,
+		SmtpAuth:  config.SmtpConfig.Auth,
+		Recipents: []string{grant.Email},
+		SendFn:    config.SmtpConfig.SendFn,
+		Branding:  &org.Branding,
+	}
+
+	var (
+		link         string
+		emailContent *bytes.Buffer
+	)
+	err = pkg.ReturnOnFirstError(
+		func() error {
+			var err error
+			link, err = guestURL(config.BaseURL, config.CookieSecretSignKey, grant)
+			return err
+		},
+		func() error {
+			var err error
+			body := fmt.Sprintf(
+				"%s has shared scores with you. You can view and download them without an account until %s.\n\nLink: %s",
+				org.Name, grant.LastDay(), link,
+			)
+			if setlist := guestSetlist(ctx, store, grant); setlist != "" {
+				body += "\n\nPieces:\n" + setlist
+			}
+			emailContent, err = email.Build("Caesura: scores shared by "+org.Name, body, func(yield func(string, io.Reader) bool) {})
+			return err
+		},
+		func() error {
+			return email.Send(ctx, emailContent.Bytes())
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := store.CountFeature(ctx, grant.OrgId, pkg.FeatureEmailSent, time.Now()); err != nil {
+		slog.ErrorContext(ctx, "Could not count feature usage", "feature", pkg.FeatureEmailSent, "error", err)
+	}
+	return nil
+}
+
+// CreateGuestGrantHandler gives an email address outside the organization access to a project or some resources
+// until the end of the date in expires, and emails the signed link to the guest
+func CreateGuestGrantHandler(store GuestGrantSender, config *pkg.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 16384)
+		code, err := parseForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		emailAddr := strings.TrimSpace(r.FormValue("email"))
+		if !validEmail(emailAddr) {
+			http.Error(w, "Invalid email address", http.StatusBadRequest)
+			return
+		}
+
+		// The guest has access throughout the last day
+		lastDay, err := time.Parse(time.DateOnly, r.FormValue("expires"))
+		if err != nil {
+			http.Error(w, "expires must be a date like 2006-01-02", http.StatusBadRequest)
+			return
+		}
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		userId, _ := r.Context().Value(pkg.UserIdKey).(string)
+		grant := pkg.NewGuestGrant(orgId, userId, emailAddr, r.Form["resourceId"], r.FormValue("projectId"), lastDay.AddDate(0, 0, 1), r.FormValue("watermark"))
+		if err := grant.Validate(); err != nil {
+			http.Error(w, err.Error(), StoreErrorCode(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), config.Timeout)
+		defer cancel()
+
+		if err := requireGuestResources(ctx, store, grant); err != nil {
+			http.Error(w, "Could not find the scores to share", StoreErrorCode(err))
+			slog.InfoContext(ctx, "Guest access to unknown scores", "error", err)
+			return
+		}
+
+		if err := store.SaveGuestGrant(ctx, grant); err != nil {
+			http.Error(w, "Could not save guest access", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not save guest access", "error", err)
+			return
+		}
+
+		if err := sendGuestEmail(ctx, store, config, grant); err != nil {
+			http.Error(w, "Could not send guest access", http.StatusInternalServerError)
+			slog.ErrorContext(ctx, "Could not send guest access", "error", err, "grantId", grant.Id)
+			return
+		}
+
+		slog.InfoContext(ctx, "Gave guest access", "grantId", grant.Id, "projectId", grant.ProjectId, "numPieces", len(grant.ResourceIds), "expires", grant.ExpiresAt)
+		HxTrigger(w, EventGuestAccessUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.guest-access-created", map[string]string{"Email": grant.Email})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(newGuestGrantStatus(grant, time.Now())); err != nil {
+			slog.ErrorContext(ctx, "Failed to encode guest access", "error", err)
+		}
+	}
+}
+
+// GuestGrantsHandler lists the guest access of the organization with their status. The optional query parameter
+// projectId limits the list to the guests of a project
+func GuestGrantsHandler(store pkg.GuestGrantStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		grants, err := store.GuestGrants(ctx, orgId)
+		if err != nil {
+			http.Error(w, "Could not fetch guest access", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch guest access", "error", err)
+			return
+		}
+
+		if projectId := r.URL.Query().Get("projectId"); projectId != "" {
+			grants = slices.DeleteFunc(grants, func(g pkg.GuestGrant) bool { return g.ProjectId != projectId })
+		}
+
+		now := time.Now()
+		result := make([]guestGrantStatus, len(grants))
+		for i := range grants {
+			result[i] = newGuestGrantStatus(&grants[i], now)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			slog.ErrorContext(ctx, "Failed to encode guest access", "error", err)
+		}
+	}
+}
+
+// RevokeGuestGrantHandler stops the link of the guest from working
+func RevokeGuestGrantHandler(store pkg.GuestGrantStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		userId, _ := r.Context().Value(pkg.UserIdKey).(string)
+		id := r.PathValue("id")
+		if _, err := pkg.RevokeGuestGrant(ctx, store, orgId, id, userId, time.Now()); err != nil {
+			http.Error(w, "Could not revoke guest access", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not revoke guest access", "error", err, "grantId", id)
+			return
+		}
+
+		slog.InfoContext(ctx, "Revoked guest access", "grantId", id)
+		HxTrigger(w, EventGuestAccessUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.guest-access-revoked", nil)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+type GuestStore interface {
+	pkg.GuestGrantStore
+	pkg.ProjectByIdGetter
+	pkg.MetaByIdsGetter
+	pkg.PartLister
+	pkg.TieredResourceGetter
+	pkg.FeatureCounter
+	pkg.AuditLogger
+}
+
+// guestAuditEntry records what a guest did. The guest is not a user, so the grant is the actor
+func guestAuditEntry(r *http.Request, action pkg.AuditAction, grant *pkg.GuestGrant, targetId, detail string) *pkg.AuditEntry {
+	return pkg.NewAuditEntry(action, grant.Id, targetId, strings.TrimSpace("email="+grant.Email+" "+detail), getIp(r))
+}
+
+// activeGuest returns the grant of the token in the request. Invalid tokens and grants that are revoked or have
+// expired are rejected with the same response, such that the guest is not told why
+func activeGuest(ctx context.Context, w http.ResponseWriter, r *http.Request, store pkg.GuestGrantStore, signSecret string) (*pkg.GuestGrant, bool) {
+	claims, err := parseGuestToken(r.URL.Query().Get("token"), signSecret)
+	if err == nil {
+		var grant *pkg.GuestGrant
+		grant, err = pkg.ActiveGuestGrant(ctx, store, claims.OrgId, claims.GrantId, time.Now())
+		if err == nil {
+			return grant, true
+		}
+	}
+	if pkg.IsNotFound(err) || errors.Is(err, pkg.ErrGuestGrantInactive) || claims == nil {
+		http.Error(w, "The link is invalid or has expired", http.StatusUnauthorized)
+		slog.InfoContext(ctx, "Invalid guest token", "error", err)
+		return nil, false
+	}
+	http.Error(w, "Could not fetch guest access", StoreErrorCode(err))
+	slog.ErrorContext(ctx, "Could not fetch guest access", "error", err)
+	return nil, false
+}
+
+// GuestPageHandler lists the scores shared with the guest holding the token, with a download link to every part
+func GuestPageHandler(store GuestStore, signSecret string, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		grant, ok := activeGuest(ctx, w, r, store, signSecret)
+		if !ok {
+			return
+		}
+
+		var parts map[string][]string
+		resourceIds, err := pkg.GuestResourceIds(ctx, store, grant)
+		if err == nil {
+			parts, err = store.PartNames(ctx, grant.OrgId)
+		}
+		if err != nil {
+			http.Error(w, "Could not fetch the shared scores", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch the shared scores", "error", err, "grantId", grant.Id)
+			return
+		}
+
+		data := web.GuestPageData{Token: r.URL.Query().Get("token"), LastDay: grant.LastDay()}
+		for _, lookup := range store.MetaByIds(ctx, grant.OrgId, resourceIds) {
+			if lookup.Err != nil || lookup.Meta.Deleted {
+				continue
+			}
+			files := slices.Sorted(slices.Values(parts[lookup.Meta.ResourceId()]))
+			data.Resources = append(data.Resources, web.GuestResource{Meta: *lookup.Meta, Files: files})
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "private, no-store")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		web.GuestPage(w, pkg.LanguageFromReq(r), data)
+		appendAudit(ctx, store, grant.OrgId, guestAuditEntry(r, pkg.AuditGuestViewed, grant, grant.Id, ""))
+	}
+}
+
+// GuestPartHandler serves a part of a resource shared with the guest holding the token. The watermark of the
+// grant is stamped on every page
+func GuestPartHandler(store GuestStore, signSecret string, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		grant, ok := activeGuest(ctx, w, r, store, signSecret)
+		if !ok {
+			return
+		}
+
+		query := r.URL.Query()
+		resourceId, filename := query.Get("resourceId"), query.Get("file")
+		resourceIds, err := pkg.GuestResourceIds(ctx, store, grant)
+		if err != nil {
+			http.Error(w, "Could not fetch the shared scores", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch the shared scores", "error", err, "grantId", grant.Id)
+			return
+		}
+		if !slices.Contains(resourceIds, resourceId) {
+			http.Error(w, "The score is not shared with you", http.StatusForbidden)
+			return
+		}
+
+		meta, err := store.MetaById(ctx, grant.OrgId, resourceId)
+		if err == nil && meta.Deleted {
+			err = pkg.ErrResourceMetadataNotFound
+		}
+		if err != nil {
+			http.Error(w, "File not found", StoreErrorCode(err))
+			return
+		}
+
+		downloader := pkg.NewResourceDownloader().
+			GetMetaData(ctx, store, grant.OrgId, resourceId).
+			Rehydrate(ctx, store, grant.OrgId).
+			GetResource(ctx, store, grant.OrgId).
+			Stamp(grant.Watermark)
+		if err := downloader.Error; err != nil {
+			http.Error(w, err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Error during guest download", "error", err, "id", resourceId)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+		w.Header().Set("Cache-Control", "private, no-store")
+		if err := downloader.ExtractSingleFile(filename, w).Error; err != nil {
+			if pkg.IsNotFound(err) {
+				w.Header().Del("Content-Disposition")
+				http.Error(w, "File not found", http.StatusNotFound)
+			}
+			slog.ErrorContext(ctx, "Error during guest download", "error", err, "id", resourceId, "file", filename)
+			return
+		}
+
+		now := time.Now()
+		appendAudit(ctx, store, grant.OrgId, guestAuditEntry(r, pkg.AuditGuestDownloaded, grant, resourceId, "file="+filename))
+		if err := store.RecordAccess(ctx, grant.OrgId, resourceId, now); err != nil {
+			slog.ErrorContext(ctx, "Failed to record access", "error", err, "id", resourceId)
+		}
+		if err := store.CountFeature(ctx, grant.OrgId, pkg.FeatureDownload, now); err != nil {
+			slog.ErrorContext(ctx, "Could not count feature usage", "feature", pkg.FeatureDownload, "error", err)
+		}
+		slog.InfoContext(ctx, "Guest downloaded part", "orgId", grant.OrgId, "grantId", grant.Id, "id", resourceId, "file", filename)
+	}
+}
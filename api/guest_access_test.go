@@ -0,0 +1,179 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	pdfapi "github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+var guestTokenPattern = regexp.MustCompile(`/guest\?token=(\S+)`)
+
+func guestAccessMux(store *pkg.MultiOrgInMemoryStore, config *pkg.Config) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("POST "+RouteGuestAccess, CreateGuestGrantHandler(store, config))
+	mux.Handle("GET "+RouteGuestAccess, GuestGrantsHandler(store, config.Timeout))
+	mux.Handle("DELETE "+RouteGuestAccessId, RevokeGuestGrantHandler(store, config.Timeout))
+	mux.Handle("GET "+RouteGuest, GuestPageHandler(store, config.CookieSecretSignKey, config.Timeout))
+	mux.Handle("GET "+RouteGuestPart, GuestPartHandler(store, config.CookieSecretSignKey, config.Timeout))
+	return mux
+}
+
+func guestAccessRequest(orgId string, form url.Values) *http.Request {
+	req := httptest.NewRequest("POST", RouteGuestAccess, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return withAuthSession(req, orgId)
+}
+
+func TestGuestAccess(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	ctx := context.Background()
+	config := pkg.NewDefaultConfig()
+	config.CookieSecretSignKey = "secret"
+	var msg string
+	config.SmtpConfig.SendFn = func(addr string, auth smtp.Auth, sender string, to []string, m []byte) error {
+		// Undo the soft line breaks and escapes of the quoted-printable body
+		msg = strings.NewReplacer("=\r\n", "", "=3D", "=").Replace(string(m))
+		testutils.AssertEqual(t, to[0], "jury@example.com")
+		return nil
+	}
+	mux := guestAccessMux(store, config)
+
+	meta := store.Data[orgId].Metadata[0]
+	project := pkg.Project{Name: "Festival", ResourceIds: []string{meta.ResourceId()}}
+	testutils.AssertNil(t, store.SubmitProject(ctx, orgId, &project))
+
+	lastDay := time.Now().AddDate(0, 0, 14).Format(time.DateOnly)
+	form := url.Values{"email": {"Jury@example.com"}, "projectId": {project.Id()}, "expires": {lastDay}, "watermark": {"Jury copy"}}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, guestAccessRequest(orgId, form))
+	testutils.AssertEqual(t, rec.Code, http.StatusCreated)
+	testutils.AssertContains(t, msg, "until "+lastDay, "/guest?token=", "1. "+meta.Title)
+	testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), string(EventGuestAccessUpdated))
+
+	var created guestGrantStatus
+	testutils.AssertNil(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	testutils.AssertEqual(t, created.Status, pkg.GuestGrantActive)
+	testutils.AssertEqual(t, created.Email, "jury@example.com")
+
+	match := guestTokenPattern.FindStringSubmatch(msg)
+	testutils.AssertEqual(t, len(match), 2)
+	token, err := url.QueryUnescape(match[1])
+	testutils.AssertNil(t, err)
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+	partURL := func(resourceId, file string) string {
+		return RouteGuestPart + "?" + url.Values{"token": {token}, "resourceId": {resourceId}, "file": {file}}.Encode()
+	}
+	files := pkg.NewResourceDownloader().GetMetaData(ctx, store, orgId, meta.ResourceId()).GetResource(ctx, store, orgId).Filenames()
+
+	t.Run("guest sees the pieces of the project", func(t *testing.T) {
+		rec := serve(RouteGuest + "?token=" + url.QueryEscape(token))
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+		testutils.AssertContains(t, rec.Body.String(), meta.Title, "/guest/part?token=", lastDay)
+		testutils.AssertNotContains(t, rec.Body.String(), store.Data[orgId].Metadata[1].Title)
+	})
+
+	t.Run("downloaded parts are watermarked", func(t *testing.T) {
+		rec := serve(partURL(meta.ResourceId(), files[0]))
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+		hasStamp, err := pdfapi.HasWatermarks(bytes.NewReader(rec.Body.Bytes()), model.NewDefaultConfiguration())
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, hasStamp, true)
+	})
+
+	t.Run("pieces outside the project are refused", func(t *testing.T) {
+		rec := serve(partURL(store.Data[orgId].Metadata[1].ResourceId(), files[0]))
+		testutils.AssertEqual(t, rec.Code, http.StatusForbidden)
+	})
+
+	t.Run("views and downloads are audited", func(t *testing.T) {
+		entries, err := store.AuditLog(ctx, orgId, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+		testutils.AssertNil(t, err)
+		actions := make(map[pkg.AuditAction]pkg.AuditEntry)
+		for _, entry := range entries {
+			actions[entry.Action] = entry
+		}
+		testutils.AssertEqual(t, actions[pkg.AuditGuestViewed].ActorId, created.Id)
+		testutils.AssertEqual(t, actions[pkg.AuditGuestDownloaded].TargetId, meta.ResourceId())
+		testutils.AssertContains(t, actions[pkg.AuditGuestDownloaded].Detail, "email=jury@example.com", "file="+files[0])
+	})
+
+	t.Run("list guest access of the project", func(t *testing.T) {
+		var grants []guestGrantStatus
+		for projectId, num := range map[string]int{project.Id(): 1, "other": 0} {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, withAuthSession(httptest.NewRequest("GET", RouteGuestAccess+"?projectId="+projectId, nil), orgId))
+			testutils.AssertEqual(t, rec.Code, http.StatusOK)
+			testutils.AssertNil(t, json.Unmarshal(rec.Body.Bytes(), &grants))
+			testutils.AssertEqual(t, len(grants), num)
+		}
+	})
+
+	t.Run("invalid tokens are rejected", func(t *testing.T) {
+		expired, err := SignedGuestToken(orgId, created.Id, config.CookieSecretSignKey, time.Now().Add(-time.Minute))
+		testutils.AssertNil(t, err)
+		unknown, err := SignedGuestToken(orgId, "unknown", config.CookieSecretSignKey, time.Now().Add(time.Hour))
+		testutils.AssertNil(t, err)
+		for _, token := range []string{expired, unknown, "not-a-token"} {
+			testutils.AssertEqual(t, serve(RouteGuest+"?token="+url.QueryEscape(token)).Code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("revoked link stops working", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, withAuthSession(httptest.NewRequest("DELETE", "/guest-access/"+created.Id, nil), orgId))
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+		testutils.AssertEqual(t, serve(RouteGuest+"?token="+url.QueryEscape(token)).Code, http.StatusUnauthorized)
+		testutils.AssertEqual(t, serve(partURL(meta.ResourceId(), files[0])).Code, http.StatusUnauthorized)
+	})
+}
+
+func TestCreateGuestGrantRejectsInvalidForms(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	config := pkg.NewDefaultConfig()
+	config.SmtpConfig.SendFn = func(addr string, auth smtp.Auth, sender string, to []string, m []byte) error {
+		t.Fatal("No email should be sent")
+		return nil
+	}
+	mux := guestAccessMux(store, config)
+
+	nextWeek := time.Now().AddDate(0, 0, 7).Format(time.DateOnly)
+	resourceId := store.Data[orgId].Metadata[0].ResourceId()
+	for _, test := range []struct {
+		desc string
+		form url.Values
+		code int
+	}{
+		{"invalid email", url.Values{"email": {"jury"}, "resourceId": {resourceId}, "expires": {nextWeek}}, http.StatusBadRequest},
+		{"invalid date", url.Values{"email": {"jury@example.com"}, "resourceId": {resourceId}, "expires": {"next week"}}, http.StatusBadRequest},
+		{"too far ahead", url.Values{"email": {"jury@example.com"}, "resourceId": {resourceId}, "expires": {time.Now().AddDate(1, 0, 0).Format(time.DateOnly)}}, http.StatusBadRequest},
+		{"nothing shared", url.Values{"email": {"jury@example.com"}, "expires": {nextWeek}}, http.StatusBadRequest},
+		{"unknown resource", url.Values{"email": {"jury@example.com"}, "resourceId": {"unknown"}, "expires": {nextWeek}}, http.StatusNotFound},
+		{"unknown project", url.Values{"email": {"jury@example.com"}, "projectId": {"unknown"}, "expires": {nextWeek}}, http.StatusNotFound},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, guestAccessRequest(orgId, test.form))
+			testutils.AssertEqual(t, rec.Code, test.code)
+		})
+	}
+}
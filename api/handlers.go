@@ -3,7 +3,6 @@ package api
 import (
 	"archive/zip"
 	"bytes"
-	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
@@ -11,12 +10,14 @@ import (
 	"html/template"
 	"io"
 	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/davidkleiven/caesura/pkg"
 	"github.com/davidkleiven/caesura/web"
@@ -33,12 +34,13 @@ const (
 	OAuthState         = "oauth_state"
 	resetPasswordToken = "resetEmailToken"
 	FileTimeFormat     = "20060102-150405"
+	rehearsalNotesFile = "rehearsal-notes.txt"
 )
 
 type ctxKey string
 
 const sessionKey ctxKey = "session"
-const googleUserInfo = "https://www.googleapis.com/oauth2/v2/userinfo"
+const googleUserInfo = pkg.GoogleUserInfoURL
 const googleToken = "https://oauth2.googleapis.com/token"
 
 func UploadHandler(w http.ResponseWriter, r *http.Request) {
@@ -49,7 +51,17 @@ func UploadHandler(w http.ResponseWriter, r *http.Request) {
 
 func InstrumentSearchHandler(w http.ResponseWriter, r *http.Request) {
 	token := r.URL.Query().Get("token")
-	instruments := pkg.FilterList(allInstruments(), token)
+	candidates := instrumentsOf(InstrumentFamilies(r))
+	if presetId := r.URL.Query().Get("preset"); presetId != "" {
+		instrumentation := pkg.DefaultInstrumentation()
+		preset, ok := instrumentation.Preset(presetId)
+		if !ok {
+			http.Error(w, fmt.Sprintf("Unknown instrumentation preset %q", presetId), http.StatusBadRequest)
+			return
+		}
+		candidates = instrumentation.PresetInstruments(preset)
+	}
+	instruments := pkg.FilterList(candidates, token)
 	format := r.URL.Query().Get("format")
 
 	if format == "options" {
@@ -90,84 +102,138 @@ func DeleteMode(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func SubmitHandler(submitter pkg.Submitter, timeout time.Duration, maxSize int) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		maxUploadSize := int64(maxSize) << 20
+// documentFromForm parses the multipart form of an upload and returns the uploaded document. The error
+// response is written when the form can not be parsed
+func documentFromForm(w http.ResponseWriter, r *http.Request, maxSize int) (multipart.File, bool) {
+	maxUploadSize := int64(maxSize) << 20
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	err := r.ParseMultipartForm(maxUploadSize)
+
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		msg := fmt.Sprintf("File is larger than max allowed size (~%d MB).", maxSize)
+		http.Error(w, msg, http.StatusRequestEntityTooLarge)
+		return nil, false
+	} else if err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		slog.ErrorContext(r.Context(), "Failed to parse form", "error", err)
+		return nil, false
+	}
 
-		r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
-		err := r.ParseMultipartForm(maxUploadSize)
+	file, _, err := r.FormFile("document")
+	if err != nil {
+		http.Error(w, "Failed to retrieve file from form", http.StatusBadRequest)
+		slog.ErrorContext(r.Context(), "Failed to retrieve file from form", "error", err)
+		return nil, false
+	}
+	return file, true
+}
 
-		var maxErr *http.MaxBytesError
-		if errors.As(err, &maxErr) {
-			msg := fmt.Sprintf("File is larger than max allowed size (~%d MB).", maxSize)
-			http.Error(w, msg, http.StatusRequestEntityTooLarge)
-			return
-		} else if err != nil {
-			http.Error(w, "Failed to parse form", http.StatusBadRequest)
-			slog.ErrorContext(r.Context(), "Failed to parse form", "error", err)
+// SubmitHandler splits the score into parts and stores them. The progress is reported to clients following the
+// upload id in the upload-id form value, when it is given
+func SubmitHandler(submitter pkg.Submitter, tracker *pkg.SubmitTracker, timeout time.Duration, maxSize int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		file, ok := documentFromForm(w, r, maxSize)
+		if !ok {
 			return
 		}
+		defer file.Close()
 
-		file, _, err := r.FormFile("document")
+		metaData, assignments, err := parseSubmission(r.MultipartForm.Value)
 		if err != nil {
-			http.Error(w, "Failed to retrieve file from form", http.StatusBadRequest)
-			slog.ErrorContext(r.Context(), "Failed to retrieve file from form", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			slog.ErrorContext(r.Context(), "Invalid submission", "error", err)
 			return
 		}
-		defer file.Close()
 
-		var assignments []pkg.Assignment
-		raw := r.MultipartForm.Value["assignments"]
-		if len(raw) == 0 {
-			http.Error(w, "No assignments provided", http.StatusBadRequest)
-			slog.ErrorContext(r.Context(), "No assignments provided")
-			return
-		}
-		if err := json.Unmarshal([]byte(raw[0]), &assignments); err != nil {
-			http.Error(w, "Failed to parse assignments", http.StatusBadRequest)
-			slog.ErrorContext(r.Context(), "Failed to parse assignments", "error", err)
-			return
+		var reporter *pkg.SubmitReporter
+		if uploadId := r.FormValue("upload-id"); uploadId != "" {
+			orgId := MustGetOrgId(MustGetSession(r))
+			if reporter, err = tracker.Start(orgId, uploadId, len(assignments)); err != nil {
+				http.Error(w, "Could not follow the progress of the upload: "+err.Error(), StoreErrorCode(err))
+				return
+			}
 		}
+		submitScore(w, r, submitter, reporter, timeout, file, metaData, assignments)
+	}
+}
 
-		var metaData pkg.MetaData
-		rawMeta := r.MultipartForm.Value["metadata"]
-
-		if len(rawMeta) == 0 {
-			http.Error(w, "No metadata provided", http.StatusBadRequest)
-			slog.ErrorContext(r.Context(), "No metadata provided")
-			return
+// parseSubmission reads the metadata and the assignments of the pages from the form values of a submission
+func parseSubmission(values map[string][]string) (pkg.MetaData, []pkg.Assignment, error) {
+	var metaData pkg.MetaData
+	var assignments []pkg.Assignment
+	raw := values["assignments"]
+	if len(raw) == 0 {
+		return metaData, nil, errors.New("No assignments provided")
+	}
+	if err := json.Unmarshal([]byte(raw[0]), &assignments); err != nil {
+		return metaData, nil, errors.New("Failed to parse assignments")
+	}
+	for _, assignment := range assignments {
+		if _, err := pkg.PartName(assignment.Id); err != nil {
+			return metaData, nil, fmt.Errorf("Invalid part name %q", assignment.Id)
 		}
+	}
 
-		if err := json.Unmarshal([]byte(rawMeta[0]), &metaData); err != nil {
-			msg := "Failed to parse metadata (often related to the duration input). Check that the input confirms the format 3m20s"
-			http.Error(w, msg, http.StatusBadRequest)
-			slog.ErrorContext(r.Context(), "Failed to parse metadata", "error", err)
-			return
-		}
+	rawMeta := values["metadata"]
+	if len(rawMeta) == 0 {
+		return metaData, nil, errors.New("No metadata provided")
+	}
+	if err := json.Unmarshal([]byte(rawMeta[0]), &metaData); err != nil {
+		return metaData, nil, errors.New("Failed to parse metadata (often related to the duration input). Check that the input confirms the format 3m20s")
+	}
 
-		resourceId := metaData.ResourceId()
-		if resourceId == "" {
-			http.Error(w, "Filename is empty. Note that only alphanumeric characters are allowed", http.StatusBadRequest)
-			slog.ErrorContext(r.Context(), "Filename cannot be empty.", "title", metaData.Title, "composer", metaData.Composer, "arranger", metaData.Arranger)
-			return
-		}
+	if metaData.ResourceId() == "" {
+		return metaData, nil, errors.New("Filename is empty. Note that only alphanumeric characters are allowed")
+	}
 
-		pdfIter := pkg.SplitPdf(file, assignments)
-		ctx, cancel := context.WithTimeout(r.Context(), timeout)
-		defer cancel()
+	// Protection can only be changed by admins through a dedicated endpoint
+	metaData.Protected = false
+	return metaData, assignments, nil
+}
 
-		orgId := MustGetOrgId(MustGetSession(r))
-		if err := submitter.Submit(ctx, orgId, &metaData, pdfIter); err != nil {
-			http.Error(w, "Failed to store file", http.StatusInternalServerError)
-			slog.ErrorContext(ctx, "Failed to store file", "error", err)
-			return
-		}
-		slog.InfoContext(ctx, "File stored successfully", "filename", resourceId, "resourceId", resourceId)
-		w.Write([]byte("File uploaded successfully!"))
+// submitScore splits the score into parts and stores them. It reports whether the score was stored. The reporter
+// may be nil when nobody follows the progress
+func submitScore(w http.ResponseWriter, r *http.Request, submitter pkg.Submitter, reporter *pkg.SubmitReporter, timeout time.Duration, file io.ReadSeeker, metaData pkg.MetaData, assignments []pkg.Assignment) bool {
+	pdfIter := reporter.Parts(pkg.SplitPdf(file, assignments))
+	ctx, cancel := context.WithTimeout(pkg.WithSubmitReporter(r.Context(), reporter), timeout)
+	defer cancel()
+
+	resourceId := metaData.ResourceId()
+	orgId := MustGetOrgId(MustGetSession(r))
+	err := submitter.Submit(ctx, orgId, &metaData, pdfIter)
+	reporter.Finish(err)
+	if errors.Is(err, pkg.ErrResourceProtected) {
+		http.Error(w, "Resource is protected and can not be replaced", http.StatusConflict)
+		slog.InfoContext(ctx, "Attempted to replace protected resource", "resourceId", resourceId)
+		return false
+	} else if err != nil {
+		http.Error(w, "Failed to store file", http.StatusInternalServerError)
+		slog.ErrorContext(ctx, "Failed to store file", "error", err)
+		return false
 	}
+	slog.InfoContext(ctx, "File stored successfully", "filename", resourceId, "resourceId", resourceId)
+	recordEvent(ctx, newRequestEvent(r, pkg.EventResourceUploaded, orgId, resourceId))
+	HxTrigger(w, EventResourceUploaded, map[string]string{"resourceId": resourceId})
+	HxFlash(w, r, FlashSuccess, "flash.file-uploaded", nil)
+	w.WriteHeader(http.StatusOK)
+	return true
+}
+
+// TextSearcher returns the ids of the resources with parts containing the words of the query
+type TextSearcher interface {
+	Search(ctx context.Context, orgId string, query string) ([]string, error)
 }
 
-func OverviewSearchHandler(fetcher pkg.MetaByPatternFetcher, timeout time.Duration) http.HandlerFunc {
+// OverviewFetcher lists the resources of the overview one page at a time. Searching inside the scores needs all
+// matches, since the resources found by the text index are merged with the metadata matches
+type OverviewFetcher interface {
+	pkg.MetaByPatternFetcher
+	pkg.MetaByPatternPager
+}
+
+func OverviewSearchHandler(fetcher OverviewFetcher, index TextSearcher, timeout time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		filterValue := r.URL.Query().Get("resource-filter")
 		pattern := &pkg.MetaData{
@@ -176,20 +242,106 @@ func OverviewSearchHandler(fetcher pkg.MetaByPatternFetcher, timeout time.Durati
 			Arranger: filterValue,
 		}
 
+		sort, err := pkg.ParseMetaSort(r.URL.Query().Get("sort"), r.URL.Query().Get("direction"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(r.Context(), timeout)
 		defer cancel()
 
 		orgId := MustGetOrgId(MustGetSession(r))
-		meta, err := fetcher.MetaByPattern(ctx, orgId, pattern)
+		var page pkg.CursorPage[pkg.MetaData]
+		if r.URL.Query().Get("search-inside") == "true" && filterValue != "" {
+			var meta []pkg.MetaData
+			meta, err = fetcher.MetaByPattern(ctx, orgId, pattern)
+			if err == nil {
+				meta, err = withTextMatches(ctx, fetcher, index, orgId, filterValue, meta)
+			}
+			if err == nil {
+				page, err = pkg.PageMetaData(meta, sort, pageRequest(r))
+			}
+		} else {
+			page, err = fetcher.MetaByPatternPage(ctx, orgId, pattern, sort, pageRequest(r))
+		}
 		if err != nil {
-			http.Error(w, "Failed to fetch metadata", http.StatusInternalServerError)
+			http.Error(w, "Failed to fetch metadata", StoreErrorCode(err))
 			slog.ErrorContext(ctx, "Failed to fetch metadata", "error", err)
 			return
 		}
-		meta = slices.DeleteFunc(meta, func(m pkg.MetaData) bool { return m.Deleted })
+
+		meta := slices.DeleteFunc(page.Items, func(m pkg.MetaData) bool { return m.Deleted })
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		web.ResourceList(w, meta)
+		writeLoadMore(w, r, page.NextCursor, 7)
+	}
+}
+
+const (
+	defaultNumSuggestions = 8
+	maxNumSuggestions     = 20
+)
+
+// ResourceSuggestHandler returns the id, title and composer of the best matches of q. It is called on every
+// keystroke of the search box, so it returns far less than OverviewSearchHandler. Clients asking for JSON get a
+// list of suggestions, and others get the options of a datalist
+func ResourceSuggestHandler(fetcher pkg.MetaByPatternFetcher, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
+		limit := defaultNumSuggestions
+		if value, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && value > 0 {
+			limit = min(value, maxNumSuggestions)
+		}
+
+		suggestions := []pkg.Suggestion{}
+		if query != "" {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			orgId := MustGetOrgId(MustGetSession(r))
+			meta, err := fetcher.MetaByPattern(ctx, orgId, &pkg.MetaData{Title: query, Composer: query, Arranger: query})
+			if err != nil {
+				http.Error(w, "Failed to fetch suggestions", StoreErrorCode(err))
+				slog.ErrorContext(ctx, "Failed to fetch suggestions", "error", err)
+				return
+			}
+			suggestions = pkg.Suggest(meta, query, limit)
+		}
+
+		w.Header().Set("Cache-Control", "private, max-age=30")
+		w.Header().Set("Vary", "Accept")
+		if strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(suggestions)
+			return
+		}
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		web.ResourceSuggestions(w, suggestions)
+	}
+}
+
+// withTextMatches adds the resources with parts containing the query to the resources matching the metadata
+func withTextMatches(ctx context.Context, fetcher pkg.MetaByPatternFetcher, index TextSearcher, orgId, query string, meta []pkg.MetaData) ([]pkg.MetaData, error) {
+	ids, err := index.Search(ctx, orgId, query)
+	if err != nil || len(ids) == 0 {
+		return meta, err
+	}
+
+	all, err := fetcher.MetaByPattern(ctx, orgId, &pkg.MetaData{})
+	if err != nil {
+		return meta, err
 	}
+	found := make(map[string]bool)
+	for _, m := range meta {
+		found[m.ResourceId()] = true
+	}
+	for _, m := range all {
+		if !found[m.ResourceId()] && slices.Contains(ids, m.ResourceId()) {
+			meta = append(meta, m)
+		}
+	}
+	return meta, nil
 }
 
 func OverviewHandler(w http.ResponseWriter, r *http.Request) {
@@ -243,7 +395,15 @@ func ProjectQueryInputHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 }
 
-func ProjectSubmitHandler(submitter pkg.ProjectSubmitter, timeout time.Duration) http.HandlerFunc {
+type ProjectSubmitStore interface {
+	pkg.Transactor
+	pkg.ProjectTemplateGetter
+}
+
+// ProjectSubmitHandler adds the pieces to the project in a single transaction, such that concurrent additions
+// to the same project are not lost. New projects get the defaults of the template given by templateId, while
+// existing projects keep their own
+func ProjectSubmitHandler(store ProjectSubmitStore, timeout time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := r.ParseForm(); err != nil {
 			http.Error(w, "Failed to parse form", http.StatusBadRequest)
@@ -270,13 +430,26 @@ func ProjectSubmitHandler(submitter pkg.ProjectSubmitter, timeout time.Duration)
 		defer cancel()
 
 		orgId := MustGetOrgId(MustGetSession(r))
-		if err := submitter.SubmitProject(ctx, orgId, project); err != nil {
-			http.Error(w, "Failed to submit project", http.StatusInternalServerError)
+		if templateId := r.FormValue("templateId"); templateId != "" {
+			template, err := store.ProjectTemplateById(ctx, orgId, templateId)
+			if err != nil {
+				http.Error(w, "Failed to fetch project template", StoreErrorCode(err))
+				slog.ErrorContext(ctx, "Failed to fetch project template", "error", err, "templateId", templateId)
+				return
+			}
+			template.Apply(project)
+		}
+
+		if err := pkg.AddToProject(ctx, store, orgId, project); err != nil {
+			http.Error(w, "Failed to submit project", StoreErrorCode(err))
 			slog.ErrorContext(r.Context(), "Failed to submit project", "error", err)
 			return
 		}
 		slog.InfoContext(ctx, "Project submitted successfully", "project_name", projectName, "num_resources", len(resourceIds))
-		w.Write(fmt.Appendf(nil, "Added %d piece(s) to '%s'", len(resourceIds), projectName))
+		recordEvent(ctx, newRequestEvent(r, pkg.EventProjectUpdated, orgId, project.Id()))
+		HxTrigger(w, EventProjectUpdated, map[string]string{"projectId": project.Id()})
+		HxFlash(w, r, FlashSuccess, "flash.project-updated", map[string]any{"Count": len(resourceIds), "Project": projectName})
+		w.WriteHeader(http.StatusOK)
 	}
 }
 
@@ -290,16 +463,49 @@ func RemoveFromProject(remover pkg.ProjectResourceRemover, timeout time.Duration
 
 		orgId := MustGetOrgId(MustGetSession(r))
 		if err := remover.RemoveResource(ctx, orgId, projectId, resourceId); err != nil {
-			http.Error(w, "failed to remove resource", http.StatusInternalServerError)
-			slog.ErrorContext(ctx, "Failed to remove resource", "projectId", projectId, "resourceId", resourceId)
+			http.Error(w, "failed to remove resource", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Failed to remove resource", "error", err, "projectId", projectId, "resourceId", resourceId)
 			return
 		}
+		recordEvent(ctx, newRequestEvent(r, pkg.EventProjectUpdated, orgId, projectId))
 
-		w.Header().Set("Content-Type", "text/plain")
+		HxTrigger(w, EventProjectUpdated, map[string]string{"projectId": projectId})
+		HxFlash(w, r, FlashSuccess, "flash.resource-removed", nil)
 		w.WriteHeader(http.StatusOK)
+	}
+}
+
+const maxProjectNoteLength = 4000
+
+func ProjectNoteHandler(store pkg.ProjectNoteSetter, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 4*maxProjectNoteLength)
+		if code, err := parseForm(r); err != nil {
+			http.Error(w, "Failed to parse form: "+err.Error(), code)
+			return
+		}
+
+		note := strings.TrimSpace(r.FormValue("note"))
+		if utf8.RuneCountInString(note) > maxProjectNoteLength {
+			http.Error(w, fmt.Sprintf("Notes can not be longer than %d characters", maxProjectNoteLength), http.StatusBadRequest)
+			return
+		}
+
+		projectId := r.PathValue("projectId")
+		resourceId := r.PathValue("resourceId")
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
 
-		msg := fmt.Sprintf("Successfully deleted item %s from project %s", resourceId, projectId)
-		w.Write([]byte(msg))
+		orgId := MustGetOrgId(MustGetSession(r))
+		if err := store.SetProjectNote(ctx, orgId, projectId, resourceId, note); err != nil {
+			http.Error(w, "Failed to save notes", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Failed to save notes", "error", err, "projectId", projectId, "resourceId", resourceId)
+			return
+		}
+		recordEvent(ctx, newRequestEvent(r, pkg.EventProjectUpdated, orgId, projectId))
+		HxFlash(w, r, FlashSuccess, "flash.notes-saved", nil)
+		w.WriteHeader(http.StatusOK)
 	}
 }
 
@@ -337,23 +543,27 @@ func ProjectByIdHandler(store pkg.ProjectMetaByIdGetter, timeout time.Duration)
 		orgId := MustGetOrgId(MustGetSession(r))
 		project, err := store.ProjectById(ctx, orgId, projectId)
 		if err != nil {
-			http.Error(w, "Failed to fetch project", http.StatusInternalServerError)
+			http.Error(w, "Failed to fetch project", StoreErrorCode(err))
 			slog.ErrorContext(ctx, "Failed to fetch project", "error", err)
 			return
 		}
 
 		metaData := make([]pkg.MetaData, 0, len(project.ResourceIds))
-		for _, id := range project.ResourceIds {
-			meta, err := store.MetaById(ctx, orgId, id)
-			if err != nil {
-				slog.ErrorContext(ctx, "Failed to fetch metadata for project", "error", err)
-			} else {
-				if !meta.Deleted {
-					metaData = append(metaData, *meta)
-				}
+		for _, lookup := range store.MetaByIds(ctx, orgId, project.ResourceIds) {
+			if lookup.Err != nil {
+				slog.ErrorContext(ctx, "Failed to fetch metadata for project", "resourceId", lookup.Id, "error", lookup.Err)
+			} else if !lookup.Meta.Deleted {
+				metaData = append(metaData, *lookup.Meta)
 			}
 		}
 
+		// Rendering a partial project would look like pieces were removed
+		if err := ctx.Err(); err != nil {
+			http.Error(w, "Timed out fetching project content", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Timed out fetching project content", "error", err)
+			return
+		}
+
 		web.ProjectContent(w, project, metaData, "en")
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	}
@@ -369,20 +579,21 @@ func ResourceContentByIdHandler(s pkg.ResourceGetter, timeout time.Duration) htt
 		downloader := pkg.NewResourceDownloader().GetMetaData(ctx, s, orgId, id).GetResource(ctx, s, orgId)
 
 		if downloader.Error != nil {
-			http.Error(w, "could not fetch resource", http.StatusInternalServerError)
+			http.Error(w, "could not fetch resource", StoreErrorCode(downloader.Error))
 			slog.ErrorContext(ctx, "Failed to fetch resource", "error", downloader.Error)
+			return
 		}
 
 		content := web.ResourceContentData{
 			ResourceId: id,
 			Filenames:  downloader.Filenames(),
 		}
-		web.ResourceContent(w, &content)
+		web.ResourceContent(w, pkg.LanguageFromReq(r), &content)
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	}
 }
 
-func ResourceDownload(s pkg.ResourceGetter, timeout time.Duration) http.HandlerFunc {
+func ResourceDownload(s pkg.TieredResourceGetter, timeout time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), timeout)
 		defer cancel()
@@ -391,44 +602,256 @@ func ResourceDownload(s pkg.ResourceGetter, timeout time.Duration) http.HandlerF
 		orgId := MustGetOrgId(session)
 		resourceId := r.PathValue("id")
 		filename := r.URL.Query().Get("file")
-		downloader := pkg.NewResourceDownloader().GetMetaData(ctx, s, orgId, resourceId).GetResource(ctx, s, orgId)
+		downloader := pkg.NewResourceDownloader().GetMetaData(ctx, s, orgId, resourceId)
+
+		// Clients that have the current content are answered before the resource is fetched or rehydrated
+		etag := downloader.ETag(filename)
+		if etag != "" && etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Cache-Control", "private, no-cache")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
 
-		var (
-			statusCode         int
-			contentDisposition string
-			contentType        string
-		)
+		downloader.Rehydrate(ctx, s, orgId).GetResource(ctx, s, orgId)
+		if err := downloader.Error; err != nil {
+			http.Error(w, err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Error during download resource", "error", err, "id", resourceId, "file", filename)
+			return
+		}
+
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Cache-Control", "private, no-cache")
+		}
 		if filename == "" {
-			zipFilename := downloader.ZipFilename()
-			contentDisposition = "attachment; filename=\"" + zipFilename + "\""
-			contentType = "application/zip"
+			// The parts are streamed from the bucket to the client, so headers are set before any content is written
+			w.Header().Set("Content-Type", "application/zip")
+			w.Header().Set("Content-Disposition", "attachment; filename=\""+downloader.ZipFilename()+"\"")
 			downloader.ZipResource(w, pkg.IncludeAll)
-		} else {
-			contentDisposition = "attachment; filename=\"" + filename + "\""
-			contentType = "application/pdf"
+		} else if part, err := openPart(ctx, s, orgId, resourceId, filename); err == nil {
+			// Only the requested ranges are read from the bucket, such that PDF viewers can fetch the pages
+			// they show instead of the whole part
+			defer part.Close()
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+			http.ServeContent(w, r, filename, time.Time{}, part)
+		} else if errors.Is(err, pkg.ErrRangeReadUnsupported) {
+			// The whole part is streamed when the bucket can not read ranges
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
 			downloader.ExtractSingleFile(filename, w)
+		} else {
+			// Nothing is written before the part is opened, so every error can be reported
+			w.Header().Del("ETag")
+			http.Error(w, err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not open part", "error", err, "id", resourceId, "file", filename)
+			return
+		}
+
+		if err := downloader.Error; err != nil {
+			// Nothing is written when the resource or the part is missing, otherwise the client sees a truncated
+			// download
+			if pkg.IsNotFound(err) {
+				w.Header().Del("Content-Disposition")
+				w.Header().Del("ETag")
+				http.Error(w, err.Error(), StoreErrorCode(err))
+			}
+			slog.ErrorContext(ctx, "Error during download resource", "error", err, "id", resourceId, "file", filename)
+			return
+		}
+		if err := s.RecordAccess(ctx, orgId, resourceId, time.Now()); err != nil {
+			slog.ErrorContext(ctx, "Failed to record access", "error", err, "id", resourceId)
 		}
+		slog.InfoContext(ctx, "Resource downloaded")
+	}
+}
+
+// openPart opens a part that only reads the ranges it is seeked to. Stores that can not read ranges return
+// ErrRangeReadUnsupported
+func openPart(ctx context.Context, store pkg.TieredResourceGetter, orgId, resourceId, filename string) (io.ReadSeekCloser, error) {
+	opener, ok := store.(pkg.PartOpener)
+	if !ok {
+		return nil, pkg.ErrRangeReadUnsupported
+	}
+	return opener.OpenPart(ctx, orgId, resourceId, filename)
+}
+
+func ResourceProtectionHandler(store pkg.ResourceProtector, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
 
-		err := downloader.Error
+		orgId := MustGetOrgId(MustGetSession(r))
+		resourceId := r.PathValue("id")
+		protected := r.Method != http.MethodDelete
+		if err := store.SetProtected(ctx, orgId, resourceId, protected); err != nil {
+			http.Error(w, "Could not update protection", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not update protection", "error", err, "resourceId", resourceId)
+			return
+		}
+		slog.InfoContext(ctx, "Updated resource protection", "resourceId", resourceId, "protected", protected)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func DeleteResourceHandler(store pkg.ResourceDeleter, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		resourceId := r.PathValue("id")
+		err := store.DeleteResource(ctx, orgId, resourceId)
 		switch {
-		case errors.Is(err, pkg.ErrFileNotInZipArchive),
-			errors.Is(err, pkg.ErrFileNotFound),
-			errors.Is(err, pkg.ErrResourceMetadataNotFound):
-			statusCode = http.StatusNotFound
+		case errors.Is(err, pkg.ErrResourceProtected):
+			http.Error(w, "Resource is protected and can not be deleted", http.StatusConflict)
+			slog.InfoContext(ctx, "Attempted to delete protected resource", "resourceId", resourceId)
+			return
 		case err != nil:
-			statusCode = http.StatusInternalServerError
-		default:
-			statusCode = http.StatusOK
+			http.Error(w, "Could not delete resource", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not delete resource", "error", err, "resourceId", resourceId)
+			return
 		}
+		slog.InfoContext(ctx, "Deleted resource", "resourceId", resourceId)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// TrashHandler lists the deleted resources of the organization with the most recently deleted first
+func TrashHandler(fetcher pkg.MetaByPatternFetcher, retention, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
 
+		orgId := MustGetOrgId(MustGetSession(r))
+		meta, err := fetcher.MetaByPattern(ctx, orgId, &pkg.MetaData{})
 		if err != nil {
-			http.Error(w, err.Error(), statusCode)
-			slog.ErrorContext(ctx, "Error during download resource", "error", err, "id", resourceId, "file", filename)
+			http.Error(w, "Failed to fetch metadata", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Failed to fetch metadata", "error", err)
 			return
 		}
-		w.Header().Set("Content-Type", contentType)
-		w.Header().Set("Content-Disposition", contentDisposition)
-		slog.InfoContext(ctx, "Resource downloaded")
+		meta = slices.DeleteFunc(meta, func(m pkg.MetaData) bool { return !m.Deleted })
+		slices.SortStableFunc(meta, func(a, b pkg.MetaData) int { return b.DeletedAt.Compare(a.DeletedAt) })
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		web.Trash(w, pkg.LanguageFromReq(r), meta, retention)
+	}
+}
+
+func RestoreResourceHandler(store pkg.ResourceRestorer, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		resourceId := r.PathValue("id")
+		if err := store.RestoreResource(ctx, orgId, resourceId); err != nil {
+			http.Error(w, "Could not restore resource", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not restore resource", "error", err, "resourceId", resourceId)
+			return
+		}
+		slog.InfoContext(ctx, "Restored resource", "resourceId", resourceId)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func ResourceVersionsHandler(store pkg.ResourceVersioner, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		resourceId := r.PathValue("id")
+		versions, err := store.ResourceVersions(ctx, orgId, resourceId)
+		if err != nil {
+			http.Error(w, "Could not fetch versions", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch versions", "error", err, "resourceId", resourceId)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		web.ResourceVersions(w, pkg.LanguageFromReq(r), resourceId, versions)
+	}
+}
+
+func versionFromPath(r *http.Request) (int, error) {
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("version must be a positive integer, got %q", r.PathValue("version"))
+	}
+	return version, nil
+}
+
+type ResourceVersionStore interface {
+	pkg.ResourceGetter
+	pkg.ResourceVersioner
+}
+
+func ResourceVersionDownload(store ResourceVersionStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		version, err := versionFromPath(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		resourceId := r.PathValue("id")
+		versions, err := store.ResourceVersions(ctx, orgId, resourceId)
+		if err != nil {
+			http.Error(w, "Could not fetch versions", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch versions", "error", err, "resourceId", resourceId)
+			return
+		}
+		if !slices.ContainsFunc(versions, func(v pkg.ResourceVersion) bool { return v.Version == version }) {
+			http.Error(w, "Version not found", http.StatusNotFound)
+			return
+		}
+
+		downloader := pkg.NewResourceDownloader().
+			GetMetaData(ctx, store, orgId, resourceId).
+			GetVersion(ctx, store, orgId, version)
+		if err := downloader.Error; err != nil {
+			http.Error(w, "Could not fetch resource", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch resource", "error", err, "resourceId", resourceId)
+			return
+		}
+
+		zipFilename := fmt.Sprintf("%s-v%d.zip", resourceId, version)
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+zipFilename+"\"")
+		if err := downloader.ZipResource(w, pkg.IncludeAll).Error; err != nil {
+			slog.ErrorContext(ctx, "Failed to download version", "error", err, "resourceId", resourceId, "version", version)
+		}
+	}
+}
+
+func RestoreVersionHandler(store pkg.ResourceVersioner, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		version, err := versionFromPath(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		resourceId := r.PathValue("id")
+		if err := store.RestoreVersion(ctx, orgId, resourceId, version); err != nil {
+			http.Error(w, "Could not restore version", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not restore version", "error", err, "resourceId", resourceId, "version", version)
+			return
+		}
+		slog.InfoContext(ctx, "Restored version", "resourceId", resourceId, "version", version)
+		HxTrigger(w, EventResourceUploaded, map[string]string{"resourceId": resourceId})
+		HxFlash(w, r, FlashSuccess, "flash.version-restored", map[string]any{"Version": version})
+		w.WriteHeader(http.StatusOK)
 	}
 }
 
@@ -442,7 +865,7 @@ func AddToResourceHandler(metaGetter pkg.MetaByIdGetter, timeout time.Duration)
 		orgId := MustGetOrgId(MustGetSession(r))
 		meta, err := metaGetter.MetaById(ctx, orgId, id)
 		if err != nil {
-			http.Error(w, "Error when fetching metadata", http.StatusInternalServerError)
+			http.Error(w, "Error when fetching metadata", StoreErrorCode(err))
 			slog.ErrorContext(ctx, "Error when fetching metadata", "error", err, "id", id, "url", r.URL.Path)
 			return
 		}
@@ -453,6 +876,11 @@ func AddToResourceHandler(metaGetter pkg.MetaByIdGetter, timeout time.Duration)
 }
 
 func HandleGoogleLogin(oauthConfig *oauth2.Config) http.HandlerFunc {
+	return handleOAuthLogin(oauthConfig)
+}
+
+// handleOAuthLogin redirects to the sign in page of the provider, with a state that the callback checks
+func handleOAuthLogin(oauthConfig *oauth2.Config, opts ...oauth2.AuthCodeOption) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		stateString := MustGenerateStateString()
 		session := MustGetSession(r)
@@ -462,12 +890,58 @@ func HandleGoogleLogin(oauthConfig *oauth2.Config) http.HandlerFunc {
 			return
 		}
 
-		url := oauthConfig.AuthCodeURL(stateString)
+		url := oauthConfigForRequest(r, oauthConfig).AuthCodeURL(stateString, opts...)
 		http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 	}
 }
 
-func HandleGoogleCallback(roleStore pkg.RoleStore, oauthConfig *oauth2.Config, timeout time.Duration, signSecret string, transport http.RoundTripper) http.HandlerFunc {
+func HandleGoogleCallback(roleStore LoginStore, oauthConfig *oauth2.Config, userInfoURL string, timeout time.Duration, signSecret string, transport http.RoundTripper) http.HandlerFunc {
+	decode := func(body io.Reader, _ *oauth2.Token) (pkg.UserInfo, error) {
+		var userInfo pkg.UserInfo
+		err := json.NewDecoder(body).Decode(&userInfo)
+		return userInfo, err
+	}
+	return handleOAuthCallback(roleStore, oauthConfig, userInfoURL, timeout, signSecret, transport, decode)
+}
+
+// handleOAuthCallback exchanges the code for a token, fetches the user from userInfoURL and signs the user in
+func handleOAuthCallback(
+	roleStore LoginStore,
+	oauthConfig *oauth2.Config,
+	userInfoURL string,
+	timeout time.Duration,
+	signSecret string,
+	transport http.RoundTripper,
+	decode func(body io.Reader, token *oauth2.Token) (pkg.UserInfo, error),
+) http.HandlerFunc {
+	fetchUser := func(r *http.Request, client *http.Client, token *oauth2.Token) (pkg.UserInfo, int, error) {
+		resp, err := client.Get(userInfoURL)
+		if err != nil || resp.StatusCode >= 400 {
+			msg, code := CodeAndMessage(err, resp.StatusCode)
+			return pkg.UserInfo{}, code, errors.New("Failed getting user info: " + msg)
+		}
+		defer resp.Body.Close()
+
+		userInfo, err := decode(resp.Body, token)
+		if err != nil {
+			return pkg.UserInfo{}, http.StatusInternalServerError, errors.New("Failed decoding user info: " + err.Error())
+		}
+		return userInfo, http.StatusOK, nil
+	}
+	return handleOAuthTokenCallback(roleStore, oauthConfig, timeout, signSecret, transport, fetchUser)
+}
+
+// handleOAuthTokenCallback exchanges the code for a token and signs in the user returned by fetchUser. The
+// client passed to fetchUser is authorized with the token. fetchUser returns the status code of the response
+// together with the error
+func handleOAuthTokenCallback(
+	roleStore LoginStore,
+	oauthConfig *oauth2.Config,
+	timeout time.Duration,
+	signSecret string,
+	transport http.RoundTripper,
+	fetchUser func(r *http.Request, client *http.Client, token *oauth2.Token) (pkg.UserInfo, int, error),
+) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		state := r.FormValue("state")
 		session := MustGetSession(r)
@@ -488,27 +962,20 @@ func HandleGoogleCallback(roleStore pkg.RoleStore, oauthConfig *oauth2.Config, t
 		if transport != nil {
 			ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: transport})
 		}
-		token, err := oauthConfig.Exchange(ctx, code)
+		config := oauthConfigForRequest(r, oauthConfig)
+		token, err := config.Exchange(ctx, code)
 		if err != nil {
 			http.Error(w, "Code exchange failed: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		client := oauthConfig.Client(ctx, token)
+		client := config.Client(ctx, token)
 		if transport != nil {
 			client.Transport = transport
 		}
-		resp, err := client.Get(googleUserInfo)
-		if err != nil || resp.StatusCode >= 400 {
-			msg, code := CodeAndMessage(err, resp.StatusCode)
-			http.Error(w, "Failed getting user info: "+msg, code)
-			return
-		}
-		defer resp.Body.Close()
-
-		var userInfo pkg.UserInfo
-		if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
-			http.Error(w, "Failed decoding user info: "+err.Error(), http.StatusInternalServerError)
+		userInfo, status, err := fetchUser(r.WithContext(ctx), client, token)
+		if err != nil {
+			http.Error(w, err.Error(), status)
 			return
 		}
 
@@ -548,29 +1015,69 @@ func OrganizationsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(web.Organizations(language))
 }
 
-func InviteLink(baseURL, signSecret string) http.HandlerFunc {
+// Anyone with an invite link can join the organization as a viewer until the link expires
+const inviteLinkExpiry = 48 * time.Hour
+
+// signedInviteURL returns a link that lets the receiver join the organization of the invite until expires
+func signedInviteURL(baseURL, signSecret string, invite InviteClaim, expires time.Time) (string, error) {
+	currentTime := time.Now()
+	invite.RegisteredClaims = jwt.RegisteredClaims{
+		Audience:  jwt.ClaimStrings{inviteTokenAudience},
+		ExpiresAt: jwt.NewNumericDate(expires),
+		IssuedAt:  jwt.NewNumericDate(currentTime),
+		NotBefore: jwt.NewNumericDate(currentTime),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, invite)
+	signedToken, err := token.SignedString([]byte(signSecret))
+	if err != nil {
+		return "", err
+	}
+	return baseURL + "/login?invite-token=" + url.QueryEscape(signedToken), nil
+}
+
+// newInviteLinkURL stores a new invite link of the organization and returns the signed link
+func newInviteLinkURL(ctx context.Context, store pkg.InviteLinkStore, config *pkg.Config, orgId, userId string) (string, error) {
+	link := pkg.NewInviteLink(orgId, userId, inviteLinkExpiry)
+	if err := store.SaveInviteLink(ctx, link); err != nil {
+		return "", err
+	}
+	return signedInviteURL(config.BaseURL, config.CookieSecretSignKey, InviteClaim{OrgId: orgId, LinkId: link.Id}, link.ExpiresAt)
+}
+
+// activeInviteLinkURL signs the newest active invite link of the organization again, such that pages showing an
+// invite link do not store a new link on every visit. A new link is stored when there is no active link
+func activeInviteLinkURL(ctx context.Context, store pkg.InviteLinkStore, config *pkg.Config, orgId, userId string) (string, error) {
+	links, err := store.InviteLinks(ctx, orgId)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	for _, link := range links {
+		if link.StatusAt(now) == pkg.InviteLinkActive {
+			return signedInviteURL(config.BaseURL, config.CookieSecretSignKey, InviteClaim{OrgId: orgId, LinkId: link.Id}, link.ExpiresAt)
+		}
+	}
+	return newInviteLinkURL(ctx, store, config, orgId, userId)
+}
+
+func InviteLink(store pkg.InviteLinkStore, config *pkg.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		orgId := r.PathValue("id")
-
-		currentTime := time.Now()
-		claims := InviteClaim{
-			OrgId: orgId,
-			RegisteredClaims: jwt.RegisteredClaims{
-				ExpiresAt: jwt.NewNumericDate(currentTime.Add(48 * time.Hour)),
-				IssuedAt:  jwt.NewNumericDate(currentTime),
-				NotBefore: jwt.NewNumericDate(currentTime),
-			},
+		orgId, ok := requireActiveOrganization(w, r)
+		if !ok {
+			return
 		}
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-		signedToken, err := token.SignedString([]byte(signSecret))
+
+		ctx, cancel := context.WithTimeout(r.Context(), config.Timeout)
+		defer cancel()
+
+		userId, _ := r.Context().Value(pkg.UserIdKey).(string)
+		inviteURL, err := newInviteLinkURL(ctx, store, config, orgId, userId)
 		if err != nil {
-			http.Error(w, "Failed to sign token", http.StatusInternalServerError)
-			slog.ErrorContext(r.Context(), "Failed to sign invite link token", "error", err)
+			http.Error(w, "Failed to create invite link", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Failed to create invite link", "error", err)
 			return
 		}
 
-		inviteURL := baseURL + "/login?invite-token=" + url.QueryEscape(signedToken)
-
 		respBody := struct {
 			InviteLink string `json:"invite_link"`
 		}{
@@ -624,12 +1131,13 @@ func OrganizationRegisterHandler(store pkg.IAMStore, stripeIdProvider pkg.Stripe
 		userId := MustGetUserId(session)
 
 		registrationFlow := pkg.NewRegisterOrganizationFlow(ctx, store, session)
-		registrationFlow.Register(&org).RegisterAdmin(userId, org.Id).RetrieveUserInfo(userId).UpdateSession(r, w, org.Id)
+		registrationFlow.Register(&org).RegisterAdmin(userId, org.Id).StartOnboarding(org.Id).RetrieveUserInfo(userId).UpdateSession(r, w, org.Id)
 		if err := registrationFlow.Error; err != nil {
 			http.Error(w, "Could not register organization: "+err.Error(), http.StatusInternalServerError)
 			slog.ErrorContext(ctx, "Could not register organization", "error", err)
 			return
 		}
+		HxTrigger(w, EventOnboardingUpdated, nil)
 		slog.InfoContext(ctx, "Successfully registered new organization")
 	}
 }
@@ -738,11 +1246,21 @@ func ActiveOrganization(getter pkg.OrganizationGetter, timeout time.Duration) ht
 			w.Write([]byte(noOrg))
 			return
 		}
+		if impersonatedOrg(session) == orgId {
+			w.Write([]byte(org.Name + " (" + web.Impersonating(language) + ")"))
+			return
+		}
 		w.Write([]byte(org.Name))
 	}
 }
 
-func AllUsers(store pkg.UserGetter, timeout time.Duration) http.HandlerFunc {
+// UserListStore lists the members of the organization for admins, and the user itself for other members
+type UserListStore interface {
+	pkg.UserGetter
+	pkg.UsersInOrgPager
+}
+
+func AllUsers(store UserListStore, timeout time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		filter := r.URL.Query().Get("name")
 		session := MustGetSession(r)
@@ -754,17 +1272,18 @@ func AllUsers(store pkg.UserGetter, timeout time.Duration) http.HandlerFunc {
 		defer cancel()
 
 		var (
-			users []pkg.UserInfo
-			err   error
+			users      []pkg.UserInfo
+			nextCursor string
 		)
-		if role == pkg.RoleAdmin {
-			// Admins gets a list of all users
-			users, err = store.GetUsersInOrg(ctx, orgId)
+		if role.AtLeast(pkg.RoleAdmin) {
+			// Admins gets a list of all users, one page at a time
+			page, err := store.UsersInOrgPage(ctx, orgId, pageRequest(r))
 			if err != nil {
-				http.Error(w, "Error when fething users "+err.Error(), http.StatusInternalServerError)
+				http.Error(w, "Error when fething users "+err.Error(), StoreErrorCode(err))
 				slog.ErrorContext(ctx, "Error when fething users ", "error", err)
 				return
 			}
+			users, nextCursor = page.Items, page.NextCursor
 		} else {
 			// Other just gets information about themselves
 			userInfoFromStore, err := store.GetUserInfo(ctx, userInfo.Id)
@@ -776,6 +1295,7 @@ func AllUsers(store pkg.UserGetter, timeout time.Duration) http.HandlerFunc {
 			users = append(users, *userInfoFromStore)
 		}
 
+		// The filter is applied to each page, such that a page may have fewer users than the limit
 		if filter != "" {
 			users = slices.DeleteFunc(users, func(u pkg.UserInfo) bool {
 				email := strings.ToLower(u.Email)
@@ -784,13 +1304,11 @@ func AllUsers(store pkg.UserGetter, timeout time.Duration) http.HandlerFunc {
 				return !strings.Contains(email, lowerFilter) && !strings.Contains(name, lowerFilter)
 			})
 		}
-		slices.SortStableFunc(users, func(a, b pkg.UserInfo) int {
-			return cmp.Compare(a.Name, b.Name)
-		})
 
 		groups := allInstruments()
 		slices.Sort(groups)
 		web.WriteUserList(w, users, orgId, append([]string{"-- Add to group --"}, groups...))
+		writeLoadMore(w, r, nextCursor, 5)
 	}
 }
 
@@ -799,7 +1317,14 @@ func PeoplePage(w http.ResponseWriter, r *http.Request) {
 	web.WritePeopleHTML(w, "en")
 }
 
-func AssignRoleHandler(store pkg.RoleRegisterer, timeout time.Duration) http.HandlerFunc {
+type RoleAssignStore interface {
+	pkg.RoleRegisterer
+	pkg.SessionRegistry
+}
+
+// AssignRoleHandler changes the role of a member. The sessions of the member are revoked, such that the new role is
+// only granted to a session issued when the member signs in again
+func AssignRoleHandler(store RoleAssignStore, timeout time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		session := MustGetSession(r)
 		userId := MustGetUserId(session)
@@ -825,7 +1350,7 @@ func AssignRoleHandler(store pkg.RoleRegisterer, timeout time.Duration) http.Han
 			slog.ErrorContext(r.Context(), "Could not convert role into int", "error", err)
 			return
 		}
-		if role > pkg.RoleAdmin {
+		if !pkg.RoleKind(role).Valid() {
 			role = pkg.RoleViewer
 		}
 
@@ -838,8 +1363,13 @@ func AssignRoleHandler(store pkg.RoleRegisterer, timeout time.Duration) http.Han
 			slog.ErrorContext(ctx, "Failed to register new role", "error", err, "targetUser", userIdFromPath)
 			return
 		}
+		if err := revokeUserSessions(ctx, store, userIdFromPath); err != nil {
+			// The role is already changed and takes effect through the permissions version of the member
+			slog.ErrorContext(ctx, "Could not revoke sessions after role change", "error", err, "targetUser", userIdFromPath)
+		}
+		HxTrigger(w, EventUsersUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.role-updated", nil)
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Successfully upgraded role for user"))
 	}
 }
 
@@ -875,9 +1405,9 @@ func RegisterRecipent(store pkg.UserRegisterer, timeout time.Duration) http.Hand
 			return
 		}
 
+		HxTrigger(w, EventUsersUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.recipent-registered", nil)
 		w.WriteHeader(http.StatusOK)
-		w.Header().Set("Content-Type", "text/plain")
-		w.Write([]byte("Successfully registered new recipent"))
 	}
 }
 
@@ -902,9 +1432,9 @@ func DeleteUserFromOrg(store pkg.DeleteRole, timeout time.Duration) http.Handler
 			return
 		}
 
+		HxTrigger(w, EventUsersUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.user-deleted", nil)
 		w.WriteHeader(http.StatusOK)
-		w.Header().Set("Content-Type", "text/plain")
-		w.Write([]byte("Successfully deleted user"))
 	}
 }
 
@@ -924,7 +1454,7 @@ func GroupHandler(store pkg.GroupStore, timeout time.Duration) http.HandlerFunc
 		userInfo := MustGetUserInfo(session)
 		role := userInfo.Roles[orgId]
 		userIdFromPath := r.PathValue("id")
-		if role < pkg.RoleAdmin && userIdFromPath != userInfo.Id {
+		if !role.AtLeast(pkg.RoleAdmin) && userIdFromPath != userInfo.Id {
 			http.Error(w, "Only admins can edit groups of others", http.StatusUnauthorized)
 			slog.WarnContext(r.Context(), "Non-admin tried to edit group of another user")
 			return
@@ -947,8 +1477,9 @@ func GroupHandler(store pkg.GroupStore, timeout time.Duration) http.HandlerFunc
 			slog.ErrorContext(ctx, "Failed to edit group", "error", err, "targetUser", userIdFromPath)
 			return
 		}
+		HxTrigger(w, EventUsersUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.group-updated", nil)
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Successfully edited group"))
 	}
 }
 
@@ -966,7 +1497,177 @@ func LoggedIn(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(html))
 }
 
-func DownloadUserParts(store pkg.ResourceGetter, config *pkg.Config) http.HandlerFunc {
+// MaintenanceBannerHandler returns a banner when the backend of the store is degraded, and nothing otherwise
+func MaintenanceBannerHandler(reporter pkg.HealthReporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if reporter != nil && reporter.Degraded() {
+			w.Write([]byte(web.MaintenanceBanner(pkg.LanguageFromReq(r))))
+		}
+	}
+}
+
+type UserPartsStore interface {
+	pkg.TieredResourceGetter
+	pkg.ProjectByIdGetter
+}
+
+// rehearsalNotes returns the notes of the downloaded pieces when the download is done from a project. Missing
+// notes should not stop the download, so errors are only logged
+func rehearsalNotes(ctx context.Context, store UserPartsStore, orgId, projectId string, ids []string) string {
+	if projectId == "" {
+		return ""
+	}
+	project, err := store.ProjectById(ctx, orgId, projectId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to fetch project notes", "error", err, "projectId", projectId)
+		return ""
+	}
+
+	metaData := make([]pkg.MetaData, 0, len(project.Notes))
+	for _, resourceId := range ids {
+		if _, ok := project.Notes[resourceId]; !ok {
+			continue
+		}
+		meta, err := store.MetaById(ctx, orgId, resourceId)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to fetch metadata for notes", "error", err, "resourceId", resourceId)
+			continue
+		}
+		metaData = append(metaData, *meta)
+	}
+	return project.RehearsalNotes(metaData)
+}
+
+// orderByProject sorts the downloaded pieces in the order of the project, such that the parts follow the setlist
+// regardless of how the client listed them. The download does not depend on the order, so errors are only logged
+func orderByProject(ctx context.Context, store pkg.ProjectByIdGetter, orgId, projectId string, ids []string) {
+	if projectId == "" {
+		return
+	}
+	project, err := store.ProjectById(ctx, orgId, projectId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to fetch project order", "error", err, "projectId", projectId)
+		return
+	}
+	project.SortByOrder(ids)
+}
+
+const stampDateFormat = "2006-01-02"
+
+// partsStamp returns the header stamped on the parts downloaded for a rehearsal, made from the name of the
+// project and the date of the rehearsal. No header is stamped when stampDate is empty
+func partsStamp(ctx context.Context, store pkg.ProjectByIdGetter, orgId, projectId, stampDate string) (string, int, error) {
+	if stampDate == "" {
+		return "", http.StatusOK, nil
+	}
+	date, err := time.Parse(stampDateFormat, stampDate)
+	if err != nil {
+		return "", http.StatusBadRequest, errors.New("Rehearsal date must be on the form YYYY-MM-DD")
+	}
+	if projectId == "" {
+		return "", http.StatusBadRequest, errors.New("Only parts downloaded from a project can be stamped")
+	}
+	project, err := store.ProjectById(ctx, orgId, projectId)
+	if err != nil {
+		return "", StoreErrorCode(err), err
+	}
+	return fmt.Sprintf("%s - %s", project.Name, date.Format(stampDateFormat)), http.StatusOK, nil
+}
+
+// userParts are the pieces of a download of parts. Metadata is fetched for all pieces before writing starts,
+// such that missing pieces are reported while the status code can still be set
+type userParts struct {
+	ids         []string
+	downloaders []*pkg.ResourceDownloader
+	include     func(string) bool
+	notes       string
+
+	// numbered prefixes the files with the position of the piece, such that a setlist keeps its order when the
+	// files are sorted by name
+	numbered bool
+}
+
+func collectUserParts(ctx context.Context, store UserPartsStore, session *sessions.Session, form url.Values) (*userParts, int, error) {
+	orgId := MustGetOrgId(session)
+	projectId := form.Get("projectId")
+	if projectId != "" {
+		// The project is given by the client, so it must be a project of the organization before it is used
+		if _, err := store.ProjectById(ctx, orgId, projectId); err != nil {
+			slog.InfoContext(ctx, "Could not resolve project of download", "error", err, "projectId", projectId)
+			return nil, StoreErrorCode(err), errors.New("Project not found")
+		}
+	}
+	stamp, code, err := partsStamp(ctx, store, orgId, projectId, form.Get("stampDate"))
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not make header of parts", "error", err, "projectId", projectId)
+		return nil, code, fmt.Errorf("Could not stamp parts: %w", err)
+	}
+
+	parts := userParts{ids: slices.Clone(form["resourceId"]), include: GroupFilterFromSession(session), numbered: projectId != ""}
+	orderByProject(ctx, store, orgId, projectId, parts.ids)
+	parts.downloaders = make([]*pkg.ResourceDownloader, len(parts.ids))
+	for i, resourceId := range parts.ids {
+		parts.downloaders[i] = pkg.NewResourceDownloader().
+			GetMetaData(ctx, store, orgId, resourceId).
+			Rehydrate(ctx, store, orgId).
+			GetResource(ctx, store, orgId).
+			Stamp(stamp)
+		if err := parts.downloaders[i].Error; err != nil {
+			slog.ErrorContext(ctx, "Failed to collect resources", "error", err, "resourceId", resourceId)
+			return nil, StoreErrorCode(err), errors.New("Could not fetch resource")
+		}
+	}
+	parts.notes = rehearsalNotes(ctx, store, orgId, projectId, parts.ids)
+	return &parts, http.StatusOK, nil
+}
+
+// writeZip streams the parts and the rehearsal notes into a zip archive and returns the number of files in it
+func (u *userParts) writeZip(w io.Writer) (int, error) {
+	zw := zip.NewWriter(w)
+	numFiles := 0
+	width := len(strconv.Itoa(len(u.ids)))
+	for i, downloader := range u.downloaders {
+		prefix := u.ids[i] + "_"
+		if u.numbered {
+			prefix = fmt.Sprintf("%0*d_%s", width, i+1, prefix)
+		}
+		numFiles += downloader.AddToZip(zw, prefix, u.include).NumFiles
+		if err := downloader.Error; err != nil {
+			return numFiles, fmt.Errorf("could not add resource %s: %w", u.ids[i], err)
+		}
+	}
+	err := pkg.ReturnOnFirstError(
+		func() error {
+			if u.notes == "" {
+				return nil
+			}
+			notesWriter, notesErr := zw.Create(rehearsalNotesFile)
+			if notesErr != nil {
+				return notesErr
+			}
+			_, notesErr = io.WriteString(notesWriter, u.notes)
+			return notesErr
+		},
+		zw.Close,
+	)
+	return numFiles, err
+}
+
+func recordPartsAccess(ctx context.Context, store pkg.AccessRecorder, orgId string, ids []string) {
+	now := time.Now()
+	for _, resourceId := range ids {
+		if err := store.RecordAccess(ctx, orgId, resourceId, now); err != nil {
+			slog.ErrorContext(ctx, "Failed to record access", "error", err, "id", resourceId)
+		}
+	}
+}
+
+func partsZipFilename() string {
+	return fmt.Sprintf("casesura-%s.zip", time.Now().Format(FileTimeFormat))
+}
+
+func DownloadUserParts(store UserPartsStore, config *pkg.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		r.Body = http.MaxBytesReader(w, r.Body, 32768)
 		code, err := parseForm(r)
@@ -977,72 +1678,67 @@ func DownloadUserParts(store pkg.ResourceGetter, config *pkg.Config) http.Handle
 		}
 		s := MustGetSession(r)
 		orgId := MustGetOrgId(s)
-		ids := r.Form["resourceId"]
 
 		ctx, cancel := context.WithTimeout(r.Context(), config.Timeout)
 		defer cancel()
-		fileFilter := GroupFilterFromSession(s)
-		namedBuffers := make([]pkg.NamedBuffer, len(ids))
 
-		downloader := pkg.NewResourceDownloader()
-
-		zipFilename := fmt.Sprintf("casesura-%s.zip", time.Now().Format(FileTimeFormat))
-		contentDisposition := "attachment; filename=\"" + zipFilename + "\""
-		contentType := "application/zip"
-		w.Header().Set("Content-Type", contentType)
-		w.Header().Set("Content-Disposition", contentDisposition)
+		parts, code, err := collectUserParts(ctx, store, s, r.Form)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
 
-		var numFilesInZip int
-		err = pkg.ReturnOnFirstError(
-			func() error {
-				for i, resourceId := range ids {
-					namedBuffers[i].Name = resourceId
-					internalErr := downloader.
-						GetMetaData(ctx, store, orgId, resourceId).
-						GetResource(ctx, store, orgId).
-						ZipResource(&namedBuffers[i].Buf, fileFilter).Error
-
-					if internalErr != nil {
-						return fmt.Errorf("download failed: Id=%d, resourceId=%s error=%w", i, resourceId, internalErr)
-					}
-				}
-				return nil
-			},
-			func() error {
-				zw := zip.NewWriter(w)
-				defer zw.Close()
-				var combineError error
-				numFilesInZip, combineError = pkg.CombineZip(zw, namedBuffers)
-				return combineError
-			},
-		)
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+partsZipFilename()+"\"")
 
+		numFilesInZip, err := parts.writeZip(w)
 		if err != nil {
-			slog.ErrorContext(ctx, "Failed to collect resources", "error", err)
+			slog.ErrorContext(ctx, "Failed to complete zip archive", "error", err)
 			return
 		}
-
-		slog.InfoContext(ctx, "Resource downloaded", "numPieces", len(ids), "numFilesInZipArchive", numFilesInZip)
+		recordPartsAccess(ctx, store, orgId, parts.ids)
+		slog.InfoContext(ctx, "Resource downloaded", "numPieces", len(parts.ids), "numFilesInZipArchive", numFilesInZip)
 	}
 }
 
-func LoginHandler(w http.ResponseWriter, r *http.Request) {
-	language := pkg.LanguageFromReq(r)
-	session := MustGetSession(r)
-	inviteToken := r.URL.Query().Get(inviteTokenKey)
-	if inviteToken != "" {
-		session.Values[inviteTokenKey] = inviteToken
+// LoginHandler shows the sign in page with a button for each of the providers
+func LoginHandler(providers web.LoginProviders) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		language := pkg.LanguageFromReq(r)
+		session := MustGetSession(r)
+		inviteToken := r.URL.Query().Get(inviteTokenKey)
+		if inviteToken != "" {
+			session.Values[inviteTokenKey] = inviteToken
+		}
+
+		if err := session.Save(r, w); err != nil {
+			http.Error(w, "Could not save session", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Could not save session", "error", err)
+			return
+		}
+
+		web.LoginForm(w, language, providers)
 	}
+}
 
-	if err := session.Save(r, w); err != nil {
-		http.Error(w, "Could not save session", http.StatusInternalServerError)
-		slog.ErrorContext(r.Context(), "Could not save session", "error", err)
-		return
+// loginProviders returns the optional sign in providers that are configured
+func loginProviders(config *pkg.Config) web.LoginProviders {
+	providers := web.LoginProviders{Apple: config.Apple.Enabled()}
+	if config.OIDC.Enabled() {
+		providers.OIDCName = config.OIDC.DisplayName()
 	}
-	web.LoginForm(w, language)
+	return providers
+}
+
+type PasswordLoginStore interface {
+	pkg.BasicAuthRoleStore
+	pkg.ResetEmailStore
+	pkg.SessionRegistry
 }
 
-func LoginByPassword(store pkg.BasicAuthRoleStore, signSecret string, timeout time.Duration) http.HandlerFunc {
+// LoginByPassword registers new users when the form field "retyped" is given and signs in existing users
+// otherwise. Failed sign-ins are counted by the throttler, which refuses attempts for a while after repeated failures
+func LoginByPassword(store PasswordLoginStore, config *pkg.Config, throttler *pkg.LoginThrottler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		r.Body = http.MaxBytesReader(w, r.Body, 1024)
 		defer r.Body.Close()
@@ -1057,7 +1753,7 @@ func LoginByPassword(store pkg.BasicAuthRoleStore, signSecret string, timeout ti
 		email := r.FormValue("email")
 		password := r.FormValue("password")
 		retypedPassword := r.FormValue("retyped")
-		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		ctx, cancel := context.WithTimeout(r.Context(), config.Timeout)
 		defer cancel()
 
 		var (
@@ -1078,12 +1774,35 @@ func LoginByPassword(store pkg.BasicAuthRoleStore, signSecret string, timeout ti
 				Store:                 store,
 			}
 			user, ok = RegisterNewUserByPassword(params)
+			if ok {
+				if err := sendVerificationEmail(ctx, store, config, user.Email); err != nil {
+					slog.ErrorContext(ctx, "Could not send verification email", "error", err, "userId", user.Id)
+				} else {
+					HxFlash(w, r, FlashSuccess, "flash.verification-sent", nil)
+				}
+			}
 		} else {
+			ip := getIp(r)
+			if wait := throttler.RetryAfter(email, ip); wait > 0 {
+				slog.WarnContext(ctx, "Refused sign-in after repeated failures", "ip", ip, "retryAfter", wait)
+				message := web.LoginThrottled(language, wait)
+				w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+				HxTrigger(w, EventFlash, Flash{Level: FlashError, Message: message})
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte(message))
+				return
+			}
+
 			basicAuthParams := BasicAuthUserLoginParams{
 				BasicAuthCommonParams: basicAuthCommonParams,
 				Store:                 store,
 			}
 			user, ok = LoginUserByPassword(basicAuthParams)
+			if ok {
+				throttler.Succeeded(email)
+			} else {
+				throttler.Failed(email, ip)
+			}
 		}
 
 		if !ok {
@@ -1098,7 +1817,7 @@ func LoginByPassword(store pkg.BasicAuthRoleStore, signSecret string, timeout ti
 			Ctx:        ctx,
 			Session:    session,
 			User:       &user,
-			SignSecret: signSecret,
+			SignSecret: config.CookieSecretSignKey,
 			Store:      store,
 			Writer:     w,
 			Req:        r,
@@ -1107,13 +1826,14 @@ func LoginByPassword(store pkg.BasicAuthRoleStore, signSecret string, timeout ti
 		if result.Error != nil {
 			http.Error(w, result.Error.Error(), result.ReturnCode)
 			slog.ErrorContext(ctx, "Error while initializing user by password", "error", result.Error)
+			return
 		}
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(web.SuccessfulLogin(language)))
 	}
 }
 
-func ResetPasswordEmail(config *pkg.Config) http.HandlerFunc {
+func ResetPasswordEmail(store pkg.ResetEmailStore, config *pkg.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		r.Body = http.MaxBytesReader(w, r.Body, 1024)
 		defer r.Body.Close()
@@ -1131,6 +1851,10 @@ func ResetPasswordEmail(config *pkg.Config) http.HandlerFunc {
 			return
 		}
 
+		ctx, cancel := context.WithTimeout(r.Context(), config.Timeout)
+		defer cancel()
+
+		branding := pkg.BrandingForUser(ctx, store, emailAddr)
 		email := pkg.Email{
 			Sender:    config.EmailSender,
 			SmtpHost:  config.SmtpConfig.Host,
@@ -1138,11 +1862,9 @@ func ResetPasswordEmail(config *pkg.Config) http.HandlerFunc {
 			SmtpAuth:  config.SmtpConfig.Auth,
 			Recipents: []string{emailAddr},
 			SendFn:    config.SmtpConfig.SendFn,
+			Branding:  &branding,
 		}
 
-		ctx, cancel := context.WithTimeout(r.Context(), config.Timeout)
-		defer cancel()
-
 		var (
 			signedToken  string
 			emailContent *bytes.Buffer
@@ -1168,6 +1890,10 @@ func ResetPasswordEmail(config *pkg.Config) http.HandlerFunc {
 			fmt.Fprintf(w, "Error: %s", overallErr)
 			return
 		}
+
+		if err := store.CountFeature(ctx, "", pkg.FeatureEmailSent, time.Now()); err != nil {
+			slog.ErrorContext(ctx, "Could not count feature usage", "feature", pkg.FeatureEmailSent, "error", err)
+		}
 		web.ResetEmailSent(w, language, emailAddr)
 	}
 }
@@ -1251,7 +1977,8 @@ func SignOut(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "Internal server error: %s", err)
 		return
 	}
-	w.Write([]byte("Logged out, session cleared"))
+	HxFlash(w, r, FlashInfo, "flash.logged-out", nil)
+	w.WriteHeader(http.StatusOK)
 }
 
 func AboutUs(w http.ResponseWriter, r *http.Request) {
@@ -1266,77 +1993,214 @@ func TermsAndConditions(w http.ResponseWriter, r *http.Request) {
 }
 
 const (
-	RouteRoot                          = "/"
-	RouteUpload                        = "/upload"
-	RouteCss                           = "/css/"
-	RouteTermsConditions               = "/terms-conditions.txt"
-	RouteInstruments                   = "/instruments"
-	RouteChoice                        = "/choice"
-	RouteJsPdfViewer                   = "/js/pdf-viewer.js"
-	RouteDeleteMode                    = "/delete-mode"
-	RouteOverview                      = "/overview"
-	RouteOverviewSearch                = "/overview/search"
-	RouteOverviewProjectSelector       = "/overview/project-selector"
-	RouteProjectQueryInput             = "/project-query-input"
-	RouteProjects                      = "/projects"
-	RouteProjectsNames                 = "/projects/names"
-	RouteProjectsInfo                  = "/projects/info"
-	RouteProjectsId                    = "/projects/{id}"
-	RouteResources                     = "/resources"
-	RouteResourcesId                   = "/resources/{id}"
-	RouteResourcesIdContent            = "/resources/{id}/content"
-	RouteResourcesIdSubmitForm         = "/resources/{id}/submit-form"
-	RouteResourcesParts                = "/resources/parts"
-	RouteLogin                         = "/login"
-	RouteLoginGoogle                   = "/login/google"
-	RouteLoginBasic                    = "/login/basic"
-	RouteLoginReset                    = "/login/reset"
-	RouteLoginResetForm                = "/login/reset/form"
-	RouteLogout                        = "/logout"
-	RouteAuthCallback                  = "/auth/callback"
-	RouteOrganizations                 = "/organizations"
-	RouteOrganizationsForm             = "/organizations/form"
-	RouteOrganizationsIdInvite         = "/organizations/{id}/invite"
-	RouteOrganizationsOptions          = "/organizations/options"
-	RouteOrganizationsActiveSession    = "/organizations/active/session"
-	RouteOrganizationsUsers            = "/organizations/users"
-	RouteOrganizationsUsersId          = "/organizations/users/{id}"
-	RouteOrganizationsUsersIdGroups    = "/organizations/users/{id}/groups"
-	RouteOrganizationsUsersIdRole      = "/organizations/users/{id}/role"
-	RouteOrganizationsRecipent         = "/organizations/recipent"
-	RouteSessionActiveOrganizationName = "/session/active-organization/name"
-	RouteSessionLoggedIn               = "/session/logged-in"
-	RoutePeople                        = "/people"
-	RouteSubscriptionPage              = "/subscription-page"
-	RouteSubscription                  = "/subscription"
-	RoutePayment                       = "/payment"
-	RouteAbout                         = "/about"
-	RouteCustomerPortal                = "/customer-portal"
-	RoutePassword                      = "/password"
+	RouteRoot                            = "/"
+	RouteUpload                          = "/upload"
+	RouteCss                             = "/css/"
+	RouteTermsConditions                 = "/terms-conditions.txt"
+	RouteInstruments                     = "/instruments"
+	RouteChoice                          = "/choice"
+	RouteJsPdfViewer                     = "/js/pdf-viewer.js"
+	RouteDeleteMode                      = "/delete-mode"
+	RouteOverview                        = "/overview"
+	RouteOverviewSearch                  = "/overview/search"
+	RouteOverviewProjectSelector         = "/overview/project-selector"
+	RouteProjectQueryInput               = "/project-query-input"
+	RouteProjects                        = "/projects"
+	RouteProjectsNames                   = "/projects/names"
+	RouteProjectsInfo                    = "/projects/info"
+	RouteProjectsId                      = "/projects/{id}"
+	RouteProjectsIdActivity              = "/projects/{id}/activity"
+	RouteProjectsIdMerge                 = "/projects/{id}/merge"
+	RouteProjectsIdOrder                 = "/projects/{id}/order"
+	RouteProjectsIdExportPdf             = "/projects/{id}/export.pdf"
+	RouteProjectsIdResourceIdNotes       = "/projects/{projectId}/{resourceId}/notes"
+	RouteProjectsTemplatesOptions        = "/projects/templates/options"
+	RouteResources                       = "/resources"
+	RouteResourcesId                     = "/resources/{id}"
+	RouteResourcesIdContent              = "/resources/{id}/content"
+	RouteResourcesIdSubmitForm           = "/resources/{id}/submit-form"
+	RouteResourcesIdProtection           = "/resources/{id}/protection"
+	RouteResourcesIdLink                 = "/resources/{id}/link"
+	RouteResourcesIdProblems             = "/resources/{id}/problems"
+	RouteResourcesIdCorrections          = "/resources/{id}/corrections"
+	RouteResourcesParts                  = "/resources/parts"
+	RouteResourcesPartsArchives          = "/resources/parts/archives"
+	RouteResourcesPartsArchivesId        = "/resources/parts/archives/{id}"
+	RouteResourcesSuggest                = "/resources/suggest"
+	RouteResourcesUploads                = "/resources/uploads"
+	RouteResourcesUploadsId              = "/resources/uploads/{id}"
+	RouteSubmitProgress                  = "/submit-progress"
+	RouteSubmitProgressId                = "/submit-progress/{id}"
+	RouteLogin                           = "/login"
+	RouteLoginGoogle                     = "/login/google"
+	RouteLoginBasic                      = "/login/basic"
+	RouteLoginReset                      = "/login/reset"
+	RouteLoginResetForm                  = "/login/reset/form"
+	RouteLogout                          = "/logout"
+	RouteAuthCallback                    = "/auth/callback"
+	RouteLoginMicrosoft                  = "/login/microsoft"
+	RouteAuthMicrosoftCallback           = "/auth/microsoft/callback"
+	RouteLoginOIDC                       = "/login/oidc"
+	RouteAuthOIDCCallback                = "/auth/oidc/callback"
+	RouteLoginApple                      = "/login/apple"
+	RouteAuthAppleCallback               = "/auth/apple/callback"
+	RouteLoginPasskeyBegin               = "/login/passkey/begin"
+	RouteLoginPasskeyFinish              = "/login/passkey/finish"
+	RoutePasskeys                        = "/passkeys"
+	RoutePasskeysId                      = "/passkeys/{id}"
+	RoutePasskeysRegisterBegin           = "/passkeys/register/begin"
+	RoutePasskeysRegisterFinish          = "/passkeys/register/finish"
+	RouteOrganizations                   = "/organizations"
+	RouteOrganizationsForm               = "/organizations/form"
+	RouteOrganizationsIdInvite           = "/organizations/{id}/invite"
+	RouteOrganizationsIdInvitations      = "/organizations/{id}/invitations"
+	RouteOrganizationsIdInvites          = "/organizations/{id}/invites"
+	RouteInvitesId                       = "/invites/{id}"
+	RouteOrganizationsOptions            = "/organizations/options"
+	RouteOrganizationsActiveSession      = "/organizations/active/session"
+	RouteOrganizationsUsers              = "/organizations/users"
+	RouteOrganizationsUsersId            = "/organizations/users/{id}"
+	RouteOrganizationsUsersIdGroups      = "/organizations/users/{id}/groups"
+	RouteOrganizationsUsersIdRole        = "/organizations/users/{id}/role"
+	RouteOrganizationsRecipent           = "/organizations/recipent"
+	RouteOrganizationsBranding           = "/organizations/branding"
+	RouteOrganizationsLogsExports        = "/organizations/logs/exports"
+	RouteOrganizationsLogsExportsId      = "/organizations/logs/exports/{id}"
+	RouteOrganizationsProjectTemplates   = "/organizations/project-templates"
+	RouteOrganizationsProjectTemplatesId = "/organizations/project-templates/{id}"
+	RouteOrganizationsProblems           = "/organizations/problems"
+	RouteOrganizationsProblemsIdStatus   = "/organizations/problems/{id}/status"
+	RouteOrganizationsCorrections        = "/organizations/corrections"
+	RouteOrganizationsAudit              = "/organizations/audit"
+	RouteOrganizationsCorrectionsProfile = "/organizations/corrections/profile"
+	RouteOrganizationsCorrectionsId      = "/organizations/corrections/{id}"
+	RouteOrganizationsPasskeys           = "/organizations/passkeys"
+	RouteOrganizationsWebhook            = "/organizations/webhook"
+	RouteOrganizationsWebhookDeliveries  = "/organizations/webhook/deliveries"
+	RouteSessionActiveOrganizationName   = "/session/active-organization/name"
+	RouteSessionLoggedIn                 = "/session/logged-in"
+	RouteSessionBrandingCss              = "/session/branding.css"
+	RouteSessionBrandingLogo             = "/session/branding/logo"
+	RouteStatusBanner                    = "/status/banner"
+	RouteApiResourcesIdManifest          = "/api/v1/resources/{id}/manifest"
+	RouteApiWebDAVToken                  = "/api/v1/webdav/token"
+	RouteApiToken                        = "/api/v1/token"
+	RouteApiResources                    = "/api/v1/resources"
+	RouteApiResourcesId                  = "/api/v1/resources/{id}"
+	RouteApiProjects                     = "/api/v1/projects"
+	RouteApiProjectsId                   = "/api/v1/projects/{id}"
+	RouteApiUsers                        = "/api/v1/users"
+	RouteApiOrganizations                = "/api/v1/organizations"
+	RouteApiOpenAPI                      = "/api/openapi.json"
+	RouteApiDocs                         = "/api/docs"
+	RouteResourcesTrash                  = "/resources/trash"
+	RouteResourcesIdRestore              = "/resources/{id}/restore"
+	RouteResourcesIdVersions             = "/resources/{id}/versions"
+	RouteResourcesIdVersionsId           = "/resources/{id}/versions/{version}"
+	RouteResourcesIdVersionsIdRestore    = "/resources/{id}/versions/{version}/restore"
+	RouteResourcesIdPartsNamePages       = "/resources/{id}/parts/{name}/pages"
+	RouteResourcesIdFilesName            = "/resources/{id}/files/{name}"
+	RouteResourcesPartNames              = "/resources/part-names"
+	RouteResourcesPartNamesRenames       = "/resources/part-names/renames"
+	RouteResourcesCombined               = "/resources/combined"
+	RouteWebDAV                          = "/webdav/"
+	RoutePeople                          = "/people"
+	RouteSubscriptionPage                = "/subscription-page"
+	RouteSubscription                    = "/subscription"
+	RoutePayment                         = "/payment"
+	RouteAbout                           = "/about"
+	RouteCustomerPortal                  = "/customer-portal"
+	RoutePassword                        = "/password"
+	RouteDevToolsSeed                    = "/devtools/seed"
+	RouteDevToolsReset                   = "/devtools/reset"
+	RouteAdminMetrics                    = "/admin/metrics"
+	RouteOverviewBulkEdit                = "/overview/bulk-edit"
+	RouteResourcesMetadata               = "/resources/metadata"
+	RouteResourcesMetadataTable          = "/resources/metadata/table"
+	RouteResourcesBulk                   = "/resources/bulk"
+	RouteResourcesExport                 = "/resources/export"
+	RouteResourcesImport                 = "/resources/import"
+	RouteAdminOrganizationsIdDomain      = "/admin/organizations/{id}/domain"
+	RouteDebugConfig                     = "/debug/config"
+	RouteDebugTranslations               = "/debug/translations"
+	RouteAdminOrganizationsIdImpersonate = "/admin/organizations/{id}/impersonate"
+	RouteAdminImpersonation              = "/admin/impersonation"
+	RouteAnnouncements                   = "/announcements"
+	RouteAnnouncementsIdRead             = "/announcements/{id}/read"
+	RouteAnnouncementsIdExpire           = "/announcements/{id}/expire"
+	RouteSharedPart                      = "/shared/part"
+	RouteSharedArchive                   = "/shared/archive"
+	RouteGuest                           = "/guest"
+	RouteGuestPart                       = "/guest/part"
+	RouteGuestAccess                     = "/guest-access"
+	RouteGuestAccessId                   = "/guest-access/{id}"
+	RouteTokens                          = "/tokens"
+	RouteTokensId                        = "/tokens/{id}"
+	RouteDashboard                       = "/dashboard"
+	RouteOnboarding                      = "/onboarding"
+	RouteOnboardingStepsStep             = "/onboarding/steps/{step}"
+	RouteSessions                        = "/sessions"
+	RouteSessionsId                      = "/sessions/{id}"
+	RouteHintsHint                       = "/hints/{hint}"
+	RouteHintsHintSeen                   = "/hints/{hint}/seen"
+	RouteEmailVerify                     = "/email/verify"
+	RouteEmailVerification               = "/email/verification"
+	RouteOrganizationsLayout             = "/organizations/layout"
+	RouteAccount                         = "/account"
+	RoutePalette                         = "/palette"
+	RouteScimUsers                       = "/scim/v2/Users"
+	RouteScimUsersId                     = "/scim/v2/Users/{id}"
+	RouteScimGroups                      = "/scim/v2/Groups"
+	RouteScimGroupsId                    = "/scim/v2/Groups/{id}"
 )
 
 func Setup(store pkg.Store, config *pkg.Config, cookieStore *sessions.CookieStore) *http.ServeMux {
 	sessionOpt := config.SessionOpts()
-	readRoute := RequireRead(cookieStore, sessionOpt)
-	writeRoute := RequireWrite(store, config, cookieStore, sessionOpt)
-	adminWithoutSubscription := RequireAdminWithoutSubscription(cookieStore, sessionOpt)
-
-	signedInRoute := RequireSignedIn(cookieStore, sessionOpt) // Require user to be signed in, but not to have a role
-	userInfoRoute := RequireUserInfo(cookieStore, sessionOpt) // Require the info about user, but nessecarily a active orgId
-
-	mux := http.NewServeMux()
+	permissions := pkg.NewCachedPermissionsVersions(store, config.PermissionsCacheTTL)
+	accessStore := &cachedAccessStore{AccessStore: store, versions: permissions}
+	readRoute := RequireRead(accessStore, config, cookieStore, sessionOpt)
+	writeRoute := RequireWrite(accessStore, config, cookieStore, sessionOpt)
+	adminWithoutSubscription := RequireAdminWithoutSubscription(accessStore, config, cookieStore, sessionOpt)
+	adminRoute := RequireAdmin(accessStore, config, cookieStore, sessionOpt)
+	librarianWithoutSubscription := RequireLibrarianWithoutSubscription(accessStore, config, cookieStore, sessionOpt)
+	librarianRoute := RequireLibrarian(accessStore, config, cookieStore, sessionOpt)
+
+	signedInRoute := RequireSignedIn(store, config, cookieStore, sessionOpt) // Require user to be signed in, but not to have a role
+	userInfoRoute := RequireUserInfo(store, config, cookieStore, sessionOpt) // Require the info about user, but nessecarily a active orgId
+
+	textIndex := pkg.NewTextIndex(store, config.TextExtractionInterval)
+	events := pkg.NewEventBus()
+	SubscribeReactions(events, permissions, textIndex, pkg.NewWebhookDispatcher(store, pkg.NewPublicHTTPClient(config.Timeout)))
+	emitEvents := EmitEvents(events)
+	auditLogin := AuditLogin(store)
+
+	mux := &routeMux{ServeMux: http.NewServeMux()}
+	if config.DevTools {
+		// Registered first, such that all other routes wait while the dev tools replace the store
+		if inMemStore, ok := store.(*pkg.MultiOrgInMemoryStore); ok {
+			slog.Warn("Dev tools are enabled. Should not be used in production!")
+			var gate devToolsGate
+			mux.Handle("POST "+RouteDevToolsSeed, gate.Exclusive(DevToolsSeedHandler(inMemStore)))
+			mux.Handle("POST "+RouteDevToolsReset, gate.Exclusive(DevToolsResetHandler(inMemStore, inMemStore.Clone())))
+			mux.middleware = gate.Shared
+		} else {
+			slog.Warn("Dev tools are only available for in-memory stores")
+		}
+	}
 	mux.HandleFunc(RouteRoot, RootHandler)
 	mux.HandleFunc(RouteUpload, UploadHandler)
 	mux.Handle(RouteCss, web.CssServer())
 	mux.HandleFunc(RouteTermsConditions, TermsAndConditions)
-	mux.HandleFunc(RouteInstruments, InstrumentSearchHandler)
 	mux.HandleFunc(RouteChoice, ChoiceHandler)
 	mux.HandleFunc(RouteJsPdfViewer, JsHandler)
 	mux.HandleFunc(RouteDeleteMode, DeleteMode)
 
 	mux.HandleFunc(RouteOverview, OverviewHandler)
-	mux.Handle(RouteOverviewSearch, readRoute(OverviewSearchHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteResourcesSuggest, readRoute(ResourceSuggestHandler(store, config.Timeout)))
+	mux.Handle("GET "+RoutePalette, readRoute(CommandPaletteHandler(store, config.Timeout)))
+	mux.Handle(RouteOverviewSearch, readRoute(OverviewSearchHandler(store, textIndex, config.Timeout)))
 	mux.HandleFunc(RouteOverviewProjectSelector, ProjectSelectorModalHandler)
+	mux.HandleFunc("GET "+RouteOverviewBulkEdit, BulkEditPageHandler)
 
 	mux.HandleFunc(RouteProjectQueryInput, ProjectQueryInputHandler)
 	mux.Handle("/js/", web.JsServer())
@@ -1345,48 +2209,198 @@ func Setup(store pkg.Store, config *pkg.Config, cookieStore *sessions.CookieStor
 	mux.Handle("GET "+RouteProjectsNames, readRoute(SearchProjectHandler(store, config.Timeout)))
 	mux.Handle("GET "+RouteProjectsInfo, readRoute(SearchProjectListHandler(store, config.Timeout)))
 	mux.Handle("GET "+RouteProjectsId, readRoute(ProjectByIdHandler(store, config.Timeout)))
-	mux.Handle("POST "+RouteProjects, writeRoute(ProjectSubmitHandler(store, config.Timeout)))
-	mux.Handle("DELETE /projects/{projectId}/{resourceId}", writeRoute(RemoveFromProject(store, config.Timeout)))
-
-	mux.Handle("GET "+RouteResourcesId, readRoute(ResourceDownload(store, config.Timeout)))
+	mux.Handle("GET "+RouteProjectsIdActivity, readRoute(ProjectActivityHandler(store, config.Timeout)))
+	mux.Handle("POST "+RouteProjects, writeRoute(emitEvents(RecordProjectActivity(store, pkg.ActivityPieceAdded, submittedPieces)(CountFeature(store, pkg.FeatureProjectSubmit)(ProjectSubmitHandler(store, config.Timeout))))))
+	mux.Handle("PATCH "+RouteProjectsId, writeRoute(emitEvents(RenameProjectHandler(store, config.Timeout))))
+	mux.Handle("POST "+RouteProjectsIdMerge, writeRoute(emitEvents(MergeProjectHandler(store, config.Timeout))))
+	mux.Handle("PUT "+RouteProjectsIdOrder, writeRoute(emitEvents(ProjectOrderHandler(store, config.Timeout))))
+	mux.Handle("DELETE /projects/{projectId}/{resourceId}", writeRoute(emitEvents(RecordProjectActivity(store, pkg.ActivityPieceRemoved, removedPiece)(RemoveFromProject(store, config.Timeout)))))
+	mux.Handle("PUT "+RouteProjectsIdResourceIdNotes, writeRoute(emitEvents(ProjectNoteHandler(store, config.Timeout))))
+	mux.Handle("GET "+RouteProjectsTemplatesOptions, readRoute(ProjectTemplateOptionsHandler(store, config.Timeout)))
+
+	shedUploads := ShedLoad(pkg.NewConcurrencyLimiter(config.LoadShedding.Uploads), "uploads", config.LoadShedding.RetryAfter)
+	shedDownloads := ShedLoad(pkg.NewConcurrencyLimiter(config.LoadShedding.Downloads), "downloads", config.LoadShedding.RetryAfter)
+	mux.Handle("GET "+RouteResourcesId, readRoute(shedDownloads(CountFeature(store, pkg.FeatureDownload)(ResourceDownload(store, config.Timeout)))))
+	mux.Handle("GET "+RouteProjectsIdExportPdf, readRoute(shedDownloads(CountFeature(store, pkg.FeatureDownload)(ProjectPdfHandler(store, config.Timeout)))))
 	mux.Handle("GET "+RouteResourcesIdContent, readRoute(ResourceContentByIdHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteResourcesIdLink, readRoute(SharedLinkHandler(store, config)))
+	mux.Handle("POST "+RouteResourcesIdProblems, readRoute(ReportProblemHandler(store, config.Timeout)))
+	mux.Handle("POST "+RouteResourcesIdCorrections, readRoute(ProposeCorrectionHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteSharedPart, shedDownloads(SharedPartHandler(store, config.CookieSecretSignKey, config.Timeout)))
+	mux.Handle("GET "+RouteApiResourcesIdManifest, readRoute(ResourceManifestHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteApiWebDAVToken, readRoute(WebDAVTokenHandler(config.BaseURL, config.CookieSecretSignKey)))
+	mux.Handle("POST "+RouteApiToken, readRoute(AccessTokenHandler(config.CookieSecretSignKey)))
+	mux.Handle("GET "+RouteApiResources, readRoute(RestResourcesHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteApiResourcesId, readRoute(RestResourceHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteApiProjects, readRoute(RestProjectsHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteApiProjectsId, readRoute(RestProjectHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteApiUsers, readRoute(RestUsersHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteApiOrganizations, readRoute(RestOrganizationsHandler(store, config.Timeout)))
+	mux.Handle(RouteWebDAV, WebDAVHandler(store, config.CookieSecretSignKey, config.Timeout))
 	mux.Handle("GET "+RouteResourcesIdSubmitForm, readRoute(AddToResourceHandler(store, config.Timeout)))
-	mux.Handle("POST "+RouteResources, writeRoute(SubmitHandler(store, config.Timeout, int(config.MaxRequestSizeMb))))
-	mux.Handle("POST "+RouteResourcesParts, writeRoute(DownloadUserParts(store, config)))
+	submits := pkg.NewSubmitTracker(config.UploadExpiry)
+	mux.Handle("POST "+RouteResources, writeRoute(shedUploads(emitEvents(CountFeature(store, pkg.FeatureUpload)(SubmitHandler(store, submits, config.Timeout, int(config.MaxRequestSizeMb)))))))
+	mux.Handle("POST "+RouteSubmitProgress, writeRoute(CreateSubmitProgressHandler(submits)))
+	mux.Handle("GET "+RouteSubmitProgressId, writeRoute(SubmitProgressHandler(submits)))
+	mux.Handle("POST "+RouteResourcesCombined, writeRoute(shedUploads(emitEvents(CountFeature(store, pkg.FeatureUpload)(SubmitCombinedHandler(store, config.Timeout, int(config.MaxRequestSizeMb)))))))
+	uploads := pkg.NewUploadSessions(config.UploadDir, config.UploadExpiry)
+	mux.Handle("POST "+RouteResourcesUploads, writeRoute(CreateUploadHandler(uploads, int(config.MaxUploadSizeMb))))
+	mux.Handle("PATCH "+RouteResourcesUploadsId, writeRoute(shedUploads(emitEvents(AppendUploadHandler(store, uploads, config.Timeout, int(config.MaxRequestSizeMb))))))
+	mux.Handle("POST "+RouteResourcesParts, writeRoute(shedDownloads(RecordProjectActivity(store, pkg.ActivityDownload, downloadedPieces)(CountFeature(store, pkg.FeatureDownload)(DownloadUserParts(store, config))))))
+	archives := pkg.NewArchives(config.ArchiveExpiry)
+	mux.Handle("POST "+RouteResourcesPartsArchives, writeRoute(shedDownloads(RecordProjectActivity(store, pkg.ActivityDownload, downloadedPieces)(CountFeature(store, pkg.FeatureDownload)(CreatePartsArchiveHandler(store, archives, config))))))
+	mux.Handle("GET "+RouteResourcesPartsArchivesId, readRoute(ArchiveStatusHandler(store, config)))
+	mux.Handle("GET "+RouteSharedArchive, shedDownloads(SharedArchiveHandler(store, config.CookieSecretSignKey, config.Timeout)))
+	mux.Handle("GET "+RouteGuest, GuestPageHandler(store, config.CookieSecretSignKey, config.Timeout))
+	mux.Handle("GET "+RouteGuestPart, shedDownloads(GuestPartHandler(store, config.CookieSecretSignKey, config.Timeout)))
+	mux.Handle("GET "+RouteGuestAccess, librarianRoute(GuestGrantsHandler(store, config.Timeout)))
+	mux.Handle("POST "+RouteGuestAccess, librarianRoute(AuditRoute(store, pkg.AuditGuestGranted, auditGuestEmail)(CreateGuestGrantHandler(store, config))))
+	mux.Handle("DELETE "+RouteGuestAccessId, librarianRoute(AuditRoute(store, pkg.AuditGuestRevoked, auditPathId)(RevokeGuestGrantHandler(store, config.Timeout))))
+	mux.Handle("DELETE "+RouteResourcesId, librarianRoute(AuditRoute(store, pkg.AuditResourceDeleted, auditPathId)(DeleteResourceHandler(store, config.Timeout))))
+	mux.Handle("GET "+RouteResourcesTrash, readRoute(TrashHandler(store, config.TrashRetention, config.Timeout)))
+	mux.Handle("POST "+RouteResourcesIdRestore, librarianRoute(RestoreResourceHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteResourcesIdVersions, readRoute(ResourceVersionsHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteResourcesIdVersionsId, readRoute(shedDownloads(ResourceVersionDownload(store, config.Timeout))))
+	mux.Handle("POST "+RouteResourcesIdVersionsIdRestore, writeRoute(RestoreVersionHandler(store, config.Timeout)))
+	mux.Handle("POST "+RouteResourcesIdPartsNamePages, writeRoute(EditPagesHandler(store, config.Timeout)))
+	mux.Handle("PATCH "+RouteResourcesIdFilesName, writeRoute(AuditRoute(store, pkg.AuditPartRenamed, auditRenamedPart)(RenamePartHandler(store, config.Timeout))))
+	mux.Handle("GET "+RouteResourcesPartNames, readRoute(WithInstrumentFamilies(store, config.Timeout)(PartNameReportHandler(store, config.Timeout))))
+	mux.Handle("POST "+RouteResourcesPartNamesRenames, writeRoute(AuditRoute(store, pkg.AuditPartRenamed, auditRenamedParts)(RenamePartsHandler(store, config.Timeout))))
+	mux.Handle("PUT "+RouteResourcesIdProtection, librarianWithoutSubscription(ResourceProtectionHandler(store, config.Timeout)))
+	mux.Handle("DELETE "+RouteResourcesIdProtection, librarianWithoutSubscription(ResourceProtectionHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteResourcesMetadataTable, librarianWithoutSubscription(BulkEditRowsHandler(store, config.Timeout)))
+	mux.Handle("PATCH "+RouteResourcesMetadata, librarianRoute(BulkMetaDataHandler(store, config.Timeout)))
+	mux.Handle("POST "+RouteResourcesBulk, librarianRoute(BulkResourcesHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteResourcesExport, readRoute(LibraryExportHandler(store, config.Timeout)))
+	mux.Handle("POST "+RouteResourcesImport, librarianRoute(shedUploads(emitEvents(ImportLibraryHandler(store, pkg.NewPublicHTTPClient(config.Timeout), config.Timeout, int(config.MaxRequestSizeMb))))))
 
 	oauthCfg := config.OAuthConfig()
-	requireAuthSession := RequireSession(cookieStore, AuthSession, sessionOpt)
-	mux.Handle(RouteLogin, requireAuthSession(http.HandlerFunc(LoginHandler)))
+	requireAuthSession := Chain(RequireSession(cookieStore, AuthSession, sessionOpt), TrackSession(store, config.Timeout))
+	mux.Handle(RouteInstruments, requireAuthSession(WithInstrumentFamilies(store, config.Timeout)(http.HandlerFunc(InstrumentSearchHandler))))
+	mux.Handle(RouteLogin, requireAuthSession(LoginHandler(loginProviders(config))))
 	mux.Handle(RouteLoginGoogle, requireAuthSession(HandleGoogleLogin(oauthCfg)))
-	mux.Handle(RouteLoginBasic, requireAuthSession(LoginByPassword(store, config.CookieSecretSignKey, config.Timeout)))
-	mux.Handle("POST "+RouteLoginReset, ResetPasswordEmail(config))
-	mux.Handle("POST "+RouteLogout, requireAuthSession(http.HandlerFunc(SignOut)))
+	mux.Handle(RouteLoginBasic, requireAuthSession(auditLogin(emitEvents(LoginByPassword(store, config, pkg.NewLoginThrottler(&config.LoginThrottle))))))
+	mux.Handle("POST "+RouteLoginReset, ResetPasswordEmail(store, config))
+	mux.Handle("POST "+RouteLogout, requireAuthSession(SignOutHandler(store, config.Timeout)))
 	mux.Handle("GET "+RouteLoginResetForm, requireAuthSession(http.HandlerFunc(ResetPasswordForm)))
 	mux.Handle("PUT "+RoutePassword, requireAuthSession(UpdatePassword(store, config.CookieSecretSignKey, config.Timeout)))
-	mux.Handle(RouteAuthCallback, requireAuthSession(HandleGoogleCallback(store, oauthCfg, config.Timeout, config.CookieSecretSignKey, config.Transport)))
+	mux.Handle(RouteAuthCallback, requireAuthSession(auditLogin(emitEvents(HandleGoogleCallback(store, oauthCfg, config.OAuthUserInfoURL(), config.Timeout, config.CookieSecretSignKey, config.Transport)))))
+
+	microsoftCfg := config.MicrosoftOAuthConfig()
+	mux.Handle(RouteLoginMicrosoft, requireAuthSession(HandleMicrosoftLogin(microsoftCfg)))
+	mux.Handle(RouteAuthMicrosoftCallback, requireAuthSession(auditLogin(emitEvents(HandleMicrosoftCallback(store, microsoftCfg, pkg.MicrosoftUserInfoURL, config.Timeout, config.CookieSecretSignKey, config.Transport)))))
+
+	if config.OIDC.Enabled() {
+		oidcProvider := pkg.NewOIDCProvider(config.OIDC, config.Transport)
+		mux.Handle(RouteLoginOIDC, requireAuthSession(HandleOIDCLogin(oidcProvider, config.Timeout)))
+		mux.Handle(RouteAuthOIDCCallback, requireAuthSession(auditLogin(emitEvents(HandleOIDCCallback(store, oidcProvider, config.Timeout, config.CookieSecretSignKey, config.Transport)))))
+	}
+
+	if config.Apple.Enabled() {
+		mux.Handle(RouteLoginApple, requireAuthSession(HandleAppleLogin(&config.Apple)))
+		mux.HandleFunc("POST "+RouteAuthAppleCallback, HandleAppleFormPost)
+		mux.Handle("GET "+RouteAuthAppleCallback, requireAuthSession(auditLogin(emitEvents(HandleAppleCallback(store, &config.Apple, config.Timeout, config.CookieSecretSignKey, config.Transport)))))
+	}
+
+	if relyingParty, err := pkg.NewWebAuthn(config.BaseURL, "Caesura"); err == nil {
+		mainDomain := PasskeysOnMainDomain(config.BaseURL)
+		mux.Handle("POST "+RouteLoginPasskeyBegin, mainDomain(requireAuthSession(BeginPasskeyLogin(relyingParty))))
+		mux.Handle("POST "+RouteLoginPasskeyFinish, mainDomain(requireAuthSession(auditLogin(emitEvents(FinishPasskeyLogin(relyingParty, store, config.CookieSecretSignKey, config.Timeout))))))
+		mux.Handle("GET "+RoutePasskeys, signedInRoute(PasskeysHandler(store, config.Timeout)))
+		mux.Handle("DELETE "+RoutePasskeysId, signedInRoute(DeletePasskeyHandler(store, config.Timeout)))
+		mux.Handle("POST "+RoutePasskeysRegisterBegin, mainDomain(signedInRoute(BeginPasskeyRegistration(relyingParty, store, config.Timeout))))
+		mux.Handle("POST "+RoutePasskeysRegisterFinish, mainDomain(signedInRoute(FinishPasskeyRegistration(relyingParty, store, config.Timeout))))
+		mux.Handle("PUT "+RouteOrganizationsPasskeys, adminWithoutSubscription(PasskeyRequirementHandler(store, config.Timeout)))
+	} else {
+		slog.Error("Passkeys are disabled. Could not configure the relying party", "error", err, "baseURL", config.BaseURL)
+	}
 
 	mux.HandleFunc("GET "+RouteOrganizationsForm, OrganizationsHandler)
 	mux.Handle("POST "+RouteOrganizations, signedInRoute(OrganizationRegisterHandler(store, config.GetStripeIdProvider(), config.Timeout)))
-	mux.Handle("DELETE "+RouteOrganizations, adminWithoutSubscription(DeleteOrganizationHandler(store, config.Timeout)))
-	mux.Handle("GET "+RouteOrganizationsIdInvite, adminWithoutSubscription(InviteLink(config.BaseURL, config.CookieSecretSignKey)))
+	mux.Handle("DELETE "+RouteOrganizations, adminWithoutSubscription(AuditRoute(store, pkg.AuditOrganizationDeleted, auditOrganization)(DeleteOrganizationHandler(store, config.Timeout))))
+	mux.Handle("GET "+RouteOrganizationsIdInvite, adminWithoutSubscription(AuditRoute(store, pkg.AuditInviteCreated, auditOrganization)(InviteLink(store, config))))
+	mux.Handle("POST "+RouteOrganizationsIdInvitations, adminWithoutSubscription(AuditRoute(store, pkg.AuditInviteCreated, auditInvitedEmail)(emitEvents(CreateInvitationHandler(store, config)))))
+	mux.Handle("GET "+RouteOrganizationsIdInvitations, adminWithoutSubscription(InvitationsHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteOrganizationsIdInvites, adminWithoutSubscription(InviteLinksHandler(store, config.Timeout)))
+	mux.Handle("DELETE "+RouteInvitesId, adminWithoutSubscription(AuditRoute(store, pkg.AuditInviteRevoked, auditPathId)(RevokeInviteLinkHandler(store, config.Timeout))))
 	mux.Handle("GET "+RouteOrganizationsOptions, userInfoRoute(OptionsFromSessionHandler(store, config.Timeout)))
 	mux.Handle("GET "+RouteOrganizationsActiveSession, userInfoRoute(http.HandlerFunc(ChosenOrganizationSessionHandler)))
 	mux.Handle("GET "+RouteOrganizationsUsers, readRoute(AllUsers(store, config.Timeout)))
-	mux.Handle("DELETE "+RouteOrganizationsUsersId, adminWithoutSubscription(DeleteUserFromOrg(store, config.Timeout)))
+	mux.Handle("DELETE "+RouteOrganizationsUsersId, adminWithoutSubscription(AuditRoute(store, pkg.AuditMemberRemoved, auditPathId)(DeleteUserFromOrg(store, config.Timeout))))
 	mux.Handle("POST "+RouteOrganizationsRecipent, adminWithoutSubscription(RegisterRecipent(store, config.Timeout)))
 	mux.Handle("POST "+RouteOrganizationsUsersIdGroups, readRoute(GroupHandler(store, config.Timeout)))
 	mux.Handle("DELETE "+RouteOrganizationsUsersIdGroups, readRoute(GroupHandler(store, config.Timeout)))
-	mux.Handle("POST "+RouteOrganizationsUsersIdRole, adminWithoutSubscription(AssignRoleHandler(store, config.Timeout)))
+	mux.Handle("POST "+RouteOrganizationsUsersIdRole, adminWithoutSubscription(AuditRoute(store, pkg.AuditRoleChanged, auditAssignedRole)(AssignRoleHandler(store, config.Timeout))))
+	mux.Handle("GET "+RouteOrganizationsBranding, readRoute(BrandingFormHandler(store, config.Timeout)))
+	mux.Handle("PUT "+RouteOrganizationsBranding, adminWithoutSubscription(UpdateBrandingHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteOrganizationsProjectTemplates, readRoute(ProjectTemplatesHandler(store, config.Timeout)))
+	mux.Handle("POST "+RouteOrganizationsProjectTemplates, adminWithoutSubscription(SubmitProjectTemplateHandler(store, config.Timeout)))
+	mux.Handle("DELETE "+RouteOrganizationsProjectTemplatesId, adminWithoutSubscription(DeleteProjectTemplateHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteOrganizationsLayout, adminWithoutSubscription(ExportLayoutHandler(store, config.Timeout)))
+	mux.Handle("POST "+RouteOrganizationsLayout, adminWithoutSubscription(ImportLayoutHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteOrganizationsProblems, librarianWithoutSubscription(ProblemReportsHandler(store, config.Timeout)))
+	mux.Handle("PUT "+RouteOrganizationsProblemsIdStatus, librarianWithoutSubscription(ProblemReportStatusHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteOrganizationsCorrections, readRoute(CorrectionsHandler(store, config.Timeout)))
+	mux.Handle("POST "+RouteOrganizationsCorrectionsProfile, readRoute(ProposeProfileCorrectionHandler(store, config.Timeout)))
+	mux.Handle("PUT "+RouteOrganizationsCorrectionsId, librarianWithoutSubscription(CorrectionDecisionHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteOrganizationsAudit, adminWithoutSubscription(AuditLogHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteOrganizationsWebhook, readRoute(WebhookHandler(store, config.Timeout)))
+	mux.Handle("PUT "+RouteOrganizationsWebhook, adminWithoutSubscription(AuditRoute(store, pkg.AuditWebhookUpdated, auditWebhookUrl)(SaveWebhookHandler(store, config.Timeout))))
+	mux.Handle("DELETE "+RouteOrganizationsWebhook, adminWithoutSubscription(AuditRoute(store, pkg.AuditWebhookRemoved, auditOrganization)(DeleteWebhookHandler(store, config.Timeout))))
+	mux.Handle("GET "+RouteOrganizationsWebhookDeliveries, adminWithoutSubscription(WebhookDeliveriesHandler(store, config.Timeout)))
+	logExports := pkg.NewLogExports(config.LogExportDir, config.LogExportExpiry)
+	mux.Handle("POST "+RouteOrganizationsLogsExports, adminWithoutSubscription(CreateLogExportHandler(store, logExports, config)))
+	mux.Handle("GET "+RouteOrganizationsLogsExportsId, adminWithoutSubscription(LogExportDownloadHandler(logExports)))
 
 	mux.Handle("GET "+RouteSessionActiveOrganizationName, requireAuthSession(ActiveOrganization(store, config.Timeout)))
 	mux.Handle("GET "+RouteSessionLoggedIn, requireAuthSession(http.HandlerFunc(LoggedIn)))
+	mux.Handle("GET "+RouteSessionBrandingCss, requireAuthSession(BrandingCSSHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteSessionBrandingLogo, requireAuthSession(BrandingLogoHandler(store, config.Timeout)))
+
+	mux.Handle("GET "+RouteAnnouncements, requireAuthSession(AnnouncementsHandler(store, config.Timeout)))
+	mux.Handle("POST "+RouteAnnouncements, adminRoute(CreateAnnouncementHandler(store, config)))
+	mux.Handle("POST "+RouteAnnouncementsIdRead, readRoute(MarkAnnouncementReadHandler(store, config.Timeout)))
+	mux.Handle("POST "+RouteAnnouncementsIdExpire, adminWithoutSubscription(ExpireAnnouncementHandler(store, config.Timeout)))
+
+	mux.Handle("GET "+RouteTokens, readRoute(ApiTokensHandler(store, config.Timeout)))
+	mux.Handle("POST "+RouteTokens, readRoute(CreateApiTokenHandler(store, config.Timeout)))
+	mux.Handle("DELETE "+RouteTokensId, readRoute(RevokeApiTokenHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteSessions, signedInRoute(SessionsHandler(store, config)))
+	mux.Handle("DELETE "+RouteSessionsId, signedInRoute(RevokeSessionHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteHintsHint, requireAuthSession(HintHandler(store, config.Timeout)))
+	mux.Handle("POST "+RouteHintsHintSeen, signedInRoute(HintSeenHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteEmailVerify, requireAuthSession(VerifyEmailHandler(store, config.CookieSecretSignKey, config.Timeout)))
+	mux.Handle("POST "+RouteEmailVerification, userInfoRoute(ResendVerificationHandler(store, config)))
+	mux.Handle("GET "+RouteAccount, userInfoRoute(AccountHandler(store, config.Timeout)))
+	mux.Handle("DELETE "+RouteAccount, userInfoRoute(DeleteAccountHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteDashboard, requireAuthSession(DashboardHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteOnboarding, requireAuthSession(OnboardingHandler(store, config)))
+	mux.Handle("POST "+RouteOnboardingStepsStep, adminWithoutSubscription(CompleteOnboardingStepHandler(store, config)))
+	mux.Handle("DELETE "+RouteOnboarding, adminWithoutSubscription(DismissOnboardingHandler(store, config.Timeout)))
+
+	scimRoute := RequireScimToken(store, config.Timeout)
+	mux.Handle("GET "+RouteScimUsers, scimRoute(ScimUsersHandler(store, config.Timeout)))
+	mux.Handle("POST "+RouteScimUsers, scimRoute(CreateScimUserHandler(store, config)))
+	mux.Handle("GET "+RouteScimUsersId, scimRoute(ScimUserHandler(store, config.Timeout)))
+	mux.Handle("PUT "+RouteScimUsersId, scimRoute(ReplaceScimUserHandler(store, config.Timeout)))
+	mux.Handle("PATCH "+RouteScimUsersId, scimRoute(PatchScimUserHandler(store, config.Timeout)))
+	mux.Handle("DELETE "+RouteScimUsersId, scimRoute(AuditRoute(store, pkg.AuditMemberRemoved, auditPathId)(DeleteScimUserHandler(store, config.Timeout))))
+	mux.Handle("GET "+RouteScimGroups, scimRoute(ScimGroupsHandler(store, config.Timeout)))
+	mux.Handle("POST "+RouteScimGroups, scimRoute(CreateScimGroupHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteScimGroupsId, scimRoute(ScimGroupHandler(store, config.Timeout)))
+	mux.Handle("PUT "+RouteScimGroupsId, scimRoute(ReplaceScimGroupHandler(store, config.Timeout)))
+	mux.Handle("PATCH "+RouteScimGroupsId, scimRoute(PatchScimGroupHandler(store, config.Timeout)))
+	mux.Handle("DELETE "+RouteScimGroupsId, scimRoute(DeleteScimGroupHandler(store, config.Timeout)))
+
+	health, _ := store.(pkg.HealthReporter)
+	mux.Handle("GET "+RouteStatusBanner, MaintenanceBannerHandler(health))
 
 	mux.HandleFunc("GET "+RoutePeople, PeoplePage)
-	mux.Handle("POST "+RouteSubscriptionPage, adminWithoutSubscription(checkoutSessionHandler(config, store)))
+	mux.Handle("POST "+RouteSubscriptionPage, adminWithoutSubscription(AuditRoute(store, pkg.AuditSubscriptionChanged, auditSubscriptionPlan)(checkoutSessionHandler(config, store))))
 
 	subscriptionHandler := SubscriptionHandler{store: store, timeout: config.Timeout}
 	mux.Handle("GET "+RouteSubscription, readRoute(&subscriptionHandler))
-	mux.Handle("POST "+RoutePayment, stripeWebhookHandler(store, config))
+	mux.Handle("POST "+RoutePayment, emitEvents(stripeWebhookHandler(store, config)))
 
 	mux.Handle("GET "+RouteAbout, http.HandlerFunc(AboutUs))
 
@@ -1397,5 +2411,28 @@ func Setup(store pkg.Store, config *pkg.Config, cookieStore *sessions.CookieStor
 		PortalSessionProvider: config.GetPortalSessionProvider(),
 	}
 	mux.Handle(RouteCustomerPortal, adminWithoutSubscription(&billingHandler))
-	return mux
+
+	platformAdminRoute := RequirePlatformAdminSession(config.PlatformAdmins, store, config, cookieStore, sessionOpt)
+	mux.Handle("GET "+RouteAdminMetrics, platformAdminRoute(FeatureMetricsHandler(store, config.Timeout)))
+	mux.Handle("PUT "+RouteAdminOrganizationsIdDomain, platformAdminRoute(UpdateDomainHandler(store, config.Timeout)))
+	mux.Handle("GET "+RouteDebugConfig, platformAdminRoute(DebugConfigHandler(config)))
+	mux.Handle("GET "+RouteDebugTranslations, platformAdminRoute(DebugTranslationsHandler()))
+	mux.Handle("POST "+RouteAdminOrganizationsIdImpersonate, platformAdminRoute(StartImpersonationHandler(store, config.Timeout)))
+	mux.Handle("DELETE "+RouteAdminImpersonation, platformAdminRoute(StopImpersonationHandler(store, config.Timeout)))
+
+	if config.MockOAuth {
+		if _, ok := store.(*pkg.MultiOrgInMemoryStore); ok {
+			slog.Warn("Mock OAuth provider is enabled. Should not be used in production!")
+			mockUsers := pkg.MockOAuthUsers()
+			mux.Handle("GET "+pkg.MockOAuthAuthorizePath, MockOAuthAuthorizeHandler(mockUsers, config.BaseURL))
+			mux.Handle("POST "+pkg.MockOAuthTokenPath, MockOAuthTokenHandler(mockUsers))
+			mux.Handle("GET "+pkg.MockOAuthUserInfoPath, MockOAuthUserInfoHandler(mockUsers))
+		} else {
+			slog.Warn("Mock OAuth provider is only available for in-memory stores")
+		}
+	}
+
+	mux.Handle("GET "+RouteApiOpenAPI, OpenAPIHandler(mux.Patterns))
+	mux.HandleFunc("GET "+RouteApiDocs, ApiDocsHandler)
+	return mux.ServeMux
 }
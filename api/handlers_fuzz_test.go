@@ -54,11 +54,38 @@ func FuzzEndpoints(f *testing.F) {
 		RouteProjectsNames,
 		RouteProjectsInfo,
 		RouteProjectsId,
+		RouteProjectsIdActivity,
+		RouteProjectsIdMerge,
+		RouteProjectsIdOrder,
+		RouteProjectsIdExportPdf,
+		RouteProjectsIdResourceIdNotes,
+		RouteProjectsTemplatesOptions,
 		RouteResources,
 		RouteResourcesId,
 		RouteResourcesIdContent,
+		RouteApiResourcesIdManifest,
+		RouteApiWebDAVToken,
 		RouteResourcesIdSubmitForm,
+		RouteResourcesIdProtection,
+		RouteResourcesIdLink,
+		RouteSharedPart,
+		RouteSharedArchive,
+		RouteResourcesTrash,
+		RouteResourcesIdRestore,
+		RouteResourcesIdVersions,
+		RouteResourcesIdVersionsId,
+		RouteResourcesIdVersionsIdRestore,
 		RouteResourcesParts,
+		RouteResourcesSuggest,
+		RouteResourcesUploads,
+		RouteResourcesUploadsId,
+		RouteSubmitProgress,
+		RouteSubmitProgressId,
+		RouteResourcesPartsArchives,
+		RouteResourcesPartsArchivesId,
+		RouteAnnouncements,
+		RouteAnnouncementsIdRead,
+		RouteAnnouncementsIdExpire,
 		RouteLogin,
 		RouteLoginBasic,
 		RouteLoginReset,
@@ -67,6 +94,9 @@ func FuzzEndpoints(f *testing.F) {
 		RouteOrganizations,
 		RouteOrganizationsForm,
 		RouteOrganizationsIdInvite,
+		RouteOrganizationsIdInvitations,
+		RouteOrganizationsIdInvites,
+		RouteInvitesId,
 		RouteOrganizationsOptions,
 		RouteOrganizationsActiveSession,
 		RouteOrganizationsUsers,
@@ -74,12 +104,63 @@ func FuzzEndpoints(f *testing.F) {
 		RouteOrganizationsUsersIdGroups,
 		RouteOrganizationsUsersIdRole,
 		RouteOrganizationsRecipent,
+		RouteOrganizationsBranding,
+		RouteOrganizationsLogsExports,
+		RouteOrganizationsLogsExportsId,
+		RouteOrganizationsProjectTemplates,
+		RouteOrganizationsProjectTemplatesId,
+		RouteOrganizationsProblems,
+		RouteOrganizationsProblemsIdStatus,
+		RouteOrganizationsCorrections,
+		RouteOrganizationsAudit,
+		RouteOrganizationsCorrectionsProfile,
+		RouteOrganizationsCorrectionsId,
+		RouteOrganizationsPasskeys,
+		RouteOrganizationsWebhook,
+		RouteOrganizationsWebhookDeliveries,
+		RoutePasskeys,
+		RoutePasskeysId,
+		RoutePasskeysRegisterBegin,
+		RoutePasskeysRegisterFinish,
+		RouteLoginPasskeyBegin,
+		RouteLoginPasskeyFinish,
+		RouteTokens,
+		RouteTokensId,
+		RouteDashboard,
+		RouteOnboarding,
+		RouteOnboardingStepsStep,
+		RouteSessions,
+		RouteSessionsId,
+		RouteHintsHint,
+		RouteHintsHintSeen,
+		RouteEmailVerify,
+		RouteEmailVerification,
+		RouteOrganizationsLayout,
+		RouteAccount,
+		RoutePalette,
+		RouteResourcesIdProblems,
+		RouteResourcesIdCorrections,
 		RouteSessionActiveOrganizationName,
 		RouteSessionLoggedIn,
+		RouteSessionBrandingCss,
+		RouteSessionBrandingLogo,
+		RouteStatusBanner,
 		RoutePeople,
 		RoutePayment,
 		RouteAbout,
 		RoutePassword,
+		RouteAdminMetrics,
+		RouteOverviewBulkEdit,
+		RouteResourcesMetadata,
+		RouteResourcesMetadataTable,
+		RouteResourcesBulk,
+		RouteResourcesExport,
+		RouteResourcesImport,
+		RouteAdminOrganizationsIdDomain,
+		RouteDebugConfig,
+		RouteDebugTranslations,
+		RouteAdminOrganizationsIdImpersonate,
+		RouteAdminImpersonation,
 	}
 
 	numSubsequentCalls := 40
@@ -7,8 +7,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
 	"io"
 	"iter"
+	"maps"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
@@ -24,6 +26,7 @@ import (
 	"github.com/davidkleiven/caesura/pkg"
 	"github.com/davidkleiven/caesura/testutils"
 	"github.com/davidkleiven/caesura/utils"
+	"github.com/davidkleiven/caesura/web"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/sessions"
 	"github.com/pdfcpu/pdfcpu/pkg/api"
@@ -165,7 +168,7 @@ func TestSubmitBadRequestHandler(t *testing.T) {
 	request := httptest.NewRequest("POST", "/resources", nil)
 	request.Header.Set("Content-Type", "multipart/form-data")
 
-	handler := SubmitHandler(pkg.NewMultiOrgInMemoryStore(), 10*time.Second, 10)
+	handler := SubmitHandler(pkg.NewMultiOrgInMemoryStore(), nil, 10*time.Second, 10)
 	handler(recorder, request)
 
 	if recorder.Code != http.StatusBadRequest {
@@ -307,7 +310,7 @@ func TestSubmitHandlerValidRequest(t *testing.T) {
 	request.Header.Set("Content-Type", contentType)
 	request = withAuthSession(request, "orgId")
 
-	handler := SubmitHandler(inMemStore, 10*time.Second, 10)
+	handler := SubmitHandler(inMemStore, nil, 10*time.Second, 10)
 	handler(recorder, request)
 
 	if recorder.Code != http.StatusOK {
@@ -315,10 +318,7 @@ func TestSubmitHandlerValidRequest(t *testing.T) {
 
 	}
 
-	expectedResponse := "File uploaded successfully"
-	if !strings.Contains(recorder.Body.String(), expectedResponse) {
-		t.Fatalf("Expected response body to contain '%s', got '%s'", expectedResponse, recorder.Body.String())
-	}
+	testutils.AssertContains(t, recorder.Header().Get("HX-Trigger"), string(EventResourceUploaded), "File uploaded successfully")
 
 	if len(inMemStore.Data) != 1 {
 		t.Fatalf("Expected 1 file in store, got %d", len(inMemStore.Data))
@@ -360,7 +360,7 @@ func TestSubmitHandlerInvalidJson(t *testing.T) {
 	request := httptest.NewRequest("POST", "/resources", &multipartBuffer)
 	request.Header.Set("Content-Type", multipartWriter.FormDataContentType())
 
-	handler := SubmitHandler(inMemStore, 10*time.Second, 10)
+	handler := SubmitHandler(inMemStore, nil, 10*time.Second, 10)
 	handler(recorder, request)
 
 	if recorder.Code != http.StatusBadRequest {
@@ -388,7 +388,7 @@ func TestSubmitFormWithoutDocument(t *testing.T) {
 	request := httptest.NewRequest("POST", "/resources", &multipartBuffer)
 	request.Header.Set("Content-Type", multipartWriter.FormDataContentType())
 
-	handler := SubmitHandler(inMemStore, 10*time.Second, 10)
+	handler := SubmitHandler(inMemStore, nil, 10*time.Second, 10)
 	handler(recorder, request)
 
 	if recorder.Code != http.StatusBadRequest {
@@ -412,7 +412,7 @@ func TestSubmitNonPdfFileAsDocument(t *testing.T) {
 	request.Header.Set("Content-Type", contentType)
 	request = withAuthSession(request, "orgId")
 
-	handler := SubmitHandler(inMemStore, 10*time.Second, 10)
+	handler := SubmitHandler(inMemStore, nil, 10*time.Second, 10)
 	handler(recorder, request)
 
 	if recorder.Code != http.StatusInternalServerError {
@@ -434,7 +434,7 @@ func TestSubmitHandlerNoAssignments(t *testing.T) {
 	request := httptest.NewRequest("POST", "/resources", multipartBuffer)
 	request.Header.Set("Content-Type", contentType)
 
-	handler := SubmitHandler(inMemStore, 10*time.Second, 10)
+	handler := SubmitHandler(inMemStore, nil, 10*time.Second, 10)
 	handler(recorder, request)
 
 	if recorder.Code != http.StatusBadRequest {
@@ -456,7 +456,7 @@ func TestSubmitHandlerNoMetaData(t *testing.T) {
 	request := httptest.NewRequest("POST", "/resources", multipartBuffer)
 	request.Header.Set("Content-Type", contentType)
 
-	handler := SubmitHandler(inMemStore, 10*time.Second, 10)
+	handler := SubmitHandler(inMemStore, nil, 10*time.Second, 10)
 	handler(recorder, request)
 
 	if recorder.Code != http.StatusBadRequest {
@@ -478,7 +478,7 @@ func TestSubmitHandlerInvalidMetaData(t *testing.T) {
 	request := httptest.NewRequest("POST", "/resources", multipartBuffer)
 	request.Header.Set("Content-Type", contentType)
 
-	handler := SubmitHandler(inMemStore, 10*time.Second, 10)
+	handler := SubmitHandler(inMemStore, nil, 10*time.Second, 10)
 	handler(recorder, request)
 
 	if recorder.Code != http.StatusBadRequest {
@@ -508,7 +508,7 @@ func TestSubmitHandlerStoreErrors(t *testing.T) {
 	request.Header.Set("Content-Type", contentType)
 	request = withAuthSession(request, "someOrg")
 
-	handler := SubmitHandler(&failingSubmitter{err: errors.New("what??")}, 10*time.Second, 10)
+	handler := SubmitHandler(&failingSubmitter{err: errors.New("what??")}, nil, 10*time.Second, 10)
 	handler(recorder, request)
 
 	if recorder.Code != http.StatusInternalServerError {
@@ -517,6 +517,19 @@ func TestSubmitHandlerStoreErrors(t *testing.T) {
 	}
 }
 
+func TestSubmitHandlerProtectedResource(t *testing.T) {
+	recorder := httptest.NewRecorder()
+
+	multipartBuffer, contentType := validMultipartForm()
+	request := httptest.NewRequest("POST", "/resources", multipartBuffer)
+	request.Header.Set("Content-Type", contentType)
+	request = withAuthSession(request, "someOrg")
+
+	handler := SubmitHandler(&failingSubmitter{err: pkg.ErrResourceProtected}, nil, 10*time.Second, 10)
+	handler(recorder, request)
+	testutils.AssertEqual(t, recorder.Code, http.StatusConflict)
+}
+
 func TestEntityTooLargeWhenUploadIsTooLarge(t *testing.T) {
 	inMemStore := pkg.NewMultiOrgInMemoryStore()
 	recorder := httptest.NewRecorder()
@@ -525,7 +538,7 @@ func TestEntityTooLargeWhenUploadIsTooLarge(t *testing.T) {
 	request := httptest.NewRequest("POST", "/resources", multipartBuffer)
 	request.Header.Set("Content-Type", contentType)
 
-	handler := SubmitHandler(inMemStore, 10*time.Second, 0)
+	handler := SubmitHandler(inMemStore, nil, 10*time.Second, 0)
 	handler(recorder, request)
 
 	if recorder.Code != http.StatusRequestEntityTooLarge {
@@ -541,7 +554,7 @@ func TestBadRequestOnEmptyResourceId(t *testing.T) {
 	request := httptest.NewRequest("POST", "/resources", multipartBuffer)
 	request.Header.Set("Content-Type", contentType)
 
-	handler := SubmitHandler(inMemStore, 10*time.Second, 4096)
+	handler := SubmitHandler(inMemStore, nil, 10*time.Second, 4096)
 	handler(recorder, request)
 
 	if recorder.Code != http.StatusBadRequest {
@@ -587,7 +600,7 @@ func TestOverviewSearchHandler(t *testing.T) {
 		store := pkg.NewDemoStore()
 		request = withAuthSession(request, store.FirstOrganizationId())
 
-		handler := OverviewSearchHandler(store, 10*time.Second)
+		handler := OverviewSearchHandler(store, pkg.NewTextIndex(store, time.Hour), 10*time.Second)
 		handler(recorder, request)
 
 		if recorder.Code != http.StatusOK {
@@ -608,6 +621,135 @@ func TestOverviewSearchHandler(t *testing.T) {
 	}
 }
 
+// followLoadMore requests the overview starting at target and follows the "load more" rows. It returns the ids
+// of the listed resources and the targets of the requests
+func followLoadMore(t *testing.T, handler http.HandlerFunc, orgId, target string) ([]string, []string) {
+	t.Helper()
+	var ids, targets []string
+	for target != "" {
+		targets = append(targets, target)
+		recorder := httptest.NewRecorder()
+		handler(recorder, withAuthSession(httptest.NewRequest("GET", target, nil), orgId))
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+
+		body := recorder.Body.String()
+		testutils.AssertEqual(t, strings.Count(body, "<tr id=\"row"), 1)
+		start := strings.Index(body, "<tr id=\"row-") + len("<tr id=\"row-")
+		ids = append(ids, body[start:start+strings.Index(body[start:], "\"")])
+
+		target = ""
+		if strings.Contains(body, "load-more-row") {
+			start := strings.Index(body, "hx-get=\"") + len("hx-get=\"")
+			target = html.UnescapeString(body[start : start+strings.Index(body[start:], "\"")])
+		}
+	}
+	return ids, targets
+}
+
+func TestOverviewSearchLoadMore(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	handler := OverviewSearchHandler(store, pkg.NewTextIndex(store, time.Hour), time.Second)
+
+	ids, targets := followLoadMore(t, handler, orgId, "/overview/search?resource-filter=&limit=1")
+	testutils.AssertEqual(t, len(ids), 2)
+	testutils.AssertEqual(t, ids[0] < ids[1], true)
+	testutils.AssertContains(t, targets[1], "resource-filter=", "limit=1")
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, withAuthSession(httptest.NewRequest("GET", "/overview/search?cursor=invalid", nil), orgId))
+	testutils.AssertEqual(t, recorder.Code, http.StatusBadRequest)
+}
+
+func TestOverviewSearchSorted(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	handler := OverviewSearchHandler(store, pkg.NewTextIndex(store, time.Hour), time.Second)
+
+	ids, targets := followLoadMore(t, handler, orgId, "/overview/search?limit=1&sort=title&direction=desc")
+	testutils.AssertEqual(t, len(ids), 2)
+	testutils.AssertEqual(t, ids[0] > ids[1], true)
+	testutils.AssertContains(t, targets[1], "sort=title", "direction=desc")
+
+	for _, query := range []string{"sort=genre", "sort=title&direction=up"} {
+		recorder := httptest.NewRecorder()
+		handler(recorder, withAuthSession(httptest.NewRequest("GET", "/overview/search?"+query, nil), orgId))
+		testutils.AssertEqual(t, recorder.Code, http.StatusBadRequest)
+	}
+}
+
+func TestOverviewSearchInsideScores(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	meta := store.Data[orgId].Metadata[0]
+	text := pkg.ResourceText{ResourceId: meta.ResourceId(), Words: []string{"kyrie", "eleison"}}
+	testutils.AssertNil(t, store.StoreResourceText(context.Background(), orgId, &text))
+	handler := OverviewSearchHandler(store, pkg.NewTextIndex(store, time.Hour), 10*time.Second)
+
+	for _, test := range []struct {
+		query         string
+		expectedCount int
+	}{
+		{"resource-filter=kyrie", 0},
+		{"resource-filter=kyrie&search-inside=true", 1},
+		{"resource-filter=eleis&search-inside=true", 1},
+		{"resource-filter=credo&search-inside=true", 0},
+	} {
+		recorder := httptest.NewRecorder()
+		request := withAuthSession(httptest.NewRequest("GET", "/overview/search?"+test.query, nil), orgId)
+		handler(recorder, request)
+
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertEqual(t, strings.Count(recorder.Body.String(), "<tr id=\"row"), test.expectedCount)
+	}
+}
+
+func TestResourceSuggestHandler(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	handler := ResourceSuggestHandler(store, time.Second)
+
+	t.Run("json", func(t *testing.T) {
+		request := withAuthSession(httptest.NewRequest("GET", "/resources/suggest?q=demo&limit=1", nil), orgId)
+		request.Header.Set("Accept", "application/json")
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertEqual(t, recorder.Header().Get("Content-Type"), "application/json")
+		var suggestions []pkg.Suggestion
+		testutils.AssertNil(t, json.NewDecoder(recorder.Body).Decode(&suggestions))
+		testutils.AssertEqual(t, len(suggestions), 1)
+		testutils.AssertContains(t, strings.ToLower(suggestions[0].Title), "demo")
+		testutils.AssertEqual(t, suggestions[0].Id != "", true)
+	})
+
+	t.Run("html", func(t *testing.T) {
+		request := withAuthSession(httptest.NewRequest("GET", "/resources/suggest?q=demo", nil), orgId)
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertEqual(t, strings.Count(recorder.Body.String(), "<option"), 2)
+		testutils.AssertNotContains(t, recorder.Body.String(), "<tr")
+	})
+
+	t.Run("empty query", func(t *testing.T) {
+		request := withAuthSession(httptest.NewRequest("GET", "/resources/suggest?q=+", nil), orgId)
+		request.Header.Set("Accept", "application/json")
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+		testutils.AssertEqual(t, strings.TrimSpace(recorder.Body.String()), "[]")
+	})
+
+	t.Run("fetch fails", func(t *testing.T) {
+		request := withAuthSession(httptest.NewRequest("GET", "/resources/suggest?q=demo", nil), orgId)
+		recorder := httptest.NewRecorder()
+		ResourceSuggestHandler(&failingFetcher{err: errors.New("fetch error")}, time.Second)(recorder, request)
+		testutils.AssertEqual(t, recorder.Code, http.StatusInternalServerError)
+	})
+}
+
 type failingFetcher struct {
 	err error
 }
@@ -616,13 +758,17 @@ func (f *failingFetcher) MetaByPattern(ctx context.Context, orgId string, patter
 	return nil, f.err
 }
 
+func (f *failingFetcher) MetaByPatternPage(ctx context.Context, orgId string, pattern *pkg.MetaData, sort pkg.MetaSort, page pkg.PageRequest) (pkg.CursorPage[pkg.MetaData], error) {
+	return pkg.CursorPage[pkg.MetaData]{}, f.err
+}
+
 func TestInternalServerErrorOnFailure(t *testing.T) {
 	expectedError := errors.New("fetch error")
 	recorder := httptest.NewRecorder()
 
 	request := httptest.NewRequest("GET", "/overview/search?resource-filter=flute", nil)
 	request = withAuthSession(request, "someOrg")
-	handler := OverviewSearchHandler(&failingFetcher{err: expectedError}, 10*time.Second)
+	handler := OverviewSearchHandler(&failingFetcher{err: expectedError}, pkg.NewTextIndex(pkg.NewMultiOrgInMemoryStore(), time.Hour), 10*time.Second)
 	handler(recorder, request)
 
 	if recorder.Code != http.StatusInternalServerError {
@@ -763,11 +909,12 @@ func TestBadRequestOnMissingName(t *testing.T) {
 	}
 }
 
-type failingProjectSubmitter struct {
+type failingTransactor struct {
+	pkg.ProjectTemplateGetter
 	err error
 }
 
-func (f *failingProjectSubmitter) SubmitProject(ctx context.Context, orgId string, project *pkg.Project) error {
+func (f *failingTransactor) RunTransaction(ctx context.Context, fn func(ctx context.Context, tx pkg.TxStore) error) error {
 	return f.err
 }
 
@@ -775,7 +922,7 @@ func TestInternaltServerErrorOnProjectSubmitFailure(t *testing.T) {
 	expectedError := errors.New("submit error")
 	recorder := httptest.NewRecorder()
 
-	inMemStore := &failingProjectSubmitter{err: expectedError}
+	inMemStore := &failingTransactor{err: expectedError}
 	form := url.Values{}
 	form.Set("projectQuery", "Test Project")
 	request := httptest.NewRequest("POST", "/projects", strings.NewReader(form.Encode()))
@@ -962,8 +1109,12 @@ type failingProjectByIdFetcher struct {
 func (f *failingProjectByIdFetcher) ProjectById(ctx context.Context, orgId, id string) (*pkg.Project, error) {
 	return &pkg.Project{Name: "Concert No. 1", ResourceIds: []string{"id1"}}, f.projectErr
 }
-func (f *failingProjectByIdFetcher) MetaById(ctx context.Context, orgId, id string) (*pkg.MetaData, error) {
-	return nil, f.metaErr
+func (f *failingProjectByIdFetcher) MetaByIds(ctx context.Context, orgId string, ids []string) []pkg.MetaLookup {
+	results := make([]pkg.MetaLookup, len(ids))
+	for i, id := range ids {
+		results[i] = pkg.MetaLookup{Id: id, Meta: &pkg.MetaData{}, Err: f.metaErr}
+	}
+	return results
 }
 
 func TestProjectByIdInternalServerError(t *testing.T) {
@@ -987,6 +1138,29 @@ func TestProjectByIdInternalServerError(t *testing.T) {
 	}
 }
 
+type slowProjectFetcher struct{}
+
+func (s *slowProjectFetcher) ProjectById(ctx context.Context, orgId, id string) (*pkg.Project, error) {
+	return &pkg.Project{Name: "Season", ResourceIds: []string{"a", "b", "c"}}, nil
+}
+
+func (s *slowProjectFetcher) MetaById(ctx context.Context, orgId, id string) (*pkg.MetaData, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (s *slowProjectFetcher) MetaByIds(ctx context.Context, orgId string, ids []string) []pkg.MetaLookup {
+	return pkg.FetchMetaByIds(ctx, s, orgId, ids, 2)
+}
+
+func TestProjectByIdTimesOutFetchingMetadata(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	request := withAuthSession(httptest.NewRequest("GET", "/projects/season", nil), "someOrg")
+	ProjectByIdHandler(&slowProjectFetcher{}, 10*time.Millisecond)(recorder, request)
+	testutils.AssertEqual(t, recorder.Code, http.StatusGatewayTimeout)
+	testutils.AssertNotContains(t, recorder.Body.String(), "Season")
+}
+
 func TestProjectByIdMetaDataError(t *testing.T) {
 	expectedError := errors.New("meta fetch error")
 	recorder := httptest.NewRecorder()
@@ -1023,6 +1197,27 @@ func TestSetup(t *testing.T) {
 	}
 }
 
+// serveWithRole sends a request through the routes of Setup, signed in as a registered member of orgId with role
+func serveWithRole(t *testing.T, store *pkg.MultiOrgInMemoryStore, orgId string, role pkg.RoleKind, req *http.Request) *httptest.ResponseRecorder {
+	user := pkg.UserInfo{Id: fmt.Sprintf("member-%d", role), Roles: map[string]pkg.RoleKind{orgId: role}, Groups: map[string][]string{}}
+	testutils.AssertNil(t, store.RegisterUser(context.Background(), &user))
+
+	cookieStore := sessions.NewCookieStore([]byte("some-random-key"))
+	session, err := cookieStore.New(req, AuthSession)
+	testutils.AssertNil(t, err)
+	pkg.PopulateSessionWithRoles(session, &user)
+	session.Values["userId"] = user.Id
+	saved := httptest.NewRecorder()
+	testutils.AssertNil(t, session.Save(req, saved))
+	for _, cookie := range saved.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+
+	recorder := httptest.NewRecorder()
+	Setup(store, pkg.NewDefaultConfig(), cookieStore).ServeHTTP(recorder, req)
+	return recorder
+}
+
 func TestResourceContentByIdHandler(t *testing.T) {
 	recorder := httptest.NewRecorder()
 	store := pkg.NewDemoStore()
@@ -1139,6 +1334,217 @@ func TestResourceDownloadSingleFile(t *testing.T) {
 	}
 }
 
+func TestResourceDownloadConditional(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &pkg.Organization{Id: "org"}))
+	var pdf bytes.Buffer
+	testutils.AssertNil(t, pkg.CreateNPagePdf(&pdf, 1))
+	parts := func(yield func(string, []byte) bool) {
+		_ = yield("Horn.pdf", pdf.Bytes()) && yield("Tuba.pdf", pdf.Bytes())
+	}
+	meta := pkg.MetaData{Title: "Polka"}
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, parts))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /resources/{id}", ResourceDownload(store, time.Second))
+	download := func(target, ifNoneMatch string) *httptest.ResponseRecorder {
+		request := withAuthSession(httptest.NewRequest("GET", target, nil), "org")
+		if ifNoneMatch != "" {
+			request.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	for _, target := range []string{"/resources/polka", "/resources/polka?file=Horn.pdf"} {
+		t.Run(target, func(t *testing.T) {
+			recorder := download(target, "")
+			testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+			etag := recorder.Header().Get("ETag")
+			testutils.AssertEqual(t, etag != "", true)
+
+			recorder = download(target, `"other", `+etag)
+			testutils.AssertEqual(t, recorder.Code, http.StatusNotModified)
+			testutils.AssertEqual(t, recorder.Body.Len(), 0)
+			testutils.AssertEqual(t, recorder.Header().Get("ETag"), etag)
+
+			testutils.AssertEqual(t, download(target, `"other"`).Code, http.StatusOK)
+		})
+	}
+
+	horn := download("/resources/polka?file=Horn.pdf", "").Header().Get("ETag")
+	testutils.AssertEqual(t, download("/resources/polka?file=Tuba.pdf", "").Header().Get("ETag") != horn, true)
+
+	t.Run("changed parts", func(t *testing.T) {
+		testutils.AssertNil(t, store.Submit(ctx, "org", &pkg.MetaData{Title: "Polka"}, parts))
+		testutils.AssertEqual(t, download("/resources/polka?file=Horn.pdf", horn).Code, http.StatusOK)
+	})
+
+	t.Run("missing part", func(t *testing.T) {
+		recorder := download("/resources/polka?file=Trumpet.pdf", "")
+		testutils.AssertEqual(t, recorder.Code, http.StatusNotFound)
+		testutils.AssertEqual(t, recorder.Header().Get("ETag"), "")
+	})
+}
+
+func TestResourceDownloadRange(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &pkg.Organization{Id: "org"}))
+	var pdf bytes.Buffer
+	testutils.AssertNil(t, pkg.CreateNPagePdf(&pdf, 2))
+	parts := func(yield func(string, []byte) bool) {
+		yield("Horn.pdf", pdf.Bytes())
+	}
+	testutils.AssertNil(t, store.Submit(ctx, "org", &pkg.MetaData{Title: "Polka"}, parts))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /resources/{id}", ResourceDownload(store, time.Second))
+	download := func(target string, headers map[string]string) *httptest.ResponseRecorder {
+		request := withAuthSession(httptest.NewRequest("GET", target, nil), "org")
+		for key, value := range headers {
+			request.Header.Set(key, value)
+		}
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	full := download("/resources/polka?file=Horn.pdf", nil)
+	testutils.AssertEqual(t, full.Code, http.StatusOK)
+	testutils.AssertEqual(t, full.Header().Get("Accept-Ranges"), "bytes")
+	testutils.AssertEqual(t, full.Header().Get("Content-Type"), "application/pdf")
+	testutils.AssertEqual(t, bytes.Equal(full.Body.Bytes(), pdf.Bytes()), true)
+	etag := full.Header().Get("ETag")
+
+	t.Run("range", func(t *testing.T) {
+		recorder := download("/resources/polka?file=Horn.pdf", map[string]string{"Range": "bytes=0-9"})
+		testutils.AssertEqual(t, recorder.Code, http.StatusPartialContent)
+		testutils.AssertEqual(t, recorder.Header().Get("Content-Range"), fmt.Sprintf("bytes 0-9/%d", pdf.Len()))
+		testutils.AssertEqual(t, recorder.Body.String(), string(pdf.Bytes()[:10]))
+	})
+
+	t.Run("matching if-range", func(t *testing.T) {
+		recorder := download("/resources/polka?file=Horn.pdf", map[string]string{"Range": "bytes=10-", "If-Range": etag})
+		testutils.AssertEqual(t, recorder.Code, http.StatusPartialContent)
+		testutils.AssertEqual(t, recorder.Body.String(), string(pdf.Bytes()[10:]))
+	})
+
+	t.Run("stale if-range", func(t *testing.T) {
+		recorder := download("/resources/polka?file=Horn.pdf", map[string]string{"Range": "bytes=0-9", "If-Range": `"stale"`})
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertEqual(t, recorder.Body.Len(), pdf.Len())
+	})
+
+	t.Run("unsatisfiable range", func(t *testing.T) {
+		recorder := download("/resources/polka?file=Horn.pdf", map[string]string{"Range": fmt.Sprintf("bytes=%d-", pdf.Len())})
+		testutils.AssertEqual(t, recorder.Code, http.StatusRequestedRangeNotSatisfiable)
+	})
+
+	t.Run("zip is not ranged", func(t *testing.T) {
+		recorder := download("/resources/polka", map[string]string{"Range": "bytes=0-9"})
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertEqual(t, recorder.Header().Get("Accept-Ranges"), "")
+	})
+}
+
+// rangeRecordingClient records the ranges read from the bucket and the number of bytes read from them
+type rangeRecordingClient struct {
+	pkg.BlobClient
+	ranger    pkg.ObjectRangeReader
+	offsets   []int64
+	bytesRead int64
+}
+
+func (r *rangeRecordingClient) ObjectSize(ctx context.Context, bucket, object string) (int64, error) {
+	return r.ranger.ObjectSize(ctx, bucket, object)
+}
+
+func (r *rangeRecordingClient) NewRangeReader(ctx context.Context, bucket, object string, offset, length int64) (io.ReadCloser, error) {
+	r.offsets = append(r.offsets, offset)
+	reader, err := r.ranger.NewRangeReader(ctx, bucket, object, offset, length)
+	return &countingReadCloser{ReadCloser: reader, count: &r.bytesRead}, err
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+	count *int64
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	*c.count += int64(n)
+	return n, err
+}
+
+func TestResourceDownloadReadsOnlyRangeFromBucket(t *testing.T) {
+	store, err := pkg.NewLocalStore(&pkg.LocalFSStoreConfig{Directory: t.TempDir()})
+	testutils.AssertNil(t, err)
+	defer store.Close()
+	files := store.BucketClient.(*pkg.FileBucketClient)
+
+	ctx := context.Background()
+	var pdf bytes.Buffer
+	testutils.AssertNil(t, pkg.CreateNPagePdf(&pdf, 2))
+	parts := func(yield func(string, []byte) bool) {
+		yield("Horn.pdf", pdf.Bytes())
+	}
+	testutils.AssertNil(t, store.Submit(ctx, "org", &pkg.MetaData{Title: "Polka"}, parts))
+
+	download := func(headers map[string]string) *httptest.ResponseRecorder {
+		request := withAuthSession(httptest.NewRequest("GET", "/resources/polka?file=Horn.pdf", nil), "org")
+		for key, value := range headers {
+			request.Header.Set(key, value)
+		}
+		recorder := httptest.NewRecorder()
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /resources/{id}", ResourceDownload(store, time.Second))
+		mux.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	t.Run("range", func(t *testing.T) {
+		recording := rangeRecordingClient{BlobClient: files, ranger: files}
+		store.BucketClient = &recording
+		recorder := download(map[string]string{"Range": "bytes=10-19"})
+		testutils.AssertEqual(t, recorder.Code, http.StatusPartialContent)
+		testutils.AssertEqual(t, recorder.Body.String(), string(pdf.Bytes()[10:20]))
+		testutils.AssertEqual(t, slices.Equal(recording.offsets, []int64{10}), true)
+		testutils.AssertEqual(t, recording.bytesRead, int64(10))
+	})
+
+	t.Run("streamed without range reads", func(t *testing.T) {
+		store.BucketClient = struct{ pkg.BlobClient }{files}
+		recorder := download(map[string]string{"Range": "bytes=10-19"})
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertEqual(t, recorder.Header().Get("Content-Type"), "application/pdf")
+		testutils.AssertEqual(t, bytes.Equal(recorder.Body.Bytes(), pdf.Bytes()), true)
+	})
+}
+
+func TestResourceDownloadRehydratesColdResource(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	resourceId := store.Data[orgId].Metadata[0].ResourceId()
+	testutils.AssertNil(t, store.TransitionStorageClass(context.Background(), orgId, resourceId, pkg.StorageClassCold))
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/resources/"+resourceId, nil)
+	request = withAuthSession(request, orgId)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /resources/{id}", ResourceDownload(store, 1*time.Second))
+	mux.ServeHTTP(recorder, request)
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+
+	meta, err := store.MetaById(context.Background(), orgId, resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, meta.StorageClass, pkg.StorageClassStandard)
+	testutils.AssertEqual(t, meta.LastAccessed.IsZero(), false)
+}
+
 func TestNotFoundWhenRequestingNonExistingResource(t *testing.T) {
 	store := pkg.NewMultiOrgInMemoryStore()
 	orgId := "someOrg"
@@ -1154,6 +1560,104 @@ func TestNotFoundWhenRequestingNonExistingResource(t *testing.T) {
 	}
 }
 
+func TestNotFoundWhenResourceHasNoParts(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	meta := pkg.MetaData{Title: "Empty"}
+	store.Data[orgId].Metadata = append(store.Data[orgId].Metadata, meta)
+
+	recorder := httptest.NewRecorder()
+	request := withAuthSession(httptest.NewRequest("GET", "/resources/"+meta.ResourceId(), nil), orgId)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /resources/{id}", ResourceDownload(store, time.Second))
+	mux.ServeHTTP(recorder, request)
+	testutils.AssertEqual(t, recorder.Code, http.StatusNotFound)
+	testutils.AssertEqual(t, recorder.Header().Get("Content-Disposition"), "")
+}
+
+func TestResourceProtection(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	resourceId := store.Data[orgId].Metadata[0].ResourceId()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT "+RouteResourcesIdProtection, ResourceProtectionHandler(store, time.Second))
+	mux.HandleFunc("DELETE "+RouteResourcesIdProtection, ResourceProtectionHandler(store, time.Second))
+	mux.HandleFunc("DELETE "+RouteResourcesId, DeleteResourceHandler(store, time.Second))
+
+	serve := func(method, route string) int {
+		rec := httptest.NewRecorder()
+		req := withAuthSession(httptest.NewRequest(method, route, nil), orgId)
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	testutils.AssertEqual(t, serve("PUT", "/resources/"+resourceId+"/protection"), http.StatusOK)
+	meta, err := store.MetaById(context.Background(), orgId, resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, meta.Protected, true)
+
+	testutils.AssertEqual(t, serve("DELETE", "/resources/"+resourceId), http.StatusConflict)
+
+	testutils.AssertEqual(t, serve("DELETE", "/resources/"+resourceId+"/protection"), http.StatusOK)
+	testutils.AssertEqual(t, serve("DELETE", "/resources/"+resourceId), http.StatusOK)
+
+	meta, err = store.MetaById(context.Background(), orgId, resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, meta.Deleted, true)
+
+	t.Run("unknown resource", func(t *testing.T) {
+		testutils.AssertEqual(t, serve("PUT", "/resources/unknown/protection"), http.StatusNotFound)
+		testutils.AssertEqual(t, serve("DELETE", "/resources/unknown"), http.StatusNotFound)
+	})
+}
+
+func TestTrashAndRestore(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	meta := store.Data[orgId].Metadata[0]
+	resourceId := meta.ResourceId()
+	testutils.AssertNil(t, store.DeleteResource(context.Background(), orgId, resourceId))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+RouteResourcesTrash, TrashHandler(store, 30*24*time.Hour, time.Second))
+	mux.HandleFunc("POST "+RouteResourcesIdRestore, RestoreResourceHandler(store, time.Second))
+
+	serve := func(method, route string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, withAuthSession(httptest.NewRequest(method, route, nil), orgId))
+		return rec
+	}
+
+	rec := serve("GET", RouteResourcesTrash)
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Body.String(), meta.Title, "/resources/"+resourceId+"/restore")
+	testutils.AssertNotContains(t, rec.Body.String(), store.Data[orgId].Metadata[1].Title)
+
+	testutils.AssertEqual(t, serve("POST", "/resources/"+resourceId+"/restore").Code, http.StatusOK)
+	restored, err := store.MetaById(context.Background(), orgId, resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, restored.Deleted, false)
+	testutils.AssertNotContains(t, serve("GET", RouteResourcesTrash).Body.String(), meta.Title)
+
+	t.Run("unknown resource", func(t *testing.T) {
+		testutils.AssertEqual(t, serve("POST", "/resources/unknown/restore").Code, http.StatusNotFound)
+	})
+
+	t.Run("only librarians delete and restore", func(t *testing.T) {
+		for _, role := range []pkg.RoleKind{pkg.RoleEditor, pkg.RoleLibrarian} {
+			want := http.StatusUnauthorized
+			if role == pkg.RoleLibrarian {
+				want = http.StatusOK
+			}
+			rec := serveWithRole(t, store, orgId, role, httptest.NewRequest("DELETE", "/resources/"+resourceId, nil))
+			testutils.AssertEqual(t, rec.Code, want)
+			rec = serveWithRole(t, store, orgId, role, httptest.NewRequest("POST", "/resources/"+resourceId+"/restore", nil))
+			testutils.AssertEqual(t, rec.Code, want)
+		}
+	})
+}
+
 func TestDeleteResourceFromProjectHandler(t *testing.T) {
 	store := pkg.NewDemoStore()
 
@@ -1186,6 +1690,42 @@ func TestDeleteResourceFromProjectHandler(t *testing.T) {
 	}
 }
 
+func TestProjectNoteHandler(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	projectId := "demoproject1"
+	resourceId := store.Data[orgId].Projects[projectId].ResourceIds[0]
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT "+RouteProjectsIdResourceIdNotes, ProjectNoteHandler(store, time.Second))
+
+	serve := func(note string) *httptest.ResponseRecorder {
+		form := url.Values{"note": {note}}
+		req := httptest.NewRequest("PUT", "/projects/"+projectId+"/"+resourceId+"/notes", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, withAuthSession(req, orgId))
+		return rec
+	}
+
+	rec := serve("  Start at letter C, repeat the coda\n")
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), "Saved rehearsal notes")
+	testutils.AssertEqual(t, store.Data[orgId].Projects[projectId].Notes[resourceId], "Start at letter C, repeat the coda")
+
+	t.Run("too long", func(t *testing.T) {
+		rec := serve(strings.Repeat("a", maxProjectNoteLength+1))
+		testutils.AssertEqual(t, rec.Code, http.StatusBadRequest)
+		testutils.AssertEqual(t, store.Data[orgId].Projects[projectId].Notes[resourceId], "Start at letter C, repeat the coda")
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		testutils.AssertEqual(t, serve("").Code, http.StatusOK)
+		_, ok := store.Data[orgId].Projects[projectId].Notes[resourceId]
+		testutils.AssertEqual(t, ok, false)
+	})
+}
+
 type failingResourceRemover struct {
 	err error
 }
@@ -1253,8 +1793,8 @@ func TestAddResourceSubmitFormResourceNotFound(t *testing.T) {
 	mux.HandleFunc("/resources/{id}/submit-form", AddToResourceHandler(store, 1*time.Second))
 	mux.ServeHTTP(recorder, request)
 
-	if recorder.Code != http.StatusInternalServerError {
-		t.Fatalf("Expected code %d got %d", http.StatusInternalServerError, recorder.Code)
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("Expected code %d got %d", http.StatusNotFound, recorder.Code)
 	}
 }
 
@@ -1297,7 +1837,7 @@ func TestInviteLinkAddedToSession(t *testing.T) {
 
 	recorder := httptest.NewRecorder()
 	request := httptest.NewRequest("GET", "/login?invite-token=ddaa", nil)
-	handler := RequireSession(cookie, AuthSession, &opt)(http.HandlerFunc(LoginHandler))
+	handler := RequireSession(cookie, AuthSession, &opt)(LoginHandler(web.LoginProviders{}))
 	handler.ServeHTTP(recorder, request)
 
 	session, err := cookie.Get(request, AuthSession)
@@ -1338,7 +1878,7 @@ func TestHandleGoogleLoginCallbackOk(t *testing.T) {
 	req := prepareGoogleCallbackRequest(sessions.NewCookieStore([]byte("some-random-key")))
 	transport := NewMockTransport()
 	store := pkg.NewDemoStore()
-	handler := HandleGoogleCallback(store, pkg.NewDefaultConfig().OAuthConfig(), 1*time.Second, "signKey", transport)
+	handler := HandleGoogleCallback(store, pkg.NewDefaultConfig().OAuthConfig(), googleUserInfo, 1*time.Second, "signKey", transport)
 
 	recorder := httptest.NewRecorder()
 	handler(recorder, req)
@@ -1373,7 +1913,7 @@ func TestHandleGoogleLoginCallbackInvalidAuthState(t *testing.T) {
 
 	session.Values[OAuthState] = "altered-state-string"
 	store := pkg.NewMultiOrgInMemoryStore()
-	handler := HandleGoogleCallback(store, pkg.NewDefaultConfig().OAuthConfig(), time.Second, "signKey", nil)
+	handler := HandleGoogleCallback(store, pkg.NewDefaultConfig().OAuthConfig(), googleUserInfo, time.Second, "signKey", nil)
 
 	recorder := httptest.NewRecorder()
 	handler(recorder, req)
@@ -1394,7 +1934,7 @@ func TestOnlyIdPresentForNewUser(t *testing.T) {
 
 	store := pkg.NewMultiOrgInMemoryStore()
 	transport := NewMockTransport()
-	handler := HandleGoogleCallback(store, pkg.NewDefaultConfig().OAuthConfig(), time.Second, "signKey", transport)
+	handler := HandleGoogleCallback(store, pkg.NewDefaultConfig().OAuthConfig(), googleUserInfo, time.Second, "signKey", transport)
 
 	recorder := httptest.NewRecorder()
 	handler(recorder, req)
@@ -1429,7 +1969,7 @@ func TestInternalServerErrorOnCookieSaveFailure(t *testing.T) {
 	req := prepareGoogleCallbackRequest(&errorStore{})
 	store := pkg.NewDemoStore()
 	transport := NewMockTransport()
-	handler := HandleGoogleCallback(store, pkg.NewDefaultConfig().OAuthConfig(), time.Second, "signKey", transport)
+	handler := HandleGoogleCallback(store, pkg.NewDefaultConfig().OAuthConfig(), googleUserInfo, time.Second, "signKey", transport)
 
 	recorder := httptest.NewRecorder()
 	handler(recorder, req)
@@ -1454,7 +1994,7 @@ func TestHandleGoogleLoginCallbackBadRequestOnMissingCode(t *testing.T) {
 	req.ContentLength = int64(len(encoded))
 
 	store := pkg.NewMultiOrgInMemoryStore()
-	handler := HandleGoogleCallback(store, pkg.NewDefaultConfig().OAuthConfig(), time.Second, "signKey", nil)
+	handler := HandleGoogleCallback(store, pkg.NewDefaultConfig().OAuthConfig(), googleUserInfo, time.Second, "signKey", nil)
 	recorder := httptest.NewRecorder()
 	handler(recorder, req)
 
@@ -1473,7 +2013,7 @@ func TestInternalServerErrorOnCodeExchangeFailure(t *testing.T) {
 
 	transport := NewMockTransport(WithTokenResponse(NewNotFoundResponse()))
 	store := pkg.NewMultiOrgInMemoryStore()
-	handler := HandleGoogleCallback(store, pkg.NewDefaultConfig().OAuthConfig(), time.Second, "signKey", transport)
+	handler := HandleGoogleCallback(store, pkg.NewDefaultConfig().OAuthConfig(), googleUserInfo, time.Second, "signKey", transport)
 
 	recorder := httptest.NewRecorder()
 	handler(recorder, req)
@@ -1509,7 +2049,7 @@ func TestInternalServerErrorOnUserRespFailure(t *testing.T) {
 	} {
 		transport := NewMockTransport(WithUserInfoResponse(test.userResp))
 		store := pkg.NewMultiOrgInMemoryStore()
-		handler := HandleGoogleCallback(store, pkg.NewDefaultConfig().OAuthConfig(), time.Second, "signKey", transport)
+		handler := HandleGoogleCallback(store, pkg.NewDefaultConfig().OAuthConfig(), googleUserInfo, time.Second, "signKey", transport)
 		recorder := httptest.NewRecorder()
 		handler(recorder, req)
 
@@ -1526,7 +2066,7 @@ func TestInternalServerErrorOnUserRespFailure(t *testing.T) {
 
 func TestViewerFromInviteLink(t *testing.T) {
 	store := pkg.NewMultiOrgInMemoryStore()
-	inviteClaim := InviteClaim{OrgId: "new-organization"}
+	inviteClaim := InviteClaim{OrgId: "new-organization", RegisteredClaims: jwt.RegisteredClaims{Audience: jwt.ClaimStrings{inviteTokenAudience}}}
 	signKey := "top-secret"
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, inviteClaim)
 	signedToken, err := token.SignedString([]byte(signKey))
@@ -1541,7 +2081,7 @@ func TestViewerFromInviteLink(t *testing.T) {
 
 	transport := NewMockTransport()
 	recorder := httptest.NewRecorder()
-	handler := HandleGoogleCallback(store, pkg.NewDefaultConfig().OAuthConfig(), time.Second, signKey, transport)
+	handler := HandleGoogleCallback(store, pkg.NewDefaultConfig().OAuthConfig(), googleUserInfo, time.Second, signKey, transport)
 	handler(recorder, req)
 
 	if recorder.Code != http.StatusSeeOther {
@@ -1594,7 +2134,7 @@ func TestViewerFromInviteLink(t *testing.T) {
 
 func TestBadRequestOnWrongToken(t *testing.T) {
 	store := pkg.NewMultiOrgInMemoryStore()
-	inviteClaim := InviteClaim{OrgId: "new-organization"}
+	inviteClaim := InviteClaim{OrgId: "new-organization", RegisteredClaims: jwt.RegisteredClaims{Audience: jwt.ClaimStrings{inviteTokenAudience}}}
 	signKey := "top-secret"
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, inviteClaim)
 
@@ -1611,7 +2151,7 @@ func TestBadRequestOnWrongToken(t *testing.T) {
 
 	transport := NewMockTransport()
 	recorder := httptest.NewRecorder()
-	handler := HandleGoogleCallback(store, pkg.NewDefaultConfig().OAuthConfig(), time.Second, signKey, transport)
+	handler := HandleGoogleCallback(store, pkg.NewDefaultConfig().OAuthConfig(), googleUserInfo, time.Second, signKey, transport)
 	handler(recorder, req)
 
 	if recorder.Code != http.StatusBadRequest {
@@ -1629,7 +2169,7 @@ func TestInternalServerErrorOnFailingRoleHandling(t *testing.T) {
 	store := pkg.FailingRoleStore{
 		ErrRegisterRole: errors.New("some un expected error occured"),
 	}
-	inviteClaim := InviteClaim{OrgId: "new-organization"}
+	inviteClaim := InviteClaim{OrgId: "new-organization", RegisteredClaims: jwt.RegisteredClaims{Audience: jwt.ClaimStrings{inviteTokenAudience}}}
 	signKey := "top-secret"
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, inviteClaim)
 	signedToken, err := token.SignedString([]byte(signKey))
@@ -1644,7 +2184,11 @@ func TestInternalServerErrorOnFailingRoleHandling(t *testing.T) {
 
 	transport := NewMockTransport()
 	recorder := httptest.NewRecorder()
-	handler := HandleGoogleCallback(&store, pkg.NewDefaultConfig().OAuthConfig(), time.Second, signKey, transport)
+	loginStore := struct {
+		*pkg.FailingRoleStore
+		pkg.SessionRegistry
+	}{&store, pkg.NewMultiOrgInMemoryStore()}
+	handler := HandleGoogleCallback(loginStore, pkg.NewDefaultConfig().OAuthConfig(), googleUserInfo, time.Second, signKey, transport)
 	handler(recorder, req)
 
 	if recorder.Code != http.StatusInternalServerError {
@@ -1674,11 +2218,13 @@ func TestOrganizationHandler(t *testing.T) {
 
 func TestInviteLinkHandler(t *testing.T) {
 	url := "http://myapp.com"
-	secret := "top-secret"
-	handler := InviteLink(url, secret)
+	config := pkg.NewDefaultConfig()
+	config.BaseURL = url
+	store := pkg.NewMultiOrgInMemoryStore()
+	handler := InviteLink(store, config)
 
 	recorder := httptest.NewRecorder()
-	request := httptest.NewRequest("GET", "/organizations/1234-431/invite", nil)
+	request := withAuthSession(httptest.NewRequest("GET", "/organizations/1234-431/invite", nil), "1234-431")
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /organizations/{id}/invite", handler)
@@ -1689,6 +2235,7 @@ func TestInviteLinkHandler(t *testing.T) {
 
 	body := recorder.Body.String()
 	testutils.AssertContains(t, body, url, "/login?invite-token=", "invite_link")
+	testutils.AssertEqual(t, len(store.OrgInviteLinks["1234-431"]), 1)
 }
 
 func TestOrganizationRegisterFormErrors(t *testing.T) {
@@ -1762,6 +2309,9 @@ func TestOrganizationHandlerSuccess(t *testing.T) {
 			handler := OrganizationRegisterHandler(test.store, &pkg.LocalStripeCustomerIdProvider{}, time.Second)
 			handler(recorder, req.WithContext(ctx))
 			testutils.AssertEqual(t, recorder.Code, test.code)
+			if test.code == http.StatusOK {
+				testutils.AssertContains(t, recorder.Header().Get("HX-Trigger"), string(EventOnboardingUpdated))
+			}
 		})
 	}
 }
@@ -2083,6 +2633,16 @@ func TestAllUsers(t *testing.T) {
 		testutils.AssertContains(t, body, "Peter")
 		testutils.AssertNotContains(t, body, "John")
 	})
+
+	t.Run("Test admin gets a page", func(t *testing.T) {
+		session.Values["orgId"] = "1000"
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest("GET", "/users?limit=1", nil).WithContext(ctx))
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		body := recorder.Body.String()
+		testutils.AssertContains(t, body, "John", "load-more-row", "cursor=")
+		testutils.AssertNotContains(t, body, "Peter")
+	})
 }
 
 func TestAssignRoleHandler(t *testing.T) {
@@ -2107,6 +2667,27 @@ func TestAssignRoleHandler(t *testing.T) {
 	session.Values["orgId"] = "1000-0000"
 	ctx := context.WithValue(req.Context(), sessionKey, session)
 
+	t.Run("sessions of the member are revoked", func(t *testing.T) {
+		own := pkg.NewUserSession("0000-0000", "", "")
+		testutils.AssertNil(t, store.RegisterSession(ctx, own))
+		testutils.AssertNil(t, store.RegisterSession(ctx, pkg.NewUserSession("0000-0001", "", "")))
+
+		form := url.Values{}
+		form.Set("role", strconv.Itoa(pkg.RoleEditor))
+		req := httptest.NewRequest("POST", "/organizations/users/0000-0001/role", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req.WithContext(ctx))
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+
+		memberSessions, err := store.Sessions(ctx, "0000-0001")
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, len(memberSessions), 0)
+
+		_, err = store.Session(ctx, own.Id)
+		testutils.AssertNil(t, err)
+	})
+
 	t.Run("test can not alter self", func(t *testing.T) {
 		req := httptest.NewRequest("POST", "/organizations/users/0000-0000/role", nil)
 		recorder := httptest.NewRecorder()
@@ -2149,10 +2730,20 @@ func TestAssignRoleHandler(t *testing.T) {
 			wantRole: 1,
 			desc:     "Register writer role",
 		},
+		{
+			role:     3,
+			wantRole: pkg.RoleLibrarian,
+			desc:     "Register librarian role",
+		},
 		{
 			role:     42,
 			wantRole: 0,
-			desc:     "Role larger than admin, should set to reader",
+			desc:     "Unknown role, should set to reader",
+		},
+		{
+			role:     -1,
+			wantRole: 0,
+			desc:     "Negative role, should set to reader",
 		},
 	} {
 		t.Run(test.desc, func(t *testing.T) {
@@ -2173,7 +2764,10 @@ func TestAssignRoleHandler(t *testing.T) {
 		ErrRegisterRole: errors.New("something went wrong"),
 	}
 
-	failingHandler := AssignRoleHandler(&failingStore, time.Second)
+	failingHandler := AssignRoleHandler(struct {
+		*pkg.MockIAMStore
+		pkg.SessionRegistry
+	}{&failingStore, store}, time.Second)
 	t.Run("test registration fails", func(t *testing.T) {
 		form := url.Values{}
 		form.Set("role", "1")
@@ -2388,6 +2982,31 @@ func TestGroupHandler(t *testing.T) {
 	})
 }
 
+type staticHealth bool
+
+func (s staticHealth) Degraded() bool {
+	return bool(s)
+}
+
+func TestMaintenanceBannerHandler(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		reporter pkg.HealthReporter
+		want     string
+	}{
+		{"No reporter", nil, ""},
+		{"Healthy", staticHealth(false), ""},
+		{"Degraded", staticHealth(true), web.MaintenanceBanner("en")},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			MaintenanceBannerHandler(test.reporter)(rec, httptest.NewRequest("GET", RouteStatusBanner, nil))
+			testutils.AssertEqual(t, rec.Code, http.StatusOK)
+			testutils.AssertEqual(t, rec.Body.String(), test.want)
+		})
+	}
+}
+
 func TestLoggedIn(t *testing.T) {
 	store := sessions.NewCookieStore([]byte("top-secret"))
 	req := httptest.NewRequest("GET", "/endpoint", nil)
@@ -2419,13 +3038,21 @@ func TestLoginHandlerReturnInternalServerError(t *testing.T) {
 	session, err := store.Get(req, AuthSession)
 	ctx := context.WithValue(context.Background(), sessionKey, session)
 	testutils.AssertNil(t, err)
-	LoginHandler(rec, req.WithContext(ctx))
+	LoginHandler(web.LoginProviders{})(rec, req.WithContext(ctx))
 	testutils.AssertEqual(t, rec.Code, http.StatusInternalServerError)
 }
 
+// passwordLoginConfig returns a configuration that does not send any emails
+func passwordLoginConfig() *pkg.Config {
+	config := pkg.NewDefaultConfig()
+	config.CookieSecretSignKey = "secret"
+	config.SmtpConfig.SendFn = pkg.NoOpSendFunc
+	return config
+}
+
 func TestLoginByPasswordErrorOnTooLargeRequest(t *testing.T) {
 	store := pkg.NewMultiOrgInMemoryStore()
-	handler := LoginByPassword(store, "secret", time.Second)
+	handler := LoginByPassword(store, passwordLoginConfig(), pkg.NewLoginThrottler(&pkg.LoginThrottling{}))
 
 	body := bytes.Repeat([]byte("b"), 4096)
 	req := httptest.NewRequest("POST", "/login", bytes.NewBuffer(body))
@@ -2438,7 +3065,7 @@ func TestLoginByPasswordErrorOnTooLargeRequest(t *testing.T) {
 
 func TestRegisterUserAndLogin(t *testing.T) {
 	store := pkg.NewMultiOrgInMemoryStore()
-	handler := LoginByPassword(store, "secret", time.Second)
+	handler := LoginByPassword(store, passwordLoginConfig(), pkg.NewLoginThrottler(&pkg.LoginThrottling{}))
 	cookieStore := sessions.NewCookieStore([]byte("sign-key"))
 
 	form := url.Values{}
@@ -2493,7 +3120,7 @@ func TestLoginByUserFailToInitSession(t *testing.T) {
 	req := httptest.NewRequest("POST", "/login", bytes.NewBufferString(form.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	handler := LoginByPassword(store, "sign-secret", time.Second)
+	handler := LoginByPassword(store, passwordLoginConfig(), pkg.NewLoginThrottler(&pkg.LoginThrottling{}))
 	session, err := cookieStore.New(req, AuthSession)
 	testutils.AssertNil(t, err)
 	ctx := context.WithValue(context.Background(), sessionKey, session)
@@ -2503,9 +3130,34 @@ func TestLoginByUserFailToInitSession(t *testing.T) {
 	testutils.AssertContains(t, rec.Body.String(), "broken session store")
 }
 
+func TestLoginByPasswordThrottled(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	throttler := pkg.NewLoginThrottler(&pkg.LoginThrottling{MaxFailures: 2, MaxFailuresPerIp: 10, BaseDelay: time.Second, Lockout: time.Minute})
+	handler := LoginByPassword(store, passwordLoginConfig(), throttler)
+
+	login := func() *httptest.ResponseRecorder {
+		form := url.Values{"email": {"john@example.com"}, "password": {"wrong-password"}}
+		req := httptest.NewRequest("POST", "/login", bytes.NewBufferString(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept-Language", "nb")
+		rec := httptest.NewRecorder()
+		handler(rec, withEmptySession(req))
+		return rec
+	}
+
+	rec := login()
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+
+	rec = login()
+	testutils.AssertEqual(t, rec.Code, http.StatusTooManyRequests)
+	testutils.AssertEqual(t, rec.Header().Get("Retry-After"), "1")
+	testutils.AssertContains(t, rec.Body.String(), "For mange mislykkede innlogginger")
+	testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), "For mange mislykkede innlogginger")
+}
+
 func TestResetPasswordErrorOnLargeRequest(t *testing.T) {
 	config := pkg.NewDefaultConfig()
-	handler := ResetPasswordEmail(config)
+	handler := ResetPasswordEmail(pkg.NewMultiOrgInMemoryStore(), config)
 
 	rec := httptest.NewRecorder()
 	data := bytes.Repeat([]byte("a"), 2048)
@@ -2519,7 +3171,7 @@ func TestResetPasswordErrorOnLargeRequest(t *testing.T) {
 
 func TestResetPasswordErrorOnInvalidEmail(t *testing.T) {
 	config := pkg.NewDefaultConfig()
-	handler := ResetPasswordEmail(config)
+	handler := ResetPasswordEmail(pkg.NewMultiOrgInMemoryStore(), config)
 	form := url.Values{}
 	form.Set("email", "john@example.n")
 
@@ -2541,7 +3193,8 @@ func TestSendResetPasswordEmail(t *testing.T) {
 		return nil
 	}
 
-	handler := ResetPasswordEmail(config)
+	store := pkg.NewMultiOrgInMemoryStore()
+	handler := ResetPasswordEmail(store, config)
 	form := url.Values{}
 	form.Set("email", "john@example.com")
 	rec := httptest.NewRecorder()
@@ -2554,13 +3207,19 @@ func TestSendResetPasswordEmail(t *testing.T) {
 	mailContent := string(msg)
 	testutils.AssertContains(t, mailContent, "/login/reset/form?token=")
 
-	err := errors.New("Could not send email")
+	counts, err := store.FeatureCounts(context.Background(), time.Now())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(counts), 1)
+	testutils.AssertEqual(t, counts[0].Feature, pkg.FeatureEmailSent)
+	testutils.AssertContains(t, mailContent, "text/html", "background-color:"+pkg.DefaultPrimaryColor)
+
+	err = errors.New("Could not send email")
 	config.SmtpConfig.SendFn = func(addr string, auth smtp.Auth, sender string, recipents []string, m []byte) error {
 		return err
 	}
 
 	rec = httptest.NewRecorder()
-	handler = ResetPasswordEmail(config)
+	handler = ResetPasswordEmail(store, config)
 	handler(rec, req)
 	testutils.AssertContains(t, rec.Body.String(), err.Error())
 }
@@ -2712,6 +3371,10 @@ func TestSignOut(t *testing.T) {
 		if !test.WantEmpty && len(cookie) == 0 {
 			t.Fatalf("Cookie was empty")
 		}
+
+		if !test.WantEmpty {
+			testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), "Logged out")
+		}
 	}
 }
 
@@ -2738,6 +3401,22 @@ func (f *failingResourceGetter) Resource(ctx context.Context, orgId string, path
 	return func(yield func(string, []byte) bool) {}
 }
 
+func (f *failingResourceGetter) ResourceStream(ctx context.Context, orgId string, path string) iter.Seq2[string, io.Reader] {
+	return func(yield func(string, io.Reader) bool) {}
+}
+
+func (f *failingResourceGetter) RecordAccess(ctx context.Context, orgId, resourceId string, at time.Time) error {
+	return nil
+}
+
+func (f *failingResourceGetter) TransitionStorageClass(ctx context.Context, orgId, resourceId string, class pkg.StorageClass) error {
+	return nil
+}
+
+func (f *failingResourceGetter) ProjectById(ctx context.Context, orgId, id string) (*pkg.Project, error) {
+	return &pkg.Project{}, pkg.ErrProjectNotFound
+}
+
 func TestDownloadUserPartsSuccess(t *testing.T) {
 	store := pkg.NewDemoStore()
 	orgId := store.FirstOrganizationId()
@@ -2788,6 +3467,79 @@ func TestDownloadUserPartsSuccess(t *testing.T) {
 		testutils.AssertEqual(t, len(emptyZip.File), 0)
 	})
 
+	t.Run("unknown piece", func(t *testing.T) {
+		form := url.Values{"resourceId": {"unknown"}}
+		req := httptest.NewRequest("POST", "/download", bytes.NewBufferString(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler(rec, req.WithContext(ctx))
+		testutils.AssertEqual(t, rec.Code, http.StatusNotFound)
+	})
+
+	t.Run("project of another organization", func(t *testing.T) {
+		form := url.Values{"resourceId": {store.FirstDataStore().Metadata[0].ResourceId()}, "projectId": {"otherorgproject"}}
+		req := httptest.NewRequest("POST", "/download", bytes.NewBufferString(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		RecordProjectActivity(store, pkg.ActivityDownload, downloadedPieces)(handler).ServeHTTP(rec, req.WithContext(ctx))
+		testutils.AssertEqual(t, rec.Code, http.StatusNotFound)
+
+		activities, err := store.ProjectActivity(context.Background(), orgId, "otherorgproject")
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, len(activities), 0)
+	})
+
+	t.Run("rehearsal notes", func(t *testing.T) {
+		meta := store.FirstDataStore().Metadata[0]
+		projectId := "demoproject1"
+		testutils.AssertNil(t, store.SetProjectNote(context.Background(), orgId, projectId, meta.ResourceId(), "Start at letter C"))
+
+		form.Set("resourceId", meta.ResourceId())
+		form.Set("projectId", projectId)
+		req := httptest.NewRequest("POST", "/download", bytes.NewBufferString(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler(rec, req.WithContext(ctx))
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+
+		result, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+		testutils.AssertNil(t, err)
+		notesFile := result.File[len(result.File)-1]
+		testutils.AssertEqual(t, notesFile.Name, rehearsalNotesFile)
+
+		reader, err := notesFile.Open()
+		testutils.AssertNil(t, err)
+		defer reader.Close()
+		notes, err := io.ReadAll(reader)
+		testutils.AssertNil(t, err)
+		testutils.AssertContains(t, string(notes), meta.Title, "Start at letter C")
+	})
+
+	t.Run("stamped parts", func(t *testing.T) {
+		form := url.Values{"resourceId": {store.FirstDataStore().Metadata[0].ResourceId()}, "projectId": {"demoproject1"}, "stampDate": {"2026-03-14"}}
+		req := httptest.NewRequest("POST", "/download", bytes.NewBufferString(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler(rec, req.WithContext(ctx))
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	})
+
+	for _, test := range []struct {
+		desc string
+		form url.Values
+	}{
+		{desc: "invalid stamp date", form: url.Values{"projectId": {"demoproject1"}, "stampDate": {"14.03.2026"}}},
+		{desc: "stamp without project", form: url.Values{"stampDate": {"2026-03-14"}}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			test.form.Set("resourceId", store.FirstDataStore().Metadata[0].ResourceId())
+			req := httptest.NewRequest("POST", "/download", bytes.NewBufferString(test.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			handler(rec, req.WithContext(ctx))
+			testutils.AssertEqual(t, rec.Code, http.StatusBadRequest)
+		})
+	}
 }
 
 func TestAboutHandler(t *testing.T) {
@@ -2824,3 +3576,155 @@ func TestTermsAndConditions(t *testing.T) {
 		t.Errorf("Expected content to contain '%s', but it didn't", expectedText)
 	}
 }
+
+type classifiedErrorStore struct {
+	err error
+}
+
+func (c *classifiedErrorStore) RemoveResource(ctx context.Context, orgId, projectId, resourceId string) error {
+	return c.err
+}
+
+func (c *classifiedErrorStore) SetProjectNote(ctx context.Context, orgId, projectId, resourceId, note string) error {
+	return c.err
+}
+
+func (c *classifiedErrorStore) SetProtected(ctx context.Context, orgId, resourceId string, protected bool) error {
+	return c.err
+}
+
+func (c *classifiedErrorStore) DeleteResource(ctx context.Context, orgId, resourceId string) error {
+	return c.err
+}
+
+func (c *classifiedErrorStore) MetaById(ctx context.Context, orgId, id string) (*pkg.MetaData, error) {
+	return &pkg.MetaData{}, c.err
+}
+
+func (c *classifiedErrorStore) MetaByIds(ctx context.Context, orgId string, ids []string) []pkg.MetaLookup {
+	return pkg.FetchMetaByIds(ctx, c, orgId, ids, 1)
+}
+
+func (c *classifiedErrorStore) ProjectById(ctx context.Context, orgId, id string) (*pkg.Project, error) {
+	return &pkg.Project{}, c.err
+}
+
+func TestHandlersMapStoreErrorsToStatusCodes(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want int
+	}{
+		{err: errors.Join(pkg.ErrResourceMetadataNotFound, errors.New("missing")), want: http.StatusNotFound},
+		{err: errors.Join(pkg.ErrProjectNotFound, errors.New("missing")), want: http.StatusNotFound},
+		{err: pkg.ErrResourceProtected, want: http.StatusConflict},
+		{err: errors.Join(pkg.ErrStoreUnavailable, errors.New("down")), want: http.StatusServiceUnavailable},
+		{err: errors.New("unexpected"), want: http.StatusInternalServerError},
+	} {
+		store := &classifiedErrorStore{err: test.err}
+		mux := http.NewServeMux()
+		mux.HandleFunc("DELETE /projects/{projectId}/{resourceId}", RemoveFromProject(store, time.Second))
+		mux.HandleFunc("PUT /projects/{projectId}/{resourceId}/notes", ProjectNoteHandler(store, time.Second))
+		mux.HandleFunc("PUT /resources/{id}/protection", ResourceProtectionHandler(store, time.Second))
+		mux.HandleFunc("DELETE /resources/{id}", DeleteResourceHandler(store, time.Second))
+		mux.HandleFunc("GET /resources/{id}/submit-form", AddToResourceHandler(store, time.Second))
+		mux.HandleFunc("GET /projects/{id}", ProjectByIdHandler(store, time.Second))
+
+		for _, route := range []string{
+			"DELETE /projects/project/resource",
+			"PUT /projects/project/resource/notes",
+			"PUT /resources/resource/protection",
+			"DELETE /resources/resource",
+			"GET /resources/resource/submit-form",
+			"GET /projects/project",
+		} {
+			t.Run(fmt.Sprintf("%s %v", route, test.err), func(t *testing.T) {
+				method, target, _ := strings.Cut(route, " ")
+				rec := httptest.NewRecorder()
+				mux.ServeHTTP(rec, withAuthSession(httptest.NewRequest(method, target, nil), "org"))
+				testutils.AssertEqual(t, rec.Code, test.want)
+			})
+		}
+	}
+}
+
+func TestResourceVersionHandlers(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	meta := store.Data[orgId].Metadata[0]
+	resourceId := meta.ResourceId()
+	original := maps.Collect(store.Resource(context.Background(), orgId, resourceId))
+	replacement := func(yield func(string, []byte) bool) { yield("Tuba.pdf", []byte("tuba")) }
+	testutils.AssertNil(t, store.Submit(context.Background(), orgId, &meta, replacement))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+RouteResourcesIdVersions, ResourceVersionsHandler(store, time.Second))
+	mux.HandleFunc("GET "+RouteResourcesIdVersionsId, ResourceVersionDownload(store, time.Second))
+	mux.HandleFunc("POST "+RouteResourcesIdVersionsIdRestore, RestoreVersionHandler(store, time.Second))
+
+	serve := func(method, route string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, withAuthSession(httptest.NewRequest(method, route, nil), orgId))
+		return rec
+	}
+
+	rec := serve("GET", "/resources/"+resourceId+"/versions")
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Body.String(), "/resources/"+resourceId+"/versions/1/restore")
+
+	rec = serve("GET", "/resources/"+resourceId+"/versions/1")
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Header().Get("Content-Disposition"), resourceId+"-v1.zip")
+	zipReader, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(zipReader.File), len(original))
+
+	rec = serve("POST", "/resources/"+resourceId+"/versions/1/restore")
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), string(EventResourceUploaded))
+	current := maps.Collect(store.Resource(context.Background(), orgId, resourceId))
+	testutils.AssertEqual(t, len(current), len(original))
+
+	for _, test := range []struct {
+		desc   string
+		method string
+		route  string
+		code   int
+	}{
+		{"invalid version", "GET", "/resources/" + resourceId + "/versions/first", http.StatusBadRequest},
+		{"unknown version", "GET", "/resources/" + resourceId + "/versions/9", http.StatusNotFound},
+		{"restore invalid version", "POST", "/resources/" + resourceId + "/versions/0/restore", http.StatusBadRequest},
+		{"restore unknown version", "POST", "/resources/" + resourceId + "/versions/9/restore", http.StatusNotFound},
+		{"restore unknown resource", "POST", "/resources/unknown/versions/1/restore", http.StatusNotFound},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			testutils.AssertEqual(t, serve(test.method, test.route).Code, test.code)
+		})
+	}
+}
+
+func TestInstrumentSearchHandlerPreset(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	InstrumentSearchHandler(recorder, httptest.NewRequest("GET", "/instruments?format=options&preset=brass-band", nil))
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+	testutils.AssertContains(t, recorder.Body.String(), "Flugelhorn", "Soprano cornet", "Conductor")
+	testutils.AssertNotContains(t, recorder.Body.String(), "Clarinet", "Violin")
+
+	recorder = httptest.NewRecorder()
+	InstrumentSearchHandler(recorder, httptest.NewRequest("GET", "/instruments?preset=kazoo-band", nil))
+	testutils.AssertEqual(t, recorder.Code, http.StatusBadRequest)
+}
+
+func TestParseSubmissionRejectsPartNamesWithPaths(t *testing.T) {
+	metaData := `{"title": "Song", "composer": "Composer"}`
+	for _, id := range []string{"../../../../x", "a/b", `a\b`, ".hidden", ""} {
+		assignments := fmt.Sprintf(`[{"id": %q, "from": 1, "to": 1}]`, id)
+		_, _, err := parseSubmission(map[string][]string{"assignments": {assignments}, "metadata": {metaData}})
+		if err == nil || !strings.Contains(err.Error(), "Invalid part name") {
+			t.Fatalf("Expected part name %q to be rejected, got %v", id, err)
+		}
+	}
+
+	_, assignments, err := parseSubmission(map[string][]string{"assignments": {`[{"id": "Flute", "from": 1, "to": 1}]`}, "metadata": {metaData}})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(assignments), 1)
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+// HintHandler renders a one-time hint unless the signed in user has dismissed it. Visitors get an empty response,
+// such that hints can be loaded on pages that do not require signing in
+func HintHandler(store pkg.HintStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hint := pkg.Hint(r.PathValue("hint"))
+		if err := pkg.ValidateHint(hint); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		userId, ok := MustGetSession(r).Values["userId"].(string)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		seen, err := store.SeenHints(ctx, userId)
+		if err != nil {
+			// Hints are not essential, hence the page is shown without it
+			slog.ErrorContext(ctx, "Could not fetch seen hints", "error", err, "userId", userId)
+			return
+		}
+		if !slices.Contains(seen, hint) {
+			web.Hint(w, pkg.LanguageFromReq(r), hint)
+		}
+	}
+}
+
+// HintSeenHandler records that the signed in user dismissed the hint, such that it is not shown again on any device
+func HintSeenHandler(store pkg.HintStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		userId := MustGetUserId(MustGetSession(r))
+		hint := pkg.Hint(r.PathValue("hint"))
+		if err := store.MarkHintSeen(ctx, userId, hint); err != nil {
+			http.Error(w, "Could not dismiss hint", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not dismiss hint", "error", err, "userId", userId, "hint", hint)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestHintHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	handler := HintHandler(store, time.Second)
+
+	serve := func(req *http.Request, hint string) *httptest.ResponseRecorder {
+		req.SetPathValue("hint", hint)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	rec := serve(withSignedInSession(httptest.NewRequest("GET", "/hints/upload-assignment", nil), "org1"), "upload-assignment")
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Body.String(), `id="hint-upload-assignment"`)
+
+	testutils.AssertNil(t, store.MarkHintSeen(context.Background(), "0000-0000", pkg.HintUploadAssignment))
+	rec = serve(withSignedInSession(httptest.NewRequest("GET", "/hints/upload-assignment", nil), "org1"), "upload-assignment")
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, rec.Body.Len(), 0)
+
+	rec = serve(withEmptySession(httptest.NewRequest("GET", "/hints/project-selector", nil)), "project-selector")
+	testutils.AssertEqual(t, rec.Body.Len(), 0)
+
+	rec = serve(withSignedInSession(httptest.NewRequest("GET", "/hints/unknown", nil), "org1"), "unknown")
+	testutils.AssertEqual(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestHintSeenHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	handler := HintSeenHandler(store, time.Second)
+
+	for _, test := range []struct {
+		hint string
+		code int
+	}{
+		{"project-selector", http.StatusOK},
+		{"project-selector", http.StatusOK},
+		{"unknown", http.StatusBadRequest},
+	} {
+		req := withSignedInSession(httptest.NewRequest("POST", "/hints/"+test.hint+"/seen", nil), "org1")
+		req.SetPathValue("hint", test.hint)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		testutils.AssertEqual(t, rec.Code, test.code)
+	}
+
+	hints, err := store.SeenHints(context.Background(), "0000-0000")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(hints), 1)
+	testutils.AssertEqual(t, hints[0], pkg.HintProjectSelector)
+}
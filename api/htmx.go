@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+type HxEvent string
+
+const (
+	EventResourceUploaded        HxEvent = "resource-uploaded"
+	EventProjectUpdated          HxEvent = "project-updated"
+	EventUsersUpdated            HxEvent = "users-updated"
+	EventFlash                   HxEvent = "flash"
+	EventBrandingUpdated         HxEvent = "branding-updated"
+	EventMetadataUpdated         HxEvent = "metadata-updated"
+	EventAnnouncementsUpdated    HxEvent = "announcements-updated"
+	EventProjectTemplatesUpdated HxEvent = "project-templates-updated"
+	EventProblemReportsUpdated   HxEvent = "problem-reports-updated"
+	EventCorrectionsUpdated      HxEvent = "corrections-updated"
+	EventPasskeysUpdated         HxEvent = "passkeys-updated"
+	EventApiTokensUpdated        HxEvent = "api-tokens-updated"
+	EventOnboardingUpdated       HxEvent = "onboarding-updated"
+	EventSessionsUpdated         HxEvent = "sessions-updated"
+	EventGuestAccessUpdated      HxEvent = "guest-access-updated"
+	EventWebhookUpdated          HxEvent = "webhook-updated"
+)
+
+type FlashLevel string
+
+const (
+	FlashSuccess FlashLevel = "success"
+	FlashInfo    FlashLevel = "info"
+	FlashWarning FlashLevel = "warning"
+	FlashError   FlashLevel = "error"
+)
+
+type Flash struct {
+	Level   FlashLevel `json:"level"`
+	Message string     `json:"message"`
+}
+
+// HxTrigger adds an event to the HX-Trigger header such that htmx dispatches it on the client.
+// Events added by earlier calls are kept. It must be called before the header is written
+func HxTrigger(w http.ResponseWriter, event HxEvent, detail any) {
+	events := make(map[HxEvent]any)
+	if existing := w.Header().Get("HX-Trigger"); existing != "" {
+		if err := json.Unmarshal([]byte(existing), &events); err != nil {
+			// The header was set to a plain event name
+			events = map[HxEvent]any{HxEvent(existing): nil}
+		}
+	}
+
+	if detail == nil {
+		detail = map[string]any{}
+	}
+	events[event] = detail
+
+	data, err := json.Marshal(events)
+	if err != nil {
+		slog.Error("Could not encode HX-Trigger events", "error", err)
+		return
+	}
+	w.Header().Set("HX-Trigger", string(data))
+}
+
+// HxFlash triggers an event that shows a message in the footer. The message is the translation of key
+// in the language of the request, with data filled into the placeholders
+func HxFlash(w http.ResponseWriter, r *http.Request, level FlashLevel, key string, data any) {
+	message := web.FlashMessage(pkg.LanguageFromReq(r), key, data)
+	HxTrigger(w, EventFlash, Flash{Level: level, Message: message})
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestHxTriggerMergesEvents(t *testing.T) {
+	rec := httptest.NewRecorder()
+	HxTrigger(rec, EventResourceUploaded, map[string]string{"resourceId": "abc"})
+	HxFlash(rec, httptest.NewRequest("POST", "/resources", nil), FlashSuccess, "flash.file-uploaded", nil)
+
+	var events map[string]map[string]string
+	testutils.AssertNil(t, json.Unmarshal([]byte(rec.Header().Get("HX-Trigger")), &events))
+	testutils.AssertEqual(t, events["resource-uploaded"]["resourceId"], "abc")
+	testutils.AssertEqual(t, events["flash"]["level"], "success")
+	testutils.AssertEqual(t, events["flash"]["message"], "File uploaded successfully!")
+}
+
+func TestHxTriggerKeepsPlainEventName(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set("HX-Trigger", "loginEvent")
+	HxTrigger(rec, EventUsersUpdated, nil)
+
+	var events map[string]any
+	testutils.AssertNil(t, json.Unmarshal([]byte(rec.Header().Get("HX-Trigger")), &events))
+	_, hasLogin := events["loginEvent"]
+	_, hasUsers := events["users-updated"]
+	testutils.AssertEqual(t, hasLogin, true)
+	testutils.AssertEqual(t, hasUsers, true)
+}
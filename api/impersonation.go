@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/gorilla/sessions"
+)
+
+// sessionImpersonatingKey holds the organization a platform operator impersonates
+const sessionImpersonatingKey = "impersonating"
+
+func impersonatedOrg(session *sessions.Session) string {
+	orgId, _ := session.Values[sessionImpersonatingKey].(string)
+	return orgId
+}
+
+// RestrictImpersonation only lets impersonating operators read from the impersonated organization. Sessions of
+// users that are no longer platform admins stop impersonating
+func RestrictImpersonation(admins []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session := MustGetSession(r)
+			orgId := impersonatedOrg(session)
+			if orgId == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			userId, _ := session.Values["userId"].(string)
+			if !slices.Contains(admins, userId) {
+				slog.InfoContext(r.Context(), "Ended impersonation of user that is not platform admin", "userId", userId, "orgId", orgId)
+				delete(session.Values, sessionImpersonatingKey)
+				trySaveSession(session, r, w)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if session.Values["orgId"] == orgId && r.Method != http.MethodGet && r.Method != http.MethodHead {
+				http.Error(w, "Impersonation is read-only", http.StatusForbidden)
+				slog.InfoContext(r.Context(), "Denied change while impersonating", "userId", userId, "orgId", orgId, "method", r.Method, "path", r.URL.Path)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type ImpersonationStore interface {
+	pkg.OrganizationGetter
+	pkg.AuditLogger
+}
+
+// StartImpersonationHandler lets a platform operator view the organization in the path as a viewer, for instance
+// to debug a problem reported by the organization. The start is written to the audit log of the organization
+func StartImpersonationHandler(store ImpersonationStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := r.PathValue("id")
+		if _, err := store.GetOrganization(ctx, orgId); err != nil {
+			http.Error(w, "Could not find organization", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch impersonated organization", "error", err, "orgId", orgId)
+			return
+		}
+
+		session := MustGetSession(r)
+		userId := MustGetUserId(session)
+		session.Values[sessionImpersonatingKey] = orgId
+		session.Values["orgId"] = orgId
+		if err := session.Save(r, w); err != nil {
+			http.Error(w, "Could not save session", http.StatusInternalServerError)
+			slog.ErrorContext(ctx, "Could not save session", "error", err)
+			return
+		}
+
+		slog.InfoContext(ctx, "Started impersonation", "userId", userId, "orgId", orgId)
+		appendAudit(ctx, store, orgId, pkg.NewAuditEntry(pkg.AuditImpersonationStart, userId, orgId, "", getIp(r)))
+		w.Header().Set("HX-Redirect", "/")
+	}
+}
+
+type StopImpersonationStore interface {
+	pkg.RoleGetter
+	pkg.AuditLogger
+}
+
+// StopImpersonationHandler ends the impersonation and makes one of the organizations of the operator active
+func StopImpersonationHandler(store StopImpersonationStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := MustGetSession(r)
+		orgId := impersonatedOrg(session)
+		if orgId == "" {
+			http.Error(w, "No organization is impersonated", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		userId := MustGetUserId(session)
+		userInfo, err := store.GetUserInfo(ctx, userId)
+		if err != nil {
+			http.Error(w, "Could not fetch user", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch user", "error", err, "userId", userId)
+			return
+		}
+
+		delete(session.Values, sessionImpersonatingKey)
+		delete(session.Values, "orgId")
+		pkg.PopulateSessionWithRoles(session, userInfo)
+		if err := session.Save(r, w); err != nil {
+			http.Error(w, "Could not save session", http.StatusInternalServerError)
+			slog.ErrorContext(ctx, "Could not save session", "error", err)
+			return
+		}
+
+		slog.InfoContext(ctx, "Stopped impersonation", "userId", userId, "orgId", orgId)
+		appendAudit(ctx, store, orgId, pkg.NewAuditEntry(pkg.AuditImpersonationEnd, userId, orgId, "", getIp(r)))
+		w.Header().Set("HX-Redirect", "/")
+	}
+}
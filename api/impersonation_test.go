@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+// withImpersonation returns a request of operator 0000-0000, who is admin of the organization "own", impersonating
+// the organization
+func withImpersonation(r *http.Request, orgId string) *http.Request {
+	r = withSignedInSession(r, "own")
+	session := MustGetSession(r)
+	session.Values["orgId"] = orgId
+	session.Values[sessionImpersonatingKey] = orgId
+	return r
+}
+
+func TestImpersonationIsReadOnly(t *testing.T) {
+	var seenOrgId any
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenOrgId = r.Context().Value(pkg.OrgIdKey)
+	})
+	serve := func(admins []string, minimumRole pkg.RoleKind, req *http.Request) int {
+		rec := httptest.NewRecorder()
+		Chain(RestrictImpersonation(admins), RequireMinimumRole(nil, minimumRole))(handler).ServeHTTP(rec, req)
+		return rec.Code
+	}
+	operators := []string{"0000-0000"}
+
+	testutils.AssertEqual(t, serve(operators, pkg.RoleViewer, withImpersonation(httptest.NewRequest("GET", "/", nil), "org1")), http.StatusOK)
+	testutils.AssertEqual(t, seenOrgId, any("org1"))
+	testutils.AssertEqual(t, serve(operators, pkg.RoleViewer, withImpersonation(httptest.NewRequest("POST", "/", nil), "org1")), http.StatusForbidden)
+	testutils.AssertEqual(t, serve(operators, pkg.RoleAdmin, withImpersonation(httptest.NewRequest("GET", "/", nil), "org1")), http.StatusUnauthorized)
+
+	t.Run("own organization is not restricted", func(t *testing.T) {
+		req := withImpersonation(httptest.NewRequest("POST", "/", nil), "org1")
+		MustGetSession(req).Values["orgId"] = "own"
+		testutils.AssertEqual(t, serve(operators, pkg.RoleAdmin, req), http.StatusOK)
+	})
+
+	t.Run("ends when no longer operator", func(t *testing.T) {
+		req := withImpersonation(httptest.NewRequest("GET", "/", nil), "org1")
+		testutils.AssertEqual(t, serve([]string{}, pkg.RoleViewer, req), http.StatusUnauthorized)
+		testutils.AssertEqual(t, impersonatedOrg(MustGetSession(req)), "")
+	})
+}
+
+func TestStartAndStopImpersonation(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	user := pkg.UserInfo{Id: "0000-0000", Roles: map[string]pkg.RoleKind{"own": pkg.RoleAdmin}}
+	testutils.AssertNil(t, store.RegisterUser(context.Background(), &user))
+
+	req := withSignedInSession(httptest.NewRequest("POST", strings.Replace(RouteAdminOrganizationsIdImpersonate, "{id}", orgId, 1), nil), "own")
+	req.SetPathValue("id", orgId)
+	rec := httptest.NewRecorder()
+	StartImpersonationHandler(store, time.Second)(rec, req)
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, rec.Header().Get("HX-Redirect"), "/")
+
+	session := MustGetSession(req)
+	testutils.AssertEqual(t, impersonatedOrg(session), orgId)
+	testutils.AssertEqual(t, session.Values["orgId"], any(orgId))
+
+	rec = httptest.NewRecorder()
+	StopImpersonationHandler(store, time.Second)(rec, req)
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, impersonatedOrg(session), "")
+	testutils.AssertEqual(t, session.Values["orgId"], any("own"))
+
+	entries, err := store.AuditLog(context.Background(), orgId, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(entries), 2)
+	testutils.AssertEqual(t, entries[0].ActorId, "0000-0000")
+
+	rec = httptest.NewRecorder()
+	StopImpersonationHandler(store, time.Second)(rec, req)
+	testutils.AssertEqual(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestStartImpersonationUnknownOrganization(t *testing.T) {
+	req := withSignedInSession(httptest.NewRequest("POST", "/admin/organizations/unknown/impersonate", nil), "own")
+	req.SetPathValue("id", "unknown")
+	rec := httptest.NewRecorder()
+	StartImpersonationHandler(pkg.NewDemoStore(), time.Second)(rec, req)
+	testutils.AssertEqual(t, rec.Code, http.StatusNotFound)
+	testutils.AssertEqual(t, impersonatedOrg(MustGetSession(req)), "")
+}
+
+func TestActiveOrganizationMarksImpersonation(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	rec := httptest.NewRecorder()
+	ActiveOrganization(store, time.Second)(rec, withImpersonation(httptest.NewRequest("GET", RouteSessionActiveOrganizationName, nil), orgId))
+	testutils.AssertContains(t, rec.Body.String(), "read-only impersonation")
+}
@@ -2,58 +2,16 @@ package api
 
 import "github.com/davidkleiven/caesura/pkg"
 
-var brass = []string{
-	"Trumpet",
-	"Cornet",
-	"Baritone",
-	"Horn",
-	"Euphonium",
-	"Trombone",
-	"Tuba",
-}
-
-var choir = []string{
-	"Soprano",
-	"Alto",
-	"Tenor",
-	"Bass",
-}
-
-var percussion = []string{
-	"Percussion",
-	"Melodic percussion",
-}
-
-var stringInstruments = []string{
-	"Violin",
-	"Viola",
-	"Cello",
-	"Contrabass",
-}
-
-var reeds = []string{
-	"Saxophone",
-	"Clarinet",
-	"Oboe",
-	"Basoon",
-	"Flute",
-}
-
-var conductor = []string{
-	"Conductor",
-}
-
 func allInstruments() []string {
-	var allInstruments []string
-	allInstruments = append(allInstruments, reeds...)
-	allInstruments = append(allInstruments, brass...)
-	allInstruments = append(allInstruments, choir...)
-	allInstruments = append(allInstruments, stringInstruments...)
-	allInstruments = append(allInstruments, percussion...)
-	allInstruments = append(allInstruments, conductor...)
-	return allInstruments
+	return pkg.DefaultInstrumentation().All()
 }
 
 func Instruments(token string) []string {
 	return pkg.FilterList(allInstruments(), token)
 }
+
+// instrumentsOf returns the instruments of the families and the conductor. All instruments are returned when
+// no family is given
+func instrumentsOf(families []string) []string {
+	return pkg.DefaultInstrumentation().Of(families)
+}
@@ -0,0 +1,265 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+type invitationStatus struct {
+	Id      string               `json:"id"`
+	Email   string               `json:"email"`
+	Role    pkg.RoleKind         `json:"role"`
+	Group   string               `json:"group"`
+	Status  pkg.InvitationStatus `json:"status"`
+	Expires time.Time            `json:"expires"`
+}
+
+func newInvitationStatus(invitation *pkg.Invitation, now time.Time) invitationStatus {
+	return invitationStatus{
+		Id:      invitation.Id,
+		Email:   invitation.Email,
+		Role:    invitation.Role,
+		Group:   invitation.Group,
+		Status:  invitation.StatusAt(now),
+		Expires: invitation.ExpiresAt,
+	}
+}
+
+// requireActiveOrganization rejects requests where the organization in the path is not the active organization
+// of the session, since the role of the user is only checked for the active organization
+func requireActiveOrganization(w http.ResponseWriter, r *http.Request) (string, bool) {
+	orgId := MustGetOrgId(MustGetSession(r))
+	if r.PathValue("id") != orgId {
+		http.Error(w, "Invitations can only be managed for the active organization", http.StatusForbidden)
+		return "", false
+	}
+	return orgId, true
+}
+
+type InvitationSender interface {
+	pkg.InvitationStore
+	pkg.OrganizationGetter
+	pkg.FeatureCounter
+}
+
+// sendInvitationEmail sends the link that lets the invited person join the organization with the role and group
+// of the invitation
+func sendInvitationEmail(ctx context.Context, store InvitationSender, config *pkg.Config, invitation *pkg.Invitation) error {
+	org, err := store.GetOrganization(ctx, invitation.OrgId)
+	if err != nil {
+		return err
+	}
+
+	email := pkg.Email{
+		Sender:    config.EmailSender,
+		SmtpHost:  config.SmtpConfig.Host,
+		SmtpPort:  config.SmtpConfig.Port,
+		SmtpAuth:  config.SmtpConfig.Auth,
+		Recipents: []string{invitation.Email},
+		SendFn:    config.SmtpConfig.SendFn,
+		Branding:  &org.Branding,
+	}
+
+	var (
+		link         string
+		emailContent *bytes.Buffer
+	)
+	err = pkg.ReturnOnFirstError(
+		func() error {
+			var err error
+			link, err = signedInviteURL(config.BaseURL, config.CookieSecretSignKey, InviteClaim{OrgId: invitation.OrgId, InvitationId: invitation.Id}, invitation.ExpiresAt)
+			return err
+		},
+		func() error {
+			var err error
+			body := fmt.Sprintf(
+				"You are invited to join %s as %s. Sign in with %s using the link below before %s.\n\nInvitation link: %s",
+				org.Name, strings.ToLower(web.RoleName(invitation.Role)), invitation.Email, invitation.ExpiresAt.Format(time.DateOnly), link,
+			)
+			emailContent, err = email.Build("Caesura: invitation to join "+org.Name, body, func(yield func(string, io.Reader) bool) {})
+			return err
+		},
+		func() error {
+			return email.Send(ctx, emailContent.Bytes())
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := store.CountFeature(ctx, invitation.OrgId, pkg.FeatureEmailSent, time.Now()); err != nil {
+		slog.ErrorContext(ctx, "Could not count feature usage", "feature", pkg.FeatureEmailSent, "error", err)
+	}
+	return nil
+}
+
+// CreateInvitationHandler emails an invitation to join the organization. The role and group in the form are given
+// to the invited person on the first sign in with the link in the email
+func CreateInvitationHandler(store InvitationSender, config *pkg.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 4096)
+		code, err := parseForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		orgId, ok := requireActiveOrganization(w, r)
+		if !ok {
+			return
+		}
+
+		emailAddr := strings.TrimSpace(r.FormValue("email"))
+		if !validEmail(emailAddr) {
+			http.Error(w, "Invalid email address", http.StatusBadRequest)
+			return
+		}
+
+		role := pkg.RoleViewer
+		if value := r.FormValue("role"); value != "" {
+			role, err = strconv.Atoi(value)
+			if err != nil || !pkg.RoleKind(role).Valid() {
+				http.Error(w, "Unknown role", http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), config.Timeout)
+		defer cancel()
+
+		invitation := pkg.NewInvitation(orgId, emailAddr, pkg.RoleKind(role), r.FormValue("group"), config.InvitationExpiry)
+		if err := store.SaveInvitation(ctx, invitation); err != nil {
+			http.Error(w, "Could not save invitation: "+err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not save invitation", "error", err)
+			return
+		}
+
+		if err := sendInvitationEmail(ctx, store, config, invitation); err != nil {
+			http.Error(w, "Could not send invitation", http.StatusInternalServerError)
+			slog.ErrorContext(ctx, "Could not send invitation", "error", err, "invitationId", invitation.Id)
+			return
+		}
+
+		slog.InfoContext(ctx, "Sent invitation", "invitationId", invitation.Id, "role", invitation.Role)
+		recordEvent(ctx, newRequestEvent(r, pkg.EventUserInvited, orgId, invitation.Id))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(newInvitationStatus(invitation, time.Now())); err != nil {
+			slog.ErrorContext(ctx, "Failed to encode invitation", "error", err)
+		}
+	}
+}
+
+// InvitationsHandler lists the invitations of the organization with their status
+func InvitationsHandler(store pkg.InvitationStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgId, ok := requireActiveOrganization(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		invitations, err := store.Invitations(ctx, orgId)
+		if err != nil {
+			http.Error(w, "Could not fetch invitations", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch invitations", "error", err)
+			return
+		}
+
+		now := time.Now()
+		result := make([]invitationStatus, len(invitations))
+		for i := range invitations {
+			result[i] = newInvitationStatus(&invitations[i], now)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			slog.ErrorContext(ctx, "Failed to encode invitations", "error", err)
+		}
+	}
+}
+
+type inviteLinkStatus struct {
+	Id        string               `json:"id"`
+	CreatedBy string               `json:"createdBy"`
+	Created   time.Time            `json:"created"`
+	Status    pkg.InviteLinkStatus `json:"status"`
+	Expires   time.Time            `json:"expires"`
+}
+
+func newInviteLinkStatus(link *pkg.InviteLink, now time.Time) inviteLinkStatus {
+	return inviteLinkStatus{
+		Id:        link.Id,
+		CreatedBy: link.CreatedBy,
+		Created:   link.CreatedAt,
+		Status:    link.StatusAt(now),
+		Expires:   link.ExpiresAt,
+	}
+}
+
+// InviteLinksHandler lists the invite links of the organization with their status
+func InviteLinksHandler(store pkg.InviteLinkStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgId, ok := requireActiveOrganization(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		links, err := store.InviteLinks(ctx, orgId)
+		if err != nil {
+			http.Error(w, "Could not fetch invite links", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch invite links", "error", err)
+			return
+		}
+
+		now := time.Now()
+		result := make([]inviteLinkStatus, len(links))
+		for i := range links {
+			result[i] = newInviteLinkStatus(&links[i], now)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			slog.ErrorContext(ctx, "Failed to encode invite links", "error", err)
+		}
+	}
+}
+
+// RevokeInviteLinkHandler stops an invite link of the active organization from being used to join it. Members
+// that have already joined with the link keep their role
+func RevokeInviteLinkHandler(store pkg.InviteLinkStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		userId, _ := r.Context().Value(pkg.UserIdKey).(string)
+		id := r.PathValue("id")
+		link, err := pkg.RevokeInviteLink(ctx, store, orgId, id, userId, time.Now())
+		if err != nil {
+			http.Error(w, "Could not revoke invite link", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not revoke invite link", "error", err, "linkId", id)
+			return
+		}
+
+		slog.InfoContext(ctx, "Revoked invite link", "linkId", id)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(newInviteLinkStatus(link, time.Now())); err != nil {
+			slog.ErrorContext(ctx, "Failed to encode invite link", "error", err)
+		}
+	}
+}
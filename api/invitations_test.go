@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func invitationMux(store *pkg.MultiOrgInMemoryStore, config *pkg.Config) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("POST "+RouteOrganizationsIdInvitations, CreateInvitationHandler(store, config))
+	mux.Handle("GET "+RouteOrganizationsIdInvitations, InvitationsHandler(store, config.Timeout))
+	return mux
+}
+
+func invitationRequest(orgId string, form url.Values) *http.Request {
+	req := httptest.NewRequest("POST", strings.Replace(RouteOrganizationsIdInvitations, "{id}", orgId, 1), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+var inviteTokenPattern = regexp.MustCompile(`invite-token=(\S+)`)
+
+func TestInvitationIsAppliedOnSignIn(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	config := pkg.NewDefaultConfig()
+	config.CookieSecretSignKey = "secret"
+	var msg string
+	config.SmtpConfig.SendFn = func(addr string, auth smtp.Auth, sender string, to []string, m []byte) error {
+		// Undo the soft line breaks and escapes of the quoted-printable body
+		msg = strings.NewReplacer("=\r\n", "", "=3D", "=").Replace(string(m))
+		testutils.AssertEqual(t, to[0], "anna@example.com")
+		return nil
+	}
+	mux := invitationMux(store, config)
+
+	form := url.Values{"email": {"Anna@example.com"}, "role": {"3"}, "group": {"Horns"}}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, withAuthSession(invitationRequest(orgId, form), orgId))
+	testutils.AssertEqual(t, rec.Code, http.StatusCreated)
+	testutils.AssertContains(t, msg, "as librarian", "/login?invite-token=")
+
+	var created invitationStatus
+	testutils.AssertNil(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	testutils.AssertEqual(t, created.Status, pkg.InvitationPending)
+	testutils.AssertEqual(t, created.Role, pkg.RoleKind(pkg.RoleLibrarian))
+
+	match := inviteTokenPattern.FindStringSubmatch(msg)
+	testutils.AssertEqual(t, len(match), 2)
+	token, err := url.QueryUnescape(match[1])
+	testutils.AssertNil(t, err)
+
+	signIn := func(user pkg.UserInfo) SessionInitResult {
+		req := withEmptySession(httptest.NewRequest("GET", "/auth/callback", nil))
+		session := MustGetSession(req)
+		session.Values[inviteTokenKey] = token
+		return InitializeUserSession(SessionInitParams{
+			Ctx:        context.Background(),
+			Session:    session,
+			User:       &user,
+			SignSecret: config.CookieSecretSignKey,
+			Store:      store,
+			Writer:     httptest.NewRecorder(),
+			Req:        req,
+		})
+	}
+
+	t.Run("other email is rejected", func(t *testing.T) {
+		result := signIn(pkg.UserInfo{Id: "john", Email: "john@example.com", Roles: map[string]pkg.RoleKind{}, Groups: map[string][]string{}})
+		testutils.AssertEqual(t, result.ReturnCode, http.StatusForbidden)
+	})
+
+	result := signIn(pkg.UserInfo{Id: "anna", Email: "anna@example.com", Roles: map[string]pkg.RoleKind{}, Groups: map[string][]string{}})
+	testutils.AssertNil(t, result.Error)
+
+	user, err := store.GetUserInfo(context.Background(), "anna")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, user.Roles[orgId], pkg.RoleKind(pkg.RoleLibrarian))
+	testutils.AssertEqual(t, strings.Join(user.Groups[orgId], ","), "Horns")
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, withAuthSession(httptest.NewRequest("GET", strings.Replace(RouteOrganizationsIdInvitations, "{id}", orgId, 1), nil), orgId))
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	var invitations []invitationStatus
+	testutils.AssertNil(t, json.Unmarshal(rec.Body.Bytes(), &invitations))
+	testutils.AssertEqual(t, len(invitations), 1)
+	testutils.AssertEqual(t, invitations[0].Status, pkg.InvitationAccepted)
+
+	t.Run("invitation can not be used twice", func(t *testing.T) {
+		result := signIn(pkg.UserInfo{Id: "anna", Email: "anna@example.com"})
+		testutils.AssertEqual(t, result.ReturnCode, http.StatusConflict)
+	})
+}
+
+func TestCreateInvitationInvalidInput(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	config := pkg.NewDefaultConfig()
+	config.SmtpConfig.SendFn = pkg.NoOpSendFunc
+	mux := invitationMux(store, config)
+
+	for _, test := range []struct {
+		desc  string
+		orgId string
+		form  url.Values
+		code  int
+	}{
+		{"invalid email", orgId, url.Values{"email": {"anna"}}, http.StatusBadRequest},
+		{"unknown role", orgId, url.Values{"email": {"anna@example.com"}, "role": {"42"}}, http.StatusBadRequest},
+		{"other organization", "other-org", url.Values{"email": {"anna@example.com"}}, http.StatusForbidden},
+		{"default role", orgId, url.Values{"email": {"anna@example.com"}}, http.StatusCreated},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, withAuthSession(invitationRequest(test.orgId, test.form), orgId))
+			testutils.AssertEqual(t, rec.Code, test.code)
+		})
+	}
+
+	invitations, err := store.Invitations(context.Background(), orgId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(invitations), 1)
+	testutils.AssertEqual(t, invitations[0].Role, pkg.RoleKind(pkg.RoleViewer))
+}
+
+func TestRevokedInviteLinkCanNotBeUsed(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	config := pkg.NewDefaultConfig()
+	config.CookieSecretSignKey = "secret"
+
+	mux := http.NewServeMux()
+	mux.Handle("GET "+RouteOrganizationsIdInvite, InviteLink(store, config))
+	mux.Handle("GET "+RouteOrganizationsIdInvites, InviteLinksHandler(store, config.Timeout))
+	mux.Handle("DELETE "+RouteInvitesId, RevokeInviteLinkHandler(store, config.Timeout))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, withAuthSession(httptest.NewRequest("GET", strings.Replace(RouteOrganizationsIdInvite, "{id}", orgId, 1), nil), orgId))
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	match := inviteTokenPattern.FindStringSubmatch(rec.Body.String())
+	testutils.AssertEqual(t, len(match), 2)
+	token, err := url.QueryUnescape(strings.TrimSuffix(match[1], `"}`))
+	testutils.AssertNil(t, err)
+
+	listLinks := func() []inviteLinkStatus {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, withAuthSession(httptest.NewRequest("GET", strings.Replace(RouteOrganizationsIdInvites, "{id}", orgId, 1), nil), orgId))
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+		var links []inviteLinkStatus
+		testutils.AssertNil(t, json.Unmarshal(rec.Body.Bytes(), &links))
+		return links
+	}
+	links := listLinks()
+	testutils.AssertEqual(t, len(links), 1)
+	testutils.AssertEqual(t, links[0].Status, pkg.InviteLinkActive)
+
+	signIn := func(userId string) SessionInitResult {
+		req := withEmptySession(httptest.NewRequest("GET", "/auth/callback", nil))
+		session := MustGetSession(req)
+		session.Values[inviteTokenKey] = token
+		return InitializeUserSession(SessionInitParams{
+			Ctx:        context.Background(),
+			Session:    session,
+			User:       &pkg.UserInfo{Id: userId, Roles: map[string]pkg.RoleKind{}, Groups: map[string][]string{}},
+			SignSecret: config.CookieSecretSignKey,
+			Store:      store,
+			Writer:     httptest.NewRecorder(),
+			Req:        req,
+		})
+	}
+	testutils.AssertNil(t, signIn("anna").Error)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, withAuthSession(httptest.NewRequest("DELETE", strings.Replace(RouteInvitesId, "{id}", links[0].Id, 1), nil), orgId))
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, listLinks()[0].Status, pkg.InviteLinkRevoked)
+
+	result := signIn("john")
+	testutils.AssertEqual(t, result.ReturnCode, http.StatusForbidden)
+	_, err = store.GetUserInfo(context.Background(), "john")
+	testutils.AssertEqual(t, err != nil, true)
+
+	t.Run("unknown link", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, withAuthSession(httptest.NewRequest("DELETE", strings.Replace(RouteInvitesId, "{id}", "unknown", 1), nil), orgId))
+		testutils.AssertEqual(t, rec.Code, http.StatusNotFound)
+	})
+}
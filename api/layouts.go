@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+// Layouts are small JSON files. The limit leaves room for the maximum number of templates
+const maxLayoutSize = 2 << 20
+
+// ExportLayoutHandler downloads the project templates and instrument families of the organization as a JSON
+// file, which can be imported into other organizations
+func ExportLayoutHandler(store pkg.LayoutStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		layout, err := pkg.ExportLayout(ctx, store, orgId, time.Now())
+		if err != nil {
+			http.Error(w, "Could not export layout", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not export layout", "error", err, "orgId", orgId)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "caesura-layout.json"))
+		w.Header().Set("Cache-Control", "no-store")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(layout); err != nil {
+			slog.ErrorContext(ctx, "Could not send layout", "error", err, "orgId", orgId)
+		}
+	}
+}
+
+// ImportLayoutHandler copies a layout exported from another organization into the organization. The file is
+// given in the multipart form field "layout"
+func ImportLayoutHandler(store pkg.LayoutStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxLayoutSize)
+		err := r.ParseMultipartForm(maxLayoutSize)
+
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, "The layout file is too large", http.StatusRequestEntityTooLarge)
+			return
+		} else if err != nil {
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
+
+		file, _, err := r.FormFile("layout")
+		if err != nil {
+			http.Error(w, "Failed to retrieve file from form", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		var layout pkg.OrganizationLayout
+		if err := json.NewDecoder(file).Decode(&layout); err != nil {
+			http.Error(w, "The file is not a layout: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, family := range layout.Instruments {
+			if !pkg.DefaultInstrumentation().HasFamily(family) {
+				http.Error(w, fmt.Sprintf("Unknown instrument family %q", family), http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		if err := pkg.ImportLayout(ctx, store, orgId, &layout, time.Now()); err != nil {
+			http.Error(w, "Could not import layout: "+err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not import layout", "error", err, "orgId", orgId)
+			return
+		}
+
+		slog.InfoContext(ctx, "Imported layout", "orgId", orgId, "numTemplates", len(layout.ProjectTemplates))
+		HxTrigger(w, EventProjectTemplatesUpdated, nil)
+		HxTrigger(w, EventOnboardingUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.layout-imported", map[string]any{"Count": len(layout.ProjectTemplates)})
+		w.WriteHeader(http.StatusOK)
+	}
+}
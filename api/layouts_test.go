@@ -0,0 +1,80 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func postLayout(store pkg.LayoutStore, content []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("layout", "caesura-layout.json")
+	pkg.PanicOnErr(err)
+	part.Write(content)
+	pkg.PanicOnErr(writer.Close())
+
+	req := httptest.NewRequest("POST", RouteOrganizationsLayout, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	recorder := httptest.NewRecorder()
+	ImportLayoutHandler(store, time.Second)(recorder, withAuthSession(req, "band"))
+	return recorder
+}
+
+func TestExportImportLayoutHandlers(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.SubmitProjectTemplate(ctx, "federation", &pkg.ProjectTemplate{Name: "Contest", Groups: []string{"Solo cornet"}}))
+	onboarding := pkg.NewOnboarding()
+	onboarding.Instruments = []string{"brass"}
+	testutils.AssertNil(t, store.SaveOnboarding(ctx, "federation", onboarding))
+
+	recorder := httptest.NewRecorder()
+	ExportLayoutHandler(store, time.Second)(recorder, withAuthSession(httptest.NewRequest("GET", RouteOrganizationsLayout, nil), "federation"))
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+	testutils.AssertContains(t, recorder.Header().Get("Content-Disposition"), "caesura-layout.json")
+
+	var layout pkg.OrganizationLayout
+	testutils.AssertNil(t, json.Unmarshal(recorder.Body.Bytes(), &layout))
+	testutils.AssertEqual(t, layout.Version, pkg.LayoutVersion)
+	testutils.AssertEqual(t, len(layout.ProjectTemplates), 1)
+
+	recorder = postLayout(store, recorder.Body.Bytes())
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+	testutils.AssertContains(t, recorder.Header().Get("HX-Trigger"), string(EventProjectTemplatesUpdated), string(EventOnboardingUpdated))
+
+	template, err := store.ProjectTemplateById(ctx, "band", "contest")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(template.Groups, ","), "Solo cornet")
+	bandOnboarding, err := store.Onboarding(ctx, "band")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(bandOnboarding.Instruments, ","), "brass")
+}
+
+func TestImportLayoutHandlerRejectsInvalidLayouts(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	for _, test := range []struct {
+		content string
+		code    int
+	}{
+		{"not json", http.StatusBadRequest},
+		{`{"version": 1, "instruments": ["kazoo"]}`, http.StatusBadRequest},
+		{`{"version": 2}`, http.StatusBadRequest},
+		{`{"version": 1, "projectTemplates": [{"name": "!"}]}`, http.StatusBadRequest},
+		{strings.Repeat(" ", maxLayoutSize), http.StatusRequestEntityTooLarge},
+	} {
+		testutils.AssertEqual(t, postLayout(store, []byte(test.content)).Code, test.code)
+	}
+	templates, err := store.ProjectTemplates(context.Background(), "band")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(templates), 0)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+// LibraryExportHandler serves the metadata of every piece in the library as a CSV file that opens in Excel.
+// The query parameter format may be left out, csv is the only supported format
+func LibraryExportHandler(store pkg.LibraryExportStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "csv" {
+			http.Error(w, "Unsupported export format: "+format, http.StatusBadRequest)
+			return
+		}
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		entries, err := pkg.LibraryEntries(ctx, store, orgId)
+		if err != nil {
+			http.Error(w, "Failed to fetch the library", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Failed to fetch the library", "error", err)
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := pkg.WriteLibraryCSV(&buf, entries); err != nil {
+			http.Error(w, "Could not write the library", http.StatusInternalServerError)
+			slog.ErrorContext(ctx, "Could not write the library", "error", err)
+			return
+		}
+
+		filename := "caesura-library-" + time.Now().Format(time.DateOnly) + ".csv"
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+		w.Header().Set("Cache-Control", "no-store")
+		if _, err := buf.WriteTo(w); err != nil {
+			slog.ErrorContext(ctx, "Failed to write library export", "error", err)
+			return
+		}
+		slog.InfoContext(ctx, "Library exported", "numPieces", len(entries))
+	}
+}
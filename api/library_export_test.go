@@ -0,0 +1,36 @@
+package api
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestLibraryExportHandler(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	handler := LibraryExportHandler(store, time.Second)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, withAuthSession(httptest.NewRequest("GET", "/resources/export?format=csv", nil), orgId))
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, rec.Header().Get("Content-Type"), "text/csv; charset=utf-8")
+	testutils.AssertContains(t, rec.Header().Get("Content-Disposition"), "caesura-library-")
+
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(rec.Body.String(), "\ufeff"))).ReadAll()
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(records[0], ","), strings.Join(pkg.LibraryColumns, ","))
+	testutils.AssertEqual(t, len(records), 3)
+	testutils.AssertEqual(t, records[1][1], "Demo Title 1")
+	testutils.AssertEqual(t, records[1][len(records[1])-1], "5")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, withAuthSession(httptest.NewRequest("GET", "/resources/export?format=xlsx", nil), orgId))
+	testutils.AssertEqual(t, rec.Code, http.StatusBadRequest)
+}
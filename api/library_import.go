@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+type LibraryImportResponse struct {
+	Results []pkg.LibraryImportResult `json:"results"`
+}
+
+// importedPdfs reads the PDFs uploaded in the repeated form field pdf, by file name
+func importedPdfs(r *http.Request) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for _, header := range r.MultipartForm.File["pdf"] {
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		files[path.Base(header.Filename)] = content
+	}
+	return files, nil
+}
+
+// ImportLibraryHandler creates a piece for each row of the CSV file in document. The optional pdf column names a
+// PDF uploaded in the repeated field pdf, or a URL the PDF is downloaded from with client. Each row is handled
+// separately and the outcome of each is reported in the response
+func ImportLibraryHandler(store pkg.LibraryImportStore, client *http.Client, timeout time.Duration, maxSize int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		file, ok := documentFromForm(w, r, maxSize)
+		if !ok {
+			return
+		}
+		defer file.Close()
+
+		rows, err := pkg.ParseLibraryCSV(file)
+		if err != nil {
+			http.Error(w, err.Error(), StoreErrorCode(err))
+			slog.InfoContext(r.Context(), "Rejected library import", "error", err)
+			return
+		}
+		files, err := importedPdfs(r)
+		if err != nil {
+			http.Error(w, "Failed to read the uploaded pdfs", http.StatusBadRequest)
+			slog.ErrorContext(r.Context(), "Failed to read the uploaded pdfs", "error", err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		pdfs := &pkg.ImportPdfs{Files: files, Client: client, MaxSize: int64(maxSize) << 20}
+		results := pkg.ImportLibrary(ctx, store, orgId, rows, pdfs)
+
+		var resourceIds []string
+		for _, result := range results {
+			if result.Ok {
+				resourceIds = append(resourceIds, result.ResourceId)
+				recordEvent(ctx, newRequestEvent(r, pkg.EventResourceUploaded, orgId, result.ResourceId))
+			}
+		}
+		slog.InfoContext(ctx, "Imported library", "num", len(results), "num-ok", len(resourceIds))
+
+		level := FlashSuccess
+		if len(resourceIds) < len(results) {
+			level = FlashWarning
+		}
+		if len(resourceIds) > 0 {
+			HxTrigger(w, EventResourceUploaded, map[string][]string{"resourceIds": resourceIds})
+		}
+		HxFlash(w, r, level, "flash.library-imported", struct{ Imported, Total int }{len(resourceIds), len(results)})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(LibraryImportResponse{Results: results})
+	}
+}
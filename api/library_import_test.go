@@ -0,0 +1,72 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func libraryImportForm(t *testing.T, csv string, withPdf bool) (*bytes.Buffer, string) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	document, err := writer.CreateFormFile("document", "library.csv")
+	testutils.AssertNil(t, err)
+	_, err = document.Write([]byte(csv))
+	testutils.AssertNil(t, err)
+	if withPdf {
+		pdf, err := writer.CreateFormFile("pdf", "Score.pdf")
+		testutils.AssertNil(t, err)
+		testutils.AssertNil(t, pkg.CreateNPagePdf(pdf, 1))
+	}
+	testutils.AssertNil(t, writer.Close())
+	return &buf, writer.FormDataContentType()
+}
+
+func TestImportLibraryHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.RegisterOrganization(context.Background(), &pkg.Organization{Id: "org1"}))
+	handler := ImportLibraryHandler(store, nil, time.Second, 10)
+
+	submit := func(csv string, withPdf bool) *httptest.ResponseRecorder {
+		body, contentType := libraryImportForm(t, csv, withPdf)
+		req := withAuthSession(httptest.NewRequest("POST", RouteResourcesImport, body), "org1")
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	rec := submit("title,composer,pdf\nAlpha,Grieg,Score.pdf\nBeta,Holst,\nGamma,,https://example.com/gamma.pdf\n", true)
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), string(EventResourceUploaded), "Imported 2 of 3 pieces", `"warning"`)
+
+	var resp LibraryImportResponse
+	testutils.AssertNil(t, json.NewDecoder(rec.Body).Decode(&resp))
+	testutils.AssertEqual(t, len(resp.Results), 3)
+	testutils.AssertEqual(t, resp.Results[0].ResourceId, "alpha_grieg")
+	testutils.AssertEqual(t, resp.Results[0].Ok, true)
+	testutils.AssertEqual(t, resp.Results[2].Ok, false)
+	testutils.AssertEqual(t, len(store.Data["org1"].Metadata), 2)
+	testutils.AssertEqual(t, len(store.Data["org1"].Data), 1)
+
+	t.Run("repeated import", func(t *testing.T) {
+		rec := submit("title,composer\nAlpha,Grieg\n", false)
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+		testutils.AssertContains(t, rec.Body.String(), pkg.ErrResourceExists.Error())
+		testutils.AssertEqual(t, len(store.Data["org1"].Metadata), 2)
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		rec := submit("composer\nGrieg\n", false)
+		testutils.AssertEqual(t, rec.Code, http.StatusBadRequest)
+		testutils.AssertContains(t, rec.Body.String(), "title column")
+	})
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+// ShedLoad limits how many requests the wrapped handler serves at once. Requests that do not get a slot are
+// rejected with 503 and a Retry-After header, such that clients back off instead of piling up in memory
+func ShedLoad(limiter *pkg.ConcurrencyLimiter, name string, retryAfter time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			release, err := limiter.Acquire(r.Context())
+			if err != nil {
+				if errors.Is(err, pkg.ErrOverloaded) {
+					inProgress, queued := limiter.InProgress()
+					slog.WarnContext(r.Context(), "Shedding load", "route", name, "inProgress", inProgress, "queued", queued)
+					w.Header().Set("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+				}
+				http.Error(w, "The server is busy, please retry later", http.StatusServiceUnavailable)
+				return
+			}
+			defer release()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
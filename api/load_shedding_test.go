@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestShedLoad(t *testing.T) {
+	limiter := pkg.NewConcurrencyLimiter(pkg.ConcurrencyLimit{MaxConcurrent: 1, MaxWait: time.Millisecond})
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+	})
+	handler := ShedLoad(limiter, "downloads", 1500*time.Millisecond)(slow)
+
+	done := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		done <- rec.Code
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	testutils.AssertEqual(t, rec.Code, http.StatusServiceUnavailable)
+	testutils.AssertEqual(t, rec.Header().Get("Retry-After"), "2")
+
+	close(finish)
+	testutils.AssertEqual(t, <-done, http.StatusOK)
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+type LogExportStore interface {
+	pkg.LogExportStore
+	pkg.OrganizationGetter
+	pkg.FeatureCounter
+}
+
+// CreateLogExportHandler starts an export of the activity log of the organization for a date range. The export
+// is written in the background, and the admin requesting it gets an email with a link when it is ready
+func CreateLogExportHandler(store LogExportStore, exports *pkg.LogExports, config *pkg.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 1024)
+		code, err := parseForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		from, errFrom := time.Parse(time.DateOnly, r.FormValue("from"))
+		to, errTo := time.Parse(time.DateOnly, r.FormValue("to"))
+		if errFrom != nil || errTo != nil {
+			http.Error(w, "Dates must be on the form YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		if _, _, err := pkg.LogExportRange(from, to); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		format, err := pkg.ParseLogExportFormat(r.FormValue("format"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		session := MustGetSession(r)
+		orgId := MustGetOrgId(session)
+		user := MustGetUserInfo(session)
+		if user.Email == "" {
+			http.Error(w, "An email address is needed to receive the export", http.StatusBadRequest)
+			return
+		}
+
+		// The export outlives the request
+		ctx := context.WithoutCancel(r.Context())
+		exports.Go(func() {
+			ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+			defer cancel()
+			if err := exportLogs(ctx, store, exports, config, orgId, user.Email, from, to, format); err != nil {
+				slog.ErrorContext(ctx, "Could not export logs", "error", err, "orgId", orgId)
+			}
+		})
+		slog.InfoContext(r.Context(), "Started log export", "orgId", orgId, "from", from, "to", to, "format", format)
+		HxFlash(w, r, FlashSuccess, "flash.log-export-started", map[string]any{"Email": user.Email})
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func exportLogs(ctx context.Context, store LogExportStore, exports *pkg.LogExports, config *pkg.Config, orgId, recipent string, from, to time.Time, format pkg.LogExportFormat) error {
+	export, err := exports.Create(ctx, store, orgId, from, to, format)
+	if err != nil {
+		return err
+	}
+
+	var branding *pkg.Branding
+	if org, err := store.GetOrganization(ctx, orgId); err == nil {
+		branding = &org.Branding
+	}
+	email := pkg.Email{
+		Sender:    config.EmailSender,
+		SmtpHost:  config.SmtpConfig.Host,
+		SmtpPort:  config.SmtpConfig.Port,
+		SmtpAuth:  config.SmtpConfig.Auth,
+		Recipents: []string{recipent},
+		SendFn:    config.SmtpConfig.SendFn,
+		Branding:  branding,
+	}
+	link := config.BaseURL + RouteOrganizationsLogsExports + "/" + export.Id
+	body := fmt.Sprintf(
+		"The log from %s to %s has %d entries. Download it within %s: %s",
+		from.Format(time.DateOnly), to.Format(time.DateOnly), export.NumEntries, config.LogExportExpiry, link,
+	)
+	content, err := email.Build("Caesura: log export", body, func(yield func(string, io.Reader) bool) {})
+	if err != nil {
+		return err
+	}
+	if err := email.Send(ctx, content.Bytes()); err != nil {
+		return err
+	}
+	if err := store.CountFeature(ctx, orgId, pkg.FeatureEmailSent, time.Now()); err != nil {
+		slog.ErrorContext(ctx, "Could not count feature usage", "feature", pkg.FeatureEmailSent, "error", err)
+	}
+	return nil
+}
+
+// LogExportDownloadHandler serves an export of the organization of the session
+func LogExportDownloadHandler(exports *pkg.LogExports) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgId := MustGetOrgId(MustGetSession(r))
+		export, f, err := exports.Open(orgId, r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "The export does not exist or has expired", StoreErrorCode(err))
+			return
+		}
+		defer f.Close()
+
+		w.Header().Set("Content-Type", export.Format.ContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.Filename()))
+		w.Header().Set("Cache-Control", "no-store")
+		if _, err := io.Copy(w, f); err != nil {
+			slog.ErrorContext(r.Context(), "Could not send log export", "error", err, "id", export.Id)
+		}
+	}
+}
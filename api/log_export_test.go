@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func logExportRequest(orgId, email string, form url.Values) *http.Request {
+	req := httptest.NewRequest("POST", RouteOrganizationsLogsExports, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = withAuthSession(req, orgId)
+	user := MustGetUserInfo(MustGetSession(req))
+	user.Email = email
+	MustGetSession(req).Values["role"] = must(json.Marshal(user))
+	return req
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func TestLogExport(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterUser(ctx, &pkg.UserInfo{Id: "a", Name: "Anna", Email: "a@example.com", Roles: map[string]pkg.RoleKind{"org": pkg.RoleEditor}}))
+	activity := pkg.NewActivity("spring", pkg.ActivityDownload, "a", []string{"polka"})
+	activity.Time = time.Date(2026, 3, 15, 18, 0, 0, 0, time.UTC)
+	testutils.AssertNil(t, store.RecordActivity(ctx, "org", activity))
+
+	var message string
+	config := pkg.NewDefaultConfig()
+	config.SmtpConfig.SendFn = func(addr string, auth smtp.Auth, sender string, to []string, m []byte) error {
+		testutils.AssertEqual(t, strings.Join(to, ","), "admin@example.com")
+		message = string(m)
+		return nil
+	}
+	exports := pkg.NewLogExports(t.TempDir(), time.Hour)
+
+	recorder := httptest.NewRecorder()
+	form := url.Values{"from": {"2026-03-01"}, "to": {"2026-03-15"}, "format": {"csv"}}
+	CreateLogExportHandler(store, exports, config)(recorder, logExportRequest("org", "admin@example.com", form))
+	testutils.AssertEqual(t, recorder.Code, http.StatusAccepted)
+	exports.Wait()
+
+	// Undo the soft line breaks of the quoted-printable body
+	message = strings.NewReplacer("=\r\n", "", "=\n", "").Replace(message)
+	link := regexp.MustCompile(`/organizations/logs/exports/[0-9a-f-]+`).FindString(message)
+	testutils.AssertContains(t, message, "1 entries")
+
+	download := func(orgId string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", link, nil)
+		req.SetPathValue("id", strings.TrimPrefix(link, RouteOrganizationsLogsExports+"/"))
+		LogExportDownloadHandler(exports)(rec, withAuthSession(req, orgId))
+		return rec
+	}
+
+	rec := download("org")
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, rec.Header().Get("Content-Type"), "text/csv; charset=utf-8")
+	testutils.AssertContains(t, rec.Header().Get("Content-Disposition"), "caesura-log-2026-03-01-2026-03-15.csv")
+	testutils.AssertContains(t, rec.Body.String(), "2026-03-15T18:00:00Z,download,a,Anna,a@example.com,spring,polka")
+
+	// Exports are only available to the organization they were made for
+	testutils.AssertEqual(t, download("other").Code, http.StatusNotFound)
+}
+
+func TestCreateLogExportBadRequest(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	exports := pkg.NewLogExports(t.TempDir(), time.Hour)
+	for _, test := range []struct {
+		desc  string
+		email string
+		form  url.Values
+	}{
+		{"invalid date", "admin@example.com", url.Values{"from": {"yesterday"}, "to": {"2026-03-15"}, "format": {"csv"}}},
+		{"reversed range", "admin@example.com", url.Values{"from": {"2026-03-15"}, "to": {"2026-03-01"}, "format": {"csv"}}},
+		{"too long", "admin@example.com", url.Values{"from": {"2020-01-01"}, "to": {"2026-03-01"}, "format": {"csv"}}},
+		{"unknown format", "admin@example.com", url.Values{"from": {"2026-03-01"}, "to": {"2026-03-15"}, "format": {"xml"}}},
+		{"no email", "", url.Values{"from": {"2026-03-01"}, "to": {"2026-03-15"}, "format": {"json"}}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			CreateLogExportHandler(store, exports, pkg.NewDefaultConfig())(recorder, logExportRequest("org", test.email, test.form))
+			testutils.AssertEqual(t, recorder.Code, http.StatusBadRequest)
+		})
+	}
+}
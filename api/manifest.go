@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+// ResourceManifestHandler lists the files of a resource with sizes, hashes and modification times such that
+// sync clients only download files that changed. Clients can send the ETag of the previous manifest in
+// If-None-Match to avoid downloading an unchanged manifest
+func ResourceManifestHandler(store pkg.ResourceManifestGetter, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		resourceId := r.PathValue("id")
+		manifest, err := store.ResourceManifest(ctx, orgId, resourceId)
+		if err != nil {
+			http.Error(w, "Failed to fetch manifest", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Failed to fetch manifest", "resourceId", resourceId, "error", err)
+			return
+		}
+
+		body, err := json.Marshal(manifest)
+		if err != nil {
+			http.Error(w, "Failed to encode manifest", http.StatusInternalServerError)
+			slog.ErrorContext(ctx, "Failed to encode manifest", "error", err)
+			return
+		}
+
+		hash := sha256.Sum256(body)
+		etag := `"` + hex.EncodeToString(hash[:16]) + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "private, no-cache")
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}
+}
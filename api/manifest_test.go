@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func manifestStore(t *testing.T) (*pkg.MultiOrgInMemoryStore, string) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	store.Data["org"] = pkg.NewInMemoryStore()
+	meta := pkg.MetaData{Title: "Polka", Composer: "Strauss"}
+	parts := func(yield func(string, []byte) bool) {
+		for _, name := range []string{"Trumpet", "Horn"} {
+			if !yield(name+".pdf", []byte(strings.ToLower(name))) {
+				return
+			}
+		}
+	}
+	testutils.AssertNil(t, store.Submit(context.Background(), "org", &meta, parts))
+	return store, meta.ResourceId()
+}
+
+func TestResourceManifestHandler(t *testing.T) {
+	store, resourceId := manifestStore(t)
+	mux := http.NewServeMux()
+	mux.Handle("GET "+RouteApiResourcesIdManifest, ResourceManifestHandler(store, time.Second))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, withAuthSession(httptest.NewRequest("GET", "/api/v1/resources/"+resourceId+"/manifest", nil), "org"))
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, rec.Header().Get("Content-Type"), "application/json")
+
+	var manifest pkg.ResourceManifest
+	testutils.AssertNil(t, json.Unmarshal(rec.Body.Bytes(), &manifest))
+	testutils.AssertEqual(t, manifest.ResourceId, resourceId)
+	testutils.AssertEqual(t, len(manifest.Files), 2)
+	testutils.AssertEqual(t, manifest.Files[0].Name, "Horn.pdf")
+	testutils.AssertEqual(t, manifest.Files[0].Size, int64(4))
+	testutils.AssertEqual(t, manifest.Files[1].MD5, "44fbd495d8919c6ffb79bc4838096717")
+
+	t.Run("Not modified", func(t *testing.T) {
+		req := withAuthSession(httptest.NewRequest("GET", "/api/v1/resources/"+resourceId+"/manifest", nil), "org")
+		req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+		notModified := httptest.NewRecorder()
+		mux.ServeHTTP(notModified, req)
+		testutils.AssertEqual(t, notModified.Code, http.StatusNotModified)
+		testutils.AssertEqual(t, notModified.Body.Len(), 0)
+	})
+
+	t.Run("Missing resource", func(t *testing.T) {
+		missing := httptest.NewRecorder()
+		mux.ServeHTTP(missing, withAuthSession(httptest.NewRequest("GET", "/api/v1/resources/missing/manifest", nil), "org"))
+		testutils.AssertEqual(t, missing.Code, http.StatusNotFound)
+	})
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+	"github.com/gorilla/sessions"
+)
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// CountFeature increments the usage counter of the feature when the wrapped handler succeeds.
+// Only the organization is recorded, not the user. Partial and not modified responses are not counted,
+// since they repeat an earlier request
+func CountFeature(counter pkg.FeatureCounter, feature pkg.Feature) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			if rec.status >= http.StatusBadRequest || rec.status == http.StatusPartialContent || rec.status == http.StatusNotModified {
+				return
+			}
+
+			orgId, _ := r.Context().Value(pkg.OrgIdKey).(string)
+			if err := counter.CountFeature(r.Context(), orgId, feature, time.Now()); err != nil {
+				slog.ErrorContext(r.Context(), "Could not count feature usage", "feature", feature, "error", err)
+			}
+		})
+	}
+}
+
+func RequirePlatformAdmin(admins []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userId, _ := MustGetSession(r).Values["userId"].(string)
+			if userId == "" || !slices.Contains(admins, userId) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				slog.InfoContext(r.Context(), "User is not platform admin", "userId", userId)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func RequirePlatformAdminSession(admins []string, registry pkg.SessionRegistry, config *pkg.Config, cookieStore *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
+	return Chain(
+		RequireSession(cookieStore, AuthSession, opts),
+		TrackSession(registry, config.Timeout),
+		RequireUserId(cookieStore),
+		RequirePlatformAdmin(admins),
+	)
+}
+
+func FeatureMetricsHandler(store pkg.FeatureCountGetter, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		numWeeks := 12
+		if weeks, err := strconv.Atoi(r.URL.Query().Get("weeks")); err == nil && weeks > 0 {
+			numWeeks = weeks
+		}
+
+		since := time.Now().AddDate(0, 0, -7*numWeeks)
+		counts, err := store.FeatureCounts(ctx, since)
+		if err != nil {
+			http.Error(w, "Could not load feature metrics", http.StatusInternalServerError)
+			slog.ErrorContext(ctx, "Could not load feature metrics", "error", err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		web.FeatureMetricsPage(w, pkg.LanguageFromReq(r), counts)
+	}
+}
+
+// DebugConfigHandler shows the effective configuration and the layers it was loaded from. Secrets are redacted
+func DebugConfigHandler(config *pkg.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := config.Redacted()
+		if err != nil {
+			http.Error(w, "Could not serialize configuration", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Could not serialize configuration", "error", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(data)
+	}
+}
+
+type TranslationCoverage struct {
+	// Missing holds the keys each language lacks. Complete languages have an empty list
+	Missing map[string][]string `json:"missing"`
+}
+
+// DebugTranslationsHandler reports the translation keys each language lacks. The embedded translations are checked
+// when the server starts, so keys are only missing when the templates are read from a directory and the
+// translations have been edited since
+func DebugTranslationsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		missing, err := web.MissingTranslations()
+		if err != nil {
+			http.Error(w, "Could not check translations", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Could not check translations", "error", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(TranslationCoverage{Missing: missing})
+	}
+}
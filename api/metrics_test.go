@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/gorilla/sessions"
+)
+
+func TestCountFeature(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	success := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	failure := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad", http.StatusBadRequest)
+	})
+	partial := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusPartialContent)
+	})
+
+	ctx := context.WithValue(context.Background(), pkg.OrgIdKey, "org1")
+	req := httptest.NewRequest("POST", "/resources", nil).WithContext(ctx)
+
+	CountFeature(store, pkg.FeatureUpload)(success).ServeHTTP(httptest.NewRecorder(), req)
+	CountFeature(store, pkg.FeatureUpload)(failure).ServeHTTP(httptest.NewRecorder(), req)
+	CountFeature(store, pkg.FeatureUpload)(partial).ServeHTTP(httptest.NewRecorder(), req)
+
+	counts, err := store.FeatureCounts(context.Background(), time.Now())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(counts), 1)
+	testutils.AssertEqual(t, counts[0].OrgId, "org1")
+	testutils.AssertEqual(t, counts[0].Count, 1)
+}
+
+func TestRequirePlatformAdmin(t *testing.T) {
+	handler := RequirePlatformAdmin([]string{"admin"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, test := range []struct {
+		userId string
+		want   int
+	}{
+		{"admin", http.StatusOK},
+		{"someone", http.StatusForbidden},
+		{"", http.StatusForbidden},
+	} {
+		session := sessions.NewSession(&errorStore{}, AuthSession)
+		if test.userId != "" {
+			session.Values["userId"] = test.userId
+		}
+		req := httptest.NewRequest("GET", RouteAdminMetrics, nil)
+		req = req.WithContext(context.WithValue(req.Context(), sessionKey, session))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		testutils.AssertEqual(t, rec.Code, test.want)
+	}
+}
+
+type failingFeatureCountGetter struct{}
+
+func (f *failingFeatureCountGetter) FeatureCounts(ctx context.Context, since time.Time) ([]pkg.FeatureCount, error) {
+	return []pkg.FeatureCount{}, errors.New("unavailable")
+}
+
+func TestFeatureMetricsHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.CountFeature(context.Background(), "org1", pkg.FeatureDownload, time.Now()))
+
+	rec := httptest.NewRecorder()
+	FeatureMetricsHandler(store, time.Second)(rec, httptest.NewRequest("GET", RouteAdminMetrics+"?weeks=2", nil))
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Body.String(), "org1", "download")
+
+	rec = httptest.NewRecorder()
+	FeatureMetricsHandler(&failingFeatureCountGetter{}, time.Second)(rec, httptest.NewRequest("GET", RouteAdminMetrics, nil))
+	testutils.AssertEqual(t, rec.Code, http.StatusInternalServerError)
+}
+
+func TestDebugConfigHandler(t *testing.T) {
+	config := pkg.NewDefaultConfig()
+	config.Sources = []string{"defaults", "config-test.yml"}
+	config.StripeSecretKey = "sk_test_123"
+
+	rec := httptest.NewRecorder()
+	DebugConfigHandler(config)(rec, httptest.NewRequest("GET", RouteDebugConfig, nil))
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, rec.Header().Get("Content-Type"), "application/yaml")
+	testutils.AssertContains(t, rec.Body.String(), "config-test.yml", "stripe_secret_key: <redacted>")
+	testutils.AssertNotContains(t, rec.Body.String(), "sk_test_123")
+}
+
+func TestDebugTranslationsHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	DebugTranslationsHandler()(rec, httptest.NewRequest("GET", RouteDebugTranslations, nil))
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, rec.Header().Get("Content-Type"), "application/json")
+
+	var coverage TranslationCoverage
+	testutils.AssertNil(t, json.NewDecoder(rec.Body).Decode(&coverage))
+	testutils.AssertEqual(t, len(coverage.Missing["en"]), 0)
+	testutils.AssertEqual(t, len(coverage.Missing["nb"]), 0)
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"golang.org/x/oauth2"
+)
+
+func HandleMicrosoftLogin(oauthConfig *oauth2.Config) http.HandlerFunc {
+	return handleOAuthLogin(oauthConfig)
+}
+
+// HandleMicrosoftCallback signs in users with a Microsoft account. The profile is read from Microsoft Graph, and
+// whether the email is verified from the id token
+func HandleMicrosoftCallback(roleStore LoginStore, oauthConfig *oauth2.Config, userInfoURL string, timeout time.Duration, signSecret string, transport http.RoundTripper) http.HandlerFunc {
+	decode := func(body io.Reader, token *oauth2.Token) (pkg.UserInfo, error) {
+		var profile pkg.MicrosoftUserInfo
+		if err := json.NewDecoder(body).Decode(&profile); err != nil {
+			return pkg.UserInfo{}, err
+		}
+		if profile.Id == "" {
+			return pkg.UserInfo{}, errors.New("profile has no id")
+		}
+
+		// Without valid claims the user can sign in, but the email is not verified
+		var claims *pkg.MicrosoftIdTokenClaims
+		if idToken, ok := token.Extra("id_token").(string); ok {
+			var err error
+			if claims, err = pkg.ParseMicrosoftIdToken(idToken, oauthConfig.ClientID, time.Now()); err != nil {
+				slog.Warn("Invalid Microsoft id token", "error", err)
+			}
+		}
+		return profile.UserInfo(claims), nil
+	}
+	return handleOAuthCallback(roleStore, oauthConfig, userInfoURL, timeout, signSecret, transport, decode)
+}
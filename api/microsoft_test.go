@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/sessions"
+	"golang.org/x/oauth2/microsoft"
+)
+
+type microsoftTransport struct {
+	profile string
+	idToken string
+}
+
+func (m *microsoftTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := m.profile
+	if req.URL.String() == microsoft.AzureADEndpoint("").TokenURL {
+		body = fmt.Sprintf(`{"access_token": "test-access-token", "expires_in": 3600, "token_type": "Bearer", "id_token": %q}`, m.idToken)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+	}, nil
+}
+
+func TestHandleMicrosoftLogin(t *testing.T) {
+	cookie := sessions.NewCookieStore([]byte("some-random-key"))
+	handler := RequireSession(cookie, AuthSession, &sessions.Options{})(HandleMicrosoftLogin(pkg.NewDefaultConfig().MicrosoftOAuthConfig()))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", RouteLoginMicrosoft, nil))
+	testutils.AssertEqual(t, recorder.Code, http.StatusTemporaryRedirect)
+	testutils.AssertContains(t, recorder.Header().Get("Location"), "https://login.microsoftonline.com/common/oauth2/v2.0/authorize")
+}
+
+func TestHandleMicrosoftCallback(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	transport := microsoftTransport{
+		profile: `{"id": "b5d1c7e2-0a4f-4c3e-9d8b-2f6a1e7c3b90", "displayName": "Ola Nordmann", "mail": null, "userPrincipalName": "Ola@Example.onmicrosoft.com"}`,
+	}
+	handler := HandleMicrosoftCallback(store, pkg.NewDefaultConfig().MicrosoftOAuthConfig(), pkg.MicrosoftUserInfoURL, time.Second, "signKey", &transport)
+
+	req := prepareGoogleCallbackRequest(sessions.NewCookieStore([]byte("some-random-key")))
+	recorder := httptest.NewRecorder()
+	handler(recorder, req)
+	testutils.AssertEqual(t, recorder.Code, http.StatusSeeOther)
+
+	userId := pkg.ExternalUserId(pkg.MicrosoftIssuer, "b5d1c7e2-0a4f-4c3e-9d8b-2f6a1e7c3b90")
+	testutils.AssertEqual(t, MustGetSession(req).Values["userId"], any(userId))
+	user, err := store.GetUserInfo(context.Background(), userId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, user.Email, "ola@example.onmicrosoft.com")
+	testutils.AssertEqual(t, user.Name, "Ola Nordmann")
+
+	// Without an id token verifying the email, the principal name is not trusted
+	testutils.AssertEqual(t, user.VerifiedEmail, false)
+}
+
+func TestHandleMicrosoftCallbackVerifiedEmail(t *testing.T) {
+	oauthConfig := pkg.NewDefaultConfig().MicrosoftOAuthConfig()
+	oauthConfig.ClientID = "client-id"
+	claims := pkg.MicrosoftIdTokenClaims{
+		Email:                  "ola@example.com",
+		EmailDomainOwnerVerify: true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{"client-id"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	idToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("key"))
+	testutils.AssertNil(t, err)
+
+	store := pkg.NewMultiOrgInMemoryStore()
+	transport := microsoftTransport{profile: `{"id": "1", "displayName": "Ola", "mail": "Ola@Example.com"}`, idToken: idToken}
+	handler := HandleMicrosoftCallback(store, oauthConfig, pkg.MicrosoftUserInfoURL, time.Second, "signKey", &transport)
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, prepareGoogleCallbackRequest(sessions.NewCookieStore([]byte("some-random-key"))))
+	testutils.AssertEqual(t, recorder.Code, http.StatusSeeOther)
+
+	user, err := store.GetUserInfo(context.Background(), pkg.ExternalUserId(pkg.MicrosoftIssuer, "1"))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, user.VerifiedEmail, true)
+}
+
+func TestHandleMicrosoftCallbackProfileWithoutId(t *testing.T) {
+	transport := microsoftTransport{profile: `{"displayName": "Nobody"}`}
+	handler := HandleMicrosoftCallback(pkg.NewMultiOrgInMemoryStore(), pkg.NewDefaultConfig().MicrosoftOAuthConfig(), pkg.MicrosoftUserInfoURL, time.Second, "signKey", &transport)
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, prepareGoogleCallbackRequest(sessions.NewCookieStore([]byte("some-random-key"))))
+	testutils.AssertEqual(t, recorder.Code, http.StatusInternalServerError)
+	testutils.AssertContains(t, strings.ToLower(recorder.Body.String()), "no id")
+}
@@ -3,8 +3,11 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/davidkleiven/caesura/pkg"
 	"github.com/gorilla/sessions"
@@ -49,13 +52,237 @@ func RequireSession(cookieStore *sessions.CookieStore, name string, opts *sessio
 				}
 			}
 
-			session.Options = opts
+			applyDomainSession(r, session, opts)
 			ctx := context.WithValue(r.Context(), sessionKey, session)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// ApiTokenSessionStore is used to sign in requests made with an API token
+type ApiTokenSessionStore interface {
+	pkg.RoleGetter
+	pkg.ApiTokenGetter
+}
+
+// requestOnlyStore keeps the sessions of API tokens in memory. Scripts send the token with every request, and a
+// cookie must never outlive the token it was created from
+type requestOnlyStore struct{}
+
+func (requestOnlyStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.NewSession(requestOnlyStore{}, name), nil
+}
+
+func (requestOnlyStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.NewSession(requestOnlyStore{}, name), nil
+}
+
+func (requestOnlyStore) Save(r *http.Request, w http.ResponseWriter, s *sessions.Session) error {
+	return nil
+}
+
+// apiTokenSession returns a session acting as the owner of the token. The roles of the session are limited to the
+// organization and the scope of the token. SCIM tokens are only accepted when scim is true, and then only SCIM
+// tokens are accepted
+func apiTokenSession(ctx context.Context, store ApiTokenSessionStore, secret string, scim bool) (*sessions.Session, int, error) {
+	token, err := store.ApiTokenByHash(ctx, pkg.HashApiToken(secret))
+	switch {
+	case errors.Is(err, pkg.ErrApiTokenNotFound):
+		return nil, http.StatusUnauthorized, errors.New("Invalid API token")
+	case err != nil:
+		return nil, StoreErrorCode(err), err
+	case token.Expired(time.Now()):
+		return nil, http.StatusUnauthorized, errors.New("API token has expired")
+	case token.Scope == pkg.ApiTokenScim && !scim:
+		return nil, http.StatusForbidden, errors.New("SCIM tokens can only be used for provisioning")
+	case token.Scope != pkg.ApiTokenScim && scim:
+		return nil, http.StatusForbidden, errors.New("Provisioning requires a SCIM token")
+	}
+
+	return scopedSession(ctx, store, token.UserId, token.OrgId, token.Scope.Role(), token.Passkey, token.Id)
+}
+
+// scopedSession returns a session acting as the user in a single organization with at most the role limit. The
+// session is marked as signed in with the token with id tokenId
+func scopedSession(ctx context.Context, store pkg.RoleGetter, userId, orgId string, limit pkg.RoleKind, passkey bool, tokenId string) (*sessions.Session, int, error) {
+	userInfo, err := store.GetUserInfo(ctx, userId)
+	if errors.Is(err, pkg.ErrUserNotFound) {
+		return nil, http.StatusUnauthorized, errors.New("Invalid API token")
+	} else if err != nil {
+		return nil, StoreErrorCode(err), err
+	}
+
+	scoped := pkg.UserInfo{Id: userInfo.Id, Roles: map[string]pkg.RoleKind{}, Groups: map[string][]string{}}
+	if role, ok := userInfo.Roles[orgId]; ok {
+		if !role.AtLeast(limit) {
+			limit = role
+		}
+		scoped.Roles[orgId] = limit
+	}
+	if groups, ok := userInfo.Groups[orgId]; ok {
+		scoped.Groups[orgId] = groups
+	}
+
+	session := sessions.NewSession(requestOnlyStore{}, AuthSession)
+	pkg.PopulateSessionWithRoles(session, &scoped)
+	session.Values["userId"] = userId
+	session.Values["orgId"] = orgId
+	session.Values[sessionPasskeyKey] = passkey
+	session.Values[sessionApiTokenKey] = tokenId
+	return session, http.StatusOK, nil
+}
+
+// RequireSessionOrApiToken signs in requests with an "Authorization: Bearer" header using the API token or the
+// access token in the header. Other requests use the session cookie
+func RequireSessionOrApiToken(store ApiTokenSessionStore, timeout time.Duration, signSecret string, cookieStore *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
+	requireSession := RequireSession(cookieStore, AuthSession, opts)
+	return func(next http.Handler) http.Handler {
+		withCookie := requireSession(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok {
+				withCookie.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			var (
+				session *sessions.Session
+				code    int
+				err     error
+			)
+			if secret = strings.TrimSpace(secret); strings.HasPrefix(secret, pkg.ApiTokenPrefix) {
+				session, code, err = apiTokenSession(ctx, store, secret, false)
+			} else {
+				session, code, err = accessTokenSession(ctx, store, secret, signSecret)
+			}
+			cancel()
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="caesura"`)
+				http.Error(w, err.Error(), code)
+				slog.InfoContext(r.Context(), "Rejected API token", "error", err)
+				return
+			}
+
+			slog.InfoContext(r.Context(), "Request signed in with API token", "tokenId", session.Values[sessionApiTokenKey])
+			ctx = context.WithValue(r.Context(), sessionKey, session)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireScimToken signs in identity providers with the SCIM token in the "Authorization: Bearer" header. The
+// owner of the token must still be an admin of the organization
+func RequireScimToken(store ApiTokenSessionStore, timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="caesura"`)
+				writeScimError(w, http.StatusUnauthorized, "", "Missing bearer token")
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			session, code, err := apiTokenSession(ctx, store, strings.TrimSpace(secret), true)
+			cancel()
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="caesura"`)
+				writeScimError(w, code, "", err.Error())
+				slog.InfoContext(r.Context(), "Rejected SCIM token", "error", err)
+				return
+			}
+
+			userId := MustGetUserId(session)
+			orgId := MustGetOrgId(session)
+			ctx = context.WithValue(r.Context(), sessionKey, session)
+			ctx = context.WithValue(ctx, pkg.UserIdKey, userId)
+			ctx = context.WithValue(ctx, pkg.OrgIdKey, orgId)
+			if !MustGetUserInfo(session).Roles[orgId].AtLeast(pkg.RoleAdmin) {
+				writeScimError(w, http.StatusForbidden, "", "The owner of the token is not an admin of the organization")
+				slog.InfoContext(ctx, "SCIM token owner is not an admin")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+const (
+	sessionRefreshedAtKey        = "refreshedAt"
+	sessionPermissionsVersionKey = "permissionsVersion"
+)
+
+// SessionRoleStore is used to keep the roles stored in the session cookie up to date
+type SessionRoleStore interface {
+	pkg.RoleGetter
+	pkg.PermissionsVersionGetter
+	pkg.OrganizationGetter
+}
+
+// RefreshSession re-issues the session cookie of signed in users when it is older than the refresh interval.
+// This slides the expiry of the cookie forward as long as the user is active. The roles of the user are read
+// from the store at the same time. Roles are also read as soon as the permissions version of the user differs
+// from the version stored in the session, such that role changes take effect without waiting for the
+// interval. If the roles can not be fetched the session is kept as is and refreshed on a later request
+func RefreshSession(store SessionRoleStore, interval time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session := MustGetSession(r)
+			userId, ok := session.Values["userId"].(string)
+			_, isApiToken := session.Values[sessionApiTokenKey]
+			if !ok || isApiToken {
+				// The roles of API tokens were just read from the store
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			sessionVersion, _ := session.Values[sessionPermissionsVersionKey].(int64)
+			version, err := store.PermissionsVersion(r.Context(), userId)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Could not fetch permissions version", "error", err, "userId", userId)
+				version = sessionVersion
+			}
+
+			refreshedAt, _ := session.Values[sessionRefreshedAtKey].(int64)
+			now := time.Now()
+			expired := interval > 0 && now.Sub(time.Unix(refreshedAt, 0)) >= interval
+			if !expired && version == sessionVersion {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			userInfo, err := store.GetUserInfo(r.Context(), userId)
+			switch {
+			case errors.Is(err, pkg.ErrUserNotFound):
+				slog.InfoContext(r.Context(), "User of session no longer exists. Clearing roles", "userId", userId)
+				delete(session.Values, "role")
+				delete(session.Values, "userId")
+			case err != nil:
+				slog.ErrorContext(r.Context(), "Could not refresh roles of session", "error", err, "userId", userId)
+				next.ServeHTTP(w, r)
+				return
+			default:
+				// The active organization is kept even if the role is revoked, such that access is denied
+				// instead of silently switching organization
+				orgId, hasOrgId := session.Values["orgId"]
+				pkg.PopulateSessionWithRoles(session, userInfo)
+				if hasOrgId {
+					session.Values["orgId"] = orgId
+				}
+
+				// Passkey requirements enabled since the last check take effect from now on
+				delete(session.Values, sessionPasskeyCheckedOrgKey)
+			}
+
+			session.Values[sessionRefreshedAtKey] = now.Unix()
+			session.Values[sessionPermissionsVersionKey] = version
+			trySaveSession(session, r, w)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func RequireMinimumRole(cookieStore *sessions.CookieStore, minimumRole pkg.RoleKind) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -85,7 +312,12 @@ func RequireMinimumRole(cookieStore *sessions.CookieStore, minimumRole pkg.RoleK
 			ctx := context.WithValue(r.Context(), pkg.UserIdKey, role.Id)
 			ctx = context.WithValue(ctx, pkg.OrgIdKey, orgId)
 
-			if orgRole, ok := role.Roles[orgId]; !ok || orgRole < minimumRole {
+			orgRole, ok := role.Roles[orgId]
+			if impersonatedOrg(session) == orgId {
+				// Impersonating operators view the organization as a viewer, see RestrictImpersonation
+				orgRole, ok = pkg.RoleViewer, true
+			}
+			if !ok || !orgRole.AtLeast(minimumRole) {
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				slog.InfoContext(ctx, "User is unauthorized", "role", orgRole, "required-role", minimumRole, "role-provided", ok)
 				return
@@ -96,6 +328,51 @@ func RequireMinimumRole(cookieStore *sessions.CookieStore, minimumRole pkg.RoleK
 	}
 }
 
+// orgRequiresPasskey returns true when only users signed in with a passkey may access the organization. Unknown
+// organizations do not require passkeys
+func orgRequiresPasskey(ctx context.Context, store pkg.OrganizationGetter, orgId string) (bool, error) {
+	org, err := store.GetOrganization(ctx, orgId)
+	if errors.Is(err, pkg.ErrOrganizationNotFound) {
+		return false, nil
+	}
+	return err == nil && org.RequirePasskey, err
+}
+
+// RequirePasskeyIfEnforced denies access to organizations that require passkeys unless the user signed in with
+// a passkey. Organizations that do not require passkeys are remembered in the session, such that the organization
+// is only looked up again when the session is refreshed or another organization is chosen
+func RequirePasskeyIfEnforced(store pkg.OrganizationGetter, timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session := MustGetSession(r)
+			orgId, _ := session.Values["orgId"].(string)
+			signedInWithPasskey, _ := session.Values[sessionPasskeyKey].(bool)
+			if signedInWithPasskey || orgId == "" || session.Values[sessionPasskeyCheckedOrgKey] == orgId {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			required, err := orgRequiresPasskey(ctx, store, orgId)
+			cancel()
+			switch {
+			case err != nil:
+				http.Error(w, "Could not check the sign in requirements of the organization", StoreErrorCode(err))
+				slog.ErrorContext(r.Context(), "Could not fetch organization", "error", err, "orgId", orgId)
+				return
+			case required:
+				http.Error(w, "The organization requires that you sign in with a passkey", http.StatusForbidden)
+				slog.InfoContext(r.Context(), "Denied access to organization requiring passkeys", "orgId", orgId)
+				return
+			}
+
+			session.Values[sessionPasskeyCheckedOrgKey] = orgId
+			trySaveSession(session, r, w)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func RequireUserId(cookieStore *sessions.CookieStore) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -175,45 +452,112 @@ func RequireWriteSubscription(store pkg.SubscriptionValidator, config *pkg.Confi
 	}
 }
 
-func RequireRead(cookieStore *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
+type AccessStore interface {
+	pkg.SubscriptionValidator
+	SessionRoleStore
+	pkg.ApiTokenGetter
+	pkg.SessionRegistry
+}
+
+// cachedAccessStore looks up permissions versions through a short lived cache, since they are checked on
+// every request with a session
+type cachedAccessStore struct {
+	AccessStore
+	versions *pkg.CachedPermissionsVersions
+}
+
+func (c *cachedAccessStore) PermissionsVersion(ctx context.Context, userId string) (int64, error) {
+	return c.versions.PermissionsVersion(ctx, userId)
+}
+
+func RequireRead(store AccessStore, config *pkg.Config, cookieStore *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
 	return Chain(
-		RequireSession(cookieStore, AuthSession, opts),
+		RequireSessionOrApiToken(store, config.Timeout, config.CookieSecretSignKey, cookieStore, opts),
+		TrackSession(store, config.Timeout),
+		RefreshSession(store, config.SessionRefreshInterval),
+		RestrictImpersonation(config.PlatformAdmins),
 		RequireMinimumRole(cookieStore, pkg.RoleViewer),
+		RequirePasskeyIfEnforced(store, config.Timeout),
 	)
 }
 
-func RequireWrite(store pkg.SubscriptionValidator, config *pkg.Config, cookieStore *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
+func RequireWrite(store AccessStore, config *pkg.Config, cookieStore *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
 	return Chain(
-		RequireSession(cookieStore, AuthSession, opts),
+		RequireSessionOrApiToken(store, config.Timeout, config.CookieSecretSignKey, cookieStore, opts),
+		TrackSession(store, config.Timeout),
+		RefreshSession(store, config.SessionRefreshInterval),
 		RequireWriteSubscription(store, config),
+		RestrictImpersonation(config.PlatformAdmins),
 		RequireMinimumRole(cookieStore, pkg.RoleEditor),
+		RequirePasskeyIfEnforced(store, config.Timeout),
 	)
 }
 
-func RequireAdmin(store pkg.SubscriptionValidator, config *pkg.Config, cookieStore *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
+func RequireAdmin(store AccessStore, config *pkg.Config, cookieStore *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
 	return Chain(
 		RequireSession(cookieStore, AuthSession, opts),
+		TrackSession(store, config.Timeout),
+		RefreshSession(store, config.SessionRefreshInterval),
 		RequireWriteSubscription(store, config),
+		RestrictImpersonation(config.PlatformAdmins),
 		RequireMinimumRole(cookieStore, pkg.RoleAdmin),
+		RequirePasskeyIfEnforced(store, config.Timeout),
 	)
 }
 
-func RequireAdminWithoutSubscription(cookieStore *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
+func RequireAdminWithoutSubscription(store AccessStore, config *pkg.Config, cookieStore *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
 	return Chain(
 		RequireSession(cookieStore, AuthSession, opts),
+		TrackSession(store, config.Timeout),
+		RefreshSession(store, config.SessionRefreshInterval),
+		RestrictImpersonation(config.PlatformAdmins),
 		RequireMinimumRole(cookieStore, pkg.RoleAdmin),
+		RequirePasskeyIfEnforced(store, config.Timeout),
 	)
 }
 
-func RequireSignedIn(cookieStore *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
+// RequireLibrarian grants access to members that manage resources, which are librarians and admins
+func RequireLibrarian(store AccessStore, config *pkg.Config, cookieStore *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
 	return Chain(
 		RequireSession(cookieStore, AuthSession, opts),
+		TrackSession(store, config.Timeout),
+		RefreshSession(store, config.SessionRefreshInterval),
+		RequireWriteSubscription(store, config),
+		RestrictImpersonation(config.PlatformAdmins),
+		RequireMinimumRole(cookieStore, pkg.RoleLibrarian),
+		RequirePasskeyIfEnforced(store, config.Timeout),
+	)
+}
+
+func RequireLibrarianWithoutSubscription(store AccessStore, config *pkg.Config, cookieStore *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
+	return Chain(
+		RequireSession(cookieStore, AuthSession, opts),
+		TrackSession(store, config.Timeout),
+		RefreshSession(store, config.SessionRefreshInterval),
+		RestrictImpersonation(config.PlatformAdmins),
+		RequireMinimumRole(cookieStore, pkg.RoleLibrarian),
+		RequirePasskeyIfEnforced(store, config.Timeout),
+	)
+}
+
+type SignedInStore interface {
+	pkg.SessionRegistry
+	pkg.RoleGetter
+}
+
+func RequireSignedIn(store SignedInStore, config *pkg.Config, cookieStore *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
+	return Chain(
+		RequireSession(cookieStore, AuthSession, opts),
+		TrackSession(store, config.Timeout),
 		RequireUserId(cookieStore),
+		RequireVerifiedEmail(store, config),
 	)
 }
-func RequireUserInfo(cookieStore *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
+
+func RequireUserInfo(registry pkg.SessionRegistry, config *pkg.Config, cookieStore *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
 	return Chain(
 		RequireSession(cookieStore, AuthSession, opts),
+		TrackSession(registry, config.Timeout),
 		RequireUserId(cookieStore),
 	)
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -156,13 +157,11 @@ func TestAccessMiddleware(t *testing.T) {
 	opt := sessions.Options{}
 
 	readWithConfig := func(config *pkg.Config, cookie *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
-		_ = config
-		return RequireRead(cookie, opts)
+		return RequireRead(pkg.NewMultiOrgInMemoryStore(), config, cookie, opts)
 	}
 
 	adminWithoutSub := func(config *pkg.Config, cookie *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
-		_ = config
-		return RequireAdminWithoutSubscription(cookie, opts)
+		return RequireAdminWithoutSubscription(pkg.NewMultiOrgInMemoryStore(), config, cookie, opts)
 	}
 
 	writeWithStore := func(config *pkg.Config, cookie *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
@@ -175,6 +174,14 @@ func TestAccessMiddleware(t *testing.T) {
 		return RequireAdmin(store, config, cookie, opts)
 	}
 
+	librarianWithStore := func(config *pkg.Config, cookie *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
+		return RequireLibrarian(pkg.NewMultiOrgInMemoryStore(), config, cookie, opts)
+	}
+
+	librarianWithoutSub := func(config *pkg.Config, cookie *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler {
+		return RequireLibrarianWithoutSubscription(pkg.NewMultiOrgInMemoryStore(), config, cookie, opts)
+	}
+
 	for _, test := range []struct {
 		middleware func(config *pkg.Config, cookie *sessions.CookieStore, opts *sessions.Options) func(http.Handler) http.Handler
 		role       pkg.RoleKind
@@ -253,6 +260,60 @@ func TestAccessMiddleware(t *testing.T) {
 			code:       http.StatusOK,
 			desc:       "Reader admin without sub, have admin",
 		},
+		{
+			middleware: readWithConfig,
+			role:       pkg.RoleLibrarian,
+			code:       http.StatusOK,
+			desc:       "Require read, have librarian",
+		},
+		{
+			middleware: writeWithStore,
+			role:       pkg.RoleLibrarian,
+			code:       http.StatusOK,
+			desc:       "Require write, have librarian",
+		},
+		{
+			middleware: adminWithStore,
+			role:       pkg.RoleLibrarian,
+			code:       http.StatusUnauthorized,
+			desc:       "Require admin, have librarian",
+		},
+		{
+			middleware: adminWithoutSub,
+			role:       pkg.RoleLibrarian,
+			code:       http.StatusUnauthorized,
+			desc:       "Require admin without sub, have librarian",
+		},
+		{
+			middleware: librarianWithStore,
+			role:       pkg.RoleEditor,
+			code:       http.StatusUnauthorized,
+			desc:       "Require librarian, have write",
+		},
+		{
+			middleware: librarianWithStore,
+			role:       pkg.RoleLibrarian,
+			code:       http.StatusOK,
+			desc:       "Require librarian, have librarian",
+		},
+		{
+			middleware: librarianWithStore,
+			role:       pkg.RoleAdmin,
+			code:       http.StatusOK,
+			desc:       "Require librarian, have admin",
+		},
+		{
+			middleware: librarianWithoutSub,
+			role:       pkg.RoleViewer,
+			code:       http.StatusUnauthorized,
+			desc:       "Require librarian without sub, have read",
+		},
+		{
+			middleware: librarianWithoutSub,
+			role:       pkg.RoleLibrarian,
+			code:       http.StatusOK,
+			desc:       "Require librarian without sub, have librarian",
+		},
 	} {
 		t.Run(test.desc, func(t *testing.T) {
 			cookie := sessions.NewCookieStore([]byte("key"))
@@ -306,7 +367,7 @@ func TestAccessMiddlewareBadRequestOnMissingSession(t *testing.T) {
 	config := pkg.NewDefaultConfig()
 	store := pkg.NewMultiOrgInMemoryStore()
 	for i, middleware := range []func(http.Handler) http.Handler{
-		RequireRead(cookie, &opt),
+		RequireRead(store, config, cookie, &opt),
 		RequireWrite(store, config, cookie, &opt),
 		RequireAdmin(store, config, cookie, &opt),
 	} {
@@ -522,3 +583,97 @@ func TestNoErrorOnFailedSubscriptionSessionSave(t *testing.T) {
 	rec := httptest.NewRecorder()
 	trySaveSession(session, req, rec)
 }
+
+func TestRefreshSession(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	user := pkg.UserInfo{Id: "user", Roles: map[string]pkg.RoleKind{"org1": pkg.RoleViewer, "org2": pkg.RoleAdmin}}
+	testutils.AssertNil(t, store.RegisterUser(context.Background(), &user))
+
+	var refreshed *pkg.UserInfo
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refreshed = MustGetUserInfo(MustGetSession(r))
+	})
+
+	request := func(refreshedAt time.Time, role pkg.RoleKind) (*http.Request, *sessions.Session) {
+		req := withAuthSession(httptest.NewRequest("GET", "/endpoint", nil), "org1")
+		session := MustGetSession(req)
+		session.Values["userId"] = "user"
+		session.Values["role"], _ = json.Marshal(pkg.UserInfo{Id: "user", Roles: map[string]pkg.RoleKind{"org1": role}})
+		session.Values[sessionRefreshedAtKey] = refreshedAt.Unix()
+		return req, session
+	}
+
+	t.Run("stale session is re-issued with roles from store", func(t *testing.T) {
+		req, session := request(time.Now().Add(-time.Hour), pkg.RoleAdmin)
+		rec := httptest.NewRecorder()
+		RefreshSession(store, time.Minute)(handler).ServeHTTP(rec, req)
+		testutils.AssertEqual(t, refreshed.Roles["org1"], pkg.RoleViewer)
+		testutils.AssertEqual(t, refreshed.Roles["org2"], pkg.RoleAdmin)
+		testutils.AssertEqual(t, session.Values["orgId"], "org1")
+		testutils.AssertEqual(t, len(rec.Result().Cookies()), 1)
+	})
+
+	t.Run("recent session is kept", func(t *testing.T) {
+		req, _ := request(time.Now(), pkg.RoleAdmin)
+		rec := httptest.NewRecorder()
+		RefreshSession(store, time.Minute)(handler).ServeHTTP(rec, req)
+		testutils.AssertEqual(t, refreshed.Roles["org1"], pkg.RoleAdmin)
+		testutils.AssertEqual(t, len(rec.Result().Cookies()), 0)
+	})
+
+	t.Run("changed permissions version refreshes recent session", func(t *testing.T) {
+		testutils.AssertNil(t, store.RegisterRole(context.Background(), "user", "org1", pkg.RoleEditor))
+		version, err := store.PermissionsVersion(context.Background(), "user")
+		testutils.AssertNil(t, err)
+
+		req, session := request(time.Now(), pkg.RoleAdmin)
+		rec := httptest.NewRecorder()
+		RefreshSession(store, time.Minute)(handler).ServeHTTP(rec, req)
+		testutils.AssertEqual(t, refreshed.Roles["org1"], pkg.RoleEditor)
+		testutils.AssertEqual(t, session.Values[sessionPermissionsVersionKey], any(version))
+		testutils.AssertEqual(t, len(rec.Result().Cookies()), 1)
+
+		// The session now carries the latest version, so it is not refreshed again
+		req, _ = request(time.Now(), pkg.RoleAdmin)
+		MustGetSession(req).Values[sessionPermissionsVersionKey] = version
+		rec = httptest.NewRecorder()
+		RefreshSession(store, time.Minute)(handler).ServeHTTP(rec, req)
+		testutils.AssertEqual(t, refreshed.Roles["org1"], pkg.RoleAdmin)
+		testutils.AssertEqual(t, len(rec.Result().Cookies()), 0)
+	})
+
+	t.Run("refresh disabled", func(t *testing.T) {
+		req, _ := request(time.Time{}, pkg.RoleAdmin)
+		MustGetSession(req).Values[sessionPermissionsVersionKey], _ = store.PermissionsVersion(context.Background(), "user")
+		rec := httptest.NewRecorder()
+		RefreshSession(store, 0)(handler).ServeHTTP(rec, req)
+		testutils.AssertEqual(t, refreshed.Roles["org1"], pkg.RoleAdmin)
+	})
+
+	t.Run("deleted user loses roles", func(t *testing.T) {
+		req, session := request(time.Time{}, pkg.RoleAdmin)
+		rec := httptest.NewRecorder()
+		RefreshSession(pkg.NewMultiOrgInMemoryStore(), time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, req)
+		_, hasRole := session.Values["role"]
+		_, hasUser := session.Values["userId"]
+		testutils.AssertEqual(t, hasRole || hasUser, false)
+	})
+
+	t.Run("permissions version failure falls back to interval", func(t *testing.T) {
+		req, _ := request(time.Now(), pkg.RoleAdmin)
+		rec := httptest.NewRecorder()
+		failing := &pkg.MockIAMStore{ErrPermissionsVersion: errors.New("store is down")}
+		RefreshSession(failing, time.Minute)(handler).ServeHTTP(rec, req)
+		testutils.AssertEqual(t, refreshed.Roles["org1"], pkg.RoleAdmin)
+		testutils.AssertEqual(t, len(rec.Result().Cookies()), 0)
+	})
+
+	t.Run("store failure keeps session", func(t *testing.T) {
+		req, _ := request(time.Time{}, pkg.RoleAdmin)
+		rec := httptest.NewRecorder()
+		failing := &pkg.MockIAMStore{ErrGetUserInfo: errors.New("store is down")}
+		RefreshSession(failing, time.Minute)(handler).ServeHTTP(rec, req)
+		testutils.AssertEqual(t, refreshed.Roles["org1"], pkg.RoleAdmin)
+		testutils.AssertEqual(t, len(rec.Result().Cookies()), 0)
+	})
+}
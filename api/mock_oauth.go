@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+func findMockOAuthUser(users []pkg.UserInfo, id string) (pkg.UserInfo, bool) {
+	for _, user := range users {
+		if user.Id == id {
+			return user, true
+		}
+	}
+	return pkg.UserInfo{}, false
+}
+
+// isBelowBaseURL returns true when the redirect points to the application at baseURL. The scheme and host must
+// match, such that a host that only starts with the host of the application is rejected
+func isBelowBaseURL(redirect *url.URL, baseURL string) bool {
+	base, err := url.Parse(baseURL)
+	if err != nil || base.Host == "" {
+		return false
+	}
+	return redirect.Scheme == base.Scheme && redirect.Host == base.Host && strings.HasPrefix(redirect.Path, base.Path)
+}
+
+// MockOAuthAuthorizeHandler shows a page where one of the users can be picked. Picking a user redirects
+// back to the application with the id of the user as authorization code. Only redirects to the application at
+// baseURL are accepted, such that the code can not be sent to another site
+func MockOAuthAuthorizeHandler(users []pkg.UserInfo, baseURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		redirect, err := url.Parse(r.URL.Query().Get("redirect_uri"))
+		if err != nil || redirect.String() == "" {
+			http.Error(w, "Missing or invalid redirect_uri", http.StatusBadRequest)
+			return
+		}
+		if !isBelowBaseURL(redirect, baseURL) {
+			http.Error(w, "redirect_uri must point to "+baseURL, http.StatusBadRequest)
+			return
+		}
+
+		choices := make([]web.MockOAuthChoice, len(users))
+		for i, user := range users {
+			query := redirect.Query()
+			query.Set("code", user.Id)
+			query.Set("state", r.URL.Query().Get("state"))
+			link := *redirect
+			link.RawQuery = query.Encode()
+			choices[i] = web.MockOAuthChoice{Name: user.Name, Email: user.Email, Link: link.String()}
+		}
+		web.MockOAuthPage(w, choices)
+	}
+}
+
+// MockOAuthTokenHandler exchanges an authorization code for an access token. The token is the id of the user
+func MockOAuthTokenHandler(users []pkg.UserInfo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code := r.FormValue("code")
+		w.Header().Set("Content-Type", "application/json")
+		if _, ok := findMockOAuthUser(users, code); !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": code,
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	}
+}
+
+func MockOAuthUserInfoHandler(users []pkg.UserInfo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		user, ok := findMockOAuthUser(users, token)
+		if !ok {
+			http.Error(w, "Invalid access token", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(user)
+	}
+}
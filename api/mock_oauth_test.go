@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/gorilla/sessions"
+)
+
+func TestMockOAuthLoginFlow(t *testing.T) {
+	server := httptest.NewUnstartedServer(nil)
+	config := pkg.NewDefaultConfig()
+	config.MockOAuth = true
+	config.BaseURL = "http://" + server.Listener.Addr().String()
+	config.GoogleAuthRedirectURL = config.BaseURL + RouteAuthCallback
+
+	server.Config.Handler = Setup(pkg.NewDemoStore(), config, sessions.NewCookieStore([]byte("secret")))
+	server.Start()
+	defer server.Close()
+
+	jar, err := cookiejar.New(nil)
+	testutils.AssertNil(t, err)
+	client := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Path == "/organizations" {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+
+	resp, err := client.Get(server.URL + RouteLoginGoogle)
+	testutils.AssertNil(t, err)
+	defer resp.Body.Close()
+	testutils.AssertEqual(t, resp.Request.URL.Path, pkg.MockOAuthAuthorizePath)
+
+	var page bytes.Buffer
+	_, err = page.ReadFrom(resp.Body)
+	testutils.AssertNil(t, err)
+	testutils.AssertContains(t, page.String(), "susan@example.com")
+
+	links := regexp.MustCompile(`href="([^"]+code=[^"]+)"`).FindAllStringSubmatch(page.String(), -1)
+	testutils.AssertEqual(t, len(links), len(pkg.MockOAuthUsers()))
+
+	callbackResp, err := client.Get(html.UnescapeString(links[0][1]))
+	testutils.AssertNil(t, err)
+	defer callbackResp.Body.Close()
+	testutils.AssertEqual(t, callbackResp.StatusCode, http.StatusSeeOther)
+	testutils.AssertEqual(t, callbackResp.Header.Get("Location"), "/organizations")
+}
+
+func TestMockOAuthAuthorizeMissingRedirect(t *testing.T) {
+	rec := httptest.NewRecorder()
+	MockOAuthAuthorizeHandler(pkg.MockOAuthUsers(), "http://localhost:8080").ServeHTTP(rec, httptest.NewRequest("GET", "/mock-oauth/authorize", nil))
+	testutils.AssertEqual(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestMockOAuthAuthorizeRedirect(t *testing.T) {
+	handler := MockOAuthAuthorizeHandler(pkg.MockOAuthUsers(), "http://localhost:8080")
+	for _, test := range []struct {
+		redirect string
+		want     int
+	}{
+		{redirect: "http://localhost:8080/auth/callback", want: http.StatusOK},
+		{redirect: "https://evil.example.com/auth/callback", want: http.StatusBadRequest},
+		{redirect: "http://localhost:8080.evil.example.com/auth/callback", want: http.StatusBadRequest},
+		{redirect: "https://localhost:8080/auth/callback", want: http.StatusBadRequest},
+		{redirect: "/auth/callback", want: http.StatusBadRequest},
+	} {
+		t.Run(test.redirect, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			target := "/mock-oauth/authorize?redirect_uri=" + url.QueryEscape(test.redirect)
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+			testutils.AssertEqual(t, rec.Code, test.want)
+		})
+	}
+}
+
+func TestMockOAuthOnlyForInMemoryStores(t *testing.T) {
+	config := pkg.NewDefaultConfig()
+	config.MockOAuth = true
+	mux := Setup(&pkg.GoogleStore{}, config, sessions.NewCookieStore([]byte("secret")))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", pkg.MockOAuthTokenPath, strings.NewReader("code="+pkg.MockOAuthUsers()[0].Id))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	mux.ServeHTTP(rec, req)
+	testutils.AssertNotContains(t, rec.Body.String(), "access_token")
+}
+
+func TestMockOAuthTokenHandler(t *testing.T) {
+	users := pkg.MockOAuthUsers()
+	handler := MockOAuthTokenHandler(users)
+
+	for _, test := range []struct {
+		code string
+		want int
+	}{
+		{code: users[0].Id, want: http.StatusOK},
+		{code: "unknown", want: http.StatusBadRequest},
+	} {
+		form := url.Values{"code": {test.code}}
+		req := httptest.NewRequest("POST", "/mock-oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		testutils.AssertEqual(t, rec.Code, test.want)
+	}
+}
+
+func TestMockOAuthUserInfoHandler(t *testing.T) {
+	users := pkg.MockOAuthUsers()
+	handler := MockOAuthUserInfoHandler(users)
+
+	req := httptest.NewRequest("GET", "/mock-oauth/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+users[1].Id)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+
+	var user pkg.UserInfo
+	testutils.AssertNil(t, json.NewDecoder(rec.Body).Decode(&user))
+	testutils.AssertEqual(t, user.Email, users[1].Email)
+
+	req.Header.Set("Authorization", "Bearer unknown")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	testutils.AssertEqual(t, rec.Code, http.StatusUnauthorized)
+}
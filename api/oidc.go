@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"golang.org/x/oauth2"
+)
+
+// HandleOIDCLogin redirects to the sign in page of the OpenID Connect provider
+func HandleOIDCLogin(provider *pkg.OIDCProvider, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		oauthConfig, _, err := provider.OAuthConfig(ctx)
+		if err != nil {
+			http.Error(w, "Could not reach the sign in provider", http.StatusBadGateway)
+			slog.ErrorContext(ctx, "Could not discover OpenID Connect provider", "error", err, "issuer", provider.Config.Issuer)
+			return
+		}
+		handleOAuthLogin(oauthConfig)(w, r)
+	}
+}
+
+// HandleOIDCCallback signs in users of the OpenID Connect provider. The profile is read from the userinfo
+// endpoint of the provider
+func HandleOIDCCallback(roleStore LoginStore, provider *pkg.OIDCProvider, timeout time.Duration, signSecret string, transport http.RoundTripper) http.HandlerFunc {
+	decode := func(body io.Reader, _ *oauth2.Token) (pkg.UserInfo, error) {
+		var claims pkg.OIDCUserInfo
+		if err := json.NewDecoder(body).Decode(&claims); err != nil {
+			return pkg.UserInfo{}, err
+		}
+		if claims.Subject == "" {
+			return pkg.UserInfo{}, errors.New("userinfo has no subject")
+		}
+		return claims.UserInfo(provider.Config.Issuer), nil
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		oauthConfig, userInfoURL, err := provider.OAuthConfig(ctx)
+		if err != nil {
+			http.Error(w, "Could not reach the sign in provider", http.StatusBadGateway)
+			slog.ErrorContext(ctx, "Could not discover OpenID Connect provider", "error", err, "issuer", provider.Config.Issuer)
+			return
+		}
+		handleOAuthCallback(roleStore, oauthConfig, userInfoURL, timeout, signSecret, transport, decode)(w, r)
+	}
+}
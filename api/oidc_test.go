@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/gorilla/sessions"
+)
+
+// newOIDCServer acts as an OpenID Connect provider returning the given userinfo
+func newOIDCServer(t *testing.T, userInfo string) *httptest.Server {
+	mux := http.NewServeMux()
+	var server *httptest.Server
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(pkg.OIDCDiscovery{
+			Issuer:                server.URL,
+			AuthorizationEndpoint: server.URL + "/authorize",
+			TokenEndpoint:         server.URL + "/token",
+			UserinfoEndpoint:      server.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "test-access-token", "expires_in": 3600, "token_type": "Bearer"}`))
+	})
+	mux.HandleFunc("GET /userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-access-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(userInfo))
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHandleOIDCLogin(t *testing.T) {
+	server := newOIDCServer(t, "{}")
+	provider := pkg.NewOIDCProvider(pkg.OIDCConfig{Issuer: server.URL, ClientId: "caesura"}, nil)
+	cookie := sessions.NewCookieStore([]byte("some-random-key"))
+	handler := RequireSession(cookie, AuthSession, &sessions.Options{})(HandleOIDCLogin(provider, time.Second))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", RouteLoginOIDC, nil))
+	testutils.AssertEqual(t, recorder.Code, http.StatusTemporaryRedirect)
+	testutils.AssertContains(t, recorder.Header().Get("Location"), server.URL+"/authorize", "client_id=caesura", "scope=openid+email+profile")
+}
+
+func TestHandleOIDCLoginUnavailableIssuer(t *testing.T) {
+	server := newOIDCServer(t, "{}")
+	server.Close()
+	provider := pkg.NewOIDCProvider(pkg.OIDCConfig{Issuer: server.URL, ClientId: "caesura"}, nil)
+	cookie := sessions.NewCookieStore([]byte("some-random-key"))
+	handler := RequireSession(cookie, AuthSession, &sessions.Options{})(HandleOIDCLogin(provider, time.Second))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", RouteLoginOIDC, nil))
+	testutils.AssertEqual(t, recorder.Code, http.StatusBadGateway)
+}
+
+func TestHandleOIDCCallback(t *testing.T) {
+	server := newOIDCServer(t, `{"sub": "5f0c1d2e-8a3b-4c6d-9e7f-0a1b2c3d4e5f", "email": "Kari@School.no", "email_verified": true, "name": "Kari Nordmann"}`)
+	provider := pkg.NewOIDCProvider(pkg.OIDCConfig{Issuer: server.URL, ClientId: "caesura"}, nil)
+	store := pkg.NewMultiOrgInMemoryStore()
+	handler := HandleOIDCCallback(store, provider, time.Second, "signKey", nil)
+
+	req := prepareGoogleCallbackRequest(sessions.NewCookieStore([]byte("some-random-key")))
+	recorder := httptest.NewRecorder()
+	handler(recorder, req)
+	testutils.AssertEqual(t, recorder.Code, http.StatusSeeOther)
+
+	userId := pkg.ExternalUserId(server.URL, "5f0c1d2e-8a3b-4c6d-9e7f-0a1b2c3d4e5f")
+	testutils.AssertEqual(t, MustGetSession(req).Values["userId"], any(userId))
+	user, err := store.GetUserInfo(context.Background(), userId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, user.Email, "kari@school.no")
+	testutils.AssertEqual(t, user.Name, "Kari Nordmann")
+}
+
+func TestHandleOIDCCallbackWithoutSubject(t *testing.T) {
+	server := newOIDCServer(t, `{"email": "kari@school.no"}`)
+	provider := pkg.NewOIDCProvider(pkg.OIDCConfig{Issuer: server.URL, ClientId: "caesura"}, nil)
+	handler := HandleOIDCCallback(pkg.NewMultiOrgInMemoryStore(), provider, time.Second, "signKey", nil)
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, prepareGoogleCallbackRequest(sessions.NewCookieStore([]byte("some-random-key"))))
+	testutils.AssertEqual(t, recorder.Code, http.StatusInternalServerError)
+	testutils.AssertContains(t, recorder.Body.String(), "no subject")
+}
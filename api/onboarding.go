@@ -0,0 +1,202 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+const instrumentFamiliesKey ctxKey = "instrumentFamilies"
+
+// WithInstrumentFamilies limits the instruments suggested in the active organization to the families chosen in
+// the onboarding wizard. Organizations that have not chosen any family get all instruments
+func WithInstrumentFamilies(store pkg.OnboardingStore, timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			orgId, _ := MustGetSession(r).Values["orgId"].(string)
+			if orgId == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			onboarding, err := store.Onboarding(ctx, orgId)
+			cancel()
+			if err != nil {
+				if !errors.Is(err, pkg.ErrOnboardingNotFound) {
+					slog.WarnContext(r.Context(), "Could not fetch onboarding. Suggesting all instruments", "error", err, "orgId", orgId)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), instrumentFamiliesKey, onboarding.Instruments)))
+		})
+	}
+}
+
+// InstrumentFamilies returns the instrument families played in the active organization
+func InstrumentFamilies(r *http.Request) []string {
+	families, _ := r.Context().Value(instrumentFamiliesKey).([]string)
+	return families
+}
+
+type OnboardingWizardStore interface {
+	pkg.OnboardingStore
+	pkg.InviteLinkStore
+}
+
+// writeOnboarding renders the current step of the wizard. Nothing is written when all steps are completed
+func writeOnboarding(w http.ResponseWriter, r *http.Request, store pkg.InviteLinkStore, onboarding *pkg.Onboarding, orgId string, config *pkg.Config) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	step, ok := onboarding.Current()
+	if !ok {
+		return
+	}
+
+	data := web.OnboardingData{
+		Step:       step,
+		StepNumber: slices.Index(pkg.OnboardingSteps, step) + 1,
+		NumSteps:   len(pkg.OnboardingSteps),
+	}
+	instrumentation := pkg.DefaultInstrumentation()
+	for _, family := range instrumentation.FamilyIds() {
+		data.Families = append(data.Families, web.OnboardingFamily{Name: family, Chosen: slices.Contains(onboarding.Instruments, family)})
+	}
+	for _, preset := range instrumentation.Presets {
+		data.Presets = append(data.Presets, preset.Id)
+	}
+	if step == pkg.OnboardingStepInvite {
+		ctx, cancel := context.WithTimeout(r.Context(), config.Timeout)
+		defer cancel()
+
+		userId, _ := r.Context().Value(pkg.UserIdKey).(string)
+		link, err := activeInviteLinkURL(ctx, store, config, orgId, userId)
+		if err != nil {
+			http.Error(w, "Failed to create invite link", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Failed to create invite link", "error", err)
+			return
+		}
+		data.InviteLink = link
+	}
+	web.Onboarding(w, pkg.LanguageFromReq(r), data)
+}
+
+// OnboardingHandler shows the onboarding wizard to the admins of the active organization until all steps are
+// completed or the wizard is dismissed. Others, and organizations created before the wizard existed, get an
+// empty response such that the wizard can be loaded on any page
+func OnboardingHandler(store OnboardingWizardStore, config *pkg.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgId, user, ok := sessionMember(r)
+		if !ok || !user.Roles[orgId].AtLeast(pkg.RoleAdmin) {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), config.Timeout)
+		defer cancel()
+
+		onboarding, err := store.Onboarding(ctx, orgId)
+		if errors.Is(err, pkg.ErrOnboardingNotFound) {
+			return
+		} else if err != nil {
+			http.Error(w, "Could not fetch onboarding", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch onboarding", "error", err, "orgId", orgId)
+			return
+		}
+		writeOnboarding(w, r, store, onboarding, orgId, config)
+	}
+}
+
+// CompleteOnboardingStepHandler marks a step of the wizard as completed and renders the next step. The
+// instrument step takes the chosen families in the form field "family", or the id of an instrumentation preset in
+// the form field "preset" which chooses the families of the preset instead
+func CompleteOnboardingStepHandler(store OnboardingWizardStore, config *pkg.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 4096)
+		code, err := parseForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		step := pkg.OnboardingStep(r.PathValue("step"))
+		if err := pkg.ValidateOnboardingStep(step); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		families := r.Form["family"]
+		if presetId := r.FormValue("preset"); presetId != "" {
+			preset, ok := pkg.DefaultInstrumentation().Preset(presetId)
+			if !ok {
+				http.Error(w, fmt.Sprintf("Unknown instrumentation preset %q", presetId), http.StatusBadRequest)
+				return
+			}
+			families = slices.Clone(preset.Families)
+		}
+		for _, family := range families {
+			if !pkg.DefaultInstrumentation().HasFamily(family) {
+				http.Error(w, fmt.Sprintf("Unknown instrument family %q", family), http.StatusBadRequest)
+				return
+			}
+		}
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		ctx, cancel := context.WithTimeout(r.Context(), config.Timeout)
+		defer cancel()
+
+		onboarding, err := store.Onboarding(ctx, orgId)
+		if err != nil {
+			http.Error(w, "Could not fetch onboarding", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch onboarding", "error", err, "orgId", orgId)
+			return
+		}
+
+		if step == pkg.OnboardingStepInstruments {
+			onboarding.Instruments = families
+		}
+		pkg.PanicOnErr(onboarding.Complete(step, time.Now()))
+		if err := store.SaveOnboarding(ctx, orgId, onboarding); err != nil {
+			http.Error(w, "Could not save onboarding", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not save onboarding", "error", err, "orgId", orgId)
+			return
+		}
+
+		if _, ok := onboarding.Current(); !ok {
+			HxFlash(w, r, FlashSuccess, "flash.onboarding-finished", nil)
+		}
+		writeOnboarding(w, r, store, onboarding, orgId, config)
+	}
+}
+
+// DismissOnboardingHandler hides the wizard for all admins of the organization
+func DismissOnboardingHandler(store pkg.OnboardingStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgId := MustGetOrgId(MustGetSession(r))
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		onboarding, err := store.Onboarding(ctx, orgId)
+		if err != nil {
+			http.Error(w, "Could not fetch onboarding", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch onboarding", "error", err, "orgId", orgId)
+			return
+		}
+
+		onboarding.Dismissed = true
+		onboarding.UpdatedAt = time.Now()
+		if err := store.SaveOnboarding(ctx, orgId, onboarding); err != nil {
+			http.Error(w, "Could not save onboarding", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not save onboarding", "error", err, "orgId", orgId)
+			return
+		}
+		HxTrigger(w, EventOnboardingUpdated, nil)
+	}
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func onboardingTestStore(t *testing.T) *pkg.MultiOrgInMemoryStore {
+	store := pkg.NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.SaveOnboarding(context.Background(), "org1", pkg.NewOnboarding()))
+	return store
+}
+
+func completeOnboardingStep(store OnboardingWizardStore, step string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/onboarding/steps/"+step, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = withAuthSession(req, "org1")
+	req.SetPathValue("step", step)
+	recorder := httptest.NewRecorder()
+	CompleteOnboardingStepHandler(store, pkg.NewDefaultConfig())(recorder, req)
+	return recorder
+}
+
+func TestOnboardingHandler(t *testing.T) {
+	store := onboardingTestStore(t)
+	handler := OnboardingHandler(store, pkg.NewDefaultConfig())
+
+	t.Run("admin", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, withAuthSession(httptest.NewRequest("GET", RouteOnboarding, nil), "org1"))
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertContains(t, recorder.Body.String(), "Step 1 / 4", `name="family" value="brass"`, `value="brass-band"`, `hx-post="/onboarding/steps/instruments"`)
+	})
+
+	t.Run("viewer", func(t *testing.T) {
+		req := withAuthSession(httptest.NewRequest("GET", RouteOnboarding, nil), "org1")
+		MustGetSession(req).Values["role"], _ = json.Marshal(pkg.UserInfo{Id: "1111", Roles: map[string]pkg.RoleKind{"org1": pkg.RoleViewer}})
+		recorder := httptest.NewRecorder()
+		handler(recorder, req)
+		testutils.AssertEqual(t, recorder.Body.Len(), 0)
+	})
+
+	t.Run("organization created before the wizard", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, withAuthSession(httptest.NewRequest("GET", RouteOnboarding, nil), "org2"))
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertEqual(t, recorder.Body.Len(), 0)
+	})
+}
+
+func TestCompleteOnboardingStepHandler(t *testing.T) {
+	store := onboardingTestStore(t)
+
+	for _, test := range []struct {
+		desc string
+		step string
+		form url.Values
+	}{
+		{"unknown step", "payment", url.Values{}},
+		{"unknown family", "instruments", url.Values{"family": {"brass", "kazoo"}}},
+		{"unknown preset", "instruments", url.Values{"preset": {"kazoo-band"}}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			testutils.AssertEqual(t, completeOnboardingStep(store, test.step, test.form).Code, http.StatusBadRequest)
+		})
+	}
+
+	recorder := completeOnboardingStep(store, "instruments", url.Values{"family": {"brass", "percussion"}})
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+	testutils.AssertContains(t, recorder.Body.String(), "Step 2 / 4", "/login?invite-token=", `hx-post="/onboarding/steps/invite"`)
+
+	onboarding, err := store.Onboarding(context.Background(), "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(onboarding.Instruments, ","), "brass,percussion")
+
+	// The wizard resumes at the first step that is not completed
+	testutils.AssertContains(t, completeOnboardingStep(store, "upload", nil).Body.String(), "Step 2 / 4")
+	testutils.AssertContains(t, completeOnboardingStep(store, "invite", nil).Body.String(), "Step 4 / 4", `href="/people"`, "Finish")
+
+	recorder = completeOnboardingStep(store, "email", nil)
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+	testutils.AssertEqual(t, recorder.Body.Len(), 0)
+	testutils.AssertContains(t, recorder.Header().Get("HX-Trigger"), string(EventFlash))
+
+	recorder = completeOnboardingStep(pkg.NewMultiOrgInMemoryStore(), "invite", nil)
+	testutils.AssertEqual(t, recorder.Code, http.StatusNotFound)
+}
+
+func TestCompleteOnboardingStepHandlerPreset(t *testing.T) {
+	store := onboardingTestStore(t)
+	recorder := completeOnboardingStep(store, "instruments", url.Values{"preset": {"brass-band"}, "family": {"choir"}})
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+
+	onboarding, err := store.Onboarding(context.Background(), "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(onboarding.Instruments, ","), "brass,percussion")
+}
+
+func TestDismissOnboardingHandler(t *testing.T) {
+	store := onboardingTestStore(t)
+	recorder := httptest.NewRecorder()
+	DismissOnboardingHandler(store, time.Second)(recorder, withAuthSession(httptest.NewRequest("DELETE", RouteOnboarding, nil), "org1"))
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+	testutils.AssertContains(t, recorder.Header().Get("HX-Trigger"), string(EventOnboardingUpdated))
+
+	onboarding, err := store.Onboarding(context.Background(), "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, onboarding.Dismissed, true)
+
+	recorder = httptest.NewRecorder()
+	OnboardingHandler(store, pkg.NewDefaultConfig())(recorder, withAuthSession(httptest.NewRequest("GET", RouteOnboarding, nil), "org1"))
+	testutils.AssertEqual(t, recorder.Body.Len(), 0)
+}
+
+func TestWithInstrumentFamilies(t *testing.T) {
+	store := onboardingTestStore(t)
+	onboarding := pkg.NewOnboarding()
+	onboarding.Instruments = []string{"brass"}
+	testutils.AssertNil(t, store.SaveOnboarding(context.Background(), "org1", onboarding))
+	handler := WithInstrumentFamilies(store, time.Second)(http.HandlerFunc(InstrumentSearchHandler))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, withAuthSession(httptest.NewRequest("GET", "/instruments?format=options", nil), "org1"))
+	testutils.AssertContains(t, recorder.Body.String(), "Trumpet", "Conductor")
+	testutils.AssertNotContains(t, recorder.Body.String(), "Flute", "Soprano")
+
+	// Organizations without a choice get all instruments
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, withAuthSession(httptest.NewRequest("GET", "/instruments?format=options", nil), "org2"))
+	testutils.AssertContains(t, recorder.Body.String(), "Trumpet", "Flute", "Soprano")
+
+	failing := WithInstrumentFamilies(&pkg.MockIAMStore{ErrOnboarding: errors.New("unavailable")}, time.Second)(http.HandlerFunc(InstrumentSearchHandler))
+	recorder = httptest.NewRecorder()
+	failing.ServeHTTP(recorder, withAuthSession(httptest.NewRequest("GET", "/instruments?format=options", nil), "org1"))
+	testutils.AssertContains(t, recorder.Body.String(), "Flute")
+}
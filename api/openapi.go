@@ -0,0 +1,370 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+// routeMux records the patterns registered by Setup, such that the OpenAPI document lists exactly the routes
+// that are served
+type routeMux struct {
+	*http.ServeMux
+	patterns []string
+
+	// Wraps the routes registered after it is set. Nil leaves them unchanged
+	middleware func(http.Handler) http.Handler
+}
+
+func (m *routeMux) Handle(pattern string, handler http.Handler) {
+	m.patterns = append(m.patterns, pattern)
+	if m.middleware != nil {
+		handler = m.middleware(handler)
+	}
+	m.ServeMux.Handle(pattern, handler)
+}
+
+func (m *routeMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.Handle(pattern, http.HandlerFunc(handler))
+}
+
+func (m *routeMux) Patterns() []string {
+	return slices.Clone(m.patterns)
+}
+
+type OpenAPIDocument struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       OpenAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                      `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type OpenAPIOperation struct {
+	OperationId string                     `json:"operationId"`
+	Summary     string                     `json:"summary,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+	Security    []map[string][]string      `json:"security,omitempty"`
+}
+
+type OpenAPIParameter struct {
+	Name        string      `json:"name"`
+	In          string      `json:"in"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required"`
+	Schema      *JSONSchema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Content map[string]OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *JSONSchema `json:"schema"`
+}
+
+type OpenAPIComponents struct {
+	Schemas         map[string]*JSONSchema           `json:"schemas"`
+	SecuritySchemes map[string]OpenAPISecurityScheme `json:"securitySchemes"`
+}
+
+type OpenAPISecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+type JSONSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Nullable             bool                   `json:"nullable,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+}
+
+// jsonOperation describes a route that answers with JSON. Routes that are not listed are documented from their
+// pattern only, since they return HTML fragments for htmx
+type jsonOperation struct {
+	summary  string
+	query    map[string]string
+	form     map[string]string
+	response any
+}
+
+var jsonOperations = map[string]jsonOperation{
+	"GET " + RouteResourcesSuggest: {
+		summary:  "Suggest resources matching a query. Requires the header Accept: application/json",
+		query:    map[string]string{"q": "Text to match", "limit": "Maximum number of suggestions"},
+		response: []pkg.Suggestion{},
+	},
+	"GET " + RouteResourcesIdLink: {
+		summary:  "Create a link to a part that works without an account",
+		query:    map[string]string{"file": "Name of the part"},
+		response: SharedLinkResponse{},
+	},
+	"POST " + RouteResourcesPartsArchives: {
+		summary:  "Start building a zip archive with the parts of the resources",
+		form:     map[string]string{"resourceId": "Id of a resource. May be repeated"},
+		response: PartsArchiveResponse{},
+	},
+	"GET " + RouteResourcesPartsArchivesId: {
+		summary:  "Progress of a parts archive",
+		response: PartsArchiveResponse{},
+	},
+	"POST " + RouteSubmitProgress: {
+		summary:  "Create an upload id for following the progress of a submit as server-sent events",
+		response: SubmitProgressResponse{},
+	},
+	"GET " + RouteResourcesPartNames: {
+		summary:  "Part names that are inconsistent with the instruments of the organization. Requires the header Accept: application/json",
+		response: pkg.PartNameReport{},
+	},
+	"GET " + RouteApiResourcesIdManifest: {
+		summary:  "Files of a resource with sizes and checksums",
+		response: pkg.ResourceManifest{},
+	},
+	"GET " + RouteApiWebDAVToken: {
+		summary:  "Issue a token for mounting the library with WebDAV",
+		response: WebDAVTokenResponse{},
+	},
+	"POST " + RouteApiToken: {
+		summary:  "Issue a short lived access token",
+		response: AccessTokenResponse{},
+	},
+	"GET " + RouteApiResources: {
+		summary:  "List resources whose title, composer or arranger start with q",
+		query:    map[string]string{"q": "Prefix to match"},
+		response: pkg.RestResourceList{},
+	},
+	"GET " + RouteApiResourcesId: {
+		summary:  "Get a resource",
+		response: pkg.RestResource{},
+	},
+	"GET " + RouteApiProjects: {
+		summary:  "List projects whose name starts with q",
+		query:    map[string]string{"q": "Prefix to match"},
+		response: pkg.RestProjectList{},
+	},
+	"GET " + RouteApiProjectsId: {
+		summary:  "Get a project with its resources",
+		response: pkg.RestProject{},
+	},
+	"GET " + RouteApiUsers: {
+		summary:  "List the members of the organization. Members that are not admins only get themselves",
+		response: pkg.RestUserList{},
+	},
+	"GET " + RouteApiOrganizations: {
+		summary:  "List the organizations the request has access to",
+		response: pkg.RestOrganizationList{},
+	},
+}
+
+// splitPattern returns the method and path of a mux pattern. Patterns without a method match every method
+func splitPattern(pattern string) (string, string) {
+	method, path, found := strings.Cut(pattern, " ")
+	if !found {
+		return "", pattern
+	}
+	return method, strings.TrimSpace(path)
+}
+
+// openAPIPath converts wildcards like {path...} and {$} of the mux to OpenAPI path templates, and returns the
+// names of the path parameters
+func openAPIPath(path string) (string, []string) {
+	path = strings.ReplaceAll(path, "{$}", "")
+	var params []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			name := strings.TrimSuffix(strings.Trim(segment, "{}"), "...")
+			segments[i] = "{" + name + "}"
+			params = append(params, name)
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func operationId(method, path string) string {
+	replacer := strings.NewReplacer("/", "_", "{", "", "}", "", "-", "_", ".", "_")
+	return strings.ToLower(method) + strings.TrimSuffix(replacer.Replace(path), "_")
+}
+
+var openAPITimeType = reflect.TypeOf(time.Time{})
+
+// schema returns the JSON schema of t. Structs are added to the components and referred to by name
+func (c *OpenAPIComponents) schema(t reflect.Type) *JSONSchema {
+	switch {
+	case t == openAPITimeType:
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Pointer:
+		schema := c.schema(t.Elem())
+		schema.Nullable = true
+		return schema
+	case t.Kind() == reflect.Struct:
+		if _, ok := c.Schemas[t.Name()]; !ok {
+			// Reserve the name first, such that types referring to themselves terminate
+			c.Schemas[t.Name()] = nil
+			c.Schemas[t.Name()] = c.structSchema(t)
+		}
+		return &JSONSchema{Ref: "#/components/schemas/" + t.Name()}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return &JSONSchema{Type: "array", Items: c.schema(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: c.schema(t.Elem())}
+	case t.Kind() == reflect.String:
+		return &JSONSchema{Type: "string"}
+	case t.Kind() == reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	}
+	return &JSONSchema{}
+}
+
+func (c *OpenAPIComponents) structSchema(t reflect.Type) *JSONSchema {
+	schema := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = c.schema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+func stringParameters(in string, descriptions map[string]string) []OpenAPIParameter {
+	var params []OpenAPIParameter
+	for _, name := range slices.Sorted(maps.Keys(descriptions)) {
+		params = append(params, OpenAPIParameter{Name: name, In: in, Description: descriptions[name], Schema: &JSONSchema{Type: "string"}})
+	}
+	return params
+}
+
+// NewOpenAPIDocument documents the routes of the patterns. Routes answering with JSON get schemas of their
+// responses, and can be called with a bearer token as well as the session cookie
+func NewOpenAPIDocument(patterns []string) *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: OpenAPIInfo{
+			Title:       "Caesura",
+			Version:     "1",
+			Description: "Most routes return HTML fragments for htmx. Routes under /api/v1 answer with JSON in schemas that stay stable within the version",
+		},
+		Paths: make(map[string]map[string]OpenAPIOperation),
+		Components: OpenAPIComponents{
+			Schemas: make(map[string]*JSONSchema),
+			SecuritySchemes: map[string]OpenAPISecurityScheme{
+				"bearer":  {Type: "http", Scheme: "bearer"},
+				"session": {Type: "apiKey", In: "cookie", Name: AuthSession},
+			},
+		},
+	}
+
+	for _, pattern := range patterns {
+		method, rawPath := splitPattern(pattern)
+		path, pathParams := openAPIPath(rawPath)
+		summary := ""
+		if method == "" {
+			method = http.MethodGet
+			summary = "Matches every method"
+		}
+
+		operation := OpenAPIOperation{
+			OperationId: operationId(method, path),
+			Summary:     summary,
+			Responses:   map[string]OpenAPIResponse{"200": {Description: "OK"}},
+		}
+		if tag, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/"); tag != "" {
+			operation.Tags = []string{tag}
+		}
+		for _, name := range pathParams {
+			operation.Parameters = append(operation.Parameters, OpenAPIParameter{Name: name, In: "path", Required: true, Schema: &JSONSchema{Type: "string"}})
+		}
+
+		if jsonOp, ok := jsonOperations[pattern]; ok {
+			operation.Summary = jsonOp.summary
+			operation.Parameters = append(operation.Parameters, stringParameters("query", jsonOp.query)...)
+			if len(jsonOp.form) > 0 {
+				form := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema)}
+				for name := range jsonOp.form {
+					form.Properties[name] = &JSONSchema{Type: "string"}
+				}
+				operation.RequestBody = &OpenAPIRequestBody{Content: map[string]OpenAPIMediaType{
+					"application/x-www-form-urlencoded": {Schema: form},
+				}}
+			}
+			operation.Responses["200"] = OpenAPIResponse{
+				Description: "OK",
+				Content: map[string]OpenAPIMediaType{
+					"application/json": {Schema: doc.Components.schema(reflect.TypeOf(jsonOp.response))},
+				},
+			}
+			operation.Security = []map[string][]string{{"bearer": {}}, {"session": {}}}
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]OpenAPIOperation)
+		}
+		doc.Paths[path][strings.ToLower(method)] = operation
+	}
+	return doc
+}
+
+// OpenAPIHandler serves the OpenAPI document of the patterns. The patterns are read on the first request, such
+// that routes registered after the handler are included
+func OpenAPIHandler(patterns func() []string) http.HandlerFunc {
+	document := sync.OnceValues(func() ([]byte, error) {
+		return json.Marshal(NewOpenAPIDocument(patterns()))
+	})
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := document()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to marshal OpenAPI document", "error", err)
+			http.Error(w, "Failed to create OpenAPI document", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}
+}
+
+// ApiDocsHandler serves Swagger UI for the OpenAPI document
+func ApiDocsHandler(w http.ResponseWriter, r *http.Request) {
+	web.ApiDocsPage(w, RouteApiOpenAPI)
+}
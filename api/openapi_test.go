@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/gorilla/sessions"
+)
+
+// setupPatterns returns the routes of the document served by Setup as patterns with a method
+func setupPatterns(t *testing.T) ([]string, *OpenAPIDocument) {
+	config := pkg.NewDefaultConfig()
+	mux := Setup(pkg.NewDemoStore(), config, sessions.NewCookieStore([]byte("secret")))
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest("GET", RouteApiOpenAPI, nil))
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+	testutils.AssertEqual(t, recorder.Header().Get("Content-Type"), "application/json")
+
+	var doc OpenAPIDocument
+	testutils.AssertNil(t, json.NewDecoder(recorder.Body).Decode(&doc))
+
+	var patterns []string
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			patterns = append(patterns, strings.ToUpper(method)+" "+path)
+		}
+	}
+	return patterns, &doc
+}
+
+func TestOpenAPIDocumentListsServedRoutes(t *testing.T) {
+	_, doc := setupPatterns(t)
+	testutils.AssertEqual(t, doc.OpenAPI, "3.0.3")
+
+	for _, path := range []string{RouteApiOpenAPI, RouteApiDocs, RouteResourcesIdFilesName, RouteScimUsersId, RouteApiResources} {
+		if _, ok := doc.Paths[path]; !ok {
+			t.Errorf("%s is not documented", path)
+		}
+	}
+
+	rename := doc.Paths[RouteResourcesIdFilesName]["patch"]
+	testutils.AssertEqual(t, len(rename.Parameters), 2)
+	testutils.AssertEqual(t, rename.Parameters[1].Name, "name")
+	testutils.AssertEqual(t, rename.Tags[0], "resources")
+}
+
+func TestJSONOperationsAreServed(t *testing.T) {
+	_, doc := setupPatterns(t)
+	for pattern := range jsonOperations {
+		method, path := splitPattern(pattern)
+		operation, ok := doc.Paths[path][strings.ToLower(method)]
+		if !ok {
+			t.Errorf("%s is documented, but not served", pattern)
+			continue
+		}
+		if operation.Responses["200"].Content["application/json"].Schema == nil {
+			t.Errorf("%s has no response schema", pattern)
+		}
+	}
+}
+
+func TestVersionedRoutesHaveSchemas(t *testing.T) {
+	patterns, doc := setupPatterns(t)
+	for _, pattern := range patterns {
+		if _, path := splitPattern(pattern); strings.HasPrefix(path, "/api/v1/") {
+			if _, ok := jsonOperations[pattern]; !ok {
+				t.Errorf("%s has no response schema. Add it to jsonOperations", pattern)
+			}
+		}
+	}
+
+	resource := doc.Components.Schemas["RestResource"]
+	testutils.AssertEqual(t, resource.Properties["submitted_at"].Format, "date-time")
+	testutils.AssertEqual(t, resource.Properties["duration_seconds"].Type, "integer")
+	testutils.AssertEqual(t, doc.Components.Schemas["RestProject"].Properties["resources"].Items.Ref, "#/components/schemas/RestResource")
+	testutils.AssertEqual(t, doc.Paths[RouteApiResources]["get"].Parameters[0].Name, "q")
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	for _, test := range []struct {
+		pattern, path string
+		params        int
+	}{
+		{"/resources/{id}/files/{name}", "/resources/{id}/files/{name}", 2},
+		{"/files/{path...}", "/files/{path}", 1},
+		{"/{$}", "/", 0},
+		{"/webdav/", "/webdav/", 0},
+	} {
+		path, params := openAPIPath(test.pattern)
+		testutils.AssertEqual(t, path, test.path)
+		testutils.AssertEqual(t, len(params), test.params)
+	}
+}
+
+func TestApiDocsHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	ApiDocsHandler(recorder, httptest.NewRequest("GET", RouteApiDocs, nil))
+	testutils.AssertContains(t, recorder.Body.String(), RouteApiOpenAPI, "swagger-ui")
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+// EditPagesHandler rotates and reorders the pages of a part. The body is a JSON encoded pkg.PageEdit, and the
+// parts before the edit are kept as a version of the resource
+func EditPagesHandler(store pkg.PartEditStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 64*1024)
+
+		var edit pkg.PageEdit
+		if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+			http.Error(w, "Could not decode page edit: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		resourceId := r.PathValue("id")
+		name := r.PathValue("name")
+		if err := pkg.EditPart(ctx, store, orgId, resourceId, name, &edit); err != nil {
+			code := StoreErrorCode(err)
+			if code == http.StatusBadRequest {
+				http.Error(w, err.Error(), code)
+				return
+			}
+			http.Error(w, "Could not edit pages", code)
+			slog.ErrorContext(ctx, "Could not edit pages", "error", err, "resourceId", resourceId, "file", name)
+			return
+		}
+
+		slog.InfoContext(ctx, "Edited pages", "resourceId", resourceId, "file", name, "numReordered", len(edit.Order), "numRotated", len(edit.Rotate))
+		HxTrigger(w, EventResourceUploaded, map[string]string{"resourceId": resourceId})
+		HxFlash(w, r, FlashSuccess, "flash.pages-edited", map[string]any{"File": name})
+		w.WriteHeader(http.StatusOK)
+	}
+}
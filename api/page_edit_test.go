@@ -0,0 +1,63 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestEditPagesHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &pkg.Organization{Id: "org1"}))
+
+	var pdf bytes.Buffer
+	testutils.AssertNil(t, pkg.CreateNPagePdf(&pdf, 2))
+	meta := pkg.MetaData{Title: "Brandenburg Concerto", Composer: "Bach"}
+	testutils.AssertNil(t, store.Submit(ctx, "org1", &meta, func(yield func(string, []byte) bool) { yield("Flute.pdf", pdf.Bytes()) }))
+	resourceId := meta.ResourceId()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+RouteResourcesIdPartsNamePages, EditPagesHandler(store, time.Second))
+	serve := func(name, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/resources/"+resourceId+"/parts/"+name+"/pages", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, withAuthSession(req, "org1"))
+		return rec
+	}
+
+	rec := serve("Flute.pdf", `{"order": [2, 1], "rotate": {"1": 180}}`)
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), string(EventResourceUploaded))
+
+	versions, err := store.ResourceVersions(ctx, "org1", resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(versions), 1)
+
+	for _, test := range []struct {
+		desc string
+		name string
+		body string
+		code int
+	}{
+		{"invalid json", "Flute.pdf", `{"order": "2,1"}`, http.StatusBadRequest},
+		{"missing page", "Flute.pdf", `{"order": [2]}`, http.StatusBadRequest},
+		{"unknown part", "Oboe.pdf", `{"order": [2, 1]}`, http.StatusNotFound},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			testutils.AssertEqual(t, serve(test.name, test.body).Code, test.code)
+		})
+	}
+
+	t.Run("protected resource", func(t *testing.T) {
+		testutils.AssertNil(t, store.SetProtected(ctx, "org1", resourceId, true))
+		testutils.AssertEqual(t, serve("Flute.pdf", `{"order": [2, 1]}`).Code, http.StatusConflict)
+	})
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+// pageRequest reads the limit and cursor query parameters. A missing or invalid limit gives the default page size
+func pageRequest(r *http.Request) pkg.PageRequest {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	return pkg.NewPageRequest(limit, r.URL.Query().Get("cursor"))
+}
+
+// writeLoadMore writes a row loading the next page with the same query as the request. Nothing is written on the
+// last page
+func writeLoadMore(w http.ResponseWriter, r *http.Request, nextCursor string, columns int) {
+	if nextCursor == "" {
+		return
+	}
+	query := r.URL.Query()
+	query.Set("cursor", nextCursor)
+	web.LoadMoreRow(w, pkg.LanguageFromReq(r), r.URL.Path+"?"+query.Encode(), columns)
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+type PaletteStore interface {
+	pkg.MetaByPatternFetcher
+	pkg.ProjectByNameGetter
+	pkg.UserInOrgGetter
+}
+
+// paletteHref links to a page where the search field is filled with the query
+func paletteHref(page, query string) string {
+	return page + "?" + url.Values{"q": {query}}.Encode()
+}
+
+// paletteEntities returns the pieces, projects and, for admins, the people matching the query. At most limit
+// entries of each kind are returned
+func paletteEntities(ctx context.Context, store PaletteStore, orgId string, role pkg.RoleKind, query string, limit int) ([]web.PaletteEntry, error) {
+	entries := []web.PaletteEntry{}
+	meta, err := store.MetaByPattern(ctx, orgId, &pkg.MetaData{Title: query, Composer: query, Arranger: query})
+	if err != nil {
+		return entries, err
+	}
+	for _, suggestion := range pkg.Suggest(meta, query, limit) {
+		entries = append(entries, web.PaletteEntry{Kind: web.PalettePiece, Label: suggestion.Title, Detail: suggestion.Composer, Href: paletteHref("/overview", suggestion.Title)})
+	}
+
+	projects, err := store.ProjectsByName(ctx, orgId, query)
+	if err != nil {
+		return entries, err
+	}
+	for _, project := range projects[:min(len(projects), limit)] {
+		entries = append(entries, web.PaletteEntry{Kind: web.PaletteProject, Label: project.Name, Href: paletteHref("/projects", project.Name)})
+	}
+
+	// Only admins see the other members, like on the people page
+	if !role.AtLeast(pkg.RoleAdmin) {
+		return entries, nil
+	}
+	users, err := store.GetUsersInOrg(ctx, orgId)
+	if err != nil {
+		return entries, err
+	}
+	lowerQuery := strings.ToLower(query)
+	numPeople := 0
+	for _, user := range users {
+		if numPeople == limit {
+			break
+		}
+		if strings.Contains(strings.ToLower(user.Name), lowerQuery) || strings.Contains(strings.ToLower(user.Email), lowerQuery) {
+			entries = append(entries, web.PaletteEntry{Kind: web.PalettePerson, Label: user.Name, Detail: user.Email, Href: "/people"})
+			numPeople++
+		}
+	}
+	return entries, nil
+}
+
+// CommandPaletteHandler powers the command palette opened with Ctrl+K. It returns the pages the caller may open
+// and the pieces, projects and people matching the query "q". The entries are rendered as links, or as JSON when
+// the client accepts it
+func CommandPaletteHandler(store PaletteStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
+		limit := defaultNumSuggestions
+		if value, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && value > 0 {
+			limit = min(value, maxNumSuggestions)
+		}
+
+		session := MustGetSession(r)
+		orgId := MustGetOrgId(session)
+		role := MustGetUserInfo(session).Roles[orgId]
+		language := pkg.LanguageFromReq(r)
+
+		entries := web.PaletteActions(language, role, query)
+		if query != "" {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			entities, err := paletteEntities(ctx, store, orgId, role, query, limit)
+			if err != nil {
+				http.Error(w, "Failed to search", StoreErrorCode(err))
+				slog.ErrorContext(ctx, "Failed to search for the command palette", "error", err, "orgId", orgId)
+				return
+			}
+			entries = append(entries, entities...)
+		}
+
+		w.Header().Set("Cache-Control", "private, max-age=30")
+		w.Header().Set("Vary", "Accept, Accept-Language")
+		if strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(entries)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		web.CommandPalette(w, language, entries)
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/davidkleiven/caesura/web"
+)
+
+func searchPalette(store PaletteStore, orgId string, role pkg.RoleKind, query string) []web.PaletteEntry {
+	req := withAuthSession(httptest.NewRequest("GET", RoutePalette+"?q="+query, nil), orgId)
+	user := pkg.UserInfo{Id: "0000-0000", Roles: map[string]pkg.RoleKind{orgId: role}}
+	MustGetSession(req).Values["role"] = must(json.Marshal(user))
+	req.Header.Set("Accept", "application/json")
+
+	rec := httptest.NewRecorder()
+	CommandPaletteHandler(store, time.Second)(rec, req)
+	var entries []web.PaletteEntry
+	pkg.PanicOnErr(json.NewDecoder(rec.Body).Decode(&entries))
+	return entries
+}
+
+func paletteKinds(entries []web.PaletteEntry) map[web.PaletteKind]int {
+	kinds := make(map[web.PaletteKind]int)
+	for _, entry := range entries {
+		kinds[entry.Kind]++
+	}
+	return kinds
+}
+
+func TestCommandPaletteHandler(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	testutils.AssertNil(t, store.RegisterUser(context.Background(), &pkg.UserInfo{Id: "demo-user", Name: "Demo Drummer", Roles: map[string]pkg.RoleKind{orgId: pkg.RoleViewer}}))
+
+	t.Run("admin", func(t *testing.T) {
+		kinds := paletteKinds(searchPalette(store, orgId, pkg.RoleAdmin, "demo"))
+		testutils.AssertEqual(t, kinds[web.PalettePiece] > 0, true)
+		testutils.AssertEqual(t, kinds[web.PaletteProject] > 0, true)
+		testutils.AssertEqual(t, kinds[web.PalettePerson], 1)
+	})
+
+	t.Run("viewer does not see people or upload", func(t *testing.T) {
+		kinds := paletteKinds(searchPalette(store, orgId, pkg.RoleViewer, "demo"))
+		testutils.AssertEqual(t, kinds[web.PalettePerson], 0)
+
+		for _, entry := range searchPalette(store, orgId, pkg.RoleViewer, "") {
+			testutils.AssertEqual(t, entry.Kind, web.PaletteAction)
+			testutils.AssertEqual(t, entry.Href != "/upload", true)
+		}
+	})
+
+	t.Run("html", func(t *testing.T) {
+		req := withAuthSession(httptest.NewRequest("GET", RoutePalette+"?q=demo+project", nil), orgId)
+		rec := httptest.NewRecorder()
+		CommandPaletteHandler(store, time.Second)(rec, req)
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+		testutils.AssertContains(t, rec.Body.String(), `id="palette-results"`, `href="/projects?q=Demo&#43;Project&#43;1"`, "Project")
+	})
+}
+
+type failingPaletteStore struct {
+	*pkg.MultiOrgInMemoryStore
+	failingFetcher
+}
+
+func (f *failingPaletteStore) MetaByPattern(ctx context.Context, orgId string, pattern *pkg.MetaData) ([]pkg.MetaData, error) {
+	return f.failingFetcher.MetaByPattern(ctx, orgId, pattern)
+}
+
+func TestCommandPaletteHandlerStoreError(t *testing.T) {
+	store := &failingPaletteStore{pkg.NewMultiOrgInMemoryStore(), failingFetcher{err: errors.New("unavailable")}}
+	req := withAuthSession(httptest.NewRequest("GET", RoutePalette+"?q=demo", nil), "org1")
+	rec := httptest.NewRecorder()
+	CommandPaletteHandler(store, time.Second)(rec, req)
+	testutils.AssertEqual(t, rec.Code, http.StatusInternalServerError)
+}
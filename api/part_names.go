@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+type PartNameReportStore interface {
+	pkg.MetaByPatternFetcher
+	pkg.PartLister
+}
+
+// PartNameReportHandler checks the part names of all resources against the instruments of the organization.
+// The report is returned as JSON when the request accepts it
+func PartNameReportHandler(store PartNameReportStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		metaData, err := store.MetaByPattern(ctx, orgId, &pkg.MetaData{})
+		if err != nil {
+			http.Error(w, "Failed to fetch metadata", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Failed to fetch metadata", "error", err)
+			return
+		}
+		parts, err := store.PartNames(ctx, orgId)
+		if err != nil {
+			http.Error(w, "Failed to list parts", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Failed to list parts", "error", err)
+			return
+		}
+
+		report := pkg.LintPartNames(metaData, parts, instrumentsOf(InstrumentFamilies(r)))
+		w.Header().Set("Vary", "Accept")
+		if strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(report)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		web.PartNameReport(w, pkg.LanguageFromReq(r), report)
+	}
+}
+
+// RenamePartsHandler renames the part in the form value name to newName in all resources in the form values
+// resourceId. Resources where the rename fails are skipped and counted in the message
+func RenamePartsHandler(store pkg.PartRenamer, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
+		name := r.FormValue("name")
+		resourceIds := pkg.RemoveDuplicates(r.Form["resourceId"])
+		if name == "" || len(resourceIds) == 0 {
+			http.Error(w, "A part name and at least one resource are required", http.StatusBadRequest)
+			return
+		}
+		if _, err := pkg.PartName(r.FormValue("newName")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		var newName string
+		failed := 0
+		for _, resourceId := range resourceIds {
+			renamed, err := pkg.RenamePart(ctx, store, orgId, resourceId, name, r.FormValue("newName"))
+			if err != nil {
+				failed++
+				slog.WarnContext(ctx, "Could not rename part", "error", err, "resourceId", resourceId, "file", name)
+				continue
+			}
+			newName = renamed
+		}
+
+		renamed := len(resourceIds) - failed
+		if renamed == 0 {
+			http.Error(w, "Could not rename the part in any of the resources", http.StatusConflict)
+			return
+		}
+
+		slog.InfoContext(ctx, "Renamed parts", "file", name, "newName", newName, "numRenamed", renamed, "numFailed", failed)
+		HxTrigger(w, EventResourceUploaded, map[string]any{"resourceIds": resourceIds})
+		data := map[string]any{"File": name, "NewName": newName, "Count": renamed, "Failed": failed}
+		if failed > 0 {
+			HxFlash(w, r, FlashWarning, "flash.parts-renamed-partly", data)
+		} else {
+			HxFlash(w, r, FlashSuccess, "flash.parts-renamed", data)
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
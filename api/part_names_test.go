@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func partNamesTestStore(t *testing.T) *pkg.MultiOrgInMemoryStore {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &pkg.Organization{Id: "org1"}))
+	for _, meta := range []pkg.MetaData{{Title: "Bolero", Composer: "Ravel"}, {Title: "Arabesque", Composer: "Debussy"}} {
+		testutils.AssertNil(t, store.Submit(ctx, "org1", &meta, func(yield func(string, []byte) bool) {
+			_ = yield("Trompet 1.pdf", []byte("trumpet")) && yield("Flute.pdf", []byte("flute"))
+		}))
+	}
+	return store
+}
+
+func TestPartNameReportHandler(t *testing.T) {
+	store := partNamesTestStore(t)
+	handler := PartNameReportHandler(store, time.Second)
+
+	req := withAuthSession(httptest.NewRequest("GET", RouteResourcesPartNames, nil), "org1")
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+
+	var report pkg.PartNameReport
+	testutils.AssertNil(t, json.NewDecoder(rec.Body).Decode(&report))
+	testutils.AssertEqual(t, report.NumResources, 2)
+	testutils.AssertEqual(t, len(report.Issues), 2)
+	testutils.AssertEqual(t, len(report.Renames), 1)
+	testutils.AssertEqual(t, report.Renames[0].Suggestion, "Trumpet 1.pdf")
+
+	t.Run("html", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, withAuthSession(httptest.NewRequest("GET", RouteResourcesPartNames, nil), "org1"))
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+		testutils.AssertContains(t, rec.Body.String(), "Trompet 1.pdf", RouteResourcesPartNamesRenames, "/files/Trompet%201.pdf")
+	})
+
+	t.Run("only instruments of the organization", func(t *testing.T) {
+		ctx := context.WithValue(req.Context(), instrumentFamiliesKey, []string{"strings"})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req.WithContext(ctx))
+		report = pkg.PartNameReport{}
+		testutils.AssertNil(t, json.NewDecoder(rec.Body).Decode(&report))
+		testutils.AssertEqual(t, len(report.Issues), 0)
+	})
+}
+
+func TestRenamePartsHandler(t *testing.T) {
+	store := partNamesTestStore(t)
+	ctx := context.Background()
+
+	mux := http.NewServeMux()
+	mux.Handle("POST "+RouteResourcesPartNamesRenames, AuditRoute(store, pkg.AuditPartRenamed, auditRenamedParts)(RenamePartsHandler(store, time.Second)))
+	serve := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", RouteResourcesPartNamesRenames, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(context.WithValue(req.Context(), pkg.OrgIdKey, "org1"))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, withAuthSession(req, "org1"))
+		return rec
+	}
+
+	form := url.Values{"name": {"Trompet 1.pdf"}, "newName": {"Trumpet 1.pdf"}, "resourceId": {"bolero_ravel", "unknown"}}
+	rec := serve(form)
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), string(EventResourceUploaded), string(FlashWarning))
+
+	parts := maps.Collect(store.Resource(ctx, "org1", "bolero_ravel"))
+	testutils.AssertEqual(t, string(parts["Trumpet 1.pdf"]), "trumpet")
+	parts = maps.Collect(store.Resource(ctx, "org1", "arabesque_debussy"))
+	testutils.AssertEqual(t, string(parts["Trompet 1.pdf"]), "trumpet")
+
+	testutils.AssertEqual(t, len(store.AuditLogs["org1"]), 1)
+	testutils.AssertEqual(t, store.AuditLogs["org1"][0].TargetId, "bolero_ravel,unknown")
+	testutils.AssertEqual(t, store.AuditLogs["org1"][0].Detail, "from=Trompet 1.pdf to=Trumpet 1.pdf")
+
+	for _, test := range []struct {
+		desc string
+		form url.Values
+		code int
+	}{
+		{"no resources", url.Values{"name": {"Flute.pdf"}, "newName": {"Flute 1"}}, http.StatusBadRequest},
+		{"invalid name", url.Values{"name": {"Flute.pdf"}, "newName": {"../Flute"}, "resourceId": {"bolero_ravel"}}, http.StatusBadRequest},
+		{"nothing renamed", url.Values{"name": {"Trompet 1.pdf"}, "newName": {"Trumpet 1"}, "resourceId": {"bolero_ravel"}}, http.StatusConflict},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			testutils.AssertEqual(t, serve(test.form).Code, test.code)
+		})
+	}
+	testutils.AssertEqual(t, len(store.AuditLogs["org1"]), 1)
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+// RenamePartHandler renames a part of a resource to the name in the form value name. The parts before the rename
+// are kept as a version
+func RenamePartHandler(store pkg.PartRenamer, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		resourceId := r.PathValue("id")
+		name := r.PathValue("name")
+		newName, err := pkg.RenamePart(ctx, store, orgId, resourceId, name, r.FormValue("name"))
+		if err != nil {
+			code := StoreErrorCode(err)
+			if code == http.StatusBadRequest || code == http.StatusConflict {
+				http.Error(w, err.Error(), code)
+				slog.InfoContext(ctx, "Rejected part rename", "error", err, "resourceId", resourceId, "file", name)
+				return
+			}
+			http.Error(w, "Could not rename part", code)
+			slog.ErrorContext(ctx, "Could not rename part", "error", err, "resourceId", resourceId, "file", name)
+			return
+		}
+
+		slog.InfoContext(ctx, "Renamed part", "resourceId", resourceId, "file", name, "newName", newName)
+		HxTrigger(w, EventResourceUploaded, map[string]string{"resourceId": resourceId})
+		HxFlash(w, r, FlashSuccess, "flash.part-renamed", map[string]any{"File": name, "NewName": newName})
+		w.WriteHeader(http.StatusOK)
+	}
+}
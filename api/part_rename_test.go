@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestRenamePartHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &pkg.Organization{Id: "org1"}))
+
+	meta := pkg.MetaData{Title: "Brandenburg Concerto", Composer: "Bach"}
+	testutils.AssertNil(t, store.Submit(ctx, "org1", &meta, func(yield func(string, []byte) bool) {
+		_ = yield("Trompet 1.pdf", []byte("trumpet")) && yield("Flute.pdf", []byte("flute"))
+	}))
+	resourceId := meta.ResourceId()
+
+	mux := http.NewServeMux()
+	mux.Handle("PATCH "+RouteResourcesIdFilesName, AuditRoute(store, pkg.AuditPartRenamed, auditRenamedPart)(RenamePartHandler(store, time.Second)))
+	serve := func(name, newName string) *httptest.ResponseRecorder {
+		form := url.Values{"name": {newName}}
+		req := httptest.NewRequest("PATCH", "/resources/"+resourceId+"/files/"+url.PathEscape(name), strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(context.WithValue(req.Context(), pkg.OrgIdKey, "org1"))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, withAuthSession(req, "org1"))
+		return rec
+	}
+
+	rec := serve("Trompet 1.pdf", "Trumpet 1")
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), string(EventResourceUploaded))
+
+	parts := maps.Collect(store.Resource(ctx, "org1", resourceId))
+	testutils.AssertEqual(t, string(parts["Trumpet 1.pdf"]), "trumpet")
+	_, exists := parts["Trompet 1.pdf"]
+	testutils.AssertEqual(t, exists, false)
+
+	testutils.AssertEqual(t, len(store.AuditLogs["org1"]), 1)
+	entry := store.AuditLogs["org1"][0]
+	testutils.AssertEqual(t, entry.Action, pkg.AuditPartRenamed)
+	testutils.AssertEqual(t, entry.TargetId, resourceId)
+	testutils.AssertEqual(t, entry.Detail, "from=Trompet 1.pdf to=Trumpet 1.pdf")
+
+	for _, test := range []struct {
+		desc    string
+		name    string
+		newName string
+		code    int
+	}{
+		{"invalid name", "Flute.pdf", "../Flute", http.StatusBadRequest},
+		{"unknown part", "Oboe.pdf", "Oboe 1", http.StatusNotFound},
+		{"existing part", "Flute.pdf", "Trumpet 1", http.StatusConflict},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			testutils.AssertEqual(t, serve(test.name, test.newName).Code, test.code)
+		})
+	}
+
+	// Rejected renames are not audited
+	testutils.AssertEqual(t, len(store.AuditLogs["org1"]), 1)
+}
@@ -0,0 +1,364 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gorilla/sessions"
+)
+
+const (
+	// The user signed in with a passkey
+	sessionPasskeyKey = "passkey"
+
+	// Organization that is known not to require passkeys
+	sessionPasskeyCheckedOrgKey = "passkeyCheckedOrg"
+
+	// Challenges of ongoing registrations and sign ins
+	passkeyRegistrationKey = "passkeyRegistration"
+	passkeyLoginKey        = "passkeyLogin"
+)
+
+type PasskeyUserStore interface {
+	pkg.RoleGetter
+	pkg.PasskeyStore
+}
+
+type PasskeyLoginStore interface {
+	LoginStore
+	pkg.PasskeyStore
+}
+
+type PasskeyListStore interface {
+	pkg.PasskeyStore
+	pkg.OrganizationGetter
+}
+
+func passkeyUser(ctx context.Context, store PasskeyUserStore, userId string) (*pkg.PasskeyUser, error) {
+	info, err := store.GetUserInfo(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	passkeys, err := store.Passkeys(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return &pkg.PasskeyUser{Info: info, Passkeys: passkeys}, nil
+}
+
+// saveCeremony stores the challenge of a registration or sign in in the session until the browser responds
+// PasskeysOnMainDomain refuses passkey ceremonies on the custom domains of organizations, since passkeys are
+// bound to the host of the base URL and the browser would reject them on other hosts
+func PasskeysOnMainDomain(baseURL string) func(http.Handler) http.Handler {
+	mainHost := pkg.HostOf(baseURL)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := DomainOrganization(r); ok {
+				http.Error(w, fmt.Sprintf("Passkeys can only be used on %s. Sign in there to use a passkey", mainHost), http.StatusBadRequest)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func saveCeremony(w http.ResponseWriter, r *http.Request, session *sessions.Session, key string, ceremony *webauthn.SessionData) error {
+	data, err := json.Marshal(ceremony)
+	if err != nil {
+		return err
+	}
+	session.Values[key] = data
+	return session.Save(r, w)
+}
+
+// takeCeremony returns the challenge stored by saveCeremony. The challenge is removed, such that every
+// challenge is only answered once
+func takeCeremony(session *sessions.Session, key string) (webauthn.SessionData, error) {
+	var ceremony webauthn.SessionData
+	data, ok := session.Values[key].([]byte)
+	if !ok {
+		return ceremony, errors.New("no passkey challenge in session")
+	}
+	delete(session.Values, key)
+	return ceremony, json.Unmarshal(data, &ceremony)
+}
+
+func writeCeremonyOptions(w http.ResponseWriter, options any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(options)
+}
+
+// BeginPasskeyRegistration returns the options the browser uses to create a passkey for the signed in user
+func BeginPasskeyRegistration(relyingParty *webauthn.WebAuthn, store PasskeyUserStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		session := MustGetSession(r)
+		userId := MustGetUserId(session)
+		user, err := passkeyUser(ctx, store, userId)
+		if err != nil {
+			http.Error(w, "Could not fetch user", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch user registering a passkey", "error", err, "userId", userId)
+			return
+		}
+
+		// Passkeys must be discoverable, such that users can sign in without entering their email
+		options, ceremony, err := relyingParty.BeginRegistration(
+			user,
+			webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+			webauthn.WithExclusions(webauthn.Credentials(user.WebAuthnCredentials()).CredentialDescriptors()),
+		)
+		if err != nil {
+			http.Error(w, "Could not start passkey registration", http.StatusInternalServerError)
+			slog.ErrorContext(ctx, "Could not begin passkey registration", "error", err, "userId", userId)
+			return
+		}
+		if err := saveCeremony(w, r, session, passkeyRegistrationKey, ceremony); err != nil {
+			http.Error(w, "Could not save session", http.StatusInternalServerError)
+			slog.ErrorContext(ctx, "Could not save session", "error", err)
+			return
+		}
+		writeCeremonyOptions(w, options)
+	}
+}
+
+// FinishPasskeyRegistration verifies the passkey created by the browser and stores it on the signed in user
+func FinishPasskeyRegistration(relyingParty *webauthn.WebAuthn, store PasskeyUserStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 64*1024)
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		session := MustGetSession(r)
+		userId := MustGetUserId(session)
+		ceremony, err := takeCeremony(session, passkeyRegistrationKey)
+		if err != nil {
+			http.Error(w, "Passkey registration has not been started", http.StatusBadRequest)
+			return
+		}
+		trySaveSession(session, r, w)
+
+		user, err := passkeyUser(ctx, store, userId)
+		if err != nil {
+			http.Error(w, "Could not fetch user", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch user registering a passkey", "error", err, "userId", userId)
+			return
+		}
+
+		credential, err := relyingParty.FinishRegistration(user, ceremony, r)
+		if err != nil {
+			http.Error(w, "Could not verify passkey", http.StatusBadRequest)
+			slog.InfoContext(ctx, "Could not verify passkey", "error", err, "userId", userId)
+			return
+		}
+
+		passkey, err := pkg.NewPasskey(userId, r.URL.Query().Get("name"), credential)
+		if err == nil {
+			err = store.SavePasskey(ctx, passkey)
+		}
+		if err != nil {
+			http.Error(w, "Could not store passkey: "+err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not store passkey", "error", err, "userId", userId)
+			return
+		}
+
+		slog.InfoContext(ctx, "Registered passkey", "userId", userId, "passkeyId", passkey.Id)
+		HxTrigger(w, EventPasskeysUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.passkey-added", nil)
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// PasskeysHandler lists the passkeys of the signed in user. Admins can also choose whether the active
+// organization requires passkeys
+func PasskeysHandler(store PasskeyListStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		session := MustGetSession(r)
+		userId := MustGetUserId(session)
+		passkeys, err := store.Passkeys(ctx, userId)
+		if err != nil {
+			http.Error(w, "Could not fetch passkeys", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch passkeys", "error", err, "userId", userId)
+			return
+		}
+
+		data := web.PasskeysData{Passkeys: passkeys}
+		orgId, _ := session.Values["orgId"].(string)
+		if _, hasRole := session.Values["role"].([]byte); hasRole && orgId != "" && MustGetUserInfo(session).Roles[orgId].AtLeast(pkg.RoleAdmin) {
+			org, err := store.GetOrganization(ctx, orgId)
+			if err != nil {
+				http.Error(w, "Could not fetch organization", StoreErrorCode(err))
+				slog.ErrorContext(ctx, "Could not fetch organization", "error", err, "orgId", orgId)
+				return
+			}
+			data.CanRequire = true
+			data.Required = org.RequirePasskey
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		web.Passkeys(w, pkg.LanguageFromReq(r), data)
+	}
+}
+
+func DeletePasskeyHandler(store pkg.PasskeyStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		userId := MustGetUserId(MustGetSession(r))
+		id := r.PathValue("id")
+		if err := store.DeletePasskey(ctx, userId, id); err != nil {
+			http.Error(w, "Could not delete passkey", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not delete passkey", "error", err, "userId", userId, "passkeyId", id)
+			return
+		}
+
+		slog.InfoContext(ctx, "Deleted passkey", "userId", userId, "passkeyId", id)
+		HxTrigger(w, EventPasskeysUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.passkey-deleted", nil)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// BeginPasskeyLogin returns the options the browser uses to sign in with any passkey registered on this site
+func BeginPasskeyLogin(relyingParty *webauthn.WebAuthn) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		options, ceremony, err := relyingParty.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+		if err != nil {
+			http.Error(w, "Could not start sign in with passkey", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Could not begin passkey login", "error", err)
+			return
+		}
+		if err := saveCeremony(w, r, MustGetSession(r), passkeyLoginKey, ceremony); err != nil {
+			http.Error(w, "Could not save session", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Could not save session", "error", err)
+			return
+		}
+		writeCeremonyOptions(w, options)
+	}
+}
+
+// FinishPasskeyLogin verifies the signature of the passkey and signs in the user owning it. The user is found
+// from the user handle stored in the passkey
+func FinishPasskeyLogin(relyingParty *webauthn.WebAuthn, store PasskeyLoginStore, signSecret string, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 64*1024)
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		session := MustGetSession(r)
+		ceremony, err := takeCeremony(session, passkeyLoginKey)
+		if err != nil {
+			http.Error(w, "Sign in with passkey has not been started", http.StatusBadRequest)
+			return
+		}
+
+		findUser := func(rawId, userHandle []byte) (webauthn.User, error) {
+			user, err := passkeyUser(ctx, store, string(userHandle))
+			if err != nil {
+				return nil, err
+			}
+			return user, nil
+		}
+		found, credential, err := relyingParty.FinishPasskeyLogin(findUser, ceremony, r)
+		if err != nil {
+			trySaveSession(session, r, w)
+			http.Error(w, "Could not verify passkey", http.StatusUnauthorized)
+			slog.InfoContext(ctx, "Could not verify passkey", "error", err)
+			return
+		}
+		user := found.(*pkg.PasskeyUser)
+		if credential.Authenticator.CloneWarning {
+			trySaveSession(session, r, w)
+			http.Error(w, "The passkey may have been copied. Sign in with another method and replace it", http.StatusUnauthorized)
+			slog.WarnContext(ctx, "Sign count of passkey did not increase", "userId", user.Info.Id)
+			return
+		}
+
+		passkey, err := user.PasskeyByCredentialId(credential.ID)
+		if err == nil {
+			err = passkey.UpdateCredential(credential, time.Now())
+		}
+		if err == nil {
+			err = store.SavePasskey(ctx, passkey)
+		}
+		if err != nil {
+			// The sign count is only used to detect copied passkeys, hence the user is signed in anyway
+			slog.ErrorContext(ctx, "Could not update passkey after sign in", "error", err, "userId", user.Info.Id)
+		}
+
+		result := InitializeUserSession(SessionInitParams{
+			Ctx:        ctx,
+			Session:    session,
+			User:       user.Info,
+			SignSecret: signSecret,
+			Store:      store,
+			Writer:     w,
+			Req:        r,
+			Passkey:    true,
+		})
+		if result.Error != nil {
+			http.Error(w, result.Error.Error(), result.ReturnCode)
+			slog.ErrorContext(ctx, "Could not initialize user session", "error", result.Error)
+			return
+		}
+
+		slog.InfoContext(ctx, "Successfully logged in user with passkey", "userId", user.Info.Id)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// PasskeyRequirementHandler sets whether members must sign in with a passkey to access the organization. Admins
+// must sign in with a passkey before requiring it, such that they do not lock themselves out
+func PasskeyRequirementHandler(updater pkg.PasskeyRequirementUpdater, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 1024)
+		code, err := parseForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+		required, err := strconv.ParseBool(r.FormValue("required"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("required must be true or false, got %q", r.FormValue("required")), http.StatusBadRequest)
+			return
+		}
+
+		session := MustGetSession(r)
+		if signedInWithPasskey, _ := session.Values[sessionPasskeyKey].(bool); required && !signedInWithPasskey {
+			http.Error(w, "Sign in with a passkey before requiring passkeys", http.StatusForbidden)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(session)
+		if err := updater.RequirePasskey(ctx, orgId, required); err != nil {
+			http.Error(w, "Could not update organization", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not update passkey requirement", "error", err, "orgId", orgId)
+			return
+		}
+
+		slog.InfoContext(ctx, "Updated passkey requirement", "orgId", orgId, "required", required)
+		HxTrigger(w, EventPasskeysUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.passkey-requirement-updated", nil)
+		w.WriteHeader(http.StatusOK)
+	}
+}
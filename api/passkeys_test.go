@@ -0,0 +1,389 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gorilla/sessions"
+)
+
+const passkeyTestOrigin = "https://caesura.no"
+
+var b64 = base64.RawURLEncoding
+
+// virtualAuthenticator creates and signs with a single passkey the way a browser would
+type virtualAuthenticator struct {
+	t            *testing.T
+	key          *ecdsa.PrivateKey
+	credentialId []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newVirtualAuthenticator(t *testing.T) *virtualAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testutils.AssertNil(t, err)
+	return &virtualAuthenticator{t: t, key: key, credentialId: []byte("credential-" + pkg.RandomInsecureID())}
+}
+
+func (a *virtualAuthenticator) authData(flags byte, attested []byte) []byte {
+	rpIdHash := sha256.Sum256([]byte(pkg.HostOf(passkeyTestOrigin)))
+	data := append(rpIdHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attested...)
+}
+
+func (a *virtualAuthenticator) clientData(kind string, challenge string) []byte {
+	data, err := json.Marshal(map[string]any{"type": kind, "challenge": challenge, "origin": passkeyTestOrigin})
+	testutils.AssertNil(a.t, err)
+	return data
+}
+
+// create answers the options returned when a registration begins
+func (a *virtualAuthenticator) create(options []byte) []byte {
+	var creation struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+			User      struct {
+				Id string `json:"id"`
+			} `json:"user"`
+		} `json:"publicKey"`
+	}
+	testutils.AssertNil(a.t, json.Unmarshal(options, &creation))
+	handle, err := b64.DecodeString(creation.PublicKey.User.Id)
+	testutils.AssertNil(a.t, err)
+	a.userHandle = handle
+
+	coseKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{KeyType: int64(webauthncose.EllipticKey), Algorithm: int64(webauthncose.AlgES256)},
+		Curve:         int64(webauthncose.P256),
+		XCoord:        a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		YCoord:        a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	testutils.AssertNil(a.t, err)
+
+	attested := make([]byte, 16) // AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialId)))
+	attested = append(attested, a.credentialId...)
+	attested = append(attested, coseKey...)
+
+	// User present, user verified and attested credential data included
+	attestation, err := webauthncbor.Marshal(map[string]any{"fmt": "none", "attStmt": map[string]any{}, "authData": a.authData(0x45, attested)})
+	testutils.AssertNil(a.t, err)
+
+	body, err := json.Marshal(map[string]any{
+		"id":    b64.EncodeToString(a.credentialId),
+		"rawId": b64.EncodeToString(a.credentialId),
+		"type":  "public-key",
+		"response": map[string]any{
+			"attestationObject": b64.EncodeToString(attestation),
+			"clientDataJSON":    b64.EncodeToString(a.clientData("webauthn.create", creation.PublicKey.Challenge)),
+		},
+	})
+	testutils.AssertNil(a.t, err)
+	return body
+}
+
+// get answers the options returned when a sign in begins
+func (a *virtualAuthenticator) get(options []byte) []byte {
+	var assertion struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+		} `json:"publicKey"`
+	}
+	testutils.AssertNil(a.t, json.Unmarshal(options, &assertion))
+
+	a.signCount++
+	authData := a.authData(0x05, nil)
+	clientData := a.clientData("webauthn.get", assertion.PublicKey.Challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(bytes.Clone(authData), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	testutils.AssertNil(a.t, err)
+
+	body, err := json.Marshal(map[string]any{
+		"id":    b64.EncodeToString(a.credentialId),
+		"rawId": b64.EncodeToString(a.credentialId),
+		"type":  "public-key",
+		"response": map[string]any{
+			"authenticatorData": b64.EncodeToString(authData),
+			"clientDataJSON":    b64.EncodeToString(clientData),
+			"signature":         b64.EncodeToString(signature),
+			"userHandle":        b64.EncodeToString(a.userHandle),
+		},
+	})
+	testutils.AssertNil(a.t, err)
+	return body
+}
+
+func passkeyTestRelyingParty(t *testing.T) *webauthn.WebAuthn {
+	relyingParty, err := pkg.NewWebAuthn(passkeyTestOrigin+"/", "Caesura")
+	testutils.AssertNil(t, err)
+	return relyingParty
+}
+
+func newPasskeySession() *sessions.Session {
+	session, err := sessions.NewCookieStore([]byte("whatever-key")).New(httptest.NewRequest("GET", "/", nil), AuthSession)
+	if err != nil {
+		panic(err)
+	}
+	return session
+}
+
+func withSession(r *http.Request, session *sessions.Session) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), sessionKey, session))
+}
+
+func servePasskeyRequest(handler http.HandlerFunc, session *sessions.Session, method, target string, body []byte) *httptest.ResponseRecorder {
+	req := withSession(httptest.NewRequest(method, target, bytes.NewReader(body)), session)
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	handler(recorder, req)
+	return recorder
+}
+
+func registerTestPasskey(t *testing.T, store PasskeyUserStore, session *sessions.Session, authenticator *virtualAuthenticator, name string) *httptest.ResponseRecorder {
+	relyingParty := passkeyTestRelyingParty(t)
+	begin := servePasskeyRequest(BeginPasskeyRegistration(relyingParty, store, time.Second), session, "POST", RoutePasskeysRegisterBegin, nil)
+	testutils.AssertEqual(t, begin.Code, http.StatusOK)
+
+	finishHandler := FinishPasskeyRegistration(relyingParty, store, time.Second)
+	return servePasskeyRequest(finishHandler, session, "POST", RoutePasskeysRegisterFinish+"?name="+url.QueryEscape(name), authenticator.create(begin.Body.Bytes()))
+}
+
+func passkeyTestStore(t *testing.T) *pkg.MultiOrgInMemoryStore {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterUser(ctx, &pkg.UserInfo{Id: "user1", Email: "kari@example.com", Name: "Kari"}))
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &pkg.Organization{Id: "org1", Name: "Band"}))
+	testutils.AssertNil(t, store.RegisterRole(ctx, "user1", "org1", pkg.RoleAdmin))
+	return store
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	store := passkeyTestStore(t)
+	authenticator := newVirtualAuthenticator(t)
+	session := newPasskeySession()
+	session.Values["userId"] = "user1"
+
+	recorder := registerTestPasskey(t, store, session, authenticator, "Laptop")
+	testutils.AssertEqual(t, recorder.Code, http.StatusCreated)
+	testutils.AssertContains(t, recorder.Header().Get("HX-Trigger"), string(EventPasskeysUpdated))
+
+	passkeys, err := store.Passkeys(context.Background(), "user1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(passkeys), 1)
+	testutils.AssertEqual(t, passkeys[0].Name, "Laptop")
+
+	t.Run("challenge is only answered once", func(t *testing.T) {
+		finish := FinishPasskeyRegistration(passkeyTestRelyingParty(t), store, time.Second)
+		recorder := servePasskeyRequest(finish, session, "POST", RoutePasskeysRegisterFinish, newVirtualAuthenticator(t).create([]byte(`{"publicKey":{"user":{}}}`)))
+		testutils.AssertEqual(t, recorder.Code, http.StatusBadRequest)
+	})
+
+	relyingParty := passkeyTestRelyingParty(t)
+	login := func(authenticator *virtualAuthenticator) (*httptest.ResponseRecorder, *sessions.Session) {
+		session := newPasskeySession()
+		begin := servePasskeyRequest(BeginPasskeyLogin(relyingParty), session, "POST", RouteLoginPasskeyBegin, nil)
+		testutils.AssertEqual(t, begin.Code, http.StatusOK)
+		finish := FinishPasskeyLogin(relyingParty, store, "secret", time.Second)
+		return servePasskeyRequest(finish, session, "POST", RouteLoginPasskeyFinish, authenticator.get(begin.Body.Bytes())), session
+	}
+
+	t.Run("sign in", func(t *testing.T) {
+		recorder, session := login(authenticator)
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertEqual(t, session.Values["userId"], "user1")
+		testutils.AssertEqual(t, session.Values[sessionPasskeyKey], true)
+
+		passkeys, err := store.Passkeys(context.Background(), "user1")
+		testutils.AssertNil(t, err)
+		credential, err := passkeys[0].WebAuthnCredential()
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, credential.Authenticator.SignCount, authenticator.signCount)
+	})
+
+	t.Run("copied passkey", func(t *testing.T) {
+		authenticator.signCount = 0
+		recorder, session := login(authenticator)
+		testutils.AssertEqual(t, recorder.Code, http.StatusUnauthorized)
+		_, signedIn := session.Values["userId"]
+		testutils.AssertEqual(t, signedIn, false)
+	})
+
+	t.Run("unknown passkey", func(t *testing.T) {
+		unknown := newVirtualAuthenticator(t)
+		unknown.userHandle = []byte("user1")
+		recorder, _ := login(unknown)
+		testutils.AssertEqual(t, recorder.Code, http.StatusUnauthorized)
+	})
+
+	t.Run("sign in not started", func(t *testing.T) {
+		finish := FinishPasskeyLogin(relyingParty, store, "secret", time.Second)
+		recorder := servePasskeyRequest(finish, newPasskeySession(), "POST", RouteLoginPasskeyFinish, nil)
+		testutils.AssertEqual(t, recorder.Code, http.StatusBadRequest)
+	})
+}
+
+func TestPasskeyRegistrationUnknownUser(t *testing.T) {
+	session := newPasskeySession()
+	session.Values["userId"] = "unknown"
+	begin := BeginPasskeyRegistration(passkeyTestRelyingParty(t), pkg.NewMultiOrgInMemoryStore(), time.Second)
+	recorder := servePasskeyRequest(begin, session, "POST", RoutePasskeysRegisterBegin, nil)
+	testutils.AssertEqual(t, recorder.Code, http.StatusNotFound)
+}
+
+func TestPasskeysHandler(t *testing.T) {
+	store := passkeyTestStore(t)
+	session := newPasskeySession()
+	session.Values["userId"] = "user1"
+	testutils.AssertEqual(t, registerTestPasskey(t, store, session, newVirtualAuthenticator(t), "Phone").Code, http.StatusCreated)
+
+	t.Run("admin can require passkeys", func(t *testing.T) {
+		req := withAuthSession(httptest.NewRequest("GET", RoutePasskeys, nil), "org1")
+		MustGetSession(req).Values["userId"] = "user1"
+		recorder := httptest.NewRecorder()
+		PasskeysHandler(store, time.Second)(recorder, req)
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertContains(t, recorder.Body.String(), "Phone", "require-passkey")
+	})
+
+	t.Run("signed in without organization", func(t *testing.T) {
+		recorder := servePasskeyRequest(PasskeysHandler(store, time.Second), session, "GET", RoutePasskeys, nil)
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertContains(t, recorder.Body.String(), "Phone")
+		testutils.AssertNotContains(t, recorder.Body.String(), "require-passkey")
+	})
+}
+
+func TestDeletePasskeyHandler(t *testing.T) {
+	store := passkeyTestStore(t)
+	session := newPasskeySession()
+	session.Values["userId"] = "user1"
+	authenticator := newVirtualAuthenticator(t)
+	testutils.AssertEqual(t, registerTestPasskey(t, store, session, authenticator, "Phone").Code, http.StatusCreated)
+
+	deletePasskey := func(userId, id string) *httptest.ResponseRecorder {
+		session := newPasskeySession()
+		session.Values["userId"] = userId
+		req := withSession(httptest.NewRequest("DELETE", "/passkeys/"+id, nil), session)
+		req.SetPathValue("id", id)
+		recorder := httptest.NewRecorder()
+		DeletePasskeyHandler(store, time.Second)(recorder, req)
+		return recorder
+	}
+
+	id := pkg.PasskeyId(authenticator.credentialId)
+	testutils.AssertEqual(t, deletePasskey("user2", id).Code, http.StatusNotFound)
+
+	recorder := deletePasskey("user1", id)
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+	testutils.AssertContains(t, recorder.Header().Get("HX-Trigger"), string(EventPasskeysUpdated))
+
+	passkeys, err := store.Passkeys(context.Background(), "user1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(passkeys), 0)
+}
+
+func TestPasskeyRequirementHandler(t *testing.T) {
+	store := passkeyTestStore(t)
+	update := func(required string, passkey bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", RouteOrganizationsPasskeys, strings.NewReader(url.Values{"required": {required}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withAuthSession(req, "org1")
+		MustGetSession(req).Values[sessionPasskeyKey] = passkey
+		recorder := httptest.NewRecorder()
+		PasskeyRequirementHandler(store, time.Second)(recorder, req)
+		return recorder
+	}
+
+	t.Run("invalid value", func(t *testing.T) {
+		testutils.AssertEqual(t, update("maybe", true).Code, http.StatusBadRequest)
+	})
+
+	t.Run("admin must sign in with passkey", func(t *testing.T) {
+		testutils.AssertEqual(t, update("true", false).Code, http.StatusForbidden)
+	})
+
+	t.Run("require and lift", func(t *testing.T) {
+		testutils.AssertEqual(t, update("true", true).Code, http.StatusOK)
+		org, err := store.GetOrganization(context.Background(), "org1")
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, org.RequirePasskey, true)
+
+		testutils.AssertEqual(t, update("false", false).Code, http.StatusOK)
+		org, err = store.GetOrganization(context.Background(), "org1")
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, org.RequirePasskey, false)
+	})
+}
+
+func TestRequirePasskeyIfEnforced(t *testing.T) {
+	store := passkeyTestStore(t)
+	testutils.AssertNil(t, store.RequirePasskey(context.Background(), "org1", true))
+	testutils.AssertNil(t, store.RegisterOrganization(context.Background(), &pkg.Organization{Id: "org2", Name: "Choir"}))
+	handler := RequirePasskeyIfEnforced(store, time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(orgId string, passkey bool) (*httptest.ResponseRecorder, *sessions.Session) {
+		req := withAuthSession(httptest.NewRequest("GET", "/", nil), orgId)
+		session := MustGetSession(req)
+		session.Values[sessionPasskeyKey] = passkey
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder, session
+	}
+
+	recorder, _ := serve("org1", false)
+	testutils.AssertEqual(t, recorder.Code, http.StatusForbidden)
+
+	recorder, _ = serve("org1", true)
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+
+	recorder, session := serve("org2", false)
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+	testutils.AssertEqual(t, session.Values[sessionPasskeyCheckedOrgKey], "org2")
+
+	recorder, _ = serve("unknown", false)
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+}
+
+func TestPasskeysOnMainDomain(t *testing.T) {
+	config := pkg.NewDefaultConfig()
+	config.BaseURL = passkeyTestOrigin
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
+	handler := ResolveDomainOrganization(domainStore(t), config)(PasskeysOnMainDomain(config.BaseURL)(next))
+
+	req := httptest.NewRequest("POST", RouteLoginPasskeyBegin, nil)
+	req.Host = "music.example.com"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	testutils.AssertEqual(t, rec.Code, http.StatusBadRequest)
+	testutils.AssertContains(t, rec.Body.String(), "only be used on caesura.no")
+	testutils.AssertEqual(t, called, false)
+
+	req = httptest.NewRequest("POST", RouteLoginPasskeyBegin, nil)
+	req.Host = "caesura.no"
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, called, true)
+}
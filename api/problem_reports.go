@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+type ProblemReporter interface {
+	pkg.MetaByIdGetter
+	pkg.ProblemReportStore
+}
+
+// ReportProblemHandler files a problem with a part of a resource. Any member can report problems, and the
+// reporter is not stored with the report
+func ReportProblemHandler(store ProblemReporter, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 8192)
+		code, err := parseForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		resourceId := r.PathValue("id")
+		meta, err := store.MetaById(ctx, orgId, resourceId)
+		if err == nil && meta.Deleted {
+			err = pkg.ErrResourceMetadataNotFound
+		}
+		if err != nil {
+			http.Error(w, "Could not find the piece", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch metadata of reported piece", "error", err, "orgId", orgId, "resourceId", resourceId)
+			return
+		}
+
+		report := pkg.NewProblemReport(meta, r.FormValue("part"), pkg.ProblemKind(r.FormValue("kind")), r.FormValue("description"))
+		if err := store.SubmitProblemReport(ctx, orgId, report); err != nil {
+			http.Error(w, "Could not report problem: "+err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not store problem report", "error", err, "orgId", orgId, "resourceId", resourceId)
+			return
+		}
+
+		slog.InfoContext(ctx, "Problem reported", "orgId", orgId, "resourceId", resourceId, "reportId", report.Id, "kind", report.Kind)
+		HxTrigger(w, EventProblemReportsUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.problem-reported", nil)
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// ProblemReportsHandler lists the reported problems of the organization. The route is limited to librarians
+func ProblemReportsHandler(store pkg.ProblemReportStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		reports, err := store.ProblemReports(ctx, orgId)
+		if err != nil {
+			http.Error(w, "Could not fetch problem reports", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch problem reports", "error", err, "orgId", orgId)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		web.ProblemReports(w, pkg.LanguageFromReq(r), reports)
+	}
+}
+
+func ProblemReportStatusHandler(store pkg.ProblemReportStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 1024)
+		code, err := parseForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		id := r.PathValue("id")
+		status := pkg.ProblemStatus(r.FormValue("status"))
+		if err := store.SetProblemReportStatus(ctx, orgId, id, status, time.Now()); err != nil {
+			http.Error(w, "Could not update status: "+err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not update status of problem report", "error", err, "orgId", orgId, "reportId", id)
+			return
+		}
+
+		slog.InfoContext(ctx, "Updated status of problem report", "orgId", orgId, "reportId", id, "status", status)
+		HxTrigger(w, EventProblemReportsUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.problem-status-updated", nil)
+		w.WriteHeader(http.StatusOK)
+	}
+}
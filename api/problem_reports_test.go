@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func postProblemReport(store ProblemReporter, orgId, resourceId string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/resources/"+resourceId+"/problems", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("id", resourceId)
+	recorder := httptest.NewRecorder()
+	ReportProblemHandler(store, time.Second)(recorder, withAuthSession(req, orgId))
+	return recorder
+}
+
+func TestReportProblemHandler(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	meta := store.Data[orgId].Metadata[0]
+
+	recorder := postProblemReport(store, orgId, meta.ResourceId(), url.Values{"part": {"Part0.pdf"}, "kind": {"missing-page"}, "description": {"Page 2"}})
+	testutils.AssertEqual(t, recorder.Code, http.StatusCreated)
+	testutils.AssertContains(t, recorder.Header().Get("HX-Trigger"), string(EventProblemReportsUpdated))
+
+	reports, err := store.ProblemReports(context.Background(), orgId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(reports), 1)
+	testutils.AssertEqual(t, reports[0].ResourceTitle, meta.Title)
+	testutils.AssertEqual(t, reports[0].Part, "Part0.pdf")
+	testutils.AssertEqual(t, reports[0].Status, pkg.ProblemOpen)
+
+	t.Run("invalid kind", func(t *testing.T) {
+		recorder := postProblemReport(store, orgId, meta.ResourceId(), url.Values{"kind": {"broken"}})
+		testutils.AssertEqual(t, recorder.Code, http.StatusBadRequest)
+	})
+
+	t.Run("unknown resource", func(t *testing.T) {
+		recorder := postProblemReport(store, orgId, "unknown", url.Values{"kind": {"missing-page"}})
+		testutils.AssertEqual(t, recorder.Code, http.StatusNotFound)
+	})
+
+	t.Run("deleted resource", func(t *testing.T) {
+		store.Data[orgId].Metadata[1].Deleted = true
+		recorder := postProblemReport(store, orgId, store.Data[orgId].Metadata[1].ResourceId(), url.Values{"kind": {"missing-page"}})
+		testutils.AssertEqual(t, recorder.Code, http.StatusNotFound)
+	})
+}
+
+func TestProblemReportsHandler(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	meta := store.Data[orgId].Metadata[0]
+	postProblemReport(store, orgId, meta.ResourceId(), url.Values{"part": {"Part0.pdf"}, "kind": {"wrong-transposition"}})
+	handler := ProblemReportsHandler(store, time.Second)
+
+	t.Run("admin sees reports", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, withAuthSession(httptest.NewRequest("GET", RouteOrganizationsProblems, nil), orgId))
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertContains(t, recorder.Body.String(), meta.Title, "Part0.pdf", "Wrong transposition")
+	})
+
+	t.Run("route is limited to librarians", func(t *testing.T) {
+		for _, test := range []struct {
+			role pkg.RoleKind
+			want int
+		}{
+			{pkg.RoleViewer, http.StatusUnauthorized},
+			{pkg.RoleEditor, http.StatusUnauthorized},
+			{pkg.RoleLibrarian, http.StatusOK},
+		} {
+			recorder := serveWithRole(t, store, orgId, test.role, httptest.NewRequest("GET", RouteOrganizationsProblems, nil))
+			testutils.AssertEqual(t, recorder.Code, test.want)
+		}
+	})
+}
+
+func TestProblemReportStatusHandler(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	postProblemReport(store, orgId, store.Data[orgId].Metadata[0].ResourceId(), url.Values{"kind": {"unreadable"}})
+	reports, err := store.ProblemReports(context.Background(), orgId)
+	testutils.AssertNil(t, err)
+	handler := ProblemReportStatusHandler(store, time.Second)
+
+	for _, test := range []struct {
+		id     string
+		status string
+		want   int
+	}{
+		{reports[0].Id, "resolved", http.StatusOK},
+		{reports[0].Id, "closed", http.StatusBadRequest},
+		{"unknown", "resolved", http.StatusNotFound},
+	} {
+		form := url.Values{"status": {test.status}}
+		req := httptest.NewRequest("PUT", "/organizations/problems/"+test.id+"/status", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("id", test.id)
+		recorder := httptest.NewRecorder()
+		handler(recorder, withAuthSession(req, orgId))
+		testutils.AssertEqual(t, recorder.Code, test.want)
+	}
+
+	reports, err = store.ProblemReports(context.Background(), orgId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, reports[0].Status, pkg.ProblemResolved)
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+// ProjectPdfHandler serves the score, or the part given by the query parameter part, of every piece of the
+// project as one PDF in setlist order, with a bookmark for each piece. Parts outside the groups of the user are
+// left out like in other downloads
+func ProjectPdfHandler(store UserPartsStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		session := MustGetSession(r)
+		orgId := MustGetOrgId(session)
+		projectId := r.PathValue("id")
+		project, err := store.ProjectById(ctx, orgId, projectId)
+		if err != nil {
+			http.Error(w, "Failed to fetch project", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Failed to fetch project", "error", err, "projectId", projectId)
+			return
+		}
+
+		part := r.URL.Query().Get("part")
+		pdfs, err := pkg.ProjectPdfs(ctx, store, orgId, project, part, GroupFilterFromSession(session))
+		if err != nil {
+			http.Error(w, "Could not collect the parts of the project: "+err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not collect the parts of the project", "error", err, "projectId", projectId, "part", part)
+			return
+		}
+
+		// The PDF is combined before anything is written, such that failures are reported with a status code
+		var combined bytes.Buffer
+		if err := pkg.CombinePdfs(&combined, pdfs); err != nil {
+			http.Error(w, "Could not combine the parts of the project", http.StatusInternalServerError)
+			slog.ErrorContext(ctx, "Could not combine the parts of the project", "error", err, "projectId", projectId)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+projectId+".pdf\"")
+		if _, err := combined.WriteTo(w); err != nil {
+			slog.ErrorContext(ctx, "Failed to write project pdf", "error", err, "projectId", projectId)
+			return
+		}
+		recordPartsAccess(ctx, store, orgId, project.ResourceIds)
+		slog.InfoContext(ctx, "Project exported", "projectId", projectId, "numPieces", len(pdfs))
+	}
+}
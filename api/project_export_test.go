@@ -0,0 +1,44 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+func TestProjectPdfHandler(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+RouteProjectsIdExportPdf, ProjectPdfHandler(store, time.Second))
+	serve := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, withAuthSession(httptest.NewRequest("GET", target, nil), orgId))
+		return rec
+	}
+
+	rec := serve("/projects/demoproject1/export.pdf?part=Part2")
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, rec.Header().Get("Content-Type"), "application/pdf")
+	testutils.AssertContains(t, rec.Header().Get("Content-Disposition"), "demoproject1.pdf")
+
+	bookmarks, err := api.Bookmarks(bytes.NewReader(rec.Body.Bytes()), model.NewDefaultConfiguration())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(bookmarks), 2)
+	testutils.AssertEqual(t, bookmarks[0].Title, "Demo Title 1 (Composer A)")
+
+	for target, code := range map[string]int{
+		"/projects/demoproject1/export.pdf": http.StatusNotFound,
+		"/projects/unknown/export.pdf":      http.StatusNotFound,
+	} {
+		testutils.AssertEqual(t, serve(target).Code, code)
+	}
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+// ProjectOrderHandler stores the order of the pieces of a project given by the repeated form field resourceId.
+// Pieces left out of the form keep their order after the listed ones
+func ProjectOrderHandler(store pkg.Transactor, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if code, err := parseForm(r); err != nil {
+			http.Error(w, "Failed to parse form: "+err.Error(), code)
+			return
+		}
+
+		projectId := r.PathValue("id")
+		order := r.Form["resourceId"]
+		if len(order) == 0 {
+			http.Error(w, "The order of the pieces is required", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		project, err := pkg.ReorderProject(ctx, store, orgId, projectId, order)
+		if err != nil {
+			http.Error(w, "Failed to order project: "+err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Failed to order project", "error", err, "projectId", projectId)
+			return
+		}
+		slog.InfoContext(ctx, "Ordered project", "projectId", projectId, "num_resources", len(project.ResourceIds))
+		recordEvent(ctx, newRequestEvent(r, pkg.EventProjectUpdated, orgId, projectId))
+
+		HxTrigger(w, EventProjectUpdated, map[string]string{"projectId": projectId})
+		HxFlash(w, r, FlashSuccess, "flash.project-ordered", map[string]any{"Project": project.Name})
+		writeRest(w, http.StatusOK, pkg.NewRestProject(project))
+	}
+}
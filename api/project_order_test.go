@@ -0,0 +1,74 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestProjectOrderHandler(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	projectId := "demoproject1"
+	resourceIds := store.Data[orgId].Projects[projectId].ResourceIds
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT "+RouteProjectsIdOrder, ProjectOrderHandler(store, time.Second))
+	serve := func(projectId string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/projects/"+projectId+"/order", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, withAuthSession(req, orgId))
+		return rec
+	}
+
+	rec := serve(projectId, url.Values{"resourceId": {resourceIds[1], resourceIds[0]}})
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), string(EventProjectUpdated))
+	testutils.AssertEqual(t, strings.Join(store.Data[orgId].Projects[projectId].ResourceIds, ","), resourceIds[1]+","+resourceIds[0])
+
+	for _, test := range []struct {
+		desc      string
+		projectId string
+		form      url.Values
+		code      int
+	}{
+		{desc: "no order", projectId: projectId, form: url.Values{}, code: http.StatusBadRequest},
+		{desc: "piece outside the project", projectId: projectId, form: url.Values{"resourceId": {"unknown"}}, code: http.StatusBadRequest},
+		{desc: "unknown project", projectId: "unknown", form: url.Values{"resourceId": {resourceIds[0]}}, code: http.StatusNotFound},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			testutils.AssertEqual(t, serve(test.projectId, test.form).Code, test.code)
+		})
+	}
+}
+
+func TestDownloadUserPartsFollowsProjectOrder(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	projectId := "demoproject1"
+	first, second := store.Data[orgId].Metadata[0].ResourceId(), store.Data[orgId].Metadata[1].ResourceId()
+	_, err := pkg.ReorderProject(context.Background(), store, orgId, projectId, []string{second, first})
+	testutils.AssertNil(t, err)
+
+	form := url.Values{"resourceId": {first, second}, "projectId": {projectId}}
+	req := httptest.NewRequest("POST", "/download", bytes.NewBufferString(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	DownloadUserParts(store, pkg.NewDefaultConfig())(rec, withAuthSession(req, orgId))
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+
+	result, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.HasPrefix(result.File[0].Name, "1_"+second+"_"), true)
+	testutils.AssertEqual(t, strings.HasPrefix(result.File[len(result.File)-1].Name, "2_"+first+"_"), true)
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+type ProjectRenameStore interface {
+	pkg.Transactor
+	pkg.GuestGrantStore
+}
+
+// moveGuestGrants moves the guest access of a project that no longer exists. The project has already been changed,
+// so a failure is only logged
+func moveGuestGrants(ctx context.Context, store pkg.GuestGrantStore, orgId, fromProjectId, toProjectId string) {
+	num, err := pkg.MoveGuestGrants(ctx, store, orgId, fromProjectId, toProjectId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to move guest access", "error", err, "from", fromProjectId, "to", toProjectId)
+		return
+	}
+	if num > 0 {
+		slog.InfoContext(ctx, "Moved guest access", "from", fromProjectId, "to", toProjectId, "num", num)
+	}
+}
+
+// RenameProjectHandler gives the project the name in the form. The response is the renamed project, whose id
+// changes with the name
+func RenameProjectHandler(store ProjectRenameStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if code, err := parseForm(r); err != nil {
+			http.Error(w, "Failed to parse form: "+err.Error(), code)
+			return
+		}
+
+		projectId := r.PathValue("id")
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		project, err := pkg.RenameProject(ctx, store, orgId, projectId, r.FormValue("name"))
+		if err != nil {
+			http.Error(w, "Failed to rename project: "+err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Failed to rename project", "error", err, "projectId", projectId)
+			return
+		}
+		if project.Id() != projectId {
+			moveGuestGrants(ctx, store, orgId, projectId, project.Id())
+		}
+		slog.InfoContext(ctx, "Renamed project", "from", projectId, "to", project.Id())
+		recordEvent(ctx, newRequestEvent(r, pkg.EventProjectUpdated, orgId, project.Id()))
+
+		HxTrigger(w, EventProjectUpdated, map[string]string{"projectId": project.Id()})
+		HxFlash(w, r, FlashSuccess, "flash.project-renamed", map[string]any{"Project": project.Name})
+		writeRest(w, http.StatusOK, pkg.NewRestProject(project))
+	}
+}
+
+// MergeProjectHandler folds the project given by source in the form into the project in the path and removes
+// it. The source may be given by its name or its id
+func MergeProjectHandler(store ProjectRenameStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if code, err := parseForm(r); err != nil {
+			http.Error(w, "Failed to parse form: "+err.Error(), code)
+			return
+		}
+
+		targetId := r.PathValue("id")
+		source := pkg.Project{Name: r.FormValue("source")}
+		if source.Id() == "" {
+			http.Error(w, "The project to merge in is required", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		project, err := pkg.MergeProjects(ctx, store, orgId, targetId, source.Id())
+		if err != nil {
+			http.Error(w, "Failed to merge projects: "+err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Failed to merge projects", "error", err, "target", targetId, "source", source.Id())
+			return
+		}
+		moveGuestGrants(ctx, store, orgId, source.Id(), targetId)
+		slog.InfoContext(ctx, "Merged projects", "target", targetId, "source", source.Id(), "num_resources", len(project.ResourceIds))
+		recordEvent(ctx, newRequestEvent(r, pkg.EventProjectUpdated, orgId, targetId))
+
+		HxTrigger(w, EventProjectUpdated, map[string]string{"projectId": targetId})
+		HxFlash(w, r, FlashSuccess, "flash.projects-merged", map[string]any{"Project": project.Name})
+		writeRest(w, http.StatusOK, pkg.NewRestProject(project))
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func projectRenameStore(t *testing.T) *pkg.MultiOrgInMemoryStore {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &pkg.Organization{Id: "org"}))
+	testutils.AssertNil(t, store.SubmitProject(ctx, "org", &pkg.Project{Name: "Spring concert", ResourceIds: []string{"polka", "march"}}))
+	testutils.AssertNil(t, store.SubmitProject(ctx, "org", &pkg.Project{Name: "Autumn concert", ResourceIds: []string{"march", "waltz"}}))
+	return store
+}
+
+func serveProjectRename(store ProjectRenameStore, method, path string, form url.Values) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("PATCH "+RouteProjectsId, RenameProjectHandler(store, time.Second))
+	mux.HandleFunc("POST "+RouteProjectsIdMerge, MergeProjectHandler(store, time.Second))
+
+	req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, withAuthSession(req, "org"))
+	return rec
+}
+
+func TestRenameProjectHandler(t *testing.T) {
+	store := projectRenameStore(t)
+	ctx := context.Background()
+	testutils.AssertNil(t, store.SaveGuestGrant(ctx, pkg.NewGuestGrant("org", "user", "jury@example.com", nil, "springconcert", time.Now().Add(time.Hour), "")))
+
+	rec := serveProjectRename(store, "PATCH", "/projects/springconcert", url.Values{"name": {"Summer concert"}})
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), string(EventProjectUpdated), "summerconcert")
+
+	var project pkg.RestProject
+	testutils.AssertNil(t, json.Unmarshal(rec.Body.Bytes(), &project))
+	testutils.AssertEqual(t, project.Id, "summerconcert")
+	testutils.AssertEqual(t, project.Name, "Summer concert")
+
+	_, err := store.ProjectById(ctx, "org", "springconcert")
+	testutils.AssertEqual(t, err != nil, true)
+	grants, err := store.GuestGrants(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, grants[0].ProjectId, "summerconcert")
+
+	t.Run("existing name", func(t *testing.T) {
+		rec := serveProjectRename(store, "PATCH", "/projects/summerconcert", url.Values{"name": {"Autumn concert"}})
+		testutils.AssertEqual(t, rec.Code, http.StatusConflict)
+	})
+
+	t.Run("invalid name", func(t *testing.T) {
+		rec := serveProjectRename(store, "PATCH", "/projects/summerconcert", url.Values{"name": {"!!"}})
+		testutils.AssertEqual(t, rec.Code, http.StatusBadRequest)
+	})
+
+	t.Run("unknown project", func(t *testing.T) {
+		rec := serveProjectRename(store, "PATCH", "/projects/springconcert", url.Values{"name": {"Winter concert"}})
+		testutils.AssertEqual(t, rec.Code, http.StatusNotFound)
+	})
+}
+
+func TestMergeProjectHandler(t *testing.T) {
+	store := projectRenameStore(t)
+	ctx := context.Background()
+
+	rec := serveProjectRename(store, "POST", "/projects/springconcert/merge", url.Values{"source": {"Autumn concert"}})
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), string(EventProjectUpdated))
+
+	project, err := store.ProjectById(ctx, "org", "springconcert")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(project.ResourceIds, ","), "polka,march,waltz")
+	_, err = store.ProjectById(ctx, "org", "autumnconcert")
+	testutils.AssertEqual(t, err != nil, true)
+
+	t.Run("missing source", func(t *testing.T) {
+		rec := serveProjectRename(store, "POST", "/projects/springconcert/merge", url.Values{})
+		testutils.AssertEqual(t, rec.Code, http.StatusBadRequest)
+	})
+
+	t.Run("into itself", func(t *testing.T) {
+		rec := serveProjectRename(store, "POST", "/projects/springconcert/merge", url.Values{"source": {"springconcert"}})
+		testutils.AssertEqual(t, rec.Code, http.StatusBadRequest)
+	})
+}
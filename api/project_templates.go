@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+// ProjectTemplatesHandler lists the project templates of the organization together with the form for adding
+// new ones. Only admins see the templates
+func ProjectTemplatesHandler(store pkg.ProjectTemplateStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := MustGetSession(r)
+		orgId := MustGetOrgId(session)
+		if !MustGetUserInfo(session).Roles[orgId].AtLeast(pkg.RoleAdmin) {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		templates, err := store.ProjectTemplates(ctx, orgId)
+		if err != nil {
+			http.Error(w, "Could not fetch project templates", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch project templates", "error", err, "orgId", orgId)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		web.ProjectTemplates(w, pkg.LanguageFromReq(r), templates)
+	}
+}
+
+// ProjectTemplateOptionsHandler renders the choice of template when pieces are added to a project
+func ProjectTemplateOptionsHandler(store pkg.ProjectTemplateStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		templates, err := store.ProjectTemplates(ctx, orgId)
+		if err != nil {
+			http.Error(w, "Could not fetch project templates", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch project templates", "error", err, "orgId", orgId)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		web.ProjectTemplateOptions(w, pkg.LanguageFromReq(r), templates)
+	}
+}
+
+// SubmitProjectTemplateHandler stores a template. A template with the same name is replaced. Categories and
+// groups are given as comma separated lists
+func SubmitProjectTemplateHandler(store pkg.ProjectTemplateStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 32768)
+		code, err := parseForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		template := pkg.ProjectTemplate{
+			Name:         strings.TrimSpace(r.FormValue("name")),
+			Categories:   pkg.ParseTemplateList(r.FormValue("categories")),
+			Announcement: strings.TrimSpace(r.FormValue("announcement")),
+			Groups:       pkg.ParseTemplateList(r.FormValue("groups")),
+			UpdatedAt:    time.Now(),
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		if err := store.SubmitProjectTemplate(ctx, orgId, &template); err != nil {
+			http.Error(w, "Could not save project template: "+err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not save project template", "error", err, "orgId", orgId)
+			return
+		}
+
+		slog.InfoContext(ctx, "Saved project template", "orgId", orgId, "templateId", template.Id())
+		HxTrigger(w, EventProjectTemplatesUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.project-template-saved", map[string]any{"Name": template.Name})
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func DeleteProjectTemplateHandler(store pkg.ProjectTemplateStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		id := r.PathValue("id")
+		if err := store.DeleteProjectTemplate(ctx, orgId, id); err != nil {
+			http.Error(w, "Could not delete project template", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not delete project template", "error", err, "orgId", orgId, "templateId", id)
+			return
+		}
+
+		HxTrigger(w, EventProjectTemplatesUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.project-template-deleted", nil)
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func postProjectTemplate(store pkg.ProjectTemplateStore, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", RouteOrganizationsProjectTemplates, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	SubmitProjectTemplateHandler(store, time.Second)(recorder, withAuthSession(req, "org"))
+	return recorder
+}
+
+func christmasTemplateForm() url.Values {
+	return url.Values{
+		"name":         {"Christmas Concert"},
+		"categories":   {"Carols, Marches, , Carols"},
+		"groups":       {"Trumpet,Horn"},
+		"announcement": {"Welcome to our Christmas concert!"},
+	}
+}
+
+func TestSubmitProjectTemplateHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	recorder := postProjectTemplate(store, christmasTemplateForm())
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+	testutils.AssertContains(t, recorder.Header().Get("HX-Trigger"), string(EventProjectTemplatesUpdated))
+
+	template, err := store.ProjectTemplateById(context.Background(), "org", "christmasconcert")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(template.Categories, ";"), "Carols;Marches")
+	testutils.AssertEqual(t, strings.Join(template.Groups, ";"), "Trumpet;Horn")
+	testutils.AssertEqual(t, template.Announcement, "Welcome to our Christmas concert!")
+
+	recorder = postProjectTemplate(store, url.Values{"name": {"!!"}})
+	testutils.AssertEqual(t, recorder.Code, http.StatusBadRequest)
+}
+
+func TestProjectTemplatesHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	postProjectTemplate(store, christmasTemplateForm())
+	handler := ProjectTemplatesHandler(store, time.Second)
+
+	t.Run("admin sees templates", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, withAuthSession(httptest.NewRequest("GET", RouteOrganizationsProjectTemplates, nil), "org"))
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertContains(t, recorder.Body.String(), "Christmas Concert", "Carols, Marches", "/organizations/project-templates/christmasconcert")
+	})
+
+	t.Run("non-admin sees nothing", func(t *testing.T) {
+		req := withAuthSession(httptest.NewRequest("GET", RouteOrganizationsProjectTemplates, nil), "org")
+		MustGetSession(req).Values["role"], _ = json.Marshal(pkg.UserInfo{Roles: map[string]pkg.RoleKind{"org": pkg.RoleViewer}})
+		recorder := httptest.NewRecorder()
+		handler(recorder, req)
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertEqual(t, recorder.Body.Len(), 0)
+	})
+}
+
+func TestProjectTemplateOptionsHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	handler := ProjectTemplateOptionsHandler(store, time.Second)
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, withAuthSession(httptest.NewRequest("GET", RouteProjectsTemplatesOptions, nil), "org"))
+	testutils.AssertNotContains(t, recorder.Body.String(), "<select")
+
+	postProjectTemplate(store, christmasTemplateForm())
+	recorder = httptest.NewRecorder()
+	handler(recorder, withAuthSession(httptest.NewRequest("GET", RouteProjectsTemplatesOptions, nil), "org"))
+	testutils.AssertContains(t, recorder.Body.String(), `name="templateId"`, `value="christmasconcert"`)
+}
+
+func TestDeleteProjectTemplateHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	postProjectTemplate(store, christmasTemplateForm())
+	handler := DeleteProjectTemplateHandler(store, time.Second)
+
+	for _, want := range []int{http.StatusOK, http.StatusNotFound} {
+		req := httptest.NewRequest("DELETE", "/organizations/project-templates/christmasconcert", nil)
+		req.SetPathValue("id", "christmasconcert")
+		recorder := httptest.NewRecorder()
+		handler(recorder, withAuthSession(req, "org"))
+		testutils.AssertEqual(t, recorder.Code, want)
+	}
+}
+
+func TestProjectSubmitHandlerWithTemplate(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.RegisterOrganization(context.Background(), &pkg.Organization{Id: "org"}))
+	postProjectTemplate(store, christmasTemplateForm())
+	postProjectTemplate(store, url.Values{"name": {"Spring"}, "groups": {"Flute"}})
+
+	submit := func(templateId string) int {
+		form := url.Values{"projectQuery": {"Christmas 2026"}, "templateId": {templateId}}
+		req := httptest.NewRequest("POST", RouteProjects, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		ProjectSubmitHandler(store, time.Second)(recorder, withAuthSession(req, "org"))
+		return recorder.Code
+	}
+
+	testutils.AssertEqual(t, submit("unknown"), http.StatusNotFound)
+	testutils.AssertEqual(t, submit("christmasconcert"), http.StatusOK)
+
+	// The defaults of existing projects are kept
+	testutils.AssertEqual(t, submit("spring"), http.StatusOK)
+
+	project, err := store.ProjectById(context.Background(), "org", "christmas2026")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, project.TemplateId, "christmasconcert")
+	testutils.AssertEqual(t, strings.Join(project.Groups, ";"), "Trumpet;Horn")
+	testutils.AssertEqual(t, project.Announcement, "Welcome to our Christmas concert!")
+}
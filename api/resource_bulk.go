@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+type BulkResourcesResponse struct {
+	Results []pkg.BulkResult `json:"results"`
+}
+
+type BulkResourceStore interface {
+	pkg.BulkStore
+	pkg.AuditLogger
+}
+
+// BulkResourcesHandler applies one action to a list of resources. Each resource is handled separately and the
+// outcome of each is reported in the response. Every deleted resource is written to the audit log, as when
+// resources are deleted one at a time
+func BulkResourcesHandler(store BulkResourceStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+		var request pkg.BulkRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Could not decode request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := request.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		results := pkg.ApplyBulk(ctx, store, orgId, &request)
+
+		userId, _ := r.Context().Value(pkg.UserIdKey).(string)
+		numOk := 0
+		for _, result := range results {
+			if !result.Ok {
+				continue
+			}
+			numOk++
+			if request.Action == pkg.BulkDelete {
+				appendAudit(ctx, store, orgId, pkg.NewAuditEntry(pkg.AuditResourceDeleted, userId, result.ResourceId, "bulk", getIp(r)))
+			}
+		}
+		slog.InfoContext(ctx, "Applied bulk action", "action", request.Action, "num", len(results), "num-ok", numOk)
+
+		level := FlashSuccess
+		if numOk < len(results) {
+			level = FlashWarning
+		}
+		HxTrigger(w, EventMetadataUpdated, nil)
+		if request.Action == pkg.BulkAddToProject && numOk > 0 {
+			HxTrigger(w, EventProjectUpdated, nil)
+		}
+		HxFlash(w, r, level, "flash.bulk-applied", struct{ Updated, Total int }{numOk, len(results)})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(BulkResourcesResponse{Results: results})
+	}
+}
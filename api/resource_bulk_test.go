@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func bulkResourcesRequest(orgId string, body []byte) *http.Request {
+	req := httptest.NewRequest("POST", RouteResourcesBulk, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return withAuthSession(req, orgId)
+}
+
+func TestBulkResourcesHandler(t *testing.T) {
+	store := pkg.NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	ids := demoResourceIds(t, store)
+	handler := BulkResourcesHandler(store, time.Second)
+
+	t.Run("delete", func(t *testing.T) {
+		body, err := json.Marshal(pkg.BulkRequest{Action: pkg.BulkDelete, ResourceIds: []string{ids[0], "unknown"}})
+		testutils.AssertNil(t, err)
+
+		rec := httptest.NewRecorder()
+		handler(rec, bulkResourcesRequest(orgId, body))
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+
+		var resp BulkResourcesResponse
+		testutils.AssertNil(t, json.NewDecoder(rec.Body).Decode(&resp))
+		testutils.AssertEqual(t, len(resp.Results), 2)
+		testutils.AssertEqual(t, resp.Results[0].Ok, true)
+		testutils.AssertEqual(t, resp.Results[1].Ok, false)
+		testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), string(EventMetadataUpdated), "Applied to 1 of 2 pieces", `"warning"`)
+
+		meta, err := store.MetaById(context.Background(), orgId, ids[0])
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, meta.Deleted, true)
+
+		entries, err := store.AuditLog(context.Background(), orgId, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, len(entries), 1)
+		testutils.AssertEqual(t, entries[0].Action, pkg.AuditResourceDeleted)
+		testutils.AssertEqual(t, entries[0].TargetId, ids[0])
+	})
+
+	t.Run("add to project", func(t *testing.T) {
+		body, err := json.Marshal(pkg.BulkRequest{Action: pkg.BulkAddToProject, ResourceIds: ids[1:], Project: "Cleanup"})
+		testutils.AssertNil(t, err)
+
+		rec := httptest.NewRecorder()
+		handler(rec, bulkResourcesRequest(orgId, body))
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+		testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), string(EventProjectUpdated), `"success"`)
+
+		project, err := store.ProjectById(context.Background(), orgId, "cleanup")
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, len(project.ResourceIds), len(ids)-1)
+	})
+
+	for _, test := range []struct {
+		desc string
+		body []byte
+		want int
+	}{
+		{"invalid json", []byte("not json"), http.StatusBadRequest},
+		{"unknown action", []byte(`{"action": "archive", "resourceIds": ["a"]}`), http.StatusBadRequest},
+		{"no resources", []byte(`{"action": "delete", "resourceIds": []}`), http.StatusBadRequest},
+		{"too large", []byte(`{"action": "delete", "resourceIds": ["` + strings.Repeat("a", 1<<20) + `"]}`), http.StatusRequestEntityTooLarge},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler(rec, bulkResourcesRequest(orgId, test.body))
+			testutils.AssertEqual(t, rec.Code, test.want)
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/sessions"
+)
+
+const (
+	accessTokenValidity = time.Hour
+	accessTokenAudience = "caesura-api"
+
+	// Sessions signed in with an access token have a token id with this prefix
+	accessTokenIdPrefix = "access-"
+)
+
+// AccessClaim lets apps call the JSON API on behalf of a user in one organization. Role is the highest role the
+// token acts with, and the role of the user is read from the store on every request, so removing the user from
+// the organization revokes the token
+type AccessClaim struct {
+	UserId  string       `json:"user_id"`
+	OrgId   string       `json:"org_id"`
+	Role    pkg.RoleKind `json:"role"`
+	Passkey bool         `json:"passkey"`
+	jwt.RegisteredClaims
+}
+
+func SignedAccessToken(claims AccessClaim, signSecret string, validity time.Duration) (string, error) {
+	currentTime := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        pkg.RandomInsecureID(),
+		Audience:  jwt.ClaimStrings{accessTokenAudience},
+		ExpiresAt: jwt.NewNumericDate(currentTime.Add(validity)),
+		IssuedAt:  jwt.NewNumericDate(currentTime),
+		NotBefore: jwt.NewNumericDate(currentTime),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(signSecret))
+}
+
+// accessTokenSession returns a session acting as the user of the access token. Other tokens signed with the same
+// secret, such as WebDAV tokens, are rejected since they are issued for another audience
+func accessTokenSession(ctx context.Context, store pkg.RoleGetter, token, signSecret string) (*sessions.Session, int, error) {
+	var claims AccessClaim
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		return []byte(signSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(accessTokenAudience))
+	if err != nil {
+		return nil, http.StatusUnauthorized, errors.New("Invalid access token")
+	}
+	if claims.UserId == "" || claims.OrgId == "" {
+		return nil, http.StatusUnauthorized, errors.New("Access token does not contain user and organization")
+	}
+	return scopedSession(ctx, store, claims.UserId, claims.OrgId, claims.Role, claims.Passkey, accessTokenIdPrefix+claims.ID)
+}
+
+type AccessTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func writeRest(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeRestError(ctx context.Context, w http.ResponseWriter, msg string, err error) {
+	code := StoreErrorCode(err)
+	if code >= http.StatusInternalServerError {
+		slog.ErrorContext(ctx, msg, "error", err)
+	}
+	writeRest(w, code, map[string]string{"error": msg})
+}
+
+// AccessTokenHandler issues a short lived access token for the user and organization of the session. Apps sign in
+// once with a session or an API token and send the access token as "Authorization: Bearer" header. A new access
+// token can not be issued with an access token, such that access ends when the token expires
+func AccessTokenHandler(signSecret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := MustGetSession(r)
+		if tokenId, _ := session.Values[sessionApiTokenKey].(string); strings.HasPrefix(tokenId, accessTokenIdPrefix) {
+			writeRest(w, http.StatusForbidden, map[string]string{"error": "An access token can not issue new access tokens"})
+			return
+		}
+
+		userInfo := MustGetUserInfo(session)
+		orgId := MustGetOrgId(session)
+		passkey, _ := session.Values[sessionPasskeyKey].(bool)
+		claims := AccessClaim{UserId: userInfo.Id, OrgId: orgId, Role: userInfo.Roles[orgId], Passkey: passkey}
+		token, err := SignedAccessToken(claims, signSecret, accessTokenValidity)
+		if err != nil {
+			writeRest(w, http.StatusInternalServerError, map[string]string{"error": "Failed to sign token"})
+			slog.ErrorContext(r.Context(), "Failed to sign access token", "error", err)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Issued access token", "userId", userInfo.Id, "orgId", orgId)
+		writeRest(w, http.StatusOK, AccessTokenResponse{
+			AccessToken: token,
+			TokenType:   "Bearer",
+			ExpiresIn:   int(accessTokenValidity.Seconds()),
+		})
+	}
+}
+
+// RestResourcesHandler lists the resources whose title, composer or arranger start with the query parameter q
+func RestResourcesHandler(store pkg.MetaByPatternFetcher, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		query := r.URL.Query().Get("q")
+		orgId := MustGetOrgId(MustGetSession(r))
+		metaData, err := store.MetaByPattern(ctx, orgId, &pkg.MetaData{Title: query, Composer: query, Arranger: query})
+		if err != nil {
+			writeRestError(ctx, w, "Failed to fetch resources", err)
+			return
+		}
+		writeRest(w, http.StatusOK, pkg.RestResourceList{Items: pkg.NewRestResources(metaData)})
+	}
+}
+
+func RestResourceHandler(store pkg.MetaByIdGetter, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		meta, err := store.MetaById(ctx, orgId, r.PathValue("id"))
+		if err == nil && meta.Deleted {
+			err = pkg.ErrResourceMetadataNotFound
+		}
+		if err != nil {
+			writeRestError(ctx, w, "Failed to fetch resource", err)
+			return
+		}
+		writeRest(w, http.StatusOK, pkg.NewRestResource(meta))
+	}
+}
+
+// RestProjectsHandler lists the projects whose name starts with the query parameter q
+func RestProjectsHandler(store pkg.ProjectByNameGetter, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		projects, err := store.ProjectsByName(ctx, orgId, r.URL.Query().Get("q"))
+		if err != nil {
+			writeRestError(ctx, w, "Failed to fetch projects", err)
+			return
+		}
+
+		items := make([]pkg.RestProject, len(projects))
+		for i, project := range projects {
+			items[i] = pkg.NewRestProject(&project)
+		}
+		slices.SortFunc(items, func(a, b pkg.RestProject) int { return strings.Compare(a.Id, b.Id) })
+		writeRest(w, http.StatusOK, pkg.RestProjectList{Items: items})
+	}
+}
+
+// RestProjectHandler returns the project with its resources
+func RestProjectHandler(store pkg.ProjectMetaByIdGetter, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		project, err := store.ProjectById(ctx, orgId, r.PathValue("id"))
+		if err != nil {
+			writeRestError(ctx, w, "Failed to fetch project", err)
+			return
+		}
+
+		result := pkg.NewRestProject(project)
+		metaData := make([]pkg.MetaData, 0, len(project.ResourceIds))
+		for _, lookup := range store.MetaByIds(ctx, orgId, project.ResourceIds) {
+			if lookup.Err != nil && !pkg.IsNotFound(lookup.Err) {
+				writeRestError(ctx, w, "Failed to fetch resources of project", lookup.Err)
+				return
+			} else if lookup.Err == nil {
+				metaData = append(metaData, *lookup.Meta)
+			}
+		}
+		result.Resources = pkg.NewRestResources(metaData)
+		writeRest(w, http.StatusOK, result)
+	}
+}
+
+// RestUsersHandler lists the members of the organization. Members that are not admins only get themselves
+func RestUsersHandler(store pkg.UserGetter, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		session := MustGetSession(r)
+		orgId := MustGetOrgId(session)
+		userInfo := MustGetUserInfo(session)
+
+		var users []pkg.UserInfo
+		if userInfo.Roles[orgId].AtLeast(pkg.RoleAdmin) {
+			members, err := store.GetUsersInOrg(ctx, orgId)
+			if err != nil {
+				writeRestError(ctx, w, "Failed to fetch users", err)
+				return
+			}
+			users = members
+		} else {
+			user, err := store.GetUserInfo(ctx, userInfo.Id)
+			if err != nil {
+				writeRestError(ctx, w, "Failed to fetch user", err)
+				return
+			}
+			users = []pkg.UserInfo{*user}
+		}
+
+		items := make([]pkg.RestUser, len(users))
+		for i, user := range users {
+			items[i] = pkg.NewRestUser(&user, orgId)
+		}
+		writeRest(w, http.StatusOK, pkg.RestUserList{Items: items})
+	}
+}
+
+// RestOrganizationsHandler lists the organizations the session has access to. Sessions signed in with a token
+// only have access to the organization of the token
+func RestOrganizationsHandler(store pkg.OrganizationGetter, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		userInfo := MustGetUserInfo(MustGetSession(r))
+		items := []pkg.RestOrganization{}
+		for _, orgId := range slices.Sorted(maps.Keys(userInfo.Roles)) {
+			org, err := store.GetOrganization(ctx, orgId)
+			if errors.Is(err, pkg.ErrOrganizationNotFound) || (err == nil && org.Deleted) {
+				continue
+			} else if err != nil {
+				writeRestError(ctx, w, "Failed to fetch organizations", err)
+				return
+			}
+			items = append(items, pkg.RestOrganization{Id: orgId, Name: org.Name, Role: pkg.RestRoleName(userInfo.Roles[orgId])})
+		}
+		writeRest(w, http.StatusOK, pkg.RestOrganizationList{Items: items})
+	}
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/gorilla/sessions"
+)
+
+func TestAccessTokenMiddleware(t *testing.T) {
+	store := apiTokenTestStore(t, pkg.RoleAdmin)
+	config := pkg.NewDefaultConfig()
+	config.RequireSubscription = false
+	config.CookieSecretSignKey = "sign-secret"
+	cookie := sessions.NewCookieStore([]byte("key"))
+	opts := &sessions.Options{}
+
+	readRoute := RequireRead(store, config, cookie, opts)
+	writeRoute := RequireWrite(store, config, cookie, opts)
+	issue := readRoute(AccessTokenHandler(config.CookieSecretSignKey))
+
+	var seen *sessions.Session
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { seen = MustGetSession(r) })
+	serve := func(route http.Handler, method, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, RouteApiToken, nil)
+		req.Header.Set("Authorization", authorization)
+		recorder := httptest.NewRecorder()
+		route.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	recorder := serve(issue, "POST", "Bearer "+mustCreateApiToken(t, store, pkg.ApiTokenRead, "30"))
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+	var response AccessTokenResponse
+	testutils.AssertNil(t, json.NewDecoder(recorder.Body).Decode(&response))
+	testutils.AssertEqual(t, response.TokenType, "Bearer")
+	testutils.AssertEqual(t, response.ExpiresIn, 3600)
+
+	t.Run("access token can read", func(t *testing.T) {
+		testutils.AssertEqual(t, serve(readRoute(handler), "GET", "Bearer "+response.AccessToken).Code, http.StatusOK)
+		testutils.AssertEqual(t, MustGetUserId(seen), "0000-0000")
+		testutils.AssertEqual(t, MustGetOrgId(seen), "org1")
+	})
+
+	t.Run("access token keeps the scope of the api token", func(t *testing.T) {
+		testutils.AssertEqual(t, serve(writeRoute(handler), "GET", "Bearer "+response.AccessToken).Code, http.StatusUnauthorized)
+	})
+
+	t.Run("access token can not issue access tokens", func(t *testing.T) {
+		testutils.AssertEqual(t, serve(issue, "POST", "Bearer "+response.AccessToken).Code, http.StatusForbidden)
+	})
+
+	t.Run("access token can not issue WebDAV tokens", func(t *testing.T) {
+		issueWebDAV := readRoute(WebDAVTokenHandler(config.BaseURL, config.CookieSecretSignKey))
+		testutils.AssertEqual(t, serve(issueWebDAV, "GET", "Bearer "+response.AccessToken).Code, http.StatusForbidden)
+	})
+
+	t.Run("other tokens are rejected", func(t *testing.T) {
+		webDAVToken, err := SignedWebDAVToken(WebDAVClaim{UserId: "0000-0000", OrgId: "org1"}, config.CookieSecretSignKey, time.Hour)
+		testutils.AssertNil(t, err)
+		otherSecret, err := SignedAccessToken(AccessClaim{UserId: "0000-0000", OrgId: "org1"}, "other-secret", time.Hour)
+		testutils.AssertNil(t, err)
+		expired, err := SignedAccessToken(AccessClaim{UserId: "0000-0000", OrgId: "org1"}, config.CookieSecretSignKey, -time.Minute)
+		testutils.AssertNil(t, err)
+
+		for _, token := range []string{webDAVToken, otherSecret, expired, "not-a-token"} {
+			recorder := serve(readRoute(handler), "GET", "Bearer "+token)
+			testutils.AssertEqual(t, recorder.Code, http.StatusUnauthorized)
+			testutils.AssertContains(t, recorder.Header().Get("WWW-Authenticate"), "Bearer")
+		}
+	})
+
+	t.Run("removed from organization", func(t *testing.T) {
+		testutils.AssertNil(t, store.DeleteRole(context.Background(), "0000-0000", "org1"))
+		testutils.AssertEqual(t, serve(readRoute(handler), "GET", "Bearer "+response.AccessToken).Code, http.StatusUnauthorized)
+	})
+}
+
+func restTestStore(t *testing.T) *pkg.MultiOrgInMemoryStore {
+	store := apiTokenTestStore(t, pkg.RoleAdmin)
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterUser(ctx, &pkg.UserInfo{Id: "0000-0001", Name: "Ola"}))
+	testutils.AssertNil(t, store.RegisterRole(ctx, "0000-0001", "org1", pkg.RoleViewer))
+
+	for _, meta := range []pkg.MetaData{
+		{Title: "Bolero", Composer: "Ravel", Duration: pkg.Duration(15 * time.Minute)},
+		{Title: "Bolero Trash", Composer: "Ravel"},
+	} {
+		testutils.AssertNil(t, store.Submit(ctx, "org1", &meta, func(yield func(string, []byte) bool) {}))
+	}
+	testutils.AssertNil(t, store.DeleteResource(ctx, "org1", "bolerotrash_ravel"))
+	testutils.AssertNil(t, store.SubmitProject(ctx, "org1", &pkg.Project{Name: "Spring concert", ResourceIds: []string{"bolero_ravel", "bolerotrash_ravel"}}))
+	return store
+}
+
+func serveRest[T any](t *testing.T, handler http.HandlerFunc, pattern, target string, role pkg.RoleKind, code int) T {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+pattern, handler)
+	req := withAuthSession(httptest.NewRequest("GET", target, nil), "org1")
+	if role != pkg.RoleAdmin {
+		session := MustGetSession(req)
+		session.Values["role"], _ = json.Marshal(pkg.UserInfo{Id: "0000-0001", Roles: map[string]pkg.RoleKind{"org1": role}})
+	}
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, req)
+	testutils.AssertEqual(t, recorder.Code, code)
+	testutils.AssertEqual(t, recorder.Header().Get("Content-Type"), "application/json")
+
+	var body T
+	testutils.AssertNil(t, json.NewDecoder(recorder.Body).Decode(&body))
+	return body
+}
+
+func TestRestResources(t *testing.T) {
+	store := restTestStore(t)
+	handler := RestResourcesHandler(store, time.Second)
+	resources := serveRest[pkg.RestResourceList](t, handler, RouteApiResources, "/api/v1/resources?q=bol", pkg.RoleAdmin, http.StatusOK)
+	testutils.AssertEqual(t, len(resources.Items), 1)
+	testutils.AssertEqual(t, resources.Items[0].Id, "bolero_ravel")
+	testutils.AssertEqual(t, resources.Items[0].DurationSeconds, 900)
+
+	resources = serveRest[pkg.RestResourceList](t, handler, RouteApiResources, "/api/v1/resources?q=mozart", pkg.RoleAdmin, http.StatusOK)
+	testutils.AssertEqual(t, len(resources.Items), 0)
+
+	handler = RestResourceHandler(store, time.Second)
+	resource := serveRest[pkg.RestResource](t, handler, RouteApiResourcesId, "/api/v1/resources/bolero_ravel", pkg.RoleAdmin, http.StatusOK)
+	testutils.AssertEqual(t, resource.Composer, "Ravel")
+
+	for _, id := range []string{"bolerotrash_ravel", "unknown"} {
+		errBody := serveRest[map[string]string](t, handler, RouteApiResourcesId, "/api/v1/resources/"+id, pkg.RoleAdmin, http.StatusNotFound)
+		testutils.AssertEqual(t, errBody["error"], "Failed to fetch resource")
+	}
+}
+
+func TestRestProjects(t *testing.T) {
+	store := restTestStore(t)
+	projects := serveRest[pkg.RestProjectList](t, RestProjectsHandler(store, time.Second), RouteApiProjects, "/api/v1/projects?q=spring", pkg.RoleAdmin, http.StatusOK)
+	testutils.AssertEqual(t, len(projects.Items), 1)
+	testutils.AssertEqual(t, len(projects.Items[0].ResourceIds), 2)
+	testutils.AssertEqual(t, len(projects.Items[0].Resources), 0)
+
+	handler := RestProjectHandler(store, time.Second)
+	project := serveRest[pkg.RestProject](t, handler, RouteApiProjectsId, "/api/v1/projects/"+projects.Items[0].Id, pkg.RoleAdmin, http.StatusOK)
+	testutils.AssertEqual(t, project.Name, "Spring concert")
+	testutils.AssertEqual(t, len(project.Resources), 1)
+	testutils.AssertEqual(t, project.Resources[0].Title, "Bolero")
+
+	serveRest[map[string]string](t, handler, RouteApiProjectsId, "/api/v1/projects/unknown", pkg.RoleAdmin, http.StatusNotFound)
+}
+
+func TestRestUsersAndOrganizations(t *testing.T) {
+	store := restTestStore(t)
+	handler := RestUsersHandler(store, time.Second)
+	users := serveRest[pkg.RestUserList](t, handler, RouteApiUsers, RouteApiUsers, pkg.RoleAdmin, http.StatusOK)
+	testutils.AssertEqual(t, len(users.Items), 2)
+
+	t.Run("members only get themselves", func(t *testing.T) {
+		users := serveRest[pkg.RestUserList](t, handler, RouteApiUsers, RouteApiUsers, pkg.RoleViewer, http.StatusOK)
+		testutils.AssertEqual(t, len(users.Items), 1)
+		testutils.AssertEqual(t, users.Items[0].Name, "Ola")
+		testutils.AssertEqual(t, users.Items[0].Role, "viewer")
+	})
+
+	organizations := serveRest[pkg.RestOrganizationList](t, RestOrganizationsHandler(store, time.Second), RouteApiOrganizations, RouteApiOrganizations, pkg.RoleAdmin, http.StatusOK)
+	testutils.AssertEqual(t, len(organizations.Items), 1)
+	testutils.AssertEqual(t, organizations.Items[0].Name, "Band")
+	testutils.AssertEqual(t, organizations.Items[0].Role, "admin")
+}
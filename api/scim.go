@@ -0,0 +1,443 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+const maxScimRequestSize = 64 * 1024
+
+func writeScim(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeScimError(w http.ResponseWriter, status int, scimType, detail string) {
+	writeScim(w, status, pkg.NewScimError(status, scimType, detail))
+}
+
+// writeScimStoreError responds with the status of the error. Identity providers read the detail, hence the
+// error message is included
+func writeScimStoreError(ctx context.Context, w http.ResponseWriter, msg string, err error) {
+	code := StoreErrorCode(err)
+	var scimType string
+	switch {
+	case errors.Is(err, pkg.ErrScimUserExists), errors.Is(err, pkg.ErrScimUserInvited):
+		scimType = "uniqueness"
+	case errors.Is(err, pkg.ErrInvalidScimRequest):
+		scimType = "invalidValue"
+	}
+
+	detail := msg
+	if code < http.StatusInternalServerError {
+		detail = msg + ": " + err.Error()
+	}
+	writeScimError(w, code, scimType, detail)
+	if code >= http.StatusInternalServerError {
+		slog.ErrorContext(ctx, msg, "error", err)
+	}
+}
+
+func decodeScim(w http.ResponseWriter, r *http.Request, v any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxScimRequestSize)
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeScimError(w, http.StatusBadRequest, "invalidSyntax", "Could not decode body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// scimPage returns the one-based start index and the number of resources requested. A negative count means all
+func scimPage(r *http.Request) (int, int) {
+	startIndex, err := strconv.Atoi(r.URL.Query().Get("startIndex"))
+	if err != nil {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil {
+		count = -1
+	}
+	return startIndex, count
+}
+
+func scimLocation(route, id string) string {
+	return strings.Replace(route, "{id}", url.PathEscape(id), 1)
+}
+
+// ScimUsersHandler lists the members of the organization. Identity providers look up users with a filter on the
+// user name before they provision them
+type ScimUserLister interface {
+	pkg.UserInOrgGetter
+	pkg.ScimIdentityStore
+}
+
+func ScimUsersHandler(store ScimUserLister, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		attribute, value, err := pkg.ParseScimFilter(r.URL.Query().Get("filter"))
+		if err != nil {
+			writeScimError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		users, err := store.GetUsersInOrg(ctx, orgId)
+		if err != nil {
+			writeScimStoreError(ctx, w, "Could not fetch users", err)
+			return
+		}
+		externalIds, err := pkg.ScimExternalIds(ctx, store, orgId)
+		if err != nil {
+			writeScimStoreError(ctx, w, "Could not fetch external ids", err)
+			return
+		}
+
+		scimUsers := []pkg.ScimUser{}
+		for _, user := range users {
+			scimUser := pkg.NewScimUser(&user, orgId, externalIds[user.Id])
+			if scimUser.MatchesScimFilter(attribute, value) {
+				scimUser.Meta.Location = scimLocation(RouteScimUsersId, scimUser.Id)
+				scimUsers = append(scimUsers, scimUser)
+			}
+		}
+		slices.SortFunc(scimUsers, func(a, b pkg.ScimUser) int { return strings.Compare(a.Id, b.Id) })
+		startIndex, count := scimPage(r)
+		writeScim(w, http.StatusOK, pkg.NewScimListResponse(scimUsers, startIndex, count))
+	}
+}
+
+// writeScimUser responds with the user and the external id the organization provisioned the user with
+func writeScimUser(ctx context.Context, w http.ResponseWriter, r *http.Request, store pkg.ScimIdentityStore, status int, user *pkg.UserInfo) {
+	orgId := MustGetOrgId(MustGetSession(r))
+	externalIds, err := pkg.ScimExternalIds(ctx, store, orgId)
+	if err != nil {
+		writeScimStoreError(ctx, w, "Could not fetch external id", err)
+		return
+	}
+	scimUser := pkg.NewScimUser(user, orgId, externalIds[user.Id])
+	scimUser.Meta.Location = scimLocation(RouteScimUsersId, scimUser.Id)
+	if status == http.StatusCreated {
+		w.Header().Set("Location", scimUser.Meta.Location)
+	}
+	writeScim(w, status, scimUser)
+}
+
+type ScimProvisioner interface {
+	pkg.ScimStore
+	InvitationSender
+}
+
+// CreateScimUserHandler makes the user a member of the organization, and registers the user when it does not exist.
+// Users with an account are emailed an invitation instead, and the provider is told that the user exists
+func CreateScimUserHandler(store ScimProvisioner, config *pkg.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var scimUser pkg.ScimUser
+		if !decodeScim(w, r, &scimUser) {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), config.Timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		user, invitation, err := pkg.ProvisionScimUser(ctx, store, orgId, &scimUser)
+		if invitation != nil {
+			if err := sendInvitationEmail(ctx, store, config, invitation); err != nil {
+				writeScimStoreError(ctx, w, "Could not send invitation", err)
+				return
+			}
+			slog.InfoContext(ctx, "Invited provisioned user", "invitationId", invitation.Id, "role", invitation.Role)
+		}
+		if err != nil {
+			writeScimStoreError(ctx, w, "Could not provision user", err)
+			return
+		}
+
+		slog.InfoContext(ctx, "Provisioned user", "targetUser", user.Id, "role", user.Roles[orgId])
+		writeScimUser(ctx, w, r, store, http.StatusCreated, user)
+	}
+}
+
+type ScimUserGetter interface {
+	pkg.RoleGetter
+	pkg.ScimIdentityStore
+}
+
+func ScimUserHandler(store ScimUserGetter, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		user, err := pkg.ScimMember(ctx, store, orgId, r.PathValue("id"))
+		if err != nil {
+			writeScimStoreError(ctx, w, "Could not fetch user", err)
+			return
+		}
+		writeScimUser(ctx, w, r, store, http.StatusOK, user)
+	}
+}
+
+// ReplaceScimUserHandler updates a member with the attributes of the body, which holds the full user
+func ReplaceScimUserHandler(store pkg.ScimStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var scimUser pkg.ScimUser
+		if !decodeScim(w, r, &scimUser) {
+			return
+		}
+		updateScimUser(w, r, store, timeout, func(*pkg.UserInfo) (*pkg.ScimUser, error) { return &scimUser, nil })
+	}
+}
+
+// PatchScimUserHandler updates a member with the operations of the body. Identity providers deprovision users by
+// setting active to false
+func PatchScimUserHandler(store pkg.ScimStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var patch pkg.ScimPatch
+		if !decodeScim(w, r, &patch) {
+			return
+		}
+		updateScimUser(w, r, store, timeout, func(user *pkg.UserInfo) (*pkg.ScimUser, error) {
+			scimUser := pkg.NewScimUser(user, MustGetOrgId(MustGetSession(r)), "")
+			return &scimUser, scimUser.ApplyScimPatch(&patch)
+		})
+	}
+}
+
+func updateScimUser(w http.ResponseWriter, r *http.Request, store pkg.ScimStore, timeout time.Duration, update func(*pkg.UserInfo) (*pkg.ScimUser, error)) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	orgId := MustGetOrgId(MustGetSession(r))
+	user, err := pkg.ScimMember(ctx, store, orgId, r.PathValue("id"))
+	if err != nil {
+		writeScimStoreError(ctx, w, "Could not fetch user", err)
+		return
+	}
+
+	scimUser, err := update(user)
+	if err != nil {
+		writeScimStoreError(ctx, w, "Could not update user", err)
+		return
+	}
+	if !scimUser.IsActive() && user.Id == MustGetUserId(MustGetSession(r)) {
+		writeScimError(w, http.StatusForbidden, "", "The owner of the token can not be deprovisioned")
+		return
+	}
+	if user, err = pkg.ReplaceScimUser(ctx, store, orgId, user, scimUser); err != nil {
+		writeScimStoreError(ctx, w, "Could not update user", err)
+		return
+	}
+
+	role, active := user.Roles[orgId]
+	slog.InfoContext(ctx, "Updated provisioned user", "targetUser", user.Id, "role", role, "active", active)
+	writeScimUser(ctx, w, r, store, http.StatusOK, user)
+}
+
+// DeleteScimUserHandler removes the user from the organization. The account is kept, since users may be members
+// of several organizations
+func DeleteScimUserHandler(store pkg.ScimStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		user, err := pkg.ScimMember(ctx, store, orgId, r.PathValue("id"))
+		if err != nil {
+			writeScimStoreError(ctx, w, "Could not fetch user", err)
+			return
+		}
+		if user.Id == MustGetUserId(MustGetSession(r)) {
+			writeScimError(w, http.StatusForbidden, "", "The owner of the token can not be deprovisioned")
+			return
+		}
+		if err := store.DeleteRole(ctx, user.Id, orgId); err != nil {
+			writeScimStoreError(ctx, w, "Could not delete role", err)
+			return
+		}
+
+		slog.InfoContext(ctx, "Deprovisioned user", "targetUser", user.Id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeScimGroup(w http.ResponseWriter, r *http.Request, status int, group *pkg.ScimGroup) {
+	group.Meta.Location = scimLocation(RouteScimGroupsId, group.Id)
+	if status == http.StatusCreated {
+		w.Header().Set("Location", group.Meta.Location)
+	}
+	writeScim(w, status, group)
+}
+
+// ScimGroupsHandler lists the groups of the organization. Groups can be filtered by the display name
+func ScimGroupsHandler(store pkg.UserInOrgGetter, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		attribute, value, err := pkg.ParseScimFilter(r.URL.Query().Get("filter"))
+		if err != nil {
+			writeScimError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		groups, err := pkg.ScimGroups(ctx, store, orgId)
+		if err != nil {
+			writeScimStoreError(ctx, w, "Could not fetch groups", err)
+			return
+		}
+
+		if attribute != "" {
+			groups = slices.DeleteFunc(groups, func(g pkg.ScimGroup) bool {
+				return !(strings.EqualFold(attribute, "displayName") || strings.EqualFold(attribute, "id")) || g.Id != value
+			})
+		}
+		for i := range groups {
+			groups[i].Meta.Location = scimLocation(RouteScimGroupsId, groups[i].Id)
+		}
+		startIndex, count := scimPage(r)
+		writeScim(w, http.StatusOK, pkg.NewScimListResponse(groups, startIndex, count))
+	}
+}
+
+// CreateScimGroupHandler adds the group to its members. Since groups only exist as long as they have members, a
+// group created without members is not listed until members are added
+func CreateScimGroupHandler(store pkg.ScimStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var group pkg.ScimGroup
+		if !decodeScim(w, r, &group) {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		_, err := pkg.ScimGroupByName(ctx, store, orgId, group.DisplayName)
+		switch {
+		case err == nil:
+			writeScimError(w, http.StatusConflict, "uniqueness", "Group already exists")
+			return
+		case !errors.Is(err, pkg.ErrScimGroupNotFound):
+			writeScimStoreError(ctx, w, "Could not fetch group", err)
+			return
+		}
+
+		if err := pkg.SaveScimGroup(ctx, store, orgId, "", &group); err != nil {
+			writeScimStoreError(ctx, w, "Could not create group", err)
+			return
+		}
+
+		slog.InfoContext(ctx, "Provisioned group", "group", group.DisplayName, "numMembers", len(group.Members))
+		created := pkg.NewScimGroup(group.DisplayName, group.Members)
+		writeScimGroup(w, r, http.StatusCreated, &created)
+	}
+}
+
+func ScimGroupHandler(store pkg.UserInOrgGetter, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		group, err := pkg.ScimGroupByName(ctx, store, orgId, r.PathValue("id"))
+		if err != nil {
+			writeScimStoreError(ctx, w, "Could not fetch group", err)
+			return
+		}
+		writeScimGroup(w, r, http.StatusOK, group)
+	}
+}
+
+// ReplaceScimGroupHandler sets the name and the members of the group to those of the body
+func ReplaceScimGroupHandler(store pkg.ScimStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var replacement pkg.ScimGroup
+		if !decodeScim(w, r, &replacement) {
+			return
+		}
+		updateScimGroup(w, r, store, timeout, func(group *pkg.ScimGroup) error {
+			group.DisplayName = replacement.DisplayName
+			group.Members = replacement.Members
+			return nil
+		})
+	}
+}
+
+// PatchScimGroupHandler adds and removes members of the group, and renames it
+func PatchScimGroupHandler(store pkg.ScimStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var patch pkg.ScimPatch
+		if !decodeScim(w, r, &patch) {
+			return
+		}
+		updateScimGroup(w, r, store, timeout, func(group *pkg.ScimGroup) error {
+			return group.ApplyScimPatch(&patch)
+		})
+	}
+}
+
+// updateScimGroup applies the update to the group with the id of the path. Groups without members are updated as
+// well, since identity providers create groups before they add members
+func updateScimGroup(w http.ResponseWriter, r *http.Request, store pkg.ScimStore, timeout time.Duration, update func(*pkg.ScimGroup) error) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	orgId := MustGetOrgId(MustGetSession(r))
+	name := r.PathValue("id")
+	group, err := pkg.ScimGroupByName(ctx, store, orgId, name)
+	if errors.Is(err, pkg.ErrScimGroupNotFound) {
+		empty := pkg.NewScimGroup(name, nil)
+		group, err = &empty, nil
+	}
+	if err != nil {
+		writeScimStoreError(ctx, w, "Could not fetch group", err)
+		return
+	}
+
+	if err := update(group); err != nil {
+		writeScimStoreError(ctx, w, "Could not update group", err)
+		return
+	}
+	if err := pkg.SaveScimGroup(ctx, store, orgId, name, group); err != nil {
+		writeScimStoreError(ctx, w, "Could not update group", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "Updated provisioned group", "group", group.DisplayName, "numMembers", len(group.Members))
+	updated := pkg.NewScimGroup(group.DisplayName, group.Members)
+	writeScimGroup(w, r, http.StatusOK, &updated)
+}
+
+// DeleteScimGroupHandler removes the group from all members
+func DeleteScimGroupHandler(store pkg.ScimStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		name := r.PathValue("id")
+		if err := pkg.SetScimGroupMembers(ctx, store, orgId, name, []string{}); err != nil {
+			writeScimStoreError(ctx, w, "Could not delete group", err)
+			return
+		}
+
+		slog.InfoContext(ctx, "Deprovisioned group", "group", name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/gorilla/sessions"
+)
+
+func scimTestServer(t *testing.T) (*http.ServeMux, *pkg.MultiOrgInMemoryStore, string) {
+	store := apiTokenTestStore(t, pkg.RoleAdmin)
+	config := pkg.NewDefaultConfig()
+	config.RequireSubscription = false
+	mux := Setup(store, config, sessions.NewCookieStore([]byte("key")))
+	return mux, store, mustCreateApiToken(t, store, pkg.ApiTokenScim, "0")
+}
+
+func serveScim(mux *http.ServeMux, secret, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+secret)
+	req.Header.Set("Content-Type", "application/scim+json")
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestScimUsers(t *testing.T) {
+	mux, store, secret := scimTestServer(t)
+	ctx := context.Background()
+
+	recorder := serveScim(mux, secret, "POST", RouteScimUsers, `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"externalId": "ola-azure",
+		"userName": "ola@example.com",
+		"displayName": "Ola",
+		"roles": [{"value": "editor"}]
+	}`)
+	testutils.AssertEqual(t, recorder.Code, http.StatusCreated)
+	testutils.AssertEqual(t, recorder.Header().Get("Content-Type"), "application/scim+json")
+
+	var created pkg.ScimUser
+	testutils.AssertNil(t, json.Unmarshal(recorder.Body.Bytes(), &created))
+	testutils.AssertEqual(t, created.ExternalId, "ola-azure")
+	testutils.AssertEqual(t, created.Id != "ola-azure", true)
+	location := "/scim/v2/Users/" + created.Id
+	testutils.AssertEqual(t, recorder.Header().Get("Location"), location)
+
+	user, err := store.GetUserInfo(ctx, created.Id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, user.Roles["org1"], pkg.RoleEditor)
+
+	t.Run("duplicate", func(t *testing.T) {
+		recorder := serveScim(mux, secret, "POST", RouteScimUsers, `{"externalId": "ola-azure", "userName": "ola@example.com"}`)
+		testutils.AssertEqual(t, recorder.Code, http.StatusConflict)
+		testutils.AssertContains(t, recorder.Body.String(), `"scimType":"uniqueness"`)
+	})
+
+	for _, filter := range []string{`userName eq "OLA@example.com"`, `externalId eq "ola-azure"`} {
+		t.Run(filter, func(t *testing.T) {
+			recorder := serveScim(mux, secret, "GET", RouteScimUsers+"?filter="+url.QueryEscape(filter), "")
+			testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+
+			var list struct {
+				TotalResults int            `json:"totalResults"`
+				Resources    []pkg.ScimUser `json:"Resources"`
+			}
+			testutils.AssertNil(t, json.Unmarshal(recorder.Body.Bytes(), &list))
+			testutils.AssertEqual(t, list.TotalResults, 1)
+			testutils.AssertEqual(t, list.Resources[0].Id, created.Id)
+			testutils.AssertEqual(t, list.Resources[0].ExternalId, "ola-azure")
+		})
+	}
+
+	t.Run("deactivate", func(t *testing.T) {
+		recorder := serveScim(mux, secret, "PATCH", location, `{
+			"Operations": [{"op": "Replace", "path": "active", "value": "False"}]
+		}`)
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+
+		user, err := store.GetUserInfo(ctx, created.Id)
+		testutils.AssertNil(t, err)
+		_, isMember := user.Roles["org1"]
+		testutils.AssertEqual(t, isMember, false)
+		testutils.AssertEqual(t, serveScim(mux, secret, "GET", location, "").Code, http.StatusNotFound)
+	})
+
+	t.Run("owner can not be deprovisioned", func(t *testing.T) {
+		testutils.AssertEqual(t, serveScim(mux, secret, "DELETE", "/scim/v2/Users/0000-0000", "").Code, http.StatusForbidden)
+	})
+}
+
+func TestScimInvitesExistingAccount(t *testing.T) {
+	store := apiTokenTestStore(t, pkg.RoleAdmin)
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterUser(ctx, &pkg.UserInfo{Id: "ola", Email: "ola@example.com", Name: "Ola", Password: "hash"}))
+
+	var recipents []string
+	config := pkg.NewDefaultConfig()
+	config.RequireSubscription = false
+	config.SmtpConfig.SendFn = func(addr string, auth smtp.Auth, sender string, to []string, m []byte) error {
+		recipents = append(recipents, to...)
+		return nil
+	}
+	mux := Setup(store, config, sessions.NewCookieStore([]byte("key")))
+	secret := mustCreateApiToken(t, store, pkg.ApiTokenScim, "0")
+
+	recorder := serveScim(mux, secret, "POST", RouteScimUsers, `{"externalId": "ola", "userName": "ola@example.com", "displayName": "Someone Else"}`)
+	testutils.AssertEqual(t, recorder.Code, http.StatusConflict)
+	testutils.AssertContains(t, recorder.Body.String(), `"scimType":"uniqueness"`)
+	testutils.AssertEqual(t, strings.Contains(recorder.Body.String(), `"Ola"`), false)
+	testutils.AssertEqual(t, slices.Equal(recipents, []string{"ola@example.com"}), true)
+
+	// The account joins when the user accepts the invitation
+	user, err := store.GetUserInfo(ctx, "ola")
+	testutils.AssertNil(t, err)
+	_, isMember := user.Roles["org1"]
+	testutils.AssertEqual(t, isMember, false)
+	testutils.AssertEqual(t, user.Name, "Ola")
+
+	invitations, err := store.Invitations(ctx, "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(invitations), 1)
+	testutils.AssertEqual(t, invitations[0].Email, "ola@example.com")
+}
+
+func TestScimGroupsEndpoint(t *testing.T) {
+	mux, store, secret := scimTestServer(t)
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterUser(ctx, &pkg.UserInfo{Id: "kari"}))
+	testutils.AssertNil(t, store.RegisterRole(ctx, "kari", "org1", pkg.RoleViewer))
+
+	recorder := serveScim(mux, secret, "POST", RouteScimGroups, `{"displayName": "Trumpets", "members": []}`)
+	testutils.AssertEqual(t, recorder.Code, http.StatusCreated)
+
+	recorder = serveScim(mux, secret, "PATCH", "/scim/v2/Groups/Trumpets", `{
+		"Operations": [{"op": "Add", "path": "members", "value": [{"value": "kari"}]}]
+	}`)
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+
+	user, err := store.GetUserInfo(ctx, "kari")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(user.Groups["org1"], ","), "Trumpets")
+
+	recorder = serveScim(mux, secret, "GET", "/scim/v2/Groups/Trumpets", "")
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+	testutils.AssertContains(t, recorder.Body.String(), `"value":"kari"`)
+
+	testutils.AssertEqual(t, serveScim(mux, secret, "DELETE", "/scim/v2/Groups/Trumpets", "").Code, http.StatusNoContent)
+	testutils.AssertEqual(t, serveScim(mux, secret, "GET", "/scim/v2/Groups/Trumpets", "").Code, http.StatusNotFound)
+}
+
+func TestScimTokens(t *testing.T) {
+	mux, store, secret := scimTestServer(t)
+	readSecret := mustCreateApiToken(t, store, pkg.ApiTokenRead, "0")
+
+	t.Run("missing token", func(t *testing.T) {
+		req := httptest.NewRequest("GET", RouteScimUsers, nil)
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+		testutils.AssertEqual(t, recorder.Code, http.StatusUnauthorized)
+		testutils.AssertContains(t, recorder.Body.String(), pkg.ScimErrorSchema)
+	})
+
+	t.Run("other tokens can not provision", func(t *testing.T) {
+		testutils.AssertEqual(t, serveScim(mux, readSecret, "GET", RouteScimUsers, "").Code, http.StatusForbidden)
+	})
+
+	t.Run("scim tokens can only provision", func(t *testing.T) {
+		testutils.AssertEqual(t, serveScim(mux, secret, "GET", RouteOrganizationsUsers, "").Code, http.StatusForbidden)
+	})
+
+	t.Run("owner is no longer admin", func(t *testing.T) {
+		testutils.AssertNil(t, store.RegisterRole(context.Background(), "0000-0000", "org1", pkg.RoleLibrarian))
+		testutils.AssertEqual(t, serveScim(mux, secret, "GET", RouteScimUsers, "").Code, http.StatusForbidden)
+	})
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const sharedPartTokenAudience = "caesura-shared-part"
+
+// SharedPartClaim grants access to a single part of a resource without a session. Unlike WebDAV tokens,
+// the claim is not tied to a user, so it stays valid until it expires
+type SharedPartClaim struct {
+	OrgId      string `json:"org_id"`
+	ResourceId string `json:"resource_id"`
+	File       string `json:"file"`
+	jwt.RegisteredClaims
+}
+
+func SignedSharedPartToken(orgId, resourceId, file, signSecret string, expires time.Time) (string, error) {
+	currentTime := time.Now()
+	claims := SharedPartClaim{
+		OrgId:      orgId,
+		ResourceId: resourceId,
+		File:       file,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{sharedPartTokenAudience},
+			ExpiresAt: jwt.NewNumericDate(expires),
+			IssuedAt:  jwt.NewNumericDate(currentTime),
+			NotBefore: jwt.NewNumericDate(currentTime),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(signSecret))
+}
+
+func parseSharedPartToken(token, signSecret string) (*SharedPartClaim, error) {
+	var claims SharedPartClaim
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		return []byte(signSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired(), jwt.WithAudience(sharedPartTokenAudience))
+	if err != nil {
+		return nil, err
+	}
+	if claims.OrgId == "" || claims.ResourceId == "" || claims.File == "" {
+		return nil, fmt.Errorf("token does not contain organization, resource and file")
+	}
+	return &claims, nil
+}
+
+// SharedLinkResponse is a link to a part that works without an account until it expires
+type SharedLinkResponse struct {
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+// SharedLinkHandler creates a link to a part of a resource that can be sent to musicians without an account.
+// Stores backed by a bucket that can sign URLs give links directly to the bucket, such that large files are
+// not passed through the server. Otherwise the link points to the shared part endpoint of the app
+func SharedLinkHandler(store pkg.TieredResourceGetter, config *pkg.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), config.Timeout)
+		defer cancel()
+
+		filename := r.URL.Query().Get("file")
+		if filename == "" {
+			http.Error(w, "Query parameter 'file' is required", http.StatusBadRequest)
+			return
+		}
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		resourceId := r.PathValue("id")
+		downloader := pkg.NewResourceDownloader().
+			GetMetaData(ctx, store, orgId, resourceId).
+			Rehydrate(ctx, store, orgId).
+			GetResource(ctx, store, orgId)
+		if err := downloader.Error; err != nil {
+			http.Error(w, err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch resource", "error", err, "id", resourceId)
+			return
+		}
+		if !slices.Contains(downloader.Filenames(), filename) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+
+		expires := time.Now().Add(config.SharedLinkExpiry)
+		link, err := signedPartURL(ctx, store, orgId, resourceId, filename, expires)
+		if err != nil {
+			token, err := SignedSharedPartToken(orgId, resourceId, filename, config.CookieSecretSignKey, expires)
+			if err != nil {
+				http.Error(w, "Failed to sign link", http.StatusInternalServerError)
+				slog.ErrorContext(ctx, "Failed to sign shared part token", "error", err)
+				return
+			}
+			link = config.BaseURL + RouteSharedPart + "?token=" + url.QueryEscape(token)
+		}
+		slog.InfoContext(ctx, "Created shared link", "resourceId", resourceId, "file", filename, "expires", expires)
+
+		respBody := SharedLinkResponse{URL: link, Expires: expires}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(respBody); err != nil {
+			slog.ErrorContext(ctx, "Failed to encode shared link", "error", err)
+		}
+	}
+}
+
+// signedPartURL asks the store for a link directly to the bucket. Stores that can not sign links return an error
+func signedPartURL(ctx context.Context, store pkg.TieredResourceGetter, orgId, resourceId, filename string, expires time.Time) (string, error) {
+	signer, ok := store.(pkg.PartURLSigner)
+	if !ok {
+		return "", pkg.ErrSignedURLUnsupported
+	}
+	link, err := signer.SignedPartURL(ctx, orgId, resourceId, filename, expires)
+	if err != nil && !errors.Is(err, pkg.ErrSignedURLUnsupported) {
+		slog.WarnContext(ctx, "Could not sign bucket url, falling back to app link", "error", err)
+	}
+	return link, err
+}
+
+type SharedPartStore interface {
+	pkg.TieredResourceGetter
+	pkg.FeatureCounter
+}
+
+// SharedPartHandler serves a part to anyone holding a valid token created by SharedLinkHandler
+func SharedPartHandler(store SharedPartStore, signSecret string, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		claims, err := parseSharedPartToken(r.URL.Query().Get("token"), signSecret)
+		if err != nil {
+			http.Error(w, "The link is invalid or has expired", http.StatusUnauthorized)
+			slog.InfoContext(ctx, "Invalid shared part token", "error", err)
+			return
+		}
+
+		downloader := pkg.NewResourceDownloader().
+			GetMetaData(ctx, store, claims.OrgId, claims.ResourceId).
+			Rehydrate(ctx, store, claims.OrgId).
+			GetResource(ctx, store, claims.OrgId)
+		if err := downloader.Error; err != nil {
+			http.Error(w, err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Error during shared download", "error", err, "id", claims.ResourceId)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+claims.File+"\"")
+		w.Header().Set("Cache-Control", "private, no-store")
+		if err := downloader.ExtractSingleFile(claims.File, w).Error; err != nil {
+			if pkg.IsNotFound(err) {
+				w.Header().Del("Content-Disposition")
+				http.Error(w, "File not found", http.StatusNotFound)
+			}
+			slog.ErrorContext(ctx, "Error during shared download", "error", err, "id", claims.ResourceId, "file", claims.File)
+			return
+		}
+
+		now := time.Now()
+		if err := store.RecordAccess(ctx, claims.OrgId, claims.ResourceId, now); err != nil {
+			slog.ErrorContext(ctx, "Failed to record access", "error", err, "id", claims.ResourceId)
+		}
+		if err := store.CountFeature(ctx, claims.OrgId, pkg.FeatureDownload, now); err != nil {
+			slog.ErrorContext(ctx, "Could not count feature usage", "feature", pkg.FeatureDownload, "error", err)
+		}
+		slog.InfoContext(ctx, "Shared part downloaded", "orgId", claims.OrgId, "id", claims.ResourceId, "file", claims.File)
+	}
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type sharedLinkResponse struct {
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+func requestSharedLink(t *testing.T, store pkg.TieredResourceGetter, config *pkg.Config, target string) (*httptest.ResponseRecorder, sharedLinkResponse) {
+	mux := http.NewServeMux()
+	mux.Handle("GET "+RouteResourcesIdLink, SharedLinkHandler(store, config))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, withAuthSession(httptest.NewRequest("GET", target, nil), "org"))
+
+	var resp sharedLinkResponse
+	if rec.Code == http.StatusOK {
+		testutils.AssertNil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	}
+	return rec, resp
+}
+
+func TestSharedLinkRoundTrip(t *testing.T) {
+	store, resourceId := manifestStore(t)
+	config := pkg.NewDefaultConfig()
+	config.CookieSecretSignKey = "secret"
+
+	rec, resp := requestSharedLink(t, store, config, "/resources/"+resourceId+"/link?file=Horn.pdf")
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, resp.URL, config.BaseURL+RouteSharedPart+"?token=")
+	testutils.AssertEqual(t, resp.Expires.After(time.Now().Add(config.SharedLinkExpiry-time.Minute)), true)
+
+	link, err := url.Parse(resp.URL)
+	testutils.AssertNil(t, err)
+
+	// The link works without a session
+	rec = httptest.NewRecorder()
+	SharedPartHandler(store, "secret", time.Second).ServeHTTP(rec, httptest.NewRequest("GET", link.RequestURI(), nil))
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, rec.Body.String(), "horn")
+	testutils.AssertEqual(t, rec.Header().Get("Content-Type"), "application/pdf")
+	testutils.AssertContains(t, rec.Header().Get("Content-Disposition"), "Horn.pdf")
+}
+
+func TestSharedLinkHandlerErrors(t *testing.T) {
+	store, resourceId := manifestStore(t)
+	config := pkg.NewDefaultConfig()
+
+	for _, test := range []struct {
+		desc   string
+		target string
+		code   int
+	}{
+		{"Missing file", "/resources/" + resourceId + "/link", http.StatusBadRequest},
+		{"Unknown file", "/resources/" + resourceId + "/link?file=Flute.pdf", http.StatusNotFound},
+		{"Unknown resource", "/resources/unknown/link?file=Horn.pdf", http.StatusNotFound},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			rec, _ := requestSharedLink(t, store, config, test.target)
+			testutils.AssertEqual(t, rec.Code, test.code)
+		})
+	}
+}
+
+type signingStore struct {
+	*pkg.MultiOrgInMemoryStore
+	err error
+}
+
+func (s *signingStore) SignedPartURL(ctx context.Context, orgId, resourceId, filename string, expires time.Time) (string, error) {
+	return "https://bucket.example.com/" + orgId + "/" + resourceId + "/" + filename, s.err
+}
+
+func TestSharedLinkUsesBucketSignedURL(t *testing.T) {
+	inMemStore, resourceId := manifestStore(t)
+	config := pkg.NewDefaultConfig()
+
+	t.Run("Bucket link", func(t *testing.T) {
+		store := &signingStore{MultiOrgInMemoryStore: inMemStore}
+		rec, resp := requestSharedLink(t, store, config, "/resources/"+resourceId+"/link?file=Horn.pdf")
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+		testutils.AssertEqual(t, resp.URL, "https://bucket.example.com/org/"+resourceId+"/Horn.pdf")
+	})
+
+	t.Run("Falls back to app link", func(t *testing.T) {
+		store := &signingStore{MultiOrgInMemoryStore: inMemStore, err: errors.New("no signing key")}
+		rec, resp := requestSharedLink(t, store, config, "/resources/"+resourceId+"/link?file=Horn.pdf")
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+		testutils.AssertEqual(t, strings.HasPrefix(resp.URL, config.BaseURL+RouteSharedPart), true)
+	})
+}
+
+func TestSharedPartHandlerRejectsInvalidTokens(t *testing.T) {
+	store, resourceId := manifestStore(t)
+	handler := SharedPartHandler(store, "secret", time.Second)
+
+	expired, err := SignedSharedPartToken("org", resourceId, "Horn.pdf", "secret", time.Now().Add(-time.Minute))
+	testutils.AssertNil(t, err)
+	forged, err := SignedSharedPartToken("org", resourceId, "Horn.pdf", "other-secret", time.Now().Add(time.Hour))
+	testutils.AssertNil(t, err)
+	otherFile, err := SignedSharedPartToken("org", resourceId, "Flute.pdf", "secret", time.Now().Add(time.Hour))
+	testutils.AssertNil(t, err)
+	withoutAudience, err := jwt.NewWithClaims(jwt.SigningMethodHS256, SharedPartClaim{
+		OrgId:            "org",
+		ResourceId:       resourceId,
+		File:             "Horn.pdf",
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	}).SignedString([]byte("secret"))
+	testutils.AssertNil(t, err)
+
+	for _, test := range []struct {
+		desc  string
+		token string
+		code  int
+	}{
+		{"Missing token", "", http.StatusUnauthorized},
+		{"Expired", expired, http.StatusUnauthorized},
+		{"Wrong signature", forged, http.StatusUnauthorized},
+		{"Unknown file", otherFile, http.StatusNotFound},
+		{"Without audience", withoutAudience, http.StatusUnauthorized},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", RouteSharedPart+"?token="+url.QueryEscape(test.token), nil))
+			testutils.AssertEqual(t, rec.Code, test.code)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+// SubmitProgressResponse is returned when an upload id is created. The progress of the submit is streamed from URL
+type SubmitProgressResponse struct {
+	UploadId string `json:"uploadId"`
+	URL      string `json:"url"`
+}
+
+// CreateSubmitProgressHandler returns an upload id that is passed in the upload-id form value of a submit. The
+// id is created before the submit, such that the client can follow the progress while the score is uploaded
+func CreateSubmitProgressHandler(tracker *pkg.SubmitTracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgId := MustGetOrgId(MustGetSession(r))
+		id := tracker.Create(orgId)
+		url := RouteSubmitProgress + "/" + id
+		w.Header().Set("Location", url)
+		writeRest(w, http.StatusCreated, SubmitProgressResponse{UploadId: id, URL: url})
+	}
+}
+
+// writeServerSentEvent writes the data as a single event of the event stream
+func writeServerSentEvent(w io.Writer, event string, data any) error {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, content)
+	return err
+}
+
+// SubmitProgressHandler streams the progress of a submit as server-sent events. A progress event is sent each
+// time a part is split or uploaded, and the stream ends once the submit is finished or failed
+func SubmitProgressHandler(tracker *pkg.SubmitTracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgId := MustGetOrgId(MustGetSession(r))
+		id := r.PathValue("id")
+		progress, changed, err := tracker.Watch(orgId, id)
+		if err != nil {
+			http.Error(w, "Could not find upload", StoreErrorCode(err))
+			return
+		}
+
+		// The stream is held open while the score is uploaded, so every event is flushed right away
+		controller := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		for {
+			if err := writeServerSentEvent(w, "progress", progress); err != nil {
+				slog.InfoContext(r.Context(), "Stopped streaming submit progress", "error", err, "uploadId", id)
+				return
+			}
+			if err := controller.Flush(); err != nil {
+				slog.ErrorContext(r.Context(), "Could not flush submit progress", "error", err, "uploadId", id)
+				return
+			}
+			if progress.Done() {
+				return
+			}
+
+			select {
+			case <-r.Context().Done():
+				return
+			case <-changed:
+			}
+
+			// The progress is removed when it expires, which ends the stream
+			if progress, changed, err = tracker.Watch(orgId, id); err != nil {
+				return
+			}
+		}
+	}
+}
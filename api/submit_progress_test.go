@@ -0,0 +1,117 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func withUploadId(id string) func(w *multipart.Writer) {
+	return func(w *multipart.Writer) {
+		w.WriteField("upload-id", id)
+	}
+}
+
+// readProgressEvents returns the progress in the data lines of the event stream until it is closed
+func readProgressEvents(t *testing.T, scanner *bufio.Scanner, events chan<- pkg.SubmitProgress) {
+	defer close(events)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var progress pkg.SubmitProgress
+		if err := json.Unmarshal([]byte(data), &progress); err != nil {
+			t.Errorf("Invalid progress event %s: %s", data, err)
+			return
+		}
+		events <- progress
+	}
+}
+
+func TestSubmitProgress(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	store.RegisterOrganization(context.Background(), &pkg.Organization{Id: "orgId"})
+	tracker := pkg.NewSubmitTracker(time.Hour)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+RouteSubmitProgressId, func(w http.ResponseWriter, r *http.Request) {
+		SubmitProgressHandler(tracker)(w, withAuthSession(r, "orgId"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	recorder := httptest.NewRecorder()
+	CreateSubmitProgressHandler(tracker)(recorder, withAuthSession(httptest.NewRequest("POST", RouteSubmitProgress, nil), "orgId"))
+	testutils.AssertEqual(t, recorder.Code, http.StatusCreated)
+	var created SubmitProgressResponse
+	testutils.AssertNil(t, json.NewDecoder(recorder.Body).Decode(&created))
+	testutils.AssertEqual(t, created.URL, RouteSubmitProgress+"/"+created.UploadId)
+	testutils.AssertEqual(t, recorder.Header().Get("Location"), created.URL)
+
+	resp, err := server.Client().Get(server.URL + created.URL)
+	testutils.AssertNil(t, err)
+	defer resp.Body.Close()
+	testutils.AssertEqual(t, resp.StatusCode, http.StatusOK)
+	testutils.AssertEqual(t, resp.Header.Get("Content-Type"), "text/event-stream")
+
+	events := make(chan pkg.SubmitProgress)
+	go readProgressEvents(t, bufio.NewScanner(resp.Body), events)
+	testutils.AssertEqual(t, (<-events).Status, pkg.SubmitWaiting)
+
+	submit := func(uploadId string) *httptest.ResponseRecorder {
+		body, contentType := multipartForm(withPdf, withAssignments, withMetaData, withUploadId(uploadId))
+		request := httptest.NewRequest("POST", RouteResources, body)
+		request.Header.Set("Content-Type", contentType)
+		recorder := httptest.NewRecorder()
+		SubmitHandler(store, tracker, time.Second, 10)(recorder, withAuthSession(request, "orgId"))
+		return recorder
+	}
+	testutils.AssertEqual(t, submit(created.UploadId).Code, http.StatusOK)
+
+	var last pkg.SubmitProgress
+	num := 0
+	for progress := range events {
+		last = progress
+		num++
+	}
+	testutils.AssertEqual(t, num > 0, true)
+	testutils.AssertEqual(t, last.Status, pkg.SubmitFinished)
+	testutils.AssertEqual(t, last.Total, 2)
+	testutils.AssertEqual(t, last.Split, 2)
+	testutils.AssertEqual(t, last.Uploaded, 2)
+
+	t.Run("upload id is used once", func(t *testing.T) {
+		testutils.AssertEqual(t, submit(created.UploadId).Code, http.StatusConflict)
+	})
+
+	t.Run("unknown upload id", func(t *testing.T) {
+		testutils.AssertEqual(t, submit("unknown").Code, http.StatusNotFound)
+
+		resp, err := server.Client().Get(server.URL + RouteSubmitProgress + "/unknown")
+		testutils.AssertNil(t, err)
+		resp.Body.Close()
+		testutils.AssertEqual(t, resp.StatusCode, http.StatusNotFound)
+	})
+
+	t.Run("finished progress is sent right away", func(t *testing.T) {
+		resp, err := server.Client().Get(server.URL + created.URL)
+		testutils.AssertNil(t, err)
+		defer resp.Body.Close()
+
+		events := make(chan pkg.SubmitProgress)
+		go readProgressEvents(t, bufio.NewScanner(resp.Body), events)
+		testutils.AssertEqual(t, (<-events).Status, pkg.SubmitFinished)
+		_, open := <-events
+		testutils.AssertEqual(t, open, false)
+	})
+}
@@ -144,6 +144,7 @@ func stripeWebhookHandler(store pkg.SubscriptionStorer, config *pkg.Config) http
 				w.WriteHeader(http.StatusServiceUnavailable)
 				return
 			}
+			recordEvent(ctx, pkg.NewEvent(pkg.EventSubscriptionChanged, "", "", customer.ID))
 
 		default:
 			slog.InfoContext(r.Context(), "Unhandled event type", "eventType", event.Type)
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+const (
+	uploadOffsetHeader = "Upload-Offset"
+	uploadLengthHeader = "Upload-Length"
+	uploadChunkType    = "application/offset+octet-stream"
+)
+
+func headerInt(r *http.Request, name string) (int64, error) {
+	value, err := strconv.ParseInt(r.Header.Get(name), 10, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return value, nil
+}
+
+func writeUploadOffset(w http.ResponseWriter, session *pkg.UploadSession) {
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(session.Offset, 10))
+	w.Header().Set(uploadLengthHeader, strconv.FormatInt(session.Length, 10))
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// CreateUploadHandler starts a resumable upload. The total size of the score is given in the Upload-Length
+// header, and the metadata and assignments as url encoded form values like for a regular submit
+func CreateUploadHandler(uploads *pkg.UploadSessions, maxSize int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const maxFormSize = 1 << 20 // 1 MB
+		r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+
+		length, err := headerInt(r, uploadLengthHeader)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if maxUploadSize := int64(maxSize) << 20; length > maxUploadSize {
+			msg := fmt.Sprintf("File is larger than max allowed size (~%d MB).", maxSize)
+			http.Error(w, msg, http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		if code, err := parseForm(r); err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+		metaData, assignments, err := parseSubmission(r.Form)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			slog.ErrorContext(r.Context(), "Invalid submission", "error", err)
+			return
+		}
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		session, err := uploads.Create(orgId, length, metaData, assignments)
+		if err != nil {
+			http.Error(w, "Could not create upload", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Could not create upload", "error", err)
+			return
+		}
+		slog.InfoContext(r.Context(), "Created upload", "uploadId", session.Id, "length", length, "resourceId", metaData.ResourceId())
+		w.Header().Set("Location", RouteResourcesUploads+"/"+session.Id)
+		writeUploadOffset(w, session)
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// AppendUploadHandler appends a chunk at the offset given in the Upload-Offset header. The score is submitted
+// once the last chunk is received. Responses carry the number of bytes received in Upload-Offset, such that
+// a client resuming after a dropped connection learns where to continue from the conflict response
+func AppendUploadHandler(submitter pkg.Submitter, uploads *pkg.UploadSessions, timeout time.Duration, maxChunkSize int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != uploadChunkType {
+			http.Error(w, "Content-Type must be "+uploadChunkType, http.StatusUnsupportedMediaType)
+			return
+		}
+		offset, err := headerInt(r, uploadOffsetHeader)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, int64(maxChunkSize)<<20)
+		orgId := MustGetOrgId(MustGetSession(r))
+		uploadId := r.PathValue("id")
+		newOffset, err := uploads.Append(orgId, uploadId, offset, r.Body)
+		w.Header().Set(uploadOffsetHeader, strconv.FormatInt(newOffset, 10))
+
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.Is(err, pkg.ErrUploadTooLarge):
+			http.Error(w, "Chunk exceeds the length of the upload and was discarded. Resume from the received offset.", http.StatusRequestEntityTooLarge)
+			return
+		case errors.As(err, &maxErr):
+			msg := fmt.Sprintf("Chunk is larger than max allowed size (~%d MB). Resume from the received offset.", maxChunkSize)
+			http.Error(w, msg, http.StatusRequestEntityTooLarge)
+			return
+		case err != nil:
+			http.Error(w, "Could not append chunk", StoreErrorCode(err))
+			slog.ErrorContext(r.Context(), "Could not append chunk", "error", err, "uploadId", uploadId)
+			return
+		}
+
+		session, err := uploads.Get(orgId, uploadId)
+		if err != nil {
+			http.Error(w, "Upload not found", StoreErrorCode(err))
+			return
+		}
+		if !session.Complete() {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		finalizeUpload(w, r, submitter, uploads, timeout, session)
+	}
+}
+
+func finalizeUpload(w http.ResponseWriter, r *http.Request, submitter pkg.Submitter, uploads *pkg.UploadSessions, timeout time.Duration, session *pkg.UploadSession) {
+	file, err := uploads.Open(session.OrgId, session.Id)
+	if err != nil {
+		http.Error(w, "Could not read upload", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Could not read upload", "error", err, "uploadId", session.Id)
+		return
+	}
+	defer file.Close()
+
+	// The session is kept on failure, such that the client can retry by sending an empty chunk at the final offset
+	if submitScore(w, r, submitter, nil, timeout, file, session.MetaData, session.Assignments) {
+		if err := uploads.Remove(session.OrgId, session.Id); err != nil {
+			slog.ErrorContext(r.Context(), "Could not remove completed upload", "error", err, "uploadId", session.Id)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func uploadForm() string {
+	assignments, _ := json.Marshal([]pkg.Assignment{{Id: "Part1", From: 1, To: 5}, {Id: "Part2", From: 6, To: 10}})
+	meta, _ := json.Marshal(pkg.MetaData{Title: "Brandenburg Concerto No. 3", Composer: "Johan Sebastian Bach"})
+	return url.Values{"assignments": {string(assignments)}, "metadata": {string(meta)}}.Encode()
+}
+
+func TestResumableUpload(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	store.RegisterOrganization(context.Background(), &pkg.Organization{Id: "orgId"})
+	uploads := pkg.NewUploadSessions(t.TempDir(), time.Hour)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+RouteResourcesUploads, CreateUploadHandler(uploads, 10))
+	mux.HandleFunc("PATCH "+RouteResourcesUploadsId, AppendUploadHandler(store, uploads, time.Second, 1))
+
+	var pdf bytes.Buffer
+	pkg.CreateNPagePdf(&pdf, 10)
+	content := pdf.Bytes()
+
+	create := func(length int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", RouteResourcesUploads, strings.NewReader(uploadForm()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(uploadLengthHeader, strconv.Itoa(length))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, withAuthSession(req, "orgId"))
+		return rec
+	}
+	patch := func(location string, offset int, chunk []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", location, bytes.NewReader(chunk))
+		req.Header.Set("Content-Type", uploadChunkType)
+		req.Header.Set(uploadOffsetHeader, strconv.Itoa(offset))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, withAuthSession(req, "orgId"))
+		return rec
+	}
+
+	rec := create(len(content))
+	testutils.AssertEqual(t, rec.Code, http.StatusCreated)
+	location := rec.Header().Get("Location")
+	testutils.AssertEqual(t, strings.HasPrefix(location, RouteResourcesUploads+"/"), true)
+
+	half := len(content) / 2
+	rec = patch(location, 0, content[:half])
+	testutils.AssertEqual(t, rec.Code, http.StatusNoContent)
+	testutils.AssertEqual(t, rec.Header().Get(uploadOffsetHeader), strconv.Itoa(half))
+
+	rec = patch(location, 0, content[half:])
+	testutils.AssertEqual(t, rec.Code, http.StatusConflict)
+	testutils.AssertEqual(t, rec.Header().Get(uploadOffsetHeader), strconv.Itoa(half))
+
+	rec = patch(location, half, content[half:])
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), string(EventResourceUploaded))
+	testutils.AssertEqual(t, len(store.Data["orgId"].Data), 2)
+
+	rec = patch(location, len(content), nil)
+	testutils.AssertEqual(t, rec.Code, http.StatusNotFound)
+
+	t.Run("Too large", func(t *testing.T) {
+		testutils.AssertEqual(t, create(11<<20).Code, http.StatusRequestEntityTooLarge)
+	})
+
+	t.Run("Chunk beyond length", func(t *testing.T) {
+		location := create(len(content)).Header().Get("Location")
+		rec := patch(location, 0, append(slices.Clone(content), 'x'))
+		testutils.AssertEqual(t, rec.Code, http.StatusRequestEntityTooLarge)
+		testutils.AssertEqual(t, rec.Header().Get(uploadOffsetHeader), "0")
+
+		rec = patch(location, 0, content)
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	})
+
+	t.Run("Wrong content type", func(t *testing.T) {
+		location := create(10).Header().Get("Location")
+		req := httptest.NewRequest("PATCH", location, strings.NewReader("a"))
+		req.Header.Set(uploadOffsetHeader, "0")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, withAuthSession(req, "orgId"))
+		testutils.AssertEqual(t, rec.Code, http.StatusUnsupportedMediaType)
+	})
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+	"github.com/gorilla/sessions"
+)
+
+// The session cookie refers to the record in the session registry with this id
+const sessionIdKey = "sessionId"
+
+// The session cookie remembers the session generation of the user when it was issued
+const sessionGenerationKey = "sessionGeneration"
+
+// sessionSeenInterval is how often the time a session was last seen is written to the registry
+const sessionSeenInterval = 5 * time.Minute
+
+// signOutSession removes the values identifying the user, such that the request continues as a visitor
+func signOutSession(session *sessions.Session) {
+	for _, key := range []string{"userId", "role", "orgId", sessionIdKey, sessionGenerationKey, sessionPasskeyKey, sessionPasskeyCheckedOrgKey} {
+		delete(session.Values, key)
+	}
+}
+
+// regenerateSessionId revokes the record the session refers to and registers a new record for the user. A session
+// id known before the user signed in, for instance from a shared computer, can then no longer be used
+func regenerateSessionId(ctx context.Context, registry pkg.SessionRegistry, r *http.Request, session *sessions.Session, userId string) error {
+	if id, ok := session.Values[sessionIdKey].(string); ok {
+		previous, err := registry.Session(ctx, id)
+		if err == nil {
+			err = registry.RevokeSession(ctx, previous.UserId, id)
+		}
+		if err != nil && !errors.Is(err, pkg.ErrUserSessionNotFound) {
+			return err
+		}
+		delete(session.Values, sessionIdKey)
+	}
+
+	record := pkg.NewUserSession(userId, r.UserAgent(), getIp(r))
+	if err := registry.RegisterSession(ctx, record); err != nil {
+		return err
+	}
+	session.Values[sessionIdKey] = record.Id
+	return nil
+}
+
+// revokeUserSessions revokes every session of the user. The browsers of the sessions are signed out on their next
+// request
+func revokeUserSessions(ctx context.Context, registry pkg.SessionRegistry, userId string) error {
+	userSessions, err := registry.Sessions(ctx, userId)
+	if err != nil {
+		return err
+	}
+	for _, s := range userSessions {
+		if err := registry.RevokeSession(ctx, userId, s.Id); err != nil && !errors.Is(err, pkg.ErrUserSessionNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// trackSession registers sessions that are not yet in the registry and reports whether the session was revoked
+func trackSession(ctx context.Context, registry pkg.SessionRegistry, r *http.Request, userId string) (revoked, changed bool) {
+	session := MustGetSession(r)
+	generation, err := registry.SessionGeneration(ctx, userId)
+	if err != nil {
+		// The session is kept when the registry is unavailable
+		slog.ErrorContext(ctx, "Could not look up session generation", "error", err, "userId", userId)
+		return false, false
+	}
+	if sessionGeneration, _ := session.Values[sessionGenerationKey].(int64); sessionGeneration < generation {
+		return true, true
+	}
+
+	id, ok := session.Values[sessionIdKey].(string)
+	if !ok {
+		record := pkg.NewUserSession(userId, r.UserAgent(), getIp(r))
+		if err := registry.RegisterSession(ctx, record); err != nil {
+			slog.ErrorContext(ctx, "Could not register session", "error", err, "userId", userId)
+			return false, false
+		}
+		session.Values[sessionIdKey] = record.Id
+		return false, true
+	}
+
+	record, err := registry.Session(ctx, id)
+	switch {
+	case errors.Is(err, pkg.ErrUserSessionNotFound) || (err == nil && record.UserId != userId):
+		return true, true
+	case err != nil:
+		// The session is kept when the registry is unavailable
+		slog.ErrorContext(ctx, "Could not look up session", "error", err, "userId", userId)
+		return false, false
+	}
+
+	if now := time.Now(); now.Sub(record.LastSeenAt) >= sessionSeenInterval {
+		record.LastSeenAt = now
+		record.IP = getIp(r)
+		if err := registry.RegisterSession(ctx, &record); err != nil {
+			slog.ErrorContext(ctx, "Could not update session", "error", err, "userId", userId)
+		}
+	}
+	return false, false
+}
+
+// TrackSession keeps a record of each signed in session in the registry. Sessions signed in before the registry
+// existed are registered on their next request. Requests with a session that has been revoked continue as if the
+// user had signed out
+func TrackSession(registry pkg.SessionRegistry, timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session := MustGetSession(r)
+			userId, ok := session.Values["userId"].(string)
+			_, isApiToken := session.Values[sessionApiTokenKey]
+			if !ok || isApiToken {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			revoked, changed := trackSession(ctx, registry, r, userId)
+			cancel()
+			if revoked {
+				slog.InfoContext(r.Context(), "Session was revoked. Signing out", "userId", userId)
+				signOutSession(session)
+			}
+			if changed {
+				trySaveSession(session, r, w)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SessionsHandler lists the active sessions of the signed in user. Sessions that have not been seen for longer
+// than the lifetime of the session cookie have expired and are not shown
+func SessionsHandler(registry pkg.SessionRegistry, config *pkg.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), config.Timeout)
+		defer cancel()
+
+		session := MustGetSession(r)
+		userId := MustGetUserId(session)
+		userSessions, err := registry.Sessions(ctx, userId)
+		if err != nil {
+			http.Error(w, "Could not fetch sessions", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch sessions", "error", err, "userId", userId)
+			return
+		}
+
+		if config.SessionMaxAge > 0 {
+			expiredBefore := time.Now().Add(-time.Duration(config.SessionMaxAge) * time.Second)
+			userSessions = slices.DeleteFunc(userSessions, func(s pkg.UserSession) bool { return s.LastSeenAt.Before(expiredBefore) })
+		}
+
+		currentId, _ := session.Values[sessionIdKey].(string)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		web.UserSessions(w, pkg.LanguageFromReq(r), web.UserSessionsData{Sessions: userSessions, CurrentId: currentId})
+	}
+}
+
+// RevokeSessionHandler signs out one of the sessions of the signed in user. The browser of the session is signed
+// out on its next request
+func RevokeSessionHandler(registry pkg.SessionRegistry, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		userId := MustGetUserId(MustGetSession(r))
+		id := r.PathValue("id")
+		if err := registry.RevokeSession(ctx, userId, id); err != nil {
+			http.Error(w, "Could not revoke session", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not revoke session", "error", err, "userId", userId)
+			return
+		}
+
+		slog.InfoContext(ctx, "Revoked session", "userId", userId)
+		HxTrigger(w, EventSessionsUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.session-revoked", nil)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// SignOutHandler revokes the session in the registry before the session cookie is cleared. With everywhere=true
+// a new session generation is started, which signs out every browser of the user on its next request
+func SignOutHandler(registry pkg.SessionRegistry, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := MustGetSession(r)
+		userId, hasUserId := session.Values["userId"].(string)
+		id, hasId := session.Values[sessionIdKey].(string)
+		if hasUserId && r.FormValue("everywhere") == "true" {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			if _, err := registry.NewSessionGeneration(ctx, userId); err != nil {
+				http.Error(w, "Could not sign out from all devices", StoreErrorCode(err))
+				slog.ErrorContext(ctx, "Could not start new session generation", "error", err, "userId", userId)
+				return
+			}
+
+			// The records are removed such that the signed out devices are no longer listed
+			if err := revokeUserSessions(ctx, registry, userId); err != nil {
+				slog.ErrorContext(ctx, "Could not revoke sessions on sign out", "error", err, "userId", userId)
+			}
+			slog.InfoContext(ctx, "Signed out from all devices", "userId", userId)
+			w.Header().Set("HX-Redirect", "/")
+		} else if hasUserId && hasId {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			err := registry.RevokeSession(ctx, userId, id)
+			cancel()
+			if err != nil && !errors.Is(err, pkg.ErrUserSessionNotFound) {
+				slog.ErrorContext(r.Context(), "Could not revoke session on sign out", "error", err, "userId", userId)
+			}
+		}
+		SignOut(w, r)
+	}
+}
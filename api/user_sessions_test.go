@@ -0,0 +1,263 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/gorilla/sessions"
+)
+
+type failingSessionRegistry struct {
+	pkg.SessionRegistry
+}
+
+func (f *failingSessionRegistry) Session(ctx context.Context, id string) (pkg.UserSession, error) {
+	return pkg.UserSession{}, errors.New("registry is down")
+}
+
+// withSignedInSession returns a request with an admin session of user 0000-0000
+func withSignedInSession(r *http.Request, orgId string) *http.Request {
+	r = withAuthSession(r, orgId)
+	MustGetSession(r).Values["userId"] = "0000-0000"
+	return r
+}
+
+func TestTrackSession(t *testing.T) {
+	var seenUserId any
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenUserId = MustGetSession(r).Values["userId"]
+	})
+
+	serve := func(registry pkg.SessionRegistry, req *http.Request) *httptest.ResponseRecorder {
+		seenUserId = nil
+		rec := httptest.NewRecorder()
+		TrackSession(registry, time.Second)(handler).ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("registers new session", func(t *testing.T) {
+		store := pkg.NewMultiOrgInMemoryStore()
+		req := withSignedInSession(httptest.NewRequest("GET", "/", nil), "org1")
+		req.Header.Set("User-Agent", "Firefox")
+		rec := serve(store, req)
+		testutils.AssertEqual(t, seenUserId, any("0000-0000"))
+		testutils.AssertEqual(t, len(rec.Result().Cookies()), 1)
+
+		id, ok := MustGetSession(req).Values[sessionIdKey].(string)
+		testutils.AssertEqual(t, ok, true)
+		record, err := store.Session(context.Background(), id)
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, record.UserId, "0000-0000")
+		testutils.AssertEqual(t, record.UserAgent, "Firefox")
+	})
+
+	t.Run("known session", func(t *testing.T) {
+		store := pkg.NewMultiOrgInMemoryStore()
+		seen := time.Now().Add(-time.Minute)
+		testutils.AssertNil(t, store.RegisterSession(context.Background(), &pkg.UserSession{Id: "laptop", UserId: "0000-0000", LastSeenAt: seen}))
+		req := withSignedInSession(httptest.NewRequest("GET", "/", nil), "org1")
+		MustGetSession(req).Values[sessionIdKey] = "laptop"
+		rec := serve(store, req)
+		testutils.AssertEqual(t, seenUserId, any("0000-0000"))
+		testutils.AssertEqual(t, len(rec.Result().Cookies()), 0)
+
+		// Last seen is only updated after an interval
+		testutils.AssertEqual(t, store.UserSessions["laptop"].LastSeenAt, seen)
+		store.UserSessions["laptop"] = pkg.UserSession{Id: "laptop", UserId: "0000-0000", LastSeenAt: seen.Add(-time.Hour)}
+		serve(store, req)
+		testutils.AssertEqual(t, store.UserSessions["laptop"].LastSeenAt.After(seen), true)
+	})
+
+	t.Run("revoked session is signed out", func(t *testing.T) {
+		store := pkg.NewMultiOrgInMemoryStore()
+		testutils.AssertNil(t, store.RegisterSession(context.Background(), &pkg.UserSession{Id: "other", UserId: "1111-1111"}))
+		for _, id := range []string{"revoked", "other"} {
+			req := withSignedInSession(httptest.NewRequest("GET", "/", nil), "org1")
+			MustGetSession(req).Values[sessionIdKey] = id
+			rec := serve(store, req)
+			testutils.AssertEqual(t, seenUserId, nil)
+			testutils.AssertEqual(t, len(rec.Result().Cookies()), 1)
+			_, hasRole := MustGetSession(req).Values["role"]
+			testutils.AssertEqual(t, hasRole, false)
+		}
+	})
+
+	t.Run("visitors and API tokens are not tracked", func(t *testing.T) {
+		store := pkg.NewMultiOrgInMemoryStore()
+		serve(store, withEmptySession(httptest.NewRequest("GET", "/", nil)))
+
+		req := withSignedInSession(httptest.NewRequest("GET", "/", nil), "org1")
+		MustGetSession(req).Values[sessionApiTokenKey] = "token"
+		serve(store, req)
+		testutils.AssertEqual(t, len(store.UserSessions), 0)
+	})
+
+	t.Run("session of an earlier generation", func(t *testing.T) {
+		store := pkg.NewMultiOrgInMemoryStore()
+		generation, err := store.NewSessionGeneration(context.Background(), "0000-0000")
+		testutils.AssertNil(t, err)
+
+		req := withSignedInSession(httptest.NewRequest("GET", "/", nil), "org1")
+		MustGetSession(req).Values[sessionGenerationKey] = generation - 1
+		serve(store, req)
+		testutils.AssertEqual(t, seenUserId, nil)
+		testutils.AssertEqual(t, len(store.UserSessions), 0)
+
+		req = withSignedInSession(httptest.NewRequest("GET", "/", nil), "org1")
+		MustGetSession(req).Values[sessionGenerationKey] = generation
+		serve(store, req)
+		testutils.AssertEqual(t, seenUserId, any("0000-0000"))
+	})
+
+	t.Run("registry failure keeps session", func(t *testing.T) {
+		req := withSignedInSession(httptest.NewRequest("GET", "/", nil), "org1")
+		MustGetSession(req).Values[sessionIdKey] = "laptop"
+		serve(&failingSessionRegistry{pkg.NewMultiOrgInMemoryStore()}, req)
+		testutils.AssertEqual(t, seenUserId, any("0000-0000"))
+	})
+}
+
+func TestRevokedSessionIsDenied(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.RegisterUser(context.Background(), &pkg.UserInfo{Id: "0000-0000"}))
+	testutils.AssertNil(t, store.RegisterRole(context.Background(), "0000-0000", "org1", pkg.RoleAdmin))
+	cookie := sessions.NewCookieStore([]byte("key"))
+	route := RequireRead(store, pkg.NewDefaultConfig(), cookie, &sessions.Options{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := httptest.NewRequest("GET", "/route", nil)
+	session, err := cookie.Get(request, AuthSession)
+	testutils.AssertNil(t, err)
+	session.Values = MustGetSession(withSignedInSession(request, "org1")).Values
+	request = request.WithContext(context.WithValue(request.Context(), sessionKey, session))
+
+	rec := httptest.NewRecorder()
+	route.ServeHTTP(rec, request)
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+
+	id := session.Values[sessionIdKey].(string)
+	testutils.AssertNil(t, store.RevokeSession(context.Background(), "0000-0000", id))
+	rec = httptest.NewRecorder()
+	route.ServeHTTP(rec, request)
+	testutils.AssertEqual(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestSessionsHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	now := time.Now()
+	for _, session := range []pkg.UserSession{
+		{Id: "laptop", UserId: "0000-0000", LastSeenAt: now},
+		{Id: "phone", UserId: "0000-0000", LastSeenAt: now.Add(-time.Hour)},
+		{Id: "expired", UserId: "0000-0000", LastSeenAt: now.Add(-60 * 24 * time.Hour)},
+		{Id: "other", UserId: "1111-1111", LastSeenAt: now},
+	} {
+		testutils.AssertNil(t, store.RegisterSession(ctx, &session))
+	}
+	config := pkg.NewDefaultConfig()
+	config.SessionMaxAge = 30 * 24 * 3600
+
+	req := withSignedInSession(httptest.NewRequest("GET", RouteSessions, nil), "org1")
+	MustGetSession(req).Values[sessionIdKey] = "laptop"
+	rec := httptest.NewRecorder()
+	SessionsHandler(store, config)(rec, req)
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Body.String(), "this device", `hx-delete="/sessions/phone"`)
+	testutils.AssertNotContains(t, rec.Body.String(), `hx-delete="/sessions/laptop"`, "/sessions/expired", "/sessions/other")
+}
+
+func TestRevokeSessionHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterSession(ctx, &pkg.UserSession{Id: "phone", UserId: "0000-0000"}))
+	testutils.AssertNil(t, store.RegisterSession(ctx, &pkg.UserSession{Id: "other", UserId: "1111-1111"}))
+	handler := RevokeSessionHandler(store, time.Second)
+
+	revoke := func(id string) *httptest.ResponseRecorder {
+		req := withSignedInSession(httptest.NewRequest("DELETE", "/sessions/"+id, nil), "org1")
+		req.SetPathValue("id", id)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	rec := revoke("phone")
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertContains(t, rec.Header().Get("HX-Trigger"), string(EventSessionsUpdated), "The device was signed out")
+	_, err := store.Session(ctx, "phone")
+	testutils.AssertEqual(t, errors.Is(err, pkg.ErrUserSessionNotFound), true)
+
+	testutils.AssertEqual(t, revoke("phone").Code, http.StatusNotFound)
+	testutils.AssertEqual(t, revoke("other").Code, http.StatusNotFound)
+	_, err = store.Session(ctx, "other")
+	testutils.AssertNil(t, err)
+}
+
+func TestSignOutHandlerRevokesSession(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.RegisterSession(context.Background(), &pkg.UserSession{Id: "laptop", UserId: "0000-0000"}))
+
+	req := withSignedInSession(httptest.NewRequest("POST", RouteLogout, nil), "org1")
+	MustGetSession(req).Values[sessionIdKey] = "laptop"
+	rec := httptest.NewRecorder()
+	SignOutHandler(store, time.Second)(rec, req)
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, len(store.UserSessions), 0)
+}
+
+func TestSignOutEverywhere(t *testing.T) {
+	ctx := context.Background()
+	store := pkg.NewMultiOrgInMemoryStore()
+	for _, id := range []string{"laptop", "phone"} {
+		testutils.AssertNil(t, store.RegisterSession(ctx, &pkg.UserSession{Id: id, UserId: "0000-0000"}))
+	}
+
+	req := withSignedInSession(httptest.NewRequest("POST", RouteLogout, strings.NewReader("everywhere=true")), "org1")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	MustGetSession(req).Values[sessionIdKey] = "laptop"
+	rec := httptest.NewRecorder()
+	SignOutHandler(store, time.Second)(rec, req)
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+	testutils.AssertEqual(t, rec.Header().Get("HX-Redirect"), "/")
+	testutils.AssertEqual(t, len(store.UserSessions), 0)
+
+	generation, err := store.SessionGeneration(ctx, "0000-0000")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, generation > 0, true)
+}
+
+func TestRegenerateSessionId(t *testing.T) {
+	ctx := context.Background()
+	store := pkg.NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.RegisterSession(ctx, &pkg.UserSession{Id: "planted", UserId: "1111-1111"}))
+
+	req := withEmptySession(httptest.NewRequest("GET", "/", nil))
+	session := MustGetSession(req)
+	session.Values[sessionIdKey] = "planted"
+	testutils.AssertNil(t, regenerateSessionId(ctx, store, req, session, "0000-0000"))
+
+	_, err := store.Session(ctx, "planted")
+	testutils.AssertEqual(t, errors.Is(err, pkg.ErrUserSessionNotFound), true)
+
+	id := session.Values[sessionIdKey].(string)
+	record, err := store.Session(ctx, id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, record.UserId, "0000-0000")
+
+	t.Run("unknown session id", func(t *testing.T) {
+		session.Values[sessionIdKey] = "unknown"
+		testutils.AssertNil(t, regenerateSessionId(ctx, store, req, session, "0000-0000"))
+		testutils.AssertEqual(t, session.Values[sessionIdKey] != "unknown", true)
+	})
+
+	t.Run("registry unavailable", func(t *testing.T) {
+		err := regenerateSessionId(ctx, &failingSessionRegistry{store}, req, session, "0000-0000")
+		testutils.AssertEqual(t, err != nil, true)
+	})
+}
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"os"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/davidkleiven/caesura/pkg"
@@ -107,6 +108,39 @@ func CodeAndMessage(err error, code int) (string, int) {
 	return msg, finalCode
 }
 
+// StoreErrorCode maps errors returned by the stores to the HTTP status code reported to the client
+func StoreErrorCode(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case pkg.IsNotFound(err):
+		return http.StatusNotFound
+	case pkg.IsConflict(err):
+		return http.StatusConflict
+	case pkg.IsInvalidInput(err):
+		return http.StatusBadRequest
+	case errors.Is(err, pkg.ErrStoreUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// etagMatches reports whether the If-None-Match header holds the entity tag. The comparison is weak, such that
+// W/"abc" matches "abc"
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for candidate := range strings.SplitSeq(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
 func organizationIds(session *sessions.Session) []string {
 	roles, ok := session.Values["role"].([]byte)
 	if !ok {
@@ -126,41 +160,61 @@ func organizationIds(session *sessions.Session) []string {
 	return result
 }
 
+// Tokens of all kinds are signed with the same key, so each kind has its own audience. A token of another kind,
+// such as a guest link, would otherwise be accepted as an invitation to the organization in it
+const inviteTokenAudience = "caesura-invite"
+
 type InviteClaim struct {
 	OrgId string `json:"org_id"`
+
+	// Set for invitations sent by email, which give a role and a group to the invited user
+	InvitationId string `json:"invitation_id,omitempty"`
+
+	// Set for invite links, which can be revoked by the admins
+	LinkId string `json:"link_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// orgIdFromInviteToken return true if the role was changed, false otherwise
-func orgIdFromInviteToken(session *sessions.Session, signSecret string) (string, error) {
+// inviteFromToken returns the claim of the invite token in the session. The claim is empty when the session has
+// no invite token
+func inviteFromToken(session *sessions.Session, signSecret string) (InviteClaim, error) {
 	token, ok := session.Values[inviteTokenKey].(string)
 	if !ok {
-		return "", nil
+		return InviteClaim{}, nil
 	}
 
-	parsedToken, err := jwt.ParseWithClaims(token, &InviteClaim{}, func(t *jwt.Token) (interface{}, error) {
+	var claims InviteClaim
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
 		return []byte(signSecret), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(inviteTokenAudience))
 
 	if err != nil {
 		slog.Error("Error when parsing invite token", "error", err)
-		return "", err
+		return InviteClaim{}, err
 	}
 
-	claims, ok := parsedToken.Claims.(*InviteClaim)
-	if !ok || claims.OrgId == "" {
-		slog.Error("Could not cast parsed token into InviteClaim", "error", err, "orgId", claims.OrgId)
-		return "", fmt.Errorf("could not parse token")
+	if claims.OrgId == "" {
+		slog.Error("Invite token does not contain an organization")
+		return InviteClaim{}, fmt.Errorf("could not parse token")
 	}
-	delete(session.Values, "invite-token")
-	return claims.OrgId, nil
+	delete(session.Values, inviteTokenKey)
+	return claims, nil
 }
 
 func emailFromResetPasswordJwt(token string, signSecret string) (string, error) {
+	return emailFromJwt(token, "", signSecret)
+}
+
+// emailFromJwt returns the email of a token signed with signedEmailToken. Tokens issued for another purpose
+// than the subject are rejected
+func emailFromJwt(token, subject, signSecret string) (string, error) {
 	var resetEmailToken ResetEmailToken
 	_, err := jwt.ParseWithClaims(token, &resetEmailToken, func(t *jwt.Token) (any, error) {
 		return []byte(signSecret), nil
 	})
+	if err == nil && resetEmailToken.Subject != subject {
+		err = fmt.Errorf("token was issued for %q", resetEmailToken.Subject)
+	}
 	return resetEmailToken.Email, err
 }
 
@@ -175,14 +229,23 @@ func parseForm(r *http.Request) (int, error) {
 	return http.StatusOK, nil
 }
 
+// LoginStore is used to sign in users and to register their sessions
+type LoginStore interface {
+	pkg.RoleStore
+	pkg.SessionRegistry
+}
+
 type SessionInitParams struct {
 	Ctx        context.Context
 	Session    *sessions.Session
 	User       *pkg.UserInfo
 	SignSecret string
-	Store      pkg.RoleStore
+	Store      LoginStore
 	Writer     http.ResponseWriter
 	Req        *http.Request
+
+	// The user signed in with a passkey
+	Passkey bool
 }
 
 type SessionInitResult struct {
@@ -195,31 +258,69 @@ func NewSessionInitResult() SessionInitResult {
 }
 
 func InitializeUserSession(p SessionInitParams) SessionInitResult {
-	inviteTokenOrg, err := orgIdFromInviteToken(p.Session, p.SignSecret)
+	invite, err := inviteFromToken(p.Session, p.SignSecret)
 	if err != nil {
 		return SessionInitResult{Error: err, ReturnCode: http.StatusBadRequest}
 	}
 	p.Session.Values["userId"] = p.User.Id
+	unverifiedEmail := p.User.Email != "" && !p.User.VerifiedEmail
 
 	roleUpdater := pkg.NewUserRolePipeline(p.Store, p.Ctx, p.User).
-		RegisterIfMissing().
-		AssignViewRoleIfNoRole(inviteTokenOrg)
+		CheckInviteLink(invite.OrgId, invite.LinkId).
+		RegisterIfMissing()
+	wasMember := roleUpdater.Error == nil && hasRole(roleUpdater.User, invite.OrgId)
+	roleUpdater.
+		AcceptInvitation(invite.OrgId, invite.InvitationId, time.Now()).
+		AssignViewRoleIfNoRole(invite.OrgId)
 
 	if roleUpdater.Error != nil {
+		code := StoreErrorCode(roleUpdater.Error)
+		if errors.Is(roleUpdater.Error, pkg.ErrInvitationEmailMismatch) || errors.Is(roleUpdater.Error, pkg.ErrInviteLinkRevoked) {
+			code = http.StatusForbidden
+		}
 		return SessionInitResult{
 			Error:      fmt.Errorf("Role update pipeline failed %s: %w", p.User.Id, roleUpdater.Error),
-			ReturnCode: http.StatusInternalServerError}
+			ReturnCode: code}
 	}
 
 	userInfoWithRoles := roleUpdater.User
+	if invite.OrgId != "" && !wasMember && hasRole(userInfoWithRoles, invite.OrgId) {
+		recordEvent(p.Ctx, pkg.NewEvent(pkg.EventUserJoined, invite.OrgId, userInfoWithRoles.Id, userInfoWithRoles.Id))
+	}
 	pkg.PopulateSessionWithRoles(p.Session, userInfoWithRoles)
+	p.Session.Values[sessionRefreshedAtKey] = time.Now().Unix()
+	p.Session.Values[sessionPasskeyKey] = p.Passkey
+	delete(p.Session.Values, sessionPasskeyCheckedOrgKey)
 	delete(p.Session.Values, inviteTokenKey)
+
+	generation, err := p.Store.SessionGeneration(p.Ctx, userInfoWithRoles.Id)
+	if err != nil {
+		return SessionInitResult{Error: fmt.Errorf("could not fetch session generation: %w", err), ReturnCode: StoreErrorCode(err)}
+	}
+	p.Session.Values[sessionGenerationKey] = generation
+
+	// The session gets a new id, such that a session id planted before the sign in is not carried over
+	if err := regenerateSessionId(p.Ctx, p.Store, p.Req, p.Session, userInfoWithRoles.Id); err != nil {
+		// The session is registered as a new session of the user on the next request
+		slog.ErrorContext(p.Ctx, "Could not regenerate session id", "error", err, "userId", userInfoWithRoles.Id)
+		delete(p.Session.Values, sessionIdKey)
+	}
+	if unverifiedEmail {
+		p.Session.Values[sessionUnverifiedEmailKey] = true
+	} else {
+		delete(p.Session.Values, sessionUnverifiedEmailKey)
+	}
 	if err := p.Session.Save(p.Req, p.Writer); err != nil {
 		return SessionInitResult{Error: err, ReturnCode: http.StatusInternalServerError}
 	}
 	return NewSessionInitResult()
 }
 
+func hasRole(user *pkg.UserInfo, orgId string) bool {
+	_, ok := user.Roles[orgId]
+	return ok
+}
+
 func validEmail(email string) bool {
 	regex := regexp.MustCompile("^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+.[a-zA-Z]{2,}$")
 	return regex.MatchString(email)
@@ -231,10 +332,17 @@ type ResetEmailToken struct {
 }
 
 func SignedResetToken(email, signKey string, expiryTime time.Duration) (string, error) {
+	return signedEmailToken(email, "", signKey, expiryTime)
+}
+
+// signedEmailToken returns a token proving that the holder received an email sent to the address. The subject
+// tells what the token may be used for
+func signedEmailToken(email, subject, signKey string, expiryTime time.Duration) (string, error) {
 	currentTime := time.Now()
 	resetPaswordClaim := ResetEmailToken{
 		Email: email,
 		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
 			ExpiresAt: jwt.NewNumericDate(currentTime.Add(expiryTime)),
 			IssuedAt:  jwt.NewNumericDate(currentTime),
 			NotBefore: jwt.NewNumericDate(currentTime),
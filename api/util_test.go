@@ -1,13 +1,16 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -238,6 +241,7 @@ func TestOrganizationIdFromInviteToken(t *testing.T) {
 		claims := InviteClaim{
 			OrgId: orgId,
 			RegisteredClaims: jwt.RegisteredClaims{
+				Audience:  jwt.ClaimStrings{inviteTokenAudience},
 				ExpiresAt: jwt.NewNumericDate(currentTime.Add(48 * time.Hour)),
 			},
 		}
@@ -262,14 +266,14 @@ func TestOrganizationIdFromInviteToken(t *testing.T) {
 			session.Values = map[any]any{"invite-token": signedToken}
 		}
 
-		extractedOrgId, err := orgIdFromInviteToken(&session, signSecret)
+		invite, err := inviteFromToken(&session, signSecret)
 
 		if err == nil && test.wantErr {
 			t.Fatalf("Did not expect an error got %v", err)
 		}
 
-		if extractedOrgId != test.expectedId {
-			t.Fatalf("Wanted '%s' got '%s", extractedOrgId, test.expectedId)
+		if invite.OrgId != test.expectedId {
+			t.Fatalf("Wanted '%s' got '%s", invite.OrgId, test.expectedId)
 		}
 	}
 }
@@ -287,9 +291,9 @@ func TestEmptyOrIdOnInvalidClaim(t *testing.T) {
 		Values: map[any]any{"invite-token": signedToken},
 	}
 
-	orgId, err := orgIdFromInviteToken(&session, secretKey)
-	if orgId != "" {
-		t.Fatalf("Wanted empty organization id got %s", orgId)
+	invite, err := inviteFromToken(&session, secretKey)
+	if invite.OrgId != "" {
+		t.Fatalf("Wanted empty organization id got %s", invite.OrgId)
 	}
 
 	if err == nil {
@@ -297,6 +301,46 @@ func TestEmptyOrIdOnInvalidClaim(t *testing.T) {
 	}
 }
 
+func TestInviteTokenRejectsOtherTokens(t *testing.T) {
+	signSecret := "top-secret"
+	expires := time.Now().Add(time.Hour)
+	guest, err := SignedGuestToken("org", "grant", signSecret, expires)
+	testutils.AssertNil(t, err)
+	sharedPart, err := SignedSharedPartToken("org", "resource", "Horn.pdf", signSecret, expires)
+	testutils.AssertNil(t, err)
+	archive, err := SignedArchiveToken("org", "archive", signSecret, expires)
+	testutils.AssertNil(t, err)
+
+	for _, test := range []struct {
+		desc  string
+		token string
+	}{
+		{"guest link", guest},
+		{"shared part", sharedPart},
+		{"archive", archive},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			session := sessions.Session{Values: map[any]any{"invite-token": test.token}}
+			invite, err := inviteFromToken(&session, signSecret)
+			testutils.AssertEqual(t, err != nil, true)
+			testutils.AssertEqual(t, invite.OrgId, "")
+		})
+	}
+
+	t.Run("invite as other tokens", func(t *testing.T) {
+		link, err := signedInviteURL("", signSecret, InviteClaim{OrgId: "org"}, expires)
+		testutils.AssertNil(t, err)
+		token, err := url.QueryUnescape(strings.TrimPrefix(link, "/login?invite-token="))
+		testutils.AssertNil(t, err)
+		_, err = parseGuestToken(token, signSecret)
+		testutils.AssertEqual(t, err != nil, true)
+		_, err = parseSharedPartToken(token, signSecret)
+		testutils.AssertEqual(t, err != nil, true)
+		_, err = parseArchiveToken(token, signSecret)
+		testutils.AssertEqual(t, err != nil, true)
+	})
+}
+
 func TestMustGetUserInfo(t *testing.T) {
 	session := sessions.Session{
 		Values: make(map[any]any),
@@ -405,3 +449,41 @@ func TestEmailFromResetPasswordToken(t *testing.T) {
 		testutils.AssertNil(t, err)
 	})
 }
+
+func TestStoreErrorCode(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want int
+	}{
+		{err: nil, want: http.StatusOK},
+		{err: errors.Join(pkg.ErrResourceMetadataNotFound, errors.New("id")), want: http.StatusNotFound},
+		{err: pkg.ErrFileNotInZipArchive, want: http.StatusNotFound},
+		{err: pkg.ErrResourceProtected, want: http.StatusConflict},
+		{err: pkg.ErrDomainInUse, want: http.StatusConflict},
+		{err: pkg.ErrInvalidMetaDataPatch, want: http.StatusBadRequest},
+		{err: errors.Join(pkg.ErrStoreUnavailable, errors.New("down")), want: http.StatusServiceUnavailable},
+		{err: fmt.Errorf("fetch: %w", context.DeadlineExceeded), want: http.StatusGatewayTimeout},
+		{err: errors.New("unknown"), want: http.StatusInternalServerError},
+	} {
+		t.Run(fmt.Sprintf("%v", test.err), func(t *testing.T) {
+			testutils.AssertEqual(t, StoreErrorCode(test.err), test.want)
+		})
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	for _, test := range []struct {
+		header, etag string
+		want         bool
+	}{
+		{`"abc"`, `"abc"`, true},
+		{`"x", "abc"`, `"abc"`, true},
+		{`W/"abc"`, `"abc"`, true},
+		{`"abc"`, `W/"abc"`, true},
+		{"*", `"abc"`, true},
+		{"", `"abc"`, false},
+		{`"abcd"`, `"abc"`, false},
+	} {
+		testutils.AssertEqual(t, etagMatches(test.header, test.etag), test.want)
+	}
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/net/webdav"
+)
+
+const (
+	webDAVTokenValidity = 180 * 24 * time.Hour
+	webDAVTokenAudience = "caesura-webdav"
+)
+
+type WebDAVStore interface {
+	pkg.LibraryStore
+	pkg.RoleGetter
+	pkg.OrganizationGetter
+	pkg.SessionRegistry
+}
+
+// WebDAVClaim grants read access to the library of an organization over WebDAV. The role of the user is
+// checked on every request, so removing the user from the organization revokes the token. Generation is the
+// session generation of the user when the token was issued, such that signing out from all devices revokes it.
+// Passkey tells whether the user signed in with a passkey when the token was issued
+type WebDAVClaim struct {
+	UserId     string `json:"user_id"`
+	OrgId      string `json:"org_id"`
+	Generation int64  `json:"generation"`
+	Passkey    bool   `json:"passkey"`
+	jwt.RegisteredClaims
+}
+
+func SignedWebDAVToken(claims WebDAVClaim, signSecret string, validity time.Duration) (string, error) {
+	currentTime := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Audience:  jwt.ClaimStrings{webDAVTokenAudience},
+		ExpiresAt: jwt.NewNumericDate(currentTime.Add(validity)),
+		IssuedAt:  jwt.NewNumericDate(currentTime),
+		NotBefore: jwt.NewNumericDate(currentTime),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(signSecret))
+}
+
+func parseWebDAVToken(token, signSecret string) (*WebDAVClaim, error) {
+	var claims WebDAVClaim
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		return []byte(signSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(webDAVTokenAudience), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if claims.UserId == "" || claims.OrgId == "" {
+		return nil, fmt.Errorf("token does not contain user and organization")
+	}
+	return &claims, nil
+}
+
+// WebDAVTokenResponse holds the URL of the library and the password used to mount it
+type WebDAVTokenResponse struct {
+	URL     string    `json:"url"`
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// WebDAVTokenHandler issues a token for the user and organization of the session. The token is used as
+// password when mounting the library, and the user name is ignored. Access tokens are short lived, hence they can
+// not issue WebDAV tokens that would outlive them
+func WebDAVTokenHandler(baseURL, signSecret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := MustGetSession(r)
+		if tokenId, _ := session.Values[sessionApiTokenKey].(string); strings.HasPrefix(tokenId, accessTokenIdPrefix) {
+			http.Error(w, "An access token can not issue WebDAV tokens", http.StatusForbidden)
+			return
+		}
+
+		userInfo := MustGetUserInfo(session)
+		generation, _ := session.Values[sessionGenerationKey].(int64)
+		passkey, _ := session.Values[sessionPasskeyKey].(bool)
+		claims := WebDAVClaim{UserId: userInfo.Id, OrgId: MustGetOrgId(session), Generation: generation, Passkey: passkey}
+		token, err := SignedWebDAVToken(claims, signSecret, webDAVTokenValidity)
+		if err != nil {
+			http.Error(w, "Failed to sign token", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Failed to sign WebDAV token", "error", err)
+			return
+		}
+
+		respBody := WebDAVTokenResponse{
+			URL:     strings.TrimSuffix(baseURL, "/") + RouteWebDAV,
+			Token:   token,
+			Expires: time.Now().Add(webDAVTokenValidity),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(respBody)
+	}
+}
+
+// WebDAVHandler serves the library of the organization in the token as a read-only WebDAV tree. Users only
+// see the parts that match their groups
+func WebDAVHandler(store WebDAVStore, signSecret string, timeout time.Duration) http.HandlerFunc {
+	lockSystem := webdav.NewMemLS()
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND":
+		default:
+			w.Header().Set("Allow", "OPTIONS, GET, HEAD, PROPFIND")
+			http.Error(w, "The library is read-only", http.StatusMethodNotAllowed)
+			return
+		}
+
+		_, token, ok := r.BasicAuth()
+		if !ok {
+			webDAVUnauthorized(w)
+			return
+		}
+		claims, err := parseWebDAVToken(token, signSecret)
+		if err != nil {
+			slog.InfoContext(r.Context(), "Rejected WebDAV token", "error", err)
+			webDAVUnauthorized(w)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		userInfo, err := store.GetUserInfo(ctx, claims.UserId)
+		if errors.Is(err, pkg.ErrUserNotFound) {
+			webDAVUnauthorized(w)
+			return
+		} else if err != nil {
+			http.Error(w, "Failed to fetch user", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Failed to fetch user", "error", err, "userId", claims.UserId)
+			return
+		}
+
+		if _, ok := userInfo.Roles[claims.OrgId]; !ok {
+			http.Error(w, "User is not a member of the organization", http.StatusForbidden)
+			return
+		}
+
+		generation, err := store.SessionGeneration(ctx, claims.UserId)
+		if err != nil {
+			http.Error(w, "Failed to check sessions", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not look up session generation", "error", err, "userId", claims.UserId)
+			return
+		}
+		if claims.Generation < generation {
+			slog.InfoContext(ctx, "Rejected WebDAV token issued before sign out", "userId", claims.UserId)
+			webDAVUnauthorized(w)
+			return
+		}
+
+		if !claims.Passkey {
+			required, err := orgRequiresPasskey(ctx, store, claims.OrgId)
+			if err != nil {
+				http.Error(w, "Could not check the sign in requirements of the organization", StoreErrorCode(err))
+				slog.ErrorContext(ctx, "Could not fetch organization", "error", err, "orgId", claims.OrgId)
+				return
+			}
+			if required {
+				http.Error(w, "The organization requires that you sign in with a passkey", http.StatusForbidden)
+				return
+			}
+		}
+
+		include := pkg.IncludeAll
+		if groups, ok := userInfo.Groups[claims.OrgId]; ok {
+			include = pkg.MatchAny(groups)
+		}
+
+		handler := webdav.Handler{
+			Prefix:     strings.TrimSuffix(RouteWebDAV, "/"),
+			FileSystem: pkg.NewLibraryFS(store, claims.OrgId, include),
+			LockSystem: lockSystem,
+			Logger: func(r *http.Request, err error) {
+				if err != nil {
+					slog.ErrorContext(r.Context(), "WebDAV request failed", "method", r.Method, "path", r.URL.Path, "error", err)
+				}
+			},
+		}
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+func webDAVUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="caesura"`)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func webDAVRequest(method, target, token string) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		req.SetBasicAuth("user", token)
+	}
+	return req
+}
+
+func TestWebDAVHandler(t *testing.T) {
+	store, resourceId := manifestStore(t)
+	store.Users = append(store.Users, pkg.UserInfo{
+		Id:     "user",
+		Roles:  map[string]pkg.RoleKind{"org": pkg.RoleViewer},
+		Groups: map[string][]string{"org": {"horn"}},
+	})
+	handler := WebDAVHandler(store, "secret", time.Second)
+	token, err := SignedWebDAVToken(WebDAVClaim{UserId: "user", OrgId: "org"}, "secret", time.Hour)
+	testutils.AssertNil(t, err)
+
+	t.Run("List resource", func(t *testing.T) {
+		req := webDAVRequest("PROPFIND", "/webdav/"+resourceId+"/", token)
+		req.Header.Set("Depth", "1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		testutils.AssertEqual(t, rec.Code, http.StatusMultiStatus)
+		testutils.AssertContains(t, rec.Body.String(), "Horn.pdf", "application/pdf")
+		testutils.AssertNotContains(t, rec.Body.String(), "Trumpet.pdf")
+	})
+
+	t.Run("Download part", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, webDAVRequest("GET", "/webdav/"+resourceId+"/Horn.pdf", token))
+		testutils.AssertEqual(t, rec.Code, http.StatusOK)
+		testutils.AssertEqual(t, rec.Body.String(), "horn")
+	})
+
+	t.Run("Part of other group", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, webDAVRequest("GET", "/webdav/"+resourceId+"/Trumpet.pdf", token))
+		testutils.AssertEqual(t, rec.Code, http.StatusNotFound)
+	})
+
+	t.Run("Write is rejected", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, webDAVRequest("PUT", "/webdav/"+resourceId+"/Flute.pdf", token))
+		testutils.AssertEqual(t, rec.Code, http.StatusMethodNotAllowed)
+	})
+
+	t.Run("Missing token", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, webDAVRequest("PROPFIND", "/webdav/", ""))
+		testutils.AssertEqual(t, rec.Code, http.StatusUnauthorized)
+		testutils.AssertContains(t, rec.Header().Get("WWW-Authenticate"), "Basic")
+	})
+
+	t.Run("Wrong signature", func(t *testing.T) {
+		forged, err := SignedWebDAVToken(WebDAVClaim{UserId: "user", OrgId: "org"}, "other-secret", time.Hour)
+		testutils.AssertNil(t, err)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, webDAVRequest("PROPFIND", "/webdav/", forged))
+		testutils.AssertEqual(t, rec.Code, http.StatusUnauthorized)
+	})
+
+	t.Run("Not member of organization", func(t *testing.T) {
+		otherOrg, err := SignedWebDAVToken(WebDAVClaim{UserId: "user", OrgId: "other-org"}, "secret", time.Hour)
+		testutils.AssertNil(t, err)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, webDAVRequest("PROPFIND", "/webdav/", otherOrg))
+		testutils.AssertEqual(t, rec.Code, http.StatusForbidden)
+	})
+
+	t.Run("Other tokens are rejected", func(t *testing.T) {
+		withoutAudience, err := jwt.NewWithClaims(jwt.SigningMethodHS256, WebDAVClaim{
+			UserId:           "user",
+			OrgId:            "org",
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		}).SignedString([]byte("secret"))
+		testutils.AssertNil(t, err)
+		withoutExpiry, err := jwt.NewWithClaims(jwt.SigningMethodHS256, WebDAVClaim{
+			UserId:           "user",
+			OrgId:            "org",
+			RegisteredClaims: jwt.RegisteredClaims{Audience: jwt.ClaimStrings{webDAVTokenAudience}},
+		}).SignedString([]byte("secret"))
+		testutils.AssertNil(t, err)
+
+		for _, other := range []string{withoutAudience, withoutExpiry} {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, webDAVRequest("PROPFIND", "/webdav/", other))
+			testutils.AssertEqual(t, rec.Code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("Passkey is required", func(t *testing.T) {
+		testutils.AssertNil(t, store.RegisterOrganization(context.Background(), &pkg.Organization{Id: "org", RequirePasskey: true}))
+		defer func() { store.Organizations = nil }()
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, webDAVRequest("PROPFIND", "/webdav/", token))
+		testutils.AssertEqual(t, rec.Code, http.StatusForbidden)
+
+		withPasskey, err := SignedWebDAVToken(WebDAVClaim{UserId: "user", OrgId: "org", Passkey: true}, "secret", time.Hour)
+		testutils.AssertNil(t, err)
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, webDAVRequest("PROPFIND", "/webdav/", withPasskey))
+		testutils.AssertEqual(t, rec.Code, http.StatusMultiStatus)
+	})
+
+	t.Run("Sign out from all devices", func(t *testing.T) {
+		generation, err := store.NewSessionGeneration(context.Background(), "user")
+		testutils.AssertNil(t, err)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, webDAVRequest("PROPFIND", "/webdav/", token))
+		testutils.AssertEqual(t, rec.Code, http.StatusUnauthorized)
+
+		current, err := SignedWebDAVToken(WebDAVClaim{UserId: "user", OrgId: "org", Generation: generation}, "secret", time.Hour)
+		testutils.AssertNil(t, err)
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, webDAVRequest("PROPFIND", "/webdav/", current))
+		testutils.AssertEqual(t, rec.Code, http.StatusMultiStatus)
+	})
+}
+
+func TestWebDAVTokenHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WebDAVTokenHandler("https://example.com/", "secret")(rec, withAuthSession(httptest.NewRequest("GET", RouteApiWebDAVToken, nil), "org"))
+	testutils.AssertEqual(t, rec.Code, http.StatusOK)
+
+	var resp struct {
+		URL   string `json:"url"`
+		Token string `json:"token"`
+	}
+	testutils.AssertNil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	testutils.AssertEqual(t, resp.URL, "https://example.com/webdav/")
+
+	claims, err := parseWebDAVToken(resp.Token, "secret")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, claims.OrgId, "org")
+	testutils.AssertEqual(t, claims.UserId, "0000-0000")
+	testutils.AssertEqual(t, claims.Audience[0], webDAVTokenAudience)
+}
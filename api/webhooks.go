@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
+)
+
+const (
+	webhookDeliveriesPageSize = 50
+	maxWebhookDeliveries      = 200
+
+	// Number of deliveries shown below the webhook form
+	webhookDeliveriesShown = 10
+)
+
+// WebhookHandler renders the webhook of the active organization with the most recent deliveries. Only admins see
+// the webhook, since the form shows where the events of the organization are sent
+func WebhookHandler(store pkg.WebhookStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := MustGetSession(r)
+		orgId := MustGetOrgId(session)
+		if !MustGetUserInfo(session).Roles[orgId].AtLeast(pkg.RoleAdmin) {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		data := web.WebhookData{Events: pkg.WebhookEvents}
+		webhook, err := store.Webhook(ctx, orgId)
+		if err != nil && !errors.Is(err, pkg.ErrWebhookNotFound) {
+			http.Error(w, "Could not fetch webhook", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not fetch webhook", "error", err, "orgId", orgId)
+			return
+		}
+		if err == nil {
+			data.Webhook = webhook
+			if data.Deliveries, err = store.WebhookDeliveries(ctx, orgId, webhookDeliveriesShown); err != nil {
+				http.Error(w, "Could not fetch webhook deliveries", StoreErrorCode(err))
+				slog.ErrorContext(ctx, "Could not fetch webhook deliveries", "error", err, "orgId", orgId)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		web.Webhook(w, pkg.LanguageFromReq(r), &data)
+	}
+}
+
+// SaveWebhookHandler creates or updates the webhook of the active organization. The secret is kept when the webhook
+// is updated, unless rotate is set
+func SaveWebhookHandler(store pkg.WebhookStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 8192)
+		code, err := parseForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		session := MustGetSession(r)
+		webhook := pkg.Webhook{
+			OrgId:     MustGetOrgId(session),
+			Url:       strings.TrimSpace(r.FormValue("url")),
+			Enabled:   r.FormValue("enabled") != "",
+			UpdatedBy: MustGetUserInfo(session).Id,
+			UpdatedAt: time.Now(),
+		}
+		for _, event := range r.Form["event"] {
+			webhook.Events = append(webhook.Events, pkg.WebhookEvent(event))
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		if err := pkg.ConfigureWebhook(ctx, store, &webhook, r.FormValue("rotate") != ""); err != nil {
+			http.Error(w, "Could not save webhook: "+err.Error(), StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not save webhook", "error", err, "orgId", webhook.OrgId)
+			return
+		}
+
+		slog.InfoContext(ctx, "Saved webhook", "orgId", webhook.OrgId, "enabled", webhook.Enabled)
+		HxTrigger(w, EventWebhookUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.webhook-saved", nil)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// DeleteWebhookHandler removes the webhook of the active organization. The delivery log is kept
+func DeleteWebhookHandler(store pkg.WebhookStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		if err := store.DeleteWebhook(ctx, orgId); err != nil {
+			http.Error(w, "Could not remove webhook", StoreErrorCode(err))
+			slog.ErrorContext(ctx, "Could not remove webhook", "error", err, "orgId", orgId)
+			return
+		}
+
+		slog.InfoContext(ctx, "Removed webhook", "orgId", orgId)
+		HxTrigger(w, EventWebhookUpdated, nil)
+		HxFlash(w, r, FlashSuccess, "flash.webhook-removed", nil)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// WebhookDeliveriesHandler lists the deliveries to the webhook of the active organization, newest first. The
+// number of deliveries is given by the limit query parameter
+func WebhookDeliveriesHandler(store pkg.WebhookStore, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := webhookDeliveriesPageSize
+		if value := r.URL.Query().Get("limit"); value != "" {
+			var err error
+			if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+				writeRest(w, http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+				return
+			}
+		}
+		limit = min(limit, maxWebhookDeliveries)
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		orgId := MustGetOrgId(MustGetSession(r))
+		deliveries, err := store.WebhookDeliveries(ctx, orgId, limit)
+		if err != nil {
+			writeRestError(ctx, w, "Could not fetch webhook deliveries", err)
+			return
+		}
+		if deliveries == nil {
+			deliveries = []pkg.WebhookDelivery{}
+		}
+		writeRest(w, http.StatusOK, map[string]any{"deliveries": deliveries})
+	}
+}
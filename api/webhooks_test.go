@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func putWebhook(store pkg.WebhookStore, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("PUT", RouteOrganizationsWebhook, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	SaveWebhookHandler(store, time.Second)(recorder, withAuthSession(req, "org"))
+	return recorder
+}
+
+func TestSaveWebhookHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	form := url.Values{
+		"url":     {" https://example.com/hooks "},
+		"event":   {string(pkg.WebhookResourceCreated), string(pkg.WebhookUserInvited)},
+		"enabled": {"on"},
+	}
+	recorder := putWebhook(store, form)
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+	testutils.AssertContains(t, recorder.Header().Get("HX-Trigger"), string(EventWebhookUpdated))
+
+	webhook, err := store.Webhook(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, webhook.Url, "https://example.com/hooks")
+	testutils.AssertEqual(t, webhook.Enabled, true)
+	testutils.AssertEqual(t, webhook.UpdatedBy, "0000-0000")
+	testutils.AssertEqual(t, len(webhook.Events), 2)
+	secret := webhook.Secret
+
+	form.Set("rotate", "on")
+	form.Del("enabled")
+	testutils.AssertEqual(t, putWebhook(store, form).Code, http.StatusOK)
+	webhook, err = store.Webhook(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, webhook.Enabled, false)
+	testutils.AssertEqual(t, webhook.Secret != secret, true)
+
+	for _, form := range []url.Values{
+		{"url": {"ftp://example.com"}},
+		{"url": {"https://example.com"}, "event": {"resource.deleted"}},
+	} {
+		testutils.AssertEqual(t, putWebhook(store, form).Code, http.StatusBadRequest)
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	handler := WebhookHandler(store, time.Second)
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, withAuthSession(httptest.NewRequest("GET", RouteOrganizationsWebhook, nil), "org"))
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+	testutils.AssertContains(t, recorder.Body.String(), `id="webhook-form"`)
+	testutils.AssertNotContains(t, recorder.Body.String(), "webhook-delete-btn")
+
+	putWebhook(store, url.Values{"url": {"https://example.com/hooks"}, "enabled": {"on"}})
+	store.RecordWebhookDelivery(context.Background(), &pkg.WebhookDelivery{Id: "delivery", OrgId: "org", Event: pkg.WebhookProjectUpdated, TargetId: "concert", Attempts: 1, StatusCode: http.StatusOK, Succeeded: true})
+
+	recorder = httptest.NewRecorder()
+	handler(recorder, withAuthSession(httptest.NewRequest("GET", RouteOrganizationsWebhook, nil), "org"))
+	testutils.AssertContains(t, recorder.Body.String(), "https://example.com/hooks", "webhook-delete-btn", pkg.WebhookSecretPrefix, "concert")
+
+	t.Run("non-admin sees nothing", func(t *testing.T) {
+		req := withAuthSession(httptest.NewRequest("GET", RouteOrganizationsWebhook, nil), "org")
+		MustGetSession(req).Values["role"], _ = json.Marshal(pkg.UserInfo{Roles: map[string]pkg.RoleKind{"org": pkg.RoleEditor}})
+		recorder := httptest.NewRecorder()
+		handler(recorder, req)
+		testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+		testutils.AssertEqual(t, recorder.Body.Len(), 0)
+	})
+}
+
+func TestDeleteWebhookHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	putWebhook(store, url.Values{"url": {"https://example.com/hooks"}})
+
+	recorder := httptest.NewRecorder()
+	DeleteWebhookHandler(store, time.Second)(recorder, withAuthSession(httptest.NewRequest("DELETE", RouteOrganizationsWebhook, nil), "org"))
+	testutils.AssertEqual(t, recorder.Code, http.StatusOK)
+
+	_, err := store.Webhook(context.Background(), "org")
+	testutils.AssertEqual(t, errors.Is(err, pkg.ErrWebhookNotFound), true)
+}
+
+func TestWebhookDeliveriesHandler(t *testing.T) {
+	store := pkg.NewMultiOrgInMemoryStore()
+	now := time.Now()
+	for i, id := range []string{"first", "second", "third"} {
+		store.RecordWebhookDelivery(context.Background(), &pkg.WebhookDelivery{Id: id, OrgId: "org", CreatedAt: now.Add(time.Duration(i) * time.Second)})
+	}
+	handler := WebhookDeliveriesHandler(store, time.Second)
+
+	for _, test := range []struct {
+		query string
+		code  int
+		ids   string
+	}{
+		{"", http.StatusOK, "third,second,first"},
+		{"?limit=2", http.StatusOK, "third,second"},
+		{"?limit=1000", http.StatusOK, "third,second,first"},
+		{"?limit=0", http.StatusBadRequest, ""},
+		{"?limit=-1", http.StatusBadRequest, ""},
+		{"?limit=many", http.StatusBadRequest, ""},
+	} {
+		t.Run(test.query, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler(recorder, withAuthSession(httptest.NewRequest("GET", RouteOrganizationsWebhookDeliveries+test.query, nil), "org"))
+			testutils.AssertEqual(t, recorder.Code, test.code)
+			if test.code != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Deliveries []pkg.WebhookDelivery `json:"deliveries"`
+			}
+			testutils.AssertNil(t, json.NewDecoder(recorder.Body).Decode(&body))
+			ids := make([]string, len(body.Deliveries))
+			for i, delivery := range body.Deliveries {
+				ids[i] = delivery.Id
+			}
+			testutils.AssertEqual(t, strings.Join(ids, ","), test.ids)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Code generated by cmd/clientTypes. DO NOT EDIT.
+
+export interface Suggestion {
+  id: string;
+  title: string;
+  composer: string;
+}
+
+export interface ResourceManifest {
+  resource_id: string;
+  files: ManifestEntry[];
+}
+
+export interface SharedLinkResponse {
+  url: string;
+  expires: string;
+}
+
+export interface PartsArchiveResponse {
+  id: string;
+  status: string;
+  size: number;
+  expires: string;
+  url?: string;
+}
+
+export interface WebDAVTokenResponse {
+  url: string;
+  token: string;
+  expires: string;
+}
+
+export interface AccessTokenResponse {
+  access_token: string;
+  token_type: string;
+  expires_in: number;
+}
+
+export interface RestResource {
+  id: string;
+  title: string;
+  composer: string;
+  arranger: string;
+  genre: string;
+  year: string;
+  instrumentation: string;
+  duration_seconds: number;
+  publisher: string;
+  ismn: string;
+  tags: string;
+  notes: string;
+  protected: boolean;
+  submitted_at: string;
+}
+
+export interface RestResourceList {
+  items: RestResource[];
+}
+
+export interface RestProjectList {
+  items: RestProject[];
+}
+
+export interface RestUserList {
+  items: RestUser[];
+}
+
+export interface RestOrganizationList {
+  items: RestOrganization[];
+}
+
+export interface ManifestEntry {
+  name: string;
+  size: number;
+  md5: string;
+  last_modified: string;
+}
+
+export interface RestProject {
+  id: string;
+  name: string;
+  resource_ids: string[];
+  created_at: string;
+  updated_at: string;
+  resources?: RestResource[];
+}
+
+export interface RestUser {
+  id: string;
+  name: string;
+  email: string;
+  role: string;
+  groups: string[];
+}
+
+export interface RestOrganization {
+  id: string;
+  name: string;
+  role: string;
+}
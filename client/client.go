@@ -0,0 +1,201 @@
+// Package client calls the HTTP API of Caesura with an API token. The paths come from the route definitions of
+// the api package and the responses are the types the handlers encode, such that the client follows the server
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/davidkleiven/caesura/api"
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+type Client struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// New returns a client of the server at baseURL authenticated by an API token created on the organizations page
+func New(baseURL, token string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), Token: token, HTTPClient: http.DefaultClient}
+}
+
+// Error is returned when the server responds with an error status. The message is the body of the response
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("caesura responded %d: %s", e.StatusCode, e.Message)
+}
+
+// routePath fills the path parameters of a route, such as {id}, in the order they appear
+func routePath(route string, values ...string) string {
+	for _, value := range values {
+		start := strings.Index(route, "{")
+		end := strings.Index(route, "}")
+		if start < 0 || end < start {
+			break
+		}
+		route = route[:start] + url.PathEscape(value) + route[end+1:]
+	}
+	return route
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, form url.Values) (*http.Response, error) {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Accept", "application/json")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+	return resp, nil
+}
+
+func (c *Client) doJSON(ctx context.Context, method, path string, query url.Values, form url.Values, result any) error {
+	resp, err := c.do(ctx, method, path, query, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("could not decode response of %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// Suggest returns up to limit pieces matching the query by title, composer or arranger. The server picks the
+// number of suggestions when limit is zero
+func (c *Client) Suggest(ctx context.Context, query string, limit int) ([]pkg.Suggestion, error) {
+	params := url.Values{"q": {query}}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	var suggestions []pkg.Suggestion
+	err := c.doJSON(ctx, http.MethodGet, api.RouteResourcesSuggest, params, nil, &suggestions)
+	return suggestions, err
+}
+
+// ResourceManifest lists the files of a piece with sizes and hashes, such that only changed files are downloaded
+func (c *Client) ResourceManifest(ctx context.Context, resourceId string) (*pkg.ResourceManifest, error) {
+	var manifest pkg.ResourceManifest
+	err := c.doJSON(ctx, http.MethodGet, routePath(api.RouteApiResourcesIdManifest, resourceId), nil, nil, &manifest)
+	return &manifest, err
+}
+
+// DownloadResource returns the zip archive with the parts of a piece. The caller closes the archive
+func (c *Client) DownloadResource(ctx context.Context, resourceId string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, routePath(api.RouteResourcesId, resourceId), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// SharedLink creates a link to a file of a piece that works without an account until it expires
+func (c *Client) SharedLink(ctx context.Context, resourceId, filename string) (*api.SharedLinkResponse, error) {
+	var link api.SharedLinkResponse
+	err := c.doJSON(ctx, http.MethodGet, routePath(api.RouteResourcesIdLink, resourceId), url.Values{"file": {filename}}, nil, &link)
+	return &link, err
+}
+
+// CreatePartsArchive starts writing a zip archive with the parts of the pieces the token user plays. Poll the
+// progress with PartsArchive. Requires a token with write access
+func (c *Client) CreatePartsArchive(ctx context.Context, resourceIds []string) (*api.PartsArchiveResponse, error) {
+	var archive api.PartsArchiveResponse
+	err := c.doJSON(ctx, http.MethodPost, api.RouteResourcesPartsArchives, nil, url.Values{"resourceId": resourceIds}, &archive)
+	return &archive, err
+}
+
+// PartsArchive returns the progress of an archive. The URL of the archive is set once it is ready
+func (c *Client) PartsArchive(ctx context.Context, archiveId string) (*api.PartsArchiveResponse, error) {
+	var archive api.PartsArchiveResponse
+	err := c.doJSON(ctx, http.MethodGet, routePath(api.RouteResourcesPartsArchivesId, archiveId), nil, nil, &archive)
+	return &archive, err
+}
+
+// WebDAVToken returns the password for mounting the library of the organization as a network drive
+func (c *Client) WebDAVToken(ctx context.Context) (*api.WebDAVTokenResponse, error) {
+	var token api.WebDAVTokenResponse
+	err := c.doJSON(ctx, http.MethodGet, api.RouteApiWebDAVToken, nil, nil, &token)
+	return &token, err
+}
+
+// AccessToken exchanges the API token for an access token that expires after an hour. Apps can hand out the
+// access token instead of the API token
+func (c *Client) AccessToken(ctx context.Context) (*api.AccessTokenResponse, error) {
+	var token api.AccessTokenResponse
+	err := c.doJSON(ctx, http.MethodPost, api.RouteApiToken, nil, nil, &token)
+	return &token, err
+}
+
+// Resources lists the pieces whose title, composer or arranger start with query. All pieces are listed when the
+// query is empty
+func (c *Client) Resources(ctx context.Context, query string) ([]pkg.RestResource, error) {
+	var list pkg.RestResourceList
+	err := c.doJSON(ctx, http.MethodGet, api.RouteApiResources, url.Values{"q": {query}}, nil, &list)
+	return list.Items, err
+}
+
+func (c *Client) Resource(ctx context.Context, resourceId string) (*pkg.RestResource, error) {
+	var resource pkg.RestResource
+	err := c.doJSON(ctx, http.MethodGet, routePath(api.RouteApiResourcesId, resourceId), nil, nil, &resource)
+	return &resource, err
+}
+
+// Projects lists the projects whose name starts with query
+func (c *Client) Projects(ctx context.Context, query string) ([]pkg.RestProject, error) {
+	var list pkg.RestProjectList
+	err := c.doJSON(ctx, http.MethodGet, api.RouteApiProjects, url.Values{"q": {query}}, nil, &list)
+	return list.Items, err
+}
+
+// Project returns the project together with its pieces
+func (c *Client) Project(ctx context.Context, projectId string) (*pkg.RestProject, error) {
+	var project pkg.RestProject
+	err := c.doJSON(ctx, http.MethodGet, routePath(api.RouteApiProjectsId, projectId), nil, nil, &project)
+	return &project, err
+}
+
+// Users lists the members of the organization. Only admins get other members than themselves
+func (c *Client) Users(ctx context.Context) ([]pkg.RestUser, error) {
+	var list pkg.RestUserList
+	err := c.doJSON(ctx, http.MethodGet, api.RouteApiUsers, nil, nil, &list)
+	return list.Items, err
+}
+
+func (c *Client) Organizations(ctx context.Context) ([]pkg.RestOrganization, error) {
+	var list pkg.RestOrganizationList
+	err := c.doJSON(ctx, http.MethodGet, api.RouteApiOrganizations, nil, nil, &list)
+	return list.Items, err
+}
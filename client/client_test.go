@@ -0,0 +1,167 @@
+package client
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/api"
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/gorilla/sessions"
+)
+
+// newTestServer serves the API of a demo store and returns a client with a token of an editor of the demo
+// organization
+func newTestServer(t *testing.T, scope pkg.ApiTokenScope) (*Client, *pkg.MultiOrgInMemoryStore) {
+	store := pkg.NewDemoStore()
+	orgId := pkg.DemoOrgId
+	user := pkg.UserInfo{Id: "0000-0000", Roles: map[string]pkg.RoleKind{orgId: pkg.RoleEditor}, Groups: map[string][]string{}}
+	testutils.AssertNil(t, store.RegisterUser(context.Background(), &user))
+
+	token, secret, err := pkg.NewApiToken(user.Id, orgId, "Client", scope, time.Time{})
+	testutils.AssertNil(t, err)
+	testutils.AssertNil(t, store.SaveApiToken(context.Background(), token))
+
+	config := pkg.NewDefaultConfig()
+	server := httptest.NewServer(api.Setup(store, config, sessions.NewCookieStore([]byte("secret"))))
+	t.Cleanup(server.Close)
+	return New(server.URL, secret), store
+}
+
+func TestRoutePath(t *testing.T) {
+	testutils.AssertEqual(t, routePath(api.RouteResourcesIdLink, "a b"), "/resources/a%20b/link")
+	testutils.AssertEqual(t, routePath(api.RouteResourcesSuggest), api.RouteResourcesSuggest)
+}
+
+func TestClientReadsResources(t *testing.T) {
+	client, store := newTestServer(t, pkg.ApiTokenRead)
+	ctx := context.Background()
+	meta := store.Data[pkg.DemoOrgId].Metadata[0]
+
+	suggestions, err := client.Suggest(ctx, meta.Title, 1)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(suggestions), 1)
+	testutils.AssertEqual(t, suggestions[0].Id, meta.ResourceId())
+
+	manifest, err := client.ResourceManifest(ctx, meta.ResourceId())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, manifest.ResourceId, meta.ResourceId())
+
+	archive, err := client.DownloadResource(ctx, meta.ResourceId())
+	testutils.AssertNil(t, err)
+	defer archive.Close()
+	content, err := io.ReadAll(archive)
+	testutils.AssertNil(t, err)
+	_, err = zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	testutils.AssertNil(t, err)
+
+	token, err := client.WebDAVToken(ctx)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, token.Token != "", true)
+}
+
+func TestClientJSONApi(t *testing.T) {
+	client, store := newTestServer(t, pkg.ApiTokenRead)
+	ctx := context.Background()
+	orgId := pkg.DemoOrgId
+	meta := store.Data[orgId].Metadata[0]
+
+	resources, err := client.Resources(ctx, meta.Title)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(resources) > 0, true)
+
+	resource, err := client.Resource(ctx, meta.ResourceId())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, resource.Title, meta.Title)
+
+	projects, err := client.Projects(ctx, "")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(projects), len(store.Data[orgId].Projects))
+
+	users, err := client.Users(ctx)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(users), 1)
+	testutils.AssertEqual(t, users[0].Role, "editor")
+
+	organizations, err := client.Organizations(ctx)
+	testutils.AssertNil(t, err)
+	idx := slices.IndexFunc(organizations, func(org pkg.RestOrganization) bool { return org.Id == orgId })
+	testutils.AssertEqual(t, idx >= 0, true)
+
+	// The organization lists the role the read token acts with
+	testutils.AssertEqual(t, organizations[idx].Role, "viewer")
+
+	token, err := client.AccessToken(ctx)
+	testutils.AssertNil(t, err)
+	withAccessToken := New(client.BaseURL, token.AccessToken)
+	_, err = withAccessToken.Resource(ctx, meta.ResourceId())
+	testutils.AssertNil(t, err)
+}
+
+func TestClientErrors(t *testing.T) {
+	client, _ := newTestServer(t, pkg.ApiTokenRead)
+	ctx := context.Background()
+
+	_, err := client.ResourceManifest(ctx, "unknown")
+	var apiErr *Error
+	testutils.AssertEqual(t, errors.As(err, &apiErr), true)
+	testutils.AssertEqual(t, apiErr.StatusCode, http.StatusNotFound)
+
+	// Read tokens can not write
+	_, err = client.CreatePartsArchive(ctx, []string{"unknown"})
+	testutils.AssertEqual(t, errors.As(err, &apiErr), true)
+	testutils.AssertEqual(t, apiErr.StatusCode, http.StatusUnauthorized)
+
+	client.Token = "unknown"
+	_, err = client.Suggest(ctx, "a", 0)
+	testutils.AssertEqual(t, errors.As(err, &apiErr), true)
+	testutils.AssertEqual(t, apiErr.StatusCode, http.StatusUnauthorized)
+}
+
+func TestClientCreatesPartsArchive(t *testing.T) {
+	client, store := newTestServer(t, pkg.ApiTokenWrite)
+	ctx := context.Background()
+	meta := store.Data[pkg.DemoOrgId].Metadata[0]
+
+	archive, err := client.CreatePartsArchive(ctx, []string{meta.ResourceId()})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, archive.Id != "", true)
+
+	status, err := client.PartsArchive(ctx, archive.Id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, status.Id, archive.Id)
+}
+
+func TestTypeScriptIsUpToDate(t *testing.T) {
+	committed, err := os.ReadFile("caesura.d.ts")
+	testutils.AssertNil(t, err)
+	if string(committed) != TypeScript(ResponseTypes...) {
+		t.Fatal("caesura.d.ts is outdated. Run make client-types")
+	}
+}
+
+func TestTypeScript(t *testing.T) {
+	type inner struct {
+		Value float64 `json:"value"`
+	}
+	type outer struct {
+		Name    string            `json:"name,omitempty"`
+		Inner   *inner            `json:"inner"`
+		Tags    map[string]string `json:"tags"`
+		Hidden  string            `json:"-"`
+		Enabled bool
+	}
+
+	result := TypeScript(outer{})
+	testutils.AssertContains(t, result, "name?: string;", "inner: inner | null;", "tags: Record<string, string>;", "Enabled: boolean;", "value: number;")
+	testutils.AssertNotContains(t, result, "Hidden")
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/caesura/api"
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+// ResponseTypes are the JSON responses of the client. TypeScript types are generated from them
+var ResponseTypes = []any{
+	pkg.Suggestion{},
+	pkg.ResourceManifest{},
+	api.SharedLinkResponse{},
+	api.PartsArchiveResponse{},
+	api.WebDAVTokenResponse{},
+	api.AccessTokenResponse{},
+	pkg.RestResource{},
+	pkg.RestResourceList{},
+	pkg.RestProjectList{},
+	pkg.RestUserList{},
+	pkg.RestOrganizationList{},
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// TypeScript returns TypeScript interfaces of the types and of the structs they refer to, using the names of
+// their JSON fields. Times are strings in RFC 3339 format
+func TypeScript(types ...any) string {
+	var builder strings.Builder
+	builder.WriteString("// Code generated by cmd/clientTypes. DO NOT EDIT.\n")
+
+	written := make(map[reflect.Type]bool)
+	queue := make([]reflect.Type, 0, len(types))
+	for _, value := range types {
+		queue = append(queue, reflect.TypeOf(value))
+	}
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+		if written[t] {
+			continue
+		}
+		written[t] = true
+
+		fmt.Fprintf(&builder, "\nexport interface %s {\n", t.Name())
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" || !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			optional := ""
+			if strings.Contains(options, "omitempty") {
+				optional = "?"
+			}
+			fmt.Fprintf(&builder, "  %s%s: %s;\n", name, optional, typeScriptType(field.Type, &queue))
+		}
+		builder.WriteString("}\n")
+	}
+	return builder.String()
+}
+
+// typeScriptType returns the TypeScript type of t. Structs are added to the queue, such that they are written
+// as interfaces too
+func typeScriptType(t reflect.Type, queue *[]reflect.Type) string {
+	switch {
+	case t == timeType:
+		return "string"
+	case t.Kind() == reflect.Pointer:
+		return typeScriptType(t.Elem(), queue) + " | null"
+	case t.Kind() == reflect.Struct:
+		*queue = append(*queue, t)
+		return t.Name()
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return typeScriptType(t.Elem(), queue) + "[]"
+	case t.Kind() == reflect.Map:
+		return fmt.Sprintf("Record<%s, %s>", typeScriptType(t.Key(), queue), typeScriptType(t.Elem(), queue))
+	case t.Kind() == reflect.String:
+		return "string"
+	case t.Kind() == reflect.Bool:
+		return "boolean"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Float64:
+		return "number"
+	}
+	return "unknown"
+}
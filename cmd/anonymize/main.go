@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+func main() {
+	source := flag.String("source", "config-prod.yml", "profile of the store to copy data from")
+	target := flag.String("target", "config-test.yml", "profile of the store that receives the anonymized data")
+	apply := flag.Bool("apply", false, "write to the target store. Without this flag the data is copied into memory only")
+	timeout := flag.Duration("timeout", 30*time.Minute, "maximum duration of the copy")
+	flag.Parse()
+
+	if *source == *target {
+		log.Fatal("Source and target profile must be different")
+	}
+
+	sourceConfig, err := pkg.LoadProfile(*source)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sourceStore := pkg.GetStore(sourceConfig)
+	if sourceStore.Err != nil {
+		log.Fatal(sourceStore.Err)
+	}
+	defer sourceStore.Cleanup()
+
+	var targetStore pkg.Store = pkg.NewMultiOrgInMemoryStore()
+	if *apply {
+		targetConfig, err := pkg.LoadProfile(*target)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if targetConfig.GoogleCfg.Environment == "prod" {
+			log.Fatal("Refusing to write anonymized data into the production environment")
+		}
+
+		targetResult := pkg.GetStore(targetConfig)
+		if targetResult.Err != nil {
+			log.Fatal(targetResult.Err)
+		}
+		defer targetResult.Cleanup()
+		targetStore = targetResult.Store
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	copier := pkg.AnonymizingCopier{Source: sourceStore.Store, Target: targetStore}
+	stats, err := copier.Copy(ctx)
+	log.Printf("Organizations=%d Users=%d Resources=%d Projects=%d\n", stats.NumOrganizations, stats.NumUsers, stats.NumResources, stats.NumProjects)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *apply {
+		log.Printf("Anonymized data is written to %s", *target)
+	} else {
+		log.Printf("Run with '--apply' to write the anonymized data to %s", *target)
+	}
+}
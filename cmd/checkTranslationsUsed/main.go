@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/davidkleiven/caesura/web"
 	"gopkg.in/yaml.v2"
 )
 
@@ -36,11 +37,18 @@ func main() {
 		log.Fatalf("Failed to parse translation file: %v", err)
 	}
 
+	translator, err := web.NewTranslatorYAML(content)
+	if err != nil {
+		log.Fatalf("Failed to parse translation file: %v", err)
+	}
+
 	// Verify root folder exists
 	if _, err := os.Stat(rootFolder); os.IsNotExist(err) {
 		log.Fatalf("Root folder not found: %v", err)
 	}
 
+	// templateKeys are the keys the templates translate, which every language must have
+	var templateKeys []string
 	used := make(map[string]bool)
 	for _, item := range mapping {
 		for k := range item {
@@ -64,6 +72,9 @@ func main() {
 		}
 
 		fileContent := string(data)
+		if filepath.Ext(path) == ".html" {
+			templateKeys = append(templateKeys, web.TemplateTranslationKeys(fileContent)...)
+		}
 		for k := range used {
 			if strings.Contains(fileContent, "\""+k+"\"") {
 				used[k] = true
@@ -79,11 +90,21 @@ func main() {
 		}
 	}
 
+	numMissing := 0
+	for language, keys := range translator.MissingKeys(templateKeys) {
+		if len(keys) > 0 {
+			fmt.Printf("The following translation keys are missing in %s: %v\n", language, keys)
+			numMissing += len(keys)
+		}
+	}
+
 	if len(unusedKeys) > 0 {
 		fmt.Printf("The following translation keys are not used: %v\n", unusedKeys)
 		fmt.Printf("Total unused keys: %d\n", len(unusedKeys))
+	}
+	if len(unusedKeys) > 0 || numMissing > 0 {
 		os.Exit(1)
 	}
 
-	fmt.Printf("✅ All %d translation keys are being used!\n", len(used))
+	fmt.Printf("✅ All %d translation keys are being used and translated into every language!\n", len(used))
 }
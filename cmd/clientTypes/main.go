@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/davidkleiven/caesura/client"
+)
+
+func main() {
+	outfile := "client/caesura.d.ts"
+	if len(os.Args) > 1 {
+		outfile = os.Args[1]
+	}
+
+	if err := os.WriteFile(outfile, []byte(client.TypeScript(client.ResponseTypes...)), 0644); err != nil {
+		fmt.Print(err)
+		os.Exit(1)
+	}
+	fmt.Printf("TypeScript types written to: %s\n", outfile)
+}
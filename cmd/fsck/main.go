@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+func main() {
+	profile := flag.String("profile", "", "profile or config file of the store to check")
+	remove := flag.Bool("delete", false, "remove orphaned files and metadata instead of only reporting them")
+	timeout := flag.Duration("timeout", time.Hour, "maximum duration of the check")
+	flag.Parse()
+
+	if *profile == "" {
+		log.Fatal("--profile must be specified")
+	}
+
+	store := initStore(*profile)
+	defer store.Cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	orphans, err := pkg.NewOrphanCheck(store.Store, *remove).Run(ctx)
+	for _, orphan := range orphans {
+		status := ""
+		if orphan.Removed {
+			status = " (removed)"
+		}
+		fmt.Fprintf(os.Stdout, "%s%s\n", orphan, status)
+	}
+	log.Printf("Found %d orphans", len(orphans))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(orphans) > 0 && !*remove {
+		log.Print("Run with '--delete' to remove the orphans")
+	}
+}
+
+// initStore loads the config file at the given path, or the embedded profile with that name otherwise
+func initStore(profile string) pkg.StoreInitResult {
+	var (
+		config *pkg.Config
+		err    error
+	)
+	if _, statErr := os.Stat(profile); statErr == nil {
+		config, err = pkg.OverrideFromFile(profile, pkg.NewDefaultConfig())
+	} else {
+		config, err = pkg.LoadProfile(profile)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	result := pkg.GetStore(config)
+	if result.Err != nil {
+		log.Fatal(result.Err)
+	}
+	return result
+}
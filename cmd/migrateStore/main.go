@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+func main() {
+	source := flag.String("source", "", "profile or config file of the store to migrate data from")
+	target := flag.String("target", "", "profile or config file of the store that receives the data")
+	dryRun := flag.Bool("dry-run", false, "read all data from the source store without writing to the target store")
+	timeout := flag.Duration("timeout", 2*time.Hour, "maximum duration of the migration")
+	flag.Parse()
+
+	if *source == "" || *target == "" {
+		log.Fatal("Both --source and --target must be specified")
+	}
+	if *source == *target {
+		log.Fatal("Source and target profile must be different")
+	}
+
+	sourceStore := initStore(*source)
+	defer sourceStore.Cleanup()
+
+	var targetStore pkg.Store = pkg.NewMultiOrgInMemoryStore()
+	if !*dryRun {
+		targetResult := initStore(*target)
+		defer targetResult.Cleanup()
+		targetStore = targetResult.Store
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	migrator := pkg.StoreMigrator{Source: sourceStore.Store, Target: targetStore, DryRun: *dryRun, Progress: os.Stdout}
+	stats, err := migrator.Migrate(ctx)
+	log.Printf("Organizations=%d Users=%d Resources=%d Projects=%d\n", stats.NumOrganizations, stats.NumUsers, stats.NumResources, stats.NumProjects)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *dryRun {
+		log.Printf("Dry run finished. Run without '--dry-run' to write the data to %s", *target)
+	} else {
+		log.Printf("Data is migrated to %s", *target)
+	}
+}
+
+// initStore loads the config file at the given path, or the embedded profile with that name otherwise.
+// Environment variables are not applied, since they would apply to both stores
+func initStore(profile string) pkg.StoreInitResult {
+	var (
+		config *pkg.Config
+		err    error
+	)
+	if _, statErr := os.Stat(profile); statErr == nil {
+		config, err = pkg.OverrideFromFile(profile, pkg.NewDefaultConfig())
+	} else {
+		config, err = pkg.LoadProfile(profile)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	result := pkg.GetStore(config)
+	if result.Err != nil {
+		log.Fatal(result.Err)
+	}
+	return result
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/davidkleiven/caesura/api"
 	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/web"
 	"github.com/gorilla/sessions"
 	"github.com/stripe/stripe-go/v84"
 )
@@ -33,6 +34,14 @@ func main() {
 		os.Exit(1)
 	}
 
+	if config.TemplateDir != "" {
+		if err := web.ReloadTemplatesFrom(config.TemplateDir); err != nil {
+			slog.Error("Failed to parse templates", "dir", config.TemplateDir, "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Reloading templates on change", "dir", config.TemplateDir)
+	}
+
 	storeResult := pkg.GetStore(config)
 	if storeResult.Err != nil {
 		slog.Error("Store initialization failed", "error", storeResult.Err)
@@ -48,7 +57,7 @@ func main() {
 
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%d", config.Port),
-		Handler: rateLimiter.Middleware(api.LogRequest(mux)),
+		Handler: rateLimiter.Middleware(api.LogRequest(api.ResolveDomainOrganization(storeResult.Store, config)(mux))),
 	}
 
 	stop := make(chan os.Signal, 1)
@@ -78,6 +87,157 @@ func main() {
 		}
 	}(cancelCtx)
 
+	if config.ColdStorageAfter > 0 {
+		tiering := pkg.NewColdStorageTiering(storeResult.Store, config.ColdStorageAfter)
+		go func(ctx context.Context) {
+			ticker := time.NewTicker(24 * time.Hour)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					num, err := tiering.Run(ctx)
+					if err != nil {
+						slog.Error("Cold storage tiering failed", "error", err, "num", num)
+						continue
+					}
+					slog.Info("Moved resources to cold storage", "num", num)
+				case <-ctx.Done():
+					slog.Info("Stopping cold storage tiering")
+					return
+				}
+			}
+		}(cancelCtx)
+	}
+
+	if config.TrashRetention > 0 {
+		purge := pkg.NewTrashPurge(storeResult.Store, config.TrashRetention)
+		go func(ctx context.Context) {
+			ticker := time.NewTicker(24 * time.Hour)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					num, err := purge.Run(ctx)
+					if err != nil {
+						slog.Error("Purging the trash failed", "error", err)
+					}
+					slog.Info("Purged resources from the trash", "num", num)
+				case <-ctx.Done():
+					slog.Info("Stopping trash purge")
+					return
+				}
+			}
+		}(cancelCtx)
+	}
+
+	if config.PendingSubmitTimeout > 0 {
+		reconciler := pkg.NewPendingSubmitReconciler(storeResult.Store, config.PendingSubmitTimeout)
+		go func(ctx context.Context) {
+			ticker := time.NewTicker(config.PendingSubmitTimeout)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					num, err := reconciler.Run(ctx)
+					if err != nil {
+						slog.Error("Resolving interrupted submits failed", "error", err)
+					}
+					slog.Info("Resolved interrupted submits", "num", num)
+				case <-ctx.Done():
+					slog.Info("Stopping submit reconciler")
+					return
+				}
+			}
+		}(cancelCtx)
+	}
+
+	if config.OrphanCheckInterval > 0 {
+		check := pkg.NewOrphanCheck(storeResult.Store, config.RemoveOrphans)
+		go func(ctx context.Context) {
+			ticker := time.NewTicker(config.OrphanCheckInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					orphans, err := check.Run(ctx)
+					if err != nil {
+						slog.Error("Checking for orphans failed", "error", err)
+					}
+					for _, orphan := range orphans {
+						slog.Warn("Found orphan", "orphan", orphan.String(), "removed", orphan.Removed)
+					}
+					slog.Info("Checked for orphans", "num", len(orphans))
+				case <-ctx.Done():
+					slog.Info("Stopping orphan check")
+					return
+				}
+			}
+		}(cancelCtx)
+	}
+
+	if config.TextExtractionInterval > 0 {
+		extraction := pkg.NewTextExtraction(storeResult.Store)
+		go func(ctx context.Context) {
+			ticker := time.NewTicker(config.TextExtractionInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					num, err := extraction.Run(ctx)
+					if err != nil {
+						slog.Error("Extracting text from scores failed", "error", err)
+					}
+					slog.Info("Extracted text from scores", "num", num)
+				case <-ctx.Done():
+					slog.Info("Stopping text extraction")
+					return
+				}
+			}
+		}(cancelCtx)
+	}
+
+	if config.ArtifactCleanupInterval > 0 {
+		cleanup := pkg.NewArtifactCleanup(storeResult.Store)
+		go func(ctx context.Context) {
+			ticker := time.NewTicker(config.ArtifactCleanupInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					num, err := cleanup.Run(ctx)
+					if err != nil {
+						slog.Error("Removing expired artifacts failed", "error", err)
+					}
+					slog.Info("Removed expired artifacts", "num", num)
+				case <-ctx.Done():
+					slog.Info("Stopping artifact cleanup")
+					return
+				}
+			}
+		}(cancelCtx)
+	}
+
+	if config.Retention.Interval > 0 {
+		purge := pkg.NewRetentionPurge(storeResult.Store, config.Retention)
+		go func(ctx context.Context) {
+			ticker := time.NewTicker(config.Retention.Interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					num, err := purge.Run(ctx)
+					if err != nil {
+						slog.Error("Removing expired logs failed", "error", err)
+					}
+					slog.Info("Removed expired logs", "num", num)
+				case <-ctx.Done():
+					slog.Info("Stopping retention purge")
+					return
+				}
+			}
+		}(cancelCtx)
+	}
+
 	<-stop
 	slog.Info("Shutting down server")
 	ctx, cancel := context.WithTimeout(context.Background(), 5.0*time.Second)
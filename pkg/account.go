@@ -0,0 +1,134 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DeletedUserId replaces the id of erased users in the activity and announcements of organizations, such that
+// the history is kept without referring to the user
+const DeletedUserId = "deleted-user"
+
+type AccountEraser interface {
+	// EraseUser deletes the user together with the memberships, passkeys, API tokens, sessions, dismissed hints,
+	// SCIM identities and profile corrections of the user. Activity, announcements, the audit log, invitations,
+	// guest grants and corrections refer to DeletedUserId instead, and the email address and IP of the user are
+	// cleared. Problem reports do not store the reporter. ErrUserNotFound is returned when there is no such user
+	EraseUser(ctx context.Context, userId string) error
+}
+
+// eraseUser anonymizes the entry and reports whether it referred to the user
+func (e *AuditEntry) eraseUser(userId string) bool {
+	changed := false
+	if e.ActorId == userId {
+		e.ActorId = DeletedUserId
+		e.IP = ""
+		changed = true
+	}
+	if e.TargetId == userId {
+		e.TargetId = DeletedUserId
+		changed = true
+	}
+	return changed
+}
+
+// eraseUser anonymizes the invitation and reports whether it referred to the user. Pending invitations sent to
+// the email of the user can no longer be accepted, since the email is cleared
+func (i *Invitation) eraseUser(userId, email string) bool {
+	changed := false
+	if email != "" && strings.EqualFold(i.Email, email) {
+		i.Email = ""
+		changed = true
+	}
+	if i.AcceptedBy == userId {
+		i.AcceptedBy = DeletedUserId
+		changed = true
+	}
+	return changed
+}
+
+// eraseUser anonymizes the grant and reports whether it referred to the user. Grants given to the email of the
+// user are revoked
+func (g *GuestGrant) eraseUser(userId, email string, now time.Time) bool {
+	changed := false
+	if email != "" && strings.EqualFold(g.Email, email) {
+		g.Email = ""
+		if g.RevokedAt.IsZero() {
+			g.RevokedAt = now
+			g.RevokedBy = DeletedUserId
+		}
+		changed = true
+	}
+	if g.CreatedBy == userId {
+		g.CreatedBy = DeletedUserId
+		changed = true
+	}
+	if g.RevokedBy == userId {
+		g.RevokedBy = DeletedUserId
+		changed = true
+	}
+	return changed
+}
+
+// concernsUser reports whether the correction holds the directory data of the user and should be deleted
+func (c *Correction) concernsUser(userId string) bool {
+	return c.Kind == CorrectionProfile && c.TargetId == userId
+}
+
+// eraseUser anonymizes the correction and reports whether it referred to the user
+func (c *Correction) eraseUser(userId string) bool {
+	changed := false
+	if c.ProposedBy == userId {
+		c.ProposedBy = DeletedUserId
+		c.ProposerName = ""
+		changed = true
+	}
+	if c.DecidedBy == userId {
+		c.DecidedBy = DeletedUserId
+		changed = true
+	}
+	return changed
+}
+
+type SoleAdminStore interface {
+	UserInOrgGetter
+	OrganizationGetter
+}
+
+// SoleAdminOrganizations returns the organizations where the user is the only admin. Users must hand over the
+// administration of these organizations before their account can be deleted. Deleted organizations are ignored
+func SoleAdminOrganizations(ctx context.Context, store SoleAdminStore, user *UserInfo) ([]Organization, error) {
+	orgs := []Organization{}
+	for orgId, role := range user.Roles {
+		if !role.AtLeast(RoleAdmin) {
+			continue
+		}
+
+		org, err := store.GetOrganization(ctx, orgId)
+		if errors.Is(err, ErrOrganizationNotFound) || (err == nil && org.Deleted) {
+			continue
+		} else if err != nil {
+			return orgs, err
+		}
+
+		members, err := store.GetUsersInOrg(ctx, orgId)
+		if err != nil {
+			return orgs, err
+		}
+		otherAdmin := false
+		for _, member := range members {
+			if member.Id != user.Id && member.Roles[orgId].AtLeast(RoleAdmin) {
+				otherAdmin = true
+				break
+			}
+		}
+		if !otherAdmin {
+			orgs = append(orgs, org)
+		}
+	}
+	slices.SortFunc(orgs, func(x, y Organization) int { return strings.Compare(x.Name, y.Name) })
+	return orgs, nil
+}
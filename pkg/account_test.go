@@ -0,0 +1,190 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+// assertAccountEraser checks that erasing a user removes the personal data of the user and anonymizes the
+// history of the organization, while other users are kept
+func assertAccountEraser(t *testing.T, store Store) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &Organization{Id: "org1", Name: "Band"}))
+	for _, user := range []*UserInfo{
+		{Id: "user1", Name: "Susan", Email: "susan@example.com", Roles: map[string]RoleKind{"org1": RoleAdmin}},
+		{Id: "user2", Name: "John", Email: "john@example.com", Roles: map[string]RoleKind{"org1": RoleViewer}},
+	} {
+		testutils.AssertNil(t, store.RegisterUser(ctx, user))
+	}
+
+	activity := NewActivity("project1", ActivityDownload, "user1", []string{"piece1"})
+	testutils.AssertNil(t, store.RecordActivity(ctx, "org1", activity))
+	announcement := NewAnnouncement("Rehearsal", "Bring your stand", "user1", now.Add(time.Hour))
+	testutils.AssertNil(t, store.SubmitAnnouncement(ctx, "org1", announcement))
+	testutils.AssertNil(t, store.MarkAnnouncementRead(ctx, "org1", announcement.Id, "user1"))
+	testutils.AssertNil(t, store.MarkAnnouncementRead(ctx, "org1", announcement.Id, "user2"))
+	testutils.AssertNil(t, store.SavePasskey(ctx, testPasskey(t, "user1", "credential", now)))
+	token, _, err := NewApiToken("user1", "org1", "Nightly upload", ApiTokenRead, time.Time{})
+	testutils.AssertNil(t, err)
+	testutils.AssertNil(t, store.SaveApiToken(ctx, token))
+	testutils.AssertNil(t, store.RegisterSession(ctx, &UserSession{Id: "laptop", UserId: "user1", CreatedAt: now, LastSeenAt: now}))
+	testutils.AssertNil(t, store.MarkHintSeen(ctx, "user1", HintUploadAssignment))
+
+	testutils.AssertNil(t, store.Audit(ctx, "org1", NewAuditEntry(AuditRoleChanged, "user1", "user2", "admin", "10.0.0.1")))
+	testutils.AssertNil(t, store.Audit(ctx, "org1", NewAuditEntry(AuditMemberRemoved, "user2", "user1", "", "10.0.0.2")))
+
+	invitation := NewInvitation("org1", "Susan@example.com", RoleViewer, "", time.Hour)
+	accepted := NewInvitation("org1", "old@example.com", RoleViewer, "", time.Hour)
+	accepted.Status, accepted.AcceptedBy, accepted.AcceptedAt = InvitationAccepted, "user1", now
+	other := NewInvitation("org1", "john@example.com", RoleViewer, "", time.Hour)
+	for _, i := range []*Invitation{invitation, accepted, other} {
+		testutils.AssertNil(t, store.SaveInvitation(ctx, i))
+	}
+
+	created := NewGuestGrant("org1", "user1", "guest@example.com", []string{"piece1"}, "", now.Add(time.Hour), "")
+	guest := NewGuestGrant("org1", "user2", "susan@example.com", []string{"piece1"}, "", now.Add(time.Hour), "")
+	for _, g := range []*GuestGrant{created, guest} {
+		testutils.AssertNil(t, store.SaveGuestGrant(ctx, g))
+	}
+
+	profile, err := NewProfileCorrection("Sue", "", &UserInfo{Id: "user1", Name: "Susan"})
+	testutils.AssertNil(t, err)
+	proposed, err := NewMetaDataCorrection(&MetaData{Title: "Polka"}, "genre", "Folk", "", &UserInfo{Id: "user1", Name: "Susan"})
+	testutils.AssertNil(t, err)
+	decided, err := NewMetaDataCorrection(&MetaData{Title: "Polka"}, "genre", "Jazz", "", &UserInfo{Id: "user2", Name: "John"})
+	testutils.AssertNil(t, err)
+	decided.Status, decided.DecidedBy, decided.DecidedAt = CorrectionApproved, "user1", now
+	for _, c := range []*Correction{profile, proposed, decided} {
+		testutils.AssertNil(t, store.SubmitCorrection(ctx, "org1", c))
+	}
+	testutils.AssertNil(t, store.SaveScimIdentity(ctx, &ScimIdentity{OrgId: "org1", UserId: "user1", ExternalId: "ext1", CreatedAt: now}))
+
+	testutils.AssertNil(t, store.EraseUser(ctx, "user1"))
+	err = store.EraseUser(ctx, "user1")
+	testutils.AssertEqual(t, errors.Is(err, ErrUserNotFound), true)
+
+	_, err = store.GetUserInfo(ctx, "user1")
+	testutils.AssertEqual(t, errors.Is(err, ErrUserNotFound), true)
+	members, err := store.GetUsersInOrg(ctx, "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(members), 1)
+	testutils.AssertEqual(t, members[0].Id, "user2")
+
+	activities, err := store.ProjectActivity(ctx, "org1", "project1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(activities), 1)
+	testutils.AssertEqual(t, activities[0].UserId, DeletedUserId)
+
+	announcements, err := store.Announcements(ctx, "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(announcements), 1)
+	testutils.AssertEqual(t, announcements[0].AuthorId, DeletedUserId)
+	testutils.AssertEqual(t, announcements[0].IsReadBy("user1"), false)
+	testutils.AssertEqual(t, announcements[0].IsReadBy("user2"), true)
+
+	passkeys, err := store.Passkeys(ctx, "user1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(passkeys), 0)
+	tokens, err := store.ApiTokens(ctx, "user1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(tokens), 0)
+	sessions, err := store.Sessions(ctx, "user1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(sessions), 0)
+	hints, err := store.SeenHints(ctx, "user1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(hints), 0)
+
+	entries, err := store.AuditLog(ctx, "org1", now.Add(-time.Hour), time.Now().Add(time.Hour))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(entries), 2)
+	for _, entry := range entries {
+		switch entry.Action {
+		case AuditRoleChanged:
+			testutils.AssertEqual(t, entry.ActorId, DeletedUserId)
+			testutils.AssertEqual(t, entry.IP, "")
+			testutils.AssertEqual(t, entry.TargetId, "user2")
+		case AuditMemberRemoved:
+			testutils.AssertEqual(t, entry.ActorId, "user2")
+			testutils.AssertEqual(t, entry.IP, "10.0.0.2")
+			testutils.AssertEqual(t, entry.TargetId, DeletedUserId)
+		}
+	}
+
+	invitations, err := store.Invitations(ctx, "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(invitations), 3)
+	for _, i := range invitations {
+		switch i.Id {
+		case invitation.Id:
+			testutils.AssertEqual(t, i.Email, "")
+		case accepted.Id:
+			testutils.AssertEqual(t, i.AcceptedBy, DeletedUserId)
+		case other.Id:
+			testutils.AssertEqual(t, i.Email, "john@example.com")
+		}
+	}
+
+	grant, err := store.GuestGrant(ctx, "org1", created.Id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, grant.CreatedBy, DeletedUserId)
+	testutils.AssertEqual(t, grant.Email, "guest@example.com")
+	testutils.AssertEqual(t, grant.RevokedAt.IsZero(), true)
+	grant, err = store.GuestGrant(ctx, "org1", guest.Id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, grant.Email, "")
+	testutils.AssertEqual(t, grant.CreatedBy, "user2")
+	testutils.AssertEqual(t, grant.StatusAt(time.Now()), GuestGrantRevoked)
+
+	corrections, err := store.Corrections(ctx, "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(corrections), 2)
+	for _, c := range corrections {
+		testutils.AssertEqual(t, c.Kind, CorrectionMetaData)
+		switch c.Id {
+		case proposed.Id:
+			testutils.AssertEqual(t, c.ProposedBy, DeletedUserId)
+			testutils.AssertEqual(t, c.ProposerName, "")
+		case decided.Id:
+			testutils.AssertEqual(t, c.ProposedBy, "user2")
+			testutils.AssertEqual(t, c.DecidedBy, DeletedUserId)
+		}
+	}
+
+	identities, err := store.ScimIdentities(ctx, "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(identities), 0)
+}
+
+func TestInMemoryAccountEraser(t *testing.T) {
+	assertAccountEraser(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGoogleAccountEraser(t *testing.T) {
+	assertAccountEraser(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
+
+func TestSoleAdminOrganizations(t *testing.T) {
+	ctx := context.Background()
+	store := NewMultiOrgInMemoryStore()
+	for _, org := range []*Organization{{Id: "band", Name: "Band"}, {Id: "choir", Name: "Choir"}, {Id: "old", Name: "Old", Deleted: true}, {Id: "orchestra", Name: "Orchestra"}} {
+		testutils.AssertNil(t, store.RegisterOrganization(ctx, org))
+	}
+	user := &UserInfo{Id: "user1", Roles: map[string]RoleKind{"band": RoleAdmin, "choir": RoleAdmin, "old": RoleAdmin, "orchestra": RoleViewer}}
+	for _, u := range []*UserInfo{
+		user,
+		{Id: "user2", Roles: map[string]RoleKind{"choir": RoleAdmin, "band": RoleEditor}},
+	} {
+		testutils.AssertNil(t, store.RegisterUser(ctx, u))
+	}
+
+	orgs, err := SoleAdminOrganizations(ctx, store, user)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(orgs), 1)
+	testutils.AssertEqual(t, orgs[0].Name, "Band")
+}
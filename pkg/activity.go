@@ -0,0 +1,88 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+type ActivityKind string
+
+const (
+	ActivityPieceAdded   ActivityKind = "piece_added"
+	ActivityPieceRemoved ActivityKind = "piece_removed"
+	ActivityDownload     ActivityKind = "download"
+
+	// An email sent to a member of the organization. The user is the recipient, and there is no project
+	ActivityEmailSent ActivityKind = "email_sent"
+)
+
+// Activity is something that happened to a project, e.g. pieces being added or parts downloaded
+type Activity struct {
+	Id          string       `json:"id" firestore:"id"`
+	ProjectId   string       `json:"projectId" firestore:"projectId"`
+	Kind        ActivityKind `json:"kind" firestore:"kind"`
+	ResourceIds []string     `json:"resourceIds" firestore:"resourceIds"`
+	UserId      string       `json:"userId" firestore:"userId"`
+	Time        time.Time    `json:"time" firestore:"time"`
+}
+
+func NewActivity(projectId string, kind ActivityKind, userId string, resourceIds []string) *Activity {
+	now := time.Now()
+	return &Activity{
+		Id:          fmt.Sprintf("%d-%s", now.UnixNano(), RandomInsecureID()),
+		ProjectId:   projectId,
+		Kind:        kind,
+		ResourceIds: resourceIds,
+		UserId:      userId,
+		Time:        now,
+	}
+}
+
+type ActivityRecorder interface {
+	RecordActivity(ctx context.Context, orgId string, activity *Activity) error
+}
+
+type ActivityGetter interface {
+	// ProjectActivity returns the activity of a project with the most recent first
+	ProjectActivity(ctx context.Context, orgId, projectId string) ([]Activity, error)
+}
+
+type OrganizationActivityGetter interface {
+	// OrganizationActivity returns the activity of the organization from (inclusive) to (exclusive) with the
+	// most recent first
+	OrganizationActivity(ctx context.Context, orgId string, from, to time.Time) ([]Activity, error)
+}
+
+type ActivityRemover interface {
+	// RemoveActivity removes the activity of a kind that happened before the given time, and returns the number
+	// of removed activities
+	RemoveActivity(ctx context.Context, orgId string, kind ActivityKind, before time.Time) (int, error)
+}
+
+type ActivityStore interface {
+	ActivityRecorder
+	ActivityGetter
+	OrganizationActivityGetter
+	ActivityRemover
+}
+
+// InTimeRange returns whether t is in [from, to)
+func InTimeRange(t, from, to time.Time) bool {
+	return !t.Before(from) && t.Before(to)
+}
+
+// SortActivity orders the activities with the most recent first
+func SortActivity(activities []Activity) {
+	slices.SortStableFunc(activities, func(a, b Activity) int {
+		return b.Time.Compare(a.Time)
+	})
+}
+
+// Page returns the items on page number page (starting at 0) and whether there are more pages
+func Page[T any](items []T, page, pageSize int) ([]T, bool) {
+	start := min(max(page, 0)*pageSize, len(items))
+	end := min(start+pageSize, len(items))
+	return items[start:end], end < len(items)
+}
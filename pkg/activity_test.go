@@ -0,0 +1,68 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestPage(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+	for _, test := range []struct {
+		page    int
+		want    []int
+		hasMore bool
+	}{
+		{0, []int{1, 2}, true},
+		{1, []int{3, 4}, true},
+		{2, []int{5}, false},
+		{3, []int{}, false},
+		{-1, []int{1, 2}, true},
+	} {
+		got, hasMore := Page(items, test.page, 2)
+		testutils.AssertEqual(t, len(got), len(test.want))
+		for i := range got {
+			testutils.AssertEqual(t, got[i], test.want[i])
+		}
+		testutils.AssertEqual(t, hasMore, test.hasMore)
+	}
+}
+
+func TestSortActivity(t *testing.T) {
+	now := time.Now()
+	activities := []Activity{{Id: "old", Time: now.Add(-time.Hour)}, {Id: "new", Time: now}}
+	SortActivity(activities)
+	testutils.AssertEqual(t, activities[0].Id, "new")
+	testutils.AssertEqual(t, activities[1].Id, "old")
+}
+
+func TestInMemoryProjectActivity(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+
+	first := NewActivity("project", ActivityPieceAdded, "user", []string{"a", "b"})
+	second := NewActivity("project", ActivityDownload, "user", []string{"a"})
+	second.Time = first.Time.Add(time.Minute)
+	other := NewActivity("other", ActivityPieceRemoved, "user", []string{"a"})
+	for _, activity := range []*Activity{first, second, other} {
+		testutils.AssertNil(t, store.RecordActivity(ctx, "org", activity))
+	}
+
+	activities, err := store.ProjectActivity(ctx, "org", "project")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(activities), 2)
+	testutils.AssertEqual(t, activities[0].Kind, ActivityDownload)
+	testutils.AssertEqual(t, activities[1].Kind, ActivityPieceAdded)
+
+	activities, err = store.ProjectActivity(ctx, "other-org", "project")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(activities), 0)
+
+	clone := store.Clone()
+	testutils.AssertNil(t, clone.RecordActivity(ctx, "org", NewActivity("project", ActivityDownload, "user", nil)))
+	activities, err = store.ProjectActivity(ctx, "org", "project")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(activities), 2)
+}
@@ -0,0 +1,85 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const maxAnnouncementLength = 10000
+
+// Announcement is a message from the admins of an organization to all members. The body is markdown
+type Announcement struct {
+	Id        string    `json:"id" firestore:"id"`
+	Title     string    `json:"title" firestore:"title"`
+	Body      string    `json:"body" firestore:"body"`
+	AuthorId  string    `json:"authorId" firestore:"authorId"`
+	CreatedAt time.Time `json:"createdAt" firestore:"createdAt"`
+
+	// The announcement is hidden after ExpiresAt. The zero time means that it never expires
+	ExpiresAt time.Time `json:"expiresAt" firestore:"expiresAt"`
+
+	// Ids of the users that have marked the announcement as read
+	ReadBy []string `json:"readBy" firestore:"readBy"`
+}
+
+func NewAnnouncement(title, body, authorId string, expiresAt time.Time) *Announcement {
+	now := time.Now()
+	return &Announcement{
+		Id:        fmt.Sprintf("%d-%s", now.UnixNano(), RandomInsecureID()),
+		Title:     strings.TrimSpace(title),
+		Body:      body,
+		AuthorId:  authorId,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+		ReadBy:    []string{},
+	}
+}
+
+func (a *Announcement) Validate() error {
+	if a.Title == "" {
+		return errors.Join(ErrInvalidAnnouncement, errors.New("title can not be empty"))
+	}
+	if len(a.Title)+len(a.Body) > maxAnnouncementLength {
+		return errors.Join(ErrInvalidAnnouncement, fmt.Errorf("announcement can not be longer than %d characters", maxAnnouncementLength))
+	}
+	return nil
+}
+
+func (a *Announcement) Active(now time.Time) bool {
+	return a.ExpiresAt.IsZero() || now.Before(a.ExpiresAt)
+}
+
+func (a *Announcement) IsReadBy(userId string) bool {
+	return slices.Contains(a.ReadBy, userId)
+}
+
+type AnnouncementStore interface {
+	SubmitAnnouncement(ctx context.Context, orgId string, announcement *Announcement) error
+
+	// Announcements returns all announcements of the organization including expired ones, newest first
+	Announcements(ctx context.Context, orgId string) ([]Announcement, error)
+
+	// ExpireAnnouncement hides the announcement from the time given
+	ExpireAnnouncement(ctx context.Context, orgId, id string, at time.Time) error
+	MarkAnnouncementRead(ctx context.Context, orgId, id, userId string) error
+}
+
+// SortAnnouncements orders the announcements with the most recent first
+func SortAnnouncements(announcements []Announcement) {
+	slices.SortStableFunc(announcements, func(a, b Announcement) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+}
+
+// ActiveAnnouncements returns the announcements that have not expired
+func ActiveAnnouncements(announcements []Announcement, now time.Time) []Announcement {
+	return slices.DeleteFunc(slices.Clone(announcements), func(a Announcement) bool { return !a.Active(now) })
+}
+
+func announcementNotFound(id string) error {
+	return errors.Join(ErrAnnouncementNotFound, fmt.Errorf("announcement id: %s", id))
+}
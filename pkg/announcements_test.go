@@ -0,0 +1,89 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestAnnouncementValidate(t *testing.T) {
+	for _, test := range []struct {
+		announcement *Announcement
+		valid        bool
+	}{
+		{NewAnnouncement("Concert", "Remember black clothes", "user", time.Time{}), true},
+		{NewAnnouncement("  ", "Body", "user", time.Time{}), false},
+		{NewAnnouncement("Long", strings.Repeat("a", maxAnnouncementLength), "user", time.Time{}), false},
+	} {
+		err := test.announcement.Validate()
+		testutils.AssertEqual(t, err == nil, test.valid)
+		if !test.valid {
+			testutils.AssertEqual(t, errors.Is(err, ErrInvalidAnnouncement), true)
+		}
+	}
+}
+
+func TestActiveAnnouncements(t *testing.T) {
+	now := time.Now()
+	announcements := []Announcement{
+		{Id: "never"},
+		{Id: "expired", ExpiresAt: now.Add(-time.Hour)},
+		{Id: "future", ExpiresAt: now.Add(time.Hour)},
+	}
+	active := ActiveAnnouncements(announcements, now)
+	testutils.AssertEqual(t, len(active), 2)
+	testutils.AssertEqual(t, active[0].Id, "never")
+	testutils.AssertEqual(t, active[1].Id, "future")
+	testutils.AssertEqual(t, len(announcements), 3)
+}
+
+// assertAnnouncementStore runs the same checks against all implementations of the announcement store
+func assertAnnouncementStore(t *testing.T, store AnnouncementStore) {
+	ctx := context.Background()
+	first := NewAnnouncement("Rehearsal moved", "Rehearsal is **Thursday** this week", "admin", time.Time{})
+	first.CreatedAt = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	second := NewAnnouncement("Concert", "Remember black clothes", "admin", time.Time{})
+	second.CreatedAt = first.CreatedAt.Add(time.Hour)
+
+	testutils.AssertNil(t, store.SubmitAnnouncement(ctx, "org", first))
+	testutils.AssertNil(t, store.SubmitAnnouncement(ctx, "org", second))
+	testutils.AssertNil(t, store.SubmitAnnouncement(ctx, "other-org", NewAnnouncement("Other", "", "admin", time.Time{})))
+
+	err := store.SubmitAnnouncement(ctx, "org", NewAnnouncement("", "", "admin", time.Time{}))
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidAnnouncement), true)
+
+	announcements, err := store.Announcements(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(announcements), 2)
+	testutils.AssertEqual(t, announcements[0].Id, second.Id)
+	testutils.AssertEqual(t, announcements[1].Body, first.Body)
+
+	testutils.AssertNil(t, store.MarkAnnouncementRead(ctx, "org", first.Id, "user1"))
+	testutils.AssertNil(t, store.MarkAnnouncementRead(ctx, "org", first.Id, "user1"))
+	testutils.AssertNil(t, store.MarkAnnouncementRead(ctx, "org", first.Id, "user2"))
+	expireAt := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	testutils.AssertNil(t, store.ExpireAnnouncement(ctx, "org", second.Id, expireAt))
+
+	announcements, err = store.Announcements(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, announcements[0].ExpiresAt.Equal(expireAt), true)
+	testutils.AssertEqual(t, slices.Equal(announcements[1].ReadBy, []string{"user1", "user2"}), true)
+
+	err = store.MarkAnnouncementRead(ctx, "org", "unknown", "user1")
+	testutils.AssertEqual(t, errors.Is(err, ErrAnnouncementNotFound), true)
+	err = store.ExpireAnnouncement(ctx, "other-org", first.Id, expireAt)
+	testutils.AssertEqual(t, errors.Is(err, ErrAnnouncementNotFound), true)
+}
+
+func TestInMemoryAnnouncements(t *testing.T) {
+	assertAnnouncementStore(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGoogleAnnouncements(t *testing.T) {
+	assertAnnouncementStore(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
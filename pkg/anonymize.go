@@ -0,0 +1,163 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+type AnonymizeStats struct {
+	NumOrganizations int
+	NumUsers         int
+	NumResources     int
+	NumProjects      int
+}
+
+// AnonymizingCopier copies organizations, users, scores and projects from Source into Target.
+// Names, emails and organization names are replaced by placeholders, and passwords are dropped
+type AnonymizingCopier struct {
+	Source Store
+	Target Store
+}
+
+func (a *AnonymizingCopier) Copy(ctx context.Context) (AnonymizeStats, error) {
+	var stats AnonymizeStats
+	orgs, err := a.Source.ListOrganizations(ctx)
+	if err != nil {
+		return stats, err
+	}
+	slices.SortFunc(orgs, func(x, y Organization) int { return strings.Compare(x.Id, y.Id) })
+
+	copiedOrgs := make(map[string]struct{})
+	users := []UserInfo{}
+	seenUsers := make(map[string]int)
+	for _, org := range orgs {
+		if org.Deleted {
+			continue
+		}
+
+		if orgErr := a.copyOrganization(ctx, &org, &stats); orgErr != nil {
+			err = errors.Join(err, fmt.Errorf("organization %s: %w", org.Id, orgErr))
+			continue
+		}
+		copiedOrgs[org.Id] = struct{}{}
+
+		members, usersErr := a.Source.GetUsersInOrg(ctx, org.Id)
+		if usersErr != nil {
+			err = errors.Join(err, usersErr)
+		}
+		users = mergeMembers(users, seenUsers, members)
+	}
+
+	for i, user := range users {
+		anonymous := AnonymizeUser(&user, i+1, copiedOrgs)
+		if userErr := a.Target.RegisterUser(ctx, anonymous); userErr != nil {
+			err = errors.Join(err, userErr)
+			continue
+		}
+		stats.NumUsers++
+	}
+	return stats, err
+}
+
+func (a *AnonymizingCopier) copyOrganization(ctx context.Context, org *Organization, stats *AnonymizeStats) error {
+	anonymousOrg := Organization{
+		Id:        org.Id,
+		Name:      fmt.Sprintf("Organization %d", stats.NumOrganizations+1),
+		NumScores: org.NumScores,
+	}
+	if org.StripeId != "" {
+		anonymousOrg.StripeId = "anonymized-" + org.Id
+	}
+
+	if err := a.Target.RegisterOrganization(ctx, &anonymousOrg); err != nil {
+		return err
+	}
+	stats.NumOrganizations++
+
+	var err error
+	if anonymousOrg.StripeId != "" {
+		subscription, subErr := a.Source.GetSubscription(ctx, org.Id)
+		if subErr == nil {
+			err = errors.Join(err, a.Target.StoreSubscription(ctx, anonymousOrg.StripeId, subscription))
+		} else if !errors.Is(subErr, ErrSubscriptionNotFound) {
+			err = errors.Join(err, subErr)
+		}
+	}
+
+	metas, metaErr := a.Source.MetaByPattern(ctx, org.Id, &MetaData{})
+	err = errors.Join(err, metaErr)
+	for _, meta := range metas {
+		content := a.Source.Resource(ctx, org.Id, meta.ResourceId())
+		if submitErr := a.Target.Submit(ctx, org.Id, &meta, content); submitErr != nil {
+			err = errors.Join(err, submitErr)
+			continue
+		}
+		stats.NumResources++
+	}
+
+	projects, projectErr := a.Source.ProjectsByName(ctx, org.Id, "")
+	err = errors.Join(err, projectErr)
+	for _, project := range projects {
+		if submitErr := a.Target.SubmitProject(ctx, org.Id, &project); submitErr != nil {
+			err = errors.Join(err, submitErr)
+			continue
+		}
+		stats.NumProjects++
+	}
+	return err
+}
+
+// AnonymizeUser returns a copy of the user where all personal information is replaced by placeholders
+// derived from num. Only roles and groups in the passed organizations are kept
+func AnonymizeUser(user *UserInfo, num int, orgs map[string]struct{}) *UserInfo {
+	anonymous := UserInfo{
+		Id:            user.Id,
+		Name:          fmt.Sprintf("Member %d", num),
+		Email:         fmt.Sprintf("member%d@example.com", num),
+		VerifiedEmail: user.VerifiedEmail,
+		Roles:         make(map[string]RoleKind),
+		Groups:        make(map[string][]string),
+	}
+
+	for orgId, role := range user.Roles {
+		if _, ok := orgs[orgId]; ok {
+			anonymous.Roles[orgId] = role
+		}
+	}
+
+	for orgId, groups := range user.Groups {
+		if _, ok := orgs[orgId]; ok {
+			anonymous.Groups[orgId] = slices.Clone(groups)
+		}
+	}
+	return &anonymous
+}
+
+// mergeMembers appends members not already in seen to users. For users that are already collected the roles
+// and groups are merged, since the members of an organization may only carry those of that organization
+func mergeMembers(users []UserInfo, seen map[string]int, members []UserInfo) []UserInfo {
+	for _, member := range members {
+		idx, ok := seen[member.Id]
+		if !ok {
+			// Cloned since the roles and groups of other organizations are merged in below
+			member.Roles = maps.Clone(member.Roles)
+			member.Groups = maps.Clone(member.Groups)
+			if member.Roles == nil {
+				member.Roles = make(map[string]RoleKind)
+			}
+			if member.Groups == nil {
+				member.Groups = make(map[string][]string)
+			}
+			seen[member.Id] = len(users)
+			users = append(users, member)
+			continue
+		}
+		maps.Copy(users[idx].Roles, member.Roles)
+		maps.Copy(users[idx].Groups, member.Groups)
+	}
+	return users
+}
@@ -0,0 +1,119 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestAnonymizingCopier(t *testing.T) {
+	source := NewDemoStore()
+	source.Users[0].Email = "susan@choir.no"
+	source.Users[0].Password = "hashed-password"
+	source.Organizations[0].StripeId = "cus_123"
+	source.Users = append(source.Users, UserInfo{
+		Id:    "outside",
+		Name:  "Outsider",
+		Roles: map[string]RoleKind{"unknown-org": RoleAdmin},
+	})
+	target := NewMultiOrgInMemoryStore()
+
+	copier := AnonymizingCopier{Source: source, Target: target}
+	stats, err := copier.Copy(context.Background())
+	testutils.AssertNil(t, err)
+
+	testutils.AssertEqual(t, stats.NumOrganizations, 2)
+	testutils.AssertEqual(t, stats.NumUsers, 2)
+	testutils.AssertEqual(t, stats.NumResources, 4)
+	testutils.AssertEqual(t, stats.NumProjects, 2)
+
+	for _, org := range target.Organizations {
+		testutils.AssertContains(t, org.Name, "Organization")
+		testutils.AssertEqual(t, org.StripeId == "cus_123", false)
+	}
+
+	for _, user := range target.Users {
+		testutils.AssertContains(t, user.Name, "Member")
+		testutils.AssertContains(t, user.Email, "@example.com")
+		testutils.AssertEqual(t, user.Password, "")
+	}
+
+	sourceOrgId := source.Organizations[0].Id
+	_, err = target.GetSubscription(context.Background(), sourceOrgId)
+	testutils.AssertNil(t, err)
+
+	for _, store := range target.Data {
+		testutils.AssertEqual(t, len(store.Data), 10)
+	}
+}
+
+func TestAnonymizeUser(t *testing.T) {
+	user := UserInfo{
+		Id:       "user",
+		Name:     "Jane Doe",
+		Email:    "jane@doe.com",
+		Password: "secret",
+		Roles:    map[string]RoleKind{"org1": RoleAdmin, "org2": RoleViewer},
+		Groups:   map[string][]string{"org1": {"Alto"}, "org2": {"Tenor"}},
+	}
+
+	anonymous := AnonymizeUser(&user, 3, map[string]struct{}{"org1": {}})
+	testutils.AssertEqual(t, anonymous.Id, "user")
+	testutils.AssertEqual(t, anonymous.Name, "Member 3")
+	testutils.AssertEqual(t, anonymous.Email, "member3@example.com")
+	testutils.AssertEqual(t, anonymous.Password, "")
+	testutils.AssertEqual(t, len(anonymous.Roles), 1)
+	testutils.AssertEqual(t, len(anonymous.Groups), 1)
+	testutils.AssertEqual(t, strings.Join(anonymous.Groups["org1"], ","), "Alto")
+}
+
+func TestAnonymizingCopierListError(t *testing.T) {
+	source := anonymizeSourceWithLister{
+		MultiOrgInMemoryStore: NewDemoStore(),
+		err:                   errors.New("could not list"),
+	}
+	copier := AnonymizingCopier{Source: &source, Target: NewMultiOrgInMemoryStore()}
+	_, err := copier.Copy(context.Background())
+	if err == nil {
+		t.Fatal("Wanted error")
+	}
+}
+
+type anonymizeSourceWithLister struct {
+	*MultiOrgInMemoryStore
+	err error
+}
+
+func (a *anonymizeSourceWithLister) ListOrganizations(ctx context.Context) ([]Organization, error) {
+	return []Organization{}, a.err
+}
+
+type orgScopedMembers struct {
+	*MultiOrgInMemoryStore
+}
+
+func (o *orgScopedMembers) GetUsersInOrg(ctx context.Context, orgId string) ([]UserInfo, error) {
+	users, err := o.MultiOrgInMemoryStore.GetUsersInOrg(ctx, orgId)
+	for i, user := range users {
+		link := UserOrganizationLink{UserId: user.Id, OrgId: orgId, Role: user.Roles[orgId], Groups: user.Groups[orgId], Name: user.Name}
+		users[i] = link.Member()
+	}
+	return users, err
+}
+
+func TestAnonymizingCopierMergesMemberships(t *testing.T) {
+	source := NewDemoStore()
+	copier := AnonymizingCopier{Source: &orgScopedMembers{source}, Target: NewMultiOrgInMemoryStore()}
+	_, err := copier.Copy(context.Background())
+	testutils.AssertNil(t, err)
+
+	target := copier.Target.(*MultiOrgInMemoryStore)
+	for _, sourceUser := range source.Users {
+		copied, err := target.GetUserInfo(context.Background(), sourceUser.Id)
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, len(copied.Roles), len(sourceUser.Roles))
+	}
+}
@@ -0,0 +1,142 @@
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxApiTokenNameLength = 100
+
+	// ApiTokenPrefix makes tokens easy to recognize, e.g. by secret scanners
+	ApiTokenPrefix = "cae_"
+)
+
+type ApiTokenScope string
+
+const (
+	ApiTokenRead  ApiTokenScope = "read"
+	ApiTokenWrite ApiTokenScope = "write"
+
+	// ApiTokenScim tokens are used by identity providers to provision members. They can only be used with the
+	// SCIM endpoint
+	ApiTokenScim ApiTokenScope = "scim"
+)
+
+var ApiTokenScopes = []ApiTokenScope{ApiTokenRead, ApiTokenWrite, ApiTokenScim}
+
+// Role returns the highest role a token with the scope acts with
+func (s ApiTokenScope) Role() RoleKind {
+	switch s {
+	case ApiTokenWrite:
+		return RoleEditor
+	case ApiTokenScim:
+		return RoleAdmin
+	}
+	return RoleViewer
+}
+
+// Limit returns the role a token with the scope acts with for an owner with the passed role
+func (s ApiTokenScope) Limit(role RoleKind) RoleKind {
+	if role.AtLeast(s.Role()) {
+		return s.Role()
+	}
+	return role
+}
+
+// ApiToken gives scripts access to a single organization on behalf of a user. Only the hash of the token is
+// stored, hence the token itself is only known when it is created
+type ApiToken struct {
+	// Id is the beginning of the hash. It identifies the token without revealing it
+	Id     string        `json:"id" firestore:"id"`
+	Hash   string        `json:"hash" firestore:"hash"`
+	UserId string        `json:"userId" firestore:"userId"`
+	OrgId  string        `json:"orgId" firestore:"orgId"`
+	Name   string        `json:"name" firestore:"name"`
+	Scope  ApiTokenScope `json:"scope" firestore:"scope"`
+
+	// The token was created by a user that signed in with a passkey
+	Passkey   bool      `json:"passkey" firestore:"passkey"`
+	CreatedAt time.Time `json:"createdAt" firestore:"createdAt"`
+
+	// The token never expires when ExpiresAt is zero
+	ExpiresAt time.Time `json:"expiresAt" firestore:"expiresAt"`
+}
+
+// HashApiToken returns the hex encoded SHA-256 hash under which a token is stored
+func HashApiToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// NewApiToken returns a new token and the secret to hand to the user. The secret can not be recovered later
+func NewApiToken(userId, orgId, name string, scope ApiTokenScope, expiresAt time.Time) (*ApiToken, string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, "", err
+	}
+	secret := ApiTokenPrefix + base64.RawURLEncoding.EncodeToString(random)
+	hash := HashApiToken(secret)
+	token := &ApiToken{
+		Id:        hash[:16],
+		Hash:      hash,
+		UserId:    userId,
+		OrgId:     orgId,
+		Name:      strings.TrimSpace(name),
+		Scope:     scope,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+	return token, secret, token.Validate()
+}
+
+func (t *ApiToken) Validate() error {
+	if t.Id == "" || t.Hash == "" || t.UserId == "" || t.OrgId == "" {
+		return errors.Join(ErrInvalidApiToken, errors.New("id, hash, user and organization can not be empty"))
+	}
+	if t.Name == "" {
+		return errors.Join(ErrInvalidApiToken, errors.New("name can not be empty"))
+	}
+	if utf8.RuneCountInString(t.Name) > maxApiTokenNameLength {
+		return errors.Join(ErrInvalidApiToken, fmt.Errorf("name can not be longer than %d characters", maxApiTokenNameLength))
+	}
+	if !slices.Contains(ApiTokenScopes, t.Scope) {
+		return errors.Join(ErrInvalidApiToken, fmt.Errorf("unknown scope %q", t.Scope))
+	}
+	return nil
+}
+
+func (t *ApiToken) Expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+type ApiTokenGetter interface {
+	// ApiTokenByHash returns the token with the passed hash. Expired tokens are also returned
+	ApiTokenByHash(ctx context.Context, hash string) (ApiToken, error)
+}
+
+type ApiTokenStore interface {
+	ApiTokenGetter
+	SaveApiToken(ctx context.Context, token *ApiToken) error
+
+	// ApiTokens returns the tokens of the user, newest first
+	ApiTokens(ctx context.Context, userId string) ([]ApiToken, error)
+	RevokeApiToken(ctx context.Context, userId, id string) error
+}
+
+func SortApiTokens(tokens []ApiToken) {
+	slices.SortStableFunc(tokens, func(a, b ApiToken) int { return b.CreatedAt.Compare(a.CreatedAt) })
+}
+
+func apiTokenNotFound(id string) error {
+	return errors.Join(ErrApiTokenNotFound, fmt.Errorf("api token id: %s", id))
+}
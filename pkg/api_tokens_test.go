@@ -0,0 +1,116 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestNewApiToken(t *testing.T) {
+	token, secret, err := NewApiToken("user1", "org1", " Nightly upload ", ApiTokenWrite, time.Time{})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.HasPrefix(secret, ApiTokenPrefix), true)
+	testutils.AssertEqual(t, token.Hash, HashApiToken(secret))
+	testutils.AssertEqual(t, strings.HasPrefix(token.Hash, token.Id), true)
+	testutils.AssertEqual(t, token.Name, "Nightly upload")
+	testutils.AssertEqual(t, token.Scope.Role(), RoleEditor)
+	testutils.AssertEqual(t, ApiTokenRead.Role(), RoleViewer)
+	testutils.AssertEqual(t, ApiTokenScim.Role(), RoleAdmin)
+
+	_, other, err := NewApiToken("user1", "org1", "Other", ApiTokenRead, time.Time{})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, other == secret, false)
+
+	for _, test := range []struct {
+		desc  string
+		name  string
+		scope ApiTokenScope
+	}{
+		{"empty name", " ", ApiTokenRead},
+		{"long name", strings.Repeat("a", maxApiTokenNameLength+1), ApiTokenRead},
+		{"unknown scope", "Script", "admin"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			_, _, err := NewApiToken("user1", "org1", test.name, test.scope, time.Time{})
+			testutils.AssertEqual(t, errors.Is(err, ErrInvalidApiToken), true)
+		})
+	}
+}
+
+func TestApiTokenScopeLimit(t *testing.T) {
+	testutils.AssertEqual(t, ApiTokenWrite.Limit(RoleAdmin), RoleEditor)
+	testutils.AssertEqual(t, ApiTokenWrite.Limit(RoleLibrarian), RoleEditor)
+	testutils.AssertEqual(t, ApiTokenWrite.Limit(RoleViewer), RoleViewer)
+	testutils.AssertEqual(t, ApiTokenScim.Limit(RoleAdmin), RoleAdmin)
+
+	// Librarians have a higher value than admins, but fewer privileges
+	testutils.AssertEqual(t, ApiTokenScim.Limit(RoleLibrarian), RoleKind(RoleLibrarian))
+}
+
+func TestApiTokenExpired(t *testing.T) {
+	now := time.Now()
+	testutils.AssertEqual(t, (&ApiToken{}).Expired(now), false)
+	testutils.AssertEqual(t, (&ApiToken{ExpiresAt: now.Add(time.Minute)}).Expired(now), false)
+	testutils.AssertEqual(t, (&ApiToken{ExpiresAt: now}).Expired(now), true)
+}
+
+func assertApiTokenStore(t *testing.T, store ApiTokenStore) {
+	ctx := context.Background()
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	first, firstSecret, err := NewApiToken("user1", "org1", "First", ApiTokenRead, expiresAt)
+	testutils.AssertNil(t, err)
+	first.CreatedAt = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	second, _, err := NewApiToken("user1", "org2", "Second", ApiTokenWrite, time.Time{})
+	testutils.AssertNil(t, err)
+	second.CreatedAt = first.CreatedAt.Add(time.Hour)
+	other, _, err := NewApiToken("user10", "org1", "Other", ApiTokenRead, time.Time{})
+	testutils.AssertNil(t, err)
+
+	for _, token := range []*ApiToken{first, second, other} {
+		testutils.AssertNil(t, store.SaveApiToken(ctx, token))
+	}
+	err = store.SaveApiToken(ctx, &ApiToken{Id: "invalid"})
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidApiToken), true)
+
+	found, err := store.ApiTokenByHash(ctx, HashApiToken(firstSecret))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, found.Id, first.Id)
+	testutils.AssertEqual(t, found.OrgId, "org1")
+	testutils.AssertEqual(t, found.Scope, ApiTokenRead)
+	testutils.AssertEqual(t, found.ExpiresAt.Equal(expiresAt), true)
+
+	_, err = store.ApiTokenByHash(ctx, HashApiToken("unknown"))
+	testutils.AssertEqual(t, errors.Is(err, ErrApiTokenNotFound), true)
+
+	tokens, err := store.ApiTokens(ctx, "user1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(tokens), 2)
+	testutils.AssertEqual(t, tokens[0].Id, second.Id)
+	testutils.AssertEqual(t, tokens[1].Id, first.Id)
+	testutils.AssertEqual(t, tokens[0].ExpiresAt.IsZero(), true)
+
+	err = store.RevokeApiToken(ctx, "user10", first.Id)
+	testutils.AssertEqual(t, errors.Is(err, ErrApiTokenNotFound), true)
+	testutils.AssertNil(t, store.RevokeApiToken(ctx, "user1", first.Id))
+	err = store.RevokeApiToken(ctx, "user1", first.Id)
+	testutils.AssertEqual(t, errors.Is(err, ErrApiTokenNotFound), true)
+
+	_, err = store.ApiTokenByHash(ctx, first.Hash)
+	testutils.AssertEqual(t, errors.Is(err, ErrApiTokenNotFound), true)
+
+	tokens, err = store.ApiTokens(ctx, "unknown")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(tokens), 0)
+}
+
+func TestInMemoryApiTokens(t *testing.T) {
+	assertApiTokenStore(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGoogleApiTokens(t *testing.T) {
+	assertApiTokenStore(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
@@ -0,0 +1,167 @@
+package pkg
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+const AppleIssuer = "https://appleid.apple.com"
+
+var AppleEndpoint = oauth2.Endpoint{
+	AuthURL:   AppleIssuer + "/auth/authorize",
+	TokenURL:  AppleIssuer + "/auth/token",
+	AuthStyle: oauth2.AuthStyleInParams,
+}
+
+// Apple accepts client secrets that are valid for up to six months. A new secret is made for each sign in
+const appleClientSecretLifetime = 5 * time.Minute
+
+// AppleConfig configures Sign in with Apple. The client id is the identifier of the services id, and the key is
+// the private key (.p8 file) created for Sign in with Apple in the Apple developer account
+type AppleConfig struct {
+	ClientId    string `yaml:"client_id"`
+	TeamId      string `yaml:"team_id"`
+	KeyId       string `yaml:"key_id"`
+	PrivateKey  string `yaml:"private_key" secret:"true"`
+	RedirectURL string `yaml:"redirect_url"`
+}
+
+func (a *AppleConfig) Enabled() bool {
+	return a.ClientId != ""
+}
+
+func (a *AppleConfig) Validate() error {
+	if a.TeamId == "" || a.KeyId == "" || a.PrivateKey == "" {
+		return fmt.Errorf("apple.team_id, apple.key_id and apple.private_key must be specified when apple.client_id is set")
+	}
+	_, err := a.signingKey()
+	return err
+}
+
+func (a *AppleConfig) signingKey() (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(a.PrivateKey))
+	if block == nil {
+		return nil, errors.New("apple.private_key is not a PEM encoded key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("apple.private_key: %w", err)
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("apple.private_key must be an elliptic curve key")
+	}
+	return ecKey, nil
+}
+
+// ClientSecret returns the client secret used when exchanging the code. Apple expects a JWT signed with the
+// private key of the team instead of a fixed secret
+func (a *AppleConfig) ClientSecret(now time.Time) (string, error) {
+	key, err := a.signingKey()
+	if err != nil {
+		return "", err
+	}
+	claims := jwt.RegisteredClaims{
+		Issuer:    a.TeamId,
+		Subject:   a.ClientId,
+		Audience:  jwt.ClaimStrings{AppleIssuer},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(appleClientSecretLifetime)),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = a.KeyId
+	return token.SignedString(key)
+}
+
+// OAuthConfig returns the code flow of Apple. The client secret is set by the callback
+func (a *AppleConfig) OAuthConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:    a.ClientId,
+		RedirectURL: a.RedirectURL,
+		Scopes:      []string{"name", "email"},
+		Endpoint:    AppleEndpoint,
+	}
+}
+
+// appleBool is a boolean claim that Apple sends either as a boolean or as the string "true" or "false"
+type appleBool bool
+
+func (a *appleBool) UnmarshalJSON(data []byte) error {
+	var value bool
+	if err := json.Unmarshal(data, &value); err == nil {
+		*a = appleBool(value)
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	*a = appleBool(text == "true")
+	return nil
+}
+
+// AppleIdTokenClaims are the claims of the id token returned by Apple. Apple has no userinfo endpoint, so the
+// email of the user is read from the id token
+type AppleIdTokenClaims struct {
+	Email          string    `json:"email"`
+	EmailVerified  appleBool `json:"email_verified"`
+	IsPrivateEmail appleBool `json:"is_private_email"`
+	jwt.RegisteredClaims
+}
+
+// ParseAppleIdToken reads the claims of an id token and checks the issuer, audience and expiry. The signature is
+// not checked, since the token is received directly from the token endpoint of Apple over TLS
+func ParseAppleIdToken(idToken, clientId string, now time.Time) (*AppleIdTokenClaims, error) {
+	var claims AppleIdTokenClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(idToken, &claims); err != nil {
+		return nil, err
+	}
+
+	validator := jwt.NewValidator(
+		jwt.WithIssuer(AppleIssuer),
+		jwt.WithAudience(clientId),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+	)
+	if err := validator.Validate(claims); err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("id token has no subject")
+	}
+	return &claims, nil
+}
+
+// AppleUser is posted to the callback together with the code the first time a user signs in. It is the only
+// time Apple shares the name of the user
+type AppleUser struct {
+	Name struct {
+		FirstName string `json:"firstName"`
+		LastName  string `json:"lastName"`
+	} `json:"name"`
+}
+
+// AppleUserInfo combines the claims of the id token with the name posted on the first sign in. The name is
+// empty when user is nil
+func AppleUserInfo(claims *AppleIdTokenClaims, user *AppleUser) UserInfo {
+	name := ""
+	if user != nil {
+		name = strings.TrimSpace(user.Name.FirstName + " " + user.Name.LastName)
+	}
+	return UserInfo{
+		Id:            ExternalUserId(AppleIssuer, claims.Subject),
+		Email:         strings.ToLower(claims.Email),
+		VerifiedEmail: bool(claims.EmailVerified),
+		Name:          name,
+	}
+}
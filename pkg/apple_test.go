@@ -0,0 +1,133 @@
+package pkg
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testAppleConfig(t *testing.T) (AppleConfig, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testutils.AssertNil(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	testutils.AssertNil(t, err)
+
+	config := AppleConfig{
+		ClientId:   "no.example.caesura",
+		TeamId:     "TEAM123",
+		KeyId:      "KEY123",
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	}
+	return config, key
+}
+
+func TestAppleClientSecret(t *testing.T) {
+	config, key := testAppleConfig(t)
+	testutils.AssertNil(t, config.Validate())
+
+	now := time.Now()
+	secret, err := config.ClientSecret(now)
+	testutils.AssertNil(t, err)
+
+	var claims jwt.RegisteredClaims
+	token, err := jwt.ParseWithClaims(secret, &claims, func(token *jwt.Token) (any, error) {
+		return &key.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"ES256"}))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, token.Header["kid"], any("KEY123"))
+	testutils.AssertEqual(t, claims.Issuer, "TEAM123")
+	testutils.AssertEqual(t, claims.Subject, "no.example.caesura")
+	testutils.AssertEqual(t, claims.Audience[0], AppleIssuer)
+}
+
+func TestAppleConfigValidate(t *testing.T) {
+	config, _ := testAppleConfig(t)
+	config.KeyId = ""
+	testutils.AssertContains(t, config.Validate().Error(), "apple.key_id")
+
+	config, _ = testAppleConfig(t)
+	config.PrivateKey = "not a key"
+	testutils.AssertContains(t, config.Validate().Error(), "PEM")
+
+	defaultConfig := NewDefaultConfig()
+	defaultConfig.Apple.ClientId = "no.example.caesura"
+	if err := defaultConfig.Validate(); err == nil {
+		t.Fatal("expected validation to fail when apple.client_id is set without a key")
+	}
+}
+
+func appleIdToken(t *testing.T, claims map[string]any) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims(claims)).SignedString([]byte("key"))
+	testutils.AssertNil(t, err)
+	return token
+}
+
+func TestParseAppleIdToken(t *testing.T) {
+	now := time.Now()
+	valid := map[string]any{
+		"iss":            AppleIssuer,
+		"aud":            "no.example.caesura",
+		"sub":            "001234.abcd",
+		"exp":            now.Add(time.Minute).Unix(),
+		"email":          "Kari@privaterelay.appleid.com",
+		"email_verified": "true",
+	}
+
+	claims, err := ParseAppleIdToken(appleIdToken(t, valid), "no.example.caesura", now)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, claims.Subject, "001234.abcd")
+	testutils.AssertEqual(t, bool(claims.EmailVerified), true)
+
+	for _, test := range []struct {
+		desc  string
+		key   string
+		value any
+	}{
+		{desc: "other issuer", key: "iss", value: "https://accounts.google.com"},
+		{desc: "other client", key: "aud", value: "no.example.other"},
+		{desc: "expired", key: "exp", value: now.Add(-time.Minute).Unix()},
+		{desc: "no subject", key: "sub", value: ""},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			claims := map[string]any{}
+			for key, value := range valid {
+				claims[key] = value
+			}
+			claims[test.key] = test.value
+			_, err := ParseAppleIdToken(appleIdToken(t, claims), "no.example.caesura", now)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+
+	_, err = ParseAppleIdToken("not-a-token", "no.example.caesura", now)
+	if err == nil {
+		t.Fatal("expected an error for a malformed token")
+	}
+}
+
+func TestAppleUserInfo(t *testing.T) {
+	var claims AppleIdTokenClaims
+	testutils.AssertNil(t, json.Unmarshal([]byte(`{"sub": "001234.abcd", "email": "Kari@Example.com", "email_verified": true}`), &claims))
+
+	var user AppleUser
+	testutils.AssertNil(t, json.Unmarshal([]byte(`{"name": {"firstName": "Kari", "lastName": "Nordmann"}, "email": "kari@example.com"}`), &user))
+
+	info := AppleUserInfo(&claims, &user)
+	testutils.AssertEqual(t, info.Id, ExternalUserId(AppleIssuer, "001234.abcd"))
+	testutils.AssertEqual(t, info.Email, "kari@example.com")
+	testutils.AssertEqual(t, info.VerifiedEmail, true)
+	testutils.AssertEqual(t, info.Name, "Kari Nordmann")
+
+	// The name is only sent on the first sign in
+	testutils.AssertEqual(t, AppleUserInfo(&claims, nil).Name, "")
+}
@@ -0,0 +1,281 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+	"google.golang.org/api/iterator"
+)
+
+type ArchiveStatus string
+
+const (
+	ArchivePending ArchiveStatus = "pending"
+	ArchiveReady   ArchiveStatus = "ready"
+	ArchiveFailed  ArchiveStatus = "failed"
+)
+
+// Archives are stored in chunks such that a range can be served without reading the whole archive
+const DefaultArchiveChunkSize = 8 << 20
+
+// Archives are kept below their own prefix and not below the prefix of the organization, such that they are
+// not mistaken for parts by the orphan check
+const (
+	archiveDir      = "archives"
+	archiveMetaName = "archive.json"
+)
+
+// Archive is a zip file of parts generated in the background. The archive is kept until ExpiresAt, such that
+// a download that is interrupted can be resumed with a range request instead of starting over
+type Archive struct {
+	Id        string        `json:"id"`
+	OrgId     string        `json:"orgId"`
+	Filename  string        `json:"filename"`
+	Status    ArchiveStatus `json:"status"`
+	Size      int64         `json:"size"`
+	ChunkSize int64         `json:"chunkSize"`
+	NumChunks int           `json:"numChunks"`
+	CreatedAt time.Time     `json:"createdAt"`
+	ExpiresAt time.Time     `json:"expiresAt"`
+}
+
+func NewArchive(orgId, filename string, expiry time.Duration) *Archive {
+	now := time.Now()
+	return &Archive{
+		Id:        uuid.NewString(),
+		OrgId:     orgId,
+		Filename:  filename,
+		Status:    ArchivePending,
+		ChunkSize: DefaultArchiveChunkSize,
+		CreatedAt: now,
+		ExpiresAt: now.Add(expiry),
+	}
+}
+
+func (a *Archive) Expired(now time.Time) bool {
+	return !now.Before(a.ExpiresAt)
+}
+
+type ArchiveChunkGetter interface {
+	ArchiveChunk(ctx context.Context, orgId, id string, index int) ([]byte, error)
+}
+
+type ArchiveStore interface {
+	ArchiveChunkGetter
+	SaveArchive(ctx context.Context, archive *Archive) error
+
+	// Archive returns ErrArchiveNotFound when there is no archive with the id in the organization
+	Archive(ctx context.Context, orgId, id string) (*Archive, error)
+	SaveArchiveChunk(ctx context.Context, orgId, id string, index int, data []byte) error
+	ArchiveDeleter
+}
+
+// ArchiveDeleter removes the metadata and chunks of an archive. It is not an error to delete an archive that is
+// already removed
+type ArchiveDeleter interface {
+	DeleteArchive(ctx context.Context, orgId, id string) error
+}
+
+// WriteArchive passes a writer to write that stores everything written as chunks of the archive. The archive is
+// ready when write returns without error, and marked as failed otherwise
+func WriteArchive(ctx context.Context, store ArchiveStore, archive *Archive, write func(w io.Writer) error) error {
+	writer := ArchiveWriter{ctx: ctx, store: store, archive: archive}
+	err := errors.Join(write(&writer), writer.flush())
+	archive.Status = ArchiveReady
+	if err != nil {
+		archive.Status = ArchiveFailed
+	}
+	return errors.Join(err, store.SaveArchive(ctx, archive))
+}
+
+// ArchiveWriter buffers the written bytes and stores a chunk each time a full chunk is buffered
+type ArchiveWriter struct {
+	ctx     context.Context
+	store   ArchiveStore
+	archive *Archive
+	buffer  []byte
+}
+
+func (a *ArchiveWriter) Write(p []byte) (int, error) {
+	a.buffer = append(a.buffer, p...)
+	for int64(len(a.buffer)) >= a.archive.ChunkSize {
+		if err := a.saveChunk(a.buffer[:a.archive.ChunkSize]); err != nil {
+			return 0, err
+		}
+		a.buffer = a.buffer[a.archive.ChunkSize:]
+	}
+	return len(p), nil
+}
+
+func (a *ArchiveWriter) saveChunk(chunk []byte) error {
+	if err := a.store.SaveArchiveChunk(a.ctx, a.archive.OrgId, a.archive.Id, a.archive.NumChunks, chunk); err != nil {
+		return err
+	}
+	a.archive.NumChunks++
+	a.archive.Size += int64(len(chunk))
+	return nil
+}
+
+func (a *ArchiveWriter) flush() error {
+	if len(a.buffer) == 0 {
+		return nil
+	}
+	err := a.saveChunk(a.buffer)
+	a.buffer = nil
+	return err
+}
+
+// ArchiveReader reads a ready archive one chunk at a time. Seeking only fetches the chunk holding the new
+// offset, such that http.ServeContent can answer range requests
+type ArchiveReader struct {
+	ctx     context.Context
+	store   ArchiveChunkGetter
+	archive *Archive
+	offset  int64
+
+	chunkIndex int
+	chunk      []byte
+}
+
+func NewArchiveReader(ctx context.Context, store ArchiveChunkGetter, archive *Archive) *ArchiveReader {
+	return &ArchiveReader{ctx: ctx, store: store, archive: archive, chunkIndex: -1}
+}
+
+func (a *ArchiveReader) Read(p []byte) (int, error) {
+	if a.offset >= a.archive.Size {
+		return 0, io.EOF
+	}
+
+	index := int(a.offset / a.archive.ChunkSize)
+	if index != a.chunkIndex {
+		chunk, err := a.store.ArchiveChunk(a.ctx, a.archive.OrgId, a.archive.Id, index)
+		if err != nil {
+			return 0, err
+		}
+		a.chunk, a.chunkIndex = chunk, index
+	}
+
+	start := a.offset - int64(index)*a.archive.ChunkSize
+	if start >= int64(len(a.chunk)) {
+		return 0, fmt.Errorf("chunk %d of archive %s is shorter than expected: %w", index, a.archive.Id, io.ErrUnexpectedEOF)
+	}
+	n := copy(p, a.chunk[start:])
+	a.offset += int64(n)
+	return n, nil
+}
+
+func (a *ArchiveReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += a.offset
+	case io.SeekEnd:
+		offset += a.archive.Size
+	default:
+		return a.offset, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return a.offset, errors.New("negative offset")
+	}
+	a.offset = offset
+	return offset, nil
+}
+
+// Archives runs the jobs generating archives. Like log exports, the jobs run on the instance that received the
+// request, but the archives are kept in the store such that any instance can serve them
+type Archives struct {
+	Expiry time.Duration
+	jobs   sync.WaitGroup
+}
+
+func NewArchives(expiry time.Duration) *Archives {
+	return &Archives{Expiry: expiry}
+}
+
+// Go runs job in the background. Wait returns when all jobs have finished
+func (a *Archives) Go(job func()) {
+	a.jobs.Add(1)
+	go func() {
+		defer a.jobs.Done()
+		job()
+	}()
+}
+
+func (a *Archives) Wait() {
+	a.jobs.Wait()
+}
+
+func archivePrefix(orgId, id string) string {
+	return path.Join(archiveDir, orgId, id) + "/"
+}
+
+func archiveChunkName(orgId, id string, index int) string {
+	return archivePrefix(orgId, id) + fmt.Sprintf("%06d", index)
+}
+
+func (g *GoogleStore) SaveArchive(ctx context.Context, archive *Archive) error {
+	data, err := json.Marshal(archive)
+	if err != nil {
+		return err
+	}
+	return g.BucketClient.Upload(ctx, g.Config.Bucket, archivePrefix(archive.OrgId, archive.Id)+archiveMetaName, data)
+}
+
+func (g *GoogleStore) Archive(ctx context.Context, orgId, id string) (*Archive, error) {
+	data, err := g.readArchiveObject(ctx, archivePrefix(orgId, id)+archiveMetaName)
+	if err != nil {
+		return nil, err
+	}
+	var archive Archive
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return nil, err
+	}
+	return &archive, nil
+}
+
+func (g *GoogleStore) SaveArchiveChunk(ctx context.Context, orgId, id string, index int, data []byte) error {
+	return g.BucketClient.Upload(ctx, g.Config.Bucket, archiveChunkName(orgId, id, index), data)
+}
+
+func (g *GoogleStore) ArchiveChunk(ctx context.Context, orgId, id string, index int) ([]byte, error) {
+	return g.readArchiveObject(ctx, archiveChunkName(orgId, id, index))
+}
+
+func (g *GoogleStore) readArchiveObject(ctx context.Context, name string) ([]byte, error) {
+	reader, err := g.BucketClient.GetObject(ctx, g.Config.Bucket, name)
+	if err != nil {
+		return nil, classifyStoreErr(err, ErrArchiveNotFound)
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func (g *GoogleStore) DeleteArchive(ctx context.Context, orgId, id string) error {
+	listing := g.BucketClient.GetObjects(ctx, g.Config.Bucket, &storage.Query{Prefix: archivePrefix(orgId, id)})
+	var names []string
+	for {
+		attrs, err := listing.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return classifyStoreErr(err, ErrArchiveNotFound)
+		}
+		names = append(names, attrs.Name)
+	}
+
+	// The metadata is listed last and removed last, such that an archive is never served without all its chunks
+	var err error
+	for _, name := range names {
+		err = errors.Join(err, g.BucketClient.Delete(ctx, g.Config.Bucket, name))
+	}
+	return err
+}
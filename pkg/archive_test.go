@@ -0,0 +1,109 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func assertArchiveStore(t *testing.T, store ArchiveStore) {
+	ctx := context.Background()
+	_, err := store.Archive(ctx, "org1", "missing")
+	testutils.AssertEqual(t, errors.Is(err, ErrArchiveNotFound), true)
+
+	archive := NewArchive("org1", "parts.zip", time.Hour)
+	archive.ChunkSize = 4
+	content := []byte("the parts of a large project")
+	err = WriteArchive(ctx, store, archive, func(w io.Writer) error {
+		_, err := w.Write(content)
+		return err
+	})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, archive.NumChunks, 7)
+
+	stored, err := store.Archive(ctx, "org1", archive.Id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Status, ArchiveReady)
+	testutils.AssertEqual(t, stored.Size, int64(len(content)))
+
+	_, err = store.Archive(ctx, "org2", archive.Id)
+	testutils.AssertEqual(t, errors.Is(err, ErrArchiveNotFound), true)
+
+	data, err := io.ReadAll(NewArchiveReader(ctx, store, stored))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, string(data), string(content))
+
+	testutils.AssertNil(t, store.DeleteArchive(ctx, "org1", archive.Id))
+	_, err = store.Archive(ctx, "org1", archive.Id)
+	testutils.AssertEqual(t, errors.Is(err, ErrArchiveNotFound), true)
+	_, err = store.ArchiveChunk(ctx, "org1", archive.Id, 0)
+	testutils.AssertEqual(t, errors.Is(err, ErrArchiveNotFound), true)
+
+	// Deleting twice is not an error, since the cleanup retries removals that failed half way
+	testutils.AssertNil(t, store.DeleteArchive(ctx, "org1", archive.Id))
+}
+
+func TestInMemoryArchiveStore(t *testing.T) {
+	assertArchiveStore(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGoogleArchiveStore(t *testing.T) {
+	assertArchiveStore(t, &GoogleStore{BucketClient: &FileBucketClient{Directory: t.TempDir()}, Config: &GoogleConfig{Bucket: "bucket"}})
+}
+
+func TestArchiveReaderSeek(t *testing.T) {
+	ctx := context.Background()
+	store := NewMultiOrgInMemoryStore()
+	archive := NewArchive("org", "parts.zip", time.Hour)
+	archive.ChunkSize = 3
+	err := WriteArchive(ctx, store, archive, func(w io.Writer) error {
+		_, err := io.Copy(w, bytes.NewBufferString("0123456789"))
+		return err
+	})
+	testutils.AssertNil(t, err)
+
+	reader := NewArchiveReader(ctx, store, archive)
+	for _, test := range []struct {
+		offset int64
+		whence int
+		want   string
+	}{
+		{offset: 4, whence: io.SeekStart, want: "456789"},
+		{offset: -2, whence: io.SeekEnd, want: "89"},
+		{offset: -7, whence: io.SeekCurrent, want: "3456789"},
+	} {
+		_, err := reader.Seek(test.offset, test.whence)
+		testutils.AssertNil(t, err)
+		data, err := io.ReadAll(reader)
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, string(data), test.want)
+	}
+
+	_, err = reader.Seek(-1, io.SeekStart)
+	testutils.AssertEqual(t, err != nil, true)
+}
+
+func TestWriteArchiveMarksFailedArchives(t *testing.T) {
+	ctx := context.Background()
+	store := NewMultiOrgInMemoryStore()
+	archive := NewArchive("org", "parts.zip", time.Hour)
+	err := WriteArchive(ctx, store, archive, func(w io.Writer) error {
+		return errors.New("resource not available")
+	})
+	testutils.AssertEqual(t, err != nil, true)
+
+	stored, err := store.Archive(ctx, "org", archive.Id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Status, ArchiveFailed)
+}
+
+func TestArchiveReaderMissingChunk(t *testing.T) {
+	archive := Archive{Id: "id", OrgId: "org", Size: 10, ChunkSize: 5, NumChunks: 2}
+	_, err := io.ReadAll(NewArchiveReader(context.Background(), NewMultiOrgInMemoryStore(), &archive))
+	testutils.AssertEqual(t, errors.Is(err, ErrArchiveNotFound), true)
+}
@@ -0,0 +1,97 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const auditLogCollection = "audit_log"
+
+type AuditAction string
+
+const (
+	AuditLogin               AuditAction = "login"
+	AuditRoleChanged         AuditAction = "role_changed"
+	AuditMemberRemoved       AuditAction = "member_removed"
+	AuditResourceDeleted     AuditAction = "resource_deleted"
+	AuditOrganizationDeleted AuditAction = "organization_deleted"
+	AuditInviteCreated       AuditAction = "invite_created"
+	AuditInviteRevoked       AuditAction = "invite_revoked"
+	AuditSubscriptionChanged AuditAction = "subscription_changed"
+	AuditImpersonationStart  AuditAction = "impersonation_started"
+	AuditImpersonationEnd    AuditAction = "impersonation_ended"
+	AuditPartRenamed         AuditAction = "part_renamed"
+	AuditGuestGranted        AuditAction = "guest_granted"
+	AuditGuestRevoked        AuditAction = "guest_revoked"
+	AuditWebhookUpdated      AuditAction = "webhook_updated"
+	AuditWebhookRemoved      AuditAction = "webhook_removed"
+
+	// Guests are not members, so the actor of what they view and download is the id of their grant
+	AuditGuestViewed     AuditAction = "guest_viewed"
+	AuditGuestDownloaded AuditAction = "guest_downloaded"
+)
+
+// AuditEntry is a security relevant action in an organization. Entries are never changed or removed once they
+// are written, except that erasing a user anonymizes the entries referring to the user
+type AuditEntry struct {
+	Id       string      `json:"id" firestore:"id"`
+	Action   AuditAction `json:"action" firestore:"action"`
+	ActorId  string      `json:"actorId" firestore:"actorId"`
+	TargetId string      `json:"targetId" firestore:"targetId"`
+
+	// Detail is extra information about the action, e.g. the new role of a member
+	Detail string    `json:"detail" firestore:"detail"`
+	IP     string    `json:"ip" firestore:"ip"`
+	Time   time.Time `json:"time" firestore:"time"`
+}
+
+func NewAuditEntry(action AuditAction, actorId, targetId, detail, ip string) *AuditEntry {
+	now := time.Now()
+	return &AuditEntry{
+		Id:       fmt.Sprintf("%d-%s", now.UnixNano(), RandomInsecureID()),
+		Action:   action,
+		ActorId:  actorId,
+		TargetId: targetId,
+		Detail:   detail,
+		IP:       ip,
+		Time:     now,
+	}
+}
+
+// AuditLogger appends entries to the audit log of an organization. There is no way to change or remove entries
+type AuditLogger interface {
+	Audit(ctx context.Context, orgId string, entry *AuditEntry) error
+}
+
+type AuditLogReader interface {
+	// AuditLog returns the entries of the organization from (inclusive) to (exclusive) with the most recent first
+	AuditLog(ctx context.Context, orgId string, from, to time.Time) ([]AuditEntry, error)
+}
+
+type AuditLogStore interface {
+	AuditLogger
+	AuditLogReader
+}
+
+// SortAuditLog orders the entries with the most recent first
+func SortAuditLog(entries []AuditEntry) {
+	slices.SortStableFunc(entries, func(a, b AuditEntry) int {
+		return b.Time.Compare(a.Time)
+	})
+}
+
+func (g *GoogleStore) Audit(ctx context.Context, orgId string, entry *AuditEntry) error {
+	return g.FsClient.StoreDocument(ctx, auditLogCollection, orgId, entry.Id, entry)
+}
+
+func (g *GoogleStore) AuditLog(ctx context.Context, orgId string, from, to time.Time) ([]AuditEntry, error) {
+	collector := NewValidCollector[AuditEntry]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, auditLogCollection, orgId, "id", "") {
+		collector.Push(doc)
+	}
+	result := slices.DeleteFunc(collector.Items, func(e AuditEntry) bool { return !InTimeRange(e.Time, from, to) })
+	SortAuditLog(result)
+	return result, collector.Err
+}
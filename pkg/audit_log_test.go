@@ -0,0 +1,47 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+// assertAuditLogStore runs the same checks against all implementations of the audit log
+func assertAuditLogStore(t *testing.T, store AuditLogStore) {
+	ctx := context.Background()
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	login := NewAuditEntry(AuditLogin, "user", "user", "", "127.0.0.1")
+	login.Time = start
+	role := NewAuditEntry(AuditRoleChanged, "admin", "user", "role=2", "127.0.0.2")
+	role.Time = start.Add(time.Hour)
+	old := NewAuditEntry(AuditResourceDeleted, "admin", "resource", "", "127.0.0.2")
+	old.Time = start.Add(-48 * time.Hour)
+
+	for _, entry := range []*AuditEntry{login, role, old} {
+		testutils.AssertNil(t, store.Audit(ctx, "org", entry))
+	}
+	testutils.AssertNil(t, store.Audit(ctx, "other-org", NewAuditEntry(AuditLogin, "user", "user", "", "")))
+
+	entries, err := store.AuditLog(ctx, "org", start.Add(-time.Hour), start.Add(2*time.Hour))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(entries), 2)
+	testutils.AssertEqual(t, entries[0].Action, AuditRoleChanged)
+	testutils.AssertEqual(t, entries[0].Detail, "role=2")
+	testutils.AssertEqual(t, entries[0].IP, "127.0.0.2")
+	testutils.AssertEqual(t, entries[1].Id, login.Id)
+	testutils.AssertEqual(t, entries[1].Time.Equal(start), true)
+
+	entries, err = store.AuditLog(ctx, "org", start.Add(time.Hour), start.Add(2*time.Hour))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(entries), 1)
+}
+
+func TestInMemoryAuditLog(t *testing.T) {
+	assertAuditLogStore(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGoogleAuditLog(t *testing.T) {
+	assertAuditLogStore(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
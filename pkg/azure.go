@@ -0,0 +1,182 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"cloud.google.com/go/storage"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	Azure                = "azure"
+	defaultColdAzureTier = blob.AccessTierCool
+)
+
+type AzureConfig struct {
+	// URL of the storage account, e.g. https://<account>.blob.core.windows.net/
+	AccountURL string `yaml:"account_url" env:"CAESURA_AZURE_ACCOUNT_URL"`
+	Container  string `yaml:"container" env:"CAESURA_AZURE_CONTAINER"`
+
+	// When set the connection string is used instead of the default Azure credential chain
+	ConnectionString string `yaml:"connection_string" env:"CAESURA_AZURE_CONNECTION_STRING" secret:"true"`
+	ColdAccessTier   string `yaml:"cold_access_tier" env:"CAESURA_AZURE_COLD_ACCESS_TIER"`
+}
+
+func (c *AzureConfig) Validate() error {
+	if c.Container == "" || (c.AccountURL == "" && c.ConnectionString == "") {
+		return fmt.Errorf("azure.container and azure.account_url or azure.connection_string must be specified for azure blob storage")
+	}
+	return nil
+}
+
+// AzureBlobAPI is the subset of the Azure Blob client used by the store
+type AzureBlobAPI interface {
+	UploadBuffer(ctx context.Context, containerName, blobName string, buffer []byte, o *azblob.UploadBufferOptions) (azblob.UploadBufferResponse, error)
+	DownloadStream(ctx context.Context, containerName, blobName string, o *azblob.DownloadStreamOptions) (azblob.DownloadStreamResponse, error)
+	NewListBlobsFlatPager(containerName string, o *azblob.ListBlobsFlatOptions) *runtime.Pager[azblob.ListBlobsFlatResponse]
+	SetTier(ctx context.Context, containerName, blobName string, tier blob.AccessTier) error
+	DeleteBlob(ctx context.Context, containerName, blobName string, o *azblob.DeleteBlobOptions) (azblob.DeleteBlobResponse, error)
+}
+
+// azureSDKClient adds SetTier to the client of the SDK, which is only available on the blob clients
+type azureSDKClient struct {
+	*azblob.Client
+}
+
+func (a *azureSDKClient) SetTier(ctx context.Context, containerName, blobName string, tier blob.AccessTier) error {
+	_, err := a.ServiceClient().NewContainerClient(containerName).NewBlobClient(blobName).SetTier(ctx, tier, nil)
+	return err
+}
+
+func NewAzureClient(config *AzureConfig) (AzureBlobAPI, error) {
+	if config.ConnectionString != "" {
+		client, err := azblob.NewClientFromConnectionString(config.ConnectionString, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &azureSDKClient{Client: client}, nil
+	}
+
+	credential, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create azure credential: %w", err)
+	}
+	client, err := azblob.NewClient(config.AccountURL, credential, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &azureSDKClient{Client: client}, nil
+}
+
+// AzureBlobClient stores objects in Azure Blob Storage. The bucket is the name of the container
+type AzureBlobClient struct {
+	Client         AzureBlobAPI
+	ColdAccessTier blob.AccessTier
+}
+
+func NewAzureBlobClient(client AzureBlobAPI, config *AzureConfig) *AzureBlobClient {
+	coldTier := defaultColdAzureTier
+	if config.ColdAccessTier != "" {
+		coldTier = blob.AccessTier(config.ColdAccessTier)
+	}
+	return &AzureBlobClient{Client: client, ColdAccessTier: coldTier}
+}
+
+func (a *AzureBlobClient) Upload(ctx context.Context, bucket, object string, data []byte) error {
+	_, err := a.Client.UploadBuffer(ctx, bucket, object, data, nil)
+	return err
+}
+
+func (a *AzureBlobClient) GetObject(ctx context.Context, bucket, objName string) (io.ReadCloser, error) {
+	resp, err := a.Client.DownloadStream(ctx, bucket, objName, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (a *AzureBlobClient) GetObjects(ctx context.Context, bucket string, query *storage.Query) ObjectLister {
+	return &azureObjectLister{
+		ctx:    ctx,
+		bucket: bucket,
+		pager:  a.Client.NewListBlobsFlatPager(bucket, &azblob.ListBlobsFlatOptions{Prefix: &query.Prefix}),
+	}
+}
+
+// SetStorageClass maps the cold storage class of GCS to the configured cold access tier. All other classes
+// are mapped to the hot tier
+func (a *AzureBlobClient) SetStorageClass(ctx context.Context, bucket, object, class string) error {
+	tier := blob.AccessTierHot
+	if class == StorageClassCold.gcsStorageClass() {
+		tier = a.ColdAccessTier
+	}
+	return a.Client.SetTier(ctx, bucket, object, tier)
+}
+
+func (a *AzureBlobClient) Delete(ctx context.Context, bucket, object string) error {
+	_, err := a.Client.DeleteBlob(ctx, bucket, object, nil)
+	return err
+}
+
+type azureObjectLister struct {
+	ctx    context.Context
+	bucket string
+	pager  *runtime.Pager[azblob.ListBlobsFlatResponse]
+	page   []*container.BlobItem
+}
+
+func (a *azureObjectLister) Next() (*storage.ObjectAttrs, error) {
+	for len(a.page) == 0 {
+		if !a.pager.More() {
+			return nil, iterator.Done
+		}
+		resp, err := a.pager.NextPage(a.ctx)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Segment != nil {
+			a.page = resp.Segment.BlobItems
+		}
+	}
+	item := a.page[0]
+	a.page = a.page[1:]
+
+	attrs := storage.ObjectAttrs{Bucket: a.bucket}
+	if item.Name != nil {
+		attrs.Name = *item.Name
+	}
+	if properties := item.Properties; properties != nil {
+		if properties.ContentLength != nil {
+			attrs.Size = *properties.ContentLength
+		}
+		if properties.LastModified != nil {
+			attrs.Updated = *properties.LastModified
+		}
+		attrs.MD5 = properties.ContentMD5
+	}
+	return &attrs, nil
+}
+
+func isAzureNotFound(err error) bool {
+	return bloberror.HasCode(err, bloberror.BlobNotFound, bloberror.ContainerNotFound)
+}
+
+// isAzureUnavailable reports whether the Azure SDK gave up on a request that may succeed later
+func isAzureUnavailable(err error) bool {
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) {
+		return false
+	}
+	return slices.Contains(transientHTTPCodes, respErr.StatusCode)
+}
@@ -0,0 +1,189 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+type fakeAzure struct {
+	mu       sync.Mutex
+	blobs    map[string][]byte
+	tiers    map[string]blob.AccessTier
+	pageSize int
+}
+
+func newFakeAzure() *fakeAzure {
+	return &fakeAzure{blobs: make(map[string][]byte), tiers: make(map[string]blob.AccessTier), pageSize: 2}
+}
+
+func azureBlobNotFound() error {
+	return &azcore.ResponseError{ErrorCode: string(bloberror.BlobNotFound), StatusCode: 404}
+}
+
+func (f *fakeAzure) UploadBuffer(ctx context.Context, containerName, blobName string, buffer []byte, o *azblob.UploadBufferOptions) (azblob.UploadBufferResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.blobs[containerName+"/"+blobName] = bytes.Clone(buffer)
+	return azblob.UploadBufferResponse{}, nil
+}
+
+func (f *fakeAzure) DownloadStream(ctx context.Context, containerName, blobName string, o *azblob.DownloadStreamOptions) (azblob.DownloadStreamResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.blobs[containerName+"/"+blobName]
+	if !ok {
+		return azblob.DownloadStreamResponse{}, azureBlobNotFound()
+	}
+	var resp azblob.DownloadStreamResponse
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
+
+// NewListBlobsFlatPager returns pageSize blobs per page to exercise pagination
+func (f *fakeAzure) NewListBlobsFlatPager(containerName string, o *azblob.ListBlobsFlatOptions) *runtime.Pager[azblob.ListBlobsFlatResponse] {
+	f.mu.Lock()
+	containerPrefix := containerName + "/"
+	var names []string
+	for location := range f.blobs {
+		name := strings.TrimPrefix(location, containerPrefix)
+		if strings.HasPrefix(location, containerPrefix) && strings.HasPrefix(name, *o.Prefix) {
+			names = append(names, name)
+		}
+	}
+	sizes := make(map[string]int64)
+	for _, name := range names {
+		sizes[name] = int64(len(f.blobs[containerPrefix+name]))
+	}
+	f.mu.Unlock()
+	slices.Sort(names)
+
+	return runtime.NewPager(runtime.PagingHandler[azblob.ListBlobsFlatResponse]{
+		More: func(page azblob.ListBlobsFlatResponse) bool {
+			return page.NextMarker != nil && *page.NextMarker != ""
+		},
+		Fetcher: func(ctx context.Context, page *azblob.ListBlobsFlatResponse) (azblob.ListBlobsFlatResponse, error) {
+			start := 0
+			if page != nil {
+				start = slices.Index(names, *page.NextMarker)
+			}
+			end := min(start+f.pageSize, len(names))
+
+			var resp azblob.ListBlobsFlatResponse
+			resp.Segment = &container.BlobFlatListSegment{}
+			for _, name := range names[start:end] {
+				size := sizes[name]
+				resp.Segment.BlobItems = append(resp.Segment.BlobItems, &container.BlobItem{
+					Name:       &name,
+					Properties: &container.BlobProperties{ContentLength: &size},
+				})
+			}
+			if end < len(names) {
+				resp.NextMarker = &names[end]
+			}
+			return resp, nil
+		},
+	})
+}
+
+func (f *fakeAzure) SetTier(ctx context.Context, containerName, blobName string, tier blob.AccessTier) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	location := containerName + "/" + blobName
+	if _, ok := f.blobs[location]; !ok {
+		return azureBlobNotFound()
+	}
+	f.tiers[location] = tier
+	return nil
+}
+
+func (f *fakeAzure) DeleteBlob(ctx context.Context, containerName, blobName string, o *azblob.DeleteBlobOptions) (azblob.DeleteBlobResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	location := containerName + "/" + blobName
+	if _, ok := f.blobs[location]; !ok {
+		return azblob.DeleteBlobResponse{}, azureBlobNotFound()
+	}
+	delete(f.blobs, location)
+	return azblob.DeleteBlobResponse{}, nil
+}
+
+func TestAzureBlobClientListsAllPages(t *testing.T) {
+	blobClient := NewAzureBlobClient(newFakeAzure(), &AzureConfig{})
+	ctx := context.Background()
+	for _, name := range []string{"org/a/1.pdf", "org/a/2.pdf", "org/a/3.pdf", "other/b/1.pdf"} {
+		testutils.AssertNil(t, blobClient.Upload(ctx, "scores", name, []byte(name)))
+	}
+
+	objects := blobClient.GetObjects(ctx, "scores", &storage.Query{Prefix: "org/"})
+	var names []string
+	for {
+		attrs, err := objects.Next()
+		if err != nil {
+			break
+		}
+		testutils.AssertEqual(t, attrs.Bucket, "scores")
+		testutils.AssertEqual(t, attrs.Size, int64(len(attrs.Name)))
+		names = append(names, attrs.Name)
+	}
+	testutils.AssertEqual(t, strings.Join(names, ","), "org/a/1.pdf,org/a/2.pdf,org/a/3.pdf")
+
+	_, err := blobClient.GetObject(ctx, "scores", "missing")
+	testutils.AssertEqual(t, isAzureNotFound(err), true)
+}
+
+func TestAzureBlobClientStorageClass(t *testing.T) {
+	client := newFakeAzure()
+	blobClient := NewAzureBlobClient(client, &AzureConfig{ColdAccessTier: string(blob.AccessTierCold)})
+	ctx := context.Background()
+	testutils.AssertNil(t, blobClient.Upload(ctx, "scores", "org/a/1.pdf", []byte("content")))
+
+	testutils.AssertNil(t, blobClient.SetStorageClass(ctx, "scores", "org/a/1.pdf", StorageClassCold.gcsStorageClass()))
+	testutils.AssertEqual(t, client.tiers["scores/org/a/1.pdf"], blob.AccessTierCold)
+	testutils.AssertNil(t, blobClient.SetStorageClass(ctx, "scores", "org/a/1.pdf", StorageClassStandard.gcsStorageClass()))
+	testutils.AssertEqual(t, client.tiers["scores/org/a/1.pdf"], blob.AccessTierHot)
+
+	testutils.AssertEqual(t, NewAzureBlobClient(client, &AzureConfig{}).ColdAccessTier, blob.AccessTierCool)
+
+	testutils.AssertNil(t, blobClient.Delete(ctx, "scores", "org/a/1.pdf"))
+	testutils.AssertEqual(t, isAzureNotFound(blobClient.Delete(ctx, "scores", "org/a/1.pdf")), true)
+}
+
+func TestGoogleStoreWithAzureBlobs(t *testing.T) {
+	store := GoogleStore{
+		FsClient:     NewLocalFirestoreClient(),
+		BucketClient: NewAzureBlobClient(newFakeAzure(), &AzureConfig{}),
+		Config:       &GoogleConfig{Bucket: "scores"},
+	}
+	ctx := context.Background()
+	meta := MetaData{Title: "Title", Composer: "Composer"}
+	parts := func(yield func(string, []byte) bool) {
+		for _, name := range []string{"Part1.pdf", "Part2.pdf", "Part3.pdf"} {
+			if !yield(name, []byte(name)) {
+				return
+			}
+		}
+	}
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, parts))
+
+	content := maps.Collect(store.Resource(ctx, "org", meta.ResourceId()))
+	testutils.AssertEqual(t, len(content), 3)
+	testutils.AssertEqual(t, string(content["Part2.pdf"]), "Part2.pdf")
+
+	_, err := store.Item(ctx, "org/missing/Part1.pdf")
+	testutils.AssertEqual(t, IsNotFound(err), true)
+}
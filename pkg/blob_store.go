@@ -1,8 +1,15 @@
 package pkg
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"iter"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -18,10 +25,52 @@ type ProjectSubmitter interface {
 	SubmitProject(ctx context.Context, orgId string, project *Project) error
 }
 
+// ProjectDeleter removes a project. Removing a project that does not exist is not an error, such that it can be
+// used after writes in a transaction
+type ProjectDeleter interface {
+	DeleteProject(ctx context.Context, orgId, projectId string) error
+}
+
+// ProjectOrderSetter stores the pieces of a project in a new order. The pieces are written as given, so they must
+// be the pieces of the project
+type ProjectOrderSetter interface {
+	SetProjectOrder(ctx context.Context, orgId, projectId string, resourceIds []string) error
+}
+
 type ProjectResourceRemover interface {
 	RemoveResource(ctx context.Context, orgId string, projectId string, resourceId string) error
 }
 
+// ProjectNoteSetter sets the rehearsal note of a piece in a project. An empty note removes it
+type ProjectNoteSetter interface {
+	SetProjectNote(ctx context.Context, orgId string, projectId string, resourceId string, note string) error
+}
+
+type ResourceProtector interface {
+	SetProtected(ctx context.Context, orgId string, resourceId string, protected bool) error
+}
+
+type ResourceDeleter interface {
+	DeleteResource(ctx context.Context, orgId string, resourceId string) error
+}
+
+// ResourceRestorer moves a resource out of the trash
+type ResourceRestorer interface {
+	RestoreResource(ctx context.Context, orgId string, resourceId string) error
+}
+
+// ResourcePurger permanently removes a resource in the trash, including its files
+type ResourcePurger interface {
+	PurgeResource(ctx context.Context, orgId string, resourceId string) error
+}
+
+// SubmitResolver cleans up after submits that were interrupted before they finished
+type SubmitResolver interface {
+	// ResolveSubmit removes a resource that failed, or that has been pending without any parts. A pending
+	// resource that has parts is marked as finished, such that the parts that made it are kept
+	ResolveSubmit(ctx context.Context, orgId string, resourceId string) error
+}
+
 type MetaByIdGetter interface {
 	MetaById(ctx context.Context, orgId string, id string) (*MetaData, error)
 }
@@ -29,6 +78,22 @@ type MetaByIdGetter interface {
 type ResourceGetter interface {
 	MetaByIdGetter
 	Resource(ctx context.Context, orgId string, path string) iter.Seq2[string, []byte]
+
+	// ResourceStream yields the parts one at a time without reading them into memory. A reader is only
+	// valid until the next part is requested
+	ResourceStream(ctx context.Context, orgId string, path string) iter.Seq2[string, io.Reader]
+}
+
+// PartURLSigner is implemented by stores that can hand out links for downloading a part directly from the
+// bucket. Stores that are not backed by a bucket return ErrSignedURLUnsupported
+type PartURLSigner interface {
+	SignedPartURL(ctx context.Context, orgId, resourceId, filename string, expires time.Time) (string, error)
+}
+
+// PartOpener is implemented by stores that can read a range of a part without fetching the whole part.
+// Stores whose bucket can not read ranges return ErrRangeReadUnsupported
+type PartOpener interface {
+	OpenPart(ctx context.Context, orgId, resourceId, filename string) (io.ReadSeekCloser, error)
 }
 
 type ItemGetter interface {
@@ -38,14 +103,36 @@ type ItemGetter interface {
 type BlobStore interface {
 	Submitter
 	MetaByPatternFetcher
+	MetaByPatternPager
 	ProjectByNameGetter
 	ProjectSubmitter
 	ProjectMetaByIdGetter
 	ProjectResourceRemover
+	ProjectNoteSetter
 	ResourceGetter
 	ItemGetter
 	SubscriptionStorer
 	SubscriptionGetter
+	AccessRecorder
+	StorageClassTransitioner
+	ResourceProtector
+	ResourceDeleter
+	ResourceRestorer
+	ResourcePurger
+	SubmitResolver
+	OrphanRemover
+	ResourceTextStore
+	ResourceVersioner
+	MetaDataUpdater
+	ResourceManifestGetter
+	PartRenamer
+	PartLister
+}
+
+type TieredResourceGetter interface {
+	ResourceGetter
+	AccessRecorder
+	StorageClassTransitioner
 }
 
 type SubscriptionValidator interface {
@@ -53,9 +140,25 @@ type SubscriptionValidator interface {
 	OrganizationGetter
 }
 
-type ProjectMetaByIdGetter interface {
+// MetaLookup is the result of fetching the metadata of one resource in a batch
+type MetaLookup struct {
+	Id   string
+	Meta *MetaData
+	Err  error
+}
+
+// MetaByIdsGetter fetches the metadata of several resources. The result has the same order as ids
+type MetaByIdsGetter interface {
+	MetaByIds(ctx context.Context, orgId string, ids []string) []MetaLookup
+}
+
+type ProjectByIdGetter interface {
 	ProjectById(ctx context.Context, orgId string, id string) (*Project, error)
-	MetaById(ctx context.Context, orgId string, id string) (*MetaData, error)
+}
+
+type ProjectMetaByIdGetter interface {
+	ProjectByIdGetter
+	MetaByIdsGetter
 }
 
 type Project struct {
@@ -63,13 +166,89 @@ type Project struct {
 	ResourceIds []string  `json:"resource_ids" firestore:"resource_ids"`
 	CreatedAt   time.Time `json:"created_at" firestore:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" firestore:"updated_at"`
+
+	// Rehearsal notes from the conductor keyed by resource id
+	Notes map[string]string `json:"notes,omitempty" firestore:"notes,omitempty"`
+
+	// Defaults copied from the ProjectTemplate the project was created from
+	TemplateId   string   `json:"template_id,omitempty" firestore:"template_id,omitempty"`
+	Categories   []string `json:"categories,omitempty" firestore:"categories,omitempty"`
+	Announcement string   `json:"announcement,omitempty" firestore:"announcement,omitempty"`
+	Groups       []string `json:"groups,omitempty" firestore:"groups,omitempty"`
 }
 
 func (p *Project) Merge(other *Project) {
 	p.ResourceIds = RemoveDuplicates(append(p.ResourceIds, other.ResourceIds...))
+	if len(other.Notes) > 0 && p.Notes == nil {
+		p.Notes = make(map[string]string, len(other.Notes))
+	}
+	maps.Copy(p.Notes, other.Notes)
 	p.UpdatedAt = time.Now()
 }
 
+// RehearsalNotes formats the notes of the pieces in metaData as plain text. Pieces without notes are skipped
+func (p *Project) RehearsalNotes(metaData []MetaData) string {
+	var builder strings.Builder
+	for _, meta := range metaData {
+		if note, ok := p.Notes[meta.ResourceId()]; ok {
+			fmt.Fprintf(&builder, "%s\n%s\n\n", meta.Title, note)
+		}
+	}
+	return builder.String()
+}
+
+// Reorder puts the pieces of the project in the given order. Pieces missing from order were likely added while the
+// order was edited, so they are kept after the ordered pieces. Unknown and repeated pieces are rejected
+func (p *Project) Reorder(order []string) error {
+	ordered := make(map[string]bool, len(order))
+	for _, resourceId := range order {
+		if !slices.Contains(p.ResourceIds, resourceId) {
+			return errors.Join(ErrInvalidProjectOrder, fmt.Errorf("%s is not in the project", resourceId))
+		}
+		if ordered[resourceId] {
+			return errors.Join(ErrInvalidProjectOrder, fmt.Errorf("%s is listed more than once", resourceId))
+		}
+		ordered[resourceId] = true
+	}
+	rest := slices.DeleteFunc(slices.Clone(p.ResourceIds), func(resourceId string) bool { return ordered[resourceId] })
+	p.ResourceIds = append(slices.Clone(order), rest...)
+	return nil
+}
+
+// SortByOrder sorts ids by their position in the project, such that pieces downloaded from a project come in the
+// order of the setlist. Ids that are not in the project are put last
+func (p *Project) SortByOrder(ids []string) {
+	position := make(map[string]int, len(p.ResourceIds))
+	for i, resourceId := range p.ResourceIds {
+		position[resourceId] = i
+	}
+	last := len(p.ResourceIds)
+	slices.SortStableFunc(ids, func(a, b string) int {
+		posA, okA := position[a]
+		posB, okB := position[b]
+		if !okA {
+			posA = last
+		}
+		if !okB {
+			posB = last
+		}
+		return cmp.Compare(posA, posB)
+	})
+}
+
+// Setlist lists the pieces in metaData as numbered plain text lines, in the order given
+func Setlist(metaData []MetaData) string {
+	var builder strings.Builder
+	for i, meta := range metaData {
+		fmt.Fprintf(&builder, "%d. %s", i+1, meta.Title)
+		if meta.Composer != "" {
+			fmt.Fprintf(&builder, " (%s)", meta.Composer)
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
 func (p *Project) Id() string {
 	return SanitizeString(p.Name)
 }
@@ -1,6 +1,12 @@
 package pkg
 
-import "testing"
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
 
 func TestProjectId(t *testing.T) {
 	project := &Project{
@@ -30,3 +36,44 @@ func TestMergeProjects(t *testing.T) {
 		t.Fatalf("Expected %d resource IDs, got %d", len(expectedResourceIds), len(project1.ResourceIds))
 	}
 }
+
+func TestMergeProjectNotes(t *testing.T) {
+	project1 := &Project{Name: "Project A", Notes: map[string]string{"res1": "Start at letter C"}}
+	project2 := &Project{Name: "Project A", Notes: map[string]string{"res2": "Repeat the coda"}}
+
+	project1.Merge(project2)
+	testutils.AssertEqual(t, len(project1.Notes), 2)
+	testutils.AssertEqual(t, project1.Notes["res2"], "Repeat the coda")
+
+	empty := &Project{Name: "Project A"}
+	empty.Merge(project1)
+	testutils.AssertEqual(t, empty.Notes["res1"], "Start at letter C")
+}
+
+func TestRehearsalNotes(t *testing.T) {
+	metaData := []MetaData{{Title: "Symphony", Composer: "Beethoven"}, {Title: "Finale"}}
+	project := &Project{Notes: map[string]string{metaData[0].ResourceId(): "Start at letter C"}}
+
+	notes := project.RehearsalNotes(metaData)
+	testutils.AssertEqual(t, notes, "Symphony\nStart at letter C\n\n")
+	testutils.AssertEqual(t, (&Project{}).RehearsalNotes(metaData), "")
+}
+
+func TestReorderProject(t *testing.T) {
+	project := &Project{ResourceIds: []string{"polka", "march", "waltz", "finale"}}
+	testutils.AssertNil(t, project.Reorder([]string{"waltz", "polka"}))
+	testutils.AssertEqual(t, strings.Join(project.ResourceIds, ","), "waltz,polka,march,finale")
+
+	for _, order := range [][]string{{"waltz", "unknown"}, {"waltz", "waltz"}} {
+		err := project.Reorder(order)
+		testutils.AssertEqual(t, errors.Is(err, ErrInvalidProjectOrder), true)
+	}
+	testutils.AssertEqual(t, strings.Join(project.ResourceIds, ","), "waltz,polka,march,finale")
+}
+
+func TestSortByProjectOrder(t *testing.T) {
+	project := &Project{ResourceIds: []string{"polka", "march", "waltz"}}
+	ids := []string{"other", "waltz", "polka"}
+	project.SortByOrder(ids)
+	testutils.AssertEqual(t, strings.Join(ids, ","), "polka,waltz,other")
+}
@@ -0,0 +1,72 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+const DefaultPrimaryColor = "#6366f1"
+
+var hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Branding is the visual identity of an organization applied to the web views of its members
+// and to outgoing emails. Empty fields means that the default Caesura look is used
+type Branding struct {
+	PrimaryColor string `json:"primaryColor" firestore:"primaryColor"`
+	LogoURL      string `json:"logoUrl" firestore:"logoUrl"`
+}
+
+func (b *Branding) Validate() error {
+	if b.PrimaryColor != "" && !hexColor.MatchString(b.PrimaryColor) {
+		return errors.Join(ErrInvalidBranding, fmt.Errorf("primary color must be on the form #rrggbb got %s", b.PrimaryColor))
+	}
+
+	if b.LogoURL != "" {
+		logo, err := url.Parse(b.LogoURL)
+		if err != nil || logo.Scheme != "https" || logo.Host == "" {
+			return errors.Join(ErrInvalidBranding, fmt.Errorf("logo must be an https URL got %s", b.LogoURL))
+		}
+	}
+	return nil
+}
+
+// Color returns the primary color, falling back to the default color
+func (b *Branding) Color() string {
+	if b.PrimaryColor == "" {
+		return DefaultPrimaryColor
+	}
+	return b.PrimaryColor
+}
+
+type BrandingUpdater interface {
+	UpdateBranding(ctx context.Context, orgId string, branding Branding) error
+}
+
+type UserBrandingGetter interface {
+	UserByEmailGetter
+	OrganizationGetter
+}
+
+type ResetEmailStore interface {
+	FeatureCounter
+	UserBrandingGetter
+}
+
+// BrandingForUser returns the branding of the organization the user is a member of. Unknown users and users
+// that are members of several organizations get the default branding
+func BrandingForUser(ctx context.Context, store UserBrandingGetter, email string) Branding {
+	user, err := store.UserByEmail(ctx, email)
+	if err != nil || len(user.Roles) != 1 {
+		return Branding{}
+	}
+
+	for orgId := range user.Roles {
+		if org, err := store.GetOrganization(ctx, orgId); err == nil {
+			return org.Branding
+		}
+	}
+	return Branding{}
+}
@@ -0,0 +1,70 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestBrandingValidate(t *testing.T) {
+	for _, test := range []struct {
+		desc     string
+		branding Branding
+		wantErr  bool
+	}{
+		{"empty", Branding{}, false},
+		{"valid", Branding{PrimaryColor: "#A1b2C3", LogoURL: "https://example.com/logo.png"}, false},
+		{"named color", Branding{PrimaryColor: "red"}, true},
+		{"short hex", Branding{PrimaryColor: "#fff"}, true},
+		{"http logo", Branding{LogoURL: "http://example.com/logo.png"}, true},
+		{"relative logo", Branding{LogoURL: "/logo.png"}, true},
+		{"javascript logo", Branding{LogoURL: "javascript:alert(1)"}, true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			err := test.branding.Validate()
+			testutils.AssertEqual(t, err != nil, test.wantErr)
+			if test.wantErr {
+				testutils.AssertEqual(t, errors.Is(err, ErrInvalidBranding), true)
+			}
+		})
+	}
+}
+
+func TestBrandingColor(t *testing.T) {
+	testutils.AssertEqual(t, (&Branding{}).Color(), DefaultPrimaryColor)
+	testutils.AssertEqual(t, (&Branding{PrimaryColor: "#112233"}).Color(), "#112233")
+}
+
+func TestBrandingForUser(t *testing.T) {
+	ctx := context.Background()
+	store := NewMultiOrgInMemoryStore()
+	branding := Branding{PrimaryColor: "#112233"}
+	store.Organizations = []Organization{{Id: "org1", Branding: branding}, {Id: "org2"}}
+	store.Users = []UserInfo{
+		{Id: "single", Email: "single@example.com", Password: "hash", Roles: map[string]RoleKind{"org1": RoleViewer}},
+		{Id: "multi", Email: "multi@example.com", Password: "hash", Roles: map[string]RoleKind{"org1": RoleViewer, "org2": RoleAdmin}},
+	}
+
+	testutils.AssertEqual(t, BrandingForUser(ctx, store, "single@example.com"), branding)
+	testutils.AssertEqual(t, BrandingForUser(ctx, store, "multi@example.com"), Branding{})
+	testutils.AssertEqual(t, BrandingForUser(ctx, store, "unknown@example.com"), Branding{})
+}
+
+func TestInMemoryUpdateBranding(t *testing.T) {
+	ctx := context.Background()
+	store := NewMultiOrgInMemoryStore()
+	store.Organizations = []Organization{{Id: "org1"}, {Id: "deleted", Deleted: true}}
+	branding := Branding{PrimaryColor: "#112233"}
+
+	testutils.AssertNil(t, store.UpdateBranding(ctx, "org1", branding))
+	org, err := store.GetOrganization(ctx, "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, org.Branding, branding)
+
+	for _, orgId := range []string{"deleted", "unknown"} {
+		err := store.UpdateBranding(ctx, orgId, branding)
+		testutils.AssertEqual(t, errors.Is(err, ErrOrganizationNotFound), true)
+	}
+}
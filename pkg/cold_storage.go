@@ -0,0 +1,98 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type StorageClass string
+
+const (
+	StorageClassStandard StorageClass = ""
+	StorageClassCold     StorageClass = "cold"
+)
+
+func (s StorageClass) gcsStorageClass() string {
+	if s == StorageClassCold {
+		return "COLDLINE"
+	}
+	return "STANDARD"
+}
+
+type AccessRecorder interface {
+	RecordAccess(ctx context.Context, orgId, resourceId string, at time.Time) error
+}
+
+type StorageClassTransitioner interface {
+	TransitionStorageClass(ctx context.Context, orgId, resourceId string, class StorageClass) error
+}
+
+type ColdStorageStore interface {
+	OrganizationLister
+	MetaByPatternFetcher
+	AccessRecorder
+	StorageClassTransitioner
+}
+
+// ColdStorageTiering moves resources that has not been downloaded within Threshold
+// to a cheaper storage class
+type ColdStorageTiering struct {
+	Store     ColdStorageStore
+	Threshold time.Duration
+	Now       func() time.Time
+}
+
+// Run transitions all stale resources and returns the number of resources moved to cold storage.
+// Resources that have never been accessed gets their access time set such that the clock starts ticking
+func (c *ColdStorageTiering) Run(ctx context.Context) (int, error) {
+	orgs, err := c.Store.ListOrganizations(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	now := c.Now()
+	numTransitioned := 0
+	for _, org := range orgs {
+		if org.Deleted {
+			continue
+		}
+
+		metas, metaErr := c.Store.MetaByPattern(ctx, org.Id, &MetaData{})
+		if metaErr != nil {
+			err = errors.Join(err, metaErr)
+			continue
+		}
+
+		for _, meta := range metas {
+			if meta.Deleted || meta.StorageClass == StorageClassCold {
+				continue
+			}
+
+			resourceId := meta.ResourceId()
+			if meta.LastAccessed.IsZero() {
+				err = errors.Join(err, c.Store.RecordAccess(ctx, org.Id, resourceId, now))
+				continue
+			}
+
+			if now.Sub(meta.LastAccessed) < c.Threshold {
+				continue
+			}
+
+			if transitionErr := c.Store.TransitionStorageClass(ctx, org.Id, resourceId, StorageClassCold); transitionErr != nil {
+				err = errors.Join(err, transitionErr)
+				continue
+			}
+			numTransitioned++
+		}
+	}
+	return numTransitioned, err
+}
+
+func NewColdStorageTiering(store ColdStorageStore, threshold time.Duration) *ColdStorageTiering {
+	return &ColdStorageTiering{
+		Store:     store,
+		Threshold: threshold,
+		Now:       time.Now,
+	}
+}
@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestColdStorageTiering(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMultiOrgInMemoryStore()
+	orgId := "org"
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &Organization{Id: orgId}))
+
+	data := store.Data[orgId]
+	data.Metadata = []MetaData{
+		{Title: "Stale", LastAccessed: now.Add(-200 * 24 * time.Hour)},
+		{Title: "Fresh", LastAccessed: now.Add(-24 * time.Hour)},
+		{Title: "Never accessed"},
+		{Title: "Removed", Deleted: true, LastAccessed: now.Add(-200 * 24 * time.Hour)},
+	}
+
+	tiering := NewColdStorageTiering(store, 90*24*time.Hour)
+	tiering.Now = func() time.Time { return now }
+
+	num, err := tiering.Run(ctx)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, num, 1)
+
+	want := []StorageClass{StorageClassCold, StorageClassStandard, StorageClassStandard, StorageClassStandard}
+	for i, meta := range data.Metadata {
+		testutils.AssertEqual(t, meta.StorageClass, want[i])
+	}
+	testutils.AssertEqual(t, data.Metadata[2].LastAccessed, now)
+
+	t.Run("already cold resources are not counted", func(t *testing.T) {
+		num, err := tiering.Run(ctx)
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, num, 0)
+	})
+
+	t.Run("deleted organizations are skipped", func(t *testing.T) {
+		testutils.AssertNil(t, data.TransitionStorageClass(ctx, data.Metadata[0].ResourceId(), StorageClassStandard))
+		testutils.AssertNil(t, store.DeleteOrganization(ctx, orgId))
+		num, err := tiering.Run(ctx)
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, num, 0)
+	})
+}
+
+func TestColdStorageTieringListError(t *testing.T) {
+	store := coldStorageStoreWithLister{
+		MultiOrgInMemoryStore: NewMultiOrgInMemoryStore(),
+		lister:                &MockIAMStore{ErrListOrganizations: errors.New("list failed")},
+	}
+	tiering := NewColdStorageTiering(&store, time.Hour)
+	num, err := tiering.Run(context.Background())
+	testutils.AssertEqual(t, num, 0)
+	if err == nil {
+		t.Fatal("Wanted error")
+	}
+}
+
+type coldStorageStoreWithLister struct {
+	*MultiOrgInMemoryStore
+	lister OrganizationLister
+}
+
+func (c *coldStorageStoreWithLister) ListOrganizations(ctx context.Context) ([]Organization, error) {
+	return c.lister.ListOrganizations(ctx)
+}
+
+func TestColdStorageTieringMissingOrganizationData(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	store.Organizations = []Organization{{Id: "no-data"}}
+	tiering := NewColdStorageTiering(store, time.Hour)
+	_, err := tiering.Run(context.Background())
+	if !errors.Is(err, ErrOrganizationNotFound) {
+		t.Fatalf("Wanted ErrOrganizationNotFound got %v", err)
+	}
+}
+
+func TestRehydrateColdResource(t *testing.T) {
+	store := NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	ctx := context.Background()
+	resourceId := store.FirstDataStore().Metadata[0].ResourceId()
+	testutils.AssertNil(t, store.TransitionStorageClass(ctx, orgId, resourceId, StorageClassCold))
+
+	downloader := NewResourceDownloader().GetMetaData(ctx, store, orgId, resourceId).Rehydrate(ctx, store, orgId)
+	testutils.AssertNil(t, downloader.Error)
+
+	meta, err := store.MetaById(ctx, orgId, resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, meta.StorageClass, StorageClassStandard)
+}
@@ -0,0 +1,113 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// CombinedPiece is one of the pieces in a PDF that holds several pieces, for instance the score and the parts of
+// every movement of a suite. The assignments refer to the pages of the combined PDF
+type CombinedPiece struct {
+	MetaData    MetaData     `json:"metadata"`
+	Assignments []Assignment `json:"assignments"`
+}
+
+type CombinedSubmitStore interface {
+	MetaByIdGetter
+	Submitter
+	ResourceDeleter
+	ResourcePurger
+}
+
+// validateCombinedPieces checks that each piece becomes a separate resource with pages within the PDF
+func validateCombinedPieces(pieces []CombinedPiece, numPages int) error {
+	if len(pieces) == 0 {
+		return errors.Join(ErrInvalidCombinedSubmit, errors.New("no pieces provided"))
+	}
+	seen := make(map[string]bool)
+	for _, piece := range pieces {
+		resourceId := piece.MetaData.ResourceId()
+		if resourceId == "" {
+			return errors.Join(ErrInvalidCombinedSubmit, errors.New("each piece needs a title, composer or arranger"))
+		}
+		if seen[resourceId] {
+			return errors.Join(ErrInvalidCombinedSubmit, fmt.Errorf("%s is given more than once", resourceId))
+		}
+		seen[resourceId] = true
+
+		if len(piece.Assignments) == 0 {
+			return errors.Join(ErrInvalidCombinedSubmit, fmt.Errorf("%s has no assigned pages", resourceId))
+		}
+		for _, assignment := range piece.Assignments {
+			if assignment.From < 1 || assignment.To < assignment.From || assignment.To > numPages {
+				return errors.Join(ErrInvalidCombinedSubmit, fmt.Errorf("pages %d-%d of %s are not within the %d pages", assignment.From, assignment.To, assignment.Id, numPages))
+			}
+		}
+	}
+	return nil
+}
+
+// SubmitCombined stores each piece of the combined PDF as a new resource and returns the ids of the resources.
+// Either all pieces are stored or none. Pieces that would replace an existing resource are rejected before
+// anything is stored, and the resources created before a failing submit are removed again
+func SubmitCombined(ctx context.Context, store CombinedSubmitStore, orgId string, pdf io.ReadSeeker, pieces []CombinedPiece) ([]string, error) {
+	numPages, err := api.PageCount(pdf, model.NewDefaultConfiguration())
+	if err != nil {
+		return nil, errors.Join(ErrInvalidCombinedSubmit, fmt.Errorf("could not read pdf: %w", err))
+	}
+	if err := validateCombinedPieces(pieces, numPages); err != nil {
+		return nil, err
+	}
+
+	for _, piece := range pieces {
+		resourceId := piece.MetaData.ResourceId()
+		_, err := store.MetaById(ctx, orgId, resourceId)
+		if err == nil {
+			return nil, errors.Join(ErrResourceExists, fmt.Errorf("resource id: %s", resourceId))
+		} else if !errors.Is(err, ErrResourceMetadataNotFound) {
+			return nil, err
+		}
+	}
+
+	created := make([]string, 0, len(pieces))
+	for _, piece := range pieces {
+		if _, err := pdf.Seek(0, io.SeekStart); err != nil {
+			return nil, errors.Join(err, removeCombinedPieces(ctx, store, orgId, created))
+		}
+
+		meta := piece.MetaData
+		meta.Protected = false
+		resourceId := meta.ResourceId()
+		err := store.Submit(ctx, orgId, &meta, SplitPdf(pdf, piece.Assignments))
+
+		// A failing submit may leave the metadata of the resource behind
+		created = append(created, resourceId)
+		if err != nil {
+			return nil, errors.Join(err, removeCombinedPieces(ctx, store, orgId, created))
+		}
+	}
+	return created, nil
+}
+
+// removeCombinedPieces removes the resources created by a combined submit that failed
+func removeCombinedPieces(ctx context.Context, store CombinedSubmitStore, orgId string, resourceIds []string) error {
+	ctx = context.WithoutCancel(ctx)
+	var errs []error
+	for _, resourceId := range resourceIds {
+		err := store.DeleteResource(ctx, orgId, resourceId)
+		if err == nil {
+			err = store.PurgeResource(ctx, orgId, resourceId)
+		}
+		if err != nil && !IsNotFound(err) {
+			slog.ErrorContext(ctx, "Could not remove piece of failed combined submit", "error", err, "resourceId", resourceId)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,132 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"iter"
+	"maps"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+// failingSecondSubmitStore fails the second submit after storing the metadata, like a failing upload of parts
+type failingSecondSubmitStore struct {
+	*MultiOrgInMemoryStore
+	numSubmits int
+}
+
+func (f *failingSecondSubmitStore) Submit(ctx context.Context, orgId string, meta *MetaData, pdfIter iter.Seq2[string, []byte]) error {
+	f.numSubmits++
+	if f.numSubmits == 2 {
+		f.MultiOrgInMemoryStore.Submit(ctx, orgId, meta, func(yield func(string, []byte) bool) {})
+		return errors.New("upload failed")
+	}
+	return f.MultiOrgInMemoryStore.Submit(ctx, orgId, meta, pdfIter)
+}
+
+func combinedTestPieces() []CombinedPiece {
+	return []CombinedPiece{
+		{
+			MetaData:    MetaData{Title: "Suite I", Composer: "Holst"},
+			Assignments: []Assignment{{Id: "Score", From: 1, To: 2}, {Id: "Flute", From: 3, To: 3}},
+		},
+		{
+			MetaData:    MetaData{Title: "Suite II", Composer: "Holst"},
+			Assignments: []Assignment{{Id: "Score", From: 4, To: 5}, {Id: "Flute", From: 6, To: 6}},
+		},
+	}
+}
+
+func TestSubmitCombined(t *testing.T) {
+	ctx := context.Background()
+	var pdf bytes.Buffer
+	testutils.AssertNil(t, CreateNPagePdf(&pdf, 6))
+
+	store := NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &Organization{Id: "org1"}))
+
+	pieces := combinedTestPieces()
+	pieces[0].MetaData.Protected = true
+	ids, err := SubmitCombined(ctx, store, "org1", bytes.NewReader(pdf.Bytes()), pieces)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(ids), 2)
+
+	for _, id := range ids {
+		meta, err := store.MetaById(ctx, "org1", id)
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, meta.Protected, false)
+
+		parts := maps.Collect(store.Resource(ctx, "org1", id))
+		testutils.AssertEqual(t, len(parts), 2)
+		text, err := ExtractPDFText(parts["Score.pdf"])
+		testutils.AssertNil(t, err)
+		if id == pieces[1].MetaData.ResourceId() {
+			testutils.AssertContains(t, text, "page 4")
+		} else {
+			testutils.AssertContains(t, text, "page 1")
+		}
+	}
+
+	t.Run("existing resources are not replaced", func(t *testing.T) {
+		pieces := combinedTestPieces()
+		pieces[0].MetaData.Title = "Suite III"
+		_, err := SubmitCombined(ctx, store, "org1", bytes.NewReader(pdf.Bytes()), pieces)
+		testutils.AssertEqual(t, errors.Is(err, ErrResourceExists), true)
+
+		_, err = store.MetaById(ctx, "org1", pieces[0].MetaData.ResourceId())
+		testutils.AssertEqual(t, errors.Is(err, ErrResourceMetadataNotFound), true)
+	})
+}
+
+func TestSubmitCombinedInvalid(t *testing.T) {
+	var pdf bytes.Buffer
+	testutils.AssertNil(t, CreateNPagePdf(&pdf, 6))
+
+	for _, test := range []struct {
+		desc   string
+		modify func(pieces []CombinedPiece) []CombinedPiece
+	}{
+		{"no pieces", func(pieces []CombinedPiece) []CombinedPiece { return nil }},
+		{"repeated piece", func(pieces []CombinedPiece) []CombinedPiece {
+			pieces[1].MetaData = pieces[0].MetaData
+			return pieces
+		}},
+		{"missing title", func(pieces []CombinedPiece) []CombinedPiece {
+			pieces[0].MetaData = MetaData{}
+			return pieces
+		}},
+		{"no pages", func(pieces []CombinedPiece) []CombinedPiece {
+			pieces[0].Assignments = nil
+			return pieces
+		}},
+		{"page outside pdf", func(pieces []CombinedPiece) []CombinedPiece {
+			pieces[1].Assignments[1].To = 7
+			return pieces
+		}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			store := NewMultiOrgInMemoryStore()
+			pieces := test.modify(combinedTestPieces())
+			_, err := SubmitCombined(context.Background(), store, "org1", bytes.NewReader(pdf.Bytes()), pieces)
+			testutils.AssertEqual(t, errors.Is(err, ErrInvalidCombinedSubmit), true)
+		})
+	}
+}
+
+func TestSubmitCombinedRollsBack(t *testing.T) {
+	ctx := context.Background()
+	var pdf bytes.Buffer
+	testutils.AssertNil(t, CreateNPagePdf(&pdf, 6))
+
+	store := &failingSecondSubmitStore{MultiOrgInMemoryStore: NewMultiOrgInMemoryStore()}
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &Organization{Id: "org1"}))
+
+	_, err := SubmitCombined(ctx, store, "org1", bytes.NewReader(pdf.Bytes()), combinedTestPieces())
+	testutils.AssertEqual(t, err != nil, true)
+
+	metas, err := store.MetaByPattern(ctx, "org1", &MetaData{})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(metas), 0)
+}
@@ -12,16 +12,20 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/firestore"
 	"cloud.google.com/go/storage"
 	"github.com/davidkleiven/caesura/utils"
+	"github.com/getsops/sops/v3"
 	"github.com/getsops/sops/v3/decrypt"
 	"github.com/gorilla/sessions"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/microsoft"
 	"gopkg.in/yaml.v2"
 )
 
@@ -33,13 +37,16 @@ var configProfiles embed.FS
 type LocalFSStoreConfig struct {
 	Directory string `yaml:"directory"`
 	Database  string `yaml:"database"`
+
+	// Store identical parts once, see DedupBucketClient
+	Deduplicate bool `yaml:"deduplicate"`
 }
 
 type Smtp struct {
-	Auth   smtp.Auth
-	Host   string   `yaml:"host"`
-	Port   string   `yaml:"port"`
-	SendFn SendFunc `yaml:"-"`
+	Auth   smtp.Auth `secret:"true"`
+	Host   string    `yaml:"host"`
+	Port   string    `yaml:"port"`
+	SendFn SendFunc  `yaml:"-"`
 }
 
 func NewBrevo(password string) *Smtp {
@@ -101,41 +108,129 @@ type Config struct {
 	Port                     int                `yaml:"port" env:"CAESURA_PORT"`
 	SecretsPath              string             `yaml:"secrets_path" env:"CAESURA_SECRETS_PATH"`
 	MaxRequestSizeMb         uint               `yaml:"max_request_size_mb" env:"CAESURA_MAX_REQUEST_SIZE_MB"`
+	MaxUploadSizeMb          uint               `yaml:"max_upload_size_mb" env:"CAESURA_MAX_UPLOAD_SIZE_MB"`
+	UploadDir                string             `yaml:"upload_dir" env:"CAESURA_UPLOAD_DIR"`
+	UploadExpiry             time.Duration      `yaml:"upload_expiry" env:"CAESURA_UPLOAD_EXPIRY"`
+	LogExportDir             string             `yaml:"log_export_dir" env:"CAESURA_LOG_EXPORT_DIR"`
+	LogExportExpiry          time.Duration      `yaml:"log_export_expiry" env:"CAESURA_LOG_EXPORT_EXPIRY"`
+	ArchiveExpiry            time.Duration      `yaml:"archive_expiry" env:"CAESURA_ARCHIVE_EXPIRY"`
+	InvitationExpiry         time.Duration      `yaml:"invitation_expiry" env:"CAESURA_INVITATION_EXPIRY"`
 	GoogleAuthClientId       string             `yaml:"google_auth_client_id" env:"CAESURA_GOOGLE_AUTH_CLIENT_ID"`
-	GoogleAuthClientSecretId string             `yaml:"google_auth_client_secret_id" env:"CAESURA_GOOGLE_AUTH_CLIENT_SECRET_ID"`
+	GoogleAuthClientSecretId string             `yaml:"google_auth_client_secret_id" env:"CAESURA_GOOGLE_AUTH_CLIENT_SECRET_ID" secret:"true"`
 	GoogleAuthRedirectURL    string             `yaml:"google_auth_rederict_url" env:"CAESURA_GOOGLE_AUTH_REDIRECT_URL"`
-	CookieSecretSignKey      string             `yaml:"cookie_secret_sign_key" env:"CAESURA_COOKIE_SECRET_SIGN_KEY"`
+	MicrosoftAuthClientId    string             `yaml:"microsoft_auth_client_id" env:"CAESURA_MICROSOFT_AUTH_CLIENT_ID"`
+	MicrosoftAuthSecret      string             `yaml:"microsoft_auth_secret" env:"CAESURA_MICROSOFT_AUTH_SECRET" secret:"true"`
+	MicrosoftAuthRedirectURL string             `yaml:"microsoft_auth_redirect_url" env:"CAESURA_MICROSOFT_AUTH_REDIRECT_URL"`
+	MicrosoftAuthTenant      string             `yaml:"microsoft_auth_tenant" env:"CAESURA_MICROSOFT_AUTH_TENANT"`
+	OIDC                     OIDCConfig         `yaml:"oidc"`
+	Apple                    AppleConfig        `yaml:"apple"`
+	CookieSecretSignKey      string             `yaml:"cookie_secret_sign_key" env:"CAESURA_COOKIE_SECRET_SIGN_KEY" secret:"true"`
 	BaseURL                  string             `yaml:"base_url" env:"CAESURA_BASE_URL"`
 	SessionMaxAge            int                `yaml:"session_max_age" env:"CAESURA_SESSION_MAX_AGE"`
+	SessionRefreshInterval   time.Duration      `yaml:"session_refresh_interval" env:"CAESURA_SESSION_REFRESH_INTERVAL"`
+	SharedLinkExpiry         time.Duration      `yaml:"shared_link_expiry" env:"CAESURA_SHARED_LINK_EXPIRY"`
+	PermissionsCacheTTL      time.Duration      `yaml:"permissions_cache_ttl" env:"CAESURA_PERMISSIONS_CACHE_TTL"`
+	DomainCacheTTL           time.Duration      `yaml:"domain_cache_ttl" env:"CAESURA_DOMAIN_CACHE_TTL"`
 	SmtpConfig               Smtp               `yaml:"smtp"`
 	EmailSender              string             `yaml:"email_sender" env:"CAESURA_EMAIL_SENDER"`
-	StripeSecretKey          string             `yaml:"stripe_secret_key" env:"CAESURA_STRIPE_SECRET_KEY"`
-	StripeWebhookSignSecret  string             `yaml:"stripe_webhook_sign_secret" env:"CAESURA_STRIPE_WEBHOOK_SIGN_SECRET"`
+	StripeSecretKey          string             `yaml:"stripe_secret_key" env:"CAESURA_STRIPE_SECRET_KEY" secret:"true"`
+	StripeWebhookSignSecret  string             `yaml:"stripe_webhook_sign_secret" env:"CAESURA_STRIPE_WEBHOOK_SIGN_SECRET" secret:"true"`
 	StripeIdProvider         string             `yaml:"stripe_id_provider" env:"CAESURA_STRIPE_ID_PROVIDER"`
 	RequireSubscription      bool               `yaml:"require_subscription" env:"CAUSURA_REQUIRE_SUBSCRIPTION"`
-	BrevoApiKey              string             `yaml:"brevo_api_key" env:"CAESURA_BREVO_API_KEY"`
+	RequireVerifiedEmail     bool               `yaml:"require_verified_email" env:"CAESURA_REQUIRE_VERIFIED_EMAIL"`
+	BrevoApiKey              string             `yaml:"brevo_api_key" env:"CAESURA_BREVO_API_KEY" secret:"true"`
 	EmailDeliveryService     string             `yaml:"email_delivery_service" env:"CAESURA_EMAIL_DELIVERY_SERVICE"`
 	GoogleCfg                GoogleConfig       `yaml:"google_config"`
+	S3Cfg                    S3Config           `yaml:"s3"`
+	PostgresCfg              PostgresConfig     `yaml:"postgres"`
+	AzureCfg                 AzureConfig        `yaml:"azure"`
+	Resilience               ResilienceConfig   `yaml:"resilience"`
+	Encryption               EncryptionConfig   `yaml:"encryption"`
 	PortalSessionProvider    string             `yaml:"portal_session_provider"`
 	MaxNumRequestsPerMinute  float64            `yaml:"max_num_requests_per_minute"`
+	LoginThrottle            LoginThrottling    `yaml:"login_throttle"`
+	LoadShedding             LoadShedding       `yaml:"load_shedding"`
+	ColdStorageAfter         time.Duration      `yaml:"cold_storage_after" env:"CAESURA_COLD_STORAGE_AFTER"`
+	TrashRetention           time.Duration      `yaml:"trash_retention" env:"CAESURA_TRASH_RETENTION"`
+	PendingSubmitTimeout     time.Duration      `yaml:"pending_submit_timeout" env:"CAESURA_PENDING_SUBMIT_TIMEOUT"`
+	OrphanCheckInterval      time.Duration      `yaml:"orphan_check_interval" env:"CAESURA_ORPHAN_CHECK_INTERVAL"`
+	RemoveOrphans            bool               `yaml:"remove_orphans"`
+	TextExtractionInterval   time.Duration      `yaml:"text_extraction_interval" env:"CAESURA_TEXT_EXTRACTION_INTERVAL"`
+	ArtifactCleanupInterval  time.Duration      `yaml:"artifact_cleanup_interval" env:"CAESURA_ARTIFACT_CLEANUP_INTERVAL"`
+	Retention                RetentionConfig    `yaml:"retention"`
+	PlatformAdmins           []string           `yaml:"platform_admins"`
+	DevTools                 bool               `yaml:"dev_tools" env:"CAESURA_DEV_TOOLS"`
+	MockOAuth                bool               `yaml:"mock_oauth" env:"CAESURA_MOCK_OAUTH"`
+	TemplateDir              string             `yaml:"template_dir" env:"CAESURA_TEMPLATE_DIR"`
+	CookieDomain             string             `yaml:"cookie_domain" env:"CAESURA_COOKIE_DOMAIN"`
 	Transport                http.RoundTripper  `yaml:"-"`
+
+	// Sources are the layers the configuration was loaded from, see LoadProfile
+	Sources []string `yaml:"-"`
 }
 
 func (c *Config) Validate() error {
 	switch c.StoreType {
-	case "in-memory", GoogleCloud:
+	case "in-memory":
 		// No additional validation needed for in-memory store
+	case GoogleCloud:
+		switch c.GoogleCfg.BlobBackend {
+		case "", GoogleCloud:
+		case Azure:
+			if err := c.AzureCfg.Validate(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown google_config.blob_backend: %s", c.GoogleCfg.BlobBackend)
+		}
 	case "small-demo":
 		// No additional validation
 	case "large-demo":
 		// No additional validation
-	case "local-fs":
+	case S3Compatible:
+		if c.S3Cfg.Bucket == "" {
+			return fmt.Errorf("s3.bucket must be specified for s3 store")
+		}
+	case LocalFS:
 		if c.LocalFS.Directory == "" {
 			return fmt.Errorf("local_fs.directory must be specified for local-fs store")
 		}
+	case Postgres:
+		if c.PostgresCfg.DSN == "" || c.PostgresCfg.Directory == "" {
+			return fmt.Errorf("postgres.dsn and postgres.directory must be specified for postgres store")
+		}
 	default:
 		return fmt.Errorf("unknown store_type: %s", c.StoreType)
 	}
+
+	if c.OIDC.Enabled() && c.OIDC.ClientId == "" {
+		return fmt.Errorf("oidc.client_id must be specified when oidc.issuer is set")
+	}
+
+	if c.Apple.Enabled() {
+		if err := c.Apple.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Anyone can sign in as any of the mock users, hence the mock provider is only for in-memory stores
+	inMemory := slices.Contains([]string{"in-memory", "small-demo", "large-demo"}, c.StoreType)
+	if c.MockOAuth && !inMemory {
+		return fmt.Errorf("mock_oauth can only be enabled with an in-memory store, not %s", c.StoreType)
+	}
+
+	if c.MockOAuth && c.GoogleCfg.Environment == "prod" {
+		return fmt.Errorf("mock_oauth can not be enabled in prod")
+	}
+
+	// The dev tools replace all data without signing in
+	if c.DevTools && !inMemory {
+		return fmt.Errorf("dev_tools can only be enabled with an in-memory store, not %s", c.StoreType)
+	}
+
+	if c.DevTools && c.GoogleCfg.Environment == "prod" {
+		return fmt.Errorf("dev_tools can not be enabled in prod")
+	}
 	return nil
 }
 
@@ -145,14 +240,45 @@ func (c *Config) OAuthConfig() *oauth2.Config {
 		ClientSecret: c.GoogleAuthClientSecretId,
 		RedirectURL:  c.GoogleAuthRedirectURL,
 		Scopes:       []string{"https://www.googleapis.com/auth/userinfo.email"},
-		Endpoint:     google.Endpoint,
+		Endpoint:     c.oauthEndpoint(),
 	}
 }
 
+func (c *Config) oauthEndpoint() oauth2.Endpoint {
+	if c.MockOAuth {
+		return oauth2.Endpoint{
+			AuthURL:   c.BaseURL + MockOAuthAuthorizePath,
+			TokenURL:  c.BaseURL + MockOAuthTokenPath,
+			AuthStyle: oauth2.AuthStyleInParams,
+		}
+	}
+	return google.Endpoint
+}
+
+// MicrosoftOAuthConfig signs in with Microsoft accounts. The tenant limits sign in to one directory, and
+// defaults to common which allows both personal and work accounts
+func (c *Config) MicrosoftOAuthConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     c.MicrosoftAuthClientId,
+		ClientSecret: c.MicrosoftAuthSecret,
+		RedirectURL:  c.MicrosoftAuthRedirectURL,
+		Scopes:       []string{"openid", "email", "profile", "User.Read"},
+		Endpoint:     microsoft.AzureADEndpoint(c.MicrosoftAuthTenant),
+	}
+}
+
+func (c *Config) OAuthUserInfoURL() string {
+	if c.MockOAuth {
+		return c.BaseURL + MockOAuthUserInfoPath
+	}
+	return GoogleUserInfoURL
+}
+
 func (c *Config) SessionOpts() *sessions.Options {
 	return &sessions.Options{
 		Path:   "/",
 		MaxAge: c.SessionMaxAge,
+		Domain: c.CookieDomain,
 	}
 }
 
@@ -188,18 +314,39 @@ func (c *Config) GetPriceIds() *PriceIds {
 
 func NewDefaultConfig() *Config {
 	return &Config{
-		StoreType:             "in-memory",
-		Timeout:               10 * time.Second,
-		Port:                  8080,
-		MaxRequestSizeMb:      100,
-		GoogleAuthClientId:    "602223566336-77ugev7r0br5k1j8rc8i407kb0et34al.apps.googleusercontent.com",
-		GoogleAuthRedirectURL: "http://localhost:8080/auth/callback",
-		BaseURL:               "http://localhost:8080",
-		SessionMaxAge:         3600,
+		StoreType:                "in-memory",
+		Timeout:                  10 * time.Second,
+		Port:                     8080,
+		MaxRequestSizeMb:         100,
+		MaxUploadSizeMb:          2000,
+		UploadExpiry:             24 * time.Hour,
+		LogExportExpiry:          72 * time.Hour,
+		ArchiveExpiry:            24 * time.Hour,
+		InvitationExpiry:         DefaultInvitationExpiry,
+		GoogleAuthClientId:       "602223566336-77ugev7r0br5k1j8rc8i407kb0et34al.apps.googleusercontent.com",
+		GoogleAuthRedirectURL:    "http://localhost:8080/auth/callback",
+		MicrosoftAuthRedirectURL: "http://localhost:8080/auth/microsoft/callback",
+		OIDC:                     OIDCConfig{RedirectURL: "http://localhost:8080/auth/oidc/callback"},
+		Apple:                    AppleConfig{RedirectURL: "http://localhost:8080/auth/apple/callback"},
+		BaseURL:                  "http://localhost:8080",
+		SessionMaxAge:            3600,
+		SessionRefreshInterval:   5 * time.Minute,
+		SharedLinkExpiry:         72 * time.Hour,
+		PermissionsCacheTTL:      5 * time.Second,
+		DomainCacheTTL:           time.Minute,
 		SmtpConfig: Smtp{
 			SendFn: smtp.SendMail,
 		},
 		MaxNumRequestsPerMinute: 120.0,
+		TrashRetention:          30 * 24 * time.Hour,
+		PendingSubmitTimeout:    time.Hour,
+		OrphanCheckInterval:     24 * time.Hour,
+		TextExtractionInterval:  5 * time.Minute,
+		ArtifactCleanupInterval: time.Hour,
+		Retention:               RetentionConfig{Interval: 24 * time.Hour},
+		Resilience:              DefaultResilienceConfig(),
+		LoginThrottle:           DefaultLoginThrottling(),
+		LoadShedding:            DefaultLoadShedding(),
 	}
 }
 
@@ -278,9 +425,101 @@ func LoadConfig(configFile string) (*Config, error) {
 			return config, err
 		}
 	}
+	overrideFromEnvironment(config)
+	return OverrideEmailDeliveryService(config)
+}
+
+// overrideFromEnvironment sets the settings given by environment variables or by files in the secrets path
+func overrideFromEnvironment(config *Config) {
 	OverrideFromEnv(config, os.LookupEnv)
 	OverrideFromEnv(config, FileEnvGetter(config.SecretsPath))
-	return OverrideEmailDeliveryService(config)
+	OverrideFromEnv(&config.S3Cfg, os.LookupEnv)
+	OverrideFromEnv(&config.S3Cfg, FileEnvGetter(config.SecretsPath))
+	OverrideFromEnv(&config.PostgresCfg, os.LookupEnv)
+	OverrideFromEnv(&config.PostgresCfg, FileEnvGetter(config.SecretsPath))
+	OverrideFromEnv(&config.Resilience, os.LookupEnv)
+	OverrideFromEnv(&config.AzureCfg, os.LookupEnv)
+	OverrideFromEnv(&config.AzureCfg, FileEnvGetter(config.SecretsPath))
+}
+
+// secretConfigKeys are parts of the names of settings holding secrets. They catch secrets that are not tagged
+var secretConfigKeys = []string{"secret", "password", "private_key", "sign_key", "api_key", "access_key", "dsn", "keys", "connection_string"}
+
+const redactedValue = "<redacted>"
+
+func isSecretConfigKey(key string) bool {
+	return slices.ContainsFunc(secretConfigKeys, func(part string) bool {
+		return strings.Contains(key, part)
+	})
+}
+
+// secretConfigPaths adds the yaml paths, such as azure.connection_string, of the settings tagged with
+// `secret:"true"`. Settings holding secrets must be tagged, such that they are redacted whatever their name is
+func secretConfigPaths(t reflect.Type, prefix string, paths map[string]bool) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		path := prefix + name
+		if field.Tag.Get("secret") == "true" {
+			paths[path] = true
+		} else if field.Type.Kind() == reflect.Struct {
+			secretConfigPaths(field.Type, path+".", paths)
+		}
+	}
+}
+
+func isEmptyConfigValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case yaml.MapSlice:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// redactSecrets replaces the secrets in the decoded yaml. Empty secrets are kept, such that it is visible which
+// secrets are missing
+func redactSecrets(values yaml.MapSlice, prefix string, paths map[string]bool) {
+	for i, item := range values {
+		key := fmt.Sprint(item.Key)
+		path := prefix + key
+		if (paths[path] || isSecretConfigKey(key)) && !isEmptyConfigValue(item.Value) {
+			values[i].Value = redactedValue
+			continue
+		}
+		if nested, ok := item.Value.(yaml.MapSlice); ok {
+			redactSecrets(nested, path+".", paths)
+		}
+	}
+}
+
+// Redacted returns the effective configuration as yaml with the secrets replaced, together with the layers it
+// was loaded from
+func (c *Config) Redacted() ([]byte, error) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var values yaml.MapSlice
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	paths := make(map[string]bool)
+	secretConfigPaths(reflect.TypeOf(*c), "", paths)
+	redactSecrets(values, "", paths)
+	return yaml.Marshal(append(yaml.MapSlice{{Key: "sources", Value: c.Sources}}, values...))
 }
 
 func OverrideEmailDeliveryService(config *Config) (*Config, error) {
@@ -324,6 +563,37 @@ func GetStore(config *Config) StoreInitResult {
 	case GoogleCloud:
 		slog.Info(msg, key, "google-cloud")
 		return initGoogleStore(config)
+	case S3Compatible:
+		slog.Info(msg, key, "s3", "endpoint", config.S3Cfg.Endpoint, "bucket", config.S3Cfg.Bucket)
+		store := NewS3Store(NewS3Client(&config.S3Cfg), &config.S3Cfg)
+		var err error
+		store.BucketClient = withDeduplication(config.S3Cfg.Deduplicate, store.BucketClient)
+		store.BucketClient, err = withEncryption(context.Background(), &config.Encryption, store.BucketClient)
+		return StoreInitResult{
+			Store:   store,
+			Err:     err,
+			Cleanup: noOpCleanUp,
+		}
+	case LocalFS:
+		slog.Info(msg, key, LocalFS, "directory", config.LocalFS.Directory)
+		store, err := NewLocalStore(&config.LocalFS)
+		if err != nil {
+			return StoreInitResult{Store: NewMultiOrgInMemoryStore(), Err: err, Cleanup: noOpCleanUp}
+		}
+		store.BucketClient = withDeduplication(config.LocalFS.Deduplicate, store.BucketClient)
+		store.BucketClient, err = withEncryption(context.Background(), &config.Encryption, store.BucketClient)
+		return StoreInitResult{Store: store, Err: err, Cleanup: store.Close}
+	case Postgres:
+		slog.Info(msg, key, Postgres, "directory", config.PostgresCfg.Directory)
+		ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+		defer cancel()
+		store, err := NewPostgresStore(ctx, &config.PostgresCfg)
+		if err != nil {
+			return StoreInitResult{Store: NewMultiOrgInMemoryStore(), Err: err, Cleanup: noOpCleanUp}
+		}
+		store.BucketClient = withDeduplication(config.PostgresCfg.Deduplicate, store.BucketClient)
+		store.BucketClient, err = withEncryption(ctx, &config.Encryption, store.BucketClient)
+		return StoreInitResult{Store: store, Err: err, Cleanup: store.Close}
 	default:
 		slog.Info(msg, key, "empty-store")
 		return StoreInitResult{
@@ -342,20 +612,36 @@ func initGoogleStore(config *Config) StoreInitResult {
 		cleanup = append(cleanup, firestoreClient.Close)
 	}
 
-	cloudStoreClient, errCloud := storage.NewClient(backgroundCtx)
-	if errCloud == nil {
-		cleanup = append(cleanup, cloudStoreClient.Close)
+	var blobClient BlobClient
+	var errBlob error
+	if googleConfig.BlobBackend == Azure {
+		var azureClient AzureBlobAPI
+		azureClient, errBlob = NewAzureClient(&config.AzureCfg)
+		blobClient = NewAzureBlobClient(azureClient, &config.AzureCfg)
+		googleConfig.Bucket = config.AzureCfg.Container
+	} else {
+		var cloudStoreClient *storage.Client
+		cloudStoreClient, errBlob = storage.NewClient(backgroundCtx)
+		if errBlob == nil {
+			cleanup = append(cleanup, cloudStoreClient.Close)
+		}
+		blobClient = &GCSBucketClient{client: cloudStoreClient}
 	}
+
+	bucketClient := withDeduplication(googleConfig.Deduplicate, NewResilientBucketClient(blobClient, &config.Resilience))
+
+	// Encrypted outside the deduplication, since parts of organizations with a key are never shared
+	bucketClient, errEncryption := withEncryption(backgroundCtx, &config.Encryption, bucketClient)
 	return StoreInitResult{
 		Store: &GoogleStore{
-			FsClient: &GoogleFirestoreClient{
+			FsClient: NewResilientFirestoreClient(&GoogleFirestoreClient{
 				client:      firestoreClient,
 				environment: googleConfig.Environment,
-			},
-			BucketClient: &GCSBucketClient{client: cloudStoreClient},
+			}, &config.Resilience),
+			BucketClient: bucketClient,
 			Config:       &googleConfig,
 		},
-		Err: errors.Join(err, errCloud),
+		Err: errors.Join(err, errBlob, errEncryption),
 		Cleanup: func() error {
 			var cleanupErrs []error
 			for _, fn := range cleanup {
@@ -368,17 +654,43 @@ func initGoogleStore(config *Config) StoreInitResult {
 	}
 }
 
-func LoadProfile(name string) (*Config, error) {
-	config := NewDefaultConfig()
+// baseProfile holds the settings shared by all profiles
+const baseProfile = "config.yml"
+
+// readProfile returns the content of an embedded profile. Profiles encrypted with sops are decrypted, and
+// profiles without sops metadata are returned as is
+func readProfile(name string) ([]byte, error) {
 	data, err := configProfiles.ReadFile(fmt.Sprintf("profiles/%s", name))
 	if err != nil {
-		return config, fmt.Errorf("Could not open file %s: %w", name, err)
+		return nil, fmt.Errorf("Could not open file %s: %w", name, err)
 	}
 
 	cleartext, err := decrypt.Data(data, "yaml")
+	if errors.Is(err, sops.MetadataNotFound) {
+		return data, nil
+	}
 	if err != nil {
-		return config, fmt.Errorf("Could not decrypt config file %s: %w", name, err)
+		return nil, fmt.Errorf("Could not decrypt config file %s: %w", name, err)
 	}
-	err = yaml.Unmarshal(cleartext, config)
-	return config, err
+	return cleartext, nil
+}
+
+// LoadProfile loads the configuration in layers where each layer overrides the previous: the defaults, the base
+// profile config.yml, the named profile and finally the environment. The applied layers are listed in Sources
+func LoadProfile(name string) (*Config, error) {
+	config := NewDefaultConfig()
+	config.Sources = []string{"defaults"}
+	for _, layer := range []string{baseProfile, name} {
+		data, err := readProfile(layer)
+		if err != nil {
+			return config, err
+		}
+		if err := yaml.Unmarshal(data, config); err != nil {
+			return config, fmt.Errorf("error parsing config file %s: %w", layer, err)
+		}
+		config.Sources = append(config.Sources, layer)
+	}
+	overrideFromEnvironment(config)
+	config.Sources = append(config.Sources, "environment")
+	return config, nil
 }
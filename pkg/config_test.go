@@ -2,10 +2,16 @@ package pkg
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io"
+	"io/fs"
+	"maps"
+	"net/smtp"
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"testing"
 
 	"github.com/davidkleiven/caesura/testutils"
@@ -275,6 +281,90 @@ func TestSuccessFullDecryption(t *testing.T) {
 	testutils.AssertEqual(t, res.BrevoApiKey, "very-secret-key")
 }
 
+func TestLoadProfileLayers(t *testing.T) {
+	t.Setenv("SOPS_AGE_KEY", "AGE-SECRET-KEY-1RXUZPSPY9VRV52XE867Q92DVL4C9YQWC2CXSSG224A5HJHRAKD6S2T3XH2")
+	t.Setenv("CAESURA_PORT", "9090")
+	t.Setenv("CAESURA_BREVO_API_KEY", "key-from-env")
+
+	res, err := LoadProfile("config-unittest.yml")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, res.Port, 9090)
+	testutils.AssertEqual(t, res.BrevoApiKey, "key-from-env")
+	testutils.AssertEqual(t, slices.Equal(res.Sources, []string{"defaults", "config.yml", "config-unittest.yml", "environment"}), true)
+}
+
+func TestPlainTextProfileIsNotDecrypted(t *testing.T) {
+	data, err := readProfile(baseProfile)
+	testutils.AssertNil(t, err)
+	testutils.AssertContains(t, string(data), "Settings shared by all profiles")
+}
+
+func TestRedactedConfig(t *testing.T) {
+	config := NewDefaultConfig()
+	config.Sources = []string{"defaults", "config-prod.yml"}
+	config.StripeSecretKey = "sk_live_123"
+	config.CookieSecretSignKey = "cookie-key"
+	config.PostgresCfg.DSN = "postgres://user:pass@db/caesura"
+	config.Encryption.Keys = map[string]string{"v1": "encryption-key"}
+	config.BaseURL = "https://caesura.no"
+
+	data, err := config.Redacted()
+	testutils.AssertNil(t, err)
+	testutils.AssertNotContains(t, string(data), "sk_live_123", "cookie-key", "user:pass", "encryption-key")
+	testutils.AssertContains(t, string(data), "stripe_secret_key: <redacted>", "https://caesura.no", "config-prod.yml")
+
+	// Missing secrets are shown as empty
+	testutils.AssertContains(t, string(data), `stripe_webhook_sign_secret: ""`)
+}
+
+func TestRedactedConfigAzureConnectionString(t *testing.T) {
+	config := NewDefaultConfig()
+	config.AzureCfg.ConnectionString = "DefaultEndpointsProtocol=https;AccountName=caesura;AccountKey=c2VjcmV0"
+
+	data, err := config.Redacted()
+	testutils.AssertNil(t, err)
+	testutils.AssertNotContains(t, string(data), "AccountKey=c2VjcmV0")
+	testutils.AssertContains(t, string(data), "connection_string: <redacted>")
+}
+
+// setSecretFields fills every setting tagged as secret with a distinct value and returns the values
+func setSecretFields(v reflect.Value, values *[]string) {
+	for i := range v.NumField() {
+		field, value := v.Type().Field(i), v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		secret := fmt.Sprintf("secret-value-%d", len(*values))
+		switch {
+		case field.Tag.Get("secret") != "true":
+			if value.Kind() == reflect.Struct {
+				setSecretFields(value, values)
+			}
+			continue
+		case value.Kind() == reflect.String:
+			value.SetString(secret)
+		case value.Kind() == reflect.Map:
+			value.Set(reflect.ValueOf(map[string]string{"v1": secret}))
+		case field.Type == reflect.TypeOf((*smtp.Auth)(nil)).Elem():
+			value.Set(reflect.ValueOf(smtp.PlainAuth("", "user", secret, "localhost")))
+		default:
+			panic(fmt.Sprintf("no test value for secret field %s", field.Name))
+		}
+		*values = append(*values, secret)
+	}
+}
+
+func TestRedactedConfigRedactsAllSecretFields(t *testing.T) {
+	config := NewDefaultConfig()
+	var values []string
+	setSecretFields(reflect.ValueOf(config).Elem(), &values)
+
+	data, err := config.Redacted()
+	testutils.AssertNil(t, err)
+	testutils.AssertNotContains(t, string(data), values...)
+	testutils.AssertEqual(t, len(values) > 10, true)
+}
+
 func TestGetMaxNumScoresUnknownPriceId(t *testing.T) {
 	priceIds := NewTestPriceIds()
 	testutils.AssertEqual(t, priceIds.NumScores("unknown-price-id"), 10)
@@ -289,6 +379,9 @@ func TestSessionOpts(t *testing.T) {
 	c := NewDefaultConfig()
 	c.SessionMaxAge = 100
 	testutils.AssertEqual(t, c.SessionOpts().MaxAge, 100)
+
+	c.CookieDomain = "caesura.no"
+	testutils.AssertEqual(t, c.SessionOpts().Domain, "caesura.no")
 }
 
 func TestStripeIdProvider(t *testing.T) {
@@ -332,3 +425,133 @@ func TestGetPriceIds(t *testing.T) {
 	}
 
 }
+
+func TestMockOAuthConfig(t *testing.T) {
+	config := NewDefaultConfig()
+	testutils.AssertEqual(t, config.OAuthUserInfoURL(), GoogleUserInfoURL)
+	testutils.AssertEqual(t, config.OAuthConfig().Endpoint.TokenURL, "https://oauth2.googleapis.com/token")
+
+	config.MockOAuth = true
+	config.BaseURL = "http://localhost:1234"
+	testutils.AssertEqual(t, config.OAuthUserInfoURL(), "http://localhost:1234/mock-oauth/userinfo")
+	testutils.AssertEqual(t, config.OAuthConfig().Endpoint.AuthURL, "http://localhost:1234/mock-oauth/authorize")
+	testutils.AssertEqual(t, config.OAuthConfig().Endpoint.TokenURL, "http://localhost:1234/mock-oauth/token")
+	testutils.AssertNil(t, config.Validate())
+
+	config.GoogleCfg.Environment = "prod"
+	if err := config.Validate(); err == nil {
+		t.Fatal("Mock OAuth should not be allowed in prod")
+	}
+
+	config.GoogleCfg.Environment = ""
+	config.StoreType = "local-fs"
+	config.LocalFS.Directory = t.TempDir()
+	if err := config.Validate(); err == nil {
+		t.Fatal("Mock OAuth should only be allowed with in-memory stores")
+	}
+}
+
+func TestDevToolsConfig(t *testing.T) {
+	config := NewDefaultConfig()
+	config.DevTools = true
+	testutils.AssertNil(t, config.Validate())
+
+	config.GoogleCfg.Environment = "prod"
+	if err := config.Validate(); err == nil {
+		t.Fatal("Dev tools should not be allowed in prod")
+	}
+
+	config.GoogleCfg.Environment = ""
+	config.StoreType = "local-fs"
+	config.LocalFS.Directory = t.TempDir()
+	if err := config.Validate(); err == nil {
+		t.Fatal("Dev tools should only be allowed with in-memory stores")
+	}
+}
+
+func TestGetS3StoreFromConfig(t *testing.T) {
+	config := NewDefaultConfig()
+	config.StoreType = S3Compatible
+	if err := config.Validate(); err == nil {
+		t.Fatal("expected validation to fail for missing s3.bucket")
+	}
+
+	config.S3Cfg = S3Config{Endpoint: "http://localhost:9000", Bucket: "caesura", Region: "us-east-1", UsePathStyle: true}
+	testutils.AssertNil(t, config.Validate())
+
+	result := GetStore(config)
+	defer result.Cleanup()
+	store, ok := result.Store.(*S3Store)
+	testutils.AssertEqual(t, ok, true)
+	testutils.AssertEqual(t, store.Config.Bucket, "caesura")
+}
+
+func TestGetLocalStoreFromConfig(t *testing.T) {
+	config := NewDefaultConfig()
+	config.StoreType = LocalFS
+	config.LocalFS = LocalFSStoreConfig{Directory: t.TempDir()}
+	testutils.AssertNil(t, config.Validate())
+
+	result := GetStore(config)
+	testutils.AssertNil(t, result.Err)
+	_, ok := result.Store.(*LocalStore)
+	testutils.AssertEqual(t, ok, true)
+	testutils.AssertNil(t, result.Cleanup())
+}
+
+func TestGetLocalStoreWithDeduplication(t *testing.T) {
+	config := NewDefaultConfig()
+	config.StoreType = LocalFS
+	config.LocalFS = LocalFSStoreConfig{Directory: t.TempDir(), Deduplicate: true}
+
+	result := GetStore(config)
+	testutils.AssertNil(t, result.Err)
+	defer result.Cleanup()
+	store := result.Store.(*LocalStore)
+	_, ok := store.BucketClient.(*DedupBucketClient)
+	testutils.AssertEqual(t, ok, true)
+
+	ctx := context.Background()
+	pdf := []byte("%PDF-1.4 shared part")
+	for _, title := range []string{"First", "Second"} {
+		meta := MetaData{Title: title}
+		testutils.AssertNil(t, store.Submit(ctx, "org", &meta, maps.All(map[string][]byte{"Trumpet.pdf": pdf})))
+	}
+
+	var blobs []string
+	filepath.WalkDir(filepath.Join(config.LocalFS.Directory, localBucket, dedupBlobDir), func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			blobs = append(blobs, path)
+		}
+		return nil
+	})
+	testutils.AssertEqual(t, len(blobs), 1)
+}
+
+func TestValidateAzureBlobBackend(t *testing.T) {
+	config := NewDefaultConfig()
+	config.StoreType = GoogleCloud
+	config.GoogleCfg.BlobBackend = Azure
+	if err := config.Validate(); err == nil {
+		t.Fatal("expected validation to fail for missing azure.container")
+	}
+
+	config.AzureCfg = AzureConfig{AccountURL: "https://caesura.blob.core.windows.net/", Container: "scores"}
+	testutils.AssertNil(t, config.Validate())
+
+	config.GoogleCfg.BlobBackend = "unknown"
+	if err := config.Validate(); err == nil {
+		t.Fatal("expected validation to fail for unknown blob backend")
+	}
+}
+
+func TestValidatePostgresConfig(t *testing.T) {
+	config := NewDefaultConfig()
+	config.StoreType = Postgres
+	if err := config.Validate(); err == nil {
+		t.Fatal("expected validation to fail for missing postgres.dsn")
+	}
+
+	config.PostgresCfg = PostgresConfig{DSN: "postgres://localhost/caesura", Directory: "/var/lib/caesura"}
+	testutils.AssertNil(t, config.Validate())
+}
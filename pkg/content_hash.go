@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"iter"
+
+	"cloud.google.com/go/firestore"
+)
+
+// contentHash accumulates the hash of the parts of a submit. Parts that are not uploaded again are kept when a
+// resource is submitted again, hence the hash of the previous parts is part of the new hash
+type contentHash struct {
+	h hash.Hash
+}
+
+func newContentHash(previous string) *contentHash {
+	h := sha256.New()
+	h.Write([]byte(previous))
+	return &contentHash{h: h}
+}
+
+// parts passes on the parts while adding their names and contents to the hash
+func (c *contentHash) parts(pdfIter iter.Seq2[string, []byte]) iter.Seq2[string, []byte] {
+	return func(yield func(string, []byte) bool) {
+		for name, content := range pdfIter {
+			part := sha256.Sum256(content)
+			c.h.Write([]byte(name))
+			c.h.Write([]byte{0})
+			c.h.Write(part[:])
+			if !yield(name, content) {
+				return
+			}
+		}
+	}
+}
+
+func (c *contentHash) Sum() string {
+	return hex.EncodeToString(c.h.Sum(nil))
+}
+
+// clearContentHash is called before the parts of a resource are changed outside of a submit. Downloads of the
+// resource have no entity tag until it is submitted again
+func (g *GoogleStore) clearContentHash(ctx context.Context, orgId, resourceId string) error {
+	err := g.FsClient.Update(ctx, metaDataCollection, orgId, resourceId, []firestore.Update{{Path: "content_hash", Value: ""}})
+	return classifyStoreErr(err, ErrResourceMetadataNotFound)
+}
+
+// PartETag is the entity tag of a part of the resource. It is empty when the content hash of the resource is
+// unknown, which is the case for resources submitted before content hashes were introduced and after the parts
+// are renamed or restored from a version
+func (m *MetaData) PartETag(name string) string {
+	if m.ContentHash == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(m.ContentHash + "/" + name))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// ResourceETag is the entity tag of the zip file with all parts of the resource. The tag is weak, since the
+// zip file is created on each download
+func (m *MetaData) ResourceETag() string {
+	if m.ContentHash == "" {
+		return ""
+	}
+	return `W/"` + m.ContentHash[:min(32, len(m.ContentHash))] + `"`
+}
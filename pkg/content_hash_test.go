@@ -0,0 +1,85 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func assertContentHash(t *testing.T, store interface {
+	Submitter
+	ResourceGetter
+	ResourceVersioner
+	PartRenamer
+}) {
+	ctx := context.Background()
+	meta := MetaData{Title: "Polka"}
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, manifestParts))
+	resourceId := meta.ResourceId()
+
+	contentHash := func() string {
+		stored, err := store.MetaById(ctx, "org", resourceId)
+		testutils.AssertNil(t, err)
+		return stored.ContentHash
+	}
+	first := contentHash()
+	testutils.AssertEqual(t, len(first), 64)
+	testutils.AssertEqual(t, meta.ContentHash, first)
+
+	// The hash only depends on the parts, and a hash given by the caller is replaced
+	other := MetaData{Title: "Waltz", ContentHash: "stale"}
+	testutils.AssertNil(t, store.Submit(ctx, "org", &other, manifestParts))
+	testutils.AssertEqual(t, other.ContentHash, first)
+
+	added := func(yield func(string, []byte) bool) { yield("Tuba.pdf", []byte("Tuba.pdf")) }
+	testutils.AssertNil(t, store.Submit(ctx, "org", &MetaData{Title: "Polka"}, added))
+	second := contentHash()
+	testutils.AssertEqual(t, second != first && second != "", true)
+
+	_, err := RenamePart(ctx, store, "org", resourceId, "Tuba.pdf", "Tuba 1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, contentHash(), "")
+
+	testutils.AssertNil(t, store.Submit(ctx, "org", &MetaData{Title: "Polka"}, added))
+	testutils.AssertEqual(t, contentHash() != "", true)
+	testutils.AssertNil(t, store.RestoreVersion(ctx, "org", resourceId, 1))
+	testutils.AssertEqual(t, contentHash(), "")
+}
+
+func TestGoogleStoreContentHash(t *testing.T) {
+	assertContentHash(t, &GoogleStore{
+		FsClient:     NewLocalFirestoreClient(),
+		BucketClient: &FileBucketClient{Directory: t.TempDir()},
+		Config:       &GoogleConfig{Bucket: "scores"},
+	})
+}
+
+func TestLocalStoreContentHash(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+	assertContentHash(t, store)
+}
+
+func TestInMemoryStoreContentHash(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.RegisterOrganization(context.Background(), &Organization{Id: "org"}))
+	assertContentHash(t, store)
+}
+
+func TestMetaDataETags(t *testing.T) {
+	meta := MetaData{Title: "Polka"}
+	testutils.AssertEqual(t, meta.PartETag("Horn.pdf"), "")
+	testutils.AssertEqual(t, meta.ResourceETag(), "")
+
+	meta.ContentHash = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	testutils.AssertEqual(t, meta.ResourceETag(), `W/"0123456789abcdef0123456789abcdef"`)
+	testutils.AssertEqual(t, len(meta.PartETag("Horn.pdf")), 34)
+	testutils.AssertEqual(t, meta.PartETag("Horn.pdf") != meta.PartETag("Tuba.pdf"), true)
+
+	downloader := NewResourceDownloader()
+	downloader.meta = &meta
+	testutils.AssertEqual(t, downloader.ETag(""), meta.ResourceETag())
+	testutils.AssertEqual(t, downloader.ETag("Horn.pdf"), meta.PartETag("Horn.pdf"))
+	downloader.stamp = "Concert"
+	testutils.AssertEqual(t, downloader.ETag("Horn.pdf"), "")
+}
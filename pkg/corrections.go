@@ -0,0 +1,296 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+const (
+	correctionCollection = "corrections"
+	maxCorrectionLength  = 2000
+
+	// ProfileNameField is the only field of the member directory that members can propose corrections to
+	ProfileNameField = "name"
+)
+
+type CorrectionKind string
+
+const (
+	// CorrectionMetaData corrects a field of the metadata of a resource
+	CorrectionMetaData CorrectionKind = "metadata"
+
+	// CorrectionProfile corrects the directory data of the member proposing it
+	CorrectionProfile CorrectionKind = "profile"
+)
+
+type CorrectionStatus string
+
+const (
+	CorrectionPending  CorrectionStatus = "pending"
+	CorrectionApproved CorrectionStatus = "approved"
+	CorrectionRejected CorrectionStatus = "rejected"
+)
+
+var CorrectionStatuses = []CorrectionStatus{CorrectionPending, CorrectionApproved, CorrectionRejected}
+
+// Correction is a change to the metadata of a resource or to the directory data of a member proposed by a
+// member. The change is applied when it is approved
+type Correction struct {
+	Id   string         `json:"id" firestore:"id"`
+	Kind CorrectionKind `json:"kind" firestore:"kind"`
+
+	// TargetId is the resource id for metadata corrections and the user id for profile corrections
+	TargetId   string `json:"targetId" firestore:"targetId"`
+	TargetName string `json:"targetName" firestore:"targetName"`
+	Field      string `json:"field" firestore:"field"`
+
+	// Current is the value of the field when the correction was proposed
+	Current      string           `json:"current" firestore:"current"`
+	Value        string           `json:"value" firestore:"value"`
+	Comment      string           `json:"comment" firestore:"comment"`
+	ProposedBy   string           `json:"proposedBy" firestore:"proposedBy"`
+	ProposerName string           `json:"proposerName" firestore:"proposerName"`
+	Status       CorrectionStatus `json:"status" firestore:"status"`
+	CreatedAt    time.Time        `json:"createdAt" firestore:"createdAt"`
+	DecidedAt    time.Time        `json:"decidedAt" firestore:"decidedAt"`
+	DecidedBy    string           `json:"decidedBy" firestore:"decidedBy"`
+}
+
+func newCorrection(kind CorrectionKind, field, value, comment string, proposer *UserInfo) *Correction {
+	now := time.Now()
+	return &Correction{
+		Id:           fmt.Sprintf("%d-%s", now.UnixNano(), RandomInsecureID()),
+		Kind:         kind,
+		Field:        strings.ToLower(strings.TrimSpace(field)),
+		Value:        strings.TrimSpace(value),
+		Comment:      strings.TrimSpace(comment),
+		ProposedBy:   proposer.Id,
+		ProposerName: proposer.Name,
+		Status:       CorrectionPending,
+		CreatedAt:    now,
+	}
+}
+
+// NewMetaDataCorrection proposes a new value of a field of the metadata. The value is checked by applying it
+// to a copy of the metadata
+func NewMetaDataCorrection(meta *MetaData, field, value, comment string, proposer *UserInfo) (*Correction, error) {
+	correction := newCorrection(CorrectionMetaData, field, value, comment, proposer)
+	correction.TargetId = meta.ResourceId()
+	correction.TargetName = meta.Title
+	if _, err := correction.Patch().Apply(*meta); err != nil {
+		return correction, errors.Join(ErrInvalidCorrection, err)
+	}
+	correction.Current = metaDataFieldValue(meta, correction.Field)
+	return correction, correction.Validate()
+}
+
+// NewProfileCorrection proposes a new name of the member proposing it
+func NewProfileCorrection(value, comment string, proposer *UserInfo) (*Correction, error) {
+	correction := newCorrection(CorrectionProfile, ProfileNameField, value, comment, proposer)
+	correction.TargetId = proposer.Id
+	correction.TargetName = proposer.Name
+	correction.Current = proposer.Name
+	return correction, correction.Validate()
+}
+
+func (c *Correction) Validate() error {
+	if c.Id == "" || c.TargetId == "" || c.ProposedBy == "" {
+		return errors.Join(ErrInvalidCorrection, errors.New("correction must have an id, a target and a proposer"))
+	}
+	switch c.Kind {
+	case CorrectionMetaData:
+		if _, ok := metaDataSetters[c.Field]; !ok {
+			return errors.Join(ErrInvalidCorrection, fmt.Errorf("field %q can not be corrected", c.Field))
+		}
+	case CorrectionProfile:
+		if c.Field != ProfileNameField {
+			return errors.Join(ErrInvalidCorrection, fmt.Errorf("field %q can not be corrected", c.Field))
+		}
+		if c.Value == "" {
+			return errors.Join(ErrInvalidCorrection, errors.New("name can not be empty"))
+		}
+	default:
+		return errors.Join(ErrInvalidCorrection, fmt.Errorf("unknown kind of correction %q", c.Kind))
+	}
+	if !slices.Contains(CorrectionStatuses, c.Status) {
+		return errors.Join(ErrInvalidCorrection, fmt.Errorf("unknown status %q", c.Status))
+	}
+	if len(c.Value)+len(c.Comment) > maxCorrectionLength {
+		return errors.Join(ErrInvalidCorrection, fmt.Errorf("correction can not be longer than %d characters", maxCorrectionLength))
+	}
+	return nil
+}
+
+// Patch returns the metadata patch that applies a metadata correction
+func (c *Correction) Patch() *MetaDataPatch {
+	return &MetaDataPatch{ResourceId: c.TargetId, Fields: map[string]string{c.Field: c.Value}}
+}
+
+func metaDataFieldValue(meta *MetaData, field string) string {
+	switch field {
+	case "genre":
+		return meta.Genre
+	case "year":
+		return meta.Year
+	case "instrumentation":
+		return meta.Instrumentation
+	case "publisher":
+		return meta.Publisher
+	case "ismn":
+		return meta.Ismn
+	case "tags":
+		return meta.Tags
+	case "notes":
+		return meta.Notes
+	case "duration":
+		if meta.Duration == 0 {
+			return ""
+		}
+		return time.Duration(meta.Duration).String()
+	}
+	return ""
+}
+
+type CorrectionStore interface {
+	// SubmitCorrection stores the correction, and replaces any stored correction with the same id
+	SubmitCorrection(ctx context.Context, orgId string, correction *Correction) error
+
+	// Correction returns ErrCorrectionNotFound when there is no correction with the id in the organization
+	Correction(ctx context.Context, orgId, id string) (*Correction, error)
+
+	// Corrections returns the corrections of the organization. Pending corrections come first, newest first
+	Corrections(ctx context.Context, orgId string) ([]Correction, error)
+}
+
+type UserNameUpdater interface {
+	UpdateUserName(ctx context.Context, userId, name string) error
+}
+
+// DecideCorrection approves or rejects a pending correction. An approved correction is applied to the metadata
+// or the member in the same transaction as the decision is stored
+func DecideCorrection(ctx context.Context, store Transactor, orgId, id string, approve bool, decidedBy string, now time.Time) (*Correction, error) {
+	var decided *Correction
+	err := store.RunTransaction(ctx, func(ctx context.Context, tx TxStore) error {
+		correction, err := tx.Correction(ctx, orgId, id)
+		if err != nil {
+			return err
+		}
+		if correction.Status != CorrectionPending {
+			return errors.Join(ErrCorrectionDecided, fmt.Errorf("correction %s is %s", id, correction.Status))
+		}
+
+		correction.Status = CorrectionRejected
+		if approve {
+			correction.Status = CorrectionApproved
+			if err := applyCorrection(ctx, tx, orgId, correction); err != nil {
+				return err
+			}
+		}
+		correction.DecidedAt = now
+		correction.DecidedBy = decidedBy
+		decided = correction
+		return tx.SubmitCorrection(ctx, orgId, correction)
+	})
+	return decided, err
+}
+
+func applyCorrection(ctx context.Context, tx TxStore, orgId string, correction *Correction) error {
+	if correction.Kind == CorrectionProfile {
+		return tx.UpdateUserName(ctx, correction.TargetId, correction.Value)
+	}
+
+	meta, err := tx.MetaById(ctx, orgId, correction.TargetId)
+	if err != nil {
+		return err
+	}
+	patched, err := correction.Patch().Apply(*meta)
+	if err != nil {
+		return errors.Join(ErrInvalidCorrection, err)
+	}
+	return tx.UpdateMetaData(ctx, orgId, &patched)
+}
+
+// SortCorrections orders the corrections such that pending corrections come first, and the most recent first
+// within the pending and decided corrections
+func SortCorrections(corrections []Correction) {
+	slices.SortStableFunc(corrections, func(a, b Correction) int {
+		aPending, bPending := a.Status == CorrectionPending, b.Status == CorrectionPending
+		if aPending != bPending {
+			if aPending {
+				return -1
+			}
+			return 1
+		}
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+}
+
+// NumPendingCorrections returns the number of corrections waiting for a decision
+func NumPendingCorrections(corrections []Correction) int {
+	num := 0
+	for _, correction := range corrections {
+		if correction.Status == CorrectionPending {
+			num++
+		}
+	}
+	return num
+}
+
+func correctionNotFound(id string) error {
+	return errors.Join(ErrCorrectionNotFound, fmt.Errorf("correction id: %s", id))
+}
+
+func (g *GoogleStore) SubmitCorrection(ctx context.Context, orgId string, correction *Correction) error {
+	if err := correction.Validate(); err != nil {
+		return err
+	}
+	return g.FsClient.StoreDocument(ctx, correctionCollection, orgId, correction.Id, correction)
+}
+
+func (g *GoogleStore) Correction(ctx context.Context, orgId, id string) (*Correction, error) {
+	doc, err := g.FsClient.GetDoc(ctx, correctionCollection, orgId, id)
+	if err != nil {
+		return &Correction{}, classifyStoreErr(err, ErrCorrectionNotFound)
+	}
+	var correction Correction
+	err = doc.DataTo(&correction)
+	return &correction, err
+}
+
+func (g *GoogleStore) Corrections(ctx context.Context, orgId string) ([]Correction, error) {
+	collector := NewValidCollector[Correction]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, correctionCollection, orgId, "id", "") {
+		collector.Push(doc)
+	}
+	SortCorrections(collector.Items)
+	return collector.Items, collector.Err
+}
+
+// UpdateUserName renames the user, and the copies of the name on the links to the organizations of the user.
+// All documents are read before they are written such that the update can run in a transaction
+func (g *GoogleStore) UpdateUserName(ctx context.Context, userId, name string) error {
+	if _, err := g.FsClient.GetDoc(ctx, userCollection, userInfoDoc, userId); err != nil {
+		return classifyStoreErr(err, ErrUserNotFound)
+	}
+
+	var orgIds []string
+	for doc := range g.FsClient.GetDocByPrefix(ctx, userCollection, userOrgLinkDoc, "userId", userId) {
+		var link UserOrganizationLink
+		if err := doc.DataTo(&link); err == nil && link.UserId == userId {
+			orgIds = append(orgIds, link.OrgId)
+		}
+	}
+
+	update := []firestore.Update{{Path: "name", Value: name}}
+	err := g.FsClient.Update(ctx, userCollection, userInfoDoc, userId, update)
+	for _, orgId := range orgIds {
+		err = errors.Join(err, g.FsClient.Update(ctx, userCollection, userOrgLinkDoc, linkId(userId, orgId), update))
+	}
+	return classifyStoreErr(err, ErrUserNotFound)
+}
@@ -0,0 +1,172 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestCorrectionValidate(t *testing.T) {
+	meta := MetaData{Title: "Polka", Genre: "Folk"}
+	proposer := UserInfo{Id: "user", Name: "Anna"}
+
+	for _, test := range []struct {
+		desc  string
+		build func() (*Correction, error)
+		valid bool
+	}{
+		{"genre", func() (*Correction, error) { return NewMetaDataCorrection(&meta, " Genre ", "Polka", "", &proposer) }, true},
+		{"duration", func() (*Correction, error) { return NewMetaDataCorrection(&meta, "duration", "3m", "", &proposer) }, true},
+		{"invalid duration", func() (*Correction, error) { return NewMetaDataCorrection(&meta, "duration", "long", "", &proposer) }, false},
+		{"title is part of the id", func() (*Correction, error) { return NewMetaDataCorrection(&meta, "title", "Waltz", "", &proposer) }, false},
+		{"name", func() (*Correction, error) { return NewProfileCorrection("Anna Hansen", "", &proposer) }, true},
+		{"empty name", func() (*Correction, error) { return NewProfileCorrection(" ", "", &proposer) }, false},
+		{"long comment", func() (*Correction, error) {
+			return NewProfileCorrection("Anna", strings.Repeat("a", maxCorrectionLength), &proposer)
+		}, false},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := test.build()
+			testutils.AssertEqual(t, err == nil, test.valid)
+			if !test.valid {
+				testutils.AssertEqual(t, errors.Is(err, ErrInvalidCorrection), true)
+			}
+		})
+	}
+
+	correction, err := NewMetaDataCorrection(&meta, "genre", "Polka", "", &proposer)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, correction.Current, "Folk")
+	testutils.AssertEqual(t, correction.TargetName, "Polka")
+}
+
+// assertCorrectionStore runs the same checks against all implementations of the correction store
+func assertCorrectionStore(t *testing.T, store CorrectionStore) {
+	ctx := context.Background()
+	proposer := UserInfo{Id: "user", Name: "Anna"}
+	first, err := NewProfileCorrection("Anna Hansen", "Missing surname", &proposer)
+	testutils.AssertNil(t, err)
+	first.CreatedAt = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	second, err := NewMetaDataCorrection(&MetaData{Title: "Polka"}, "genre", "Folk", "", &proposer)
+	testutils.AssertNil(t, err)
+	second.CreatedAt = first.CreatedAt.Add(time.Hour)
+
+	testutils.AssertNil(t, store.SubmitCorrection(ctx, "org", first))
+	testutils.AssertNil(t, store.SubmitCorrection(ctx, "org", second))
+
+	invalid := *first
+	invalid.Kind = "unknown"
+	testutils.AssertEqual(t, errors.Is(store.SubmitCorrection(ctx, "org", &invalid), ErrInvalidCorrection), true)
+
+	decidedAt := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	second.Status = CorrectionRejected
+	second.DecidedAt = decidedAt
+	second.DecidedBy = "admin"
+	testutils.AssertNil(t, store.SubmitCorrection(ctx, "org", second))
+
+	corrections, err := store.Corrections(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(corrections), 2)
+	testutils.AssertEqual(t, corrections[0].Id, first.Id)
+	testutils.AssertEqual(t, corrections[0].Comment, "Missing surname")
+	testutils.AssertEqual(t, corrections[1].Status, CorrectionRejected)
+	testutils.AssertEqual(t, NumPendingCorrections(corrections), 1)
+
+	stored, err := store.Correction(ctx, "org", second.Id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.DecidedAt.Equal(decidedAt), true)
+	testutils.AssertEqual(t, stored.DecidedBy, "admin")
+	testutils.AssertEqual(t, stored.TargetId, second.TargetId)
+
+	_, err = store.Correction(ctx, "other-org", first.Id)
+	testutils.AssertEqual(t, errors.Is(err, ErrCorrectionNotFound), true)
+}
+
+func TestInMemoryCorrections(t *testing.T) {
+	assertCorrectionStore(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGoogleCorrections(t *testing.T) {
+	assertCorrectionStore(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
+
+type correctionTestStore interface {
+	Transactor
+	Submitter
+	MetaByIdGetter
+	CorrectionStore
+	UserRegisterer
+	RoleGetter
+}
+
+// assertDecideCorrection checks that approved corrections are applied, and that a correction that can not be
+// applied stays pending
+func assertDecideCorrection(t *testing.T, store correctionTestStore) {
+	ctx := context.Background()
+	meta := MetaData{Title: "Polka", Genre: "Folk"}
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, manifestParts))
+	user := UserInfo{Id: "user", Name: "Anna", Roles: map[string]RoleKind{"org": RoleViewer}, Groups: map[string][]string{}}
+	testutils.AssertNil(t, store.RegisterUser(ctx, &user))
+
+	genre, err := NewMetaDataCorrection(&meta, "genre", "Dance", "", &user)
+	testutils.AssertNil(t, err)
+	name, err := NewProfileCorrection("Anna Hansen", "", &user)
+	testutils.AssertNil(t, err)
+	notes, err := NewMetaDataCorrection(&meta, "notes", "Too fast", "", &user)
+	testutils.AssertNil(t, err)
+	for _, correction := range []*Correction{genre, name, notes} {
+		testutils.AssertNil(t, store.SubmitCorrection(ctx, "org", correction))
+	}
+
+	decided, err := DecideCorrection(ctx, store, "org", genre.Id, true, "admin", time.Now())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, decided.Status, CorrectionApproved)
+	stored, err := store.MetaById(ctx, "org", meta.ResourceId())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Genre, "Dance")
+
+	_, err = DecideCorrection(ctx, store, "org", name.Id, true, "admin", time.Now())
+	testutils.AssertNil(t, err)
+	renamed, err := store.GetUserInfo(ctx, "user")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, renamed.Name, "Anna Hansen")
+
+	decided, err = DecideCorrection(ctx, store, "org", notes.Id, false, "admin", time.Now())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, decided.Status, CorrectionRejected)
+	stored, err = store.MetaById(ctx, "org", meta.ResourceId())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Notes, "")
+
+	// A decision is final
+	_, err = DecideCorrection(ctx, store, "org", genre.Id, false, "admin", time.Now())
+	testutils.AssertEqual(t, errors.Is(err, ErrCorrectionDecided), true)
+
+	// Nothing is stored when the correction can not be applied
+	missing, err := NewMetaDataCorrection(&MetaData{Title: "Waltz"}, "genre", "Dance", "", &user)
+	testutils.AssertNil(t, err)
+	testutils.AssertNil(t, store.SubmitCorrection(ctx, "org", missing))
+	_, err = DecideCorrection(ctx, store, "org", missing.Id, true, "admin", time.Now())
+	testutils.AssertEqual(t, errors.Is(err, ErrResourceMetadataNotFound), true)
+	pending, err := store.Correction(ctx, "org", missing.Id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, pending.Status, CorrectionPending)
+}
+
+func TestInMemoryDecideCorrection(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.RegisterOrganization(context.Background(), &Organization{Id: "org"}))
+	assertDecideCorrection(t, store)
+}
+
+func TestGoogleStoreDecideCorrection(t *testing.T) {
+	assertDecideCorrection(t, &GoogleStore{
+		FsClient:     NewLocalFirestoreClient(),
+		BucketClient: &FileBucketClient{Directory: t.TempDir()},
+		Config:       &GoogleConfig{Bucket: "scores"},
+	})
+}
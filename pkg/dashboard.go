@@ -0,0 +1,104 @@
+package pkg
+
+import (
+	"context"
+	"slices"
+)
+
+// DashboardStore holds the aggregate queries used to assemble the start page of a signed in user
+type DashboardStore interface {
+	// RecentProjects returns at most limit projects of the organization, most recently updated first
+	RecentProjects(ctx context.Context, orgId string, limit int) ([]Project, error)
+
+	// RecentResources returns at most limit resources that are not deleted and not pending or failed,
+	// most recently submitted first
+	RecentResources(ctx context.Context, orgId string, limit int) ([]MetaData, error)
+
+	// NumMembers returns the number of members of the organization
+	NumMembers(ctx context.Context, orgId string) (int, error)
+}
+
+type DashboardTask string
+
+const (
+	DashboardTaskVerifyEmail DashboardTask = "verify-email"
+	DashboardTaskJoinGroup   DashboardTask = "join-group"
+)
+
+// DashboardLimit is the number of projects and resources shown on the dashboard
+const DashboardLimit = 5
+
+type Dashboard struct {
+	Organization Organization
+	NumMembers   int
+	Projects     []Project
+	Resources    []MetaData
+
+	// Tasks the user should complete, such as verifying the email address
+	Tasks []DashboardTask
+}
+
+type DashboardSource interface {
+	DashboardStore
+	OrganizationGetter
+	RoleGetter
+}
+
+// NewDashboard assembles the dashboard of the user in the organization
+func NewDashboard(ctx context.Context, store DashboardSource, orgId, userId string) (*Dashboard, error) {
+	org, err := store.GetOrganization(ctx, orgId)
+	if err != nil {
+		return nil, err
+	}
+	user, err := store.GetUserInfo(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	numMembers, err := store.NumMembers(ctx, orgId)
+	if err != nil {
+		return nil, err
+	}
+	projects, err := store.RecentProjects(ctx, orgId, DashboardLimit)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := store.RecentResources(ctx, orgId, DashboardLimit)
+	if err != nil {
+		return nil, err
+	}
+	return &Dashboard{
+		Organization: org,
+		NumMembers:   numMembers,
+		Projects:     projects,
+		Resources:    resources,
+		Tasks:        DashboardTasks(user, orgId),
+	}, nil
+}
+
+// DashboardTasks returns the tasks the user has not completed in the organization
+func DashboardTasks(user *UserInfo, orgId string) []DashboardTask {
+	tasks := []DashboardTask{}
+	if user.Email != "" && !user.VerifiedEmail {
+		tasks = append(tasks, DashboardTaskVerifyEmail)
+	}
+	if len(user.Groups[orgId]) == 0 {
+		tasks = append(tasks, DashboardTaskJoinGroup)
+	}
+	return tasks
+}
+
+// SortRecentProjects sorts the projects such that the most recently updated comes first and keeps at most limit
+func SortRecentProjects(projects []Project, limit int) []Project {
+	slices.SortStableFunc(projects, func(a, b Project) int { return b.UpdatedAt.Compare(a.UpdatedAt) })
+	return projects[:min(limit, len(projects))]
+}
+
+// SortRecentResources keeps the resources that are not deleted and not pending or failed, most recently
+// submitted first, and returns at most limit of them
+func SortRecentResources(resources []MetaData, limit int) []MetaData {
+	resources = slices.DeleteFunc(resources, func(m MetaData) bool {
+		return m.Deleted || m.Status == StoreStatusPending || m.Status == StoreStatusFailed
+	})
+	slices.SortStableFunc(resources, func(a, b MetaData) int { return b.SubmittedAt.Compare(a.SubmittedAt) })
+	return resources[:min(limit, len(resources))]
+}
@@ -0,0 +1,116 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+type dashboardTestStore interface {
+	DashboardStore
+	UserRegisterer
+	RoleRegisterer
+	OrganizationRegisterer
+	SubmitProject(ctx context.Context, orgId string, project *Project) error
+}
+
+// assertDashboardStore checks the aggregate queries. Metadata is stored with storeMeta, such that the status
+// and submission time are kept as they are
+func assertDashboardStore(t *testing.T, store dashboardTestStore, storeMeta func(orgId string, meta *MetaData)) {
+	ctx := context.Background()
+	for _, orgId := range []string{"org1", "org2"} {
+		testutils.AssertNil(t, store.RegisterOrganization(ctx, &Organization{Id: orgId, Name: orgId}))
+	}
+	for _, membership := range []struct{ userId, orgId string }{{"user1", "org1"}, {"user2", "org1"}, {"user3", "org2"}} {
+		testutils.AssertNil(t, store.RegisterUser(ctx, &UserInfo{Id: membership.userId}))
+		testutils.AssertNil(t, store.RegisterRole(ctx, membership.userId, membership.orgId, RoleViewer))
+	}
+
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for name, hours := range map[string]int{"Spring concert": 0, "Autumn concert": 2, "Summer concert": 1} {
+		project := &Project{Name: name, ResourceIds: []string{}, CreatedAt: start, UpdatedAt: start.Add(time.Duration(hours) * time.Hour)}
+		testutils.AssertNil(t, store.SubmitProject(ctx, "org1", project))
+	}
+	testutils.AssertNil(t, store.SubmitProject(ctx, "org2", &Project{Name: "Other", ResourceIds: []string{}, CreatedAt: start, UpdatedAt: start.Add(time.Hour)}))
+
+	for i, meta := range []MetaData{
+		{Title: "Old", Status: StoreStatusFinished},
+		{Title: "Legacy"},
+		{Title: "New", Status: StoreStatusFinished},
+		{Title: "Pending", Status: StoreStatusPending},
+		{Title: "Failed", Status: StoreStatusFailed},
+		{Title: "Deleted", Status: StoreStatusFinished, Deleted: true},
+	} {
+		meta.SubmittedAt = start.Add(time.Duration(i) * time.Hour)
+		storeMeta("org1", &meta)
+	}
+
+	projects, err := store.RecentProjects(ctx, "org1", 2)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(projects), 2)
+	testutils.AssertEqual(t, projects[0].Name, "Autumn concert")
+	testutils.AssertEqual(t, projects[1].Name, "Summer concert")
+
+	resources, err := store.RecentResources(ctx, "org1", 5)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(resources), 3)
+	testutils.AssertEqual(t, resources[0].Title, "New")
+	testutils.AssertEqual(t, resources[1].Title, "Legacy")
+	testutils.AssertEqual(t, resources[2].Title, "Old")
+
+	resources, err = store.RecentResources(ctx, "org2", 5)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(resources), 0)
+
+	numMembers, err := store.NumMembers(ctx, "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, numMembers, 2)
+}
+
+func TestInMemoryDashboardStore(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	assertDashboardStore(t, store, func(orgId string, meta *MetaData) {
+		store.Data[orgId].Metadata = append(store.Data[orgId].Metadata, *meta)
+	})
+}
+
+func TestGoogleDashboardStore(t *testing.T) {
+	store := &GoogleStore{FsClient: NewLocalFirestoreClient()}
+	assertDashboardStore(t, store, func(orgId string, meta *MetaData) {
+		record := FirestoreMetaData{MetaData: *meta, TitleSearch: firebaseSearchString(meta.Title)}
+		testutils.AssertNil(t, store.FsClient.StoreDocument(context.Background(), metaDataCollection, orgId, meta.ResourceId(), &record))
+	})
+}
+
+func TestNewDashboard(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &Organization{Id: "org1", Name: "Band"}))
+	testutils.AssertNil(t, store.RegisterUser(ctx, &UserInfo{Id: "user1", Email: "kari@example.com"}))
+	testutils.AssertNil(t, store.RegisterRole(ctx, "user1", "org1", RoleEditor))
+	testutils.AssertNil(t, store.SubmitProject(ctx, "org1", &Project{Name: "Spring concert"}))
+
+	dashboard, err := NewDashboard(ctx, store, "org1", "user1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, dashboard.Organization.Name, "Band")
+	testutils.AssertEqual(t, dashboard.NumMembers, 1)
+	testutils.AssertEqual(t, len(dashboard.Projects), 1)
+	testutils.AssertEqual(t, len(dashboard.Tasks), 2)
+	testutils.AssertEqual(t, dashboard.Tasks[0], DashboardTaskVerifyEmail)
+	testutils.AssertEqual(t, dashboard.Tasks[1], DashboardTaskJoinGroup)
+
+	_, err = NewDashboard(ctx, store, "unknown", "user1")
+	testutils.AssertEqual(t, err != nil, true)
+}
+
+func TestDashboardTasks(t *testing.T) {
+	user := &UserInfo{Id: "user1", Email: "kari@example.com", VerifiedEmail: true, Groups: map[string][]string{"org1": {"Horn"}}}
+	testutils.AssertEqual(t, len(DashboardTasks(user, "org1")), 0)
+
+	// Users without an email have nothing to verify
+	tasks := DashboardTasks(&UserInfo{Id: "user1"}, "org1")
+	testutils.AssertEqual(t, len(tasks), 1)
+	testutils.AssertEqual(t, tasks[0], DashboardTaskJoinGroup)
+}
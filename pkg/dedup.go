@@ -0,0 +1,317 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	// Content addressed blobs are stored below dedupBlobDir by their SHA-256 hash, and every object referring to
+	// a blob has an empty marker below dedupRefDir/<hash>/. The number of markers is the reference count
+	dedupBlobDir = "blobs/data"
+	dedupRefDir  = "blobs/refs"
+	dedupRoot    = "blobs/"
+
+	// Pointers are much smaller than any PDF, so only objects up to this size are checked for a pointer
+	maxDedupPointerSize = 512
+)
+
+var dedupPointerMagic = []byte(`{"caesura_blob":`)
+
+// dedupPointer is stored under the name of a part and refers to the blob holding its content. Size and MD5 are
+// those of the content, such that listings report the part and not the pointer
+type dedupPointer struct {
+	Hash string `json:"caesura_blob"`
+	Size int64  `json:"size"`
+	MD5  []byte `json:"md5"`
+}
+
+func newDedupPointer(data []byte) dedupPointer {
+	hash := sha256.Sum256(data)
+	checksum := md5.Sum(data)
+	return dedupPointer{Hash: hex.EncodeToString(hash[:]), Size: int64(len(data)), MD5: checksum[:]}
+}
+
+func parseDedupPointer(data []byte) (dedupPointer, bool) {
+	var pointer dedupPointer
+	if !bytes.HasPrefix(data, dedupPointerMagic) || json.Unmarshal(data, &pointer) != nil || pointer.Hash == "" {
+		return pointer, false
+	}
+	return pointer, true
+}
+
+func dedupBlobName(hash string) string {
+	return path.Join(dedupBlobDir, hash)
+}
+
+func dedupRefName(hash, object string) string {
+	return path.Join(dedupRefDir, hash, url.PathEscape(object))
+}
+
+// DedupBucketClient stores identical parts once, also across organizations. The object of a part only
+// contains a pointer to a blob named by the hash of the content, and the blob is removed when the last
+// object pointing to it is deleted or overwritten. Objects written before deduplication was enabled are
+// read as before. Storage classes only apply to the pointers, since a blob may be shared by several resources
+type DedupBucketClient struct {
+	Client BlobClient
+}
+
+// withDeduplication wraps client in a DedupBucketClient when enabled. Encryption must be applied outside, since
+// encrypted parts are never identical
+func withDeduplication(enabled bool, client BlobClient) BlobClient {
+	if !enabled {
+		return client
+	}
+	return NewDedupBucketClient(client)
+}
+
+func NewDedupBucketClient(client BlobClient) *DedupBucketClient {
+	return &DedupBucketClient{Client: client}
+}
+
+func (d *DedupBucketClient) Upload(ctx context.Context, bucket, object string, data []byte) error {
+	pointer := newDedupPointer(data)
+	previous, hasPrevious, err := d.readPointer(ctx, bucket, object)
+	if err != nil {
+		return err
+	}
+	if hasPrevious && previous.Hash == pointer.Hash {
+		return nil
+	}
+
+	// The reference is added before the blob is written, such that a concurrent release of the last other
+	// reference does not remove the blob
+	if err := d.Client.Upload(ctx, bucket, dedupRefName(pointer.Hash, object), []byte{}); err != nil {
+		return err
+	}
+	exists, err := d.objectExists(ctx, bucket, dedupBlobName(pointer.Hash))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := d.Client.Upload(ctx, bucket, dedupBlobName(pointer.Hash), data); err != nil {
+			return err
+		}
+	}
+
+	content, err := json.Marshal(pointer)
+	if err != nil {
+		return err
+	}
+	if err := d.Client.Upload(ctx, bucket, object, content); err != nil {
+		return err
+	}
+	if hasPrevious {
+		return d.release(ctx, bucket, previous.Hash, object)
+	}
+	return nil
+}
+
+// GetObject returns the content of the blob when the object is a pointer
+func (d *DedupBucketClient) GetObject(ctx context.Context, bucket, objName string) (io.ReadCloser, error) {
+	reader, err := d.Client.GetObject(ctx, bucket, objName)
+	if err != nil {
+		return reader, err
+	}
+
+	buffered := bufio.NewReaderSize(reader, maxDedupPointerSize)
+	head, _ := buffered.Peek(len(dedupPointerMagic))
+	if !bytes.Equal(head, dedupPointerMagic) {
+		return &bufferedReadCloser{Reader: buffered, Closer: reader}, nil
+	}
+
+	content, err := io.ReadAll(io.LimitReader(buffered, maxDedupPointerSize+1))
+	if err := errors.Join(err, reader.Close()); err != nil {
+		return nil, err
+	}
+	pointer, ok := parseDedupPointer(content)
+	if !ok {
+		return io.NopCloser(bytes.NewReader(content)), nil
+	}
+	return d.Client.GetObject(ctx, bucket, dedupBlobName(pointer.Hash))
+}
+
+// GetObjects hides the blobs and reports the size and checksum of the content for pointers
+func (d *DedupBucketClient) GetObjects(ctx context.Context, bucket string, query *storage.Query) ObjectLister {
+	return &dedupObjectLister{ctx: ctx, client: d, bucket: bucket, lister: d.Client.GetObjects(ctx, bucket, query)}
+}
+
+func (d *DedupBucketClient) SetStorageClass(ctx context.Context, bucket, object, class string) error {
+	return d.Client.SetStorageClass(ctx, bucket, object, class)
+}
+
+func (d *DedupBucketClient) Delete(ctx context.Context, bucket, object string) error {
+	pointer, isPointer, err := d.readPointer(ctx, bucket, object)
+	if err != nil && !IsNotFound(classifyStoreErr(err, ErrResourceNotFound)) {
+		return err
+	}
+	if err := d.Client.Delete(ctx, bucket, object); err != nil {
+		return err
+	}
+	if isPointer {
+		return d.release(ctx, bucket, pointer.Hash, object)
+	}
+	return nil
+}
+
+// SignedURL links to the blob, since the link is downloaded directly from the bucket
+func (d *DedupBucketClient) SignedURL(bucket, object, filename string, expires time.Time) (string, error) {
+	signer, ok := d.Client.(ObjectURLSigner)
+	if !ok {
+		return "", ErrSignedURLUnsupported
+	}
+	pointer, isPointer, err := d.readPointer(context.Background(), bucket, object)
+	if err != nil {
+		return "", err
+	}
+	if isPointer {
+		object = dedupBlobName(pointer.Hash)
+	}
+	return signer.SignedURL(bucket, object, filename, expires)
+}
+
+// rangeReader is the wrapped client together with the object holding the content of object
+func (d *DedupBucketClient) rangeReader(ctx context.Context, bucket, object string) (ObjectRangeReader, string, error) {
+	ranger, ok := d.Client.(ObjectRangeReader)
+	if !ok {
+		return nil, "", ErrRangeReadUnsupported
+	}
+	pointer, isPointer, err := d.readPointer(ctx, bucket, object)
+	if err != nil {
+		return nil, "", err
+	}
+	if isPointer {
+		object = dedupBlobName(pointer.Hash)
+	}
+	return ranger, object, nil
+}
+
+// ObjectSize is the size of the blob when the object is a pointer
+func (d *DedupBucketClient) ObjectSize(ctx context.Context, bucket, object string) (int64, error) {
+	ranger, object, err := d.rangeReader(ctx, bucket, object)
+	if err != nil {
+		return 0, err
+	}
+	return ranger.ObjectSize(ctx, bucket, object)
+}
+
+// NewRangeReader reads the range from the blob when the object is a pointer
+func (d *DedupBucketClient) NewRangeReader(ctx context.Context, bucket, object string, offset, length int64) (io.ReadCloser, error) {
+	ranger, object, err := d.rangeReader(ctx, bucket, object)
+	if err != nil {
+		return nil, err
+	}
+	return ranger.NewRangeReader(ctx, bucket, object, offset, length)
+}
+
+func (d *DedupBucketClient) Degraded() bool {
+	reporter, ok := d.Client.(HealthReporter)
+	return ok && reporter.Degraded()
+}
+
+// readPointer returns the pointer stored in object. Objects that do not exist or that are not pointers are
+// reported with ok false. Only a missing object returns an error
+func (d *DedupBucketClient) readPointer(ctx context.Context, bucket, object string) (dedupPointer, bool, error) {
+	reader, err := d.Client.GetObject(ctx, bucket, object)
+	if IsNotFound(classifyStoreErr(err, ErrResourceNotFound)) {
+		return dedupPointer{}, false, nil
+	}
+	if err != nil {
+		return dedupPointer{}, false, err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(io.LimitReader(reader, maxDedupPointerSize+1))
+	if err != nil {
+		return dedupPointer{}, false, err
+	}
+	pointer, ok := parseDedupPointer(content)
+	return pointer, ok, nil
+}
+
+func (d *DedupBucketClient) objectExists(ctx context.Context, bucket, object string) (bool, error) {
+	attrs, err := d.Client.GetObjects(ctx, bucket, &storage.Query{Prefix: object}).Next()
+	if errors.Is(err, iterator.Done) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return attrs.Name == object, nil
+}
+
+// release removes the reference from object to the blob, and removes the blob when it was the last reference
+func (d *DedupBucketClient) release(ctx context.Context, bucket, hash, object string) error {
+	err := d.Client.Delete(ctx, bucket, dedupRefName(hash, object))
+	if err != nil && !IsNotFound(classifyStoreErr(err, ErrResourceNotFound)) {
+		return err
+	}
+
+	_, err = d.Client.GetObjects(ctx, bucket, &storage.Query{Prefix: path.Join(dedupRefDir, hash) + "/"}).Next()
+	if !errors.Is(err, iterator.Done) {
+		// Either the blob is still referenced or the references could not be listed
+		return err
+	}
+	slog.InfoContext(ctx, "Removing unreferenced blob", "hash", hash)
+	err = d.Client.Delete(ctx, bucket, dedupBlobName(hash))
+	if err != nil && !IsNotFound(classifyStoreErr(err, ErrResourceNotFound)) {
+		return fmt.Errorf("could not remove blob %s: %w", hash, err)
+	}
+	return nil
+}
+
+type bufferedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// dedupObjectLister skips the blobs and references, and replaces the attributes of pointers
+type dedupObjectLister struct {
+	ctx    context.Context
+	client *DedupBucketClient
+	bucket string
+	lister ObjectLister
+}
+
+func (d *dedupObjectLister) Next() (*storage.ObjectAttrs, error) {
+	for {
+		attrs, err := d.lister.Next()
+		if err != nil {
+			return attrs, err
+		}
+		if strings.HasPrefix(attrs.Name, dedupRoot) {
+			continue
+		}
+		if attrs.Size > maxDedupPointerSize {
+			return attrs, nil
+		}
+
+		pointer, isPointer, err := d.client.readPointer(d.ctx, d.bucket, attrs.Name)
+		if err != nil {
+			return attrs, err
+		}
+		if isPointer {
+			resolved := *attrs
+			resolved.Size = pointer.Size
+			resolved.MD5 = pointer.MD5
+			return &resolved, nil
+		}
+		return attrs, nil
+	}
+}
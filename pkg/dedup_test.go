@@ -0,0 +1,156 @@
+package pkg
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/davidkleiven/caesura/testutils"
+	"google.golang.org/api/iterator"
+)
+
+func readObject(t *testing.T, client BlobClient, object string) string {
+	reader, err := client.GetObject(context.Background(), "bucket", object)
+	testutils.AssertNil(t, err)
+	content, err := io.ReadAll(reader)
+	testutils.AssertNil(t, errors.Join(err, reader.Close()))
+	return string(content)
+}
+
+func listNames(t *testing.T, client BlobClient, prefix string) []string {
+	lister := client.GetObjects(context.Background(), "bucket", &storage.Query{Prefix: prefix})
+	var names []string
+	for {
+		attrs, err := lister.Next()
+		if errors.Is(err, iterator.Done) {
+			return names
+		}
+		testutils.AssertNil(t, err)
+		names = append(names, attrs.Name)
+	}
+}
+
+func TestDedupBucketClientStoresIdenticalPartsOnce(t *testing.T) {
+	files := &FileBucketClient{Directory: t.TempDir()}
+	client := NewDedupBucketClient(files)
+	ctx := context.Background()
+	content := strings.Repeat("horn", 200)
+
+	testutils.AssertNil(t, client.Upload(ctx, "bucket", "org1/polka/Horn.pdf", []byte(content)))
+	testutils.AssertNil(t, client.Upload(ctx, "bucket", "org2/polka/Horn.pdf", []byte(content)))
+	testutils.AssertEqual(t, len(listNames(t, files, dedupBlobDir+"/")), 1)
+	testutils.AssertEqual(t, len(listNames(t, files, dedupRefDir+"/")), 2)
+
+	testutils.AssertEqual(t, readObject(t, client, "org1/polka/Horn.pdf"), content)
+	testutils.AssertEqual(t, readObject(t, client, "org2/polka/Horn.pdf"), content)
+
+	// Listings report the content, and blobs are hidden
+	attrs, err := client.GetObjects(ctx, "bucket", &storage.Query{Prefix: "org1/"}).Next()
+	testutils.AssertNil(t, err)
+	checksum := md5.Sum([]byte(content))
+	testutils.AssertEqual(t, attrs.Size, int64(len(content)))
+	testutils.AssertEqual(t, string(attrs.MD5), string(checksum[:]))
+	testutils.AssertEqual(t, len(listNames(t, client, "")), 2)
+
+	// The blob is kept until the last reference is removed
+	testutils.AssertNil(t, client.Delete(ctx, "bucket", "org1/polka/Horn.pdf"))
+	testutils.AssertEqual(t, readObject(t, client, "org2/polka/Horn.pdf"), content)
+	testutils.AssertNil(t, client.Delete(ctx, "bucket", "org2/polka/Horn.pdf"))
+	testutils.AssertEqual(t, len(listNames(t, files, "")), 0)
+}
+
+func TestDedupBucketClientOverwrite(t *testing.T) {
+	files := &FileBucketClient{Directory: t.TempDir()}
+	client := NewDedupBucketClient(files)
+	ctx := context.Background()
+
+	testutils.AssertNil(t, client.Upload(ctx, "bucket", "org/polka/Horn.pdf", []byte("first scan")))
+	testutils.AssertNil(t, client.Upload(ctx, "bucket", "org/polka/Horn.pdf", []byte("first scan")))
+	testutils.AssertEqual(t, len(listNames(t, files, dedupRefDir+"/")), 1)
+
+	testutils.AssertNil(t, client.Upload(ctx, "bucket", "org/polka/Horn.pdf", []byte("second scan")))
+	testutils.AssertEqual(t, readObject(t, client, "org/polka/Horn.pdf"), "second scan")
+	testutils.AssertEqual(t, len(listNames(t, files, dedupBlobDir+"/")), 1)
+	testutils.AssertEqual(t, len(listNames(t, files, dedupRefDir+"/")), 1)
+}
+
+func TestDedupBucketClientReadsObjectsWithoutPointer(t *testing.T) {
+	files := &FileBucketClient{Directory: t.TempDir()}
+	client := NewDedupBucketClient(files)
+	ctx := context.Background()
+	testutils.AssertNil(t, files.Upload(ctx, "bucket", "org/polka/Horn.pdf", []byte("horn")))
+
+	testutils.AssertEqual(t, readObject(t, client, "org/polka/Horn.pdf"), "horn")
+	attrs, err := client.GetObjects(ctx, "bucket", &storage.Query{Prefix: "org/"}).Next()
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, attrs.Size, int64(4))
+
+	testutils.AssertNil(t, client.Delete(ctx, "bucket", "org/polka/Horn.pdf"))
+	_, err = client.GetObject(ctx, "bucket", "org/polka/Horn.pdf")
+	testutils.AssertEqual(t, errors.Is(err, storage.ErrObjectNotExist), true)
+}
+
+type recordingSigner struct {
+	*FileBucketClient
+}
+
+func (r *recordingSigner) SignedURL(bucket, object, filename string, expires time.Time) (string, error) {
+	return "https://bucket.example.com/" + object, nil
+}
+
+func TestDedupBucketClientSignsBlob(t *testing.T) {
+	client := NewDedupBucketClient(&recordingSigner{FileBucketClient: &FileBucketClient{Directory: t.TempDir()}})
+	testutils.AssertNil(t, client.Upload(context.Background(), "bucket", "org/polka/Horn.pdf", []byte("horn")))
+
+	link, err := client.SignedURL("bucket", "org/polka/Horn.pdf", "Horn.pdf", time.Now().Add(time.Hour))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, link, "https://bucket.example.com/"+dedupBlobName(newDedupPointer([]byte("horn")).Hash))
+
+	_, err = NewDedupBucketClient(&FileBucketClient{}).SignedURL("bucket", "object", "file", time.Now())
+	testutils.AssertEqual(t, errors.Is(err, ErrSignedURLUnsupported), true)
+}
+
+func TestDedupBucketClientReadsRangeOfBlob(t *testing.T) {
+	client := NewDedupBucketClient(&FileBucketClient{Directory: t.TempDir()})
+	ctx := context.Background()
+	testutils.AssertNil(t, client.Upload(ctx, "bucket", "org/polka/Horn.pdf", []byte("horn part")))
+
+	size, err := client.ObjectSize(ctx, "bucket", "org/polka/Horn.pdf")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, size, int64(len("horn part")))
+
+	reader, err := client.NewRangeReader(ctx, "bucket", "org/polka/Horn.pdf", 5, 2)
+	testutils.AssertNil(t, err)
+	content, err := io.ReadAll(reader)
+	testutils.AssertNil(t, errors.Join(err, reader.Close()))
+	testutils.AssertEqual(t, string(content), "pa")
+}
+
+func TestGoogleStoreWithDedupVersions(t *testing.T) {
+	files := &FileBucketClient{Directory: t.TempDir()}
+	store := GoogleStore{FsClient: NewLocalFirestoreClient(), BucketClient: NewDedupBucketClient(files), Config: &GoogleConfig{Bucket: "bucket"}}
+	ctx := context.Background()
+	meta := MetaData{Title: "Polka"}
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, manifestParts))
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, manifestParts))
+
+	// The archived version shares the blobs with the current parts
+	versions, err := store.ResourceVersions(ctx, "org", meta.ResourceId())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(versions), 1)
+	testutils.AssertEqual(t, len(listNames(t, files, dedupBlobDir+"/")), 2)
+
+	manifest, err := store.ResourceManifest(ctx, "org", meta.ResourceId())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(manifest.Files), 2)
+	testutils.AssertEqual(t, manifest.Files[0].Size, int64(len(manifest.Files[0].Name)))
+
+	testutils.AssertNil(t, store.DeleteResource(ctx, "org", meta.ResourceId()))
+	testutils.AssertNil(t, store.PurgeResource(ctx, "org", meta.ResourceId()))
+	testutils.AssertEqual(t, len(listNames(t, files, "")), 0)
+}
@@ -0,0 +1,124 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var domainName = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// NormalizeDomain lower cases the host and removes port and trailing dot such that it can be compared
+// with the domain registered on an organization
+func NormalizeDomain(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+// ValidateDomain checks that domain is a fully qualified host name. An empty domain is valid and
+// means that the organization is only served on the main domain
+func ValidateDomain(domain string) error {
+	if domain != "" && !domainName.MatchString(domain) {
+		return errors.Join(ErrInvalidDomain, fmt.Errorf("%s is not a valid domain", domain))
+	}
+	return nil
+}
+
+// HostOf returns the normalized host of a URL. An empty string is returned if the URL can not be parsed
+func HostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return NormalizeDomain(u.Host)
+}
+
+type OrganizationByDomainGetter interface {
+	OrganizationByDomain(ctx context.Context, domain string) (Organization, error)
+}
+
+type DomainUpdater interface {
+	UpdateDomain(ctx context.Context, orgId, domain string) error
+}
+
+type DomainStore interface {
+	OrganizationByDomainGetter
+	DomainUpdater
+}
+
+// maxCachedDomains bounds the memory used by the cache, since any client can choose the host of a request
+const maxCachedDomains = 10000
+
+type cachedDomainOrganization struct {
+	org       Organization
+	err       error
+	fetchedAt time.Time
+}
+
+// CachedOrganizationByDomain keeps the organizations of domains for a short time. Domains without an
+// organization are cached as well, such that requests to other hosts do not cause a lookup each. A changed
+// domain is therefore noticed after at most one TTL
+type CachedOrganizationByDomain struct {
+	Getter  OrganizationByDomainGetter
+	TTL     time.Duration
+	Monitor CacheMonitor
+
+	mu    sync.Mutex
+	cache map[string]cachedDomainOrganization
+}
+
+func (c *CachedOrganizationByDomain) OrganizationByDomain(ctx context.Context, domain string) (Organization, error) {
+	now := time.Now()
+	c.mu.Lock()
+	item, ok := c.cache[domain]
+	if ok && now.Sub(item.fetchedAt) < c.TTL {
+		c.Monitor.NumHits += 1
+		c.mu.Unlock()
+		return item.org, item.err
+	}
+	c.Monitor.NumMisses += 1
+	c.mu.Unlock()
+
+	org, err := c.Getter.OrganizationByDomain(ctx, domain)
+	if err != nil && !errors.Is(err, ErrOrganizationNotFound) {
+		return org, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.cache) >= maxCachedDomains {
+		c.evictExpired(now)
+	}
+	c.cache[domain] = cachedDomainOrganization{org: org, err: err, fetchedAt: now}
+	c.Monitor.UpdateMaxSize(len(c.cache))
+	return org, err
+}
+
+// evictExpired removes the expired items. Everything is removed if all items are still valid
+func (c *CachedOrganizationByDomain) evictExpired(now time.Time) {
+	for domain, item := range c.cache {
+		if now.Sub(item.fetchedAt) >= c.TTL {
+			delete(c.cache, domain)
+		}
+	}
+	if len(c.cache) >= maxCachedDomains {
+		clear(c.cache)
+	}
+}
+
+func NewCachedOrganizationByDomain(getter OrganizationByDomainGetter, ttl time.Duration) *CachedOrganizationByDomain {
+	return &CachedOrganizationByDomain{
+		Getter: getter,
+		TTL:    ttl,
+		cache:  make(map[string]cachedDomainOrganization),
+	}
+}
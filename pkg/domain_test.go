@@ -0,0 +1,133 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestNormalizeDomain(t *testing.T) {
+	for _, test := range []struct {
+		host string
+		want string
+	}{
+		{"music.myorchestra.org", "music.myorchestra.org"},
+		{"Music.MyOrchestra.org:8080", "music.myorchestra.org"},
+		{"music.myorchestra.org.", "music.myorchestra.org"},
+		{" localhost:8080 ", "localhost"},
+		{"", ""},
+	} {
+		testutils.AssertEqual(t, NormalizeDomain(test.host), test.want)
+	}
+}
+
+func TestValidateDomain(t *testing.T) {
+	for _, domain := range []string{"", "music.myorchestra.org", "a-b.example.com"} {
+		testutils.AssertNil(t, ValidateDomain(domain))
+	}
+
+	for _, domain := range []string{"localhost", "-bad.example.com", "example", "http://example.com", "exa mple.com"} {
+		err := ValidateDomain(domain)
+		testutils.AssertEqual(t, errors.Is(err, ErrInvalidDomain), true)
+	}
+}
+
+func TestHostOf(t *testing.T) {
+	testutils.AssertEqual(t, HostOf("https://Caesura.no/path"), "caesura.no")
+	testutils.AssertEqual(t, HostOf("http://localhost:8080"), "localhost")
+	testutils.AssertEqual(t, HostOf("://bad"), "")
+}
+
+func TestInMemoryDomain(t *testing.T) {
+	ctx := context.Background()
+	store := NewMultiOrgInMemoryStore()
+	store.Organizations = []Organization{{Id: "org1"}, {Id: "org2"}, {Id: "deleted", Deleted: true, Domain: "old.example.com"}}
+
+	_, err := store.OrganizationByDomain(ctx, "")
+	testutils.AssertEqual(t, errors.Is(err, ErrOrganizationNotFound), true)
+
+	testutils.AssertNil(t, store.UpdateDomain(ctx, "org1", "music.example.com"))
+	org, err := store.OrganizationByDomain(ctx, "music.example.com")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, org.Id, "org1")
+
+	t.Run("domain used by other organization", func(t *testing.T) {
+		err := store.UpdateDomain(ctx, "org2", "music.example.com")
+		testutils.AssertEqual(t, errors.Is(err, ErrDomainInUse), true)
+	})
+
+	t.Run("same organization can set domain again", func(t *testing.T) {
+		testutils.AssertNil(t, store.UpdateDomain(ctx, "org1", "music.example.com"))
+	})
+
+	t.Run("deleted organizations are not resolved", func(t *testing.T) {
+		_, err := store.OrganizationByDomain(ctx, "old.example.com")
+		testutils.AssertEqual(t, errors.Is(err, ErrOrganizationNotFound), true)
+		testutils.AssertNil(t, store.UpdateDomain(ctx, "org2", "old.example.com"))
+	})
+
+	t.Run("unknown organization", func(t *testing.T) {
+		err := store.UpdateDomain(ctx, "unknown", "new.example.com")
+		testutils.AssertEqual(t, errors.Is(err, ErrOrganizationNotFound), true)
+	})
+}
+
+type countingDomainGetter struct {
+	calls int
+	err   error
+}
+
+func (c *countingDomainGetter) OrganizationByDomain(ctx context.Context, domain string) (Organization, error) {
+	c.calls++
+	if domain != "music.example.com" {
+		return Organization{}, ErrOrganizationNotFound
+	}
+	return Organization{Id: "org1"}, c.err
+}
+
+func TestCachedOrganizationByDomain(t *testing.T) {
+	ctx := context.Background()
+	getter := &countingDomainGetter{}
+	cache := NewCachedOrganizationByDomain(getter, time.Hour)
+
+	for range 3 {
+		org, err := cache.OrganizationByDomain(ctx, "music.example.com")
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, org.Id, "org1")
+
+		_, err = cache.OrganizationByDomain(ctx, "scanner.example.com")
+		testutils.AssertEqual(t, errors.Is(err, ErrOrganizationNotFound), true)
+	}
+	testutils.AssertEqual(t, getter.calls, 2)
+	testutils.AssertEqual(t, cache.Monitor.NumHits, 4)
+
+	t.Run("expired", func(t *testing.T) {
+		getter := &countingDomainGetter{}
+		cache := NewCachedOrganizationByDomain(getter, 0)
+		cache.OrganizationByDomain(ctx, "music.example.com")
+		cache.OrganizationByDomain(ctx, "music.example.com")
+		testutils.AssertEqual(t, getter.calls, 2)
+	})
+
+	t.Run("store failures are not cached", func(t *testing.T) {
+		getter := &countingDomainGetter{err: errors.New("unavailable")}
+		cache := NewCachedOrganizationByDomain(getter, time.Hour)
+		for range 2 {
+			_, err := cache.OrganizationByDomain(ctx, "music.example.com")
+			testutils.AssertEqual(t, err, getter.err)
+		}
+		testutils.AssertEqual(t, getter.calls, 2)
+	})
+
+	t.Run("bounded size", func(t *testing.T) {
+		cache := NewCachedOrganizationByDomain(&countingDomainGetter{}, time.Hour)
+		for i := range maxCachedDomains + 10 {
+			cache.OrganizationByDomain(ctx, fmt.Sprintf("host%d.example.com", i))
+		}
+		testutils.AssertEqual(t, len(cache.cache) <= maxCachedDomains, true)
+	})
+}
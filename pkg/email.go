@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"html/template"
 	"io"
 	"iter"
 	"math"
@@ -25,6 +27,7 @@ type Email struct {
 	SmtpPort  string
 	SmtpAuth  smtp.Auth
 	SendFn    SendFunc
+	Branding  *Branding
 }
 
 type EmailOpt func(*Email)
@@ -75,8 +78,91 @@ func WithSendFn(fn SendFunc) EmailOpt {
 	}
 }
 
+// WithBranding adds an HTML version of the body styled with the branding of an organization
+func WithBranding(branding Branding) EmailOpt {
+	return func(e *Email) {
+		e.Branding = &branding
+	}
+}
+
 const MIMEBase64MaxLineLength = 76
 
+var brandedEmailTemplate = template.Must(template.New("email").Parse(`<!doctype html>
+<html>
+  <body style="margin:0;font-family:sans-serif;">
+    <div style="background-color:{{.Color}};padding:16px;">
+      {{if .LogoURL}}<img src="{{.LogoURL}}" alt="Logo" style="max-height:48px;" />{{end}}
+    </div>
+    <div style="padding:16px;">
+      {{range .Lines}}<p>{{.}}</p>{{end}}
+    </div>
+  </body>
+</html>`))
+
+func writeQuotedPrintable(w io.Writer, content []byte) error {
+	qp := quotedprintable.NewWriter(w)
+	_, err := qp.Write(content)
+	return errors.Join(err, qp.Close())
+}
+
+func (e *Email) writeBody(writer *multipart.Writer, body string) error {
+	textPartHeader := make(textproto.MIMEHeader)
+	textPartHeader.Set("Content-Type", "text/plain; charset=utf-8")
+	textPartHeader.Set("Content-Transfer-Encoding", "quoted-printable")
+
+	if e.Branding == nil {
+		textPart, err := writer.CreatePart(textPartHeader)
+		if err != nil {
+			return err
+		}
+		return writeQuotedPrintable(textPart, []byte(body))
+	}
+
+	alternativeBoundary := "caesura-alternative-boundary"
+	alternativeHeader := make(textproto.MIMEHeader)
+	alternativeHeader.Set("Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", alternativeBoundary))
+	alternativePart, err := writer.CreatePart(alternativeHeader)
+	if err != nil {
+		return err
+	}
+
+	alternatives := multipart.NewWriter(alternativePart)
+	alternatives.SetBoundary(alternativeBoundary)
+	textPart, err := alternatives.CreatePart(textPartHeader)
+	if err != nil {
+		return err
+	}
+	if err := writeQuotedPrintable(textPart, []byte(body)); err != nil {
+		return err
+	}
+
+	htmlPartHeader := make(textproto.MIMEHeader)
+	htmlPartHeader.Set("Content-Type", "text/html; charset=utf-8")
+	htmlPartHeader.Set("Content-Transfer-Encoding", "quoted-printable")
+	htmlPart, err := alternatives.CreatePart(htmlPartHeader)
+	if err != nil {
+		return err
+	}
+
+	data := struct {
+		Color   string
+		LogoURL string
+		Lines   []string
+	}{
+		Color:   e.Branding.Color(),
+		LogoURL: e.Branding.LogoURL,
+		Lines:   strings.Split(body, "\n"),
+	}
+	var html bytes.Buffer
+	if err := brandedEmailTemplate.Execute(&html, data); err != nil {
+		return err
+	}
+	if err := writeQuotedPrintable(htmlPart, html.Bytes()); err != nil {
+		return err
+	}
+	return alternatives.Close()
+}
+
 func (e *Email) Build(subject string, body string, attachments iter.Seq2[string, io.Reader]) (*bytes.Buffer, error) {
 	var msg bytes.Buffer
 	boundary := "caesura-mixed-boundary"
@@ -97,17 +183,7 @@ func (e *Email) Build(subject string, body string, attachments iter.Seq2[string,
 	defer writer.Close()
 
 	writer.SetBoundary(boundary)
-	textPartHeader := make(textproto.MIMEHeader)
-	textPartHeader.Set("Content-Type", "text/plain; charset=utf-8")
-	textPartHeader.Set("Content-Transfer-Encoding", "quoted-printable")
-	textPart, err := writer.CreatePart(textPartHeader)
-	if err != nil {
-		return &msg, err
-	}
-	qp := quotedprintable.NewWriter(textPart)
-	_, err = qp.Write([]byte(body))
-	qp.Close()
-	if err != nil {
+	if err := e.writeBody(writer, body); err != nil {
 		return &msg, err
 	}
 
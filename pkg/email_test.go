@@ -238,3 +238,38 @@ func TestEmailOpts(t *testing.T) {
 	testutils.AssertEqual(t, emailSender, "me")
 	testutils.AssertEqual(t, string(message), "kjd")
 }
+
+func TestEmailBuild_BrandedBody(t *testing.T) {
+	email := Email{Sender: "sender@example.com", Recipents: []string{"recipient@example.com"}}
+	WithBranding(Branding{PrimaryColor: "#112233", LogoURL: "https://example.com/logo.png"})(&email)
+
+	noAttachments := iter.Seq2[string, io.Reader](func(yield func(string, io.Reader) bool) {})
+	msgBytes, err := email.Build("Test Subject", "Hello\n<b>world</b>", noAttachments)
+	testutils.AssertNil(t, err)
+
+	msg, err := mail.ReadMessage(msgBytes)
+	testutils.AssertNil(t, err)
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	testutils.AssertNil(t, err)
+
+	part, err := multipart.NewReader(msg.Body, params["boundary"]).NextPart()
+	testutils.AssertNil(t, err)
+	mediaType, params, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, mediaType, "multipart/alternative")
+
+	alternatives := multipart.NewReader(part, params["boundary"])
+	text, err := alternatives.NextPart()
+	testutils.AssertNil(t, err)
+	testutils.AssertContains(t, text.Header.Get("Content-Type"), "text/plain")
+	textBody, err := io.ReadAll(quotedprintable.NewReader(text))
+	testutils.AssertNil(t, err)
+	testutils.AssertContains(t, string(textBody), "Hello", "<b>world</b>")
+
+	html, err := alternatives.NextPart()
+	testutils.AssertNil(t, err)
+	testutils.AssertContains(t, html.Header.Get("Content-Type"), "text/html")
+	htmlBody, err := io.ReadAll(quotedprintable.NewReader(html))
+	testutils.AssertNil(t, err)
+	testutils.AssertContains(t, string(htmlBody), "background-color:#112233", `src="https://example.com/logo.png"`, "&lt;b&gt;world&lt;/b&gt;")
+}
@@ -0,0 +1,328 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"cloud.google.com/go/storage"
+)
+
+// Encrypted objects start with encryptionMagic followed by the nonce. The authentication tag follows the
+// ciphertext. The name of the object is the additional data, such that an object can not be replaced by another
+// object of the organization
+var encryptionMagic = []byte("CAESENC1")
+
+const encryptionOverhead = 8 + 12 + 16
+
+type EncryptionConfig struct {
+	// Base64 encoded AES-256 keys by organization id
+	Keys map[string]string `yaml:"keys" secret:"true"`
+
+	// Name of the Cloud KMS key that decrypts WrappedKeys, e.g.
+	// projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>
+	KmsKey string `yaml:"kms_key"`
+
+	// Base64 encoded AES-256 keys encrypted by KmsKey, by organization id
+	WrappedKeys map[string]string `yaml:"wrapped_keys" secret:"true"`
+
+	// Migrate allows reading parts of organizations with a key that were uploaded before the key was added. Such
+	// parts are encrypted when they are read
+	Migrate bool `yaml:"migrate"`
+}
+
+func (e *EncryptionConfig) Enabled() bool {
+	return len(e.Keys) > 0 || len(e.WrappedKeys) > 0
+}
+
+// KeyUnwrapper decrypts keys that were encrypted by a key management service
+type KeyUnwrapper interface {
+	Unwrap(ctx context.Context, keyName string, wrapped []byte) ([]byte, error)
+}
+
+type GoogleKMSUnwrapper struct {
+	Client *kms.KeyManagementClient
+}
+
+func (g *GoogleKMSUnwrapper) Unwrap(ctx context.Context, keyName string, wrapped []byte) ([]byte, error) {
+	resp, err := g.Client.Decrypt(ctx, &kmspb.DecryptRequest{Name: keyName, Ciphertext: wrapped})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
+
+// LoadEncryptionKeys decodes the keys of the config and unwraps the wrapped keys. The unwrapper is only used
+// when there are wrapped keys
+func LoadEncryptionKeys(ctx context.Context, config *EncryptionConfig, unwrapper KeyUnwrapper) (map[string][]byte, error) {
+	keys := make(map[string][]byte)
+	for orgId, encoded := range config.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidEncryptionKey, fmt.Errorf("organization %s: %w", orgId, err))
+		}
+		keys[orgId] = key
+	}
+
+	for orgId, encoded := range config.WrappedKeys {
+		wrapped, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidEncryptionKey, fmt.Errorf("organization %s: %w", orgId, err))
+		}
+		key, err := unwrapper.Unwrap(ctx, config.KmsKey, wrapped)
+		if err != nil {
+			return nil, fmt.Errorf("could not unwrap key of organization %s: %w", orgId, err)
+		}
+		keys[orgId] = key
+	}
+
+	for orgId, key := range keys {
+		if len(key) != 32 {
+			return nil, errors.Join(ErrInvalidEncryptionKey, fmt.Errorf("key of organization %s has %d bytes, expected 32", orgId, len(key)))
+		}
+	}
+	return keys, nil
+}
+
+// EncryptingBucketClient encrypts the parts of organizations with a key using AES-GCM before they are uploaded,
+// and decrypts them when they are read. Objects of other organizations are passed through unchanged. Objects of
+// organizations with a key that are not encrypted are refused unless Migrate is set. Signed links are not
+// supported for organizations with a key, since the bucket only holds the ciphertext
+type EncryptingBucketClient struct {
+	Client BlobClient
+
+	// AES-256 key by organization id
+	Keys map[string][]byte
+
+	// Migrate allows reading objects written before the key was added. They are encrypted when they are read
+	Migrate bool
+}
+
+func NewEncryptingBucketClient(client BlobClient, keys map[string][]byte) *EncryptingBucketClient {
+	return &EncryptingBucketClient{Client: client, Keys: keys}
+}
+
+// orgCipher returns the cipher of the organization owning object, or nil when the organization has no key
+func (e *EncryptingBucketClient) orgCipher(object string) (cipher.AEAD, string, error) {
+	orgId, _, _ := strings.Cut(object, "/")
+	key, ok := e.Keys[orgId]
+	if !ok {
+		return nil, orgId, nil
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, orgId, err
+	}
+	aead, err := cipher.NewGCM(block)
+	return aead, orgId, err
+}
+
+func (e *EncryptingBucketClient) Upload(ctx context.Context, bucket, object string, data []byte) error {
+	aead, _, err := e.orgCipher(object)
+	if err != nil || aead == nil {
+		return errors.Join(err, e.Client.Upload(ctx, bucket, object, data))
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	content := make([]byte, 0, len(data)+encryptionOverhead)
+	content = append(content, encryptionMagic...)
+	content = append(content, nonce...)
+	content = aead.Seal(content, nonce, data, []byte(object))
+	return e.Client.Upload(ctx, bucket, object, content)
+}
+
+func (e *EncryptingBucketClient) GetObject(ctx context.Context, bucket, objName string) (io.ReadCloser, error) {
+	reader, err := e.Client.GetObject(ctx, bucket, objName)
+	if err != nil {
+		return reader, err
+	}
+
+	buffered := bufio.NewReader(reader)
+	head, _ := buffered.Peek(len(encryptionMagic))
+	orgId, _, _ := strings.Cut(objName, "/")
+	if _, ok := e.Keys[orgId]; !ok && !bytes.Equal(head, encryptionMagic) {
+		return &bufferedReadCloser{Reader: buffered, Closer: reader}, nil
+	}
+
+	content, err := io.ReadAll(buffered)
+	if err := errors.Join(err, reader.Close()); err != nil {
+		return nil, err
+	}
+	plaintext, migrated, err := e.decrypt(objName, content)
+	if err != nil {
+		return nil, err
+	}
+	if migrated {
+		if err := e.Upload(ctx, bucket, objName, plaintext); err != nil {
+			slog.WarnContext(ctx, "Could not encrypt object during migration", "object", objName, "error", err)
+		}
+	}
+	return io.NopCloser(bytes.NewReader(plaintext)), nil
+}
+
+// decrypt returns the plaintext of an object of an organization with a key, and whether the object must be
+// encrypted. Objects that are not encrypted are only read when migrating
+func (e *EncryptingBucketClient) decrypt(object string, content []byte) ([]byte, bool, error) {
+	aead, orgId, err := e.orgCipher(object)
+	if err != nil {
+		return nil, false, err
+	}
+	if aead == nil {
+		return nil, false, errors.Join(ErrDecryptionFailed, fmt.Errorf("no key for organization %s", orgId))
+	}
+
+	if !bytes.HasPrefix(content, encryptionMagic) {
+		if !e.Migrate {
+			return nil, false, errors.Join(ErrDecryptionFailed, fmt.Errorf("object %s is not encrypted, enable migration to read it", object))
+		}
+		return content, true, nil
+	}
+
+	sealed := content[len(encryptionMagic):]
+	if len(sealed) < aead.NonceSize() {
+		return nil, false, errors.Join(ErrDecryptionFailed, fmt.Errorf("object %s is truncated", object))
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(object))
+	if err != nil {
+		return nil, false, errors.Join(ErrDecryptionFailed, fmt.Errorf("object %s: %w", object, err))
+	}
+	return plaintext, false, nil
+}
+
+// GetObjects reports the size of the plaintext for encrypted objects. The checksum of the ciphertext is removed,
+// such that it is computed from the content when needed
+func (e *EncryptingBucketClient) GetObjects(ctx context.Context, bucket string, query *storage.Query) ObjectLister {
+	return &encryptedObjectLister{ctx: ctx, client: e, bucket: bucket, lister: e.Client.GetObjects(ctx, bucket, query)}
+}
+
+func (e *EncryptingBucketClient) SetStorageClass(ctx context.Context, bucket, object, class string) error {
+	return e.Client.SetStorageClass(ctx, bucket, object, class)
+}
+
+func (e *EncryptingBucketClient) Delete(ctx context.Context, bucket, object string) error {
+	return e.Client.Delete(ctx, bucket, object)
+}
+
+func (e *EncryptingBucketClient) SignedURL(bucket, object, filename string, expires time.Time) (string, error) {
+	signer, ok := e.Client.(ObjectURLSigner)
+	orgId, _, _ := strings.Cut(object, "/")
+	if _, encrypted := e.Keys[orgId]; encrypted || !ok {
+		return "", ErrSignedURLUnsupported
+	}
+	return signer.SignedURL(bucket, object, filename, expires)
+}
+
+// rangeReader is the wrapped client when it can read ranges of objects. Ranges of encrypted objects can not
+// be read, since the whole ciphertext is needed to authenticate the content
+func (e *EncryptingBucketClient) rangeReader(object string) (ObjectRangeReader, error) {
+	ranger, ok := e.Client.(ObjectRangeReader)
+	orgId, _, _ := strings.Cut(object, "/")
+	if _, encrypted := e.Keys[orgId]; encrypted || !ok {
+		return nil, ErrRangeReadUnsupported
+	}
+	return ranger, nil
+}
+
+func (e *EncryptingBucketClient) ObjectSize(ctx context.Context, bucket, object string) (int64, error) {
+	ranger, err := e.rangeReader(object)
+	if err != nil {
+		return 0, err
+	}
+	return ranger.ObjectSize(ctx, bucket, object)
+}
+
+func (e *EncryptingBucketClient) NewRangeReader(ctx context.Context, bucket, object string, offset, length int64) (io.ReadCloser, error) {
+	ranger, err := e.rangeReader(object)
+	if err != nil {
+		return nil, err
+	}
+	return ranger.NewRangeReader(ctx, bucket, object, offset, length)
+}
+
+func (e *EncryptingBucketClient) Degraded() bool {
+	reporter, ok := e.Client.(HealthReporter)
+	return ok && reporter.Degraded()
+}
+
+// isEncrypted reads the start of an object of an organization with a key
+func (e *EncryptingBucketClient) isEncrypted(ctx context.Context, bucket, object string) (bool, error) {
+	reader, err := e.Client.GetObject(ctx, bucket, object)
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+
+	head := make([]byte, len(encryptionMagic))
+	if _, err := io.ReadFull(reader, head); err != nil {
+		return false, nil
+	}
+	return bytes.Equal(head, encryptionMagic), nil
+}
+
+type encryptedObjectLister struct {
+	ctx    context.Context
+	client *EncryptingBucketClient
+	bucket string
+	lister ObjectLister
+}
+
+func (e *encryptedObjectLister) Next() (*storage.ObjectAttrs, error) {
+	attrs, err := e.lister.Next()
+	if err != nil {
+		return attrs, err
+	}
+	orgId, _, _ := strings.Cut(attrs.Name, "/")
+	if _, ok := e.client.Keys[orgId]; !ok || attrs.Size < encryptionOverhead {
+		return attrs, nil
+	}
+
+	encrypted, err := e.client.isEncrypted(e.ctx, e.bucket, attrs.Name)
+	if err != nil || !encrypted {
+		return attrs, err
+	}
+	resolved := *attrs
+	resolved.Size = attrs.Size - encryptionOverhead
+	resolved.MD5 = nil
+	return &resolved, nil
+}
+
+// withEncryption wraps client in an EncryptingBucketClient when keys are configured. Wrapped keys are unwrapped
+// once, such that the key management service is only needed at startup
+func withEncryption(ctx context.Context, config *EncryptionConfig, client BlobClient) (BlobClient, error) {
+	if !config.Enabled() {
+		return client, nil
+	}
+
+	var unwrapper KeyUnwrapper
+	if len(config.WrappedKeys) > 0 {
+		kmsClient, err := kms.NewKeyManagementClient(ctx)
+		if err != nil {
+			return client, fmt.Errorf("could not create key management client: %w", err)
+		}
+		defer kmsClient.Close()
+		unwrapper = &GoogleKMSUnwrapper{Client: kmsClient}
+	}
+	keys, err := LoadEncryptionKeys(ctx, config, unwrapper)
+	if err != nil {
+		return client, err
+	}
+	encrypting := NewEncryptingBucketClient(client, keys)
+	encrypting.Migrate = config.Migrate
+	return encrypting, nil
+}
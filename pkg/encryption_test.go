@@ -0,0 +1,163 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+var testEncryptionKey = bytes.Repeat([]byte{7}, 32)
+
+func TestEncryptingBucketClientRoundTrip(t *testing.T) {
+	files := &FileBucketClient{Directory: t.TempDir()}
+	client := NewEncryptingBucketClient(files, map[string][]byte{"secret": testEncryptionKey})
+	ctx := context.Background()
+
+	testutils.AssertNil(t, client.Upload(ctx, "bucket", "secret/polka/Horn.pdf", []byte("horn part")))
+	testutils.AssertNil(t, client.Upload(ctx, "bucket", "open/polka/Horn.pdf", []byte("horn part")))
+
+	// Only the organization with a key is encrypted at rest
+	testutils.AssertEqual(t, strings.Contains(readObject(t, files, "secret/polka/Horn.pdf"), "horn part"), false)
+	testutils.AssertEqual(t, readObject(t, files, "open/polka/Horn.pdf"), "horn part")
+
+	testutils.AssertEqual(t, readObject(t, client, "secret/polka/Horn.pdf"), "horn part")
+	testutils.AssertEqual(t, readObject(t, client, "open/polka/Horn.pdf"), "horn part")
+
+	attrs, err := client.GetObjects(ctx, "bucket", &storage.Query{Prefix: "secret/"}).Next()
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, attrs.Size, int64(len("horn part")))
+	testutils.AssertEqual(t, len(attrs.MD5), 0)
+}
+
+func TestEncryptingBucketClientRefusesPlaintextObjects(t *testing.T) {
+	files := &FileBucketClient{Directory: t.TempDir()}
+	testutils.AssertNil(t, files.Upload(context.Background(), "bucket", "secret/polka/Horn.pdf", []byte("written before the key was added")))
+	client := NewEncryptingBucketClient(files, map[string][]byte{"secret": testEncryptionKey})
+
+	_, err := client.GetObject(context.Background(), "bucket", "secret/polka/Horn.pdf")
+	testutils.AssertEqual(t, errors.Is(err, ErrDecryptionFailed), true)
+	attrs, err := client.GetObjects(context.Background(), "bucket", &storage.Query{Prefix: "secret/"}).Next()
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, attrs.Size, int64(len("written before the key was added")))
+}
+
+func TestEncryptingBucketClientMigratesPlaintextObjects(t *testing.T) {
+	files := &FileBucketClient{Directory: t.TempDir()}
+	testutils.AssertNil(t, files.Upload(context.Background(), "bucket", "secret/polka/Horn.pdf", []byte("written before the key was added")))
+	client := NewEncryptingBucketClient(files, map[string][]byte{"secret": testEncryptionKey})
+	client.Migrate = true
+
+	testutils.AssertEqual(t, readObject(t, client, "secret/polka/Horn.pdf"), "written before the key was added")
+
+	// The object is encrypted when it is read, and can be read after the migration
+	testutils.AssertEqual(t, strings.Contains(readObject(t, files, "secret/polka/Horn.pdf"), "written before"), false)
+	client.Migrate = false
+	testutils.AssertEqual(t, readObject(t, client, "secret/polka/Horn.pdf"), "written before the key was added")
+}
+
+func TestEncryptingBucketClientBindsObjectName(t *testing.T) {
+	files := &FileBucketClient{Directory: t.TempDir()}
+	client := NewEncryptingBucketClient(files, map[string][]byte{"secret": testEncryptionKey})
+	ctx := context.Background()
+	testutils.AssertNil(t, client.Upload(ctx, "bucket", "secret/polka/Horn.pdf", []byte("horn")))
+
+	// A part copied to the name of another part of the organization is not accepted
+	testutils.AssertNil(t, files.Upload(ctx, "bucket", "secret/polka/Tuba.pdf", []byte(readObject(t, files, "secret/polka/Horn.pdf"))))
+	_, err := client.GetObject(ctx, "bucket", "secret/polka/Tuba.pdf")
+	testutils.AssertEqual(t, errors.Is(err, ErrDecryptionFailed), true)
+}
+
+func TestEncryptingBucketClientWrongKey(t *testing.T) {
+	files := &FileBucketClient{Directory: t.TempDir()}
+	ctx := context.Background()
+	testutils.AssertNil(t, NewEncryptingBucketClient(files, map[string][]byte{"secret": testEncryptionKey}).Upload(ctx, "bucket", "secret/polka/Horn.pdf", []byte("horn")))
+
+	for _, keys := range []map[string][]byte{{"secret": bytes.Repeat([]byte{8}, 32)}, {}} {
+		_, err := NewEncryptingBucketClient(files, keys).GetObject(ctx, "bucket", "secret/polka/Horn.pdf")
+		testutils.AssertEqual(t, errors.Is(err, ErrDecryptionFailed), true)
+	}
+}
+
+func TestEncryptingBucketClientDoesNotSignEncryptedParts(t *testing.T) {
+	client := NewEncryptingBucketClient(&recordingSigner{FileBucketClient: &FileBucketClient{Directory: t.TempDir()}}, map[string][]byte{"secret": testEncryptionKey})
+
+	_, err := client.SignedURL("bucket", "secret/polka/Horn.pdf", "Horn.pdf", time.Now().Add(time.Hour))
+	testutils.AssertEqual(t, errors.Is(err, ErrSignedURLUnsupported), true)
+
+	link, err := client.SignedURL("bucket", "open/polka/Horn.pdf", "Horn.pdf", time.Now().Add(time.Hour))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, link, "https://bucket.example.com/open/polka/Horn.pdf")
+}
+
+func TestEncryptingBucketClientDoesNotReadRangesOfEncryptedParts(t *testing.T) {
+	client := NewEncryptingBucketClient(&FileBucketClient{Directory: t.TempDir()}, map[string][]byte{"secret": testEncryptionKey})
+	ctx := context.Background()
+	testutils.AssertNil(t, client.Upload(ctx, "bucket", "open/polka/Horn.pdf", []byte("horn")))
+
+	_, err := client.NewRangeReader(ctx, "bucket", "secret/polka/Horn.pdf", 0, -1)
+	testutils.AssertEqual(t, errors.Is(err, ErrRangeReadUnsupported), true)
+
+	reader, err := client.NewRangeReader(ctx, "bucket", "open/polka/Horn.pdf", 1, -1)
+	testutils.AssertNil(t, err)
+	content, err := io.ReadAll(reader)
+	testutils.AssertNil(t, errors.Join(err, reader.Close()))
+	testutils.AssertEqual(t, string(content), "orn")
+}
+
+type fakeUnwrapper struct {
+	keyName string
+}
+
+func (f *fakeUnwrapper) Unwrap(ctx context.Context, keyName string, wrapped []byte) ([]byte, error) {
+	f.keyName = keyName
+	return bytes.TrimPrefix(wrapped, []byte("wrapped:")), nil
+}
+
+func TestLoadEncryptionKeys(t *testing.T) {
+	unwrapper := &fakeUnwrapper{}
+	config := EncryptionConfig{
+		Keys:        map[string]string{"org1": base64.StdEncoding.EncodeToString(testEncryptionKey)},
+		KmsKey:      "projects/p/locations/l/keyRings/r/cryptoKeys/k",
+		WrappedKeys: map[string]string{"org2": base64.StdEncoding.EncodeToString(append([]byte("wrapped:"), testEncryptionKey...))},
+	}
+	keys, err := LoadEncryptionKeys(context.Background(), &config, unwrapper)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(keys), 2)
+	testutils.AssertEqual(t, bytes.Equal(keys["org2"], testEncryptionKey), true)
+	testutils.AssertEqual(t, unwrapper.keyName, config.KmsKey)
+
+	for _, keys := range []map[string]string{{"org": "not base64!"}, {"org": base64.StdEncoding.EncodeToString([]byte("short"))}} {
+		_, err := LoadEncryptionKeys(context.Background(), &EncryptionConfig{Keys: keys}, nil)
+		testutils.AssertEqual(t, errors.Is(err, ErrInvalidEncryptionKey), true)
+	}
+}
+
+func TestGoogleStoreWithEncryptionAndDedup(t *testing.T) {
+	files := &FileBucketClient{Directory: t.TempDir()}
+	client := NewEncryptingBucketClient(NewDedupBucketClient(files), map[string][]byte{"org": testEncryptionKey})
+	store := GoogleStore{FsClient: NewLocalFirestoreClient(), BucketClient: client, Config: &GoogleConfig{Bucket: "bucket"}}
+	ctx := context.Background()
+	meta := MetaData{Title: "Polka"}
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, manifestParts))
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, manifestParts))
+
+	manifest, err := store.ResourceManifest(ctx, "org", meta.ResourceId())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(manifest.Files), 2)
+	testutils.AssertEqual(t, manifest.Files[0].Size, int64(len(manifest.Files[0].Name)))
+
+	numParts := 0
+	for name, content := range store.Resource(ctx, "org", meta.ResourceId()) {
+		testutils.AssertEqual(t, string(content), name)
+		numParts++
+	}
+	testutils.AssertEqual(t, numParts, 2)
+}
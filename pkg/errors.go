@@ -1,6 +1,14 @@
 package pkg
 
-import "errors"
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
 
 var ErrResourceNotFound = errors.New("resource not found")
 var ErrResourceMetadataNotFound = errors.New("resource metadata not found")
@@ -8,3 +16,215 @@ var ErrProjectNotFound = errors.New("project not found")
 var ErrUserNotFound = errors.New("user not found")
 var ErrOrganizationNotFound = errors.New("organization not found")
 var ErrSubscriptionNotFound = errors.New("subscription not found")
+var ErrResourceProtected = errors.New("resource is protected")
+var ErrFixtureNotFound = errors.New("fixture not found")
+var ErrInvalidBranding = errors.New("invalid branding")
+var ErrInvalidDomain = errors.New("invalid domain")
+var ErrDomainInUse = errors.New("domain is used by another organization")
+var ErrInvalidMetaDataPatch = errors.New("invalid metadata patch")
+var ErrInvalidBulkRequest = errors.New("invalid bulk request")
+var ErrStoreUnavailable = errors.New("store is temporarily unavailable")
+var ErrCircuitOpen = errors.New("backend is degraded, request not attempted")
+var ErrResourceNotDeleted = errors.New("resource is not in the trash")
+var ErrVersionNotFound = errors.New("version not found")
+var ErrUploadNotFound = errors.New("upload not found")
+var ErrUploadOffsetMismatch = errors.New("upload offset does not match the received bytes")
+var ErrUploadInProgress = errors.New("another chunk of the upload is being received")
+var ErrUploadTooLarge = errors.New("upload is larger than the announced length")
+var ErrAnnouncementNotFound = errors.New("announcement not found")
+var ErrInvalidAnnouncement = errors.New("invalid announcement")
+var ErrSignedURLUnsupported = errors.New("bucket client can not sign urls")
+var ErrRangeReadUnsupported = errors.New("bucket client can not read ranges")
+var ErrNotOrphaned = errors.New("resource is not orphaned")
+var ErrInvalidEncryptionKey = errors.New("invalid encryption key")
+var ErrDecryptionFailed = errors.New("could not decrypt object")
+var ErrLogExportNotFound = errors.New("log export not found")
+var ErrInvalidLogExport = errors.New("invalid log export")
+var ErrOIDCDiscovery = errors.New("could not discover OpenID Connect provider")
+var ErrProjectTemplateNotFound = errors.New("project template not found")
+var ErrInvalidProjectTemplate = errors.New("invalid project template")
+var ErrProblemReportNotFound = errors.New("problem report not found")
+var ErrInvalidProblemReport = errors.New("invalid problem report")
+var ErrPasskeyNotFound = errors.New("passkey not found")
+var ErrInvalidPasskey = errors.New("invalid passkey")
+var ErrApiTokenNotFound = errors.New("api token not found")
+var ErrInvalidApiToken = errors.New("invalid api token")
+var ErrOnboardingNotFound = errors.New("onboarding not found")
+var ErrInvalidOnboardingStep = errors.New("invalid onboarding step")
+var ErrUserSessionNotFound = errors.New("session not found")
+var ErrInvalidUserSession = errors.New("invalid session")
+var ErrInvalidHint = errors.New("invalid hint")
+var ErrInvalidLayout = errors.New("invalid layout")
+var ErrArchiveNotFound = errors.New("archive not found")
+var ErrInvitationNotFound = errors.New("invitation not found")
+var ErrInvalidInvitation = errors.New("invalid invitation")
+var ErrInvitationNotPending = errors.New("invitation is accepted or has expired")
+var ErrInvitationEmailMismatch = errors.New("invitation was sent to another email")
+var ErrInviteLinkNotFound = errors.New("invite link not found")
+var ErrInvalidInviteLink = errors.New("invalid invite link")
+var ErrInviteLinkRevoked = errors.New("invite link is revoked")
+var ErrCorrectionNotFound = errors.New("correction not found")
+var ErrInvalidCorrection = errors.New("invalid correction")
+var ErrCorrectionDecided = errors.New("correction is already approved or rejected")
+var ErrOverloaded = errors.New("too many requests in progress")
+var ErrInvalidScimRequest = errors.New("invalid scim request")
+var ErrScimUserExists = errors.New("user is already provisioned")
+var ErrScimUserInvited = errors.New("an account with the email exists and was invited to the organization")
+var ErrScimGroupNotFound = errors.New("scim group not found")
+var ErrInvalidPageEdit = errors.New("invalid page edit")
+var ErrInvalidCombinedSubmit = errors.New("invalid combined submit")
+var ErrResourceExists = errors.New("resource already exists")
+var ErrInvalidPartName = errors.New("invalid part name")
+var ErrPartExists = errors.New("part already exists")
+var ErrInvalidCursor = errors.New("invalid cursor")
+var ErrInvalidSort = errors.New("invalid sort")
+var ErrGuestGrantNotFound = errors.New("guest access not found")
+var ErrInvalidGuestGrant = errors.New("invalid guest access")
+var ErrGuestGrantInactive = errors.New("guest access is revoked or has expired")
+var ErrInvalidProjectName = errors.New("invalid project name")
+var ErrInvalidProjectMerge = errors.New("invalid project merge")
+var ErrInvalidProjectOrder = errors.New("invalid project order")
+var ErrProjectExists = errors.New("project already exists")
+var ErrInvalidLibraryImport = errors.New("invalid library import")
+var ErrWebhookNotFound = errors.New("webhook not found")
+var ErrInvalidWebhook = errors.New("invalid webhook")
+var ErrSubmitProgressNotFound = errors.New("submit progress not found")
+var ErrSubmitStarted = errors.New("a submit with the upload id has already started")
+var ErrInvalidObjectName = errors.New("invalid object name")
+
+// transientCodes are the gRPC codes where the request may succeed if attempted again later
+var transientCodes = []codes.Code{codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted}
+
+// transientHTTPCodes are the HTTP status codes of the REST based clients where the request may succeed if
+// attempted again later
+var transientHTTPCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+var notFoundErrors = []error{
+	ErrResourceNotFound,
+	ErrResourceMetadataNotFound,
+	ErrProjectNotFound,
+	ErrUserNotFound,
+	ErrOrganizationNotFound,
+	ErrSubscriptionNotFound,
+	ErrFileNotFound,
+	ErrFileNotInZipArchive,
+	ErrVersionNotFound,
+	ErrUploadNotFound,
+	ErrAnnouncementNotFound,
+	ErrLogExportNotFound,
+	ErrProjectTemplateNotFound,
+	ErrProblemReportNotFound,
+	ErrPasskeyNotFound,
+	ErrApiTokenNotFound,
+	ErrOnboardingNotFound,
+	ErrUserSessionNotFound,
+	ErrArchiveNotFound,
+	ErrInvitationNotFound,
+	ErrInviteLinkNotFound,
+	ErrCorrectionNotFound,
+	ErrScimGroupNotFound,
+	ErrGuestGrantNotFound,
+	ErrWebhookNotFound,
+	ErrSubmitProgressNotFound,
+}
+
+var invalidInputErrors = []error{
+	ErrInvalidBranding,
+	ErrInvalidDomain,
+	ErrInvalidMetaDataPatch,
+	ErrInvalidBulkRequest,
+	ErrInvalidAnnouncement,
+	ErrInvalidLogExport,
+	ErrInvalidProjectTemplate,
+	ErrInvalidProblemReport,
+	ErrInvalidPasskey,
+	ErrInvalidApiToken,
+	ErrInvalidOnboardingStep,
+	ErrInvalidUserSession,
+	ErrInvalidHint,
+	ErrInvalidLayout,
+	ErrInvalidInvitation,
+	ErrInvalidInviteLink,
+	ErrInvalidCorrection,
+	ErrInvalidScimRequest,
+	ErrInvalidPageEdit,
+	ErrInvalidCombinedSubmit,
+	ErrInvalidPartName,
+	ErrInvalidCursor,
+	ErrInvalidSort,
+	ErrInvalidGuestGrant,
+	ErrInvalidProjectName,
+	ErrInvalidProjectMerge,
+	ErrInvalidProjectOrder,
+	ErrInvalidLibraryImport,
+	ErrInvalidWebhook,
+	ErrInvalidObjectName,
+}
+
+var conflictErrors = []error{
+	ErrResourceProtected,
+	ErrDomainInUse,
+	ErrResourceNotDeleted,
+	ErrUploadOffsetMismatch,
+	ErrUploadInProgress,
+	ErrSubmitStarted,
+	ErrNotOrphaned,
+	ErrInvitationNotPending,
+	ErrCorrectionDecided,
+	ErrScimUserExists,
+	ErrScimUserInvited,
+	ErrResourceExists,
+	ErrPartExists,
+	ErrProjectExists,
+}
+
+func isAnyOf(err error, targets []error) bool {
+	for _, target := range targets {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsNotFound reports whether err means that the requested item does not exist
+func IsNotFound(err error) bool {
+	return isAnyOf(err, notFoundErrors)
+}
+
+// IsInvalidInput reports whether err was caused by invalid data passed to the store
+func IsInvalidInput(err error) bool {
+	return isAnyOf(err, invalidInputErrors)
+}
+
+// IsConflict reports whether err was caused by the current state of the item, e.g. a protected resource
+func IsConflict(err error) bool {
+	return isAnyOf(err, conflictErrors)
+}
+
+// classifyStoreErr joins errors from the backing services with the errors of this package such that callers
+// can use errors.Is. Missing documents and objects are reported as notFound
+func classifyStoreErr(err error, notFound error) error {
+	if err == nil {
+		return nil
+	}
+
+	code := status.Code(err)
+	if code == codes.NotFound {
+		return errors.Join(notFound, err)
+	}
+	if slices.Contains(transientCodes, code) || isAzureUnavailable(err) || isGoogleAPIUnavailable(err) {
+		return errors.Join(ErrStoreUnavailable, err)
+	}
+
+	if errors.Is(err, storage.ErrObjectNotExist) || isNoSuchKey(err) || isAzureNotFound(err) {
+		return errors.Join(notFound, err)
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/davidkleiven/caesura/testutils"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorCategories(t *testing.T) {
+	for _, test := range []struct {
+		err          error
+		notFound     bool
+		conflict     bool
+		invalidInput bool
+	}{
+		{err: errors.Join(ErrUserNotFound, errors.New("user-id")), notFound: true},
+		{err: fmt.Errorf("wrapped: %w", ErrFileNotInZipArchive), notFound: true},
+		{err: ErrResourceProtected, conflict: true},
+		{err: ErrDomainInUse, conflict: true},
+		{err: errors.Join(ErrInvalidBranding, errors.New("bad color")), invalidInput: true},
+		{err: errors.New("something else")},
+		{err: nil},
+	} {
+		t.Run(fmt.Sprintf("%v", test.err), func(t *testing.T) {
+			testutils.AssertEqual(t, IsNotFound(test.err), test.notFound)
+			testutils.AssertEqual(t, IsConflict(test.err), test.conflict)
+			testutils.AssertEqual(t, IsInvalidInput(test.err), test.invalidInput)
+		})
+	}
+}
+
+func TestClassifyStoreErr(t *testing.T) {
+	other := errors.New("permission denied")
+	forbidden := &googleapi.Error{Code: 403}
+	for _, test := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"grpc not found", status.Error(codes.NotFound, "missing"), ErrProjectNotFound},
+		{"bucket object missing", fmt.Errorf("get: %w", storage.ErrObjectNotExist), ErrProjectNotFound},
+		{"s3 no such key", &types.NoSuchKey{}, ErrProjectNotFound},
+		{"azure blob not found", &azcore.ResponseError{ErrorCode: string(bloberror.BlobNotFound), StatusCode: 404}, ErrProjectNotFound},
+		{"azure throttled", &azcore.ResponseError{StatusCode: 503}, ErrStoreUnavailable},
+		{"gcs unavailable", &googleapi.Error{Code: 503}, ErrStoreUnavailable},
+		{"gcs forbidden", forbidden, forbidden},
+		{"grpc unavailable", status.Error(codes.Unavailable, "down"), ErrStoreUnavailable},
+		{"grpc deadline", status.Error(codes.DeadlineExceeded, "slow"), ErrStoreUnavailable},
+		{"other", other, other},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := classifyStoreErr(test.err, ErrProjectNotFound)
+			testutils.AssertEqual(t, errors.Is(err, test.want), true)
+			testutils.AssertEqual(t, errors.Is(err, test.err), true)
+		})
+	}
+
+	testutils.AssertNil(t, classifyStoreErr(nil, ErrProjectNotFound))
+}
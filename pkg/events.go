@@ -0,0 +1,103 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type EventKind string
+
+const (
+	EventResourceUploaded    EventKind = "resource-uploaded"
+	EventProjectUpdated      EventKind = "project-updated"
+	EventUserJoined          EventKind = "user-joined"
+	EventUserInvited         EventKind = "user-invited"
+	EventSubscriptionChanged EventKind = "subscription-changed"
+)
+
+// Event tells that something has happened in an organization. Side effects, such as logging and cache
+// invalidation, subscribe to the events instead of being done by the handler causing them
+type Event struct {
+	Kind   EventKind
+	OrgId  string
+	UserId string
+
+	// TargetId is the resource, project, user or customer the event concerns
+	TargetId string
+	At       time.Time
+}
+
+func NewEvent(kind EventKind, orgId, userId, targetId string) Event {
+	return Event{Kind: kind, OrgId: orgId, UserId: userId, TargetId: targetId, At: time.Now()}
+}
+
+type EventHandler func(ctx context.Context, event Event)
+
+type EventPublisher interface {
+	Publish(ctx context.Context, event Event)
+}
+
+// EventBus passes published events to the handlers subscribing to them. The bus is internal to the instance,
+// events are not shared with other instances
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers map[EventKind][]EventHandler
+	all      []EventHandler
+	running  sync.WaitGroup
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{handlers: make(map[EventKind][]EventHandler)}
+}
+
+// Subscribe calls handler for events of the given kinds, or for all events when no kinds are given
+func (b *EventBus) Subscribe(handler EventHandler, kinds ...EventKind) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(kinds) == 0 {
+		b.all = append(b.all, handler)
+		return
+	}
+	for _, kind := range kinds {
+		b.handlers[kind] = append(b.handlers[kind], handler)
+	}
+}
+
+// Publish runs the handlers of the event in the background, such that slow handlers do not delay the response.
+// The handlers get a context that is not cancelled when the request finishes. A handler that panics does not
+// stop the other handlers
+func (b *EventBus) Publish(ctx context.Context, event Event) {
+	b.mu.RLock()
+	handlers := append(append([]EventHandler{}, b.all...), b.handlers[event.Kind]...)
+	b.mu.RUnlock()
+
+	ctx = context.WithoutCancel(ctx)
+	for _, handler := range handlers {
+		b.running.Add(1)
+		go func() {
+			defer b.running.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					slog.ErrorContext(ctx, "Event handler panicked", "kind", event.Kind, "error", fmt.Sprint(r))
+				}
+			}()
+			handler(ctx, event)
+		}()
+	}
+}
+
+// Wait returns when the handlers of all published events have finished
+func (b *EventBus) Wait() {
+	b.running.Wait()
+}
+
+// LogEvent writes the event to the log, such that the log holds a trail of what happened in the organization
+func LogEvent(ctx context.Context, event Event) {
+	slog.InfoContext(
+		ctx, "Event",
+		slog.Group("event", "kind", event.Kind, "orgId", event.OrgId, "userId", event.UserId, "targetId", event.TargetId),
+	)
+}
@@ -0,0 +1,55 @@
+package pkg
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestEventBusSubscribe(t *testing.T) {
+	bus := NewEventBus()
+
+	var (
+		mu       sync.Mutex
+		all      []EventKind
+		projects []string
+	)
+	bus.Subscribe(func(ctx context.Context, event Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		all = append(all, event.Kind)
+	})
+	bus.Subscribe(func(ctx context.Context, event Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		projects = append(projects, event.TargetId)
+	}, EventProjectUpdated)
+	bus.Subscribe(func(ctx context.Context, event Event) {
+		panic("handler failed")
+	}, EventProjectUpdated)
+
+	bus.Publish(context.Background(), NewEvent(EventResourceUploaded, "org", "user", "resource"))
+	bus.Publish(context.Background(), NewEvent(EventProjectUpdated, "org", "user", "project"))
+	bus.Wait()
+
+	slices.Sort(all)
+	testutils.AssertEqual(t, slices.Equal(all, []EventKind{EventProjectUpdated, EventResourceUploaded}), true)
+	testutils.AssertEqual(t, slices.Equal(projects, []string{"project"}), true)
+}
+
+func TestEventHandlerContextNotCancelled(t *testing.T) {
+	bus := NewEventBus()
+	var err error
+	bus.Subscribe(func(ctx context.Context, event Event) {
+		err = ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bus.Publish(ctx, NewEvent(EventUserJoined, "org", "user", "user"))
+	bus.Wait()
+	testutils.AssertNil(t, err)
+}
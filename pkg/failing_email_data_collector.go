@@ -2,6 +2,7 @@ package pkg
 
 import (
 	"context"
+	"io"
 	"iter"
 )
 
@@ -26,6 +27,10 @@ func (f *FailingEmailDataCollector) Resource(ctx context.Context, orgId string,
 	return func(yield func(string, []byte) bool) {}
 }
 
+func (f *FailingEmailDataCollector) ResourceStream(ctx context.Context, orgId string, path string) iter.Seq2[string, io.Reader] {
+	return func(yield func(string, io.Reader) bool) {}
+}
+
 func (f *FailingEmailDataCollector) GetUsersInOrg(ctx context.Context, orgId string) ([]UserInfo, error) {
 	return f.Users, f.ErrUsersInOrg
 }
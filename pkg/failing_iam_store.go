@@ -13,6 +13,14 @@ type MockIAMStore struct {
 	ErrDeleteUserRole       error
 	ErrRegisterGroup        error
 	ErrRemoveGroup          error
+	ErrListOrganizations    error
+	ErrUpdateBranding       error
+	ErrOrganizationByDomain error
+	ErrUpdateDomain         error
+	ErrPermissionsVersion   error
+	ErrRequirePasskey       error
+	ErrOnboarding           error
+	ErrSaveOnboarding       error
 }
 
 func (m *MockIAMStore) RegisterUser(ctx context.Context, userInfo *UserInfo) error {
@@ -23,6 +31,10 @@ func (m *MockIAMStore) GetUserInfo(ctx context.Context, userId string) (*UserInf
 	return &UserInfo{Id: userId, Name: "Mock User"}, m.ErrGetUserInfo
 }
 
+func (m *MockIAMStore) PermissionsVersion(ctx context.Context, userId string) (int64, error) {
+	return 0, m.ErrPermissionsVersion
+}
+
 func (m *MockIAMStore) RegisterRole(ctx context.Context, userId string, organizationId string, role RoleKind) error {
 	return m.ErrRegisterRole
 }
@@ -43,6 +55,10 @@ func (m *MockIAMStore) GetUsersInOrg(ctx context.Context, orgId string) ([]UserI
 	return []UserInfo{}, m.ErrUserInOrg
 }
 
+func (m *MockIAMStore) UsersInOrgPage(ctx context.Context, orgId string, page PageRequest) (CursorPage[UserInfo], error) {
+	return CursorPage[UserInfo]{Items: []UserInfo{}}, m.ErrUserInOrg
+}
+
 func (m *MockIAMStore) DeleteRole(ctx context.Context, userId, orgId string) error {
 	return m.ErrDeleteUserRole
 }
@@ -54,3 +70,31 @@ func (m *MockIAMStore) RegisterGroup(ctx context.Context, userId, orgId, group s
 func (m *MockIAMStore) RemoveGroup(ctx context.Context, userId, orgId, group string) error {
 	return m.ErrRemoveGroup
 }
+
+func (m *MockIAMStore) ListOrganizations(ctx context.Context) ([]Organization, error) {
+	return []Organization{{Id: "mock-org", Name: "Mock Org"}}, m.ErrListOrganizations
+}
+
+func (m *MockIAMStore) UpdateBranding(ctx context.Context, orgId string, branding Branding) error {
+	return m.ErrUpdateBranding
+}
+
+func (m *MockIAMStore) OrganizationByDomain(ctx context.Context, domain string) (Organization, error) {
+	return Organization{Id: "mock-org", Name: "Mock Org", Domain: domain}, m.ErrOrganizationByDomain
+}
+
+func (m *MockIAMStore) UpdateDomain(ctx context.Context, orgId, domain string) error {
+	return m.ErrUpdateDomain
+}
+
+func (m *MockIAMStore) RequirePasskey(ctx context.Context, orgId string, required bool) error {
+	return m.ErrRequirePasskey
+}
+
+func (m *MockIAMStore) Onboarding(ctx context.Context, orgId string) (*Onboarding, error) {
+	return NewOnboarding(), m.ErrOnboarding
+}
+
+func (m *MockIAMStore) SaveOnboarding(ctx context.Context, orgId string, onboarding *Onboarding) error {
+	return m.ErrSaveOnboarding
+}
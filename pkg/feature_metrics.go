@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Feature string
+
+const (
+	FeatureUpload        Feature = "upload"
+	FeatureDownload      Feature = "download"
+	FeatureEmailSent     Feature = "email_sent"
+	FeatureProjectSubmit Feature = "project_submit"
+)
+
+// FeatureCount is the number of times a feature was used within an organization during one week.
+// Nothing about the individual user is stored
+type FeatureCount struct {
+	OrgId   string  `json:"orgId" firestore:"orgId"`
+	Week    string  `json:"week" firestore:"week"`
+	Feature Feature `json:"feature" firestore:"feature"`
+	Count   int     `json:"count" firestore:"count"`
+}
+
+func (f *FeatureCount) Id() string {
+	return f.OrgId + "-" + f.Week + "-" + string(f.Feature)
+}
+
+type FeatureCounter interface {
+	CountFeature(ctx context.Context, orgId string, feature Feature, at time.Time) error
+}
+
+type FeatureCountGetter interface {
+	FeatureCounts(ctx context.Context, since time.Time) ([]FeatureCount, error)
+}
+
+type FeatureMetricsStore interface {
+	FeatureCounter
+	FeatureCountGetter
+}
+
+// IsoWeek returns the ISO 8601 week of t formatted as 2025-W07
+func IsoWeek(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// SortFeatureCounts orders the counts with the most recent week first
+func SortFeatureCounts(counts []FeatureCount) {
+	slices.SortFunc(counts, func(a, b FeatureCount) int {
+		if c := strings.Compare(b.Week, a.Week); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.OrgId, b.OrgId); c != 0 {
+			return c
+		}
+		return strings.Compare(string(a.Feature), string(b.Feature))
+	})
+}
@@ -0,0 +1,40 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestIsoWeek(t *testing.T) {
+	testutils.AssertEqual(t, IsoWeek(time.Date(2025, 2, 12, 0, 0, 0, 0, time.UTC)), "2025-W07")
+	testutils.AssertEqual(t, IsoWeek(time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC)), "2025-W01")
+}
+
+func TestInMemoryFeatureCounts(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	now := time.Date(2025, 6, 11, 0, 0, 0, 0, time.UTC)
+	lastYear := now.AddDate(-1, 0, 0)
+
+	testutils.AssertNil(t, store.CountFeature(ctx, "org1", FeatureUpload, now))
+	testutils.AssertNil(t, store.CountFeature(ctx, "org1", FeatureUpload, now))
+	testutils.AssertNil(t, store.CountFeature(ctx, "org2", FeatureUpload, now))
+	testutils.AssertNil(t, store.CountFeature(ctx, "org1", FeatureDownload, now.AddDate(0, 0, -7)))
+	testutils.AssertNil(t, store.CountFeature(ctx, "org1", FeatureDownload, lastYear))
+
+	counts, err := store.FeatureCounts(ctx, now.AddDate(0, 0, -14))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(counts), 3)
+
+	want := []FeatureCount{
+		{OrgId: "org1", Week: "2025-W24", Feature: FeatureUpload, Count: 2},
+		{OrgId: "org2", Week: "2025-W24", Feature: FeatureUpload, Count: 1},
+		{OrgId: "org1", Week: "2025-W23", Feature: FeatureDownload, Count: 1},
+	}
+	for i, w := range want {
+		testutils.AssertEqual(t, counts[i], w)
+	}
+}
@@ -25,10 +25,28 @@ type FirestoreClient interface {
 	StoreDocument(ctx context.Context, dataset, orgId, itemId string, data any) error
 	Update(ctx context.Context, dataset, orgId, itemId string, update []firestore.Update) error
 	GetDocByPrefix(ctx context.Context, dataset, orgId, field, prefix string) iter.Seq[Document]
+
+	// GetDocPage yields at most limit documents ordered by their id, starting after the document with id after.
+	// An empty after starts at the first document
+	GetDocPage(ctx context.Context, dataset, orgId, after string, limit int) iter.Seq[Document]
 	GetDoc(ctx context.Context, dataset, orgId, itemId string) (Document, error)
 	DeleteDoc(ctx context.Context, dataset, collection, item string) error
 }
 
+// DocOrder orders documents by a field, and by document id among documents with the same value. An empty field
+// orders by document id only. After holds the values of the last document of the previous page, which is the
+// value of the field followed by the document id
+type DocOrder struct {
+	Field      string
+	Descending bool
+	After      []any
+}
+
+// OrderedDocPager is implemented by clients that can order pages by a field other than the document id
+type OrderedDocPager interface {
+	GetOrderedDocPage(ctx context.Context, dataset, orgId string, order DocOrder, limit int) iter.Seq[Document]
+}
+
 type Document interface {
 	DataTo(obj any) error
 }
@@ -68,6 +86,59 @@ func (g *GoogleFirestoreClient) GetDocByPrefix(ctx context.Context, dataset, org
 	}
 }
 
+func (g *GoogleFirestoreClient) GetDocPage(ctx context.Context, dataset, orgId, after string, limit int) iter.Seq[Document] {
+	query := g.client.Collection(g.environment).Doc(dataset).Collection(orgId).OrderBy(firestore.DocumentID, firestore.Asc)
+	if after != "" {
+		query = query.StartAfter(after)
+	}
+	docIter := query.Limit(limit).Documents(ctx)
+
+	return func(yield func(doc Document) bool) {
+		defer docIter.Stop()
+		for {
+			doc, err := docIter.Next()
+			if err != nil {
+				logOnErrorNotDone(err)
+				return
+			}
+			if !yield(doc) {
+				return
+			}
+		}
+	}
+}
+
+// GetOrderedDocPage only needs the single field indexes firestore creates by default, since there is no filter
+func (g *GoogleFirestoreClient) GetOrderedDocPage(ctx context.Context, dataset, orgId string, order DocOrder, limit int) iter.Seq[Document] {
+	direction := firestore.Asc
+	if order.Descending {
+		direction = firestore.Desc
+	}
+	query := g.client.Collection(g.environment).Doc(dataset).Collection(orgId).Query
+	if order.Field != "" {
+		query = query.OrderBy(order.Field, direction)
+	}
+	query = query.OrderBy(firestore.DocumentID, direction)
+	if len(order.After) > 0 {
+		query = query.StartAfter(order.After...)
+	}
+	docIter := query.Limit(limit).Documents(ctx)
+
+	return func(yield func(doc Document) bool) {
+		defer docIter.Stop()
+		for {
+			doc, err := docIter.Next()
+			if err != nil {
+				logOnErrorNotDone(err)
+				return
+			}
+			if !yield(doc) {
+				return
+			}
+		}
+	}
+}
+
 func (g *GoogleFirestoreClient) GetDoc(ctx context.Context, dataset, orgId, itemId string) (Document, error) {
 	return g.client.Collection(g.environment).Doc(dataset).Collection(orgId).Doc(itemId).Get(ctx)
 }
@@ -77,6 +148,81 @@ func (g *GoogleFirestoreClient) DeleteDoc(ctx context.Context, dataset, collecti
 	return err
 }
 
+func (g *GoogleFirestoreClient) RunTransaction(ctx context.Context, fn func(ctx context.Context, client FirestoreClient) error) error {
+	return g.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		return fn(ctx, &firestoreTxClient{client: g, tx: tx})
+	})
+}
+
+func (g *GoogleFirestoreClient) doc(dataset, orgId, itemId string) *firestore.DocumentRef {
+	return g.client.Collection(g.environment).Doc(dataset).Collection(orgId).Doc(itemId)
+}
+
+// firestoreTxClient reads and writes documents in a transaction. Writes are sent when the transaction commits
+type firestoreTxClient struct {
+	client *GoogleFirestoreClient
+	tx     *firestore.Transaction
+}
+
+func (f *firestoreTxClient) StoreDocument(ctx context.Context, dataset, orgId, itemId string, data any) error {
+	return f.tx.Set(f.client.doc(dataset, orgId, itemId), data)
+}
+
+func (f *firestoreTxClient) Update(ctx context.Context, dataset, orgId, itemId string, update []firestore.Update) error {
+	return f.tx.Update(f.client.doc(dataset, orgId, itemId), update)
+}
+
+func (f *firestoreTxClient) GetDocByPrefix(ctx context.Context, dataset, orgId, field, prefix string) iter.Seq[Document] {
+	query := f.client.client.Collection(f.client.environment).Doc(dataset).Collection(orgId).
+		Where(field, ">=", prefix).
+		Where(field, "<", prefix+maxUtf8)
+	docIter := f.tx.Documents(query)
+
+	return func(yield func(doc Document) bool) {
+		defer docIter.Stop()
+		for {
+			doc, err := docIter.Next()
+			if err != nil {
+				logOnErrorNotDone(err)
+				return
+			}
+			if !yield(doc) {
+				return
+			}
+		}
+	}
+}
+
+func (f *firestoreTxClient) GetDocPage(ctx context.Context, dataset, orgId, after string, limit int) iter.Seq[Document] {
+	query := f.client.client.Collection(f.client.environment).Doc(dataset).Collection(orgId).OrderBy(firestore.DocumentID, firestore.Asc)
+	if after != "" {
+		query = query.StartAfter(after)
+	}
+	docIter := f.tx.Documents(query.Limit(limit))
+
+	return func(yield func(doc Document) bool) {
+		defer docIter.Stop()
+		for {
+			doc, err := docIter.Next()
+			if err != nil {
+				logOnErrorNotDone(err)
+				return
+			}
+			if !yield(doc) {
+				return
+			}
+		}
+	}
+}
+
+func (f *firestoreTxClient) GetDoc(ctx context.Context, dataset, orgId, itemId string) (Document, error) {
+	return f.tx.Get(f.client.doc(dataset, orgId, itemId))
+}
+
+func (f *firestoreTxClient) DeleteDoc(ctx context.Context, dataset, collection, itemId string) error {
+	return f.tx.Delete(f.client.doc(dataset, collection, itemId))
+}
+
 func logOnErrorNotDone(err error) {
 	if !errors.Is(err, iterator.Done) {
 		slog.Error("Error occured when iterating over document", "error", err)
@@ -111,6 +257,36 @@ func (l *LocalFirestoreClient) DeleteDoc(ctx context.Context, dataset, collectio
 	return nil
 }
 
+// RunTransaction restores the documents when fn fails. Documents are copied before fn runs, since updates
+// modify them in place. Maps inside the documents are not copied
+func (l *LocalFirestoreClient) RunTransaction(ctx context.Context, fn func(ctx context.Context, client FirestoreClient) error) error {
+	l.mu.Lock()
+	snapshot := make(map[string]any, len(l.data))
+	for loc, item := range l.data {
+		snapshot[loc] = copyDocument(item)
+	}
+	l.mu.Unlock()
+
+	if err := fn(ctx, l); err != nil {
+		l.mu.Lock()
+		l.data = snapshot
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// copyDocument returns a shallow copy of the value a pointer points to, or the value itself for other types
+func copyDocument(item any) any {
+	value := reflect.ValueOf(item)
+	if value.Kind() != reflect.Pointer || value.IsNil() {
+		return item
+	}
+	copied := reflect.New(value.Elem().Type())
+	copied.Elem().Set(value.Elem())
+	return copied.Interface()
+}
+
 func NewLocalFirestoreClient() *LocalFirestoreClient {
 	return &LocalFirestoreClient{
 		data: make(map[string]any),
@@ -121,9 +297,38 @@ func NewLocalFirestoreClient() *LocalFirestoreClient {
 // it tries to update 'something' but not nessecarily the exact values
 func (l *LocalFirestoreClient) Update(ctx context.Context, dataset, orgId, itemId string, update []firestore.Update) error {
 	location := path.Join(dataset, orgId, itemId)
+	if _, ok := l.data[location]; !ok {
+		return status.Errorf(codes.NotFound, "Could not find %s", location)
+	}
 	for _, u := range update {
+		if len(u.FieldPath) == 2 && u.FieldPath[0] == "notes" {
+			item, ok := l.data[location].(*FirestoreProject)
+			if !ok {
+				return errors.New("could not convert to fire store project")
+			}
+			if item.Notes == nil {
+				item.Notes = make(map[string]string)
+			}
+
+			// Anything else than a string is treated as firestore.Delete
+			if note, ok := u.Value.(string); ok {
+				item.Notes[u.FieldPath[1]] = note
+			} else {
+				delete(item.Notes, u.FieldPath[1])
+			}
+			continue
+		}
+
 		switch u.Path {
 		case "status":
+			if item, ok := l.data[location].(*ProblemReport); ok {
+				val, ok := u.Value.(ProblemStatus)
+				if !ok {
+					return errors.New("could not convert status into ProblemStatus")
+				}
+				item.Status = val
+				continue
+			}
 			item, ok := l.data[location].(*FirestoreMetaData)
 			if !ok {
 				return errors.New("could not convert to FirestoreMetaData")
@@ -139,20 +344,66 @@ func (l *LocalFirestoreClient) Update(ctx context.Context, dataset, orgId, itemI
 			if !ok {
 				return errors.New("could not convert to fire store project")
 			}
+			if ids, ok := u.Value.([]string); ok {
+				item.ResourceIds = slices.Clone(ids)
+				continue
+			}
 			slog.Warn("LocalFirebase client always removes the last item")
 			item.ResourceIds = item.ResourceIds[:len(item.ResourceIds)-1]
 			l.data[location] = item
 		case "deleted":
+			value, ok := u.Value.(bool)
+			if !ok {
+				return errors.New("could not convert value to 'bool'")
+			}
+			switch item := l.data[location].(type) {
+			case *Organization:
+				item.Deleted = value
+			case *FirestoreMetaData:
+				item.Deleted = value
+			default:
+				return errors.New("could not convert item to organization or metadata")
+			}
+		case "branding":
+			item, ok := l.data[location].(*Organization)
+			if !ok {
+				return status.Errorf(codes.NotFound, "Could not find %s", location)
+			}
+			value, ok := u.Value.(Branding)
+			if !ok {
+				return errors.New("could not convert value to 'Branding'")
+			}
+			item.Branding = value
+		case "domain":
+			item, ok := l.data[location].(*Organization)
+			if !ok {
+				return status.Errorf(codes.NotFound, "Could not find %s", location)
+			}
+			value, ok := u.Value.(string)
+			if !ok {
+				return errors.New("could not convert value to 'string'")
+			}
+			item.Domain = value
+		case "requirePasskey":
 			item, ok := l.data[location].(*Organization)
 			if !ok {
-				return errors.New("could not convert item to organization")
+				return status.Errorf(codes.NotFound, "Could not find %s", location)
 			}
 			value, ok := u.Value.(bool)
 			if !ok {
 				return errors.New("could not convert value to 'bool'")
 			}
-			item.Deleted = value
-			l.data[location] = item
+			item.RequirePasskey = value
+		case "protected":
+			item, ok := l.data[location].(*FirestoreMetaData)
+			if !ok {
+				return status.Errorf(codes.NotFound, "Could not find %s", location)
+			}
+			value, ok := u.Value.(bool)
+			if !ok {
+				return errors.New("could not convert value to 'bool'")
+			}
+			item.Protected = value
 		case "groups":
 			item, ok := l.data[location].(UserOrganizationLink)
 			if !ok {
@@ -169,6 +420,26 @@ func (l *LocalFirestoreClient) Update(ctx context.Context, dataset, orgId, itemI
 				return fmt.Errorf("Unknown name %s", updateName)
 			}
 			l.data[location] = item
+		case "name", "email":
+			if user, ok := l.data[location].(User); ok && u.Path == "name" {
+				user.Name, _ = u.Value.(string)
+				l.data[location] = user
+				continue
+			}
+			item, ok := l.data[location].(UserOrganizationLink)
+			if !ok {
+				return status.Errorf(codes.NotFound, "Could not find %s", location)
+			}
+			value, ok := u.Value.(string)
+			if !ok {
+				return errors.New("could not convert value to 'string'")
+			}
+			if u.Path == "name" {
+				item.Name = value
+			} else {
+				item.Email = value
+			}
+			l.data[location] = item
 		case "role":
 			item, ok := l.data[location].(UserOrganizationLink)
 			if !ok {
@@ -185,6 +456,90 @@ func (l *LocalFirestoreClient) Update(ctx context.Context, dataset, orgId, itemI
 			item := l.data[location].(User)
 			item.Password = u.Value.(string)
 			l.data[location] = item
+		case "verified_email":
+			item, ok := l.data[location].(User)
+			if !ok {
+				return errors.New("could not convert to User")
+			}
+			val, ok := u.Value.(bool)
+			if !ok {
+				return errors.New("could not convert value to 'bool'")
+			}
+			item.VerifiedEmail = val
+			l.data[location] = item
+		case "storage_class":
+			item, ok := l.data[location].(*FirestoreMetaData)
+			if !ok {
+				return errors.New("could not convert to FirestoreMetaData")
+			}
+			val, ok := u.Value.(StorageClass)
+			if !ok {
+				return errors.New("could not convert value to 'StorageClass'")
+			}
+			item.StorageClass = val
+			l.data[location] = item
+		case "content_hash":
+			item, ok := l.data[location].(*FirestoreMetaData)
+			if !ok {
+				return errors.New("could not convert to FirestoreMetaData")
+			}
+			val, ok := u.Value.(string)
+			if !ok {
+				return errors.New("could not convert value to 'string'")
+			}
+			item.ContentHash = val
+		case "last_accessed", "deleted_at":
+			item, ok := l.data[location].(*FirestoreMetaData)
+			if !ok {
+				return errors.New("could not convert to FirestoreMetaData")
+			}
+			val, ok := u.Value.(time.Time)
+			if !ok {
+				return errors.New("could not convert value to 'time.Time'")
+			}
+			if u.Path == "last_accessed" {
+				item.LastAccessed = val
+			} else {
+				item.DeletedAt = val
+			}
+			l.data[location] = item
+		case "expiresAt":
+			item, ok := l.data[location].(*Announcement)
+			if !ok {
+				return errors.New("could not convert to Announcement")
+			}
+			val, ok := u.Value.(time.Time)
+			if !ok {
+				return errors.New("could not convert value to 'time.Time'")
+			}
+			item.ExpiresAt = val
+		case "readBy":
+			item, ok := l.data[location].(*Announcement)
+			if !ok {
+				return errors.New("could not convert to Announcement")
+			}
+			elems := reflect.ValueOf(u.Value).Field(0)
+			for i := range elems.Len() {
+				if userId := elems.Index(i).Elem().String(); !item.IsReadBy(userId) {
+					item.ReadBy = append(item.ReadBy, userId)
+				}
+			}
+		case "count":
+			item, ok := l.data[location].(*FeatureCount)
+			if !ok {
+				return status.Errorf(codes.NotFound, "Could not find %s", location)
+			}
+			item.Count++
+		case "updatedAt":
+			item, ok := l.data[location].(*ProblemReport)
+			if !ok {
+				return errors.New("could not convert to ProblemReport")
+			}
+			val, ok := u.Value.(time.Time)
+			if !ok {
+				return errors.New("could not convert value to 'time.Time'")
+			}
+			item.UpdatedAt = val
 		case "updated_at":
 			item := l.data[location].(*FirestoreProject)
 			item.UpdatedAt = u.Value.(time.Time)
@@ -223,6 +578,99 @@ func (l *LocalFirestoreClient) GetDocByPrefix(ctx context.Context, dataset, orgI
 	}
 }
 
+func (l *LocalFirestoreClient) GetDocPage(ctx context.Context, dataset, orgId, after string, limit int) iter.Seq[Document] {
+	pathPrefix := path.Join(dataset, orgId) + "/"
+	l.mu.Lock()
+	var ids []string
+	for location := range l.data {
+		if id, ok := strings.CutPrefix(location, pathPrefix); ok && id > after {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	docs := make([]Document, 0, min(limit, len(ids)))
+	for _, id := range ids[:min(limit, len(ids))] {
+		docs = append(docs, &LocalDocument{data: l.data[pathPrefix+id]})
+	}
+	l.mu.Unlock()
+	return slices.Values(docs)
+}
+
+func (l *LocalFirestoreClient) GetOrderedDocPage(ctx context.Context, dataset, orgId string, order DocOrder, limit int) iter.Seq[Document] {
+	type entry struct {
+		keys []any
+		data any
+	}
+	compare := func(a, b []any) int {
+		for i := range min(len(a), len(b)) {
+			if c := compareDocValues(a[i], b[i]); c != 0 {
+				if order.Descending {
+					return -c
+				}
+				return c
+			}
+		}
+		return 0
+	}
+
+	pathPrefix := path.Join(dataset, orgId) + "/"
+	l.mu.Lock()
+	var entries []entry
+	for location, data := range l.data {
+		id, ok := strings.CutPrefix(location, pathPrefix)
+		if !ok {
+			continue
+		}
+		keys := []any{id}
+		if order.Field != "" {
+			keys = []any{docFieldValue(data, order.Field), id}
+		}
+		if len(order.After) == 0 || compare(keys, order.After) > 0 {
+			entries = append(entries, entry{keys: keys, data: data})
+		}
+	}
+	l.mu.Unlock()
+
+	slices.SortFunc(entries, func(a, b entry) int { return compare(a.keys, b.keys) })
+	docs := make([]Document, 0, min(limit, len(entries)))
+	for _, e := range entries[:min(limit, len(entries))] {
+		docs = append(docs, &LocalDocument{data: e.data})
+	}
+	return slices.Values(docs)
+}
+
+// docFieldValue returns the value of the struct field with the firestore tag, or nil when there is no such field
+func docFieldValue(data any, field string) any {
+	val := reflect.ValueOf(data)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := range val.NumField() {
+		if val.Type().Field(i).Tag.Get("firestore") == field {
+			return val.Field(i).Interface()
+		}
+	}
+	return nil
+}
+
+// compareDocValues compares strings and times. Values of other types are considered equal
+func compareDocValues(a, b any) int {
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b)
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			return a.Compare(b)
+		}
+	}
+	return 0
+}
+
 type LocalDocument struct {
 	data any
 }
@@ -7,10 +7,13 @@ import (
 
 	"cloud.google.com/go/firestore"
 	"github.com/davidkleiven/caesura/testutils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestLocalFirestoreClientErrorOnNotMetaData(t *testing.T) {
 	client := NewLocalFirestoreClient()
+	client.data["org/collection/doc"] = &Organization{}
 	err := client.Update(context.Background(), "org", "collection", "doc", []firestore.Update{{Path: "status", Value: "finished"}})
 	if err == nil {
 		t.Fatal("Wanted error")
@@ -110,3 +113,9 @@ func TestLocalClientGetDoc(t *testing.T) {
 		testutils.AssertNil(t, err)
 	})
 }
+
+func TestLocalFirestoreClientUpdateMissingDocument(t *testing.T) {
+	client := NewLocalFirestoreClient()
+	err := client.Update(context.Background(), "org", "collection", "doc", []firestore.Update{{Path: "status", Value: StoreStatusFinished}})
+	testutils.AssertEqual(t, status.Code(err), codes.NotFound)
+}
@@ -0,0 +1,71 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+const DemoOrgId = "cccc13f9-ddd5-489e-bd77-3b935b457f71"
+
+// Fixtures are named store states used to set up end-to-end tests
+var Fixtures = map[string]func() *MultiOrgInMemoryStore{
+	"empty":                NewMultiOrgInMemoryStore,
+	"demo":                 NewDemoStore,
+	"expired-subscription": NewExpiredSubscriptionStore,
+	"large-library":        func() *MultiOrgInMemoryStore { return NewLibraryStore(300) },
+}
+
+func FixtureNames() []string {
+	return slices.Sorted(maps.Keys(Fixtures))
+}
+
+func NewFixtureStore(name string) (*MultiOrgInMemoryStore, error) {
+	fixture, ok := Fixtures[name]
+	if !ok {
+		return nil, errors.Join(ErrFixtureNotFound, fmt.Errorf("fixture %s does not exist", name))
+	}
+	return fixture(), nil
+}
+
+// NewExpiredSubscriptionStore returns the demo store where all subscriptions expired yesterday
+func NewExpiredSubscriptionStore() *MultiOrgInMemoryStore {
+	store := NewDemoStore()
+	for orgId, subscription := range store.Subscriptions {
+		subscription.Expires = time.Now().Add(-24 * time.Hour)
+		store.Subscriptions[orgId] = subscription
+	}
+	return store
+}
+
+// NewLibraryStore returns the demo store where the demo organization has numScores scores
+func NewLibraryStore(numScores int) *MultiOrgInMemoryStore {
+	store := NewDemoStore()
+
+	var pdfBuf bytes.Buffer
+	PanicOnErr(CreateNPagePdf(&pdfBuf, 1))
+	content := pdfBuf.Bytes()
+
+	library := NewInMemoryStore()
+	library.Metadata = make([]MetaData, numScores)
+	for i := range numScores {
+		library.Metadata[i] = MetaData{
+			Title:    fmt.Sprintf("Score %03d", i+1),
+			Composer: fmt.Sprintf("Composer %d", i%10),
+			Arranger: fmt.Sprintf("Arranger %d", i%7),
+		}
+		library.Data[library.Metadata[i].ResourceId()+"/Part0.pdf"] = content
+	}
+	store.Data[DemoOrgId] = library
+	return store
+}
+
+// ReplaceWith replaces the content of the store with the content of other. The store is modified in place
+// such that handlers holding a reference to it see the new content. The store is not synchronized, so no
+// request may use the store while the content is replaced
+func (m *MultiOrgInMemoryStore) ReplaceWith(other *MultiOrgInMemoryStore) {
+	*m = *other.Clone()
+}
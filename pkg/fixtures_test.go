@@ -0,0 +1,59 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestAllFixturesCanBeCreated(t *testing.T) {
+	for _, name := range FixtureNames() {
+		t.Run(name, func(t *testing.T) {
+			store, err := NewFixtureStore(name)
+			testutils.AssertNil(t, err)
+			if store == nil {
+				t.Fatal("Wanted a store")
+			}
+		})
+	}
+}
+
+func TestNewFixtureStoreUnknown(t *testing.T) {
+	_, err := NewFixtureStore("not-a-fixture")
+	if !errors.Is(err, ErrFixtureNotFound) {
+		t.Fatalf("Wanted ErrFixtureNotFound got %v", err)
+	}
+}
+
+func TestExpiredSubscriptionStore(t *testing.T) {
+	store := NewExpiredSubscriptionStore()
+	subscription, err := store.GetSubscription(context.Background(), DemoOrgId)
+	testutils.AssertNil(t, err)
+	if subscription.Expires.After(time.Now()) {
+		t.Fatalf("Wanted expired subscription got %v", subscription.Expires)
+	}
+}
+
+func TestLibraryStore(t *testing.T) {
+	store := NewLibraryStore(300)
+	metas, err := store.MetaByPattern(context.Background(), DemoOrgId, &MetaData{})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(metas), 300)
+}
+
+func TestReplaceWith(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	other := NewDemoStore()
+	store.ReplaceWith(other)
+	testutils.AssertEqual(t, len(store.Organizations), len(other.Organizations))
+	testutils.AssertEqual(t, len(store.Users), len(other.Users))
+
+	// Modifications of the replaced store should not leak into the source
+	store.Organizations[0].Name = "Changed"
+	if other.Organizations[0].Name == "Changed" {
+		t.Fatal("Source store was modified")
+	}
+}
@@ -7,9 +7,12 @@ import (
 	"io"
 	"iter"
 	"log/slog"
+	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -17,25 +20,47 @@ import (
 	"cloud.google.com/go/firestore"
 	"cloud.google.com/go/storage"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
 const (
-	metaDataCollection     = "metadata"
-	projectCollection      = "projects"
-	subscriptionCollection = "subscriptions"
-	organizationCollection = "organizations"
-	organizationInfo       = "info"
-	userCollection         = "users"
-	userInfoDoc            = "info"
-	userOrgLinkDoc         = "userOrganizationLinks"
+	metaDataCollection        = "metadata"
+	projectCollection         = "projects"
+	subscriptionCollection    = "subscriptions"
+	organizationCollection    = "organizations"
+	organizationInfo          = "info"
+	organizationOnboarding    = "onboarding"
+	userCollection            = "users"
+	userInfoDoc               = "info"
+	userOrgLinkDoc            = "userOrganizationLinks"
+	userPasskeyDoc            = "passkeys"
+	userApiTokenDoc           = "apiTokens"
+	userSessionDoc            = "sessions"
+	userHintsDoc              = "hints"
+	permissionsVersionDoc     = "permissionsVersions"
+	sessionGenerationDoc      = "sessionGenerations"
+	metricsCollection         = "metrics"
+	activityCollection        = "activity"
+	announcementCollection    = "announcements"
+	resourceTextCollection    = "resourcetext"
+	projectTemplateCollection = "projecttemplates"
+	problemReportCollection   = "problemreports"
+	featureCountDoc           = "features"
 )
 
 type GoogleConfig struct {
 	Bucket      string `yaml:"bucket" env:"CAESURA_BUCKET"`
 	ProjectId   string `yaml:"projectId" env:"CAESURA_PROJECT_ID"`
 	Environment string `yaml:"environment" env:"CAESURA_GOOGLE_ENVIRONMENT"`
+
+	// Where the PDFs are stored. Either GCS (default) or Azure
+	BlobBackend string `yaml:"blob_backend" env:"CAESURA_BLOB_BACKEND"`
+
+	// Store identical parts once, see DedupBucketClient
+	Deduplicate bool `yaml:"deduplicate" env:"CAESURA_DEDUPLICATE"`
 }
 
 func NewTestConfig() *GoogleConfig {
@@ -55,10 +80,27 @@ type ObjectLister interface {
 	Next() (*storage.ObjectAttrs, error)
 }
 
-type GoogleBucketClient interface {
+// BlobClient stores the PDFs. GCS, S3, Azure Blob Storage and the local disk are supported backends. The
+// storage class names are those of GCS and mapped by the other backends
+type BlobClient interface {
 	Upload(ctx context.Context, bucket, object string, data []byte) error
 	GetObject(ctx context.Context, bucket, objName string) (io.ReadCloser, error)
 	GetObjects(ctx context.Context, bucket string, query *storage.Query) ObjectLister
+	SetStorageClass(ctx context.Context, bucket, object, class string) error
+	Delete(ctx context.Context, bucket, object string) error
+}
+
+// ObjectURLSigner is implemented by blob clients that can create links to objects which are downloaded
+// directly from the bucket without credentials
+type ObjectURLSigner interface {
+	SignedURL(bucket, object, filename string, expires time.Time) (string, error)
+}
+
+// ObjectRangeReader is implemented by blob clients that can read a range of an object without fetching the
+// rest of it. A negative length reads to the end of the object
+type ObjectRangeReader interface {
+	ObjectSize(ctx context.Context, bucket, object string) (int64, error)
+	NewRangeReader(ctx context.Context, bucket, object string, offset, length int64) (io.ReadCloser, error)
 }
 
 type GCSBucketClient struct {
@@ -81,24 +123,84 @@ func (g *GCSBucketClient) GetObjects(ctx context.Context, bucket string, query *
 	return g.client.Bucket(bucket).Objects(ctx, query)
 }
 
+func (g *GCSBucketClient) SetStorageClass(ctx context.Context, bucket, object, class string) error {
+	obj := g.client.Bucket(bucket).Object(object)
+	copier := obj.CopierFrom(obj)
+	copier.StorageClass = class
+	_, err := copier.Run(ctx)
+	return err
+}
+
+func (g *GCSBucketClient) Delete(ctx context.Context, bucket, object string) error {
+	return g.client.Bucket(bucket).Object(object).Delete(ctx)
+}
+
+// SignedURL creates a V4 signed URL. The signing account is detected from the credentials of the client
+func (g *GCSBucketClient) SignedURL(bucket, object, filename string, expires time.Time) (string, error) {
+	opts := storage.SignedURLOptions{
+		Method:  http.MethodGet,
+		Expires: expires,
+		Scheme:  storage.SigningSchemeV4,
+		QueryParameters: url.Values{
+			"response-content-disposition": {"attachment; filename=\"" + filename + "\""},
+		},
+	}
+	return g.client.Bucket(bucket).SignedURL(object, &opts)
+}
+
+func (g *GCSBucketClient) ObjectSize(ctx context.Context, bucket, object string) (int64, error) {
+	attrs, err := g.client.Bucket(bucket).Object(object).Attrs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return attrs.Size, nil
+}
+
+func (g *GCSBucketClient) NewRangeReader(ctx context.Context, bucket, object string, offset, length int64) (io.ReadCloser, error) {
+	return g.client.Bucket(bucket).Object(object).NewRangeReader(ctx, offset, length)
+}
+
 type GoogleStore struct {
-	BucketClient GoogleBucketClient
+	BucketClient BlobClient
 	FsClient     FirestoreClient
 	Config       *GoogleConfig
 }
 
+// isGoogleAPIUnavailable reports whether a call to the JSON API of Cloud Storage failed in a way that may
+// succeed later. Firestore uses gRPC and reports these as status codes instead
+func isGoogleAPIUnavailable(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return slices.Contains(transientHTTPCodes, apiErr.Code)
+}
+
 func (gs *GoogleStore) objectName(orgId, resourceId, name string) string {
 	return path.Join(orgId, resourceId, name)
 }
 
 func (gs *GoogleStore) Submit(ctx context.Context, orgId string, m *MetaData, pdfIter iter.Seq2[string, []byte]) error {
-	var (
-		wg       sync.WaitGroup
-		firstErr error
-		numErr   int
-		mu       sync.Mutex
-	)
 	m.Status = StoreStatusPending
+	m.SubmittedAt = time.Now()
+
+	// The hash is set once the parts are uploaded, such that an interrupted submit has no hash
+	m.ContentHash = ""
+
+	resourceId := m.ResourceId()
+	existing, err := gs.MetaById(ctx, orgId, resourceId)
+	exists, err := replaceable(existing, err, resourceId)
+	if err != nil {
+		return err
+	}
+	var previous []*storage.ObjectAttrs
+	if exists {
+		if previous, err = gs.archiveResource(ctx, orgId, resourceId); err != nil {
+			return fmt.Errorf("could not keep the previous version: %w", err)
+		}
+	} else {
+		existing = nil
+	}
 
 	metaRecord := FirestoreMetaData{
 		MetaData:       *m,
@@ -107,38 +209,99 @@ func (gs *GoogleStore) Submit(ctx context.Context, orgId string, m *MetaData, pd
 		ArrangerSearch: firebaseSearchString(m.Arranger),
 	}
 
-	resourceId := m.ResourceId()
 	if err := gs.FsClient.StoreDocument(ctx, metaDataCollection, orgId, resourceId, &metaRecord); err != nil {
 		return err
 	}
 
-	for name, data := range pdfIter {
-		wg.Add(1)
-		go func(file string, d []byte) {
-			defer wg.Done()
-			objName := gs.objectName(orgId, resourceId, file)
-			err := gs.BucketClient.Upload(ctx, gs.Config.Bucket, objName, d)
+	previousHash := ""
+	if existing != nil {
+		previousHash = existing.ContentHash
+	}
+	hash := newContentHash(previousHash)
+	if err := gs.uploadParts(ctx, orgId, resourceId, hash.parts(pdfIter), previous); err != nil {
+		return errors.Join(err, gs.abortSubmit(ctx, orgId, resourceId, existing))
+	}
+	m.ContentHash = hash.Sum()
+	err = gs.FsClient.Update(
+		ctx,
+		metaDataCollection,
+		orgId,
+		resourceId,
+		[]firestore.Update{{Path: "status", Value: StoreStatusFinished}, {Path: "content_hash", Value: m.ContentHash}},
+	)
+	return classifyStoreErr(err, ErrResourceMetadataNotFound)
+}
 
-			if err != nil {
-				mu.Lock()
-				firstErr = err
-				numErr += 1
-				mu.Unlock()
-			}
-		}(name, data)
+// abortSubmit is called after the files of a failed submit are rolled back. The metadata of a resource that
+// existed before is restored, and new resources are marked as failed such that they are cleaned up later
+func (gs *GoogleStore) abortSubmit(ctx context.Context, orgId, resourceId string, existing *MetaData) error {
+	ctx = context.WithoutCancel(ctx)
+	if existing != nil {
+		return gs.UpdateMetaData(ctx, orgId, existing)
 	}
-	wg.Wait()
+	err := gs.FsClient.Update(
+		ctx,
+		metaDataCollection,
+		orgId,
+		resourceId,
+		[]firestore.Update{{Path: "status", Value: StoreStatusFailed}},
+	)
+	return classifyStoreErr(err, ErrResourceMetadataNotFound)
+}
 
-	if firstErr != nil {
-		return fmt.Errorf("Received %d errors. First error %w", numErr, firstErr)
+func (g *GoogleStore) checkNotProtected(ctx context.Context, orgId, resourceId string) error {
+	meta, err := g.MetaById(ctx, orgId, resourceId)
+	if errors.Is(err, ErrResourceMetadataNotFound) {
+		return nil
+	} else if err != nil {
+		return err
 	}
-	return gs.FsClient.Update(
+
+	if meta.Protected {
+		return errors.Join(ErrResourceProtected, fmt.Errorf("resource id: %s", resourceId))
+	}
+	return nil
+}
+
+func (g *GoogleStore) SetProtected(ctx context.Context, orgId, resourceId string, protected bool) error {
+	err := g.FsClient.Update(
 		ctx,
 		metaDataCollection,
 		orgId,
 		resourceId,
-		[]firestore.Update{{Path: "status", Value: StoreStatusFinished}},
+		[]firestore.Update{{Path: "protected", Value: protected}},
 	)
+	return classifyStoreErr(err, ErrResourceMetadataNotFound)
+}
+
+func (g *GoogleStore) DeleteResource(ctx context.Context, orgId, resourceId string) error {
+	if err := g.checkNotProtected(ctx, orgId, resourceId); err != nil {
+		return err
+	}
+	err := g.FsClient.Update(
+		ctx,
+		metaDataCollection,
+		orgId,
+		resourceId,
+		[]firestore.Update{{Path: "deleted", Value: true}, {Path: "deleted_at", Value: time.Now()}},
+	)
+	return classifyStoreErr(err, ErrResourceMetadataNotFound)
+}
+
+// UpdateMetaData replaces the stored metadata of an existing resource
+func (g *GoogleStore) UpdateMetaData(ctx context.Context, orgId string, meta *MetaData) error {
+	resourceId := meta.ResourceId()
+	if _, err := g.MetaById(ctx, orgId, resourceId); err != nil {
+		return err
+	}
+
+	metaRecord := FirestoreMetaData{
+		MetaData:       *meta,
+		TitleSearch:    firebaseSearchString(meta.Title),
+		ComposerSearch: firebaseSearchString(meta.Composer),
+		ArrangerSearch: firebaseSearchString(meta.Arranger),
+	}
+	return g.FsClient.StoreDocument(ctx, metaDataCollection, orgId, resourceId, &metaRecord)
 }
 
 func (g *GoogleStore) SubmitProject(ctx context.Context, orgId string, project *Project) error {
@@ -186,11 +349,15 @@ func (g *GoogleStore) MetaByPattern(ctx context.Context, orgId string, pattern *
 	return result, nil
 }
 
+func (g *GoogleStore) MetaByIds(ctx context.Context, orgId string, ids []string) []MetaLookup {
+	return FetchMetaByIds(ctx, g, orgId, ids, metaFetchWorkers)
+}
+
 func (g *GoogleStore) MetaById(ctx context.Context, orgId, metaId string) (*MetaData, error) {
 	doc, err := g.FsClient.GetDoc(ctx, metaDataCollection, orgId, metaId)
 	var meta MetaData
 	if err != nil {
-		return &meta, err
+		return &meta, classifyStoreErr(err, ErrResourceMetadataNotFound)
 	}
 	err = doc.DataTo(&meta)
 	return &meta, err
@@ -216,55 +383,242 @@ func (g *GoogleStore) ProjectsByName(ctx context.Context, orgId string, name str
 func (g *GoogleStore) ProjectById(ctx context.Context, orgId string, projectId string) (*Project, error) {
 	doc, err := g.FsClient.GetDoc(ctx, projectCollection, orgId, projectId)
 	if err != nil {
-		return &Project{}, err
+		return &Project{}, classifyStoreErr(err, ErrProjectNotFound)
 	}
 	var proj Project
 	err = doc.DataTo(&proj)
 	return &proj, err
 }
 
+func (g *GoogleStore) DeleteProject(ctx context.Context, orgId, projectId string) error {
+	return g.FsClient.DeleteDoc(ctx, projectCollection, orgId, projectId)
+}
+
 func (g *GoogleStore) RemoveResource(ctx context.Context, orgId string, projectId string, resourceId string) error {
 	update := []firestore.Update{
 		{
 			Path:  "resource_ids",
 			Value: firestore.ArrayRemove(resourceId),
 		},
+		{
+			FieldPath: firestore.FieldPath{"notes", resourceId},
+			Value:     firestore.Delete,
+		},
+		{
+			Path:  "updated_at",
+			Value: time.Now(),
+		},
+	}
+	return classifyStoreErr(g.FsClient.Update(ctx, projectCollection, orgId, projectId, update), ErrProjectNotFound)
+}
+
+func (g *GoogleStore) SetProjectOrder(ctx context.Context, orgId, projectId string, resourceIds []string) error {
+	update := []firestore.Update{
+		{
+			Path:  "resource_ids",
+			Value: resourceIds,
+		},
 		{
 			Path:  "updated_at",
 			Value: time.Now(),
 		},
 	}
-	return g.FsClient.Update(ctx, projectCollection, orgId, projectId, update)
+	return classifyStoreErr(g.FsClient.Update(ctx, projectCollection, orgId, projectId, update), ErrProjectNotFound)
+}
+
+func (g *GoogleStore) SetProjectNote(ctx context.Context, orgId, projectId, resourceId, note string) error {
+	var value any = note
+	if note == "" {
+		value = firestore.Delete
+	}
+	update := []firestore.Update{
+		{
+			FieldPath: firestore.FieldPath{"notes", resourceId},
+			Value:     value,
+		},
+		{
+			Path:  "updated_at",
+			Value: time.Now(),
+		},
+	}
+	return classifyStoreErr(g.FsClient.Update(ctx, projectCollection, orgId, projectId, update), ErrProjectNotFound)
 }
 
 func (g *GoogleStore) Resource(ctx context.Context, orgId string, path string) iter.Seq2[string, []byte] {
-	query := storage.Query{Prefix: filepath.Join(orgId, path)}
-	objects := g.BucketClient.GetObjects(ctx, g.Config.Bucket, &query)
+	return g.contentByPrefix(ctx, filepath.Join(orgId, path))
+}
+
+func (g *GoogleStore) ResourceStream(ctx context.Context, orgId string, path string) iter.Seq2[string, io.Reader] {
+	return g.readersByPrefix(ctx, filepath.Join(orgId, path))
+}
+
+// SignedPartURL creates a link to a part of the resource that can be downloaded directly from the bucket
+// until expires. ErrSignedURLUnsupported is returned when the bucket client can not sign links
+func (g *GoogleStore) SignedPartURL(ctx context.Context, orgId, resourceId, filename string, expires time.Time) (string, error) {
+	signer, ok := g.BucketClient.(ObjectURLSigner)
+	if !ok {
+		return "", ErrSignedURLUnsupported
+	}
+	return signer.SignedURL(g.Config.Bucket, g.objectName(orgId, resourceId, filename), filename, expires)
+}
+
+// OpenPart opens a part of the resource that reads only the ranges it is seeked to from the bucket.
+// ErrRangeReadUnsupported is returned when the bucket client can not read ranges
+func (g *GoogleStore) OpenPart(ctx context.Context, orgId, resourceId, filename string) (io.ReadSeekCloser, error) {
+	client, ok := g.BucketClient.(ObjectRangeReader)
+	if !ok {
+		return nil, ErrRangeReadUnsupported
+	}
+	object := g.objectName(orgId, resourceId, filename)
+	size, err := client.ObjectSize(ctx, g.Config.Bucket, object)
+	if err != nil {
+		return nil, classifyStoreErr(err, errors.Join(ErrFileNotFound, fmt.Errorf("file: %s", filename)))
+	}
+	return &rangedObject{ctx: ctx, client: client, bucket: g.Config.Bucket, object: object, size: size}, nil
+}
+
+// rangedObject reads an object from the offset it was last seeked to. The object is opened on the first read
+// after a seek, such that seeking to find the size, as http.ServeContent does, reads nothing from the bucket
+type rangedObject struct {
+	ctx    context.Context
+	client ObjectRangeReader
+	bucket string
+	object string
+	size   int64
+	offset int64
+	reader io.ReadCloser
+}
+
+func (r *rangedObject) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.reader == nil {
+		reader, err := r.client.NewRangeReader(r.ctx, r.bucket, r.object, r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.reader = reader
+	}
+	n, err := r.reader.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *rangedObject) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("seek to negative offset %d", offset)
+	}
+	if offset != r.offset {
+		if err := r.Close(); err != nil {
+			return 0, err
+		}
+		r.offset = offset
+	}
+	return offset, nil
+}
+
+func (r *rangedObject) Close() error {
+	if r.reader == nil {
+		return nil
+	}
+	err := r.reader.Close()
+	r.reader = nil
+	return err
+}
+
+func (g *GoogleStore) contentByPrefix(ctx context.Context, prefix string) iter.Seq2[string, []byte] {
 	return func(yield func(name string, content []byte) bool) {
+		for name, content := range g.readersByPrefix(ctx, prefix) {
+			contentBytes, err := io.ReadAll(content)
+			if err != nil {
+				continue
+			}
+			if !yield(name, contentBytes) {
+				return
+			}
+		}
+	}
+}
+
+// readersByPrefix opens the objects starting with prefix one at a time. Each object is closed when the
+// consumer asks for the next one
+func (g *GoogleStore) readersByPrefix(ctx context.Context, prefix string) iter.Seq2[string, io.Reader] {
+	query := storage.Query{Prefix: prefix}
+	objects := g.BucketClient.GetObjects(ctx, g.Config.Bucket, &query)
+	return func(yield func(name string, content io.Reader) bool) {
 		for {
 			objAttr, err := objects.Next()
 			if err != nil {
 				return
 			}
 			content, err := g.BucketClient.GetObject(ctx, objAttr.Bucket, objAttr.Name)
-			contentBytes, err := io.ReadAll(content)
-			content.Close()
-
 			if err != nil {
 				continue
 			}
 
-			resourceName := filepath.Base(objAttr.Name)
-			if !yield(resourceName, contentBytes) {
+			more := yield(filepath.Base(objAttr.Name), content)
+			content.Close()
+			if !more {
 				return
 			}
 		}
 	}
 }
+
+func (g *GoogleStore) RecordAccess(ctx context.Context, orgId, resourceId string, at time.Time) error {
+	err := g.FsClient.Update(
+		ctx,
+		metaDataCollection,
+		orgId,
+		resourceId,
+		[]firestore.Update{{Path: "last_accessed", Value: at}},
+	)
+	return classifyStoreErr(err, ErrResourceMetadataNotFound)
+}
+
+// setStorageClass changes the storage class of all objects starting with prefix
+func setStorageClass(ctx context.Context, client BlobClient, bucket, prefix string, class StorageClass) error {
+	objects := client.GetObjects(ctx, bucket, &storage.Query{Prefix: prefix})
+	var err error
+	for {
+		objAttr, iterErr := objects.Next()
+		if errors.Is(iterErr, iterator.Done) {
+			break
+		}
+		if iterErr != nil {
+			return iterErr
+		}
+		err = errors.Join(err, client.SetStorageClass(ctx, objAttr.Bucket, objAttr.Name, class.gcsStorageClass()))
+	}
+	return err
+}
+
+func (g *GoogleStore) TransitionStorageClass(ctx context.Context, orgId, resourceId string, class StorageClass) error {
+	prefix := path.Join(orgId, resourceId) + "/"
+	if err := setStorageClass(ctx, g.BucketClient, g.Config.Bucket, prefix, class); err != nil {
+		return classifyStoreErr(err, ErrResourceNotFound)
+	}
+	err := g.FsClient.Update(
+		ctx,
+		metaDataCollection,
+		orgId,
+		resourceId,
+		[]firestore.Update{{Path: "storage_class", Value: class}},
+	)
+	return classifyStoreErr(err, ErrResourceMetadataNotFound)
+}
+
 func (g *GoogleStore) Item(ctx context.Context, path string) ([]byte, error) {
 	content, err := g.BucketClient.GetObject(ctx, g.Config.Bucket, path)
 	if err != nil {
-		return []byte{}, err
+		return []byte{}, classifyStoreErr(err, ErrResourceNotFound)
 	}
 	defer content.Close()
 	return io.ReadAll(content)
@@ -287,7 +641,7 @@ func (g *GoogleStore) GetSubscription(ctx context.Context, orgId string) (*Subsc
 	doc, err := g.FsClient.GetDoc(ctx, organizationCollection, subscriptionCollection, orgId)
 	var sub Subscription
 	if err != nil {
-		return &sub, errors.Join(ErrSubscriptionNotFound, err)
+		return &sub, classifyStoreErr(err, ErrSubscriptionNotFound)
 	}
 	err = doc.DataTo(&sub)
 	return &sub, err
@@ -301,36 +655,120 @@ func (g *GoogleStore) GetOrganization(ctx context.Context, orgId string) (Organi
 	var org Organization
 	doc, err := g.FsClient.GetDoc(ctx, organizationCollection, organizationInfo, orgId)
 	if err != nil {
-		return org, err
+		return org, classifyStoreErr(err, ErrOrganizationNotFound)
 	}
 	err = doc.DataTo(&org)
 	return org, err
 }
 
+func (g *GoogleStore) ListOrganizations(ctx context.Context) ([]Organization, error) {
+	collector := NewValidCollector[Organization]()
+	for item := range g.FsClient.GetDocByPrefix(ctx, organizationCollection, organizationInfo, "id", "") {
+		collector.Push(item)
+	}
+	return collector.Items, collector.Err
+}
+
 func (g *GoogleStore) DeleteOrganization(ctx context.Context, orgId string) error {
-	return g.FsClient.Update(
+	err := g.FsClient.Update(
 		ctx,
 		organizationCollection,
 		organizationInfo,
 		orgId,
 		[]firestore.Update{{Path: "deleted", Value: true}})
+	return classifyStoreErr(err, ErrOrganizationNotFound)
+}
+
+func (g *GoogleStore) UpdateBranding(ctx context.Context, orgId string, branding Branding) error {
+	err := g.FsClient.Update(
+		ctx,
+		organizationCollection,
+		organizationInfo,
+		orgId,
+		[]firestore.Update{{Path: "branding", Value: branding}})
+	return classifyStoreErr(err, ErrOrganizationNotFound)
+}
+
+func (g *GoogleStore) OrganizationByDomain(ctx context.Context, domain string) (Organization, error) {
+	if domain == "" {
+		return Organization{}, ErrOrganizationNotFound
+	}
+	for doc := range g.FsClient.GetDocByPrefix(ctx, organizationCollection, organizationInfo, "domain", domain) {
+		var org Organization
+		if err := doc.DataTo(&org); err != nil {
+			return org, err
+		}
+
+		// Prefix query also matches longer domains
+		if org.Domain == domain && !org.Deleted {
+			return org, nil
+		}
+	}
+	return Organization{}, ErrOrganizationNotFound
+}
+
+func (g *GoogleStore) UpdateDomain(ctx context.Context, orgId, domain string) error {
+	if owner, err := g.OrganizationByDomain(ctx, domain); err == nil && owner.Id != orgId {
+		return errors.Join(ErrDomainInUse, fmt.Errorf("%s is used by %s", domain, owner.Id))
+	}
+	err := g.FsClient.Update(
+		ctx,
+		organizationCollection,
+		organizationInfo,
+		orgId,
+		[]firestore.Update{{Path: "domain", Value: domain}})
+	return classifyStoreErr(err, ErrOrganizationNotFound)
+}
+
+func (g *GoogleStore) RequirePasskey(ctx context.Context, orgId string, required bool) error {
+	err := g.FsClient.Update(
+		ctx,
+		organizationCollection,
+		organizationInfo,
+		orgId,
+		[]firestore.Update{{Path: "requirePasskey", Value: required}})
+	return classifyStoreErr(err, ErrOrganizationNotFound)
 }
 
 func (g *GoogleStore) RegisterUser(ctx context.Context, userInfo *UserInfo) error {
+	// Links to organizations that are not part of userInfo keep their role and groups, but the name and
+	// email must follow the user
+	var otherLinks []UserOrganizationLink
+	for doc := range g.FsClient.GetDocByPrefix(ctx, userCollection, userOrgLinkDoc, "userId", userInfo.Id) {
+		var link UserOrganizationLink
+		if err := doc.DataTo(&link); err != nil || link.UserId != userInfo.Id {
+			continue
+		}
+		if _, registered := userInfo.Roles[link.OrgId]; !registered {
+			otherLinks = append(otherLinks, link)
+		}
+	}
+
 	flatUser := userInfo.ToFlat()
-	group, ctx := errgroup.WithContext(ctx)
+	group, groupCtx := errgroup.WithContext(ctx)
 	group.Go(func() error {
-		return g.FsClient.StoreDocument(ctx, userCollection, userInfoDoc, flatUser.User.Id, flatUser.User)
+		return g.FsClient.StoreDocument(groupCtx, userCollection, userInfoDoc, flatUser.User.Id, flatUser.User)
 	})
 
 	for i := range flatUser.UserOrgLinks {
 		link := flatUser.UserOrgLinks[i]
 		group.Go(func() error {
 			lId := linkId(link.UserId, link.OrgId)
-			return g.FsClient.StoreDocument(ctx, userCollection, userOrgLinkDoc, lId, link)
+			return g.FsClient.StoreDocument(groupCtx, userCollection, userOrgLinkDoc, lId, link)
 		})
 	}
-	return group.Wait()
+
+	err := group.Wait()
+	for _, link := range otherLinks {
+		err = errors.Join(err, g.FsClient.Update(
+			ctx,
+			userCollection,
+			userOrgLinkDoc,
+			linkId(link.UserId, link.OrgId),
+			[]firestore.Update{{Path: "name", Value: userInfo.Name}, {Path: "email", Value: userInfo.Email}},
+		))
+	}
+	return err
 }
 
 func (g *GoogleStore) GetUserInfo(ctx context.Context, userId string) (*UserInfo, error) {
@@ -339,10 +777,8 @@ func (g *GoogleStore) GetUserInfo(ctx context.Context, userId string) (*UserInfo
 	}
 
 	doc, err := g.FsClient.GetDoc(ctx, userCollection, userInfoDoc, userId)
-	if err != nil && status.Code(err) == codes.NotFound {
-		return &UserInfo{}, errors.Join(ErrUserNotFound, err)
-	} else if err != nil {
-		return &UserInfo{}, fmt.Errorf("Could not get document %w", err)
+	if err != nil {
+		return &UserInfo{}, classifyStoreErr(err, ErrUserNotFound)
 	}
 
 	var user User
@@ -361,24 +797,51 @@ func (g *GoogleStore) GetUserInfo(ctx context.Context, userId string) (*UserInfo
 	return NewUserFromFlat(&flat), collector.Err
 }
 
+func (g *GoogleStore) PermissionsVersion(ctx context.Context, userId string) (int64, error) {
+	doc, err := g.FsClient.GetDoc(ctx, userCollection, permissionsVersionDoc, userId)
+	if status.Code(err) == codes.NotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, classifyStoreErr(err, ErrUserNotFound)
+	}
+
+	var version PermissionsVersion
+	err = doc.DataTo(&version)
+	return version.Version, err
+}
+
+// bumpPermissionsVersion is called after the roles or groups of the user have changed
+func (g *GoogleStore) bumpPermissionsVersion(ctx context.Context, userId string) error {
+	version := PermissionsVersion{Version: newPermissionsVersion()}
+	return g.FsClient.StoreDocument(ctx, userCollection, permissionsVersionDoc, userId, version)
+}
+
 func (g *GoogleStore) RegisterGroup(ctx context.Context, userId, orgId, group string) error {
-	return g.FsClient.Update(
+	err := g.FsClient.Update(
 		ctx,
 		userCollection,
 		userOrgLinkDoc,
 		linkId(userId, orgId),
 		[]firestore.Update{{Path: "groups", Value: firestore.ArrayUnion(group)}},
 	)
+	if err != nil {
+		return classifyStoreErr(err, ErrUserNotFound)
+	}
+	return g.bumpPermissionsVersion(ctx, userId)
 }
 
 func (g *GoogleStore) RemoveGroup(ctx context.Context, userId, orgId, group string) error {
-	return g.FsClient.Update(
+	err := g.FsClient.Update(
 		ctx,
 		userCollection,
 		userOrgLinkDoc,
 		linkId(userId, orgId),
 		[]firestore.Update{{Path: "groups", Value: firestore.ArrayRemove(group)}},
 	)
+	if err != nil {
+		return classifyStoreErr(err, ErrUserNotFound)
+	}
+	return g.bumpPermissionsVersion(ctx, userId)
 }
 
 func (g *GoogleStore) RegisterRole(ctx context.Context, userId string, organizationId string, role RoleKind) error {
@@ -398,13 +861,25 @@ func (g *GoogleStore) RegisterRole(ctx context.Context, userId string, organizat
 			OrgId:  organizationId,
 			Role:   role,
 		}
+		if doc, userErr := g.FsClient.GetDoc(ctx, userCollection, userInfoDoc, userId); userErr == nil {
+			var user User
+			if doc.DataTo(&user) == nil {
+				userOrgLink.Name, userOrgLink.Email = user.Name, user.Email
+			}
+		}
 		err = g.FsClient.StoreDocument(ctx, userCollection, userOrgLinkDoc, docId, userOrgLink)
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	return g.bumpPermissionsVersion(ctx, userId)
 }
 
 func (g *GoogleStore) DeleteRole(ctx context.Context, userId, orgId string) error {
-	return g.FsClient.DeleteDoc(ctx, userCollection, userOrgLinkDoc, linkId(userId, orgId))
+	if err := g.FsClient.DeleteDoc(ctx, userCollection, userOrgLinkDoc, linkId(userId, orgId)); err != nil {
+		return err
+	}
+	return g.bumpPermissionsVersion(ctx, userId)
 }
 
 func (g *GoogleStore) GetUsersInOrg(ctx context.Context, orgId string) ([]UserInfo, error) {
@@ -416,9 +891,15 @@ func (g *GoogleStore) GetUsersInOrg(ctx context.Context, orgId string) ([]UserIn
 	users := make([]UserInfo, len(collector.Items))
 	errors := make([]error, len(collector.Items))
 	var wg sync.WaitGroup
-	wg.Add(len(users))
 	for i, link := range collector.Items {
+		if link.hasSnapshot() {
+			users[i] = link.Member()
+			continue
+		}
+
+		// Links stored before name and email were copied onto them
 		idx, userId := i, link.UserId
+		wg.Add(1)
 		go func() {
 			defer wg.Done()
 
@@ -460,14 +941,521 @@ func (g *GoogleStore) UserByEmail(ctx context.Context, email string) (UserInfo,
 // Note that the password should be a hashed version of the password using
 // a cryptographically safe hash method
 func (g *GoogleStore) ResetPassword(ctx context.Context, userId, password string) error {
-	return g.FsClient.Update(
+	err := g.FsClient.Update(
 		ctx,
 		userCollection,
 		userInfoDoc,
 		userId,
 		[]firestore.Update{{Path: "password", Value: password}},
 	)
+	return classifyStoreErr(err, ErrUserNotFound)
+}
+
+func (g *GoogleStore) MarkEmailVerified(ctx context.Context, userId string) error {
+	err := g.FsClient.Update(
+		ctx,
+		userCollection,
+		userInfoDoc,
+		userId,
+		[]firestore.Update{{Path: "verified_email", Value: true}},
+	)
+	return classifyStoreErr(err, ErrUserNotFound)
+}
+
+func (g *GoogleStore) EraseUser(ctx context.Context, userId string) error {
+	doc, err := g.FsClient.GetDoc(ctx, userCollection, userInfoDoc, userId)
+	if err != nil {
+		return classifyStoreErr(err, errors.Join(ErrUserNotFound, fmt.Errorf("user id: %s", userId)))
+	}
+	var user UserInfo
+	if err := doc.DataTo(&user); err != nil {
+		return err
+	}
+
+	// The history of all organizations is anonymized, since the user may have left some of them
+	orgs, err := g.ListOrganizations(ctx)
+	if err != nil {
+		return err
+	}
+	for _, org := range orgs {
+		err = errors.Join(
+			err,
+			g.anonymizeActivity(ctx, org.Id, userId),
+			g.anonymizeAnnouncements(ctx, org.Id, userId),
+			g.anonymizeAuditLog(ctx, org.Id, userId),
+			g.anonymizeInvitations(ctx, org.Id, userId, user.Email),
+			g.anonymizeGuestGrants(ctx, org.Id, userId, user.Email),
+			g.anonymizeCorrections(ctx, org.Id, userId),
+			g.deleteScimIdentity(ctx, org.Id, userId),
+		)
+	}
+
+	for doc := range g.FsClient.GetDocByPrefix(ctx, userCollection, userOrgLinkDoc, "userId", userId) {
+		var link UserOrganizationLink
+		if doc.DataTo(&link) == nil && link.UserId == userId {
+			err = errors.Join(err, g.FsClient.DeleteDoc(ctx, userCollection, userOrgLinkDoc, linkId(userId, link.OrgId)))
+		}
+	}
+
+	passkeys, passkeysErr := g.Passkeys(ctx, userId)
+	sessions, sessionsErr := g.Sessions(ctx, userId)
+	tokens, tokensErr := g.ApiTokens(ctx, userId)
+	err = errors.Join(err, passkeysErr, sessionsErr, tokensErr)
+	for _, passkey := range passkeys {
+		err = errors.Join(err, g.FsClient.DeleteDoc(ctx, userCollection, userPasskeyDoc, passkey.Id))
+	}
+	for _, session := range sessions {
+		err = errors.Join(err, g.FsClient.DeleteDoc(ctx, userCollection, userSessionDoc, session.Id))
+	}
+	for _, token := range tokens {
+		err = errors.Join(err, g.FsClient.DeleteDoc(ctx, userCollection, userApiTokenDoc, token.Hash))
+	}
+
+	if err != nil {
+		// The user is kept such that erasing can be attempted again
+		return err
+	}
+	return errors.Join(
+		g.FsClient.DeleteDoc(ctx, userCollection, userHintsDoc, userId),
+		g.FsClient.DeleteDoc(ctx, userCollection, userInfoDoc, userId),
+		g.bumpPermissionsVersion(ctx, userId),
+	)
+}
+
+func (g *GoogleStore) anonymizeActivity(ctx context.Context, orgId, userId string) error {
+	collector := NewValidCollector[Activity]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, activityCollection, orgId, "projectId", "") {
+		collector.Push(doc)
+	}
+
+	err := collector.Err
+	for _, activity := range collector.Items {
+		if activity.UserId != userId {
+			continue
+		}
+		activity.UserId = DeletedUserId
+		err = errors.Join(err, g.FsClient.StoreDocument(ctx, activityCollection, orgId, activity.Id, &activity))
+	}
+	return err
+}
+
+func (g *GoogleStore) anonymizeAnnouncements(ctx context.Context, orgId, userId string) error {
+	announcements, err := g.Announcements(ctx, orgId)
+	for _, announcement := range announcements {
+		if announcement.AuthorId != userId && !announcement.IsReadBy(userId) {
+			continue
+		}
+		if announcement.AuthorId == userId {
+			announcement.AuthorId = DeletedUserId
+		}
+		announcement.ReadBy = slices.DeleteFunc(announcement.ReadBy, func(id string) bool { return id == userId })
+		err = errors.Join(err, g.FsClient.StoreDocument(ctx, announcementCollection, orgId, announcement.Id, &announcement))
+	}
+	return err
+}
+
+func (g *GoogleStore) anonymizeAuditLog(ctx context.Context, orgId, userId string) error {
+	collector := NewValidCollector[AuditEntry]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, auditLogCollection, orgId, "id", "") {
+		collector.Push(doc)
+	}
+
+	err := collector.Err
+	for _, entry := range collector.Items {
+		if entry.eraseUser(userId) {
+			err = errors.Join(err, g.FsClient.StoreDocument(ctx, auditLogCollection, orgId, entry.Id, &entry))
+		}
+	}
+	return err
+}
+
+// anonymizeInvitations stores the invitations directly, since invitations without an email are not valid
+func (g *GoogleStore) anonymizeInvitations(ctx context.Context, orgId, userId, email string) error {
+	invitations, err := g.Invitations(ctx, orgId)
+	for _, invitation := range invitations {
+		if invitation.eraseUser(userId, email) {
+			err = errors.Join(err, g.FsClient.StoreDocument(ctx, invitationCollection, orgId, invitation.Id, &invitation))
+		}
+	}
+	return err
+}
+
+func (g *GoogleStore) anonymizeGuestGrants(ctx context.Context, orgId, userId, email string) error {
+	grants, err := g.GuestGrants(ctx, orgId)
+	now := time.Now()
+	for _, grant := range grants {
+		if grant.eraseUser(userId, email, now) {
+			err = errors.Join(err, g.FsClient.StoreDocument(ctx, guestGrantCollection, orgId, grant.Id, &grant))
+		}
+	}
+	return err
+}
+
+func (g *GoogleStore) anonymizeCorrections(ctx context.Context, orgId, userId string) error {
+	corrections, err := g.Corrections(ctx, orgId)
+	for _, correction := range corrections {
+		if correction.concernsUser(userId) {
+			err = errors.Join(err, g.FsClient.DeleteDoc(ctx, correctionCollection, orgId, correction.Id))
+		} else if correction.eraseUser(userId) {
+			err = errors.Join(err, g.FsClient.StoreDocument(ctx, correctionCollection, orgId, correction.Id, &correction))
+		}
+	}
+	return err
+}
+
+func (g *GoogleStore) deleteScimIdentity(ctx context.Context, orgId, userId string) error {
+	identities, err := g.ScimIdentities(ctx, orgId)
+	for _, identity := range identities {
+		if identity.UserId == userId {
+			err = errors.Join(err, g.FsClient.DeleteDoc(ctx, scimIdentityCollection, orgId, identity.UserId))
+		}
+	}
+	return err
+}
+
+// CountFeature creates the count of the week or increments it in a transaction, such that two first uses at the
+// same time are both counted. Clients without transactions fall back to creating the count when the increment
+// finds no count
+func (g *GoogleStore) CountFeature(ctx context.Context, orgId string, feature Feature, at time.Time) error {
+	count := FeatureCount{OrgId: orgId, Week: IsoWeek(at), Feature: feature, Count: 1}
+	increment := []firestore.Update{{Path: "count", Value: firestore.Increment(1)}}
+	client, ok := g.FsClient.(TransactionalClient)
+	if !ok {
+		err := g.FsClient.Update(ctx, metricsCollection, featureCountDoc, count.Id(), increment)
+		if err != nil && status.Code(err) == codes.NotFound {
+			err = g.FsClient.StoreDocument(ctx, metricsCollection, featureCountDoc, count.Id(), &count)
+		}
+		return err
+	}
+
+	return client.RunTransaction(ctx, func(ctx context.Context, tx FirestoreClient) error {
+		_, err := tx.GetDoc(ctx, metricsCollection, featureCountDoc, count.Id())
+		if status.Code(err) == codes.NotFound {
+			return tx.StoreDocument(ctx, metricsCollection, featureCountDoc, count.Id(), &count)
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Update(ctx, metricsCollection, featureCountDoc, count.Id(), increment)
+	})
+}
+
+func (g *GoogleStore) FeatureCounts(ctx context.Context, since time.Time) ([]FeatureCount, error) {
+	collector := NewValidCollector[FeatureCount]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, metricsCollection, featureCountDoc, "week", "") {
+		collector.Push(doc)
+	}
+
+	sinceWeek := IsoWeek(since)
+	result := slices.DeleteFunc(collector.Items, func(c FeatureCount) bool { return c.Week < sinceWeek })
+	SortFeatureCounts(result)
+	return result, collector.Err
+}
+
+func (g *GoogleStore) RecordActivity(ctx context.Context, orgId string, activity *Activity) error {
+	return g.FsClient.StoreDocument(ctx, activityCollection, orgId, activity.Id, activity)
+}
+
+func (g *GoogleStore) ProjectActivity(ctx context.Context, orgId, projectId string) ([]Activity, error) {
+	collector := NewValidCollector[Activity]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, activityCollection, orgId, "projectId", projectId) {
+		collector.Push(doc)
+	}
+
+	// Prefix query also matches projects with longer ids
+	result := slices.DeleteFunc(collector.Items, func(a Activity) bool { return a.ProjectId != projectId })
+	SortActivity(result)
+	return result, collector.Err
+}
+
+func (g *GoogleStore) OrganizationActivity(ctx context.Context, orgId string, from, to time.Time) ([]Activity, error) {
+	collector := NewValidCollector[Activity]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, activityCollection, orgId, "projectId", "") {
+		collector.Push(doc)
+	}
+	result := slices.DeleteFunc(collector.Items, func(a Activity) bool { return !InTimeRange(a.Time, from, to) })
+	SortActivity(result)
+	return result, collector.Err
+}
+
+func (g *GoogleStore) RemoveActivity(ctx context.Context, orgId string, kind ActivityKind, before time.Time) (int, error) {
+	collector := NewValidCollector[Activity]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, activityCollection, orgId, "projectId", "") {
+		collector.Push(doc)
+	}
+
+	numRemoved := 0
+	err := collector.Err
+	for _, activity := range collector.Items {
+		if activity.Kind != kind || !activity.Time.Before(before) {
+			continue
+		}
+		if deleteErr := g.FsClient.DeleteDoc(ctx, activityCollection, orgId, activity.Id); deleteErr != nil {
+			err = errors.Join(err, deleteErr)
+			continue
+		}
+		numRemoved++
+	}
+	return numRemoved, err
+}
+
+func (g *GoogleStore) SubmitAnnouncement(ctx context.Context, orgId string, announcement *Announcement) error {
+	if err := announcement.Validate(); err != nil {
+		return err
+	}
+	return g.FsClient.StoreDocument(ctx, announcementCollection, orgId, announcement.Id, announcement)
+}
+
+func (g *GoogleStore) Announcements(ctx context.Context, orgId string) ([]Announcement, error) {
+	collector := NewValidCollector[Announcement]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, announcementCollection, orgId, "id", "") {
+		collector.Push(doc)
+	}
+	SortAnnouncements(collector.Items)
+	return collector.Items, collector.Err
+}
+
+func (g *GoogleStore) ExpireAnnouncement(ctx context.Context, orgId, id string, at time.Time) error {
+	err := g.FsClient.Update(ctx, announcementCollection, orgId, id, []firestore.Update{{Path: "expiresAt", Value: at}})
+	return classifyStoreErr(err, ErrAnnouncementNotFound)
+}
+
+func (g *GoogleStore) MarkAnnouncementRead(ctx context.Context, orgId, id, userId string) error {
+	err := g.FsClient.Update(ctx, announcementCollection, orgId, id, []firestore.Update{{Path: "readBy", Value: firestore.ArrayUnion(userId)}})
+	return classifyStoreErr(err, ErrAnnouncementNotFound)
+}
+
+func (g *GoogleStore) SubmitProjectTemplate(ctx context.Context, orgId string, template *ProjectTemplate) error {
+	if err := template.Validate(); err != nil {
+		return err
+	}
+	return g.FsClient.StoreDocument(ctx, projectTemplateCollection, orgId, template.Id(), template)
+}
+
+func (g *GoogleStore) ProjectTemplates(ctx context.Context, orgId string) ([]ProjectTemplate, error) {
+	collector := NewValidCollector[ProjectTemplate]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, projectTemplateCollection, orgId, "name", "") {
+		collector.Push(doc)
+	}
+	SortProjectTemplates(collector.Items)
+	return collector.Items, collector.Err
+}
+
+func (g *GoogleStore) ProjectTemplateById(ctx context.Context, orgId, id string) (*ProjectTemplate, error) {
+	doc, err := g.FsClient.GetDoc(ctx, projectTemplateCollection, orgId, id)
+	if err != nil {
+		return &ProjectTemplate{}, classifyStoreErr(err, ErrProjectTemplateNotFound)
+	}
+	var template ProjectTemplate
+	err = doc.DataTo(&template)
+	return &template, err
+}
+
+func (g *GoogleStore) DeleteProjectTemplate(ctx context.Context, orgId, id string) error {
+	if _, err := g.ProjectTemplateById(ctx, orgId, id); err != nil {
+		return err
+	}
+	return g.FsClient.DeleteDoc(ctx, projectTemplateCollection, orgId, id)
+}
+
+func (g *GoogleStore) SubmitProblemReport(ctx context.Context, orgId string, report *ProblemReport) error {
+	if err := report.Validate(); err != nil {
+		return err
+	}
+	return g.FsClient.StoreDocument(ctx, problemReportCollection, orgId, report.Id, report)
+}
+
+func (g *GoogleStore) ProblemReports(ctx context.Context, orgId string) ([]ProblemReport, error) {
+	collector := NewValidCollector[ProblemReport]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, problemReportCollection, orgId, "id", "") {
+		collector.Push(doc)
+	}
+	SortProblemReports(collector.Items)
+	return collector.Items, collector.Err
+}
+
+func (g *GoogleStore) SetProblemReportStatus(ctx context.Context, orgId, id string, status ProblemStatus, at time.Time) error {
+	if err := ValidateProblemStatus(status); err != nil {
+		return err
+	}
+	err := g.FsClient.Update(ctx, problemReportCollection, orgId, id, []firestore.Update{{Path: "status", Value: status}, {Path: "updatedAt", Value: at}})
+	return classifyStoreErr(err, ErrProblemReportNotFound)
+}
 
+func (g *GoogleStore) SavePasskey(ctx context.Context, passkey *Passkey) error {
+	if err := passkey.Validate(); err != nil {
+		return err
+	}
+	stored := *passkey
+	return g.FsClient.StoreDocument(ctx, userCollection, userPasskeyDoc, passkey.Id, &stored)
+}
+
+func (g *GoogleStore) Passkeys(ctx context.Context, userId string) ([]Passkey, error) {
+	collector := NewValidCollector[Passkey]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, userCollection, userPasskeyDoc, "userId", userId) {
+		collector.Push(doc)
+	}
+
+	// Prefix query also matches longer user ids
+	passkeys := slices.DeleteFunc(collector.Items, func(p Passkey) bool { return p.UserId != userId })
+	SortPasskeys(passkeys)
+	return passkeys, collector.Err
+}
+
+func (g *GoogleStore) DeletePasskey(ctx context.Context, userId, id string) error {
+	doc, err := g.FsClient.GetDoc(ctx, userCollection, userPasskeyDoc, id)
+	if err != nil {
+		return classifyStoreErr(err, ErrPasskeyNotFound)
+	}
+
+	var passkey Passkey
+	if err := doc.DataTo(&passkey); err != nil {
+		return err
+	}
+	if passkey.UserId != userId {
+		return passkeyNotFound(id)
+	}
+	return g.FsClient.DeleteDoc(ctx, userCollection, userPasskeyDoc, id)
+}
+
+func (g *GoogleStore) SaveApiToken(ctx context.Context, token *ApiToken) error {
+	if err := token.Validate(); err != nil {
+		return err
+	}
+	stored := *token
+	return g.FsClient.StoreDocument(ctx, userCollection, userApiTokenDoc, token.Hash, &stored)
+}
+
+func (g *GoogleStore) ApiTokenByHash(ctx context.Context, hash string) (ApiToken, error) {
+	var token ApiToken
+	doc, err := g.FsClient.GetDoc(ctx, userCollection, userApiTokenDoc, hash)
+	if err != nil {
+		return token, classifyStoreErr(err, ErrApiTokenNotFound)
+	}
+	return token, doc.DataTo(&token)
+}
+
+func (g *GoogleStore) ApiTokens(ctx context.Context, userId string) ([]ApiToken, error) {
+	collector := NewValidCollector[ApiToken]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, userCollection, userApiTokenDoc, "userId", userId) {
+		collector.Push(doc)
+	}
+
+	// Prefix query also matches longer user ids
+	tokens := slices.DeleteFunc(collector.Items, func(t ApiToken) bool { return t.UserId != userId })
+	SortApiTokens(tokens)
+	return tokens, collector.Err
+}
+
+func (g *GoogleStore) RevokeApiToken(ctx context.Context, userId, id string) error {
+	tokens, err := g.ApiTokens(ctx, userId)
+	if err != nil {
+		return err
+	}
+	idx := slices.IndexFunc(tokens, func(t ApiToken) bool { return t.Id == id })
+	if idx < 0 {
+		return apiTokenNotFound(id)
+	}
+	return g.FsClient.DeleteDoc(ctx, userCollection, userApiTokenDoc, tokens[idx].Hash)
+}
+
+func (g *GoogleStore) RegisterSession(ctx context.Context, session *UserSession) error {
+	if err := session.Validate(); err != nil {
+		return err
+	}
+	stored := *session
+	return g.FsClient.StoreDocument(ctx, userCollection, userSessionDoc, session.Id, &stored)
+}
+
+func (g *GoogleStore) Session(ctx context.Context, id string) (UserSession, error) {
+	var session UserSession
+	doc, err := g.FsClient.GetDoc(ctx, userCollection, userSessionDoc, id)
+	if err != nil {
+		return session, classifyStoreErr(err, userSessionNotFound(id))
+	}
+	return session, doc.DataTo(&session)
+}
+
+func (g *GoogleStore) Sessions(ctx context.Context, userId string) ([]UserSession, error) {
+	collector := NewValidCollector[UserSession]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, userCollection, userSessionDoc, "userId", userId) {
+		collector.Push(doc)
+	}
+
+	// Prefix query also matches longer user ids
+	sessions := slices.DeleteFunc(collector.Items, func(s UserSession) bool { return s.UserId != userId })
+	SortUserSessions(sessions)
+	return sessions, collector.Err
+}
+
+func (g *GoogleStore) RevokeSession(ctx context.Context, userId, id string) error {
+	session, err := g.Session(ctx, id)
+	if err != nil {
+		return err
+	}
+	if session.UserId != userId {
+		return userSessionNotFound(id)
+	}
+	return g.FsClient.DeleteDoc(ctx, userCollection, userSessionDoc, id)
+}
+
+func (g *GoogleStore) SessionGeneration(ctx context.Context, userId string) (int64, error) {
+	doc, err := g.FsClient.GetDoc(ctx, userCollection, sessionGenerationDoc, userId)
+	if status.Code(err) == codes.NotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, classifyStoreErr(err, ErrUserNotFound)
+	}
+
+	var generation SessionGeneration
+	err = doc.DataTo(&generation)
+	return generation.Generation, err
+}
+
+func (g *GoogleStore) NewSessionGeneration(ctx context.Context, userId string) (int64, error) {
+	generation := SessionGeneration{Generation: newSessionGeneration()}
+	return generation.Generation, g.FsClient.StoreDocument(ctx, userCollection, sessionGenerationDoc, userId, generation)
+}
+
+func (g *GoogleStore) seenHints(ctx context.Context, userId string) (SeenHints, error) {
+	var seen SeenHints
+	doc, err := g.FsClient.GetDoc(ctx, userCollection, userHintsDoc, userId)
+	if status.Code(err) == codes.NotFound {
+		return SeenHints{Hints: []Hint{}}, nil
+	} else if err != nil {
+		return seen, classifyStoreErr(err, ErrUserNotFound)
+	}
+	return seen, doc.DataTo(&seen)
+}
+
+func (g *GoogleStore) SeenHints(ctx context.Context, userId string) ([]Hint, error) {
+	seen, err := g.seenHints(ctx, userId)
+	return append([]Hint{}, seen.Hints...), err
+}
+
+func (g *GoogleStore) MarkHintSeen(ctx context.Context, userId string, hint Hint) error {
+	if err := ValidateHint(hint); err != nil {
+		return err
+	}
+	seen, err := g.seenHints(ctx, userId)
+	if err != nil || slices.Contains(seen.Hints, hint) {
+		return err
+	}
+	seen.Hints = append(seen.Hints, hint)
+	seen.UpdatedAt = time.Now()
+	return g.FsClient.StoreDocument(ctx, userCollection, userHintsDoc, userId, &seen)
+}
+
+func (g *GoogleStore) StoreResourceText(ctx context.Context, orgId string, text *ResourceText) error {
+	return g.FsClient.StoreDocument(ctx, resourceTextCollection, orgId, text.ResourceId, text)
+}
+
+func (g *GoogleStore) ResourceTexts(ctx context.Context, orgId string) ([]ResourceText, error) {
+	collector := NewValidCollector[ResourceText]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, resourceTextCollection, orgId, "resourceId", "") {
+		collector.Push(doc)
+	}
+	return collector.Items, collector.Err
 }
 
 func uniqueErrors(possibleErrors []error) error {
@@ -510,3 +1498,39 @@ type FirestoreProject struct {
 func linkId(userId, orgId string) string {
 	return userId + "-" + orgId
 }
+
+func (g *GoogleStore) RecentProjects(ctx context.Context, orgId string, limit int) ([]Project, error) {
+	projects, err := g.ProjectsByName(ctx, orgId, "")
+	return SortRecentProjects(projects, limit), err
+}
+
+func (g *GoogleStore) RecentResources(ctx context.Context, orgId string, limit int) ([]MetaData, error) {
+	collector := NewValidCollector[MetaData]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, metaDataCollection, orgId, "title_search", "") {
+		collector.Push(doc)
+	}
+	return SortRecentResources(collector.Items, limit), collector.Err
+}
+
+func (g *GoogleStore) NumMembers(ctx context.Context, orgId string) (int, error) {
+	collector := NewValidCollector[UserOrganizationLink]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, userCollection, userOrgLinkDoc, "orgId", orgId) {
+		collector.Push(doc)
+	}
+	links := slices.DeleteFunc(collector.Items, func(link UserOrganizationLink) bool { return link.Deleted })
+	return len(links), collector.Err
+}
+
+func (g *GoogleStore) Onboarding(ctx context.Context, orgId string) (*Onboarding, error) {
+	onboarding := NewOnboarding()
+	doc, err := g.FsClient.GetDoc(ctx, organizationCollection, organizationOnboarding, orgId)
+	if err != nil {
+		return onboarding, classifyStoreErr(err, ErrOnboardingNotFound)
+	}
+	err = doc.DataTo(onboarding)
+	return onboarding, err
+}
+
+func (g *GoogleStore) SaveOnboarding(ctx context.Context, orgId string, onboarding *Onboarding) error {
+	return g.FsClient.StoreDocument(ctx, organizationCollection, organizationOnboarding, orgId, onboarding)
+}
@@ -14,24 +14,51 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"cloud.google.com/go/storage"
 	"github.com/davidkleiven/caesura/testutils"
 	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type LocalBucketClient struct {
-	buckets map[string]io.ReadCloser
-	mutex   sync.Mutex
+	buckets        map[string]io.ReadCloser
+	storageClasses map[string]string
+	mutex          sync.Mutex
 }
 
 func NewLocalBucketClient() *LocalBucketClient {
 	return &LocalBucketClient{
-		buckets: make(map[string]io.ReadCloser),
+		buckets:        make(map[string]io.ReadCloser),
+		storageClasses: make(map[string]string),
 	}
 }
 
+func (l *LocalBucketClient) SetStorageClass(ctx context.Context, bucket, object, class string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	location := path.Join(bucket, object)
+	if _, ok := l.buckets[location]; !ok {
+		return fmt.Errorf("%s not found", location)
+	}
+	l.storageClasses[location] = class
+	return nil
+}
+
+func (l *LocalBucketClient) Delete(ctx context.Context, bucket, object string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	location := path.Join(bucket, object)
+	if _, ok := l.buckets[location]; !ok {
+		return fmt.Errorf("%s: %w", location, storage.ErrObjectNotExist)
+	}
+	delete(l.buckets, location)
+	return nil
+}
+
 func (l *LocalBucketClient) Upload(ctx context.Context, bucket, object string, data []byte) error {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
@@ -44,7 +71,7 @@ func (l *LocalBucketClient) GetObject(ctx context.Context, bucket, objName strin
 	location := path.Join(bucket, objName)
 	data, ok := l.buckets[location]
 	if !ok {
-		return nil, fmt.Errorf("%s not found", location)
+		return nil, fmt.Errorf("%s: %w", location, storage.ErrObjectNotExist)
 	}
 	return data, nil
 }
@@ -79,6 +106,14 @@ type FailingBucketClient struct {
 	objReadErr error
 }
 
+func (f *FailingBucketClient) SetStorageClass(ctx context.Context, bucket, object, class string) error {
+	return nil
+}
+
+func (f *FailingBucketClient) Delete(ctx context.Context, bucket, object string) error {
+	return nil
+}
+
 func (f *FailingBucketClient) Upload(ctx context.Context, bucket, object string, data []byte) error {
 	return f.uploadErr
 }
@@ -92,6 +127,14 @@ func (f *FailingBucketClient) GetObjects(ctx context.Context, bucket string, que
 	return &LocalObjectLister{items: []storage.ObjectAttrs{}}
 }
 
+type failingStorageClassClient struct {
+	*LocalBucketClient
+}
+
+func (f *failingStorageClassClient) SetStorageClass(ctx context.Context, bucket, object, class string) error {
+	return errors.New("could not change storage class")
+}
+
 type SubmitTestData struct {
 	store GoogleStore
 	orgId string
@@ -99,7 +142,7 @@ type SubmitTestData struct {
 	data  iter.Seq2[string, []byte]
 }
 
-func createSubmitData(bucketClient GoogleBucketClient, fsClient FirestoreClient) *SubmitTestData {
+func createSubmitData(bucketClient BlobClient, fsClient FirestoreClient) *SubmitTestData {
 	config := NewTestConfig()
 	store := GoogleStore{
 		Config:       config,
@@ -169,7 +212,7 @@ func TestGoogleSubmitBucketUploadError(t *testing.T) {
 
 	casted, ok := data.(*FirestoreMetaData)
 	testutils.AssertEqual(t, ok, true)
-	testutils.AssertEqual(t, casted.Status, StoreStatusPending)
+	testutils.AssertEqual(t, casted.Status, StoreStatusFailed)
 }
 
 type FailingFirestoreClient struct {
@@ -191,7 +234,14 @@ func (f *FailingFirestoreClient) GetDocByPrefix(ctx context.Context, dataset, or
 	return func(yield func(doc Document) bool) {}
 }
 
+func (f *FailingFirestoreClient) GetDocPage(ctx context.Context, dataset, orgId, after string, limit int) iter.Seq[Document] {
+	return func(yield func(doc Document) bool) {}
+}
+
 func (f *FailingFirestoreClient) GetDoc(ctx context.Context, dataset, orgId, itemid string) (Document, error) {
+	if f.errGetDoc == nil {
+		return nil, status.Errorf(codes.NotFound, "%s not found", itemid)
+	}
 	return nil, f.errGetDoc
 }
 
@@ -321,6 +371,26 @@ func TestRemoveResourceFromProject(t *testing.T) {
 	}
 }
 
+func TestGoogleStoreProjectNotes(t *testing.T) {
+	project := Project{Name: "project", ResourceIds: []string{"id1", "id2"}}
+	store := GoogleStore{FsClient: NewLocalFirestoreClient()}
+	ctx := context.Background()
+	testutils.AssertNil(t, store.SubmitProject(ctx, "my-org", &project))
+
+	testutils.AssertNil(t, store.SetProjectNote(ctx, "my-org", "project", "id1", "Start at letter C"))
+	testutils.AssertNil(t, store.SetProjectNote(ctx, "my-org", "project", "id2", "Repeat the coda"))
+	stored, err := store.ProjectById(ctx, "my-org", "project")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Notes["id1"], "Start at letter C")
+	testutils.AssertEqual(t, stored.Notes["id2"], "Repeat the coda")
+
+	testutils.AssertNil(t, store.SetProjectNote(ctx, "my-org", "project", "id1", ""))
+	testutils.AssertNil(t, store.RemoveResource(ctx, "my-org", "project", "id2"))
+	stored, err = store.ProjectById(ctx, "my-org", "project")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(stored.Notes), 0)
+}
+
 func TestGoogleMetaById(t *testing.T) {
 	store, err := storeWithMetaData()
 	testutils.AssertNil(t, err)
@@ -342,6 +412,19 @@ func TestGoogleMetaById(t *testing.T) {
 	})
 }
 
+func TestGoogleMetaByIds(t *testing.T) {
+	store, err := storeWithMetaData()
+	testutils.AssertNil(t, err)
+
+	metaId := "withasmileandasong_frankchurchill_unknown"
+	lookups := store.MetaByIds(context.Background(), "my-org", []string{"non-existing", metaId})
+	testutils.AssertEqual(t, len(lookups), 2)
+	testutils.AssertEqual(t, lookups[0].Id, "non-existing")
+	testutils.AssertEqual(t, errors.Is(lookups[0].Err, ErrResourceMetadataNotFound), true)
+	testutils.AssertNil(t, lookups[1].Err)
+	testutils.AssertContains(t, lookups[1].Meta.Title, "With a ")
+}
+
 func TestResource(t *testing.T) {
 	validContent1 := bytes.NewBufferString("content1")
 	validContent2 := bytes.NewBufferString("content3")
@@ -372,6 +455,186 @@ func TestResource(t *testing.T) {
 	}
 }
 
+func TestGoogleStoreOpenPart(t *testing.T) {
+	client := &FileBucketClient{Directory: t.TempDir()}
+	store := GoogleStore{BucketClient: client, Config: &GoogleConfig{Bucket: "test"}}
+	ctx := context.Background()
+	testutils.AssertNil(t, client.Upload(ctx, "test", "org/polka/Horn.pdf", []byte("horn part")))
+
+	part, err := store.OpenPart(ctx, "org", "polka", "Horn.pdf")
+	testutils.AssertNil(t, err)
+	defer part.Close()
+	size, err := part.Seek(0, io.SeekEnd)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, size, int64(len("horn part")))
+
+	_, err = part.Seek(5, io.SeekStart)
+	testutils.AssertNil(t, err)
+	content, err := io.ReadAll(part)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, string(content), "part")
+
+	_, err = store.OpenPart(ctx, "org", "polka", "Trumpet.pdf")
+	testutils.AssertEqual(t, errors.Is(err, ErrFileNotFound), true)
+
+	unranged := GoogleStore{BucketClient: NewLocalBucketClient(), Config: &GoogleConfig{Bucket: "test"}}
+	_, err = unranged.OpenPart(ctx, "org", "polka", "Horn.pdf")
+	testutils.AssertEqual(t, errors.Is(err, ErrRangeReadUnsupported), true)
+}
+
+func TestGoogleTransitionStorageClass(t *testing.T) {
+	client := NewLocalBucketClient()
+	fsClient := NewLocalFirestoreClient()
+	submitData := createSubmitData(client, fsClient)
+	ctx := context.Background()
+	err := submitData.store.Submit(ctx, submitData.orgId, submitData.meta, submitData.data)
+	testutils.AssertNil(t, err)
+
+	resourceId := submitData.meta.ResourceId()
+	err = submitData.store.TransitionStorageClass(ctx, submitData.orgId, resourceId, StorageClassCold)
+	testutils.AssertNil(t, err)
+
+	testutils.AssertEqual(t, len(client.storageClasses), 2)
+	for _, class := range client.storageClasses {
+		testutils.AssertEqual(t, class, "COLDLINE")
+	}
+
+	meta, err := submitData.store.MetaById(ctx, submitData.orgId, resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, meta.StorageClass, StorageClassCold)
+
+	t.Run("bucket error", func(t *testing.T) {
+		store := GoogleStore{
+			BucketClient: &failingStorageClassClient{LocalBucketClient: client},
+			FsClient:     fsClient,
+			Config:       submitData.store.Config,
+		}
+		err := store.TransitionStorageClass(ctx, submitData.orgId, resourceId, StorageClassStandard)
+		if err == nil {
+			t.Fatal("Wanted error")
+		}
+
+		meta, err := store.MetaById(ctx, submitData.orgId, resourceId)
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, meta.StorageClass, StorageClassCold)
+	})
+}
+
+func TestGoogleProtectedResource(t *testing.T) {
+	client := NewLocalBucketClient()
+	fsClient := NewLocalFirestoreClient()
+	submitData := createSubmitData(client, fsClient)
+	ctx := context.Background()
+	err := submitData.store.Submit(ctx, submitData.orgId, submitData.meta, submitData.data)
+	testutils.AssertNil(t, err)
+
+	resourceId := submitData.meta.ResourceId()
+	testutils.AssertNil(t, submitData.store.SetProtected(ctx, submitData.orgId, resourceId, true))
+
+	err = submitData.store.Submit(ctx, submitData.orgId, submitData.meta, submitData.data)
+	if !errors.Is(err, ErrResourceProtected) {
+		t.Fatalf("Wanted ErrResourceProtected got %v", err)
+	}
+
+	err = submitData.store.DeleteResource(ctx, submitData.orgId, resourceId)
+	if !errors.Is(err, ErrResourceProtected) {
+		t.Fatalf("Wanted ErrResourceProtected got %v", err)
+	}
+
+	testutils.AssertNil(t, submitData.store.SetProtected(ctx, submitData.orgId, resourceId, false))
+	testutils.AssertNil(t, submitData.store.DeleteResource(ctx, submitData.orgId, resourceId))
+
+	meta, err := submitData.store.MetaById(ctx, submitData.orgId, resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, meta.Deleted, true)
+
+	t.Run("metadata lookup error", func(t *testing.T) {
+		store := GoogleStore{
+			BucketClient: client,
+			FsClient:     &FailingFirestoreClient{errGetDoc: errors.New("unavailable")},
+			Config:       submitData.store.Config,
+		}
+		if err := store.DeleteResource(ctx, submitData.orgId, resourceId); err == nil {
+			t.Fatal("Wanted error")
+		}
+	})
+}
+
+type transactionCountingClient struct {
+	*LocalFirestoreClient
+	numTransactions int
+}
+
+func (c *transactionCountingClient) RunTransaction(ctx context.Context, fn func(ctx context.Context, client FirestoreClient) error) error {
+	c.numTransactions++
+	return c.LocalFirestoreClient.RunTransaction(ctx, fn)
+}
+
+func TestGoogleFeatureCounts(t *testing.T) {
+	store := GoogleStore{FsClient: NewLocalFirestoreClient()}
+	ctx := context.Background()
+	now := time.Date(2025, 6, 11, 0, 0, 0, 0, time.UTC)
+
+	testutils.AssertNil(t, store.CountFeature(ctx, "org1", FeatureUpload, now))
+	testutils.AssertNil(t, store.CountFeature(ctx, "org1", FeatureUpload, now))
+	testutils.AssertNil(t, store.CountFeature(ctx, "org1", FeatureDownload, now.AddDate(-1, 0, 0)))
+
+	counts, err := store.FeatureCounts(ctx, now.AddDate(0, 0, -7))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(counts), 1)
+	testutils.AssertEqual(t, counts[0].Count, 2)
+	testutils.AssertEqual(t, counts[0].Feature, FeatureUpload)
+
+	t.Run("first count is created in a transaction", func(t *testing.T) {
+		client := transactionCountingClient{LocalFirestoreClient: NewLocalFirestoreClient()}
+		store := GoogleStore{FsClient: &client}
+		testutils.AssertNil(t, store.CountFeature(ctx, "org1", FeatureUpload, now))
+		testutils.AssertNil(t, store.CountFeature(ctx, "org1", FeatureUpload, now))
+		testutils.AssertEqual(t, client.numTransactions, 2)
+
+		counts, err := store.FeatureCounts(ctx, now)
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, counts[0].Count, 2)
+	})
+
+	t.Run("update error", func(t *testing.T) {
+		failing := GoogleStore{FsClient: &FailingFirestoreClient{errUpdateField: errors.New("unavailable")}}
+		if err := failing.CountFeature(ctx, "org1", FeatureUpload, now); err == nil {
+			t.Fatal("Wanted error")
+		}
+	})
+}
+
+func TestGoogleRecordAccess(t *testing.T) {
+	client := NewLocalBucketClient()
+	fsClient := NewLocalFirestoreClient()
+	submitData := createSubmitData(client, fsClient)
+	ctx := context.Background()
+	err := submitData.store.Submit(ctx, submitData.orgId, submitData.meta, submitData.data)
+	testutils.AssertNil(t, err)
+
+	resourceId := submitData.meta.ResourceId()
+	at := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	err = submitData.store.RecordAccess(ctx, submitData.orgId, resourceId, at)
+	testutils.AssertNil(t, err)
+
+	meta, err := submitData.store.MetaById(ctx, submitData.orgId, resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, meta.LastAccessed, at)
+}
+
+func TestGoogleListOrganizations(t *testing.T) {
+	store := GoogleStore{FsClient: NewLocalFirestoreClient()}
+	ctx := context.Background()
+	for _, id := range []string{"org1", "org2"} {
+		testutils.AssertNil(t, store.RegisterOrganization(ctx, &Organization{Id: id}))
+	}
+
+	orgs, err := store.ListOrganizations(ctx)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(orgs), 2)
+}
+
 func TestGoogleItem(t *testing.T) {
 	client := NewLocalBucketClient()
 
@@ -730,3 +993,191 @@ func TestGoogleResetPassword(t *testing.T) {
 	testutils.AssertNil(t, err)
 	testutils.AssertEqual(t, receivedUser.Password, "new-top-secret-password")
 }
+
+func TestGoogleUpdateBranding(t *testing.T) {
+	store := GoogleStore{FsClient: NewLocalFirestoreClient()}
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &Organization{Id: "my-org"}))
+
+	branding := Branding{PrimaryColor: "#112233", LogoURL: "https://example.com/logo.png"}
+	testutils.AssertNil(t, store.UpdateBranding(ctx, "my-org", branding))
+
+	org, err := store.GetOrganization(ctx, "my-org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, org.Branding, branding)
+}
+
+func TestGoogleDomain(t *testing.T) {
+	store := GoogleStore{FsClient: NewLocalFirestoreClient()}
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &Organization{Id: "org1"}))
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &Organization{Id: "org2", Domain: "music.example.com.au"}))
+
+	_, err := store.OrganizationByDomain(ctx, "music.example.com")
+	testutils.AssertEqual(t, errors.Is(err, ErrOrganizationNotFound), true)
+
+	testutils.AssertNil(t, store.UpdateDomain(ctx, "org1", "music.example.com"))
+	org, err := store.OrganizationByDomain(ctx, "music.example.com")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, org.Id, "org1")
+
+	err = store.UpdateDomain(ctx, "org2", "music.example.com")
+	testutils.AssertEqual(t, errors.Is(err, ErrDomainInUse), true)
+
+	_, err = store.OrganizationByDomain(ctx, "")
+	testutils.AssertEqual(t, errors.Is(err, ErrOrganizationNotFound), true)
+}
+
+func TestGoogleUpdateMetaData(t *testing.T) {
+	store := GoogleStore{FsClient: NewLocalFirestoreClient()}
+	ctx := context.Background()
+	meta := MetaData{Title: "Title", Composer: "Composer"}
+	testutils.AssertNil(t, store.FsClient.StoreDocument(ctx, metaDataCollection, "org", meta.ResourceId(), &FirestoreMetaData{MetaData: meta}))
+
+	meta.Genre = "Rock"
+	testutils.AssertNil(t, store.UpdateMetaData(ctx, "org", &meta))
+
+	stored, err := store.MetaById(ctx, "org", meta.ResourceId())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Genre, "Rock")
+
+	results, err := store.MetaByPattern(ctx, "org", &MetaData{Title: "tit"})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(results), 1)
+
+	err = store.UpdateMetaData(ctx, "org", &MetaData{Title: "Unknown"})
+	testutils.AssertEqual(t, errors.Is(err, ErrResourceMetadataNotFound), true)
+}
+
+func TestGoogleProjectActivity(t *testing.T) {
+	store := GoogleStore{FsClient: NewLocalFirestoreClient()}
+	ctx := context.Background()
+
+	first := NewActivity("project", ActivityPieceAdded, "user", []string{"a"})
+	second := NewActivity("project", ActivityDownload, "user", []string{"a"})
+	second.Time = first.Time.Add(time.Minute)
+	longer := NewActivity("project2", ActivityDownload, "user", []string{"a"})
+	for _, activity := range []*Activity{first, second, longer} {
+		testutils.AssertNil(t, store.RecordActivity(ctx, "org", activity))
+	}
+
+	activities, err := store.ProjectActivity(ctx, "org", "project")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(activities), 2)
+	testutils.AssertEqual(t, activities[0].Id, second.Id)
+	testutils.AssertEqual(t, activities[1].Id, first.Id)
+}
+
+func TestGoogleGetUsersInOrgFromSnapshots(t *testing.T) {
+	fsClient := NewLocalFirestoreClient()
+	store := GoogleStore{FsClient: fsClient}
+	ctx := context.Background()
+
+	user := UserInfo{
+		Id:     "user1",
+		Name:   "Susan",
+		Email:  "susan@example.com",
+		Roles:  map[string]RoleKind{"org1": RoleEditor, "org2": RoleViewer},
+		Groups: map[string][]string{"org1": {"Alto"}},
+	}
+	testutils.AssertNil(t, store.RegisterUser(ctx, &user))
+
+	// The user document is not needed when the links carry a snapshot
+	testutils.AssertNil(t, fsClient.DeleteDoc(ctx, userCollection, userInfoDoc, "user1"))
+	members, err := store.GetUsersInOrg(ctx, "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(members), 1)
+	testutils.AssertEqual(t, members[0].Name, "Susan")
+	testutils.AssertEqual(t, members[0].Email, "susan@example.com")
+	testutils.AssertEqual(t, members[0].Roles["org1"], RoleEditor)
+	testutils.AssertEqual(t, members[0].Groups["org1"][0], "Alto")
+
+	t.Run("user changes update all links", func(t *testing.T) {
+		renamed := UserInfo{Id: "user1", Name: "Susanne", Email: "susanne@example.com", Roles: map[string]RoleKind{"org1": RoleEditor}}
+		testutils.AssertNil(t, store.RegisterUser(ctx, &renamed))
+
+		members, err := store.GetUsersInOrg(ctx, "org2")
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, len(members), 1)
+		testutils.AssertEqual(t, members[0].Name, "Susanne")
+		testutils.AssertEqual(t, members[0].Email, "susanne@example.com")
+		testutils.AssertEqual(t, members[0].Roles["org2"], RoleViewer)
+	})
+
+	t.Run("new role copies the user", func(t *testing.T) {
+		testutils.AssertNil(t, store.RegisterRole(ctx, "user1", "org3", RoleAdmin))
+		members, err := store.GetUsersInOrg(ctx, "org3")
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, len(members), 1)
+		testutils.AssertEqual(t, members[0].Name, "Susanne")
+	})
+
+	t.Run("links without snapshot", func(t *testing.T) {
+		legacy := User{Id: "legacy", Name: "Old", Email: "old@example.com"}
+		testutils.AssertNil(t, fsClient.StoreDocument(ctx, userCollection, userInfoDoc, legacy.Id, legacy))
+		link := UserOrganizationLink{UserId: legacy.Id, OrgId: "org4", Role: RoleViewer}
+		testutils.AssertNil(t, fsClient.StoreDocument(ctx, userCollection, userOrgLinkDoc, linkId(legacy.Id, "org4"), link))
+
+		members, err := store.GetUsersInOrg(ctx, "org4")
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, len(members), 1)
+		testutils.AssertEqual(t, members[0].Email, "old@example.com")
+	})
+}
+
+func TestGoogleStoreWrapsNotFoundErrors(t *testing.T) {
+	store := GoogleStore{
+		FsClient:     NewLocalFirestoreClient(),
+		BucketClient: NewLocalBucketClient(),
+		Config:       &GoogleConfig{Bucket: "test"},
+	}
+	ctx := context.Background()
+
+	_, errMeta := store.MetaById(ctx, "org", "missing")
+	_, errProject := store.ProjectById(ctx, "org", "missing")
+	_, errOrg := store.GetOrganization(ctx, "missing")
+	_, errUser := store.GetUserInfo(ctx, "missing")
+	_, errSubscription := store.GetSubscription(ctx, "missing")
+	_, errItem := store.Item(ctx, "org/resource/part.pdf")
+
+	for _, test := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"MetaById", errMeta, ErrResourceMetadataNotFound},
+		{"SetProtected", store.SetProtected(ctx, "org", "missing", true), ErrResourceMetadataNotFound},
+		{"RecordAccess", store.RecordAccess(ctx, "org", "missing", time.Now()), ErrResourceMetadataNotFound},
+		{"DeleteResource", store.DeleteResource(ctx, "org", "missing"), ErrResourceMetadataNotFound},
+		{"ProjectById", errProject, ErrProjectNotFound},
+		{"RemoveResource", store.RemoveResource(ctx, "org", "missing", "resource"), ErrProjectNotFound},
+		{"SetProjectNote", store.SetProjectNote(ctx, "org", "missing", "resource", "note"), ErrProjectNotFound},
+		{"GetOrganization", errOrg, ErrOrganizationNotFound},
+		{"UpdateBranding", store.UpdateBranding(ctx, "missing", Branding{}), ErrOrganizationNotFound},
+		{"GetUserInfo", errUser, ErrUserNotFound},
+		{"RegisterGroup", store.RegisterGroup(ctx, "missing", "org", "group"), ErrUserNotFound},
+		{"GetSubscription", errSubscription, ErrSubscriptionNotFound},
+		{"Item", errItem, ErrResourceNotFound},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if !errors.Is(test.err, test.want) {
+				t.Fatalf("Wanted %v got %v", test.want, test.err)
+			}
+			testutils.AssertEqual(t, IsNotFound(test.err), true)
+		})
+	}
+}
+
+func TestGoogleStoreUnavailable(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection reset")
+	store := GoogleStore{FsClient: &FailingFirestoreClient{errGetDoc: unavailable, errUpdateField: unavailable}}
+	ctx := context.Background()
+
+	_, err := store.MetaById(ctx, "org", "resource")
+	testutils.AssertEqual(t, errors.Is(err, ErrStoreUnavailable), true)
+	testutils.AssertEqual(t, IsNotFound(err), false)
+
+	err = store.SetProtected(ctx, "org", "resource", true)
+	testutils.AssertEqual(t, errors.Is(err, ErrStoreUnavailable), true)
+	testutils.AssertEqual(t, status.Code(err), codes.Unavailable)
+}
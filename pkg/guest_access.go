@@ -0,0 +1,207 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const guestGrantCollection = "guest_grants"
+
+// MaxGuestAccess is the longest a guest can be given access, which covers the judging of a festival
+const MaxGuestAccess = 90 * 24 * time.Hour
+
+const maxWatermarkLength = 120
+
+type GuestGrantStatus string
+
+const (
+	GuestGrantActive  GuestGrantStatus = "active"
+	GuestGrantExpired GuestGrantStatus = "expired"
+	GuestGrantRevoked GuestGrantStatus = "revoked"
+)
+
+// GuestGrant gives a person outside the organization, such as a festival jury, access to some resources or the
+// resources of a project until it expires. The guest is not a member, and only gets a signed link holding the id
+// of the grant. The grant is looked up on every request, such that it can be revoked
+type GuestGrant struct {
+	Id          string   `json:"id" firestore:"id"`
+	OrgId       string   `json:"orgId" firestore:"orgId"`
+	Email       string   `json:"email" firestore:"email"`
+	ResourceIds []string `json:"resourceIds" firestore:"resourceIds"`
+	ProjectId   string   `json:"projectId" firestore:"projectId"`
+
+	// Watermark is stamped on every page of the parts the guest downloads. Empty leaves the parts unchanged
+	Watermark string    `json:"watermark" firestore:"watermark"`
+	CreatedBy string    `json:"createdBy" firestore:"createdBy"`
+	CreatedAt time.Time `json:"createdAt" firestore:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt" firestore:"expiresAt"`
+	RevokedAt time.Time `json:"revokedAt" firestore:"revokedAt"`
+	RevokedBy string    `json:"revokedBy" firestore:"revokedBy"`
+}
+
+func NewGuestGrant(orgId, createdBy, email string, resourceIds []string, projectId string, expiresAt time.Time, watermark string) *GuestGrant {
+	return &GuestGrant{
+		Id:          uuid.NewString(),
+		OrgId:       orgId,
+		Email:       strings.ToLower(strings.TrimSpace(email)),
+		ResourceIds: resourceIds,
+		ProjectId:   projectId,
+		Watermark:   strings.TrimSpace(watermark),
+		CreatedBy:   createdBy,
+		CreatedAt:   time.Now(),
+		ExpiresAt:   expiresAt,
+	}
+}
+
+func (g *GuestGrant) Validate() error {
+	switch {
+	case g.Id == "" || g.OrgId == "":
+		return errors.Join(ErrInvalidGuestGrant, errors.New("guest access must have an id and an organization"))
+	case !strings.Contains(g.Email, "@"):
+		return errors.Join(ErrInvalidGuestGrant, fmt.Errorf("invalid email address %q", g.Email))
+	case (len(g.ResourceIds) == 0) == (g.ProjectId == ""):
+		return errors.Join(ErrInvalidGuestGrant, errors.New("guest access must be given to either resources or a project"))
+	case !g.ExpiresAt.After(g.CreatedAt):
+		return errors.Join(ErrInvalidGuestGrant, errors.New("guest access must expire after it is given"))
+	case g.ExpiresAt.Sub(g.CreatedAt) > MaxGuestAccess:
+		return errors.Join(ErrInvalidGuestGrant, fmt.Errorf("guest access can last at most %d days", int(MaxGuestAccess.Hours()/24)))
+	case len(g.Watermark) > maxWatermarkLength:
+		return errors.Join(ErrInvalidGuestGrant, fmt.Errorf("watermark can be at most %d characters", maxWatermarkLength))
+	}
+	return nil
+}
+
+// StatusAt returns the status of the grant at now. Revoked grants stay revoked after they would have expired
+func (g *GuestGrant) StatusAt(now time.Time) GuestGrantStatus {
+	switch {
+	case !g.RevokedAt.IsZero():
+		return GuestGrantRevoked
+	case !now.Before(g.ExpiresAt):
+		return GuestGrantExpired
+	default:
+		return GuestGrantActive
+	}
+}
+
+// LastDay is the last date the guest has access, since grants expire at the start of the day after
+func (g *GuestGrant) LastDay() string {
+	return g.ExpiresAt.Add(-time.Nanosecond).Format(time.DateOnly)
+}
+
+type GuestGrantStore interface {
+	// SaveGuestGrant inserts the grant or updates the project and revocation of an existing grant
+	SaveGuestGrant(ctx context.Context, grant *GuestGrant) error
+
+	// GuestGrant returns ErrGuestGrantNotFound when there is no grant with the id in the organization
+	GuestGrant(ctx context.Context, orgId, id string) (*GuestGrant, error)
+
+	// GuestGrants returns the guest grants of the organization, newest first
+	GuestGrants(ctx context.Context, orgId string) ([]GuestGrant, error)
+}
+
+// RevokeGuestGrant stops the guest from using the link. Revoking a revoked grant does nothing
+func RevokeGuestGrant(ctx context.Context, store GuestGrantStore, orgId, id, userId string, now time.Time) (*GuestGrant, error) {
+	grant, err := store.GuestGrant(ctx, orgId, id)
+	if err != nil || !grant.RevokedAt.IsZero() {
+		return grant, err
+	}
+	grant.RevokedAt = now
+	grant.RevokedBy = userId
+	return grant, store.SaveGuestGrant(ctx, grant)
+}
+
+// ActiveGuestGrant returns ErrGuestGrantInactive when the grant is revoked or has expired
+func ActiveGuestGrant(ctx context.Context, store GuestGrantStore, orgId, id string, now time.Time) (*GuestGrant, error) {
+	grant, err := store.GuestGrant(ctx, orgId, id)
+	if err != nil {
+		return grant, err
+	}
+	if status := grant.StatusAt(now); status != GuestGrantActive {
+		return grant, errors.Join(ErrGuestGrantInactive, fmt.Errorf("guest access %s is %s", id, status))
+	}
+	return grant, nil
+}
+
+// GuestResourceIds returns the resources the guest can access. The resources of a project are looked up on every
+// request, such that pieces added to the project are shared with the guest as well
+func GuestResourceIds(ctx context.Context, store ProjectByIdGetter, grant *GuestGrant) ([]string, error) {
+	if grant.ProjectId == "" {
+		return grant.ResourceIds, nil
+	}
+	project, err := store.ProjectById(ctx, grant.OrgId, grant.ProjectId)
+	if err != nil {
+		return nil, err
+	}
+	return project.ResourceIds, nil
+}
+
+// SortGuestGrants orders the grants with the newest first
+func SortGuestGrants(grants []GuestGrant) {
+	slices.SortStableFunc(grants, func(a, b GuestGrant) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+}
+
+func guestGrantNotFound(id string) error {
+	return errors.Join(ErrGuestGrantNotFound, fmt.Errorf("guest access id: %s", id))
+}
+
+func (g *GoogleStore) SaveGuestGrant(ctx context.Context, grant *GuestGrant) error {
+	if err := grant.Validate(); err != nil {
+		return err
+	}
+	return g.FsClient.StoreDocument(ctx, guestGrantCollection, grant.OrgId, grant.Id, grant)
+}
+
+func (g *GoogleStore) GuestGrant(ctx context.Context, orgId, id string) (*GuestGrant, error) {
+	doc, err := g.FsClient.GetDoc(ctx, guestGrantCollection, orgId, id)
+	if err != nil {
+		return &GuestGrant{}, classifyStoreErr(err, ErrGuestGrantNotFound)
+	}
+	var grant GuestGrant
+	err = doc.DataTo(&grant)
+	return &grant, err
+}
+
+func (g *GoogleStore) GuestGrants(ctx context.Context, orgId string) ([]GuestGrant, error) {
+	collector := NewValidCollector[GuestGrant]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, guestGrantCollection, orgId, "id", "") {
+		collector.Push(doc)
+	}
+	SortGuestGrants(collector.Items)
+	return collector.Items, collector.Err
+}
+
+func (m *MultiOrgInMemoryStore) SaveGuestGrant(ctx context.Context, grant *GuestGrant) error {
+	if err := grant.Validate(); err != nil {
+		return err
+	}
+	m.OrgGuestGrants[grant.OrgId] = slices.DeleteFunc(m.OrgGuestGrants[grant.OrgId], func(g GuestGrant) bool { return g.Id == grant.Id })
+	m.OrgGuestGrants[grant.OrgId] = append(m.OrgGuestGrants[grant.OrgId], *grant)
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) GuestGrant(ctx context.Context, orgId, id string) (*GuestGrant, error) {
+	idx := slices.IndexFunc(m.OrgGuestGrants[orgId], func(g GuestGrant) bool { return g.Id == id })
+	if idx == -1 {
+		return &GuestGrant{}, guestGrantNotFound(id)
+	}
+	grant := m.OrgGuestGrants[orgId][idx]
+	grant.ResourceIds = slices.Clone(grant.ResourceIds)
+	return &grant, nil
+}
+
+func (m *MultiOrgInMemoryStore) GuestGrants(ctx context.Context, orgId string) ([]GuestGrant, error) {
+	result := slices.Clone(m.OrgGuestGrants[orgId])
+	if result == nil {
+		result = []GuestGrant{}
+	}
+	SortGuestGrants(result)
+	return result, nil
+}
@@ -0,0 +1,132 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestGuestGrantValidate(t *testing.T) {
+	week := time.Now().Add(7 * 24 * time.Hour)
+	for _, test := range []struct {
+		desc  string
+		grant *GuestGrant
+		valid bool
+	}{
+		{"resources", NewGuestGrant("org", "user", " Jury@Example.com ", []string{"bolero_ravel"}, "", week, "Jury copy"), true},
+		{"project", NewGuestGrant("org", "user", "jury@example.com", nil, "project", week, ""), true},
+		{"invalid email", NewGuestGrant("org", "user", "jury", []string{"bolero_ravel"}, "", week, ""), false},
+		{"nothing shared", NewGuestGrant("org", "user", "jury@example.com", nil, "", week, ""), false},
+		{"resources and project", NewGuestGrant("org", "user", "jury@example.com", []string{"bolero_ravel"}, "project", week, ""), false},
+		{"expired", NewGuestGrant("org", "user", "jury@example.com", nil, "project", time.Now().Add(-time.Hour), ""), false},
+		{"too long", NewGuestGrant("org", "user", "jury@example.com", nil, "project", time.Now().Add(MaxGuestAccess+time.Hour), ""), false},
+		{"long watermark", NewGuestGrant("org", "user", "jury@example.com", nil, "project", week, strings.Repeat("a", maxWatermarkLength+1)), false},
+		{"no organization", NewGuestGrant("", "user", "jury@example.com", nil, "project", week, ""), false},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			err := test.grant.Validate()
+			testutils.AssertEqual(t, err == nil, test.valid)
+			if !test.valid {
+				testutils.AssertEqual(t, errors.Is(err, ErrInvalidGuestGrant), true)
+			}
+		})
+	}
+}
+
+func TestGuestGrantStatusAt(t *testing.T) {
+	grant := NewGuestGrant("org", "user", "Jury@example.com", nil, "project", time.Now().Add(time.Hour), "")
+	testutils.AssertEqual(t, grant.Email, "jury@example.com")
+	testutils.AssertEqual(t, grant.StatusAt(time.Now()), GuestGrantActive)
+	testutils.AssertEqual(t, grant.StatusAt(grant.ExpiresAt), GuestGrantExpired)
+
+	grant.RevokedAt = time.Now()
+	testutils.AssertEqual(t, grant.StatusAt(time.Now()), GuestGrantRevoked)
+	testutils.AssertEqual(t, grant.StatusAt(grant.ExpiresAt), GuestGrantRevoked)
+}
+
+func TestGuestResourceIdsFollowTheProject(t *testing.T) {
+	store := NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	ctx := context.Background()
+	project := Project{Name: "Festival", ResourceIds: []string{"a"}}
+	testutils.AssertNil(t, store.SubmitProject(ctx, orgId, &project))
+
+	grant := NewGuestGrant(orgId, "user", "jury@example.com", nil, project.Id(), time.Now().Add(time.Hour), "")
+	ids, err := GuestResourceIds(ctx, store, grant)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(ids, ","), "a")
+
+	testutils.AssertNil(t, store.SubmitProject(ctx, orgId, &Project{Name: "Festival", ResourceIds: []string{"b"}}))
+	ids, err = GuestResourceIds(ctx, store, grant)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(ids, ","), "a,b")
+
+	grant = NewGuestGrant(orgId, "user", "jury@example.com", []string{"c"}, "", time.Now().Add(time.Hour), "")
+	ids, err = GuestResourceIds(ctx, store, grant)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(ids, ","), "c")
+}
+
+// assertGuestGrantStore runs the same checks against all implementations of the guest grant store
+func assertGuestGrantStore(t *testing.T, store GuestGrantStore) {
+	ctx := context.Background()
+	expires := time.Now().Add(24 * time.Hour)
+	first := NewGuestGrant("org", "user", "jury@example.com", []string{"bolero_ravel", "nimrod_elgar"}, "", expires, "Jury copy")
+	first.CreatedAt = first.CreatedAt.Add(-time.Hour)
+	second := NewGuestGrant("org", "user", "judge@example.com", nil, "project", expires, "")
+
+	testutils.AssertNil(t, store.SaveGuestGrant(ctx, first))
+	testutils.AssertNil(t, store.SaveGuestGrant(ctx, second))
+	testutils.AssertNil(t, store.SaveGuestGrant(ctx, NewGuestGrant("other-org", "user", "jury@example.com", nil, "project", expires, "")))
+
+	err := store.SaveGuestGrant(ctx, NewGuestGrant("org", "user", "jury", nil, "project", expires, ""))
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidGuestGrant), true)
+
+	grants, err := store.GuestGrants(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(grants), 2)
+	testutils.AssertEqual(t, grants[0].Id, second.Id)
+	testutils.AssertEqual(t, grants[0].ProjectId, "project")
+	testutils.AssertEqual(t, strings.Join(grants[1].ResourceIds, ","), "bolero_ravel,nimrod_elgar")
+	testutils.AssertEqual(t, grants[1].Watermark, "Jury copy")
+
+	_, err = ActiveGuestGrant(ctx, store, "org", first.Id, time.Now())
+	testutils.AssertNil(t, err)
+
+	revokedAt := time.Now().Truncate(time.Second)
+	_, err = RevokeGuestGrant(ctx, store, "org", first.Id, "admin", revokedAt)
+	testutils.AssertNil(t, err)
+
+	stored, err := store.GuestGrant(ctx, "org", first.Id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.RevokedAt.Equal(revokedAt), true)
+	testutils.AssertEqual(t, stored.RevokedBy, "admin")
+
+	_, err = ActiveGuestGrant(ctx, store, "org", first.Id, time.Now())
+	testutils.AssertEqual(t, errors.Is(err, ErrGuestGrantInactive), true)
+
+	_, err = ActiveGuestGrant(ctx, store, "org", second.Id, expires)
+	testutils.AssertEqual(t, errors.Is(err, ErrGuestGrantInactive), true)
+
+	_, err = store.GuestGrant(ctx, "other-org", first.Id)
+	testutils.AssertEqual(t, errors.Is(err, ErrGuestGrantNotFound), true)
+
+	num, err := MoveGuestGrants(ctx, store, "org", "project", "renamed")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, num, 1)
+	stored, err = store.GuestGrant(ctx, "org", second.Id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.ProjectId, "renamed")
+}
+
+func TestInMemoryGuestGrants(t *testing.T) {
+	assertGuestGrantStore(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGoogleGuestGrants(t *testing.T) {
+	assertGuestGrantStore(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
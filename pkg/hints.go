@@ -0,0 +1,41 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Hint is a one-time tip shown on a complex page until the user dismisses it
+type Hint string
+
+const (
+	HintUploadAssignment Hint = "upload-assignment"
+	HintProjectSelector  Hint = "project-selector"
+)
+
+var Hints = []Hint{HintUploadAssignment, HintProjectSelector}
+
+func ValidateHint(hint Hint) error {
+	if !slices.Contains(Hints, hint) {
+		return errors.Join(ErrInvalidHint, fmt.Errorf("unknown hint %q", hint))
+	}
+	return nil
+}
+
+// SeenHints are the hints a user has dismissed. They are stored per user, such that a hint dismissed on one
+// device is not shown on the others
+type SeenHints struct {
+	Hints     []Hint    `json:"hints" firestore:"hints"`
+	UpdatedAt time.Time `json:"updatedAt" firestore:"updatedAt"`
+}
+
+type HintStore interface {
+	// SeenHints returns the hints the user has dismissed. Users that have not dismissed any hints get an empty list
+	SeenHints(ctx context.Context, userId string) ([]Hint, error)
+
+	// MarkHintSeen records that the user dismissed the hint. Marking a hint that is already seen is not an error
+	MarkHintSeen(ctx context.Context, userId string, hint Hint) error
+}
@@ -0,0 +1,49 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestValidateHint(t *testing.T) {
+	for _, hint := range Hints {
+		testutils.AssertNil(t, ValidateHint(hint))
+	}
+	testutils.AssertEqual(t, errors.Is(ValidateHint("unknown"), ErrInvalidHint), true)
+}
+
+func assertHintStore(t *testing.T, store HintStore) {
+	ctx := context.Background()
+	hints, err := store.SeenHints(ctx, "user1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(hints), 0)
+
+	testutils.AssertNil(t, store.MarkHintSeen(ctx, "user1", HintUploadAssignment))
+	testutils.AssertNil(t, store.MarkHintSeen(ctx, "user1", HintProjectSelector))
+	testutils.AssertNil(t, store.MarkHintSeen(ctx, "user1", HintUploadAssignment))
+	testutils.AssertNil(t, store.MarkHintSeen(ctx, "user2", HintProjectSelector))
+
+	err = store.MarkHintSeen(ctx, "user1", "unknown")
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidHint), true)
+
+	hints, err = store.SeenHints(ctx, "user1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(hints), 2)
+	testutils.AssertEqual(t, hints[0], HintUploadAssignment)
+	testutils.AssertEqual(t, hints[1], HintProjectSelector)
+
+	hints, err = store.SeenHints(ctx, "user2")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(hints), 1)
+}
+
+func TestInMemoryHintStore(t *testing.T) {
+	assertHintStore(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGoogleHintStore(t *testing.T) {
+	assertHintStore(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
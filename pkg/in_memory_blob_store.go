@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"iter"
+	"maps"
 	"path"
 	"slices"
 	"strings"
@@ -18,22 +19,52 @@ type InMemoryStore struct {
 	Data     map[string][]byte
 	Metadata []MetaData
 	Projects map[string]Project
+
+	// Time each item in Data was last written by Submit
+	Modified map[string]time.Time
+
+	// Earlier versions of each resource, oldest first
+	Versions map[string][]InMemoryVersion
 }
 
 func (s *InMemoryStore) Submit(ctx context.Context, meta *MetaData, pdfIter iter.Seq2[string, []byte]) error {
-	if _, err := s.MetaById(ctx, meta.ResourceId()); err != nil {
+	previousHash := ""
+	existing, err := s.MetaById(ctx, meta.ResourceId())
+	if err != nil {
 		s.Metadata = append(s.Metadata, *meta)
+	} else if existing.Protected {
+		return errors.Join(ErrResourceProtected, fmt.Errorf("resource id: %s", meta.ResourceId()))
+	} else {
+		previousHash = existing.ContentHash
+		s.archiveResource(meta.ResourceId())
 	}
 
 	resourceName := meta.ResourceId()
+	if s.Modified == nil {
+		s.Modified = make(map[string]time.Time)
+	}
 
-	for name, pdfContent := range pdfIter {
+	hash := newContentHash(previousHash)
+	reporter := submitReporterFrom(ctx)
+	for name, pdfContent := range hash.parts(pdfIter) {
 		fullName := resourceName + "/" + name
 		s.Data[fullName] = pdfContent
+		s.Modified[fullName] = time.Now()
+		reporter.Uploaded(name)
 	}
+	meta.ContentHash = hash.Sum()
+	s.setContentHash(resourceName, meta.ContentHash)
 	return nil
 }
 
+func (s *InMemoryStore) setContentHash(id, contentHash string) {
+	for i, meta := range s.Metadata {
+		if meta.ResourceId() == id {
+			s.Metadata[i].ContentHash = contentHash
+		}
+	}
+}
+
 func (s *InMemoryStore) MetaByPattern(ctx context.Context, pattern *MetaData) ([]MetaData, error) {
 	var results []MetaData
 	for _, meta := range s.Metadata {
@@ -69,6 +100,7 @@ func (s *InMemoryStore) SubmitProject(ctx context.Context, project *Project) err
 	projectId := project.Id()
 	if existingProject, exists := s.Projects[projectId]; exists {
 		existingProject.Merge(project)
+		existingProject.Name = project.Name
 		s.Projects[projectId] = existingProject
 	} else {
 		s.Projects[projectId] = *project
@@ -92,6 +124,37 @@ func (s *InMemoryStore) RemoveResource(ctx context.Context, projectId string, re
 	project.ResourceIds = slices.DeleteFunc(project.ResourceIds, func(item string) bool {
 		return item == resourceId
 	})
+	delete(project.Notes, resourceId)
+	project.UpdatedAt = time.Now()
+	s.Projects[projectId] = project
+	return nil
+}
+
+func (s *InMemoryStore) SetProjectOrder(ctx context.Context, projectId string, resourceIds []string) error {
+	project, ok := s.Projects[projectId]
+	if !ok {
+		return errors.Join(ErrProjectNotFound, fmt.Errorf("Project ID: %s", projectId))
+	}
+	project.ResourceIds = slices.Clone(resourceIds)
+	project.UpdatedAt = time.Now()
+	s.Projects[projectId] = project
+	return nil
+}
+
+func (s *InMemoryStore) SetProjectNote(ctx context.Context, projectId, resourceId, note string) error {
+	project, ok := s.Projects[projectId]
+	if !ok {
+		return errors.Join(ErrProjectNotFound, fmt.Errorf("Project ID: %s", projectId))
+	}
+
+	if project.Notes == nil {
+		project.Notes = make(map[string]string)
+	}
+	if note == "" {
+		delete(project.Notes, resourceId)
+	} else {
+		project.Notes[resourceId] = note
+	}
 	project.UpdatedAt = time.Now()
 	s.Projects[projectId] = project
 	return nil
@@ -106,6 +169,157 @@ func (s *InMemoryStore) MetaById(ctx context.Context, id string) (*MetaData, err
 	return &MetaData{}, errors.Join(ErrResourceMetadataNotFound, fmt.Errorf("metadata with id %s not found", id))
 }
 
+func (s *InMemoryStore) RecordAccess(ctx context.Context, id string, at time.Time) error {
+	for i, meta := range s.Metadata {
+		if meta.ResourceId() == id {
+			s.Metadata[i].LastAccessed = at
+			return nil
+		}
+	}
+	return errors.Join(ErrResourceMetadataNotFound, fmt.Errorf("metadata with id %s not found", id))
+}
+
+func (s *InMemoryStore) TransitionStorageClass(ctx context.Context, id string, class StorageClass) error {
+	for i, meta := range s.Metadata {
+		if meta.ResourceId() == id {
+			s.Metadata[i].StorageClass = class
+			return nil
+		}
+	}
+	return errors.Join(ErrResourceMetadataNotFound, fmt.Errorf("metadata with id %s not found", id))
+}
+
+func (s *InMemoryStore) SetProtected(ctx context.Context, id string, protected bool) error {
+	for i, meta := range s.Metadata {
+		if meta.ResourceId() == id {
+			s.Metadata[i].Protected = protected
+			return nil
+		}
+	}
+	return errors.Join(ErrResourceMetadataNotFound, fmt.Errorf("metadata with id %s not found", id))
+}
+
+func (s *InMemoryStore) UpdateMetaData(ctx context.Context, meta *MetaData) error {
+	id := meta.ResourceId()
+	for i, current := range s.Metadata {
+		if current.ResourceId() == id {
+			s.Metadata[i] = *meta
+			return nil
+		}
+	}
+	return errors.Join(ErrResourceMetadataNotFound, fmt.Errorf("metadata with id %s not found", id))
+}
+
+func (s *InMemoryStore) DeleteResource(ctx context.Context, id string) error {
+	for i, meta := range s.Metadata {
+		if meta.ResourceId() == id {
+			if meta.Protected {
+				return errors.Join(ErrResourceProtected, fmt.Errorf("resource id: %s", id))
+			}
+			s.Metadata[i].Deleted = true
+			s.Metadata[i].DeletedAt = time.Now()
+			return nil
+		}
+	}
+	return errors.Join(ErrResourceMetadataNotFound, fmt.Errorf("metadata with id %s not found", id))
+}
+
+func (s *InMemoryStore) RestoreResource(ctx context.Context, id string) error {
+	for i, meta := range s.Metadata {
+		if meta.ResourceId() == id {
+			s.Metadata[i].Deleted = false
+			s.Metadata[i].DeletedAt = time.Time{}
+			return nil
+		}
+	}
+	return errors.Join(ErrResourceMetadataNotFound, fmt.Errorf("metadata with id %s not found", id))
+}
+
+func (s *InMemoryStore) PurgeResource(ctx context.Context, id string) error {
+	idx := slices.IndexFunc(s.Metadata, func(m MetaData) bool { return m.ResourceId() == id })
+	if idx < 0 {
+		return errors.Join(ErrResourceMetadataNotFound, fmt.Errorf("metadata with id %s not found", id))
+	}
+	if !s.Metadata[idx].Deleted {
+		return errors.Join(ErrResourceNotDeleted, fmt.Errorf("resource id: %s", id))
+	}
+
+	s.removeResource(idx)
+	return nil
+}
+
+func (s *InMemoryStore) ResolveSubmit(ctx context.Context, id string) error {
+	idx := slices.IndexFunc(s.Metadata, func(m MetaData) bool { return m.ResourceId() == id })
+	if idx < 0 {
+		return errors.Join(ErrResourceMetadataNotFound, fmt.Errorf("metadata with id %s not found", id))
+	}
+
+	hasParts := false
+	for name := range s.Data {
+		hasParts = hasParts || strings.HasPrefix(name, id+"/")
+	}
+	if resolveAction(&s.Metadata[idx], hasParts) == StoreStatusFinished {
+		s.Metadata[idx].Status = StoreStatusFinished
+		return nil
+	}
+	s.removeResource(idx)
+	return nil
+}
+
+func (s *InMemoryStore) ResourceFiles(ctx context.Context) map[string]int {
+	files := make(map[string]int)
+	for name := range s.Data {
+		resourceId, _, _ := strings.Cut(name, "/")
+		files[resourceId]++
+	}
+	for resourceId := range s.Versions {
+		if _, ok := files[resourceId]; !ok {
+			files[resourceId] = 0
+		}
+	}
+	return files
+}
+
+func (s *InMemoryStore) RemoveOrphanFiles(ctx context.Context, id string) error {
+	_, err := s.MetaById(ctx, id)
+	if err := orphanedFiles(err, id); err != nil {
+		return err
+	}
+	delete(s.Versions, id)
+	for name := range s.Data {
+		if strings.HasPrefix(name, id+"/") {
+			delete(s.Data, name)
+			delete(s.Modified, name)
+		}
+	}
+	return nil
+}
+
+func (s *InMemoryStore) RemoveOrphanMetaData(ctx context.Context, id string) error {
+	idx := slices.IndexFunc(s.Metadata, func(m MetaData) bool { return m.ResourceId() == id })
+	if idx < 0 {
+		return errors.Join(ErrResourceMetadataNotFound, fmt.Errorf("metadata with id %s not found", id))
+	}
+	if err := orphanedMetaData(s.ResourceFiles(ctx)[id], nil, id); err != nil {
+		return err
+	}
+	s.removeResource(idx)
+	return nil
+}
+
+// removeResource removes the metadata at idx together with the parts and versions of the resource
+func (s *InMemoryStore) removeResource(idx int) {
+	id := s.Metadata[idx].ResourceId()
+	s.Metadata = slices.Delete(s.Metadata, idx, idx+1)
+	delete(s.Versions, id)
+	for name := range s.Data {
+		if strings.HasPrefix(name, id+"/") {
+			delete(s.Data, name)
+			delete(s.Modified, name)
+		}
+	}
+}
+
 func (s *InMemoryStore) Resource(ctx context.Context, name string) iter.Seq2[string, []byte] {
 	return func(yield func(k string, c []byte) bool) {
 		for k, content := range s.Data {
@@ -139,7 +353,13 @@ func (s *InMemoryStore) Clone() *InMemoryStore {
 		dst.Data[k] = make([]byte, len(v))
 		copy(dst.Data[k], v)
 	}
+	maps.Copy(dst.Modified, s.Modified)
 
+	for k, versions := range s.Versions {
+		for _, version := range versions {
+			dst.Versions[k] = append(dst.Versions[k], InMemoryVersion{ArchivedAt: version.ArchivedAt, Data: maps.Clone(version.Data)})
+		}
+	}
 	return dst
 }
 
@@ -153,5 +373,7 @@ func NewInMemoryStore() *InMemoryStore {
 		Data:     make(map[string][]byte),
 		Metadata: []MetaData{},
 		Projects: make(map[string]Project),
+		Modified: make(map[string]time.Time),
+		Versions: make(map[string][]InMemoryVersion),
 	}
 }
@@ -127,6 +127,40 @@ func TestAppendWhenExist(t *testing.T) {
 	}
 }
 
+func TestProtectedResourceCanNotBeReplacedOrDeleted(t *testing.T) {
+	store := NewInMemoryStore()
+	ctx := context.Background()
+	meta := MetaData{Title: "Rented score"}
+	content := func(yield func(n string, c []byte) bool) {
+		yield("part.pdf", []byte("content"))
+	}
+	testutils.AssertNil(t, store.Submit(ctx, &meta, content))
+	testutils.AssertNil(t, store.SetProtected(ctx, meta.ResourceId(), true))
+
+	err := store.Submit(ctx, &meta, content)
+	if !errors.Is(err, ErrResourceProtected) {
+		t.Fatalf("Wanted ErrResourceProtected got %v", err)
+	}
+
+	err = store.DeleteResource(ctx, meta.ResourceId())
+	if !errors.Is(err, ErrResourceProtected) {
+		t.Fatalf("Wanted ErrResourceProtected got %v", err)
+	}
+
+	testutils.AssertNil(t, store.SetProtected(ctx, meta.ResourceId(), false))
+	testutils.AssertNil(t, store.DeleteResource(ctx, meta.ResourceId()))
+	testutils.AssertEqual(t, store.Metadata[0].Deleted, true)
+
+	t.Run("unknown resource", func(t *testing.T) {
+		if err := store.SetProtected(ctx, "unknown", true); !errors.Is(err, ErrResourceMetadataNotFound) {
+			t.Fatalf("Wanted ErrResourceMetadataNotFound got %v", err)
+		}
+		if err := store.DeleteResource(ctx, "unknown"); !errors.Is(err, ErrResourceMetadataNotFound) {
+			t.Fatalf("Wanted ErrResourceMetadataNotFound got %v", err)
+		}
+	})
+}
+
 func TestProjectByName(t *testing.T) {
 	inMemStore := &InMemoryStore{
 		Projects: map[string]Project{
@@ -351,6 +385,23 @@ func TestDeleteResourceFromProject(t *testing.T) {
 	}
 }
 
+func TestSetProjectNote(t *testing.T) {
+	store := NewInMemoryStore()
+	ctx := context.Background()
+	store.SubmitProject(ctx, &Project{Name: "myproject", ResourceIds: []string{"id1", "id2"}})
+
+	testutils.AssertNil(t, store.SetProjectNote(ctx, "myproject", "id1", "Start at letter C"))
+	testutils.AssertNil(t, store.SetProjectNote(ctx, "myproject", "id2", "Repeat the coda"))
+	testutils.AssertEqual(t, store.Projects["myproject"].Notes["id1"], "Start at letter C")
+
+	testutils.AssertNil(t, store.SetProjectNote(ctx, "myproject", "id1", ""))
+	testutils.AssertNil(t, store.RemoveResource(ctx, "myproject", "id2"))
+	testutils.AssertEqual(t, len(store.Projects["myproject"].Notes), 0)
+
+	err := store.SetProjectNote(ctx, "unknown", "id1", "note")
+	testutils.AssertEqual(t, errors.Is(err, ErrProjectNotFound), true)
+}
+
 func TestDeleteResourceErrorOnUnknownProject(t *testing.T) {
 	store := NewInMemoryStore()
 	err := store.RemoveResource(context.Background(), "some-non-existent-project", "resource")
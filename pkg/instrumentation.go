@@ -0,0 +1,125 @@
+package pkg
+
+import (
+	"embed"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/davidkleiven/caesura/utils"
+	"gopkg.in/yaml.v2"
+)
+
+//go:embed instrumentation/presets.yml
+var instrumentationFS embed.FS
+
+// InstrumentFamily is a group of instruments that organizations choose during onboarding
+type InstrumentFamily struct {
+	Id          string   `yaml:"id"`
+	Instruments []string `yaml:"instruments"`
+}
+
+// InstrumentationPreset is a typical ensemble, such as a brass band or a choir
+type InstrumentationPreset struct {
+	Id string `yaml:"id"`
+
+	// Families chosen when the preset is picked in the onboarding wizard
+	Families    []string `yaml:"families"`
+	Instruments []string `yaml:"instruments"`
+}
+
+type Instrumentation struct {
+	Conductor []string                `yaml:"conductor"`
+	Families  []InstrumentFamily      `yaml:"families"`
+	Presets   []InstrumentationPreset `yaml:"presets"`
+}
+
+// Validate checks that all families referred to by the presets exist and that ids are unique
+func (i *Instrumentation) Validate() error {
+	families := make(map[string]bool)
+	for _, family := range i.Families {
+		if families[family.Id] {
+			return fmt.Errorf("duplicate instrument family %q", family.Id)
+		}
+		families[family.Id] = true
+	}
+
+	presets := make(map[string]bool)
+	for _, preset := range i.Presets {
+		if presets[preset.Id] {
+			return fmt.Errorf("duplicate instrumentation preset %q", preset.Id)
+		}
+		presets[preset.Id] = true
+		for _, family := range preset.Families {
+			if !families[family] {
+				return fmt.Errorf("preset %q refers to unknown instrument family %q", preset.Id, family)
+			}
+		}
+	}
+	return nil
+}
+
+// All returns the instruments of all families followed by the conductor
+func (i *Instrumentation) All() []string {
+	var instruments []string
+	for _, family := range i.Families {
+		instruments = append(instruments, family.Instruments...)
+	}
+	return append(instruments, i.Conductor...)
+}
+
+// Of returns the instruments of the families followed by the conductor. Unknown families are ignored and all
+// instruments are returned when no family is given
+func (i *Instrumentation) Of(families []string) []string {
+	if len(families) == 0 {
+		return i.All()
+	}
+	var instruments []string
+	for _, family := range i.Families {
+		if slices.Contains(families, family.Id) {
+			instruments = append(instruments, family.Instruments...)
+		}
+	}
+	return append(instruments, i.Conductor...)
+}
+
+func (i *Instrumentation) FamilyIds() []string {
+	ids := make([]string, len(i.Families))
+	for j, family := range i.Families {
+		ids[j] = family.Id
+	}
+	return ids
+}
+
+func (i *Instrumentation) HasFamily(id string) bool {
+	return slices.Contains(i.FamilyIds(), id)
+}
+
+func (i *Instrumentation) Preset(id string) (InstrumentationPreset, bool) {
+	for _, preset := range i.Presets {
+		if preset.Id == id {
+			return preset, true
+		}
+	}
+	return InstrumentationPreset{}, false
+}
+
+// PresetInstruments returns the instruments of the preset followed by the conductor
+func (i *Instrumentation) PresetInstruments(preset InstrumentationPreset) []string {
+	return append(slices.Clone(preset.Instruments), i.Conductor...)
+}
+
+func ParseInstrumentation(data []byte) (*Instrumentation, error) {
+	var instrumentation Instrumentation
+	if err := yaml.UnmarshalStrict(data, &instrumentation); err != nil {
+		return nil, err
+	}
+	return &instrumentation, instrumentation.Validate()
+}
+
+// DefaultInstrumentation returns the instrument families and presets shipped with the application
+var DefaultInstrumentation = sync.OnceValue(func() *Instrumentation {
+	instrumentation, err := ParseInstrumentation(utils.Must(instrumentationFS.ReadFile("instrumentation/presets.yml")))
+	PanicOnErr(err)
+	return instrumentation
+})
@@ -0,0 +1,40 @@
+package pkg
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestDefaultInstrumentation(t *testing.T) {
+	instrumentation := DefaultInstrumentation()
+	testutils.AssertEqual(t, strings.Join(instrumentation.FamilyIds(), ","), "reeds,brass,strings,percussion,choir")
+	for _, id := range []string{"concert-band", "brass-band", "big-band", "satb-choir", "string-orchestra"} {
+		preset, ok := instrumentation.Preset(id)
+		testutils.AssertEqual(t, ok, true)
+		testutils.AssertEqual(t, len(preset.Instruments) > 0, true)
+	}
+
+	choir := instrumentation.Of([]string{"choir"})
+	testutils.AssertEqual(t, strings.Join(choir, ","), "Soprano,Alto,Tenor,Bass,Conductor")
+	testutils.AssertEqual(t, slices.Contains(instrumentation.All(), "Trumpet"), true)
+	testutils.AssertEqual(t, instrumentation.HasFamily("kazoo"), false)
+
+	preset, _ := instrumentation.Preset("string-orchestra")
+	testutils.AssertEqual(t, strings.Join(instrumentation.PresetInstruments(preset), ","), "Violin,Viola,Cello,Contrabass,Conductor")
+	testutils.AssertEqual(t, len(preset.Instruments), 4)
+}
+
+func TestParseInstrumentationErrors(t *testing.T) {
+	for _, data := range []string{
+		"families: [{id: brass}, {id: brass}]",
+		"presets: [{id: band}, {id: band}]",
+		"families: [{id: brass}]\npresets: [{id: band, families: [reeds]}]",
+		"instruments: [Kazoo]",
+	} {
+		_, err := ParseInstrumentation([]byte(data))
+		testutils.AssertEqual(t, err != nil, true)
+	}
+}
@@ -0,0 +1,182 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	invitationCollection = "invitations"
+
+	// DefaultInvitationExpiry is how long an emailed invitation can be accepted
+	DefaultInvitationExpiry = 7 * 24 * time.Hour
+
+	maxInvitationGroupLength = 128
+)
+
+type InvitationStatus string
+
+const (
+	InvitationPending  InvitationStatus = "pending"
+	InvitationAccepted InvitationStatus = "accepted"
+	InvitationExpired  InvitationStatus = "expired"
+)
+
+// Invitation is sent by email to a person who should join the organization. The role and group are given to the
+// user that signs in with the link in the email, provided that the user has the email address the invitation
+// was sent to
+type Invitation struct {
+	Id         string           `json:"id" firestore:"id"`
+	OrgId      string           `json:"orgId" firestore:"orgId"`
+	Email      string           `json:"email" firestore:"email"`
+	Role       RoleKind         `json:"role" firestore:"role"`
+	Group      string           `json:"group" firestore:"group"`
+	Status     InvitationStatus `json:"status" firestore:"status"`
+	CreatedAt  time.Time        `json:"createdAt" firestore:"createdAt"`
+	ExpiresAt  time.Time        `json:"expiresAt" firestore:"expiresAt"`
+	AcceptedAt time.Time        `json:"acceptedAt" firestore:"acceptedAt"`
+	AcceptedBy string           `json:"acceptedBy" firestore:"acceptedBy"`
+}
+
+func NewInvitation(orgId, email string, role RoleKind, group string, expiry time.Duration) *Invitation {
+	now := time.Now()
+	return &Invitation{
+		Id:        uuid.NewString(),
+		OrgId:     orgId,
+		Email:     strings.ToLower(strings.TrimSpace(email)),
+		Role:      role,
+		Group:     strings.TrimSpace(group),
+		Status:    InvitationPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(expiry),
+	}
+}
+
+func (i *Invitation) Validate() error {
+	if i.Id == "" || i.OrgId == "" {
+		return errors.Join(ErrInvalidInvitation, errors.New("invitation must have an id and an organization"))
+	}
+	if !strings.Contains(i.Email, "@") {
+		return errors.Join(ErrInvalidInvitation, fmt.Errorf("invalid email %q", i.Email))
+	}
+	if !i.Role.Valid() {
+		return errors.Join(ErrInvalidInvitation, fmt.Errorf("unknown role %d", i.Role))
+	}
+	if len(i.Group) > maxInvitationGroupLength {
+		return errors.Join(ErrInvalidInvitation, fmt.Errorf("group can not be longer than %d characters", maxInvitationGroupLength))
+	}
+	if !slices.Contains([]InvitationStatus{InvitationPending, InvitationAccepted}, i.Status) {
+		return errors.Join(ErrInvalidInvitation, fmt.Errorf("unknown status %q", i.Status))
+	}
+	return nil
+}
+
+// StatusAt returns the status of the invitation at now. Expiry is not stored, pending invitations are expired
+// once ExpiresAt has passed
+func (i *Invitation) StatusAt(now time.Time) InvitationStatus {
+	if i.Status == InvitationPending && !now.Before(i.ExpiresAt) {
+		return InvitationExpired
+	}
+	return i.Status
+}
+
+type InvitationStore interface {
+	SaveInvitation(ctx context.Context, invitation *Invitation) error
+
+	// Invitation returns ErrInvitationNotFound when there is no invitation with the id in the organization
+	Invitation(ctx context.Context, orgId, id string) (*Invitation, error)
+
+	// Invitations returns the invitations of the organization, newest first
+	Invitations(ctx context.Context, orgId string) ([]Invitation, error)
+}
+
+type InvitationAcceptStore interface {
+	InvitationStore
+	RoleRegisterer
+	GroupStore
+}
+
+// AcceptInvitation gives the user the role and group of a pending invitation and marks it as accepted. The user
+// must have the email address the invitation was sent to. Members are never given a role with fewer privileges
+// than they have. The roles and groups of user are updated in place
+func AcceptInvitation(ctx context.Context, store InvitationAcceptStore, orgId, id string, user *UserInfo, now time.Time) error {
+	invitation, err := store.Invitation(ctx, orgId, id)
+	if err != nil {
+		return err
+	}
+	if status := invitation.StatusAt(now); status != InvitationPending {
+		return errors.Join(ErrInvitationNotPending, fmt.Errorf("invitation %s is %s", id, status))
+	}
+	if !strings.EqualFold(strings.TrimSpace(user.Email), invitation.Email) {
+		return errors.Join(ErrInvitationEmailMismatch, fmt.Errorf("invitation %s was sent to another email", id))
+	}
+
+	// Members that already have the privileges of the invited role keep their role
+	if current, isMember := user.Roles[orgId]; !isMember || !current.AtLeast(invitation.Role) {
+		if err := store.RegisterRole(ctx, user.Id, orgId, invitation.Role); err != nil {
+			return err
+		}
+		if user.Roles == nil {
+			user.Roles = make(map[string]RoleKind)
+		}
+		user.Roles[orgId] = invitation.Role
+	}
+
+	if invitation.Group != "" && !slices.Contains(user.Groups[orgId], invitation.Group) {
+		if err := store.RegisterGroup(ctx, user.Id, orgId, invitation.Group); err != nil {
+			return err
+		}
+		if user.Groups == nil {
+			user.Groups = make(map[string][]string)
+		}
+		user.Groups[orgId] = append(user.Groups[orgId], invitation.Group)
+	}
+
+	invitation.Status = InvitationAccepted
+	invitation.AcceptedAt = now
+	invitation.AcceptedBy = user.Id
+	return store.SaveInvitation(ctx, invitation)
+}
+
+// SortInvitations orders the invitations with the newest first
+func SortInvitations(invitations []Invitation) {
+	slices.SortStableFunc(invitations, func(a, b Invitation) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+}
+
+func invitationNotFound(id string) error {
+	return errors.Join(ErrInvitationNotFound, fmt.Errorf("invitation id: %s", id))
+}
+
+func (g *GoogleStore) SaveInvitation(ctx context.Context, invitation *Invitation) error {
+	if err := invitation.Validate(); err != nil {
+		return err
+	}
+	return g.FsClient.StoreDocument(ctx, invitationCollection, invitation.OrgId, invitation.Id, invitation)
+}
+
+func (g *GoogleStore) Invitation(ctx context.Context, orgId, id string) (*Invitation, error) {
+	doc, err := g.FsClient.GetDoc(ctx, invitationCollection, orgId, id)
+	if err != nil {
+		return &Invitation{}, classifyStoreErr(err, ErrInvitationNotFound)
+	}
+	var invitation Invitation
+	err = doc.DataTo(&invitation)
+	return &invitation, err
+}
+
+func (g *GoogleStore) Invitations(ctx context.Context, orgId string) ([]Invitation, error) {
+	collector := NewValidCollector[Invitation]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, invitationCollection, orgId, "id", "") {
+		collector.Push(doc)
+	}
+	SortInvitations(collector.Items)
+	return collector.Items, collector.Err
+}
@@ -0,0 +1,133 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestInvitationValidate(t *testing.T) {
+	for _, test := range []struct {
+		desc       string
+		invitation *Invitation
+		valid      bool
+	}{
+		{"librarian", NewInvitation("org", " Anna@Example.com ", RoleLibrarian, "Horns", time.Hour), true},
+		{"invalid email", NewInvitation("org", "anna", RoleViewer, "", time.Hour), false},
+		{"unknown role", NewInvitation("org", "anna@example.com", RoleKind(42), "", time.Hour), false},
+		{"long group", NewInvitation("org", "anna@example.com", RoleViewer, strings.Repeat("a", maxInvitationGroupLength+1), time.Hour), false},
+		{"no organization", NewInvitation("", "anna@example.com", RoleViewer, "", time.Hour), false},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			err := test.invitation.Validate()
+			testutils.AssertEqual(t, err == nil, test.valid)
+			if !test.valid {
+				testutils.AssertEqual(t, errors.Is(err, ErrInvalidInvitation), true)
+			}
+		})
+	}
+}
+
+func TestInvitationStatusAt(t *testing.T) {
+	invitation := NewInvitation("org", "anna@example.com", RoleViewer, "", time.Hour)
+	testutils.AssertEqual(t, invitation.Email, "anna@example.com")
+	testutils.AssertEqual(t, invitation.StatusAt(time.Now()), InvitationPending)
+	testutils.AssertEqual(t, invitation.StatusAt(invitation.ExpiresAt), InvitationExpired)
+
+	invitation.Status = InvitationAccepted
+	testutils.AssertEqual(t, invitation.StatusAt(invitation.ExpiresAt), InvitationAccepted)
+}
+
+// assertInvitationStore runs the same checks against all implementations of the invitation store
+func assertInvitationStore(t *testing.T, store InvitationStore) {
+	ctx := context.Background()
+	first := NewInvitation("org", "anna@example.com", RoleEditor, "Horns", time.Hour)
+	first.CreatedAt = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	second := NewInvitation("org", "john@example.com", RoleViewer, "", time.Hour)
+	second.CreatedAt = first.CreatedAt.Add(time.Hour)
+
+	testutils.AssertNil(t, store.SaveInvitation(ctx, first))
+	testutils.AssertNil(t, store.SaveInvitation(ctx, second))
+	testutils.AssertNil(t, store.SaveInvitation(ctx, NewInvitation("other-org", "anna@example.com", RoleViewer, "", time.Hour)))
+
+	err := store.SaveInvitation(ctx, NewInvitation("org", "anna", RoleViewer, "", time.Hour))
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidInvitation), true)
+
+	invitations, err := store.Invitations(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(invitations), 2)
+	testutils.AssertEqual(t, invitations[0].Id, second.Id)
+	testutils.AssertEqual(t, invitations[1].Group, "Horns")
+	testutils.AssertEqual(t, invitations[1].Role, RoleKind(RoleEditor))
+
+	acceptedAt := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	first.Status = InvitationAccepted
+	first.AcceptedAt = acceptedAt
+	first.AcceptedBy = "user"
+	testutils.AssertNil(t, store.SaveInvitation(ctx, first))
+
+	stored, err := store.Invitation(ctx, "org", first.Id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Status, InvitationAccepted)
+	testutils.AssertEqual(t, stored.AcceptedAt.Equal(acceptedAt), true)
+	testutils.AssertEqual(t, stored.AcceptedBy, "user")
+
+	_, err = store.Invitation(ctx, "other-org", first.Id)
+	testutils.AssertEqual(t, errors.Is(err, ErrInvitationNotFound), true)
+}
+
+func TestInMemoryInvitations(t *testing.T) {
+	assertInvitationStore(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGoogleInvitations(t *testing.T) {
+	assertInvitationStore(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	ctx := context.Background()
+	store := NewMultiOrgInMemoryStore()
+	user := UserInfo{Id: "user", Email: "Anna@example.com", Roles: map[string]RoleKind{}, Groups: map[string][]string{}}
+	testutils.AssertNil(t, store.RegisterUser(ctx, &user))
+
+	invitation := NewInvitation("org", "anna@example.com", RoleLibrarian, "Horns", time.Hour)
+	testutils.AssertNil(t, store.SaveInvitation(ctx, invitation))
+
+	other := UserInfo{Id: "other", Email: "john@example.com"}
+	err := AcceptInvitation(ctx, store, "org", invitation.Id, &other, time.Now())
+	testutils.AssertEqual(t, errors.Is(err, ErrInvitationEmailMismatch), true)
+
+	testutils.AssertNil(t, AcceptInvitation(ctx, store, "org", invitation.Id, &user, time.Now()))
+	testutils.AssertEqual(t, user.Roles["org"], RoleKind(RoleLibrarian))
+	testutils.AssertEqual(t, user.Groups["org"][0], "Horns")
+
+	stored, err := store.GetUserInfo(ctx, "user")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Roles["org"], RoleKind(RoleLibrarian))
+	testutils.AssertEqual(t, stored.Groups["org"][0], "Horns")
+
+	accepted, err := store.Invitation(ctx, "org", invitation.Id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, accepted.Status, InvitationAccepted)
+	testutils.AssertEqual(t, accepted.AcceptedBy, "user")
+
+	// An invitation can only be used once
+	err = AcceptInvitation(ctx, store, "org", invitation.Id, &user, time.Now())
+	testutils.AssertEqual(t, errors.Is(err, ErrInvitationNotPending), true)
+}
+
+func TestAcceptExpiredInvitation(t *testing.T) {
+	ctx := context.Background()
+	store := NewMultiOrgInMemoryStore()
+	invitation := NewInvitation("org", "anna@example.com", RoleEditor, "", time.Hour)
+	testutils.AssertNil(t, store.SaveInvitation(ctx, invitation))
+
+	user := UserInfo{Id: "user", Email: "anna@example.com"}
+	err := AcceptInvitation(ctx, store, "org", invitation.Id, &user, invitation.ExpiresAt)
+	testutils.AssertEqual(t, errors.Is(err, ErrInvitationNotPending), true)
+	testutils.AssertEqual(t, len(user.Roles), 0)
+}
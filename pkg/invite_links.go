@@ -0,0 +1,138 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const inviteLinkCollection = "invite_links"
+
+type InviteLinkStatus string
+
+const (
+	InviteLinkActive  InviteLinkStatus = "active"
+	InviteLinkExpired InviteLinkStatus = "expired"
+	InviteLinkRevoked InviteLinkStatus = "revoked"
+)
+
+// InviteLink lets anyone with the link join the organization as a viewer until it expires or is revoked. The
+// link itself is a signed token holding the id, such that the store is only needed to check for revocation
+type InviteLink struct {
+	Id        string    `json:"id" firestore:"id"`
+	OrgId     string    `json:"orgId" firestore:"orgId"`
+	CreatedBy string    `json:"createdBy" firestore:"createdBy"`
+	CreatedAt time.Time `json:"createdAt" firestore:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt" firestore:"expiresAt"`
+	RevokedAt time.Time `json:"revokedAt" firestore:"revokedAt"`
+	RevokedBy string    `json:"revokedBy" firestore:"revokedBy"`
+}
+
+func NewInviteLink(orgId, createdBy string, expiry time.Duration) *InviteLink {
+	now := time.Now()
+	return &InviteLink{
+		Id:        uuid.NewString(),
+		OrgId:     orgId,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		ExpiresAt: now.Add(expiry),
+	}
+}
+
+func (l *InviteLink) Validate() error {
+	if l.Id == "" || l.OrgId == "" {
+		return errors.Join(ErrInvalidInviteLink, errors.New("invite link must have an id and an organization"))
+	}
+	if !l.ExpiresAt.After(l.CreatedAt) {
+		return errors.Join(ErrInvalidInviteLink, errors.New("invite link must expire after it is created"))
+	}
+	return nil
+}
+
+// StatusAt returns the status of the link at now. Revoked links stay revoked after they would have expired
+func (l *InviteLink) StatusAt(now time.Time) InviteLinkStatus {
+	switch {
+	case !l.RevokedAt.IsZero():
+		return InviteLinkRevoked
+	case !now.Before(l.ExpiresAt):
+		return InviteLinkExpired
+	default:
+		return InviteLinkActive
+	}
+}
+
+type InviteLinkStore interface {
+	// SaveInviteLink inserts the link or updates the revocation of an existing link
+	SaveInviteLink(ctx context.Context, link *InviteLink) error
+
+	// InviteLink returns ErrInviteLinkNotFound when there is no link with the id in the organization
+	InviteLink(ctx context.Context, orgId, id string) (*InviteLink, error)
+
+	// InviteLinks returns the invite links of the organization, newest first
+	InviteLinks(ctx context.Context, orgId string) ([]InviteLink, error)
+}
+
+// RevokeInviteLink stops the link from being used to join the organization. Revoking a revoked link does nothing
+func RevokeInviteLink(ctx context.Context, store InviteLinkStore, orgId, id, userId string, now time.Time) (*InviteLink, error) {
+	link, err := store.InviteLink(ctx, orgId, id)
+	if err != nil || !link.RevokedAt.IsZero() {
+		return link, err
+	}
+	link.RevokedAt = now
+	link.RevokedBy = userId
+	return link, store.SaveInviteLink(ctx, link)
+}
+
+// CheckInviteLink returns ErrInviteLinkRevoked when the link can no longer be used to join the organization.
+// The expiry is part of the signed token, and is checked when the token is parsed
+func CheckInviteLink(ctx context.Context, store InviteLinkStore, orgId, id string) error {
+	link, err := store.InviteLink(ctx, orgId, id)
+	if err != nil {
+		return err
+	}
+	if !link.RevokedAt.IsZero() {
+		return errors.Join(ErrInviteLinkRevoked, fmt.Errorf("invite link %s was revoked", id))
+	}
+	return nil
+}
+
+// SortInviteLinks orders the links with the newest first
+func SortInviteLinks(links []InviteLink) {
+	slices.SortStableFunc(links, func(a, b InviteLink) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+}
+
+func inviteLinkNotFound(id string) error {
+	return errors.Join(ErrInviteLinkNotFound, fmt.Errorf("invite link id: %s", id))
+}
+
+func (g *GoogleStore) SaveInviteLink(ctx context.Context, link *InviteLink) error {
+	if err := link.Validate(); err != nil {
+		return err
+	}
+	return g.FsClient.StoreDocument(ctx, inviteLinkCollection, link.OrgId, link.Id, link)
+}
+
+func (g *GoogleStore) InviteLink(ctx context.Context, orgId, id string) (*InviteLink, error) {
+	doc, err := g.FsClient.GetDoc(ctx, inviteLinkCollection, orgId, id)
+	if err != nil {
+		return &InviteLink{}, classifyStoreErr(err, ErrInviteLinkNotFound)
+	}
+	var link InviteLink
+	err = doc.DataTo(&link)
+	return &link, err
+}
+
+func (g *GoogleStore) InviteLinks(ctx context.Context, orgId string) ([]InviteLink, error) {
+	collector := NewValidCollector[InviteLink]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, inviteLinkCollection, orgId, "id", "") {
+		collector.Push(doc)
+	}
+	SortInviteLinks(collector.Items)
+	return collector.Items, collector.Err
+}
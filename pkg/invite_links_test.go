@@ -0,0 +1,74 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestInviteLinkStatusAt(t *testing.T) {
+	link := NewInviteLink("org", "user", time.Hour)
+	testutils.AssertNil(t, link.Validate())
+	testutils.AssertEqual(t, link.StatusAt(time.Now()), InviteLinkActive)
+	testutils.AssertEqual(t, link.StatusAt(link.ExpiresAt), InviteLinkExpired)
+
+	link.RevokedAt = time.Now()
+	testutils.AssertEqual(t, link.StatusAt(link.ExpiresAt), InviteLinkRevoked)
+
+	err := NewInviteLink("org", "user", 0).Validate()
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidInviteLink), true)
+}
+
+// assertInviteLinkStore runs the same checks against all implementations of the invite link store
+func assertInviteLinkStore(t *testing.T, store InviteLinkStore) {
+	ctx := context.Background()
+	first := NewInviteLink("org", "user", time.Hour)
+	first.CreatedAt = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	first.ExpiresAt = first.CreatedAt.Add(48 * time.Hour)
+	second := NewInviteLink("org", "user", time.Hour)
+
+	testutils.AssertNil(t, store.SaveInviteLink(ctx, first))
+	testutils.AssertNil(t, store.SaveInviteLink(ctx, second))
+	testutils.AssertNil(t, store.SaveInviteLink(ctx, NewInviteLink("other-org", "user", time.Hour)))
+
+	links, err := store.InviteLinks(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(links), 2)
+	testutils.AssertEqual(t, links[0].Id, second.Id)
+	testutils.AssertEqual(t, links[1].CreatedBy, "user")
+
+	now := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	revoked, err := RevokeInviteLink(ctx, store, "org", first.Id, "admin", now)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, revoked.StatusAt(now), InviteLinkRevoked)
+
+	stored, err := store.InviteLink(ctx, "org", first.Id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.RevokedAt.Equal(now), true)
+	testutils.AssertEqual(t, stored.RevokedBy, "admin")
+
+	err = CheckInviteLink(ctx, store, "org", first.Id)
+	testutils.AssertEqual(t, errors.Is(err, ErrInviteLinkRevoked), true)
+	testutils.AssertNil(t, CheckInviteLink(ctx, store, "org", second.Id))
+
+	// Revoking again keeps the first revocation
+	_, err = RevokeInviteLink(ctx, store, "org", first.Id, "other-admin", now.Add(time.Hour))
+	testutils.AssertNil(t, err)
+	stored, err = store.InviteLink(ctx, "org", first.Id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.RevokedBy, "admin")
+
+	_, err = store.InviteLink(ctx, "other-org", first.Id)
+	testutils.AssertEqual(t, errors.Is(err, ErrInviteLinkNotFound), true)
+}
+
+func TestInMemoryInviteLinks(t *testing.T) {
+	assertInviteLinkStore(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGoogleInviteLinks(t *testing.T) {
+	assertInviteLinkStore(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
@@ -0,0 +1,94 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// LayoutVersion is the version of the file format of exported layouts
+const LayoutVersion = 1
+
+const maxLayoutTemplates = 100
+
+// OrganizationLayout is the part of the setup of an organization that can be copied to other organizations, such
+// that the members of a federation use the same project templates, groups and instruments
+type OrganizationLayout struct {
+	Version          int               `json:"version"`
+	ProjectTemplates []ProjectTemplate `json:"projectTemplates"`
+
+	// Instrument families played in the organization. Empty means all instruments
+	Instruments []string  `json:"instruments"`
+	ExportedAt  time.Time `json:"exportedAt"`
+}
+
+func (l *OrganizationLayout) Validate() error {
+	if l.Version != LayoutVersion {
+		return errors.Join(ErrInvalidLayout, fmt.Errorf("unsupported version %d", l.Version))
+	}
+	if len(l.ProjectTemplates) > maxLayoutTemplates {
+		return errors.Join(ErrInvalidLayout, fmt.Errorf("a layout can not have more than %d project templates", maxLayoutTemplates))
+	}
+	for _, template := range l.ProjectTemplates {
+		if err := template.Validate(); err != nil {
+			return errors.Join(ErrInvalidLayout, err)
+		}
+	}
+	return nil
+}
+
+type LayoutStore interface {
+	ProjectTemplateStore
+	OnboardingStore
+}
+
+// ExportLayout collects the project templates and the instrument families of the organization
+func ExportLayout(ctx context.Context, store LayoutStore, orgId string, now time.Time) (*OrganizationLayout, error) {
+	templates, err := store.ProjectTemplates(ctx, orgId)
+	if err != nil {
+		return nil, err
+	}
+
+	instruments := []string{}
+	onboarding, err := store.Onboarding(ctx, orgId)
+	switch {
+	case err == nil:
+		instruments = onboarding.Instruments
+	case !errors.Is(err, ErrOnboardingNotFound):
+		return nil, err
+	}
+	return &OrganizationLayout{Version: LayoutVersion, ProjectTemplates: templates, Instruments: instruments, ExportedAt: now}, nil
+}
+
+// ImportLayout copies the layout to the organization. Project templates replace templates with the same name and
+// other templates are kept. The instrument families replace the families of the organization unless the layout
+// has none
+func ImportLayout(ctx context.Context, store LayoutStore, orgId string, layout *OrganizationLayout, now time.Time) error {
+	if err := layout.Validate(); err != nil {
+		return err
+	}
+
+	for _, template := range layout.ProjectTemplates {
+		template.UpdatedAt = now
+		if err := store.SubmitProjectTemplate(ctx, orgId, &template); err != nil {
+			return err
+		}
+	}
+
+	if len(layout.Instruments) == 0 {
+		return nil
+	}
+	onboarding, err := store.Onboarding(ctx, orgId)
+	if errors.Is(err, ErrOnboardingNotFound) {
+		// Organizations created before the wizard existed should not be shown the wizard
+		onboarding = NewOnboarding()
+		onboarding.Dismissed = true
+	} else if err != nil {
+		return err
+	}
+	onboarding.Instruments = slices.Clone(layout.Instruments)
+	PanicOnErr(onboarding.Complete(OnboardingStepInstruments, now))
+	return store.SaveOnboarding(ctx, orgId, onboarding)
+}
@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestOrganizationLayoutValidate(t *testing.T) {
+	testutils.AssertNil(t, (&OrganizationLayout{Version: LayoutVersion}).Validate())
+
+	for _, layout := range []OrganizationLayout{
+		{Version: LayoutVersion + 1},
+		{Version: LayoutVersion, ProjectTemplates: []ProjectTemplate{{Name: "!"}}},
+		{Version: LayoutVersion, ProjectTemplates: make([]ProjectTemplate, maxLayoutTemplates+1)},
+	} {
+		testutils.AssertEqual(t, errors.Is(layout.Validate(), ErrInvalidLayout), true)
+	}
+}
+
+func TestExportImportLayout(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.SubmitProjectTemplate(ctx, "federation", &ProjectTemplate{Name: "Contest", Groups: []string{"Solo cornet"}}))
+
+	layout, err := ExportLayout(ctx, store, "federation", now)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(layout.ProjectTemplates), 1)
+	testutils.AssertEqual(t, len(layout.Instruments), 0)
+
+	onboarding := NewOnboarding()
+	onboarding.Instruments = []string{"brass", "percussion"}
+	testutils.AssertNil(t, store.SaveOnboarding(ctx, "federation", onboarding))
+	layout, err = ExportLayout(ctx, store, "federation", now)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(layout.Instruments, ","), "brass,percussion")
+
+	// The band keeps its own templates, and was created before the onboarding wizard existed
+	testutils.AssertNil(t, store.SubmitProjectTemplate(ctx, "band", &ProjectTemplate{Name: "Christmas"}))
+	testutils.AssertNil(t, ImportLayout(ctx, store, "band", layout, now))
+
+	templates, err := store.ProjectTemplates(ctx, "band")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(templates), 2)
+	imported, err := store.ProjectTemplateById(ctx, "band", "contest")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, imported.Groups[0], "Solo cornet")
+	testutils.AssertEqual(t, imported.UpdatedAt, now)
+
+	bandOnboarding, err := store.Onboarding(ctx, "band")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(bandOnboarding.Instruments, ","), "brass,percussion")
+	_, active := bandOnboarding.Current()
+	testutils.AssertEqual(t, active, false)
+
+	err = ImportLayout(ctx, store, "band", &OrganizationLayout{Version: 0}, now)
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidLayout), true)
+}
@@ -0,0 +1,85 @@
+package pkg
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"io"
+	"slices"
+	"strconv"
+)
+
+// LibraryColumns are the columns of an exported library, in order
+var LibraryColumns = []string{
+	"resource_id", "title", "composer", "arranger", "genre", "year", "instrumentation", "publisher", "ismn", "duration",
+	"tags", "num_parts",
+}
+
+// utf8BOM makes spreadsheet programs such as Excel read the file as UTF-8, such that names with letters like ø
+// are shown correctly
+const utf8BOM = "\ufeff"
+
+type LibraryExportStore interface {
+	MetaByPatternFetcher
+	PartLister
+}
+
+// LibraryEntry is one piece of an exported library
+type LibraryEntry struct {
+	Meta     MetaData
+	NumParts int
+}
+
+func (e *LibraryEntry) record() []string {
+	duration := ""
+	if e.Meta.Duration != 0 {
+		duration = e.Meta.Duration.String()
+	}
+	return []string{
+		e.Meta.ResourceId(), e.Meta.Title, e.Meta.Composer, e.Meta.Arranger, e.Meta.Genre, e.Meta.Year,
+		e.Meta.Instrumentation, e.Meta.Publisher, e.Meta.Ismn, duration, e.Meta.Tags, strconv.Itoa(e.NumParts),
+	}
+}
+
+// LibraryEntries lists the pieces of the organization sorted by title, composer and arranger. Pieces in the trash
+// are left out
+func LibraryEntries(ctx context.Context, store LibraryExportStore, orgId string) ([]LibraryEntry, error) {
+	metas, err := store.MetaByPattern(ctx, orgId, &MetaData{})
+	if err != nil {
+		return nil, err
+	}
+	parts, err := store.PartNames(ctx, orgId)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]LibraryEntry, 0, len(metas))
+	for _, meta := range metas {
+		if meta.Deleted {
+			continue
+		}
+		entries = append(entries, LibraryEntry{Meta: meta, NumParts: len(parts[meta.ResourceId()])})
+	}
+	slices.SortFunc(entries, func(a, b LibraryEntry) int {
+		return cmp.Or(
+			cmp.Compare(a.Meta.Title, b.Meta.Title),
+			cmp.Compare(a.Meta.Composer, b.Meta.Composer),
+			cmp.Compare(a.Meta.Arranger, b.Meta.Arranger),
+		)
+	})
+	return entries, nil
+}
+
+// WriteLibraryCSV writes the entries as CSV with a header of LibraryColumns
+func WriteLibraryCSV(w io.Writer, entries []LibraryEntry) error {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	writer.Write(LibraryColumns)
+	for _, entry := range entries {
+		writer.Write(entry.record())
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,38 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestLibraryEntries(t *testing.T) {
+	store := NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	ctx := context.Background()
+	inMem := store.Data[orgId]
+	inMem.Metadata = append(inMem.Metadata, MetaData{Title: "Alpha", Composer: "Grieg", Duration: Duration(4*time.Minute + 30*time.Second)}, MetaData{Title: "Binned", Deleted: true})
+
+	entries, err := LibraryEntries(ctx, store, orgId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(entries), 3)
+	testutils.AssertEqual(t, entries[0].Meta.Title, "Alpha")
+	testutils.AssertEqual(t, entries[0].NumParts, 0)
+	testutils.AssertEqual(t, entries[1].NumParts, 5)
+
+	var buf bytes.Buffer
+	testutils.AssertNil(t, WriteLibraryCSV(&buf, entries))
+	testutils.AssertEqual(t, strings.HasPrefix(buf.String(), utf8BOM), true)
+
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), utf8BOM))).ReadAll()
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(records), 4)
+	testutils.AssertEqual(t, strings.Join(records[0], ","), strings.Join(LibraryColumns, ","))
+	testutils.AssertEqual(t, strings.Join(records[1], ","), "alpha_grieg,Alpha,Grieg,,,,,,,4m30s,,0")
+	testutils.AssertEqual(t, records[2][len(LibraryColumns)-1], "5")
+}
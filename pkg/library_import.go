@@ -0,0 +1,282 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const MaxLibraryImportRows = 2000
+
+// LibraryPdfColumn is the optional column of an import with the URL of a PDF, or the name of a PDF uploaded
+// together with the CSV file
+const LibraryPdfColumn = "pdf"
+
+// LibraryImportRow is one piece of an imported library. Line is the line of the row in the CSV file
+type LibraryImportRow struct {
+	Line int
+	Meta MetaData
+	Pdf  string
+
+	// err is set when the row has invalid values, and is reported when the rows are imported
+	err error
+}
+
+type LibraryImportResult struct {
+	Line       int    `json:"line"`
+	ResourceId string `json:"resourceId,omitempty"`
+	Ok         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+}
+
+type LibraryImportStore interface {
+	MetaByIdGetter
+	Submitter
+}
+
+// PdfSource gives the part name and the content of the PDF referred to by the pdf column of an import
+type PdfSource interface {
+	Pdf(ctx context.Context, ref string) (string, []byte, error)
+}
+
+func setLibraryField(meta *MetaData, column, value string) error {
+	switch column {
+	case "title":
+		meta.Title = value
+	case "composer":
+		meta.Composer = value
+	case "arranger":
+		meta.Arranger = value
+	case "genre":
+		meta.Genre = value
+	case "year":
+		meta.Year = value
+	case "instrumentation":
+		meta.Instrumentation = value
+	case "publisher":
+		meta.Publisher = value
+	case "ismn":
+		meta.Ismn = value
+	case "tags":
+		meta.Tags = value
+	case "duration":
+		if value == "" {
+			return nil
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, use a format like 3m20s", value)
+		}
+		meta.Duration = Duration(duration)
+	}
+	return nil
+}
+
+// ParseLibraryCSV reads pieces from a CSV file with a header naming the columns. The columns are those of an
+// exported library, such that an export can be imported again, in addition to the optional pdf column. Unknown
+// columns, the resource id and the number of parts are ignored. Rows with invalid values are reported when the
+// rows are imported, while a file that can not be read is rejected
+func ParseLibraryCSV(r io.Reader) ([]LibraryImportRow, error) {
+	buffered := bufio.NewReader(r)
+	if bom, err := buffered.Peek(len(utf8BOM)); err == nil && string(bom) == utf8BOM {
+		buffered.Discard(len(utf8BOM))
+	}
+	reader := csv.NewReader(buffered)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.Join(ErrInvalidLibraryImport, errors.New("the file is empty"))
+	} else if err != nil {
+		return nil, errors.Join(ErrInvalidLibraryImport, err)
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		columns[i] = strings.ToLower(strings.TrimSpace(name))
+	}
+	if !slices.Contains(columns, "title") {
+		return nil, errors.Join(ErrInvalidLibraryImport, errors.New("the file needs a title column"))
+	}
+
+	var rows []LibraryImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, errors.Join(ErrInvalidLibraryImport, err)
+		}
+		if !slices.ContainsFunc(record, func(value string) bool { return strings.TrimSpace(value) != "" }) {
+			continue
+		}
+		if len(rows) == MaxLibraryImportRows {
+			return nil, errors.Join(ErrInvalidLibraryImport, fmt.Errorf("at most %d pieces can be imported at once", MaxLibraryImportRows))
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := LibraryImportRow{Line: line}
+		for i, value := range record[:min(len(record), len(columns))] {
+			value = strings.TrimSpace(value)
+			if columns[i] == LibraryPdfColumn {
+				row.Pdf = value
+			} else if err := setLibraryField(&row.Meta, columns[i], value); err != nil {
+				row.err = errors.Join(ErrInvalidLibraryImport, err)
+			}
+		}
+		if row.err == nil && row.Meta.ResourceId() == "" {
+			row.err = errors.Join(ErrInvalidLibraryImport, errors.New("a title with letters or digits is required"))
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, errors.Join(ErrInvalidLibraryImport, errors.New("the file has no pieces"))
+	}
+	return rows, nil
+}
+
+// importLibraryRow creates the piece of the row. Existing pieces are never replaced
+func importLibraryRow(ctx context.Context, store LibraryImportStore, orgId string, row *LibraryImportRow, pdfs PdfSource) error {
+	if row.err != nil {
+		return row.err
+	}
+	resourceId := row.Meta.ResourceId()
+	if _, err := store.MetaById(ctx, orgId, resourceId); err == nil {
+		return errors.Join(ErrResourceExists, fmt.Errorf("resource id: %s", resourceId))
+	} else if !errors.Is(err, ErrResourceMetadataNotFound) {
+		return err
+	}
+
+	parts := iter.Seq2[string, []byte](func(yield func(string, []byte) bool) {})
+	if row.Pdf != "" {
+		if pdfs == nil {
+			return errors.Join(ErrInvalidLibraryImport, errors.New("no pdfs are available for the import"))
+		}
+		name, content, err := pdfs.Pdf(ctx, row.Pdf)
+		if err != nil {
+			return err
+		}
+		parts = func(yield func(string, []byte) bool) { yield(name, content) }
+	}
+	meta := row.Meta
+	return store.Submit(ctx, orgId, &meta, parts)
+}
+
+// ImportLibrary creates a piece for each row, with the PDF of the row as its only part. Pieces that already exist
+// are left untouched, such that an import can be repeated after the failing rows are fixed. A row that fails does
+// not affect the others, and the outcome is reported in the result with the same index as the row
+func ImportLibrary(ctx context.Context, store LibraryImportStore, orgId string, rows []LibraryImportRow, pdfs PdfSource) []LibraryImportResult {
+	results := make([]LibraryImportResult, len(rows))
+	for i := range rows {
+		results[i] = LibraryImportResult{Line: rows[i].Line, ResourceId: rows[i].Meta.ResourceId()}
+		if err := importLibraryRow(ctx, store, orgId, &rows[i], pdfs); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Ok = true
+	}
+	return results
+}
+
+// ImportPdfs finds the PDFs of an import by name among the files uploaded with it, or downloads them when the pdf
+// column holds a http or https URL. URLs are only followed when Client is set, and downloads larger than MaxSize
+// bytes are rejected
+type ImportPdfs struct {
+	Files   map[string][]byte
+	Client  *http.Client
+	MaxSize int64
+}
+
+func (p *ImportPdfs) Pdf(ctx context.Context, ref string) (string, []byte, error) {
+	var name string
+	var content []byte
+	if u, err := url.Parse(ref); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		name = path.Base(u.Path)
+		if content, err = p.download(ctx, ref); err != nil {
+			return "", nil, err
+		}
+	} else {
+		var ok bool
+		name = path.Base(strings.ReplaceAll(ref, "\\", "/"))
+		if content, ok = p.Files[name]; !ok {
+			return "", nil, errors.Join(ErrFileNotFound, fmt.Errorf("%s was not uploaded with the import", name))
+		}
+	}
+
+	if !bytes.HasPrefix(content, []byte("%PDF-")) {
+		return "", nil, errors.Join(ErrInvalidLibraryImport, fmt.Errorf("%s is not a pdf", ref))
+	}
+	partName, err := PartName(name)
+	if err != nil {
+		partName = "Score.pdf"
+	}
+	return partName, content, nil
+}
+
+func (p *ImportPdfs) download(ctx context.Context, ref string) ([]byte, error) {
+	if p.Client == nil {
+		return nil, errors.Join(ErrInvalidLibraryImport, errors.New("pdfs can not be downloaded from urls"))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref, nil)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidLibraryImport, err)
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not download %s: %w", ref, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading %s failed with status %d", ref, resp.StatusCode)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, p.MaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("could not download %s: %w", ref, err)
+	}
+	if int64(len(content)) > p.MaxSize {
+		return nil, errors.Join(ErrInvalidLibraryImport, fmt.Errorf("%s is larger than %d bytes", ref, p.MaxSize))
+	}
+	return content, nil
+}
+
+// publicAddressOnly refuses connections to loopback, private and link local addresses
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return fmt.Errorf("%s is not a public address", ip)
+	}
+	return nil
+}
+
+// NewPublicHTTPClient returns a client that only connects to public addresses, such that URLs given by users can
+// not be used to reach services on the network of the server
+func NewPublicHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: publicAddressOnly}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
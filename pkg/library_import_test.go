@@ -0,0 +1,137 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestParseLibraryCSVReadsExport(t *testing.T) {
+	entries := []LibraryEntry{{Meta: MetaData{Title: "Peer Gynt", Composer: "Grieg", Tags: "march,concert", Duration: Duration(3 * time.Minute)}, NumParts: 4}}
+	var buf bytes.Buffer
+	testutils.AssertNil(t, WriteLibraryCSV(&buf, entries))
+
+	rows, err := ParseLibraryCSV(&buf)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(rows), 1)
+	testutils.AssertEqual(t, rows[0].Line, 2)
+	testutils.AssertEqual(t, rows[0].Meta, entries[0].Meta)
+	testutils.AssertNil(t, rows[0].err)
+}
+
+func TestParseLibraryCSV(t *testing.T) {
+	content := "Title, Composer,Duration,PDF,Unknown\n" +
+		"Alpha,Grieg,,alpha.pdf,ignored\n" +
+		",,,,\n" +
+		"Beta,Holst,soon\n" +
+		"!!!\n"
+	rows, err := ParseLibraryCSV(strings.NewReader(content))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(rows), 3)
+	testutils.AssertEqual(t, rows[0].Meta.ResourceId(), "alpha_grieg")
+	testutils.AssertEqual(t, rows[0].Pdf, "alpha.pdf")
+	testutils.AssertEqual(t, rows[1].Line, 4)
+	testutils.AssertContains(t, rows[1].err.Error(), "invalid duration")
+	testutils.AssertContains(t, rows[2].err.Error(), "title")
+
+	for _, test := range []struct {
+		desc, content string
+	}{
+		{"empty", ""},
+		{"no title column", "composer\nGrieg\n"},
+		{"no pieces", "title\n"},
+		{"broken quotes", "title\n\"Alpha\n"},
+		{"too many rows", "title\n" + strings.Repeat("Alpha\n", MaxLibraryImportRows+1)},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := ParseLibraryCSV(strings.NewReader(test.content))
+			testutils.AssertEqual(t, IsInvalidInput(err), true)
+		})
+	}
+}
+
+func TestImportLibrary(t *testing.T) {
+	var pdf bytes.Buffer
+	testutils.AssertNil(t, CreateNPagePdf(&pdf, 1))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/scores/Trumpet.pdf" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(pdf.Bytes())
+	}))
+	defer server.Close()
+
+	store := NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	ctx := context.Background()
+	content := "title,composer,arranger,pdf\n" +
+		"Demo Title 1,Composer A,Arranger X,\n" +
+		"Alpha,,,\n" +
+		"Beta,,,beta.pdf\n" +
+		"Gamma,,," + server.URL + "/scores/Trumpet.pdf\n" +
+		"Delta,,,missing.pdf\n" +
+		"Epsilon,,," + server.URL + "/unknown.pdf\n" +
+		"Zeta,,,notes.pdf\n"
+	rows, err := ParseLibraryCSV(strings.NewReader(content))
+	testutils.AssertNil(t, err)
+
+	pdfs := &ImportPdfs{
+		Files:   map[string][]byte{"beta.pdf": pdf.Bytes(), "notes.pdf": []byte("not a pdf")},
+		Client:  server.Client(),
+		MaxSize: 1 << 20,
+	}
+	results := ImportLibrary(ctx, store, orgId, rows, pdfs)
+	testutils.AssertEqual(t, len(results), 7)
+
+	ok := make([]bool, len(results))
+	for i, result := range results {
+		ok[i] = result.Ok
+	}
+	testutils.AssertEqual(t, slices.Equal(ok, []bool{false, true, true, true, false, false, false}), true)
+	testutils.AssertContains(t, results[0].Error, ErrResourceExists.Error())
+	testutils.AssertEqual(t, results[1].Line, 3)
+	testutils.AssertContains(t, results[4].Error, "missing.pdf")
+	testutils.AssertContains(t, results[5].Error, "404")
+	testutils.AssertContains(t, results[6].Error, "not a pdf")
+
+	meta, err := store.MetaById(ctx, orgId, "alpha")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, meta.Title, "Alpha")
+
+	for resourceId, part := range map[string]string{"beta": "beta.pdf", "gamma": "Trumpet.pdf"} {
+		names, err := store.ResourceItemNames(ctx, orgId+"/"+resourceId)
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, strings.Join(names, ","), orgId+"/"+resourceId+"/"+part)
+	}
+
+	t.Run("urls need a client", func(t *testing.T) {
+		_, _, err := (&ImportPdfs{}).Pdf(ctx, server.URL+"/scores/Trumpet.pdf")
+		testutils.AssertEqual(t, IsInvalidInput(err), true)
+	})
+
+	t.Run("downloads are limited", func(t *testing.T) {
+		_, _, err := (&ImportPdfs{Client: server.Client(), MaxSize: 10}).Pdf(ctx, server.URL+"/scores/Trumpet.pdf")
+		testutils.AssertContains(t, err.Error(), "larger than 10 bytes")
+	})
+}
+
+func TestPublicHTTPClientRefusesLocalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, err := NewPublicHTTPClient(time.Second).Get(server.URL)
+	testutils.AssertContains(t, err.Error(), "not a public address")
+
+	for _, address := range []string{"10.0.0.1:80", "169.254.169.254:80", "[::1]:443", "192.168.1.10:80"} {
+		testutils.AssertEqual(t, publicAddressOnly("tcp", address, nil) != nil, true)
+	}
+	testutils.AssertNil(t, publicAddressOnly("tcp", "8.8.8.8:443", nil))
+}
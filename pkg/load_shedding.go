@@ -0,0 +1,88 @@
+package pkg
+
+import (
+	"context"
+	"time"
+)
+
+// ConcurrencyLimit bounds how many expensive requests, such as uploads and zip archives, are handled at once
+type ConcurrencyLimit struct {
+	// Number of requests handled at once. Zero disables the limit
+	MaxConcurrent int `yaml:"max_concurrent"`
+
+	// Number of requests waiting for a free slot. Requests beyond the queue are rejected at once
+	MaxQueued int `yaml:"max_queued"`
+
+	// How long a queued request waits for a free slot before it is rejected
+	MaxWait time.Duration `yaml:"max_wait"`
+}
+
+type LoadShedding struct {
+	Uploads   ConcurrencyLimit `yaml:"uploads"`
+	Downloads ConcurrencyLimit `yaml:"downloads"`
+
+	// Wait suggested to clients in Retry-After when their request is rejected
+	RetryAfter time.Duration `yaml:"retry_after"`
+}
+
+func DefaultLoadShedding() LoadShedding {
+	return LoadShedding{
+		Uploads:    ConcurrencyLimit{MaxConcurrent: 4, MaxQueued: 16, MaxWait: 30 * time.Second},
+		Downloads:  ConcurrencyLimit{MaxConcurrent: 8, MaxQueued: 64, MaxWait: 30 * time.Second},
+		RetryAfter: 10 * time.Second,
+	}
+}
+
+// ConcurrencyLimiter hands out a bounded number of slots. Callers that find all slots taken wait in a bounded
+// queue, such that a burst of requests is smoothed out instead of exhausting the memory of the instance
+type ConcurrencyLimiter struct {
+	limit  ConcurrencyLimit
+	slots  chan struct{}
+	queued chan struct{}
+}
+
+func NewConcurrencyLimiter(limit ConcurrencyLimit) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		limit:  limit,
+		slots:  make(chan struct{}, max(limit.MaxConcurrent, 0)),
+		queued: make(chan struct{}, max(limit.MaxQueued, 0)),
+	}
+}
+
+// Acquire returns a function releasing the slot once one is free. ErrOverloaded is returned when the queue is
+// full or no slot was freed within the maximum wait
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context) (func(), error) {
+	if l.limit.MaxConcurrent <= 0 {
+		return func() {}, nil
+	}
+	release := func() { <-l.slots }
+
+	select {
+	case l.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	select {
+	case l.queued <- struct{}{}:
+	default:
+		return nil, ErrOverloaded
+	}
+	defer func() { <-l.queued }()
+
+	timer := time.NewTimer(l.limit.MaxWait)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, ErrOverloaded
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// InProgress returns the number of slots taken and the number of callers waiting for one
+func (l *ConcurrencyLimiter) InProgress() (int, int) {
+	return len(l.slots), len(l.queued)
+}
@@ -0,0 +1,61 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestConcurrencyLimiterQueuesBoundedNumber(t *testing.T) {
+	limiter := NewConcurrencyLimiter(ConcurrencyLimit{MaxConcurrent: 1, MaxQueued: 1, MaxWait: time.Second})
+	release, err := limiter.Acquire(context.Background())
+	testutils.AssertNil(t, err)
+
+	acquired := make(chan error)
+	go func() {
+		release, err := limiter.Acquire(context.Background())
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+
+	// Wait until the second caller is queued, such that the third finds the queue full
+	for _, queued := limiter.InProgress(); queued == 0; _, queued = limiter.InProgress() {
+		time.Sleep(time.Millisecond)
+	}
+	_, err = limiter.Acquire(context.Background())
+	testutils.AssertEqual(t, errors.Is(err, ErrOverloaded), true)
+
+	release()
+	testutils.AssertNil(t, <-acquired)
+	inProgress, queued := limiter.InProgress()
+	testutils.AssertEqual(t, inProgress, 0)
+	testutils.AssertEqual(t, queued, 0)
+}
+
+func TestConcurrencyLimiterMaxWait(t *testing.T) {
+	limiter := NewConcurrencyLimiter(ConcurrencyLimit{MaxConcurrent: 1, MaxQueued: 1, MaxWait: time.Millisecond})
+	_, err := limiter.Acquire(context.Background())
+	testutils.AssertNil(t, err)
+
+	_, err = limiter.Acquire(context.Background())
+	testutils.AssertEqual(t, errors.Is(err, ErrOverloaded), true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limiter.limit.MaxWait = time.Hour
+	_, err = limiter.Acquire(ctx)
+	testutils.AssertEqual(t, errors.Is(err, context.Canceled), true)
+}
+
+func TestConcurrencyLimiterDisabled(t *testing.T) {
+	limiter := NewConcurrencyLimiter(ConcurrencyLimit{})
+	for range 3 {
+		_, err := limiter.Acquire(context.Background())
+		testutils.AssertNil(t, err)
+	}
+}
@@ -0,0 +1,472 @@
+package pkg
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/storage"
+	_ "github.com/mattn/go-sqlite3"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	LocalFS            = "local-fs"
+	localBucket        = "scores"
+	localDatabaseName  = "caesura.db"
+	localDirectoryPerm = 0o755
+	localFilePerm      = 0o644
+)
+
+// localMigrations are applied in order on open. The number of applied migrations is kept in user_version,
+// so new migrations must be appended to the end
+var localMigrations = []string{
+	`CREATE TABLE documents (
+		dataset TEXT NOT NULL,
+		org_id  TEXT NOT NULL,
+		item_id TEXT NOT NULL,
+		data    TEXT NOT NULL,
+		PRIMARY KEY (dataset, org_id, item_id)
+	)`,
+}
+
+// FileBucketClient stores objects as files below Directory. Each bucket is a sub directory
+type FileBucketClient struct {
+	Directory string
+}
+
+// location resolves the file of the object. Object names that would resolve to a file outside the bucket
+// directory are rejected
+func (f *FileBucketClient) location(bucket, object string) (string, error) {
+	root := filepath.Join(f.Directory, bucket)
+	local := filepath.FromSlash(object)
+	if !filepath.IsLocal(local) {
+		return "", errors.Join(ErrInvalidObjectName, fmt.Errorf("%q is outside of bucket %s", object, bucket))
+	}
+	location := filepath.Join(root, local)
+	if rel, err := filepath.Rel(root, location); err != nil || !filepath.IsLocal(rel) {
+		return "", errors.Join(ErrInvalidObjectName, fmt.Errorf("%q is outside of bucket %s", object, bucket))
+	}
+	return location, nil
+}
+
+func (f *FileBucketClient) Upload(ctx context.Context, bucket, object string, data []byte) error {
+	location, err := f.location(bucket, object)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(location), localDirectoryPerm); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first such that readers never see a partially written file
+	tmp := location + ".tmp"
+	if err := os.WriteFile(tmp, data, localFilePerm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, location)
+}
+
+func (f *FileBucketClient) GetObject(ctx context.Context, bucket, objName string) (io.ReadCloser, error) {
+	location, err := f.location(bucket, objName)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(location)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errors.Join(storage.ErrObjectNotExist, err)
+	}
+	return file, err
+}
+
+func (f *FileBucketClient) ObjectSize(ctx context.Context, bucket, object string) (int64, error) {
+	location, err := f.location(bucket, object)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(location)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, errors.Join(storage.ErrObjectNotExist, err)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// NewRangeReader seeks in the file, such that only the range is read from the disk
+func (f *FileBucketClient) NewRangeReader(ctx context.Context, bucket, object string, offset, length int64) (io.ReadCloser, error) {
+	location, err := f.location(bucket, object)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(location)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errors.Join(storage.ErrObjectNotExist, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, errors.Join(err, file.Close())
+	}
+	if length < 0 {
+		return file, nil
+	}
+	return &bufferedReadCloser{Reader: io.LimitReader(file, length), Closer: file}, nil
+}
+
+func (f *FileBucketClient) GetObjects(ctx context.Context, bucket string, query *storage.Query) ObjectLister {
+	root := filepath.Join(f.Directory, bucket)
+	lister := fileObjectLister{bucket: bucket}
+	dir, err := f.location(bucket, path.Dir(query.Prefix))
+	if err != nil {
+		lister.err = err
+		return &lister
+	}
+	err = filepath.WalkDir(dir, func(location string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fs.SkipAll
+		}
+		if err != nil || entry.IsDir() || strings.HasSuffix(location, ".tmp") {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, location)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if !strings.HasPrefix(name, query.Prefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		lister.objects = append(lister.objects, &storage.ObjectAttrs{Bucket: bucket, Name: name, Size: info.Size(), Updated: info.ModTime()})
+		return nil
+	})
+	lister.err = err
+	slices.SortFunc(lister.objects, func(a, b *storage.ObjectAttrs) int { return strings.Compare(a.Name, b.Name) })
+	return &lister
+}
+
+// SetStorageClass only verifies that the object exists since a disk has a single storage class
+func (f *FileBucketClient) SetStorageClass(ctx context.Context, bucket, object, class string) error {
+	location, err := f.location(bucket, object)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stat(location)
+	if errors.Is(err, fs.ErrNotExist) {
+		return errors.Join(storage.ErrObjectNotExist, err)
+	}
+	return err
+}
+
+func (f *FileBucketClient) Delete(ctx context.Context, bucket, object string) error {
+	location, err := f.location(bucket, object)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(location)
+	if errors.Is(err, fs.ErrNotExist) {
+		return errors.Join(storage.ErrObjectNotExist, err)
+	}
+	return err
+}
+
+type fileObjectLister struct {
+	bucket  string
+	objects []*storage.ObjectAttrs
+	err     error
+}
+
+func (f *fileObjectLister) Next() (*storage.ObjectAttrs, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if len(f.objects) == 0 {
+		return nil, iterator.Done
+	}
+	object := f.objects[0]
+	f.objects = f.objects[1:]
+	return object, nil
+}
+
+// SQLiteDocumentClient keeps documents as JSON in a single table. As for the S3DocumentClient the fields are
+// named after the firestore tags
+type SQLiteDocumentClient struct {
+	DB *sql.DB
+
+	// Serializes read-modify-write updates within this process
+	mu sync.Mutex
+
+	// Set for the clients passed to the function of RunTransaction
+	tx *sql.Tx
+}
+
+func (s *SQLiteDocumentClient) db() sqlQuerier {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.DB
+}
+
+// RunTransaction holds the single connection to the database while fn runs, so other requests wait until the
+// transaction is committed
+func (s *SQLiteDocumentClient) RunTransaction(ctx context.Context, fn func(ctx context.Context, client FirestoreClient) error) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(ctx, &SQLiteDocumentClient{DB: s.DB, tx: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func migrateSQLite(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("could not read schema version: %w", err)
+	}
+
+	for i := version; i < len(localMigrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(localMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d failed: %w", i+1, err)
+		}
+
+		// PRAGMA does not support placeholders
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteDocumentClient) put(ctx context.Context, dataset, orgId, itemId string, fields map[string]json.RawMessage) error {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	_, err = s.db().ExecContext(
+		ctx,
+		`INSERT INTO documents (dataset, org_id, item_id, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT (dataset, org_id, item_id) DO UPDATE SET data = excluded.data`,
+		dataset, orgId, itemId, string(data),
+	)
+	return err
+}
+
+func (s *SQLiteDocumentClient) get(ctx context.Context, dataset, orgId, itemId string) (map[string]json.RawMessage, error) {
+	var data string
+	err := s.db().QueryRowContext(
+		ctx,
+		"SELECT data FROM documents WHERE dataset = ? AND org_id = ? AND item_id = ?",
+		dataset, orgId, itemId,
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Errorf(codes.NotFound, "%s not found", path.Join(dataset, orgId, itemId))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &fields); err != nil {
+		return nil, fmt.Errorf("could not decode document %s: %w", path.Join(dataset, orgId, itemId), err)
+	}
+	return fields, nil
+}
+
+func (s *SQLiteDocumentClient) StoreDocument(ctx context.Context, dataset, orgId, itemId string, data any) error {
+	fields, err := encodeFirestoreFields(data)
+	if err != nil {
+		return err
+	}
+	return s.put(ctx, dataset, orgId, itemId, fields)
+}
+
+func (s *SQLiteDocumentClient) Update(ctx context.Context, dataset, orgId, itemId string, update []firestore.Update) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fields, err := s.get(ctx, dataset, orgId, itemId)
+	if err != nil {
+		return err
+	}
+	for _, u := range update {
+		if err := applyUpdate(fields, u); err != nil {
+			return fmt.Errorf("could not update %s of %s: %w", updateName(u), path.Join(dataset, orgId, itemId), err)
+		}
+	}
+	return s.put(ctx, dataset, orgId, itemId, fields)
+}
+
+// GetDocByPrefix reads all matching documents before yielding such that callers can update the
+// documents while iterating
+func (s *SQLiteDocumentClient) GetDocByPrefix(ctx context.Context, dataset, orgId, field, prefix string) iter.Seq[Document] {
+	return func(yield func(doc Document) bool) {
+		rows, err := s.db().QueryContext(
+			ctx,
+			`SELECT data FROM documents
+			WHERE dataset = ? AND org_id = ? AND substr(json_extract(data, ?), 1, length(?)) = ?
+			ORDER BY item_id`,
+			dataset, orgId, "$."+field, prefix, prefix,
+		)
+		if err != nil {
+			logOnErrorNotDone(err)
+			return
+		}
+
+		var docs []Document
+		for rows.Next() {
+			var data string
+			var fields map[string]json.RawMessage
+			if err := rows.Scan(&data); err != nil {
+				logOnErrorNotDone(err)
+				continue
+			}
+			if err := json.Unmarshal([]byte(data), &fields); err != nil {
+				logOnErrorNotDone(err)
+				continue
+			}
+			docs = append(docs, &JSONDocument{fields: fields})
+		}
+		if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+			logOnErrorNotDone(err)
+		}
+
+		for _, doc := range docs {
+			if !yield(doc) {
+				return
+			}
+		}
+	}
+}
+
+func (s *SQLiteDocumentClient) GetDocPage(ctx context.Context, dataset, orgId, after string, limit int) iter.Seq[Document] {
+	return func(yield func(doc Document) bool) {
+		rows, err := s.db().QueryContext(
+			ctx,
+			`SELECT data FROM documents
+			WHERE dataset = ? AND org_id = ? AND item_id > ?
+			ORDER BY item_id LIMIT ?`,
+			dataset, orgId, after, limit,
+		)
+		if err != nil {
+			logOnErrorNotDone(err)
+			return
+		}
+
+		var docs []Document
+		for rows.Next() {
+			var data string
+			var fields map[string]json.RawMessage
+			if err := rows.Scan(&data); err != nil {
+				logOnErrorNotDone(err)
+				continue
+			}
+			if err := json.Unmarshal([]byte(data), &fields); err != nil {
+				logOnErrorNotDone(err)
+				continue
+			}
+			docs = append(docs, &JSONDocument{fields: fields})
+		}
+		if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+			logOnErrorNotDone(err)
+		}
+
+		for _, doc := range docs {
+			if !yield(doc) {
+				return
+			}
+		}
+	}
+}
+
+func (s *SQLiteDocumentClient) GetDoc(ctx context.Context, dataset, orgId, itemId string) (Document, error) {
+	fields, err := s.get(ctx, dataset, orgId, itemId)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONDocument{fields: fields}, nil
+}
+
+func (s *SQLiteDocumentClient) DeleteDoc(ctx context.Context, dataset, collection, itemId string) error {
+	_, err := s.db().ExecContext(
+		ctx,
+		"DELETE FROM documents WHERE dataset = ? AND org_id = ? AND item_id = ?",
+		dataset, collection, itemId,
+	)
+	return err
+}
+
+// LocalStore keeps scores as files in a directory and all documents in SQLite, such that Caesura can
+// run on a single server without any cloud dependency. The SQLite driver requires a build with cgo enabled
+type LocalStore struct {
+	GoogleStore
+	db *sql.DB
+}
+
+func NewLocalStore(config *LocalFSStoreConfig) (*LocalStore, error) {
+	if err := os.MkdirAll(config.Directory, localDirectoryPerm); err != nil {
+		return nil, fmt.Errorf("could not create directory %s: %w", config.Directory, err)
+	}
+
+	database := config.Database
+	if database == "" {
+		database = filepath.Join(config.Directory, localDatabaseName)
+	}
+	db, err := sql.Open("sqlite3", database+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("could not open database %s: %w", database, err)
+	}
+
+	// SQLite allows a single writer. One connection avoids busy errors between concurrent requests
+	db.SetMaxOpenConns(1)
+	if err := migrateSQLite(db); err != nil {
+		return nil, errors.Join(err, db.Close())
+	}
+
+	return &LocalStore{
+		GoogleStore: GoogleStore{
+			BucketClient: &FileBucketClient{Directory: config.Directory},
+			FsClient:     &SQLiteDocumentClient{DB: db},
+			Config:       &GoogleConfig{Bucket: localBucket},
+		},
+		db: db,
+	}, nil
+}
+
+func (l *LocalStore) Close() error {
+	return l.db.Close()
+}
@@ -0,0 +1,210 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/storage"
+	"github.com/davidkleiven/caesura/testutils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestLocalStore(t *testing.T) (*LocalStore, *LocalFSStoreConfig) {
+	config := LocalFSStoreConfig{Directory: t.TempDir()}
+	store, err := NewLocalStore(&config)
+	testutils.AssertNil(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store, &config
+}
+
+func TestFileBucketClient(t *testing.T) {
+	client := FileBucketClient{Directory: t.TempDir()}
+	ctx := context.Background()
+	for _, name := range []string{"org/a/2.pdf", "org/a/1.pdf", "org/ab/1.pdf", "other/b/1.pdf"} {
+		testutils.AssertNil(t, client.Upload(ctx, "bucket", name, []byte(name)))
+	}
+
+	collect := func(prefix string) string {
+		objects := client.GetObjects(ctx, "bucket", &storage.Query{Prefix: prefix})
+		var names []string
+		for {
+			attrs, err := objects.Next()
+			if err != nil {
+				break
+			}
+			testutils.AssertEqual(t, attrs.Size, int64(len(attrs.Name)))
+			names = append(names, attrs.Name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	testutils.AssertEqual(t, collect("org/a/"), "org/a/1.pdf,org/a/2.pdf")
+	testutils.AssertEqual(t, collect("org/a"), "org/a/1.pdf,org/a/2.pdf,org/ab/1.pdf")
+	testutils.AssertEqual(t, collect(""), "org/a/1.pdf,org/a/2.pdf,org/ab/1.pdf,other/b/1.pdf")
+	testutils.AssertEqual(t, collect("missing/"), "")
+
+	_, err := client.GetObject(ctx, "bucket", "org/a/3.pdf")
+	testutils.AssertEqual(t, errors.Is(err, storage.ErrObjectNotExist), true)
+	testutils.AssertEqual(t, errors.Is(client.SetStorageClass(ctx, "bucket", "org/a/3.pdf", "COLDLINE"), storage.ErrObjectNotExist), true)
+	testutils.AssertNil(t, client.SetStorageClass(ctx, "bucket", "org/a/1.pdf", "COLDLINE"))
+
+	testutils.AssertNil(t, client.Delete(ctx, "bucket", "org/a/1.pdf"))
+	testutils.AssertEqual(t, collect("org/a/"), "org/a/2.pdf")
+	testutils.AssertEqual(t, errors.Is(client.Delete(ctx, "bucket", "org/a/1.pdf"), storage.ErrObjectNotExist), true)
+}
+
+func TestFileBucketClientRejectsObjectsOutsideBucket(t *testing.T) {
+	root := t.TempDir()
+	client := FileBucketClient{Directory: filepath.Join(root, "data")}
+	ctx := context.Background()
+	for _, name := range []string{"../escaped.pdf", "org/../../escaped.pdf", "org/../../../../escaped.pdf", "/abs.pdf"} {
+		testutils.AssertEqual(t, errors.Is(client.Upload(ctx, "bucket", name, []byte("data")), ErrInvalidObjectName), true)
+		_, err := client.GetObject(ctx, "bucket", name)
+		testutils.AssertEqual(t, errors.Is(err, ErrInvalidObjectName), true)
+		testutils.AssertEqual(t, errors.Is(client.Delete(ctx, "bucket", name), ErrInvalidObjectName), true)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(root, "*.pdf"))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(matches), 0)
+}
+
+func TestSQLiteDocumentClient(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+	client := store.FsClient
+	ctx := context.Background()
+
+	for _, org := range []Organization{{Id: "org1", Name: "Brass band"}, {Id: "org2", Name: "Big band"}, {Id: "org3", Name: "Choir"}} {
+		testutils.AssertNil(t, client.StoreDocument(ctx, organizationCollection, organizationInfo, org.Id, &org))
+	}
+
+	var names []string
+	for doc := range client.GetDocByPrefix(ctx, organizationCollection, organizationInfo, "name", "B") {
+		var org Organization
+		testutils.AssertNil(t, doc.DataTo(&org))
+		names = append(names, org.Name)
+	}
+	testutils.AssertEqual(t, strings.Join(names, ","), "Brass band,Big band")
+
+	testutils.AssertNil(t, client.Update(ctx, organizationCollection, organizationInfo, "org1", []firestore.Update{{Path: "name", Value: "Wind band"}}))
+	doc, err := client.GetDoc(ctx, organizationCollection, organizationInfo, "org1")
+	testutils.AssertNil(t, err)
+	var org Organization
+	testutils.AssertNil(t, doc.DataTo(&org))
+	testutils.AssertEqual(t, org.Name, "Wind band")
+
+	testutils.AssertNil(t, client.DeleteDoc(ctx, organizationCollection, organizationInfo, "org1"))
+	_, err = client.GetDoc(ctx, organizationCollection, organizationInfo, "org1")
+	testutils.AssertEqual(t, status.Code(err), codes.NotFound)
+
+	err = client.Update(ctx, organizationCollection, organizationInfo, "org1", []firestore.Update{{Path: "name", Value: "Band"}})
+	testutils.AssertEqual(t, status.Code(err), codes.NotFound)
+}
+
+func TestLocalStoreSubmitAndRetrieve(t *testing.T) {
+	store, config := newTestLocalStore(t)
+	ctx := context.Background()
+	meta := MetaData{Title: "Title", Composer: "Composer", Duration: Duration(90 * time.Second)}
+	parts := func(yield func(string, []byte) bool) {
+		for _, name := range []string{"Part1.pdf", "Part2.pdf"} {
+			if !yield(name, []byte(name)) {
+				return
+			}
+		}
+	}
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, parts))
+
+	stored, err := store.MetaById(ctx, "org", meta.ResourceId())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Status, StoreStatusFinished)
+	testutils.AssertEqual(t, stored.Duration, meta.Duration)
+
+	found, err := store.MetaByPattern(ctx, "org", &MetaData{Title: "tit"})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(found), 1)
+
+	content := maps.Collect(store.Resource(ctx, "org", meta.ResourceId()))
+	testutils.AssertEqual(t, string(content["Part1.pdf"]), "Part1.pdf")
+	testutils.AssertEqual(t, len(content), 2)
+	testutils.AssertNil(t, store.TransitionStorageClass(ctx, "org", meta.ResourceId(), StorageClassCold))
+
+	_, err = store.MetaById(ctx, "org", "missing")
+	testutils.AssertEqual(t, errors.Is(err, ErrResourceMetadataNotFound), true)
+
+	// Content must survive a restart
+	testutils.AssertNil(t, store.Close())
+	reopened, err := NewLocalStore(config)
+	testutils.AssertNil(t, err)
+	defer reopened.Close()
+
+	stored, err = reopened.MetaById(ctx, "org", meta.ResourceId())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Title, "Title")
+	testutils.AssertEqual(t, stored.StorageClass, StorageClassCold)
+}
+
+func TestLocalStoreProjectNotes(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+	assertProjectNotesRoundTrip(t, &store.GoogleStore)
+}
+
+func TestLocalStoreUsersAndSubscriptions(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+	ctx := context.Background()
+	user := UserInfo{
+		Id:     "user-id",
+		Email:  "user@example.com",
+		Roles:  map[string]RoleKind{"org1": RoleEditor},
+		Groups: map[string][]string{"org1": {"group1"}},
+	}
+	testutils.AssertNil(t, store.RegisterUser(ctx, &user))
+	testutils.AssertNil(t, store.RegisterGroup(ctx, "user-id", "org1", "group2"))
+
+	received, err := store.GetUserInfo(ctx, "user-id")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(received.Groups["org1"], ","), "group1,group2")
+	testutils.AssertEqual(t, received.Roles["org1"], RoleEditor)
+
+	byEmail, err := store.UserByEmail(ctx, "user@example.com")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, byEmail.Id, "user-id")
+
+	org := Organization{Id: "org1", Name: "Band", StripeId: "stripe-id"}
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &org))
+	subscription := Subscription{Id: "sub", Expires: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+	testutils.AssertNil(t, store.StoreSubscription(ctx, "stripe-id", &subscription))
+
+	receivedSub, err := store.GetSubscription(ctx, "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, receivedSub.Expires.Equal(subscription.Expires), true)
+}
+
+func TestLocalStoreMigrationsAreIdempotent(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+	testutils.AssertNil(t, migrateSQLite(store.db))
+
+	var version int
+	testutils.AssertNil(t, store.db.QueryRow("PRAGMA user_version").Scan(&version))
+	testutils.AssertEqual(t, version, len(localMigrations))
+}
+
+func TestNewLocalStoreUsesConfiguredDatabase(t *testing.T) {
+	dir := t.TempDir()
+	config := LocalFSStoreConfig{Directory: filepath.Join(dir, "scores"), Database: filepath.Join(dir, "meta.db")}
+	store, err := NewLocalStore(&config)
+	testutils.AssertNil(t, err)
+	testutils.AssertNil(t, store.Close())
+
+	matches, err := filepath.Glob(filepath.Join(dir, "meta.db*"))
+	testutils.AssertNil(t, err)
+	if len(matches) == 0 {
+		t.Fatal("Wanted database to be created at the configured location")
+	}
+}
@@ -0,0 +1,233 @@
+package pkg
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LogExportFormat string
+
+const (
+	LogExportCSV  LogExportFormat = "csv"
+	LogExportJSON LogExportFormat = "json"
+)
+
+// Longest date range of a single export
+const maxLogExportRange = 366 * 24 * time.Hour
+
+func (f LogExportFormat) ContentType() string {
+	if f == LogExportJSON {
+		return "application/json"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+func ParseLogExportFormat(format string) (LogExportFormat, error) {
+	switch LogExportFormat(format) {
+	case LogExportCSV, LogExportJSON:
+		return LogExportFormat(format), nil
+	}
+	return "", errors.Join(ErrInvalidLogExport, fmt.Errorf("unknown format %q", format))
+}
+
+// ActivityLogEntry is one line of an exported log. The name and email are those of the user when the log was
+// exported, and are empty for users that have left the organization
+type ActivityLogEntry struct {
+	Time        time.Time    `json:"time"`
+	Kind        ActivityKind `json:"kind"`
+	UserId      string       `json:"userId"`
+	UserName    string       `json:"userName"`
+	UserEmail   string       `json:"userEmail"`
+	ProjectId   string       `json:"projectId"`
+	ResourceIds []string     `json:"resourceIds"`
+}
+
+func NewActivityLogEntries(activities []Activity, users []UserInfo) []ActivityLogEntry {
+	byId := make(map[string]UserInfo, len(users))
+	for _, user := range users {
+		byId[user.Id] = user
+	}
+
+	entries := make([]ActivityLogEntry, len(activities))
+	for i, activity := range activities {
+		user := byId[activity.UserId]
+		entries[i] = ActivityLogEntry{
+			Time:        activity.Time,
+			Kind:        activity.Kind,
+			UserId:      activity.UserId,
+			UserName:    user.Name,
+			UserEmail:   user.Email,
+			ProjectId:   activity.ProjectId,
+			ResourceIds: activity.ResourceIds,
+		}
+	}
+	return entries
+}
+
+func WriteActivityLog(w io.Writer, format LogExportFormat, entries []ActivityLogEntry) error {
+	if format == LogExportJSON {
+		return json.NewEncoder(w).Encode(entries)
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"time", "kind", "user_id", "user_name", "user_email", "project_id", "resource_ids"})
+	for _, entry := range entries {
+		writer.Write([]string{
+			entry.Time.UTC().Format(time.RFC3339),
+			string(entry.Kind),
+			entry.UserId,
+			entry.UserName,
+			entry.UserEmail,
+			entry.ProjectId,
+			strings.Join(entry.ResourceIds, ";"),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// LogExportRange returns the start of the day of from and the end of the day of to. The range can not be
+// longer than a year
+func LogExportRange(from, to time.Time) (time.Time, time.Time, error) {
+	start := from.Truncate(24 * time.Hour)
+	end := to.Truncate(24*time.Hour).AddDate(0, 0, 1)
+	if !start.Before(end) || end.Sub(start) > maxLogExportRange {
+		return start, end, errors.Join(ErrInvalidLogExport, fmt.Errorf("the range must end after it starts and be at most %d days", int(maxLogExportRange.Hours()/24)))
+	}
+	return start, end, nil
+}
+
+// LogExport is an exported log of an organization covering the activity from (inclusive) to (exclusive)
+type LogExport struct {
+	Id         string
+	OrgId      string
+	Format     LogExportFormat
+	From       time.Time
+	To         time.Time
+	NumEntries int
+	CreatedAt  time.Time
+}
+
+func (l *LogExport) Filename() string {
+	return fmt.Sprintf("caesura-log-%s-%s.%s", l.From.Format(time.DateOnly), l.To.Add(-time.Second).Format(time.DateOnly), l.Format)
+}
+
+type LogExportStore interface {
+	OrganizationActivityGetter
+	UserInOrgGetter
+}
+
+// LogExports writes exported logs to a directory on local disk. Like UploadSessions, the exports are kept in
+// memory and can only be downloaded from the instance that created them. Exports are removed after Expiry
+type LogExports struct {
+	Dir    string
+	Expiry time.Duration
+
+	mu      sync.Mutex
+	exports map[string]*LogExport
+	jobs    sync.WaitGroup
+}
+
+func NewLogExports(dir string, expiry time.Duration) *LogExports {
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "caesura-exports")
+	}
+	return &LogExports{Dir: dir, Expiry: expiry, exports: make(map[string]*LogExport)}
+}
+
+func (l *LogExports) path(id string) string {
+	return filepath.Join(l.Dir, id)
+}
+
+// Create writes the log of the organization for the days from the date of from to the date of to, both
+// included
+func (l *LogExports) Create(ctx context.Context, store LogExportStore, orgId string, from, to time.Time, format LogExportFormat) (*LogExport, error) {
+	l.RemoveExpired(time.Now())
+
+	start, end, err := LogExportRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	export := LogExport{Id: uuid.NewString(), OrgId: orgId, Format: format, From: start, To: end, CreatedAt: time.Now()}
+
+	activities, err := store.OrganizationActivity(ctx, orgId, export.From, export.To)
+	if err != nil {
+		return nil, err
+	}
+	users, err := store.GetUsersInOrg(ctx, orgId)
+	if err != nil {
+		return nil, err
+	}
+	export.NumEntries = len(activities)
+
+	if err := os.MkdirAll(l.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("could not create export directory: %w", err)
+	}
+	f, err := os.OpenFile(l.path(export.Id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	err = WriteActivityLog(f, format, NewActivityLogEntries(activities, users))
+	if err := errors.Join(err, f.Close()); err != nil {
+		return nil, errors.Join(err, os.Remove(l.path(export.Id)))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.exports[export.Id] = &export
+	return &export, nil
+}
+
+// Open returns an export of the organization. The caller must close the file
+func (l *LogExports) Open(orgId, id string) (*LogExport, *os.File, error) {
+	l.mu.Lock()
+	export, ok := l.exports[id]
+	l.mu.Unlock()
+	if !ok || export.OrgId != orgId || time.Since(export.CreatedAt) > l.Expiry {
+		return nil, nil, errors.Join(ErrLogExportNotFound, fmt.Errorf("export id: %s", id))
+	}
+
+	f, err := os.Open(l.path(id))
+	if err != nil {
+		return nil, nil, errors.Join(ErrLogExportNotFound, err)
+	}
+	return export, f, nil
+}
+
+func (l *LogExports) RemoveExpired(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for id, export := range l.exports {
+		if now.Sub(export.CreatedAt) > l.Expiry {
+			if err := os.Remove(l.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				slog.Warn("Could not remove expired log export", "id", id, "error", err)
+			}
+			delete(l.exports, id)
+		}
+	}
+}
+
+// Go runs job in the background. Wait returns when all jobs have finished
+func (l *LogExports) Go(job func()) {
+	l.jobs.Add(1)
+	go func() {
+		defer l.jobs.Done()
+		job()
+	}()
+}
+
+func (l *LogExports) Wait() {
+	l.jobs.Wait()
+}
@@ -0,0 +1,84 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestLogExportsCreate(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	for i, day := range []int{1, 15, 16} {
+		activity := NewActivity("", ActivityEmailSent, "a", nil)
+		activity.Id = string(rune('a' + i))
+		activity.Time = time.Date(2026, 3, day, 12, 0, 0, 0, time.UTC)
+		testutils.AssertNil(t, store.RecordActivity(ctx, "org", activity))
+	}
+
+	exports := NewLogExports(t.TempDir(), time.Hour)
+	from, to := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	export, err := exports.Create(ctx, store, "org", from, to, LogExportJSON)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, export.NumEntries, 2)
+	testutils.AssertEqual(t, export.Filename(), "caesura-log-2026-03-01-2026-03-15.json")
+
+	_, f, err := exports.Open("org", export.Id)
+	testutils.AssertNil(t, err)
+	content, err := io.ReadAll(f)
+	testutils.AssertNil(t, errors.Join(err, f.Close()))
+	var entries []ActivityLogEntry
+	testutils.AssertNil(t, json.Unmarshal(content, &entries))
+	testutils.AssertEqual(t, len(entries), 2)
+	testutils.AssertEqual(t, entries[0].Time.Day(), 15)
+
+	_, _, err = exports.Open("other", export.Id)
+	testutils.AssertEqual(t, errors.Is(err, ErrLogExportNotFound), true)
+
+	exports.RemoveExpired(time.Now().Add(2 * time.Hour))
+	_, _, err = exports.Open("org", export.Id)
+	testutils.AssertEqual(t, errors.Is(err, ErrLogExportNotFound), true)
+}
+
+func TestLogExportRange(t *testing.T) {
+	day := time.Date(2026, 3, 1, 15, 0, 0, 0, time.UTC)
+	start, end, err := LogExportRange(day, day)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, end.Sub(start), 24*time.Hour)
+
+	_, _, err = LogExportRange(day, day.AddDate(0, 0, -1))
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidLogExport), true)
+	_, _, err = LogExportRange(day, day.AddDate(2, 0, 0))
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidLogExport), true)
+}
+
+func TestWriteActivityLogCSV(t *testing.T) {
+	activities := []Activity{{Kind: ActivityPieceAdded, UserId: "gone", ProjectId: "spring", ResourceIds: []string{"a", "b"}, Time: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}}
+	var buf bytes.Buffer
+	testutils.AssertNil(t, WriteActivityLog(&buf, LogExportCSV, NewActivityLogEntries(activities, nil)))
+	testutils.AssertEqual(t, buf.String(), "time,kind,user_id,user_name,user_email,project_id,resource_ids\n2026-03-01T00:00:00Z,piece_added,gone,,,spring,a;b\n")
+
+	_, err := ParseLogExportFormat("xml")
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidLogExport), true)
+}
+
+func TestGoogleStoreOrganizationActivity(t *testing.T) {
+	store := GoogleStore{FsClient: NewLocalFirestoreClient()}
+	ctx := context.Background()
+	for i, projectId := range []string{"spring", "", "autumn"} {
+		activity := NewActivity(projectId, ActivityDownload, "a", nil)
+		activity.Time = time.Date(2026, 3, 1+i, 0, 0, 0, 0, time.UTC)
+		testutils.AssertNil(t, store.RecordActivity(ctx, "org", activity))
+	}
+
+	activities, err := store.OrganizationActivity(ctx, "org", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(activities), 2)
+	testutils.AssertEqual(t, activities[0].ProjectId, "")
+}
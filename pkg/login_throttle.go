@@ -0,0 +1,110 @@
+package pkg
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+type LoginThrottling struct {
+	// Number of failed sign-ins for an email address, and from an IP address, before further attempts are refused
+	// until the lockout has passed. Zero disables the throttling
+	MaxFailures      int `yaml:"max_failures"`
+	MaxFailuresPerIp int `yaml:"max_failures_per_ip"`
+
+	// Delay after the first failure, doubled for each consecutive failure up to the lockout
+	BaseDelay time.Duration `yaml:"base_delay"`
+
+	// How long sign-ins are refused after too many failures. Failures older than the lockout are forgotten
+	Lockout time.Duration `yaml:"lockout"`
+}
+
+func DefaultLoginThrottling() LoginThrottling {
+	return LoginThrottling{
+		MaxFailures:      5,
+		MaxFailuresPerIp: 20,
+		BaseDelay:        time.Second,
+		Lockout:          15 * time.Minute,
+	}
+}
+
+type loginFailures struct {
+	count int
+	last  time.Time
+}
+
+// LoginThrottler counts failed password sign-ins per email address and per IP address. Each failure delays the
+// next attempt exponentially, and too many failures lock out further attempts. The counters are kept in memory
+// and are per instance
+type LoginThrottler struct {
+	Config LoginThrottling
+	Now    func() time.Time
+
+	mu     sync.Mutex
+	emails map[string]loginFailures
+	ips    map[string]loginFailures
+}
+
+func NewLoginThrottler(config *LoginThrottling) *LoginThrottler {
+	return &LoginThrottler{
+		Config: *config,
+		Now:    time.Now,
+		emails: make(map[string]loginFailures),
+		ips:    make(map[string]loginFailures),
+	}
+}
+
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// wait returns how long to wait before the next attempt is allowed
+func (t *LoginThrottler) wait(failures loginFailures, maxFailures int, now time.Time) time.Duration {
+	if failures.count == 0 || maxFailures <= 0 {
+		return 0
+	}
+	delay := t.Config.Lockout
+	if failures.count < maxFailures && failures.count < 32 {
+		delay = min(t.Config.BaseDelay<<(failures.count-1), t.Config.Lockout)
+	}
+	return max(failures.last.Add(delay).Sub(now), 0)
+}
+
+// RetryAfter returns how long to wait before a sign-in for the email from the IP address is allowed. Zero means
+// that the attempt is allowed
+func (t *LoginThrottler) RetryAfter(email, ip string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.Now()
+	return max(
+		t.wait(t.emails[normalizeLoginEmail(email)], t.Config.MaxFailures, now),
+		t.wait(t.ips[ip], t.Config.MaxFailuresPerIp, now),
+	)
+}
+
+// Failed records a failed sign-in. Counters that have not changed during the lockout are removed
+func (t *LoginThrottler) Failed(email, ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.Now()
+	for _, counters := range []map[string]loginFailures{t.emails, t.ips} {
+		for key, failures := range counters {
+			if now.Sub(failures.last) >= t.Config.Lockout {
+				delete(counters, key)
+			}
+		}
+	}
+
+	for key, counters := range map[string]map[string]loginFailures{normalizeLoginEmail(email): t.emails, ip: t.ips} {
+		failures := counters[key]
+		counters[key] = loginFailures{count: failures.count + 1, last: now}
+	}
+}
+
+// Succeeded resets the failures of the email address. Failures from the IP address are kept such that an attacker
+// can not reset the counter by signing in to an account of their own
+func (t *LoginThrottler) Succeeded(email string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.emails, normalizeLoginEmail(email))
+}
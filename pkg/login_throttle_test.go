@@ -0,0 +1,52 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestLoginThrottler(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	throttler := NewLoginThrottler(&LoginThrottling{MaxFailures: 3, MaxFailuresPerIp: 5, BaseDelay: time.Second, Lockout: time.Minute})
+	throttler.Now = func() time.Time { return now }
+
+	testutils.AssertEqual(t, throttler.RetryAfter("john@example.com", "10.0.0.1"), time.Duration(0))
+
+	// The delay doubles for each failure, also for other spellings of the email address
+	throttler.Failed("john@example.com", "10.0.0.1")
+	testutils.AssertEqual(t, throttler.RetryAfter(" John@Example.com", "10.0.0.2"), time.Second)
+	throttler.Failed("john@example.com", "10.0.0.1")
+	testutils.AssertEqual(t, throttler.RetryAfter("john@example.com", "10.0.0.2"), 2*time.Second)
+
+	now = now.Add(2 * time.Second)
+	testutils.AssertEqual(t, throttler.RetryAfter("john@example.com", "10.0.0.2"), time.Duration(0))
+
+	// Too many failures lock out the email address
+	throttler.Failed("john@example.com", "10.0.0.1")
+	testutils.AssertEqual(t, throttler.RetryAfter("john@example.com", "10.0.0.2"), time.Minute)
+	testutils.AssertEqual(t, throttler.RetryAfter("susan@example.com", "10.0.0.2"), time.Duration(0))
+
+	// Failures from the same IP address are counted across email addresses
+	now = now.Add(time.Hour)
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+		throttler.Failed(email, "10.0.0.3")
+	}
+	testutils.AssertEqual(t, throttler.RetryAfter("susan@example.com", "10.0.0.3"), time.Minute)
+	testutils.AssertEqual(t, throttler.RetryAfter("john@example.com", "10.0.0.1"), time.Duration(0))
+
+	// Old counters are removed and a successful sign-in resets the email address
+	testutils.AssertEqual(t, len(throttler.emails), 5)
+	throttler.Succeeded("A@example.com")
+	testutils.AssertEqual(t, len(throttler.emails), 4)
+	testutils.AssertEqual(t, throttler.RetryAfter("susan@example.com", "10.0.0.4"), time.Duration(0))
+}
+
+func TestLoginThrottlerDisabled(t *testing.T) {
+	throttler := NewLoginThrottler(&LoginThrottling{})
+	for range 10 {
+		throttler.Failed("john@example.com", "10.0.0.1")
+	}
+	testutils.AssertEqual(t, throttler.RetryAfter("john@example.com", "10.0.0.1"), time.Duration(0))
+}
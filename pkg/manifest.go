@@ -0,0 +1,124 @@
+package pkg
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// ManifestEntry describes one file of a resource such that sync clients can detect changes without
+// downloading the file
+type ManifestEntry struct {
+	Name         string    `json:"name"`
+	Size         int64     `json:"size"`
+	MD5          string    `json:"md5"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+type ResourceManifest struct {
+	ResourceId string          `json:"resource_id"`
+	Files      []ManifestEntry `json:"files"`
+}
+
+type ResourceManifestGetter interface {
+	ResourceManifest(ctx context.Context, orgId string, resourceId string) (*ResourceManifest, error)
+}
+
+func newManifestEntry(name string, content []byte, modified time.Time) ManifestEntry {
+	hash := md5.Sum(content)
+	return ManifestEntry{Name: name, Size: int64(len(content)), MD5: hex.EncodeToString(hash[:]), LastModified: modified}
+}
+
+// visibleMeta returns an error if the metadata could not be fetched or the resource is deleted
+func visibleMeta(meta *MetaData, err error, resourceId string) error {
+	if err != nil {
+		return err
+	}
+	if meta.Deleted {
+		return errors.Join(ErrResourceMetadataNotFound, fmt.Errorf("resource id: %s", resourceId))
+	}
+	return nil
+}
+
+func sortManifest(manifest *ResourceManifest) *ResourceManifest {
+	slices.SortFunc(manifest.Files, func(a, b ManifestEntry) int { return strings.Compare(a.Name, b.Name) })
+	return manifest
+}
+
+// ResourceManifest lists the files of the resource. The MD5 hash is taken from the bucket when available,
+// otherwise the file is read to compute it
+func (g *GoogleStore) ResourceManifest(ctx context.Context, orgId, resourceId string) (*ResourceManifest, error) {
+	meta, err := g.MetaById(ctx, orgId, resourceId)
+	if err := visibleMeta(meta, err, resourceId); err != nil {
+		return nil, err
+	}
+	return g.listManifest(ctx, orgId, resourceId)
+}
+
+func (g *GoogleStore) listManifest(ctx context.Context, orgId, resourceId string) (*ResourceManifest, error) {
+	manifest := ResourceManifest{ResourceId: resourceId, Files: []ManifestEntry{}}
+	objects := g.BucketClient.GetObjects(ctx, g.Config.Bucket, &storage.Query{Prefix: path.Join(orgId, resourceId) + "/"})
+	for {
+		attrs, err := objects.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, classifyStoreErr(err, ErrResourceNotFound)
+		}
+
+		entry := ManifestEntry{
+			Name:         path.Base(attrs.Name),
+			Size:         attrs.Size,
+			MD5:          hex.EncodeToString(attrs.MD5),
+			LastModified: attrs.Updated,
+		}
+		if len(attrs.MD5) != md5.Size {
+			if entry.MD5, err = g.objectMD5(ctx, attrs.Name); err != nil {
+				return nil, err
+			}
+		}
+		manifest.Files = append(manifest.Files, entry)
+	}
+	return sortManifest(&manifest), nil
+}
+
+func (g *GoogleStore) objectMD5(ctx context.Context, name string) (string, error) {
+	content, err := g.BucketClient.GetObject(ctx, g.Config.Bucket, name)
+	if err != nil {
+		return "", classifyStoreErr(err, ErrResourceNotFound)
+	}
+	defer content.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (s *InMemoryStore) ResourceManifest(ctx context.Context, resourceId string) (*ResourceManifest, error) {
+	meta, err := s.MetaById(ctx, resourceId)
+	if err := visibleMeta(meta, err, resourceId); err != nil {
+		return nil, err
+	}
+
+	manifest := ResourceManifest{ResourceId: resourceId, Files: []ManifestEntry{}}
+	prefix := resourceId + "/"
+	for name, content := range s.Data {
+		if strings.HasPrefix(name, prefix) {
+			manifest.Files = append(manifest.Files, newManifestEntry(path.Base(name), content, s.Modified[name]))
+		}
+	}
+	return sortManifest(&manifest), nil
+}
@@ -0,0 +1,89 @@
+package pkg
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func manifestParts(yield func(string, []byte) bool) {
+	for _, name := range []string{"Trumpet.pdf", "Horn.pdf"} {
+		if !yield(name, []byte(name)) {
+			return
+		}
+	}
+}
+
+// hashingBucketClient reports MD5 hashes when listing and fails reads, such that the manifest must be built
+// from the attributes alone
+type hashingBucketClient struct {
+	*FileBucketClient
+}
+
+func (h *hashingBucketClient) GetObjects(ctx context.Context, bucket string, query *storage.Query) ObjectLister {
+	lister := h.FileBucketClient.GetObjects(ctx, bucket, query).(*fileObjectLister)
+	for _, attrs := range lister.objects {
+		hash := md5.Sum([]byte("attrs"))
+		attrs.MD5 = hash[:]
+	}
+	return lister
+}
+
+func (h *hashingBucketClient) GetObject(ctx context.Context, bucket, objName string) (io.ReadCloser, error) {
+	return nil, errors.New("manifest should not read objects")
+}
+
+func TestGoogleStoreResourceManifest(t *testing.T) {
+	dir := t.TempDir()
+	store := GoogleStore{
+		FsClient:     NewLocalFirestoreClient(),
+		BucketClient: &FileBucketClient{Directory: dir},
+		Config:       &GoogleConfig{Bucket: "scores"},
+	}
+	ctx := context.Background()
+	meta := MetaData{Title: "Polka"}
+	other := MetaData{Title: "Polka", Composer: "Strauss"}
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, manifestParts))
+	testutils.AssertNil(t, store.Submit(ctx, "org", &other, manifestParts))
+
+	manifest, err := store.ResourceManifest(ctx, "org", meta.ResourceId())
+	testutils.AssertNil(t, err)
+
+	// Resources sharing a prefix are not included
+	testutils.AssertEqual(t, len(manifest.Files), 2)
+	testutils.AssertEqual(t, manifest.Files[0].Name, "Horn.pdf")
+	testutils.AssertEqual(t, manifest.Files[0].Size, int64(len("Horn.pdf")))
+	testutils.AssertEqual(t, manifest.Files[0].MD5, newManifestEntry("", []byte("Horn.pdf"), time.Time{}).MD5)
+	testutils.AssertEqual(t, manifest.Files[0].LastModified.IsZero(), false)
+
+	store.BucketClient = &hashingBucketClient{FileBucketClient: &FileBucketClient{Directory: dir}}
+	manifest, err = store.ResourceManifest(ctx, "org", meta.ResourceId())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, manifest.Files[1].MD5, newManifestEntry("", []byte("attrs"), time.Time{}).MD5)
+
+	testutils.AssertNil(t, store.DeleteResource(ctx, "org", meta.ResourceId()))
+	_, err = store.ResourceManifest(ctx, "org", meta.ResourceId())
+	testutils.AssertEqual(t, errors.Is(err, ErrResourceMetadataNotFound), true)
+}
+
+func TestInMemoryResourceManifest(t *testing.T) {
+	store := NewInMemoryStore()
+	ctx := context.Background()
+	meta := MetaData{Title: "Polka"}
+	testutils.AssertNil(t, store.Submit(ctx, &meta, manifestParts))
+
+	manifest, err := store.ResourceManifest(ctx, meta.ResourceId())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(manifest.Files), 2)
+	testutils.AssertEqual(t, manifest.Files[1].Name, "Trumpet.pdf")
+	testutils.AssertEqual(t, manifest.Files[1].LastModified.IsZero(), false)
+
+	_, err = store.ResourceManifest(ctx, "missing")
+	testutils.AssertEqual(t, errors.Is(err, ErrResourceMetadataNotFound), true)
+}
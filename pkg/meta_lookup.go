@@ -0,0 +1,42 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+)
+
+// Number of concurrent metadata requests per batch
+const metaFetchWorkers = 8
+
+// FetchMetaByIds fetches the metadata of ids using at most workers concurrent requests. Resources that are not
+// started before the context is done get the error of the context
+func FetchMetaByIds(ctx context.Context, getter MetaByIdGetter, orgId string, ids []string, workers int) []MetaLookup {
+	results := make([]MetaLookup, len(ids))
+	slots := make(chan struct{}, max(workers, 1))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		results[i].Id = id
+		if err := ctx.Err(); err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			results[i].Meta, results[i].Err = getter.MetaById(ctx, orgId, id)
+		}()
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,88 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+// slowMetaGetter records the maximum number of concurrent requests
+type slowMetaGetter struct {
+	delay time.Duration
+
+	mu            sync.Mutex
+	active        int
+	maxActive     int
+	numRequests   int
+	missingSuffix string
+}
+
+func (s *slowMetaGetter) MetaById(ctx context.Context, orgId, id string) (*MetaData, error) {
+	s.mu.Lock()
+	s.active++
+	s.numRequests++
+	s.maxActive = max(s.maxActive, s.active)
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.active--
+		s.mu.Unlock()
+	}()
+
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if s.missingSuffix != "" && strings.HasSuffix(id, s.missingSuffix) {
+		return nil, ErrResourceMetadataNotFound
+	}
+	return &MetaData{Title: id}, nil
+}
+
+func TestFetchMetaByIdsPreservesOrder(t *testing.T) {
+	getter := slowMetaGetter{delay: time.Millisecond, missingSuffix: "3"}
+	ids := make([]string, 25)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("piece%d", i)
+	}
+
+	results := FetchMetaByIds(context.Background(), &getter, "org", ids, 4)
+	testutils.AssertEqual(t, len(results), len(ids))
+	for i, result := range results {
+		testutils.AssertEqual(t, result.Id, ids[i])
+		if i%10 == 3 {
+			testutils.AssertEqual(t, errors.Is(result.Err, ErrResourceMetadataNotFound), true)
+			continue
+		}
+		testutils.AssertNil(t, result.Err)
+		testutils.AssertEqual(t, result.Meta.Title, ids[i])
+	}
+
+	if getter.maxActive > 4 || getter.maxActive < 2 {
+		t.Fatalf("Wanted between 2 and 4 concurrent requests, got %d", getter.maxActive)
+	}
+}
+
+func TestFetchMetaByIdsStopsWhenContextIsDone(t *testing.T) {
+	getter := slowMetaGetter{delay: time.Second}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	results := FetchMetaByIds(ctx, &getter, "org", []string{"a", "b", "c", "d"}, 2)
+	for _, result := range results {
+		testutils.AssertEqual(t, errors.Is(result.Err, context.DeadlineExceeded), true)
+	}
+	testutils.AssertEqual(t, getter.numRequests, 2)
+}
+
+func TestFetchMetaByIdsEmpty(t *testing.T) {
+	testutils.AssertEqual(t, len(FetchMetaByIds(context.Background(), &slowMetaGetter{}, "org", nil, 0)), 0)
+}
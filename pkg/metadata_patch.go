@@ -0,0 +1,143 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const MaxMetaDataPatches = 500
+
+// identityFields make up the resource id. Changing them would move the parts of the resource, so they can not
+// be edited in bulk
+var identityFields = []string{"title", "composer", "arranger"}
+
+// metaDataSetters are the fields that can be edited in bulk
+var metaDataSetters = map[string]func(m *MetaData, value string) error{
+	"genre":           func(m *MetaData, v string) error { m.Genre = v; return nil },
+	"year":            func(m *MetaData, v string) error { m.Year = v; return nil },
+	"instrumentation": func(m *MetaData, v string) error { m.Instrumentation = v; return nil },
+	"publisher":       func(m *MetaData, v string) error { m.Publisher = v; return nil },
+	"ismn":            func(m *MetaData, v string) error { m.Ismn = v; return nil },
+	"tags":            func(m *MetaData, v string) error { m.Tags = v; return nil },
+	"notes":           func(m *MetaData, v string) error { m.Notes = v; return nil },
+	"duration": func(m *MetaData, v string) error {
+		if v == "" {
+			m.Duration = 0
+			return nil
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		m.Duration = Duration(d)
+		return nil
+	},
+}
+
+func EditableMetaDataFields() []string {
+	return []string{"genre", "year", "instrumentation", "duration", "publisher", "ismn", "tags", "notes"}
+}
+
+// UnsupportedPatchFields returns the sorted names of the fields in patches that can not be edited in bulk
+func UnsupportedPatchFields(patches []MetaDataPatch) []string {
+	var unsupported []string
+	for _, patch := range patches {
+		for field := range patch.Fields {
+			if _, ok := metaDataSetters[field]; !ok && !slices.Contains(unsupported, field) {
+				unsupported = append(unsupported, field)
+			}
+		}
+	}
+	slices.Sort(unsupported)
+	return unsupported
+}
+
+// IsIdentityField reports whether field is part of the resource id
+func IsIdentityField(field string) bool {
+	return slices.Contains(identityFields, field)
+}
+
+type MetaDataPatch struct {
+	ResourceId string            `json:"resourceId"`
+	Fields     map[string]string `json:"fields"`
+}
+
+// Apply returns a copy of m with the fields of the patch set. The original is left untouched if
+// any of the fields are invalid
+func (p *MetaDataPatch) Apply(m MetaData) (MetaData, error) {
+	if len(p.Fields) == 0 {
+		return m, errors.Join(ErrInvalidMetaDataPatch, errors.New("no fields to update"))
+	}
+
+	// Sort the fields to get deterministic error messages
+	fields := make([]string, 0, len(p.Fields))
+	for field := range p.Fields {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	for _, field := range fields {
+		setter, ok := metaDataSetters[field]
+		if !ok {
+			return m, errors.Join(ErrInvalidMetaDataPatch, fmt.Errorf("field %s can not be edited", field))
+		}
+		if err := setter(&m, strings.TrimSpace(p.Fields[field])); err != nil {
+			return m, errors.Join(ErrInvalidMetaDataPatch, fmt.Errorf("invalid value for %s: %w", field, err))
+		}
+	}
+	return m, nil
+}
+
+type MetaDataPatchResult struct {
+	ResourceId string `json:"resourceId"`
+	Ok         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+}
+
+type MetaDataUpdater interface {
+	UpdateMetaData(ctx context.Context, orgId string, meta *MetaData) error
+}
+
+type MetaDataPatcher interface {
+	MetaByIdGetter
+	MetaDataUpdater
+}
+
+// patchMetaData applies the patch in a transaction, such that updates made to the metadata while the patch is
+// applied, for example by RecordAccess, are not overwritten
+func patchMetaData(ctx context.Context, store Transactor, orgId string, patch *MetaDataPatch) error {
+	return store.RunTransaction(ctx, func(ctx context.Context, tx TxStore) error {
+		meta, err := tx.MetaById(ctx, orgId, patch.ResourceId)
+		if err != nil {
+			return err
+		}
+		if meta.Deleted {
+			return errors.Join(ErrResourceMetadataNotFound, fmt.Errorf("%s is deleted", patch.ResourceId))
+		}
+
+		patched, err := patch.Apply(*meta)
+		if err != nil {
+			return err
+		}
+		return tx.UpdateMetaData(ctx, orgId, &patched)
+	})
+}
+
+// BulkPatchMetaData applies each patch separately in its own transaction. A failing patch does not affect the
+// others and the outcome is reported in the result with the same index as the patch
+func BulkPatchMetaData(ctx context.Context, store Transactor, orgId string, patches []MetaDataPatch) []MetaDataPatchResult {
+	results := make([]MetaDataPatchResult, len(patches))
+	for i := range patches {
+		results[i].ResourceId = patches[i].ResourceId
+		if err := patchMetaData(ctx, store, orgId, &patches[i]); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Ok = true
+	}
+	return results
+}
@@ -0,0 +1,142 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestMetaDataPatchApply(t *testing.T) {
+	original := MetaData{Title: "Title", Genre: "Pop", Duration: Duration(time.Minute)}
+
+	t.Run("all editable fields", func(t *testing.T) {
+		patch := MetaDataPatch{Fields: map[string]string{}}
+		for _, field := range EditableMetaDataFields() {
+			patch.Fields[field] = "2m"
+		}
+		patched, err := patch.Apply(original)
+		testutils.AssertNil(t, err)
+		want := MetaData{
+			Title:           "Title",
+			Genre:           "2m",
+			Year:            "2m",
+			Instrumentation: "2m",
+			Duration:        Duration(2 * time.Minute),
+			Publisher:       "2m",
+			Ismn:            "2m",
+			Tags:            "2m",
+			Notes:           "2m",
+		}
+		testutils.AssertEqual(t, patched, want)
+		testutils.AssertEqual(t, original.Genre, "Pop")
+	})
+
+	t.Run("empty duration clears", func(t *testing.T) {
+		patch := MetaDataPatch{Fields: map[string]string{"duration": ""}}
+		patched, err := patch.Apply(original)
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, patched.Duration, Duration(0))
+	})
+
+	for _, test := range []struct {
+		desc   string
+		fields map[string]string
+	}{
+		{"no fields", map[string]string{}},
+		{"identity field", map[string]string{"arranger": "Someone"}},
+		{"unknown field", map[string]string{"color": "red"}},
+		{"invalid duration", map[string]string{"genre": "Rock", "duration": "three minutes"}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			patch := MetaDataPatch{Fields: test.fields}
+			patched, err := patch.Apply(original)
+			testutils.AssertEqual(t, errors.Is(err, ErrInvalidMetaDataPatch), true)
+			testutils.AssertEqual(t, patched, original)
+		})
+	}
+}
+
+func TestBulkPatchMetaData(t *testing.T) {
+	ctx := context.Background()
+	store := NewMultiOrgInMemoryStore()
+	inMem := NewInMemoryStore()
+	inMem.Metadata = []MetaData{{Title: "A", Genre: "Pop"}, {Title: "B", Protected: true}, {Title: "C", Deleted: true}}
+	store.Data["org"] = inMem
+
+	patches := []MetaDataPatch{
+		{ResourceId: "a", Fields: map[string]string{"genre": "Rock"}},
+		{ResourceId: "b", Fields: map[string]string{"tags": "christmas"}},
+		{ResourceId: "c", Fields: map[string]string{"genre": "Rock"}},
+		{ResourceId: "missing", Fields: map[string]string{"genre": "Rock"}},
+		{ResourceId: "a", Fields: map[string]string{"title": "Other"}},
+	}
+	results := BulkPatchMetaData(ctx, store, "org", patches)
+	testutils.AssertEqual(t, len(results), len(patches))
+
+	wantOk := []bool{true, true, false, false, false}
+	for i, result := range results {
+		testutils.AssertEqual(t, result.ResourceId, patches[i].ResourceId)
+		testutils.AssertEqual(t, result.Ok, wantOk[i])
+		testutils.AssertEqual(t, result.Error == "", wantOk[i])
+	}
+
+	meta, err := store.MetaById(ctx, "org", "a")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, meta.Genre, "Rock")
+	testutils.AssertEqual(t, meta.Title, "A")
+
+	meta, err = store.MetaById(ctx, "org", "b")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, meta.Tags, "christmas")
+	testutils.AssertEqual(t, meta.Protected, true)
+}
+
+func TestUnsupportedPatchFields(t *testing.T) {
+	patches := []MetaDataPatch{
+		{Fields: map[string]string{"genre": "Rock", "title": "Other"}},
+		{Fields: map[string]string{"arranger": "Someone", "title": "Third"}},
+	}
+	testutils.AssertEqual(t, strings.Join(UnsupportedPatchFields(patches), ","), "arranger,title")
+	testutils.AssertEqual(t, len(UnsupportedPatchFields(patches[:0])), 0)
+}
+
+type countingTransactor struct {
+	*MultiOrgInMemoryStore
+	numTransactions int
+}
+
+func (c *countingTransactor) RunTransaction(ctx context.Context, fn func(ctx context.Context, tx TxStore) error) error {
+	c.numTransactions++
+	return c.MultiOrgInMemoryStore.RunTransaction(ctx, fn)
+}
+
+func TestBulkPatchMetaDataRunsEachPatchInTransaction(t *testing.T) {
+	ctx := context.Background()
+	store := countingTransactor{MultiOrgInMemoryStore: NewMultiOrgInMemoryStore()}
+	inMem := NewInMemoryStore()
+	inMem.Metadata = []MetaData{{Title: "A"}, {Title: "B"}}
+	store.Data["org"] = inMem
+
+	patches := []MetaDataPatch{
+		{ResourceId: "a", Fields: map[string]string{"genre": "Rock"}},
+		{ResourceId: "b", Fields: map[string]string{"genre": "Pop"}},
+	}
+	results := BulkPatchMetaData(ctx, &store, "org", patches)
+	testutils.AssertEqual(t, results[0].Ok && results[1].Ok, true)
+	testutils.AssertEqual(t, store.numTransactions, 2)
+}
+
+func TestUpdateMetaDataUnknownResource(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	store.Data["org"] = NewInMemoryStore()
+
+	err := store.UpdateMetaData(context.Background(), "org", &MetaData{Title: "A"})
+	testutils.AssertEqual(t, errors.Is(err, ErrResourceMetadataNotFound), true)
+
+	err = store.UpdateMetaData(context.Background(), "unknown-org", &MetaData{Title: "A"})
+	testutils.AssertEqual(t, errors.Is(err, ErrOrganizationNotFound), true)
+}
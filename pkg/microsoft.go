@@ -0,0 +1,75 @@
+package pkg
+
+import (
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	MicrosoftUserInfoURL = "https://graph.microsoft.com/v1.0/me"
+
+	// MicrosoftIssuer namespaces the ids of Microsoft Graph, which are unique across tenants
+	MicrosoftIssuer = "https://login.microsoftonline.com"
+)
+
+// MicrosoftUserInfo is the profile returned by Microsoft Graph. Work and school accounts without a mailbox have
+// no mail, and the user principal name is then used as the email
+type MicrosoftUserInfo struct {
+	Id                string `json:"id"`
+	DisplayName       string `json:"displayName"`
+	Mail              string `json:"mail"`
+	UserPrincipalName string `json:"userPrincipalName"`
+}
+
+// UserInfo returns the user of the profile. The email is only verified when the claims of the id token verify
+// it, since the mail and user principal name of a profile can be set by the tenant to any address. claims may
+// be nil when there is no id token
+func (m *MicrosoftUserInfo) UserInfo(claims *MicrosoftIdTokenClaims) UserInfo {
+	email := m.Mail
+	if email == "" && strings.Contains(m.UserPrincipalName, "@") {
+		email = m.UserPrincipalName
+	}
+	return UserInfo{
+		Id:            ExternalUserId(MicrosoftIssuer, m.Id),
+		Email:         strings.ToLower(email),
+		VerifiedEmail: email != "" && claims != nil && claims.Verifies(email),
+		Name:          m.DisplayName,
+	}
+}
+
+// MicrosoftIdTokenClaims are the claims of the id token telling whether the email is verified. Both are
+// optional claims that must be added to the app registration: xms_edov is set when the domain of the email is
+// verified by the tenant, and email_verified is set for personal accounts
+type MicrosoftIdTokenClaims struct {
+	Email                  string `json:"email"`
+	EmailVerified          bool   `json:"email_verified"`
+	EmailDomainOwnerVerify bool   `json:"xms_edov"`
+	jwt.RegisteredClaims
+}
+
+// Verifies reports whether the claims verify the email
+func (m *MicrosoftIdTokenClaims) Verifies(email string) bool {
+	return (m.EmailVerified || m.EmailDomainOwnerVerify) && m.Email != "" && strings.EqualFold(m.Email, email)
+}
+
+// ParseMicrosoftIdToken reads the claims of an id token and checks the audience and expiry. The issuer depends on
+// the tenant and is not checked. The signature is not checked, since the token is received directly from the
+// token endpoint of Microsoft over TLS
+func ParseMicrosoftIdToken(idToken, clientId string, now time.Time) (*MicrosoftIdTokenClaims, error) {
+	var claims MicrosoftIdTokenClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(idToken, &claims); err != nil {
+		return nil, err
+	}
+
+	validator := jwt.NewValidator(
+		jwt.WithAudience(clientId),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+	)
+	if err := validator.Validate(claims); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
@@ -0,0 +1,80 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestMicrosoftUserInfo(t *testing.T) {
+	profile := MicrosoftUserInfo{Id: "1", DisplayName: "Kari", Mail: "Kari@Example.com", UserPrincipalName: "kari_example.com#EXT#@tenant.onmicrosoft.com"}
+	user := profile.UserInfo(&MicrosoftIdTokenClaims{Email: "kari@example.com", EmailDomainOwnerVerify: true})
+	testutils.AssertEqual(t, user.Id, ExternalUserId(MicrosoftIssuer, "1"))
+	testutils.AssertEqual(t, user.Email, "kari@example.com")
+	testutils.AssertEqual(t, user.VerifiedEmail, true)
+
+	// Principal names are not always email addresses
+	profile = MicrosoftUserInfo{Id: "2", UserPrincipalName: "kari"}
+	user = profile.UserInfo(nil)
+	testutils.AssertEqual(t, user.Email, "")
+	testutils.AssertEqual(t, user.VerifiedEmail, false)
+}
+
+func TestMicrosoftUserInfoVerifiedEmail(t *testing.T) {
+	profile := MicrosoftUserInfo{Id: "1", Mail: "kari@example.com"}
+	for _, test := range []struct {
+		desc     string
+		claims   *MicrosoftIdTokenClaims
+		verified bool
+	}{
+		{"no id token", nil, false},
+		{"no verification claims", &MicrosoftIdTokenClaims{Email: "kari@example.com"}, false},
+		{"domain owner verified", &MicrosoftIdTokenClaims{Email: "Kari@Example.com", EmailDomainOwnerVerify: true}, true},
+		{"email verified", &MicrosoftIdTokenClaims{Email: "kari@example.com", EmailVerified: true}, true},
+		{"other email verified", &MicrosoftIdTokenClaims{Email: "ola@example.com", EmailVerified: true}, false},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			testutils.AssertEqual(t, profile.UserInfo(test.claims).VerifiedEmail, test.verified)
+		})
+	}
+}
+
+func TestParseMicrosoftIdToken(t *testing.T) {
+	now := time.Now()
+	sign := func(claims MicrosoftIdTokenClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("key"))
+		testutils.AssertNil(t, err)
+		return token
+	}
+	valid := MicrosoftIdTokenClaims{
+		Email:         "kari@example.com",
+		EmailVerified: true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{"client-id"},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+
+	claims, err := ParseMicrosoftIdToken(sign(valid), "client-id", now)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, claims.Verifies("kari@example.com"), true)
+
+	_, err = ParseMicrosoftIdToken(sign(valid), "other-client", now)
+	testutils.AssertEqual(t, err != nil, true)
+
+	_, err = ParseMicrosoftIdToken(sign(valid), "client-id", now.Add(2*time.Hour))
+	testutils.AssertEqual(t, err != nil, true)
+
+	_, err = ParseMicrosoftIdToken("test-id-token", "client-id", now)
+	testutils.AssertEqual(t, err != nil, true)
+}
+
+func TestMicrosoftOAuthConfigTenant(t *testing.T) {
+	config := NewDefaultConfig()
+	testutils.AssertContains(t, config.MicrosoftOAuthConfig().Endpoint.AuthURL, "/common/")
+
+	config.MicrosoftAuthTenant = "contoso.onmicrosoft.com"
+	testutils.AssertContains(t, config.MicrosoftOAuthConfig().Endpoint.TokenURL, "/contoso.onmicrosoft.com/")
+}
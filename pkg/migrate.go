@@ -0,0 +1,115 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+type MigrateStats struct {
+	NumOrganizations int
+	NumUsers         int
+	NumResources     int
+	NumProjects      int
+}
+
+// StoreMigrator copies organizations, subscriptions, users, scores and projects from Source into Target
+// without modifications. Users that are not a member of any organization are not copied. When DryRun is set
+// everything is read from Source, but nothing is written to Target
+type StoreMigrator struct {
+	Source   Store
+	Target   Store
+	DryRun   bool
+	Progress io.Writer
+}
+
+func (s *StoreMigrator) Migrate(ctx context.Context) (MigrateStats, error) {
+	var stats MigrateStats
+	orgs, err := s.Source.ListOrganizations(ctx)
+	if err != nil {
+		return stats, err
+	}
+	slices.SortFunc(orgs, func(x, y Organization) int { return strings.Compare(x.Id, y.Id) })
+
+	users := []UserInfo{}
+	seenUsers := make(map[string]int)
+	for i, org := range orgs {
+		s.progress("[%d/%d] Organization %s (%s)", i+1, len(orgs), org.Id, org.Name)
+		if orgErr := s.migrateOrganization(ctx, &org, &stats); orgErr != nil {
+			err = errors.Join(err, fmt.Errorf("organization %s: %w", org.Id, orgErr))
+		}
+
+		members, usersErr := s.Source.GetUsersInOrg(ctx, org.Id)
+		if usersErr != nil {
+			err = errors.Join(err, fmt.Errorf("users of organization %s: %w", org.Id, usersErr))
+		}
+		users = mergeMembers(users, seenUsers, members)
+	}
+
+	for _, user := range users {
+		if !s.DryRun {
+			if userErr := s.Target.RegisterUser(ctx, &user); userErr != nil {
+				err = errors.Join(err, fmt.Errorf("user %s: %w", user.Id, userErr))
+				continue
+			}
+		}
+		stats.NumUsers++
+	}
+	s.progress("Users: %d", stats.NumUsers)
+	return stats, err
+}
+
+func (s *StoreMigrator) migrateOrganization(ctx context.Context, org *Organization, stats *MigrateStats) error {
+	if !s.DryRun {
+		if err := s.Target.RegisterOrganization(ctx, org); err != nil {
+			return err
+		}
+	}
+	stats.NumOrganizations++
+
+	var err error
+	if org.StripeId != "" {
+		subscription, subErr := s.Source.GetSubscription(ctx, org.Id)
+		if subErr == nil && !s.DryRun {
+			err = errors.Join(err, s.Target.StoreSubscription(ctx, org.StripeId, subscription))
+		} else if subErr != nil && !errors.Is(subErr, ErrSubscriptionNotFound) {
+			err = errors.Join(err, subErr)
+		}
+	}
+
+	metas, metaErr := s.Source.MetaByPattern(ctx, org.Id, &MetaData{})
+	err = errors.Join(err, metaErr)
+	for _, meta := range metas {
+		if !s.DryRun {
+			content := s.Source.Resource(ctx, org.Id, meta.ResourceId())
+			if submitErr := s.Target.Submit(ctx, org.Id, &meta, content); submitErr != nil {
+				err = errors.Join(err, fmt.Errorf("resource %s: %w", meta.ResourceId(), submitErr))
+				continue
+			}
+		}
+		stats.NumResources++
+	}
+
+	projects, projectErr := s.Source.ProjectsByName(ctx, org.Id, "")
+	err = errors.Join(err, projectErr)
+	for _, project := range projects {
+		if !s.DryRun {
+			if submitErr := s.Target.SubmitProject(ctx, org.Id, &project); submitErr != nil {
+				err = errors.Join(err, fmt.Errorf("project %s: %w", project.Id(), submitErr))
+				continue
+			}
+		}
+		stats.NumProjects++
+	}
+	s.progress("  Resources: %d Projects: %d", len(metas), len(projects))
+	return err
+}
+
+func (s *StoreMigrator) progress(format string, args ...any) {
+	if s.Progress != nil {
+		fmt.Fprintf(s.Progress, format+"\n", args...)
+	}
+}
@@ -0,0 +1,68 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestStoreMigrator(t *testing.T) {
+	source := NewDemoStore()
+	source.Users[0].Password = "hashed-password"
+	source.Organizations[0].StripeId = "cus_123"
+	target := NewMultiOrgInMemoryStore()
+
+	var progress bytes.Buffer
+	migrator := StoreMigrator{Source: source, Target: target, Progress: &progress}
+	stats, err := migrator.Migrate(context.Background())
+	testutils.AssertNil(t, err)
+
+	testutils.AssertEqual(t, stats.NumOrganizations, 2)
+	testutils.AssertEqual(t, stats.NumUsers, len(source.Users))
+	testutils.AssertEqual(t, stats.NumResources, 4)
+	testutils.AssertEqual(t, stats.NumProjects, 2)
+	testutils.AssertContains(t, progress.String(), "[1/2] Organization", "[2/2] Organization", "Users:")
+
+	// Data is copied as is
+	user, err := target.GetUserInfo(context.Background(), source.Users[0].Id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, user.Password, "hashed-password")
+	testutils.AssertEqual(t, user.Email, source.Users[0].Email)
+
+	org, err := target.GetOrganization(context.Background(), source.Organizations[0].Id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, org.Name, source.Organizations[0].Name)
+
+	_, err = target.GetSubscription(context.Background(), org.Id)
+	testutils.AssertNil(t, err)
+
+	for orgId, store := range source.Data {
+		testutils.AssertEqual(t, len(target.Data[orgId].Data), len(store.Data))
+	}
+}
+
+func TestStoreMigratorDryRun(t *testing.T) {
+	source := NewDemoStore()
+	target := NewMultiOrgInMemoryStore()
+	migrator := StoreMigrator{Source: source, Target: target, DryRun: true}
+	stats, err := migrator.Migrate(context.Background())
+	testutils.AssertNil(t, err)
+
+	testutils.AssertEqual(t, stats.NumOrganizations, 2)
+	testutils.AssertEqual(t, stats.NumResources, 4)
+	testutils.AssertEqual(t, len(target.Organizations), 0)
+	testutils.AssertEqual(t, len(target.Users), 0)
+}
+
+func TestStoreMigratorListError(t *testing.T) {
+	source := anonymizeSourceWithLister{
+		MultiOrgInMemoryStore: NewDemoStore(),
+		err:                   errors.New("could not list"),
+	}
+	migrator := StoreMigrator{Source: &source, Target: NewMultiOrgInMemoryStore()}
+	_, err := migrator.Migrate(context.Background())
+	testutils.AssertEqual(t, err != nil, true)
+}
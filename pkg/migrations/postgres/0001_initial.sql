@@ -0,0 +1,81 @@
+CREATE TABLE organizations (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL DEFAULT '',
+    deleted    BOOLEAN NOT NULL DEFAULT FALSE,
+    num_scores INTEGER NOT NULL DEFAULT 0,
+    stripe_id  TEXT NOT NULL DEFAULT '',
+    branding   JSONB NOT NULL DEFAULT '{}',
+    domain     TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX organizations_stripe_id ON organizations (stripe_id);
+CREATE INDEX organizations_domain ON organizations (domain);
+
+CREATE TABLE subscriptions (
+    org_id TEXT PRIMARY KEY REFERENCES organizations (id),
+    data   JSONB NOT NULL
+);
+
+CREATE TABLE users (
+    id             TEXT PRIMARY KEY,
+    email          TEXT NOT NULL DEFAULT '',
+    verified_email BOOLEAN NOT NULL DEFAULT FALSE,
+    name           TEXT NOT NULL DEFAULT '',
+    password       TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX users_email ON users (lower(email));
+
+-- Roles may be registered before the user, hence no foreign key to users
+CREATE TABLE memberships (
+    user_id TEXT NOT NULL,
+    org_id  TEXT NOT NULL,
+    role    INTEGER NOT NULL DEFAULT 0,
+    groups  TEXT[] NOT NULL DEFAULT '{}',
+    deleted BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (user_id, org_id)
+);
+
+CREATE INDEX memberships_org_id ON memberships (org_id);
+
+-- The metadata is kept as JSON. Columns used for searching are generated from it
+CREATE TABLE metadata (
+    org_id      TEXT NOT NULL,
+    resource_id TEXT NOT NULL,
+    data        JSONB NOT NULL,
+    title       TEXT GENERATED ALWAYS AS (coalesce(data ->> 'title', '')) STORED,
+    composer    TEXT GENERATED ALWAYS AS (coalesce(data ->> 'composer', '')) STORED,
+    arranger    TEXT GENERATED ALWAYS AS (coalesce(data ->> 'arranger', '')) STORED,
+    PRIMARY KEY (org_id, resource_id)
+);
+
+CREATE TABLE projects (
+    org_id       TEXT NOT NULL,
+    id           TEXT NOT NULL,
+    name         TEXT NOT NULL,
+    resource_ids TEXT[] NOT NULL DEFAULT '{}',
+    created_at   TIMESTAMPTZ NOT NULL,
+    updated_at   TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (org_id, id)
+);
+
+CREATE TABLE feature_counts (
+    org_id  TEXT NOT NULL,
+    week    TEXT NOT NULL,
+    feature TEXT NOT NULL,
+    count   INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (org_id, week, feature)
+);
+
+CREATE TABLE activity (
+    org_id       TEXT NOT NULL,
+    id           TEXT NOT NULL,
+    project_id   TEXT NOT NULL,
+    kind         TEXT NOT NULL,
+    resource_ids TEXT[] NOT NULL DEFAULT '{}',
+    user_id      TEXT NOT NULL,
+    time         TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (org_id, id)
+);
+
+CREATE INDEX activity_project ON activity (org_id, project_id, time DESC);
@@ -0,0 +1 @@
+ALTER TABLE projects ADD COLUMN notes JSONB NOT NULL DEFAULT '{}';
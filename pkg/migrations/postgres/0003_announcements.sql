@@ -0,0 +1,11 @@
+CREATE TABLE announcements (
+    org_id     TEXT NOT NULL,
+    id         TEXT NOT NULL,
+    title      TEXT NOT NULL,
+    body       TEXT NOT NULL DEFAULT '',
+    author_id  TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ,
+    read_by    TEXT[] NOT NULL DEFAULT '{}',
+    PRIMARY KEY (org_id, id)
+);
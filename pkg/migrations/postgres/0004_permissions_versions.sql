@@ -0,0 +1,5 @@
+-- Roles may be registered before the user, hence no foreign key to users
+CREATE TABLE permissions_versions (
+    user_id TEXT PRIMARY KEY,
+    version BIGINT NOT NULL
+);
@@ -0,0 +1,8 @@
+-- Distinct words found in the parts of each resource, written by the text extraction job
+CREATE TABLE resource_texts (
+    org_id       TEXT NOT NULL,
+    resource_id  TEXT NOT NULL,
+    words        TEXT[] NOT NULL DEFAULT '{}',
+    extracted_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (org_id, resource_id)
+);
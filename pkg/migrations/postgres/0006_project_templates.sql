@@ -0,0 +1,16 @@
+-- Defaults of recurring kinds of projects, copied to projects created from the template
+CREATE TABLE project_templates (
+    org_id       TEXT NOT NULL,
+    id           TEXT NOT NULL,
+    name         TEXT NOT NULL,
+    categories   TEXT[] NOT NULL DEFAULT '{}',
+    announcement TEXT NOT NULL DEFAULT '',
+    groups       TEXT[] NOT NULL DEFAULT '{}',
+    updated_at   TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (org_id, id)
+);
+
+ALTER TABLE projects ADD COLUMN template_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE projects ADD COLUMN categories TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE projects ADD COLUMN announcement TEXT NOT NULL DEFAULT '';
+ALTER TABLE projects ADD COLUMN groups TEXT[] NOT NULL DEFAULT '{}';
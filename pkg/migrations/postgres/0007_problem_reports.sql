@@ -0,0 +1,14 @@
+-- Problems with parts reported by members. The reporter is not stored
+CREATE TABLE problem_reports (
+    org_id         TEXT NOT NULL,
+    id             TEXT NOT NULL,
+    resource_id    TEXT NOT NULL,
+    resource_title TEXT NOT NULL DEFAULT '',
+    part           TEXT NOT NULL DEFAULT '',
+    kind           TEXT NOT NULL,
+    description    TEXT NOT NULL DEFAULT '',
+    status         TEXT NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL,
+    updated_at     TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (org_id, id)
+);
@@ -0,0 +1,13 @@
+ALTER TABLE organizations ADD COLUMN require_passkey BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- WebAuthn credentials of the users. The credential is the JSON encoded public key and sign count
+CREATE TABLE passkeys (
+    id           TEXT PRIMARY KEY,
+    user_id      TEXT NOT NULL,
+    name         TEXT NOT NULL DEFAULT '',
+    credential   JSONB NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL,
+    last_used_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX passkeys_user_id ON passkeys (user_id);
@@ -0,0 +1,14 @@
+-- Tokens scripts use instead of a session. Only the SHA-256 hash of a token is stored
+CREATE TABLE api_tokens (
+    hash       TEXT PRIMARY KEY,
+    id         TEXT NOT NULL,
+    user_id    TEXT NOT NULL,
+    org_id     TEXT NOT NULL,
+    name       TEXT NOT NULL,
+    scope      TEXT NOT NULL,
+    passkey    BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ
+);
+
+CREATE INDEX api_tokens_user_id ON api_tokens (user_id);
@@ -0,0 +1,8 @@
+-- Progress of the admins of new organizations through the onboarding wizard
+CREATE TABLE onboarding (
+    org_id      TEXT PRIMARY KEY,
+    completed   TEXT[] NOT NULL DEFAULT '{}',
+    instruments TEXT[] NOT NULL DEFAULT '{}',
+    dismissed   BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at  TIMESTAMPTZ NOT NULL
+);
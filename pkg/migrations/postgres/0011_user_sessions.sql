@@ -0,0 +1,11 @@
+-- Server side records of signed in browsers. Deleting a row signs the browser out
+CREATE TABLE user_sessions (
+    id           TEXT PRIMARY KEY,
+    user_id      TEXT NOT NULL,
+    user_agent   TEXT NOT NULL DEFAULT '',
+    ip           TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL,
+    last_seen_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX user_sessions_user_id ON user_sessions (user_id);
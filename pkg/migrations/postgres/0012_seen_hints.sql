@@ -0,0 +1,7 @@
+-- One-time hints each user has dismissed
+CREATE TABLE seen_hints (
+    user_id TEXT NOT NULL,
+    hint    TEXT NOT NULL,
+    seen_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, hint)
+);
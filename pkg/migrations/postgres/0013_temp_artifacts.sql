@@ -0,0 +1,10 @@
+-- Artifacts kept for a limited time, such as pre-generated archives. Expired artifacts are removed by the cleanup
+CREATE TABLE temp_artifacts (
+    kind       TEXT NOT NULL,
+    org_id     TEXT NOT NULL,
+    id         TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (kind, org_id, id)
+);
+
+CREATE INDEX temp_artifacts_expires_at ON temp_artifacts (kind, expires_at);
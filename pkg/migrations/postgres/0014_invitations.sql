@@ -0,0 +1,14 @@
+-- Invitations emailed to people who should join an organization with a given role and group
+CREATE TABLE invitations (
+    org_id      TEXT NOT NULL,
+    id          TEXT NOT NULL,
+    email       TEXT NOT NULL,
+    role        INTEGER NOT NULL,
+    group_name  TEXT NOT NULL DEFAULT '',
+    status      TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL,
+    expires_at  TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ,
+    accepted_by TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (org_id, id)
+);
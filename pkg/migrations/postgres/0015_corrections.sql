@@ -0,0 +1,19 @@
+-- Corrections of resource metadata and member names proposed by members and approved by librarians or admins
+CREATE TABLE corrections (
+    org_id        TEXT NOT NULL,
+    id            TEXT NOT NULL,
+    kind          TEXT NOT NULL,
+    target_id     TEXT NOT NULL,
+    target_name   TEXT NOT NULL DEFAULT '',
+    field         TEXT NOT NULL,
+    current_value TEXT NOT NULL DEFAULT '',
+    value         TEXT NOT NULL DEFAULT '',
+    comment       TEXT NOT NULL DEFAULT '',
+    proposed_by   TEXT NOT NULL,
+    proposer_name TEXT NOT NULL DEFAULT '',
+    status        TEXT NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL,
+    decided_at    TIMESTAMPTZ,
+    decided_by    TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (org_id, id)
+);
@@ -0,0 +1,11 @@
+-- Invite links that let anyone with the link join an organization as a viewer, stored such that they can be revoked
+CREATE TABLE invite_links (
+    org_id     TEXT NOT NULL,
+    id         TEXT NOT NULL,
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    revoked_by TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (org_id, id)
+);
@@ -0,0 +1,14 @@
+-- Append-only log of security relevant actions, e.g. sign ins, role changes and deletions
+CREATE TABLE audit_log (
+    org_id    TEXT NOT NULL,
+    id        TEXT NOT NULL,
+    action    TEXT NOT NULL,
+    actor_id  TEXT NOT NULL DEFAULT '',
+    target_id TEXT NOT NULL DEFAULT '',
+    detail    TEXT NOT NULL DEFAULT '',
+    ip        TEXT NOT NULL DEFAULT '',
+    time      TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (org_id, id)
+);
+
+CREATE INDEX audit_log_time ON audit_log (org_id, time);
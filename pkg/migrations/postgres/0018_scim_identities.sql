@@ -0,0 +1,8 @@
+-- Users provisioned by the identity provider of each organization, with the id the provider knows them by
+CREATE TABLE scim_identities (
+    org_id      TEXT NOT NULL,
+    user_id     TEXT NOT NULL,
+    external_id TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (org_id, user_id)
+);
@@ -0,0 +1,5 @@
+-- Sessions issued before the generation of the user are signed out
+CREATE TABLE session_generations (
+    user_id    TEXT PRIMARY KEY,
+    generation BIGINT NOT NULL
+);
@@ -0,0 +1,15 @@
+-- Time-limited access for guests outside the organization, stored such that it can be revoked
+CREATE TABLE guest_grants (
+    org_id       TEXT NOT NULL,
+    id           TEXT NOT NULL,
+    email        TEXT NOT NULL,
+    resource_ids TEXT[] NOT NULL DEFAULT '{}',
+    project_id   TEXT NOT NULL DEFAULT '',
+    watermark    TEXT NOT NULL DEFAULT '',
+    created_by   TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL,
+    expires_at   TIMESTAMPTZ NOT NULL,
+    revoked_at   TIMESTAMPTZ,
+    revoked_by   TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (org_id, id)
+);
@@ -0,0 +1,5 @@
+-- The overview can be sorted by title, composer and arranger. The resource id breaks ties, such that the pages
+-- of a sorted listing can start after the last resource of the previous page
+CREATE INDEX metadata_title ON metadata (org_id, title, resource_id);
+CREATE INDEX metadata_composer ON metadata (org_id, composer, resource_id);
+CREATE INDEX metadata_arranger ON metadata (org_id, arranger, resource_id);
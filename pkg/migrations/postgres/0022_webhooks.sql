@@ -0,0 +1,26 @@
+-- Outbound webhook of each organization, and the log of the events delivered to it
+CREATE TABLE webhooks (
+    org_id     TEXT PRIMARY KEY,
+    url        TEXT NOT NULL,
+    secret     TEXT NOT NULL,
+    events     TEXT[] NOT NULL DEFAULT '{}',
+    enabled    BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_by TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE webhook_deliveries (
+    org_id      TEXT NOT NULL,
+    id          TEXT NOT NULL,
+    event       TEXT NOT NULL,
+    target_id   TEXT NOT NULL DEFAULT '',
+    url         TEXT NOT NULL,
+    attempts    INTEGER NOT NULL,
+    status_code INTEGER NOT NULL DEFAULT 0,
+    error       TEXT NOT NULL DEFAULT '',
+    succeeded   BOOLEAN NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (org_id, id)
+);
+
+CREATE INDEX webhook_deliveries_created_at ON webhook_deliveries (org_id, created_at DESC);
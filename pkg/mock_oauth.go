@@ -0,0 +1,33 @@
+package pkg
+
+const (
+	GoogleUserInfoURL      = "https://www.googleapis.com/oauth2/v2/userinfo"
+	MockOAuthAuthorizePath = "/mock-oauth/authorize"
+	MockOAuthTokenPath     = "/mock-oauth/token"
+	MockOAuthUserInfoPath  = "/mock-oauth/userinfo"
+)
+
+// MockOAuthUsers are the users the built-in fake OAuth provider can sign in. The first two
+// are also present in NewDemoStore, the last one is unknown to all stores and goes through sign up
+func MockOAuthUsers() []UserInfo {
+	return []UserInfo{
+		{
+			Id:            "217f40fa-c0d7-4d8e-a284-293347868289",
+			Email:         "susan@example.com",
+			VerifiedEmail: true,
+			Name:          "Susan",
+		},
+		{
+			Id:            "6b2d9876-0bc4-407a-8f76-4fb1ad2a523b",
+			Email:         "john@example.com",
+			VerifiedEmail: true,
+			Name:          "John",
+		},
+		{
+			Id:            "0d9c2a5e-6f1b-4c38-9a57-3e8b1f4d7c20",
+			Email:         "new.member@example.com",
+			VerifiedEmail: true,
+			Name:          "New Member",
+		},
+	}
+}
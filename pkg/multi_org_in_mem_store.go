@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"iter"
 	"maps"
 	"slices"
@@ -94,10 +95,48 @@ func NewDemoStore() *MultiOrgInMemoryStore {
 }
 
 type MultiOrgInMemoryStore struct {
-	Data          map[string]*InMemoryStore
-	Users         []UserInfo
-	Organizations []Organization
-	Subscriptions map[string]Subscription
+	Data                map[string]*InMemoryStore
+	Users               []UserInfo
+	Organizations       []Organization
+	Subscriptions       map[string]Subscription
+	FeatureMetrics      map[string]FeatureCount
+	Activities          map[string][]Activity
+	AuditLogs           map[string][]AuditEntry
+	OrgAnnouncements    map[string][]Announcement
+	OrgTexts            map[string]map[string]ResourceText
+	OrgProjectTemplates map[string][]ProjectTemplate
+	OrgProblemReports   map[string][]ProblemReport
+	UserPasskeys        map[string][]Passkey
+	OrgOnboarding       map[string]Onboarding
+	OrgInvitations      map[string][]Invitation
+	OrgInviteLinks      map[string][]InviteLink
+	OrgGuestGrants      map[string][]GuestGrant
+	OrgWebhooks         map[string]Webhook
+	OrgCorrections      map[string][]Correction
+	OrgScimIdentities   map[string][]ScimIdentity
+
+	// Deliveries to the webhooks by organization, oldest first
+	OrgWebhookDeliveries map[string][]WebhookDelivery
+
+	// API tokens by the hash of the token
+	HashedApiTokens map[string]ApiToken
+
+	// Signed in sessions by session id
+	UserSessions map[string]UserSession
+
+	// Hints each user has dismissed
+	UserHints map[string][]Hint
+
+	// Version of the permissions of each user that had roles or groups changed
+	PermissionsVersions map[string]int64
+	SessionGenerations  map[string]int64
+
+	// Generated archives by organization and id, and their chunks by organization, id and index
+	Archives      map[string]Archive
+	ArchiveChunks map[string][]byte
+
+	// Temporary artifacts by kind, organization and id
+	TempArtifacts map[string]TempArtifact
 }
 
 func (m *MultiOrgInMemoryStore) Submit(ctx context.Context, orgId string, meta *MetaData, pdfIter iter.Seq2[string, []byte]) error {
@@ -146,6 +185,15 @@ func (m *MultiOrgInMemoryStore) ProjectById(ctx context.Context, orgId, id strin
 	return store.ProjectById(ctx, id)
 }
 
+func (m *MultiOrgInMemoryStore) DeleteProject(ctx context.Context, orgId, projectId string) error {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return ErrOrganizationNotFound
+	}
+	delete(store.Projects, projectId)
+	return nil
+}
+
 func (m *MultiOrgInMemoryStore) RemoveResource(ctx context.Context, orgId, projectId, resourceId string) error {
 	store, ok := m.Data[orgId]
 	if !ok {
@@ -154,6 +202,46 @@ func (m *MultiOrgInMemoryStore) RemoveResource(ctx context.Context, orgId, proje
 	return store.RemoveResource(ctx, projectId, resourceId)
 }
 
+func (m *MultiOrgInMemoryStore) ResourceVersions(ctx context.Context, orgId, resourceId string) ([]ResourceVersion, error) {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return nil, ErrOrganizationNotFound
+	}
+	return store.ResourceVersions(ctx, resourceId)
+}
+
+func (m *MultiOrgInMemoryStore) ResourceVersion(ctx context.Context, orgId, resourceId string, version int) iter.Seq2[string, []byte] {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return func(yield func(string, []byte) bool) {}
+	}
+	return store.ResourceVersion(ctx, resourceId, version)
+}
+
+func (m *MultiOrgInMemoryStore) RestoreVersion(ctx context.Context, orgId, resourceId string, version int) error {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return ErrOrganizationNotFound
+	}
+	return store.RestoreVersion(ctx, resourceId, version)
+}
+
+func (m *MultiOrgInMemoryStore) SetProjectOrder(ctx context.Context, orgId, projectId string, resourceIds []string) error {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return ErrOrganizationNotFound
+	}
+	return store.SetProjectOrder(ctx, projectId, resourceIds)
+}
+
+func (m *MultiOrgInMemoryStore) SetProjectNote(ctx context.Context, orgId, projectId, resourceId, note string) error {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return ErrOrganizationNotFound
+	}
+	return store.SetProjectNote(ctx, projectId, resourceId, note)
+}
+
 func (m *MultiOrgInMemoryStore) MetaById(ctx context.Context, orgId, id string) (*MetaData, error) {
 	store, ok := m.Data[orgId]
 	if !ok {
@@ -162,6 +250,72 @@ func (m *MultiOrgInMemoryStore) MetaById(ctx context.Context, orgId, id string)
 	return store.MetaById(ctx, id)
 }
 
+func (m *MultiOrgInMemoryStore) ResourceManifest(ctx context.Context, orgId, resourceId string) (*ResourceManifest, error) {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return nil, ErrOrganizationNotFound
+	}
+	return store.ResourceManifest(ctx, resourceId)
+}
+
+func (m *MultiOrgInMemoryStore) SaveArchive(ctx context.Context, archive *Archive) error {
+	m.Archives[archivePrefix(archive.OrgId, archive.Id)] = *archive
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) Archive(ctx context.Context, orgId, id string) (*Archive, error) {
+	archive, ok := m.Archives[archivePrefix(orgId, id)]
+	if !ok {
+		return nil, errors.Join(ErrArchiveNotFound, fmt.Errorf("archive id: %s", id))
+	}
+	return &archive, nil
+}
+
+func (m *MultiOrgInMemoryStore) SaveArchiveChunk(ctx context.Context, orgId, id string, index int, data []byte) error {
+	m.ArchiveChunks[archiveChunkName(orgId, id, index)] = slices.Clone(data)
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) ArchiveChunk(ctx context.Context, orgId, id string, index int) ([]byte, error) {
+	chunk, ok := m.ArchiveChunks[archiveChunkName(orgId, id, index)]
+	if !ok {
+		return nil, errors.Join(ErrArchiveNotFound, fmt.Errorf("chunk %d of archive id: %s", index, id))
+	}
+	return chunk, nil
+}
+
+func (m *MultiOrgInMemoryStore) DeleteArchive(ctx context.Context, orgId, id string) error {
+	prefix := archivePrefix(orgId, id)
+	maps.DeleteFunc(m.ArchiveChunks, func(name string, _ []byte) bool { return strings.HasPrefix(name, prefix) })
+	delete(m.Archives, prefix)
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) TrackArtifact(ctx context.Context, artifact *TempArtifact) error {
+	m.TempArtifacts[string(artifact.Kind)+"/"+artifact.key()] = *artifact
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) ExpiredArtifacts(ctx context.Context, kind ArtifactKind, now time.Time) ([]TempArtifact, error) {
+	expired := []TempArtifact{}
+	for _, artifact := range m.TempArtifacts {
+		if artifact.Kind == kind && !now.Before(artifact.ExpiresAt) {
+			expired = append(expired, artifact)
+		}
+	}
+	return expired, nil
+}
+
+func (m *MultiOrgInMemoryStore) ForgetArtifact(ctx context.Context, artifact *TempArtifact) error {
+	delete(m.TempArtifacts, string(artifact.Kind)+"/"+artifact.key())
+	return nil
+}
+
+// MetaByIds fetches the metadata serially since all data is in memory
+func (m *MultiOrgInMemoryStore) MetaByIds(ctx context.Context, orgId string, ids []string) []MetaLookup {
+	return FetchMetaByIds(ctx, m, orgId, ids, 1)
+}
+
 func (m *MultiOrgInMemoryStore) Resource(ctx context.Context, orgId, name string) iter.Seq2[string, []byte] {
 	store, ok := m.Data[orgId]
 	if !ok {
@@ -170,6 +324,116 @@ func (m *MultiOrgInMemoryStore) Resource(ctx context.Context, orgId, name string
 	return store.Resource(ctx, name)
 }
 
+func (m *MultiOrgInMemoryStore) ResourceStream(ctx context.Context, orgId, name string) iter.Seq2[string, io.Reader] {
+	return Readers(m.Resource(ctx, orgId, name))
+}
+
+// OpenPart reads the part from memory, where every range is available without further cost
+func (m *MultiOrgInMemoryStore) OpenPart(ctx context.Context, orgId, resourceId, filename string) (io.ReadSeekCloser, error) {
+	for name, content := range m.Resource(ctx, orgId, resourceId) {
+		if name == filename {
+			return nopSeekCloser{bytes.NewReader(content)}, nil
+		}
+	}
+	return nil, errors.Join(ErrFileNotFound, fmt.Errorf("file: %s", filename))
+}
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error {
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) RecordAccess(ctx context.Context, orgId, resourceId string, at time.Time) error {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return ErrOrganizationNotFound
+	}
+	return store.RecordAccess(ctx, resourceId, at)
+}
+
+func (m *MultiOrgInMemoryStore) TransitionStorageClass(ctx context.Context, orgId, resourceId string, class StorageClass) error {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return ErrOrganizationNotFound
+	}
+	return store.TransitionStorageClass(ctx, resourceId, class)
+}
+
+func (m *MultiOrgInMemoryStore) SetProtected(ctx context.Context, orgId, resourceId string, protected bool) error {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return ErrOrganizationNotFound
+	}
+	return store.SetProtected(ctx, resourceId, protected)
+}
+
+func (m *MultiOrgInMemoryStore) DeleteResource(ctx context.Context, orgId, resourceId string) error {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return ErrOrganizationNotFound
+	}
+	return store.DeleteResource(ctx, resourceId)
+}
+
+func (m *MultiOrgInMemoryStore) RestoreResource(ctx context.Context, orgId, resourceId string) error {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return ErrOrganizationNotFound
+	}
+	return store.RestoreResource(ctx, resourceId)
+}
+
+func (m *MultiOrgInMemoryStore) PurgeResource(ctx context.Context, orgId, resourceId string) error {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return ErrOrganizationNotFound
+	}
+	return store.PurgeResource(ctx, resourceId)
+}
+
+func (m *MultiOrgInMemoryStore) ResolveSubmit(ctx context.Context, orgId, resourceId string) error {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return ErrOrganizationNotFound
+	}
+	return store.ResolveSubmit(ctx, resourceId)
+}
+
+func (m *MultiOrgInMemoryStore) ResourceFiles(ctx context.Context, orgId string) (map[string]int, error) {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return nil, ErrOrganizationNotFound
+	}
+	return store.ResourceFiles(ctx), nil
+}
+
+func (m *MultiOrgInMemoryStore) RemoveOrphanFiles(ctx context.Context, orgId, resourceId string) error {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return ErrOrganizationNotFound
+	}
+	return store.RemoveOrphanFiles(ctx, resourceId)
+}
+
+func (m *MultiOrgInMemoryStore) RemoveOrphanMetaData(ctx context.Context, orgId, resourceId string) error {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return ErrOrganizationNotFound
+	}
+	return store.RemoveOrphanMetaData(ctx, resourceId)
+}
+
+func (m *MultiOrgInMemoryStore) UpdateMetaData(ctx context.Context, orgId string, meta *MetaData) error {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return ErrOrganizationNotFound
+	}
+	return store.UpdateMetaData(ctx, meta)
+}
+
 func (m *MultiOrgInMemoryStore) Clone() *MultiOrgInMemoryStore {
 	dst := NewMultiOrgInMemoryStore()
 
@@ -186,6 +450,78 @@ func (m *MultiOrgInMemoryStore) Clone() *MultiOrgInMemoryStore {
 	dst.Organizations = make([]Organization, len(m.Organizations))
 	copy(dst.Organizations, m.Organizations)
 	maps.Copy(dst.Subscriptions, m.Subscriptions)
+	maps.Copy(dst.FeatureMetrics, m.FeatureMetrics)
+	for orgId, activities := range m.Activities {
+		dst.Activities[orgId] = slices.Clone(activities)
+	}
+	for orgId, entries := range m.AuditLogs {
+		dst.AuditLogs[orgId] = slices.Clone(entries)
+	}
+	for orgId, announcements := range m.OrgAnnouncements {
+		for _, announcement := range announcements {
+			announcement.ReadBy = slices.Clone(announcement.ReadBy)
+			dst.OrgAnnouncements[orgId] = append(dst.OrgAnnouncements[orgId], announcement)
+		}
+	}
+	for orgId, templates := range m.OrgProjectTemplates {
+		dst.OrgProjectTemplates[orgId] = slices.Clone(templates)
+	}
+	for orgId, reports := range m.OrgProblemReports {
+		dst.OrgProblemReports[orgId] = slices.Clone(reports)
+	}
+	for orgId, invitations := range m.OrgInvitations {
+		dst.OrgInvitations[orgId] = slices.Clone(invitations)
+	}
+	for orgId, links := range m.OrgInviteLinks {
+		dst.OrgInviteLinks[orgId] = slices.Clone(links)
+	}
+	for orgId, grants := range m.OrgGuestGrants {
+		dst.OrgGuestGrants[orgId] = slices.Clone(grants)
+	}
+	for orgId, webhook := range m.OrgWebhooks {
+		webhook.Events = slices.Clone(webhook.Events)
+		dst.OrgWebhooks[orgId] = webhook
+	}
+	for orgId, deliveries := range m.OrgWebhookDeliveries {
+		dst.OrgWebhookDeliveries[orgId] = slices.Clone(deliveries)
+	}
+	for orgId, corrections := range m.OrgCorrections {
+		dst.OrgCorrections[orgId] = slices.Clone(corrections)
+	}
+	for orgId, identities := range m.OrgScimIdentities {
+		dst.OrgScimIdentities[orgId] = slices.Clone(identities)
+	}
+	for userId, passkeys := range m.UserPasskeys {
+		dst.UserPasskeys[userId] = slices.Clone(passkeys)
+	}
+	for hash, token := range m.HashedApiTokens {
+		dst.HashedApiTokens[hash] = token
+	}
+	for id, session := range m.UserSessions {
+		dst.UserSessions[id] = session
+	}
+	for userId, hints := range m.UserHints {
+		dst.UserHints[userId] = slices.Clone(hints)
+	}
+	for orgId, onboarding := range m.OrgOnboarding {
+		onboarding.Completed = slices.Clone(onboarding.Completed)
+		onboarding.Instruments = slices.Clone(onboarding.Instruments)
+		dst.OrgOnboarding[orgId] = onboarding
+	}
+	for orgId, texts := range m.OrgTexts {
+		dst.OrgTexts[orgId] = make(map[string]ResourceText, len(texts))
+		for resourceId, text := range texts {
+			text.Words = slices.Clone(text.Words)
+			dst.OrgTexts[orgId][resourceId] = text
+		}
+	}
+	maps.Copy(dst.PermissionsVersions, m.PermissionsVersions)
+	maps.Copy(dst.SessionGenerations, m.SessionGenerations)
+	maps.Copy(dst.Archives, m.Archives)
+	for key, chunk := range m.ArchiveChunks {
+		dst.ArchiveChunks[key] = bytes.Clone(chunk)
+	}
+	maps.Copy(dst.TempArtifacts, m.TempArtifacts)
 	return dst
 }
 
@@ -199,6 +535,7 @@ func (m *MultiOrgInMemoryStore) GetUserInfo(ctx context.Context, userId string)
 }
 
 func (m *MultiOrgInMemoryStore) RegisterRole(ctx context.Context, userId string, organizationId string, role RoleKind) error {
+	m.PermissionsVersions[userId] = newPermissionsVersion()
 	for i, u := range m.Users {
 		if u.Id == userId {
 			m.Users[i].Roles[organizationId] = role
@@ -216,6 +553,10 @@ func (m *MultiOrgInMemoryStore) RegisterRole(ctx context.Context, userId string,
 	return nil
 }
 
+func (m *MultiOrgInMemoryStore) PermissionsVersion(ctx context.Context, userId string) (int64, error) {
+	return m.PermissionsVersions[userId], nil
+}
+
 func (m *MultiOrgInMemoryStore) RegisterOrganization(ctx context.Context, org *Organization) error {
 	m.Data[org.Id] = NewInMemoryStore()
 	m.Organizations = append(m.Organizations, *org)
@@ -254,6 +595,12 @@ func (m *MultiOrgInMemoryStore) GetOrganization(ctx context.Context, orgId strin
 	return Organization{}, ErrOrganizationNotFound
 }
 
+func (m *MultiOrgInMemoryStore) ListOrganizations(ctx context.Context) ([]Organization, error) {
+	result := make([]Organization, len(m.Organizations))
+	copy(result, m.Organizations)
+	return result, nil
+}
+
 func (m *MultiOrgInMemoryStore) DeleteOrganization(ctx context.Context, orgId string) error {
 	for i, org := range m.Organizations {
 		if org.Id == orgId {
@@ -263,6 +610,49 @@ func (m *MultiOrgInMemoryStore) DeleteOrganization(ctx context.Context, orgId st
 	return nil
 }
 
+func (m *MultiOrgInMemoryStore) UpdateBranding(ctx context.Context, orgId string, branding Branding) error {
+	for i, org := range m.Organizations {
+		if org.Id == orgId && !org.Deleted {
+			m.Organizations[i].Branding = branding
+			return nil
+		}
+	}
+	return ErrOrganizationNotFound
+}
+
+func (m *MultiOrgInMemoryStore) OrganizationByDomain(ctx context.Context, domain string) (Organization, error) {
+	for _, org := range m.Organizations {
+		if domain != "" && org.Domain == domain && !org.Deleted {
+			return org, nil
+		}
+	}
+	return Organization{}, ErrOrganizationNotFound
+}
+
+func (m *MultiOrgInMemoryStore) UpdateDomain(ctx context.Context, orgId, domain string) error {
+	if owner, err := m.OrganizationByDomain(ctx, domain); err == nil && owner.Id != orgId {
+		return errors.Join(ErrDomainInUse, fmt.Errorf("%s is used by %s", domain, owner.Id))
+	}
+
+	for i, org := range m.Organizations {
+		if org.Id == orgId && !org.Deleted {
+			m.Organizations[i].Domain = domain
+			return nil
+		}
+	}
+	return ErrOrganizationNotFound
+}
+
+func (m *MultiOrgInMemoryStore) RequirePasskey(ctx context.Context, orgId string, required bool) error {
+	for i, org := range m.Organizations {
+		if org.Id == orgId && !org.Deleted {
+			m.Organizations[i].RequirePasskey = required
+			return nil
+		}
+	}
+	return ErrOrganizationNotFound
+}
+
 func (m *MultiOrgInMemoryStore) GetUsersInOrg(ctx context.Context, orgId string) ([]UserInfo, error) {
 	result := make([]UserInfo, 0, len(m.Users))
 	for _, user := range m.Users {
@@ -274,6 +664,7 @@ func (m *MultiOrgInMemoryStore) GetUsersInOrg(ctx context.Context, orgId string)
 }
 
 func (m *MultiOrgInMemoryStore) DeleteRole(ctx context.Context, userId, orgId string) error {
+	m.PermissionsVersions[userId] = newPermissionsVersion()
 	for i, u := range m.Users {
 		if u.Id == userId {
 			delete(m.Users[i].Roles, orgId)
@@ -283,6 +674,7 @@ func (m *MultiOrgInMemoryStore) DeleteRole(ctx context.Context, userId, orgId st
 }
 
 func (m *MultiOrgInMemoryStore) RegisterGroup(ctx context.Context, userId, orgId, group string) error {
+	m.PermissionsVersions[userId] = newPermissionsVersion()
 	for i, u := range m.Users {
 		if u.Id == userId {
 			_, exists := m.Users[i].Groups[orgId]
@@ -297,6 +689,7 @@ func (m *MultiOrgInMemoryStore) RegisterGroup(ctx context.Context, userId, orgId
 }
 
 func (m *MultiOrgInMemoryStore) RemoveGroup(ctx context.Context, userId, orgId, group string) error {
+	m.PermissionsVersions[userId] = newPermissionsVersion()
 	for i, u := range m.Users {
 		if u.Id == userId {
 			groups, ok := u.Groups[orgId]
@@ -326,7 +719,7 @@ func (m *MultiOrgInMemoryStore) Item(ctx context.Context, path string) ([]byte,
 
 	data, ok := orgData.Item(fullName)
 	if !ok {
-		return data, fmt.Errorf("Resource not found %s", fullName)
+		return data, errors.Join(ErrResourceNotFound, fmt.Errorf("item %s", fullName))
 	}
 	return data, nil
 }
@@ -397,11 +790,560 @@ func (m *MultiOrgInMemoryStore) ResetPassword(ctx context.Context, userId, passw
 	return ErrUserNotFound
 }
 
+func (m *MultiOrgInMemoryStore) MarkEmailVerified(ctx context.Context, userId string) error {
+	for i, user := range m.Users {
+		if user.Id == userId {
+			m.Users[i].VerifiedEmail = true
+			return nil
+		}
+	}
+	return ErrUserNotFound
+}
+
+func (m *MultiOrgInMemoryStore) EraseUser(ctx context.Context, userId string) error {
+	idx := slices.IndexFunc(m.Users, func(u UserInfo) bool { return u.Id == userId })
+	if idx < 0 {
+		return errors.Join(ErrUserNotFound, fmt.Errorf("user id: %s", userId))
+	}
+	email := m.Users[idx].Email
+	m.Users = slices.Delete(m.Users, idx, idx+1)
+
+	for _, activities := range m.Activities {
+		for i := range activities {
+			if activities[i].UserId == userId {
+				activities[i].UserId = DeletedUserId
+			}
+		}
+	}
+	for _, announcements := range m.OrgAnnouncements {
+		for i := range announcements {
+			if announcements[i].AuthorId == userId {
+				announcements[i].AuthorId = DeletedUserId
+			}
+			announcements[i].ReadBy = slices.DeleteFunc(announcements[i].ReadBy, func(id string) bool { return id == userId })
+		}
+	}
+
+	for _, entries := range m.AuditLogs {
+		for i := range entries {
+			entries[i].eraseUser(userId)
+		}
+	}
+	for _, invitations := range m.OrgInvitations {
+		for i := range invitations {
+			invitations[i].eraseUser(userId, email)
+		}
+	}
+	now := time.Now()
+	for _, grants := range m.OrgGuestGrants {
+		for i := range grants {
+			grants[i].eraseUser(userId, email, now)
+		}
+	}
+	for orgId, corrections := range m.OrgCorrections {
+		corrections = slices.DeleteFunc(corrections, func(c Correction) bool { return c.concernsUser(userId) })
+		for i := range corrections {
+			corrections[i].eraseUser(userId)
+		}
+		m.OrgCorrections[orgId] = corrections
+	}
+	for orgId, identities := range m.OrgScimIdentities {
+		m.OrgScimIdentities[orgId] = slices.DeleteFunc(identities, func(i ScimIdentity) bool { return i.UserId == userId })
+	}
+
+	delete(m.UserPasskeys, userId)
+	delete(m.UserHints, userId)
+	maps.DeleteFunc(m.HashedApiTokens, func(_ string, t ApiToken) bool { return t.UserId == userId })
+	maps.DeleteFunc(m.UserSessions, func(_ string, s UserSession) bool { return s.UserId == userId })
+	m.PermissionsVersions[userId] = newPermissionsVersion()
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) CountFeature(ctx context.Context, orgId string, feature Feature, at time.Time) error {
+	count := FeatureCount{OrgId: orgId, Week: IsoWeek(at), Feature: feature}
+	if existing, ok := m.FeatureMetrics[count.Id()]; ok {
+		count = existing
+	}
+	count.Count++
+	m.FeatureMetrics[count.Id()] = count
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) FeatureCounts(ctx context.Context, since time.Time) ([]FeatureCount, error) {
+	sinceWeek := IsoWeek(since)
+	result := []FeatureCount{}
+	for _, count := range m.FeatureMetrics {
+		if count.Week >= sinceWeek {
+			result = append(result, count)
+		}
+	}
+	SortFeatureCounts(result)
+	return result, nil
+}
+
 func NewMultiOrgInMemoryStore() *MultiOrgInMemoryStore {
 	return &MultiOrgInMemoryStore{
-		Data:          make(map[string]*InMemoryStore),
-		Users:         []UserInfo{},
-		Organizations: []Organization{},
-		Subscriptions: make(map[string]Subscription),
+		Data:                make(map[string]*InMemoryStore),
+		Users:               []UserInfo{},
+		Organizations:       []Organization{},
+		Subscriptions:       make(map[string]Subscription),
+		FeatureMetrics:      make(map[string]FeatureCount),
+		Activities:          make(map[string][]Activity),
+		AuditLogs:           make(map[string][]AuditEntry),
+		OrgAnnouncements:    make(map[string][]Announcement),
+		OrgTexts:            make(map[string]map[string]ResourceText),
+		OrgProjectTemplates: make(map[string][]ProjectTemplate),
+		OrgProblemReports:   make(map[string][]ProblemReport),
+		UserPasskeys:        make(map[string][]Passkey),
+		OrgOnboarding:       make(map[string]Onboarding),
+		OrgInvitations:      make(map[string][]Invitation),
+		OrgInviteLinks:      make(map[string][]InviteLink),
+		OrgGuestGrants:      make(map[string][]GuestGrant),
+		OrgWebhooks:         make(map[string]Webhook),
+		OrgCorrections:      make(map[string][]Correction),
+		OrgScimIdentities:   make(map[string][]ScimIdentity),
+		HashedApiTokens:     make(map[string]ApiToken),
+		UserSessions:        make(map[string]UserSession),
+		UserHints:           make(map[string][]Hint),
+
+		PermissionsVersions: make(map[string]int64),
+		SessionGenerations:  make(map[string]int64),
+		Archives:            make(map[string]Archive),
+		ArchiveChunks:       make(map[string][]byte),
+		TempArtifacts:       make(map[string]TempArtifact),
+
+		OrgWebhookDeliveries: make(map[string][]WebhookDelivery),
+	}
+}
+
+func (m *MultiOrgInMemoryStore) RecordActivity(ctx context.Context, orgId string, activity *Activity) error {
+	m.Activities[orgId] = append(m.Activities[orgId], *activity)
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) ProjectActivity(ctx context.Context, orgId, projectId string) ([]Activity, error) {
+	result := []Activity{}
+	for _, activity := range m.Activities[orgId] {
+		if activity.ProjectId == projectId {
+			result = append(result, activity)
+		}
+	}
+	SortActivity(result)
+	return result, nil
+}
+
+func (m *MultiOrgInMemoryStore) OrganizationActivity(ctx context.Context, orgId string, from, to time.Time) ([]Activity, error) {
+	result := []Activity{}
+	for _, activity := range m.Activities[orgId] {
+		if InTimeRange(activity.Time, from, to) {
+			result = append(result, activity)
+		}
+	}
+	SortActivity(result)
+	return result, nil
+}
+
+func (m *MultiOrgInMemoryStore) RemoveActivity(ctx context.Context, orgId string, kind ActivityKind, before time.Time) (int, error) {
+	num := len(m.Activities[orgId])
+	m.Activities[orgId] = slices.DeleteFunc(m.Activities[orgId], func(a Activity) bool {
+		return a.Kind == kind && a.Time.Before(before)
+	})
+	return num - len(m.Activities[orgId]), nil
+}
+
+func (m *MultiOrgInMemoryStore) Audit(ctx context.Context, orgId string, entry *AuditEntry) error {
+	m.AuditLogs[orgId] = append(m.AuditLogs[orgId], *entry)
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) AuditLog(ctx context.Context, orgId string, from, to time.Time) ([]AuditEntry, error) {
+	result := []AuditEntry{}
+	for _, entry := range m.AuditLogs[orgId] {
+		if InTimeRange(entry.Time, from, to) {
+			result = append(result, entry)
+		}
+	}
+	SortAuditLog(result)
+	return result, nil
+}
+
+func (m *MultiOrgInMemoryStore) SubmitAnnouncement(ctx context.Context, orgId string, announcement *Announcement) error {
+	if err := announcement.Validate(); err != nil {
+		return err
+	}
+	m.OrgAnnouncements[orgId] = append(m.OrgAnnouncements[orgId], *announcement)
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) Announcements(ctx context.Context, orgId string) ([]Announcement, error) {
+	result := slices.Clone(m.OrgAnnouncements[orgId])
+	SortAnnouncements(result)
+	return result, nil
+}
+
+func (m *MultiOrgInMemoryStore) announcement(orgId, id string) (*Announcement, error) {
+	idx := slices.IndexFunc(m.OrgAnnouncements[orgId], func(a Announcement) bool { return a.Id == id })
+	if idx < 0 {
+		return nil, announcementNotFound(id)
+	}
+	return &m.OrgAnnouncements[orgId][idx], nil
+}
+
+func (m *MultiOrgInMemoryStore) ExpireAnnouncement(ctx context.Context, orgId, id string, at time.Time) error {
+	announcement, err := m.announcement(orgId, id)
+	if err != nil {
+		return err
+	}
+	announcement.ExpiresAt = at
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) MarkAnnouncementRead(ctx context.Context, orgId, id, userId string) error {
+	announcement, err := m.announcement(orgId, id)
+	if err != nil {
+		return err
+	}
+	if !announcement.IsReadBy(userId) {
+		announcement.ReadBy = append(slices.Clone(announcement.ReadBy), userId)
+	}
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) StoreResourceText(ctx context.Context, orgId string, text *ResourceText) error {
+	if _, ok := m.OrgTexts[orgId]; !ok {
+		m.OrgTexts[orgId] = make(map[string]ResourceText)
+	}
+	m.OrgTexts[orgId][text.ResourceId] = *text
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) ResourceTexts(ctx context.Context, orgId string) ([]ResourceText, error) {
+	return slices.AppendSeq([]ResourceText{}, maps.Values(m.OrgTexts[orgId])), nil
+}
+
+func (m *MultiOrgInMemoryStore) SubmitProjectTemplate(ctx context.Context, orgId string, template *ProjectTemplate) error {
+	if err := template.Validate(); err != nil {
+		return err
+	}
+	m.OrgProjectTemplates[orgId] = slices.DeleteFunc(m.OrgProjectTemplates[orgId], func(t ProjectTemplate) bool { return t.Id() == template.Id() })
+	m.OrgProjectTemplates[orgId] = append(m.OrgProjectTemplates[orgId], *template)
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) ProjectTemplates(ctx context.Context, orgId string) ([]ProjectTemplate, error) {
+	result := slices.Clone(m.OrgProjectTemplates[orgId])
+	SortProjectTemplates(result)
+	return result, nil
+}
+
+func (m *MultiOrgInMemoryStore) ProjectTemplateById(ctx context.Context, orgId, id string) (*ProjectTemplate, error) {
+	idx := slices.IndexFunc(m.OrgProjectTemplates[orgId], func(t ProjectTemplate) bool { return t.Id() == id })
+	if idx < 0 {
+		return &ProjectTemplate{}, projectTemplateNotFound(id)
+	}
+	template := m.OrgProjectTemplates[orgId][idx]
+	return &template, nil
+}
+
+func (m *MultiOrgInMemoryStore) DeleteProjectTemplate(ctx context.Context, orgId, id string) error {
+	num := len(m.OrgProjectTemplates[orgId])
+	m.OrgProjectTemplates[orgId] = slices.DeleteFunc(m.OrgProjectTemplates[orgId], func(t ProjectTemplate) bool { return t.Id() == id })
+	if len(m.OrgProjectTemplates[orgId]) == num {
+		return projectTemplateNotFound(id)
+	}
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) SubmitProblemReport(ctx context.Context, orgId string, report *ProblemReport) error {
+	if err := report.Validate(); err != nil {
+		return err
+	}
+	m.OrgProblemReports[orgId] = append(m.OrgProblemReports[orgId], *report)
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) ProblemReports(ctx context.Context, orgId string) ([]ProblemReport, error) {
+	result := slices.Clone(m.OrgProblemReports[orgId])
+	SortProblemReports(result)
+	return result, nil
+}
+
+func (m *MultiOrgInMemoryStore) SetProblemReportStatus(ctx context.Context, orgId, id string, status ProblemStatus, at time.Time) error {
+	if err := ValidateProblemStatus(status); err != nil {
+		return err
+	}
+	idx := slices.IndexFunc(m.OrgProblemReports[orgId], func(r ProblemReport) bool { return r.Id == id })
+	if idx < 0 {
+		return problemReportNotFound(id)
+	}
+	m.OrgProblemReports[orgId][idx].Status = status
+	m.OrgProblemReports[orgId][idx].UpdatedAt = at
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) SaveInvitation(ctx context.Context, invitation *Invitation) error {
+	if err := invitation.Validate(); err != nil {
+		return err
+	}
+	m.OrgInvitations[invitation.OrgId] = slices.DeleteFunc(m.OrgInvitations[invitation.OrgId], func(i Invitation) bool { return i.Id == invitation.Id })
+	m.OrgInvitations[invitation.OrgId] = append(m.OrgInvitations[invitation.OrgId], *invitation)
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) Invitation(ctx context.Context, orgId, id string) (*Invitation, error) {
+	idx := slices.IndexFunc(m.OrgInvitations[orgId], func(i Invitation) bool { return i.Id == id })
+	if idx == -1 {
+		return &Invitation{}, invitationNotFound(id)
+	}
+	invitation := m.OrgInvitations[orgId][idx]
+	return &invitation, nil
+}
+
+func (m *MultiOrgInMemoryStore) Invitations(ctx context.Context, orgId string) ([]Invitation, error) {
+	result := slices.Clone(m.OrgInvitations[orgId])
+	if result == nil {
+		result = []Invitation{}
 	}
+	SortInvitations(result)
+	return result, nil
+}
+
+func (m *MultiOrgInMemoryStore) SaveScimIdentity(ctx context.Context, identity *ScimIdentity) error {
+	m.OrgScimIdentities[identity.OrgId] = slices.DeleteFunc(m.OrgScimIdentities[identity.OrgId], func(i ScimIdentity) bool { return i.UserId == identity.UserId })
+	m.OrgScimIdentities[identity.OrgId] = append(m.OrgScimIdentities[identity.OrgId], *identity)
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) ScimIdentities(ctx context.Context, orgId string) ([]ScimIdentity, error) {
+	result := slices.Clone(m.OrgScimIdentities[orgId])
+	if result == nil {
+		result = []ScimIdentity{}
+	}
+	return result, nil
+}
+
+func (m *MultiOrgInMemoryStore) SaveInviteLink(ctx context.Context, link *InviteLink) error {
+	if err := link.Validate(); err != nil {
+		return err
+	}
+	m.OrgInviteLinks[link.OrgId] = slices.DeleteFunc(m.OrgInviteLinks[link.OrgId], func(l InviteLink) bool { return l.Id == link.Id })
+	m.OrgInviteLinks[link.OrgId] = append(m.OrgInviteLinks[link.OrgId], *link)
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) InviteLink(ctx context.Context, orgId, id string) (*InviteLink, error) {
+	idx := slices.IndexFunc(m.OrgInviteLinks[orgId], func(l InviteLink) bool { return l.Id == id })
+	if idx == -1 {
+		return &InviteLink{}, inviteLinkNotFound(id)
+	}
+	link := m.OrgInviteLinks[orgId][idx]
+	return &link, nil
+}
+
+func (m *MultiOrgInMemoryStore) InviteLinks(ctx context.Context, orgId string) ([]InviteLink, error) {
+	result := slices.Clone(m.OrgInviteLinks[orgId])
+	if result == nil {
+		result = []InviteLink{}
+	}
+	SortInviteLinks(result)
+	return result, nil
+}
+
+func (m *MultiOrgInMemoryStore) SubmitCorrection(ctx context.Context, orgId string, correction *Correction) error {
+	if err := correction.Validate(); err != nil {
+		return err
+	}
+	m.OrgCorrections[orgId] = slices.DeleteFunc(m.OrgCorrections[orgId], func(c Correction) bool { return c.Id == correction.Id })
+	m.OrgCorrections[orgId] = append(m.OrgCorrections[orgId], *correction)
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) Correction(ctx context.Context, orgId, id string) (*Correction, error) {
+	idx := slices.IndexFunc(m.OrgCorrections[orgId], func(c Correction) bool { return c.Id == id })
+	if idx == -1 {
+		return &Correction{}, correctionNotFound(id)
+	}
+	correction := m.OrgCorrections[orgId][idx]
+	return &correction, nil
+}
+
+func (m *MultiOrgInMemoryStore) Corrections(ctx context.Context, orgId string) ([]Correction, error) {
+	result := slices.Clone(m.OrgCorrections[orgId])
+	if result == nil {
+		result = []Correction{}
+	}
+	SortCorrections(result)
+	return result, nil
+}
+
+func (m *MultiOrgInMemoryStore) UpdateUserName(ctx context.Context, userId, name string) error {
+	idx := slices.IndexFunc(m.Users, func(u UserInfo) bool { return u.Id == userId })
+	if idx == -1 {
+		return errors.Join(ErrUserNotFound, fmt.Errorf("user id: %s", userId))
+	}
+	m.Users[idx].Name = name
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) SavePasskey(ctx context.Context, passkey *Passkey) error {
+	if err := passkey.Validate(); err != nil {
+		return err
+	}
+	passkeys := m.UserPasskeys[passkey.UserId]
+	idx := slices.IndexFunc(passkeys, func(p Passkey) bool { return p.Id == passkey.Id })
+	if idx < 0 {
+		m.UserPasskeys[passkey.UserId] = append(passkeys, *passkey)
+	} else {
+		passkeys[idx] = *passkey
+	}
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) Passkeys(ctx context.Context, userId string) ([]Passkey, error) {
+	result := slices.Clone(m.UserPasskeys[userId])
+	SortPasskeys(result)
+	return result, nil
+}
+
+func (m *MultiOrgInMemoryStore) DeletePasskey(ctx context.Context, userId, id string) error {
+	passkeys := m.UserPasskeys[userId]
+	idx := slices.IndexFunc(passkeys, func(p Passkey) bool { return p.Id == id })
+	if idx < 0 {
+		return passkeyNotFound(id)
+	}
+	m.UserPasskeys[userId] = slices.Delete(passkeys, idx, idx+1)
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) SaveApiToken(ctx context.Context, token *ApiToken) error {
+	if err := token.Validate(); err != nil {
+		return err
+	}
+	m.HashedApiTokens[token.Hash] = *token
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) ApiTokenByHash(ctx context.Context, hash string) (ApiToken, error) {
+	token, ok := m.HashedApiTokens[hash]
+	if !ok {
+		return ApiToken{}, ErrApiTokenNotFound
+	}
+	return token, nil
+}
+
+func (m *MultiOrgInMemoryStore) ApiTokens(ctx context.Context, userId string) ([]ApiToken, error) {
+	tokens := []ApiToken{}
+	for _, token := range m.HashedApiTokens {
+		if token.UserId == userId {
+			tokens = append(tokens, token)
+		}
+	}
+	SortApiTokens(tokens)
+	return tokens, nil
+}
+
+func (m *MultiOrgInMemoryStore) RevokeApiToken(ctx context.Context, userId, id string) error {
+	for hash, token := range m.HashedApiTokens {
+		if token.UserId == userId && token.Id == id {
+			delete(m.HashedApiTokens, hash)
+			return nil
+		}
+	}
+	return apiTokenNotFound(id)
+}
+
+func (m *MultiOrgInMemoryStore) RegisterSession(ctx context.Context, session *UserSession) error {
+	if err := session.Validate(); err != nil {
+		return err
+	}
+	m.UserSessions[session.Id] = *session
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) Session(ctx context.Context, id string) (UserSession, error) {
+	session, ok := m.UserSessions[id]
+	if !ok {
+		return UserSession{}, userSessionNotFound(id)
+	}
+	return session, nil
+}
+
+func (m *MultiOrgInMemoryStore) Sessions(ctx context.Context, userId string) ([]UserSession, error) {
+	sessions := []UserSession{}
+	for _, session := range m.UserSessions {
+		if session.UserId == userId {
+			sessions = append(sessions, session)
+		}
+	}
+	SortUserSessions(sessions)
+	return sessions, nil
+}
+
+func (m *MultiOrgInMemoryStore) RevokeSession(ctx context.Context, userId, id string) error {
+	session, ok := m.UserSessions[id]
+	if !ok || session.UserId != userId {
+		return userSessionNotFound(id)
+	}
+	delete(m.UserSessions, id)
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) SessionGeneration(ctx context.Context, userId string) (int64, error) {
+	return m.SessionGenerations[userId], nil
+}
+
+func (m *MultiOrgInMemoryStore) NewSessionGeneration(ctx context.Context, userId string) (int64, error) {
+	generation := newSessionGeneration()
+	m.SessionGenerations[userId] = generation
+	return generation, nil
+}
+
+func (m *MultiOrgInMemoryStore) SeenHints(ctx context.Context, userId string) ([]Hint, error) {
+	return append([]Hint{}, m.UserHints[userId]...), nil
+}
+
+func (m *MultiOrgInMemoryStore) MarkHintSeen(ctx context.Context, userId string, hint Hint) error {
+	if err := ValidateHint(hint); err != nil {
+		return err
+	}
+	if !slices.Contains(m.UserHints[userId], hint) {
+		m.UserHints[userId] = append(slices.Clone(m.UserHints[userId]), hint)
+	}
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) RecentProjects(ctx context.Context, orgId string, limit int) ([]Project, error) {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return []Project{}, ErrOrganizationNotFound
+	}
+	return SortRecentProjects(slices.Collect(maps.Values(store.Projects)), limit), nil
+}
+
+func (m *MultiOrgInMemoryStore) RecentResources(ctx context.Context, orgId string, limit int) ([]MetaData, error) {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return []MetaData{}, ErrOrganizationNotFound
+	}
+	return SortRecentResources(slices.Clone(store.Metadata), limit), nil
+}
+
+func (m *MultiOrgInMemoryStore) NumMembers(ctx context.Context, orgId string) (int, error) {
+	users, err := m.GetUsersInOrg(ctx, orgId)
+	return len(users), err
+}
+
+func (m *MultiOrgInMemoryStore) Onboarding(ctx context.Context, orgId string) (*Onboarding, error) {
+	onboarding, ok := m.OrgOnboarding[orgId]
+	if !ok {
+		return NewOnboarding(), onboardingNotFound(orgId)
+	}
+	onboarding.Completed = slices.Clone(onboarding.Completed)
+	onboarding.Instruments = slices.Clone(onboarding.Instruments)
+	return &onboarding, nil
+}
+
+func (m *MultiOrgInMemoryStore) SaveOnboarding(ctx context.Context, orgId string, onboarding *Onboarding) error {
+	m.OrgOnboarding[orgId] = *onboarding
+	return nil
 }
@@ -56,6 +56,13 @@ func TestMultiOrgErrorHandling(t *testing.T) {
 			desc:           "RemoveResource",
 			afterOrgRegErr: ErrProjectNotFound,
 		},
+		{
+			fn: func(orgId string) error {
+				return store.SetProjectNote(ctx, orgId, "someProject", "someResource", "note")
+			},
+			desc:           "SetProjectNote",
+			afterOrgRegErr: ErrProjectNotFound,
+		},
 		{
 			fn: func(orgId string) error {
 				_, err := store.MetaById(ctx, orgId, "someResourceId")
@@ -155,6 +162,49 @@ func TestMultiOrgClone(t *testing.T) {
 	}
 }
 
+// nonEmptyValue returns a value of type t where maps and slices hold one element
+func nonEmptyValue(t reflect.Type) reflect.Value {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return reflect.New(t.Elem())
+	case reflect.Slice:
+		return reflect.Append(reflect.MakeSlice(t, 0, 1), nonEmptyValue(t.Elem()))
+	case reflect.Map:
+		m := reflect.MakeMap(t)
+		m.SetMapIndex(reflect.ValueOf("key").Convert(t.Key()), nonEmptyValue(t.Elem()))
+		return m
+	}
+	return reflect.New(t).Elem()
+}
+
+// fillEmptyFields adds an element to every map and slice of the store that is empty, such that a field that is
+// not copied is noticed
+func fillEmptyFields(store *MultiOrgInMemoryStore) {
+	v := reflect.ValueOf(store).Elem()
+	for i := range v.NumField() {
+		if field := v.Field(i); field.Len() == 0 {
+			field.Set(nonEmptyValue(field.Type()))
+		}
+	}
+}
+
+func TestCloneAndReplaceWithCopyAllFields(t *testing.T) {
+	store := NewDemoStore()
+	fillEmptyFields(store)
+
+	replaced := NewMultiOrgInMemoryStore()
+	replaced.ReplaceWith(store)
+	for desc, copied := range map[string]*MultiOrgInMemoryStore{"Clone": store.Clone(), "ReplaceWith": replaced} {
+		want, got := reflect.ValueOf(store).Elem(), reflect.ValueOf(copied).Elem()
+		for i := range want.NumField() {
+			name := want.Type().Field(i).Name
+			if !reflect.DeepEqual(want.Field(i).Interface(), got.Field(i).Interface()) {
+				t.Errorf("%s does not copy %s", desc, name)
+			}
+		}
+	}
+}
+
 func TestGetUserInfo(t *testing.T) {
 	store := NewDemoStore()
 	ctx := context.Background()
@@ -403,7 +453,8 @@ func TestErrOnValidOrgButMissingResource(t *testing.T) {
 	if err == nil {
 		t.Fatal("Error should not be nil")
 	}
-	testutils.AssertContains(t, err.Error(), "Resource not")
+	testutils.AssertContains(t, err.Error(), "resource not found")
+	testutils.AssertEqual(t, errors.Is(err, ErrResourceNotFound), true)
 }
 
 func TestErrorOnTooShortPath(t *testing.T) {
@@ -0,0 +1,160 @@
+package pkg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// OIDCConfig configures sign in with an OpenID Connect provider such as Keycloak or Auth0
+type OIDCConfig struct {
+	// Name of the provider shown on the sign in page
+	Name string `yaml:"name"`
+
+	// The endpoints are read from <issuer>/.well-known/openid-configuration
+	Issuer       string   `yaml:"issuer"`
+	ClientId     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret" secret:"true"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+func (o *OIDCConfig) Enabled() bool {
+	return o.Issuer != ""
+}
+
+func (o *OIDCConfig) DisplayName() string {
+	if o.Name == "" {
+		return "single sign-on"
+	}
+	return o.Name
+}
+
+// OIDCDiscovery is the part of the provider metadata that is needed to sign in
+type OIDCDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// DiscoverOIDC fetches the metadata of an issuer. The issuer of the metadata must match the configured issuer
+func DiscoverOIDC(ctx context.Context, client *http.Client, issuer string) (*OIDCDiscovery, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Join(ErrOIDCDiscovery, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Join(ErrOIDCDiscovery, fmt.Errorf("issuer %s returned status %d", issuer, resp.StatusCode))
+	}
+
+	var discovery OIDCDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return nil, errors.Join(ErrOIDCDiscovery, err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != issuer {
+		return nil, errors.Join(ErrOIDCDiscovery, fmt.Errorf("metadata is for issuer %q, expected %q", discovery.Issuer, issuer))
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.UserinfoEndpoint == "" {
+		return nil, errors.Join(ErrOIDCDiscovery, fmt.Errorf("issuer %s is missing authorization, token or userinfo endpoint", issuer))
+	}
+	return &discovery, nil
+}
+
+// OIDCProvider discovers the endpoints of the issuer on first use, such that the server starts when the
+// issuer is unavailable. A failed discovery is retried on the next sign in
+type OIDCProvider struct {
+	Config    OIDCConfig
+	Transport http.RoundTripper
+
+	mu        sync.Mutex
+	discovery *OIDCDiscovery
+}
+
+func NewOIDCProvider(config OIDCConfig, transport http.RoundTripper) *OIDCProvider {
+	return &OIDCProvider{Config: config, Transport: transport}
+}
+
+func (o *OIDCProvider) Discover(ctx context.Context) (*OIDCDiscovery, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.discovery != nil {
+		return o.discovery, nil
+	}
+
+	discovery, err := DiscoverOIDC(ctx, &http.Client{Transport: o.Transport}, o.Config.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	o.discovery = discovery
+	return discovery, nil
+}
+
+// OAuthConfig returns the configuration of the code flow and the userinfo endpoint of the provider
+func (o *OIDCProvider) OAuthConfig(ctx context.Context) (*oauth2.Config, string, error) {
+	discovery, err := o.Discover(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	scopes := o.Config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	config := oauth2.Config{
+		ClientID:     o.Config.ClientId,
+		ClientSecret: o.Config.ClientSecret,
+		RedirectURL:  o.Config.RedirectURL,
+		Scopes:       scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  discovery.AuthorizationEndpoint,
+			TokenURL: discovery.TokenEndpoint,
+		},
+	}
+	return &config, discovery.UserinfoEndpoint, nil
+}
+
+// OIDCUserInfo holds the standard claims returned by the userinfo endpoint
+type OIDCUserInfo struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// ExternalUserId returns the id of the account of a user signed in with an identity provider. Subjects are only
+// unique for the issuer, hence the id is a hash of both such that a subject chosen by one issuer can not sign in
+// to the account of a user of another issuer
+func ExternalUserId(issuer, subject string) string {
+	sum := sha256.Sum256([]byte(issuer + "\x00" + subject))
+	return hex.EncodeToString(sum[:])
+}
+
+// UserInfo returns the user of the claims, where issuer is the issuer of the provider that returned them
+func (o *OIDCUserInfo) UserInfo(issuer string) UserInfo {
+	name := o.Name
+	if name == "" {
+		name = o.PreferredUsername
+	}
+	return UserInfo{
+		Id:            ExternalUserId(strings.TrimSuffix(issuer, "/"), o.Subject),
+		Email:         strings.ToLower(o.Email),
+		VerifiedEmail: o.EmailVerified,
+		Name:          name,
+	}
+}
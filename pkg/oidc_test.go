@@ -0,0 +1,124 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+// newOIDCIssuer serves the metadata of an issuer and counts the number of requests
+func newOIDCIssuer(t *testing.T, modify func(issuer string, d *OIDCDiscovery)) (*httptest.Server, *int) {
+	numRequests := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/realms/school/.well-known/openid-configuration" {
+			http.NotFound(w, r)
+			return
+		}
+		numRequests++
+		issuer := server.URL + "/realms/school"
+		discovery := OIDCDiscovery{
+			Issuer:                issuer,
+			AuthorizationEndpoint: issuer + "/protocol/openid-connect/auth",
+			TokenEndpoint:         issuer + "/protocol/openid-connect/token",
+			UserinfoEndpoint:      issuer + "/protocol/openid-connect/userinfo",
+		}
+		if modify != nil {
+			modify(issuer, &discovery)
+		}
+		json.NewEncoder(w).Encode(discovery)
+	}))
+	t.Cleanup(server.Close)
+	return server, &numRequests
+}
+
+func TestOIDCProviderOAuthConfig(t *testing.T) {
+	server, numRequests := newOIDCIssuer(t, nil)
+	issuer := server.URL + "/realms/school"
+	provider := NewOIDCProvider(OIDCConfig{Issuer: issuer + "/", ClientId: "caesura", ClientSecret: "secret"}, nil)
+
+	for range 2 {
+		config, userInfoURL, err := provider.OAuthConfig(context.Background())
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, config.Endpoint.AuthURL, issuer+"/protocol/openid-connect/auth")
+		testutils.AssertEqual(t, config.Endpoint.TokenURL, issuer+"/protocol/openid-connect/token")
+		testutils.AssertEqual(t, config.ClientID, "caesura")
+		testutils.AssertEqual(t, len(config.Scopes), 3)
+		testutils.AssertEqual(t, userInfoURL, issuer+"/protocol/openid-connect/userinfo")
+	}
+	testutils.AssertEqual(t, *numRequests, 1)
+}
+
+func TestOIDCProviderScopes(t *testing.T) {
+	server, _ := newOIDCIssuer(t, nil)
+	provider := NewOIDCProvider(OIDCConfig{Issuer: server.URL + "/realms/school", Scopes: []string{"openid", "email"}}, nil)
+	config, _, err := provider.OAuthConfig(context.Background())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(config.Scopes), 2)
+}
+
+func TestDiscoverOIDCInvalidMetadata(t *testing.T) {
+	for _, test := range []struct {
+		desc   string
+		modify func(issuer string, d *OIDCDiscovery)
+		path   string
+	}{
+		{desc: "other issuer", modify: func(issuer string, d *OIDCDiscovery) { d.Issuer = "https://evil.example.com" }, path: "/realms/school"},
+		{desc: "no userinfo", modify: func(issuer string, d *OIDCDiscovery) { d.UserinfoEndpoint = "" }, path: "/realms/school"},
+		{desc: "not found", path: "/realms/other"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			server, _ := newOIDCIssuer(t, test.modify)
+			_, err := DiscoverOIDC(context.Background(), server.Client(), server.URL+test.path)
+			testutils.AssertEqual(t, errors.Is(err, ErrOIDCDiscovery), true)
+		})
+	}
+}
+
+func TestOIDCProviderRetriesFailedDiscovery(t *testing.T) {
+	provider := NewOIDCProvider(OIDCConfig{Issuer: "http://127.0.0.1:0"}, nil)
+	_, _, err := provider.OAuthConfig(context.Background())
+	testutils.AssertEqual(t, errors.Is(err, ErrOIDCDiscovery), true)
+
+	server, _ := newOIDCIssuer(t, nil)
+	provider.Config.Issuer = server.URL + "/realms/school"
+	_, _, err = provider.OAuthConfig(context.Background())
+	testutils.AssertNil(t, err)
+}
+
+func TestOIDCUserInfo(t *testing.T) {
+	claims := OIDCUserInfo{Subject: "f3a9", Email: "Kari@School.no", EmailVerified: true, PreferredUsername: "kari"}
+	user := claims.UserInfo("https://auth.school.no/")
+	testutils.AssertEqual(t, user.Id, ExternalUserId("https://auth.school.no", "f3a9"))
+	testutils.AssertEqual(t, user.Email, "kari@school.no")
+	testutils.AssertEqual(t, user.VerifiedEmail, true)
+	testutils.AssertEqual(t, user.Name, "kari")
+}
+
+func TestExternalUserId(t *testing.T) {
+	id := ExternalUserId("https://auth.school.no", "f3a9")
+	testutils.AssertEqual(t, len(id), 64)
+	testutils.AssertEqual(t, id, ExternalUserId("https://auth.school.no", "f3a9"))
+
+	// The same subject of another issuer is another user
+	testutils.AssertEqual(t, id != ExternalUserId("https://auth.other.no", "f3a9"), true)
+	testutils.AssertEqual(t, id != ExternalUserId(AppleIssuer, "f3a9"), true)
+	testutils.AssertEqual(t, ExternalUserId("a", "bc") != ExternalUserId("ab", "c"), true)
+}
+
+func TestValidateOIDCConfig(t *testing.T) {
+	config := NewDefaultConfig()
+	config.OIDC.Issuer = "https://auth.school.no/realms/school"
+	if err := config.Validate(); err == nil {
+		t.Fatal("expected validation to fail for missing oidc.client_id")
+	}
+
+	config.OIDC.ClientId = "caesura"
+	testutils.AssertNil(t, config.Validate())
+	testutils.AssertEqual(t, config.OIDC.DisplayName(), "single sign-on")
+}
@@ -0,0 +1,87 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+type OnboardingStep string
+
+const (
+	OnboardingStepInstruments OnboardingStep = "instruments"
+	OnboardingStepInvite      OnboardingStep = "invite"
+	OnboardingStepUpload      OnboardingStep = "upload"
+	OnboardingStepEmail       OnboardingStep = "email"
+)
+
+// OnboardingSteps are the steps of the onboarding wizard in the order they are shown
+var OnboardingSteps = []OnboardingStep{
+	OnboardingStepInstruments,
+	OnboardingStepInvite,
+	OnboardingStepUpload,
+	OnboardingStepEmail,
+}
+
+// Onboarding is the progress of the admins of a new organization through the onboarding wizard. It is stored
+// such that the wizard can be resumed where it was left
+type Onboarding struct {
+	// Steps that are completed or skipped
+	Completed []OnboardingStep `json:"completed" firestore:"completed"`
+
+	// Instrument families played in the organization. Empty means all instruments
+	Instruments []string `json:"instruments" firestore:"instruments"`
+
+	// The admins chose to close the wizard before all steps were completed
+	Dismissed bool      `json:"dismissed" firestore:"dismissed"`
+	UpdatedAt time.Time `json:"updatedAt" firestore:"updatedAt"`
+}
+
+func NewOnboarding() *Onboarding {
+	return &Onboarding{Completed: []OnboardingStep{}, Instruments: []string{}, UpdatedAt: time.Now()}
+}
+
+// Current returns the first step that is not completed. False is returned when the wizard is finished or dismissed
+func (o *Onboarding) Current() (OnboardingStep, bool) {
+	if o.Dismissed {
+		return "", false
+	}
+	for _, step := range OnboardingSteps {
+		if !slices.Contains(o.Completed, step) {
+			return step, true
+		}
+	}
+	return "", false
+}
+
+// Complete marks the step as completed
+func (o *Onboarding) Complete(step OnboardingStep, at time.Time) error {
+	if err := ValidateOnboardingStep(step); err != nil {
+		return err
+	}
+	if !slices.Contains(o.Completed, step) {
+		o.Completed = append(o.Completed, step)
+	}
+	o.UpdatedAt = at
+	return nil
+}
+
+func ValidateOnboardingStep(step OnboardingStep) error {
+	if !slices.Contains(OnboardingSteps, step) {
+		return errors.Join(ErrInvalidOnboardingStep, fmt.Errorf("unknown step %q", step))
+	}
+	return nil
+}
+
+type OnboardingStore interface {
+	// Onboarding returns the progress through the onboarding wizard. ErrOnboardingNotFound is returned for
+	// organizations that were created before the wizard existed
+	Onboarding(ctx context.Context, orgId string) (*Onboarding, error)
+	SaveOnboarding(ctx context.Context, orgId string, onboarding *Onboarding) error
+}
+
+func onboardingNotFound(orgId string) error {
+	return errors.Join(ErrOnboardingNotFound, fmt.Errorf("organization id: %s", orgId))
+}
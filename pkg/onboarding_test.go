@@ -0,0 +1,74 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestOnboardingSteps(t *testing.T) {
+	onboarding := NewOnboarding()
+	step, ok := onboarding.Current()
+	testutils.AssertEqual(t, ok, true)
+	testutils.AssertEqual(t, step, OnboardingStepInstruments)
+
+	// Steps can be completed in any order, the first open step is the current one
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	testutils.AssertNil(t, onboarding.Complete(OnboardingStepInvite, at))
+	testutils.AssertNil(t, onboarding.Complete(OnboardingStepInstruments, at))
+	testutils.AssertNil(t, onboarding.Complete(OnboardingStepInstruments, at))
+	testutils.AssertEqual(t, len(onboarding.Completed), 2)
+	step, _ = onboarding.Current()
+	testutils.AssertEqual(t, step, OnboardingStepUpload)
+	testutils.AssertEqual(t, onboarding.UpdatedAt, at)
+
+	err := onboarding.Complete("unknown", at)
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidOnboardingStep), true)
+
+	testutils.AssertNil(t, onboarding.Complete(OnboardingStepUpload, at))
+	testutils.AssertNil(t, onboarding.Complete(OnboardingStepEmail, at))
+	_, ok = onboarding.Current()
+	testutils.AssertEqual(t, ok, false)
+
+	_, ok = (&Onboarding{Dismissed: true}).Current()
+	testutils.AssertEqual(t, ok, false)
+}
+
+func assertOnboardingStore(t *testing.T, store OnboardingStore) {
+	ctx := context.Background()
+	_, err := store.Onboarding(ctx, "org1")
+	testutils.AssertEqual(t, errors.Is(err, ErrOnboardingNotFound), true)
+
+	onboarding := NewOnboarding()
+	testutils.AssertNil(t, store.SaveOnboarding(ctx, "org1", onboarding))
+	testutils.AssertNil(t, store.SaveOnboarding(ctx, "org2", NewOnboarding()))
+
+	onboarding.Instruments = []string{"brass", "percussion"}
+	onboarding.Dismissed = true
+	updatedAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	testutils.AssertNil(t, onboarding.Complete(OnboardingStepInstruments, updatedAt))
+	testutils.AssertNil(t, store.SaveOnboarding(ctx, "org1", onboarding))
+
+	stored, err := store.Onboarding(ctx, "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(stored.Completed), 1)
+	testutils.AssertEqual(t, stored.Completed[0], OnboardingStepInstruments)
+	testutils.AssertEqual(t, len(stored.Instruments), 2)
+	testutils.AssertEqual(t, stored.Dismissed, true)
+	testutils.AssertEqual(t, stored.UpdatedAt.Equal(updatedAt), true)
+
+	other, err := store.Onboarding(ctx, "org2")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(other.Completed), 0)
+}
+
+func TestInMemoryOnboarding(t *testing.T) {
+	assertOnboardingStore(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGoogleOnboarding(t *testing.T) {
+	assertOnboardingStore(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
@@ -0,0 +1,195 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+)
+
+type OrphanKind string
+
+const (
+	// Files in the bucket of a resource without metadata
+	OrphanFiles OrphanKind = "files"
+
+	// Metadata of a finished resource without any parts in the bucket
+	OrphanMetaData OrphanKind = "metadata"
+)
+
+type Orphan struct {
+	OrgId      string
+	ResourceId string
+	Kind       OrphanKind
+	Removed    bool
+}
+
+func (o Orphan) String() string {
+	missing := OrphanMetaData
+	if o.Kind == OrphanMetaData {
+		missing = OrphanFiles
+	}
+	return fmt.Sprintf("%s/%s: %s without %s", o.OrgId, o.ResourceId, o.Kind, missing)
+}
+
+// OrphanRemover finds and removes files and metadata that are left without each other. The remove methods check
+// again that the resource is orphaned, such that resources submitted since the check are kept
+type OrphanRemover interface {
+	// ResourceFiles returns the number of current parts of every resource with files in the bucket. Resources
+	// with only earlier versions have zero parts
+	ResourceFiles(ctx context.Context, orgId string) (map[string]int, error)
+	RemoveOrphanFiles(ctx context.Context, orgId string, resourceId string) error
+	RemoveOrphanMetaData(ctx context.Context, orgId string, resourceId string) error
+}
+
+// resourceIdFromObject returns the id of the resource an object below the organization belongs to, and whether
+// the object is one of its current parts
+func resourceIdFromObject(orgId, name string) (string, bool) {
+	rel := strings.TrimPrefix(name, orgId+"/")
+	if archived, ok := strings.CutPrefix(rel, versionsDir+"/"); ok {
+		resourceId, _, _ := strings.Cut(archived, "/")
+		return resourceId, false
+	}
+	resourceId, _, _ := strings.Cut(rel, "/")
+	return resourceId, true
+}
+
+func (g *GoogleStore) ResourceFiles(ctx context.Context, orgId string) (map[string]int, error) {
+	objects, err := g.listObjects(ctx, orgId+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]int)
+	for _, attrs := range objects {
+		resourceId, isPart := resourceIdFromObject(orgId, attrs.Name)
+		numParts := files[resourceId]
+		if isPart {
+			numParts++
+		}
+		files[resourceId] = numParts
+	}
+	return files, nil
+}
+
+func (g *GoogleStore) RemoveOrphanFiles(ctx context.Context, orgId, resourceId string) error {
+	_, err := g.MetaById(ctx, orgId, resourceId)
+	if err := orphanedFiles(err, resourceId); err != nil {
+		return err
+	}
+	return g.deleteFiles(ctx, orgId, resourceId)
+}
+
+func (g *GoogleStore) RemoveOrphanMetaData(ctx context.Context, orgId, resourceId string) error {
+	parts, err := g.listObjects(ctx, path.Join(orgId, resourceId)+"/")
+	if err := orphanedMetaData(len(parts), err, resourceId); err != nil {
+		return err
+	}
+	return g.FsClient.DeleteDoc(ctx, metaDataCollection, orgId, resourceId)
+}
+
+// orphanedFiles returns an error unless the lookup of the metadata showed that it does not exist
+func orphanedFiles(metaErr error, resourceId string) error {
+	if errors.Is(metaErr, ErrResourceMetadataNotFound) {
+		return nil
+	}
+	if metaErr != nil {
+		return metaErr
+	}
+	return errors.Join(ErrNotOrphaned, fmt.Errorf("resource %s has metadata", resourceId))
+}
+
+func orphanedMetaData(numParts int, err error, resourceId string) error {
+	if err != nil {
+		return err
+	}
+	if numParts > 0 {
+		return errors.Join(ErrNotOrphaned, fmt.Errorf("resource %s has %d parts", resourceId, numParts))
+	}
+	return nil
+}
+
+type OrphanStore interface {
+	OrganizationLister
+	MetaByPatternFetcher
+	OrphanRemover
+}
+
+// OrphanCheck compares the files in the bucket with the metadata of each organization. Pending and failed
+// resources are left to the PendingSubmitReconciler
+type OrphanCheck struct {
+	Store  OrphanStore
+	Remove bool
+}
+
+// Run returns the orphans that were found, and removes them when Remove is set
+func (o *OrphanCheck) Run(ctx context.Context) ([]Orphan, error) {
+	orgs, err := o.Store.ListOrganizations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []Orphan
+	for _, org := range orgs {
+		found, orgErr := o.checkOrganization(ctx, org.Id)
+		orphans = append(orphans, found...)
+		err = errors.Join(err, orgErr)
+	}
+	return orphans, err
+}
+
+func (o *OrphanCheck) checkOrganization(ctx context.Context, orgId string) ([]Orphan, error) {
+	metas, err := o.Store.MetaByPattern(ctx, orgId, &MetaData{})
+	if err != nil {
+		return nil, err
+	}
+	files, err := o.Store.ResourceFiles(ctx, orgId)
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []Orphan
+	known := make(map[string]bool)
+	for _, meta := range metas {
+		resourceId := meta.ResourceId()
+		known[resourceId] = true
+		if files[resourceId] == 0 && meta.Status != StoreStatusPending && meta.Status != StoreStatusFailed {
+			orphans = append(orphans, Orphan{OrgId: orgId, ResourceId: resourceId, Kind: OrphanMetaData})
+		}
+	}
+	for resourceId := range files {
+		if !known[resourceId] {
+			orphans = append(orphans, Orphan{OrgId: orgId, ResourceId: resourceId, Kind: OrphanFiles})
+		}
+	}
+	slices.SortFunc(orphans, func(a, b Orphan) int { return strings.Compare(a.ResourceId, b.ResourceId) })
+
+	if !o.Remove {
+		return orphans, nil
+	}
+	for i, orphan := range orphans {
+		remove := o.Store.RemoveOrphanMetaData
+		if orphan.Kind == OrphanFiles {
+			remove = o.Store.RemoveOrphanFiles
+		}
+		removeErr := remove(ctx, orgId, orphan.ResourceId)
+		if errors.Is(removeErr, ErrNotOrphaned) {
+			// Submitted after the check started
+			continue
+		}
+		if removeErr != nil {
+			err = errors.Join(err, removeErr)
+			continue
+		}
+		orphans[i].Removed = true
+		slog.InfoContext(ctx, "Removed orphan", "orgId", orgId, "resourceId", orphan.ResourceId, "kind", orphan.Kind)
+	}
+	return orphans, err
+}
+
+func NewOrphanCheck(store OrphanStore, remove bool) *OrphanCheck {
+	return &OrphanCheck{Store: store, Remove: remove}
+}
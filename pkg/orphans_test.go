@@ -0,0 +1,88 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestOrphanCheck(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &Organization{Id: "org"}))
+
+	data := store.Data["org"]
+	data.Metadata = []MetaData{
+		{Title: "Kept", Status: StoreStatusFinished},
+		{Title: "Missing files", Status: StoreStatusFinished},
+		{Title: "Uploading", Status: StoreStatusPending},
+	}
+	data.Data["kept/Horn.pdf"] = []byte("horn")
+	data.Data["deleted/Horn.pdf"] = []byte("horn")
+
+	orphans, err := NewOrphanCheck(store, false).Run(ctx)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(orphans), 2)
+	testutils.AssertEqual(t, orphans[0], Orphan{OrgId: "org", ResourceId: "deleted", Kind: OrphanFiles})
+	testutils.AssertEqual(t, orphans[1], Orphan{OrgId: "org", ResourceId: "missingfiles", Kind: OrphanMetaData})
+	testutils.AssertEqual(t, orphans[0].String(), "org/deleted: files without metadata")
+	testutils.AssertEqual(t, len(data.Metadata), 3)
+	testutils.AssertEqual(t, len(data.Data), 2)
+
+	orphans, err = NewOrphanCheck(store, true).Run(ctx)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(orphans), 2)
+	testutils.AssertEqual(t, orphans[0].Removed && orphans[1].Removed, true)
+	testutils.AssertEqual(t, len(data.Metadata), 2)
+	testutils.AssertEqual(t, len(data.Data), 1)
+
+	orphans, err = NewOrphanCheck(store, true).Run(ctx)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(orphans), 0)
+}
+
+func TestGoogleStoreOrphans(t *testing.T) {
+	store := GoogleStore{
+		FsClient:     NewLocalFirestoreClient(),
+		BucketClient: &FileBucketClient{Directory: t.TempDir()},
+		Config:       &GoogleConfig{Bucket: "scores"},
+	}
+	ctx := context.Background()
+	meta := MetaData{Title: "Polka"}
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, manifestParts))
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, manifestParts))
+	resourceId := meta.ResourceId()
+
+	files, err := store.ResourceFiles(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(files), 1)
+	testutils.AssertEqual(t, files[resourceId], 2)
+
+	err = store.RemoveOrphanFiles(ctx, "org", resourceId)
+	testutils.AssertEqual(t, errors.Is(err, ErrNotOrphaned), true)
+	err = store.RemoveOrphanMetaData(ctx, "org", resourceId)
+	testutils.AssertEqual(t, errors.Is(err, ErrNotOrphaned), true)
+
+	testutils.AssertNil(t, store.FsClient.DeleteDoc(ctx, metaDataCollection, "org", resourceId))
+	testutils.AssertNil(t, store.RemoveOrphanFiles(ctx, "org", resourceId))
+	files, err = store.ResourceFiles(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(files), 0)
+}
+
+func TestResourceIdFromObject(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		resourceId string
+		isPart     bool
+	}{
+		{"org/polka/Horn.pdf", "polka", true},
+		{"org/.versions/polka/1/Horn.pdf", "polka", false},
+	} {
+		resourceId, isPart := resourceIdFromObject("org", test.name)
+		testutils.AssertEqual(t, resourceId, test.resourceId)
+		testutils.AssertEqual(t, isPart, test.isPart)
+	}
+}
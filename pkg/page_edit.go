@@ -0,0 +1,130 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// PageEdit rotates and reorders the pages of a part. Pages are numbered from 1 as in the part before the edit
+type PageEdit struct {
+	// Order lists every page of the part once, in the order of the edited part. Empty keeps the order
+	Order []int `json:"order"`
+
+	// Rotate maps page numbers to a clockwise rotation in degrees, which must be a multiple of 90
+	Rotate map[int]int `json:"rotate"`
+}
+
+// Validate checks that the edit can be applied to a part with numPages pages. Leaving out or repeating pages is
+// not allowed, since the edit is meant to fix scans and not to remove music
+func (e *PageEdit) Validate(numPages int) error {
+	if len(e.Order) == 0 && len(e.Rotate) == 0 {
+		return errors.Join(ErrInvalidPageEdit, errors.New("the edit neither reorders nor rotates pages"))
+	}
+	if len(e.Order) > 0 {
+		errOrder := errors.Join(ErrInvalidPageEdit, fmt.Errorf("order must list each of the %d pages once", numPages))
+		if len(e.Order) != numPages {
+			return errOrder
+		}
+		for i, page := range slices.Sorted(slices.Values(e.Order)) {
+			if page != i+1 {
+				return errOrder
+			}
+		}
+	}
+	for page, degrees := range e.Rotate {
+		if page < 1 || page > numPages {
+			return errors.Join(ErrInvalidPageEdit, fmt.Errorf("page %d does not exist", page))
+		}
+		if degrees%90 != 0 {
+			return errors.Join(ErrInvalidPageEdit, fmt.Errorf("rotation of page %d must be a multiple of 90", page))
+		}
+	}
+	return nil
+}
+
+// EditPages writes the pdf with the edit applied to w
+func EditPages(w io.Writer, pdf io.ReadSeeker, edit *PageEdit) error {
+	ctx, err := api.ReadValidateAndOptimize(pdf, model.NewDefaultConfiguration())
+	if err != nil {
+		return errors.Join(ErrInvalidPageEdit, fmt.Errorf("could not read pdf: %w", err))
+	}
+	if err := edit.Validate(ctx.PageCount); err != nil {
+		return err
+	}
+
+	// Pages rotated by the same angle are rotated together
+	byRotation := make(map[int]types.IntSet)
+	for page, degrees := range edit.Rotate {
+		degrees = (degrees%360 + 360) % 360
+		if degrees == 0 {
+			continue
+		}
+		if byRotation[degrees] == nil {
+			byRotation[degrees] = make(types.IntSet)
+		}
+		byRotation[degrees][page] = true
+	}
+	for _, degrees := range slices.Sorted(maps.Keys(byRotation)) {
+		if err := pdfcpu.RotatePages(ctx, byRotation[degrees], degrees); err != nil {
+			return err
+		}
+	}
+
+	if len(edit.Order) > 0 {
+		if ctx, err = pdfcpu.ExtractPages(ctx, edit.Order, false); err != nil {
+			return err
+		}
+	}
+	return api.WriteContext(ctx, w)
+}
+
+type PartEditStore interface {
+	ResourceGetter
+	Submitter
+}
+
+// EditPart applies the edit to the part of the resource. The resource is submitted again with the edited part,
+// such that the parts before the edit are kept as a version and can be restored
+func EditPart(ctx context.Context, store PartEditStore, orgId, resourceId, name string, edit *PageEdit) error {
+	if !strings.HasSuffix(strings.ToLower(name), ".pdf") {
+		return errors.Join(ErrInvalidPageEdit, fmt.Errorf("%s is not a pdf file", name))
+	}
+	meta, err := store.MetaById(ctx, orgId, resourceId)
+	if err != nil {
+		return err
+	}
+
+	var (
+		content []byte
+		found   bool
+	)
+	for partName, data := range store.Resource(ctx, orgId, resourceId) {
+		if partName == name {
+			content, found = data, true
+			break
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !found {
+		return errors.Join(ErrFileNotFound, fmt.Errorf("resource id: %s file: %s", resourceId, name))
+	}
+
+	var edited bytes.Buffer
+	if err := EditPages(&edited, bytes.NewReader(content), edit); err != nil {
+		return err
+	}
+	return store.Submit(ctx, orgId, meta, func(yield func(string, []byte) bool) { yield(name, edited.Bytes()) })
+}
@@ -0,0 +1,101 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+func pageRotation(t *testing.T, pdf []byte, page int) int {
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(pdf), model.NewDefaultConfiguration())
+	testutils.AssertNil(t, err)
+	_, _, inherited, err := ctx.PageDict(page, false)
+	testutils.AssertNil(t, err)
+	return inherited.Rotate
+}
+
+func TestPageEditValidate(t *testing.T) {
+	for _, test := range []struct {
+		desc string
+		edit PageEdit
+	}{
+		{"empty", PageEdit{}},
+		{"missing page", PageEdit{Order: []int{2, 1}}},
+		{"repeated page", PageEdit{Order: []int{1, 1, 2}}},
+		{"unknown page", PageEdit{Order: []int{1, 2, 4}}},
+		{"rotate unknown page", PageEdit{Rotate: map[int]int{4: 90}}},
+		{"rotate by 45", PageEdit{Rotate: map[int]int{1: 45}}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			testutils.AssertEqual(t, errors.Is(test.edit.Validate(3), ErrInvalidPageEdit), true)
+		})
+	}
+
+	valid := PageEdit{Order: []int{3, 1, 2}, Rotate: map[int]int{2: -90}}
+	testutils.AssertNil(t, valid.Validate(3))
+}
+
+func TestEditPages(t *testing.T) {
+	var pdf bytes.Buffer
+	testutils.AssertNil(t, CreateNPagePdf(&pdf, 3))
+
+	var edited bytes.Buffer
+	edit := PageEdit{Order: []int{3, 1, 2}, Rotate: map[int]int{1: 180, 2: -90}}
+	testutils.AssertNil(t, EditPages(&edited, bytes.NewReader(pdf.Bytes()), &edit))
+
+	text, err := ExtractPDFText(edited.Bytes())
+	testutils.AssertNil(t, err)
+	pages := strings.Split(strings.TrimSpace(text), "\n")
+	testutils.AssertEqual(t, len(pages), 3)
+	testutils.AssertContains(t, pages[0], "page 3")
+	testutils.AssertContains(t, pages[1], "page 1")
+
+	// Rotations refer to the pages before they are reordered
+	testutils.AssertEqual(t, pageRotation(t, edited.Bytes(), 1), 0)
+	testutils.AssertEqual(t, pageRotation(t, edited.Bytes(), 2), 180)
+	testutils.AssertEqual(t, pageRotation(t, edited.Bytes(), 3), 270)
+}
+
+func TestEditPart(t *testing.T) {
+	ctx := context.Background()
+	store := NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &Organization{Id: "org1"}))
+
+	var pdf bytes.Buffer
+	testutils.AssertNil(t, CreateNPagePdf(&pdf, 2))
+	meta := MetaData{Title: "Brandenburg Concerto", Composer: "Bach"}
+	parts := map[string][]byte{"Flute.pdf": pdf.Bytes(), "notes.txt": []byte("notes")}
+	testutils.AssertNil(t, store.Submit(ctx, "org1", &meta, maps.All(parts)))
+	resourceId := meta.ResourceId()
+
+	edit := PageEdit{Rotate: map[int]int{1: 90}}
+	testutils.AssertNil(t, EditPart(ctx, store, "org1", resourceId, "Flute.pdf", &edit))
+
+	current := maps.Collect(store.Resource(ctx, "org1", resourceId))
+	testutils.AssertEqual(t, len(current), 2)
+	testutils.AssertEqual(t, pageRotation(t, current["Flute.pdf"], 1), 90)
+
+	// The part before the edit is kept as a version
+	versions, err := store.ResourceVersions(ctx, "org1", resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(versions), 1)
+	previous := maps.Collect(store.ResourceVersion(ctx, "org1", resourceId, 1))
+	testutils.AssertEqual(t, pageRotation(t, previous["Flute.pdf"], 1), 0)
+
+	t.Run("missing part", func(t *testing.T) {
+		err := EditPart(ctx, store, "org1", resourceId, "Oboe.pdf", &edit)
+		testutils.AssertEqual(t, errors.Is(err, ErrFileNotFound), true)
+	})
+
+	t.Run("not a pdf", func(t *testing.T) {
+		err := EditPart(ctx, store, "org1", resourceId, "notes.txt", &edit)
+		testutils.AssertEqual(t, errors.Is(err, ErrInvalidPageEdit), true)
+	})
+}
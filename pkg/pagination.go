@@ -0,0 +1,327 @@
+package pkg
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// PageRequest selects a page of a listing. Cursor is the NextCursor of the previous page, and an empty cursor
+// starts at the beginning
+type PageRequest struct {
+	Limit  int
+	Cursor string
+}
+
+// NewPageRequest limits the page size to MaxPageSize. Sizes that are not positive get the default size
+func NewPageRequest(limit int, cursor string) PageRequest {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	return PageRequest{Limit: min(limit, MaxPageSize), Cursor: cursor}
+}
+
+// CursorPage is a part of a listing. NextCursor is empty on the last page
+type CursorPage[T any] struct {
+	Items      []T
+	NextCursor string
+}
+
+// MetaByPatternPager lists the metadata matching the pattern in the order of sort. Resources in the trash are
+// included, such that a page may have fewer visible resources than the limit
+type MetaByPatternPager interface {
+	MetaByPatternPage(ctx context.Context, orgId string, pattern *MetaData, sort MetaSort, page PageRequest) (CursorPage[MetaData], error)
+}
+
+type MetaSortField string
+
+const (
+	SortByResourceId MetaSortField = ""
+	SortByTitle      MetaSortField = "title"
+	SortByComposer   MetaSortField = "composer"
+	SortByArranger   MetaSortField = "arranger"
+
+	// SortByUpdated orders by the time the resource was last submitted
+	SortByUpdated MetaSortField = "updated"
+)
+
+// Times in cursors have a fixed width, such that they compare like the times they represent. Microseconds is the
+// precision of both firestore and postgres, and postgres rounds finer times to the nearest microsecond
+const cursorTimeFormat = "2006-01-02T15:04:05.000000Z"
+
+// MetaSort orders a listing of metadata by a field, and by resource id among resources with the same value
+type MetaSort struct {
+	Field      MetaSortField
+	Descending bool
+}
+
+// ParseMetaSort reads the sort and direction parameters of a request. Direction is asc or desc, and empty values
+// give the default order by resource id
+func ParseMetaSort(field, direction string) (MetaSort, error) {
+	sort := MetaSort{Field: MetaSortField(field)}
+	switch sort.Field {
+	case SortByResourceId, SortByTitle, SortByComposer, SortByArranger, SortByUpdated:
+	default:
+		return sort, errors.Join(ErrInvalidSort, fmt.Errorf("unknown sort %q", field))
+	}
+	switch direction {
+	case "", "asc":
+	case "desc":
+		sort.Descending = true
+	default:
+		return sort, errors.Join(ErrInvalidSort, fmt.Errorf("unknown direction %q", direction))
+	}
+	return sort, nil
+}
+
+// keys are the values a resource is ordered by, which also make up the cursor of the next page
+func (s MetaSort) keys(meta *MetaData) []string {
+	switch s.Field {
+	case SortByTitle:
+		return []string{meta.Title, meta.ResourceId()}
+	case SortByComposer:
+		return []string{meta.Composer, meta.ResourceId()}
+	case SortByArranger:
+		return []string{meta.Arranger, meta.ResourceId()}
+	case SortByUpdated:
+		return []string{meta.SubmittedAt.UTC().Round(time.Microsecond).Format(cursorTimeFormat), meta.ResourceId()}
+	default:
+		return metaPageKeys(meta)
+	}
+}
+
+func (s MetaSort) numKeys() int {
+	return len(s.keys(&MetaData{}))
+}
+
+// docField is the name of the field in the stored documents
+func (s MetaSort) docField() string {
+	if s.Field == SortByUpdated {
+		return "submitted_at"
+	}
+	return string(s.Field)
+}
+
+// docOrder starts a page of documents after the keys of a cursor
+func (s MetaSort) docOrder(after []string) (DocOrder, error) {
+	order := DocOrder{Field: s.docField(), Descending: s.Descending}
+	if after == nil {
+		return order, nil
+	}
+	if s.Field == SortByResourceId {
+		order.After = []any{after[0]}
+		return order, nil
+	}
+	var value any = after[0]
+	if s.Field == SortByUpdated {
+		submittedAt, err := time.Parse(cursorTimeFormat, after[0])
+		if err != nil {
+			return order, errors.Join(ErrInvalidCursor, err)
+		}
+		value = submittedAt
+	}
+	order.After = []any{value, after[1]}
+	return order, nil
+}
+
+// UsersInOrgPager lists the members of the organization ordered by name
+type UsersInOrgPager interface {
+	UsersInOrgPage(ctx context.Context, orgId string, page PageRequest) (CursorPage[UserInfo], error)
+}
+
+// Cursors are opaque to clients, such that the keys a listing is ordered by can change
+func encodeCursor(keys ...string) string {
+	data, _ := json.Marshal(keys)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string, numKeys int) ([]string, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	var keys []string
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		err = json.Unmarshal(data, &keys)
+	}
+	if err != nil || len(keys) != numKeys {
+		return nil, errors.Join(ErrInvalidCursor, fmt.Errorf("cursor %q", cursor))
+	}
+	return keys, nil
+}
+
+// pageSlice returns the items ordered after the cursor. The cursor of the next page is made from the keys of the
+// last item, and is only set when more items follow
+func pageSlice[T any](items []T, page PageRequest, keys func(*T) []string, descending bool) (CursorPage[T], error) {
+	compare := slices.Compare[[]string]
+	if descending {
+		compare = func(a, b []string) int { return slices.Compare(b, a) }
+	}
+	items = slices.Clone(items)
+	slices.SortStableFunc(items, func(a, b T) int { return compare(keys(&a), keys(&b)) })
+
+	start := 0
+	if page.Cursor != "" {
+		after, err := decodeCursor(page.Cursor, len(keys(new(T))))
+		if err != nil {
+			return CursorPage[T]{Items: []T{}}, err
+		}
+		start, _ = slices.BinarySearchFunc(items, after, func(item T, target []string) int {
+			if c := compare(keys(&item), target); c != 0 {
+				return c
+			}
+			// Items equal to the cursor were on the previous page
+			return -1
+		})
+	}
+
+	end := min(start+page.Limit, len(items))
+	result := CursorPage[T]{Items: items[start:end]}
+	if end < len(items) && end > start {
+		result.NextCursor = encodeCursor(keys(&items[end-1])...)
+	}
+	return result, nil
+}
+
+func metaPageKeys(meta *MetaData) []string {
+	return []string{meta.ResourceId()}
+}
+
+// userPageKeys orders users by name without regard to case, and by id among users with the same name
+func userPageKeys(user *UserInfo) []string {
+	return []string{strings.ToLower(user.Name), user.Id}
+}
+
+func PageMetaData(metaData []MetaData, sort MetaSort, page PageRequest) (CursorPage[MetaData], error) {
+	return pageSlice(metaData, page, sort.keys, sort.Descending)
+}
+
+func PageUsers(users []UserInfo, page PageRequest) (CursorPage[UserInfo], error) {
+	return pageSlice(users, page, userPageKeys, false)
+}
+
+// pageOf returns the first limit items. One more item than the limit is fetched to tell whether a next page exists
+func pageOf[T any](items []T, limit int, keys func(*T) []string) CursorPage[T] {
+	if len(items) <= limit {
+		return CursorPage[T]{Items: items}
+	}
+	return CursorPage[T]{Items: items[:limit], NextCursor: encodeCursor(keys(&items[limit-1])...)}
+}
+
+func isEmptyPattern(pattern *MetaData) bool {
+	return pattern.Title == "" && pattern.Composer == "" && pattern.Arranger == ""
+}
+
+// MetaByPatternPage starts after the cursor in firestore when all resources are listed. Ordering by a single field
+// and the document id only needs the indexes firestore creates for every field, so no composite index is needed.
+// The prefix queries used for searches can not be ordered by another field, so the matches are paged in memory
+func (g *GoogleStore) MetaByPatternPage(ctx context.Context, orgId string, pattern *MetaData, sort MetaSort, page PageRequest) (CursorPage[MetaData], error) {
+	pager, canOrder := g.FsClient.(OrderedDocPager)
+	if !isEmptyPattern(pattern) || (sort != MetaSort{} && !canOrder) {
+		metaData, err := g.MetaByPattern(ctx, orgId, pattern)
+		if err != nil {
+			return CursorPage[MetaData]{Items: []MetaData{}}, err
+		}
+		return PageMetaData(metaData, sort, page)
+	}
+
+	after, err := decodeCursor(page.Cursor, sort.numKeys())
+	if err != nil {
+		return CursorPage[MetaData]{Items: []MetaData{}}, err
+	}
+
+	var docs iter.Seq[Document]
+	if sort == (MetaSort{}) {
+		var startAfter string
+		if after != nil {
+			startAfter = after[0]
+		}
+		docs = g.FsClient.GetDocPage(ctx, metaDataCollection, orgId, startAfter, page.Limit+1)
+	} else {
+		order, err := sort.docOrder(after)
+		if err != nil {
+			return CursorPage[MetaData]{Items: []MetaData{}}, err
+		}
+		docs = pager.GetOrderedDocPage(ctx, metaDataCollection, orgId, order, page.Limit+1)
+	}
+
+	collector := NewValidCollector[MetaData]()
+	for doc := range docs {
+		collector.Push(doc)
+	}
+	return pageOf(collector.Items, page.Limit, sort.keys), nil
+}
+
+func (p *PostgresStore) MetaByPatternPage(ctx context.Context, orgId string, pattern *MetaData, sort MetaSort, page PageRequest) (CursorPage[MetaData], error) {
+	after, err := decodeCursor(page.Cursor, sort.numKeys())
+	if err != nil {
+		return CursorPage[MetaData]{Items: []MetaData{}}, err
+	}
+
+	query, args := metaSearchPageQuery(orgId, pattern, sort, after, page.Limit+1)
+	metaData, err := p.queryMetaData(ctx, query, args...)
+	if err != nil {
+		return CursorPage[MetaData]{Items: []MetaData{}}, err
+	}
+	return pageOf(metaData, page.Limit, sort.keys), nil
+}
+
+func (m *MultiOrgInMemoryStore) MetaByPatternPage(ctx context.Context, orgId string, pattern *MetaData, sort MetaSort, page PageRequest) (CursorPage[MetaData], error) {
+	metaData, err := m.MetaByPattern(ctx, orgId, pattern)
+	if err != nil {
+		return CursorPage[MetaData]{Items: []MetaData{}}, err
+	}
+	return PageMetaData(metaData, sort, page)
+}
+
+// UsersInOrgPage pages the members in memory, since the membership links are not ordered by name in firestore
+func (g *GoogleStore) UsersInOrgPage(ctx context.Context, orgId string, page PageRequest) (CursorPage[UserInfo], error) {
+	users, err := g.GetUsersInOrg(ctx, orgId)
+	if err != nil {
+		return CursorPage[UserInfo]{Items: []UserInfo{}}, err
+	}
+	return PageUsers(users, page)
+}
+
+func (p *PostgresStore) UsersInOrgPage(ctx context.Context, orgId string, page PageRequest) (CursorPage[UserInfo], error) {
+	after, err := decodeCursor(page.Cursor, 2)
+	if err != nil {
+		return CursorPage[UserInfo]{Items: []UserInfo{}}, err
+	}
+
+	query := `SELECT u.id, u.name, u.email, m.role, m.groups FROM memberships m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.org_id = $1 AND NOT m.deleted`
+	args := []any{orgId}
+	if after != nil {
+		query += " AND (lower(u.name), u.id) > ($2, $3)"
+		args = append(args, after[0], after[1])
+	}
+	args = append(args, page.Limit+1)
+	query += fmt.Sprintf(" ORDER BY lower(u.name), u.id LIMIT $%d", len(args))
+
+	users, err := p.queryMembers(ctx, orgId, query, args...)
+	if err != nil {
+		return CursorPage[UserInfo]{Items: []UserInfo{}}, err
+	}
+	return pageOf(users, page.Limit, userPageKeys), nil
+}
+
+func (m *MultiOrgInMemoryStore) UsersInOrgPage(ctx context.Context, orgId string, page PageRequest) (CursorPage[UserInfo], error) {
+	users, err := m.GetUsersInOrg(ctx, orgId)
+	if err != nil {
+		return CursorPage[UserInfo]{Items: []UserInfo{}}, err
+	}
+	return PageUsers(users, page)
+}
@@ -0,0 +1,250 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+// collectPages follows the cursors until the last page and returns the items of all pages
+func collectPages[T any](t *testing.T, limit int, fetch func(page PageRequest) (CursorPage[T], error)) ([]T, int) {
+	t.Helper()
+	var items []T
+	numPages := 0
+	page := NewPageRequest(limit, "")
+	for {
+		result, err := fetch(page)
+		testutils.AssertNil(t, err)
+		numPages++
+		items = append(items, result.Items...)
+		if result.NextCursor == "" {
+			return items, numPages
+		}
+		testutils.AssertEqual(t, len(result.Items), limit)
+		page.Cursor = result.NextCursor
+	}
+}
+
+func resourceIds(metaData []MetaData) string {
+	ids := make([]string, len(metaData))
+	for i, meta := range metaData {
+		ids[i] = meta.ResourceId()
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestNewPageRequest(t *testing.T) {
+	testutils.AssertEqual(t, NewPageRequest(0, "").Limit, DefaultPageSize)
+	testutils.AssertEqual(t, NewPageRequest(-1, "").Limit, DefaultPageSize)
+	testutils.AssertEqual(t, NewPageRequest(10, "").Limit, 10)
+	testutils.AssertEqual(t, NewPageRequest(10*MaxPageSize, "").Limit, MaxPageSize)
+}
+
+func TestPageMetaData(t *testing.T) {
+	metaData := []MetaData{{Title: "E"}, {Title: "C"}, {Title: "A"}, {Title: "D"}, {Title: "B"}}
+	for _, test := range []struct {
+		limit    int
+		numPages int
+	}{
+		{limit: 1, numPages: 5},
+		{limit: 2, numPages: 3},
+		{limit: 5, numPages: 1},
+		{limit: 10, numPages: 1},
+	} {
+		t.Run(fmt.Sprintf("limit %d", test.limit), func(t *testing.T) {
+			items, numPages := collectPages(t, test.limit, func(page PageRequest) (CursorPage[MetaData], error) {
+				return PageMetaData(metaData, MetaSort{}, page)
+			})
+			testutils.AssertEqual(t, resourceIds(items), "a,b,c,d,e")
+			testutils.AssertEqual(t, numPages, test.numPages)
+		})
+	}
+
+	t.Run("cursor of a removed resource", func(t *testing.T) {
+		page, err := PageMetaData(metaData, MetaSort{}, PageRequest{Limit: 2, Cursor: encodeCursor("bb")})
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, resourceIds(page.Items), "c,d")
+	})
+}
+
+func TestPageUsersOrdersByName(t *testing.T) {
+	users := []UserInfo{{Id: "3", Name: "bob"}, {Id: "2", Name: "Alice"}, {Id: "1", Name: "Bob"}}
+	items, numPages := collectPages(t, 1, func(page PageRequest) (CursorPage[UserInfo], error) {
+		return PageUsers(users, page)
+	})
+	testutils.AssertEqual(t, numPages, 3)
+	var ids []string
+	for _, user := range items {
+		ids = append(ids, user.Id)
+	}
+	testutils.AssertEqual(t, strings.Join(ids, ","), "2,1,3")
+}
+
+func TestParseMetaSort(t *testing.T) {
+	sort, err := ParseMetaSort("", "")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, sort, MetaSort{})
+
+	sort, err = ParseMetaSort("composer", "desc")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, sort, MetaSort{Field: SortByComposer, Descending: true})
+
+	for _, params := range [][2]string{{"genre", ""}, {"title", "up"}} {
+		_, err := ParseMetaSort(params[0], params[1])
+		testutils.AssertEqual(t, errors.Is(err, ErrInvalidSort), true)
+	}
+}
+
+func TestPageMetaDataSorted(t *testing.T) {
+	metaData := []MetaData{
+		{Title: "Suite", Composer: "Holst", SubmittedAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{Title: "Bolero", Composer: "Ravel", SubmittedAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Title: "Jupiter", Composer: "Holst", SubmittedAt: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range []struct {
+		sort MetaSort
+		want string
+	}{
+		{sort: MetaSort{Field: SortByTitle}, want: "bolero_ravel,jupiter_holst,suite_holst"},
+		{sort: MetaSort{Field: SortByTitle, Descending: true}, want: "suite_holst,jupiter_holst,bolero_ravel"},
+		{sort: MetaSort{Field: SortByComposer}, want: "jupiter_holst,suite_holst,bolero_ravel"},
+		{sort: MetaSort{Field: SortByUpdated, Descending: true}, want: "suite_holst,jupiter_holst,bolero_ravel"},
+		{sort: MetaSort{Descending: true}, want: "suite_holst,jupiter_holst,bolero_ravel"},
+	} {
+		t.Run(fmt.Sprintf("%s desc=%t", test.sort.Field, test.sort.Descending), func(t *testing.T) {
+			items, numPages := collectPages(t, 1, func(page PageRequest) (CursorPage[MetaData], error) {
+				return PageMetaData(metaData, test.sort, page)
+			})
+			testutils.AssertEqual(t, resourceIds(items), test.want)
+			testutils.AssertEqual(t, numPages, 3)
+		})
+	}
+
+	_, err := PageMetaData(metaData, MetaSort{Field: SortByTitle}, PageRequest{Limit: 1, Cursor: encodeCursor("a")})
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidCursor), true)
+}
+
+func TestInvalidCursor(t *testing.T) {
+	for _, cursor := range []string{"not base64!", encodeCursor("a", "b"), "e30"} {
+		_, err := PageMetaData([]MetaData{{Title: "A"}}, MetaSort{}, PageRequest{Limit: 1, Cursor: cursor})
+		testutils.AssertEqual(t, errors.Is(err, ErrInvalidCursor), true)
+	}
+}
+
+func TestGetDocPage(t *testing.T) {
+	local, _ := newTestLocalStore(t)
+	for _, test := range []struct {
+		name   string
+		client FirestoreClient
+	}{
+		{name: "local", client: NewLocalFirestoreClient()},
+		{name: "sqlite", client: local.FsClient},
+		{name: "s3", client: &S3DocumentClient{Client: newFakeS3(), Bucket: "caesura"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, id := range []string{"org3", "org1", "org4", "org2"} {
+				org := Organization{Id: id, Name: "Band " + id}
+				testutils.AssertNil(t, test.client.StoreDocument(ctx, organizationCollection, organizationInfo, id, &org))
+			}
+
+			ids := func(after string, limit int) string {
+				var ids []string
+				for doc := range test.client.GetDocPage(ctx, organizationCollection, organizationInfo, after, limit) {
+					var org Organization
+					testutils.AssertNil(t, doc.DataTo(&org))
+					ids = append(ids, org.Id)
+				}
+				return strings.Join(ids, ",")
+			}
+			testutils.AssertEqual(t, ids("", 2), "org1,org2")
+			testutils.AssertEqual(t, ids("org2", 2), "org3,org4")
+			testutils.AssertEqual(t, ids("org4", 2), "")
+		})
+	}
+}
+
+func TestMetaByPatternPage(t *testing.T) {
+	ctx := context.Background()
+	googleStore := GoogleStore{FsClient: NewLocalFirestoreClient(), BucketClient: NewLocalBucketClient(), Config: NewTestConfig()}
+	memStore := NewMultiOrgInMemoryStore()
+	memStore.Data["org"] = NewInMemoryStore()
+
+	for _, test := range []struct {
+		name  string
+		store interface {
+			Submitter
+			MetaByPatternPager
+		}
+	}{
+		{name: "google", store: &googleStore},
+		{name: "in memory", store: memStore},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			for _, title := range []string{"Sonata", "Symphony 2", "Rondo", "Symphony 1", "Suite"} {
+				meta := MetaData{Title: title, Composer: "Mozart"}
+				testutils.AssertNil(t, test.store.Submit(ctx, "org", &meta, func(yield func(string, []byte) bool) {}))
+			}
+
+			for _, pattern := range []struct {
+				title string
+				want  string
+			}{
+				{title: "", want: "rondo_mozart,sonata_mozart,suite_mozart,symphony1_mozart,symphony2_mozart"},
+				{title: "sy", want: "symphony1_mozart,symphony2_mozart"},
+			} {
+				items, _ := collectPages(t, 2, func(page PageRequest) (CursorPage[MetaData], error) {
+					return test.store.MetaByPatternPage(ctx, "org", &MetaData{Title: pattern.title}, MetaSort{}, page)
+				})
+				testutils.AssertEqual(t, resourceIds(items), pattern.want)
+			}
+
+			for _, sorted := range []struct {
+				sort MetaSort
+				want string
+			}{
+				{sort: MetaSort{Field: SortByTitle, Descending: true}, want: "symphony2_mozart,symphony1_mozart,suite_mozart,sonata_mozart,rondo_mozart"},
+				{sort: MetaSort{Field: SortByComposer}, want: "rondo_mozart,sonata_mozart,suite_mozart,symphony1_mozart,symphony2_mozart"},
+				{sort: MetaSort{Descending: true}, want: "symphony2_mozart,symphony1_mozart,suite_mozart,sonata_mozart,rondo_mozart"},
+			} {
+				items, _ := collectPages(t, 2, func(page PageRequest) (CursorPage[MetaData], error) {
+					return test.store.MetaByPatternPage(ctx, "org", &MetaData{}, sorted.sort, page)
+				})
+				testutils.AssertEqual(t, resourceIds(items), sorted.want)
+			}
+		})
+	}
+}
+
+func TestGetOrderedDocPage(t *testing.T) {
+	ctx := context.Background()
+	client := NewLocalFirestoreClient()
+	for _, meta := range []MetaData{
+		{Title: "Suite", Composer: "Holst", SubmittedAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{Title: "Bolero", Composer: "Ravel", SubmittedAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Title: "Jupiter", Composer: "Holst", SubmittedAt: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		testutils.AssertNil(t, client.StoreDocument(ctx, metaDataCollection, "org", meta.ResourceId(), &meta))
+	}
+
+	ids := func(order DocOrder, limit int) string {
+		var metaData []MetaData
+		for doc := range client.GetOrderedDocPage(ctx, metaDataCollection, "org", order, limit) {
+			var meta MetaData
+			testutils.AssertNil(t, doc.DataTo(&meta))
+			metaData = append(metaData, meta)
+		}
+		return resourceIds(metaData)
+	}
+	testutils.AssertEqual(t, ids(DocOrder{Field: "composer"}, 2), "jupiter_holst,suite_holst")
+	testutils.AssertEqual(t, ids(DocOrder{Field: "composer", After: []any{"Holst", "suite_holst"}}, 2), "bolero_ravel")
+	testutils.AssertEqual(t, ids(DocOrder{Field: "submitted_at", Descending: true}, 3), "suite_holst,jupiter_holst,bolero_ravel")
+	after := []any{time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), "jupiter_holst"}
+	testutils.AssertEqual(t, ids(DocOrder{Field: "submitted_at", After: after}, 3), "suite_holst")
+	testutils.AssertEqual(t, ids(DocOrder{Descending: true, After: []any{"suite_holst"}}, 3), "jupiter_holst,bolero_ravel")
+}
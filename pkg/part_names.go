@@ -0,0 +1,315 @@
+package pkg
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// PartLister lists the current parts of all resources in an organization
+type PartLister interface {
+	// PartNames returns the file names of the current parts by resource id
+	PartNames(ctx context.Context, orgId string) (map[string][]string, error)
+}
+
+type PartNameIssueKind string
+
+const (
+	// The part name is close to, but not the same as, an instrument of the organization
+	PartNameTypo PartNameIssueKind = "typo"
+
+	// The part name only differs from an instrument by upper and lower case
+	PartNameCasing PartNameIssueKind = "casing"
+
+	// Numbered parts of an instrument skip a number
+	PartNameNumbering PartNameIssueKind = "numbering"
+)
+
+// PartNameIssue is a part name that is inconsistent with the instruments of the organization
+type PartNameIssue struct {
+	ResourceId string            `json:"resource_id"`
+	Title      string            `json:"title"`
+	Kind       PartNameIssueKind `json:"kind"`
+	Instrument string            `json:"instrument"`
+
+	// Part is the file name of the part. It is empty for gaps in the numbering, since no part is wrong
+	Part string `json:"part,omitempty"`
+
+	// Suggestion is the file name the part should be renamed to. It is empty when a rename can not fix the issue
+	Suggestion string `json:"suggestion,omitempty"`
+
+	// Missing are the numbers skipped in the numbering
+	Missing []int `json:"missing,omitempty"`
+}
+
+// PartRenameGroup is the same rename suggested in several resources, such that it can be applied in one go
+type PartRenameGroup struct {
+	Part        string            `json:"part"`
+	Suggestion  string            `json:"suggestion"`
+	Kind        PartNameIssueKind `json:"kind"`
+	ResourceIds []string          `json:"resource_ids"`
+}
+
+type PartNameReport struct {
+	NumResources int               `json:"num_resources"`
+	NumParts     int               `json:"num_parts"`
+	Issues       []PartNameIssue   `json:"issues"`
+	Renames      []PartRenameGroup `json:"renames"`
+}
+
+// The number of a part is the digits at the end of the name, like "Cornet 2" or "Cornet2"
+var numberedPart = regexp.MustCompile(`^(.*?)(\s*)(\d+)$`)
+
+type parsedPart struct {
+	file      string
+	base      string
+	separator string
+	number    int
+	numbered  bool
+}
+
+func parsePartName(file string) parsedPart {
+	part := parsedPart{file: file, base: strings.TrimSpace(file)}
+	if ext := path.Ext(part.base); strings.EqualFold(ext, ".pdf") {
+		part.base = strings.TrimSpace(strings.TrimSuffix(part.base, ext))
+	}
+	if match := numberedPart.FindStringSubmatch(part.base); match != nil && match[1] != "" {
+		number, err := strconv.Atoi(match[3])
+		if err == nil {
+			part.base, part.separator, part.number, part.numbered = match[1], match[2], number, true
+		}
+	}
+	return part
+}
+
+func (p parsedPart) renamed(instrument string) string {
+	if !p.numbered {
+		return instrument + ".pdf"
+	}
+	return instrument + p.separator + strconv.Itoa(p.number) + ".pdf"
+}
+
+// editDistance returns the number of characters that must be inserted, removed or replaced to turn a into b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+// maxTypoDistance is the largest edit distance to an instrument that is considered a typo. Short names only
+// allow one edit, such that names like Alto and Alt are not mixed up with other short names
+func maxTypoDistance(instrument string) int {
+	if len([]rune(instrument)) < 6 {
+		return 1
+	}
+	return 2
+}
+
+// matchInstrument returns the instrument the base of a part name refers to and the kind of issue with the name.
+// The kind is empty when the name is correct, and ok is false when the name does not look like any instrument
+func matchInstrument(base string, instruments []string) (instrument string, kind PartNameIssueKind, ok bool) {
+	if slices.Contains(instruments, base) {
+		return base, "", true
+	}
+	for _, candidate := range instruments {
+		if strings.EqualFold(candidate, base) {
+			return candidate, PartNameCasing, true
+		}
+	}
+	if len([]rune(base)) < 3 {
+		return "", "", false
+	}
+
+	best, bestDistance := "", 0
+	lowerBase := strings.ToLower(base)
+	for _, candidate := range instruments {
+		distance := editDistance(lowerBase, strings.ToLower(candidate))
+		if distance <= maxTypoDistance(candidate) && (best == "" || distance < bestDistance) {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best, PartNameTypo, best != ""
+}
+
+// lintResource checks the parts of one resource. Names already taken by a part are never suggested
+func lintResource(meta *MetaData, files []string, instruments []string) []PartNameIssue {
+	taken := make(map[string]bool, len(files))
+	for _, file := range files {
+		taken[file] = true
+	}
+
+	var issues []PartNameIssue
+	newIssue := func(kind PartNameIssueKind, instrument string) PartNameIssue {
+		return PartNameIssue{ResourceId: meta.ResourceId(), Title: meta.Title, Kind: kind, Instrument: instrument}
+	}
+
+	// Index of the issue of every misnamed part, such that a part gets at most one suggestion
+	misnamed := make(map[string]int)
+	numbers := make(map[string][]int)
+	unnumbered := make(map[string][]parsedPart)
+	for _, file := range files {
+		part := parsePartName(file)
+		instrument, kind, ok := matchInstrument(part.base, instruments)
+		if !ok {
+			continue
+		}
+
+		if kind != "" {
+			issue := newIssue(kind, instrument)
+			issue.Part = file
+			if suggestion := part.renamed(instrument); !taken[suggestion] {
+				issue.Suggestion = suggestion
+				taken[suggestion] = true
+			}
+			misnamed[file] = len(issues)
+			issues = append(issues, issue)
+		}
+
+		if part.numbered {
+			numbers[instrument] = append(numbers[instrument], part.number)
+		} else {
+			unnumbered[instrument] = append(unnumbered[instrument], part)
+		}
+	}
+
+	for _, instrument := range slices.Sorted(maps.Keys(numbers)) {
+		used := numbers[instrument]
+		// A single part without a number next to numbered parts is the first part, like Cornet and Cornet 2
+		if parts := unnumbered[instrument]; len(parts) == 1 && !slices.Contains(used, 1) {
+			suggestion := instrument + " 1.pdf"
+			if !taken[suggestion] {
+				taken[suggestion] = true
+				used = append(used, 1)
+				if i, ok := misnamed[parts[0].file]; ok {
+					delete(taken, issues[i].Suggestion)
+					issues[i].Suggestion = suggestion
+				} else {
+					issue := newIssue(PartNameNumbering, instrument)
+					issue.Part = parts[0].file
+					issue.Suggestion = suggestion
+					issues = append(issues, issue)
+				}
+			}
+		}
+
+		first := 1
+		if slices.Contains(used, 0) {
+			first = 0
+		}
+		var missing []int
+		for number := first; number < slices.Max(used); number++ {
+			if !slices.Contains(used, number) {
+				missing = append(missing, number)
+			}
+		}
+		if len(missing) > 0 {
+			issue := newIssue(PartNameNumbering, instrument)
+			issue.Missing = missing
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// LintPartNames checks the part names of the resources against the instruments of the organization. Resources in
+// the trash are skipped. Renames suggested in several resources are grouped, such that they can be applied at once
+func LintPartNames(metaData []MetaData, parts map[string][]string, instruments []string) *PartNameReport {
+	report := &PartNameReport{Issues: []PartNameIssue{}, Renames: []PartRenameGroup{}}
+	metaData = slices.Clone(metaData)
+	slices.SortStableFunc(metaData, func(a, b MetaData) int {
+		return cmp.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+	})
+
+	groups := make(map[[2]string]*PartRenameGroup)
+	for i := range metaData {
+		meta := &metaData[i]
+		files, ok := parts[meta.ResourceId()]
+		if meta.Deleted || !ok {
+			continue
+		}
+		report.NumResources++
+		report.NumParts += len(files)
+
+		for _, issue := range lintResource(meta, slices.Sorted(slices.Values(files)), instruments) {
+			report.Issues = append(report.Issues, issue)
+			if issue.Suggestion == "" {
+				continue
+			}
+			key := [2]string{issue.Part, issue.Suggestion}
+			group, ok := groups[key]
+			if !ok {
+				group = &PartRenameGroup{Part: issue.Part, Suggestion: issue.Suggestion, Kind: issue.Kind}
+				groups[key] = group
+			}
+			group.ResourceIds = append(group.ResourceIds, issue.ResourceId)
+		}
+	}
+
+	for _, group := range groups {
+		report.Renames = append(report.Renames, *group)
+	}
+	slices.SortFunc(report.Renames, func(a, b PartRenameGroup) int {
+		return cmp.Or(
+			cmp.Compare(len(b.ResourceIds), len(a.ResourceIds)),
+			cmp.Compare(a.Part, b.Part),
+			cmp.Compare(a.Suggestion, b.Suggestion),
+		)
+	})
+	return report
+}
+
+func (g *GoogleStore) PartNames(ctx context.Context, orgId string) (map[string][]string, error) {
+	objects, err := g.listObjects(ctx, orgId+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make(map[string][]string)
+	for _, attrs := range objects {
+		if resourceId, isPart := resourceIdFromObject(orgId, attrs.Name); isPart {
+			parts[resourceId] = append(parts[resourceId], path.Base(attrs.Name))
+		}
+	}
+	return parts, nil
+}
+
+func (p *PostgresStore) PartNames(ctx context.Context, orgId string) (map[string][]string, error) {
+	return p.blobs().PartNames(ctx, orgId)
+}
+
+func (s *InMemoryStore) PartNames(ctx context.Context) map[string][]string {
+	parts := make(map[string][]string)
+	for name := range s.Data {
+		resourceId, file, _ := strings.Cut(name, "/")
+		parts[resourceId] = append(parts[resourceId], file)
+	}
+	return parts
+}
+
+func (m *MultiOrgInMemoryStore) PartNames(ctx context.Context, orgId string) (map[string][]string, error) {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return nil, ErrOrganizationNotFound
+	}
+	return store.PartNames(ctx), nil
+}
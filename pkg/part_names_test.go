@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestParsePartName(t *testing.T) {
+	for _, test := range []struct {
+		file, base string
+		number     int
+		numbered   bool
+	}{
+		{"Cornet 2.pdf", "Cornet", 2, true},
+		{"Cornet2.PDF", "Cornet", 2, true},
+		{"Tuba.pdf", "Tuba", 0, false},
+		{"2.pdf", "2", 0, false},
+	} {
+		part := parsePartName(test.file)
+		testutils.AssertEqual(t, part.base, test.base)
+		testutils.AssertEqual(t, part.number, test.number)
+		testutils.AssertEqual(t, part.numbered, test.numbered)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	testutils.AssertEqual(t, editDistance("trumpt", "trumpet"), 1)
+	testutils.AssertEqual(t, editDistance("cornet", "cornet"), 0)
+	testutils.AssertEqual(t, editDistance("", "tuba"), 4)
+	testutils.AssertEqual(t, editDistance("flügel", "flugel"), 1)
+}
+
+func TestLintPartNames(t *testing.T) {
+	instruments := []string{"Trumpet", "Cornet", "Tuba", "Horn", "Conductor"}
+	metaData := []MetaData{
+		{Title: "Bolero"},
+		{Title: "Arabesque"},
+		{Title: "Trashed", Deleted: true},
+		{Title: "Without parts"},
+	}
+	parts := map[string][]string{
+		"bolero":    {"Trumpt 1.pdf", "Trumpet 2.pdf", "cornet 1.pdf", "Cornet 1.pdf", "Horn 1.pdf", "Horn 3.pdf", "Score.pdf"},
+		"arabesque": {"Trumpt 1.pdf", "Tuba.pdf", "Tuba 2.pdf", "conductor.pdf"},
+		"trashed":   {"Trumpt 1.pdf"},
+	}
+
+	report := LintPartNames(metaData, parts, instruments)
+	testutils.AssertEqual(t, report.NumResources, 2)
+	testutils.AssertEqual(t, report.NumParts, 11)
+
+	want := []PartNameIssue{
+		{ResourceId: "arabesque", Title: "Arabesque", Kind: PartNameTypo, Instrument: "Trumpet", Part: "Trumpt 1.pdf", Suggestion: "Trumpet 1.pdf"},
+		{ResourceId: "arabesque", Title: "Arabesque", Kind: PartNameCasing, Instrument: "Conductor", Part: "conductor.pdf", Suggestion: "Conductor.pdf"},
+		{ResourceId: "arabesque", Title: "Arabesque", Kind: PartNameNumbering, Instrument: "Tuba", Part: "Tuba.pdf", Suggestion: "Tuba 1.pdf"},
+		{ResourceId: "bolero", Title: "Bolero", Kind: PartNameTypo, Instrument: "Trumpet", Part: "Trumpt 1.pdf", Suggestion: "Trumpet 1.pdf"},
+		{ResourceId: "bolero", Title: "Bolero", Kind: PartNameCasing, Instrument: "Cornet", Part: "cornet 1.pdf"},
+		{ResourceId: "bolero", Title: "Bolero", Kind: PartNameNumbering, Instrument: "Horn", Missing: []int{2}},
+	}
+	testutils.AssertEqual(t, len(report.Issues), len(want))
+	for i, issue := range want {
+		testutils.AssertEqual(t, report.Issues[i].Kind, issue.Kind)
+		testutils.AssertEqual(t, report.Issues[i].ResourceId, issue.ResourceId)
+		testutils.AssertEqual(t, report.Issues[i].Part, issue.Part)
+		testutils.AssertEqual(t, report.Issues[i].Suggestion, issue.Suggestion)
+		testutils.AssertEqual(t, len(report.Issues[i].Missing), len(issue.Missing))
+	}
+
+	testutils.AssertEqual(t, len(report.Renames), 3)
+	testutils.AssertEqual(t, report.Renames[0].Part, "Trumpt 1.pdf")
+	testutils.AssertEqual(t, len(report.Renames[0].ResourceIds), 2)
+}
+
+func TestLintPartNamesNumbersTheFirstPart(t *testing.T) {
+	parts := map[string][]string{"bolero": {"cornet.pdf", "Cornet 2.pdf"}}
+	report := LintPartNames([]MetaData{{Title: "Bolero"}}, parts, []string{"Cornet"})
+	testutils.AssertEqual(t, len(report.Issues), 1)
+	testutils.AssertEqual(t, report.Issues[0].Kind, PartNameCasing)
+	testutils.AssertEqual(t, report.Issues[0].Suggestion, "Cornet 1.pdf")
+}
+
+func TestMultiOrgInMemoryStorePartNames(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	store.Data["org"] = NewInMemoryStore()
+	store.Data["org"].Data["bolero/Cornet.pdf"] = []byte("pdf")
+	parts, err := store.PartNames(context.Background(), "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, parts["bolero"][0], "Cornet.pdf")
+
+	_, err = store.PartNames(context.Background(), "unknown")
+	testutils.AssertEqual(t, err, ErrOrganizationNotFound)
+}
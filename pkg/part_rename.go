@@ -0,0 +1,142 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// PartRenamer renames a part of a resource. The parts before the rename are kept as a version, such that the
+// rename can be undone by restoring it
+type PartRenamer interface {
+	RenamePart(ctx context.Context, orgId, resourceId, name, newName string) error
+}
+
+// PartName cleans up a part name given by a user. The .pdf extension is added when it is left out
+func PartName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if !strings.HasSuffix(strings.ToLower(name), ".pdf") {
+		name += ".pdf"
+	}
+	base := strings.TrimSpace(name[:len(name)-len(".pdf")])
+	if base == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, "/\\") {
+		return "", errors.Join(ErrInvalidPartName, fmt.Errorf("%q is not a valid part name", name))
+	}
+	return name, nil
+}
+
+// RenamePart gives the part name of the resource the name newName and returns the cleaned up new name
+func RenamePart(ctx context.Context, store PartRenamer, orgId, resourceId, name, newName string) (string, error) {
+	newName, err := PartName(newName)
+	if err != nil {
+		return "", err
+	}
+	if newName == name {
+		return "", errors.Join(ErrInvalidPartName, fmt.Errorf("%s already has that name", name))
+	}
+	return newName, store.RenamePart(ctx, orgId, resourceId, name, newName)
+}
+
+// renamePart archives the current parts and moves the object of the part to the new name
+func (g *GoogleStore) renamePart(ctx context.Context, orgId, resourceId, name, newName string) error {
+	current, err := g.listObjects(ctx, path.Join(orgId, resourceId)+"/")
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, attrs := range current {
+		switch path.Base(attrs.Name) {
+		case name:
+			found = true
+		case newName:
+			return errors.Join(ErrPartExists, fmt.Errorf("resource id: %s file: %s", resourceId, newName))
+		}
+	}
+	if !found {
+		return errors.Join(ErrFileNotFound, fmt.Errorf("resource id: %s file: %s", resourceId, name))
+	}
+
+	content, err := g.Item(ctx, g.objectName(orgId, resourceId, name))
+	if err != nil {
+		return err
+	}
+	if _, err := g.archiveResource(ctx, orgId, resourceId); err != nil {
+		return fmt.Errorf("could not keep the previous version: %w", err)
+	}
+	if err := g.BucketClient.Upload(ctx, g.Config.Bucket, g.objectName(orgId, resourceId, newName), content); err != nil {
+		return err
+	}
+	err = g.BucketClient.Delete(ctx, g.Config.Bucket, g.objectName(orgId, resourceId, name))
+	if err != nil && !IsNotFound(classifyStoreErr(err, ErrResourceNotFound)) {
+		return err
+	}
+	return nil
+}
+
+func (g *GoogleStore) RenamePart(ctx context.Context, orgId, resourceId, name, newName string) error {
+	meta, err := g.MetaById(ctx, orgId, resourceId)
+	if err := visibleMeta(meta, err, resourceId); err != nil {
+		return err
+	}
+	if _, err := replaceable(meta, nil, resourceId); err != nil {
+		return err
+	}
+	if err := g.clearContentHash(ctx, orgId, resourceId); err != nil {
+		return err
+	}
+	return g.renamePart(ctx, orgId, resourceId, name, newName)
+}
+
+func (p *PostgresStore) RenamePart(ctx context.Context, orgId, resourceId, name, newName string) error {
+	meta, err := p.MetaById(ctx, orgId, resourceId)
+	if err := visibleMeta(meta, err, resourceId); err != nil {
+		return err
+	}
+	if _, err := replaceable(meta, nil, resourceId); err != nil {
+		return err
+	}
+	if err := p.updateMetaField(ctx, orgId, resourceId, "content_hash", ""); err != nil {
+		return err
+	}
+	return p.blobs().renamePart(ctx, orgId, resourceId, name, newName)
+}
+
+func (s *InMemoryStore) RenamePart(ctx context.Context, id, name, newName string) error {
+	meta, err := s.MetaById(ctx, id)
+	if err := visibleMeta(meta, err, id); err != nil {
+		return err
+	}
+	if _, err := replaceable(meta, nil, id); err != nil {
+		return err
+	}
+
+	content, ok := s.Data[id+"/"+name]
+	if !ok {
+		return errors.Join(ErrFileNotFound, fmt.Errorf("resource id: %s file: %s", id, name))
+	}
+	if _, exists := s.Data[id+"/"+newName]; exists {
+		return errors.Join(ErrPartExists, fmt.Errorf("resource id: %s file: %s", id, newName))
+	}
+
+	s.setContentHash(id, "")
+	s.archiveResource(id)
+	delete(s.Data, id+"/"+name)
+	delete(s.Modified, id+"/"+name)
+	if s.Modified == nil {
+		s.Modified = make(map[string]time.Time)
+	}
+	s.Data[id+"/"+newName] = content
+	s.Modified[id+"/"+newName] = time.Now()
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) RenamePart(ctx context.Context, orgId, resourceId, name, newName string) error {
+	store, ok := m.Data[orgId]
+	if !ok {
+		return ErrOrganizationNotFound
+	}
+	return store.RenamePart(ctx, resourceId, name, newName)
+}
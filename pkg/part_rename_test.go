@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestPartName(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		want string
+		err  bool
+	}{
+		{"Trumpet 1", "Trumpet 1.pdf", false},
+		{" Trumpet 1.PDF ", "Trumpet 1.PDF", false},
+		{"", "", true},
+		{".pdf", "", true},
+		{"../Trumpet", "", true},
+		{".versions", "", true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			name, err := PartName(test.name)
+			testutils.AssertEqual(t, errors.Is(err, ErrInvalidPartName), test.err)
+			testutils.AssertEqual(t, name, test.want)
+		})
+	}
+}
+
+func assertRenamePart(t *testing.T, store interface {
+	Submitter
+	ResourceGetter
+	ResourceVersioner
+	ResourceProtector
+	PartRenamer
+}) {
+	ctx := context.Background()
+	meta := MetaData{Title: "Polka"}
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, manifestParts))
+	resourceId := meta.ResourceId()
+
+	newName, err := RenamePart(ctx, store, "org", resourceId, "Trumpet.pdf", "Trumpet 1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, newName, "Trumpet 1.pdf")
+
+	current := maps.Collect(store.Resource(ctx, "org", resourceId))
+	testutils.AssertEqual(t, slices.Equal(slices.Sorted(maps.Keys(current)), []string{"Horn.pdf", "Trumpet 1.pdf"}), true)
+	testutils.AssertEqual(t, string(current["Trumpet 1.pdf"]), "Trumpet.pdf")
+
+	versions, err := store.ResourceVersions(ctx, "org", resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(versions), 1)
+	testutils.AssertEqual(t, slices.Equal(versions[0].Files, []string{"Horn.pdf", "Trumpet.pdf"}), true)
+
+	t.Run("missing part", func(t *testing.T) {
+		_, err := RenamePart(ctx, store, "org", resourceId, "Trumpet.pdf", "Trumpet 2")
+		testutils.AssertEqual(t, errors.Is(err, ErrFileNotFound), true)
+	})
+
+	t.Run("existing part", func(t *testing.T) {
+		_, err := RenamePart(ctx, store, "org", resourceId, "Horn.pdf", "Trumpet 1.pdf")
+		testutils.AssertEqual(t, errors.Is(err, ErrPartExists), true)
+	})
+
+	t.Run("same name", func(t *testing.T) {
+		_, err := RenamePart(ctx, store, "org", resourceId, "Horn.pdf", "Horn")
+		testutils.AssertEqual(t, errors.Is(err, ErrInvalidPartName), true)
+	})
+
+	t.Run("protected resource", func(t *testing.T) {
+		testutils.AssertNil(t, store.SetProtected(ctx, "org", resourceId, true))
+		_, err := RenamePart(ctx, store, "org", resourceId, "Horn.pdf", "Horn 1")
+		testutils.AssertEqual(t, errors.Is(err, ErrResourceProtected), true)
+	})
+}
+
+func TestGoogleStoreRenamePart(t *testing.T) {
+	assertRenamePart(t, &GoogleStore{
+		FsClient:     NewLocalFirestoreClient(),
+		BucketClient: &FileBucketClient{Directory: t.TempDir()},
+		Config:       &GoogleConfig{Bucket: "scores"},
+	})
+}
+
+func TestInMemoryStoreRenamePart(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.RegisterOrganization(context.Background(), &Organization{Id: "org"}))
+	assertRenamePart(t, store)
+}
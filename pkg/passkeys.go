@@ -0,0 +1,164 @@
+package pkg
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const maxPasskeyNameLength = 100
+
+// Passkey is a WebAuthn credential a user has registered to sign in with
+type Passkey struct {
+	// Id is the base64 (URL encoding without padding) encoded id of the credential
+	Id     string `json:"id" firestore:"id"`
+	UserId string `json:"userId" firestore:"userId"`
+
+	// Name chosen by the user such that the passkey can be recognized later, e.g. "Laptop"
+	Name string `json:"name" firestore:"name"`
+
+	// Credential is the JSON encoded webauthn.Credential holding the public key and the sign count
+	Credential []byte    `json:"credential" firestore:"credential"`
+	CreatedAt  time.Time `json:"createdAt" firestore:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt" firestore:"lastUsedAt"`
+}
+
+func PasskeyId(credentialId []byte) string {
+	return base64.RawURLEncoding.EncodeToString(credentialId)
+}
+
+func NewPasskey(userId, name string, credential *webauthn.Credential) (*Passkey, error) {
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	now := time.Now()
+	return &Passkey{
+		Id:         PasskeyId(credential.ID),
+		UserId:     userId,
+		Name:       name,
+		Credential: data,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}, nil
+}
+
+func (p *Passkey) Validate() error {
+	if p.Id == "" || p.UserId == "" || len(p.Credential) == 0 {
+		return errors.Join(ErrInvalidPasskey, errors.New("id, user and credential can not be empty"))
+	}
+	if utf8.RuneCountInString(p.Name) > maxPasskeyNameLength {
+		return errors.Join(ErrInvalidPasskey, fmt.Errorf("name can not be longer than %d characters", maxPasskeyNameLength))
+	}
+	return nil
+}
+
+func (p *Passkey) WebAuthnCredential() (webauthn.Credential, error) {
+	var credential webauthn.Credential
+	err := json.Unmarshal(p.Credential, &credential)
+	return credential, err
+}
+
+// UpdateCredential stores the sign count and flags of a credential that was just used to sign in
+func (p *Passkey) UpdateCredential(credential *webauthn.Credential, at time.Time) error {
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+	p.Credential = data
+	p.LastUsedAt = at
+	return nil
+}
+
+type PasskeyStore interface {
+	// SavePasskey stores the passkey. A passkey with the same id is replaced
+	SavePasskey(ctx context.Context, passkey *Passkey) error
+
+	// Passkeys returns the passkeys of the user, oldest first
+	Passkeys(ctx context.Context, userId string) ([]Passkey, error)
+	DeletePasskey(ctx context.Context, userId, id string) error
+}
+
+func SortPasskeys(passkeys []Passkey) {
+	slices.SortStableFunc(passkeys, func(a, b Passkey) int { return a.CreatedAt.Compare(b.CreatedAt) })
+}
+
+func passkeyNotFound(id string) error {
+	return errors.Join(ErrPasskeyNotFound, fmt.Errorf("passkey id: %s", id))
+}
+
+// PasskeyUser is the user in a WebAuthn ceremony. The id of the user is used as the user handle, such that
+// the user can be found from the passkey alone when signing in
+type PasskeyUser struct {
+	Info     *UserInfo
+	Passkeys []Passkey
+}
+
+func (u *PasskeyUser) WebAuthnID() []byte {
+	return []byte(u.Info.Id)
+}
+
+func (u *PasskeyUser) WebAuthnName() string {
+	if u.Info.Email != "" {
+		return u.Info.Email
+	}
+	return u.Info.Id
+}
+
+func (u *PasskeyUser) WebAuthnDisplayName() string {
+	if u.Info.Name != "" {
+		return u.Info.Name
+	}
+	return u.WebAuthnName()
+}
+
+func (u *PasskeyUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.Passkeys))
+	for _, passkey := range u.Passkeys {
+		credential, err := passkey.WebAuthnCredential()
+		if err != nil {
+			slog.Warn("Could not decode passkey. Skipping it", "error", err, "userId", u.Info.Id, "passkeyId", passkey.Id)
+			continue
+		}
+		credentials = append(credentials, credential)
+	}
+	return credentials
+}
+
+// PasskeyByCredentialId returns the passkey of the user with the passed credential id
+func (u *PasskeyUser) PasskeyByCredentialId(credentialId []byte) (*Passkey, error) {
+	id := PasskeyId(credentialId)
+	idx := slices.IndexFunc(u.Passkeys, func(p Passkey) bool { return p.Id == id })
+	if idx < 0 {
+		return nil, passkeyNotFound(id)
+	}
+	return &u.Passkeys[idx], nil
+}
+
+// NewWebAuthn returns the relying party used for passkeys. Passkeys are bound to the host of baseURL, hence
+// they can not be used on custom domains of organizations
+func NewWebAuthn(baseURL, displayName string) (*webauthn.WebAuthn, error) {
+	return webauthn.New(&webauthn.Config{
+		RPID:          HostOf(baseURL),
+		RPDisplayName: displayName,
+		RPOrigins:     []string{strings.TrimSuffix(baseURL, "/")},
+	})
+}
+
+type PasskeyRequirementUpdater interface {
+	// RequirePasskey sets whether members of the organization must sign in with a passkey
+	RequirePasskey(ctx context.Context, orgId string, required bool) error
+}
@@ -0,0 +1,146 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+func testPasskey(t *testing.T, userId string, credentialId string, createdAt time.Time) *Passkey {
+	passkey, err := NewPasskey(userId, " Laptop ", &webauthn.Credential{ID: []byte(credentialId), PublicKey: []byte("public-key")})
+	testutils.AssertNil(t, err)
+	passkey.CreatedAt = createdAt
+	return passkey
+}
+
+func TestNewPasskey(t *testing.T) {
+	passkey := testPasskey(t, "user1", "credential", time.Now())
+	testutils.AssertEqual(t, passkey.Id, "Y3JlZGVudGlhbA")
+	testutils.AssertEqual(t, passkey.Name, "Laptop")
+	testutils.AssertNil(t, passkey.Validate())
+
+	credential, err := passkey.WebAuthnCredential()
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, string(credential.PublicKey), "public-key")
+
+	unnamed, err := NewPasskey("user1", "", &webauthn.Credential{ID: []byte("credential")})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, unnamed.Name, "Passkey")
+
+	passkey.Name = strings.Repeat("a", maxPasskeyNameLength+1)
+	testutils.AssertEqual(t, errors.Is(passkey.Validate(), ErrInvalidPasskey), true)
+	testutils.AssertEqual(t, errors.Is((&Passkey{Id: "id"}).Validate(), ErrInvalidPasskey), true)
+}
+
+func TestPasskeyUser(t *testing.T) {
+	passkey := testPasskey(t, "user1", "credential", time.Now())
+	broken := Passkey{Id: "broken", UserId: "user1", Credential: []byte("{")}
+	user := PasskeyUser{Info: &UserInfo{Id: "user1", Email: "kari@example.com"}, Passkeys: []Passkey{*passkey, broken}}
+
+	testutils.AssertEqual(t, string(user.WebAuthnID()), "user1")
+	testutils.AssertEqual(t, user.WebAuthnName(), "kari@example.com")
+	testutils.AssertEqual(t, user.WebAuthnDisplayName(), "kari@example.com")
+	testutils.AssertEqual(t, len(user.WebAuthnCredentials()), 1)
+
+	found, err := user.PasskeyByCredentialId([]byte("credential"))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, found.Id, passkey.Id)
+
+	_, err = user.PasskeyByCredentialId([]byte("unknown"))
+	testutils.AssertEqual(t, errors.Is(err, ErrPasskeyNotFound), true)
+
+	user.Info = &UserInfo{Id: "user2", Name: "Ola"}
+	testutils.AssertEqual(t, user.WebAuthnName(), "user2")
+	testutils.AssertEqual(t, user.WebAuthnDisplayName(), "Ola")
+}
+
+func TestNewWebAuthn(t *testing.T) {
+	relyingParty, err := NewWebAuthn("https://caesura.no/", "Caesura")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, relyingParty.Config.RPID, "caesura.no")
+	testutils.AssertEqual(t, relyingParty.Config.RPOrigins[0], "https://caesura.no")
+}
+
+func assertPasskeyStore(t *testing.T, store PasskeyStore) {
+	ctx := context.Background()
+	createdAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	second := testPasskey(t, "user1", "second", createdAt.Add(time.Hour))
+	first := testPasskey(t, "user1", "first", createdAt)
+	testutils.AssertNil(t, store.SavePasskey(ctx, second))
+	testutils.AssertNil(t, store.SavePasskey(ctx, first))
+	testutils.AssertNil(t, store.SavePasskey(ctx, testPasskey(t, "user10", "other", createdAt)))
+
+	err := store.SavePasskey(ctx, &Passkey{Id: "invalid"})
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidPasskey), true)
+
+	passkeys, err := store.Passkeys(ctx, "user1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(passkeys), 2)
+	testutils.AssertEqual(t, passkeys[0].Id, first.Id)
+	testutils.AssertEqual(t, passkeys[1].Id, second.Id)
+
+	// Saving a passkey with the same id replaces it
+	usedAt := createdAt.Add(2 * time.Hour)
+	testutils.AssertNil(t, first.UpdateCredential(&webauthn.Credential{ID: []byte("first"), Authenticator: webauthn.Authenticator{SignCount: 7}}, usedAt))
+	testutils.AssertNil(t, store.SavePasskey(ctx, first))
+	passkeys, err = store.Passkeys(ctx, "user1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(passkeys), 2)
+	testutils.AssertEqual(t, passkeys[0].LastUsedAt.Equal(usedAt), true)
+	credential, err := passkeys[0].WebAuthnCredential()
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, credential.Authenticator.SignCount, uint32(7))
+
+	err = store.DeletePasskey(ctx, "user10", first.Id)
+	testutils.AssertEqual(t, errors.Is(err, ErrPasskeyNotFound), true)
+	testutils.AssertNil(t, store.DeletePasskey(ctx, "user1", first.Id))
+	err = store.DeletePasskey(ctx, "user1", first.Id)
+	testutils.AssertEqual(t, errors.Is(err, ErrPasskeyNotFound), true)
+
+	passkeys, err = store.Passkeys(ctx, "user1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(passkeys), 1)
+
+	passkeys, err = store.Passkeys(ctx, "unknown")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(passkeys), 0)
+}
+
+func TestInMemoryPasskeys(t *testing.T) {
+	assertPasskeyStore(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGooglePasskeys(t *testing.T) {
+	assertPasskeyStore(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
+
+func assertPasskeyRequirement(t *testing.T, store OrganizationStore) {
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &Organization{Id: "org1", Name: "Band"}))
+	testutils.AssertNil(t, store.RequirePasskey(ctx, "org1", true))
+
+	org, err := store.GetOrganization(ctx, "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, org.RequirePasskey, true)
+
+	testutils.AssertNil(t, store.RequirePasskey(ctx, "org1", false))
+	org, err = store.GetOrganization(ctx, "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, org.RequirePasskey, false)
+
+	err = store.RequirePasskey(ctx, "unknown", true)
+	testutils.AssertEqual(t, errors.Is(err, ErrOrganizationNotFound), true)
+}
+
+func TestInMemoryPasskeyRequirement(t *testing.T) {
+	assertPasskeyRequirement(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGooglePasskeyRequirement(t *testing.T) {
+	assertPasskeyRequirement(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
@@ -0,0 +1,79 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// PermissionsVersionGetter returns a number that changes whenever the roles or groups of the user change.
+// Sessions remember the version their roles were read at, such that changes are noticed without reading all
+// roles on every request. Users without any changes have version 0
+type PermissionsVersionGetter interface {
+	PermissionsVersion(ctx context.Context, userId string) (int64, error)
+}
+
+// PermissionsVersion is the document stored for each user with changed permissions
+type PermissionsVersion struct {
+	Version int64 `firestore:"version"`
+}
+
+// newPermissionsVersion creates a version that is larger than all earlier versions of the user
+func newPermissionsVersion() int64 {
+	return time.Now().UnixNano()
+}
+
+type cachedPermissionsVersion struct {
+	version   int64
+	fetchedAt time.Time
+}
+
+// CachedPermissionsVersions keeps the versions for a short time, such that a user sending many requests
+// only causes one lookup per TTL
+type CachedPermissionsVersions struct {
+	Getter  PermissionsVersionGetter
+	TTL     time.Duration
+	Monitor CacheMonitor
+
+	mu    sync.Mutex
+	cache map[string]cachedPermissionsVersion
+}
+
+func (c *CachedPermissionsVersions) PermissionsVersion(ctx context.Context, userId string) (int64, error) {
+	now := time.Now()
+	c.mu.Lock()
+	item, ok := c.cache[userId]
+	if ok && now.Sub(item.fetchedAt) < c.TTL {
+		c.Monitor.NumHits += 1
+		c.mu.Unlock()
+		return item.version, nil
+	}
+	c.Monitor.NumMisses += 1
+	c.mu.Unlock()
+
+	version, err := c.Getter.PermissionsVersion(ctx, userId)
+	if err != nil {
+		return version, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache[userId] = cachedPermissionsVersion{version: version, fetchedAt: now}
+	c.Monitor.UpdateMaxSize(len(c.cache))
+	return version, nil
+}
+
+// Clear removes the cached version of the user, such that the next lookup reads from the store
+func (c *CachedPermissionsVersions) Clear(userId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.cache, userId)
+}
+
+func NewCachedPermissionsVersions(getter PermissionsVersionGetter, ttl time.Duration) *CachedPermissionsVersions {
+	return &CachedPermissionsVersions{
+		Getter: getter,
+		TTL:    ttl,
+		cache:  make(map[string]cachedPermissionsVersion),
+	}
+}
@@ -0,0 +1,106 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+type permissionsVersionStore interface {
+	UserRegisterer
+	RoleRegisterer
+	DeleteRole
+	GroupStore
+	PermissionsVersionGetter
+}
+
+func assertPermissionsVersions(t *testing.T, store permissionsVersionStore) {
+	ctx := context.Background()
+	user := UserInfo{Id: "versioned-user", Roles: map[string]RoleKind{"org1": RoleViewer}, Groups: map[string][]string{}}
+	testutils.AssertNil(t, store.RegisterUser(ctx, &user))
+
+	version, err := store.PermissionsVersion(ctx, user.Id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, version, int64(0))
+
+	for i, change := range []func() error{
+		func() error { return store.RegisterRole(ctx, user.Id, "org1", RoleAdmin) },
+		func() error { return store.RegisterGroup(ctx, user.Id, "org1", "horn") },
+		func() error { return store.RemoveGroup(ctx, user.Id, "org1", "horn") },
+		func() error { return store.DeleteRole(ctx, user.Id, "org1") },
+	} {
+		testutils.AssertNil(t, change())
+		newVersion, err := store.PermissionsVersion(ctx, user.Id)
+		testutils.AssertNil(t, err)
+		if newVersion == version {
+			t.Fatalf("Change #%d did not update the permissions version", i)
+		}
+		version = newVersion
+	}
+
+	version, err = store.PermissionsVersion(ctx, "unknown-user")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, version, int64(0))
+}
+
+func TestMultiOrgInMemoryStorePermissionsVersions(t *testing.T) {
+	assertPermissionsVersions(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGooglePermissionsVersions(t *testing.T) {
+	assertPermissionsVersions(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
+
+type countingVersionGetter struct {
+	version int64
+	calls   int
+	err     error
+}
+
+func (c *countingVersionGetter) PermissionsVersion(ctx context.Context, userId string) (int64, error) {
+	c.calls++
+	return c.version, c.err
+}
+
+func TestCachedPermissionsVersions(t *testing.T) {
+	ctx := context.Background()
+	getter := &countingVersionGetter{version: 1}
+	cache := NewCachedPermissionsVersions(getter, time.Hour)
+
+	for range 3 {
+		version, err := cache.PermissionsVersion(ctx, "user")
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, version, int64(1))
+	}
+	testutils.AssertEqual(t, getter.calls, 1)
+	testutils.AssertEqual(t, cache.Monitor.NumHits, 2)
+
+	getter.version = 2
+	cache.Clear("user")
+	version, err := cache.PermissionsVersion(ctx, "user")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, version, int64(2))
+
+	cache.TTL = 0
+	_, err = cache.PermissionsVersion(ctx, "user")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, getter.calls, 3)
+}
+
+func TestCachedPermissionsVersionsDoesNotCacheErrors(t *testing.T) {
+	ctx := context.Background()
+	getter := &countingVersionGetter{err: errors.New("store is down")}
+	cache := NewCachedPermissionsVersions(getter, time.Hour)
+
+	_, err := cache.PermissionsVersion(ctx, "user")
+	testutils.AssertEqual(t, err, getter.err)
+
+	getter.err = nil
+	getter.version = 3
+	version, err := cache.PermissionsVersion(ctx, "user")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, version, int64(3))
+}
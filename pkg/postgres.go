@@ -0,0 +1,2110 @@
+package pkg
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/lib/pq"
+)
+
+const (
+	Postgres = "postgres"
+
+	// Arbitrary key that serializes migrations when several instances start at the same time
+	postgresMigrationLock = 7_153_924
+)
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+type PostgresConfig struct {
+	DSN string `yaml:"dsn" env:"CAESURA_POSTGRES_DSN" secret:"true"`
+
+	// Directory where the PDFs are stored
+	Directory string `yaml:"directory" env:"CAESURA_POSTGRES_DIRECTORY"`
+
+	// Store identical parts once, see DedupBucketClient
+	Deduplicate bool `yaml:"deduplicate"`
+}
+
+// PostgresStore keeps metadata, projects, users, organizations and subscriptions in PostgreSQL.
+// The PDFs are kept by the bucket client
+type PostgresStore struct {
+	DB           *sql.DB
+	BucketClient BlobClient
+	Bucket       string
+
+	// Set for the stores passed to the function of RunTransaction
+	tx *sql.Tx
+}
+
+type sqlQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (p *PostgresStore) db() sqlQuerier {
+	if p.tx != nil {
+		return p.tx
+	}
+	return p.DB
+}
+
+func NewPostgresStore(ctx context.Context, config *PostgresConfig) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", config.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("could not open database: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		return nil, errors.Join(fmt.Errorf("could not connect to database: %w", err), db.Close())
+	}
+	if err := MigratePostgres(ctx, db); err != nil {
+		return nil, errors.Join(err, db.Close())
+	}
+	return &PostgresStore{
+		DB:           db,
+		BucketClient: &FileBucketClient{Directory: config.Directory},
+		Bucket:       localBucket,
+	}, nil
+}
+
+// Number of attempts of a transaction that conflicts with concurrent transactions
+const postgresTxAttempts = 3
+
+// RunTransaction runs fn with repeatable read isolation, such that rows read by fn can not change before it
+// commits. Transactions failing because of concurrent updates are attempted again
+func (p *PostgresStore) RunTransaction(ctx context.Context, fn func(ctx context.Context, tx TxStore) error) error {
+	var err error
+	for range postgresTxAttempts {
+		err = p.runTransaction(ctx, fn)
+		var pqErr *pq.Error
+		if !errors.As(err, &pqErr) || pqErr.Code != "40001" {
+			return err
+		}
+	}
+	return err
+}
+
+func (p *PostgresStore) runTransaction(ctx context.Context, fn func(ctx context.Context, tx TxStore) error) error {
+	tx, err := p.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(ctx, &PostgresStore{DB: p.DB, BucketClient: p.BucketClient, Bucket: p.Bucket, tx: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p *PostgresStore) Close() error {
+	return p.DB.Close()
+}
+
+type postgresMigration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// postgresMigrationList returns the migrations shipped in the binary ordered by version. The files are named
+// <version>_<description>.sql
+func postgresMigrationList() ([]postgresMigration, error) {
+	entries, err := fs.ReadDir(postgresMigrations, "migrations/postgres")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]postgresMigration, 0, len(entries))
+	for _, entry := range entries {
+		prefix, _, _ := strings.Cut(entry.Name(), "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s does not start with a version: %w", entry.Name(), err)
+		}
+		content, err := postgresMigrations.ReadFile(path.Join("migrations/postgres", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, postgresMigration{Version: version, Name: entry.Name(), SQL: string(content)})
+	}
+	slices.SortFunc(migrations, func(a, b postgresMigration) int { return a.Version - b.Version })
+	return migrations, nil
+}
+
+// MigratePostgres applies all migrations that are not yet applied. Each migration runs in its own transaction
+func MigratePostgres(ctx context.Context, db *sql.DB) error {
+	migrations, err := postgresMigrationList()
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("could not create migration table: %w", err)
+	}
+
+	for _, migration := range migrations {
+		if err := applyPostgresMigration(ctx, db, migration); err != nil {
+			return fmt.Errorf("migration %s failed: %w", migration.Name, err)
+		}
+	}
+	return nil
+}
+
+func applyPostgresMigration(ctx context.Context, db *sql.DB, migration postgresMigration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", postgresMigrationLock); err != nil {
+		return err
+	}
+
+	var applied bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", migration.Version).Scan(&applied); err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, migration.SQL); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// likePattern returns a pattern for ILIKE matching values that contain s
+func likePattern(s string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + escaper.Replace(s) + "%"
+}
+
+// metaSearchConditions returns the WHERE clause used by MetaByPattern. A resource matches if the title, composer or
+// arranger contains the corresponding field of the pattern. When all fields are empty, all resources match
+func metaSearchConditions(orgId string, pattern *MetaData) (string, []any) {
+	args := []any{orgId}
+	var conditions []string
+	for _, field := range []struct {
+		column string
+		value  string
+	}{
+		{"title", pattern.Title},
+		{"composer", pattern.Composer},
+		{"arranger", pattern.Arranger},
+	} {
+		if field.value == "" {
+			continue
+		}
+		args = append(args, likePattern(field.value))
+		conditions = append(conditions, fmt.Sprintf("%s ILIKE $%d", field.column, len(args)))
+	}
+
+	where := "WHERE org_id = $1"
+	if len(conditions) > 0 {
+		where += " AND (" + strings.Join(conditions, " OR ") + ")"
+	}
+	return where, args
+}
+
+func metaSearchQuery(orgId string, pattern *MetaData) (string, []any) {
+	where, args := metaSearchConditions(orgId, pattern)
+	return "SELECT data FROM metadata " + where + " ORDER BY title, composer, arranger", args
+}
+
+// metaSortColumn is the expression the metadata is ordered by. The times are compared as timestamps, since the
+// stored text trims trailing zeros of the fraction
+func metaSortColumn(field MetaSortField) string {
+	switch field {
+	case SortByTitle, SortByComposer, SortByArranger:
+		return string(field)
+	case SortByUpdated:
+		return "(data ->> 'submitted_at')::timestamptz"
+	default:
+		return ""
+	}
+}
+
+// metaSearchPageQuery orders the matches by the sort column and resource id, such that a page can start after the
+// last resource of the previous page. After holds the keys of the cursor
+func metaSearchPageQuery(orgId string, pattern *MetaData, sort MetaSort, after []string, limit int) (string, []any) {
+	where, args := metaSearchConditions(orgId, pattern)
+	direction, comparison := "ASC", ">"
+	if sort.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	column := metaSortColumn(sort.Field)
+	order := "resource_id " + direction
+	if column != "" {
+		order = column + " " + direction + ", " + order
+	}
+
+	if after != nil {
+		if column == "" {
+			args = append(args, after[0])
+			where += fmt.Sprintf(" AND resource_id %s $%d", comparison, len(args))
+		} else {
+			cast := ""
+			if sort.Field == SortByUpdated {
+				cast = "::timestamptz"
+			}
+			args = append(args, after[0], after[1])
+			where += fmt.Sprintf(" AND (%s, resource_id) %s ($%d%s, $%d)", column, comparison, len(args)-1, cast, len(args))
+		}
+	}
+	args = append(args, limit)
+	return "SELECT data FROM metadata " + where + fmt.Sprintf(" ORDER BY %s LIMIT $%d", order, len(args)), args
+}
+
+// textArray converts s to a parameter for a TEXT[] column. Nil slices are stored as empty arrays
+func textArray(s []string) any {
+	if s == nil {
+		s = []string{}
+	}
+	return pq.Array(s)
+}
+
+// expectRows returns notFound if the statement did not change any rows
+func expectRows(result sql.Result, err error, notFound error) error {
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
+
+func (p *PostgresStore) blobs() *GoogleStore {
+	// Only the methods of the GoogleStore that use the bucket client may be called
+	return &GoogleStore{BucketClient: p.BucketClient, Config: &GoogleConfig{Bucket: p.Bucket}}
+}
+
+func (p *PostgresStore) storeMeta(ctx context.Context, orgId string, meta *MetaData) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	_, err = p.db().ExecContext(
+		ctx,
+		`INSERT INTO metadata (org_id, resource_id, data) VALUES ($1, $2, $3)
+		ON CONFLICT (org_id, resource_id) DO UPDATE SET data = excluded.data`,
+		orgId, meta.ResourceId(), string(data),
+	)
+	return err
+}
+
+func (p *PostgresStore) updateMetaField(ctx context.Context, orgId, resourceId, field string, value any) error {
+	return p.updateMetaFields(ctx, orgId, resourceId, map[string]any{field: value})
+}
+
+// updateMetaFields updates several fields of the metadata in one statement
+func (p *PostgresStore) updateMetaFields(ctx context.Context, orgId, resourceId string, fields map[string]any) error {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	result, err := p.db().ExecContext(
+		ctx,
+		"UPDATE metadata SET data = data || $3::jsonb WHERE org_id = $1 AND resource_id = $2",
+		orgId, resourceId, string(data),
+	)
+	return expectRows(result, err, errors.Join(ErrResourceMetadataNotFound, fmt.Errorf("resource id: %s", resourceId)))
+}
+
+func (p *PostgresStore) checkNotProtected(ctx context.Context, orgId, resourceId string) error {
+	meta, err := p.MetaById(ctx, orgId, resourceId)
+	if errors.Is(err, ErrResourceMetadataNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if meta.Protected {
+		return errors.Join(ErrResourceProtected, fmt.Errorf("resource id: %s", resourceId))
+	}
+	return nil
+}
+
+func (p *PostgresStore) Submit(ctx context.Context, orgId string, m *MetaData, pdfIter iter.Seq2[string, []byte]) error {
+	resourceId := m.ResourceId()
+	existing, err := p.MetaById(ctx, orgId, resourceId)
+	exists, err := replaceable(existing, err, resourceId)
+	if err != nil {
+		return err
+	}
+	var previous []*storage.ObjectAttrs
+	if exists {
+		if previous, err = p.blobs().archiveResource(ctx, orgId, resourceId); err != nil {
+			return fmt.Errorf("could not keep the previous version: %w", err)
+		}
+	}
+
+	m.Status = StoreStatusPending
+	m.SubmittedAt = time.Now()
+	m.ContentHash = ""
+	if err := p.storeMeta(ctx, orgId, m); err != nil {
+		return err
+	}
+
+	previousHash := ""
+	if exists {
+		previousHash = existing.ContentHash
+	}
+	hash := newContentHash(previousHash)
+	if err := p.blobs().uploadParts(ctx, orgId, resourceId, hash.parts(pdfIter), previous); err != nil {
+		abortCtx := context.WithoutCancel(ctx)
+		if exists {
+			return errors.Join(err, p.storeMeta(abortCtx, orgId, existing))
+		}
+		return errors.Join(err, p.updateMetaField(abortCtx, orgId, resourceId, "status", StoreStatusFailed))
+	}
+	m.ContentHash = hash.Sum()
+	return p.updateMetaFields(ctx, orgId, resourceId, map[string]any{"status": StoreStatusFinished, "content_hash": m.ContentHash})
+}
+
+func (p *PostgresStore) SetProtected(ctx context.Context, orgId, resourceId string, protected bool) error {
+	return p.updateMetaField(ctx, orgId, resourceId, "protected", protected)
+}
+
+func (p *PostgresStore) DeleteResource(ctx context.Context, orgId, resourceId string) error {
+	if err := p.checkNotProtected(ctx, orgId, resourceId); err != nil {
+		return err
+	}
+	return p.updateMetaFields(ctx, orgId, resourceId, map[string]any{"deleted": true, "deleted_at": time.Now()})
+}
+
+func (p *PostgresStore) RestoreResource(ctx context.Context, orgId, resourceId string) error {
+	return p.updateMetaFields(ctx, orgId, resourceId, map[string]any{"deleted": false, "deleted_at": time.Time{}})
+}
+
+// PurgeResource removes the files before the metadata, such that a failed purge can be attempted again
+func (p *PostgresStore) PurgeResource(ctx context.Context, orgId, resourceId string) error {
+	meta, err := p.MetaById(ctx, orgId, resourceId)
+	if err := purgeable(meta, err, resourceId); err != nil {
+		return err
+	}
+	if err := p.blobs().deleteFiles(ctx, orgId, resourceId); err != nil {
+		return err
+	}
+	_, err = p.db().ExecContext(ctx, "DELETE FROM metadata WHERE org_id = $1 AND resource_id = $2", orgId, resourceId)
+	return err
+}
+
+func (p *PostgresStore) ResolveSubmit(ctx context.Context, orgId, resourceId string) error {
+	meta, err := p.MetaById(ctx, orgId, resourceId)
+	if err != nil {
+		return err
+	}
+	parts, err := p.blobs().listObjects(ctx, path.Join(orgId, resourceId)+"/")
+	if err != nil {
+		return err
+	}
+
+	if resolveAction(meta, len(parts) > 0) == StoreStatusFinished {
+		return p.updateMetaField(ctx, orgId, resourceId, "status", StoreStatusFinished)
+	}
+	if err := p.blobs().deleteFiles(ctx, orgId, resourceId); err != nil {
+		return err
+	}
+	_, err = p.db().ExecContext(ctx, "DELETE FROM metadata WHERE org_id = $1 AND resource_id = $2", orgId, resourceId)
+	return err
+}
+
+func (p *PostgresStore) ResourceFiles(ctx context.Context, orgId string) (map[string]int, error) {
+	return p.blobs().ResourceFiles(ctx, orgId)
+}
+
+func (p *PostgresStore) RemoveOrphanFiles(ctx context.Context, orgId, resourceId string) error {
+	_, err := p.MetaById(ctx, orgId, resourceId)
+	if err := orphanedFiles(err, resourceId); err != nil {
+		return err
+	}
+	return p.blobs().deleteFiles(ctx, orgId, resourceId)
+}
+
+func (p *PostgresStore) RemoveOrphanMetaData(ctx context.Context, orgId, resourceId string) error {
+	parts, err := p.blobs().listObjects(ctx, path.Join(orgId, resourceId)+"/")
+	if err := orphanedMetaData(len(parts), err, resourceId); err != nil {
+		return err
+	}
+	_, err = p.db().ExecContext(ctx, "DELETE FROM metadata WHERE org_id = $1 AND resource_id = $2", orgId, resourceId)
+	return err
+}
+
+func (p *PostgresStore) ResourceVersions(ctx context.Context, orgId, resourceId string) ([]ResourceVersion, error) {
+	return p.blobs().ResourceVersions(ctx, orgId, resourceId)
+}
+
+func (p *PostgresStore) ResourceVersion(ctx context.Context, orgId, resourceId string, version int) iter.Seq2[string, []byte] {
+	return p.blobs().ResourceVersion(ctx, orgId, resourceId, version)
+}
+
+func (p *PostgresStore) RestoreVersion(ctx context.Context, orgId, resourceId string, version int) error {
+	meta, err := p.MetaById(ctx, orgId, resourceId)
+	if err != nil {
+		return err
+	}
+	if _, err := replaceable(meta, nil, resourceId); err != nil {
+		return err
+	}
+	if err := p.updateMetaField(ctx, orgId, resourceId, "content_hash", ""); err != nil {
+		return err
+	}
+	return p.blobs().restoreVersion(ctx, orgId, resourceId, version)
+}
+
+func (p *PostgresStore) UpdateMetaData(ctx context.Context, orgId string, meta *MetaData) error {
+	if _, err := p.MetaById(ctx, orgId, meta.ResourceId()); err != nil {
+		return err
+	}
+	return p.storeMeta(ctx, orgId, meta)
+}
+
+func (p *PostgresStore) MetaByPattern(ctx context.Context, orgId string, pattern *MetaData) ([]MetaData, error) {
+	query, args := metaSearchQuery(orgId, pattern)
+	return p.queryMetaData(ctx, query, args...)
+}
+
+func (p *PostgresStore) queryMetaData(ctx context.Context, query string, args ...any) ([]MetaData, error) {
+	rows, err := p.db().QueryContext(ctx, query, args...)
+	if err != nil {
+		return []MetaData{}, err
+	}
+	defer rows.Close()
+
+	result := []MetaData{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return result, err
+		}
+		var meta MetaData
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return result, err
+		}
+		result = append(result, meta)
+	}
+	return result, rows.Err()
+}
+
+func (p *PostgresStore) MetaById(ctx context.Context, orgId, id string) (*MetaData, error) {
+	var data []byte
+	var meta MetaData
+	err := p.db().QueryRowContext(ctx, "SELECT data FROM metadata WHERE org_id = $1 AND resource_id = $2", orgId, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &meta, errors.Join(ErrResourceMetadataNotFound, fmt.Errorf("resource id: %s", id))
+	} else if err != nil {
+		return &meta, err
+	}
+	err = json.Unmarshal(data, &meta)
+	return &meta, err
+}
+
+func (p *PostgresStore) ResourceManifest(ctx context.Context, orgId, resourceId string) (*ResourceManifest, error) {
+	meta, err := p.MetaById(ctx, orgId, resourceId)
+	if err := visibleMeta(meta, err, resourceId); err != nil {
+		return nil, err
+	}
+	return p.blobs().listManifest(ctx, orgId, resourceId)
+}
+
+func (p *PostgresStore) SaveArchive(ctx context.Context, archive *Archive) error {
+	return p.blobs().SaveArchive(ctx, archive)
+}
+
+func (p *PostgresStore) Archive(ctx context.Context, orgId, id string) (*Archive, error) {
+	return p.blobs().Archive(ctx, orgId, id)
+}
+
+func (p *PostgresStore) SaveArchiveChunk(ctx context.Context, orgId, id string, index int, data []byte) error {
+	return p.blobs().SaveArchiveChunk(ctx, orgId, id, index, data)
+}
+
+func (p *PostgresStore) ArchiveChunk(ctx context.Context, orgId, id string, index int) ([]byte, error) {
+	return p.blobs().ArchiveChunk(ctx, orgId, id, index)
+}
+
+func (p *PostgresStore) DeleteArchive(ctx context.Context, orgId, id string) error {
+	return p.blobs().DeleteArchive(ctx, orgId, id)
+}
+
+func (p *PostgresStore) TrackArtifact(ctx context.Context, artifact *TempArtifact) error {
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO temp_artifacts (kind, org_id, id, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (kind, org_id, id) DO UPDATE SET expires_at = excluded.expires_at`,
+		artifact.Kind, artifact.OrgId, artifact.Id, artifact.ExpiresAt,
+	)
+	return err
+}
+
+func (p *PostgresStore) ExpiredArtifacts(ctx context.Context, kind ArtifactKind, now time.Time) ([]TempArtifact, error) {
+	rows, err := p.db().QueryContext(ctx, "SELECT kind, org_id, id, expires_at FROM temp_artifacts WHERE kind = $1 AND expires_at <= $2", kind, now)
+	if err != nil {
+		return []TempArtifact{}, err
+	}
+	defer rows.Close()
+
+	artifacts := []TempArtifact{}
+	for rows.Next() {
+		var artifact TempArtifact
+		if err := rows.Scan(&artifact.Kind, &artifact.OrgId, &artifact.Id, &artifact.ExpiresAt); err != nil {
+			return artifacts, err
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts, rows.Err()
+}
+
+func (p *PostgresStore) ForgetArtifact(ctx context.Context, artifact *TempArtifact) error {
+	_, err := p.db().ExecContext(ctx, "DELETE FROM temp_artifacts WHERE kind = $1 AND org_id = $2 AND id = $3", artifact.Kind, artifact.OrgId, artifact.Id)
+	return err
+}
+
+// MetaByIds fetches all metadata in a single query
+func (p *PostgresStore) MetaByIds(ctx context.Context, orgId string, ids []string) []MetaLookup {
+	results := make([]MetaLookup, len(ids))
+	for i, id := range ids {
+		results[i] = MetaLookup{Id: id, Err: errors.Join(ErrResourceMetadataNotFound, fmt.Errorf("resource id: %s", id))}
+	}
+
+	setAll := func(err error) []MetaLookup {
+		for i := range results {
+			results[i].Err = err
+		}
+		return results
+	}
+
+	rows, err := p.db().QueryContext(ctx, "SELECT resource_id, data FROM metadata WHERE org_id = $1 AND resource_id = ANY($2)", orgId, textArray(ids))
+	if err != nil {
+		return setAll(err)
+	}
+	defer rows.Close()
+
+	found := make(map[string]*MetaData)
+	for rows.Next() {
+		var id string
+		var data []byte
+		var meta MetaData
+		if err := rows.Scan(&id, &data); err != nil {
+			return setAll(err)
+		}
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return setAll(err)
+		}
+		found[id] = &meta
+	}
+	if err := rows.Err(); err != nil {
+		return setAll(err)
+	}
+
+	for i, id := range ids {
+		if meta, ok := found[id]; ok {
+			results[i].Meta, results[i].Err = meta, nil
+		}
+	}
+	return results
+}
+
+func (p *PostgresStore) SubmitProject(ctx context.Context, orgId string, project *Project) error {
+	notes, err := json.Marshal(project.Notes)
+	if err != nil {
+		return err
+	}
+	if project.Notes == nil {
+		notes = []byte("{}")
+	}
+	_, err = p.db().ExecContext(
+		ctx,
+		`INSERT INTO projects (org_id, id, name, resource_ids, created_at, updated_at, notes, template_id, categories, announcement, groups)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (org_id, id) DO UPDATE
+		SET name = excluded.name, resource_ids = excluded.resource_ids, updated_at = excluded.updated_at,
+		notes = projects.notes || excluded.notes`,
+		orgId, project.Id(), project.Name, textArray(project.ResourceIds), project.CreatedAt, project.UpdatedAt, string(notes),
+		project.TemplateId, textArray(project.Categories), project.Announcement, textArray(project.Groups),
+	)
+	return err
+}
+
+const projectColumns = "name, resource_ids, created_at, updated_at, notes, template_id, categories, announcement, groups"
+
+func scanProject(row interface{ Scan(...any) error }) (Project, error) {
+	var project Project
+	var notes []byte
+	err := row.Scan(
+		&project.Name, pq.Array(&project.ResourceIds), &project.CreatedAt, &project.UpdatedAt, &notes,
+		&project.TemplateId, pq.Array(&project.Categories), &project.Announcement, pq.Array(&project.Groups),
+	)
+	if project.ResourceIds == nil {
+		project.ResourceIds = []string{}
+	}
+	if err == nil {
+		err = json.Unmarshal(notes, &project.Notes)
+	}
+	return project, err
+}
+
+func (p *PostgresStore) ProjectsByName(ctx context.Context, orgId string, name string) ([]Project, error) {
+	rows, err := p.db().QueryContext(
+		ctx,
+		"SELECT "+projectColumns+" FROM projects WHERE org_id = $1 AND name ILIKE $2 ORDER BY name",
+		orgId, likePattern(name),
+	)
+	if err != nil {
+		return []Project{}, err
+	}
+	defer rows.Close()
+
+	projects := []Project{}
+	for rows.Next() {
+		project, err := scanProject(rows)
+		if err != nil {
+			return projects, err
+		}
+		projects = append(projects, project)
+	}
+	return projects, rows.Err()
+}
+
+func (p *PostgresStore) ProjectById(ctx context.Context, orgId string, id string) (*Project, error) {
+	row := p.db().QueryRowContext(ctx, "SELECT "+projectColumns+" FROM projects WHERE org_id = $1 AND id = $2", orgId, id)
+	project, err := scanProject(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Project{}, errors.Join(ErrProjectNotFound, fmt.Errorf("project id: %s", id))
+	}
+	return &project, err
+}
+
+func (p *PostgresStore) DeleteProject(ctx context.Context, orgId, projectId string) error {
+	_, err := p.db().ExecContext(ctx, "DELETE FROM projects WHERE org_id = $1 AND id = $2", orgId, projectId)
+	return err
+}
+
+func (p *PostgresStore) RemoveResource(ctx context.Context, orgId string, projectId string, resourceId string) error {
+	result, err := p.db().ExecContext(
+		ctx,
+		"UPDATE projects SET resource_ids = array_remove(resource_ids, $3), notes = notes - $3::text, updated_at = $4 WHERE org_id = $1 AND id = $2",
+		orgId, projectId, resourceId, time.Now(),
+	)
+	return expectRows(result, err, errors.Join(ErrProjectNotFound, fmt.Errorf("project id: %s", projectId)))
+}
+
+func (p *PostgresStore) SetProjectOrder(ctx context.Context, orgId, projectId string, resourceIds []string) error {
+	result, err := p.db().ExecContext(
+		ctx,
+		"UPDATE projects SET resource_ids = $3, updated_at = $4 WHERE org_id = $1 AND id = $2",
+		orgId, projectId, textArray(resourceIds), time.Now(),
+	)
+	return expectRows(result, err, errors.Join(ErrProjectNotFound, fmt.Errorf("project id: %s", projectId)))
+}
+
+func (p *PostgresStore) SetProjectNote(ctx context.Context, orgId, projectId, resourceId, note string) error {
+	query := "UPDATE projects SET notes = notes || jsonb_build_object($3::text, $4::text), updated_at = $5 WHERE org_id = $1 AND id = $2"
+	args := []any{orgId, projectId, resourceId, note, time.Now()}
+	if note == "" {
+		query = "UPDATE projects SET notes = notes - $3::text, updated_at = $4 WHERE org_id = $1 AND id = $2"
+		args = []any{orgId, projectId, resourceId, time.Now()}
+	}
+	result, err := p.db().ExecContext(ctx, query, args...)
+	return expectRows(result, err, errors.Join(ErrProjectNotFound, fmt.Errorf("project id: %s", projectId)))
+}
+
+func (p *PostgresStore) Resource(ctx context.Context, orgId string, path string) iter.Seq2[string, []byte] {
+	return p.blobs().Resource(ctx, orgId, path)
+}
+
+func (p *PostgresStore) ResourceStream(ctx context.Context, orgId string, path string) iter.Seq2[string, io.Reader] {
+	return p.blobs().ResourceStream(ctx, orgId, path)
+}
+
+func (p *PostgresStore) SignedPartURL(ctx context.Context, orgId, resourceId, filename string, expires time.Time) (string, error) {
+	return p.blobs().SignedPartURL(ctx, orgId, resourceId, filename, expires)
+}
+
+func (p *PostgresStore) OpenPart(ctx context.Context, orgId, resourceId, filename string) (io.ReadSeekCloser, error) {
+	return p.blobs().OpenPart(ctx, orgId, resourceId, filename)
+}
+
+func (p *PostgresStore) Item(ctx context.Context, path string) ([]byte, error) {
+	return p.blobs().Item(ctx, path)
+}
+
+func (p *PostgresStore) ResourceItemNames(ctx context.Context, resourceId string) ([]string, error) {
+	return p.blobs().ResourceItemNames(ctx, resourceId)
+}
+
+func (p *PostgresStore) RecordAccess(ctx context.Context, orgId, resourceId string, at time.Time) error {
+	return p.updateMetaField(ctx, orgId, resourceId, "last_accessed", at)
+}
+
+func (p *PostgresStore) TransitionStorageClass(ctx context.Context, orgId, resourceId string, class StorageClass) error {
+	prefix := path.Join(orgId, resourceId) + "/"
+	if err := setStorageClass(ctx, p.BucketClient, p.Bucket, prefix, class); err != nil {
+		return classifyStoreErr(err, ErrResourceNotFound)
+	}
+	return p.updateMetaField(ctx, orgId, resourceId, "storage_class", class)
+}
+
+func (p *PostgresStore) StoreSubscription(ctx context.Context, stripeId string, subscription *Subscription) error {
+	var orgId string
+	err := p.db().QueryRowContext(ctx, "SELECT id FROM organizations WHERE stripe_id = $1 LIMIT 1", stripeId).Scan(&orgId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("Could not find any organization for stripe id %s: %w", stripeId, ErrOrganizationNotFound)
+	} else if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(subscription)
+	if err != nil {
+		return err
+	}
+	_, err = p.db().ExecContext(
+		ctx,
+		"INSERT INTO subscriptions (org_id, data) VALUES ($1, $2) ON CONFLICT (org_id) DO UPDATE SET data = excluded.data",
+		orgId, string(data),
+	)
+	return err
+}
+
+func (p *PostgresStore) GetSubscription(ctx context.Context, orgId string) (*Subscription, error) {
+	var data []byte
+	var sub Subscription
+	err := p.db().QueryRowContext(ctx, "SELECT data FROM subscriptions WHERE org_id = $1", orgId).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &sub, errors.Join(ErrSubscriptionNotFound, fmt.Errorf("organization id: %s", orgId))
+	} else if err != nil {
+		return &sub, err
+	}
+	err = json.Unmarshal(data, &sub)
+	return &sub, err
+}
+
+const organizationColumns = "id, name, deleted, num_scores, stripe_id, branding, domain, require_passkey"
+
+func scanOrganization(row interface{ Scan(...any) error }) (Organization, error) {
+	var org Organization
+	var branding []byte
+	if err := row.Scan(&org.Id, &org.Name, &org.Deleted, &org.NumScores, &org.StripeId, &branding, &org.Domain, &org.RequirePasskey); err != nil {
+		return org, err
+	}
+	return org, json.Unmarshal(branding, &org.Branding)
+}
+
+func (p *PostgresStore) RegisterOrganization(ctx context.Context, org *Organization) error {
+	branding, err := json.Marshal(org.Branding)
+	if err != nil {
+		return err
+	}
+	_, err = p.db().ExecContext(
+		ctx,
+		`INSERT INTO organizations (`+organizationColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name, deleted = excluded.deleted, num_scores = excluded.num_scores,
+		stripe_id = excluded.stripe_id, branding = excluded.branding, domain = excluded.domain,
+		require_passkey = excluded.require_passkey`,
+		org.Id, org.Name, org.Deleted, org.NumScores, org.StripeId, string(branding), org.Domain, org.RequirePasskey,
+	)
+	return err
+}
+
+func (p *PostgresStore) GetOrganization(ctx context.Context, orgId string) (Organization, error) {
+	row := p.db().QueryRowContext(ctx, "SELECT "+organizationColumns+" FROM organizations WHERE id = $1", orgId)
+	org, err := scanOrganization(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return org, errors.Join(ErrOrganizationNotFound, fmt.Errorf("organization id: %s", orgId))
+	}
+	return org, err
+}
+
+func (p *PostgresStore) ListOrganizations(ctx context.Context) ([]Organization, error) {
+	rows, err := p.db().QueryContext(ctx, "SELECT "+organizationColumns+" FROM organizations ORDER BY id")
+	if err != nil {
+		return []Organization{}, err
+	}
+	defer rows.Close()
+
+	orgs := []Organization{}
+	for rows.Next() {
+		org, err := scanOrganization(rows)
+		if err != nil {
+			return orgs, err
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, rows.Err()
+}
+
+func (p *PostgresStore) DeleteOrganization(ctx context.Context, orgId string) error {
+	result, err := p.db().ExecContext(ctx, "UPDATE organizations SET deleted = TRUE WHERE id = $1", orgId)
+	return expectRows(result, err, errors.Join(ErrOrganizationNotFound, fmt.Errorf("organization id: %s", orgId)))
+}
+
+func (p *PostgresStore) UpdateBranding(ctx context.Context, orgId string, branding Branding) error {
+	data, err := json.Marshal(branding)
+	if err != nil {
+		return err
+	}
+	result, err := p.db().ExecContext(ctx, "UPDATE organizations SET branding = $2 WHERE id = $1", orgId, string(data))
+	return expectRows(result, err, errors.Join(ErrOrganizationNotFound, fmt.Errorf("organization id: %s", orgId)))
+}
+
+func (p *PostgresStore) OrganizationByDomain(ctx context.Context, domain string) (Organization, error) {
+	if domain == "" {
+		return Organization{}, ErrOrganizationNotFound
+	}
+	row := p.db().QueryRowContext(ctx, "SELECT "+organizationColumns+" FROM organizations WHERE domain = $1 AND NOT deleted LIMIT 1", domain)
+	org, err := scanOrganization(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Organization{}, ErrOrganizationNotFound
+	}
+	return org, err
+}
+
+func (p *PostgresStore) UpdateDomain(ctx context.Context, orgId, domain string) error {
+	if owner, err := p.OrganizationByDomain(ctx, domain); err == nil && owner.Id != orgId {
+		return errors.Join(ErrDomainInUse, fmt.Errorf("%s is used by %s", domain, owner.Id))
+	}
+	result, err := p.db().ExecContext(ctx, "UPDATE organizations SET domain = $2 WHERE id = $1", orgId, domain)
+	return expectRows(result, err, errors.Join(ErrOrganizationNotFound, fmt.Errorf("organization id: %s", orgId)))
+}
+
+func (p *PostgresStore) RequirePasskey(ctx context.Context, orgId string, required bool) error {
+	result, err := p.db().ExecContext(ctx, "UPDATE organizations SET require_passkey = $2 WHERE id = $1", orgId, required)
+	return expectRows(result, err, errors.Join(ErrOrganizationNotFound, fmt.Errorf("organization id: %s", orgId)))
+}
+
+func (p *PostgresStore) RegisterUser(ctx context.Context, userInfo *UserInfo) error {
+	flatUser := userInfo.ToFlat()
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	user := flatUser.User
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO users (id, email, verified_email, name, password) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE SET email = excluded.email, verified_email = excluded.verified_email,
+		name = excluded.name, password = excluded.password`,
+		user.Id, user.Email, user.VerifiedEmail, user.Name, user.Password,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, link := range flatUser.UserOrgLinks {
+		_, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO memberships (user_id, org_id, role, groups, deleted) VALUES ($1, $2, $3, $4, FALSE)
+			ON CONFLICT (user_id, org_id) DO UPDATE SET role = excluded.role, groups = excluded.groups, deleted = FALSE`,
+			link.UserId, link.OrgId, link.Role, textArray(link.Groups),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *PostgresStore) GetUserInfo(ctx context.Context, userId string) (*UserInfo, error) {
+	if userId == "" {
+		return &UserInfo{}, fmt.Errorf("Empty userId provided: %w", ErrUserNotFound)
+	}
+
+	var user User
+	err := p.db().QueryRowContext(
+		ctx,
+		"SELECT id, email, verified_email, name, password FROM users WHERE id = $1",
+		userId,
+	).Scan(&user.Id, &user.Email, &user.VerifiedEmail, &user.Name, &user.Password)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &UserInfo{}, errors.Join(ErrUserNotFound, fmt.Errorf("user id: %s", userId))
+	} else if err != nil {
+		return &UserInfo{}, err
+	}
+
+	rows, err := p.db().QueryContext(ctx, "SELECT org_id, role, groups FROM memberships WHERE user_id = $1 AND NOT deleted", userId)
+	if err != nil {
+		return &UserInfo{}, err
+	}
+	defer rows.Close()
+
+	flat := FlatUser{User: user}
+	for rows.Next() {
+		link := UserOrganizationLink{UserId: userId}
+		if err := rows.Scan(&link.OrgId, &link.Role, pq.Array(&link.Groups)); err != nil {
+			return &UserInfo{}, err
+		}
+		flat.UserOrgLinks = append(flat.UserOrgLinks, link)
+	}
+	return NewUserFromFlat(&flat), rows.Err()
+}
+
+func (p *PostgresStore) PermissionsVersion(ctx context.Context, userId string) (int64, error) {
+	var version int64
+	err := p.db().QueryRowContext(ctx, "SELECT version FROM permissions_versions WHERE user_id = $1", userId).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return version, err
+}
+
+// bumpPermissionsVersion is called after the roles or groups of the user have changed
+func (p *PostgresStore) bumpPermissionsVersion(ctx context.Context, userId string) error {
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO permissions_versions (user_id, version) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET version = excluded.version`,
+		userId, newPermissionsVersion(),
+	)
+	return err
+}
+
+func (p *PostgresStore) RegisterGroup(ctx context.Context, userId, orgId, group string) error {
+	result, err := p.db().ExecContext(
+		ctx,
+		`UPDATE memberships SET groups = array_append(groups, $3)
+		WHERE user_id = $1 AND org_id = $2 AND NOT ($3 = ANY (groups))`,
+		userId, orgId, group,
+	)
+	if err := expectRows(result, err, ErrUserNotFound); err == nil {
+		return p.bumpPermissionsVersion(ctx, userId)
+	} else if !errors.Is(err, ErrUserNotFound) {
+		return err
+	}
+
+	// Nothing was updated either because the user already is in the group or because the user is not a member
+	var exists bool
+	err = p.db().QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM memberships WHERE user_id = $1 AND org_id = $2)", userId, orgId).Scan(&exists)
+	if err == nil && !exists {
+		err = errors.Join(ErrUserNotFound, fmt.Errorf("user %s is not a member of %s", userId, orgId))
+	}
+	return err
+}
+
+func (p *PostgresStore) RemoveGroup(ctx context.Context, userId, orgId, group string) error {
+	result, err := p.db().ExecContext(
+		ctx,
+		"UPDATE memberships SET groups = array_remove(groups, $3) WHERE user_id = $1 AND org_id = $2",
+		userId, orgId, group,
+	)
+	if err := expectRows(result, err, errors.Join(ErrUserNotFound, fmt.Errorf("user %s is not a member of %s", userId, orgId))); err != nil {
+		return err
+	}
+	return p.bumpPermissionsVersion(ctx, userId)
+}
+
+func (p *PostgresStore) RegisterRole(ctx context.Context, userId string, organizationId string, role RoleKind) error {
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO memberships (user_id, org_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, org_id) DO UPDATE SET role = excluded.role`,
+		userId, organizationId, role,
+	)
+	if err != nil {
+		return err
+	}
+	return p.bumpPermissionsVersion(ctx, userId)
+}
+
+func (p *PostgresStore) DeleteRole(ctx context.Context, userId, orgId string) error {
+	if _, err := p.db().ExecContext(ctx, "DELETE FROM memberships WHERE user_id = $1 AND org_id = $2", userId, orgId); err != nil {
+		return err
+	}
+	return p.bumpPermissionsVersion(ctx, userId)
+}
+
+func (p *PostgresStore) GetUsersInOrg(ctx context.Context, orgId string) ([]UserInfo, error) {
+	query := `SELECT u.id, u.name, u.email, m.role, m.groups FROM memberships m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.org_id = $1 AND NOT m.deleted
+		ORDER BY u.name, u.id`
+	return p.queryMembers(ctx, orgId, query, orgId)
+}
+
+func (p *PostgresStore) queryMembers(ctx context.Context, orgId, query string, args ...any) ([]UserInfo, error) {
+	rows, err := p.db().QueryContext(ctx, query, args...)
+	if err != nil {
+		return []UserInfo{}, err
+	}
+	defer rows.Close()
+
+	users := []UserInfo{}
+	for rows.Next() {
+		link := UserOrganizationLink{OrgId: orgId}
+		if err := rows.Scan(&link.UserId, &link.Name, &link.Email, &link.Role, pq.Array(&link.Groups)); err != nil {
+			return users, err
+		}
+		users = append(users, link.Member())
+	}
+	return users, rows.Err()
+}
+
+func (p *PostgresStore) UserByEmail(ctx context.Context, email string) (UserInfo, error) {
+	var userId string
+	err := p.db().QueryRowContext(ctx, "SELECT id FROM users WHERE lower(email) = lower($1) LIMIT 1", email).Scan(&userId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return UserInfo{}, err
+	}
+	u, err := p.GetUserInfo(ctx, userId)
+	return *u, err
+}
+
+// ResetPassword resets the users password
+// Note that the password should be a hashed version of the password using
+// a cryptographically safe hash method
+func (p *PostgresStore) ResetPassword(ctx context.Context, userId, password string) error {
+	result, err := p.db().ExecContext(ctx, "UPDATE users SET password = $2 WHERE id = $1", userId, password)
+	return expectRows(result, err, errors.Join(ErrUserNotFound, fmt.Errorf("user id: %s", userId)))
+}
+
+func (p *PostgresStore) MarkEmailVerified(ctx context.Context, userId string) error {
+	result, err := p.db().ExecContext(ctx, "UPDATE users SET verified_email = TRUE WHERE id = $1", userId)
+	return expectRows(result, err, errors.Join(ErrUserNotFound, fmt.Errorf("user id: %s", userId)))
+}
+
+func (p *PostgresStore) EraseUser(ctx context.Context, userId string) error {
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var email string
+	err = tx.QueryRowContext(ctx, "DELETE FROM users WHERE id = $1 RETURNING email", userId).Scan(&email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.Join(ErrUserNotFound, fmt.Errorf("user id: %s", userId))
+	} else if err != nil {
+		return err
+	}
+
+	for _, statement := range []string{
+		"DELETE FROM memberships WHERE user_id = $1",
+		"DELETE FROM passkeys WHERE user_id = $1",
+		"DELETE FROM api_tokens WHERE user_id = $1",
+		"DELETE FROM user_sessions WHERE user_id = $1",
+		"DELETE FROM seen_hints WHERE user_id = $1",
+		"DELETE FROM scim_identities WHERE user_id = $1",
+		"DELETE FROM corrections WHERE kind = 'profile' AND target_id = $1",
+		"UPDATE audit_log SET ip = '' WHERE actor_id = $1",
+		"UPDATE announcements SET read_by = array_remove(read_by, $1) WHERE $1 = ANY(read_by)",
+	} {
+		if _, err := tx.ExecContext(ctx, statement, userId); err != nil {
+			return err
+		}
+	}
+	for _, statement := range []string{
+		"UPDATE activity SET user_id = $2 WHERE user_id = $1",
+		"UPDATE announcements SET author_id = $2 WHERE author_id = $1",
+		"UPDATE audit_log SET actor_id = $2 WHERE actor_id = $1",
+		"UPDATE audit_log SET target_id = $2 WHERE target_id = $1",
+		"UPDATE invitations SET accepted_by = $2 WHERE accepted_by = $1",
+		"UPDATE guest_grants SET created_by = $2 WHERE created_by = $1",
+		"UPDATE guest_grants SET revoked_by = $2 WHERE revoked_by = $1",
+		"UPDATE corrections SET proposed_by = $2, proposer_name = '' WHERE proposed_by = $1",
+		"UPDATE corrections SET decided_by = $2 WHERE decided_by = $1",
+	} {
+		if _, err := tx.ExecContext(ctx, statement, userId, DeletedUserId); err != nil {
+			return err
+		}
+	}
+	// Invitations sent to the email of the user can no longer be accepted, and guest grants given to it are revoked
+	if _, err := tx.ExecContext(ctx, "UPDATE invitations SET email = '' WHERE email <> '' AND lower(email) = lower($1)", email); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE guest_grants SET email = '', revoked_at = COALESCE(revoked_at, $2),
+		revoked_by = CASE WHEN revoked_at IS NULL THEN $3 ELSE revoked_by END
+		WHERE email <> '' AND lower(email) = lower($1)`,
+		email, time.Now(), DeletedUserId,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO permissions_versions (user_id, version) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET version = excluded.version`,
+		userId, newPermissionsVersion(),
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p *PostgresStore) CountFeature(ctx context.Context, orgId string, feature Feature, at time.Time) error {
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO feature_counts (org_id, week, feature, count) VALUES ($1, $2, $3, 1)
+		ON CONFLICT (org_id, week, feature) DO UPDATE SET count = feature_counts.count + 1`,
+		orgId, IsoWeek(at), string(feature),
+	)
+	return err
+}
+
+func (p *PostgresStore) FeatureCounts(ctx context.Context, since time.Time) ([]FeatureCount, error) {
+	rows, err := p.db().QueryContext(ctx, "SELECT org_id, week, feature, count FROM feature_counts WHERE week >= $1", IsoWeek(since))
+	if err != nil {
+		return []FeatureCount{}, err
+	}
+	defer rows.Close()
+
+	counts := []FeatureCount{}
+	for rows.Next() {
+		var count FeatureCount
+		if err := rows.Scan(&count.OrgId, &count.Week, &count.Feature, &count.Count); err != nil {
+			return counts, err
+		}
+		counts = append(counts, count)
+	}
+	SortFeatureCounts(counts)
+	return counts, rows.Err()
+}
+
+func (p *PostgresStore) RecordActivity(ctx context.Context, orgId string, activity *Activity) error {
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO activity (org_id, id, project_id, kind, resource_ids, user_id, time) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (org_id, id) DO NOTHING`,
+		orgId, activity.Id, activity.ProjectId, string(activity.Kind), textArray(activity.ResourceIds), activity.UserId, activity.Time,
+	)
+	return err
+}
+
+func (p *PostgresStore) ProjectActivity(ctx context.Context, orgId, projectId string) ([]Activity, error) {
+	rows, err := p.db().QueryContext(
+		ctx,
+		`SELECT id, project_id, kind, resource_ids, user_id, time FROM activity
+		WHERE org_id = $1 AND project_id = $2 ORDER BY time DESC`,
+		orgId, projectId,
+	)
+	if err != nil {
+		return []Activity{}, err
+	}
+	defer rows.Close()
+
+	activities := []Activity{}
+	for rows.Next() {
+		var activity Activity
+		if err := rows.Scan(&activity.Id, &activity.ProjectId, &activity.Kind, pq.Array(&activity.ResourceIds), &activity.UserId, &activity.Time); err != nil {
+			return activities, err
+		}
+		activities = append(activities, activity)
+	}
+	return activities, rows.Err()
+}
+
+func (p *PostgresStore) OrganizationActivity(ctx context.Context, orgId string, from, to time.Time) ([]Activity, error) {
+	rows, err := p.db().QueryContext(
+		ctx,
+		`SELECT id, project_id, kind, resource_ids, user_id, time FROM activity
+		WHERE org_id = $1 AND time >= $2 AND time < $3 ORDER BY time DESC`,
+		orgId, from, to,
+	)
+	if err != nil {
+		return []Activity{}, err
+	}
+	defer rows.Close()
+
+	activities := []Activity{}
+	for rows.Next() {
+		var activity Activity
+		if err := rows.Scan(&activity.Id, &activity.ProjectId, &activity.Kind, pq.Array(&activity.ResourceIds), &activity.UserId, &activity.Time); err != nil {
+			return activities, err
+		}
+		activities = append(activities, activity)
+	}
+	return activities, rows.Err()
+}
+
+func (p *PostgresStore) Audit(ctx context.Context, orgId string, entry *AuditEntry) error {
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO audit_log (org_id, id, action, actor_id, target_id, detail, ip, time) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (org_id, id) DO NOTHING`,
+		orgId, entry.Id, string(entry.Action), entry.ActorId, entry.TargetId, entry.Detail, entry.IP, entry.Time,
+	)
+	return err
+}
+
+func (p *PostgresStore) AuditLog(ctx context.Context, orgId string, from, to time.Time) ([]AuditEntry, error) {
+	rows, err := p.db().QueryContext(
+		ctx,
+		`SELECT id, action, actor_id, target_id, detail, ip, time FROM audit_log
+		WHERE org_id = $1 AND time >= $2 AND time < $3 ORDER BY time DESC`,
+		orgId, from, to,
+	)
+	if err != nil {
+		return []AuditEntry{}, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		if err := rows.Scan(&entry.Id, &entry.Action, &entry.ActorId, &entry.TargetId, &entry.Detail, &entry.IP, &entry.Time); err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (p *PostgresStore) RemoveActivity(ctx context.Context, orgId string, kind ActivityKind, before time.Time) (int, error) {
+	result, err := p.db().ExecContext(ctx, `DELETE FROM activity WHERE org_id = $1 AND kind = $2 AND time < $3`, orgId, string(kind), before)
+	if err != nil {
+		return 0, err
+	}
+	num, err := result.RowsAffected()
+	return int(num), err
+}
+
+// nullTime stores the zero time as NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func (p *PostgresStore) SubmitAnnouncement(ctx context.Context, orgId string, announcement *Announcement) error {
+	if err := announcement.Validate(); err != nil {
+		return err
+	}
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO announcements (org_id, id, title, body, author_id, created_at, expires_at, read_by) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (org_id, id) DO UPDATE SET title = excluded.title, body = excluded.body, expires_at = excluded.expires_at`,
+		orgId, announcement.Id, announcement.Title, announcement.Body, announcement.AuthorId, announcement.CreatedAt,
+		nullTime(announcement.ExpiresAt), textArray(announcement.ReadBy),
+	)
+	return err
+}
+
+func (p *PostgresStore) Announcements(ctx context.Context, orgId string) ([]Announcement, error) {
+	rows, err := p.db().QueryContext(
+		ctx,
+		`SELECT id, title, body, author_id, created_at, expires_at, read_by FROM announcements
+		WHERE org_id = $1 ORDER BY created_at DESC`,
+		orgId,
+	)
+	if err != nil {
+		return []Announcement{}, err
+	}
+	defer rows.Close()
+
+	announcements := []Announcement{}
+	for rows.Next() {
+		var (
+			announcement Announcement
+			expiresAt    sql.NullTime
+		)
+		if err := rows.Scan(&announcement.Id, &announcement.Title, &announcement.Body, &announcement.AuthorId, &announcement.CreatedAt, &expiresAt, pq.Array(&announcement.ReadBy)); err != nil {
+			return announcements, err
+		}
+		announcement.ExpiresAt = expiresAt.Time
+		announcements = append(announcements, announcement)
+	}
+	return announcements, rows.Err()
+}
+
+func (p *PostgresStore) ExpireAnnouncement(ctx context.Context, orgId, id string, at time.Time) error {
+	result, err := p.db().ExecContext(ctx, "UPDATE announcements SET expires_at = $3 WHERE org_id = $1 AND id = $2", orgId, id, at)
+	return expectRows(result, err, announcementNotFound(id))
+}
+
+func (p *PostgresStore) MarkAnnouncementRead(ctx context.Context, orgId, id, userId string) error {
+	result, err := p.db().ExecContext(
+		ctx,
+		`UPDATE announcements SET read_by = CASE WHEN $3 = ANY(read_by) THEN read_by ELSE array_append(read_by, $3) END
+		WHERE org_id = $1 AND id = $2`,
+		orgId, id, userId,
+	)
+	return expectRows(result, err, announcementNotFound(id))
+}
+
+func (p *PostgresStore) SubmitProjectTemplate(ctx context.Context, orgId string, template *ProjectTemplate) error {
+	if err := template.Validate(); err != nil {
+		return err
+	}
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO project_templates (org_id, id, name, categories, announcement, groups, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (org_id, id) DO UPDATE
+		SET name = excluded.name, categories = excluded.categories, announcement = excluded.announcement,
+		groups = excluded.groups, updated_at = excluded.updated_at`,
+		orgId, template.Id(), template.Name, textArray(template.Categories), template.Announcement, textArray(template.Groups), template.UpdatedAt,
+	)
+	return err
+}
+
+const projectTemplateColumns = "name, categories, announcement, groups, updated_at"
+
+func scanProjectTemplate(row interface{ Scan(...any) error }) (ProjectTemplate, error) {
+	var template ProjectTemplate
+	err := row.Scan(&template.Name, pq.Array(&template.Categories), &template.Announcement, pq.Array(&template.Groups), &template.UpdatedAt)
+	return template, err
+}
+
+func (p *PostgresStore) ProjectTemplates(ctx context.Context, orgId string) ([]ProjectTemplate, error) {
+	rows, err := p.db().QueryContext(ctx, "SELECT "+projectTemplateColumns+" FROM project_templates WHERE org_id = $1 ORDER BY id", orgId)
+	if err != nil {
+		return []ProjectTemplate{}, err
+	}
+	defer rows.Close()
+
+	templates := []ProjectTemplate{}
+	for rows.Next() {
+		template, err := scanProjectTemplate(rows)
+		if err != nil {
+			return templates, err
+		}
+		templates = append(templates, template)
+	}
+	return templates, rows.Err()
+}
+
+func (p *PostgresStore) ProjectTemplateById(ctx context.Context, orgId, id string) (*ProjectTemplate, error) {
+	row := p.db().QueryRowContext(ctx, "SELECT "+projectTemplateColumns+" FROM project_templates WHERE org_id = $1 AND id = $2", orgId, id)
+	template, err := scanProjectTemplate(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &ProjectTemplate{}, projectTemplateNotFound(id)
+	}
+	return &template, err
+}
+
+func (p *PostgresStore) DeleteProjectTemplate(ctx context.Context, orgId, id string) error {
+	result, err := p.db().ExecContext(ctx, "DELETE FROM project_templates WHERE org_id = $1 AND id = $2", orgId, id)
+	return expectRows(result, err, projectTemplateNotFound(id))
+}
+
+func (p *PostgresStore) SubmitProblemReport(ctx context.Context, orgId string, report *ProblemReport) error {
+	if err := report.Validate(); err != nil {
+		return err
+	}
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO problem_reports (org_id, id, resource_id, resource_title, part, kind, description, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		orgId, report.Id, report.ResourceId, report.ResourceTitle, report.Part, report.Kind, report.Description, report.Status,
+		report.CreatedAt, report.UpdatedAt,
+	)
+	return err
+}
+
+func (p *PostgresStore) ProblemReports(ctx context.Context, orgId string) ([]ProblemReport, error) {
+	rows, err := p.db().QueryContext(
+		ctx,
+		`SELECT id, resource_id, resource_title, part, kind, description, status, created_at, updated_at FROM problem_reports
+		WHERE org_id = $1 ORDER BY created_at DESC`,
+		orgId,
+	)
+	if err != nil {
+		return []ProblemReport{}, err
+	}
+	defer rows.Close()
+
+	reports := []ProblemReport{}
+	for rows.Next() {
+		var report ProblemReport
+		if err := rows.Scan(&report.Id, &report.ResourceId, &report.ResourceTitle, &report.Part, &report.Kind, &report.Description, &report.Status, &report.CreatedAt, &report.UpdatedAt); err != nil {
+			return reports, err
+		}
+		reports = append(reports, report)
+	}
+	SortProblemReports(reports)
+	return reports, rows.Err()
+}
+
+func (p *PostgresStore) SetProblemReportStatus(ctx context.Context, orgId, id string, status ProblemStatus, at time.Time) error {
+	if err := ValidateProblemStatus(status); err != nil {
+		return err
+	}
+	result, err := p.db().ExecContext(ctx, "UPDATE problem_reports SET status = $3, updated_at = $4 WHERE org_id = $1 AND id = $2", orgId, id, status, at)
+	return expectRows(result, err, problemReportNotFound(id))
+}
+
+const invitationColumns = "org_id, id, email, role, group_name, status, created_at, expires_at, accepted_at, accepted_by"
+
+func scanInvitation(row interface{ Scan(...any) error }) (Invitation, error) {
+	var (
+		invitation Invitation
+		acceptedAt sql.NullTime
+	)
+	err := row.Scan(
+		&invitation.OrgId, &invitation.Id, &invitation.Email, &invitation.Role, &invitation.Group, &invitation.Status,
+		&invitation.CreatedAt, &invitation.ExpiresAt, &acceptedAt, &invitation.AcceptedBy,
+	)
+	invitation.AcceptedAt = acceptedAt.Time
+	return invitation, err
+}
+
+func (p *PostgresStore) SaveInvitation(ctx context.Context, invitation *Invitation) error {
+	if err := invitation.Validate(); err != nil {
+		return err
+	}
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO invitations (`+invitationColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (org_id, id) DO UPDATE SET status = excluded.status, accepted_at = excluded.accepted_at, accepted_by = excluded.accepted_by`,
+		invitation.OrgId, invitation.Id, invitation.Email, invitation.Role, invitation.Group, invitation.Status,
+		invitation.CreatedAt, invitation.ExpiresAt, nullTime(invitation.AcceptedAt), invitation.AcceptedBy,
+	)
+	return err
+}
+
+func (p *PostgresStore) Invitation(ctx context.Context, orgId, id string) (*Invitation, error) {
+	row := p.db().QueryRowContext(ctx, "SELECT "+invitationColumns+" FROM invitations WHERE org_id = $1 AND id = $2", orgId, id)
+	invitation, err := scanInvitation(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Invitation{}, invitationNotFound(id)
+	}
+	return &invitation, err
+}
+
+func (p *PostgresStore) Invitations(ctx context.Context, orgId string) ([]Invitation, error) {
+	rows, err := p.db().QueryContext(ctx, "SELECT "+invitationColumns+" FROM invitations WHERE org_id = $1 ORDER BY created_at DESC", orgId)
+	if err != nil {
+		return []Invitation{}, err
+	}
+	defer rows.Close()
+
+	invitations := []Invitation{}
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return invitations, err
+		}
+		invitations = append(invitations, invitation)
+	}
+	return invitations, rows.Err()
+}
+
+func (p *PostgresStore) SaveScimIdentity(ctx context.Context, identity *ScimIdentity) error {
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO scim_identities (org_id, user_id, external_id, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (org_id, user_id) DO UPDATE SET external_id = excluded.external_id`,
+		identity.OrgId, identity.UserId, identity.ExternalId, identity.CreatedAt,
+	)
+	return err
+}
+
+func (p *PostgresStore) ScimIdentities(ctx context.Context, orgId string) ([]ScimIdentity, error) {
+	rows, err := p.db().QueryContext(ctx, "SELECT org_id, user_id, external_id, created_at FROM scim_identities WHERE org_id = $1", orgId)
+	if err != nil {
+		return []ScimIdentity{}, err
+	}
+	defer rows.Close()
+
+	identities := []ScimIdentity{}
+	for rows.Next() {
+		var identity ScimIdentity
+		if err := rows.Scan(&identity.OrgId, &identity.UserId, &identity.ExternalId, &identity.CreatedAt); err != nil {
+			return identities, err
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+const inviteLinkColumns = "org_id, id, created_by, created_at, expires_at, revoked_at, revoked_by"
+
+func scanInviteLink(row interface{ Scan(...any) error }) (InviteLink, error) {
+	var (
+		link      InviteLink
+		revokedAt sql.NullTime
+	)
+	err := row.Scan(&link.OrgId, &link.Id, &link.CreatedBy, &link.CreatedAt, &link.ExpiresAt, &revokedAt, &link.RevokedBy)
+	link.RevokedAt = revokedAt.Time
+	return link, err
+}
+
+func (p *PostgresStore) SaveInviteLink(ctx context.Context, link *InviteLink) error {
+	if err := link.Validate(); err != nil {
+		return err
+	}
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO invite_links (`+inviteLinkColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (org_id, id) DO UPDATE SET project_id = excluded.project_id, revoked_at = excluded.revoked_at, revoked_by = excluded.revoked_by`,
+		link.OrgId, link.Id, link.CreatedBy, link.CreatedAt, link.ExpiresAt, nullTime(link.RevokedAt), link.RevokedBy,
+	)
+	return err
+}
+
+func (p *PostgresStore) InviteLink(ctx context.Context, orgId, id string) (*InviteLink, error) {
+	row := p.db().QueryRowContext(ctx, "SELECT "+inviteLinkColumns+" FROM invite_links WHERE org_id = $1 AND id = $2", orgId, id)
+	link, err := scanInviteLink(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &InviteLink{}, inviteLinkNotFound(id)
+	}
+	return &link, err
+}
+
+func (p *PostgresStore) InviteLinks(ctx context.Context, orgId string) ([]InviteLink, error) {
+	rows, err := p.db().QueryContext(ctx, "SELECT "+inviteLinkColumns+" FROM invite_links WHERE org_id = $1 ORDER BY created_at DESC", orgId)
+	if err != nil {
+		return []InviteLink{}, err
+	}
+	defer rows.Close()
+
+	links := []InviteLink{}
+	for rows.Next() {
+		link, err := scanInviteLink(rows)
+		if err != nil {
+			return links, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+const guestGrantColumns = "org_id, id, email, resource_ids, project_id, watermark, created_by, created_at, expires_at, revoked_at, revoked_by"
+
+func scanGuestGrant(row interface{ Scan(...any) error }) (GuestGrant, error) {
+	var (
+		grant     GuestGrant
+		revokedAt sql.NullTime
+	)
+	err := row.Scan(
+		&grant.OrgId, &grant.Id, &grant.Email, pq.Array(&grant.ResourceIds), &grant.ProjectId, &grant.Watermark,
+		&grant.CreatedBy, &grant.CreatedAt, &grant.ExpiresAt, &revokedAt, &grant.RevokedBy,
+	)
+	grant.RevokedAt = revokedAt.Time
+	return grant, err
+}
+
+func (p *PostgresStore) SaveGuestGrant(ctx context.Context, grant *GuestGrant) error {
+	if err := grant.Validate(); err != nil {
+		return err
+	}
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO guest_grants (`+guestGrantColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (org_id, id) DO UPDATE SET revoked_at = excluded.revoked_at, revoked_by = excluded.revoked_by`,
+		grant.OrgId, grant.Id, grant.Email, textArray(grant.ResourceIds), grant.ProjectId, grant.Watermark,
+		grant.CreatedBy, grant.CreatedAt, grant.ExpiresAt, nullTime(grant.RevokedAt), grant.RevokedBy,
+	)
+	return err
+}
+
+func (p *PostgresStore) GuestGrant(ctx context.Context, orgId, id string) (*GuestGrant, error) {
+	row := p.db().QueryRowContext(ctx, "SELECT "+guestGrantColumns+" FROM guest_grants WHERE org_id = $1 AND id = $2", orgId, id)
+	grant, err := scanGuestGrant(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &GuestGrant{}, guestGrantNotFound(id)
+	}
+	return &grant, err
+}
+
+func (p *PostgresStore) GuestGrants(ctx context.Context, orgId string) ([]GuestGrant, error) {
+	rows, err := p.db().QueryContext(ctx, "SELECT "+guestGrantColumns+" FROM guest_grants WHERE org_id = $1 ORDER BY created_at DESC", orgId)
+	if err != nil {
+		return []GuestGrant{}, err
+	}
+	defer rows.Close()
+
+	grants := []GuestGrant{}
+	for rows.Next() {
+		grant, err := scanGuestGrant(rows)
+		if err != nil {
+			return grants, err
+		}
+		grants = append(grants, grant)
+	}
+	return grants, rows.Err()
+}
+
+func (p *PostgresStore) SaveWebhook(ctx context.Context, webhook *Webhook) error {
+	if err := webhook.Validate(); err != nil {
+		return err
+	}
+	events := make([]string, len(webhook.Events))
+	for i, event := range webhook.Events {
+		events[i] = string(event)
+	}
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO webhooks (org_id, url, secret, events, enabled, updated_by, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (org_id) DO UPDATE SET url = excluded.url, secret = excluded.secret, events = excluded.events,
+		enabled = excluded.enabled, updated_by = excluded.updated_by, updated_at = excluded.updated_at`,
+		webhook.OrgId, webhook.Url, webhook.Secret, textArray(events), webhook.Enabled, webhook.UpdatedBy, webhook.UpdatedAt,
+	)
+	return err
+}
+
+func (p *PostgresStore) Webhook(ctx context.Context, orgId string) (*Webhook, error) {
+	webhook := Webhook{OrgId: orgId}
+	var events []string
+	err := p.db().QueryRowContext(
+		ctx,
+		"SELECT url, secret, events, enabled, updated_by, updated_at FROM webhooks WHERE org_id = $1",
+		orgId,
+	).Scan(&webhook.Url, &webhook.Secret, pq.Array(&events), &webhook.Enabled, &webhook.UpdatedBy, &webhook.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Webhook{}, webhookNotFound(orgId)
+	} else if err != nil {
+		return &Webhook{}, err
+	}
+	for _, event := range events {
+		webhook.Events = append(webhook.Events, WebhookEvent(event))
+	}
+	return &webhook, nil
+}
+
+func (p *PostgresStore) DeleteWebhook(ctx context.Context, orgId string) error {
+	_, err := p.db().ExecContext(ctx, "DELETE FROM webhooks WHERE org_id = $1", orgId)
+	return err
+}
+
+const webhookDeliveryColumns = "org_id, id, event, target_id, url, attempts, status_code, error, succeeded, created_at"
+
+func (p *PostgresStore) RecordWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO webhook_deliveries (`+webhookDeliveryColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		delivery.OrgId, delivery.Id, string(delivery.Event), delivery.TargetId, delivery.Url, delivery.Attempts,
+		delivery.StatusCode, delivery.Error, delivery.Succeeded, delivery.CreatedAt,
+	)
+	return err
+}
+
+func (p *PostgresStore) WebhookDeliveries(ctx context.Context, orgId string, limit int) ([]WebhookDelivery, error) {
+	rows, err := p.db().QueryContext(
+		ctx,
+		"SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE org_id = $1 ORDER BY created_at DESC LIMIT $2",
+		orgId, limit,
+	)
+	if err != nil {
+		return []WebhookDelivery{}, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var delivery WebhookDelivery
+		err := rows.Scan(
+			&delivery.OrgId, &delivery.Id, &delivery.Event, &delivery.TargetId, &delivery.Url, &delivery.Attempts,
+			&delivery.StatusCode, &delivery.Error, &delivery.Succeeded, &delivery.CreatedAt,
+		)
+		if err != nil {
+			return deliveries, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+const correctionColumns = "id, kind, target_id, target_name, field, current_value, value, comment, proposed_by, proposer_name, status, created_at, decided_at, decided_by"
+
+func scanCorrection(row interface{ Scan(...any) error }) (Correction, error) {
+	var (
+		correction Correction
+		decidedAt  sql.NullTime
+	)
+	err := row.Scan(
+		&correction.Id, &correction.Kind, &correction.TargetId, &correction.TargetName, &correction.Field, &correction.Current,
+		&correction.Value, &correction.Comment, &correction.ProposedBy, &correction.ProposerName, &correction.Status,
+		&correction.CreatedAt, &decidedAt, &correction.DecidedBy,
+	)
+	correction.DecidedAt = decidedAt.Time
+	return correction, err
+}
+
+func (p *PostgresStore) SubmitCorrection(ctx context.Context, orgId string, correction *Correction) error {
+	if err := correction.Validate(); err != nil {
+		return err
+	}
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO corrections (org_id, `+correctionColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		ON CONFLICT (org_id, id) DO UPDATE SET status = excluded.status, decided_at = excluded.decided_at, decided_by = excluded.decided_by`,
+		orgId, correction.Id, correction.Kind, correction.TargetId, correction.TargetName, correction.Field, correction.Current,
+		correction.Value, correction.Comment, correction.ProposedBy, correction.ProposerName, correction.Status,
+		correction.CreatedAt, nullTime(correction.DecidedAt), correction.DecidedBy,
+	)
+	return err
+}
+
+func (p *PostgresStore) Correction(ctx context.Context, orgId, id string) (*Correction, error) {
+	row := p.db().QueryRowContext(ctx, "SELECT "+correctionColumns+" FROM corrections WHERE org_id = $1 AND id = $2", orgId, id)
+	correction, err := scanCorrection(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Correction{}, correctionNotFound(id)
+	}
+	return &correction, err
+}
+
+func (p *PostgresStore) Corrections(ctx context.Context, orgId string) ([]Correction, error) {
+	rows, err := p.db().QueryContext(ctx, "SELECT "+correctionColumns+" FROM corrections WHERE org_id = $1 ORDER BY created_at DESC", orgId)
+	if err != nil {
+		return []Correction{}, err
+	}
+	defer rows.Close()
+
+	corrections := []Correction{}
+	for rows.Next() {
+		correction, err := scanCorrection(rows)
+		if err != nil {
+			return corrections, err
+		}
+		corrections = append(corrections, correction)
+	}
+	SortCorrections(corrections)
+	return corrections, rows.Err()
+}
+
+func (p *PostgresStore) UpdateUserName(ctx context.Context, userId, name string) error {
+	result, err := p.db().ExecContext(ctx, "UPDATE users SET name = $2 WHERE id = $1", userId, name)
+	return expectRows(result, err, errors.Join(ErrUserNotFound, fmt.Errorf("user id: %s", userId)))
+}
+
+func (p *PostgresStore) SavePasskey(ctx context.Context, passkey *Passkey) error {
+	if err := passkey.Validate(); err != nil {
+		return err
+	}
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO passkeys (id, user_id, name, credential, created_at, last_used_at) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name, credential = excluded.credential, last_used_at = excluded.last_used_at`,
+		passkey.Id, passkey.UserId, passkey.Name, string(passkey.Credential), passkey.CreatedAt, passkey.LastUsedAt,
+	)
+	return err
+}
+
+func (p *PostgresStore) Passkeys(ctx context.Context, userId string) ([]Passkey, error) {
+	rows, err := p.db().QueryContext(
+		ctx,
+		"SELECT id, user_id, name, credential, created_at, last_used_at FROM passkeys WHERE user_id = $1 ORDER BY created_at",
+		userId,
+	)
+	if err != nil {
+		return []Passkey{}, err
+	}
+	defer rows.Close()
+
+	passkeys := []Passkey{}
+	for rows.Next() {
+		var passkey Passkey
+		if err := rows.Scan(&passkey.Id, &passkey.UserId, &passkey.Name, &passkey.Credential, &passkey.CreatedAt, &passkey.LastUsedAt); err != nil {
+			return passkeys, err
+		}
+		passkeys = append(passkeys, passkey)
+	}
+	return passkeys, rows.Err()
+}
+
+func (p *PostgresStore) DeletePasskey(ctx context.Context, userId, id string) error {
+	result, err := p.db().ExecContext(ctx, "DELETE FROM passkeys WHERE user_id = $1 AND id = $2", userId, id)
+	return expectRows(result, err, passkeyNotFound(id))
+}
+
+const apiTokenColumns = "hash, id, user_id, org_id, name, scope, passkey, created_at, expires_at"
+
+func scanApiToken(row interface{ Scan(...any) error }) (ApiToken, error) {
+	var (
+		token     ApiToken
+		expiresAt sql.NullTime
+	)
+	err := row.Scan(&token.Hash, &token.Id, &token.UserId, &token.OrgId, &token.Name, &token.Scope, &token.Passkey, &token.CreatedAt, &expiresAt)
+	token.ExpiresAt = expiresAt.Time
+	return token, err
+}
+
+func (p *PostgresStore) SaveApiToken(ctx context.Context, token *ApiToken) error {
+	if err := token.Validate(); err != nil {
+		return err
+	}
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO api_tokens (`+apiTokenColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (hash) DO UPDATE SET name = excluded.name, scope = excluded.scope, expires_at = excluded.expires_at`,
+		token.Hash, token.Id, token.UserId, token.OrgId, token.Name, token.Scope, token.Passkey, token.CreatedAt, nullTime(token.ExpiresAt),
+	)
+	return err
+}
+
+func (p *PostgresStore) ApiTokenByHash(ctx context.Context, hash string) (ApiToken, error) {
+	row := p.db().QueryRowContext(ctx, "SELECT "+apiTokenColumns+" FROM api_tokens WHERE hash = $1", hash)
+	token, err := scanApiToken(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return token, ErrApiTokenNotFound
+	}
+	return token, err
+}
+
+func (p *PostgresStore) ApiTokens(ctx context.Context, userId string) ([]ApiToken, error) {
+	rows, err := p.db().QueryContext(ctx, "SELECT "+apiTokenColumns+" FROM api_tokens WHERE user_id = $1 ORDER BY created_at DESC", userId)
+	if err != nil {
+		return []ApiToken{}, err
+	}
+	defer rows.Close()
+
+	tokens := []ApiToken{}
+	for rows.Next() {
+		token, err := scanApiToken(rows)
+		if err != nil {
+			return tokens, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (p *PostgresStore) RevokeApiToken(ctx context.Context, userId, id string) error {
+	result, err := p.db().ExecContext(ctx, "DELETE FROM api_tokens WHERE user_id = $1 AND id = $2", userId, id)
+	return expectRows(result, err, apiTokenNotFound(id))
+}
+
+const userSessionColumns = "id, user_id, user_agent, ip, created_at, last_seen_at"
+
+func scanUserSession(row interface{ Scan(...any) error }) (UserSession, error) {
+	var session UserSession
+	err := row.Scan(&session.Id, &session.UserId, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt)
+	return session, err
+}
+
+func (p *PostgresStore) RegisterSession(ctx context.Context, session *UserSession) error {
+	if err := session.Validate(); err != nil {
+		return err
+	}
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO user_sessions (`+userSessionColumns+`) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (id) DO UPDATE SET user_agent = excluded.user_agent, ip = excluded.ip, last_seen_at = excluded.last_seen_at`,
+		session.Id, session.UserId, session.UserAgent, session.IP, session.CreatedAt, session.LastSeenAt,
+	)
+	return err
+}
+
+func (p *PostgresStore) Session(ctx context.Context, id string) (UserSession, error) {
+	row := p.db().QueryRowContext(ctx, "SELECT "+userSessionColumns+" FROM user_sessions WHERE id = $1", id)
+	session, err := scanUserSession(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return session, userSessionNotFound(id)
+	}
+	return session, err
+}
+
+func (p *PostgresStore) Sessions(ctx context.Context, userId string) ([]UserSession, error) {
+	rows, err := p.db().QueryContext(ctx, "SELECT "+userSessionColumns+" FROM user_sessions WHERE user_id = $1 ORDER BY last_seen_at DESC", userId)
+	if err != nil {
+		return []UserSession{}, err
+	}
+	defer rows.Close()
+
+	sessions := []UserSession{}
+	for rows.Next() {
+		session, err := scanUserSession(rows)
+		if err != nil {
+			return sessions, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (p *PostgresStore) RevokeSession(ctx context.Context, userId, id string) error {
+	result, err := p.db().ExecContext(ctx, "DELETE FROM user_sessions WHERE user_id = $1 AND id = $2", userId, id)
+	return expectRows(result, err, userSessionNotFound(id))
+}
+
+func (p *PostgresStore) SessionGeneration(ctx context.Context, userId string) (int64, error) {
+	var generation int64
+	err := p.db().QueryRowContext(ctx, "SELECT generation FROM session_generations WHERE user_id = $1", userId).Scan(&generation)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return generation, err
+}
+
+func (p *PostgresStore) NewSessionGeneration(ctx context.Context, userId string) (int64, error) {
+	generation := newSessionGeneration()
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO session_generations (user_id, generation) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET generation = excluded.generation`,
+		userId, generation,
+	)
+	return generation, err
+}
+
+func (p *PostgresStore) SeenHints(ctx context.Context, userId string) ([]Hint, error) {
+	rows, err := p.db().QueryContext(ctx, "SELECT hint FROM seen_hints WHERE user_id = $1 ORDER BY seen_at", userId)
+	if err != nil {
+		return []Hint{}, err
+	}
+	defer rows.Close()
+
+	hints := []Hint{}
+	for rows.Next() {
+		var hint Hint
+		if err := rows.Scan(&hint); err != nil {
+			return hints, err
+		}
+		hints = append(hints, hint)
+	}
+	return hints, rows.Err()
+}
+
+func (p *PostgresStore) MarkHintSeen(ctx context.Context, userId string, hint Hint) error {
+	if err := ValidateHint(hint); err != nil {
+		return err
+	}
+	_, err := p.db().ExecContext(
+		ctx,
+		"INSERT INTO seen_hints (user_id, hint, seen_at) VALUES ($1, $2, $3) ON CONFLICT (user_id, hint) DO NOTHING",
+		userId, hint, time.Now(),
+	)
+	return err
+}
+
+func (p *PostgresStore) StoreResourceText(ctx context.Context, orgId string, text *ResourceText) error {
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO resource_texts (org_id, resource_id, words, extracted_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (org_id, resource_id) DO UPDATE SET words = excluded.words, extracted_at = excluded.extracted_at`,
+		orgId, text.ResourceId, textArray(text.Words), text.ExtractedAt,
+	)
+	return err
+}
+
+func (p *PostgresStore) ResourceTexts(ctx context.Context, orgId string) ([]ResourceText, error) {
+	rows, err := p.db().QueryContext(ctx, "SELECT resource_id, words, extracted_at FROM resource_texts WHERE org_id = $1", orgId)
+	if err != nil {
+		return []ResourceText{}, err
+	}
+	defer rows.Close()
+
+	texts := []ResourceText{}
+	for rows.Next() {
+		var text ResourceText
+		if err := rows.Scan(&text.ResourceId, pq.Array(&text.Words), &text.ExtractedAt); err != nil {
+			return texts, err
+		}
+		texts = append(texts, text)
+	}
+	return texts, rows.Err()
+}
+
+func (p *PostgresStore) RecentProjects(ctx context.Context, orgId string, limit int) ([]Project, error) {
+	rows, err := p.db().QueryContext(
+		ctx,
+		"SELECT "+projectColumns+" FROM projects WHERE org_id = $1 ORDER BY updated_at DESC LIMIT $2",
+		orgId, limit,
+	)
+	if err != nil {
+		return []Project{}, err
+	}
+	defer rows.Close()
+
+	projects := []Project{}
+	for rows.Next() {
+		project, err := scanProject(rows)
+		if err != nil {
+			return projects, err
+		}
+		projects = append(projects, project)
+	}
+	return projects, rows.Err()
+}
+
+func (p *PostgresStore) RecentResources(ctx context.Context, orgId string, limit int) ([]MetaData, error) {
+	rows, err := p.db().QueryContext(
+		ctx,
+		`SELECT data FROM metadata
+		WHERE org_id = $1 AND coalesce(data ->> 'status', '') NOT IN ($2, $3)
+		AND NOT coalesce((data ->> 'deleted')::boolean, false)
+		ORDER BY (data ->> 'submitted_at')::timestamptz DESC NULLS LAST LIMIT $4`,
+		orgId, string(StoreStatusPending), string(StoreStatusFailed), limit,
+	)
+	if err != nil {
+		return []MetaData{}, err
+	}
+	defer rows.Close()
+
+	result := []MetaData{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return result, err
+		}
+		var meta MetaData
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return result, err
+		}
+		result = append(result, meta)
+	}
+	return result, rows.Err()
+}
+
+func (p *PostgresStore) NumMembers(ctx context.Context, orgId string) (int, error) {
+	var count int
+	err := p.db().QueryRowContext(ctx, "SELECT count(*) FROM memberships WHERE org_id = $1 AND NOT deleted", orgId).Scan(&count)
+	return count, err
+}
+
+func (p *PostgresStore) Onboarding(ctx context.Context, orgId string) (*Onboarding, error) {
+	onboarding := NewOnboarding()
+	var completed []string
+	err := p.db().QueryRowContext(
+		ctx,
+		"SELECT completed, instruments, dismissed, updated_at FROM onboarding WHERE org_id = $1",
+		orgId,
+	).Scan(pq.Array(&completed), pq.Array(&onboarding.Instruments), &onboarding.Dismissed, &onboarding.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return onboarding, onboardingNotFound(orgId)
+	} else if err != nil {
+		return onboarding, err
+	}
+	for _, step := range completed {
+		onboarding.Completed = append(onboarding.Completed, OnboardingStep(step))
+	}
+	return onboarding, nil
+}
+
+func (p *PostgresStore) SaveOnboarding(ctx context.Context, orgId string, onboarding *Onboarding) error {
+	completed := make([]string, len(onboarding.Completed))
+	for i, step := range onboarding.Completed {
+		completed[i] = string(step)
+	}
+	_, err := p.db().ExecContext(
+		ctx,
+		`INSERT INTO onboarding (org_id, completed, instruments, dismissed, updated_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (org_id) DO UPDATE SET completed = excluded.completed, instruments = excluded.instruments,
+		dismissed = excluded.dismissed, updated_at = excluded.updated_at`,
+		orgId, textArray(completed), textArray(onboarding.Instruments), onboarding.Dismissed, onboarding.UpdatedAt,
+	)
+	return err
+}
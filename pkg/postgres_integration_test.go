@@ -0,0 +1,319 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+// newPostgresIntegrationStore connects to the database given by CAESURA_TEST_POSTGRES_DSN. All tables are
+// emptied such that each test starts from a clean database
+func newPostgresIntegrationStore(t *testing.T) *PostgresStore {
+	dsn, ok := os.LookupEnv("CAESURA_TEST_POSTGRES_DSN")
+	if !ok {
+		t.Skip("CAESURA_TEST_POSTGRES_DSN is not set")
+	}
+
+	ctx := context.Background()
+	store, err := NewPostgresStore(ctx, &PostgresConfig{DSN: dsn, Directory: t.TempDir()})
+	testutils.AssertNil(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	_, err = store.DB.ExecContext(ctx, "TRUNCATE organizations, subscriptions, users, memberships, metadata, projects, feature_counts, activity, announcements, permissions_versions, resource_texts, onboarding, user_sessions, seen_hints, temp_artifacts, invitations, invite_links, corrections, audit_log, guest_grants, webhooks, webhook_deliveries, scim_identities")
+	testutils.AssertNil(t, err)
+	return store
+}
+
+func TestPostgresMetadataAndProjects(t *testing.T) {
+	store := newPostgresIntegrationStore(t)
+	ctx := context.Background()
+	parts := func(yield func(string, []byte) bool) {
+		yield("Part1.pdf", []byte("Part1"))
+	}
+
+	for _, meta := range []MetaData{
+		{Title: "Symphony no. 5", Composer: "Beethoven"},
+		{Title: "Brandenburg Concerto", Composer: "Bach", Arranger: "Smith"},
+		{Title: "Hymn", Composer: "Traditional", Arranger: "Johnson"},
+	} {
+		testutils.AssertNil(t, store.Submit(ctx, "org", &meta, parts))
+	}
+
+	// Substring matches are found, not only prefixes
+	found, err := store.MetaByPattern(ctx, "org", &MetaData{Title: "concerto"})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(found), 1)
+	testutils.AssertEqual(t, found[0].Status, StoreStatusFinished)
+
+	found, err = store.MetaByPattern(ctx, "org", &MetaData{Composer: "BEET", Arranger: "john"})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(found), 2)
+
+	found, err = store.MetaByPattern(ctx, "org", &MetaData{})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(found), 3)
+
+	page, err := store.MetaByPatternPage(ctx, "org", &MetaData{}, MetaSort{}, PageRequest{Limit: 2})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(page.Items), 2)
+	page, err = store.MetaByPatternPage(ctx, "org", &MetaData{}, MetaSort{}, PageRequest{Limit: 2, Cursor: page.NextCursor})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(page.Items), 1)
+	testutils.AssertEqual(t, page.NextCursor, "")
+
+	byComposer := MetaSort{Field: SortByComposer, Descending: true}
+	page, err = store.MetaByPatternPage(ctx, "org", &MetaData{}, byComposer, PageRequest{Limit: 2})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, page.Items[0].Composer, "Traditional")
+	page, err = store.MetaByPatternPage(ctx, "org", &MetaData{}, byComposer, PageRequest{Limit: 2, Cursor: page.NextCursor})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(page.Items), 1)
+	testutils.AssertEqual(t, page.Items[0].Composer, "Bach")
+
+	page, err = store.MetaByPatternPage(ctx, "org", &MetaData{}, MetaSort{Field: SortByUpdated}, PageRequest{Limit: 1})
+	testutils.AssertNil(t, err)
+	page, err = store.MetaByPatternPage(ctx, "org", &MetaData{}, MetaSort{Field: SortByUpdated}, PageRequest{Limit: 2, Cursor: page.NextCursor})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(page.Items), 2)
+
+	resourceId := found[0].ResourceId()
+	lookups := store.MetaByIds(ctx, "org", []string{found[2].ResourceId(), "missing", resourceId})
+	testutils.AssertEqual(t, lookups[0].Meta.Title, found[2].Title)
+	testutils.AssertEqual(t, errors.Is(lookups[1].Err, ErrResourceMetadataNotFound), true)
+	testutils.AssertEqual(t, lookups[2].Meta.Title, found[0].Title)
+
+	content := maps.Collect(store.Resource(ctx, "org", resourceId))
+	testutils.AssertEqual(t, string(content["Part1.pdf"]), "Part1")
+
+	// Uploading again keeps the previous parts as a version
+	testutils.AssertNil(t, store.Submit(ctx, "org", &found[0], parts))
+	versions, err := store.ResourceVersions(ctx, "org", resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(versions), 1)
+	testutils.AssertNil(t, store.RestoreVersion(ctx, "org", resourceId, 1))
+
+	testutils.AssertNil(t, store.SetProtected(ctx, "org", resourceId, true))
+	testutils.AssertEqual(t, errors.Is(store.DeleteResource(ctx, "org", resourceId), ErrResourceProtected), true)
+	testutils.AssertEqual(t, errors.Is(store.SetProtected(ctx, "org", "missing", true), ErrResourceMetadataNotFound), true)
+
+	project := Project{Name: "Spring concert", ResourceIds: []string{resourceId, "other"}, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	testutils.AssertNil(t, store.SubmitProject(ctx, "org", &project))
+	testutils.AssertNil(t, store.SetProjectNote(ctx, "org", project.Id(), resourceId, "Start at letter C"))
+	testutils.AssertNil(t, store.SetProjectNote(ctx, "org", project.Id(), "other", "Repeat the coda"))
+	testutils.AssertNil(t, store.RemoveResource(ctx, "org", project.Id(), "other"))
+
+	projects, err := store.ProjectsByName(ctx, "org", "concert")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(projects), 1)
+	testutils.AssertEqual(t, strings.Join(projects[0].ResourceIds, ","), resourceId)
+	testutils.AssertEqual(t, len(projects[0].Notes), 1)
+	testutils.AssertEqual(t, projects[0].Notes[resourceId], "Start at letter C")
+
+	// Submitting the project again keeps notes that are not part of the submitted project
+	testutils.AssertNil(t, store.SubmitProject(ctx, "org", &project))
+	testutils.AssertNil(t, store.SetProjectNote(ctx, "org", project.Id(), resourceId, ""))
+	stored, err := store.ProjectById(ctx, "org", project.Id())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(stored.Notes), 0)
+	testutils.AssertEqual(t, errors.Is(store.SetProjectNote(ctx, "org", "missing", resourceId, "note"), ErrProjectNotFound), true)
+
+	_, err = store.ProjectById(ctx, "org", "missing")
+	testutils.AssertEqual(t, errors.Is(err, ErrProjectNotFound), true)
+}
+
+func TestPostgresUsersAndOrganizations(t *testing.T) {
+	store := newPostgresIntegrationStore(t)
+	ctx := context.Background()
+
+	org := Organization{Id: "org1", Name: "Band", StripeId: "stripe-id", Branding: Branding{PrimaryColor: "#112233"}}
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &org))
+	testutils.AssertNil(t, store.UpdateDomain(ctx, "org1", "band.example.com"))
+	byDomain, err := store.OrganizationByDomain(ctx, "band.example.com")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, byDomain.Branding.PrimaryColor, "#112233")
+
+	subscription := Subscription{Id: "sub", Expires: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+	testutils.AssertNil(t, store.StoreSubscription(ctx, "stripe-id", &subscription))
+	receivedSub, err := store.GetSubscription(ctx, "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, receivedSub.Expires.Equal(subscription.Expires), true)
+
+	user := UserInfo{
+		Id:     "user-id",
+		Name:   "Alice",
+		Email:  "alice@example.com",
+		Roles:  map[string]RoleKind{"org1": RoleEditor},
+		Groups: map[string][]string{"org1": {"trumpet"}},
+	}
+	testutils.AssertNil(t, store.RegisterUser(ctx, &user))
+	testutils.AssertNil(t, store.RegisterGroup(ctx, "user-id", "org1", "brass"))
+	testutils.AssertNil(t, store.RegisterGroup(ctx, "user-id", "org1", "brass"))
+	testutils.AssertEqual(t, errors.Is(store.RegisterGroup(ctx, "user-id", "org2", "brass"), ErrUserNotFound), true)
+
+	byEmail, err := store.UserByEmail(ctx, "Alice@example.com")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(byEmail.Groups["org1"], ","), "trumpet,brass")
+
+	members, err := store.GetUsersInOrg(ctx, "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(members), 1)
+	testutils.AssertEqual(t, members[0].Name, "Alice")
+	testutils.AssertEqual(t, members[0].Roles["org1"], RoleEditor)
+
+	memberPage, err := store.UsersInOrgPage(ctx, "org1", PageRequest{Limit: 1})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(memberPage.Items), 1)
+	testutils.AssertEqual(t, memberPage.NextCursor, "")
+
+	testutils.AssertNil(t, store.DeleteRole(ctx, "user-id", "org1"))
+	members, err = store.GetUsersInOrg(ctx, "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(members), 0)
+
+	_, err = store.GetUserInfo(ctx, "missing")
+	testutils.AssertEqual(t, errors.Is(err, ErrUserNotFound), true)
+}
+
+func TestPostgresMetricsAndActivity(t *testing.T) {
+	store := newPostgresIntegrationStore(t)
+	ctx := context.Background()
+	now := time.Date(2025, 6, 11, 0, 0, 0, 0, time.UTC)
+	for range 3 {
+		testutils.AssertNil(t, store.CountFeature(ctx, "org1", FeatureUpload, now))
+	}
+	counts, err := store.FeatureCounts(ctx, now)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(counts), 1)
+	testutils.AssertEqual(t, counts[0].Count, 3)
+
+	first := Activity{Id: "1", ProjectId: "project", Kind: ActivityPieceAdded, UserId: "user", Time: now}
+	second := Activity{Id: "2", ProjectId: "project", Kind: ActivityDownload, UserId: "user", Time: now.Add(time.Hour)}
+	testutils.AssertNil(t, store.RecordActivity(ctx, "org1", &first))
+	testutils.AssertNil(t, store.RecordActivity(ctx, "org1", &second))
+
+	activities, err := store.ProjectActivity(ctx, "org1", "project")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(activities), 2)
+	testutils.AssertEqual(t, activities[0].Id, "2")
+}
+
+func TestPostgresRemoveActivity(t *testing.T) {
+	assertActivityRemover(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresAnnouncements(t *testing.T) {
+	assertAnnouncementStore(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresResourceTexts(t *testing.T) {
+	assertResourceTextStore(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresPermissionsVersions(t *testing.T) {
+	assertPermissionsVersions(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresTransaction(t *testing.T) {
+	assertTransactor(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresProjectRename(t *testing.T) {
+	assertProjectRename(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresProjectOrder(t *testing.T) {
+	assertProjectOrder(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresProjectTemplates(t *testing.T) {
+	assertProjectTemplateStore(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresProblemReports(t *testing.T) {
+	assertProblemReportStore(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresPasskeys(t *testing.T) {
+	assertPasskeyStore(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresPasskeyRequirement(t *testing.T) {
+	assertPasskeyRequirement(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresApiTokens(t *testing.T) {
+	assertApiTokenStore(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresSessionRegistry(t *testing.T) {
+	assertSessionRegistry(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresMarkEmailVerified(t *testing.T) {
+	assertEmailVerifier(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresHintStore(t *testing.T) {
+	assertHintStore(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresAccountEraser(t *testing.T) {
+	assertAccountEraser(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresDashboardStore(t *testing.T) {
+	store := newPostgresIntegrationStore(t)
+	assertDashboardStore(t, store, func(orgId string, meta *MetaData) {
+		testutils.AssertNil(t, store.storeMeta(context.Background(), orgId, meta))
+	})
+}
+
+func TestPostgresOnboarding(t *testing.T) {
+	assertOnboardingStore(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresArchiveStore(t *testing.T) {
+	assertArchiveStore(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresTempArtifactStore(t *testing.T) {
+	assertTempArtifactStore(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresInvitationStore(t *testing.T) {
+	assertInvitationStore(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresScimIdentityStore(t *testing.T) {
+	assertScimIdentityStore(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresInviteLinkStore(t *testing.T) {
+	assertInviteLinkStore(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresGuestGrantStore(t *testing.T) {
+	assertGuestGrantStore(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresContentHash(t *testing.T) {
+	assertContentHash(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresWebhookStore(t *testing.T) {
+	assertWebhookStore(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresAuditLog(t *testing.T) {
+	assertAuditLogStore(t, newPostgresIntegrationStore(t))
+}
+
+func TestPostgresCorrectionStore(t *testing.T) {
+	assertCorrectionStore(t, newPostgresIntegrationStore(t))
+}
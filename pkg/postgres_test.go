@@ -0,0 +1,144 @@
+package pkg
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestPostgresMigrationList(t *testing.T) {
+	migrations, err := postgresMigrationList()
+	testutils.AssertNil(t, err)
+	if len(migrations) == 0 {
+		t.Fatal("Wanted at least one migration")
+	}
+
+	for i, migration := range migrations {
+		testutils.AssertEqual(t, migration.Version, i+1)
+	}
+	testutils.AssertContains(t, migrations[0].SQL, "CREATE TABLE metadata", "CREATE TABLE memberships")
+}
+
+func TestLikePattern(t *testing.T) {
+	for _, test := range []struct {
+		input string
+		want  string
+	}{
+		{"Mozart", "%Mozart%"},
+		{"", "%%"},
+		{"100%_sure", `%100\%\_sure%`},
+		{`back\slash`, `%back\\slash%`},
+	} {
+		testutils.AssertEqual(t, likePattern(test.input), test.want)
+	}
+}
+
+func TestMetaSearchQuery(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		pattern   MetaData
+		wantWhere string
+		wantArgs  []string
+	}{
+		{
+			name:      "all resources",
+			wantWhere: "WHERE org_id = $1 ORDER BY",
+			wantArgs:  []string{"org"},
+		},
+		{
+			name:      "title only",
+			pattern:   MetaData{Title: "sym"},
+			wantWhere: "WHERE org_id = $1 AND (title ILIKE $2) ORDER BY",
+			wantArgs:  []string{"org", "%sym%"},
+		},
+		{
+			name:      "composer and arranger",
+			pattern:   MetaData{Composer: "bach", Arranger: "smith"},
+			wantWhere: "WHERE org_id = $1 AND (composer ILIKE $2 OR arranger ILIKE $3) ORDER BY",
+			wantArgs:  []string{"org", "%bach%", "%smith%"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			query, args := metaSearchQuery("org", &test.pattern)
+			testutils.AssertContains(t, query, test.wantWhere)
+			testutils.AssertEqual(t, len(args), len(test.wantArgs))
+			for i, arg := range args {
+				testutils.AssertEqual(t, arg.(string), test.wantArgs[i])
+			}
+		})
+	}
+}
+
+func TestMetaSearchPageQuery(t *testing.T) {
+	query, args := metaSearchPageQuery("org", &MetaData{Title: "sym"}, MetaSort{}, nil, 10)
+	testutils.AssertContains(t, query, "WHERE org_id = $1 AND (title ILIKE $2) ORDER BY resource_id ASC LIMIT $3")
+	testutils.AssertEqual(t, len(args), 3)
+
+	query, args = metaSearchPageQuery("org", &MetaData{}, MetaSort{}, []string{"bolero_ravel"}, 10)
+	testutils.AssertContains(t, query, "WHERE org_id = $1 AND resource_id > $2 ORDER BY resource_id ASC LIMIT $3")
+	testutils.AssertEqual(t, args[1].(string), "bolero_ravel")
+	testutils.AssertEqual(t, args[2].(int), 10)
+}
+
+func TestMetaSearchPageQuerySorted(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		sort  MetaSort
+		after []string
+		want  string
+	}{
+		{
+			name: "title first page",
+			sort: MetaSort{Field: SortByTitle},
+			want: "WHERE org_id = $1 ORDER BY title ASC, resource_id ASC LIMIT $2",
+		},
+		{
+			name:  "composer descending",
+			sort:  MetaSort{Field: SortByComposer, Descending: true},
+			after: []string{"Ravel", "bolero_ravel"},
+			want:  "WHERE org_id = $1 AND (composer, resource_id) < ($2, $3) ORDER BY composer DESC, resource_id DESC LIMIT $4",
+		},
+		{
+			name:  "updated",
+			sort:  MetaSort{Field: SortByUpdated},
+			after: []string{"2026-01-02T03:04:05.000000Z", "bolero_ravel"},
+			want:  "AND ((data ->> 'submitted_at')::timestamptz, resource_id) > ($2::timestamptz, $3) ORDER BY (data ->> 'submitted_at')::timestamptz ASC",
+		},
+		{
+			name:  "resource id descending",
+			sort:  MetaSort{Descending: true},
+			after: []string{"bolero_ravel"},
+			want:  "AND resource_id < $2 ORDER BY resource_id DESC LIMIT $3",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			query, args := metaSearchPageQuery("org", &MetaData{}, test.sort, test.after, 10)
+			testutils.AssertContains(t, query, test.want)
+			testutils.AssertEqual(t, len(args), len(test.after)+2)
+		})
+	}
+}
+
+func TestNewPostgresStoreConnectionError(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	config := PostgresConfig{DSN: "postgres://caesura@127.0.0.1:1/caesura?sslmode=disable&connect_timeout=1", Directory: t.TempDir()}
+	_, err := NewPostgresStore(ctx, &config)
+	if err == nil || !strings.Contains(err.Error(), "could not connect") {
+		t.Fatalf("Wanted connection error got %v", err)
+	}
+}
+
+func TestTextArrayStoresNilAsEmpty(t *testing.T) {
+	value, err := textArray(nil).(driver.Valuer).Value()
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, value.(string), "{}")
+
+	value, err = textArray([]string{"a", "b"}).(driver.Valuer).Value()
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, value.(string), `{"a","b"}`)
+}
@@ -0,0 +1,128 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const maxProblemDescriptionLength = 2000
+
+type ProblemKind string
+
+const (
+	ProblemMissingPage        ProblemKind = "missing-page"
+	ProblemWrongTransposition ProblemKind = "wrong-transposition"
+	ProblemUnreadable         ProblemKind = "unreadable"
+	ProblemOther              ProblemKind = "other"
+)
+
+var ProblemKinds = []ProblemKind{ProblemMissingPage, ProblemWrongTransposition, ProblemUnreadable, ProblemOther}
+
+type ProblemStatus string
+
+const (
+	ProblemOpen       ProblemStatus = "open"
+	ProblemInProgress ProblemStatus = "in-progress"
+	ProblemResolved   ProblemStatus = "resolved"
+)
+
+var ProblemStatuses = []ProblemStatus{ProblemOpen, ProblemInProgress, ProblemResolved}
+
+// ProblemReport is a problem with a part of a resource reported by a member, such as a missing page. The
+// reporter is not stored, such that members can report problems without being singled out
+type ProblemReport struct {
+	Id            string `json:"id" firestore:"id"`
+	ResourceId    string `json:"resourceId" firestore:"resourceId"`
+	ResourceTitle string `json:"resourceTitle" firestore:"resourceTitle"`
+
+	// File name of the part. Empty when the problem concerns the whole resource
+	Part        string        `json:"part" firestore:"part"`
+	Kind        ProblemKind   `json:"kind" firestore:"kind"`
+	Description string        `json:"description" firestore:"description"`
+	Status      ProblemStatus `json:"status" firestore:"status"`
+	CreatedAt   time.Time     `json:"createdAt" firestore:"createdAt"`
+	UpdatedAt   time.Time     `json:"updatedAt" firestore:"updatedAt"`
+}
+
+func NewProblemReport(meta *MetaData, part string, kind ProblemKind, description string) *ProblemReport {
+	now := time.Now()
+	return &ProblemReport{
+		Id:            fmt.Sprintf("%d-%s", now.UnixNano(), RandomInsecureID()),
+		ResourceId:    meta.ResourceId(),
+		ResourceTitle: meta.Title,
+		Part:          strings.TrimSpace(part),
+		Kind:          kind,
+		Description:   strings.TrimSpace(description),
+		Status:        ProblemOpen,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+func (p *ProblemReport) Validate() error {
+	if p.ResourceId == "" {
+		return errors.Join(ErrInvalidProblemReport, errors.New("resource can not be empty"))
+	}
+	if !slices.Contains(ProblemKinds, p.Kind) {
+		return errors.Join(ErrInvalidProblemReport, fmt.Errorf("unknown kind of problem %q", p.Kind))
+	}
+	if !slices.Contains(ProblemStatuses, p.Status) {
+		return errors.Join(ErrInvalidProblemReport, fmt.Errorf("unknown status %q", p.Status))
+	}
+	if p.Kind == ProblemOther && p.Description == "" {
+		return errors.Join(ErrInvalidProblemReport, errors.New("describe the problem"))
+	}
+	if len(p.Part)+len(p.Description) > maxProblemDescriptionLength {
+		return errors.Join(ErrInvalidProblemReport, fmt.Errorf("report can not be longer than %d characters", maxProblemDescriptionLength))
+	}
+	return nil
+}
+
+func ValidateProblemStatus(status ProblemStatus) error {
+	if !slices.Contains(ProblemStatuses, status) {
+		return errors.Join(ErrInvalidProblemReport, fmt.Errorf("unknown status %q", status))
+	}
+	return nil
+}
+
+type ProblemReportStore interface {
+	SubmitProblemReport(ctx context.Context, orgId string, report *ProblemReport) error
+
+	// ProblemReports returns the reports of the organization. Unresolved reports come first, newest first
+	ProblemReports(ctx context.Context, orgId string) ([]ProblemReport, error)
+	SetProblemReportStatus(ctx context.Context, orgId, id string, status ProblemStatus, at time.Time) error
+}
+
+// SortProblemReports orders the reports such that unresolved reports come first, and the most recent first
+// within the resolved and unresolved reports
+func SortProblemReports(reports []ProblemReport) {
+	slices.SortStableFunc(reports, func(a, b ProblemReport) int {
+		aResolved, bResolved := a.Status == ProblemResolved, b.Status == ProblemResolved
+		if aResolved != bResolved {
+			if aResolved {
+				return 1
+			}
+			return -1
+		}
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+}
+
+// NumUnresolvedProblems returns the number of reports that are not resolved
+func NumUnresolvedProblems(reports []ProblemReport) int {
+	num := 0
+	for _, report := range reports {
+		if report.Status != ProblemResolved {
+			num++
+		}
+	}
+	return num
+}
+
+func problemReportNotFound(id string) error {
+	return errors.Join(ErrProblemReportNotFound, fmt.Errorf("problem report id: %s", id))
+}
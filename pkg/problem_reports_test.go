@@ -0,0 +1,94 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestProblemReportValidate(t *testing.T) {
+	meta := MetaData{Title: "Polka", Composer: "Strauss"}
+	for _, test := range []struct {
+		desc   string
+		report *ProblemReport
+		valid  bool
+	}{
+		{"missing page", NewProblemReport(&meta, "Horn.pdf", ProblemMissingPage, ""), true},
+		{"unknown kind", NewProblemReport(&meta, "Horn.pdf", "broken", ""), false},
+		{"other without description", NewProblemReport(&meta, "", ProblemOther, "  "), false},
+		{"too long", NewProblemReport(&meta, "Horn.pdf", ProblemOther, strings.Repeat("a", maxProblemDescriptionLength)), false},
+		{"no resource", &ProblemReport{Kind: ProblemOther, Description: "a", Status: ProblemOpen}, false},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			err := test.report.Validate()
+			testutils.AssertEqual(t, err == nil, test.valid)
+			if !test.valid {
+				testutils.AssertEqual(t, errors.Is(err, ErrInvalidProblemReport), true)
+			}
+		})
+	}
+}
+
+func TestSortProblemReports(t *testing.T) {
+	now := time.Now()
+	reports := []ProblemReport{
+		{Id: "old-open", Status: ProblemOpen, CreatedAt: now.Add(-time.Hour)},
+		{Id: "new-resolved", Status: ProblemResolved, CreatedAt: now},
+		{Id: "new-in-progress", Status: ProblemInProgress, CreatedAt: now},
+	}
+	SortProblemReports(reports)
+	testutils.AssertEqual(t, reports[0].Id, "new-in-progress")
+	testutils.AssertEqual(t, reports[1].Id, "old-open")
+	testutils.AssertEqual(t, reports[2].Id, "new-resolved")
+	testutils.AssertEqual(t, NumUnresolvedProblems(reports), 2)
+}
+
+// assertProblemReportStore runs the same checks against all implementations of the problem report store
+func assertProblemReportStore(t *testing.T, store ProblemReportStore) {
+	ctx := context.Background()
+	meta := MetaData{Title: "Polka", Composer: "Strauss"}
+	first := NewProblemReport(&meta, "Horn.pdf", ProblemMissingPage, "Page 2 is missing")
+	first.CreatedAt = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	second := NewProblemReport(&meta, "Clarinet.pdf", ProblemWrongTransposition, "")
+	second.CreatedAt = first.CreatedAt.Add(time.Hour)
+
+	testutils.AssertNil(t, store.SubmitProblemReport(ctx, "org", first))
+	testutils.AssertNil(t, store.SubmitProblemReport(ctx, "org", second))
+	testutils.AssertNil(t, store.SubmitProblemReport(ctx, "other-org", NewProblemReport(&meta, "", ProblemUnreadable, "")))
+
+	err := store.SubmitProblemReport(ctx, "org", NewProblemReport(&meta, "", "broken", ""))
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidProblemReport), true)
+
+	reports, err := store.ProblemReports(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(reports), 2)
+	testutils.AssertEqual(t, reports[0].Id, second.Id)
+	testutils.AssertEqual(t, reports[1].Description, "Page 2 is missing")
+	testutils.AssertEqual(t, reports[1].ResourceId, meta.ResourceId())
+
+	resolvedAt := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	testutils.AssertNil(t, store.SetProblemReportStatus(ctx, "org", second.Id, ProblemResolved, resolvedAt))
+
+	reports, err = store.ProblemReports(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, reports[0].Id, first.Id)
+	testutils.AssertEqual(t, reports[1].Status, ProblemResolved)
+	testutils.AssertEqual(t, reports[1].UpdatedAt.Equal(resolvedAt), true)
+
+	err = store.SetProblemReportStatus(ctx, "org", first.Id, "closed", resolvedAt)
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidProblemReport), true)
+	err = store.SetProblemReportStatus(ctx, "other-org", first.Id, ProblemResolved, resolvedAt)
+	testutils.AssertEqual(t, errors.Is(err, ErrProblemReportNotFound), true)
+}
+
+func TestInMemoryProblemReports(t *testing.T) {
+	assertProblemReportStore(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGoogleProblemReports(t *testing.T) {
+	assertProblemReportStore(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// DefaultExportPart is the part of each piece put in a combined project PDF when no part is chosen
+const DefaultExportPart = "score"
+
+// BookmarkedPdf is a PDF added to a combined PDF with a bookmark pointing at its first page
+type BookmarkedPdf struct {
+	Title   string
+	Content []byte
+}
+
+type ProjectExportStore interface {
+	ResourceGetter
+	StorageClassTransitioner
+}
+
+// exportedPart returns the first part, sorted by name, of the resource whose name contains part. Only pdf files
+// that include accepts are considered. Nil is returned when the resource has no such part
+func exportedPart(ctx context.Context, store ProjectExportStore, orgId, resourceId, part string, include func(string) bool) ([]byte, error) {
+	part = strings.ToLower(part)
+	var (
+		chosen  string
+		content []byte
+	)
+	for name, reader := range store.ResourceStream(ctx, orgId, resourceId) {
+		name = path.Base(name)
+		lower := strings.ToLower(name)
+		if !strings.HasSuffix(lower, ".pdf") || !strings.Contains(lower, part) || !include(name) {
+			continue
+		}
+		if chosen != "" && name > chosen {
+			continue
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		chosen, content = name, data
+	}
+	return content, ctx.Err()
+}
+
+// ProjectPdfs collects the part matching part of each piece of the project in setlist order. Pieces in the trash
+// and pieces without a matching part are left out. ErrFileNotFound is returned when no piece has the part
+func ProjectPdfs(ctx context.Context, store ProjectExportStore, orgId string, project *Project, part string, include func(string) bool) ([]BookmarkedPdf, error) {
+	if part == "" {
+		part = DefaultExportPart
+	}
+	pdfs := make([]BookmarkedPdf, 0, len(project.ResourceIds))
+	for _, resourceId := range project.ResourceIds {
+		downloader := NewResourceDownloader().GetMetaData(ctx, store, orgId, resourceId).Rehydrate(ctx, store, orgId)
+		if IsNotFound(downloader.Error) || (downloader.Error == nil && downloader.meta.Deleted) {
+			continue
+		}
+		if downloader.Error != nil {
+			return nil, downloader.Error
+		}
+
+		content, err := exportedPart(ctx, store, orgId, resourceId, part, include)
+		if err != nil {
+			return nil, err
+		}
+		if content == nil {
+			slog.InfoContext(ctx, "Piece has no part to export", "resourceId", resourceId, "part", part)
+			continue
+		}
+
+		title := downloader.meta.Title
+		if downloader.meta.Composer != "" {
+			title = fmt.Sprintf("%s (%s)", title, downloader.meta.Composer)
+		}
+		pdfs = append(pdfs, BookmarkedPdf{Title: title, Content: content})
+	}
+	if len(pdfs) == 0 {
+		return nil, errors.Join(ErrFileNotFound, fmt.Errorf("no piece of %s has a part matching %q", project.Name, part))
+	}
+	return pdfs, nil
+}
+
+// CombinePdfs writes the PDFs to w as one PDF, in the order given, with a bookmark at the first page of each
+func CombinePdfs(w io.Writer, pdfs []BookmarkedPdf) error {
+	if len(pdfs) == 0 {
+		return errors.New("no pdfs to combine")
+	}
+	readers := make([]io.ReadSeeker, len(pdfs))
+	bookmarks := make([]pdfcpu.Bookmark, len(pdfs))
+	page := 1
+	for i, pdf := range pdfs {
+		numPages, err := api.PageCount(bytes.NewReader(pdf.Content), model.NewDefaultConfiguration())
+		if err != nil {
+			return fmt.Errorf("could not read %s: %w", pdf.Title, err)
+		}
+		readers[i] = bytes.NewReader(pdf.Content)
+		bookmarks[i] = pdfcpu.Bookmark{Title: pdf.Title, PageFrom: page}
+		page += numPages
+	}
+
+	var merged bytes.Buffer
+	if err := api.MergeRaw(readers, &merged, false, model.NewDefaultConfiguration()); err != nil {
+		return err
+	}
+	return api.AddBookmarks(bytes.NewReader(merged.Bytes()), w, bookmarks, true, model.NewDefaultConfiguration())
+}
@@ -0,0 +1,54 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+func TestProjectPdfsFollowSetlist(t *testing.T) {
+	store := NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	project := store.Data[orgId].Projects["demoproject1"]
+	project.ResourceIds = []string{project.ResourceIds[1], "unknown", project.ResourceIds[0]}
+
+	pdfs, err := ProjectPdfs(context.Background(), store, orgId, &project, "part1", IncludeAll)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(pdfs), 2)
+	testutils.AssertEqual(t, pdfs[0].Title, "Demo Title 2 (Composer B)")
+	testutils.AssertEqual(t, pdfs[1].Title, "Demo Title 1 (Composer A)")
+
+	_, err = ProjectPdfs(context.Background(), store, orgId, &project, "", IncludeAll)
+	testutils.AssertEqual(t, errors.Is(err, ErrFileNotFound), true)
+
+	_, err = ProjectPdfs(context.Background(), store, orgId, &project, "part1", func(string) bool { return false })
+	testutils.AssertEqual(t, errors.Is(err, ErrFileNotFound), true)
+}
+
+func TestCombinePdfs(t *testing.T) {
+	var two, three bytes.Buffer
+	testutils.AssertNil(t, CreateNPagePdf(&two, 2))
+	testutils.AssertNil(t, CreateNPagePdf(&three, 3))
+
+	var combined bytes.Buffer
+	err := CombinePdfs(&combined, []BookmarkedPdf{{Title: "March", Content: two.Bytes()}, {Title: "Waltz", Content: three.Bytes()}})
+	testutils.AssertNil(t, err)
+
+	numPages, err := api.PageCount(bytes.NewReader(combined.Bytes()), model.NewDefaultConfiguration())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, numPages, 5)
+
+	bookmarks, err := api.Bookmarks(bytes.NewReader(combined.Bytes()), model.NewDefaultConfiguration())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(bookmarks), 2)
+	testutils.AssertEqual(t, bookmarks[1].Title, "Waltz")
+	testutils.AssertEqual(t, bookmarks[1].PageFrom, 3)
+
+	testutils.AssertEqual(t, CombinePdfs(&combined, nil) != nil, true)
+	testutils.AssertEqual(t, CombinePdfs(&combined, []BookmarkedPdf{{Title: "Broken", Content: []byte("not a pdf")}}) != nil, true)
+}
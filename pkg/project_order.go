@@ -0,0 +1,24 @@
+package pkg
+
+import "context"
+
+// ReorderProject stores the pieces of a project in the given order, such that the project can be used as the
+// setlist of a concert. See Project.Reorder for how pieces missing from order are handled
+func ReorderProject(ctx context.Context, store Transactor, orgId, projectId string, order []string) (*Project, error) {
+	var project *Project
+	err := store.RunTransaction(ctx, func(ctx context.Context, tx TxStore) error {
+		var err error
+		project, err = tx.ProjectById(ctx, orgId, projectId)
+		if err != nil {
+			return err
+		}
+		if err := project.Reorder(order); err != nil {
+			return err
+		}
+		return tx.SetProjectOrder(ctx, orgId, projectId, project.ResourceIds)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return project, nil
+}
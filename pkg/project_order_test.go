@@ -0,0 +1,59 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func assertProjectOrder(t *testing.T, store transactionTestStore) {
+	ctx := context.Background()
+	polka, march, waltz := MetaData{Title: "Polka"}, MetaData{Title: "March"}, MetaData{Title: "Waltz"}
+	for _, meta := range []*MetaData{&polka, &march, &waltz} {
+		testutils.AssertNil(t, store.Submit(ctx, "org", meta, manifestParts))
+	}
+	project := Project{Name: "Concert", ResourceIds: []string{polka.ResourceId(), march.ResourceId(), waltz.ResourceId()}}
+	testutils.AssertNil(t, AddToProject(ctx, store, "org", &project))
+
+	ordered, err := ReorderProject(ctx, store, "org", "concert", []string{waltz.ResourceId(), polka.ResourceId()})
+	testutils.AssertNil(t, err)
+	want := strings.Join([]string{waltz.ResourceId(), polka.ResourceId(), march.ResourceId()}, ",")
+	testutils.AssertEqual(t, strings.Join(ordered.ResourceIds, ","), want)
+
+	stored, err := store.ProjectById(ctx, "org", "concert")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(stored.ResourceIds, ","), want)
+
+	// New pieces are added after the ordered ones
+	testutils.AssertNil(t, AddToProject(ctx, store, "org", &Project{Name: "Concert", ResourceIds: []string{polka.ResourceId()}}))
+	stored, err = store.ProjectById(ctx, "org", "concert")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(stored.ResourceIds, ","), want)
+
+	_, err = ReorderProject(ctx, store, "org", "concert", []string{"unknown"})
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidProjectOrder), true)
+	_, err = ReorderProject(ctx, store, "org", "unknown", []string{polka.ResourceId()})
+	testutils.AssertEqual(t, errors.Is(err, ErrProjectNotFound), true)
+}
+
+func TestInMemoryProjectOrder(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.RegisterOrganization(context.Background(), &Organization{Id: "org"}))
+	assertProjectOrder(t, store)
+}
+
+func TestGoogleStoreProjectOrder(t *testing.T) {
+	assertProjectOrder(t, &GoogleStore{
+		FsClient:     NewResilientFirestoreClient(NewLocalFirestoreClient(), &ResilienceConfig{}),
+		BucketClient: &FileBucketClient{Directory: t.TempDir()},
+		Config:       &GoogleConfig{Bucket: "scores"},
+	})
+}
+
+func TestLocalStoreProjectOrder(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+	assertProjectOrder(t, store)
+}
@@ -0,0 +1,110 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+)
+
+// RenameProject gives the project a new name. The id of a project is derived from its name, so the project is
+// moved to the new id and the old one is removed. Renaming fails with ErrProjectExists when another project
+// already has the new id. Changes that keep the id, such as the case of a letter, only update the name
+func RenameProject(ctx context.Context, store Transactor, orgId, projectId, name string) (*Project, error) {
+	name = strings.TrimSpace(name)
+	renamed := &Project{Name: name}
+	if renamed.Id() == "" {
+		return nil, errors.Join(ErrInvalidProjectName, fmt.Errorf("%q has no letters or digits", name))
+	}
+
+	err := store.RunTransaction(ctx, func(ctx context.Context, tx TxStore) error {
+		project, err := tx.ProjectById(ctx, orgId, projectId)
+		if err != nil {
+			return err
+		}
+		if renamed.Id() != projectId {
+			_, err := tx.ProjectById(ctx, orgId, renamed.Id())
+			if err == nil {
+				return errors.Join(ErrProjectExists, fmt.Errorf("project id: %s", renamed.Id()))
+			}
+			if !errors.Is(err, ErrProjectNotFound) {
+				return err
+			}
+		}
+
+		*renamed = *project
+		renamed.Name = name
+		renamed.UpdatedAt = time.Now()
+		if err := tx.SubmitProject(ctx, orgId, renamed); err != nil {
+			return err
+		}
+		if renamed.Id() == projectId {
+			return nil
+		}
+		return tx.DeleteProject(ctx, orgId, projectId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return renamed, nil
+}
+
+// MergeProjects folds the pieces and notes of the source project into the target project and removes the
+// source. Pieces in both projects are only listed once, and the notes of the target are kept when both
+// projects have notes for a piece. The defaults of the target, such as its groups, are kept
+func MergeProjects(ctx context.Context, store Transactor, orgId, targetId, sourceId string) (*Project, error) {
+	if targetId == sourceId {
+		return nil, errors.Join(ErrInvalidProjectMerge, errors.New("a project can not be merged into itself"))
+	}
+
+	var target *Project
+	err := store.RunTransaction(ctx, func(ctx context.Context, tx TxStore) error {
+		var err error
+		target, err = tx.ProjectById(ctx, orgId, targetId)
+		if err != nil {
+			return err
+		}
+		source, err := tx.ProjectById(ctx, orgId, sourceId)
+		if err != nil {
+			return err
+		}
+
+		notes := maps.Clone(source.Notes)
+		for resourceId := range target.Notes {
+			delete(notes, resourceId)
+		}
+		target.Merge(&Project{ResourceIds: source.ResourceIds, Notes: notes})
+		if err := tx.SubmitProject(ctx, orgId, target); err != nil {
+			return err
+		}
+		return tx.DeleteProject(ctx, orgId, sourceId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// MoveGuestGrants gives the guests of a project access to the project it was renamed to or merged into, and
+// returns the number of grants that were moved. Revoked and expired grants are moved as well, such that they
+// are still listed with the project
+func MoveGuestGrants(ctx context.Context, store GuestGrantStore, orgId, fromProjectId, toProjectId string) (int, error) {
+	grants, err := store.GuestGrants(ctx, orgId)
+	if err != nil {
+		return 0, err
+	}
+	num := 0
+	for _, grant := range grants {
+		if grant.ProjectId != fromProjectId {
+			continue
+		}
+		grant.ProjectId = toProjectId
+		if err := store.SaveGuestGrant(ctx, &grant); err != nil {
+			return num, err
+		}
+		num++
+	}
+	return num, nil
+}
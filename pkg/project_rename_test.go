@@ -0,0 +1,113 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+// assertProjectRename checks renaming and merging of projects against all implementations of the transactor
+func assertProjectRename(t *testing.T, store transactionTestStore) {
+	ctx := context.Background()
+	polka, march, waltz := MetaData{Title: "Polka"}, MetaData{Title: "March"}, MetaData{Title: "Waltz"}
+	for _, meta := range []*MetaData{&polka, &march, &waltz} {
+		testutils.AssertNil(t, store.Submit(ctx, "org", meta, manifestParts))
+	}
+
+	spring := Project{Name: "Spring concert", ResourceIds: []string{polka.ResourceId(), march.ResourceId()}, Notes: map[string]string{polka.ResourceId(): "Slow"}}
+	autumn := Project{Name: "Autumn concert", ResourceIds: []string{march.ResourceId(), waltz.ResourceId()}, Notes: map[string]string{polka.ResourceId(): "Fast", waltz.ResourceId(): "Repeat"}}
+	for _, project := range []*Project{&spring, &autumn} {
+		testutils.AssertNil(t, AddToProject(ctx, store, "org", project))
+	}
+
+	renamed, err := RenameProject(ctx, store, "org", spring.Id(), " Summer concert ")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, renamed.Id(), "summerconcert")
+	testutils.AssertEqual(t, renamed.Name, "Summer concert")
+
+	stored, err := store.ProjectById(ctx, "org", "summerconcert")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(stored.ResourceIds), 2)
+	testutils.AssertEqual(t, stored.Notes[polka.ResourceId()], "Slow")
+	_, err = store.ProjectById(ctx, "org", spring.Id())
+	testutils.AssertEqual(t, errors.Is(err, ErrProjectNotFound), true)
+
+	// Changing the case keeps the id
+	renamed, err = RenameProject(ctx, store, "org", "summerconcert", "Summer Concert")
+	testutils.AssertNil(t, err)
+	stored, err = store.ProjectById(ctx, "org", "summerconcert")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Name, "Summer Concert")
+
+	_, err = RenameProject(ctx, store, "org", "summerconcert", "Autumn Concert")
+	testutils.AssertEqual(t, errors.Is(err, ErrProjectExists), true)
+	_, err = RenameProject(ctx, store, "org", "summerconcert", " !? ")
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidProjectName), true)
+	_, err = RenameProject(ctx, store, "org", "unknown", "Winter concert")
+	testutils.AssertEqual(t, errors.Is(err, ErrProjectNotFound), true)
+
+	merged, err := MergeProjects(ctx, store, "org", "summerconcert", autumn.Id())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, merged.Name, "Summer Concert")
+
+	stored, err = store.ProjectById(ctx, "org", "summerconcert")
+	testutils.AssertNil(t, err)
+	slices.Sort(stored.ResourceIds)
+	want := []string{polka.ResourceId(), march.ResourceId(), waltz.ResourceId()}
+	slices.Sort(want)
+	testutils.AssertEqual(t, slices.Equal(stored.ResourceIds, want), true)
+	testutils.AssertEqual(t, stored.Notes[polka.ResourceId()], "Slow")
+	testutils.AssertEqual(t, stored.Notes[waltz.ResourceId()], "Repeat")
+	_, err = store.ProjectById(ctx, "org", autumn.Id())
+	testutils.AssertEqual(t, errors.Is(err, ErrProjectNotFound), true)
+
+	_, err = MergeProjects(ctx, store, "org", "summerconcert", autumn.Id())
+	testutils.AssertEqual(t, errors.Is(err, ErrProjectNotFound), true)
+	_, err = MergeProjects(ctx, store, "org", "summerconcert", "summerconcert")
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidProjectMerge), true)
+}
+
+func TestInMemoryProjectRename(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.RegisterOrganization(context.Background(), &Organization{Id: "org"}))
+	assertProjectRename(t, store)
+}
+
+func TestGoogleStoreProjectRename(t *testing.T) {
+	assertProjectRename(t, &GoogleStore{
+		FsClient:     NewResilientFirestoreClient(NewLocalFirestoreClient(), &ResilienceConfig{}),
+		BucketClient: &FileBucketClient{Directory: t.TempDir()},
+		Config:       &GoogleConfig{Bucket: "scores"},
+	})
+}
+
+func TestLocalStoreProjectRename(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+	assertProjectRename(t, store)
+}
+
+func TestMoveGuestGrants(t *testing.T) {
+	ctx := context.Background()
+	store := NewMultiOrgInMemoryStore()
+	expires := time.Now().Add(time.Hour)
+	for _, projectId := range []string{"spring", "spring", "autumn"} {
+		testutils.AssertNil(t, store.SaveGuestGrant(ctx, NewGuestGrant("org", "user", "jury@example.com", nil, projectId, expires, "")))
+	}
+
+	num, err := MoveGuestGrants(ctx, store, "org", "spring", "summer")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, num, 2)
+
+	grants, err := store.GuestGrants(ctx, "org")
+	testutils.AssertNil(t, err)
+	projectIds := []string{}
+	for _, grant := range grants {
+		projectIds = append(projectIds, grant.ProjectId)
+	}
+	slices.Sort(projectIds)
+	testutils.AssertEqual(t, slices.Equal(projectIds, []string{"autumn", "summer", "summer"}), true)
+}
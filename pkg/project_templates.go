@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const maxProjectTemplateLength = 10000
+
+// ProjectTemplate holds the defaults of a recurring kind of project, such as a Christmas concert. The
+// defaults are copied to new projects created from the template
+type ProjectTemplate struct {
+	Name string `json:"name" firestore:"name"`
+
+	// Categories of the pieces, such as carols or marches
+	Categories []string `json:"categories" firestore:"categories"`
+
+	// Standard text of the announcement of the project
+	Announcement string `json:"announcement" firestore:"announcement"`
+
+	// Groups that receive the parts of the project
+	Groups []string `json:"groups" firestore:"groups"`
+
+	UpdatedAt time.Time `json:"updated_at" firestore:"updated_at"`
+}
+
+func (p *ProjectTemplate) Id() string {
+	return SanitizeString(p.Name)
+}
+
+func (p *ProjectTemplate) Validate() error {
+	if p.Id() == "" {
+		return errors.Join(ErrInvalidProjectTemplate, errors.New("name must contain letters or digits"))
+	}
+	length := len(p.Name) + len(p.Announcement)
+	for _, item := range slices.Concat(p.Categories, p.Groups) {
+		length += len(item)
+	}
+	if length > maxProjectTemplateLength {
+		return errors.Join(ErrInvalidProjectTemplate, fmt.Errorf("template can not be longer than %d characters", maxProjectTemplateLength))
+	}
+	return nil
+}
+
+// Apply copies the defaults of the template to a project
+func (p *ProjectTemplate) Apply(project *Project) {
+	project.TemplateId = p.Id()
+	project.Categories = slices.Clone(p.Categories)
+	project.Announcement = p.Announcement
+	project.Groups = slices.Clone(p.Groups)
+}
+
+// ParseTemplateList splits a comma separated list, and drops empty and repeated items
+func ParseTemplateList(list string) []string {
+	items := []string{}
+	for item := range strings.SplitSeq(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" && !slices.Contains(items, item) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// SortProjectTemplates orders the templates by name
+func SortProjectTemplates(templates []ProjectTemplate) {
+	slices.SortFunc(templates, func(a, b ProjectTemplate) int {
+		return strings.Compare(a.Id(), b.Id())
+	})
+}
+
+type ProjectTemplateGetter interface {
+	ProjectTemplateById(ctx context.Context, orgId, id string) (*ProjectTemplate, error)
+}
+
+type ProjectTemplateStore interface {
+	ProjectTemplateGetter
+
+	// SubmitProjectTemplate stores the template, and replaces a template with the same id
+	SubmitProjectTemplate(ctx context.Context, orgId string, template *ProjectTemplate) error
+
+	// ProjectTemplates returns the templates of the organization ordered by name
+	ProjectTemplates(ctx context.Context, orgId string) ([]ProjectTemplate, error)
+	DeleteProjectTemplate(ctx context.Context, orgId, id string) error
+}
+
+func projectTemplateNotFound(id string) error {
+	return errors.Join(ErrProjectTemplateNotFound, fmt.Errorf("project template id: %s", id))
+}
@@ -0,0 +1,119 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestParseTemplateList(t *testing.T) {
+	testutils.AssertEqual(t, strings.Join(ParseTemplateList(" Carols, Marches,,Carols "), ";"), "Carols;Marches")
+	testutils.AssertEqual(t, len(ParseTemplateList("")), 0)
+}
+
+func TestProjectTemplateValidate(t *testing.T) {
+	testutils.AssertNil(t, (&ProjectTemplate{Name: "Christmas concert"}).Validate())
+
+	for _, template := range []ProjectTemplate{
+		{Name: "!!"},
+		{Name: "Christmas", Announcement: strings.Repeat("a", maxProjectTemplateLength)},
+	} {
+		testutils.AssertEqual(t, errors.Is(template.Validate(), ErrInvalidProjectTemplate), true)
+	}
+}
+
+func TestProjectTemplateApply(t *testing.T) {
+	template := ProjectTemplate{Name: "Christmas concert", Categories: []string{"Carols"}, Groups: []string{"Trumpet"}, Announcement: "Welcome"}
+	project := Project{Name: "Christmas 2026"}
+	template.Apply(&project)
+	template.Groups[0] = "Horn"
+
+	testutils.AssertEqual(t, project.TemplateId, "christmasconcert")
+	testutils.AssertEqual(t, project.Categories[0], "Carols")
+	testutils.AssertEqual(t, project.Groups[0], "Trumpet")
+	testutils.AssertEqual(t, project.Announcement, "Welcome")
+}
+
+type projectTemplateTestStore interface {
+	ProjectTemplateStore
+	ProjectSubmitter
+	ProjectByIdGetter
+}
+
+// assertProjectTemplateStore checks that templates can be stored, replaced, listed and deleted, and that the
+// defaults of a project are stored with it
+func assertProjectTemplateStore(t *testing.T, store projectTemplateTestStore) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	christmas := ProjectTemplate{Name: "Christmas concert", Categories: []string{"Carols"}, Groups: []string{"Trumpet"}, UpdatedAt: now}
+	spring := ProjectTemplate{Name: "Spring concert", Announcement: "Welcome", UpdatedAt: now}
+	for _, template := range []*ProjectTemplate{&spring, &christmas} {
+		testutils.AssertNil(t, store.SubmitProjectTemplate(ctx, "org", template))
+	}
+	testutils.AssertEqual(t, errors.Is(store.SubmitProjectTemplate(ctx, "org", &ProjectTemplate{Name: "!"}), ErrInvalidProjectTemplate), true)
+
+	christmas.Groups = []string{"Trumpet", "Horn"}
+	testutils.AssertNil(t, store.SubmitProjectTemplate(ctx, "org", &christmas))
+
+	templates, err := store.ProjectTemplates(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(templates), 2)
+	testutils.AssertEqual(t, templates[0].Name, "Christmas concert")
+	testutils.AssertEqual(t, strings.Join(templates[0].Groups, ";"), "Trumpet;Horn")
+	testutils.AssertEqual(t, templates[1].Announcement, "Welcome")
+
+	other, err := store.ProjectTemplates(ctx, "other-org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(other), 0)
+
+	template, err := store.ProjectTemplateById(ctx, "org", "christmasconcert")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, template.Categories[0], "Carols")
+
+	project := Project{Name: "Christmas 2026", ResourceIds: []string{}, CreatedAt: now, UpdatedAt: now}
+	template.Apply(&project)
+	testutils.AssertNil(t, store.SubmitProject(ctx, "org", &project))
+	stored, err := store.ProjectById(ctx, "org", project.Id())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.TemplateId, "christmasconcert")
+	testutils.AssertEqual(t, strings.Join(stored.Groups, ";"), "Trumpet;Horn")
+
+	testutils.AssertNil(t, store.DeleteProjectTemplate(ctx, "org", "christmasconcert"))
+	_, err = store.ProjectTemplateById(ctx, "org", "christmasconcert")
+	testutils.AssertEqual(t, errors.Is(err, ErrProjectTemplateNotFound), true)
+	testutils.AssertEqual(t, errors.Is(store.DeleteProjectTemplate(ctx, "org", "christmasconcert"), ErrProjectTemplateNotFound), true)
+}
+
+func TestInMemoryProjectTemplates(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.RegisterOrganization(context.Background(), &Organization{Id: "org"}))
+	assertProjectTemplateStore(t, store)
+}
+
+func TestGoogleStoreProjectTemplates(t *testing.T) {
+	assertProjectTemplateStore(t, &GoogleStore{
+		FsClient:     NewLocalFirestoreClient(),
+		BucketClient: &FileBucketClient{Directory: t.TempDir()},
+		Config:       &GoogleConfig{Bucket: "scores"},
+	})
+}
+
+func TestLocalStoreProjectTemplates(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+	assertProjectTemplateStore(t, store)
+}
+
+func TestCloneKeepsProjectTemplates(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.SubmitProjectTemplate(context.Background(), "org", &ProjectTemplate{Name: "Christmas"}))
+	clone := store.Clone()
+	testutils.AssertNil(t, store.DeleteProjectTemplate(context.Background(), "org", "christmas"))
+
+	templates, err := clone.ProjectTemplates(context.Background(), "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(templates), 1)
+}
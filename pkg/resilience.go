@@ -0,0 +1,410 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/storage"
+	"google.golang.org/grpc/status"
+)
+
+type ResilienceConfig struct {
+	// Number of additional attempts for idempotent operations
+	MaxRetries     int           `yaml:"max_retries" env:"CAESURA_MAX_RETRIES"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+
+	// Fraction of the backoff that is randomized, such that instances failing at the same time spread out
+	// their retries
+	Jitter float64 `yaml:"jitter"`
+
+	// Timeout of each attempt. Zero means that only the deadline of the request applies
+	CallTimeout time.Duration `yaml:"call_timeout"`
+
+	// Number of consecutive failures before the circuit opens, and how long it stays open
+	FailureThreshold int           `yaml:"failure_threshold"`
+	OpenDuration     time.Duration `yaml:"open_duration"`
+}
+
+func DefaultResilienceConfig() ResilienceConfig {
+	return ResilienceConfig{
+		MaxRetries:       2,
+		InitialBackoff:   100 * time.Millisecond,
+		MaxBackoff:       time.Second,
+		Jitter:           0.2,
+		CallTimeout:      5 * time.Second,
+		FailureThreshold: 5,
+		OpenDuration:     30 * time.Second,
+	}
+}
+
+// HealthReporter is implemented by stores that know when their backend is degraded
+type HealthReporter interface {
+	Degraded() bool
+}
+
+// isTransientErr reports whether err signals an overloaded or unreachable backend
+func isTransientErr(err error) bool {
+	return errors.Is(err, ErrStoreUnavailable) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		isAzureUnavailable(err) ||
+		isGoogleAPIUnavailable(err) ||
+		slices.Contains(transientCodes, status.Code(err))
+}
+
+// CircuitBreaker fails fast after FailureThreshold consecutive transient failures. When OpenDuration has
+// passed calls are let through again, and the first success closes the circuit
+type CircuitBreaker struct {
+	FailureThreshold int
+	OpenDuration     time.Duration
+	Now              func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func NewCircuitBreaker(config *ResilienceConfig) *CircuitBreaker {
+	return &CircuitBreaker{FailureThreshold: config.FailureThreshold, OpenDuration: config.OpenDuration, Now: time.Now}
+}
+
+func (c *CircuitBreaker) Allow() error {
+	if c.Degraded() {
+		return errors.Join(ErrStoreUnavailable, ErrCircuitOpen)
+	}
+	return nil
+}
+
+func (c *CircuitBreaker) Record(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		c.failures = 0
+		c.openUntil = time.Time{}
+		return
+	}
+	if !isTransientErr(err) {
+		return
+	}
+
+	c.failures++
+	if c.FailureThreshold > 0 && c.failures >= c.FailureThreshold {
+		if c.openUntil.IsZero() || c.Now().After(c.openUntil) {
+			slog.Warn("Opening circuit breaker", "failures", c.failures, "openDuration", c.OpenDuration, "error", err)
+		}
+		c.openUntil = c.Now().Add(c.OpenDuration)
+	}
+}
+
+func (c *CircuitBreaker) Degraded() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Now().Before(c.openUntil)
+}
+
+// callWithRetry runs fn with a timeout per attempt. Transient failures are retried with exponential backoff
+// when retry is true, which must only be the case for idempotent operations
+func callWithRetry(ctx context.Context, config *ResilienceConfig, breaker *CircuitBreaker, retry bool, fn func(ctx context.Context) error) error {
+	backoff := config.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		if err := breaker.Allow(); err != nil {
+			return err
+		}
+
+		callCtx, cancel := withCallTimeout(ctx, config.CallTimeout)
+		err := fn(callCtx)
+		cancel()
+		breaker.Record(err)
+
+		if err == nil || !retry || attempt >= config.MaxRetries || !isTransientErr(err) || ctx.Err() != nil {
+			return err
+		}
+
+		wait := withJitter(backoff, config.Jitter)
+		slog.WarnContext(ctx, "Retrying store call", "attempt", attempt+1, "backoff", wait, "error", err)
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(wait):
+		}
+		backoff = min(2*backoff, config.MaxBackoff)
+	}
+}
+
+// withJitter returns a random duration within the fraction jitter of backoff
+func withJitter(backoff time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 {
+		return backoff
+	}
+	return backoff + time.Duration(jitter*float64(backoff)*(2*rand.Float64()-1))
+}
+
+func withCallTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// ResilientFirestoreClient adds timeouts, retries and a circuit breaker to a FirestoreClient. Updates are not
+// retried since they may contain non-idempotent transforms such as array unions
+type ResilientFirestoreClient struct {
+	Client  FirestoreClient
+	Config  ResilienceConfig
+	Breaker *CircuitBreaker
+}
+
+func NewResilientFirestoreClient(client FirestoreClient, config *ResilienceConfig) *ResilientFirestoreClient {
+	return &ResilientFirestoreClient{Client: client, Config: *config, Breaker: NewCircuitBreaker(config)}
+}
+
+func (r *ResilientFirestoreClient) StoreDocument(ctx context.Context, dataset, orgId, itemId string, data any) error {
+	return callWithRetry(ctx, &r.Config, r.Breaker, true, func(ctx context.Context) error {
+		return r.Client.StoreDocument(ctx, dataset, orgId, itemId, data)
+	})
+}
+
+func (r *ResilientFirestoreClient) Update(ctx context.Context, dataset, orgId, itemId string, update []firestore.Update) error {
+	return callWithRetry(ctx, &r.Config, r.Breaker, false, func(ctx context.Context) error {
+		return r.Client.Update(ctx, dataset, orgId, itemId, update)
+	})
+}
+
+// GetDocByPrefix is not retried since documents may already have been yielded when an error occurs
+func (r *ResilientFirestoreClient) GetDocByPrefix(ctx context.Context, dataset, orgId, field, prefix string) iter.Seq[Document] {
+	return func(yield func(doc Document) bool) {
+		if err := r.Breaker.Allow(); err != nil {
+			slog.WarnContext(ctx, "Skipping query", "dataset", dataset, "error", err)
+			return
+		}
+
+		callCtx, cancel := withCallTimeout(ctx, r.Config.CallTimeout)
+		defer cancel()
+		for doc := range r.Client.GetDocByPrefix(callCtx, dataset, orgId, field, prefix) {
+			if !yield(doc) {
+				return
+			}
+		}
+		if errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+			r.Breaker.Record(callCtx.Err())
+		}
+	}
+}
+
+// GetDocPage is not retried for the same reason as GetDocByPrefix
+func (r *ResilientFirestoreClient) GetDocPage(ctx context.Context, dataset, orgId, after string, limit int) iter.Seq[Document] {
+	return func(yield func(doc Document) bool) {
+		if err := r.Breaker.Allow(); err != nil {
+			slog.WarnContext(ctx, "Skipping query", "dataset", dataset, "error", err)
+			return
+		}
+
+		callCtx, cancel := withCallTimeout(ctx, r.Config.CallTimeout)
+		defer cancel()
+		for doc := range r.Client.GetDocPage(callCtx, dataset, orgId, after, limit) {
+			if !yield(doc) {
+				return
+			}
+		}
+		if errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+			r.Breaker.Record(callCtx.Err())
+		}
+	}
+}
+
+// GetOrderedDocPage forwards to the wrapped client, which must implement OrderedDocPager
+func (r *ResilientFirestoreClient) GetOrderedDocPage(ctx context.Context, dataset, orgId string, order DocOrder, limit int) iter.Seq[Document] {
+	return func(yield func(doc Document) bool) {
+		pager, ok := r.Client.(OrderedDocPager)
+		if !ok {
+			slog.ErrorContext(ctx, "Client can not order documents", "dataset", dataset)
+			return
+		}
+		if err := r.Breaker.Allow(); err != nil {
+			slog.WarnContext(ctx, "Skipping query", "dataset", dataset, "error", err)
+			return
+		}
+
+		callCtx, cancel := withCallTimeout(ctx, r.Config.CallTimeout)
+		defer cancel()
+		for doc := range pager.GetOrderedDocPage(callCtx, dataset, orgId, order, limit) {
+			if !yield(doc) {
+				return
+			}
+		}
+		if errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+			r.Breaker.Record(callCtx.Err())
+		}
+	}
+}
+
+func (r *ResilientFirestoreClient) GetDoc(ctx context.Context, dataset, orgId, itemId string) (Document, error) {
+	var doc Document
+	err := callWithRetry(ctx, &r.Config, r.Breaker, true, func(ctx context.Context) error {
+		var err error
+		doc, err = r.Client.GetDoc(ctx, dataset, orgId, itemId)
+		return err
+	})
+	return doc, err
+}
+
+func (r *ResilientFirestoreClient) DeleteDoc(ctx context.Context, dataset, collection, itemId string) error {
+	return callWithRetry(ctx, &r.Config, r.Breaker, true, func(ctx context.Context) error {
+		return r.Client.DeleteDoc(ctx, dataset, collection, itemId)
+	})
+}
+
+// RunTransaction is not retried, since the transactions of Firestore are already attempted again on conflicts.
+// The calls inside the transaction have no timeout other than the deadline of ctx
+func (r *ResilientFirestoreClient) RunTransaction(ctx context.Context, fn func(ctx context.Context, client FirestoreClient) error) error {
+	client, ok := r.Client.(TransactionalClient)
+	if !ok {
+		return fn(ctx, r)
+	}
+	if err := r.Breaker.Allow(); err != nil {
+		return err
+	}
+	err := client.RunTransaction(ctx, fn)
+	r.Breaker.Record(err)
+	return err
+}
+
+func (r *ResilientFirestoreClient) Degraded() bool {
+	return r.Breaker.Degraded()
+}
+
+// ResilientBucketClient adds timeouts, retries and a circuit breaker to a BlobClient. All operations
+// are idempotent since objects are overwritten as a whole
+type ResilientBucketClient struct {
+	Client  BlobClient
+	Config  ResilienceConfig
+	Breaker *CircuitBreaker
+}
+
+func NewResilientBucketClient(client BlobClient, config *ResilienceConfig) *ResilientBucketClient {
+	return &ResilientBucketClient{Client: client, Config: *config, Breaker: NewCircuitBreaker(config)}
+}
+
+func (r *ResilientBucketClient) Upload(ctx context.Context, bucket, object string, data []byte) error {
+	return callWithRetry(ctx, &r.Config, r.Breaker, true, func(ctx context.Context) error {
+		return r.Client.Upload(ctx, bucket, object, data)
+	})
+}
+
+// GetObject only applies the circuit breaker. The reader uses the context until it is closed, so a timeout per
+// attempt would abort reads of large objects
+func (r *ResilientBucketClient) GetObject(ctx context.Context, bucket, objName string) (io.ReadCloser, error) {
+	var reader io.ReadCloser
+	config := r.Config
+	config.CallTimeout = 0
+	err := callWithRetry(ctx, &config, r.Breaker, true, func(context.Context) error {
+		var err error
+		reader, err = r.Client.GetObject(ctx, bucket, objName)
+		return err
+	})
+	return reader, err
+}
+
+func (r *ResilientBucketClient) GetObjects(ctx context.Context, bucket string, query *storage.Query) ObjectLister {
+	if err := r.Breaker.Allow(); err != nil {
+		return &failingObjectLister{err: err}
+	}
+	return &breakerObjectLister{lister: r.Client.GetObjects(ctx, bucket, query), breaker: r.Breaker}
+}
+
+func (r *ResilientBucketClient) SetStorageClass(ctx context.Context, bucket, object, class string) error {
+	return callWithRetry(ctx, &r.Config, r.Breaker, true, func(ctx context.Context) error {
+		return r.Client.SetStorageClass(ctx, bucket, object, class)
+	})
+}
+
+func (r *ResilientBucketClient) Delete(ctx context.Context, bucket, object string) error {
+	return callWithRetry(ctx, &r.Config, r.Breaker, true, func(ctx context.Context) error {
+		return r.Client.Delete(ctx, bucket, object)
+	})
+}
+
+// SignedURL is computed locally or by a single call to the IAM service, so it is passed on to the wrapped
+// client as is
+func (r *ResilientBucketClient) SignedURL(bucket, object, filename string, expires time.Time) (string, error) {
+	signer, ok := r.Client.(ObjectURLSigner)
+	if !ok {
+		return "", ErrSignedURLUnsupported
+	}
+	return signer.SignedURL(bucket, object, filename, expires)
+}
+
+func (r *ResilientBucketClient) ObjectSize(ctx context.Context, bucket, object string) (int64, error) {
+	ranger, ok := r.Client.(ObjectRangeReader)
+	if !ok {
+		return 0, ErrRangeReadUnsupported
+	}
+	var size int64
+	err := callWithRetry(ctx, &r.Config, r.Breaker, true, func(ctx context.Context) error {
+		var err error
+		size, err = ranger.ObjectSize(ctx, bucket, object)
+		return err
+	})
+	return size, err
+}
+
+// NewRangeReader only applies the circuit breaker, for the same reason as GetObject
+func (r *ResilientBucketClient) NewRangeReader(ctx context.Context, bucket, object string, offset, length int64) (io.ReadCloser, error) {
+	ranger, ok := r.Client.(ObjectRangeReader)
+	if !ok {
+		return nil, ErrRangeReadUnsupported
+	}
+	var reader io.ReadCloser
+	config := r.Config
+	config.CallTimeout = 0
+	err := callWithRetry(ctx, &config, r.Breaker, true, func(context.Context) error {
+		var err error
+		reader, err = ranger.NewRangeReader(ctx, bucket, object, offset, length)
+		return err
+	})
+	return reader, err
+}
+
+func (r *ResilientBucketClient) Degraded() bool {
+	return r.Breaker.Degraded()
+}
+
+type failingObjectLister struct {
+	err error
+}
+
+func (f *failingObjectLister) Next() (*storage.ObjectAttrs, error) {
+	return nil, f.err
+}
+
+// breakerObjectLister reports transient listing errors to the circuit breaker
+type breakerObjectLister struct {
+	lister  ObjectLister
+	breaker *CircuitBreaker
+}
+
+func (b *breakerObjectLister) Next() (*storage.ObjectAttrs, error) {
+	attrs, err := b.lister.Next()
+	if isTransientErr(err) {
+		b.breaker.Record(err)
+	}
+	return attrs, err
+}
+
+// Degraded reports whether the circuit breaker of any of the clients is open
+func (gs *GoogleStore) Degraded() bool {
+	for _, client := range []any{gs.FsClient, gs.BucketClient} {
+		if reporter, ok := client.(HealthReporter); ok && reporter.Degraded() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/storage"
+	"github.com/davidkleiven/caesura/testutils"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func testResilienceConfig() ResilienceConfig {
+	return ResilienceConfig{
+		MaxRetries:       2,
+		InitialBackoff:   time.Millisecond,
+		MaxBackoff:       time.Millisecond,
+		CallTimeout:      time.Second,
+		FailureThreshold: 3,
+		OpenDuration:     time.Minute,
+	}
+}
+
+// flakyFirestoreClient fails the first numFailures calls with err
+type flakyFirestoreClient struct {
+	*LocalFirestoreClient
+	numFailures int
+	numCalls    int
+	err         error
+}
+
+func (f *flakyFirestoreClient) fail() error {
+	f.numCalls++
+	if f.numCalls <= f.numFailures {
+		return f.err
+	}
+	return nil
+}
+
+func (f *flakyFirestoreClient) StoreDocument(ctx context.Context, dataset, orgId, itemId string, data any) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return f.LocalFirestoreClient.StoreDocument(ctx, dataset, orgId, itemId, data)
+}
+
+func (f *flakyFirestoreClient) Update(ctx context.Context, dataset, orgId, itemId string, update []firestore.Update) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return f.LocalFirestoreClient.Update(ctx, dataset, orgId, itemId, update)
+}
+
+func (f *flakyFirestoreClient) GetDoc(ctx context.Context, dataset, orgId, itemId string) (Document, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return f.LocalFirestoreClient.GetDoc(ctx, dataset, orgId, itemId)
+}
+
+func newFlakyClient(numFailures int, err error) (*ResilientFirestoreClient, *flakyFirestoreClient) {
+	flaky := flakyFirestoreClient{LocalFirestoreClient: NewLocalFirestoreClient(), numFailures: numFailures, err: err}
+	config := testResilienceConfig()
+	return NewResilientFirestoreClient(&flaky, &config), &flaky
+}
+
+func TestResilientClientRetriesTransientErrors(t *testing.T) {
+	client, flaky := newFlakyClient(2, status.Error(codes.Unavailable, "unavailable"))
+	testutils.AssertNil(t, client.StoreDocument(context.Background(), "dataset", "org", "item", &Organization{Name: "Band"}))
+	testutils.AssertEqual(t, flaky.numCalls, 3)
+	testutils.AssertEqual(t, client.Degraded(), false)
+}
+
+func TestResilientClientGivesUpAfterMaxRetries(t *testing.T) {
+	client, flaky := newFlakyClient(10, status.Error(codes.Unavailable, "unavailable"))
+	_, err := client.GetDoc(context.Background(), "dataset", "org", "item")
+	testutils.AssertEqual(t, status.Code(err), codes.Unavailable)
+	testutils.AssertEqual(t, flaky.numCalls, 3)
+}
+
+func TestResilientClientDoesNotRetry(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		call func(client *ResilientFirestoreClient) error
+		err  error
+	}{
+		{
+			name: "Not found",
+			call: func(client *ResilientFirestoreClient) error {
+				_, err := client.GetDoc(context.Background(), "dataset", "org", "item")
+				return err
+			},
+			err: status.Error(codes.NotFound, "not found"),
+		},
+		{
+			name: "Update is not idempotent",
+			call: func(client *ResilientFirestoreClient) error {
+				return client.Update(context.Background(), "dataset", "org", "item", nil)
+			},
+			err: status.Error(codes.Unavailable, "unavailable"),
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			client, flaky := newFlakyClient(10, test.err)
+			err := test.call(client)
+			testutils.AssertEqual(t, status.Code(err), status.Code(test.err))
+			testutils.AssertEqual(t, flaky.numCalls, 1)
+		})
+	}
+}
+
+func TestResilientClientOpensCircuit(t *testing.T) {
+	client, flaky := newFlakyClient(10, status.Error(codes.DeadlineExceeded, "slow"))
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	client.Breaker.Now = func() time.Time { return now }
+
+	_, err := client.GetDoc(context.Background(), "dataset", "org", "item")
+	testutils.AssertEqual(t, errors.Is(err, ErrCircuitOpen), false)
+	testutils.AssertEqual(t, client.Degraded(), true)
+
+	// Calls fail fast without reaching the backend while the circuit is open
+	numCalls := flaky.numCalls
+	err = client.StoreDocument(context.Background(), "dataset", "org", "item", &Organization{})
+	testutils.AssertEqual(t, errors.Is(err, ErrCircuitOpen), true)
+	testutils.AssertEqual(t, errors.Is(err, ErrStoreUnavailable), true)
+	testutils.AssertEqual(t, flaky.numCalls, numCalls)
+	testutils.AssertEqual(t, len(slices.Collect(client.GetDocByPrefix(context.Background(), "dataset", "org", "name", ""))), 0)
+
+	// A success after the circuit has been open long enough closes it
+	now = now.Add(2 * time.Minute)
+	flaky.numFailures = 0
+	testutils.AssertNil(t, client.StoreDocument(context.Background(), "dataset", "org", "item", &Organization{}))
+	testutils.AssertEqual(t, client.Degraded(), false)
+}
+
+func TestCircuitBreakerIgnoresNonTransientErrors(t *testing.T) {
+	config := testResilienceConfig()
+	breaker := NewCircuitBreaker(&config)
+	for range 10 {
+		breaker.Record(ErrResourceNotFound)
+	}
+	testutils.AssertEqual(t, breaker.Degraded(), false)
+	testutils.AssertNil(t, breaker.Allow())
+}
+
+func TestCallWithRetryStopsWhenContextIsDone(t *testing.T) {
+	config := testResilienceConfig()
+	config.InitialBackoff = time.Hour
+	config.MaxBackoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	numCalls := 0
+	err := callWithRetry(ctx, &config, NewCircuitBreaker(&config), true, func(ctx context.Context) error {
+		numCalls++
+		return status.Error(codes.Unavailable, "unavailable")
+	})
+	testutils.AssertEqual(t, errors.Is(err, context.DeadlineExceeded), true)
+	testutils.AssertEqual(t, numCalls, 1)
+}
+
+func TestResilientBucketClient(t *testing.T) {
+	config := testResilienceConfig()
+	client := NewResilientBucketClient(&FileBucketClient{Directory: t.TempDir()}, &config)
+	ctx := context.Background()
+	testutils.AssertNil(t, client.Upload(ctx, "bucket", "org/a/1.pdf", []byte("content")))
+
+	reader, err := client.GetObject(ctx, "bucket", "org/a/1.pdf")
+	testutils.AssertNil(t, err)
+	content, err := io.ReadAll(reader)
+	testutils.AssertNil(t, errors.Join(err, reader.Close()))
+	testutils.AssertEqual(t, string(content), "content")
+
+	attrs, err := client.GetObjects(ctx, "bucket", &storage.Query{Prefix: "org/"}).Next()
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, attrs.Name, "org/a/1.pdf")
+	testutils.AssertNil(t, client.SetStorageClass(ctx, "bucket", "org/a/1.pdf", "COLDLINE"))
+
+	for range config.FailureThreshold {
+		client.Breaker.Record(status.Error(codes.Unavailable, "unavailable"))
+	}
+	_, err = client.GetObjects(ctx, "bucket", &storage.Query{Prefix: "org/"}).Next()
+	testutils.AssertEqual(t, errors.Is(err, ErrCircuitOpen), true)
+	_, err = client.GetObject(ctx, "bucket", "org/a/1.pdf")
+	testutils.AssertEqual(t, errors.Is(err, ErrCircuitOpen), true)
+}
+
+func TestGoogleStoreDegraded(t *testing.T) {
+	config := testResilienceConfig()
+	fsClient := NewResilientFirestoreClient(NewLocalFirestoreClient(), &config)
+	store := GoogleStore{FsClient: fsClient, BucketClient: &FileBucketClient{Directory: t.TempDir()}}
+	testutils.AssertEqual(t, store.Degraded(), false)
+
+	for range config.FailureThreshold {
+		fsClient.Breaker.Record(context.DeadlineExceeded)
+	}
+	testutils.AssertEqual(t, store.Degraded(), true)
+
+	_, err := store.MetaById(context.Background(), "org", "resource")
+	testutils.AssertEqual(t, errors.Is(err, ErrCircuitOpen), true)
+}
+
+// flakyBucketClient fails the first numFailures uploads with err
+type flakyBucketClient struct {
+	*FileBucketClient
+	numFailures int
+	numCalls    int
+	err         error
+}
+
+func (f *flakyBucketClient) Upload(ctx context.Context, bucket, object string, data []byte) error {
+	f.numCalls++
+	if f.numCalls <= f.numFailures {
+		return f.err
+	}
+	return f.FileBucketClient.Upload(ctx, bucket, object, data)
+}
+
+func TestResilientBucketClientRetriesUnavailableBucket(t *testing.T) {
+	config := testResilienceConfig()
+	flaky := flakyBucketClient{FileBucketClient: &FileBucketClient{Directory: t.TempDir()}, numFailures: 2, err: &googleapi.Error{Code: 503}}
+	client := NewResilientBucketClient(&flaky, &config)
+	testutils.AssertNil(t, client.Upload(context.Background(), "bucket", "org/a/1.pdf", []byte("content")))
+	testutils.AssertEqual(t, flaky.numCalls, 3)
+
+	flaky.numCalls = 0
+	flaky.err = &googleapi.Error{Code: 403}
+	err := client.Upload(context.Background(), "bucket", "org/a/1.pdf", []byte("content"))
+	testutils.AssertEqual(t, err, flaky.err)
+	testutils.AssertEqual(t, flaky.numCalls, 1)
+}
+
+func TestWithJitter(t *testing.T) {
+	testutils.AssertEqual(t, withJitter(time.Second, 0), time.Second)
+	for range 100 {
+		wait := withJitter(time.Second, 0.2)
+		testutils.AssertEqual(t, wait >= 800*time.Millisecond && wait <= 1200*time.Millisecond, true)
+	}
+}
+
+func TestResilientBucketClientSignedURLUnsupported(t *testing.T) {
+	config := testResilienceConfig()
+	client := NewResilientBucketClient(&FileBucketClient{Directory: t.TempDir()}, &config)
+	_, err := client.SignedURL("bucket", "org/a/1.pdf", "1.pdf", time.Now().Add(time.Hour))
+	testutils.AssertEqual(t, errors.Is(err, ErrSignedURLUnsupported), true)
+}
@@ -0,0 +1,160 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const MaxBulkResources = 500
+
+type BulkAction string
+
+const (
+	// BulkDelete moves the resources to the trash. Protected resources are left untouched
+	BulkDelete BulkAction = "delete"
+
+	// BulkAddToProject adds the resources to a project, which is created if it does not exist
+	BulkAddToProject BulkAction = "add-to-project"
+
+	// BulkTag adds tags to the resources. Tags the resources already have are not repeated
+	BulkTag BulkAction = "tag"
+)
+
+type BulkRequest struct {
+	Action      BulkAction `json:"action"`
+	ResourceIds []string   `json:"resourceIds"`
+
+	// Project is the name of the project of add-to-project
+	Project string `json:"project,omitempty"`
+
+	// Tags is a comma separated list of the tags added by tag
+	Tags string `json:"tags,omitempty"`
+}
+
+// Validate removes duplicate resource ids and checks that the action has the values it needs
+func (b *BulkRequest) Validate() error {
+	b.ResourceIds = RemoveDuplicates(slices.DeleteFunc(b.ResourceIds, func(id string) bool { return id == "" }))
+	if len(b.ResourceIds) == 0 || len(b.ResourceIds) > MaxBulkResources {
+		return errors.Join(ErrInvalidBulkRequest, fmt.Errorf("number of resources must be between 1 and %d", MaxBulkResources))
+	}
+
+	b.Project = strings.TrimSpace(b.Project)
+	switch b.Action {
+	case BulkDelete:
+	case BulkAddToProject:
+		if b.Project == "" {
+			return errors.Join(ErrInvalidBulkRequest, errors.New("a project is required"))
+		}
+	case BulkTag:
+		if len(ParseTemplateList(b.Tags)) == 0 {
+			return errors.Join(ErrInvalidBulkRequest, errors.New("at least one tag is required"))
+		}
+	default:
+		return errors.Join(ErrInvalidBulkRequest, fmt.Errorf("unknown action %q", b.Action))
+	}
+	return nil
+}
+
+type BulkResult struct {
+	ResourceId string `json:"resourceId"`
+	Ok         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+}
+
+type BulkStore interface {
+	ResourceDeleter
+	Transactor
+	MetaDataPatcher
+}
+
+// addTags appends the tags that are not already in existing. Tags are compared without regard to case
+func addTags(existing string, tags []string) string {
+	result := ParseTemplateList(existing)
+	for _, tag := range tags {
+		if !slices.ContainsFunc(result, func(t string) bool { return strings.EqualFold(t, tag) }) {
+			result = append(result, tag)
+		}
+	}
+	return strings.Join(result, ",")
+}
+
+func tagResource(ctx context.Context, store MetaDataPatcher, orgId, resourceId string, tags []string) error {
+	meta, err := store.MetaById(ctx, orgId, resourceId)
+	if err != nil {
+		return err
+	}
+	if meta.Deleted {
+		return errors.Join(ErrResourceMetadataNotFound, fmt.Errorf("%s is deleted", resourceId))
+	}
+	meta.Tags = addTags(meta.Tags, tags)
+	return store.UpdateMetaData(ctx, orgId, meta)
+}
+
+// bulkAddToProject adds the resources that exist and are not in the trash to the project in one transaction, such
+// that a missing resource does not stop the others from being added
+func bulkAddToProject(ctx context.Context, store BulkStore, orgId string, request *BulkRequest, results []BulkResult) {
+	var valid []string
+	for i, resourceId := range request.ResourceIds {
+		meta, err := store.MetaById(ctx, orgId, resourceId)
+		switch {
+		case err != nil:
+			results[i].Error = err.Error()
+		case meta.Deleted:
+			results[i].Error = errors.Join(ErrResourceMetadataNotFound, fmt.Errorf("%s is deleted", resourceId)).Error()
+		default:
+			valid = append(valid, resourceId)
+		}
+	}
+	if len(valid) == 0 {
+		return
+	}
+
+	now := time.Now()
+	project := Project{Name: request.Project, ResourceIds: valid, CreatedAt: now, UpdatedAt: now}
+	err := AddToProject(ctx, store, orgId, &project)
+	for i := range results {
+		if results[i].Error != "" {
+			continue
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Ok = true
+	}
+}
+
+// ApplyBulk applies the action of a validated request to each resource. A resource that fails does not affect the
+// others, and the outcome is reported in the result with the same index as the resource id
+func ApplyBulk(ctx context.Context, store BulkStore, orgId string, request *BulkRequest) []BulkResult {
+	results := make([]BulkResult, len(request.ResourceIds))
+	for i, resourceId := range request.ResourceIds {
+		results[i].ResourceId = resourceId
+	}
+
+	if request.Action == BulkAddToProject {
+		bulkAddToProject(ctx, store, orgId, request, results)
+		return results
+	}
+
+	tags := ParseTemplateList(request.Tags)
+	for i, resourceId := range request.ResourceIds {
+		var err error
+		switch request.Action {
+		case BulkDelete:
+			err = store.DeleteResource(ctx, orgId, resourceId)
+		case BulkTag:
+			err = tagResource(ctx, store, orgId, resourceId, tags)
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Ok = true
+	}
+	return results
+}
@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestBulkRequestValidate(t *testing.T) {
+	tooManyIds := make([]string, MaxBulkResources+1)
+	for i := range tooManyIds {
+		tooManyIds[i] = fmt.Sprintf("piece%d", i)
+	}
+
+	request := BulkRequest{Action: BulkTag, ResourceIds: []string{"a", "", "b", "a"}, Tags: "jul"}
+	testutils.AssertNil(t, request.Validate())
+	testutils.AssertEqual(t, strings.Join(request.ResourceIds, ","), "a,b")
+
+	for _, test := range []struct {
+		desc    string
+		request BulkRequest
+	}{
+		{"no resources", BulkRequest{Action: BulkDelete}},
+		{"too many", BulkRequest{Action: BulkDelete, ResourceIds: tooManyIds}},
+		{"unknown action", BulkRequest{Action: "archive", ResourceIds: []string{"a"}}},
+		{"no project", BulkRequest{Action: BulkAddToProject, ResourceIds: []string{"a"}, Project: " "}},
+		{"no tags", BulkRequest{Action: BulkTag, ResourceIds: []string{"a"}, Tags: " , "}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			testutils.AssertEqual(t, errors.Is(test.request.Validate(), ErrInvalidBulkRequest), true)
+		})
+	}
+}
+
+func TestAddTags(t *testing.T) {
+	testutils.AssertEqual(t, addTags("", []string{"jul"}), "jul")
+	testutils.AssertEqual(t, addTags("bebop, Jul", []string{"jul", "standard"}), "bebop,Jul,standard")
+}
+
+func TestApplyBulk(t *testing.T) {
+	ctx := context.Background()
+	newStore := func() *MultiOrgInMemoryStore {
+		store := NewMultiOrgInMemoryStore()
+		inMem := NewInMemoryStore()
+		inMem.Metadata = []MetaData{{Title: "A", Tags: "pop"}, {Title: "B", Protected: true}, {Title: "C", Deleted: true}}
+		store.Data["org"] = inMem
+		return store
+	}
+	resourceIds := []string{"a", "b", "c", "missing"}
+
+	okResults := func(results []BulkResult) string {
+		var ok []string
+		for _, result := range results {
+			if result.Ok {
+				ok = append(ok, result.ResourceId)
+			} else {
+				testutils.AssertEqual(t, result.Error != "", true)
+			}
+		}
+		return strings.Join(ok, ",")
+	}
+
+	t.Run("delete", func(t *testing.T) {
+		store := newStore()
+		results := ApplyBulk(ctx, store, "org", &BulkRequest{Action: BulkDelete, ResourceIds: resourceIds})
+		testutils.AssertEqual(t, okResults(results), "a,c")
+		meta, err := store.MetaById(ctx, "org", "a")
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, meta.Deleted, true)
+	})
+
+	t.Run("tag", func(t *testing.T) {
+		store := newStore()
+		results := ApplyBulk(ctx, store, "org", &BulkRequest{Action: BulkTag, ResourceIds: resourceIds, Tags: "jul, pop"})
+		testutils.AssertEqual(t, okResults(results), "a,b")
+		meta, err := store.MetaById(ctx, "org", "a")
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, meta.Tags, "pop,jul")
+	})
+
+	t.Run("add to project", func(t *testing.T) {
+		store := newStore()
+		results := ApplyBulk(ctx, store, "org", &BulkRequest{Action: BulkAddToProject, ResourceIds: resourceIds, Project: "Spring"})
+		testutils.AssertEqual(t, okResults(results), "a,b")
+		project, err := store.ProjectById(ctx, "org", "spring")
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, strings.Join(project.ResourceIds, ","), "a,b")
+	})
+}
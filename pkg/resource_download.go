@@ -2,7 +2,10 @@ package pkg
 
 import (
 	"archive/zip"
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"iter"
 	"strings"
@@ -15,9 +18,26 @@ type ZipWriter interface {
 
 type ResourceDownloader struct {
 	meta        *MetaData
-	contentIter iter.Seq2[string, []byte]
+	contentIter iter.Seq2[string, io.Reader]
 	zwFactory   func(w io.Writer) ZipWriter
 	Error       error
+
+	// Header stamped on each page of the parts written. Empty leaves the parts unchanged
+	stamp string
+
+	// Number of files written to zip archives
+	NumFiles int
+}
+
+// Readers wraps parts held in memory such that they can be used where parts are streamed
+func Readers(parts iter.Seq2[string, []byte]) iter.Seq2[string, io.Reader] {
+	return func(yield func(string, io.Reader) bool) {
+		for name, content := range parts {
+			if !yield(name, bytes.NewReader(content)) {
+				return
+			}
+		}
+	}
 }
 
 func (r *ResourceDownloader) GetMetaData(ctx context.Context, store ResourceGetter, orgId, id string) *ResourceDownloader {
@@ -28,23 +48,61 @@ func (r *ResourceDownloader) GetMetaData(ctx context.Context, store ResourceGett
 	return r
 }
 
+// ETag is the entity tag of the part named filename, or of the zip file with all parts when filename is empty.
+// Stamped parts have no entity tag, since the stamp is not part of the content hash
+func (r *ResourceDownloader) ETag(filename string) string {
+	if r.Error != nil || r.meta == nil || r.stamp != "" {
+		return ""
+	}
+	if filename == "" {
+		return r.meta.ResourceETag()
+	}
+	return r.meta.PartETag(filename)
+}
+
+// Rehydrate moves a resource in cold storage back to the standard storage class
+func (r *ResourceDownloader) Rehydrate(ctx context.Context, store StorageClassTransitioner, orgId string) *ResourceDownloader {
+	if r.Error != nil || r.meta.StorageClass != StorageClassCold {
+		return r
+	}
+	r.Error = store.TransitionStorageClass(ctx, orgId, r.meta.ResourceId(), StorageClassStandard)
+	return r
+}
+
 func (r *ResourceDownloader) GetResource(ctx context.Context, store ResourceGetter, orgId string) *ResourceDownloader {
 	if r.Error != nil {
 		return r
 	}
-	r.contentIter = store.Resource(ctx, orgId, r.meta.ResourceId())
+	r.contentIter = store.ResourceStream(ctx, orgId, r.meta.ResourceId())
+	return r
+}
+
+// GetVersion fetches an earlier version of the resource instead of the current parts
+func (r *ResourceDownloader) GetVersion(ctx context.Context, store ResourceVersioner, orgId string, version int) *ResourceDownloader {
+	if r.Error != nil {
+		return r
+	}
+	r.contentIter = Readers(store.ResourceVersion(ctx, orgId, r.meta.ResourceId(), version))
 	return r
 }
 
+// ExtractSingleFile streams the part named filename to w. ErrFileNotFound is set when the resource has no
+// such part, in which case nothing is written
 func (r *ResourceDownloader) ExtractSingleFile(filename string, w io.Writer) *ResourceDownloader {
+	if r.Error != nil {
+		return r
+	}
 	for name, file := range r.contentIter {
 		if name == filename {
-			if _, err := w.Write(file); err != nil {
-				r.Error = err
-				return r
+			if r.stamp != "" {
+				r.Error = copyStamped(w, name, file, r.stamp)
+			} else {
+				_, r.Error = io.Copy(w, file)
 			}
+			return r
 		}
 	}
+	r.Error = errors.Join(ErrFileNotFound, fmt.Errorf("file: %s", filename))
 	return r
 }
 
@@ -67,12 +125,25 @@ func MatchAny(tokens []string) func(string) bool {
 	}
 }
 
+// ZipResource streams the parts of the resource into a new zip archive. The archive is only completed on
+// success, such that nothing is written to w when the resource has no parts
 func (r *ResourceDownloader) ZipResource(w io.Writer, include func(string) bool) *ResourceDownloader {
 	if r.Error != nil {
 		return r
 	}
 	zw := r.zwFactory(w)
-	defer zw.Close()
+	if r.AddToZip(zw, "", include).Error == nil {
+		r.Error = zw.Close()
+	}
+	return r
+}
+
+// AddToZip streams the parts of the resource into an existing archive. The part names are prefixed by prefix,
+// which allows several resources to be combined in one archive without reading them into memory
+func (r *ResourceDownloader) AddToZip(zw ZipWriter, prefix string, include func(string) bool) *ResourceDownloader {
+	if r.Error != nil {
+		return r
+	}
 	resourceExist := false
 
 	for name, content := range r.contentIter {
@@ -80,15 +151,21 @@ func (r *ResourceDownloader) ZipResource(w io.Writer, include func(string) bool)
 		if !include(name) {
 			continue
 		}
-		subwriter, err := zw.Create(name)
+		subwriter, err := zw.Create(prefix + name)
 		if err != nil {
 			r.Error = err
 			return r
 		}
-		if _, err := subwriter.Write(content); err != nil {
+		if r.stamp != "" {
+			err = copyStamped(subwriter, name, content, r.stamp)
+		} else {
+			_, err = io.Copy(subwriter, content)
+		}
+		if err != nil {
 			r.Error = err
 			return r
 		}
+		r.NumFiles++
 	}
 
 	if !resourceExist {
@@ -97,6 +174,13 @@ func (r *ResourceDownloader) ZipResource(w io.Writer, include func(string) bool)
 	return r
 }
 
+// Stamp sets a header, such as the project and date of a rehearsal, that is stamped at the top of each page of
+// the parts written. Printed copies can then be traced to the rehearsal they were made for
+func (r *ResourceDownloader) Stamp(text string) *ResourceDownloader {
+	r.stamp = text
+	return r
+}
+
 func (r *ResourceDownloader) Filenames() []string {
 	result := []string{}
 	for name := range r.contentIter {
@@ -114,7 +198,7 @@ func (r *ResourceDownloader) ZipFilename() string {
 func NewResourceDownloader() *ResourceDownloader {
 	return &ResourceDownloader{
 		meta:        &MetaData{},
-		contentIter: func(yield func(string, []byte) bool) {},
+		contentIter: func(yield func(string, io.Reader) bool) {},
 		zwFactory: func(w io.Writer) ZipWriter {
 			return zip.NewWriter(w)
 		},
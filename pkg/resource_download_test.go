@@ -7,6 +7,7 @@ import (
 	"errors"
 	"io"
 	"slices"
+	"strings"
 	"testing"
 
 	"github.com/davidkleiven/caesura/testutils"
@@ -87,13 +88,13 @@ func (f *failingWriter) Write(p []byte) (int, error) {
 
 func TestErrorSetOnFailingWrite(t *testing.T) {
 	downloader := ResourceDownloader{
-		contentIter: func(yield func(n string, b []byte) bool) {
+		contentIter: Readers(func(yield func(n string, b []byte) bool) {
 			for range 1 {
 				if !yield("name", []byte("content")) {
 					return
 				}
 			}
-		},
+		}),
 	}
 
 	err := downloader.ExtractSingleFile("name", &failingWriter{}).Error
@@ -163,3 +164,94 @@ func TestErrorSetOnFailingSubwriter(t *testing.T) {
 
 	testutils.AssertContains(t, downloader.Error.Error(), "could not write to file")
 }
+
+type trackingReadCloser struct {
+	io.Reader
+	open *int
+}
+
+func (t *trackingReadCloser) Close() error {
+	*t.open--
+	return nil
+}
+
+// trackingBucketClient counts the number of objects that are open at the same time
+type trackingBucketClient struct {
+	*FileBucketClient
+	open    int
+	maxOpen int
+}
+
+func (t *trackingBucketClient) GetObject(ctx context.Context, bucket, objName string) (io.ReadCloser, error) {
+	reader, err := t.FileBucketClient.GetObject(ctx, bucket, objName)
+	if err != nil {
+		return nil, err
+	}
+	t.open++
+	t.maxOpen = max(t.maxOpen, t.open)
+	return &trackingReadCloser{Reader: reader, open: &t.open}, nil
+}
+
+func TestGoogleStoreStreamsOneObjectAtATime(t *testing.T) {
+	bucket := &trackingBucketClient{FileBucketClient: &FileBucketClient{Directory: t.TempDir()}}
+	store := GoogleStore{
+		FsClient:     NewLocalFirestoreClient(),
+		BucketClient: bucket,
+		Config:       &GoogleConfig{Bucket: "scores"},
+	}
+	ctx := context.Background()
+	meta := MetaData{Title: "Polka"}
+	parts := func(yield func(string, []byte) bool) {
+		for _, name := range []string{"Horn.pdf", "Flute.pdf", "Tuba.pdf"} {
+			if !yield(name, []byte(name)) {
+				return
+			}
+		}
+	}
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, parts))
+
+	var buf bytes.Buffer
+	downloader := NewResourceDownloader().GetMetaData(ctx, &store, "org", meta.ResourceId()).GetResource(ctx, &store, "org")
+	testutils.AssertNil(t, downloader.ZipResource(&buf, IncludeAll).Error)
+	testutils.AssertEqual(t, downloader.NumFiles, 3)
+	testutils.AssertEqual(t, bucket.maxOpen, 1)
+	testutils.AssertEqual(t, bucket.open, 0)
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	testutils.AssertNil(t, err)
+	reader, err := archive.Open("Tuba.pdf")
+	testutils.AssertNil(t, err)
+	content, err := io.ReadAll(reader)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, string(content), "Tuba.pdf")
+}
+
+func TestAddToZipPrefixesNames(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	downloader := populatedDownloader()
+	testutils.AssertNil(t, downloader.AddToZip(zw, "piece_", MatchAny([]string{"part1", "part2"})).Error)
+	testutils.AssertNil(t, zw.Close())
+	testutils.AssertEqual(t, downloader.NumFiles, 2)
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	testutils.AssertNil(t, err)
+	names := []string{archive.File[0].Name, archive.File[1].Name}
+	slices.Sort(names)
+	testutils.AssertEqual(t, strings.Join(names, ","), "piece_Part1.pdf,piece_Part2.pdf")
+}
+
+func TestZipResourceWritesNothingWithoutParts(t *testing.T) {
+	var buf bytes.Buffer
+	NewResourceDownloader().ZipResource(&buf, IncludeAll)
+	testutils.AssertEqual(t, buf.Len(), 0)
+}
+
+func TestExtractSingleFileMissingPart(t *testing.T) {
+	downloader := populatedDownloader()
+	var buf bytes.Buffer
+
+	err := downloader.ExtractSingleFile("Unknown.pdf", &buf).Error
+	testutils.AssertEqual(t, errors.Is(err, ErrFileNotFound), true)
+	testutils.AssertEqual(t, buf.Len(), 0)
+}
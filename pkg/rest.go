@@ -0,0 +1,135 @@
+package pkg
+
+import (
+	"slices"
+	"time"
+)
+
+// The schemas of version 1 of the JSON API. Fields may be added, but are never renamed or removed, such that
+// clients written against the version keep working
+
+type RestResource struct {
+	Id              string    `json:"id"`
+	Title           string    `json:"title"`
+	Composer        string    `json:"composer"`
+	Arranger        string    `json:"arranger"`
+	Genre           string    `json:"genre"`
+	Year            string    `json:"year"`
+	Instrumentation string    `json:"instrumentation"`
+	DurationSeconds int       `json:"duration_seconds"`
+	Publisher       string    `json:"publisher"`
+	Ismn            string    `json:"ismn"`
+	Tags            string    `json:"tags"`
+	Notes           string    `json:"notes"`
+	Protected       bool      `json:"protected"`
+	SubmittedAt     time.Time `json:"submitted_at"`
+}
+
+type RestProject struct {
+	Id          string    `json:"id"`
+	Name        string    `json:"name"`
+	ResourceIds []string  `json:"resource_ids"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Resources is only filled in when a single project is requested
+	Resources []RestResource `json:"resources,omitempty"`
+}
+
+type RestUser struct {
+	Id     string   `json:"id"`
+	Name   string   `json:"name"`
+	Email  string   `json:"email"`
+	Role   string   `json:"role"`
+	Groups []string `json:"groups"`
+}
+
+type RestOrganization struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+
+	// Role is the role the request acts with, which is limited by the scope of a token
+	Role string `json:"role"`
+}
+
+// Listings wrap the items in an object, such that fields like a cursor can be added later
+
+type RestResourceList struct {
+	Items []RestResource `json:"items"`
+}
+
+type RestProjectList struct {
+	Items []RestProject `json:"items"`
+}
+
+type RestUserList struct {
+	Items []RestUser `json:"items"`
+}
+
+type RestOrganizationList struct {
+	Items []RestOrganization `json:"items"`
+}
+
+// RestRoleName is the name of the role in the API. The names are the same as in SCIM
+func RestRoleName(role RoleKind) string {
+	return scimRoleName(role)
+}
+
+func NewRestResource(meta *MetaData) RestResource {
+	return RestResource{
+		Id:              meta.ResourceId(),
+		Title:           meta.Title,
+		Composer:        meta.Composer,
+		Arranger:        meta.Arranger,
+		Genre:           meta.Genre,
+		Year:            meta.Year,
+		Instrumentation: meta.Instrumentation,
+		DurationSeconds: int(time.Duration(meta.Duration).Seconds()),
+		Publisher:       meta.Publisher,
+		Ismn:            meta.Ismn,
+		Tags:            meta.Tags,
+		Notes:           meta.Notes,
+		Protected:       meta.Protected,
+		SubmittedAt:     meta.SubmittedAt,
+	}
+}
+
+// NewRestResources converts the metadata to resources. Resources in the trash are left out
+func NewRestResources(metaData []MetaData) []RestResource {
+	resources := make([]RestResource, 0, len(metaData))
+	for _, meta := range metaData {
+		if !meta.Deleted {
+			resources = append(resources, NewRestResource(&meta))
+		}
+	}
+	return resources
+}
+
+func NewRestProject(project *Project) RestProject {
+	resourceIds := slices.Clone(project.ResourceIds)
+	if resourceIds == nil {
+		resourceIds = []string{}
+	}
+	return RestProject{
+		Id:          project.Id(),
+		Name:        project.Name,
+		ResourceIds: resourceIds,
+		CreatedAt:   project.CreatedAt,
+		UpdatedAt:   project.UpdatedAt,
+	}
+}
+
+// NewRestUser converts the user to its representation in the organization
+func NewRestUser(user *UserInfo, orgId string) RestUser {
+	groups := slices.Clone(user.Groups[orgId])
+	if groups == nil {
+		groups = []string{}
+	}
+	return RestUser{
+		Id:     user.Id,
+		Name:   user.Name,
+		Email:  user.Email,
+		Role:   RestRoleName(user.Roles[orgId]),
+		Groups: groups,
+	}
+}
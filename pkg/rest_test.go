@@ -0,0 +1,28 @@
+package pkg
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestRestSchemasHaveNoNullLists(t *testing.T) {
+	project := NewRestProject(&Project{Name: "Spring concert"})
+	user := NewRestUser(&UserInfo{Id: "user", Roles: map[string]RoleKind{"org": RoleLibrarian}}, "org")
+
+	data, err := json.Marshal([]any{project, user})
+	testutils.AssertNil(t, err)
+	testutils.AssertContains(t, string(data), `"resource_ids":[]`, `"groups":[]`, `"role":"librarian"`)
+}
+
+func TestNewRestResources(t *testing.T) {
+	resources := NewRestResources([]MetaData{
+		{Title: "Bolero", Duration: Duration(90 * time.Second)},
+		{Title: "Trashed", Deleted: true},
+	})
+	testutils.AssertEqual(t, len(resources), 1)
+	testutils.AssertEqual(t, resources[0].Id, "bolero")
+	testutils.AssertEqual(t, resources[0].DurationSeconds, 90)
+}
@@ -0,0 +1,111 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// RetentionPolicy gives how long each kind of log is kept. Zero keeps the log forever
+type RetentionPolicy struct {
+	// Pieces added to and removed from projects
+	Audit time.Duration `yaml:"audit"`
+
+	// Downloads of parts
+	Downloads time.Duration `yaml:"downloads"`
+
+	// Emails sent to members
+	Emails time.Duration `yaml:"emails"`
+}
+
+// Retention returns how long activity of a kind is kept
+func (r *RetentionPolicy) Retention(kind ActivityKind) time.Duration {
+	switch kind {
+	case ActivityDownload:
+		return r.Downloads
+	case ActivityEmailSent:
+		return r.Emails
+	default:
+		return r.Audit
+	}
+}
+
+// RetentionConfig holds the retention of the deployment, and of organizations that need to keep their logs for
+// a different time. Fields left at zero for an organization use the retention of the deployment
+type RetentionConfig struct {
+	RetentionPolicy `yaml:",inline"`
+
+	// How often logs older than the retention are removed. Zero disables the purge
+	Interval time.Duration `yaml:"interval"`
+
+	Organizations map[string]RetentionPolicy `yaml:"organizations"`
+}
+
+// Policy returns the retention of an organization
+func (r *RetentionConfig) Policy(orgId string) RetentionPolicy {
+	policy := r.RetentionPolicy
+	override, ok := r.Organizations[orgId]
+	if !ok {
+		return policy
+	}
+	if override.Audit > 0 {
+		policy.Audit = override.Audit
+	}
+	if override.Downloads > 0 {
+		policy.Downloads = override.Downloads
+	}
+	if override.Emails > 0 {
+		policy.Emails = override.Emails
+	}
+	return policy
+}
+
+var retainedActivityKinds = []ActivityKind{ActivityPieceAdded, ActivityPieceRemoved, ActivityDownload, ActivityEmailSent}
+
+type RetentionStore interface {
+	OrganizationLister
+	ActivityRemover
+}
+
+// RetentionPurge removes the activity of each organization that is older than its retention
+type RetentionPurge struct {
+	Store  RetentionStore
+	Config RetentionConfig
+	Now    func() time.Time
+}
+
+// Run removes expired activity and returns the number of removed activities
+func (r *RetentionPurge) Run(ctx context.Context) (int, error) {
+	orgs, err := r.Store.ListOrganizations(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	now := r.Now()
+	numRemoved := 0
+	for _, org := range orgs {
+		policy := r.Config.Policy(org.Id)
+		for _, kind := range retainedActivityKinds {
+			retention := policy.Retention(kind)
+			if retention <= 0 {
+				continue
+			}
+
+			num, removeErr := r.Store.RemoveActivity(ctx, org.Id, kind, now.Add(-retention))
+			numRemoved += num
+			if removeErr != nil {
+				err = errors.Join(err, removeErr)
+				continue
+			}
+			if num > 0 {
+				slog.InfoContext(ctx, "Removed expired activity", "orgId", org.Id, "kind", kind, "num", num)
+			}
+		}
+	}
+	return numRemoved, err
+}
+
+func NewRetentionPurge(store RetentionStore, config RetentionConfig) *RetentionPurge {
+	return &RetentionPurge{Store: store, Config: config, Now: time.Now}
+}
@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestRetentionPolicy(t *testing.T) {
+	config := RetentionConfig{
+		RetentionPolicy: RetentionPolicy{Audit: 365 * 24 * time.Hour, Downloads: 90 * 24 * time.Hour},
+		Organizations:   map[string]RetentionPolicy{"strict": {Downloads: 24 * time.Hour, Emails: 7 * 24 * time.Hour}},
+	}
+
+	policy := config.Policy("strict")
+	testutils.AssertEqual(t, policy.Retention(ActivityPieceAdded), 365*24*time.Hour)
+	testutils.AssertEqual(t, policy.Retention(ActivityDownload), 24*time.Hour)
+	testutils.AssertEqual(t, policy.Retention(ActivityEmailSent), 7*24*time.Hour)
+
+	policy = config.Policy("other")
+	testutils.AssertEqual(t, policy.Retention(ActivityDownload), 90*24*time.Hour)
+	testutils.AssertEqual(t, policy.Retention(ActivityEmailSent), time.Duration(0))
+}
+
+// assertActivityRemover checks that only activity of the given kind from before the given time is removed
+func assertActivityRemover(t *testing.T, store ActivityStore) {
+	ctx := context.Background()
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	for _, activity := range []Activity{
+		{Id: "old-download", Kind: ActivityDownload, UserId: "u", Time: now.Add(-48 * time.Hour)},
+		{Id: "new-download", Kind: ActivityDownload, UserId: "u", Time: now},
+		{Id: "old-email", Kind: ActivityEmailSent, UserId: "u", Time: now.Add(-48 * time.Hour)},
+	} {
+		testutils.AssertNil(t, store.RecordActivity(ctx, "org", &activity))
+	}
+
+	num, err := store.RemoveActivity(ctx, "org", ActivityDownload, now.Add(-24*time.Hour))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, num, 1)
+
+	activities, err := store.OrganizationActivity(ctx, "org", now.Add(-72*time.Hour), now.Add(time.Hour))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(activities), 2)
+	testutils.AssertEqual(t, activities[0].Id, "new-download")
+	testutils.AssertEqual(t, activities[1].Id, "old-email")
+}
+
+func TestInMemoryRemoveActivity(t *testing.T) {
+	assertActivityRemover(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGoogleStoreRemoveActivity(t *testing.T) {
+	assertActivityRemover(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
+
+func TestRetentionPurge(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	for _, orgId := range []string{"org", "strict"} {
+		testutils.AssertNil(t, store.RegisterOrganization(ctx, &Organization{Id: orgId}))
+		for _, kind := range []ActivityKind{ActivityPieceAdded, ActivityDownload, ActivityEmailSent} {
+			activity := NewActivity("", kind, "u", nil)
+			activity.Time = now.Add(-10 * 24 * time.Hour)
+			testutils.AssertNil(t, store.RecordActivity(ctx, orgId, activity))
+		}
+	}
+
+	purge := NewRetentionPurge(store, RetentionConfig{
+		RetentionPolicy: RetentionPolicy{Downloads: 30 * 24 * time.Hour},
+		Organizations:   map[string]RetentionPolicy{"strict": {Downloads: 7 * 24 * time.Hour, Emails: 7 * 24 * time.Hour}},
+	})
+	purge.Now = func() time.Time { return now }
+	num, err := purge.Run(ctx)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, num, 2)
+	testutils.AssertEqual(t, len(store.Activities["org"]), 3)
+	testutils.AssertEqual(t, len(store.Activities["strict"]), 1)
+	testutils.AssertEqual(t, store.Activities["strict"][0].Kind, ActivityPieceAdded)
+}
+
+func TestRetentionPurgeListError(t *testing.T) {
+	store := coldStorageStoreWithLister{
+		MultiOrgInMemoryStore: NewMultiOrgInMemoryStore(),
+		lister:                &MockIAMStore{ErrListOrganizations: errors.New("list failed")},
+	}
+	num, err := NewRetentionPurge(&store, RetentionConfig{}).Run(context.Background())
+	testutils.AssertEqual(t, num, 0)
+	testutils.AssertEqual(t, err != nil, true)
+}
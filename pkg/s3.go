@@ -0,0 +1,503 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"net/url"
+	"path"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	S3Compatible       = "s3"
+	s3DocumentPrefix   = "documents"
+	defaultColdS3Class = "STANDARD_IA"
+)
+
+type S3Config struct {
+	Endpoint         string `yaml:"endpoint" env:"CAESURA_S3_ENDPOINT"`
+	Region           string `yaml:"region" env:"CAESURA_S3_REGION"`
+	Bucket           string `yaml:"bucket" env:"CAESURA_S3_BUCKET"`
+	AccessKeyId      string `yaml:"access_key_id" env:"CAESURA_S3_ACCESS_KEY_ID" secret:"true"`
+	SecretAccessKey  string `yaml:"secret_access_key" env:"CAESURA_S3_SECRET_ACCESS_KEY" secret:"true"`
+	UsePathStyle     bool   `yaml:"use_path_style"`
+	ColdStorageClass string `yaml:"cold_storage_class" env:"CAESURA_S3_COLD_STORAGE_CLASS"`
+
+	// Store identical parts once, see DedupBucketClient
+	Deduplicate bool `yaml:"deduplicate"`
+}
+
+// NewS3Client creates a client for the configured endpoint. When no endpoint is given, AWS is used
+func NewS3Client(config *S3Config) *s3.Client {
+	options := s3.Options{
+		Region:       config.Region,
+		UsePathStyle: config.UsePathStyle,
+		Credentials:  credentials.NewStaticCredentialsProvider(config.AccessKeyId, config.SecretAccessKey, ""),
+	}
+	if config.Endpoint != "" {
+		options.BaseEndpoint = aws.String(config.Endpoint)
+	}
+	return s3.New(options)
+}
+
+// S3API is the subset of the S3 client used by the store
+type S3API interface {
+	s3.ListObjectsV2APIClient
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+func isNoSuchKey(err error) bool {
+	var noSuchKey *types.NoSuchKey
+	return errors.As(err, &noSuchKey)
+}
+
+type S3BucketClient struct {
+	Client           S3API
+	ColdStorageClass string
+}
+
+func (s *S3BucketClient) Upload(ctx context.Context, bucket, object string, data []byte) error {
+	_, err := s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(object),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+func (s *S3BucketClient) GetObject(ctx context.Context, bucket, objName string) (io.ReadCloser, error) {
+	out, err := s.Client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(objName)})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s *S3BucketClient) GetObjects(ctx context.Context, bucket string, query *storage.Query) ObjectLister {
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(bucket), Prefix: aws.String(query.Prefix)}
+	return &s3ObjectLister{
+		ctx:       ctx,
+		bucket:    bucket,
+		paginator: s3.NewListObjectsV2Paginator(s.Client, input),
+	}
+}
+
+// SetStorageClass copies the object onto itself with a new storage class. The cold storage class of GCS
+// is mapped to the configured cold storage class of the S3 provider
+func (s *S3BucketClient) SetStorageClass(ctx context.Context, bucket, object, class string) error {
+	s3Class := types.StorageClassStandard
+	if class == StorageClassCold.gcsStorageClass() {
+		s3Class = types.StorageClass(s.ColdStorageClass)
+	}
+	_, err := s.Client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String(bucket),
+		Key:               aws.String(object),
+		CopySource:        aws.String(url.PathEscape(bucket + "/" + object)),
+		StorageClass:      s3Class,
+		MetadataDirective: types.MetadataDirectiveCopy,
+	})
+	return err
+}
+
+func (s *S3BucketClient) Delete(ctx context.Context, bucket, object string) error {
+	_, err := s.Client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String(object)})
+	return err
+}
+
+type s3ObjectLister struct {
+	ctx       context.Context
+	bucket    string
+	paginator *s3.ListObjectsV2Paginator
+	page      []types.Object
+}
+
+func (s *s3ObjectLister) Next() (*storage.ObjectAttrs, error) {
+	for len(s.page) == 0 {
+		if !s.paginator.HasMorePages() {
+			return nil, iterator.Done
+		}
+		out, err := s.paginator.NextPage(s.ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.page = out.Contents
+	}
+	object := s.page[0]
+	s.page = s.page[1:]
+	return &storage.ObjectAttrs{
+		Bucket:  s.bucket,
+		Name:    aws.ToString(object.Key),
+		Size:    aws.ToInt64(object.Size),
+		Updated: aws.ToTime(object.LastModified),
+	}, nil
+}
+
+// S3DocumentClient stores documents as JSON objects in a bucket. The fields are named after the firestore tags
+// such that updates and prefix queries address the same fields as in Firestore. Prefix queries read all
+// documents in the collection, which is fine for the size of a self hosted library
+type S3DocumentClient struct {
+	Client S3API
+	Bucket string
+
+	// Serializes read-modify-write updates within this process
+	mu sync.Mutex
+}
+
+func (s *S3DocumentClient) key(dataset, orgId, itemId string) string {
+	return path.Join(s3DocumentPrefix, dataset, orgId, itemId) + ".json"
+}
+
+func (s *S3DocumentClient) put(ctx context.Context, key string, fields map[string]json.RawMessage) error {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	})
+	return err
+}
+
+func (s *S3DocumentClient) get(ctx context.Context, key string) (map[string]json.RawMessage, error) {
+	out, err := s.Client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(s.Bucket), Key: aws.String(key)})
+	if isNoSuchKey(err) {
+		return nil, status.Errorf(codes.NotFound, "%s not found", key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(out.Body).Decode(&fields); err != nil {
+		return nil, fmt.Errorf("could not decode document %s: %w", key, err)
+	}
+	return fields, nil
+}
+
+func (s *S3DocumentClient) StoreDocument(ctx context.Context, dataset, orgId, itemId string, data any) error {
+	fields, err := encodeFirestoreFields(data)
+	if err != nil {
+		return err
+	}
+	return s.put(ctx, s.key(dataset, orgId, itemId), fields)
+}
+
+// Update applies the updates to the stored document. Array unions and removals of strings are supported,
+// and increments always add one since that is the only increment issued by the stores. Field paths into
+// nested maps are supported, and firestore.Delete removes the field
+func (s *S3DocumentClient) Update(ctx context.Context, dataset, orgId, itemId string, update []firestore.Update) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.key(dataset, orgId, itemId)
+	fields, err := s.get(ctx, key)
+	if err != nil {
+		return err
+	}
+	for _, u := range update {
+		if err := applyUpdate(fields, u); err != nil {
+			return fmt.Errorf("could not update %s of %s: %w", updateName(u), key, err)
+		}
+	}
+	return s.put(ctx, key, fields)
+}
+
+func applyUpdate(fields map[string]json.RawMessage, u firestore.Update) error {
+	fieldPath, err := updatePath(u)
+	if err != nil {
+		return err
+	}
+	return applyFieldUpdate(fields, fieldPath, u.Value)
+}
+
+// updatePath returns the field path of the update. A path is split on dots like Firestore does
+func updatePath(u firestore.Update) ([]string, error) {
+	switch {
+	case u.Path != "" && len(u.FieldPath) > 0:
+		return nil, errors.New("only one of Path and FieldPath can be set")
+	case u.Path != "":
+		return strings.Split(u.Path, "."), nil
+	case len(u.FieldPath) > 0 && !slices.Contains(u.FieldPath, ""):
+		return u.FieldPath, nil
+	default:
+		return nil, errors.New("the update has no field path")
+	}
+}
+
+func updateName(u firestore.Update) string {
+	if u.Path != "" {
+		return u.Path
+	}
+	return strings.Join(u.FieldPath, ".")
+}
+
+// applyFieldUpdate applies the update to the field given by fieldPath. Fields of nested maps are decoded and
+// encoded again, and missing maps are created
+func applyFieldUpdate(fields map[string]json.RawMessage, fieldPath []string, v any) error {
+	name := fieldPath[0]
+	if len(fieldPath) > 1 {
+		nested := make(map[string]json.RawMessage)
+		if raw, ok := fields[name]; ok && string(raw) != "null" {
+			if err := json.Unmarshal(raw, &nested); err != nil {
+				return fmt.Errorf("%s is not a map: %w", name, err)
+			}
+		}
+		if err := applyFieldUpdate(nested, fieldPath[1:], v); err != nil {
+			return err
+		}
+		return setField(fields, name, nested)
+	}
+
+	if v == nil {
+		return setField(fields, name, nil)
+	}
+	if v == firestore.Delete {
+		delete(fields, name)
+		return nil
+	}
+
+	value := reflect.ValueOf(v)
+	switch value.Type().String() {
+	case "firestore.arrayUnion", "firestore.arrayRemove":
+		var current []string
+		if raw, ok := fields[name]; ok {
+			if err := json.Unmarshal(raw, &current); err != nil {
+				return err
+			}
+		}
+
+		elems := value.Field(0)
+		for i := range elems.Len() {
+			elem := elems.Index(i).Elem()
+			if elem.Kind() != reflect.String {
+				return fmt.Errorf("only string elements are supported in array updates got %s", elem.Kind())
+			}
+			if value.Type().Name() == "arrayRemove" {
+				current = slices.DeleteFunc(current, func(s string) bool { return s == elem.String() })
+			} else if !slices.Contains(current, elem.String()) {
+				current = append(current, elem.String())
+			}
+		}
+		return setField(fields, name, current)
+	case "firestore.transform":
+		var count int
+		if raw, ok := fields[name]; ok {
+			if err := json.Unmarshal(raw, &count); err != nil {
+				return err
+			}
+		}
+		return setField(fields, name, count+1)
+	case "firestore.sentinel":
+		return fmt.Errorf("%v is not supported", v)
+	default:
+		return setField(fields, name, v)
+	}
+}
+
+func setField(fields map[string]json.RawMessage, name string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	fields[name] = data
+	return nil
+}
+
+func (s *S3DocumentClient) GetDocByPrefix(ctx context.Context, dataset, orgId, field, prefix string) iter.Seq[Document] {
+	bucketClient := S3BucketClient{Client: s.Client}
+	query := storage.Query{Prefix: path.Join(s3DocumentPrefix, dataset, orgId) + "/"}
+	objects := bucketClient.GetObjects(ctx, s.Bucket, &query)
+	return func(yield func(doc Document) bool) {
+		for {
+			objAttr, err := objects.Next()
+			if err != nil {
+				logOnErrorNotDone(err)
+				return
+			}
+			fields, err := s.get(ctx, objAttr.Name)
+			if err != nil {
+				slog.Error("Could not read document", "key", objAttr.Name, "error", err)
+				continue
+			}
+
+			var content string
+			if err := json.Unmarshal(fields[field], &content); err != nil || !strings.HasPrefix(content, prefix) {
+				continue
+			}
+			if !yield(&JSONDocument{fields: fields}) {
+				return
+			}
+		}
+	}
+}
+
+// GetDocPage relies on the keys being listed in lexical order, which S3 guarantees for ListObjectsV2
+func (s *S3DocumentClient) GetDocPage(ctx context.Context, dataset, orgId, after string, limit int) iter.Seq[Document] {
+	bucketClient := S3BucketClient{Client: s.Client}
+	prefix := path.Join(s3DocumentPrefix, dataset, orgId) + "/"
+	objects := bucketClient.GetObjects(ctx, s.Bucket, &storage.Query{Prefix: prefix})
+	return func(yield func(doc Document) bool) {
+		for count := 0; count < limit; {
+			objAttr, err := objects.Next()
+			if err != nil {
+				logOnErrorNotDone(err)
+				return
+			}
+			if strings.TrimSuffix(strings.TrimPrefix(objAttr.Name, prefix), ".json") <= after {
+				continue
+			}
+			fields, err := s.get(ctx, objAttr.Name)
+			if err != nil {
+				slog.Error("Could not read document", "key", objAttr.Name, "error", err)
+				continue
+			}
+			count++
+			if !yield(&JSONDocument{fields: fields}) {
+				return
+			}
+		}
+	}
+}
+
+func (s *S3DocumentClient) GetDoc(ctx context.Context, dataset, orgId, itemId string) (Document, error) {
+	fields, err := s.get(ctx, s.key(dataset, orgId, itemId))
+	if err != nil {
+		return nil, err
+	}
+	return &JSONDocument{fields: fields}, nil
+}
+
+func (s *S3DocumentClient) DeleteDoc(ctx context.Context, dataset, collection, itemId string) error {
+	_, err := s.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.key(dataset, collection, itemId)),
+	})
+	return err
+}
+
+// JSONDocument is a document whose fields are JSON encoded and named after the firestore tags
+type JSONDocument struct {
+	fields map[string]json.RawMessage
+}
+
+func (s *JSONDocument) DataTo(obj any) error {
+	value := reflect.ValueOf(obj)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("obj must be a non-nil pointer to a struct")
+	}
+	return decodeFirestoreFields(s.fields, value.Elem())
+}
+
+// firestoreName returns the name of the field in a document. Embedded structs without a name are flattened
+func firestoreName(field reflect.StructField) (name string, flatten bool) {
+	name, _, _ = strings.Cut(field.Tag.Get("firestore"), ",")
+	if name == "" && field.Anonymous && field.Type.Kind() == reflect.Struct {
+		return "", true
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, false
+}
+
+func encodeFirestoreFields(data any) (map[string]json.RawMessage, error) {
+	value := reflect.Indirect(reflect.ValueOf(data))
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("documents must be structs got %s", value.Kind())
+	}
+
+	fields := make(map[string]json.RawMessage)
+	var encode func(v reflect.Value) error
+	encode = func(v reflect.Value) error {
+		for i := range v.NumField() {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, flatten := firestoreName(field)
+			switch {
+			case flatten:
+				if err := encode(v.Field(i)); err != nil {
+					return err
+				}
+			case name != "-":
+				if err := setField(fields, name, v.Field(i).Interface()); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return fields, encode(value)
+}
+
+func decodeFirestoreFields(fields map[string]json.RawMessage, v reflect.Value) error {
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, flatten := firestoreName(field)
+		if flatten {
+			if err := decodeFirestoreFields(fields, v.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, ok := fields[name]
+		if !ok || name == "-" {
+			continue
+		}
+		if err := json.Unmarshal(raw, v.Field(i).Addr().Interface()); err != nil {
+			return fmt.Errorf("could not decode field %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// S3Store keeps scores in an S3 compatible bucket (e.g. AWS or MinIO). The documents that the GoogleStore keeps
+// in Firestore are stored as JSON objects in the same bucket
+type S3Store struct {
+	GoogleStore
+}
+
+func NewS3Store(client S3API, config *S3Config) *S3Store {
+	coldClass := config.ColdStorageClass
+	if coldClass == "" {
+		coldClass = defaultColdS3Class
+	}
+	return &S3Store{
+		GoogleStore: GoogleStore{
+			BucketClient: &S3BucketClient{Client: client, ColdStorageClass: coldClass},
+			FsClient:     &S3DocumentClient{Client: client, Bucket: config.Bucket},
+			Config:       &GoogleConfig{Bucket: config.Bucket},
+		},
+	}
+}
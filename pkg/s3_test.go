@@ -0,0 +1,280 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/davidkleiven/caesura/testutils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeS3 struct {
+	mu             sync.Mutex
+	objects        map[string][]byte
+	storageClasses map[string]types.StorageClass
+	pageSize       int
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: make(map[string][]byte), storageClasses: make(map[string]types.StorageClass), pageSize: 2}
+}
+
+func (f *fakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)] = data
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (f *fakeS3) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	location := aws.ToString(params.Bucket) + "/" + aws.ToString(params.Key)
+	if _, ok := f.objects[location]; !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	f.storageClasses[location] = params.StorageClass
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func (f *fakeS3) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.objects, aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+// ListObjectsV2 returns pageSize objects per page to exercise pagination
+func (f *fakeS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bucketPrefix := aws.ToString(params.Bucket) + "/"
+	var keys []string
+	for location := range f.objects {
+		key := strings.TrimPrefix(location, bucketPrefix)
+		if strings.HasPrefix(location, bucketPrefix) && strings.HasPrefix(key, aws.ToString(params.Prefix)) && key > aws.ToString(params.ContinuationToken) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	out := &s3.ListObjectsV2Output{}
+	if len(keys) > f.pageSize {
+		keys = keys[:f.pageSize]
+		out.IsTruncated = aws.Bool(true)
+		out.NextContinuationToken = aws.String(keys[len(keys)-1])
+	}
+	for _, key := range keys {
+		out.Contents = append(out.Contents, types.Object{Key: aws.String(key), Size: aws.Int64(int64(len(f.objects[bucketPrefix+key])))})
+	}
+	return out, nil
+}
+
+func newTestS3Store() (*S3Store, *fakeS3) {
+	client := newFakeS3()
+	return NewS3Store(client, &S3Config{Bucket: "caesura"}), client
+}
+
+func TestS3BucketClientListsAllPages(t *testing.T) {
+	client := newFakeS3()
+	bucketClient := S3BucketClient{Client: client}
+	ctx := context.Background()
+	for _, name := range []string{"org/a/1.pdf", "org/a/2.pdf", "org/a/3.pdf", "other/b/1.pdf"} {
+		testutils.AssertNil(t, bucketClient.Upload(ctx, "bucket", name, []byte(name)))
+	}
+
+	objects := bucketClient.GetObjects(ctx, "bucket", &storage.Query{Prefix: "org/"})
+	var names []string
+	for {
+		attrs, err := objects.Next()
+		if err != nil {
+			break
+		}
+		testutils.AssertEqual(t, attrs.Bucket, "bucket")
+		names = append(names, attrs.Name)
+	}
+	testutils.AssertEqual(t, strings.Join(names, ","), "org/a/1.pdf,org/a/2.pdf,org/a/3.pdf")
+
+	_, err := bucketClient.GetObject(ctx, "bucket", "missing")
+	testutils.AssertEqual(t, isNoSuchKey(err), true)
+}
+
+func TestS3StoreSubmitAndRetrieve(t *testing.T) {
+	store, client := newTestS3Store()
+	ctx := context.Background()
+	meta := MetaData{Title: "Title", Composer: "Composer", Duration: Duration(90 * time.Second)}
+	parts := func(yield func(string, []byte) bool) {
+		for _, name := range []string{"Part1.pdf", "Part2.pdf"} {
+			if !yield(name, []byte(name)) {
+				return
+			}
+		}
+	}
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, parts))
+
+	stored, err := store.MetaById(ctx, "org", meta.ResourceId())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Status, StoreStatusFinished)
+	testutils.AssertEqual(t, stored.Duration, meta.Duration)
+
+	found, err := store.MetaByPattern(ctx, "org", &MetaData{Title: "tit"})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(found), 1)
+
+	content := maps.Collect(store.Resource(ctx, "org", meta.ResourceId()))
+	testutils.AssertEqual(t, string(content["Part1.pdf"]), "Part1.pdf")
+	testutils.AssertEqual(t, len(content), 2)
+
+	testutils.AssertNil(t, store.TransitionStorageClass(ctx, "org", meta.ResourceId(), StorageClassCold))
+	testutils.AssertEqual(t, client.storageClasses["caesura/org/title_composer/Part1.pdf"], types.StorageClassStandardIa)
+	stored, err = store.MetaById(ctx, "org", meta.ResourceId())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.StorageClass, StorageClassCold)
+}
+
+func TestS3StoreUsersAndGroups(t *testing.T) {
+	store, _ := newTestS3Store()
+	ctx := context.Background()
+	user := UserInfo{
+		Id:     "user-id",
+		Email:  "user@example.com",
+		Roles:  map[string]RoleKind{"org1": RoleEditor},
+		Groups: map[string][]string{"org1": {"group1"}},
+	}
+	testutils.AssertNil(t, store.RegisterUser(ctx, &user))
+	testutils.AssertNil(t, store.RegisterGroup(ctx, "user-id", "org1", "group2"))
+	testutils.AssertNil(t, store.RegisterGroup(ctx, "user-id", "org1", "group2"))
+
+	received, err := store.GetUserInfo(ctx, "user-id")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(received.Groups["org1"], ","), "group1,group2")
+	testutils.AssertEqual(t, received.Roles["org1"], RoleEditor)
+
+	testutils.AssertNil(t, store.RemoveGroup(ctx, "user-id", "org1", "group1"))
+	received, err = store.GetUserInfo(ctx, "user-id")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(received.Groups["org1"], ","), "group2")
+
+	byEmail, err := store.UserByEmail(ctx, "user@example.com")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, byEmail.Id, "user-id")
+}
+
+func TestS3StoreFeatureCounts(t *testing.T) {
+	store, _ := newTestS3Store()
+	ctx := context.Background()
+	now := time.Date(2025, 6, 11, 0, 0, 0, 0, time.UTC)
+	for range 3 {
+		testutils.AssertNil(t, store.CountFeature(ctx, "org1", FeatureUpload, now))
+	}
+
+	counts, err := store.FeatureCounts(ctx, now)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(counts), 1)
+	testutils.AssertEqual(t, counts[0].Count, 3)
+}
+
+// assertProjectNotesRoundTrip sets, replaces and removes notes of a project in the store
+func assertProjectNotesRoundTrip(t *testing.T, store *GoogleStore) {
+	t.Helper()
+	ctx := context.Background()
+	project := Project{Name: "Spring concert", ResourceIds: []string{"piece1", "piece2"}}
+	testutils.AssertNil(t, store.SubmitProject(ctx, "org", &project))
+
+	testutils.AssertNil(t, store.SetProjectNote(ctx, "org", project.Id(), "piece1", "Start slow"))
+	testutils.AssertNil(t, store.SetProjectNote(ctx, "org", project.Id(), "piece2", "Repeat twice"))
+	stored, err := store.ProjectById(ctx, "org", project.Id())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Notes["piece1"], "Start slow")
+	testutils.AssertEqual(t, stored.Notes["piece2"], "Repeat twice")
+
+	testutils.AssertNil(t, store.SetProjectNote(ctx, "org", project.Id(), "piece1", ""))
+	stored, err = store.ProjectById(ctx, "org", project.Id())
+	testutils.AssertNil(t, err)
+	_, ok := stored.Notes["piece1"]
+	testutils.AssertEqual(t, ok, false)
+	testutils.AssertEqual(t, stored.Notes["piece2"], "Repeat twice")
+
+	testutils.AssertNil(t, store.RemoveResource(ctx, "org", project.Id(), "piece2"))
+	stored, err = store.ProjectById(ctx, "org", project.Id())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(stored.Notes), 0)
+	testutils.AssertEqual(t, strings.Join(stored.ResourceIds, ","), "piece1")
+}
+
+func TestS3StoreProjectNotes(t *testing.T) {
+	store, _ := newTestS3Store()
+	assertProjectNotesRoundTrip(t, &store.GoogleStore)
+}
+
+func TestS3DocumentClientRejectsUnsupportedUpdates(t *testing.T) {
+	store, _ := newTestS3Store()
+	ctx := context.Background()
+	project := Project{Name: "Project"}
+	testutils.AssertNil(t, store.SubmitProject(ctx, "org", &project))
+
+	for _, update := range []firestore.Update{
+		{Path: "updated_at", Value: firestore.ServerTimestamp},
+		{Path: "name", FieldPath: firestore.FieldPath{"name"}, Value: "new"},
+		{Value: "new"},
+		{FieldPath: firestore.FieldPath{"name", "nested"}, Value: "new"},
+	} {
+		err := store.FsClient.Update(ctx, projectCollection, "org", project.Id(), []firestore.Update{update})
+		testutils.AssertEqual(t, err != nil, true)
+	}
+}
+
+func TestS3DocumentClient(t *testing.T) {
+	client := S3DocumentClient{Client: newFakeS3(), Bucket: "caesura"}
+	ctx := context.Background()
+	org := Organization{Id: "org", Name: "Band", Branding: Branding{PrimaryColor: "#112233"}}
+	testutils.AssertNil(t, client.StoreDocument(ctx, organizationCollection, organizationInfo, org.Id, &org))
+
+	doc, err := client.GetDoc(ctx, organizationCollection, organizationInfo, org.Id)
+	testutils.AssertNil(t, err)
+	var received Organization
+	testutils.AssertNil(t, doc.DataTo(&received))
+	testutils.AssertEqual(t, received.Name, "Band")
+	testutils.AssertEqual(t, received.Branding.PrimaryColor, "#112233")
+
+	testutils.AssertNil(t, client.DeleteDoc(ctx, organizationCollection, organizationInfo, org.Id))
+	_, err = client.GetDoc(ctx, organizationCollection, organizationInfo, org.Id)
+	testutils.AssertEqual(t, status.Code(err), codes.NotFound)
+
+	err = client.Update(ctx, organizationCollection, organizationInfo, org.Id, nil)
+	testutils.AssertEqual(t, status.Code(err), codes.NotFound)
+
+	if _, err := encodeFirestoreFields("not a struct"); err == nil {
+		t.Fatal("Wanted error when storing a non-struct document")
+	}
+	if err := doc.DataTo(received); err == nil {
+		t.Fatal("Wanted error when decoding into a non-pointer")
+	}
+}
@@ -0,0 +1,690 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const scimIdentityCollection = "scim_identities"
+
+const (
+	ScimUserSchema  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	ScimGroupSchema = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	ScimListSchema  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	ScimPatchSchema = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ScimErrorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// scimRoles are the values of the roles attribute of SCIM users
+var scimRoles = map[string]RoleKind{
+	"viewer":    RoleViewer,
+	"editor":    RoleEditor,
+	"librarian": RoleLibrarian,
+	"admin":     RoleAdmin,
+}
+
+func scimRoleName(role RoleKind) string {
+	for name, kind := range scimRoles {
+		if kind == role {
+			return name
+		}
+	}
+	return "viewer"
+}
+
+type ScimValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type ScimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type ScimMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location,omitempty"`
+}
+
+// ScimUser is a member of an organization as seen by an identity provider. The id is the id of the user, and
+// the roles and groups are those of the organization the provider provisions
+type ScimUser struct {
+	Schemas     []string    `json:"schemas"`
+	Id          string      `json:"id,omitempty"`
+	ExternalId  string      `json:"externalId,omitempty"`
+	UserName    string      `json:"userName"`
+	Name        *ScimName   `json:"name,omitempty"`
+	DisplayName string      `json:"displayName,omitempty"`
+	Emails      []ScimValue `json:"emails,omitempty"`
+	Active      *bool       `json:"active,omitempty"`
+	Roles       []ScimValue `json:"roles,omitempty"`
+	Groups      []ScimValue `json:"groups,omitempty"`
+	Meta        *ScimMeta   `json:"meta,omitempty"`
+}
+
+// NewScimUser returns the user as seen by the provider of the organization. The external id is empty for users
+// the provider did not provision
+func NewScimUser(user *UserInfo, orgId, externalId string) ScimUser {
+	_, active := user.Roles[orgId]
+	userName := user.Email
+	if userName == "" {
+		userName = user.Id
+	}
+	scimUser := ScimUser{
+		Schemas:     []string{ScimUserSchema},
+		Id:          user.Id,
+		ExternalId:  externalId,
+		UserName:    userName,
+		DisplayName: user.Name,
+		Active:      &active,
+		Groups:      []ScimValue{},
+		Meta:        &ScimMeta{ResourceType: "User"},
+	}
+	if user.Name != "" {
+		scimUser.Name = &ScimName{Formatted: user.Name}
+	}
+	if user.Email != "" {
+		scimUser.Emails = []ScimValue{{Value: user.Email, Primary: true}}
+	}
+	if active {
+		scimUser.Roles = []ScimValue{{Value: scimRoleName(user.Roles[orgId])}}
+	}
+	for _, group := range user.Groups[orgId] {
+		scimUser.Groups = append(scimUser.Groups, ScimValue{Value: group, Display: group})
+	}
+	return scimUser
+}
+
+// Email returns the primary email, or the user name when it is an email address
+func (u *ScimUser) Email() string {
+	email := u.UserName
+	for _, value := range u.Emails {
+		if value.Primary || !strings.Contains(email, "@") {
+			email = value.Value
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// FullName returns the display name, or the formatted name when there is no display name
+func (u *ScimUser) FullName() string {
+	if u.DisplayName != "" || u.Name == nil {
+		return strings.TrimSpace(u.DisplayName)
+	}
+	if u.Name.Formatted != "" {
+		return strings.TrimSpace(u.Name.Formatted)
+	}
+	return strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+}
+
+// IsActive returns true unless the provider has deactivated the user
+func (u *ScimUser) IsActive() bool {
+	return u.Active == nil || *u.Active
+}
+
+// Role returns the highest of the roles of the user. Users without roles are viewers
+func (u *ScimUser) Role() (RoleKind, error) {
+	var role RoleKind = RoleViewer
+	for _, value := range u.Roles {
+		kind, ok := scimRoles[strings.ToLower(value.Value)]
+		if !ok {
+			return role, errors.Join(ErrInvalidScimRequest, fmt.Errorf("unknown role %q", value.Value))
+		}
+		if kind.AtLeast(role) {
+			role = kind
+		}
+	}
+	return role, nil
+}
+
+type ScimGroup struct {
+	Schemas     []string    `json:"schemas"`
+	Id          string      `json:"id"`
+	DisplayName string      `json:"displayName"`
+	Members     []ScimValue `json:"members"`
+	Meta        *ScimMeta   `json:"meta,omitempty"`
+}
+
+type ScimListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    any      `json:"Resources"`
+}
+
+// NewScimListResponse returns count resources from startIndex, where the first resource has index 1
+func NewScimListResponse[T any](resources []T, startIndex, count int) ScimListResponse {
+	startIndex = max(startIndex, 1)
+	from := min(startIndex-1, len(resources))
+	to := len(resources)
+	if count >= 0 {
+		to = min(from+count, to)
+	}
+	page := resources[from:to]
+	return ScimListResponse{
+		Schemas:      []string{ScimListSchema},
+		TotalResults: len(resources),
+		StartIndex:   startIndex,
+		ItemsPerPage: len(page),
+		Resources:    page,
+	}
+}
+
+type ScimError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+func NewScimError(status int, scimType, detail string) ScimError {
+	return ScimError{Schemas: []string{ScimErrorSchema}, Status: strconv.Itoa(status), ScimType: scimType, Detail: detail}
+}
+
+type ScimPatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+}
+
+type ScimPatch struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []ScimPatchOperation `json:"Operations"`
+}
+
+// ParseScimFilter returns the attribute and value of filters on the form `attribute eq "value"`, which are the
+// filters identity providers use to look up users and groups. Other filters are not supported
+func ParseScimFilter(filter string) (string, string, error) {
+	if filter == "" {
+		return "", "", nil
+	}
+	attribute, rest, ok := strings.Cut(strings.TrimSpace(filter), " ")
+	operator, value, ok2 := strings.Cut(strings.TrimSpace(rest), " ")
+	value, err := strconv.Unquote(strings.TrimSpace(value))
+	if !ok || !ok2 || !strings.EqualFold(operator, "eq") || err != nil {
+		return "", "", errors.Join(ErrInvalidScimRequest, fmt.Errorf("unsupported filter %q", filter))
+	}
+	return attribute, value, nil
+}
+
+// MatchesScimFilter returns true when the user has the value of the attribute. User names are case insensitive
+func (u *ScimUser) MatchesScimFilter(attribute, value string) bool {
+	switch strings.ToLower(attribute) {
+	case "":
+		return true
+	case "username":
+		return strings.EqualFold(u.UserName, value)
+	case "externalid":
+		return u.ExternalId == value
+	case "id":
+		return u.Id == value
+	}
+	return false
+}
+
+// ScimIdentity records that the identity provider of an organization provisioned the user. The external id is
+// the id the provider knows the user by. It is kept per organization, since the providers of two organizations
+// may hand out the same external ids
+type ScimIdentity struct {
+	OrgId      string    `json:"orgId" firestore:"orgId"`
+	UserId     string    `json:"userId" firestore:"userId"`
+	ExternalId string    `json:"externalId" firestore:"externalId"`
+	CreatedAt  time.Time `json:"createdAt" firestore:"createdAt"`
+}
+
+type ScimIdentityStore interface {
+	SaveScimIdentity(ctx context.Context, identity *ScimIdentity) error
+
+	// ScimIdentities returns the identities of the users provisioned by the organization
+	ScimIdentities(ctx context.Context, orgId string) ([]ScimIdentity, error)
+}
+
+// ScimExternalIds returns the external ids of the users provisioned by the organization by user id
+func ScimExternalIds(ctx context.Context, store ScimIdentityStore, orgId string) (map[string]string, error) {
+	identities, err := store.ScimIdentities(ctx, orgId)
+	externalIds := make(map[string]string, len(identities))
+	for _, identity := range identities {
+		externalIds[identity.UserId] = identity.ExternalId
+	}
+	return externalIds, err
+}
+
+type ScimStore interface {
+	RoleStore
+	UserInOrgGetter
+	UserByEmailGetter
+	GroupStore
+	UserNameUpdater
+	ScimIdentityStore
+	InvitationStore
+}
+
+// ScimMember returns the user when the user is a member of the organization
+func ScimMember(ctx context.Context, store RoleGetter, orgId, userId string) (*UserInfo, error) {
+	user, err := store.GetUserInfo(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := user.Roles[orgId]; !ok {
+		return nil, errors.Join(ErrUserNotFound, fmt.Errorf("user %s is not a member of the organization", userId))
+	}
+	return user, nil
+}
+
+// ProvisionScimUser makes the user a member of the organization. Users are global, hence accounts are given an
+// id of their own and the external id is only stored for the organization. An account that already exists is
+// not added right away, unless the organization provisioned it earlier. It is sent an invitation, and joins the
+// organization when the user accepts it. Otherwise an organization could take over any account by its email
+func ProvisionScimUser(ctx context.Context, store ScimStore, orgId string, scimUser *ScimUser) (*UserInfo, *Invitation, error) {
+	email := scimUser.Email()
+	if !strings.Contains(email, "@") {
+		return nil, nil, errors.Join(ErrInvalidScimRequest, errors.New("userName or emails must hold an email address"))
+	}
+	role, err := scimUser.Role()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	externalIds, err := ScimExternalIds(ctx, store, orgId)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := scimUserByExternalId(ctx, store, externalIds, scimUser.ExternalId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		existing, err := store.UserByEmail(ctx, email)
+		switch {
+		case errors.Is(err, ErrUserNotFound):
+			user = NewUserInfo()
+			user.Id = uuid.NewString()
+			user.Email = email
+			user.Name = scimUser.FullName()
+			if err := store.RegisterUser(ctx, user); err != nil {
+				return nil, nil, err
+			}
+			externalIds[user.Id] = scimUser.ExternalId
+		case err != nil:
+			return nil, nil, err
+		default:
+			user = &existing
+		}
+	}
+
+	if _, ok := user.Roles[orgId]; ok {
+		return nil, nil, errors.Join(ErrScimUserExists, fmt.Errorf("user %s is already a member", user.Id))
+	}
+	if _, provisioned := externalIds[user.Id]; !provisioned {
+		if !scimUser.IsActive() {
+			return nil, nil, errors.Join(ErrScimUserInvited, errors.New("inactive users are not invited"))
+		}
+		invitation := NewInvitation(orgId, email, role, "", DefaultInvitationExpiry)
+		if err := store.SaveInvitation(ctx, invitation); err != nil {
+			return nil, nil, err
+		}
+		return nil, invitation, errors.Join(ErrScimUserInvited, fmt.Errorf("invitation %s", invitation.Id))
+	}
+
+	identity := ScimIdentity{OrgId: orgId, UserId: user.Id, ExternalId: scimUser.ExternalId, CreatedAt: time.Now()}
+	if err := store.SaveScimIdentity(ctx, &identity); err != nil {
+		return nil, nil, err
+	}
+	if scimUser.IsActive() {
+		if err := store.RegisterRole(ctx, user.Id, orgId, role); err != nil {
+			return nil, nil, err
+		}
+		if user.Roles == nil {
+			user.Roles = make(map[string]RoleKind)
+		}
+		user.Roles[orgId] = role
+	}
+	return user, nil, nil
+}
+
+// scimUserByExternalId returns the user the organization provisioned with the external id, or nil when there is none
+func scimUserByExternalId(ctx context.Context, store RoleGetter, externalIds map[string]string, externalId string) (*UserInfo, error) {
+	if externalId == "" {
+		return nil, nil
+	}
+	for userId, id := range externalIds {
+		if id == externalId {
+			return store.GetUserInfo(ctx, userId)
+		}
+	}
+	return nil, nil
+}
+
+// ReplaceScimUser updates the name and role of a member. Deactivated users are removed from the organization,
+// but the account is kept since the user may be a member of other organizations. The name is only updated for
+// users the organization provisioned, since accounts that joined by other means are not owned by the provider
+func ReplaceScimUser(ctx context.Context, store ScimStore, orgId string, user *UserInfo, scimUser *ScimUser) (*UserInfo, error) {
+	if !scimUser.IsActive() {
+		if err := store.DeleteRole(ctx, user.Id, orgId); err != nil {
+			return nil, err
+		}
+		delete(user.Roles, orgId)
+		return user, nil
+	}
+
+	role, err := scimUser.Role()
+	if err != nil {
+		return nil, err
+	}
+	if current, ok := user.Roles[orgId]; !ok || current != role {
+		if err := store.RegisterRole(ctx, user.Id, orgId, role); err != nil {
+			return nil, err
+		}
+		user.Roles[orgId] = role
+	}
+
+	name := scimUser.FullName()
+	if name == "" || name == user.Name {
+		return user, nil
+	}
+	externalIds, err := ScimExternalIds(ctx, store, orgId)
+	if err != nil {
+		return nil, err
+	}
+	if _, provisioned := externalIds[user.Id]; provisioned {
+		if err := store.UpdateUserName(ctx, user.Id, name); err != nil {
+			return nil, err
+		}
+		user.Name = name
+	}
+	return user, nil
+}
+
+func scimBool(value any) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		// Some providers send booleans as strings, e.g. "False"
+		return strconv.ParseBool(strings.ToLower(v))
+	}
+	return false, errors.Join(ErrInvalidScimRequest, fmt.Errorf("%v is not a boolean", value))
+}
+
+func scimString(value any) (string, error) {
+	if v, ok := value.(string); ok {
+		return v, nil
+	}
+	return "", errors.Join(ErrInvalidScimRequest, fmt.Errorf("%v is not a string", value))
+}
+
+// scimValues returns the values of a multi-valued attribute, given either as a list of objects with a value or
+// as a single object
+func scimValues(value any) ([]ScimValue, error) {
+	items, ok := value.([]any)
+	if !ok {
+		items = []any{value}
+	}
+	values := make([]ScimValue, 0, len(items))
+	for _, item := range items {
+		object, ok := item.(map[string]any)
+		if !ok {
+			return nil, errors.Join(ErrInvalidScimRequest, fmt.Errorf("%v is not an object with a value", item))
+		}
+		value, err := scimString(object["value"])
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, ScimValue{Value: value})
+	}
+	return values, nil
+}
+
+// applyUserAttribute sets one of the attributes a provider can change on a user
+func (u *ScimUser) applyUserAttribute(path string, value any) error {
+	var err error
+	switch strings.ToLower(path) {
+	case "active":
+		var active bool
+		active, err = scimBool(value)
+		u.Active = &active
+	case "displayname":
+		u.DisplayName, err = scimString(value)
+	case "name.formatted":
+		u.Name = &ScimName{}
+		u.DisplayName = ""
+		u.Name.Formatted, err = scimString(value)
+	case "roles":
+		u.Roles, err = scimValues(value)
+	}
+
+	// Attributes that can not be changed, such as the email, are ignored
+	return err
+}
+
+// ApplyScimPatch applies the operations to the user. Operations without a path hold the attributes to replace
+func (u *ScimUser) ApplyScimPatch(patch *ScimPatch) error {
+	for _, operation := range patch.Operations {
+		switch strings.ToLower(operation.Op) {
+		case "add", "replace":
+		case "remove":
+			if strings.EqualFold(operation.Path, "roles") {
+				u.Roles = nil
+			}
+			continue
+		default:
+			return errors.Join(ErrInvalidScimRequest, fmt.Errorf("unknown operation %q", operation.Op))
+		}
+
+		if operation.Path != "" {
+			if err := u.applyUserAttribute(operation.Path, operation.Value); err != nil {
+				return err
+			}
+			continue
+		}
+		attributes, ok := operation.Value.(map[string]any)
+		if !ok {
+			return errors.Join(ErrInvalidScimRequest, errors.New("operations without path must have an object as value"))
+		}
+		for path, value := range attributes {
+			if err := u.applyUserAttribute(path, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ScimGroups returns the groups of the members of the organization. Groups only exist as long as they have
+// members
+func ScimGroups(ctx context.Context, store UserInOrgGetter, orgId string) ([]ScimGroup, error) {
+	users, err := store.GetUsersInOrg(ctx, orgId)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make(map[string][]ScimValue)
+	for _, user := range users {
+		for _, group := range user.Groups[orgId] {
+			if !slices.ContainsFunc(members[group], func(v ScimValue) bool { return v.Value == user.Id }) {
+				members[group] = append(members[group], ScimValue{Value: user.Id, Display: user.Name})
+			}
+		}
+	}
+
+	groups := make([]ScimGroup, 0, len(members))
+	for name, values := range members {
+		groups = append(groups, NewScimGroup(name, values))
+	}
+	slices.SortFunc(groups, func(a, b ScimGroup) int { return strings.Compare(a.Id, b.Id) })
+	return groups, nil
+}
+
+// NewScimGroup returns a group. The name of a group is also its id
+func NewScimGroup(name string, members []ScimValue) ScimGroup {
+	if members == nil {
+		members = []ScimValue{}
+	}
+	return ScimGroup{
+		Schemas:     []string{ScimGroupSchema},
+		Id:          name,
+		DisplayName: name,
+		Members:     members,
+		Meta:        &ScimMeta{ResourceType: "Group"},
+	}
+}
+
+// ScimGroupByName returns the group with the name. Groups without members are not found
+func ScimGroupByName(ctx context.Context, store UserInOrgGetter, orgId, name string) (*ScimGroup, error) {
+	groups, err := ScimGroups(ctx, store, orgId)
+	if err != nil {
+		return nil, err
+	}
+	idx := slices.IndexFunc(groups, func(g ScimGroup) bool { return g.Id == name })
+	if idx < 0 {
+		return nil, errors.Join(ErrScimGroupNotFound, fmt.Errorf("group %q", name))
+	}
+	return &groups[idx], nil
+}
+
+// SetScimGroupMembers adds the group to the members and removes it from the other members of the organization
+func SetScimGroupMembers(ctx context.Context, store ScimStore, orgId, name string, memberIds []string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.Join(ErrInvalidScimRequest, errors.New("groups must have a name"))
+	}
+	users, err := store.GetUsersInOrg(ctx, orgId)
+	if err != nil {
+		return err
+	}
+	for _, id := range memberIds {
+		if !slices.ContainsFunc(users, func(u UserInfo) bool { return u.Id == id }) {
+			return errors.Join(ErrInvalidScimRequest, fmt.Errorf("user %s is not a member of the organization", id))
+		}
+	}
+
+	for _, user := range users {
+		inGroup := slices.Contains(user.Groups[orgId], name)
+		shouldBe := slices.Contains(memberIds, user.Id)
+		switch {
+		case shouldBe && !inGroup:
+			err = store.RegisterGroup(ctx, user.Id, orgId, name)
+		case !shouldBe && inGroup:
+			err = store.RemoveGroup(ctx, user.Id, orgId, name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// memberIds returns the member ids of the group
+func (g *ScimGroup) memberIds() []string {
+	ids := make([]string, 0, len(g.Members))
+	for _, member := range g.Members {
+		ids = append(ids, member.Value)
+	}
+	return ids
+}
+
+// ApplyScimPatch applies the operations to the members and the name of the group. Members are removed either by
+// a path on the form `members[value eq "id"]` or by the values of the operation
+func (g *ScimGroup) ApplyScimPatch(patch *ScimPatch) error {
+	ids := g.memberIds()
+	for _, operation := range patch.Operations {
+		path := strings.ToLower(operation.Path)
+		op := strings.ToLower(operation.Op)
+		switch {
+		case op == "remove" && strings.HasPrefix(path, "members["):
+			_, value, err := ParseScimFilter(strings.TrimSuffix(operation.Path[len("members["):], "]"))
+			if err != nil {
+				return err
+			}
+			ids = slices.DeleteFunc(ids, func(id string) bool { return id == value })
+		case path == "members":
+			var values []ScimValue
+			if operation.Value != nil {
+				var err error
+				if values, err = scimValues(operation.Value); err != nil {
+					return err
+				}
+			}
+			switch op {
+			case "add":
+				for _, value := range values {
+					if !slices.Contains(ids, value.Value) {
+						ids = append(ids, value.Value)
+					}
+				}
+			case "remove":
+				if operation.Value == nil {
+					ids = []string{}
+				}
+				for _, value := range values {
+					ids = slices.DeleteFunc(ids, func(id string) bool { return id == value.Value })
+				}
+			case "replace":
+				ids = []string{}
+				for _, value := range values {
+					ids = append(ids, value.Value)
+				}
+			default:
+				return errors.Join(ErrInvalidScimRequest, fmt.Errorf("unknown operation %q", operation.Op))
+			}
+		case (op == "replace" || op == "add") && path == "displayname":
+			name, err := scimString(operation.Value)
+			if err != nil {
+				return err
+			}
+			g.DisplayName = name
+		case op == "replace" && path == "":
+			attributes, ok := operation.Value.(map[string]any)
+			if !ok {
+				return errors.Join(ErrInvalidScimRequest, errors.New("operations without path must have an object as value"))
+			}
+			if name, ok := attributes["displayName"]; ok {
+				var err error
+				if g.DisplayName, err = scimString(name); err != nil {
+					return err
+				}
+			}
+		default:
+			return errors.Join(ErrInvalidScimRequest, fmt.Errorf("unsupported operation %q on %q", operation.Op, operation.Path))
+		}
+	}
+
+	g.Members = make([]ScimValue, 0, len(ids))
+	for _, id := range ids {
+		g.Members = append(g.Members, ScimValue{Value: id})
+	}
+	return nil
+}
+
+// SaveScimGroup stores the members of the group. A renamed group is removed from all members under the old name
+func SaveScimGroup(ctx context.Context, store ScimStore, orgId, previousName string, group *ScimGroup) error {
+	if previousName != "" && previousName != group.DisplayName {
+		if err := SetScimGroupMembers(ctx, store, orgId, previousName, []string{}); err != nil {
+			return err
+		}
+	}
+	return SetScimGroupMembers(ctx, store, orgId, group.DisplayName, group.memberIds())
+}
+
+func (g *GoogleStore) SaveScimIdentity(ctx context.Context, identity *ScimIdentity) error {
+	return g.FsClient.StoreDocument(ctx, scimIdentityCollection, identity.OrgId, identity.UserId, identity)
+}
+
+func (g *GoogleStore) ScimIdentities(ctx context.Context, orgId string) ([]ScimIdentity, error) {
+	collector := NewValidCollector[ScimIdentity]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, scimIdentityCollection, orgId, "userId", "") {
+		collector.Push(doc)
+	}
+	return collector.Items, collector.Err
+}
@@ -0,0 +1,281 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func scimTestStore(t *testing.T) *MultiOrgInMemoryStore {
+	store := NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &Organization{Id: "org1", Name: "Band"}))
+	testutils.AssertNil(t, store.RegisterUser(ctx, &UserInfo{Id: "admin", Email: "admin@example.com", Name: "Admin"}))
+	testutils.AssertNil(t, store.RegisterRole(ctx, "admin", "org1", RoleAdmin))
+	testutils.AssertNil(t, store.RegisterUser(ctx, &UserInfo{Id: "kari", Email: "kari@example.com", Name: "Kari", Password: "hash"}))
+	return store
+}
+
+func mustDecodeScim[T any](t *testing.T, body string) *T {
+	var v T
+	testutils.AssertNil(t, json.Unmarshal([]byte(body), &v))
+	return &v
+}
+
+func TestScimUserRole(t *testing.T) {
+	user := ScimUser{Roles: []ScimValue{{Value: "Editor"}, {Value: "librarian"}}}
+	role, err := user.Role()
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, role, RoleKind(RoleLibrarian))
+
+	role, err = (&ScimUser{}).Role()
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, role, RoleKind(RoleViewer))
+
+	_, err = (&ScimUser{Roles: []ScimValue{{Value: "owner"}}}).Role()
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidScimRequest), true)
+}
+
+func TestNewScimUser(t *testing.T) {
+	user := UserInfo{
+		Id:     "kari",
+		Email:  "kari@example.com",
+		Name:   "Kari",
+		Roles:  map[string]RoleKind{"org1": RoleEditor},
+		Groups: map[string][]string{"org1": {"Horn"}},
+	}
+	scimUser := NewScimUser(&user, "org1", "kari-azure")
+	testutils.AssertEqual(t, scimUser.UserName, "kari@example.com")
+	testutils.AssertEqual(t, scimUser.ExternalId, "kari-azure")
+	testutils.AssertEqual(t, scimUser.MatchesScimFilter("externalId", "kari-azure"), true)
+	testutils.AssertEqual(t, scimUser.MatchesScimFilter("externalId", "kari"), false)
+	testutils.AssertEqual(t, *scimUser.Active, true)
+	testutils.AssertEqual(t, scimUser.Roles[0].Value, "editor")
+	testutils.AssertEqual(t, scimUser.Groups[0].Value, "Horn")
+
+	other := NewScimUser(&user, "org2", "")
+	testutils.AssertEqual(t, *other.Active, false)
+	testutils.AssertEqual(t, len(other.Roles), 0)
+}
+
+func TestParseScimFilter(t *testing.T) {
+	attribute, value, err := ParseScimFilter(`userName eq "kari@example.com"`)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, attribute, "userName")
+	testutils.AssertEqual(t, value, "kari@example.com")
+
+	for _, filter := range []string{`userName sw "kari"`, `userName eq kari`, "userName"} {
+		t.Run(filter, func(t *testing.T) {
+			_, _, err := ParseScimFilter(filter)
+			testutils.AssertEqual(t, errors.Is(err, ErrInvalidScimRequest), true)
+		})
+	}
+}
+
+func TestNewScimListResponse(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+	response := NewScimListResponse(items, 2, 2)
+	testutils.AssertEqual(t, response.TotalResults, 5)
+	testutils.AssertEqual(t, response.ItemsPerPage, 2)
+	testutils.AssertEqual(t, slices.Equal(response.Resources.([]int), []int{2, 3}), true)
+
+	testutils.AssertEqual(t, NewScimListResponse(items, 0, -1).ItemsPerPage, 5)
+	testutils.AssertEqual(t, NewScimListResponse(items, 10, 2).ItemsPerPage, 0)
+}
+
+func TestProvisionScimUser(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("new user", func(t *testing.T) {
+		store := scimTestStore(t)
+		scimUser := mustDecodeScim[ScimUser](t, `{
+			"userName": "Ola@Example.com",
+			"externalId": "admin",
+			"name": {"givenName": "Ola", "familyName": "Nordmann"},
+			"roles": [{"value": "editor"}]
+		}`)
+		user, invitation, err := ProvisionScimUser(ctx, store, "org1", scimUser)
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, invitation == nil, true)
+
+		// The external id is chosen by the provider, hence it is never used as the id of the account
+		testutils.AssertEqual(t, user.Id != "admin", true)
+		stored, err := store.GetUserInfo(ctx, user.Id)
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, stored.Email, "ola@example.com")
+		testutils.AssertEqual(t, stored.Name, "Ola Nordmann")
+		testutils.AssertEqual(t, stored.Roles["org1"], RoleEditor)
+
+		externalIds, err := ScimExternalIds(ctx, store, "org1")
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, externalIds[user.Id], "admin")
+
+		admin, err := store.GetUserInfo(ctx, "admin")
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, admin.Email, "admin@example.com")
+
+		_, _, err = ProvisionScimUser(ctx, store, "org1", scimUser)
+		testutils.AssertEqual(t, errors.Is(err, ErrScimUserExists), true)
+	})
+
+	t.Run("existing user is invited", func(t *testing.T) {
+		store := scimTestStore(t)
+		scimUser := &ScimUser{UserName: "kari", Emails: []ScimValue{{Value: "kari@example.com", Primary: true}}, Roles: []ScimValue{{Value: "editor"}}}
+		user, invitation, err := ProvisionScimUser(ctx, store, "org1", scimUser)
+		testutils.AssertEqual(t, errors.Is(err, ErrScimUserInvited), true)
+		testutils.AssertEqual(t, user == nil, true)
+		testutils.AssertEqual(t, invitation.Email, "kari@example.com")
+		testutils.AssertEqual(t, invitation.Role, RoleKind(RoleEditor))
+
+		stored, err := store.Invitation(ctx, "org1", invitation.Id)
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, stored.Status, InvitationPending)
+
+		kari, err := store.GetUserInfo(ctx, "kari")
+		testutils.AssertNil(t, err)
+		_, member := kari.Roles["org1"]
+		testutils.AssertEqual(t, member, false)
+	})
+
+	t.Run("deactivated user is activated again", func(t *testing.T) {
+		store := scimTestStore(t)
+		scimUser := &ScimUser{UserName: "ola@example.com", ExternalId: "ola-azure"}
+		user, _, err := ProvisionScimUser(ctx, store, "org1", scimUser)
+		testutils.AssertNil(t, err)
+		testutils.AssertNil(t, store.DeleteRole(ctx, user.Id, "org1"))
+
+		again, invitation, err := ProvisionScimUser(ctx, store, "org1", scimUser)
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, invitation == nil, true)
+		testutils.AssertEqual(t, again.Id, user.Id)
+		testutils.AssertEqual(t, again.Roles["org1"], RoleKind(RoleViewer))
+	})
+
+	t.Run("without email", func(t *testing.T) {
+		_, _, err := ProvisionScimUser(ctx, scimTestStore(t), "org1", &ScimUser{UserName: "ola"})
+		testutils.AssertEqual(t, errors.Is(err, ErrInvalidScimRequest), true)
+	})
+}
+
+func TestPatchScimUser(t *testing.T) {
+	ctx := context.Background()
+	store := scimTestStore(t)
+	testutils.AssertNil(t, store.RegisterRole(ctx, "kari", "org1", RoleViewer))
+	testutils.AssertNil(t, store.SaveScimIdentity(ctx, &ScimIdentity{OrgId: "org1", UserId: "kari", ExternalId: "kari-azure"}))
+	user, err := ScimMember(ctx, store, "org1", "kari")
+	testutils.AssertNil(t, err)
+
+	// Azure AD sends operations without path and booleans as strings
+	patch := mustDecodeScim[ScimPatch](t, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "path": "roles", "value": [{"value": "librarian"}]},
+			{"op": "replace", "value": {"displayName": "Kari Nordmann"}}
+		]
+	}`)
+	scimUser := NewScimUser(user, "org1", "kari-azure")
+	testutils.AssertNil(t, scimUser.ApplyScimPatch(patch))
+	user, err = ReplaceScimUser(ctx, store, "org1", user, &scimUser)
+	testutils.AssertNil(t, err)
+
+	stored, err := store.GetUserInfo(ctx, "kari")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Name, "Kari Nordmann")
+	testutils.AssertEqual(t, stored.Roles["org1"], RoleKind(RoleLibrarian))
+
+	deactivate := mustDecodeScim[ScimPatch](t, `{"Operations": [{"op": "Replace", "path": "active", "value": "False"}]}`)
+	scimUser = NewScimUser(user, "org1", "kari-azure")
+	testutils.AssertNil(t, scimUser.ApplyScimPatch(deactivate))
+	_, err = ReplaceScimUser(ctx, store, "org1", user, &scimUser)
+	testutils.AssertNil(t, err)
+
+	_, err = ScimMember(ctx, store, "org1", "kari")
+	testutils.AssertEqual(t, errors.Is(err, ErrUserNotFound), true)
+
+	// The account is kept, since the user may be a member of other organizations
+	_, err = store.GetUserInfo(ctx, "kari")
+	testutils.AssertNil(t, err)
+}
+
+func TestReplaceScimUserKeepsNameOfOtherAccounts(t *testing.T) {
+	ctx := context.Background()
+	store := scimTestStore(t)
+	testutils.AssertNil(t, store.RegisterRole(ctx, "kari", "org1", RoleViewer))
+	user, err := ScimMember(ctx, store, "org1", "kari")
+	testutils.AssertNil(t, err)
+
+	// Kari joined without being provisioned, hence the provider may change the role but not the account
+	scimUser := &ScimUser{UserName: "kari@example.com", DisplayName: "Someone Else", Roles: []ScimValue{{Value: "editor"}}}
+	_, err = ReplaceScimUser(ctx, store, "org1", user, scimUser)
+	testutils.AssertNil(t, err)
+
+	stored, err := store.GetUserInfo(ctx, "kari")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Name, "Kari")
+	testutils.AssertEqual(t, stored.Roles["org1"], RoleKind(RoleEditor))
+}
+
+func assertScimIdentityStore(t *testing.T, store ScimIdentityStore) {
+	ctx := context.Background()
+	createdAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	testutils.AssertNil(t, store.SaveScimIdentity(ctx, &ScimIdentity{OrgId: "org", UserId: "kari", ExternalId: "1", CreatedAt: createdAt}))
+	testutils.AssertNil(t, store.SaveScimIdentity(ctx, &ScimIdentity{OrgId: "org", UserId: "kari", ExternalId: "2", CreatedAt: createdAt}))
+	testutils.AssertNil(t, store.SaveScimIdentity(ctx, &ScimIdentity{OrgId: "other-org", UserId: "ola", ExternalId: "1", CreatedAt: createdAt}))
+
+	identities, err := store.ScimIdentities(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(identities), 1)
+	testutils.AssertEqual(t, identities[0].UserId, "kari")
+	testutils.AssertEqual(t, identities[0].ExternalId, "2")
+
+	identities, err = store.ScimIdentities(ctx, "unknown-org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(identities), 0)
+}
+
+func TestInMemoryScimIdentities(t *testing.T) {
+	assertScimIdentityStore(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGoogleScimIdentities(t *testing.T) {
+	assertScimIdentityStore(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
+
+func TestScimGroups(t *testing.T) {
+	ctx := context.Background()
+	store := scimTestStore(t)
+	testutils.AssertNil(t, store.RegisterRole(ctx, "kari", "org1", RoleViewer))
+
+	group := NewScimGroup("Horn", []ScimValue{{Value: "kari"}, {Value: "admin"}})
+	testutils.AssertNil(t, SaveScimGroup(ctx, store, "org1", "", &group))
+	stored, err := ScimGroupByName(ctx, store, "org1", "Horn")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(stored.Members), 2)
+
+	patch := mustDecodeScim[ScimPatch](t, `{
+		"Operations": [
+			{"op": "Remove", "path": "members[value eq \"admin\"]"},
+			{"op": "Replace", "path": "displayName", "value": "Brass"}
+		]
+	}`)
+	testutils.AssertNil(t, stored.ApplyScimPatch(patch))
+	testutils.AssertNil(t, SaveScimGroup(ctx, store, "org1", "Horn", stored))
+
+	groups, err := ScimGroups(ctx, store, "org1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(groups), 1)
+	testutils.AssertEqual(t, groups[0].Id, "Brass")
+	testutils.AssertEqual(t, groups[0].Members[0].Value, "kari")
+
+	_, err = ScimGroupByName(ctx, store, "org1", "Horn")
+	testutils.AssertEqual(t, errors.Is(err, ErrScimGroupNotFound), true)
+
+	invalid := NewScimGroup("Brass", []ScimValue{{Value: "ola"}})
+	err = SaveScimGroup(ctx, store, "org1", "Brass", &invalid)
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidScimRequest), true)
+}
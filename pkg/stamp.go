@@ -0,0 +1,46 @@
+package pkg
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// The header is placed in the top margin, above the music, in a small font
+const stampDescription = "font:Helvetica, points:8, pos:tc, off:0 -10, scale:1 abs, rot:0, fillc:#000000, opacity:1"
+
+// StampPdf writes pdf to w with text stamped at the top of each page
+func StampPdf(w io.Writer, pdf io.ReadSeeker, text string) error {
+	watermark, err := api.TextWatermark(text, stampDescription, true, false, types.POINTS)
+	if err != nil {
+		return err
+	}
+	return api.AddWatermarks(pdf, w, nil, watermark, model.NewDefaultConfiguration())
+}
+
+// copyStamped copies a part to w with text stamped on each page. Parts that can not be stamped, such as parts
+// that are not pdf files, are copied as they are, since a missing header should not stop the download
+func copyStamped(w io.Writer, name string, content io.Reader, text string) error {
+	if !strings.HasSuffix(strings.ToLower(name), ".pdf") {
+		_, err := io.Copy(w, content)
+		return err
+	}
+
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	var stamped bytes.Buffer
+	if err := StampPdf(&stamped, bytes.NewReader(data), text); err != nil {
+		slog.Warn("Could not stamp part, the part is added without header", "error", err, "name", name)
+		_, err = w.Write(data)
+		return err
+	}
+	_, err = stamped.WriteTo(w)
+	return err
+}
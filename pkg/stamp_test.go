@@ -0,0 +1,79 @@
+package pkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+func TestStampPdf(t *testing.T) {
+	var pdf bytes.Buffer
+	testutils.AssertNil(t, CreateNPagePdf(&pdf, 2))
+
+	var stamped bytes.Buffer
+	testutils.AssertNil(t, StampPdf(&stamped, bytes.NewReader(pdf.Bytes()), "Spring concert - 2026-03-14"))
+
+	hasStamp, err := api.HasWatermarks(bytes.NewReader(stamped.Bytes()), model.NewDefaultConfiguration())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, hasStamp, true)
+
+	numPages, err := api.PageCount(bytes.NewReader(stamped.Bytes()), model.NewDefaultConfiguration())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, numPages, 2)
+}
+
+func TestCopyStampedKeepsPartsThatCanNotBeStamped(t *testing.T) {
+	for _, name := range []string{"notes.txt", "Broken.pdf"} {
+		var buf bytes.Buffer
+		testutils.AssertNil(t, copyStamped(&buf, name, bytes.NewBufferString("not a pdf"), "Concert"))
+		testutils.AssertEqual(t, buf.String(), "not a pdf")
+	}
+}
+
+func TestAddToZipStampsParts(t *testing.T) {
+	store := NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	ctx := context.Background()
+	resourceId := store.Data[orgId].Metadata[0].ResourceId()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	downloader := NewResourceDownloader().GetMetaData(ctx, store, orgId, resourceId).GetResource(ctx, store, orgId).Stamp("Concert")
+	testutils.AssertNil(t, downloader.AddToZip(zw, "", IncludeAll).Error)
+	testutils.AssertNil(t, zw.Close())
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	testutils.AssertNil(t, err)
+	file, err := archive.File[0].Open()
+	testutils.AssertNil(t, err)
+	content, err := io.ReadAll(file)
+	testutils.AssertNil(t, err)
+
+	hasStamp, err := api.HasWatermarks(bytes.NewReader(content), model.NewDefaultConfiguration())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, hasStamp, true)
+}
+
+func TestExtractSingleFileStampsPart(t *testing.T) {
+	store := NewDemoStore()
+	orgId := store.FirstOrganizationId()
+	ctx := context.Background()
+	resourceId := store.Data[orgId].Metadata[0].ResourceId()
+
+	downloader := NewResourceDownloader().GetMetaData(ctx, store, orgId, resourceId).GetResource(ctx, store, orgId)
+	filename := downloader.Filenames()[0]
+
+	var buf bytes.Buffer
+	downloader = NewResourceDownloader().GetMetaData(ctx, store, orgId, resourceId).GetResource(ctx, store, orgId).Stamp("Jury copy")
+	testutils.AssertNil(t, downloader.ExtractSingleFile(filename, &buf).Error)
+
+	hasStamp, err := api.HasWatermarks(bytes.NewReader(buf.Bytes()), model.NewDefaultConfiguration())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, hasStamp, true)
+}
@@ -19,6 +19,9 @@ type StoreStatus string
 const (
 	StoreStatusPending  StoreStatus = "pending"
 	StoreStatusFinished StoreStatus = "finished"
+
+	// The upload of the parts failed and the parts that were uploaded have been removed
+	StoreStatusFailed StoreStatus = "failed"
 )
 
 type Submitter interface {
@@ -50,19 +53,27 @@ func (d Duration) String() string {
 }
 
 type MetaData struct {
-	Title           string      `json:"title" firestore:"title"`
-	Composer        string      `json:"composer" firestore:"composer"`
-	Arranger        string      `json:"arranger" firestore:"arranger"`
-	Genre           string      `json:"genre" firestore:"genre"`
-	Year            string      `json:"year" firestore:"year"`
-	Instrumentation string      `json:"instrumentation" firestore:"instrumentation"`
-	Duration        Duration    `json:"duration" firestore:"duration"`
-	Publisher       string      `json:"publisher" firestore:"publisher"`
-	Ismn            string      `json:"ismn" firestore:"ismn"`
-	Tags            string      `json:"tags" firestore:"tags"`
-	Notes           string      `json:"notes" firestore:"notes"`
-	Status          StoreStatus `json:"status" firestore:"status"`
-	Deleted         bool        `json:"deleted" firestore:"deleted"`
+	Title           string       `json:"title" firestore:"title"`
+	Composer        string       `json:"composer" firestore:"composer"`
+	Arranger        string       `json:"arranger" firestore:"arranger"`
+	Genre           string       `json:"genre" firestore:"genre"`
+	Year            string       `json:"year" firestore:"year"`
+	Instrumentation string       `json:"instrumentation" firestore:"instrumentation"`
+	Duration        Duration     `json:"duration" firestore:"duration"`
+	Publisher       string       `json:"publisher" firestore:"publisher"`
+	Ismn            string       `json:"ismn" firestore:"ismn"`
+	Tags            string       `json:"tags" firestore:"tags"`
+	Notes           string       `json:"notes" firestore:"notes"`
+	Status          StoreStatus  `json:"status" firestore:"status"`
+	Deleted         bool         `json:"deleted" firestore:"deleted"`
+	DeletedAt       time.Time    `json:"deleted_at" firestore:"deleted_at"`
+	Protected       bool         `json:"protected" firestore:"protected"`
+	StorageClass    StorageClass `json:"storage_class" firestore:"storage_class"`
+	LastAccessed    time.Time    `json:"last_accessed" firestore:"last_accessed"`
+	SubmittedAt     time.Time    `json:"submitted_at" firestore:"submitted_at"`
+
+	// ContentHash changes whenever the parts of the resource change, and is used for conditional downloads
+	ContentHash string `json:"content_hash" firestore:"content_hash"`
 }
 
 func (m *MetaData) ResourceId() string {
@@ -192,6 +203,29 @@ func NewFSStore(directory string) *FSStore {
 type Store interface {
 	BlobStore
 	IAMStore
+	UsersInOrgPager
 	EmailDataCollector
 	BasicAuthRoleStore
+	FeatureMetricsStore
+	ActivityStore
+	AuditLogStore
+	AnnouncementStore
+	ProjectTemplateStore
+	ProblemReportStore
+	PasskeyStore
+	ApiTokenStore
+	DashboardStore
+	SessionRegistry
+	HintStore
+	AccountEraser
+	ArchiveStore
+	TempArtifactStore
+	InvitationStore
+	InviteLinkStore
+	GuestGrantStore
+	WebhookStore
+	ScimIdentityStore
+	CorrectionStore
+	UserNameUpdater
+	Transactor
 }
@@ -0,0 +1,205 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"log/slog"
+	"path"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/storage"
+)
+
+// uploadParts uploads the parts of a submit concurrently. If any upload fails, the files of the resource are
+// rolled back to how they were before the submit. previous are the parts of the resource before the submit,
+// which have been archived as the latest version
+func (g *GoogleStore) uploadParts(ctx context.Context, orgId, resourceId string, pdfIter iter.Seq2[string, []byte], previous []*storage.ObjectAttrs) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		errs     []error
+		uploaded []string
+	)
+	attempted := make(map[string]bool)
+	reporter := submitReporterFrom(ctx)
+	for name, data := range pdfIter {
+		attempted[name] = true
+		wg.Add(1)
+		go func(file string, d []byte) {
+			defer wg.Done()
+			err := g.BucketClient.Upload(ctx, g.Config.Bucket, g.objectName(orgId, resourceId, file), d)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				uploaded = append(uploaded, file)
+				reporter.Uploaded(file)
+			}
+		}(name, data)
+	}
+	wg.Wait()
+
+	if len(errs) == 0 {
+		return nil
+	}
+	err := fmt.Errorf("Received %d errors. First error %w", len(errs), errs[0])
+	if rollbackErr := g.rollbackParts(context.WithoutCancel(ctx), orgId, resourceId, attempted, uploaded, previous); rollbackErr != nil {
+		slog.ErrorContext(ctx, "Could not roll back failed upload", "error", rollbackErr, "resourceId", resourceId)
+		return errors.Join(err, fmt.Errorf("could not roll back the upload: %w", rollbackErr))
+	}
+	return err
+}
+
+// rollbackParts removes the parts that were added by a failed submit, and restores the parts that were
+// replaced from the version archived before the upload started. The archived version is removed once the
+// parts are restored, since it is identical to the current parts
+func (g *GoogleStore) rollbackParts(ctx context.Context, orgId, resourceId string, attempted map[string]bool, uploaded []string, previous []*storage.ObjectAttrs) error {
+	replaced := make(map[string]bool)
+	for _, attrs := range previous {
+		if name := path.Base(attrs.Name); attempted[name] {
+			replaced[name] = true
+		}
+	}
+
+	var errs []error
+	for _, name := range uploaded {
+		if replaced[name] {
+			continue
+		}
+		err := g.BucketClient.Delete(ctx, g.Config.Bucket, g.objectName(orgId, resourceId, name))
+		if err != nil && !IsNotFound(classifyStoreErr(err, ErrResourceNotFound)) {
+			errs = append(errs, err)
+		}
+	}
+	if len(previous) == 0 {
+		return errors.Join(errs...)
+	}
+
+	versions, err := g.ResourceVersions(ctx, orgId, resourceId)
+	if err != nil || len(versions) == 0 {
+		return errors.Join(append(errs, fmt.Errorf("no archived version to restore from: %w", err))...)
+	}
+	latest := versions[len(versions)-1].Version
+	for name, content := range g.ResourceVersion(ctx, orgId, resourceId, latest) {
+		if !replaced[name] {
+			continue
+		}
+		delete(replaced, name)
+		if err := g.BucketClient.Upload(ctx, g.Config.Bucket, g.objectName(orgId, resourceId, name), content); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for name := range replaced {
+		errs = append(errs, fmt.Errorf("could not restore %s", name))
+	}
+	if len(errs) > 0 {
+		// The archived version is kept such that the parts can be restored manually
+		return errors.Join(errs...)
+	}
+
+	archived, err := g.listObjects(ctx, g.versionPrefix(orgId, resourceId, latest))
+	if err != nil {
+		return err
+	}
+	return g.deleteObjects(ctx, archived)
+}
+
+// resolveAction decides what to do with a resource left by an interrupted submit
+func resolveAction(meta *MetaData, hasParts bool) StoreStatus {
+	if meta.Status == StoreStatusFailed || !hasParts {
+		return StoreStatusFailed
+	}
+	return StoreStatusFinished
+}
+
+func (g *GoogleStore) ResolveSubmit(ctx context.Context, orgId, resourceId string) error {
+	meta, err := g.MetaById(ctx, orgId, resourceId)
+	if err != nil {
+		return err
+	}
+	parts, err := g.listObjects(ctx, path.Join(orgId, resourceId)+"/")
+	if err != nil {
+		return err
+	}
+
+	if resolveAction(meta, len(parts) > 0) == StoreStatusFinished {
+		err := g.FsClient.Update(
+			ctx,
+			metaDataCollection,
+			orgId,
+			resourceId,
+			[]firestore.Update{{Path: "status", Value: StoreStatusFinished}},
+		)
+		return classifyStoreErr(err, ErrResourceMetadataNotFound)
+	}
+	if err := g.deleteFiles(ctx, orgId, resourceId); err != nil {
+		return err
+	}
+	return g.FsClient.DeleteDoc(ctx, metaDataCollection, orgId, resourceId)
+}
+
+type PendingSubmitStore interface {
+	OrganizationLister
+	MetaByPatternFetcher
+	SubmitResolver
+}
+
+// PendingSubmitReconciler resolves resources that are left pending or failed by submits that were
+// interrupted, for example by a restart of the server during an upload
+type PendingSubmitReconciler struct {
+	Store PendingSubmitStore
+
+	// Resources are only considered interrupted when they have been pending for longer than StaleAfter,
+	// such that uploads in progress are left alone
+	StaleAfter time.Duration
+	Now        func() time.Time
+}
+
+// Run resolves all stale submits and returns the number of resolved resources
+func (p *PendingSubmitReconciler) Run(ctx context.Context) (int, error) {
+	orgs, err := p.Store.ListOrganizations(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	now := p.Now()
+	numResolved := 0
+	for _, org := range orgs {
+		metas, metaErr := p.Store.MetaByPattern(ctx, org.Id, &MetaData{})
+		if metaErr != nil {
+			err = errors.Join(err, metaErr)
+			continue
+		}
+
+		for _, meta := range metas {
+			// Resources submitted before the submit time was recorded have a zero time and are always stale
+			stalePending := meta.Status == StoreStatusPending && now.Sub(meta.SubmittedAt) >= p.StaleAfter
+			if meta.Status != StoreStatusFailed && !stalePending {
+				continue
+			}
+
+			resourceId := meta.ResourceId()
+			if resolveErr := p.Store.ResolveSubmit(ctx, org.Id, resourceId); resolveErr != nil {
+				err = errors.Join(err, resolveErr)
+				continue
+			}
+			slog.InfoContext(ctx, "Resolved interrupted submit", "orgId", org.Id, "resourceId", resourceId, "status", meta.Status)
+			numResolved++
+		}
+	}
+	return numResolved, err
+}
+
+func NewPendingSubmitReconciler(store PendingSubmitStore, staleAfter time.Duration) *PendingSubmitReconciler {
+	return &PendingSubmitReconciler{
+		Store:      store,
+		StaleAfter: staleAfter,
+		Now:        time.Now,
+	}
+}
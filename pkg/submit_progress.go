@@ -0,0 +1,215 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SubmitStatus string
+
+const (
+	SubmitWaiting  SubmitStatus = "waiting"
+	SubmitRunning  SubmitStatus = "running"
+	SubmitFinished SubmitStatus = "finished"
+	SubmitFailed   SubmitStatus = "failed"
+)
+
+// SubmitProgress is the progress of splitting a score into parts and uploading them. Total is the number of
+// assigned parts, and Part is the part that changed last
+type SubmitProgress struct {
+	Id       string       `json:"id"`
+	Status   SubmitStatus `json:"status"`
+	Total    int          `json:"total"`
+	Split    int          `json:"split"`
+	Uploaded int          `json:"uploaded"`
+	Part     string       `json:"part,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+func (p *SubmitProgress) Done() bool {
+	return p.Status == SubmitFinished || p.Status == SubmitFailed
+}
+
+type trackedSubmit struct {
+	orgId     string
+	progress  SubmitProgress
+	updatedAt time.Time
+
+	// changed is closed and replaced on each update, such that watchers are woken up
+	changed chan struct{}
+}
+
+// SubmitTracker keeps the progress of submits in memory. Like uploads, the submit and the clients following
+// it must reach the same instance. Progress not updated within Expiry is removed
+type SubmitTracker struct {
+	Expiry time.Duration
+
+	mu      sync.Mutex
+	submits map[string]*trackedSubmit
+}
+
+func NewSubmitTracker(expiry time.Duration) *SubmitTracker {
+	return &SubmitTracker{Expiry: expiry, submits: make(map[string]*trackedSubmit)}
+}
+
+// Create returns the id of a new submit. The progress is waiting until a submit with the id starts
+func (t *SubmitTracker) Create(orgId string) string {
+	t.RemoveExpired(time.Now())
+
+	id := uuid.NewString()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.submits[id] = &trackedSubmit{
+		orgId:     orgId,
+		progress:  SubmitProgress{Id: id, Status: SubmitWaiting},
+		updatedAt: time.Now(),
+		changed:   make(chan struct{}),
+	}
+	return id
+}
+
+func (t *SubmitTracker) get(orgId, id string) (*trackedSubmit, error) {
+	submit, ok := t.submits[id]
+	if !ok || submit.orgId != orgId {
+		return nil, errors.Join(ErrSubmitProgressNotFound, fmt.Errorf("upload id: %s", id))
+	}
+	return submit, nil
+}
+
+// Watch returns the current progress and a channel that is closed on the next update. Submits of other
+// organizations are reported as not found
+func (t *SubmitTracker) Watch(orgId, id string) (SubmitProgress, <-chan struct{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	submit, err := t.get(orgId, id)
+	if err != nil {
+		return SubmitProgress{}, nil, err
+	}
+	return submit.progress, submit.changed, nil
+}
+
+func (t *SubmitTracker) update(orgId, id string, modify func(p *SubmitProgress)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	submit, err := t.get(orgId, id)
+	if err != nil {
+		return
+	}
+	submit.modify(modify)
+}
+
+func (s *trackedSubmit) modify(modify func(p *SubmitProgress)) {
+	modify(&s.progress)
+	s.updatedAt = time.Now()
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Start marks the submit as running and returns the reporter the parts are counted with. Progress that is
+// already running or done can not be started again
+func (t *SubmitTracker) Start(orgId, id string, total int) (*SubmitReporter, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	submit, err := t.get(orgId, id)
+	if err != nil {
+		return nil, err
+	}
+	if submit.progress.Status != SubmitWaiting {
+		return nil, errors.Join(ErrSubmitStarted, fmt.Errorf("upload id %s is %s", id, submit.progress.Status))
+	}
+
+	submit.modify(func(p *SubmitProgress) {
+		p.Status = SubmitRunning
+		p.Total = total
+	})
+	return &SubmitReporter{tracker: t, orgId: orgId, id: id}, nil
+}
+
+// RemoveExpired removes progress that has not been updated within the expiry
+func (t *SubmitTracker) RemoveExpired(now time.Time) int {
+	if t.Expiry <= 0 {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	num := 0
+	for id, submit := range t.submits {
+		if now.Sub(submit.updatedAt) < t.Expiry {
+			continue
+		}
+		delete(t.submits, id)
+		num++
+	}
+	return num
+}
+
+// SubmitReporter counts the parts of a running submit. All methods are no-ops on a nil reporter, such that
+// submits nobody follows are not affected
+type SubmitReporter struct {
+	tracker *SubmitTracker
+	orgId   string
+	id      string
+}
+
+// Parts passes on the parts while counting them as split
+func (r *SubmitReporter) Parts(pdfIter iter.Seq2[string, []byte]) iter.Seq2[string, []byte] {
+	if r == nil {
+		return pdfIter
+	}
+	return func(yield func(string, []byte) bool) {
+		for name, content := range pdfIter {
+			r.tracker.update(r.orgId, r.id, func(p *SubmitProgress) {
+				p.Split++
+				p.Part = name
+			})
+			if !yield(name, content) {
+				return
+			}
+		}
+	}
+}
+
+func (r *SubmitReporter) Uploaded(name string) {
+	if r == nil {
+		return
+	}
+	r.tracker.update(r.orgId, r.id, func(p *SubmitProgress) {
+		p.Uploaded++
+		p.Part = name
+	})
+}
+
+// Finish marks the submit as finished, or as failed when err is not nil
+func (r *SubmitReporter) Finish(err error) {
+	if r == nil {
+		return
+	}
+	r.tracker.update(r.orgId, r.id, func(p *SubmitProgress) {
+		p.Status = SubmitFinished
+		if err != nil {
+			p.Status = SubmitFailed
+			p.Error = err.Error()
+		}
+	})
+}
+
+type submitCtxKey string
+
+const submitReporterKey submitCtxKey = "submitReporter"
+
+// WithSubmitReporter passes the reporter to the store, which reports each part once it is uploaded
+func WithSubmitReporter(ctx context.Context, reporter *SubmitReporter) context.Context {
+	return context.WithValue(ctx, submitReporterKey, reporter)
+}
+
+func submitReporterFrom(ctx context.Context) *SubmitReporter {
+	reporter, _ := ctx.Value(submitReporterKey).(*SubmitReporter)
+	return reporter
+}
@@ -0,0 +1,91 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestSubmitTrackerReportsParts(t *testing.T) {
+	tracker := NewSubmitTracker(time.Hour)
+	id := tracker.Create("org")
+
+	progress, changed, err := tracker.Watch("org", id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, progress.Status, SubmitWaiting)
+
+	_, _, err = tracker.Watch("other-org", id)
+	testutils.AssertEqual(t, errors.Is(err, ErrSubmitProgressNotFound), true)
+
+	reporter, err := tracker.Start("org", id, 2)
+	testutils.AssertNil(t, err)
+	<-changed
+
+	_, err = tracker.Start("org", id, 2)
+	testutils.AssertEqual(t, errors.Is(err, ErrSubmitStarted), true)
+
+	store := NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.RegisterOrganization(context.Background(), &Organization{Id: "org"}))
+	ctx := WithSubmitReporter(context.Background(), reporter)
+	err = store.Submit(ctx, "org", &MetaData{Title: "Polka"}, reporter.Parts(manifestParts))
+	reporter.Finish(err)
+	testutils.AssertNil(t, err)
+
+	progress, _, err = tracker.Watch("org", id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, progress, SubmitProgress{Id: id, Status: SubmitFinished, Total: 2, Split: 2, Uploaded: 2, Part: "Horn.pdf"})
+}
+
+func TestSubmitTrackerReportsUploadsOfGoogleStore(t *testing.T) {
+	tracker := NewSubmitTracker(time.Hour)
+	id := tracker.Create("org")
+	reporter, err := tracker.Start("org", id, 2)
+	testutils.AssertNil(t, err)
+
+	store := &GoogleStore{FsClient: NewLocalFirestoreClient(), BucketClient: &FileBucketClient{Directory: t.TempDir()}, Config: &GoogleConfig{Bucket: "scores"}}
+	ctx := WithSubmitReporter(context.Background(), reporter)
+	testutils.AssertNil(t, store.Submit(ctx, "org", &MetaData{Title: "Polka"}, reporter.Parts(manifestParts)))
+
+	progress, _, err := tracker.Watch("org", id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, progress.Split, 2)
+	testutils.AssertEqual(t, progress.Uploaded, 2)
+}
+
+func TestSubmitTrackerFailedSubmit(t *testing.T) {
+	tracker := NewSubmitTracker(time.Hour)
+	id := tracker.Create("org")
+	reporter, err := tracker.Start("org", id, 1)
+	testutils.AssertNil(t, err)
+
+	reporter.Finish(ErrResourceProtected)
+	progress, _, err := tracker.Watch("org", id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, progress.Status, SubmitFailed)
+	testutils.AssertEqual(t, progress.Done(), true)
+	testutils.AssertEqual(t, progress.Error, ErrResourceProtected.Error())
+}
+
+func TestNilSubmitReporter(t *testing.T) {
+	var reporter *SubmitReporter
+	num := 0
+	for range reporter.Parts(manifestParts) {
+		num++
+	}
+	reporter.Uploaded("Horn.pdf")
+	reporter.Finish(nil)
+	testutils.AssertEqual(t, num, 2)
+}
+
+func TestSubmitTrackerRemoveExpired(t *testing.T) {
+	tracker := NewSubmitTracker(time.Hour)
+	id := tracker.Create("org")
+
+	testutils.AssertEqual(t, tracker.RemoveExpired(time.Now()), 0)
+	testutils.AssertEqual(t, tracker.RemoveExpired(time.Now().Add(2*time.Hour)), 1)
+	_, err := tracker.Start("org", id, 1)
+	testutils.AssertEqual(t, errors.Is(err, ErrSubmitProgressNotFound), true)
+}
@@ -0,0 +1,176 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+// partFailingBucketClient fails the first upload of the part with the given name
+type partFailingBucketClient struct {
+	*FileBucketClient
+	mu       sync.Mutex
+	failName string
+}
+
+func (p *partFailingBucketClient) Upload(ctx context.Context, bucket, object string, data []byte) error {
+	p.mu.Lock()
+	fail := p.failName != "" && path.Base(object) == p.failName && !strings.Contains(object, versionsDir)
+	if fail {
+		p.failName = ""
+	}
+	p.mu.Unlock()
+	if fail {
+		return errors.New("upload failed")
+	}
+	return p.FileBucketClient.Upload(ctx, bucket, object, data)
+}
+
+func partsOf(names ...string) func(yield func(string, []byte) bool) {
+	return func(yield func(string, []byte) bool) {
+		for _, name := range names {
+			if !yield(name, []byte("new "+name)) {
+				return
+			}
+		}
+	}
+}
+
+func TestGoogleSubmitRollbackNewResource(t *testing.T) {
+	client := &partFailingBucketClient{FileBucketClient: &FileBucketClient{Directory: t.TempDir()}, failName: "Horn.pdf"}
+	store := GoogleStore{FsClient: NewLocalFirestoreClient(), BucketClient: client, Config: &GoogleConfig{Bucket: "scores"}}
+	ctx := context.Background()
+	meta := MetaData{Title: "Polka"}
+
+	err := store.Submit(ctx, "org", &meta, partsOf("Trumpet.pdf", "Horn.pdf"))
+	testutils.AssertEqual(t, err != nil, true)
+
+	stored, err := store.MetaById(ctx, "org", meta.ResourceId())
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Status, StoreStatusFailed)
+
+	parts, err := store.listObjects(ctx, path.Join("org", meta.ResourceId())+"/")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(parts), 0)
+
+	testutils.AssertNil(t, store.ResolveSubmit(ctx, "org", meta.ResourceId()))
+	_, err = store.MetaById(ctx, "org", meta.ResourceId())
+	testutils.AssertEqual(t, errors.Is(err, ErrResourceMetadataNotFound), true)
+}
+
+func TestGoogleSubmitRollbackExistingResource(t *testing.T) {
+	client := &partFailingBucketClient{FileBucketClient: &FileBucketClient{Directory: t.TempDir()}}
+	store := GoogleStore{FsClient: NewLocalFirestoreClient(), BucketClient: client, Config: &GoogleConfig{Bucket: "scores"}}
+	ctx := context.Background()
+	meta := MetaData{Title: "Polka"}
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, manifestParts))
+	resourceId := meta.ResourceId()
+
+	client.failName = "Horn.pdf"
+	update := MetaData{Title: "Polka", Publisher: "Doblinger"}
+	err := store.Submit(ctx, "org", &update, partsOf("Trumpet.pdf", "Horn.pdf", "Flute.pdf"))
+	testutils.AssertEqual(t, err != nil, true)
+
+	stored, err := store.MetaById(ctx, "org", resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Status, StoreStatusFinished)
+	testutils.AssertEqual(t, stored.Publisher, "")
+
+	content := make(map[string]string)
+	for name, data := range store.Resource(ctx, "org", resourceId) {
+		content[name] = string(data)
+	}
+	testutils.AssertEqual(t, len(content), 2)
+	testutils.AssertEqual(t, content["Trumpet.pdf"], "Trumpet.pdf")
+	testutils.AssertEqual(t, content["Horn.pdf"], "Horn.pdf")
+
+	versions, err := store.ResourceVersions(ctx, "org", resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(versions), 0)
+}
+
+func TestGoogleResolveSubmit(t *testing.T) {
+	store := GoogleStore{
+		FsClient:     NewLocalFirestoreClient(),
+		BucketClient: &FileBucketClient{Directory: t.TempDir()},
+		Config:       &GoogleConfig{Bucket: "scores"},
+	}
+	ctx := context.Background()
+	meta := MetaData{Title: "Polka"}
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, manifestParts))
+	resourceId := meta.ResourceId()
+	testutils.AssertNil(t, store.FsClient.Update(ctx, metaDataCollection, "org", resourceId, []firestore.Update{{Path: "status", Value: StoreStatusPending}}))
+
+	// Parts that made it are kept
+	testutils.AssertNil(t, store.ResolveSubmit(ctx, "org", resourceId))
+	stored, err := store.MetaById(ctx, "org", resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Status, StoreStatusFinished)
+
+	err = store.ResolveSubmit(ctx, "org", "unknown")
+	testutils.AssertEqual(t, errors.Is(err, ErrResourceMetadataNotFound), true)
+}
+
+func TestInMemoryResolveSubmit(t *testing.T) {
+	store := NewInMemoryStore()
+	ctx := context.Background()
+	withParts := MetaData{Title: "Polka"}
+	testutils.AssertNil(t, store.Submit(ctx, &withParts, manifestParts))
+	store.Metadata = append(store.Metadata, MetaData{Title: "Waltz", Status: StoreStatusPending})
+	store.Metadata[0].Status = StoreStatusPending
+
+	testutils.AssertNil(t, store.ResolveSubmit(ctx, withParts.ResourceId()))
+	testutils.AssertNil(t, store.ResolveSubmit(ctx, "waltz"))
+	testutils.AssertEqual(t, len(store.Metadata), 1)
+	testutils.AssertEqual(t, store.Metadata[0].Status, StoreStatusFinished)
+
+	err := store.ResolveSubmit(ctx, "waltz")
+	testutils.AssertEqual(t, errors.Is(err, ErrResourceMetadataNotFound), true)
+}
+
+func TestPendingSubmitReconciler(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &Organization{Id: "org"}))
+
+	data := store.Data["org"]
+	data.Metadata = []MetaData{
+		{Title: "Failed", Status: StoreStatusFailed, SubmittedAt: now},
+		{Title: "Stale", Status: StoreStatusPending, SubmittedAt: now.Add(-2 * time.Hour)},
+		{Title: "Uploading", Status: StoreStatusPending, SubmittedAt: now.Add(-time.Minute)},
+		{Title: "Unknown submit time", Status: StoreStatusPending},
+		{Title: "Finished", Status: StoreStatusFinished},
+	}
+	data.Data["failed/Horn.pdf"] = []byte("horn")
+	data.Data["stale/Horn.pdf"] = []byte("horn")
+
+	reconciler := NewPendingSubmitReconciler(store, time.Hour)
+	reconciler.Now = func() time.Time { return now }
+	num, err := reconciler.Run(ctx)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, num, 3)
+
+	testutils.AssertEqual(t, len(data.Metadata), 3)
+	testutils.AssertEqual(t, data.Metadata[0].Title, "Stale")
+	testutils.AssertEqual(t, data.Metadata[0].Status, StoreStatusFinished)
+	testutils.AssertEqual(t, data.Metadata[1].Status, StoreStatusPending)
+	testutils.AssertEqual(t, len(data.Data), 1)
+}
+
+func TestPendingSubmitReconcilerListError(t *testing.T) {
+	store := coldStorageStoreWithLister{
+		MultiOrgInMemoryStore: NewMultiOrgInMemoryStore(),
+		lister:                &MockIAMStore{ErrListOrganizations: errors.New("list failed")},
+	}
+	num, err := NewPendingSubmitReconciler(&store, time.Hour).Run(context.Background())
+	testutils.AssertEqual(t, num, 0)
+	testutils.AssertEqual(t, err != nil, true)
+}
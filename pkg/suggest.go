@@ -0,0 +1,60 @@
+package pkg
+
+import (
+	"slices"
+	"strings"
+)
+
+// Suggestion is the minimal description of a resource shown while typing in the search box
+type Suggestion struct {
+	Id       string `json:"id"`
+	Title    string `json:"title"`
+	Composer string `json:"composer"`
+}
+
+// suggestionRank orders matches at the start of the title first, then matches at the start of the composer or
+// arranger, and finally matches anywhere. Resources that do not match get -1
+func suggestionRank(meta *MetaData, query string) int {
+	title, composer, arranger := strings.ToLower(meta.Title), strings.ToLower(meta.Composer), strings.ToLower(meta.Arranger)
+	switch {
+	case strings.HasPrefix(title, query):
+		return 0
+	case strings.HasPrefix(composer, query) || strings.HasPrefix(arranger, query):
+		return 1
+	case strings.Contains(title, query) || strings.Contains(composer, query) || strings.Contains(arranger, query):
+		return 2
+	default:
+		return -1
+	}
+}
+
+// Suggest returns at most limit resources matching the query, best matches first. Resources in the trash are
+// skipped
+func Suggest(meta []MetaData, query string, limit int) []Suggestion {
+	query = strings.ToLower(strings.TrimSpace(query))
+	type ranked struct {
+		rank int
+		meta *MetaData
+	}
+	matches := []ranked{}
+	for i := range meta {
+		if meta[i].Deleted {
+			continue
+		}
+		if rank := suggestionRank(&meta[i], query); rank >= 0 {
+			matches = append(matches, ranked{rank: rank, meta: &meta[i]})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b ranked) int {
+		if a.rank != b.rank {
+			return a.rank - b.rank
+		}
+		return strings.Compare(strings.ToLower(a.meta.Title), strings.ToLower(b.meta.Title))
+	})
+
+	suggestions := make([]Suggestion, 0, min(limit, len(matches)))
+	for _, match := range matches[:min(limit, len(matches))] {
+		suggestions = append(suggestions, Suggestion{Id: match.meta.ResourceId(), Title: match.meta.Title, Composer: match.meta.Composer})
+	}
+	return suggestions
+}
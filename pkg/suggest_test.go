@@ -0,0 +1,34 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestSuggest(t *testing.T) {
+	meta := []MetaData{
+		{Title: "Wedding March", Composer: "Mendelssohn"},
+		{Title: "Marche Slave", Composer: "Tchaikovsky"},
+		{Title: "Radetzky March", Composer: "Strauss"},
+		{Title: "Slavonic Dances", Composer: "Dvorak", Arranger: "Marchetti"},
+		{Title: "March of the Toreadors", Composer: "Bizet", Deleted: true},
+		{Title: "Boléro", Composer: "Ravel"},
+	}
+
+	suggestions := Suggest(meta, " MARCH", 10)
+	titles := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		titles[i] = s.Title
+	}
+	testutils.AssertEqual(t, len(titles), 4)
+	testutils.AssertEqual(t, titles[0], "Marche Slave")
+	testutils.AssertEqual(t, titles[1], "Slavonic Dances")
+	testutils.AssertEqual(t, titles[2], "Radetzky March")
+	testutils.AssertEqual(t, titles[3], "Wedding March")
+	testutils.AssertEqual(t, suggestions[0].Id, meta[1].ResourceId())
+	testutils.AssertEqual(t, suggestions[0].Composer, "Tchaikovsky")
+
+	testutils.AssertEqual(t, len(Suggest(meta, "march", 2)), 2)
+	testutils.AssertEqual(t, len(Suggest(meta, "waltz", 2)), 0)
+}
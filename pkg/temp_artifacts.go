@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+)
+
+const tempArtifactCollection = "tempartifacts"
+
+type ArtifactKind string
+
+const (
+	ArtifactArchive ArtifactKind = "archive"
+)
+
+// TempArtifact is something the app creates for a limited time, such as a pre-generated archive. Artifacts are
+// tracked in the store, such that the cleanup removes them also when the instance that made them is gone
+type TempArtifact struct {
+	Kind      ArtifactKind `json:"kind" firestore:"kind"`
+	OrgId     string       `json:"orgId" firestore:"orgId"`
+	Id        string       `json:"id" firestore:"id"`
+	ExpiresAt time.Time    `json:"expiresAt" firestore:"expiresAt"`
+}
+
+func (t *TempArtifact) key() string {
+	return t.OrgId + "_" + t.Id
+}
+
+type TempArtifactStore interface {
+	TrackArtifact(ctx context.Context, artifact *TempArtifact) error
+
+	// ExpiredArtifacts returns the artifacts of a kind in all organizations that have expired at now
+	ExpiredArtifacts(ctx context.Context, kind ArtifactKind, now time.Time) ([]TempArtifact, error)
+
+	// ForgetArtifact stops tracking an artifact. It is not an error to forget an artifact that is not tracked
+	ForgetArtifact(ctx context.Context, artifact *TempArtifact) error
+}
+
+// ArtifactRemover removes the content of an expired artifact
+type ArtifactRemover func(ctx context.Context, artifact *TempArtifact) error
+
+type ArtifactCleanupStore interface {
+	TempArtifactStore
+	ArchiveDeleter
+}
+
+// ArtifactCleanup removes expired artifacts of every kind with a remover. An artifact is only forgotten once
+// its content is removed, such that removals that fail are attempted again on the next run
+type ArtifactCleanup struct {
+	Store    TempArtifactStore
+	Removers map[ArtifactKind]ArtifactRemover
+	Now      func() time.Time
+}
+
+// Run removes expired artifacts and returns the number of removed artifacts
+func (a *ArtifactCleanup) Run(ctx context.Context) (int, error) {
+	now := a.Now()
+	numRemoved := 0
+	var err error
+	for _, kind := range slices.Sorted(maps.Keys(a.Removers)) {
+		expired, listErr := a.Store.ExpiredArtifacts(ctx, kind, now)
+		if listErr != nil {
+			err = errors.Join(err, listErr)
+			continue
+		}
+
+		for _, artifact := range expired {
+			if removeErr := a.Removers[kind](ctx, &artifact); removeErr != nil && !IsNotFound(removeErr) {
+				err = errors.Join(err, removeErr)
+				continue
+			}
+			if forgetErr := a.Store.ForgetArtifact(ctx, &artifact); forgetErr != nil {
+				err = errors.Join(err, forgetErr)
+				continue
+			}
+			numRemoved++
+			slog.InfoContext(ctx, "Removed expired artifact", "kind", kind, "orgId", artifact.OrgId, "id", artifact.Id)
+		}
+	}
+	return numRemoved, err
+}
+
+func NewArtifactCleanup(store ArtifactCleanupStore) *ArtifactCleanup {
+	return &ArtifactCleanup{
+		Store: store,
+		Removers: map[ArtifactKind]ArtifactRemover{
+			ArtifactArchive: func(ctx context.Context, artifact *TempArtifact) error {
+				return store.DeleteArchive(ctx, artifact.OrgId, artifact.Id)
+			},
+		},
+		Now: time.Now,
+	}
+}
+
+func (g *GoogleStore) TrackArtifact(ctx context.Context, artifact *TempArtifact) error {
+	return g.FsClient.StoreDocument(ctx, tempArtifactCollection, string(artifact.Kind), artifact.key(), artifact)
+}
+
+func (g *GoogleStore) ExpiredArtifacts(ctx context.Context, kind ArtifactKind, now time.Time) ([]TempArtifact, error) {
+	collector := NewValidCollector[TempArtifact]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, tempArtifactCollection, string(kind), "id", "") {
+		collector.Push(doc)
+	}
+	expired := slices.DeleteFunc(collector.Items, func(a TempArtifact) bool { return a.Kind != kind || now.Before(a.ExpiresAt) })
+	return expired, collector.Err
+}
+
+func (g *GoogleStore) ForgetArtifact(ctx context.Context, artifact *TempArtifact) error {
+	return g.FsClient.DeleteDoc(ctx, tempArtifactCollection, string(artifact.Kind), artifact.key())
+}
@@ -0,0 +1,90 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func assertTempArtifactStore(t *testing.T, store TempArtifactStore) {
+	ctx := context.Background()
+	now := time.Now()
+	expired := TempArtifact{Kind: ArtifactArchive, OrgId: "org1", Id: "expired", ExpiresAt: now.Add(-time.Minute)}
+	fresh := TempArtifact{Kind: ArtifactArchive, OrgId: "org2", Id: "fresh", ExpiresAt: now.Add(time.Hour)}
+	testutils.AssertNil(t, store.TrackArtifact(ctx, &expired))
+	testutils.AssertNil(t, store.TrackArtifact(ctx, &fresh))
+
+	artifacts, err := store.ExpiredArtifacts(ctx, ArtifactArchive, now)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(artifacts), 1)
+	testutils.AssertEqual(t, artifacts[0].Id, "expired")
+	testutils.AssertEqual(t, artifacts[0].OrgId, "org1")
+
+	artifacts, err = store.ExpiredArtifacts(ctx, ArtifactKind("other"), now)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(artifacts), 0)
+
+	testutils.AssertNil(t, store.ForgetArtifact(ctx, &expired))
+	testutils.AssertNil(t, store.ForgetArtifact(ctx, &expired))
+	artifacts, err = store.ExpiredArtifacts(ctx, ArtifactArchive, now.Add(2*time.Hour))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(artifacts), 1)
+	testutils.AssertEqual(t, artifacts[0].Id, "fresh")
+}
+
+func TestInMemoryTempArtifactStore(t *testing.T) {
+	assertTempArtifactStore(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGoogleTempArtifactStore(t *testing.T) {
+	assertTempArtifactStore(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
+
+func TestArtifactCleanupRemovesExpiredArchives(t *testing.T) {
+	ctx := context.Background()
+	store := NewMultiOrgInMemoryStore()
+	for _, expiry := range []time.Duration{-time.Minute, time.Hour} {
+		archive := NewArchive("org", "parts.zip", expiry)
+		testutils.AssertNil(t, store.SaveArchive(ctx, archive))
+		testutils.AssertNil(t, store.SaveArchiveChunk(ctx, "org", archive.Id, 0, []byte("zip")))
+		testutils.AssertNil(t, store.TrackArtifact(ctx, &TempArtifact{Kind: ArtifactArchive, OrgId: "org", Id: archive.Id, ExpiresAt: archive.ExpiresAt}))
+	}
+
+	num, err := NewArtifactCleanup(store).Run(ctx)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, num, 1)
+	testutils.AssertEqual(t, len(store.Archives), 1)
+	testutils.AssertEqual(t, len(store.ArchiveChunks), 1)
+	testutils.AssertEqual(t, len(store.TempArtifacts), 1)
+	for _, archive := range store.Archives {
+		testutils.AssertEqual(t, archive.Expired(time.Now()), false)
+	}
+}
+
+func TestArtifactCleanupKeepsArtifactsThatCouldNotBeRemoved(t *testing.T) {
+	ctx := context.Background()
+	store := NewMultiOrgInMemoryStore()
+	artifact := TempArtifact{Kind: ArtifactArchive, OrgId: "org", Id: "archive", ExpiresAt: time.Now().Add(-time.Minute)}
+	testutils.AssertNil(t, store.TrackArtifact(ctx, &artifact))
+
+	cleanup := NewArtifactCleanup(store)
+	cleanup.Removers[ArtifactArchive] = func(ctx context.Context, artifact *TempArtifact) error {
+		return errors.New("bucket unavailable")
+	}
+	num, err := cleanup.Run(ctx)
+	testutils.AssertEqual(t, num, 0)
+	testutils.AssertEqual(t, err != nil, true)
+	testutils.AssertEqual(t, len(store.TempArtifacts), 1)
+
+	// Artifacts that are already gone are forgotten
+	cleanup.Removers[ArtifactArchive] = func(ctx context.Context, artifact *TempArtifact) error {
+		return ErrArchiveNotFound
+	}
+	num, err = cleanup.Run(ctx)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, num, 1)
+	testutils.AssertEqual(t, len(store.TempArtifacts), 0)
+}
@@ -0,0 +1,320 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// Scores with more distinct words than this are most likely not sheet music, and only the first words are kept
+const maxResourceWords = 20000
+
+// ResourceText holds the distinct words found in the parts of a resource. Scanned parts have no words, but the
+// text is still stored such that extraction is not attempted again
+type ResourceText struct {
+	ResourceId  string    `json:"resourceId" firestore:"resourceId"`
+	Words       []string  `json:"words" firestore:"words"`
+	ExtractedAt time.Time `json:"extractedAt" firestore:"extractedAt"`
+}
+
+type ResourceTextStore interface {
+	StoreResourceText(ctx context.Context, orgId string, text *ResourceText) error
+	ResourceTexts(ctx context.Context, orgId string) ([]ResourceText, error)
+}
+
+// TextWords splits text into distinct lower case words, in the order they first appear
+func TextWords(text string) []string {
+	seen := make(map[string]bool)
+	words := []string{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// ExtractPDFText returns the text shown on the pages of a PDF. Text in fonts without a standard encoding, and
+// text in scanned pages, is not found
+func ExtractPDFText(data []byte) (string, error) {
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(data), model.NewDefaultConfiguration())
+	if err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+	for page := 1; page <= ctx.PageCount; page++ {
+		content, err := pdfcpu.ExtractPageContent(ctx, page)
+		if err != nil {
+			return text.String(), fmt.Errorf("page %d: %w", page, err)
+		}
+		if content == nil {
+			continue
+		}
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(content); err != nil {
+			return text.String(), fmt.Errorf("page %d: %w", page, err)
+		}
+		text.WriteString(contentStreamText(buf.Bytes()))
+		text.WriteString("\n")
+	}
+	return text.String(), nil
+}
+
+// contentStreamText collects the literal strings shown by the text operators of a page content stream. Large
+// negative adjustments inside TJ arrays are treated as spaces between words
+func contentStreamText(content []byte) string {
+	var text strings.Builder
+	inArray := false
+	for i := 0; i < len(content); i++ {
+		switch c := content[i]; {
+		case c == '(':
+			literal, end := readLiteralString(content, i)
+			text.WriteString(literal)
+			i = end
+		case c == '[':
+			inArray = true
+		case c == ']':
+			inArray = false
+			text.WriteString(" ")
+		case inArray && (c == '-' || c == '.' || (c >= '0' && c <= '9')):
+			end := i
+			for end < len(content) && (content[end] == '-' || content[end] == '.' || (content[end] >= '0' && content[end] <= '9')) {
+				end++
+			}
+			if adjustment, err := strconv.ParseFloat(string(content[i:end]), 64); err == nil && adjustment < -200 {
+				text.WriteString(" ")
+			}
+			i = end - 1
+		case !inArray && (c == 'T' || c == '\'' || c == '"'):
+			// End of a show text operator, or a new line
+			text.WriteString(" ")
+		}
+	}
+	return text.String()
+}
+
+// readLiteralString reads the literal string starting at the opening parenthesis at start, and returns its
+// content and the position of the closing parenthesis
+func readLiteralString(content []byte, start int) (string, int) {
+	var literal strings.Builder
+	depth := 0
+	for i := start; i < len(content); i++ {
+		switch c := content[i]; c {
+		case '\\':
+			if i+1 < len(content) {
+				i++
+				switch escaped := content[i]; escaped {
+				case 'n', 'r', 't':
+					literal.WriteByte(' ')
+				case '0', '1', '2', '3', '4', '5', '6', '7':
+					end := i
+					for end < len(content) && end < i+3 && content[end] >= '0' && content[end] <= '7' {
+						end++
+					}
+					code, _ := strconv.ParseUint(string(content[i:end]), 8, 8)
+					literal.WriteRune(rune(code))
+					i = end - 1
+				default:
+					literal.WriteByte(escaped)
+				}
+			}
+		case '(':
+			if depth > 0 {
+				literal.WriteByte(c)
+			}
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return literal.String(), i
+			}
+			literal.WriteByte(c)
+		default:
+			literal.WriteByte(c)
+		}
+	}
+	return literal.String(), len(content)
+}
+
+type TextExtractionStore interface {
+	OrganizationLister
+	MetaByPatternFetcher
+	ResourceGetter
+	ResourceTextStore
+}
+
+// TextExtraction extracts the words of resources that were submitted since their text was last extracted
+type TextExtraction struct {
+	Store TextExtractionStore
+	Now   func() time.Time
+}
+
+// Run extracts the text of all resources that need it and returns the number of extracted resources
+func (t *TextExtraction) Run(ctx context.Context) (int, error) {
+	orgs, err := t.Store.ListOrganizations(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	numExtracted := 0
+	for _, org := range orgs {
+		num, orgErr := t.extractOrganization(ctx, org.Id)
+		numExtracted += num
+		err = errors.Join(err, orgErr)
+	}
+	return numExtracted, err
+}
+
+func (t *TextExtraction) extractOrganization(ctx context.Context, orgId string) (int, error) {
+	metas, err := t.Store.MetaByPattern(ctx, orgId, &MetaData{})
+	if err != nil {
+		return 0, err
+	}
+	texts, err := t.Store.ResourceTexts(ctx, orgId)
+	if err != nil {
+		return 0, err
+	}
+	extractedAt := make(map[string]time.Time)
+	for _, text := range texts {
+		extractedAt[text.ResourceId] = text.ExtractedAt
+	}
+
+	numExtracted := 0
+	for _, meta := range metas {
+		resourceId := meta.ResourceId()
+		last, ok := extractedAt[resourceId]
+		if meta.Deleted || meta.Status == StoreStatusPending || meta.Status == StoreStatusFailed || (ok && !last.Before(meta.SubmittedAt)) {
+			continue
+		}
+
+		text := ResourceText{ResourceId: resourceId, Words: []string{}, ExtractedAt: t.Now()}
+		var content strings.Builder
+		for name, data := range t.Store.Resource(ctx, orgId, resourceId) {
+			partText, extractErr := ExtractPDFText(data)
+			if extractErr != nil {
+				slog.WarnContext(ctx, "Could not extract text", "resourceId", resourceId, "part", name, "error", extractErr)
+			}
+			content.WriteString(partText)
+			content.WriteString("\n")
+		}
+		text.Words = TextWords(content.String())
+		text.Words = text.Words[:min(len(text.Words), maxResourceWords)]
+
+		if storeErr := t.Store.StoreResourceText(ctx, orgId, &text); storeErr != nil {
+			err = errors.Join(err, storeErr)
+			continue
+		}
+		numExtracted++
+	}
+	return numExtracted, err
+}
+
+func NewTextExtraction(store TextExtractionStore) *TextExtraction {
+	return &TextExtraction{Store: store, Now: time.Now}
+}
+
+// orgTextIndex maps the sorted distinct words of an organization to the resources containing them
+type orgTextIndex struct {
+	words     []string
+	resources map[string][]string
+	loadedAt  time.Time
+}
+
+func newOrgTextIndex(texts []ResourceText, now time.Time) *orgTextIndex {
+	index := orgTextIndex{resources: make(map[string][]string), loadedAt: now}
+	for _, text := range texts {
+		for _, word := range text.Words {
+			index.resources[word] = append(index.resources[word], text.ResourceId)
+		}
+	}
+	for word := range index.resources {
+		index.words = append(index.words, word)
+	}
+	slices.Sort(index.words)
+	return &index
+}
+
+// search returns the resources that contain a word starting with each of the words in the query
+func (o *orgTextIndex) search(query string) []string {
+	var matches map[string]bool
+	for _, token := range TextWords(query) {
+		found := make(map[string]bool)
+		start, _ := slices.BinarySearch(o.words, token)
+		for _, word := range o.words[start:] {
+			if !strings.HasPrefix(word, token) {
+				break
+			}
+			for _, resourceId := range o.resources[word] {
+				if matches == nil || matches[resourceId] {
+					found[resourceId] = true
+				}
+			}
+		}
+		matches = found
+	}
+
+	result := make([]string, 0, len(matches))
+	for resourceId := range matches {
+		result = append(result, resourceId)
+	}
+	slices.Sort(result)
+	return result
+}
+
+// TextIndex searches the words inside the scores of an organization. The index of each organization is kept in
+// memory, and is read again from the store when it is older than TTL
+type TextIndex struct {
+	Store ResourceTextStore
+	TTL   time.Duration
+
+	mu    sync.Mutex
+	index map[string]*orgTextIndex
+}
+
+// Search returns the ids of the resources containing all words of the query. The last word may be incomplete
+func (t *TextIndex) Search(ctx context.Context, orgId, query string) ([]string, error) {
+	now := time.Now()
+	t.mu.Lock()
+	index, ok := t.index[orgId]
+	t.mu.Unlock()
+
+	if !ok || now.Sub(index.loadedAt) >= t.TTL {
+		texts, err := t.Store.ResourceTexts(ctx, orgId)
+		if err != nil {
+			return nil, err
+		}
+		index = newOrgTextIndex(texts, now)
+
+		t.mu.Lock()
+		t.index[orgId] = index
+		t.mu.Unlock()
+	}
+	return index.search(query), nil
+}
+
+func NewTextIndex(store ResourceTextStore, ttl time.Duration) *TextIndex {
+	return &TextIndex{Store: store, TTL: ttl, index: make(map[string]*orgTextIndex)}
+}
+
+// Invalidate drops the index of the organization, such that the next search reads the texts from the store
+func (t *TextIndex) Invalidate(orgId string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.index, orgId)
+}
@@ -0,0 +1,141 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestExtractPDFText(t *testing.T) {
+	var buf bytes.Buffer
+	testutils.AssertNil(t, CreateNPagePdf(&buf, 2))
+
+	text, err := ExtractPDFText(buf.Bytes())
+	testutils.AssertNil(t, err)
+	testutils.AssertContains(t, text, "This is page 1", "This is page 2")
+
+	_, err = ExtractPDFText([]byte("not a pdf"))
+	testutils.AssertEqual(t, err != nil, true)
+}
+
+func TestContentStreamText(t *testing.T) {
+	for _, test := range []struct {
+		content string
+		want    []string
+	}{
+		{"BT (Ave) Tj ET", []string{"ave"}},
+		{"BT [(Ky)10(rie)-300(elei)(son)] TJ ET", []string{"kyrie", "eleison"}},
+		{`BT (Gloria \(in\) excelsis) Tj (Deo) ' ET`, []string{"gloria", "in", "excelsis", "deo"}},
+		{`BT (Agnus\040Dei) Tj ET`, []string{"agnus", "dei"}},
+	} {
+		testutils.AssertEqual(t, strings.Join(TextWords(contentStreamText([]byte(test.content))), " "), strings.Join(test.want, " "))
+	}
+}
+
+func TestTextWords(t *testing.T) {
+	words := TextWords("Allegro, ma non troppo - ALLEGRO 2.")
+	testutils.AssertEqual(t, strings.Join(words, " "), "allegro ma non troppo 2")
+}
+
+func TestTextExtraction(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &Organization{Id: "org"}))
+
+	var buf bytes.Buffer
+	testutils.AssertNil(t, CreateNPagePdf(&buf, 1))
+	data := store.Data["org"]
+	data.Metadata = []MetaData{
+		{Title: "Sonata", Status: StoreStatusFinished},
+		{Title: "Scanned", Status: StoreStatusFinished},
+		{Title: "Uploading", Status: StoreStatusPending},
+	}
+	data.Data["sonata/Flute.pdf"] = buf.Bytes()
+	data.Data["scanned/Flute.pdf"] = []byte("not a pdf")
+
+	extraction := NewTextExtraction(store)
+	num, err := extraction.Run(ctx)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, num, 2)
+	testutils.AssertEqual(t, strings.Join(store.OrgTexts["org"]["sonata"].Words, " "), "this is page 1")
+	testutils.AssertEqual(t, len(store.OrgTexts["org"]["scanned"].Words), 0)
+
+	// Extracted again only when submitted after the last extraction
+	num, err = extraction.Run(ctx)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, num, 0)
+
+	data.Metadata[0].SubmittedAt = time.Now().Add(time.Hour)
+	num, err = extraction.Run(ctx)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, num, 1)
+}
+
+func TestTextIndexSearch(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	texts := []ResourceText{
+		{ResourceId: "mass", Words: []string{"kyrie", "eleison", "gloria"}},
+		{ResourceId: "hymn", Words: []string{"gloria", "patri"}},
+	}
+	for _, text := range texts {
+		testutils.AssertNil(t, store.StoreResourceText(ctx, "org", &text))
+	}
+
+	index := NewTextIndex(store, time.Hour)
+	for _, test := range []struct {
+		query string
+		want  []string
+	}{
+		{"Gloria", []string{"hymn", "mass"}},
+		{"glor kyr", []string{"mass"}},
+		{"gloria credo", []string{}},
+		{"", []string{}},
+	} {
+		ids, err := index.Search(ctx, "org", test.query)
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, slices.Equal(ids, test.want), true)
+	}
+
+	// The index is cached until it expires
+	testutils.AssertNil(t, store.StoreResourceText(ctx, "org", &ResourceText{ResourceId: "requiem", Words: []string{"requiem"}}))
+	ids, err := index.Search(ctx, "org", "requiem")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(ids), 0)
+
+	index.TTL = 0
+	ids, err = index.Search(ctx, "org", "requiem")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, slices.Equal(ids, []string{"requiem"}), true)
+}
+
+func assertResourceTextStore(t *testing.T, store ResourceTextStore) {
+	ctx := context.Background()
+	text := ResourceText{ResourceId: "mass", Words: []string{"kyrie"}, ExtractedAt: time.Now().UTC()}
+	testutils.AssertNil(t, store.StoreResourceText(ctx, "org", &text))
+	text.Words = []string{"gloria", "credo"}
+	testutils.AssertNil(t, store.StoreResourceText(ctx, "org", &text))
+
+	texts, err := store.ResourceTexts(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(texts), 1)
+	testutils.AssertEqual(t, texts[0].ResourceId, "mass")
+	testutils.AssertEqual(t, strings.Join(texts[0].Words, " "), "gloria credo")
+
+	texts, err = store.ResourceTexts(ctx, "other")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(texts), 0)
+}
+
+func TestGoogleStoreResourceTexts(t *testing.T) {
+	assertResourceTextStore(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
+
+func TestInMemoryResourceTexts(t *testing.T) {
+	assertResourceTextStore(t, NewMultiOrgInMemoryStore())
+}
@@ -0,0 +1,81 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// TxStore is the part of a store that can be used inside a transaction
+type TxStore interface {
+	MetaByIdGetter
+	MetaDataUpdater
+	ProjectByIdGetter
+	ProjectSubmitter
+	ProjectDeleter
+	ProjectOrderSetter
+	CorrectionStore
+	UserNameUpdater
+}
+
+// Transactor runs updates spanning several documents, such as metadata and projects, such that either all or
+// none of them are applied
+type Transactor interface {
+	// RunTransaction calls fn with a store whose writes are applied when fn returns nil, and discarded when it
+	// returns an error. fn may be called again when the transaction conflicts with another, so it must not have
+	// effects outside the store. Firestore requires all reads of a transaction to come before its writes
+	RunTransaction(ctx context.Context, fn func(ctx context.Context, tx TxStore) error) error
+}
+
+// TransactionalClient is implemented by document clients that can apply several writes together
+type TransactionalClient interface {
+	RunTransaction(ctx context.Context, fn func(ctx context.Context, client FirestoreClient) error) error
+}
+
+// RunTransaction runs fn in a transaction of the document client. Clients without transactions, such as the
+// S3DocumentClient, apply the writes one by one
+func (g *GoogleStore) RunTransaction(ctx context.Context, fn func(ctx context.Context, tx TxStore) error) error {
+	client, ok := g.FsClient.(TransactionalClient)
+	if !ok {
+		return fn(ctx, g)
+	}
+	return client.RunTransaction(ctx, func(ctx context.Context, fsClient FirestoreClient) error {
+		return fn(ctx, &GoogleStore{BucketClient: g.BucketClient, FsClient: fsClient, Config: g.Config})
+	})
+}
+
+// RunTransaction restores the content of the store when fn fails
+func (m *MultiOrgInMemoryStore) RunTransaction(ctx context.Context, fn func(ctx context.Context, tx TxStore) error) error {
+	snapshot := m.Clone()
+	if err := fn(ctx, m); err != nil {
+		m.ReplaceWith(snapshot)
+		return err
+	}
+	return nil
+}
+
+// AddToProject adds resources to a project, and creates the project if it does not exist. The resources must
+// exist and not be in the trash. Notes of the project are kept
+func AddToProject(ctx context.Context, store Transactor, orgId string, project *Project) error {
+	return store.RunTransaction(ctx, func(ctx context.Context, tx TxStore) error {
+		for _, resourceId := range project.ResourceIds {
+			meta, err := tx.MetaById(ctx, orgId, resourceId)
+			if err != nil {
+				return err
+			}
+			if meta.Deleted {
+				return errors.Join(ErrResourceMetadataNotFound, fmt.Errorf("%s is deleted", resourceId))
+			}
+		}
+
+		existing, err := tx.ProjectById(ctx, orgId, project.Id())
+		if errors.Is(err, ErrProjectNotFound) {
+			return tx.SubmitProject(ctx, orgId, project)
+		}
+		if err != nil {
+			return err
+		}
+		existing.Merge(project)
+		return tx.SubmitProject(ctx, orgId, existing)
+	})
+}
@@ -0,0 +1,86 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+type transactionTestStore interface {
+	Transactor
+	Submitter
+	ProjectByIdGetter
+	ResourceDeleter
+}
+
+// assertTransactor checks that AddToProject merges with the existing project, and that nothing is written when
+// the transaction fails
+func assertTransactor(t *testing.T, store transactionTestStore) {
+	ctx := context.Background()
+	polka, march, waltz := MetaData{Title: "Polka"}, MetaData{Title: "March"}, MetaData{Title: "Waltz"}
+	for _, meta := range []*MetaData{&polka, &march, &waltz} {
+		testutils.AssertNil(t, store.Submit(ctx, "org", meta, manifestParts))
+	}
+	testutils.AssertNil(t, store.DeleteResource(ctx, "org", waltz.ResourceId()))
+
+	project := Project{Name: "Spring concert", ResourceIds: []string{polka.ResourceId()}, Notes: map[string]string{polka.ResourceId(): "Slow"}}
+	testutils.AssertNil(t, AddToProject(ctx, store, "org", &project))
+	testutils.AssertNil(t, AddToProject(ctx, store, "org", &Project{Name: "Spring concert", ResourceIds: []string{march.ResourceId()}}))
+
+	stored, err := store.ProjectById(ctx, "org", project.Id())
+	testutils.AssertNil(t, err)
+	slices.Sort(stored.ResourceIds)
+	testutils.AssertEqual(t, len(stored.ResourceIds), 2)
+	testutils.AssertEqual(t, stored.ResourceIds[0], march.ResourceId())
+	testutils.AssertEqual(t, stored.Notes[polka.ResourceId()], "Slow")
+
+	for _, resourceId := range []string{"unknown", waltz.ResourceId()} {
+		err = AddToProject(ctx, store, "org", &Project{Name: "Spring concert", ResourceIds: []string{resourceId}})
+		testutils.AssertEqual(t, errors.Is(err, ErrResourceMetadataNotFound), true)
+	}
+
+	// Writes are discarded when the transaction fails
+	errAbort := errors.New("abort")
+	err = store.RunTransaction(ctx, func(ctx context.Context, tx TxStore) error {
+		if err := tx.SubmitProject(ctx, "org", &Project{Name: "Autumn concert", ResourceIds: []string{polka.ResourceId()}}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	testutils.AssertEqual(t, errors.Is(err, errAbort), true)
+	_, err = store.ProjectById(ctx, "org", "autumnconcert")
+	testutils.AssertEqual(t, errors.Is(err, ErrProjectNotFound), true)
+}
+
+func TestInMemoryTransaction(t *testing.T) {
+	store := NewMultiOrgInMemoryStore()
+	testutils.AssertNil(t, store.RegisterOrganization(context.Background(), &Organization{Id: "org"}))
+	assertTransactor(t, store)
+}
+
+func TestGoogleStoreTransaction(t *testing.T) {
+	assertTransactor(t, &GoogleStore{
+		FsClient:     NewResilientFirestoreClient(NewLocalFirestoreClient(), &ResilienceConfig{}),
+		BucketClient: &FileBucketClient{Directory: t.TempDir()},
+		Config:       &GoogleConfig{Bucket: "scores"},
+	})
+}
+
+func TestLocalStoreTransaction(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+	assertTransactor(t, store)
+}
+
+func TestGoogleStoreTransactionWithoutTransactionalClient(t *testing.T) {
+	store := GoogleStore{FsClient: &FailingFirestoreClient{}}
+	called := false
+	err := store.RunTransaction(context.Background(), func(ctx context.Context, tx TxStore) error {
+		called = true
+		return nil
+	})
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, called, true)
+}
@@ -0,0 +1,134 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/storage"
+)
+
+// purgeable returns an error if the metadata could not be fetched or the resource is not in the trash
+func purgeable(meta *MetaData, err error, resourceId string) error {
+	if err != nil {
+		return err
+	}
+	if !meta.Deleted {
+		return errors.Join(ErrResourceNotDeleted, fmt.Errorf("resource id: %s", resourceId))
+	}
+	return nil
+}
+
+func (g *GoogleStore) RestoreResource(ctx context.Context, orgId, resourceId string) error {
+	err := g.FsClient.Update(
+		ctx,
+		metaDataCollection,
+		orgId,
+		resourceId,
+		[]firestore.Update{{Path: "deleted", Value: false}, {Path: "deleted_at", Value: time.Time{}}},
+	)
+	return classifyStoreErr(err, ErrResourceMetadataNotFound)
+}
+
+// PurgeResource removes the files before the metadata, such that a failed purge can be attempted again
+func (g *GoogleStore) PurgeResource(ctx context.Context, orgId, resourceId string) error {
+	meta, err := g.MetaById(ctx, orgId, resourceId)
+	if err := purgeable(meta, err, resourceId); err != nil {
+		return err
+	}
+	if err := g.deleteFiles(ctx, orgId, resourceId); err != nil {
+		return err
+	}
+	return g.FsClient.DeleteDoc(ctx, metaDataCollection, orgId, resourceId)
+}
+
+// deleteFiles removes the parts of the resource including earlier versions
+func (g *GoogleStore) deleteFiles(ctx context.Context, orgId, resourceId string) error {
+	for _, prefix := range []string{path.Join(orgId, resourceId) + "/", path.Join(orgId, versionsDir, resourceId) + "/"} {
+		// Listed first since not all backends support deleting while listing
+		objects, err := g.listObjects(ctx, prefix)
+		if err != nil {
+			return err
+		}
+		if err := g.deleteObjects(ctx, objects); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *GoogleStore) deleteObjects(ctx context.Context, objects []*storage.ObjectAttrs) error {
+	for _, attrs := range objects {
+		if err := g.BucketClient.Delete(ctx, g.Config.Bucket, attrs.Name); err != nil && !IsNotFound(classifyStoreErr(err, ErrResourceNotFound)) {
+			return err
+		}
+	}
+	return nil
+}
+
+type TrashStore interface {
+	OrganizationLister
+	MetaByPatternFetcher
+	ResourceDeleter
+	ResourcePurger
+}
+
+// TrashPurge permanently removes resources that have been in the trash for longer than Retention
+type TrashPurge struct {
+	Store     TrashStore
+	Retention time.Duration
+	Now       func() time.Time
+}
+
+// Run purges all expired resources and returns the number of purged resources. Resources deleted
+// before the deletion time was recorded gets it set such that the clock starts ticking
+func (t *TrashPurge) Run(ctx context.Context) (int, error) {
+	orgs, err := t.Store.ListOrganizations(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	now := t.Now()
+	numPurged := 0
+	for _, org := range orgs {
+		metas, metaErr := t.Store.MetaByPattern(ctx, org.Id, &MetaData{})
+		if metaErr != nil {
+			err = errors.Join(err, metaErr)
+			continue
+		}
+
+		for _, meta := range metas {
+			if !meta.Deleted {
+				continue
+			}
+
+			resourceId := meta.ResourceId()
+			if meta.DeletedAt.IsZero() {
+				err = errors.Join(err, t.Store.DeleteResource(ctx, org.Id, resourceId))
+				continue
+			}
+
+			if now.Sub(meta.DeletedAt) < t.Retention {
+				continue
+			}
+
+			if purgeErr := t.Store.PurgeResource(ctx, org.Id, resourceId); purgeErr != nil {
+				err = errors.Join(err, purgeErr)
+				continue
+			}
+			numPurged++
+		}
+	}
+	return numPurged, err
+}
+
+func NewTrashPurge(store TrashStore, retention time.Duration) *TrashPurge {
+	return &TrashPurge{
+		Store:     store,
+		Retention: retention,
+		Now:       time.Now,
+	}
+}
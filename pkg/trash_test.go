@@ -0,0 +1,108 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestGoogleStoreTrash(t *testing.T) {
+	store := GoogleStore{
+		FsClient:     NewLocalFirestoreClient(),
+		BucketClient: &FileBucketClient{Directory: t.TempDir()},
+		Config:       &GoogleConfig{Bucket: "scores"},
+	}
+	ctx := context.Background()
+	meta := MetaData{Title: "Polka"}
+	other := MetaData{Title: "Polka", Composer: "Strauss"}
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, manifestParts))
+	testutils.AssertNil(t, store.Submit(ctx, "org", &other, manifestParts))
+	resourceId := meta.ResourceId()
+
+	err := store.PurgeResource(ctx, "org", resourceId)
+	testutils.AssertEqual(t, errors.Is(err, ErrResourceNotDeleted), true)
+
+	testutils.AssertNil(t, store.DeleteResource(ctx, "org", resourceId))
+	deleted, err := store.MetaById(ctx, "org", resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, deleted.Deleted, true)
+	testutils.AssertEqual(t, deleted.DeletedAt.IsZero(), false)
+
+	testutils.AssertNil(t, store.RestoreResource(ctx, "org", resourceId))
+	restored, err := store.MetaById(ctx, "org", resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, restored.Deleted, false)
+	testutils.AssertEqual(t, restored.DeletedAt.IsZero(), true)
+
+	testutils.AssertNil(t, store.DeleteResource(ctx, "org", resourceId))
+	testutils.AssertNil(t, store.PurgeResource(ctx, "org", resourceId))
+	_, err = store.MetaById(ctx, "org", resourceId)
+	testutils.AssertEqual(t, errors.Is(err, ErrResourceMetadataNotFound), true)
+	_, err = store.Item(ctx, "org/"+resourceId+"/Horn.pdf")
+	testutils.AssertEqual(t, errors.Is(err, ErrResourceNotFound), true)
+
+	// Resources sharing a prefix are kept
+	_, err = store.Item(ctx, "org/"+other.ResourceId()+"/Horn.pdf")
+	testutils.AssertNil(t, err)
+
+	err = store.RestoreResource(ctx, "org", resourceId)
+	testutils.AssertEqual(t, errors.Is(err, ErrResourceMetadataNotFound), true)
+}
+
+func TestInMemoryPurgeResource(t *testing.T) {
+	store := NewInMemoryStore()
+	ctx := context.Background()
+	meta := MetaData{Title: "Polka"}
+	testutils.AssertNil(t, store.Submit(ctx, &meta, manifestParts))
+
+	err := store.PurgeResource(ctx, meta.ResourceId())
+	testutils.AssertEqual(t, errors.Is(err, ErrResourceNotDeleted), true)
+
+	testutils.AssertNil(t, store.DeleteResource(ctx, meta.ResourceId()))
+	testutils.AssertNil(t, store.PurgeResource(ctx, meta.ResourceId()))
+	testutils.AssertEqual(t, len(store.Metadata), 0)
+	testutils.AssertEqual(t, len(store.Data), 0)
+
+	err = store.PurgeResource(ctx, meta.ResourceId())
+	testutils.AssertEqual(t, errors.Is(err, ErrResourceMetadataNotFound), true)
+}
+
+func TestTrashPurge(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterOrganization(ctx, &Organization{Id: "org"}))
+
+	data := store.Data["org"]
+	data.Metadata = []MetaData{
+		{Title: "Expired", Deleted: true, DeletedAt: now.Add(-40 * 24 * time.Hour)},
+		{Title: "Recent", Deleted: true, DeletedAt: now.Add(-24 * time.Hour)},
+		{Title: "Unknown deletion time", Deleted: true},
+		{Title: "Kept"},
+	}
+	data.Data["expired/Horn.pdf"] = []byte("horn")
+
+	purge := NewTrashPurge(store, 30*24*time.Hour)
+	purge.Now = func() time.Time { return now }
+	num, err := purge.Run(ctx)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, num, 1)
+
+	testutils.AssertEqual(t, len(data.Metadata), 3)
+	testutils.AssertEqual(t, data.Metadata[0].Title, "Recent")
+	testutils.AssertEqual(t, data.Metadata[1].DeletedAt.IsZero(), false)
+	testutils.AssertEqual(t, len(data.Data), 0)
+}
+
+func TestTrashPurgeListError(t *testing.T) {
+	store := coldStorageStoreWithLister{
+		MultiOrgInMemoryStore: NewMultiOrgInMemoryStore(),
+		lister:                &MockIAMStore{ErrListOrganizations: errors.New("list failed")},
+	}
+	num, err := NewTrashPurge(&store, time.Hour).Run(context.Background())
+	testutils.AssertEqual(t, num, 0)
+	testutils.AssertEqual(t, err != nil, true)
+}
@@ -0,0 +1,167 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UploadSession is a resumable upload of a score. The file is received in chunks, and the score is submitted
+// with the metadata and assignments given when the session was created once all bytes are received
+type UploadSession struct {
+	Id          string
+	OrgId       string
+	Length      int64
+	Offset      int64
+	MetaData    MetaData
+	Assignments []Assignment
+	UpdatedAt   time.Time
+
+	mu sync.Mutex
+}
+
+func (s *UploadSession) Complete() bool {
+	return s.Offset == s.Length
+}
+
+// UploadSessions keeps the chunks of ongoing uploads in a directory on local disk. Sessions are kept in memory,
+// so all chunks of an upload must reach the same instance. Sessions not updated within Expiry are removed
+type UploadSessions struct {
+	Dir    string
+	Expiry time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*UploadSession
+}
+
+func NewUploadSessions(dir string, expiry time.Duration) *UploadSessions {
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "caesura-uploads")
+	}
+	return &UploadSessions{Dir: dir, Expiry: expiry, sessions: make(map[string]*UploadSession)}
+}
+
+func (u *UploadSessions) path(id string) string {
+	return filepath.Join(u.Dir, id)
+}
+
+func (u *UploadSessions) Create(orgId string, length int64, meta MetaData, assignments []Assignment) (*UploadSession, error) {
+	u.RemoveExpired(time.Now())
+
+	if err := os.MkdirAll(u.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("could not create upload directory: %w", err)
+	}
+	session := &UploadSession{
+		Id:          uuid.NewString(),
+		OrgId:       orgId,
+		Length:      length,
+		MetaData:    meta,
+		Assignments: assignments,
+		UpdatedAt:   time.Now(),
+	}
+	f, err := os.OpenFile(u.path(session.Id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.sessions[session.Id] = session
+	return session, nil
+}
+
+// Get returns the session. Sessions of other organizations are reported as not found
+func (u *UploadSessions) Get(orgId, id string) (*UploadSession, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	session, ok := u.sessions[id]
+	if !ok || session.OrgId != orgId {
+		return nil, errors.Join(ErrUploadNotFound, fmt.Errorf("upload id: %s", id))
+	}
+	return session, nil
+}
+
+// Append writes the chunk at offset, which must match the number of bytes received so far. Bytes received
+// before a failing read are kept, such that the client can resume from the new offset. A chunk going beyond
+// the announced length is discarded and leaves the upload unchanged
+func (u *UploadSessions) Append(orgId, id string, offset int64, chunk io.Reader) (int64, error) {
+	session, err := u.Get(orgId, id)
+	if err != nil {
+		return 0, err
+	}
+	if !session.mu.TryLock() {
+		return 0, errors.Join(ErrUploadInProgress, fmt.Errorf("upload id: %s", id))
+	}
+	defer session.mu.Unlock()
+
+	if offset != session.Offset {
+		return session.Offset, errors.Join(ErrUploadOffsetMismatch, fmt.Errorf("expected offset %d got %d", session.Offset, offset))
+	}
+
+	f, err := os.OpenFile(u.path(id), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return session.Offset, err
+	}
+	defer f.Close()
+
+	remaining := session.Length - session.Offset
+	n, copyErr := io.Copy(f, io.LimitReader(chunk, remaining+1))
+	if n > remaining {
+		// The whole chunk is rejected, such that the upload can not be finished with a cut off file
+		if err := f.Truncate(session.Offset); err != nil {
+			return session.Offset, err
+		}
+		return session.Offset, errors.Join(ErrUploadTooLarge, fmt.Errorf("upload length is %d bytes", session.Length))
+	}
+	session.Offset += n
+	session.UpdatedAt = time.Now()
+	return session.Offset, copyErr
+}
+
+// Open returns the received file. The caller closes it
+func (u *UploadSessions) Open(orgId, id string) (*os.File, error) {
+	if _, err := u.Get(orgId, id); err != nil {
+		return nil, err
+	}
+	return os.Open(u.path(id))
+}
+
+func (u *UploadSessions) Remove(orgId, id string) error {
+	if _, err := u.Get(orgId, id); err != nil {
+		return err
+	}
+	u.mu.Lock()
+	delete(u.sessions, id)
+	u.mu.Unlock()
+	return os.Remove(u.path(id))
+}
+
+// RemoveExpired removes sessions that have not received any chunks within the expiry
+func (u *UploadSessions) RemoveExpired(now time.Time) int {
+	if u.Expiry <= 0 {
+		return 0
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	num := 0
+	for id, session := range u.sessions {
+		if now.Sub(session.UpdatedAt) < u.Expiry {
+			continue
+		}
+		delete(u.sessions, id)
+		if err := os.Remove(u.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Error("Could not remove expired upload", "error", err, "uploadId", id)
+		}
+		num++
+	}
+	return num
+}
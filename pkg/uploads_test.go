@@ -0,0 +1,80 @@
+package pkg
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestUploadSessionsAppend(t *testing.T) {
+	uploads := NewUploadSessions(t.TempDir(), time.Hour)
+	session, err := uploads.Create("org", 6, MetaData{Title: "Polka"}, nil)
+	testutils.AssertNil(t, err)
+
+	offset, err := uploads.Append("org", session.Id, 0, strings.NewReader("abc"))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, offset, int64(3))
+	testutils.AssertEqual(t, session.Complete(), false)
+
+	_, err = uploads.Append("org", session.Id, 0, strings.NewReader("abc"))
+	testutils.AssertEqual(t, errors.Is(err, ErrUploadOffsetMismatch), true)
+
+	_, err = uploads.Append("other-org", session.Id, 3, strings.NewReader("def"))
+	testutils.AssertEqual(t, errors.Is(err, ErrUploadNotFound), true)
+
+	offset, err = uploads.Append("org", session.Id, 3, strings.NewReader("def"))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, offset, int64(6))
+	testutils.AssertEqual(t, session.Complete(), true)
+
+	f, err := uploads.Open("org", session.Id)
+	testutils.AssertNil(t, err)
+	content, err := io.ReadAll(f)
+	f.Close()
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, string(content), "abcdef")
+
+	testutils.AssertNil(t, uploads.Remove("org", session.Id))
+	_, err = uploads.Get("org", session.Id)
+	testutils.AssertEqual(t, errors.Is(err, ErrUploadNotFound), true)
+}
+
+func TestUploadSessionsTooLarge(t *testing.T) {
+	uploads := NewUploadSessions(t.TempDir(), time.Hour)
+	session, err := uploads.Create("org", 2, MetaData{}, nil)
+	testutils.AssertNil(t, err)
+
+	offset, err := uploads.Append("org", session.Id, 0, strings.NewReader("a"))
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, offset, int64(1))
+
+	offset, err = uploads.Append("org", session.Id, 1, strings.NewReader("bc"))
+	testutils.AssertEqual(t, errors.Is(err, ErrUploadTooLarge), true)
+	testutils.AssertEqual(t, offset, int64(1))
+
+	received, err := uploads.Get("org", session.Id)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, received.Complete(), false)
+
+	f, err := uploads.Open("org", session.Id)
+	testutils.AssertNil(t, err)
+	content, err := io.ReadAll(f)
+	f.Close()
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, string(content), "a")
+}
+
+func TestUploadSessionsRemoveExpired(t *testing.T) {
+	uploads := NewUploadSessions(t.TempDir(), time.Hour)
+	session, err := uploads.Create("org", 2, MetaData{}, nil)
+	testutils.AssertNil(t, err)
+
+	testutils.AssertEqual(t, uploads.RemoveExpired(time.Now()), 0)
+	testutils.AssertEqual(t, uploads.RemoveExpired(time.Now().Add(2*time.Hour)), 1)
+	_, err = uploads.Get("org", session.Id)
+	testutils.AssertEqual(t, errors.Is(err, ErrUploadNotFound), true)
+}
@@ -0,0 +1,117 @@
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxUserAgentLength = 512
+
+// UserSession is the server side record of a signed in browser. The session cookie refers to the record by id,
+// and the session is signed out as soon as the record is revoked
+type UserSession struct {
+	Id         string    `json:"id" firestore:"id"`
+	UserId     string    `json:"userId" firestore:"userId"`
+	UserAgent  string    `json:"userAgent" firestore:"userAgent"`
+	IP         string    `json:"ip" firestore:"ip"`
+	CreatedAt  time.Time `json:"createdAt" firestore:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt" firestore:"lastSeenAt"`
+}
+
+// NewUserSession returns a session of the user with a random id. Long user agents are truncated
+func NewUserSession(userId, userAgent, ip string) *UserSession {
+	if utf8.RuneCountInString(userAgent) > maxUserAgentLength {
+		userAgent = string([]rune(userAgent)[:maxUserAgentLength])
+	}
+	now := time.Now()
+	return &UserSession{
+		Id:         rand.Text(),
+		UserId:     userId,
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+}
+
+func (s *UserSession) Validate() error {
+	if s.Id == "" || s.UserId == "" {
+		return errors.Join(ErrInvalidUserSession, errors.New("id and user can not be empty"))
+	}
+	return nil
+}
+
+type SessionRegistry interface {
+	// RegisterSession stores the session. Registering an existing session updates it
+	RegisterSession(ctx context.Context, session *UserSession) error
+	Session(ctx context.Context, id string) (UserSession, error)
+
+	// Sessions returns the sessions of the user, most recently seen first
+	Sessions(ctx context.Context, userId string) ([]UserSession, error)
+	RevokeSession(ctx context.Context, userId, id string) error
+
+	// SessionGeneration returns the generation of the sessions of the user. Sessions issued before the current
+	// generation are signed out. Users that never signed out from all devices have generation 0
+	SessionGeneration(ctx context.Context, userId string) (int64, error)
+
+	// NewSessionGeneration starts a generation that is larger than all earlier generations of the user
+	NewSessionGeneration(ctx context.Context, userId string) (int64, error)
+}
+
+// SessionGeneration is the document stored for each user that has signed out from all devices
+type SessionGeneration struct {
+	Generation int64 `firestore:"generation"`
+}
+
+// newSessionGeneration creates a generation that is larger than all earlier generations of the user
+func newSessionGeneration() int64 {
+	return time.Now().UnixNano()
+}
+
+func SortUserSessions(sessions []UserSession) {
+	slices.SortStableFunc(sessions, func(a, b UserSession) int { return b.LastSeenAt.Compare(a.LastSeenAt) })
+}
+
+func userSessionNotFound(id string) error {
+	return errors.Join(ErrUserSessionNotFound, fmt.Errorf("session id: %s", id))
+}
+
+var (
+	userAgentBrowsers = []struct{ token, name string }{
+		{"Edg", "Edge"}, {"OPR", "Opera"}, {"Firefox", "Firefox"}, {"Chrome", "Chrome"}, {"Safari", "Safari"},
+	}
+	userAgentSystems = []struct{ token, name string }{
+		{"Android", "Android"}, {"iPhone", "iOS"}, {"iPad", "iPadOS"}, {"Windows", "Windows"}, {"Mac OS X", "macOS"}, {"Linux", "Linux"},
+	}
+)
+
+// Device returns a short description of the browser and operating system of the session, e.g. "Firefox on Linux".
+// An empty string is returned when neither is recognized
+func (s UserSession) Device() string {
+	var browser, system string
+	for _, b := range userAgentBrowsers {
+		if strings.Contains(s.UserAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, o := range userAgentSystems {
+		if strings.Contains(s.UserAgent, o.token) {
+			system = o.name
+			break
+		}
+	}
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	}
+	return system
+}
@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestNewUserSession(t *testing.T) {
+	session := NewUserSession("user1", strings.Repeat("ø", maxUserAgentLength+1), "10.0.0.1")
+	testutils.AssertNil(t, session.Validate())
+	testutils.AssertEqual(t, len([]rune(session.UserAgent)), maxUserAgentLength)
+	testutils.AssertEqual(t, session.CreatedAt, session.LastSeenAt)
+	testutils.AssertEqual(t, session.Id != NewUserSession("user1", "", "").Id, true)
+
+	err := (&UserSession{Id: "session"}).Validate()
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidUserSession), true)
+}
+
+func assertSessionRegistry(t *testing.T, store SessionRegistry) {
+	ctx := context.Background()
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	laptop := &UserSession{Id: "laptop", UserId: "user1", UserAgent: "Firefox", IP: "10.0.0.1", CreatedAt: start, LastSeenAt: start}
+	phone := &UserSession{Id: "phone", UserId: "user1", UserAgent: "Safari", CreatedAt: start, LastSeenAt: start.Add(time.Hour)}
+	other := &UserSession{Id: "other", UserId: "user10", CreatedAt: start, LastSeenAt: start}
+	for _, session := range []*UserSession{laptop, phone, other} {
+		testutils.AssertNil(t, store.RegisterSession(ctx, session))
+	}
+	err := store.RegisterSession(ctx, &UserSession{Id: "invalid"})
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidUserSession), true)
+
+	found, err := store.Session(ctx, "laptop")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, found.UserId, "user1")
+	testutils.AssertEqual(t, found.UserAgent, "Firefox")
+	testutils.AssertEqual(t, found.IP, "10.0.0.1")
+
+	_, err = store.Session(ctx, "unknown")
+	testutils.AssertEqual(t, errors.Is(err, ErrUserSessionNotFound), true)
+
+	// Registering again updates the time the session was last seen
+	laptop.LastSeenAt = start.Add(2 * time.Hour)
+	testutils.AssertNil(t, store.RegisterSession(ctx, laptop))
+
+	sessions, err := store.Sessions(ctx, "user1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(sessions), 2)
+	testutils.AssertEqual(t, sessions[0].Id, "laptop")
+	testutils.AssertEqual(t, sessions[1].Id, "phone")
+	testutils.AssertEqual(t, sessions[0].LastSeenAt.Equal(laptop.LastSeenAt), true)
+
+	err = store.RevokeSession(ctx, "user10", "laptop")
+	testutils.AssertEqual(t, errors.Is(err, ErrUserSessionNotFound), true)
+	testutils.AssertNil(t, store.RevokeSession(ctx, "user1", "laptop"))
+	err = store.RevokeSession(ctx, "user1", "laptop")
+	testutils.AssertEqual(t, errors.Is(err, ErrUserSessionNotFound), true)
+
+	_, err = store.Session(ctx, "laptop")
+	testutils.AssertEqual(t, errors.Is(err, ErrUserSessionNotFound), true)
+
+	sessions, err = store.Sessions(ctx, "unknown")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(sessions), 0)
+
+	generation, err := store.SessionGeneration(ctx, "user1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, generation, int64(0))
+
+	newGeneration, err := store.NewSessionGeneration(ctx, "user1")
+	testutils.AssertNil(t, err)
+	generation, err = store.SessionGeneration(ctx, "user1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, generation, newGeneration)
+	testutils.AssertEqual(t, generation > 0, true)
+}
+
+func TestInMemorySessionRegistry(t *testing.T) {
+	assertSessionRegistry(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGoogleSessionRegistry(t *testing.T) {
+	assertSessionRegistry(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
+
+func TestUserSessionDevice(t *testing.T) {
+	for _, test := range []struct{ userAgent, want string }{
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0", "Firefox on Linux"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36 Edg/126.0", "Edge on Windows"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1", "Safari on iOS"},
+		{"Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Mobile Safari/537.36", "Chrome on Android"},
+		{"curl/8.5.0", ""},
+	} {
+		session := UserSession{UserAgent: test.userAgent}
+		testutils.AssertEqual(t, session.Device(), test.want)
+	}
+}
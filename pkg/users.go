@@ -8,6 +8,7 @@ import (
 	"math/rand"
 	"net/http"
 	"reflect"
+	"time"
 
 	"github.com/davidkleiven/caesura/utils"
 	"github.com/gorilla/sessions"
@@ -66,6 +67,8 @@ func (u *UserInfo) ToFlat() *FlatUser {
 			Role:    role,
 			Groups:  groups,
 			Deleted: false,
+			Name:    u.Name,
+			Email:   u.Email,
 		}
 		orgLinks = append(orgLinks, orgLink)
 	}
@@ -82,7 +85,7 @@ func (u UserInfo) Generate(r *rand.Rand, size int) reflect.Value {
 	groups := make([]string, n)
 	ids := make([]string, n)
 	emails := make([]string, n)
-	roles := []RoleKind{RoleViewer, RoleEditor, RoleAdmin}
+	roles := []RoleKind{RoleViewer, RoleEditor, RoleAdmin, RoleLibrarian}
 	for i := range n {
 		names[i] = fmt.Sprintf("name%d", i)
 		orgIds[i] = fmt.Sprintf("org%d", i)
@@ -142,21 +145,49 @@ func NewUserFromFlat(flatUser *FlatUser) *UserInfo {
 }
 
 type Organization struct {
-	Id        string `json:"id" firestore:"id"`
-	Name      string `json:"name" firestore:"name"`
-	Deleted   bool   `json:"deleted" firestore:"deleted"`
-	NumScores int    `json:"numScores" firestore:"numScores"`
-	StripeId  string `json:"stripeId" firestore:"stripeId"`
+	Id        string   `json:"id" firestore:"id"`
+	Name      string   `json:"name" firestore:"name"`
+	Deleted   bool     `json:"deleted" firestore:"deleted"`
+	NumScores int      `json:"numScores" firestore:"numScores"`
+	StripeId  string   `json:"stripeId" firestore:"stripeId"`
+	Branding  Branding `json:"branding" firestore:"branding"`
+	Domain    string   `json:"domain" firestore:"domain"`
+
+	// Members must sign in with a passkey to access the organization
+	RequirePasskey bool `json:"requirePasskey" firestore:"requirePasskey"`
 }
 
 type RoleKind int
 
+// Roles are stored by value, so new roles are appended. Use AtLeast to compare roles, since the values do not
+// follow the order of the privileges
 const (
 	RoleViewer = iota
 	RoleEditor
 	RoleAdmin
+
+	// Librarians upload and manage resources, but do not manage members or billing
+	RoleLibrarian
 )
 
+var roleRanks = map[RoleKind]int{
+	RoleViewer:    0,
+	RoleEditor:    1,
+	RoleLibrarian: 2,
+	RoleAdmin:     3,
+}
+
+// AtLeast reports whether the role has all the privileges of minimum. Unknown roles have no privileges
+func (r RoleKind) AtLeast(minimum RoleKind) bool {
+	rank, ok := roleRanks[r]
+	return ok && rank >= roleRanks[minimum]
+}
+
+func (r RoleKind) Valid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
 type UserRegisterer interface {
 	RegisterUser(ctx context.Context, userInfo *UserInfo) error
 }
@@ -170,6 +201,8 @@ type RoleRegisterer interface {
 }
 
 type UserInOrgGetter interface {
+	// GetUsersInOrg returns the members of the organization. Roles and groups in other organizations
+	// may be left out
 	GetUsersInOrg(ctx context.Context, orgId string) ([]UserInfo, error)
 }
 
@@ -192,6 +225,12 @@ type BasicAuthRoleStore interface {
 	RoleStore
 	UserByEmailGetter
 	BasicAuthPasswordResetter
+	EmailVerifier
+}
+
+type EmailVerifier interface {
+	// MarkEmailVerified records that the user has confirmed that the email address belongs to them
+	MarkEmailVerified(ctx context.Context, userId string) error
 }
 
 type BasicAuthUserRegisterer interface {
@@ -226,8 +265,17 @@ type GroupStore interface {
 	RemoveGroup(ctx context.Context, userId, orgId, group string) error
 }
 
+type OrganizationLister interface {
+	ListOrganizations(ctx context.Context) ([]Organization, error)
+}
+
 type OrganizationStore interface {
 	OrganizationGetter
+	OrganizationLister
+	BrandingUpdater
+	DomainStore
+	PasskeyRequirementUpdater
+	OnboardingStore
 	OrganizationRegisterer
 	OrganizationDeleter
 	UserInOrgGetter
@@ -238,6 +286,7 @@ type IAMStore interface {
 	OrganizationStore
 	UserGetter
 	GroupStore
+	PermissionsVersionGetter
 }
 
 func GetUserOrRegisterNewUser(store RoleStore, ctx context.Context, info *UserInfo) (*UserInfo, error) {
@@ -299,6 +348,36 @@ func (u *UserRolePipeline) AssignViewRoleIfNoRole(orgId string) *UserRolePipelin
 	return u
 }
 
+// AcceptInvitation gives the user the role and group of an invitation sent by email. Nothing is done when id is
+// empty, which is the case for invite links
+func (u *UserRolePipeline) AcceptInvitation(orgId, id string, now time.Time) *UserRolePipeline {
+	if u.Error != nil || id == "" {
+		return u
+	}
+	store, ok := u.store.(InvitationAcceptStore)
+	if !ok {
+		u.Error = errors.New("store can not accept invitations")
+		return u
+	}
+	u.Error = AcceptInvitation(u.ctx, store, orgId, id, u.User, now)
+	return u
+}
+
+// CheckInviteLink stops the pipeline when the invite link is revoked. Nothing is done when id is empty, which is
+// the case for invitations sent by email and for links signed before links were stored
+func (u *UserRolePipeline) CheckInviteLink(orgId, id string) *UserRolePipeline {
+	if u.Error != nil || id == "" {
+		return u
+	}
+	store, ok := u.store.(InviteLinkStore)
+	if !ok {
+		u.Error = errors.New("store can not check invite links")
+		return u
+	}
+	u.Error = CheckInviteLink(u.ctx, store, orgId, id)
+	return u
+}
+
 type FailingRoleStore struct {
 	ErrRegisterUser   error
 	ErrRegisterRole   error
@@ -364,6 +443,16 @@ func (r *RegisterOrganizationFlow) RetrieveUserInfo(userId string) *RegisterOrga
 	return r
 }
 
+// StartOnboarding stores the progress of the onboarding wizard such that it is shown to the admins
+func (r *RegisterOrganizationFlow) StartOnboarding(orgId string) *RegisterOrganizationFlow {
+	if r.Error != nil {
+		return r
+	}
+
+	r.Error = r.store.SaveOnboarding(r.ctx, orgId, NewOnboarding())
+	return r
+}
+
 func (r *RegisterOrganizationFlow) UpdateSession(req *http.Request, w http.ResponseWriter, orgId string) *RegisterOrganizationFlow {
 	if r.Error != nil {
 		return r
@@ -398,12 +487,36 @@ type User struct {
 	Password      string `firestore:"password"`
 }
 
+// UserOrganizationLink holds the membership of a user in an organization. Name and email are copies of
+// the user such that the members of an organization can be listed without fetching each user
 type UserOrganizationLink struct {
 	UserId  string   `firestore:"userId"`
 	OrgId   string   `firestore:"orgId"`
 	Deleted bool     `firestore:"deleted"`
 	Role    RoleKind `firestore:"role"`
 	Groups  []string `firestore:"groups"`
+	Name    string   `firestore:"name"`
+	Email   string   `firestore:"email"`
+}
+
+func (l *UserOrganizationLink) hasSnapshot() bool {
+	return l.Name != "" || l.Email != ""
+}
+
+// Member returns the user as seen from the organization of the link. Only the role and the groups
+// within that organization are populated
+func (l *UserOrganizationLink) Member() UserInfo {
+	groups := l.Groups
+	if groups == nil {
+		groups = []string{}
+	}
+	return UserInfo{
+		Id:     l.UserId,
+		Name:   l.Name,
+		Email:  l.Email,
+		Roles:  map[string]RoleKind{l.OrgId: l.Role},
+		Groups: map[string][]string{l.OrgId: groups},
+	}
 }
 
 type FlatUser struct {
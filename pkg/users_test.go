@@ -32,6 +32,24 @@ func TestRolesAreAssignableWhenMissing(t *testing.T) {
 	info.Roles["someOrg"] = RoleAdmin
 }
 
+func TestRoleAtLeast(t *testing.T) {
+	for _, test := range []struct {
+		role    RoleKind
+		minimum RoleKind
+		want    bool
+	}{
+		{role: RoleViewer, minimum: RoleViewer, want: true},
+		{role: RoleEditor, minimum: RoleLibrarian, want: false},
+		{role: RoleLibrarian, minimum: RoleEditor, want: true},
+		{role: RoleLibrarian, minimum: RoleLibrarian, want: true},
+		{role: RoleLibrarian, minimum: RoleAdmin, want: false},
+		{role: RoleAdmin, minimum: RoleLibrarian, want: true},
+		{role: RoleKind(10), minimum: RoleViewer, want: false},
+	} {
+		testutils.AssertEqual(t, test.role.AtLeast(test.minimum), test.want)
+	}
+}
+
 func TestGetOrRegisterNewUser(t *testing.T) {
 	store := NewMultiOrgInMemoryStore()
 	newUser := NewUserInfo()
@@ -197,10 +215,11 @@ func TestRegisterOrganizationFlow(t *testing.T) {
 
 	store.RegisterUser(context.Background(), &user)
 	registrationFlow := NewRegisterOrganizationFlow(context.Background(), store, session)
-	registrationFlow.Register(&org).RegisterAdmin(user.Id, org.Id).RetrieveUserInfo(user.Id).UpdateSession(r, w, org.Id)
+	registrationFlow.Register(&org).RegisterAdmin(user.Id, org.Id).StartOnboarding(org.Id).RetrieveUserInfo(user.Id).UpdateSession(r, w, org.Id)
 	testutils.AssertEqual(t, org.Id, session.Values["orgId"].(string))
 	testutils.AssertEqual(t, store.Users[0].Roles[org.Id], RoleAdmin)
 	testutils.AssertEqual(t, utils.Must(store.GetUserInfo(context.Background(), user.Id)).Roles[org.Id], RoleAdmin)
+	testutils.AssertEqual(t, len(utils.Must(store.Onboarding(context.Background(), org.Id)).Completed), 0)
 }
 
 func TestOrganizationFlowAbortedOnError(t *testing.T) {
@@ -251,3 +270,28 @@ func TestFlatUserRoundTrip(t *testing.T) {
 	}
 	testutils.AssertNil(t, quick.Check(property, nil))
 }
+
+func assertEmailVerifier(t *testing.T, store interface {
+	EmailVerifier
+	UserRegisterer
+	RoleGetter
+}) {
+	ctx := context.Background()
+	testutils.AssertNil(t, store.RegisterUser(ctx, &UserInfo{Id: "user1", Email: "kari@example.com"}))
+	testutils.AssertNil(t, store.MarkEmailVerified(ctx, "user1"))
+
+	user, err := store.GetUserInfo(ctx, "user1")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, user.VerifiedEmail, true)
+
+	err = store.MarkEmailVerified(ctx, "unknown")
+	testutils.AssertEqual(t, errors.Is(err, ErrUserNotFound), true)
+}
+
+func TestInMemoryMarkEmailVerified(t *testing.T) {
+	assertEmailVerifier(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGoogleMarkEmailVerified(t *testing.T) {
+	assertEmailVerifier(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
@@ -0,0 +1,272 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// Previous versions are kept outside the prefix of the resource, such that listing the resource only gives the
+// current parts. Resource ids never contain a dot
+const versionsDir = ".versions"
+
+// ResourceVersion is an earlier upload of a resource. Versions are numbered from 1 in the order they were replaced
+type ResourceVersion struct {
+	Version    int       `json:"version"`
+	ArchivedAt time.Time `json:"archived_at"`
+	Files      []string  `json:"files"`
+}
+
+type ResourceVersioner interface {
+	ResourceVersions(ctx context.Context, orgId string, resourceId string) ([]ResourceVersion, error)
+	ResourceVersion(ctx context.Context, orgId string, resourceId string, version int) iter.Seq2[string, []byte]
+
+	// RestoreVersion makes an earlier version the current one. The current parts are kept as a new version
+	RestoreVersion(ctx context.Context, orgId string, resourceId string, version int) error
+}
+
+// replaceable reports whether the resource exists, and returns an error if it may not be replaced
+func replaceable(meta *MetaData, err error, resourceId string) (bool, error) {
+	if errors.Is(err, ErrResourceMetadataNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if meta.Protected {
+		return true, errors.Join(ErrResourceProtected, fmt.Errorf("resource id: %s", resourceId))
+	}
+	return true, nil
+}
+
+func findVersion(versions []ResourceVersion, resourceId string, version int) (*ResourceVersion, error) {
+	idx := slices.IndexFunc(versions, func(v ResourceVersion) bool { return v.Version == version })
+	if idx < 0 {
+		return nil, errors.Join(ErrVersionNotFound, fmt.Errorf("resource id: %s version: %d", resourceId, version))
+	}
+	return &versions[idx], nil
+}
+
+func (g *GoogleStore) versionPrefix(orgId, resourceId string, version int) string {
+	return path.Join(orgId, versionsDir, resourceId, strconv.Itoa(version)) + "/"
+}
+
+func (g *GoogleStore) listObjects(ctx context.Context, prefix string) ([]*storage.ObjectAttrs, error) {
+	objects := g.BucketClient.GetObjects(ctx, g.Config.Bucket, &storage.Query{Prefix: prefix})
+	var result []*storage.ObjectAttrs
+	for {
+		attrs, err := objects.Next()
+		if errors.Is(err, iterator.Done) {
+			return result, nil
+		}
+		if err != nil {
+			return result, classifyStoreErr(err, ErrResourceNotFound)
+		}
+		result = append(result, attrs)
+	}
+}
+
+func (g *GoogleStore) ResourceVersions(ctx context.Context, orgId, resourceId string) ([]ResourceVersion, error) {
+	prefix := path.Join(orgId, versionsDir, resourceId) + "/"
+	objects, err := g.listObjects(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*ResourceVersion)
+	for _, attrs := range objects {
+		number, name, ok := strings.Cut(strings.TrimPrefix(attrs.Name, prefix), "/")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil {
+			continue
+		}
+		if _, ok := byVersion[version]; !ok {
+			byVersion[version] = &ResourceVersion{Version: version}
+		}
+		entry := byVersion[version]
+		entry.Files = append(entry.Files, name)
+		if attrs.Updated.After(entry.ArchivedAt) {
+			entry.ArchivedAt = attrs.Updated
+		}
+	}
+
+	versions := make([]ResourceVersion, 0, len(byVersion))
+	for _, number := range slices.Sorted(maps.Keys(byVersion)) {
+		slices.Sort(byVersion[number].Files)
+		versions = append(versions, *byVersion[number])
+	}
+	return versions, nil
+}
+
+func (g *GoogleStore) ResourceVersion(ctx context.Context, orgId, resourceId string, version int) iter.Seq2[string, []byte] {
+	return g.contentByPrefix(ctx, g.versionPrefix(orgId, resourceId, version))
+}
+
+// archiveResource copies the current parts of a resource into a new version and returns the current parts. The
+// parts are copied rather than moved, since uploads to an existing resource add parts to it. Nothing is archived
+// when the resource has no parts
+func (g *GoogleStore) archiveResource(ctx context.Context, orgId, resourceId string) ([]*storage.ObjectAttrs, error) {
+	current, err := g.listObjects(ctx, path.Join(orgId, resourceId)+"/")
+	if err != nil || len(current) == 0 {
+		return current, err
+	}
+
+	versions, err := g.ResourceVersions(ctx, orgId, resourceId)
+	if err != nil {
+		return current, err
+	}
+	next := 1
+	if len(versions) > 0 {
+		next = versions[len(versions)-1].Version + 1
+	}
+
+	prefix := g.versionPrefix(orgId, resourceId, next)
+	for _, attrs := range current {
+		content, err := g.Item(ctx, attrs.Name)
+		if err != nil {
+			return current, err
+		}
+		if err := g.BucketClient.Upload(ctx, g.Config.Bucket, prefix+path.Base(attrs.Name), content); err != nil {
+			return current, err
+		}
+	}
+	return current, nil
+}
+
+// restoreVersion archives the current parts and replaces them by the parts of version
+func (g *GoogleStore) restoreVersion(ctx context.Context, orgId, resourceId string, version int) error {
+	versions, err := g.ResourceVersions(ctx, orgId, resourceId)
+	if err != nil {
+		return err
+	}
+	if _, err := findVersion(versions, resourceId, version); err != nil {
+		return err
+	}
+
+	// Read before archiving, such that a failing read leaves the current parts untouched
+	files := make(map[string][]byte)
+	for name, content := range g.ResourceVersion(ctx, orgId, resourceId, version) {
+		files[name] = content
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	current, err := g.archiveResource(ctx, orgId, resourceId)
+	if err != nil {
+		return err
+	}
+	if err := g.deleteObjects(ctx, current); err != nil {
+		return err
+	}
+	for name, content := range files {
+		if err := g.BucketClient.Upload(ctx, g.Config.Bucket, g.objectName(orgId, resourceId, name), content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *GoogleStore) RestoreVersion(ctx context.Context, orgId, resourceId string, version int) error {
+	meta, err := g.MetaById(ctx, orgId, resourceId)
+	if err != nil {
+		return err
+	}
+	if _, err := replaceable(meta, nil, resourceId); err != nil {
+		return err
+	}
+	if err := g.clearContentHash(ctx, orgId, resourceId); err != nil {
+		return err
+	}
+	return g.restoreVersion(ctx, orgId, resourceId, version)
+}
+
+// InMemoryVersion holds the parts of an earlier version of a resource
+type InMemoryVersion struct {
+	ArchivedAt time.Time
+	Data       map[string][]byte
+}
+
+// archiveResource copies the current parts of a resource into a new version
+func (s *InMemoryStore) archiveResource(id string) {
+	prefix := id + "/"
+	version := InMemoryVersion{ArchivedAt: time.Now(), Data: make(map[string][]byte)}
+	for name, content := range s.Data {
+		if strings.HasPrefix(name, prefix) {
+			version.Data[strings.TrimPrefix(name, prefix)] = content
+		}
+	}
+
+	if len(version.Data) == 0 {
+		return
+	}
+	if s.Versions == nil {
+		s.Versions = make(map[string][]InMemoryVersion)
+	}
+	s.Versions[id] = append(s.Versions[id], version)
+}
+
+func (s *InMemoryStore) ResourceVersions(ctx context.Context, id string) ([]ResourceVersion, error) {
+	versions := make([]ResourceVersion, len(s.Versions[id]))
+	for i, version := range s.Versions[id] {
+		versions[i] = ResourceVersion{
+			Version:    i + 1,
+			ArchivedAt: version.ArchivedAt,
+			Files:      slices.Sorted(maps.Keys(version.Data)),
+		}
+	}
+	return versions, nil
+}
+
+func (s *InMemoryStore) ResourceVersion(ctx context.Context, id string, version int) iter.Seq2[string, []byte] {
+	return func(yield func(string, []byte) bool) {
+		if version < 1 || version > len(s.Versions[id]) {
+			return
+		}
+		for name, content := range s.Versions[id][version-1].Data {
+			if !yield(name, content) {
+				return
+			}
+		}
+	}
+}
+
+func (s *InMemoryStore) RestoreVersion(ctx context.Context, id string, version int) error {
+	meta, err := s.MetaById(ctx, id)
+	if err != nil {
+		return err
+	}
+	if meta.Protected {
+		return errors.Join(ErrResourceProtected, fmt.Errorf("resource id: %s", id))
+	}
+	if version < 1 || version > len(s.Versions[id]) {
+		return errors.Join(ErrVersionNotFound, fmt.Errorf("resource id: %s version: %d", id, version))
+	}
+
+	restored := s.Versions[id][version-1].Data
+	s.setContentHash(id, "")
+	s.archiveResource(id)
+	for name := range s.Data {
+		if strings.HasPrefix(name, id+"/") {
+			delete(s.Data, name)
+			delete(s.Modified, name)
+		}
+	}
+	if s.Modified == nil {
+		s.Modified = make(map[string]time.Time)
+	}
+	for name, content := range restored {
+		s.Data[id+"/"+name] = content
+		s.Modified[id+"/"+name] = time.Now()
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func fluteParts(yield func(string, []byte) bool) {
+	yield("Flute.pdf", []byte("Flute v2"))
+}
+
+func TestGoogleStoreVersions(t *testing.T) {
+	store := GoogleStore{
+		FsClient:     NewLocalFirestoreClient(),
+		BucketClient: &FileBucketClient{Directory: t.TempDir()},
+		Config:       &GoogleConfig{Bucket: "scores"},
+	}
+	ctx := context.Background()
+	meta := MetaData{Title: "Polka"}
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, manifestParts))
+	resourceId := meta.ResourceId()
+
+	versions, err := store.ResourceVersions(ctx, "org", resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(versions), 0)
+
+	testutils.AssertNil(t, store.Submit(ctx, "org", &meta, fluteParts))
+	versions, err = store.ResourceVersions(ctx, "org", resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(versions), 1)
+	testutils.AssertEqual(t, versions[0].Version, 1)
+	testutils.AssertEqual(t, slices.Equal(versions[0].Files, []string{"Horn.pdf", "Trumpet.pdf"}), true)
+
+	content := maps.Collect(store.ResourceVersion(ctx, "org", resourceId, 1))
+	testutils.AssertEqual(t, string(content["Horn.pdf"]), "Horn.pdf")
+
+	// Versions are not part of the current resource
+	manifest, err := store.ResourceManifest(ctx, "org", resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(manifest.Files), 3)
+
+	testutils.AssertNil(t, store.RestoreVersion(ctx, "org", resourceId, 1))
+	current := maps.Collect(store.Resource(ctx, "org", resourceId))
+	testutils.AssertEqual(t, slices.Equal(slices.Sorted(maps.Keys(current)), []string{"Horn.pdf", "Trumpet.pdf"}), true)
+
+	versions, err = store.ResourceVersions(ctx, "org", resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(versions), 2)
+	testutils.AssertEqual(t, slices.Contains(versions[1].Files, "Flute.pdf"), true)
+
+	err = store.RestoreVersion(ctx, "org", resourceId, 5)
+	testutils.AssertEqual(t, errors.Is(err, ErrVersionNotFound), true)
+
+	testutils.AssertNil(t, store.DeleteResource(ctx, "org", resourceId))
+	testutils.AssertNil(t, store.PurgeResource(ctx, "org", resourceId))
+	versions, err = store.ResourceVersions(ctx, "org", resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(versions), 0)
+}
+
+func TestInMemoryStoreVersions(t *testing.T) {
+	store := NewInMemoryStore()
+	ctx := context.Background()
+	meta := MetaData{Title: "Polka"}
+	testutils.AssertNil(t, store.Submit(ctx, &meta, manifestParts))
+	testutils.AssertNil(t, store.Submit(ctx, &meta, fluteParts))
+	resourceId := meta.ResourceId()
+
+	versions, err := store.ResourceVersions(ctx, resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(versions), 1)
+	testutils.AssertEqual(t, slices.Equal(versions[0].Files, []string{"Horn.pdf", "Trumpet.pdf"}), true)
+	testutils.AssertEqual(t, len(maps.Collect(store.ResourceVersion(ctx, resourceId, 1))), 2)
+	testutils.AssertEqual(t, len(maps.Collect(store.ResourceVersion(ctx, resourceId, 2))), 0)
+
+	testutils.AssertNil(t, store.RestoreVersion(ctx, resourceId, 1))
+	current := maps.Collect(store.Resource(ctx, resourceId))
+	testutils.AssertEqual(t, len(current), 2)
+	_, hasFlute := current["Flute.pdf"]
+	testutils.AssertEqual(t, hasFlute, false)
+
+	versions, err = store.ResourceVersions(ctx, resourceId)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(versions), 2)
+
+	t.Run("unknown version", func(t *testing.T) {
+		err := store.RestoreVersion(ctx, resourceId, 3)
+		testutils.AssertEqual(t, errors.Is(err, ErrVersionNotFound), true)
+	})
+
+	t.Run("protected", func(t *testing.T) {
+		store.Metadata[0].Protected = true
+		defer func() { store.Metadata[0].Protected = false }()
+		err := store.RestoreVersion(ctx, resourceId, 1)
+		testutils.AssertEqual(t, errors.Is(err, ErrResourceProtected), true)
+	})
+}
@@ -0,0 +1,265 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+type LibraryStore interface {
+	MetaByPatternFetcher
+	ResourceManifestGetter
+	ItemGetter
+}
+
+// LibraryFS exposes the library of an organization as a read-only WebDAV file system. The root contains one
+// directory per resource, and each directory contains the files of the resource accepted by Include. A
+// LibraryFS caches the listings, and should therefore only be used for a single request
+type LibraryFS struct {
+	Store   LibraryStore
+	OrgId   string
+	Include func(string) bool
+
+	mu        sync.Mutex
+	manifests map[string]*ResourceManifest
+}
+
+func NewLibraryFS(store LibraryStore, orgId string, include func(string) bool) *LibraryFS {
+	return &LibraryFS{Store: store, OrgId: orgId, Include: include, manifests: make(map[string]*ResourceManifest)}
+}
+
+func (l *LibraryFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return os.ErrPermission
+}
+
+func (l *LibraryFS) RemoveAll(ctx context.Context, name string) error {
+	return os.ErrPermission
+}
+
+func (l *LibraryFS) Rename(ctx context.Context, oldName, newName string) error {
+	return os.ErrPermission
+}
+
+func (l *LibraryFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, os.ErrPermission
+	}
+
+	info, err := l.Stat(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	resourceId, fileName := splitLibraryPath(name)
+	if info.IsDir() {
+		return &libraryDir{ctx: ctx, fs: l, resourceId: resourceId, info: info}, nil
+	}
+	return &libraryFile{ctx: ctx, fs: l, path: path.Join(l.OrgId, resourceId, fileName), info: info}, nil
+}
+
+func (l *LibraryFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	resourceId, fileName := splitLibraryPath(name)
+	if strings.Contains(fileName, "/") {
+		return nil, os.ErrNotExist
+	}
+	if resourceId == "" {
+		return &libraryFileInfo{name: "/", dir: true}, nil
+	}
+
+	manifest, err := l.manifest(ctx, resourceId)
+	if err != nil {
+		return nil, err
+	}
+	if fileName == "" {
+		return &libraryFileInfo{name: resourceId, dir: true}, nil
+	}
+
+	for _, entry := range manifest.Files {
+		if entry.Name == fileName && l.Include(entry.Name) {
+			return newLibraryFileInfo(&entry), nil
+		}
+	}
+	return nil, os.ErrNotExist
+}
+
+func (l *LibraryFS) manifest(ctx context.Context, resourceId string) (*ResourceManifest, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if manifest, ok := l.manifests[resourceId]; ok {
+		return manifest, nil
+	}
+
+	manifest, err := l.Store.ResourceManifest(ctx, l.OrgId, resourceId)
+	if errors.Is(err, ErrResourceMetadataNotFound) || errors.Is(err, ErrResourceNotFound) {
+		return nil, os.ErrNotExist
+	} else if err != nil {
+		return nil, err
+	}
+	l.manifests[resourceId] = manifest
+	return manifest, nil
+}
+
+func (l *LibraryFS) readDir(ctx context.Context, resourceId string) ([]fs.FileInfo, error) {
+	if resourceId == "" {
+		metas, err := l.Store.MetaByPattern(ctx, l.OrgId, &MetaData{})
+		if err != nil {
+			return nil, err
+		}
+		infos := make([]fs.FileInfo, 0, len(metas))
+		for _, meta := range metas {
+			if !meta.Deleted {
+				infos = append(infos, &libraryFileInfo{name: meta.ResourceId(), dir: true})
+			}
+		}
+		slices.SortFunc(infos, func(a, b fs.FileInfo) int { return strings.Compare(a.Name(), b.Name()) })
+		return infos, nil
+	}
+
+	manifest, err := l.manifest(ctx, resourceId)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]fs.FileInfo, 0, len(manifest.Files))
+	for _, entry := range manifest.Files {
+		if l.Include(entry.Name) {
+			infos = append(infos, newLibraryFileInfo(&entry))
+		}
+	}
+	return infos, nil
+}
+
+// splitLibraryPath splits name into the resource id and the name of the file within the resource
+func splitLibraryPath(name string) (string, string) {
+	name = strings.Trim(path.Clean("/"+name), "/")
+	resourceId, fileName, _ := strings.Cut(name, "/")
+	return resourceId, fileName
+}
+
+type libraryFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	md5     string
+	dir     bool
+}
+
+func newLibraryFileInfo(entry *ManifestEntry) *libraryFileInfo {
+	return &libraryFileInfo{name: entry.Name, size: entry.Size, modTime: entry.LastModified, md5: entry.MD5}
+}
+
+func (i *libraryFileInfo) Name() string       { return i.name }
+func (i *libraryFileInfo) Size() int64        { return i.size }
+func (i *libraryFileInfo) ModTime() time.Time { return i.modTime }
+func (i *libraryFileInfo) IsDir() bool        { return i.dir }
+func (i *libraryFileInfo) Sys() any           { return nil }
+
+func (i *libraryFileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+// ContentType avoids that the WebDAV handler reads the beginning of every file in a listing
+func (i *libraryFileInfo) ContentType(ctx context.Context) (string, error) {
+	if contentType := mime.TypeByExtension(path.Ext(i.name)); contentType != "" {
+		return contentType, nil
+	}
+	return "application/octet-stream", nil
+}
+
+func (i *libraryFileInfo) ETag(ctx context.Context) (string, error) {
+	if i.md5 == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return `"` + i.md5 + `"`, nil
+}
+
+type libraryDir struct {
+	ctx        context.Context
+	fs         *LibraryFS
+	resourceId string
+	info       fs.FileInfo
+	entries    []fs.FileInfo
+	listed     bool
+}
+
+func (d *libraryDir) Close() error                                 { return nil }
+func (d *libraryDir) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (d *libraryDir) Write(p []byte) (int, error)                  { return 0, os.ErrPermission }
+func (d *libraryDir) Seek(offset int64, whence int) (int64, error) { return 0, os.ErrInvalid }
+func (d *libraryDir) Stat() (fs.FileInfo, error)                   { return d.info, nil }
+
+func (d *libraryDir) Readdir(count int) ([]fs.FileInfo, error) {
+	if !d.listed {
+		entries, err := d.fs.readDir(d.ctx, d.resourceId)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = entries
+		d.listed = true
+	}
+
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n := min(count, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// libraryFile fetches the content on the first read, such that listings do not download the files
+type libraryFile struct {
+	ctx    context.Context
+	fs     *LibraryFS
+	path   string
+	info   fs.FileInfo
+	reader *bytes.Reader
+}
+
+func (f *libraryFile) Close() error                             { return nil }
+func (f *libraryFile) Write(p []byte) (int, error)              { return 0, os.ErrPermission }
+func (f *libraryFile) Readdir(count int) ([]fs.FileInfo, error) { return nil, os.ErrInvalid }
+func (f *libraryFile) Stat() (fs.FileInfo, error)               { return f.info, nil }
+
+func (f *libraryFile) Read(p []byte) (int, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.reader.Read(p)
+}
+
+func (f *libraryFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.reader.Seek(offset, whence)
+}
+
+func (f *libraryFile) load() error {
+	if f.reader != nil {
+		return nil
+	}
+	content, err := f.fs.Store.Item(f.ctx, f.path)
+	if err != nil {
+		return err
+	}
+	f.reader = bytes.NewReader(content)
+	return nil
+}
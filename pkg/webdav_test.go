@@ -0,0 +1,80 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func newTestLibraryFS(t *testing.T, include func(string) bool) (*LibraryFS, string) {
+	store := NewMultiOrgInMemoryStore()
+	store.Data["org"] = NewInMemoryStore()
+	meta := MetaData{Title: "Polka"}
+	testutils.AssertNil(t, store.Submit(context.Background(), "org", &meta, manifestParts))
+
+	deleted := MetaData{Title: "Waltz", Deleted: true}
+	testutils.AssertNil(t, store.Submit(context.Background(), "org", &deleted, manifestParts))
+	return NewLibraryFS(store, "org", include), meta.ResourceId()
+}
+
+func readDirNames(t *testing.T, fs *LibraryFS, name string) string {
+	dir, err := fs.OpenFile(context.Background(), name, os.O_RDONLY, 0)
+	testutils.AssertNil(t, err)
+	defer dir.Close()
+	infos, err := dir.Readdir(0)
+	testutils.AssertNil(t, err)
+
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+func TestLibraryFSListing(t *testing.T) {
+	fs, resourceId := newTestLibraryFS(t, IncludeAll)
+	testutils.AssertEqual(t, readDirNames(t, fs, "/"), resourceId)
+	testutils.AssertEqual(t, readDirNames(t, fs, "/"+resourceId+"/"), "Horn.pdf,Trumpet.pdf")
+
+	info, err := fs.Stat(context.Background(), "/"+resourceId+"/Horn.pdf")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, info.Size(), int64(len("Horn.pdf")))
+	testutils.AssertEqual(t, info.IsDir(), false)
+
+	file, err := fs.OpenFile(context.Background(), "/"+resourceId+"/Horn.pdf", os.O_RDONLY, 0)
+	testutils.AssertNil(t, err)
+	content, err := io.ReadAll(file)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, string(content), "Horn.pdf")
+}
+
+func TestLibraryFSGroupFilter(t *testing.T) {
+	fs, resourceId := newTestLibraryFS(t, MatchAny([]string{"horn"}))
+	testutils.AssertEqual(t, readDirNames(t, fs, "/"+resourceId), "Horn.pdf")
+
+	_, err := fs.Stat(context.Background(), "/"+resourceId+"/Trumpet.pdf")
+	testutils.AssertEqual(t, errors.Is(err, os.ErrNotExist), true)
+}
+
+func TestLibraryFSNotFound(t *testing.T) {
+	fs, resourceId := newTestLibraryFS(t, IncludeAll)
+	for _, name := range []string{"/missing", "/waltz", "/" + resourceId + "/missing.pdf", "/" + resourceId + "/Horn.pdf/nested"} {
+		_, err := fs.Stat(context.Background(), name)
+		testutils.AssertEqual(t, errors.Is(err, os.ErrNotExist), true)
+	}
+}
+
+func TestLibraryFSIsReadOnly(t *testing.T) {
+	fs, resourceId := newTestLibraryFS(t, IncludeAll)
+	ctx := context.Background()
+	_, err := fs.OpenFile(ctx, "/"+resourceId+"/Horn.pdf", os.O_RDWR, 0)
+	testutils.AssertEqual(t, errors.Is(err, os.ErrPermission), true)
+	testutils.AssertEqual(t, errors.Is(fs.Mkdir(ctx, "/new", 0o755), os.ErrPermission), true)
+	testutils.AssertEqual(t, errors.Is(fs.RemoveAll(ctx, "/"+resourceId), os.ErrPermission), true)
+	testutils.AssertEqual(t, errors.Is(fs.Rename(ctx, "/"+resourceId, "/other"), os.ErrPermission), true)
+}
@@ -0,0 +1,389 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	organizationWebhook       = "webhook"
+	webhookDeliveryCollection = "webhook_deliveries"
+)
+
+const (
+	WebhookSecretPrefix    = "whsec_"
+	WebhookSignatureHeader = "X-Caesura-Signature"
+	WebhookEventHeader     = "X-Caesura-Event"
+	WebhookDeliveryHeader  = "X-Caesura-Delivery"
+
+	maxWebhookUrlLength = 2048
+)
+
+// WebhookEvent is the name of an event as it is delivered to webhooks. The names are part of the public API and
+// must not change, even if the internal event kinds do
+type WebhookEvent string
+
+const (
+	WebhookResourceCreated WebhookEvent = "resource.created"
+	WebhookProjectUpdated  WebhookEvent = "project.updated"
+	WebhookUserInvited     WebhookEvent = "user.invited"
+	WebhookUserJoined      WebhookEvent = "user.joined"
+)
+
+// WebhookEvents are the events that can be delivered to webhooks
+var WebhookEvents = []WebhookEvent{WebhookResourceCreated, WebhookProjectUpdated, WebhookUserInvited, WebhookUserJoined}
+
+var webhookEventKinds = map[EventKind]WebhookEvent{
+	EventResourceUploaded: WebhookResourceCreated,
+	EventProjectUpdated:   WebhookProjectUpdated,
+	EventUserInvited:      WebhookUserInvited,
+	EventUserJoined:       WebhookUserJoined,
+}
+
+// DefaultWebhookBackoff is the wait before each retry of a failed delivery
+var DefaultWebhookBackoff = []time.Duration{10 * time.Second, time.Minute, 5 * time.Minute}
+
+// Webhook posts the events of an organization to a URL, such that other systems can react to them. Each delivery
+// is signed with the secret
+type Webhook struct {
+	OrgId  string `json:"orgId" firestore:"orgId"`
+	Url    string `json:"url" firestore:"url"`
+	Secret string `json:"-" firestore:"secret"`
+
+	// Events are the events that are delivered. Empty delivers every event
+	Events    []WebhookEvent `json:"events" firestore:"events"`
+	Enabled   bool           `json:"enabled" firestore:"enabled"`
+	UpdatedBy string         `json:"updatedBy" firestore:"updatedBy"`
+	UpdatedAt time.Time      `json:"updatedAt" firestore:"updatedAt"`
+}
+
+func (w *Webhook) Validate() error {
+	u, err := url.Parse(w.Url)
+	switch {
+	case w.OrgId == "":
+		return errors.Join(ErrInvalidWebhook, errors.New("webhook must belong to an organization"))
+	case err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "":
+		return errors.Join(ErrInvalidWebhook, fmt.Errorf("%q is not a http or https url", w.Url))
+	case len(w.Url) > maxWebhookUrlLength:
+		return errors.Join(ErrInvalidWebhook, fmt.Errorf("url can be at most %d characters", maxWebhookUrlLength))
+	case !strings.HasPrefix(w.Secret, WebhookSecretPrefix):
+		return errors.Join(ErrInvalidWebhook, errors.New("webhook must have a secret"))
+	}
+	for _, event := range w.Events {
+		if !slices.Contains(WebhookEvents, event) {
+			return errors.Join(ErrInvalidWebhook, fmt.Errorf("unknown event %q", event))
+		}
+	}
+	return nil
+}
+
+// Subscribes reports whether the event is delivered to the webhook
+func (w *Webhook) Subscribes(event WebhookEvent) bool {
+	return w.Enabled && (len(w.Events) == 0 || slices.Contains(w.Events, event))
+}
+
+// WebhookDelivery is the outcome of posting one event to a webhook, after all retries
+type WebhookDelivery struct {
+	Id       string       `json:"id" firestore:"id"`
+	OrgId    string       `json:"orgId" firestore:"orgId"`
+	Event    WebhookEvent `json:"event" firestore:"event"`
+	TargetId string       `json:"targetId" firestore:"targetId"`
+	Url      string       `json:"url" firestore:"url"`
+	Attempts int          `json:"attempts" firestore:"attempts"`
+
+	// StatusCode of the last attempt. Zero when no response was received
+	StatusCode int       `json:"statusCode" firestore:"statusCode"`
+	Error      string    `json:"error,omitempty" firestore:"error"`
+	Succeeded  bool      `json:"succeeded" firestore:"succeeded"`
+	CreatedAt  time.Time `json:"createdAt" firestore:"createdAt"`
+}
+
+// WebhookPayload is the body posted to a webhook
+type WebhookPayload struct {
+	Id             string       `json:"id"`
+	Event          WebhookEvent `json:"event"`
+	OrganizationId string       `json:"organizationId"`
+	UserId         string       `json:"userId,omitempty"`
+
+	// TargetId is the resource, project, invitation or user the event concerns
+	TargetId   string    `json:"targetId"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+type WebhookStore interface {
+	// SaveWebhook inserts or replaces the webhook of the organization
+	SaveWebhook(ctx context.Context, webhook *Webhook) error
+
+	// Webhook returns ErrWebhookNotFound when the organization has no webhook
+	Webhook(ctx context.Context, orgId string) (*Webhook, error)
+	DeleteWebhook(ctx context.Context, orgId string) error
+	RecordWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error
+
+	// WebhookDeliveries returns at most limit deliveries of the organization, newest first
+	WebhookDeliveries(ctx context.Context, orgId string, limit int) ([]WebhookDelivery, error)
+}
+
+func webhookNotFound(orgId string) error {
+	return errors.Join(ErrWebhookNotFound, fmt.Errorf("organization id: %s", orgId))
+}
+
+func newWebhookSecret() (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return WebhookSecretPrefix + base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// ConfigureWebhook saves the webhook of the organization. The secret of an existing webhook is kept unless
+// rotateSecret is set, and new webhooks get a new secret
+func ConfigureWebhook(ctx context.Context, store WebhookStore, webhook *Webhook, rotateSecret bool) error {
+	existing, err := store.Webhook(ctx, webhook.OrgId)
+	if err != nil && !errors.Is(err, ErrWebhookNotFound) {
+		return err
+	}
+	webhook.Secret = existing.Secret
+	if err != nil || rotateSecret {
+		if webhook.Secret, err = newWebhookSecret(); err != nil {
+			return err
+		}
+	}
+	webhook.Events = RemoveDuplicates(webhook.Events)
+	return store.SaveWebhook(ctx, webhook)
+}
+
+// SignWebhook returns the signature header of a body sent at t. The signature is the hex encoded HMAC-SHA256 of
+// the unix time and the body joined by a dot, such that receivers can reject old deliveries that are sent again
+func SignWebhook(secret string, t time.Time, body []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", t.Unix(), webhookMac(secret, strconv.FormatInt(t.Unix(), 10), body))
+}
+
+func webhookMac(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks the signature header of a delivery, and that it was signed within tolerance of now
+func VerifyWebhookSignature(secret, header string, body []byte, now time.Time, tolerance time.Duration) bool {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || now.Sub(time.Unix(unix, 0)).Abs() > tolerance {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(webhookMac(secret, timestamp, body)))
+}
+
+// WebhookDispatcher delivers events to the webhooks of the organizations
+type WebhookDispatcher struct {
+	Store  WebhookStore
+	Client *http.Client
+
+	// Backoff is the wait before each retry. A delivery is attempted once more than the number of waits
+	Backoff []time.Duration
+	Now     func() time.Time
+}
+
+func NewWebhookDispatcher(store WebhookStore, client *http.Client) *WebhookDispatcher {
+	return &WebhookDispatcher{Store: store, Client: client, Backoff: DefaultWebhookBackoff, Now: time.Now}
+}
+
+// Deliver posts the event to the webhook of its organization, if the webhook subscribes to it. Attempts that fail
+// with a network error or a transient status code are retried, and the outcome is written to the delivery log.
+// Deliver subscribes to the event bus and runs in the background
+func (d *WebhookDispatcher) Deliver(ctx context.Context, event Event) {
+	name, ok := webhookEventKinds[event.Kind]
+	if !ok {
+		return
+	}
+	webhook, err := d.Store.Webhook(ctx, event.OrgId)
+	if errors.Is(err, ErrWebhookNotFound) {
+		return
+	} else if err != nil {
+		slog.ErrorContext(ctx, "Could not fetch webhook", "error", err, "orgId", event.OrgId)
+		return
+	}
+	if !webhook.Subscribes(name) {
+		return
+	}
+
+	payload := WebhookPayload{
+		Id:             uuid.NewString(),
+		Event:          name,
+		OrganizationId: event.OrgId,
+		UserId:         event.UserId,
+		TargetId:       event.TargetId,
+		OccurredAt:     event.At,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not encode webhook payload", "error", err)
+		return
+	}
+
+	delivery := WebhookDelivery{
+		Id:        payload.Id,
+		OrgId:     event.OrgId,
+		Event:     name,
+		TargetId:  event.TargetId,
+		Url:       webhook.Url,
+		CreatedAt: d.Now(),
+	}
+	for attempt := 0; ; attempt++ {
+		delivery.Attempts = attempt + 1
+		if !d.post(ctx, webhook, &delivery, body) || attempt >= len(d.Backoff) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			delivery.Error += "; " + ctx.Err().Error()
+		case <-time.After(d.Backoff[attempt]):
+			continue
+		}
+		break
+	}
+
+	if err := d.Store.RecordWebhookDelivery(ctx, &delivery); err != nil {
+		slog.ErrorContext(ctx, "Could not record webhook delivery", "error", err, "deliveryId", delivery.Id)
+	}
+	slog.InfoContext(ctx, "Delivered webhook", "orgId", event.OrgId, "event", name, "succeeded", delivery.Succeeded, "attempts", delivery.Attempts)
+}
+
+// post makes one attempt to deliver the body and reports whether the attempt should be retried
+func (d *WebhookDispatcher) post(ctx context.Context, webhook *Webhook, delivery *WebhookDelivery, body []byte) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Caesura-Webhook")
+	req.Header.Set(WebhookEventHeader, string(delivery.Event))
+	req.Header.Set(WebhookDeliveryHeader, delivery.Id)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(webhook.Secret, d.Now(), body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		delivery.StatusCode = 0
+		delivery.Error = err.Error()
+		return true
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	delivery.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		delivery.Error = ""
+		delivery.Succeeded = true
+		return false
+	}
+	delivery.Error = resp.Status
+	return slices.Contains(transientHTTPCodes, resp.StatusCode)
+}
+
+// SortWebhookDeliveries orders the deliveries with the newest first
+func SortWebhookDeliveries(deliveries []WebhookDelivery) {
+	slices.SortStableFunc(deliveries, func(a, b WebhookDelivery) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+}
+
+func (g *GoogleStore) SaveWebhook(ctx context.Context, webhook *Webhook) error {
+	if err := webhook.Validate(); err != nil {
+		return err
+	}
+	return g.FsClient.StoreDocument(ctx, organizationCollection, organizationWebhook, webhook.OrgId, webhook)
+}
+
+func (g *GoogleStore) Webhook(ctx context.Context, orgId string) (*Webhook, error) {
+	doc, err := g.FsClient.GetDoc(ctx, organizationCollection, organizationWebhook, orgId)
+	if err != nil {
+		return &Webhook{}, classifyStoreErr(err, ErrWebhookNotFound)
+	}
+	var webhook Webhook
+	err = doc.DataTo(&webhook)
+	return &webhook, err
+}
+
+func (g *GoogleStore) DeleteWebhook(ctx context.Context, orgId string) error {
+	return g.FsClient.DeleteDoc(ctx, organizationCollection, organizationWebhook, orgId)
+}
+
+func (g *GoogleStore) RecordWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	return g.FsClient.StoreDocument(ctx, webhookDeliveryCollection, delivery.OrgId, delivery.Id, delivery)
+}
+
+func (g *GoogleStore) WebhookDeliveries(ctx context.Context, orgId string, limit int) ([]WebhookDelivery, error) {
+	collector := NewValidCollector[WebhookDelivery]()
+	for doc := range g.FsClient.GetDocByPrefix(ctx, webhookDeliveryCollection, orgId, "id", "") {
+		collector.Push(doc)
+	}
+	SortWebhookDeliveries(collector.Items)
+	return collector.Items[:min(limit, len(collector.Items))], collector.Err
+}
+
+func (m *MultiOrgInMemoryStore) SaveWebhook(ctx context.Context, webhook *Webhook) error {
+	if err := webhook.Validate(); err != nil {
+		return err
+	}
+	stored := *webhook
+	stored.Events = slices.Clone(webhook.Events)
+	m.OrgWebhooks[webhook.OrgId] = stored
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) Webhook(ctx context.Context, orgId string) (*Webhook, error) {
+	webhook, ok := m.OrgWebhooks[orgId]
+	if !ok {
+		return &Webhook{}, webhookNotFound(orgId)
+	}
+	webhook.Events = slices.Clone(webhook.Events)
+	return &webhook, nil
+}
+
+func (m *MultiOrgInMemoryStore) DeleteWebhook(ctx context.Context, orgId string) error {
+	delete(m.OrgWebhooks, orgId)
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) RecordWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	m.OrgWebhookDeliveries[delivery.OrgId] = append(m.OrgWebhookDeliveries[delivery.OrgId], *delivery)
+	return nil
+}
+
+func (m *MultiOrgInMemoryStore) WebhookDeliveries(ctx context.Context, orgId string, limit int) ([]WebhookDelivery, error) {
+	result := slices.Clone(m.OrgWebhookDeliveries[orgId])
+	if result == nil {
+		result = []WebhookDelivery{}
+	}
+	SortWebhookDeliveries(result)
+	return result[:min(limit, len(result))], nil
+}
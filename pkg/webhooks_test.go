@@ -0,0 +1,226 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestWebhookValidate(t *testing.T) {
+	valid := func() *Webhook {
+		return &Webhook{OrgId: "org", Url: "https://example.com/hooks", Secret: WebhookSecretPrefix + "abc", Enabled: true}
+	}
+	for _, test := range []struct {
+		desc   string
+		modify func(w *Webhook)
+		valid  bool
+	}{
+		{"valid", func(w *Webhook) {}, true},
+		{"events", func(w *Webhook) { w.Events = []WebhookEvent{WebhookResourceCreated, WebhookUserInvited} }, true},
+		{"unknown event", func(w *Webhook) { w.Events = []WebhookEvent{"resource.deleted"} }, false},
+		{"no organization", func(w *Webhook) { w.OrgId = "" }, false},
+		{"ftp url", func(w *Webhook) { w.Url = "ftp://example.com" }, false},
+		{"no host", func(w *Webhook) { w.Url = "https://" }, false},
+		{"long url", func(w *Webhook) { w.Url = "https://example.com/" + strings.Repeat("a", maxWebhookUrlLength) }, false},
+		{"no secret", func(w *Webhook) { w.Secret = "" }, false},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			webhook := valid()
+			test.modify(webhook)
+			err := webhook.Validate()
+			testutils.AssertEqual(t, err == nil, test.valid)
+			if !test.valid {
+				testutils.AssertEqual(t, errors.Is(err, ErrInvalidWebhook), true)
+			}
+		})
+	}
+}
+
+func TestWebhookSubscribes(t *testing.T) {
+	webhook := Webhook{Enabled: true}
+	testutils.AssertEqual(t, webhook.Subscribes(WebhookProjectUpdated), true)
+
+	webhook.Events = []WebhookEvent{WebhookResourceCreated}
+	testutils.AssertEqual(t, webhook.Subscribes(WebhookProjectUpdated), false)
+	testutils.AssertEqual(t, webhook.Subscribes(WebhookResourceCreated), true)
+
+	webhook.Enabled = false
+	testutils.AssertEqual(t, webhook.Subscribes(WebhookResourceCreated), false)
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"event":"resource.created"}`)
+	header := SignWebhook("whsec_secret", now, body)
+	testutils.AssertEqual(t, VerifyWebhookSignature("whsec_secret", header, body, now, time.Minute), true)
+	testutils.AssertEqual(t, VerifyWebhookSignature("whsec_other", header, body, now, time.Minute), false)
+	testutils.AssertEqual(t, VerifyWebhookSignature("whsec_secret", header, []byte("{}"), now, time.Minute), false)
+	testutils.AssertEqual(t, VerifyWebhookSignature("whsec_secret", header, body, now.Add(time.Hour), time.Minute), false)
+	testutils.AssertEqual(t, VerifyWebhookSignature("whsec_secret", "v1=abc", body, now, time.Minute), false)
+}
+
+// assertWebhookStore runs the same checks against all implementations of the webhook store
+func assertWebhookStore(t *testing.T, store WebhookStore) {
+	ctx := context.Background()
+	_, err := store.Webhook(ctx, "org")
+	testutils.AssertEqual(t, errors.Is(err, ErrWebhookNotFound), true)
+
+	webhook := Webhook{
+		OrgId:     "org",
+		Url:       "https://example.com/hooks",
+		Events:    []WebhookEvent{WebhookResourceCreated, WebhookResourceCreated, WebhookUserInvited},
+		Enabled:   true,
+		UpdatedBy: "admin",
+		UpdatedAt: time.Now().Truncate(time.Second),
+	}
+	testutils.AssertNil(t, ConfigureWebhook(ctx, store, &webhook, false))
+
+	stored, err := store.Webhook(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Url, webhook.Url)
+	testutils.AssertEqual(t, stored.Enabled, true)
+	testutils.AssertEqual(t, stored.UpdatedBy, "admin")
+	testutils.AssertEqual(t, stored.UpdatedAt.Equal(webhook.UpdatedAt), true)
+	testutils.AssertEqual(t, len(stored.Events), 2)
+	testutils.AssertEqual(t, strings.HasPrefix(stored.Secret, WebhookSecretPrefix), true)
+	secret := stored.Secret
+
+	update := Webhook{OrgId: "org", Url: "https://example.com/other", UpdatedAt: time.Now()}
+	testutils.AssertNil(t, ConfigureWebhook(ctx, store, &update, false))
+	stored, err = store.Webhook(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Url, "https://example.com/other")
+	testutils.AssertEqual(t, stored.Secret, secret)
+	testutils.AssertEqual(t, stored.Enabled, false)
+
+	testutils.AssertNil(t, ConfigureWebhook(ctx, store, &update, true))
+	stored, err = store.Webhook(ctx, "org")
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, stored.Secret != secret, true)
+
+	invalid := Webhook{OrgId: "org", Url: "not a url"}
+	err = ConfigureWebhook(ctx, store, &invalid, false)
+	testutils.AssertEqual(t, errors.Is(err, ErrInvalidWebhook), true)
+
+	now := time.Now().Truncate(time.Second)
+	for i, id := range []string{"first", "second", "third"} {
+		delivery := WebhookDelivery{
+			Id:         id,
+			OrgId:      "org",
+			Event:      WebhookProjectUpdated,
+			TargetId:   "project",
+			Url:        stored.Url,
+			Attempts:   i + 1,
+			StatusCode: http.StatusOK,
+			Succeeded:  true,
+			CreatedAt:  now.Add(time.Duration(i) * time.Minute),
+		}
+		testutils.AssertNil(t, store.RecordWebhookDelivery(ctx, &delivery))
+	}
+	testutils.AssertNil(t, store.RecordWebhookDelivery(ctx, &WebhookDelivery{Id: "other", OrgId: "other-org", CreatedAt: now}))
+
+	deliveries, err := store.WebhookDeliveries(ctx, "org", 2)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(deliveries), 2)
+	testutils.AssertEqual(t, deliveries[0].Id, "third")
+	testutils.AssertEqual(t, deliveries[0].Attempts, 3)
+	testutils.AssertEqual(t, deliveries[0].Event, WebhookProjectUpdated)
+	testutils.AssertEqual(t, deliveries[1].Id, "second")
+
+	testutils.AssertNil(t, store.DeleteWebhook(ctx, "org"))
+	_, err = store.Webhook(ctx, "org")
+	testutils.AssertEqual(t, errors.Is(err, ErrWebhookNotFound), true)
+}
+
+func TestInMemoryWebhookStore(t *testing.T) {
+	assertWebhookStore(t, NewMultiOrgInMemoryStore())
+}
+
+func TestGoogleWebhookStore(t *testing.T) {
+	assertWebhookStore(t, &GoogleStore{FsClient: NewLocalFirestoreClient()})
+}
+
+func TestLocalWebhookStore(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+	assertWebhookStore(t, store)
+}
+
+func TestWebhookDispatcherDeliver(t *testing.T) {
+	var calls atomic.Int32
+	var received WebhookPayload
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		signature = r.Header.Get(WebhookSignatureHeader)
+		json.Unmarshal(body, &received)
+		if !VerifyWebhookSignature(testWebhookSecret, signature, body, time.Now(), time.Minute) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	store := NewMultiOrgInMemoryStore()
+	ctx := context.Background()
+	webhook := Webhook{OrgId: "org", Url: server.URL, Secret: testWebhookSecret, Enabled: true, Events: []WebhookEvent{WebhookResourceCreated}}
+	testutils.AssertNil(t, store.SaveWebhook(ctx, &webhook))
+
+	dispatcher := NewWebhookDispatcher(store, server.Client())
+	dispatcher.Backoff = []time.Duration{time.Millisecond}
+	dispatcher.Deliver(ctx, NewEvent(EventResourceUploaded, "org", "user", "bolero_ravel"))
+	dispatcher.Deliver(ctx, NewEvent(EventProjectUpdated, "org", "user", "concert"))
+	dispatcher.Deliver(ctx, NewEvent(EventSubscriptionChanged, "org", "user", "customer"))
+	dispatcher.Deliver(ctx, NewEvent(EventResourceUploaded, "no-webhook", "user", "bolero_ravel"))
+
+	testutils.AssertEqual(t, calls.Load(), int32(2))
+	testutils.AssertEqual(t, received.Event, WebhookResourceCreated)
+	testutils.AssertEqual(t, received.TargetId, "bolero_ravel")
+	testutils.AssertEqual(t, received.OrganizationId, "org")
+
+	deliveries, err := store.WebhookDeliveries(ctx, "org", 10)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(deliveries), 1)
+	testutils.AssertEqual(t, deliveries[0].Id, received.Id)
+	testutils.AssertEqual(t, deliveries[0].Attempts, 2)
+	testutils.AssertEqual(t, deliveries[0].Succeeded, true)
+	testutils.AssertEqual(t, deliveries[0].StatusCode, http.StatusOK)
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		calls.Store(1)
+		webhook.Secret = WebhookSecretPrefix + "wrong"
+		testutils.AssertNil(t, store.SaveWebhook(ctx, &webhook))
+		dispatcher.Deliver(ctx, NewEvent(EventResourceUploaded, "org", "user", "nimrod_elgar"))
+
+		deliveries, err := store.WebhookDeliveries(ctx, "org", 1)
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, deliveries[0].Succeeded, false)
+		testutils.AssertEqual(t, deliveries[0].Attempts, 1)
+		testutils.AssertEqual(t, deliveries[0].StatusCode, http.StatusUnauthorized)
+		testutils.AssertContains(t, deliveries[0].Error, "401")
+	})
+
+	t.Run("gives up after the retries", func(t *testing.T) {
+		server.Close()
+		dispatcher.Deliver(ctx, NewEvent(EventResourceUploaded, "org", "user", "enigma_elgar"))
+
+		deliveries, err := store.WebhookDeliveries(ctx, "org", 1)
+		testutils.AssertNil(t, err)
+		testutils.AssertEqual(t, deliveries[0].Succeeded, false)
+		testutils.AssertEqual(t, deliveries[0].Attempts, 2)
+		testutils.AssertEqual(t, deliveries[0].StatusCode, 0)
+	})
+}
+
+const testWebhookSecret = WebhookSecretPrefix + "test"
@@ -0,0 +1,21 @@
+package web
+
+import (
+	"io"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+type AccountData struct {
+	// Text the user types to confirm that the account should be deleted
+	Confirmation string
+
+	// Organizations where the user is the only admin. The account can not be deleted until they have another admin
+	SoleAdminOrgs []pkg.Organization
+}
+
+// Account renders the form for deleting the account of the signed in user
+func Account(w io.Writer, language string, data AccountData) {
+	tmpl := lookupTemplate("account", language)
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "account", data))
+}
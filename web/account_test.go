@@ -0,0 +1,20 @@
+package web
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestAccount(t *testing.T) {
+	var buf bytes.Buffer
+	Account(&buf, "en", AccountData{Confirmation: "john@example.com"})
+	testutils.AssertContains(t, buf.String(), `hx-delete="/account"`, "john@example.com", "Delete my account")
+
+	buf.Reset()
+	Account(&buf, "nb", AccountData{Confirmation: "john@example.com", SoleAdminOrgs: []pkg.Organization{{Name: "<Brass band>"}}})
+	testutils.AssertContains(t, buf.String(), "eneste administrator", "&lt;Brass band&gt;")
+	testutils.AssertNotContains(t, buf.String(), `hx-delete="/account"`)
+}
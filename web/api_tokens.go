@@ -0,0 +1,26 @@
+package web
+
+import (
+	"io"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+type ApiTokensData struct {
+	Tokens []pkg.ApiToken
+
+	// Secret of a token that was just created. It is shown once, since only the hash is stored
+	Secret string
+
+	// The user may create tokens that can upload and edit scores
+	CanWrite bool
+
+	// The user may create tokens that identity providers use to provision members
+	CanProvision bool
+}
+
+// ApiTokens renders the API tokens of the signed in user
+func ApiTokens(w io.Writer, language string, data ApiTokensData) {
+	tmpl := lookupTemplate("api-tokens", language)
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "api-tokens", data))
+}
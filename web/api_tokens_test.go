@@ -0,0 +1,29 @@
+package web
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestApiTokens(t *testing.T) {
+	tokens := []pkg.ApiToken{
+		{Id: "abc", Name: "<Upload>", Scope: pkg.ApiTokenWrite, ExpiresAt: time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{Id: "def", Name: "Backup", Scope: pkg.ApiTokenRead},
+	}
+
+	var buf bytes.Buffer
+	ApiTokens(&buf, "nb", ApiTokensData{Tokens: tokens, Secret: "cae_secret", CanWrite: true})
+	testutils.AssertContains(
+		t, buf.String(), "API-nøkler", "cae_secret", "&lt;Upload&gt; (Lese og skrive)", "2026-06-01", "Backup (Lese)",
+		"Aldri", `hx-delete="/tokens/abc"`, `<option value="write">`,
+	)
+
+	buf.Reset()
+	ApiTokens(&buf, "en", ApiTokensData{})
+	testutils.AssertContains(t, buf.String(), "You have no API tokens for this organization")
+	testutils.AssertNotContains(t, buf.String(), `<option value="write">`, "Copy the token now")
+}
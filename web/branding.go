@@ -0,0 +1,48 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+// darken scales each channel of a #rrggbb color by factor
+func darken(color string, factor float64) string {
+	value, err := strconv.ParseUint(color[1:], 16, 32)
+	if err != nil {
+		return color
+	}
+	r := float64((value >> 16) & 0xff)
+	g := float64((value >> 8) & 0xff)
+	b := float64(value & 0xff)
+	return fmt.Sprintf("#%02x%02x%02x", int(r*factor), int(g*factor), int(b*factor))
+}
+
+// BrandingCSS writes a stylesheet that overrides the primary color palette. Nothing is written
+// when the organization uses the default colors
+func BrandingCSS(w io.Writer, branding pkg.Branding) {
+	if branding.PrimaryColor == "" || branding.Validate() != nil {
+		return
+	}
+	color := branding.Color()
+	fmt.Fprintf(w, ":root {\n  --color-primary-500: %s;\n  --color-primary-600: %s;\n  --color-primary-700: %s;\n}\n", color, color, darken(color, 0.85))
+}
+
+func BrandingLogo(w io.Writer, branding pkg.Branding) {
+	tmpl := lookupTemplate("branding_logo.html", "")
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "branding-logo", branding))
+}
+
+func BrandingForm(w io.Writer, language string, branding pkg.Branding) {
+	tmpl := lookupTemplate("branding-form", language)
+	data := struct {
+		pkg.Branding
+		Color string
+	}{
+		Branding: branding,
+		Color:    branding.Color(),
+	}
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "branding-form", data))
+}
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestDarken(t *testing.T) {
+	testutils.AssertEqual(t, darken("#ffffff", 0.5), "#7f7f7f")
+	testutils.AssertEqual(t, darken("#102030", 1.0), "#102030")
+	testutils.AssertEqual(t, darken("#zzzzzz", 0.5), "#zzzzzz")
+}
+
+func TestBrandingCSS(t *testing.T) {
+	for _, test := range []struct {
+		desc     string
+		branding pkg.Branding
+		want     string
+	}{
+		{"default", pkg.Branding{}, ""},
+		{"invalid", pkg.Branding{PrimaryColor: "red"}, ""},
+		{"custom", pkg.Branding{PrimaryColor: "#ffffff"}, ":root {\n  --color-primary-500: #ffffff;\n  --color-primary-600: #ffffff;\n  --color-primary-700: #d8d8d8;\n}\n"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			BrandingCSS(&buf, test.branding)
+			testutils.AssertEqual(t, buf.String(), test.want)
+		})
+	}
+}
+
+func TestBrandingLogo(t *testing.T) {
+	var buf bytes.Buffer
+	BrandingLogo(&buf, pkg.Branding{})
+	testutils.AssertNotContains(t, buf.String(), "<img")
+
+	buf.Reset()
+	BrandingLogo(&buf, pkg.Branding{LogoURL: "https://example.com/logo.png"})
+	testutils.AssertContains(t, buf.String(), `<img src="https://example.com/logo.png"`)
+}
+
+func TestBrandingForm(t *testing.T) {
+	var buf bytes.Buffer
+	BrandingForm(&buf, "nb", pkg.Branding{})
+	testutils.AssertContains(t, buf.String(), `hx-put="/organizations/branding"`, `value="#6366f1"`, "Hovedfarge")
+}
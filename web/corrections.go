@@ -0,0 +1,31 @@
+package web
+
+import (
+	"io"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+type CorrectionsData struct {
+	Corrections []pkg.Correction
+
+	// CanDecide is true for members who approve or reject corrections
+	CanDecide bool
+
+	// Name of the member in the directory, shown as a hint in the form for correcting it
+	Name string
+}
+
+// Corrections renders the form for proposing a correction of the name of the member, and the queue of corrections.
+// Members who can not decide only see their own corrections
+func Corrections(w io.Writer, language string, data *CorrectionsData) {
+	tmpl := lookupTemplate("corrections", language)
+	content := struct {
+		*CorrectionsData
+		NumPending int
+	}{
+		CorrectionsData: data,
+		NumPending:      pkg.NumPendingCorrections(data.Corrections),
+	}
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "corrections", content))
+}
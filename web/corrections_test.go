@@ -0,0 +1,30 @@
+package web
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestCorrections(t *testing.T) {
+	createdAt := time.Date(2025, 6, 1, 19, 30, 0, 0, time.UTC)
+	corrections := []pkg.Correction{
+		{Id: "1", Kind: pkg.CorrectionMetaData, TargetName: "Polka", Field: "genre", Current: "Folk", Value: "Dance <2>", ProposerName: "Anna", Status: pkg.CorrectionPending, CreatedAt: createdAt},
+		{Id: "2", Kind: pkg.CorrectionProfile, TargetName: "John", Field: "name", Current: "John", Value: "John Smith", Status: pkg.CorrectionRejected, CreatedAt: createdAt},
+	}
+
+	var buf bytes.Buffer
+	Corrections(&buf, "nb", &CorrectionsData{Corrections: corrections, CanDecide: true, Name: "Anna"})
+	testutils.AssertContains(
+		t, buf.String(), "Rettelser (1)", "Polka &ndash; Sjanger", "Dance &lt;2&gt;", "Anna &middot; 2025-06-01 19:30",
+		`hx-put="/organizations/corrections/1"`, "Godkjenn", "Avvist", `placeholder="Anna"`,
+	)
+	testutils.AssertNotContains(t, buf.String(), `hx-put="/organizations/corrections/2"`)
+
+	buf.Reset()
+	Corrections(&buf, "en", &CorrectionsData{})
+	testutils.AssertContains(t, buf.String(), "No corrections have been proposed", "Propose a correction of your name")
+}
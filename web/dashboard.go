@@ -0,0 +1,13 @@
+package web
+
+import (
+	"io"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+// Dashboard renders the start page of a signed in user
+func Dashboard(w io.Writer, language string, dashboard *pkg.Dashboard) {
+	tmpl := lookupTemplate("dashboard", language)
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "dashboard", dashboard))
+}
@@ -0,0 +1,37 @@
+package web
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestDashboard(t *testing.T) {
+	dashboard := &pkg.Dashboard{
+		Organization: pkg.Organization{Name: "<Brass band>", NumScores: 12},
+		NumMembers:   3,
+		Projects:     []pkg.Project{{Name: "Spring concert", UpdatedAt: time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)}},
+		Resources:    []pkg.MetaData{{Title: "Festive overture", Composer: "Shostakovich"}},
+		Tasks:        []pkg.DashboardTask{pkg.DashboardTaskJoinGroup},
+	}
+
+	var buf bytes.Buffer
+	Dashboard(&buf, "nb", dashboard)
+	testutils.AssertContains(
+		t, buf.String(), "&lt;Brass band&gt;", "12 noter", "3 medlemmer", "Spring concert", "2026-04-01",
+		"Shostakovich", "ikke med i noen gruppe",
+	)
+	testutils.AssertNotContains(t, buf.String(), "/email/verification")
+
+	buf.Reset()
+	Dashboard(&buf, "en", &pkg.Dashboard{Tasks: []pkg.DashboardTask{pkg.DashboardTaskVerifyEmail}})
+	testutils.AssertContains(t, buf.String(), `hx-post="/email/verification"`, "Send a new link")
+
+	buf.Reset()
+	Dashboard(&buf, "en", &pkg.Dashboard{})
+	testutils.AssertContains(t, buf.String(), "There are no projects yet", "No scores have been uploaded yet")
+	testutils.AssertNotContains(t, buf.String(), "dashboard-tasks")
+}
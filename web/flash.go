@@ -0,0 +1,21 @@
+package web
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+// FlashMessage translates the key and fills in data. Flash messages are inserted as text on the client,
+// therefore no HTML escaping is done
+func FlashMessage(lang, key string, data any) string {
+	tmpl, err := template.New("flash").Parse(translator.MustGet(lang, key))
+	if err != nil {
+		return key
+	}
+
+	var builder strings.Builder
+	pkg.PanicOnErr(tmpl.Execute(&builder, data))
+	return builder.String()
+}
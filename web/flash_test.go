@@ -0,0 +1,17 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestFlashMessage(t *testing.T) {
+	data := map[string]any{"Count": 2, "Project": "Spring & Summer"}
+	testutils.AssertEqual(t, FlashMessage("en", "flash.project-updated", data), "Added 2 piece(s) to 'Spring & Summer'")
+	testutils.AssertEqual(t, FlashMessage("nb", "flash.project-updated", data), "La til 2 stykke(r) i 'Spring & Summer'")
+}
+
+func TestFlashMessageUnknownKey(t *testing.T) {
+	testutils.AssertEqual(t, FlashMessage("en", "flash.does-not-exist", nil), "flash.does-not-exist")
+}
@@ -0,0 +1,25 @@
+package web
+
+import (
+	"io"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+type GuestResource struct {
+	Meta  pkg.MetaData
+	Files []string
+}
+
+type GuestPageData struct {
+	// Token of the guest, which is passed on to the download links
+	Token     string
+	LastDay   string
+	Resources []GuestResource
+}
+
+// GuestPage renders the scores shared with a guest. The page is shown without a session, so it has no header
+func GuestPage(w io.Writer, language string, data GuestPageData) {
+	tmpl := lookupTemplate("guest", language)
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "guest", data))
+}
@@ -0,0 +1,30 @@
+package web
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestGuestPage(t *testing.T) {
+	data := GuestPageData{
+		Token:   "a.b+c",
+		LastDay: "2026-06-01",
+		Resources: []GuestResource{
+			{Meta: pkg.MetaData{Title: "Bolero", Composer: "Ravel"}, Files: []string{"Cornet 1.pdf"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	GuestPage(&buf, "nb", data)
+	testutils.AssertContains(
+		t, buf.String(), "Delte noter", "Tilgang til: 2026-06-01", "Bolero", "Ravel",
+		`href="/guest/part?token=a.b%2bc&resourceId=bolero_ravel&file=Cornet%201.pdf"`,
+	)
+
+	buf.Reset()
+	GuestPage(&buf, "en", GuestPageData{LastDay: "2026-06-01"})
+	testutils.AssertContains(t, buf.String(), "No scores are shared with you")
+}
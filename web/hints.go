@@ -0,0 +1,13 @@
+package web
+
+import (
+	"io"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+// Hint renders a one-time hint with a button for dismissing it
+func Hint(w io.Writer, language string, hint pkg.Hint) {
+	tmpl := lookupTemplate("hint", language)
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "hint", hint))
+}
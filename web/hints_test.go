@@ -0,0 +1,18 @@
+package web
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestHint(t *testing.T) {
+	for _, hint := range pkg.Hints {
+		var buf bytes.Buffer
+		Hint(&buf, "nb", hint)
+		testutils.AssertContains(t, buf.String(), "Tips", "Skjønner", `hx-post="/hints/`+string(hint)+`/seen"`)
+		testutils.AssertNotContains(t, buf.String(), "hint.")
+	}
+}
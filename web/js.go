@@ -14,6 +14,10 @@ import (
 //go:embed js/*
 var jsFS embed.FS
 
+// SwaggerUIVersion is the version of swagger-ui-dist that renders the documentation of the API. It is not bundled
+// and is therefore not listed in package.json
+const SwaggerUIVersion = "5.17.14"
+
 func PdfJs(w io.Writer) {
 	deps := LoadDependencies().Dependencies
 	content := string(utils.Must(jsFS.ReadFile("js/pdf-viewer.js")))
@@ -0,0 +1,17 @@
+// bulk-actions.js
+
+// Applies an action to several pieces at once. Extra holds the values the action needs, such as the tags to add
+function applyBulkAction(resourceIds, action, extra) {
+  if (resourceIds.length === 0) return;
+
+  fetch("/resources/bulk", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ action: action, resourceIds: resourceIds, ...extra }),
+  }).then((response) => {
+    dispatchTriggeredEvents(response);
+    if (!response.ok) {
+      response.text().then((text) => showFlash("error", text));
+    }
+  });
+}
@@ -0,0 +1,51 @@
+// Collects the edited cells of the bulk edit table. Only rows where at least one value differs
+// from the value it was rendered with are included
+function collectMetadataPatches(doc) {
+  const patches = [];
+  for (const row of doc.querySelectorAll("#bulk-edit-rows tr[data-resource-id]")) {
+    const fields = {};
+    for (const input of row.querySelectorAll("input[data-field]")) {
+      if (input.value !== input.dataset.original) {
+        fields[input.dataset.field] = input.value;
+      }
+    }
+    if (Object.keys(fields).length > 0) {
+      patches.push({ resourceId: row.dataset.resourceId, fields: fields });
+    }
+  }
+  return patches;
+}
+
+function markPatchResults(doc, results) {
+  for (const result of results) {
+    const row = doc.querySelector(`tr[data-resource-id="${CSS.escape(result.resourceId)}"]`);
+    if (!row) continue;
+
+    const status = row.querySelector(".bulk-edit-status");
+    status.textContent = result.ok ? "✓" : result.error;
+    status.className = `bulk-edit-status flash-${result.ok ? "success" : "error"}`;
+    if (result.ok) {
+      for (const input of row.querySelectorAll("input[data-field]")) {
+        input.dataset.original = input.value;
+      }
+    }
+  }
+}
+
+function saveMetadataPatches(doc) {
+  const patches = collectMetadataPatches(doc);
+  if (patches.length === 0) return;
+
+  fetch("/resources/metadata", {
+    method: "PATCH",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(patches),
+  }).then((response) => {
+    dispatchTriggeredEvents(response);
+    if (!response.ok) {
+      response.text().then((text) => showFlash("error", text));
+      return;
+    }
+    response.json().then((data) => markPatchResults(doc, data.results));
+  });
+}
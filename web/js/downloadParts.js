@@ -1,4 +1,4 @@
-function downloadParts(doc, ids) {
+function downloadParts(doc, ids, projectId, stampDate) {
   // Create a temporary form and populate it with the resource ids
   const form = doc.createElement("form");
   form.method = "POST";
@@ -14,6 +14,24 @@ function downloadParts(doc, ids) {
     form.appendChild(input);
   });
 
+  // The project is used to show the download in the activity feed of the project
+  if (projectId) {
+    const input = doc.createElement("input");
+    input.type = "hidden";
+    input.name = "projectId";
+    input.value = projectId;
+    form.appendChild(input);
+  }
+
+  // The project name and rehearsal date are stamped on the pages when a date is given
+  if (projectId && stampDate) {
+    const input = doc.createElement("input");
+    input.type = "hidden";
+    input.name = "stampDate";
+    input.value = stampDate;
+    form.appendChild(input);
+  }
+
   // Append, submit, then remove
   doc.body.appendChild(form);
   form.submit();
@@ -0,0 +1,63 @@
+// Dispatches the events in the HX-Trigger header of a response obtained with fetch,
+// such that listeners behave the same as for requests made by htmx
+function dispatchTriggeredEvents(response) {
+  const header = response.headers.get("HX-Trigger");
+  if (!header) return;
+
+  for (const [name, detail] of Object.entries(JSON.parse(header))) {
+    document.body.dispatchEvent(new CustomEvent(name, { detail: detail }));
+  }
+}
+
+// Shows a flash message in the footer. Messages that are not errors are removed after the
+// number of milliseconds given by the data-dismiss-after attribute of the container
+function showFlash(level, message) {
+  const container = document.getElementById("flashMessage");
+  if (!container) return;
+
+  const item = document.createElement("span");
+  item.className = `flash flash-${level}`;
+  item.dataset.level = level;
+  item.textContent = message;
+  container.replaceChildren(item);
+
+  clearTimeout(container.dismissTimer);
+  const dismissAfter = parseInt(container.dataset.dismissAfter ?? "0");
+  if (level !== "error" && dismissAfter > 0) {
+    container.dismissTimer = setTimeout(() => item.remove(), dismissAfter);
+  }
+}
+
+document.body.addEventListener("flash", function (event) {
+  showFlash(event.detail.level ?? "info", event.detail.message ?? "");
+});
+
+// Reloads the organization specific stylesheet such that new colors apply without a page reload
+function reloadBranding() {
+  const link = document.querySelector('link[href^="/session/branding.css"]');
+  if (!link) return;
+  link.href = `/session/branding.css?t=${Date.now()}`;
+}
+
+for (const name of ["branding-updated", "loginEvent", "logoutEvent"]) {
+  document.body.addEventListener(name, reloadBranding);
+}
+
+// Opens the command palette on Ctrl+K, or Cmd+K on Mac, and loads the pages that can be opened
+document.addEventListener("keydown", function (event) {
+  if (!(event.ctrlKey || event.metaKey) || event.key.toLowerCase() !== "k") return;
+  const palette = document.getElementById("command-palette");
+  if (!palette) return;
+
+  event.preventDefault();
+  const input = palette.querySelector("input[name='q']");
+  palette.showModal();
+  input.select();
+  input.dispatchEvent(new Event("palette-opened"));
+});
+
+// Fills search fields marked with data-url-query from the "q" parameter of the URL, such that links from the
+// command palette open the page with the search applied. Deferred scripts run before htmx sends the load requests
+for (const input of document.querySelectorAll("input[data-url-query]")) {
+  input.value = new URLSearchParams(window.location.search).get("q") ?? input.value;
+}
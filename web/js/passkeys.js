@@ -0,0 +1,88 @@
+// Conversions between the base64url encoded strings used by the server and the binary
+// buffers used by the WebAuthn browser API
+function base64urlToBuffer(value) {
+  const base64 = value.replace(/-/g, "+").replace(/_/g, "/");
+  const padded = base64 + "=".repeat((4 - (base64.length % 4)) % 4);
+  return Uint8Array.from(atob(padded), (c) => c.charCodeAt(0)).buffer;
+}
+
+function bufferToBase64url(buffer) {
+  const bytes = new Uint8Array(buffer);
+  let binary = "";
+  for (const b of bytes) binary += String.fromCharCode(b);
+  return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+async function postPasskeyCeremony(url, body) {
+  const response = await fetch(url, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: body ? JSON.stringify(body) : undefined,
+  });
+  if (!response.ok) {
+    throw new Error((await response.text()) || response.statusText);
+  }
+  return response;
+}
+
+// Registers a new passkey for the signed in user
+async function registerPasskey(name) {
+  try {
+    const begin = await postPasskeyCeremony("/passkeys/register/begin");
+    const options = (await begin.json()).publicKey;
+    options.challenge = base64urlToBuffer(options.challenge);
+    options.user.id = base64urlToBuffer(options.user.id);
+    for (const credential of options.excludeCredentials ?? []) {
+      credential.id = base64urlToBuffer(credential.id);
+    }
+
+    const credential = await navigator.credentials.create({ publicKey: options });
+    const finish = await postPasskeyCeremony(
+      `/passkeys/register/finish?name=${encodeURIComponent(name ?? "")}`,
+      {
+        id: credential.id,
+        rawId: bufferToBase64url(credential.rawId),
+        type: credential.type,
+        response: {
+          attestationObject: bufferToBase64url(credential.response.attestationObject),
+          clientDataJSON: bufferToBase64url(credential.response.clientDataJSON),
+          transports: credential.response.getTransports?.() ?? [],
+        },
+      },
+    );
+    dispatchTriggeredEvents(finish);
+  } catch (err) {
+    showFlash("error", err.message);
+  }
+}
+
+// Signs in with a passkey stored on the device. The user is found from the passkey,
+// hence no email is needed
+async function signInWithPasskey() {
+  try {
+    const begin = await postPasskeyCeremony("/login/passkey/begin");
+    const options = (await begin.json()).publicKey;
+    options.challenge = base64urlToBuffer(options.challenge);
+    for (const credential of options.allowCredentials ?? []) {
+      credential.id = base64urlToBuffer(credential.id);
+    }
+
+    const credential = await navigator.credentials.get({ publicKey: options });
+    await postPasskeyCeremony("/login/passkey/finish", {
+      id: credential.id,
+      rawId: bufferToBase64url(credential.rawId),
+      type: credential.type,
+      response: {
+        authenticatorData: bufferToBase64url(credential.response.authenticatorData),
+        clientDataJSON: bufferToBase64url(credential.response.clientDataJSON),
+        signature: bufferToBase64url(credential.response.signature),
+        userHandle: credential.response.userHandle
+          ? bufferToBase64url(credential.response.userHandle)
+          : null,
+      },
+    });
+    window.location.href = "/organizations";
+  } catch (err) {
+    showFlash("error", err.message);
+  }
+}
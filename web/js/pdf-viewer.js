@@ -22,6 +22,12 @@ const arrangerInput = document.getElementById("arranger-input");
 const titleInput = document.getElementById("title-input");
 const durationInput = document.getElementById("duration-input");
 const genreInput = document.getElementById("genre-input");
+const addPieceBtn = document.getElementById("add-piece-btn");
+const pieceList = document.getElementById("pieces");
+const submitProgress = document.getElementById("submit-progress");
+
+// Pieces added from a PDF holding several pieces. Each piece becomes a separate resource
+let pieces = [];
 
 document.addEventListener("keydown", function (event) {
   if (event.key === "+") {
@@ -106,6 +112,7 @@ fileInput.addEventListener("change", (e) => {
 });
 
 submitBtn.addEventListener("click", submitPartitions);
+addPieceBtn.addEventListener("click", addPiece);
 
 function deleteOrJump(elem) {
   if (deleteOnClickCheckBox.checked) {
@@ -201,19 +208,7 @@ function assignmentColor(assignmentId) {
   return "bg-gray-400 hover:bg-gray-500";
 }
 
-async function submitPartitions() {
-  if (!fileInput.files.length) {
-    return;
-  }
-
-  const metadata = getMetaData();
-  if (!metadata) {
-    return;
-  }
-
-  const formData = new FormData();
-  formData.append("document", fileInput.files[0]);
-
+function getAssignments() {
   let assignments = [];
   for (const div of assignmentSection.children) {
     if (div.id.endsWith("-group")) {
@@ -230,20 +225,124 @@ async function submitPartitions() {
       });
     }
   }
-  formData.append("assignments", JSON.stringify(assignments));
+  return assignments;
+}
+
+// addPiece stores the metadata and assignments of the current piece, such that the next piece of the same PDF
+// can be assigned. It reports whether the piece was added
+function addPiece() {
+  const metadata = getMetaData();
+  if (!metadata) {
+    return false;
+  }
+  const assignments = getAssignments();
+  if (!assignments.length) {
+    alert("Please assign the pages of the piece before adding it.");
+    return false;
+  }
+
+  pieces.push({ metadata: metadata, assignments: assignments });
+  const item = document.createElement("li");
+  item.textContent = [metadata.title, metadata.composer]
+    .filter(Boolean)
+    .join(" - ");
+  pieceList.appendChild(item);
+
+  assignmentSection.replaceChildren();
+  titleInput.value = "";
+  durationInput.value = "";
+  return true;
+}
+
+async function submitPartitions() {
+  if (!fileInput.files.length) {
+    return;
+  }
+  if (pieces.length) {
+    return submitPieces();
+  }
+
+  const metadata = getMetaData();
+  if (!metadata) {
+    return;
+  }
+
+  const formData = new FormData();
+  formData.append("document", fileInput.files[0]);
+  formData.append("assignments", JSON.stringify(getAssignments()));
   formData.append("metadata", JSON.stringify(metadata));
 
+  const progress = await followSubmitProgress();
+  if (progress) {
+    formData.append("upload-id", progress.uploadId);
+  }
+
   const response = await fetch("/resources", {
     method: "POST",
     body: formData,
   });
+  progress?.close();
   if (!response.ok) {
     const errorText = await response.text();
     alert(`Error submitting partition: ${errorText}`);
   } else {
-    response.text().then((html) => {
-      document.getElementById("flashMessage").innerHTML = html;
-    });
+    dispatchTriggeredEvents(response);
+  }
+}
+
+// followSubmitProgress creates an upload id and shows the progress streamed for it. Parts count twice, once
+// when they are split and once when they are uploaded. The submit works without progress if the id can not be
+// created
+async function followSubmitProgress() {
+  const response = await fetch("/submit-progress", { method: "POST" });
+  if (!response.ok) {
+    return null;
+  }
+  const { uploadId, url } = await response.json();
+
+  submitProgress.value = 0;
+  submitProgress.classList.remove("hidden");
+  const source = new EventSource(url);
+  source.addEventListener("progress", (event) => {
+    const progress = JSON.parse(event.data);
+    if (progress.total) {
+      submitProgress.value =
+        (progress.split + progress.uploaded) / (2 * progress.total);
+    }
+    if (progress.status === "finished" || progress.status === "failed") {
+      source.close();
+    }
+  });
+  return {
+    uploadId: uploadId,
+    close: () => {
+      source.close();
+      submitProgress.classList.add("hidden");
+    },
+  };
+}
+
+// submitPieces stores all added pieces, and the piece currently being assigned, in one request
+async function submitPieces() {
+  if (getAssignments().length && !addPiece()) {
+    return;
+  }
+
+  const formData = new FormData();
+  formData.append("document", fileInput.files[0]);
+  formData.append("pieces", JSON.stringify(pieces));
+
+  const response = await fetch("/resources/combined", {
+    method: "POST",
+    body: formData,
+  });
+  if (!response.ok) {
+    const errorText = await response.text();
+    alert(`Error submitting pieces: ${errorText}`);
+  } else {
+    pieces = [];
+    pieceList.replaceChildren();
+    dispatchTriggeredEvents(response);
   }
 }
 
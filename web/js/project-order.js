@@ -0,0 +1,37 @@
+// project-order.js
+
+// The pieces of a project are reordered by dragging the handle of a row. The expanded content of the row moves
+// with it, and the order of the rows is saved by the form holding their hidden inputs when the drag ends
+let draggedProjectRow = null;
+
+function expandRowOf(row) {
+  return document.getElementById(row.id.replace("row-", "expand-"));
+}
+
+function startProjectDrag(event) {
+  draggedProjectRow = event.target.closest("tr");
+  event.dataTransfer.effectAllowed = "move";
+  event.dataTransfer.setDragImage(draggedProjectRow, 0, 0);
+}
+
+function overProjectRow(event) {
+  const row = event.currentTarget;
+  if (!draggedProjectRow || row === draggedProjectRow) {
+    return;
+  }
+  event.preventDefault();
+
+  const { top, height } = row.getBoundingClientRect();
+  const anchor = event.clientY < top + height / 2 ? row : expandRowOf(row).nextSibling;
+  const expand = expandRowOf(draggedProjectRow);
+  row.parentNode.insertBefore(draggedProjectRow, anchor);
+  row.parentNode.insertBefore(expand, draggedProjectRow.nextSibling);
+}
+
+function endProjectDrag() {
+  if (!draggedProjectRow) {
+    return;
+  }
+  draggedProjectRow = null;
+  htmx.trigger("#project-order-form", "reorder");
+}
@@ -0,0 +1,18 @@
+// sort-overview.js
+
+// nextSortDirection sorts ascending by a new column and flips the direction when the same column is clicked again.
+// The sort is kept in hidden inputs, such that searches and "load more" use the same order
+function nextSortDirection(field) {
+  const sort = document.querySelector("input[name=sort]");
+  const direction = document.querySelector("input[name=direction]");
+  direction.value = sort.value === field && direction.value === "asc" ? "desc" : "asc";
+  sort.value = field;
+
+  for (const header of document.querySelectorAll("[data-sort]")) {
+    header.setAttribute(
+      "aria-sort",
+      header.dataset.sort !== field ? "none" : direction.value === "asc" ? "ascending" : "descending",
+    );
+  }
+  return direction.value;
+}
@@ -2,7 +2,10 @@ package web
 
 import (
 	"bytes"
+	"net/http"
+	"net/http/httptest"
 	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -14,3 +17,27 @@ func TestPdfJs(t *testing.T) {
 		t.Fatal("Expected response to have a version put into the URL for pdfjs-dist")
 	}
 }
+
+func TestEventsJsIsServed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	JsServer().ServeHTTP(rec, httptest.NewRequest("GET", "/js/events.js", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "dispatchTriggeredEvents") {
+		t.Fatalf("Expected events.js to be served, got status %d", rec.Code)
+	}
+}
+
+func TestProjectOrderJsIsServed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	JsServer().ServeHTTP(rec, httptest.NewRequest("GET", "/js/project-order.js", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "endProjectDrag") {
+		t.Fatalf("Expected project-order.js to be served, got status %d", rec.Code)
+	}
+}
+
+func TestSortOverviewJsIsServed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	JsServer().ServeHTTP(rec, httptest.NewRequest("GET", "/js/sort-overview.js", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "nextSortDirection") {
+		t.Fatalf("Expected sort-overview.js to be served, got status %d", rec.Code)
+	}
+}
@@ -0,0 +1,127 @@
+package web
+
+import (
+	"html"
+	"html/template"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Only a small subset of markdown is supported: paragraphs, line breaks, headings, lists, bold, italic,
+// inline code and links. Everything else is shown as text. Raw HTML is always escaped
+var (
+	headingPattern     = regexp.MustCompile(`^(#{1,3})\s+(.*)$`)
+	bulletPattern      = regexp.MustCompile(`^\s*[-*]\s+(.*)$`)
+	orderedItemPattern = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	inlinePattern      = regexp.MustCompile("`([^`]+)`" + `|\[([^\]]+)\]\(([^)\s]+)\)|\*\*(.+?)\*\*|\*([^*]+)\*|\b_([^_]+)_\b`)
+)
+
+var linkSchemes = []string{"http", "https", "mailto"}
+
+// Markdown renders user written text such as rehearsal notes and announcements as HTML. The output is safe to
+// embed in pages since all text is escaped and only links to http, https and mailto addresses are created
+func Markdown(src string) template.HTML {
+	var out strings.Builder
+	for _, block := range markdownBlocks(src) {
+		writeBlock(&out, block)
+	}
+	return template.HTML(out.String())
+}
+
+func markdownBlocks(src string) [][]string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	var blocks [][]string
+	var current []string
+	for line := range strings.SplitSeq(src, "\n") {
+		if strings.TrimSpace(line) == "" {
+			if len(current) > 0 {
+				blocks = append(blocks, current)
+			}
+			current = nil
+			continue
+		}
+		current = append(current, strings.TrimRight(line, " \t"))
+	}
+	if len(current) > 0 {
+		blocks = append(blocks, current)
+	}
+	return blocks
+}
+
+func allMatch(lines []string, pattern *regexp.Regexp) bool {
+	for _, line := range lines {
+		if !pattern.MatchString(line) {
+			return false
+		}
+	}
+	return true
+}
+
+func writeBlock(out *strings.Builder, lines []string) {
+	if len(lines) == 1 {
+		if m := headingPattern.FindStringSubmatch(lines[0]); m != nil {
+			// Headings start at h3 since notes are shown inside pages that already have headings
+			tag := "h" + string(rune('2'+len(m[1])))
+			out.WriteString("<" + tag + ">" + renderInline(m[2]) + "</" + tag + ">")
+			return
+		}
+	}
+
+	switch {
+	case allMatch(lines, bulletPattern):
+		writeList(out, "ul", lines, bulletPattern)
+	case allMatch(lines, orderedItemPattern):
+		writeList(out, "ol", lines, orderedItemPattern)
+	default:
+		rendered := make([]string, len(lines))
+		for i, line := range lines {
+			rendered[i] = renderInline(strings.TrimSpace(line))
+		}
+		out.WriteString("<p>" + strings.Join(rendered, "<br>") + "</p>")
+	}
+}
+
+func writeList(out *strings.Builder, tag string, lines []string, pattern *regexp.Regexp) {
+	out.WriteString("<" + tag + ">")
+	for _, line := range lines {
+		out.WriteString("<li>" + renderInline(pattern.FindStringSubmatch(line)[1]) + "</li>")
+	}
+	out.WriteString("</" + tag + ">")
+}
+
+func renderInline(text string) string {
+	var out strings.Builder
+	last := 0
+	for _, m := range inlinePattern.FindAllStringSubmatchIndex(text, -1) {
+		out.WriteString(html.EscapeString(text[last:m[0]]))
+		last = m[1]
+		group := func(i int) string { return text[m[2*i]:m[2*i+1]] }
+
+		switch {
+		case m[2] >= 0:
+			out.WriteString("<code>" + html.EscapeString(group(1)) + "</code>")
+		case m[4] >= 0:
+			out.WriteString(renderLink(group(2), group(3)))
+		case m[8] >= 0:
+			out.WriteString("<strong>" + renderInline(group(4)) + "</strong>")
+		case m[10] >= 0:
+			out.WriteString("<em>" + renderInline(group(5)) + "</em>")
+		default:
+			out.WriteString("<em>" + renderInline(group(6)) + "</em>")
+		}
+	}
+	out.WriteString(html.EscapeString(text[last:]))
+	return out.String()
+}
+
+// renderLink creates a link if the target is an absolute address with an allowed scheme. Other targets, like
+// javascript: URLs, are shown as text
+func renderLink(label, target string) string {
+	parsed, err := url.Parse(target)
+	if err != nil || !slices.Contains(linkSchemes, parsed.Scheme) {
+		return html.EscapeString("[" + label + "](" + target + ")")
+	}
+	return `<a href="` + html.EscapeString(parsed.String()) + `" rel="nofollow noopener noreferrer" target="_blank">` + renderInline(label) + "</a>"
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+var hrefPattern = regexp.MustCompile(`href="([^"]*)"`)
+
+func TestMarkdown(t *testing.T) {
+	for _, test := range []struct {
+		desc string
+		src  string
+		want string
+	}{
+		{"empty", "", ""},
+		{"paragraphs", "First line\r\nsecond line\n\nNext", "<p>First line<br>second line</p><p>Next</p>"},
+		{"heading", "## Bar 12", "<h4>Bar 12</h4>"},
+		{"bullets", "- Trumpets\n* Horns", "<ul><li>Trumpets</li><li>Horns</li></ul>"},
+		{"ordered", "1. Intro\n2) Coda", "<ol><li>Intro</li><li>Coda</li></ol>"},
+		{"emphasis", "**loud** and *soft* and _quiet_", "<p><strong>loud</strong> and <em>soft</em> and <em>quiet</em></p>"},
+		{"underscores in words", "snake_case_name", "<p>snake_case_name</p>"},
+		{"code", "`<b>` stays", "<p><code>&lt;b&gt;</code> stays</p>"},
+		{"link", "[score](https://example.com/a?b=1&c=2)", `<p><a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener noreferrer" target="_blank">score</a></p>`},
+		{"mail", "[mail](mailto:a@b.no)", `<p><a href="mailto:a@b.no" rel="nofollow noopener noreferrer" target="_blank">mail</a></p>`},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			testutils.AssertEqual(t, string(Markdown(test.src)), test.want)
+		})
+	}
+}
+
+func TestMarkdownEscapesXSSVectors(t *testing.T) {
+	for _, src := range []string{
+		"<script>alert(1)</script>",
+		"<img src=x onerror=alert(1)>",
+		"[click](javascript:alert(1))",
+		"[click](JaVaScRiPt:alert(1))",
+		"[click](data:text/html;base64,PHNjcmlwdD4=)",
+		"[click](vbscript:msgbox(1))",
+		`[x](https://a.no/"onmouseover="alert(1))`,
+		"[<svg onload=alert(1)>](https://a.no)",
+		"**<iframe src=javascript:alert(1)>**",
+		"- <a href='javascript:alert(1)'>x</a>",
+		"# <style>body{display:none}</style>",
+		"`</code><script>alert(1)</script>`",
+		"&lt;script&gt;",
+	} {
+		t.Run(src, func(t *testing.T) {
+			rendered := strings.ToLower(string(Markdown(src)))
+			for _, tag := range []string{"<script", "<img", "<svg", "<iframe", "<style", "<a href='"} {
+				if strings.Contains(rendered, tag) {
+					t.Fatalf("%q rendered as %q which contains %q", src, rendered, tag)
+				}
+			}
+			for _, href := range hrefPattern.FindAllStringSubmatch(rendered, -1) {
+				if !strings.HasPrefix(href[1], "https:") {
+					t.Fatalf("%q rendered with unsafe link %q", src, href[1])
+				}
+			}
+		})
+	}
+}
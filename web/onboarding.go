@@ -0,0 +1,35 @@
+package web
+
+import (
+	"io"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+type OnboardingFamily struct {
+	Name   string
+	Chosen bool
+}
+
+type OnboardingData struct {
+	Step pkg.OnboardingStep
+
+	// Position of the step, starting at 1
+	StepNumber int
+	NumSteps   int
+
+	// Instrument families to choose between in the instrument step
+	Families []OnboardingFamily
+
+	// Ids of the instrumentation presets that choose the families in the instrument step
+	Presets []string
+
+	// Link for inviting members, shown in the invite step
+	InviteLink string
+}
+
+// Onboarding renders the current step of the onboarding wizard of a new organization
+func Onboarding(w io.Writer, language string, data OnboardingData) {
+	tmpl := lookupTemplate("onboarding", language)
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "onboarding", data))
+}
@@ -0,0 +1,30 @@
+package web
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestOnboarding(t *testing.T) {
+	var buf bytes.Buffer
+	Onboarding(&buf, "nb", OnboardingData{
+		Step:       pkg.OnboardingStepInstruments,
+		StepNumber: 1,
+		NumSteps:   4,
+		Families:   []OnboardingFamily{{Name: "brass", Chosen: true}, {Name: "choir"}},
+		Presets:    []string{"brass-band"},
+	})
+	testutils.AssertContains(t, buf.String(), "Steg 1 / 4", "Velg instrumenter", `value="brass" checked`, "Messing", "Kor", `value="brass-band"`, "Brassband")
+	testutils.AssertNotContains(t, buf.String(), `value="choir" checked`)
+
+	buf.Reset()
+	Onboarding(&buf, "en", OnboardingData{Step: pkg.OnboardingStepInvite, StepNumber: 2, NumSteps: 4, InviteLink: "https://caesura.no/login?invite-token=abc"})
+	testutils.AssertContains(t, buf.String(), "Invite your members", "invite-token=abc", `hx-post="/onboarding/steps/invite"`, "Next")
+
+	buf.Reset()
+	Onboarding(&buf, "en", OnboardingData{Step: pkg.OnboardingStepEmail, StepNumber: 4, NumSteps: 4})
+	testutils.AssertContains(t, buf.String(), `href="/people"`, "Finish")
+}
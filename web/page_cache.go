@@ -0,0 +1,28 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// renderedPages holds pages that are the same for every visitor, keyed by page and language. The templates are
+// embedded in the binary, so the pages only change on a deploy, which starts with an empty cache
+var renderedPages sync.Map
+
+// cachedPage returns the page written by render, which is only called the first time the key is requested. Pages
+// are rendered on every request when templates are reloaded from a directory, such that edits show up
+func cachedPage(key string, render func(w io.Writer)) []byte {
+	if registry.reload {
+		var buf bytes.Buffer
+		render(&buf)
+		return buf.Bytes()
+	}
+	if page, ok := renderedPages.Load(key); ok {
+		return page.([]byte)
+	}
+	var buf bytes.Buffer
+	render(&buf)
+	page, _ := renderedPages.LoadOrStore(key, buf.Bytes())
+	return page.([]byte)
+}
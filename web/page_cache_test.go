@@ -0,0 +1,30 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestCachedPageRendersOnce(t *testing.T) {
+	calls := 0
+	render := func(w io.Writer) {
+		calls++
+		w.Write([]byte("page"))
+	}
+	for range 3 {
+		testutils.AssertEqual(t, string(cachedPage("test-page", render)), "page")
+	}
+	testutils.AssertEqual(t, calls, 1)
+}
+
+func TestLoginFormIsCachedPerProvider(t *testing.T) {
+	var withApple, withoutApple bytes.Buffer
+	LoginForm(&withApple, "en", LoginProviders{Apple: true})
+	LoginForm(&withoutApple, "en", LoginProviders{})
+	testutils.AssertContains(t, withApple.String(), "/login/apple")
+	testutils.AssertNotContains(t, withoutApple.String(), "/login/apple")
+	testutils.AssertEqual(t, bytes.Equal(Index("nb"), Index("en")), false)
+}
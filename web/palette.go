@@ -0,0 +1,69 @@
+package web
+
+import (
+	"io"
+	"strings"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+type PaletteKind string
+
+const (
+	PaletteAction  PaletteKind = "action"
+	PalettePiece   PaletteKind = "piece"
+	PaletteProject PaletteKind = "project"
+	PalettePerson  PaletteKind = "person"
+)
+
+// PaletteEntry is a destination offered by the command palette
+type PaletteEntry struct {
+	Kind   PaletteKind `json:"kind"`
+	Label  string      `json:"label"`
+	Detail string      `json:"detail,omitempty"`
+	Href   string      `json:"href"`
+}
+
+type paletteAction struct {
+	// Translation key of the label
+	Label string
+	Href  string
+
+	// Lowest role in the active organization that may open the page
+	Role pkg.RoleKind
+}
+
+var paletteActions = []paletteAction{
+	{Label: "nav.home", Href: "/", Role: pkg.RoleViewer},
+	{Label: "nav.overview", Href: "/overview", Role: pkg.RoleViewer},
+	{Label: "bulk-edit.title", Href: "/overview/bulk-edit", Role: pkg.RoleEditor},
+	{Label: "nav.upload", Href: "/upload", Role: pkg.RoleEditor},
+	{Label: "nav.projects", Href: "/projects", Role: pkg.RoleViewer},
+	{Label: "nav.people", Href: "/people", Role: pkg.RoleViewer},
+	{Label: "nav.organizations", Href: "/organizations/form", Role: pkg.RoleViewer},
+	{Label: "palette.branding", Href: "/organizations/form#branding", Role: pkg.RoleAdmin},
+	{Label: "palette.log-export", Href: "/organizations/form#log-export-form", Role: pkg.RoleAdmin},
+	{Label: "palette.security", Href: "/organizations/form", Role: pkg.RoleViewer},
+	{Label: "nav.about", Href: "/about", Role: pkg.RoleViewer},
+}
+
+// PaletteActions returns the pages that the role may open, with a translated label containing the query. All
+// pages are returned for an empty query
+func PaletteActions(language string, role pkg.RoleKind, query string) []PaletteEntry {
+	translate := translateFunc(language)
+	query = strings.ToLower(strings.TrimSpace(query))
+	entries := []PaletteEntry{}
+	for _, action := range paletteActions {
+		label := translate(action.Label)
+		if role.AtLeast(action.Role) && strings.Contains(strings.ToLower(label), query) {
+			entries = append(entries, PaletteEntry{Kind: PaletteAction, Label: label, Href: action.Href})
+		}
+	}
+	return entries
+}
+
+// CommandPalette renders the entries as a list of links
+func CommandPalette(w io.Writer, language string, entries []PaletteEntry) {
+	tmpl := lookupTemplate("palette", language)
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "palette-results", entries))
+}
@@ -0,0 +1,30 @@
+package web
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestPaletteActions(t *testing.T) {
+	viewer := PaletteActions("en", pkg.RoleViewer, "")
+	admin := PaletteActions("en", pkg.RoleAdmin, "")
+	testutils.AssertEqual(t, len(viewer) < len(admin), true)
+
+	entries := PaletteActions("nb", pkg.RoleEditor, " LAST OPP")
+	testutils.AssertEqual(t, len(entries), 1)
+	testutils.AssertEqual(t, entries[0].Href, "/upload")
+	testutils.AssertEqual(t, len(PaletteActions("nb", pkg.RoleViewer, "last opp")), 0)
+}
+
+func TestCommandPalette(t *testing.T) {
+	var buf bytes.Buffer
+	CommandPalette(&buf, "en", []PaletteEntry{{Kind: PalettePiece, Label: "Alpine <Symphony>", Detail: "Strauss", Href: "/overview?q=Alpine"}})
+	testutils.AssertContains(t, buf.String(), "Alpine &lt;Symphony&gt;", "Strauss · Piece", `href="/overview?q=Alpine"`)
+
+	buf.Reset()
+	CommandPalette(&buf, "nb", nil)
+	testutils.AssertContains(t, buf.String(), "Ingen treff")
+}
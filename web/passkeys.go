@@ -0,0 +1,21 @@
+package web
+
+import (
+	"io"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+type PasskeysData struct {
+	Passkeys []pkg.Passkey
+
+	// The user is an admin that can choose whether the active organization requires passkeys
+	CanRequire bool
+	Required   bool
+}
+
+// Passkeys renders the passkeys of the signed in user
+func Passkeys(w io.Writer, language string, data PasskeysData) {
+	tmpl := lookupTemplate("passkeys", language)
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "passkeys", data))
+}
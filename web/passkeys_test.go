@@ -0,0 +1,27 @@
+package web
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestPasskeys(t *testing.T) {
+	lastUsed := time.Date(2025, 6, 1, 19, 30, 0, 0, time.UTC)
+	passkeys := []pkg.Passkey{{Id: "abc", Name: "<Laptop>", LastUsedAt: lastUsed}}
+
+	var buf bytes.Buffer
+	Passkeys(&buf, "nb", PasskeysData{Passkeys: passkeys, CanRequire: true, Required: true})
+	testutils.AssertContains(
+		t, buf.String(), "Tilgangsnøkler", "&lt;Laptop&gt;", "2025-06-01 19:30", `hx-delete="/passkeys/abc"`,
+		`hx-put="/organizations/passkeys"`, "checked",
+	)
+
+	buf.Reset()
+	Passkeys(&buf, "en", PasskeysData{})
+	testutils.AssertContains(t, buf.String(), "You have not added any passkeys")
+	testutils.AssertNotContains(t, buf.String(), "require-passkey")
+}
@@ -0,0 +1,22 @@
+package web
+
+import (
+	"io"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+// ProblemReports renders the problems reported by members together with the choice of status of each report
+func ProblemReports(w io.Writer, language string, reports []pkg.ProblemReport) {
+	tmpl := lookupTemplate("problem-reports", language)
+	data := struct {
+		Reports       []pkg.ProblemReport
+		Statuses      []pkg.ProblemStatus
+		NumUnresolved int
+	}{
+		Reports:       reports,
+		Statuses:      pkg.ProblemStatuses,
+		NumUnresolved: pkg.NumUnresolvedProblems(reports),
+	}
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "problem-reports", data))
+}
@@ -0,0 +1,29 @@
+package web
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestProblemReports(t *testing.T) {
+	createdAt := time.Date(2025, 6, 1, 19, 30, 0, 0, time.UTC)
+	reports := []pkg.ProblemReport{
+		{Id: "1", ResourceTitle: "Polka", Part: "Horn.pdf", Kind: pkg.ProblemMissingPage, Description: "Page <2>", Status: pkg.ProblemOpen, CreatedAt: createdAt},
+		{Id: "2", ResourceTitle: "March", Kind: pkg.ProblemOther, Description: "Wrong title", Status: pkg.ProblemResolved, CreatedAt: createdAt},
+	}
+
+	var buf bytes.Buffer
+	ProblemReports(&buf, "nb", reports)
+	testutils.AssertContains(
+		t, buf.String(), "Meldte problemer (1)", "Polka &ndash; Horn.pdf", "Side mangler", "Page &lt;2&gt;", "2025-06-01 19:30",
+		`hx-put="/organizations/problems/2/status"`, `<option value="resolved" selected>Løst</option>`,
+	)
+
+	buf.Reset()
+	ProblemReports(&buf, "en", nil)
+	testutils.AssertContains(t, buf.String(), "No problems have been reported")
+}
@@ -0,0 +1,20 @@
+package web
+
+import (
+	"io"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+// ProjectTemplates renders the templates of the organization and the form for adding new ones
+func ProjectTemplates(w io.Writer, language string, templates []pkg.ProjectTemplate) {
+	data := struct{ Templates []pkg.ProjectTemplate }{Templates: templates}
+	pkg.PanicOnErr(lookupTemplate("project-templates", language).ExecuteTemplate(w, "project-templates", data))
+}
+
+// ProjectTemplateOptions renders the choice of template when pieces are added to a project. Nothing is
+// rendered when the organization has no templates
+func ProjectTemplateOptions(w io.Writer, language string, templates []pkg.ProjectTemplate) {
+	data := struct{ Templates []pkg.ProjectTemplate }{Templates: templates}
+	pkg.PanicOnErr(lookupTemplate("project-templates", language).ExecuteTemplate(w, "project-template-options", data))
+}
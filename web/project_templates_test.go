@@ -0,0 +1,39 @@
+package web
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestProjectTemplates(t *testing.T) {
+	var buf bytes.Buffer
+	templates := []pkg.ProjectTemplate{{Name: "Julekonsert", Categories: []string{"Julesanger", "Marsjer"}, Groups: []string{"Trompet"}}}
+	ProjectTemplates(&buf, "nb", templates)
+	testutils.AssertContains(
+		t, buf.String(), "Prosjektmaler", "Julekonsert", "Julesanger, Marsjer", "Trompet",
+		`hx-delete="/organizations/project-templates/julekonsert"`, `href="/organizations/layout"`, "Importer",
+	)
+}
+
+func TestProjectTemplateOptions(t *testing.T) {
+	var buf bytes.Buffer
+	ProjectTemplateOptions(&buf, "en", nil)
+	testutils.AssertNotContains(t, buf.String(), "<select")
+
+	ProjectTemplateOptions(&buf, "en", []pkg.ProjectTemplate{{Name: "Christmas concert"}})
+	testutils.AssertContains(t, buf.String(), `name="templateId"`, `<option value="christmasconcert">Christmas concert</option>`)
+}
+
+func TestProjectContentWithDefaults(t *testing.T) {
+	var buf bytes.Buffer
+	project := pkg.Project{Name: "Christmas 2026", Categories: []string{"Carols", "Marches"}, Groups: []string{"Trumpet"}, Announcement: "Welcome"}
+	ProjectContent(&buf, &project, nil, "en")
+	testutils.AssertContains(t, buf.String(), "Carols, Marches", "Distribution groups", "Trumpet", "Welcome")
+
+	buf.Reset()
+	ProjectContent(&buf, &pkg.Project{Name: "Rehearsal"}, nil, "en")
+	testutils.AssertNotContains(t, buf.String(), "project-defaults")
+}
@@ -0,0 +1,314 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/utils"
+)
+
+// pageFiles adds the header, footer and flash messages shared by all full pages
+func pageFiles(files ...string) []string {
+	return append(files, "header.html", "footer.html", "flash.html")
+}
+
+// templateFiles lists the files of each template set by the name of its root template. Sets rendered with Execute
+// are named after the file holding the template
+var templateFiles = map[string][]string{
+	"index-template":         pageFiles("index.html"),
+	"upload":                 pageFiles("upload.html"),
+	"overview":               {"overview.html", "header.html", "resource_table.html", "footer.html", "flash.html"},
+	"projects":               pageFiles("projects.html"),
+	"organizations":          {"organizations.html", "header.html", "organization_list.html", "footer.html", "flash.html"},
+	"people":                 pageFiles("people.html"),
+	"feature-metrics":        pageFiles("feature_metrics.html"),
+	"login":                  pageFiles("login.html"),
+	"resetPassword":          pageFiles("resetPassword.html"),
+	"contact":                pageFiles("about.html"),
+	"bulk-edit":              pageFiles("bulk_edit.html"),
+	"resource_list.html":     {"resource_list.html"},
+	"resource-suggestions":   {"resource_suggestions.html"},
+	"project-modal":          {"project_selection_modal.html"},
+	"project-query-input":    {"project_query_input.html"},
+	"project_list.html":      {"project_list.html"},
+	"project-content":        {"project_content.html", "resource_table.html"},
+	"resource_content.html":  {"resource_content.html", "problem_reports.html", "corrections.html"},
+	"organization_list.html": {"organization_list.html"},
+	"mock-oauth":             {"mock_oauth.html"},
+	"api-docs":               {"api_docs.html"},
+	"userList":               {"user_list.html", "options.html"},
+	"options.html":           {"options.html"},
+	"bulk_edit_rows.html":    {"bulk_edit_rows.html"},
+	"project-activity":       {"project_activity.html"},
+	"trash":                  {"trash.html"},
+	"part-names":             {"part_names.html"},
+	"load-more":              {"load_more.html"},
+	"resource-versions":      {"resource_versions.html"},
+	"announcements":          {"announcements.html"},
+	"account":                {"account.html"},
+	"api-tokens":             {"api_tokens.html"},
+	"branding_logo.html":     {"branding_logo.html"},
+	"branding-form":          {"branding_form.html"},
+	"corrections":            {"corrections.html"},
+	"dashboard":              {"dashboard.html"},
+	"guest":                  {"guest.html"},
+	"hint":                   {"hint.html"},
+	"onboarding":             {"onboarding.html"},
+	"palette":                {"palette.html"},
+	"passkeys":               {"passkeys.html"},
+	"problem-reports":        {"problem_reports.html"},
+	"project-templates":      {"project_templates.html"},
+	"user-sessions":          {"user_sessions.html"},
+	"webhook":                {"webhooks.html"},
+}
+
+// templateFuncs are the functions available to every template set. Pages also get their title and breadcrumbs
+func templateFuncs(tr *Translator, name, language string) template.FuncMap {
+	funcs := pageFuncs(tr, name, language)
+	funcs["pathEscape"] = url.PathEscape
+	funcs["getRoleName"] = RoleName
+	funcs["Join"] = func(items []string) string { return strings.Join(items, ", ") }
+	return funcs
+}
+
+type templateKey struct {
+	name     string
+	language string
+}
+
+type compiledTemplate struct {
+	tmpl     *template.Template
+	parsedAt time.Time
+}
+
+// TemplateRegistry holds every template set compiled once for each language, such that requests only execute them.
+// The templates are compiled with the translations next to them, which must cover every key the templates use
+type TemplateRegistry struct {
+	fsys fs.FS
+
+	// reload parses a set again when one of its files, or the translations, has changed since it was parsed.
+	// Embedded files never change, so it is only useful when the templates are read from a directory
+	reload bool
+
+	mu           sync.RWMutex
+	translator   *Translator
+	translatedAt time.Time
+	compiled     map[templateKey]compiledTemplate
+}
+
+func parseTemplateSet(fsys fs.FS, tr *Translator, name, language string) (*template.Template, error) {
+	files, ok := templateFiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown template set %s", name)
+	}
+	return template.New(name).Funcs(templateFuncs(tr, name, language)).ParseFS(fsys, files...)
+}
+
+// usedTranslationKeys returns the keys translated with literals in the templates and the page titles
+func usedTranslationKeys(fsys fs.FS) ([]string, error) {
+	var keys []string
+	for _, nav := range pageNavs {
+		if nav.Title != "" {
+			keys = append(keys, nav.Title)
+		}
+		for _, crumb := range nav.Breadcrumbs {
+			keys = append(keys, crumb.Label)
+		}
+	}
+
+	read := make(map[string]bool)
+	for _, files := range templateFiles {
+		for _, file := range files {
+			if read[file] {
+				continue
+			}
+			read[file] = true
+			content, err := fs.ReadFile(fsys, file)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, TemplateTranslationKeys(string(content))...)
+		}
+	}
+	return pkg.RemoveDuplicates(keys), nil
+}
+
+// missingTranslations returns the keys each language of tr lacks, or nil when all languages are complete
+func missingTranslations(fsys fs.FS, tr *Translator) (map[string][]string, error) {
+	used, err := usedTranslationKeys(fsys)
+	if err != nil {
+		return nil, err
+	}
+	missing := tr.MissingKeys(used)
+	for _, keys := range missing {
+		if len(keys) > 0 {
+			return missing, nil
+		}
+	}
+	return nil, nil
+}
+
+// NewTemplateRegistry compiles all template sets in fsys for all languages of the translations in fsys. Translations
+// that lack keys are rejected, such that an incomplete language fails when the server starts
+func NewTemplateRegistry(fsys fs.FS, reload bool) (*TemplateRegistry, error) {
+	tr, err := NewTranslatorFS(fsys)
+	if err != nil {
+		return nil, err
+	}
+	missing, err := missingTranslations(fsys, tr)
+	if err != nil {
+		return nil, err
+	}
+	if missing != nil {
+		return nil, fmt.Errorf("incomplete translations: %v", missing)
+	}
+
+	now := time.Now()
+	registry := &TemplateRegistry{
+		fsys:         fsys,
+		reload:       reload,
+		translator:   tr,
+		translatedAt: now,
+		compiled:     make(map[templateKey]compiledTemplate),
+	}
+	for name := range templateFiles {
+		for _, language := range tr.Languages() {
+			tmpl, err := parseTemplateSet(fsys, tr, name, language)
+			if err != nil {
+				return nil, err
+			}
+			registry.compiled[templateKey{name, language}] = compiledTemplate{tmpl: tmpl, parsedAt: now}
+		}
+	}
+	return registry, nil
+}
+
+// MissingTranslations returns the keys each language lacks. It is empty unless the translations were edited after
+// the templates were read from a directory
+func (r *TemplateRegistry) MissingTranslations() (map[string][]string, error) {
+	r.mu.RLock()
+	tr := r.translator
+	r.mu.RUnlock()
+	used, err := usedTranslationKeys(r.fsys)
+	if err != nil {
+		return nil, err
+	}
+	return tr.MissingKeys(used), nil
+}
+
+// refreshTranslations reads the translations again when the file has changed. Translations that can not be read
+// are logged and the previous ones are kept. Missing keys are logged, since they show as the key itself
+func (r *TemplateRegistry) refreshTranslations() {
+	info, err := fs.Stat(r.fsys, translationsFile)
+	r.mu.RLock()
+	stale := err == nil && info.ModTime().After(r.translatedAt)
+	r.mu.RUnlock()
+	if !stale {
+		return
+	}
+
+	loadedAt := time.Now()
+	tr, err := NewTranslatorFS(r.fsys)
+	if err != nil {
+		slog.Error("Could not read changed translations. Using the previous version", "error", err)
+	} else if missing, err := missingTranslations(r.fsys, tr); err == nil && missing != nil {
+		slog.Warn("Translations are incomplete", "missing", missing)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.translatedAt = loadedAt
+	if tr != nil {
+		r.translator = tr
+	}
+}
+
+// changedSince reports whether the translations or a file of the set was modified after t
+func (r *TemplateRegistry) changedSince(name string, t time.Time) bool {
+	r.mu.RLock()
+	translatedAt := r.translatedAt
+	r.mu.RUnlock()
+	if translatedAt.After(t) {
+		return true
+	}
+	for _, file := range templateFiles[name] {
+		info, err := fs.Stat(r.fsys, file)
+		if err == nil && info.ModTime().After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns the template set compiled for the language. Unknown languages get the english templates, which
+// is also what the translations fall back to. Sets without translated text are looked up with an empty language
+func (r *TemplateRegistry) Lookup(name, language string) *template.Template {
+	key := templateKey{name, language}
+	r.mu.RLock()
+	compiled, ok := r.compiled[key]
+	if !ok {
+		key.language = "en"
+		compiled, ok = r.compiled[key]
+	}
+	r.mu.RUnlock()
+	if !ok {
+		panic(fmt.Sprintf("unknown template set %s", name))
+	}
+
+	if !r.reload {
+		return compiled.tmpl
+	}
+
+	r.refreshTranslations()
+	if r.changedSince(name, compiled.parsedAt) {
+		parsedAt := time.Now()
+		r.mu.RLock()
+		tr := r.translator
+		r.mu.RUnlock()
+		tmpl, err := parseTemplateSet(r.fsys, tr, name, key.language)
+		if err != nil {
+			// The broken version is not parsed again until the file is saved once more
+			slog.Error("Could not parse changed template. Using the previous version", "name", name, "error", err)
+			tmpl = compiled.tmpl
+		}
+		r.mu.Lock()
+		r.compiled[key] = compiledTemplate{tmpl: tmpl, parsedAt: parsedAt}
+		r.mu.Unlock()
+		return tmpl
+	}
+	return compiled.tmpl
+}
+
+// registry holds the embedded templates unless the templates are read from a directory during development
+var registry = utils.Must(NewTemplateRegistry(embeddedTemplates, false))
+
+var embeddedTemplates = utils.Must(fs.Sub(templatesFS, "templates"))
+
+// ReloadTemplatesFrom reads the templates from a directory and parses them again when they change, such that
+// edits show up without restarting the server. Pages otherwise rendered once are rendered on every request. It
+// must be called before the server starts
+func ReloadTemplatesFrom(dir string) error {
+	reloading, err := NewTemplateRegistry(os.DirFS(dir), true)
+	if err != nil {
+		return err
+	}
+	registry = reloading
+	return nil
+}
+
+func lookupTemplate(name, language string) *template.Template {
+	return registry.Lookup(name, language)
+}
+
+// MissingTranslations returns the keys each language of the templates in use lacks
+func MissingTranslations() (map[string][]string, error) {
+	return registry.MissingTranslations()
+}
@@ -0,0 +1,166 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+	"github.com/davidkleiven/caesura/utils"
+)
+
+func TestRegistryCompilesAllSetsForAllLanguages(t *testing.T) {
+	reg, err := NewTemplateRegistry(embeddedTemplates, false)
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, len(reg.compiled), len(templateFiles)*len(translator.Languages()))
+}
+
+func TestRegistryLookupIsTranslated(t *testing.T) {
+	var en, nb, unknown bytes.Buffer
+	pkg.PanicOnErr(registry.Lookup("hint", "en").ExecuteTemplate(&en, "hint", "overview"))
+	pkg.PanicOnErr(registry.Lookup("hint", "nb").ExecuteTemplate(&nb, "hint", "overview"))
+	pkg.PanicOnErr(registry.Lookup("hint", "xx").ExecuteTemplate(&unknown, "hint", "overview"))
+
+	testutils.AssertContains(t, en.String(), translator.MustGet("en", "hint.dismiss"))
+	testutils.AssertContains(t, nb.String(), translator.MustGet("nb", "hint.dismiss"))
+	testutils.AssertEqual(t, unknown.String(), en.String())
+}
+
+func TestRegistryLookupUnknownSetPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected a panic")
+		}
+	}()
+	registry.Lookup("does-not-exist", "en")
+}
+
+func copyTemplates(t *testing.T) string {
+	dir := t.TempDir()
+	testutils.AssertNil(t, os.CopyFS(dir, embeddedTemplates))
+	return dir
+}
+
+func TestRegistryReloadsChangedTemplate(t *testing.T) {
+	dir := copyTemplates(t)
+	reg, err := NewTemplateRegistry(os.DirFS(dir), true)
+	testutils.AssertNil(t, err)
+
+	file := filepath.Join(dir, "hint.html")
+	testutils.AssertNil(t, os.WriteFile(file, []byte(`{{ define "hint" }}changed {{ . }}{{ end }}`), 0o644))
+	later := time.Now().Add(time.Minute)
+	testutils.AssertNil(t, os.Chtimes(file, later, later))
+
+	var buf bytes.Buffer
+	pkg.PanicOnErr(reg.Lookup("hint", "en").ExecuteTemplate(&buf, "hint", "overview"))
+	testutils.AssertEqual(t, buf.String(), "changed overview")
+}
+
+func TestRegistryKeepsPreviousTemplateOnParseError(t *testing.T) {
+	dir := copyTemplates(t)
+	reg, err := NewTemplateRegistry(os.DirFS(dir), true)
+	testutils.AssertNil(t, err)
+
+	file := filepath.Join(dir, "hint.html")
+	testutils.AssertNil(t, os.WriteFile(file, []byte(`{{ define "hint" }}{{ .`), 0o644))
+	later := time.Now().Add(time.Minute)
+	testutils.AssertNil(t, os.Chtimes(file, later, later))
+
+	var buf bytes.Buffer
+	pkg.PanicOnErr(reg.Lookup("hint", "en").ExecuteTemplate(&buf, "hint", "overview"))
+	testutils.AssertContains(t, buf.String(), `id="hint-overview"`)
+}
+
+func TestRegistryWithoutReloadIgnoresChanges(t *testing.T) {
+	dir := copyTemplates(t)
+	reg, err := NewTemplateRegistry(os.DirFS(dir), false)
+	testutils.AssertNil(t, err)
+
+	file := filepath.Join(dir, "hint.html")
+	testutils.AssertNil(t, os.WriteFile(file, []byte(`{{ define "hint" }}changed{{ end }}`), 0o644))
+	later := time.Now().Add(time.Minute)
+	testutils.AssertNil(t, os.Chtimes(file, later, later))
+
+	var buf bytes.Buffer
+	pkg.PanicOnErr(reg.Lookup("hint", "en").ExecuteTemplate(&buf, "hint", "overview"))
+	testutils.AssertContains(t, buf.String(), `id="hint-overview"`)
+}
+
+func TestRegistryRejectsIncompleteTranslations(t *testing.T) {
+	dir := copyTemplates(t)
+	hint := utils.Must(os.ReadFile(filepath.Join(dir, "hint.html")))
+	hint = bytes.Replace(hint, []byte(`T "hint.dismiss"`), []byte(`T "hint.not-translated"`), 1)
+	testutils.AssertNil(t, os.WriteFile(filepath.Join(dir, "hint.html"), hint, 0o644))
+
+	_, err := NewTemplateRegistry(os.DirFS(dir), true)
+	testutils.AssertContains(t, err.Error(), "incomplete translations", "hint.not-translated")
+}
+
+func TestRegistryReloadsChangedTranslations(t *testing.T) {
+	dir := copyTemplates(t)
+	reg, err := NewTemplateRegistry(os.DirFS(dir), true)
+	testutils.AssertNil(t, err)
+
+	file := filepath.Join(dir, translationsFile)
+	translations := utils.Must(os.ReadFile(file))
+	dismiss := translator.MustGet("en", "hint.dismiss")
+	translations = bytes.Replace(translations, []byte("hint.dismiss: "+dismiss), []byte("hint.dismiss: Dismiss tip"), 1)
+	translations = bytes.Replace(translations, []byte("  hint.title:"), []byte("  hint.renamed:"), 1)
+	testutils.AssertNil(t, os.WriteFile(file, translations, 0o644))
+	later := time.Now().Add(time.Minute)
+	testutils.AssertNil(t, os.Chtimes(file, later, later))
+
+	var buf bytes.Buffer
+	pkg.PanicOnErr(reg.Lookup("hint", "en").ExecuteTemplate(&buf, "hint", "overview"))
+	testutils.AssertContains(t, buf.String(), "Dismiss tip")
+
+	missing, err := reg.MissingTranslations()
+	testutils.AssertNil(t, err)
+	testutils.AssertEqual(t, strings.Join(missing["en"], ","), "hint.title")
+}
+
+// The benchmarks compare parsing the templates on every request, as the render functions used to, with executing
+// the templates compiled by the registry
+
+func BenchmarkOverviewParsedPerRequest(b *testing.B) {
+	deps := LoadDependencies().Dependencies
+	for b.Loop() {
+		tmpl := utils.Must(parseTemplateSet(embeddedTemplates, translator, "overview", "en"))
+		pkg.PanicOnErr(tmpl.ExecuteTemplate(io.Discard, "overview", deps))
+	}
+}
+
+func BenchmarkOverviewFromRegistry(b *testing.B) {
+	deps := LoadDependencies().Dependencies
+	for b.Loop() {
+		pkg.PanicOnErr(registry.Lookup("overview", "en").ExecuteTemplate(io.Discard, "overview", deps))
+	}
+}
+
+func benchmarkMetaData() []pkg.MetaData {
+	metaData := make([]pkg.MetaData, 50)
+	for i := range metaData {
+		metaData[i] = pkg.MetaData{Title: "Symphony", Composer: "Beethoven", Arranger: "Mahler"}
+	}
+	return metaData
+}
+
+func BenchmarkResourceListParsedPerRequest(b *testing.B) {
+	data := ResourceListData{MetaData: benchmarkMetaData(), CheckboxVisible: true, PatchVisible: true}
+	for b.Loop() {
+		tmpl := utils.Must(parseTemplateSet(embeddedTemplates, translator, "resource_list.html", ""))
+		pkg.PanicOnErr(tmpl.Execute(io.Discard, data))
+	}
+}
+
+func BenchmarkResourceListFromRegistry(b *testing.B) {
+	metaData := benchmarkMetaData()
+	for b.Loop() {
+		ResourceList(io.Discard, metaData)
+	}
+}
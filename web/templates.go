@@ -3,8 +3,10 @@ package web
 import (
 	"bytes"
 	"embed"
+	"fmt"
 	"html/template"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/davidkleiven/caesura/pkg"
@@ -21,24 +23,90 @@ type ScoreMetaData struct {
 }
 
 func translateFunc(language string) func(string) string {
-	return func(f string) string { return translator.MustGet(language, f) }
+	return translator.Func(language)
+}
+
+type Breadcrumb struct {
+	Label string
+	Href  string
+}
+
+// PageNav declares the title and breadcrumb trail of a page. Title and labels are translation keys.
+// The last breadcrumb is the current page and has no link
+type PageNav struct {
+	Title       string
+	Breadcrumbs []Breadcrumb
+}
+
+var homeCrumb = Breadcrumb{Label: "nav.home", Href: "/"}
+
+func crumbsTo(label string) []Breadcrumb {
+	return []Breadcrumb{homeCrumb, {Label: label}}
+}
+
+var pageNavs = map[string]PageNav{
+	"index-template":  {},
+	"upload":          {Title: "nav.upload", Breadcrumbs: crumbsTo("nav.upload")},
+	"overview":        {Title: "nav.overview", Breadcrumbs: crumbsTo("nav.overview")},
+	"projects":        {Title: "nav.projects", Breadcrumbs: crumbsTo("nav.projects")},
+	"organizations":   {Title: "nav.organizations", Breadcrumbs: crumbsTo("nav.organizations")},
+	"people":          {Title: "nav.people", Breadcrumbs: crumbsTo("nav.people")},
+	"feature-metrics": {Title: "metrics.title", Breadcrumbs: crumbsTo("metrics.title")},
+	"login":           {Title: "sign-in", Breadcrumbs: crumbsTo("sign-in")},
+	"contact":         {Title: "about.products", Breadcrumbs: crumbsTo("nav.about")},
+	"resetPassword": {
+		Title:       "resetPassword.header",
+		Breadcrumbs: []Breadcrumb{homeCrumb, {Label: "sign-in", Href: "/login"}, {Label: "resetPassword.header"}},
+	},
+	"bulk-edit": {
+		Title:       "bulk-edit.title",
+		Breadcrumbs: []Breadcrumb{homeCrumb, {Label: "nav.overview", Href: "/overview"}, {Label: "bulk-edit.title"}},
+	},
+}
+
+// NavFor returns the title and breadcrumbs of the page translated into language
+func NavFor(page, language string) PageNav {
+	return navFor(translator, page, language)
+}
+
+func navFor(tr *Translator, page, language string) PageNav {
+	translate := tr.Func(language)
+	nav := pageNavs[page]
+
+	translated := PageNav{Title: "Caesura"}
+	if nav.Title != "" {
+		translated.Title = translate(nav.Title) + " - Caesura"
+	}
+	for _, crumb := range nav.Breadcrumbs {
+		translated.Breadcrumbs = append(translated.Breadcrumbs, Breadcrumb{Label: translate(crumb.Label), Href: crumb.Href})
+	}
+	return translated
+}
+
+// pageFuncs returns the template functions available to full pages
+func pageFuncs(tr *Translator, page, language string) template.FuncMap {
+	nav := navFor(tr, page, language)
+	return template.FuncMap{
+		"T":           tr.Func(language),
+		"PageTitle":   func() string { return nav.Title },
+		"Breadcrumbs": func() []Breadcrumb { return nav.Breadcrumbs },
+		"Markdown":    Markdown,
+	}
 }
 
 func Upload(data *ScoreMetaData, language string) []byte {
-	tmpl := template.Must(
-		template.New("upload").
-			Funcs(template.FuncMap{"T": translateFunc(language)}).
-			ParseFS(templatesFS, "templates/upload.html", "templates/header.html", "templates/footer.html"),
-	)
+	tmpl := lookupTemplate("upload", language)
 	var buf bytes.Buffer
 
 	deps := LoadDependencies().Dependencies
 	templateData := struct {
 		ScoreMetaData *ScoreMetaData
 		Dependencies  *Dependencies
+		Presets       []pkg.InstrumentationPreset
 	}{
 		ScoreMetaData: data,
 		Dependencies:  &deps,
+		Presets:       pkg.DefaultInstrumentation().Presets,
 	}
 
 	pkg.PanicOnErr(tmpl.ExecuteTemplate(&buf, "upload", templateData))
@@ -49,25 +117,16 @@ func List() []byte {
 	return utils.Must(templatesFS.ReadFile("templates/list.html"))
 }
 
+// Index renders the start page. The page does not depend on the visitor, so it is only rendered once per language
 func Index(language string) []byte {
-	tmpl := template.Must(
-		template.New("index-template").
-			Funcs(template.FuncMap{"T": translateFunc(language)}).
-			ParseFS(templatesFS, "templates/index.html", "templates/header.html", "templates/footer.html"),
-	)
-
-	var buf bytes.Buffer
-
-	pkg.PanicOnErr(tmpl.ExecuteTemplate(&buf, "index-template", LoadDependencies().Dependencies))
-	return buf.Bytes()
+	return cachedPage("index-template/"+language, func(w io.Writer) {
+		tmpl := lookupTemplate("index-template", language)
+		pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "index-template", LoadDependencies().Dependencies))
+	})
 }
 
 func Overview(language string) []byte {
-	tmpl := template.Must(
-		template.New("overview").
-			Funcs(template.FuncMap{"T": translateFunc(language)}).
-			ParseFS(templatesFS, "templates/overview.html", "templates/header.html", "templates/resource_table.html", "templates/footer.html"),
-	)
+	tmpl := lookupTemplate("overview", language)
 	var buf bytes.Buffer
 	pkg.PanicOnErr(tmpl.ExecuteTemplate(&buf, "overview", LoadDependencies().Dependencies))
 	return buf.Bytes()
@@ -80,43 +139,37 @@ func ResourceList(w io.Writer, metaData []pkg.MetaData) {
 		PatchVisible:             true,
 		RemoveFromProjectVisible: false,
 	}
-	tmpl := template.Must(template.ParseFS(templatesFS, "templates/resource_list.html"))
+	tmpl := lookupTemplate("resource_list.html", "")
 	pkg.PanicOnErr(tmpl.Execute(w, data))
 }
 
+// ResourceSuggestions renders the suggestions as options of the datalist of the search box
+func ResourceSuggestions(w io.Writer, suggestions []pkg.Suggestion) {
+	tmpl := lookupTemplate("resource-suggestions", "")
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "resource-suggestions", suggestions))
+}
+
 func ProjectSelectorModal(language string) []byte {
-	tmpl := template.Must(
-		template.New("project-modal").
-			Funcs(template.FuncMap{"T": translateFunc(language)}).
-			ParseFS(templatesFS, "templates/project_selection_modal.html"),
-	)
+	tmpl := lookupTemplate("project-modal", language)
 	var buf bytes.Buffer
 	pkg.PanicOnErr(tmpl.ExecuteTemplate(&buf, "project-modal", LoadDependencies().Dependencies))
 	return buf.Bytes()
 }
 
 func ProjectQueryInput(w io.Writer, language, queryContent string) {
-	tmpl := template.Must(
-		template.New("project-query-input").
-			Funcs(template.FuncMap{"T": translateFunc(language)}).
-			ParseFS(templatesFS, "templates/project_query_input.html"),
-	)
+	tmpl := lookupTemplate("project-query-input", language)
 	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "project-query-input", queryContent))
 }
 
 func Projects(language string) []byte {
-	tmpl := template.Must(
-		template.New("projects").
-			Funcs(template.FuncMap{"T": translateFunc(language)}).
-			ParseFS(templatesFS, "templates/projects.html", "templates/header.html", "templates/footer.html"),
-	)
+	tmpl := lookupTemplate("projects", language)
 	var buf bytes.Buffer
 	pkg.PanicOnErr(tmpl.ExecuteTemplate(&buf, "projects", LoadDependencies().Dependencies))
 	return buf.Bytes()
 }
 
 func ProjectList(w io.Writer, projects []pkg.Project) {
-	tmpl := template.Must(template.ParseFS(templatesFS, "templates/project_list.html"))
+	tmpl := lookupTemplate("project_list.html", "")
 
 	data := make([]struct {
 		Name      string
@@ -137,17 +190,13 @@ func ProjectList(w io.Writer, projects []pkg.Project) {
 }
 
 func ProjectContent(w io.Writer, project *pkg.Project, resources []pkg.MetaData, language string) {
-	resourceTable := template.Must(
-		template.New("project-content").
-			Funcs(template.FuncMap{"T": translateFunc(language)}).
-			ParseFS(templatesFS, "templates/project_content.html", "templates/resource_table.html"),
-	)
+	resourceTable := lookupTemplate("project-content", language)
 
 	var resourceTableBuffer bytes.Buffer
 	pkg.PanicOnErr(resourceTable.ExecuteTemplate(&resourceTableBuffer, "project-content", project))
 
 	var buffer bytes.Buffer
-	rows := template.Must(template.ParseFS(templatesFS, "templates/resource_list.html"))
+	rows := lookupTemplate("resource_list.html", "")
 
 	data := ResourceListData{
 		MetaData:                 resources,
@@ -155,6 +204,7 @@ func ProjectContent(w io.Writer, project *pkg.Project, resources []pkg.MetaData,
 		PatchVisible:             false,
 		RemoveFromProjectVisible: true,
 		ProjectId:                project.Id(),
+		Notes:                    project.Notes,
 	}
 
 	pkg.PanicOnErr(rows.Execute(&buffer, data))
@@ -169,6 +219,9 @@ type ResourceListData struct {
 	CheckboxVisible          bool
 	PatchVisible             bool
 	RemoveFromProjectVisible bool
+
+	// Rehearsal notes keyed by resource id. The notes are only shown for projects
+	Notes map[string]string
 }
 
 type ResourceContentData struct {
@@ -176,17 +229,24 @@ type ResourceContentData struct {
 	Filenames  []string
 }
 
-func ResourceContent(w io.Writer, data *ResourceContentData) {
-	template := template.Must(template.ParseFS(templatesFS, "templates/resource_content.html"))
-	pkg.PanicOnErr(template.Execute(w, data))
+// ResourceContent renders the parts of a resource and the forms for reporting problems with them and proposing
+// corrections of the metadata
+func ResourceContent(w io.Writer, language string, data *ResourceContentData) {
+	tmpl := lookupTemplate("resource_content.html", language)
+	content := struct {
+		*ResourceContentData
+		Kinds            []pkg.ProblemKind
+		CorrectionFields []string
+	}{
+		ResourceContentData: data,
+		Kinds:               pkg.ProblemKinds,
+		CorrectionFields:    pkg.EditableMetaDataFields(),
+	}
+	pkg.PanicOnErr(tmpl.Execute(w, content))
 }
 
 func Organizations(language string) []byte {
-	tmpl := template.Must(
-		template.New("organizations").
-			Funcs(template.FuncMap{"T": translateFunc(language)}).
-			ParseFS(templatesFS, "templates/organizations.html", "templates/header.html", "templates/organization_list.html", "templates/footer.html"),
-	)
+	tmpl := lookupTemplate("organizations", language)
 	var buf bytes.Buffer
 
 	pkg.PanicOnErr(tmpl.ExecuteTemplate(&buf, "organizations", LoadDependencies()))
@@ -194,7 +254,7 @@ func Organizations(language string) []byte {
 }
 
 func WriteOrganizationHTML(w io.Writer, organizations []pkg.Organization) {
-	tmpl := template.Must(template.ParseFS(templatesFS, "templates/organization_list.html"))
+	tmpl := lookupTemplate("organization_list.html", "")
 	data := struct {
 		Organizations []pkg.Organization
 	}{
@@ -204,14 +264,43 @@ func WriteOrganizationHTML(w io.Writer, organizations []pkg.Organization) {
 }
 
 func WritePeopleHTML(w io.Writer, language string) {
-	tmpl := template.Must(
-		template.New("people").
-			Funcs(template.FuncMap{"T": translateFunc(language)}).
-			ParseFS(templatesFS, "templates/people.html", "templates/header.html", "templates/footer.html"),
-	)
+	tmpl := lookupTemplate("people", language)
 	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "people", LoadDependencies()))
 }
 
+func FeatureMetricsPage(w io.Writer, language string, counts []pkg.FeatureCount) {
+	tmpl := lookupTemplate("feature-metrics", language)
+	data := struct {
+		JsPackages
+		Counts []pkg.FeatureCount
+	}{
+		JsPackages: LoadDependencies(),
+		Counts:     counts,
+	}
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "feature-metrics", data))
+}
+
+type MockOAuthChoice struct {
+	Name  string
+	Email string
+	Link  string
+}
+
+func MockOAuthPage(w io.Writer, choices []MockOAuthChoice) {
+	tmpl := lookupTemplate("mock-oauth", "")
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "mock-oauth", choices))
+}
+
+type apiDocsData struct {
+	SpecURL          string
+	SwaggerUIVersion string
+}
+
+func ApiDocsPage(w io.Writer, specURL string) {
+	tmpl := lookupTemplate("api-docs", "")
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "api-docs", apiDocsData{SpecURL: specURL, SwaggerUIVersion: SwaggerUIVersion}))
+}
+
 type userListViewObj struct {
 	Id        string
 	Name      string
@@ -222,17 +311,14 @@ type userListViewObj struct {
 }
 
 func WriteUserList(w io.Writer, users []pkg.UserInfo, orgId string, groupOpts []string) {
-	tmpl := template.Must(
-		template.New("userList").Funcs(template.FuncMap{
-			"getRoleName": getRoleName,
-		}).ParseFS(templatesFS, "templates/user_list.html", "templates/options.html"),
-	)
+	tmpl := lookupTemplate("userList", "")
 	viewObj := make([]userListViewObj, len(users))
 
 	roleOpts := map[pkg.RoleKind][]pkg.RoleKind{
-		pkg.RoleViewer: {pkg.RoleViewer, pkg.RoleEditor, pkg.RoleAdmin},
-		pkg.RoleEditor: {pkg.RoleEditor, pkg.RoleAdmin, pkg.RoleViewer},
-		pkg.RoleAdmin:  {pkg.RoleAdmin, pkg.RoleViewer, pkg.RoleEditor},
+		pkg.RoleViewer:    {pkg.RoleViewer, pkg.RoleEditor, pkg.RoleLibrarian, pkg.RoleAdmin},
+		pkg.RoleEditor:    {pkg.RoleEditor, pkg.RoleViewer, pkg.RoleLibrarian, pkg.RoleAdmin},
+		pkg.RoleLibrarian: {pkg.RoleLibrarian, pkg.RoleViewer, pkg.RoleEditor, pkg.RoleAdmin},
+		pkg.RoleAdmin:     {pkg.RoleAdmin, pkg.RoleViewer, pkg.RoleEditor, pkg.RoleLibrarian},
 	}
 
 	opts := make([]Option, len(groupOpts))
@@ -255,12 +341,15 @@ func WriteUserList(w io.Writer, users []pkg.UserInfo, orgId string, groupOpts []
 	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "userList", viewObj))
 }
 
-func getRoleName(r pkg.RoleKind) string {
+// RoleName returns the name of the role shown to users
+func RoleName(r pkg.RoleKind) string {
 	switch r {
 	case pkg.RoleViewer:
 		return "Viewer"
 	case pkg.RoleEditor:
 		return "Editor"
+	case pkg.RoleLibrarian:
+		return "Librarian"
 	case pkg.RoleAdmin:
 		return "Admin"
 	default:
@@ -279,7 +368,7 @@ func WriteStringAsOptions(w io.Writer, items []string) {
 		options[i].Name = item
 		options[i].Value = item
 	}
-	tmpl := template.Must(template.ParseFS(templatesFS, "templates/options.html"))
+	tmpl := lookupTemplate("options.html", "")
 	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "option-list", options))
 }
 
@@ -296,6 +385,10 @@ func NoOrganization(lang string) string {
 	return translator.MustGet(lang, "no-org")
 }
 
+func Impersonating(lang string) string {
+	return translator.MustGet(lang, "impersonating")
+}
+
 func SubscriptionExpired(lang string) string {
 	return translator.MustGet(lang, "org.subscription-expired")
 }
@@ -304,18 +397,37 @@ func SubscriptionExpires(lang string) string {
 	return translator.MustGet(lang, "org.subscription-expires")
 }
 
+func MaintenanceBanner(lang string) string {
+	return `<div class="bg-yellow-400 text-sm text-center px-4 py-2" role="status">` +
+		template.HTMLEscapeString(translator.MustGet(lang, "maintenance")) + "</div>"
+}
+
 func MaxNumScoresReached(lang string) string {
 	return translator.MustGet(lang, "org.max-num-scores-reached")
 }
 
-func LoginForm(w io.Writer, language string) {
-	tmpl := template.Must(
-		template.New("login").
-			Funcs(template.FuncMap{"T": translateFunc(language)}).
-			ParseFS(templatesFS, "templates/login.html", "templates/header.html", "templates/footer.html"),
-	)
-
-	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "login", LoadDependencies()))
+// LoginProviders are the sign in providers that are only shown when configured
+type LoginProviders struct {
+	// Name of the OpenID Connect provider. The button is hidden when the name is empty
+	OIDCName string
+	Apple    bool
+}
+
+// LoginForm renders the sign in page. The page only depends on the language and the configured providers, so it
+// is rendered once for each of them
+func LoginForm(w io.Writer, language string, providers LoginProviders) {
+	key := fmt.Sprintf("login/%s/%s/%t", language, providers.OIDCName, providers.Apple)
+	w.Write(cachedPage(key, func(w io.Writer) {
+		tmpl := lookupTemplate("login", language)
+		data := struct {
+			JsPackages
+			LoginProviders
+		}{
+			JsPackages:     LoadDependencies(),
+			LoginProviders: providers,
+		}
+		pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "login", data))
+	}))
 }
 
 func MinimumPasswordLength(lang string) string {
@@ -359,6 +471,11 @@ func Unauthorized(lang string) string {
 	return translator.MustGet(lang, "login.unauthorized")
 }
 
+// LoginThrottled tells that sign-in is refused after too many failed attempts, and how long to wait
+func LoginThrottled(lang string, wait time.Duration) string {
+	return FlashMessage(lang, "login.throttled", struct{ Wait string }{Wait: wait.Round(time.Second).String()})
+}
+
 func EnterValidEmail(w io.Writer, lang string) {
 	w.Write([]byte(translator.MustGet(lang, "login.enter_valid_email")))
 }
@@ -376,11 +493,7 @@ func ResetEmailSent(w io.Writer, lang string, email string) {
 }
 
 func ResetPasswordPage(w io.Writer, lang string) {
-	tmpl := template.Must(
-		template.New("resetPassword").
-			Funcs(template.FuncMap{"T": translateFunc(lang)}).
-			ParseFS(templatesFS, "templates/resetPassword.html", "templates/header.html", "templates/footer.html"),
-	)
+	tmpl := lookupTemplate("resetPassword", lang)
 	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "resetPassword", LoadDependencies()))
 
 }
@@ -389,11 +502,199 @@ func CreateNewProject(lang string) string {
 	return translator.MustGet(lang, "project-modal.create-new")
 }
 
+// AboutUsPage renders the about page, which is rendered once per language
 func AboutUsPage(w io.Writer, lang string) {
-	tmpl := template.Must(
-		template.New("contact").
-			Funcs(template.FuncMap{"T": translateFunc(lang)}).
-			ParseFS(templatesFS, "templates/about.html", "templates/header.html", "templates/footer.html"),
-	)
-	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "contact", nil))
+	w.Write(cachedPage("contact/"+lang, func(w io.Writer) {
+		tmpl := lookupTemplate("contact", lang)
+		pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "contact", nil))
+	}))
+}
+
+func BulkEditPage(w io.Writer, language string) {
+	tmpl := lookupTemplate("bulk-edit", language)
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "bulk-edit", LoadDependencies().Dependencies))
+}
+
+type bulkEditCell struct {
+	Field string
+	Value string
+}
+
+type bulkEditRow struct {
+	pkg.MetaData
+	Cells []bulkEditCell
+}
+
+func bulkEditValue(meta *pkg.MetaData, field string) string {
+	switch field {
+	case "genre":
+		return meta.Genre
+	case "year":
+		return meta.Year
+	case "instrumentation":
+		return meta.Instrumentation
+	case "duration":
+		if meta.Duration == 0 {
+			return ""
+		}
+		return meta.Duration.String()
+	case "publisher":
+		return meta.Publisher
+	case "ismn":
+		return meta.Ismn
+	case "tags":
+		return meta.Tags
+	case "notes":
+		return meta.Notes
+	}
+	return ""
+}
+
+// BulkEditRows writes one table row per resource with an input for each of the editable fields
+func BulkEditRows(w io.Writer, metaData []pkg.MetaData) {
+	fields := pkg.EditableMetaDataFields()
+	rows := make([]bulkEditRow, len(metaData))
+	for i := range metaData {
+		rows[i].MetaData = metaData[i]
+		rows[i].Cells = make([]bulkEditCell, len(fields))
+		for j, field := range fields {
+			rows[i].Cells[j] = bulkEditCell{Field: field, Value: bulkEditValue(&metaData[i], field)}
+		}
+	}
+	tmpl := lookupTemplate("bulk_edit_rows.html", "")
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "bulk-edit-rows", rows))
+}
+
+var activityKeys = map[pkg.ActivityKind]string{
+	pkg.ActivityPieceAdded:   "activity.piece-added",
+	pkg.ActivityPieceRemoved: "activity.piece-removed",
+	pkg.ActivityDownload:     "activity.download",
+}
+
+type ActivityEntry struct {
+	Activity pkg.Activity
+	User     string
+	Pieces   []string
+}
+
+type activityItem struct {
+	Time        time.Time
+	Description string
+}
+
+type projectActivityData struct {
+	ProjectId string
+	Items     []activityItem
+	FirstPage bool
+	HasMore   bool
+	NextPage  int
+}
+
+// ProjectActivity writes one page of the activity feed of a project. The first page includes the heading,
+// later pages are appended in place of the button that loads them
+func ProjectActivity(w io.Writer, language, projectId string, entries []ActivityEntry, page int, hasMore bool) {
+	data := projectActivityData{
+		ProjectId: projectId,
+		Items:     make([]activityItem, len(entries)),
+		FirstPage: page == 0,
+		HasMore:   hasMore,
+		NextPage:  page + 1,
+	}
+	for i, entry := range entries {
+		key, ok := activityKeys[entry.Activity.Kind]
+		if !ok {
+			key = string(entry.Activity.Kind)
+		}
+		data.Items[i] = activityItem{
+			Time: entry.Activity.Time,
+			Description: FlashMessage(language, key, map[string]any{
+				"User":   entry.User,
+				"Count":  len(entry.Pieces),
+				"Pieces": strings.Join(entry.Pieces, ", "),
+			}),
+		}
+	}
+
+	tmpl := lookupTemplate("project-activity", language)
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "project-activity", data))
+}
+
+type trashItem struct {
+	pkg.MetaData
+	ResourceId string
+	PurgeAt    time.Time
+}
+
+// Trash writes the deleted resources with the time they are permanently removed. A retention of zero
+// means that resources are never purged
+func Trash(w io.Writer, language string, metaData []pkg.MetaData, retention time.Duration) {
+	data := struct {
+		Items []trashItem
+	}{
+		Items: make([]trashItem, len(metaData)),
+	}
+	for i, meta := range metaData {
+		data.Items[i] = trashItem{MetaData: meta, ResourceId: meta.ResourceId()}
+		if retention > 0 && !meta.DeletedAt.IsZero() {
+			data.Items[i].PurgeAt = meta.DeletedAt.Add(retention)
+		}
+	}
+
+	tmpl := lookupTemplate("trash", language)
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "trash", data))
+}
+
+// PartNameReport writes the part names that are inconsistent with the instruments of the organization, with
+// buttons that apply the suggested renames
+func PartNameReport(w io.Writer, language string, report *pkg.PartNameReport) {
+	tmpl := lookupTemplate("part-names", language)
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "part-names", report))
+}
+
+// LoadMoreRow writes a table row with a button replacing the row with the rows of the next page
+func LoadMoreRow(w io.Writer, language, url string, columns int) {
+	data := struct {
+		URL     string
+		Columns int
+	}{URL: url, Columns: columns}
+
+	tmpl := lookupTemplate("load-more", language)
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "load-more", data))
+}
+
+// ResourceVersions writes the earlier versions of a resource. Nothing is written when there are no versions
+func ResourceVersions(w io.Writer, language string, resourceId string, versions []pkg.ResourceVersion) {
+	data := struct {
+		ResourceId string
+		Versions   []pkg.ResourceVersion
+	}{
+		ResourceId: resourceId,
+		Versions:   versions,
+	}
+
+	tmpl := lookupTemplate("resource-versions", language)
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "resource-versions", data))
+}
+
+type announcementItem struct {
+	pkg.Announcement
+	Unread bool
+}
+
+// Announcements writes the announcements of an organization with the unread ones highlighted. Admins also
+// get the form for posting new announcements. Nothing is written when there is nothing to show
+func Announcements(w io.Writer, language string, announcements []pkg.Announcement, userId string, isAdmin bool) {
+	data := struct {
+		Items   []announcementItem
+		IsAdmin bool
+	}{
+		Items:   make([]announcementItem, len(announcements)),
+		IsAdmin: isAdmin,
+	}
+	for i, announcement := range announcements {
+		data.Items[i] = announcementItem{Announcement: announcement, Unread: !announcement.IsReadBy(userId)}
+	}
+
+	tmpl := lookupTemplate("announcements", language)
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "announcements", data))
 }
@@ -16,6 +16,7 @@ func TestUpload(t *testing.T) {
 	if !bytes.Contains(index, []byte("Composer")) {
 		t.Fatal("Expected index to contain 'Composer'")
 	}
+	testutils.AssertContains(t, string(index), `name="preset"`, `value="satb-choir"`, "SATB choir")
 }
 
 func TestList(t *testing.T) {
@@ -32,6 +33,7 @@ func TestOverview(t *testing.T) {
 	if !bytes.Contains(overview, []byte("Title")) {
 		t.Fatal("Expected overview to contain 'Title")
 	}
+	testutils.AssertContains(t, string(overview), `nextSortDirection("composer")`, `nextSortDirection("updated")`, "/js/bulk-actions.js", `id="bulk-delete-btn"`, `href="/resources/export?format=csv"`, `hx-post="/resources/import"`)
 }
 
 func TestResourceList(t *testing.T) {
@@ -45,6 +47,40 @@ func TestResourceList(t *testing.T) {
 	}
 }
 
+func TestResourceListColdStorageBadge(t *testing.T) {
+	var buf bytes.Buffer
+	ResourceList(&buf, []pkg.MetaData{
+		{Title: "Warm Title", Composer: "Test Composer"},
+	})
+	testutils.AssertNotContains(t, buf.String(), "Archived")
+
+	buf.Reset()
+	ResourceList(&buf, []pkg.MetaData{
+		{Title: "Cold Title", Composer: "Test Composer", StorageClass: pkg.StorageClassCold},
+	})
+	testutils.AssertContains(t, buf.String(), "Archived")
+}
+
+func TestResourceListProtectedBadge(t *testing.T) {
+	var buf bytes.Buffer
+	ResourceList(&buf, []pkg.MetaData{
+		{Title: "Rented Title", Protected: true},
+	})
+	testutils.AssertContains(t, buf.String(), "Protected")
+}
+
+func TestFeatureMetricsPage(t *testing.T) {
+	var buf bytes.Buffer
+	FeatureMetricsPage(&buf, "en", []pkg.FeatureCount{
+		{OrgId: "org1", Week: "2025-W24", Feature: pkg.FeatureUpload, Count: 42},
+	})
+	testutils.AssertContains(t, buf.String(), "2025-W24", "org1", "upload", "42", "Feature usage per week")
+
+	buf.Reset()
+	FeatureMetricsPage(&buf, "en", []pkg.FeatureCount{})
+	testutils.AssertContains(t, buf.String(), "No feature usage recorded")
+}
+
 func TestProjectSelectorModal(t *testing.T) {
 	projectSelector := ProjectSelectorModal("en")
 
@@ -107,6 +143,7 @@ func TestProjectContent(t *testing.T) {
 	project := &pkg.Project{
 		Name:        "Test Project",
 		ResourceIds: []string{resources[0].ResourceId()},
+		Notes:       map[string]string{resources[0].ResourceId(): "Start at <letter> **C**"},
 	}
 
 	ProjectContent(&buf, project, resources, "en")
@@ -120,6 +157,14 @@ func TestProjectContent(t *testing.T) {
 		"Arranger X",
 		"<tbody",
 		"</tbody>",
+		"/projects/testproject/" + resources[0].ResourceId() + "/notes",
+		"Start at &lt;letter&gt; **C**",
+		"<p>Start at &lt;letter&gt; <strong>C</strong></p>",
+		`id="project-rename-form"`,
+		`hx-post="/projects/testproject/merge"`,
+		`hx-put="/projects/testproject/order"`,
+		`form="project-order-form"`,
+		`action="/projects/testproject/export.pdf"`,
 	}
 
 	for _, exp := range expect {
@@ -127,17 +172,22 @@ func TestProjectContent(t *testing.T) {
 			t.Fatalf("Expected project content to contain '%s', but it did not", exp)
 		}
 	}
+	testutils.AssertNotContains(t, content, "nextSortDirection")
 }
 
 func TestResourceContent(t *testing.T) {
 	var buf bytes.Buffer
 	data := ResourceContentData{
 		ResourceId: "resource-id",
-		Filenames:  []string{"file.pdf", "file2.pdf"},
+		Filenames:  []string{"file.pdf", "file2.pdf", "Trumpet 1.pdf"},
 	}
 
-	ResourceContent(&buf, &data)
-	testutils.AssertContains(t, buf.String(), "resource-id", "file.pdf", "file2.pdf")
+	ResourceContent(&buf, "en", &data)
+	testutils.AssertContains(
+		t, buf.String(), "resource-id", "file.pdf", "file2.pdf", `hx-post="/resources/resource-id/problems"`, `<option value="file2.pdf">`,
+		"Missing page", `hx-post="/resources/resource-id/corrections"`, `<option value="ismn">ISMN</option>`,
+		`hx-patch="/resources/resource-id/files/Trumpet%201.pdf"`,
+	)
 }
 
 func TestOrganizations(t *testing.T) {
@@ -157,13 +207,13 @@ func TestOrganizationsList(t *testing.T) {
 
 func TestIndex(t *testing.T) {
 	index := string(Index("en"))
-	testutils.AssertContains(t, index, "</body>")
+	testutils.AssertContains(t, index, "</body>", `hx-get="/dashboard"`)
 }
 
 func TestPeopleHtml(t *testing.T) {
 	var buf bytes.Buffer
 	WritePeopleHTML(&buf, "en")
-	testutils.AssertContains(t, buf.String(), "</body>")
+	testutils.AssertContains(t, buf.String(), "</body>", `id="flashMessage"`, "data-dismiss-after")
 }
 
 func TestWriteUserList(t *testing.T) {
@@ -182,10 +232,14 @@ func TestWriteUserList(t *testing.T) {
 			Name:  "Susan",
 			Roles: map[string]pkg.RoleKind{orgId: 2},
 		},
+		{
+			Name:  "Mary",
+			Roles: map[string]pkg.RoleKind{orgId: pkg.RoleLibrarian},
+		},
 	}
 
 	WriteUserList(&buf, users, orgId, []string{"opt A", "opt B"})
-	testutils.AssertContains(t, buf.String(), "Peter", "John", "Susan")
+	testutils.AssertContains(t, buf.String(), "Peter", "John", "Susan", "Mary", `value="3"`, "Librarian")
 }
 
 func TestWriteStringAsOptions(t *testing.T) {
@@ -204,14 +258,31 @@ func TestSignedIn(t *testing.T) {
 	testutils.AssertEqual(t, SignedIn("en"), "Signed in")
 }
 
+func TestMaintenanceBanner(t *testing.T) {
+	testutils.AssertContains(t, MaintenanceBanner("en"), "problems with our storage provider", `role="status"`)
+}
+
 func TestNoOrganization(t *testing.T) {
 	testutils.AssertEqual(t, NoOrganization("en"), "No organization")
 }
 
 func TestLoginForm(t *testing.T) {
 	var buf bytes.Buffer
-	LoginForm(&buf, "en")
+	LoginForm(&buf, "en", LoginProviders{})
 	testutils.AssertContains(t, buf.String(), "Caesura")
+	testutils.AssertNotContains(t, buf.String(), "/login/oidc", "/login/apple")
+}
+
+func TestLoginFormWithOIDC(t *testing.T) {
+	var buf bytes.Buffer
+	LoginForm(&buf, "en", LoginProviders{OIDCName: "Keycloak"})
+	testutils.AssertContains(t, buf.String(), "/login/oidc", "Continue with Keycloak")
+}
+
+func TestLoginFormWithApple(t *testing.T) {
+	var buf bytes.Buffer
+	LoginForm(&buf, "en", LoginProviders{Apple: true})
+	testutils.AssertContains(t, buf.String(), "/login/apple", "Continue with Apple")
 }
 
 func TestUserNotFound(t *testing.T) {
@@ -242,6 +313,11 @@ func TestUnauthorized(t *testing.T) {
 	testutils.AssertEqual(t, txt, "Email or password is not valid")
 }
 
+func TestLoginThrottled(t *testing.T) {
+	txt := LoginThrottled("en", 14*time.Minute+1500*time.Millisecond)
+	testutils.AssertEqual(t, txt, "Too many failed sign-in attempts. Try again in 14m2s")
+}
+
 func TestEnterValidEmail(t *testing.T) {
 	var buf bytes.Buffer
 	EnterValidEmail(&buf, "en")
@@ -265,3 +341,150 @@ func TestLoadAboutPage(t *testing.T) {
 	AboutUsPage(&buf, "en")
 	testutils.AssertContains(t, buf.String(), "Caesura Free")
 }
+
+func TestMockOAuthPage(t *testing.T) {
+	var buf bytes.Buffer
+	MockOAuthPage(&buf, []MockOAuthChoice{{Name: "Susan", Email: "susan@example.com", Link: "/auth/callback?code=1&state=abc"}})
+	testutils.AssertContains(t, buf.String(), "Susan", "susan@example.com", "/auth/callback?code=1&amp;state=abc")
+}
+
+func TestApiDocsPage(t *testing.T) {
+	var buf bytes.Buffer
+	ApiDocsPage(&buf, "/api/openapi.json")
+	testutils.AssertContains(t, buf.String(), `data-spec-url="/api/openapi.json"`, "swagger-ui-dist@"+SwaggerUIVersion)
+}
+
+func TestNavFor(t *testing.T) {
+	nav := NavFor("resetPassword", "en")
+	testutils.AssertEqual(t, nav.Title, "Reset password - Caesura")
+	testutils.AssertEqual(t, len(nav.Breadcrumbs), 3)
+	testutils.AssertEqual(t, nav.Breadcrumbs[1], Breadcrumb{Label: "Sign in", Href: "/login"})
+	testutils.AssertEqual(t, nav.Breadcrumbs[2].Href, "")
+
+	nav = NavFor("people", "nb")
+	testutils.AssertEqual(t, nav.Title, "Personer - Caesura")
+	testutils.AssertEqual(t, nav.Breadcrumbs[0].Label, "Hjem")
+
+	nav = NavFor("unknown-page", "en")
+	testutils.AssertEqual(t, nav.Title, "Caesura")
+	testutils.AssertEqual(t, len(nav.Breadcrumbs), 0)
+}
+
+func TestPagesRenderTitleAndBreadcrumbs(t *testing.T) {
+	var buf bytes.Buffer
+	WritePeopleHTML(&buf, "en")
+	testutils.AssertContains(t, buf.String(), "<title>People - Caesura</title>", `id="breadcrumbs"`, `aria-current="page"`)
+
+	index := string(Index("en"))
+	testutils.AssertNotContains(t, index, `id="breadcrumbs"`)
+}
+
+func TestBulkEditRows(t *testing.T) {
+	var buf bytes.Buffer
+	meta := []pkg.MetaData{{Title: "Piece", Genre: "Pop", Duration: pkg.Duration(90 * time.Second)}, {Title: "Other"}}
+	BulkEditRows(&buf, meta)
+
+	content := buf.String()
+	testutils.AssertEqual(t, strings.Count(content, "data-resource-id="), 2)
+	testutils.AssertEqual(t, strings.Count(content, `data-field="genre"`), 2)
+	testutils.AssertContains(t, content, `data-resource-id="piece"`, `data-original="Pop"`, `value="1m30s"`)
+	testutils.AssertNotContains(t, content, `value="0s"`)
+}
+
+func TestBulkEditPage(t *testing.T) {
+	var buf bytes.Buffer
+	BulkEditPage(&buf, "nb")
+	testutils.AssertContains(t, buf.String(), "<title>Masseredigering - Caesura</title>", `href="/overview"`, "Lagre endringer")
+}
+
+func TestProjectActivity(t *testing.T) {
+	activity := pkg.NewActivity("project", pkg.ActivityPieceAdded, "user", []string{"a", "b"})
+	entries := []ActivityEntry{{Activity: *activity, User: "Susan", Pieces: []string{"Piece A", "Piece B"}}}
+
+	var buf bytes.Buffer
+	ProjectActivity(&buf, "en", "project", entries, 0, true)
+	testutils.AssertContains(t, buf.String(), "<h3", "Susan added 2 piece(s): Piece A, Piece B", "/projects/project/activity?page=1")
+
+	buf.Reset()
+	ProjectActivity(&buf, "nb", "project", nil, 0, false)
+	testutils.AssertContains(t, buf.String(), "Ingenting har skjedd")
+	testutils.AssertNotContains(t, buf.String(), "hx-get")
+}
+
+func TestTrash(t *testing.T) {
+	deletedAt := time.Date(2025, 6, 1, 12, 30, 0, 0, time.UTC)
+	meta := []pkg.MetaData{{Title: "Polka", Deleted: true, DeletedAt: deletedAt}, {Title: "Waltz", Deleted: true}}
+
+	var buf bytes.Buffer
+	Trash(&buf, "en", meta, 30*24*time.Hour)
+	testutils.AssertContains(t, buf.String(), "2025-06-01 12:30", "2025-07-01", `hx-post="/resources/polka/restore"`, "Restore")
+
+	buf.Reset()
+	Trash(&buf, "nb", nil, 0)
+	testutils.AssertContains(t, buf.String(), "Papirkurven er tom")
+}
+
+func TestResourceVersions(t *testing.T) {
+	archivedAt := time.Date(2025, 6, 1, 12, 30, 0, 0, time.UTC)
+	versions := []pkg.ResourceVersion{{Version: 1, ArchivedAt: archivedAt, Files: []string{"Horn.pdf", "Flute.pdf"}}}
+
+	var buf bytes.Buffer
+	ResourceVersions(&buf, "en", "polka", versions)
+	testutils.AssertContains(t, buf.String(), "2025-06-01 12:30", "2 file(s)", `href="/resources/polka/versions/1"`, `hx-post="/resources/polka/versions/1/restore"`)
+
+	buf.Reset()
+	ResourceVersions(&buf, "en", "polka", nil)
+	testutils.AssertEqual(t, strings.TrimSpace(buf.String()), "")
+}
+
+func TestAnnouncements(t *testing.T) {
+	announcements := []pkg.Announcement{
+		{Id: "1", Title: "Concert", Body: "<script>alert(1)</script> **Saturday**", ReadBy: []string{"user"}},
+		{Id: "2", Title: "Rehearsal", ExpiresAt: time.Date(2025, 6, 1, 12, 30, 0, 0, time.UTC)},
+	}
+
+	var buf bytes.Buffer
+	Announcements(&buf, "en", announcements, "user", false)
+	content := buf.String()
+	testutils.AssertContains(t, content, "<strong>Saturday</strong>", "&lt;script&gt;", `hx-post="/announcements/2/read"`, "2025-06-01 12:30")
+	testutils.AssertNotContains(t, content, "<script>", `hx-post="/announcements/1/read"`, "announcement-form", "/expire")
+
+	buf.Reset()
+	Announcements(&buf, "nb", announcements, "user", true)
+	testutils.AssertContains(t, buf.String(), "Kunngjøringer", "announcement-form", `hx-post="/announcements/1/expire"`, "Lest av: 1")
+
+	buf.Reset()
+	Announcements(&buf, "en", nil, "user", false)
+	testutils.AssertEqual(t, strings.TrimSpace(buf.String()), "")
+}
+
+func TestResourceSuggestions(t *testing.T) {
+	var buf bytes.Buffer
+	suggestions := []pkg.Suggestion{{Id: "abc", Title: "Brahms <Symphony>", Composer: "Johannes Brahms"}}
+	ResourceSuggestions(&buf, suggestions)
+	testutils.AssertContains(t, buf.String(), `data-id="abc"`, "Brahms &lt;Symphony&gt;", "Johannes Brahms")
+}
+
+func TestPartNameReport(t *testing.T) {
+	var buf bytes.Buffer
+	PartNameReport(&buf, "en", &pkg.PartNameReport{
+		NumResources: 1,
+		NumParts:     3,
+		Issues: []pkg.PartNameIssue{
+			{ResourceId: "bolero", Title: "Bolero", Kind: pkg.PartNameTypo, Instrument: "Trumpet", Part: "Trumpt 1.pdf", Suggestion: "Trumpet 1.pdf"},
+			{ResourceId: "bolero", Title: "Bolero", Kind: pkg.PartNameNumbering, Instrument: "Horn", Missing: []int{2, 3}},
+		},
+		Renames: []pkg.PartRenameGroup{{Part: "Trumpt 1.pdf", Suggestion: "Trumpet 1.pdf", ResourceIds: []string{"bolero"}}},
+	})
+	testutils.AssertContains(t, buf.String(), "Misspelled instrument", "missing 2, 3", "/resources/bolero/files/Trumpt%201.pdf", `name="resourceId" value="bolero"`)
+
+	buf.Reset()
+	PartNameReport(&buf, "en", &pkg.PartNameReport{})
+	testutils.AssertContains(t, buf.String(), "All part names match")
+}
+
+func TestLoadMoreRow(t *testing.T) {
+	var buf bytes.Buffer
+	LoadMoreRow(&buf, "nb", "/overview/search?cursor=abc&resource-filter=x", 7)
+	testutils.AssertContains(t, buf.String(), `colspan="7"`, `hx-get="/overview/search?cursor=abc&amp;resource-filter=x"`, "Last inn flere")
+}
@@ -1,7 +1,12 @@
 package web
 
 import (
+	"errors"
+	"io/fs"
 	"log/slog"
+	"maps"
+	"regexp"
+	"slices"
 
 	"github.com/davidkleiven/caesura/pkg"
 	"github.com/davidkleiven/caesura/utils"
@@ -29,11 +34,80 @@ func (t *Translator) MustGet(lang, field string) string {
 	return translation
 }
 
-func NewTranslator() *Translator {
-	data := utils.Must(templatesFS.ReadFile("templates/translations.yml"))
+// Func returns the translation of keys into language, as used by T in the templates
+func (t *Translator) Func(language string) func(string) string {
+	return func(f string) string { return t.MustGet(language, f) }
+}
+
+// Languages returns the languages with translations in alphabetical order
+func (t *Translator) Languages() []string {
+	return slices.Sorted(maps.Keys(t.mapping))
+}
+
+// MissingKeys returns the keys each language lacks in alphabetical order. A language is expected to have every key
+// another language has and every key in used. Complete languages get an empty list
+func (t *Translator) MissingKeys(used []string) map[string][]string {
+	expected := make(map[string]struct{})
+	for _, key := range used {
+		expected[key] = struct{}{}
+	}
+	for _, languageMap := range t.mapping {
+		for key := range languageMap {
+			expected[key] = struct{}{}
+		}
+	}
+
+	missing := make(map[string][]string, len(t.mapping))
+	for language, languageMap := range t.mapping {
+		missing[language] = []string{}
+		for key := range expected {
+			if _, ok := languageMap[key]; !ok {
+				missing[language] = append(missing[language], key)
+			}
+		}
+		slices.Sort(missing[language])
+	}
+	return missing
+}
+
+const translationsFile = "translations.yml"
+
+// NewTranslatorFS reads the translations in translations.yml of fsys
+func NewTranslatorFS(fsys fs.FS) (*Translator, error) {
+	data, err := fs.ReadFile(fsys, translationsFile)
+	if err != nil {
+		return nil, err
+	}
+	return NewTranslatorYAML(data)
+}
+
+// NewTranslatorYAML reads translations mapping each language to its keys
+func NewTranslatorYAML(data []byte) (*Translator, error) {
 	var mapping map[string]map[string]string
-	pkg.PanicOnErr(yaml.Unmarshal(data, &mapping))
-	return &Translator{mapping: mapping}
+	if err := yaml.Unmarshal(data, &mapping); err != nil {
+		return nil, err
+	}
+	if _, ok := mapping["en"]; !ok {
+		return nil, errors.New("translations must include the fallback language en")
+	}
+	return &Translator{mapping: mapping}, nil
+}
+
+func NewTranslator() *Translator {
+	return utils.Must(NewTranslatorFS(embeddedTemplates))
+}
+
+// translationCall matches the keys passed as literals to T in the templates
+var translationCall = regexp.MustCompile(`\bT "([^"]+)"`)
+
+// TemplateTranslationKeys returns the keys a template translates with T. Keys built at runtime, as with printf, are
+// not included
+func TemplateTranslationKeys(content string) []string {
+	var keys []string
+	for _, match := range translationCall.FindAllStringSubmatch(content, -1) {
+		keys = append(keys, match[1])
+	}
+	return pkg.RemoveDuplicates(keys)
 }
 
 // Global translator
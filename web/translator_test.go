@@ -1,6 +1,7 @@
 package web
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/davidkleiven/caesura/testutils"
@@ -40,3 +41,24 @@ func TestPanicOnMissingFallback(t *testing.T) {
 	}()
 	tr.MustGet("unknown-language", "field")
 }
+
+func TestMissingKeys(t *testing.T) {
+	tr, err := NewTranslatorYAML([]byte("en:\n  a: A\n  b: B\nnb:\n  a: A\n"))
+	testutils.AssertNil(t, err)
+
+	missing := tr.MissingKeys([]string{"c", "a"})
+	testutils.AssertEqual(t, strings.Join(missing["en"], ","), "c")
+	testutils.AssertEqual(t, strings.Join(missing["nb"], ","), "b,c")
+}
+
+func TestTranslatorRequiresFallbackLanguage(t *testing.T) {
+	_, err := NewTranslatorYAML([]byte("nb:\n  a: A\n"))
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+}
+
+func TestTemplateTranslationKeys(t *testing.T) {
+	content := `{{ T "a.title" }} {{T "b"}} {{ T (printf "c.%s" .) }} {{ T "a.title" }}`
+	testutils.AssertEqual(t, strings.Join(TemplateTranslationKeys(content), ","), "a.title,b")
+}
@@ -0,0 +1,20 @@
+package web
+
+import (
+	"io"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+type UserSessionsData struct {
+	Sessions []pkg.UserSession
+
+	// Id of the session making the request
+	CurrentId string
+}
+
+// UserSessions renders the signed in sessions of the user
+func UserSessions(w io.Writer, language string, data UserSessionsData) {
+	tmpl := lookupTemplate("user-sessions", language)
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "user-sessions", data))
+}
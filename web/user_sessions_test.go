@@ -0,0 +1,26 @@
+package web
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestUserSessions(t *testing.T) {
+	seen := time.Date(2026, 6, 1, 12, 30, 0, 0, time.UTC)
+	userSessions := []pkg.UserSession{
+		{Id: "laptop", UserAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0", IP: "10.0.0.1", LastSeenAt: seen},
+		{Id: "phone", LastSeenAt: seen},
+	}
+
+	var buf bytes.Buffer
+	UserSessions(&buf, "nb", UserSessionsData{Sessions: userSessions, CurrentId: "laptop"})
+	testutils.AssertContains(
+		t, buf.String(), "Innloggede enheter", "Firefox on Linux", "(denne enheten)", "2026-06-01 12:30", "10.0.0.1",
+		"Ukjent enhet", `hx-delete="/sessions/phone"`,
+	)
+	testutils.AssertNotContains(t, buf.String(), `hx-delete="/sessions/laptop"`)
+}
@@ -0,0 +1,28 @@
+package web
+
+import (
+	"io"
+	"slices"
+
+	"github.com/davidkleiven/caesura/pkg"
+)
+
+type WebhookData struct {
+	// Webhook of the organization. Nil when the organization has no webhook
+	Webhook *pkg.Webhook
+
+	// Events are all the events a webhook can subscribe to
+	Events     []pkg.WebhookEvent
+	Deliveries []pkg.WebhookDelivery
+}
+
+// Subscribed reports whether the event is checked in the form. Every event is checked for new webhooks
+func (d *WebhookData) Subscribed(event pkg.WebhookEvent) bool {
+	return d.Webhook == nil || len(d.Webhook.Events) == 0 || slices.Contains(d.Webhook.Events, event)
+}
+
+// Webhook renders the form for the webhook of the organization and its most recent deliveries
+func Webhook(w io.Writer, language string, data *WebhookData) {
+	tmpl := lookupTemplate("webhook", language)
+	pkg.PanicOnErr(tmpl.ExecuteTemplate(w, "webhook", data))
+}
@@ -0,0 +1,32 @@
+package web
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/caesura/pkg"
+	"github.com/davidkleiven/caesura/testutils"
+)
+
+func TestWebhook(t *testing.T) {
+	var buf bytes.Buffer
+	Webhook(&buf, "en", &WebhookData{Events: pkg.WebhookEvents})
+	testutils.AssertContains(t, buf.String(), `value="resource.created" checked`, `id="webhook-enabled" type="checkbox" name="enabled" value="on" checked`)
+	testutils.AssertNotContains(t, buf.String(), "webhook-delete-btn", "webhook-rotate")
+
+	buf.Reset()
+	data := &WebhookData{
+		Webhook: &pkg.Webhook{Url: "https://example.com/hooks", Secret: "whsec_abc", Events: []pkg.WebhookEvent{pkg.WebhookUserInvited}},
+		Events:  pkg.WebhookEvents,
+		Deliveries: []pkg.WebhookDelivery{
+			{Event: pkg.WebhookUserInvited, TargetId: "invitation", Attempts: 4, Error: "<timeout>", CreatedAt: time.Date(2026, 5, 1, 12, 30, 0, 0, time.UTC)},
+		},
+	}
+	Webhook(&buf, "nb", data)
+	testutils.AssertContains(
+		t, buf.String(), "https://example.com/hooks", "whsec_abc", `value="user.invited" checked`, "Feilet", "2026-05-01 12:30",
+		"&lt;timeout&gt;", "webhook-delete-btn",
+	)
+	testutils.AssertNotContains(t, buf.String(), `value="resource.created" checked`, `name="enabled" value="on" checked`)
+}
@@ -3,6 +3,7 @@ package web_test
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
@@ -81,13 +82,18 @@ func TestMain(m *testing.M) {
 	defer browser.Close()
 	fmt.Printf("Browser launched: version=%s name=%s connected=%v\n", browser.Version(), browser.BrowserType().Name(), browser.IsConnected())
 
+	server = httptest.NewUnstartedServer(nil)
 	config := pkg.NewDefaultConfig()
 	config.SmtpConfig.SendFn = pkg.NoOpSendFunc
 	config.PortalSessionProvider = "fixed"
+	config.DevTools = true
+	config.MockOAuth = true
+	config.BaseURL = "http://" + server.Listener.Addr().String()
+	config.GoogleAuthRedirectURL = config.BaseURL + api.RouteAuthCallback
 
 	// The key must match the store used to get the cookie value
-	mux := api.Setup(store, config, cookieStore)
-	server = httptest.NewServer(mux)
+	server.Config.Handler = api.Setup(store, config, cookieStore)
+	server.Start()
 	defer server.Close()
 
 	fmt.Printf("Test server started. url=%s\n", server.URL)
@@ -96,14 +102,18 @@ func TestMain(m *testing.M) {
 	os.Exit(rcode)
 }
 
+func resetStore(t *testing.T) {
+	resp, err := http.Post(server.URL+api.RouteDevToolsReset, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	testutils.AssertEqual(t, resp.StatusCode, http.StatusOK)
+}
+
 func withBrowser(testFunc func(t *testing.T, page playwright.Page), path string) func(t *testing.T) {
 	return func(t *testing.T) {
-		initialStore := store.Clone()
-		defer func() {
-			store.Data = initialStore.Data
-			store.Organizations = initialStore.Organizations
-			store.Users = initialStore.Users
-		}()
+		defer resetStore(t)
 
 		context, err := browser.NewContext()
 		if err != nil {
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/davidkleiven/caesura/api"
 	"github.com/davidkleiven/caesura/testutils"
 	"github.com/playwright-community/playwright-go"
 )
@@ -130,3 +131,19 @@ func TestLogout(t *testing.T) {
 		}
 	}, loginPage)(t)
 }
+
+func TestGoogleLoginWithMockProvider(t *testing.T) {
+	withBrowser(func(t *testing.T, page playwright.Page) {
+		_, err := page.Goto(server.URL + api.RouteLoginGoogle)
+		testutils.AssertNil(t, err)
+
+		err = page.GetByRole("link", playwright.PageGetByRoleOptions{Name: "New Member"}).Click()
+		testutils.AssertNil(t, err)
+
+		err = page.WaitForURL("**/organizations")
+		testutils.AssertNil(t, err)
+
+		_, err = store.UserByEmail(context.Background(), "new.member@example.com")
+		testutils.AssertNil(t, err)
+	}, loginPage)(t)
+}